@@ -252,6 +252,9 @@ func NewTPacket(opts ...interface{}) (h *TPacket, err error) {
 	if err = h.setRequestedTPacketVersion(); err != nil {
 		goto errlbl
 	}
+	if err = setQdiscBypass(h.fd, h.opts.qdiscBypass); err != nil {
+		goto errlbl
+	}
 	if err = h.setUpRing(); err != nil {
 		goto errlbl
 	}
@@ -108,6 +108,13 @@ type OptPollTimeout time.Duration
 // be provided if available.
 type OptAddVLANHeader bool
 
+// OptQdiscBypass sends packets written to the socket straight to the
+// network device, bypassing the kernel's queueing discipline layer.  This
+// saves time per packet, at the cost of packets being dropped rather than
+// queued when the device's transmit queue is full.
+// It can be passed into NewTPacket and NewTXRing.
+type OptQdiscBypass bool
+
 // Default constants used by options.
 const (
 	DefaultFrameSize    = 4096                   // Default value for OptFrameSize.
@@ -123,6 +130,7 @@ type options struct {
 	blockSize      int
 	numBlocks      int
 	addVLANHeader  bool
+	qdiscBypass    bool
 	blockTimeout   time.Duration
 	pollTimeout    time.Duration
 	version        OptTPacketVersion
@@ -162,6 +170,8 @@ func parseOptions(opts ...interface{}) (ret options, err error) {
 			ret.socktype = v
 		case OptAddVLANHeader:
 			ret.addVLANHeader = bool(v)
+		case OptQdiscBypass:
+			ret.qdiscBypass = bool(v)
 		default:
 			err = errors.New("unknown type in options")
 			return
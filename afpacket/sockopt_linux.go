@@ -9,6 +9,7 @@
 package afpacket
 
 import (
+	"fmt"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -56,3 +57,14 @@ func getsockopt(fd, level, name int, val unsafe.Pointer, vallen uintptr) error {
 func htons(i uint16) uint16 {
 	return (i<<8)&0xff00 | i>>8
 }
+
+// setQdiscBypass sets PACKET_QDISC_BYPASS on a packet socket if requested.
+func setQdiscBypass(fd int, bypass bool) error {
+	if !bypass {
+		return nil
+	}
+	if err := unix.SetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_QDISC_BYPASS, 1); err != nil {
+		return fmt.Errorf("setsockopt packet_qdisc_bypass: %v", err)
+	}
+	return nil
+}
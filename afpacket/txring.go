@@ -0,0 +1,376 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// +build linux
+
+package afpacket
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// #include <linux/if_packet.h>
+import "C"
+
+// TXFrameError reports a packet the kernel refused to send from a TXRing, as
+// flagged in the status word of its frame.
+type TXFrameError struct {
+	// Packet is the number of the rejected packet, counting the packets
+	// written to the ring from 0.
+	Packet uint64
+	// Status is the status word of the packet's frame, which has
+	// TP_STATUS_WRONG_FORMAT set.
+	Status uint32
+}
+
+func (e TXFrameError) Error() string {
+	return fmt.Sprintf("packet %d rejected by kernel, frame status %#x", e.Packet, e.Status)
+}
+
+// TXFrameErrors is returned by TXRing.Flush when the kernel rejected some of
+// the packets it sent.
+type TXFrameErrors []TXFrameError
+
+func (e TXFrameErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%d packets rejected by kernel, first: %v", len(e), e[0])
+}
+
+// TXStats is a set of counters detailing the work a TXRing has done so far.
+type TXStats struct {
+	// Packets is the total number of packets written to the ring.
+	Packets int64
+	// Flushes is the number of send syscalls made to transmit them.
+	Flushes int64
+}
+
+// TXRing implements packet transmission through a Linux AF_PACKET
+// PACKET_TX_RING.  Packets are staged into frames of a ring buffer shared
+// with the kernel, then all sent with a single syscall, rather than one
+// syscall per packet as with TPacket.WritePacketData.
+type TXRing struct {
+	// stats is simple statistics on TXRing's run. This MUST be the first entry to ensure alignment for sync.atomic
+	stats TXStats
+	// fd is the C file descriptor.
+	fd int
+	// ring points to the memory space of the ring buffer shared by tpacket and the kernel.
+	ring []byte
+	// opts contains read-only options for the TXRing object.
+	opts options
+	// numFrames is the number of frames in the ring.
+	numFrames int
+	// dataOffset is the offset of the packet data within each frame.
+	dataOffset int
+
+	mu sync.Mutex // guards below
+	// next is the index of the next frame to fill.
+	next int
+	// staged is the number of frames filled since they were last sent,
+	// the oldest of which is staged frames before next.
+	staged int
+	// seq holds the number of the packet in each frame.
+	seq []uint64
+	// frameErrors holds the packets rejected since the last Flush.
+	frameErrors TXFrameErrors
+}
+
+// NewTXRing returns a new TXRing object for writing packets to the wire.
+// Its behavior may be modified by passing in afpacket.Opt* to this function:
+// OptInterface is required, and OptFrameSize, OptBlockSize, OptNumBlocks,
+// OptPollTimeout and OptQdiscBypass are honored.  TX rings use TPacket
+// version 2 with SocketRaw, so written packets must include their link layer
+// header.
+// If this function succeeds, the user should be sure to Close the returned
+// TXRing when finished with it.
+func NewTXRing(opts ...interface{}) (t *TXRing, err error) {
+	t = &TXRing{fd: -1}
+	if t.opts, err = parseOptions(opts...); err != nil {
+		return nil, err
+	}
+	switch {
+	case t.opts.iface == "":
+		return nil, errors.New("TX ring requires an interface")
+	case t.opts.socktype != SocketRaw:
+		return nil, fmt.Errorf("TX ring doesn't support socket type %v", t.opts.socktype)
+	case t.opts.version != TPacketVersionHighestAvailable && t.opts.version != TPacketVersion2:
+		return nil, fmt.Errorf("TX ring doesn't support tpacket version %v", t.opts.version)
+	}
+	// A protocol of 0 keeps the socket from receiving any packets.
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, 0)
+	if err != nil {
+		return nil, err
+	}
+	t.fd = fd
+	if err = t.setUpRing(); err != nil {
+		t.Close()
+		return nil, err
+	}
+	runtime.SetFinalizer(t, (*TXRing).Close)
+	return t, nil
+}
+
+// setUpRing binds the socket and sets up the shared-memory ring buffer
+// between the user process and the kernel.
+func (t *TXRing) setUpRing() (err error) {
+	iface, err := net.InterfaceByName(t.opts.iface)
+	if err != nil {
+		return fmt.Errorf("InterfaceByName: %v", err)
+	}
+	if err = unix.Bind(t.fd, &unix.SockaddrLinklayer{Ifindex: iface.Index}); err != nil {
+		return err
+	}
+	if err = unix.SetsockoptInt(t.fd, unix.SOL_PACKET, unix.PACKET_VERSION, int(TPacketVersion2)); err != nil {
+		return fmt.Errorf("setsockopt packet_version: %v", err)
+	}
+	if err = setQdiscBypass(t.fd, t.opts.qdiscBypass); err != nil {
+		return err
+	}
+	t.numFrames = t.opts.framesPerBlock * t.opts.numBlocks
+	// Without PACKET_TX_HAS_OFF, the kernel expects the packet data
+	// right after the aligned frame header.
+	t.dataOffset = tpAlign(int(C.sizeof_struct_tpacket2_hdr))
+	t.seq = make([]uint64, t.numFrames)
+	return t.mapRing()
+}
+
+// mapRing asks the kernel for the ring and maps it into memory.
+func (t *TXRing) mapRing() (err error) {
+	var tp C.struct_tpacket_req
+	tp.tp_block_size = C.uint(t.opts.blockSize)
+	tp.tp_block_nr = C.uint(t.opts.numBlocks)
+	tp.tp_frame_size = C.uint(t.opts.frameSize)
+	tp.tp_frame_nr = C.uint(t.numFrames)
+	if err = setsockopt(t.fd, unix.SOL_PACKET, unix.PACKET_TX_RING, unsafe.Pointer(&tp), unsafe.Sizeof(tp)); err != nil {
+		return fmt.Errorf("setsockopt packet_tx_ring: %v", err)
+	}
+	t.ring, err = unix.Mmap(t.fd, 0, t.numFrames*t.opts.frameSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	return err
+}
+
+// Close cleans up the TXRing, dropping any packets not yet flushed.  It
+// should not be used after the Close call.
+func (t *TXRing) Close() {
+	if t.fd == -1 {
+		return // already closed.
+	}
+	if t.ring != nil {
+		unix.Munmap(t.ring)
+	}
+	t.ring = nil
+	unix.Close(t.fd)
+	t.fd = -1
+	runtime.SetFinalizer(t, nil)
+}
+
+// MaxPacketSize returns the size of the largest packet that fits in a frame.
+func (t *TXRing) MaxPacketSize() int {
+	return t.opts.frameSize - t.dataOffset
+}
+
+// Stats returns statistics on the packets the TXRing has sent so far.
+func (t *TXRing) Stats() (TXStats, error) {
+	return TXStats{
+		Packets: atomic.LoadInt64(&t.stats.Packets),
+		Flushes: atomic.LoadInt64(&t.stats.Flushes),
+	}, nil
+}
+
+func (t *TXRing) frame(i int) *v2header {
+	return (*v2header)(unsafe.Pointer(&t.ring[i*t.opts.frameSize]))
+}
+
+// WritePacketData copies a raw packet into the next free frame of the ring,
+// to be sent by the next Flush.  If the ring is full of packets waiting to be
+// sent, they're flushed first, and any packets the kernel rejects among them
+// are reported by the next Flush.
+func (t *TXRing) WritePacketData(pkt []byte) error {
+	if len(pkt) > t.MaxPacketSize() {
+		return fmt.Errorf("packet length %d exceeds maximum of %d", len(pkt), t.MaxPacketSize())
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ring == nil {
+		return errors.New("TX ring is not mapped")
+	}
+	if t.frame(t.next).tp_status != C.TP_STATUS_AVAILABLE {
+		if err := t.waitForFrameLocked(); err != nil {
+			return err
+		}
+	}
+	t.stageLocked(pkt, uint64(atomic.AddInt64(&t.stats.Packets, 1)-1))
+	return nil
+}
+
+// stageLocked copies a packet into the next frame, which must be available,
+// and flags it for sending.
+func (t *TXRing) stageLocked(pkt []byte, seq uint64) {
+	hdr := t.frame(t.next)
+	start := t.next*t.opts.frameSize + t.dataOffset
+	copy(t.ring[start:start+len(pkt)], pkt)
+	hdr.tp_len = C.__u32(len(pkt))
+	hdr.tp_status = C.TP_STATUS_SEND_REQUEST
+	t.seq[t.next] = seq
+	t.next = (t.next + 1) % t.numFrames
+	t.staged++
+}
+
+// Flush sends all packets written since the last Flush with one syscall,
+// returning once the kernel is done with them.  If the kernel rejected some
+// of the packets, the rest are still sent and Flush returns TXFrameErrors
+// describing the rejected ones.
+func (t *TXRing) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ring == nil {
+		return errors.New("TX ring is not mapped")
+	}
+	if err := t.flushLocked(); err != nil {
+		return err
+	}
+	if errs := t.frameErrors; len(errs) > 0 {
+		t.frameErrors = nil
+		return errs
+	}
+	return nil
+}
+
+// waitForFrameLocked flushes the ring and waits until the kernel hands the
+// next frame back to us.
+func (t *TXRing) waitForFrameLocked() error {
+	if err := t.flushLocked(); err != nil {
+		return err
+	}
+	// Flushing may have set up the ring again, so look the frame up now.
+	hdr := t.frame(t.next)
+	tm := int(t.opts.pollTimeout / time.Millisecond)
+	for hdr.tp_status != C.TP_STATUS_AVAILABLE {
+		pollset := [1]unix.PollFd{
+			{
+				Fd:     int32(t.fd),
+				Events: unix.POLLOUT,
+			},
+		}
+		n, err := unix.Poll(pollset[:], tm)
+		if n == 0 {
+			return ErrTimeout
+		}
+		if pollset[0].Revents&unix.POLLERR > 0 {
+			return ErrPoll
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushLocked sends the staged frames, recording the ones the kernel rejects.
+func (t *TXRing) flushLocked() error {
+	for {
+		t.trimSentLocked()
+		if t.staged == 0 {
+			return nil
+		}
+		atomic.AddInt64(&t.stats.Flushes, 1)
+		err := t.send()
+		t.trimSentLocked()
+		// The kernel stops at the first frame it can't send, flagging
+		// it with TP_STATUS_WRONG_FORMAT.
+		bad := t.oldestStagedLocked()
+		if t.staged == 0 || t.frame(bad).tp_status&C.TP_STATUS_WRONG_FORMAT == 0 {
+			return err
+		}
+		t.frameErrors = append(t.frameErrors, TXFrameError{
+			Packet: t.seq[bad],
+			Status: uint32(t.frame(bad).tp_status),
+		})
+		if err := t.skipFrameLocked(bad); err != nil {
+			return err
+		}
+	}
+}
+
+// oldestStagedLocked returns the index of the oldest staged frame.
+func (t *TXRing) oldestStagedLocked() int {
+	return (t.next - t.staged + t.numFrames) % t.numFrames
+}
+
+// trimSentLocked drops the frames the kernel has sent from the staged ones.
+func (t *TXRing) trimSentLocked() {
+	for t.staged > 0 && t.frame(t.oldestStagedLocked()).tp_status == C.TP_STATUS_AVAILABLE {
+		t.staged--
+	}
+}
+
+// skipFrameLocked gets past a frame the kernel rejected.  The kernel won't
+// send anything past a rejected frame, and PACKET_LOSS, which makes it drop
+// them instead, can't be changed once the ring is set up and would hide which
+// packets were rejected.  So the ring is set up again from scratch, and the
+// packets staged after the rejected one are copied back into it.
+func (t *TXRing) skipFrameLocked(bad int) error {
+	type stagedPacket struct {
+		data []byte
+		seq  uint64
+	}
+	var held []stagedPacket
+	for i := (bad + 1) % t.numFrames; i != t.next; i = (i + 1) % t.numFrames {
+		if hdr := t.frame(i); hdr.tp_status == C.TP_STATUS_SEND_REQUEST {
+			start := i*t.opts.frameSize + t.dataOffset
+			held = append(held, stagedPacket{
+				data: append([]byte(nil), t.ring[start:start+int(hdr.tp_len)]...),
+				seq:  t.seq[i],
+			})
+		}
+	}
+	if err := unix.Munmap(t.ring); err != nil {
+		return err
+	}
+	t.ring = nil
+	// A zero request releases the ring.
+	var tp C.struct_tpacket_req
+	if err := setsockopt(t.fd, unix.SOL_PACKET, unix.PACKET_TX_RING, unsafe.Pointer(&tp), unsafe.Sizeof(tp)); err != nil {
+		return fmt.Errorf("setsockopt packet_tx_ring release: %v", err)
+	}
+	if err := t.mapRing(); err != nil {
+		return err
+	}
+	t.next, t.staged = 0, 0
+	for _, p := range held {
+		t.stageLocked(p.data, p.seq)
+	}
+	return nil
+}
+
+// send asks the kernel to transmit all frames flagged TP_STATUS_SEND_REQUEST,
+// waiting until it's done.
+func (t *TXRing) send() error {
+	for {
+		_, _, errno := unix.Syscall6(unix.SYS_SENDTO, uintptr(t.fd), 0, 0, 0, 0, 0)
+		switch errno {
+		case 0:
+			return nil
+		case syscall.EINTR:
+			continue
+		default:
+			return errno
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// +build linux
+
+package afpacket
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTXRingOptions(t *testing.T) {
+	for i, opts := range [][]interface{}{
+		{},
+		{OptInterface("lo"), SocketDgram},
+		{OptInterface("lo"), TPacketVersion3},
+	} {
+		if tx, err := NewTXRing(opts...); err == nil {
+			tx.Close()
+			t.Errorf("%d: expected error for options %v", i, opts)
+		}
+	}
+}
+
+func TestTXRingLoopback(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+	rx, err := NewTPacket(OptInterface("lo"), OptPollTimeout(time.Second))
+	if err != nil {
+		t.Skip("unable to open receive socket:", err)
+	}
+	defer rx.Close()
+	tx, err := NewTXRing(OptInterface("lo"), OptFrameSize(2048), OptBlockSize(pageSize), OptNumBlocks(1))
+	if err != nil {
+		t.Skip("unable to open TX ring:", err)
+	}
+	defer tx.Close()
+
+	pkt := []byte{
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x01, 0x02, 0x00, 0x00, 0x00, 0x00, 0x02, 0x88, 0xb5,
+		'g', 'o', 'p', 'a', 'c', 'k', 'e', 't',
+	}
+	// Write more packets than the ring has frames, so it flushes itself.
+	n := pageSize/2048 + 1
+	for i := 0; i < n; i++ {
+		if err := tx.WritePacketData(pkt); err != nil {
+			t.Fatal("write:", err)
+		}
+	}
+	// A packet shorter than a link layer header is rejected, but doesn't
+	// keep the packets after it from being sent.
+	if err := tx.WritePacketData(pkt[:4]); err != nil {
+		t.Fatal("write:", err)
+	}
+	if err := tx.WritePacketData(pkt); err != nil {
+		t.Fatal("write:", err)
+	}
+	err = tx.Flush()
+	if errs, ok := err.(TXFrameErrors); !ok || len(errs) != 1 || errs[0].Packet != uint64(n) {
+		t.Errorf("unexpected flush result %v", err)
+	}
+	if stats, _ := tx.Stats(); stats.Packets != int64(n+2) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	received := 0
+	for received < n+1 {
+		data, _, err := rx.ZeroCopyReadPacketData()
+		if err != nil {
+			t.Fatalf("read after %d packets: %v", received, err)
+		}
+		if bytes.Equal(data, pkt) {
+			received++
+		}
+	}
+}
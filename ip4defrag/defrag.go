@@ -55,6 +55,12 @@ const (
 // the packet, a new IPv4 layer will be returned, and will be set to
 // the entire defragmented packet,
 //
+// Fragments are tracked per source, destination, Id and protocol.  A
+// fragment whose data was all received already is ignored, so the first
+// copy of any data wins.  A fragment that partially overlaps data already
+// received is never legitimate (this is how teardrop-style attacks work),
+// so it is rejected with an error and the whole datagram is discarded.
+//
 // It use a map of all the running flows
 //
 // Usage example:
@@ -104,21 +110,29 @@ func (d *IPv4Defragmenter) DefragIPv4WithTimestamp(in *layers.IPv4, t time.Time)
 	var fl *fragmentList
 	var exist bool
 	d.Lock()
+	defer d.Unlock()
 	fl, exist = d.ipFlows[ipf]
 	if !exist {
+		if d.MaxFlows > 0 && len(d.ipFlows) >= d.MaxFlows {
+			return nil, fmt.Errorf("defrag: already reassembling the "+
+				"maximum of %d datagrams", d.MaxFlows)
+		}
 		debug.Printf("defrag: unknown flow, creating a new one\n")
 		fl = new(fragmentList)
 		d.ipFlows[ipf] = fl
 	}
-	d.Unlock()
 	// insert, and if final build it
-	out, err2 := fl.insert(in, t)
+	out, err2 := fl.insert(in, t, d.MaxFlowBytes)
+	if err2 != nil {
+		delete(d.ipFlows, ipf)
+		return nil, err2
+	}
 
 	// at last, if we hit the maximum frag list len
 	// without any defrag success, we just drop everything and
 	// raise an error
 	if out == nil && fl.List.Len()+1 > IPv4MaximumFragmentListLen {
-		d.flush(ipf)
+		delete(d.ipFlows, ipf)
 		return nil, fmt.Errorf("defrag: Fragment List hits its maximum"+
 			"size(%d), without success. Flushing the list",
 			IPv4MaximumFragmentListLen)
@@ -128,10 +142,10 @@ func (d *IPv4Defragmenter) DefragIPv4WithTimestamp(in *layers.IPv4, t time.Time)
 	if out != nil {
 		// when defrag is done for a flow between two ip
 		// clean the list
-		d.flush(ipf)
+		delete(d.ipFlows, ipf)
 		return out, nil
 	}
-	return nil, err2
+	return nil, nil
 }
 
 // DiscardOlderThan forgets all packets without any activity since
@@ -150,13 +164,6 @@ func (d *IPv4Defragmenter) DiscardOlderThan(t time.Time) int {
 	return nb
 }
 
-// flush the fragment list for a particular flow
-func (d *IPv4Defragmenter) flush(ipf ipv4) {
-	d.Lock()
-	delete(d.ipFlows, ipf)
-	d.Unlock()
-}
-
 // dontDefrag returns true if the IPv4 packet do not need
 // any defragmentation
 func (d *IPv4Defragmenter) dontDefrag(ip *layers.IPv4) bool {
@@ -173,6 +180,10 @@ func (d *IPv4Defragmenter) dontDefrag(ip *layers.IPv4) bool {
 
 // securityChecks performs the needed security checks
 func (d *IPv4Defragmenter) securityChecks(ip *layers.IPv4) error {
+	if int(ip.Length) < int(ip.IHL)*4 {
+		return fmt.Errorf("defrag: fragment length %d shorter "+
+			"than its header", ip.Length)
+	}
 	fragSize := ip.Length - uint16(ip.IHL)*4
 
 	// don't allow small fragments outside of specification
@@ -189,138 +200,149 @@ func (d *IPv4Defragmenter) securityChecks(ip *layers.IPv4) error {
 	fragOffset := ip.FragOffset * 8
 
 	// don't allow fragment that would oversize an IP packet
-	if fragOffset+ip.Length > IPv4MaximumSize {
+	if int(fragOffset)+int(ip.Length) > IPv4MaximumSize {
 		return fmt.Errorf("defrag: fragment will overrun "+
-			"(handcrafted? %d > %d)", fragOffset+ip.Length, IPv4MaximumSize)
+			"(handcrafted? %d > %d)", int(fragOffset)+int(ip.Length), IPv4MaximumSize)
 	}
 
 	return nil
 }
 
 // fragmentList holds a container/list used to contains IP
-// packets/fragments.  It stores internal counters to track the
-// maximum total of byte, and the current length it has received.
-// It also stores a flag to know if he has seen the last packet.
+// packets/fragments, sorted by offset and never overlapping.  It
+// stores internal counters to track the end of the data seen so far,
+// and the number of bytes it has received.  It also stores a flag
+// to know if he has seen the last packet.
 type fragmentList struct {
 	List          list.List
-	Highest       uint16
-	Current       uint16
+	Highest       int
+	Current       int
 	FinalReceived bool
 	LastSeen      time.Time
 }
 
-// insert insert an IPv4 fragment/packet into the Fragment List
-// It use the following strategy : we are inserting fragment based
-// on their offset, latest first. This is sometimes called BSD-Right.
-// See: http://www.sans.org/reading-room/whitepapers/detection/ip-fragment-reassembly-scapy-33969
-func (f *fragmentList) insert(in *layers.IPv4, t time.Time) (*layers.IPv4, error) {
+// fragmentBounds returns the offset of the first byte of data in
+// fragment ip, and the offset just past its last byte.
+func fragmentBounds(ip *layers.IPv4) (start, end int) {
+	start = int(ip.FragOffset) * 8
+	return start, start + int(ip.Length) - int(ip.IHL)*4
+}
+
+// insert insert an IPv4 fragment/packet into the Fragment List,
+// keeping the list sorted by offset.  Fragments entirely covered by
+// ones already in the list are dropped; fragments partially
+// overlapping them are an error.  maxBytes, if not 0, limits the
+// number of bytes held in the list.
+func (f *fragmentList) insert(in *layers.IPv4, t time.Time, maxBytes int) (*layers.IPv4, error) {
 	// TODO: should keep a copy of *in in the list
 	// or not (ie the packet source is reliable) ? -> depends on Lazy / last packet
-	fragOffset := in.FragOffset * 8
-	if fragOffset >= f.Highest {
-		f.List.PushBack(in)
-	} else {
-		for e := f.List.Front(); e != nil; e = e.Next() {
-			frag, _ := e.Value.(*layers.IPv4)
-			if in.FragOffset == frag.FragOffset {
-				// TODO: what if we receive a fragment
-				// that begins with duplicate data but
-				// *also* has new data? For example:
-				//
-				// AAAA
-				//     BB
-				//     BBCC
-				//         DDDD
-				//
-				// In this situation we completely
-				// ignore CC and the complete packet can
-				// never be reassembled.
-				debug.Printf("defrag: ignoring frag %d as we already have it (duplicate?)\n",
-					fragOffset)
-				return nil, nil
-			}
-			if in.FragOffset < frag.FragOffset {
-				debug.Printf("defrag: inserting frag %d before existing frag %d\n",
-					fragOffset, frag.FragOffset*8)
-				f.List.InsertBefore(in, e)
-				break
-			}
+	fragOffset, fragEnd := fragmentBounds(in)
+	f.LastSeen = t
+
+	var next *list.Element
+	for e := f.List.Front(); e != nil; e = e.Next() {
+		frag, _ := e.Value.(*layers.IPv4)
+		start, end := fragmentBounds(frag)
+		if fragOffset >= start && fragEnd <= end {
+			debug.Printf("defrag: ignoring frag %d as we already have it (duplicate?)\n",
+				fragOffset)
+			return nil, nil
+		}
+		if fragOffset < end && fragEnd > start {
+			return nil, fmt.Errorf("defrag: fragment %d-%d overlaps "+
+				"fragment %d-%d", fragOffset, fragEnd, start, end)
 		}
+		if next == nil && start > fragOffset {
+			next = e
+		}
+	}
+	if f.FinalReceived && fragEnd > f.Highest {
+		return nil, fmt.Errorf("defrag: fragment %d-%d is past the "+
+			"final fragment", fragOffset, fragEnd)
+	}
+	final := in.Flags&layers.IPv4MoreFragments == 0
+	if final && fragEnd < f.Highest {
+		return nil, fmt.Errorf("defrag: final fragment ends at %d, "+
+			"before data already received", fragEnd)
+	}
+	if maxBytes > 0 && f.Current+fragEnd-fragOffset > maxBytes {
+		return nil, fmt.Errorf("defrag: datagram exceeds the limit "+
+			"of %d bytes", maxBytes)
 	}
 
-	f.LastSeen = t
+	if next != nil {
+		debug.Printf("defrag: inserting frag %d before existing frag\n",
+			fragOffset)
+		f.List.InsertBefore(in, next)
+	} else {
+		f.List.PushBack(in)
+	}
 
-	fragLength := in.Length - 20
 	// After inserting the Fragment, we update the counters
-	if f.Highest < fragOffset+fragLength {
-		f.Highest = fragOffset + fragLength
+	if f.Highest < fragEnd {
+		f.Highest = fragEnd
 	}
-	f.Current = f.Current + fragLength
+	f.Current += fragEnd - fragOffset
 
 	debug.Printf("defrag: insert ListLen: %d Highest:%d Current:%d\n",
 		f.List.Len(),
 		f.Highest, f.Current)
 
 	// Final Fragment ?
-	if in.Flags&layers.IPv4MoreFragments == 0 {
+	if final {
 		f.FinalReceived = true
 	}
 	// Ready to try defrag ?
 	if f.FinalReceived && f.Highest == f.Current {
-		return f.build(in)
+		return f.build()
 	}
 	return nil, nil
 }
 
-// Build builds the final datagram, modifying ip in place.
-// It puts priority to packet in the early position of the list.
-// See Insert for more details.
-func (f *fragmentList) build(in *layers.IPv4) (*layers.IPv4, error) {
-	var final []byte
-	var currentOffset uint16
+// build builds the final datagram from the fragments in the list,
+// which must leave no holes.  The header is taken from the first
+// fragment, as RFC 791 requires.
+func (f *fragmentList) build() (*layers.IPv4, error) {
+	final := make([]byte, 0, f.Highest)
+	var currentOffset int
 
 	debug.Printf("defrag: building the datagram \n")
 	for e := f.List.Front(); e != nil; e = e.Next() {
 		frag, _ := e.Value.(*layers.IPv4)
-		if frag.FragOffset*8 == currentOffset {
-			debug.Printf("defrag: building - adding %d\n", frag.FragOffset*8)
-			final = append(final, frag.Payload...)
-			currentOffset = currentOffset + frag.Length - 20
-		} else if frag.FragOffset*8 < currentOffset {
-			// overlapping fragment - let's take only what we need
-			startAt := currentOffset - frag.FragOffset*8
-			debug.Printf("defrag: building - overlapping, starting at %d\n",
-				startAt)
-			if startAt > frag.Length-20 {
-				return nil, errors.New("defrag: building - invalid fragment")
-			}
-			final = append(final, frag.Payload[startAt:]...)
-			currentOffset = currentOffset + frag.FragOffset*8
-		} else {
+		start, end := fragmentBounds(frag)
+		if start != currentOffset {
 			// Houston - we have an hole !
 			debug.Printf("defrag: hole found while building, " +
 				"stopping the defrag process\n")
 			return nil, errors.New("defrag: building - hole found")
 		}
+		if len(frag.Payload) < end-start {
+			return nil, fmt.Errorf("defrag: building - fragment %d "+
+				"has %d bytes of its %d", start, len(frag.Payload), end-start)
+		}
+		debug.Printf("defrag: building - adding %d\n", start)
+		final = append(final, frag.Payload[:end-start]...)
+		currentOffset = end
 		debug.Printf("defrag: building - next is %d\n", currentOffset)
 	}
 
 	// TODO recompute IP Checksum
+	first := f.List.Front().Value.(*layers.IPv4)
 	out := &layers.IPv4{
-		Version:    in.Version,
-		IHL:        in.IHL,
-		TOS:        in.TOS,
-		Length:     f.Highest,
-		Id:         in.Id,
+		Version:    first.Version,
+		IHL:        first.IHL,
+		TOS:        first.TOS,
+		Length:     uint16(int(first.IHL)*4 + len(final)),
+		Id:         first.Id,
 		Flags:      0,
 		FragOffset: 0,
-		TTL:        in.TTL,
-		Protocol:   in.Protocol,
+		TTL:        first.TTL,
+		Protocol:   first.Protocol,
 		Checksum:   0,
-		SrcIP:      in.SrcIP,
-		DstIP:      in.DstIP,
-		Options:    in.Options,
-		Padding:    in.Padding,
+		SrcIP:      first.SrcIP,
+		DstIP:      first.DstIP,
+		Options:    first.Options,
+		Padding:    first.Padding,
 	}
 	out.Payload = final
 
@@ -329,15 +351,17 @@ func (f *fragmentList) build(in *layers.IPv4) (*layers.IPv4, error) {
 
 // ipv4 is a struct to be used as a key.
 type ipv4 struct {
-	ip4 gopacket.Flow
-	id  uint16
+	ip4   gopacket.Flow
+	id    uint16
+	proto layers.IPProtocol
 }
 
 // newIPv4 returns a new initialized IPv4 Flow
 func newIPv4(ip *layers.IPv4) ipv4 {
 	return ipv4{
-		ip4: ip.NetworkFlow(),
-		id:  ip.Id,
+		ip4:   ip.NetworkFlow(),
+		id:    ip.Id,
+		proto: ip.Protocol,
 	}
 }
 
@@ -346,6 +370,15 @@ func newIPv4(ip *layers.IPv4) ipv4 {
 type IPv4Defragmenter struct {
 	sync.RWMutex
 	ipFlows map[ipv4]*fragmentList
+
+	// MaxFlows limits the number of datagrams being reassembled at
+	// once.  Fragments of new datagrams beyond it are rejected with an
+	// error until others complete or are discarded.  0 means no limit.
+	MaxFlows int
+	// MaxFlowBytes limits the number of bytes of fragments held for a
+	// single datagram.  A datagram exceeding it is discarded with an
+	// error.  0 means no limit beyond the maximum IPv4 packet size.
+	MaxFlowBytes int
 }
 
 // NewIPv4Defragmenter returns a new IPv4Defragmenter
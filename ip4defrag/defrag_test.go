@@ -259,6 +259,119 @@ func TestDefragIDField(t *testing.T) {
 
 }
 
+// newFragment returns an IPv4 fragment of datagram id, carrying payload at
+// offset bytes into the datagram.
+func newFragment(id uint16, offset int, more bool, payload []byte) *layers.IPv4 {
+	ip := &layers.IPv4{
+		Version:    4,
+		IHL:        5,
+		TTL:        15,
+		Protocol:   layers.IPProtocolUDP,
+		SrcIP:      net.IPv4(1, 1, 1, 1),
+		DstIP:      net.IPv4(2, 2, 2, 2),
+		Id:         id,
+		FragOffset: uint16(offset / 8),
+		Length:     uint16(20 + len(payload)),
+	}
+	if more {
+		ip.Flags = layers.IPv4MoreFragments
+	}
+	ip.Payload = payload
+	return ip
+}
+
+func TestDefragOutOfOrder(t *testing.T) {
+	defrag := NewIPv4Defragmenter()
+	payload := make([]byte, 48)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	for _, frag := range []*layers.IPv4{
+		newFragment(1, 32, false, payload[32:]),
+		newFragment(1, 16, true, payload[16:32]),
+		newFragment(1, 0, true, payload[:16]),
+	} {
+		out, err := defrag.DefragIPv4(frag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frag.FragOffset != 0 {
+			if out != nil {
+				t.Fatalf("defrag: got a datagram before the first fragment")
+			}
+			continue
+		}
+		if out == nil {
+			t.Fatal("defrag: no datagram after the last fragment")
+		}
+		if !bytes.Equal(out.Payload, payload) {
+			t.Errorf("defrag: payload is not correctly defragmented")
+		}
+		if out.Length != 20+48 || out.Flags != 0 || out.FragOffset != 0 {
+			t.Errorf("defrag: got Length %d, Flags %v, FragOffset %d", out.Length, out.Flags, out.FragOffset)
+		}
+	}
+}
+
+func TestDefragTeardrop(t *testing.T) {
+	defrag := NewIPv4Defragmenter()
+	payload := make([]byte, 48)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	// The teardrop fragment lies entirely within the first fragment, so
+	// it's ignored and the first copy of the data wins.
+	if out, err := defrag.DefragIPv4(newFragment(2, 0, true, payload[:40])); out != nil || err != nil {
+		t.Fatalf("defrag: first fragment gave %v, %v", out, err)
+	}
+	if out, err := defrag.DefragIPv4(newFragment(2, 24, true, make([]byte, 8))); out != nil || err != nil {
+		t.Fatalf("defrag: teardrop fragment gave %v, %v", out, err)
+	}
+	out, err := defrag.DefragIPv4(newFragment(2, 40, false, payload[40:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil || !bytes.Equal(out.Payload, payload) {
+		t.Fatalf("defrag: payload is not correctly defragmented")
+	}
+
+	// A fragment partially overlapping received data is rejected, and the
+	// datagram discarded.
+	defrag.DefragIPv4(newFragment(3, 0, true, payload[:40]))
+	if _, err := defrag.DefragIPv4(newFragment(3, 32, false, payload[32:])); err == nil {
+		t.Fatal("defrag: expected error for overlapping fragment")
+	}
+	if out, err := defrag.DefragIPv4(newFragment(3, 32, false, payload[32:])); out != nil || err != nil {
+		t.Fatalf("defrag: datagram wasn't discarded after overlap: %v, %v", out, err)
+	}
+}
+
+func TestDefragLimits(t *testing.T) {
+	defrag := NewIPv4Defragmenter()
+	defrag.MaxFlows = 1
+	defrag.MaxFlowBytes = 24
+
+	if _, err := defrag.DefragIPv4(newFragment(4, 0, true, make([]byte, 16))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := defrag.DefragIPv4(newFragment(5, 0, true, make([]byte, 16))); err == nil {
+		t.Error("defrag: expected error exceeding MaxFlows")
+	}
+	// Fragments of the same Id but another protocol are another datagram.
+	other := newFragment(4, 0, true, make([]byte, 16))
+	other.Protocol = layers.IPProtocolTCP
+	if _, err := defrag.DefragIPv4(other); err == nil {
+		t.Error("defrag: expected error exceeding MaxFlows")
+	}
+	if _, err := defrag.DefragIPv4(newFragment(4, 16, true, make([]byte, 16))); err == nil {
+		t.Error("defrag: expected error exceeding MaxFlowBytes")
+	}
+	if _, err := defrag.DefragIPv4(newFragment(5, 0, true, make([]byte, 16))); err != nil {
+		t.Errorf("defrag: datagram over MaxFlowBytes wasn't discarded: %v", err)
+	}
+}
+
 func gentestDefrag(t *testing.T, defrag *IPv4Defragmenter, buf []byte, expect bool, label string) *layers.IPv4 {
 	p := gopacket.NewPacket(buf, layers.LinkTypeEthernet, gopacket.Default)
 	if p.ErrorLayer() != nil {
@@ -0,0 +1,958 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// BVLCTypeBACnetIP is the BVLC type of BACnet/IP, the only one supported.
+const BVLCTypeBACnetIP = 0x81
+
+// BVLCFunction is the function of a BACnet/IP BVLC message.
+type BVLCFunction uint8
+
+// BVLCFunction known values.
+const (
+	BVLCFunctionResult                            BVLCFunction = 0x00
+	BVLCFunctionWriteBroadcastDistributionTable   BVLCFunction = 0x01
+	BVLCFunctionReadBroadcastDistributionTable    BVLCFunction = 0x02
+	BVLCFunctionReadBroadcastDistributionTableAck BVLCFunction = 0x03
+	BVLCFunctionForwardedNPDU                     BVLCFunction = 0x04
+	BVLCFunctionRegisterForeignDevice             BVLCFunction = 0x05
+	BVLCFunctionReadForeignDeviceTable            BVLCFunction = 0x06
+	BVLCFunctionReadForeignDeviceTableAck         BVLCFunction = 0x07
+	BVLCFunctionDeleteForeignDeviceTableEntry     BVLCFunction = 0x08
+	BVLCFunctionDistributeBroadcastToNetwork      BVLCFunction = 0x09
+	BVLCFunctionOriginalUnicastNPDU               BVLCFunction = 0x0a
+	BVLCFunctionOriginalBroadcastNPDU             BVLCFunction = 0x0b
+	BVLCFunctionSecureBVLL                        BVLCFunction = 0x0c
+)
+
+func (f BVLCFunction) String() string {
+	switch f {
+	case BVLCFunctionResult:
+		return "Result"
+	case BVLCFunctionWriteBroadcastDistributionTable:
+		return "WriteBroadcastDistributionTable"
+	case BVLCFunctionReadBroadcastDistributionTable:
+		return "ReadBroadcastDistributionTable"
+	case BVLCFunctionReadBroadcastDistributionTableAck:
+		return "ReadBroadcastDistributionTableAck"
+	case BVLCFunctionForwardedNPDU:
+		return "ForwardedNPDU"
+	case BVLCFunctionRegisterForeignDevice:
+		return "RegisterForeignDevice"
+	case BVLCFunctionReadForeignDeviceTable:
+		return "ReadForeignDeviceTable"
+	case BVLCFunctionReadForeignDeviceTableAck:
+		return "ReadForeignDeviceTableAck"
+	case BVLCFunctionDeleteForeignDeviceTableEntry:
+		return "DeleteForeignDeviceTableEntry"
+	case BVLCFunctionDistributeBroadcastToNetwork:
+		return "DistributeBroadcastToNetwork"
+	case BVLCFunctionOriginalUnicastNPDU:
+		return "OriginalUnicastNPDU"
+	case BVLCFunctionOriginalBroadcastNPDU:
+		return "OriginalBroadcastNPDU"
+	case BVLCFunctionSecureBVLL:
+		return "SecureBVLL"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(f))
+	}
+}
+
+// BVLCResultCode is the result code of a BVLC-Result message.
+type BVLCResultCode uint16
+
+// BVLCResultCode known values.
+const (
+	BVLCResultSuccessfulCompletion               BVLCResultCode = 0x0000
+	BVLCResultWriteBroadcastDistributionTableNAK BVLCResultCode = 0x0010
+	BVLCResultReadBroadcastDistributionTableNAK  BVLCResultCode = 0x0020
+	BVLCResultRegisterForeignDeviceNAK           BVLCResultCode = 0x0030
+	BVLCResultReadForeignDeviceTableNAK          BVLCResultCode = 0x0040
+	BVLCResultDeleteForeignDeviceTableEntryNAK   BVLCResultCode = 0x0050
+	BVLCResultDistributeBroadcastToNetworkNAK    BVLCResultCode = 0x0060
+)
+
+func (c BVLCResultCode) String() string {
+	switch c {
+	case BVLCResultSuccessfulCompletion:
+		return "SuccessfulCompletion"
+	case BVLCResultWriteBroadcastDistributionTableNAK:
+		return "WriteBroadcastDistributionTableNAK"
+	case BVLCResultReadBroadcastDistributionTableNAK:
+		return "ReadBroadcastDistributionTableNAK"
+	case BVLCResultRegisterForeignDeviceNAK:
+		return "RegisterForeignDeviceNAK"
+	case BVLCResultReadForeignDeviceTableNAK:
+		return "ReadForeignDeviceTableNAK"
+	case BVLCResultDeleteForeignDeviceTableEntryNAK:
+		return "DeleteForeignDeviceTableEntryNAK"
+	case BVLCResultDistributeBroadcastToNetworkNAK:
+		return "DistributeBroadcastToNetworkNAK"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(c))
+	}
+}
+
+// BVLCBDTEntry is an entry of a BACnet/IP broadcast distribution table.
+type BVLCBDTEntry struct {
+	IP   net.IP
+	Port uint16
+	Mask net.IPMask
+}
+
+// BVLCFDTEntry is an entry of a BACnet/IP foreign device table.  TTL and
+// Remaining are in seconds.
+type BVLCFDTEntry struct {
+	IP        net.IP
+	Port      uint16
+	TTL       uint16
+	Remaining uint16
+}
+
+// BVLC is the BACnet virtual link control header of a BACnet/IP message, as
+// defined in Annex J of ASHRAE 135.  The broadcast distribution table and
+// foreign device functions are fully decoded; functions carrying an NPDU
+// leave it in the payload, preceded for ForwardedNPDU by the address of the
+// device that originated it.
+type BVLC struct {
+	BaseLayer
+	Type     uint8
+	Function BVLCFunction
+	// Length is the length of the whole message, including the header.
+	Length uint16
+
+	// ResultCode is set for Result messages.
+	ResultCode BVLCResultCode
+	// BDT is set for WriteBroadcastDistributionTable and
+	// ReadBroadcastDistributionTableAck messages.
+	BDT []BVLCBDTEntry
+	// FDT is set for ReadForeignDeviceTableAck messages.
+	FDT []BVLCFDTEntry
+	// TTL, in seconds, is set for RegisterForeignDevice messages.
+	TTL uint16
+	// IP and Port are the originating device of ForwardedNPDU messages, or
+	// the entry to remove of DeleteForeignDeviceTableEntry messages.
+	IP   net.IP
+	Port uint16
+}
+
+const (
+	bvlcHeaderLength   = 4
+	bvlcAddressLength  = 6
+	bvlcBDTEntryLength = 10
+	bvlcFDTEntryLength = 10
+)
+
+// LayerType returns LayerTypeBVLC.
+func (b *BVLC) LayerType() gopacket.LayerType { return LayerTypeBVLC }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (b *BVLC) CanDecode() gopacket.LayerClass { return LayerTypeBVLC }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (b *BVLC) NextLayerType() gopacket.LayerType {
+	switch b.Function {
+	case BVLCFunctionForwardedNPDU, BVLCFunctionDistributeBroadcastToNetwork,
+		BVLCFunctionOriginalUnicastNPDU, BVLCFunctionOriginalBroadcastNPDU:
+		return LayerTypeBACnetNPDU
+	}
+	if len(b.Payload) > 0 {
+		return gopacket.LayerTypePayload
+	}
+	return gopacket.LayerTypeZero
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (b *BVLC) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < bvlcHeaderLength {
+		df.SetTruncated()
+		return errors.New("BVLC header too short")
+	}
+	*b = BVLC{
+		Type:     data[0],
+		Function: BVLCFunction(data[1]),
+		Length:   binary.BigEndian.Uint16(data[2:4]),
+	}
+	if b.Type != BVLCTypeBACnetIP {
+		return fmt.Errorf("BVLC type %#x unsupported", b.Type)
+	}
+	if b.Length < bvlcHeaderLength {
+		return fmt.Errorf("BVLC length %d too short", b.Length)
+	}
+	if int(b.Length) > len(data) {
+		df.SetTruncated()
+		return errors.New("BVLC message truncated")
+	}
+	data = data[:b.Length]
+	body := data[bvlcHeaderLength:]
+	n := 0
+	switch b.Function {
+	case BVLCFunctionResult:
+		if len(body) != 2 {
+			return fmt.Errorf("BVLC %v length %d invalid", b.Function, b.Length)
+		}
+		b.ResultCode = BVLCResultCode(binary.BigEndian.Uint16(body))
+		n = 2
+	case BVLCFunctionWriteBroadcastDistributionTable, BVLCFunctionReadBroadcastDistributionTableAck:
+		if len(body)%bvlcBDTEntryLength != 0 {
+			return fmt.Errorf("BVLC %v length %d invalid", b.Function, b.Length)
+		}
+		for ; n < len(body); n += bvlcBDTEntryLength {
+			e := body[n : n+bvlcBDTEntryLength]
+			b.BDT = append(b.BDT, BVLCBDTEntry{
+				IP:   net.IP(e[0:4]),
+				Port: binary.BigEndian.Uint16(e[4:6]),
+				Mask: net.IPMask(e[6:10]),
+			})
+		}
+	case BVLCFunctionReadBroadcastDistributionTable, BVLCFunctionReadForeignDeviceTable:
+		if len(body) != 0 {
+			return fmt.Errorf("BVLC %v length %d invalid", b.Function, b.Length)
+		}
+	case BVLCFunctionForwardedNPDU:
+		if len(body) < bvlcAddressLength {
+			df.SetTruncated()
+			return fmt.Errorf("BVLC %v address truncated", b.Function)
+		}
+		b.IP = net.IP(body[0:4])
+		b.Port = binary.BigEndian.Uint16(body[4:6])
+		n = bvlcAddressLength
+	case BVLCFunctionRegisterForeignDevice:
+		if len(body) != 2 {
+			return fmt.Errorf("BVLC %v length %d invalid", b.Function, b.Length)
+		}
+		b.TTL = binary.BigEndian.Uint16(body)
+		n = 2
+	case BVLCFunctionReadForeignDeviceTableAck:
+		if len(body)%bvlcFDTEntryLength != 0 {
+			return fmt.Errorf("BVLC %v length %d invalid", b.Function, b.Length)
+		}
+		for ; n < len(body); n += bvlcFDTEntryLength {
+			e := body[n : n+bvlcFDTEntryLength]
+			b.FDT = append(b.FDT, BVLCFDTEntry{
+				IP:        net.IP(e[0:4]),
+				Port:      binary.BigEndian.Uint16(e[4:6]),
+				TTL:       binary.BigEndian.Uint16(e[6:8]),
+				Remaining: binary.BigEndian.Uint16(e[8:10]),
+			})
+		}
+	case BVLCFunctionDeleteForeignDeviceTableEntry:
+		if len(body) != bvlcAddressLength {
+			return fmt.Errorf("BVLC %v length %d invalid", b.Function, b.Length)
+		}
+		b.IP = net.IP(body[0:4])
+		b.Port = binary.BigEndian.Uint16(body[4:6])
+		n = bvlcAddressLength
+	}
+	b.BaseLayer = BaseLayer{Contents: data[:bvlcHeaderLength+n], Payload: body[n:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (b *BVLC) SerializeTo(buf gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	payload := buf.Bytes()
+	var n int
+	switch b.Function {
+	case BVLCFunctionResult, BVLCFunctionRegisterForeignDevice:
+		n = 2
+	case BVLCFunctionWriteBroadcastDistributionTable, BVLCFunctionReadBroadcastDistributionTableAck:
+		n = len(b.BDT) * bvlcBDTEntryLength
+	case BVLCFunctionReadForeignDeviceTableAck:
+		n = len(b.FDT) * bvlcFDTEntryLength
+	case BVLCFunctionForwardedNPDU, BVLCFunctionDeleteForeignDeviceTableEntry:
+		n = bvlcAddressLength
+	}
+	bytes, err := buf.PrependBytes(bvlcHeaderLength + n)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		b.Length = uint16(bvlcHeaderLength + n + len(payload))
+	}
+	bytes[0] = b.Type
+	bytes[1] = byte(b.Function)
+	binary.BigEndian.PutUint16(bytes[2:4], b.Length)
+	body := bytes[bvlcHeaderLength:]
+	switch b.Function {
+	case BVLCFunctionResult:
+		binary.BigEndian.PutUint16(body, uint16(b.ResultCode))
+	case BVLCFunctionRegisterForeignDevice:
+		binary.BigEndian.PutUint16(body, b.TTL)
+	case BVLCFunctionWriteBroadcastDistributionTable, BVLCFunctionReadBroadcastDistributionTableAck:
+		for i, e := range b.BDT {
+			ip := e.IP.To4()
+			if ip == nil || len(e.Mask) != 4 {
+				return fmt.Errorf("BVLC BDT entry %v/%v invalid", e.IP, e.Mask)
+			}
+			d := body[i*bvlcBDTEntryLength:]
+			copy(d[0:4], ip)
+			binary.BigEndian.PutUint16(d[4:6], e.Port)
+			copy(d[6:10], e.Mask)
+		}
+	case BVLCFunctionReadForeignDeviceTableAck:
+		for i, e := range b.FDT {
+			ip := e.IP.To4()
+			if ip == nil {
+				return fmt.Errorf("BVLC FDT entry address %v invalid", e.IP)
+			}
+			d := body[i*bvlcFDTEntryLength:]
+			copy(d[0:4], ip)
+			binary.BigEndian.PutUint16(d[4:6], e.Port)
+			binary.BigEndian.PutUint16(d[6:8], e.TTL)
+			binary.BigEndian.PutUint16(d[8:10], e.Remaining)
+		}
+	case BVLCFunctionForwardedNPDU, BVLCFunctionDeleteForeignDeviceTableEntry:
+		ip := b.IP.To4()
+		if ip == nil {
+			return fmt.Errorf("BVLC address %v invalid", b.IP)
+		}
+		copy(body[0:4], ip)
+		binary.BigEndian.PutUint16(body[4:6], b.Port)
+	}
+	return nil
+}
+
+func decodeBVLC(data []byte, p gopacket.PacketBuilder) error {
+	return decodingLayerDecoder(&BVLC{}, data, p)
+}
+
+// BACnetPriority is the network priority of a BACnet NPDU.
+type BACnetPriority uint8
+
+// BACnetPriority known values.
+const (
+	BACnetPriorityNormal            BACnetPriority = 0
+	BACnetPriorityUrgent            BACnetPriority = 1
+	BACnetPriorityCriticalEquipment BACnetPriority = 2
+	BACnetPriorityLifeSafety        BACnetPriority = 3
+)
+
+func (p BACnetPriority) String() string {
+	switch p {
+	case BACnetPriorityNormal:
+		return "Normal"
+	case BACnetPriorityUrgent:
+		return "Urgent"
+	case BACnetPriorityCriticalEquipment:
+		return "CriticalEquipment"
+	case BACnetPriorityLifeSafety:
+		return "LifeSafety"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(p))
+	}
+}
+
+// BACnetNetworkMessageType is the type of a BACnet network layer message.
+// Types from 0x80 on are proprietary, and followed by a vendor ID.
+type BACnetNetworkMessageType uint8
+
+// BACnetNetworkMessageType known values.
+const (
+	BACnetWhoIsRouterToNetwork      BACnetNetworkMessageType = 0x00
+	BACnetIAmRouterToNetwork        BACnetNetworkMessageType = 0x01
+	BACnetICouldBeRouterToNetwork   BACnetNetworkMessageType = 0x02
+	BACnetRejectMessageToNetwork    BACnetNetworkMessageType = 0x03
+	BACnetRouterBusyToNetwork       BACnetNetworkMessageType = 0x04
+	BACnetRouterAvailableToNetwork  BACnetNetworkMessageType = 0x05
+	BACnetInitializeRoutingTable    BACnetNetworkMessageType = 0x06
+	BACnetInitializeRoutingTableAck BACnetNetworkMessageType = 0x07
+	BACnetEstablishConnection       BACnetNetworkMessageType = 0x08
+	BACnetDisconnectConnection      BACnetNetworkMessageType = 0x09
+	BACnetWhatIsNetworkNumber       BACnetNetworkMessageType = 0x12
+	BACnetNetworkNumberIs           BACnetNetworkMessageType = 0x13
+)
+
+func (t BACnetNetworkMessageType) String() string {
+	switch t {
+	case BACnetWhoIsRouterToNetwork:
+		return "WhoIsRouterToNetwork"
+	case BACnetIAmRouterToNetwork:
+		return "IAmRouterToNetwork"
+	case BACnetICouldBeRouterToNetwork:
+		return "ICouldBeRouterToNetwork"
+	case BACnetRejectMessageToNetwork:
+		return "RejectMessageToNetwork"
+	case BACnetRouterBusyToNetwork:
+		return "RouterBusyToNetwork"
+	case BACnetRouterAvailableToNetwork:
+		return "RouterAvailableToNetwork"
+	case BACnetInitializeRoutingTable:
+		return "InitializeRoutingTable"
+	case BACnetInitializeRoutingTableAck:
+		return "InitializeRoutingTableAck"
+	case BACnetEstablishConnection:
+		return "EstablishConnection"
+	case BACnetDisconnectConnection:
+		return "DisconnectConnection"
+	case BACnetWhatIsNetworkNumber:
+		return "WhatIsNetworkNumber"
+	case BACnetNetworkNumberIs:
+		return "NetworkNumberIs"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// BACnetNPDU is the network layer header of a BACnet message, as defined in
+// clause 6 of ASHRAE 135.  An NPDU carries either an APDU, left in the
+// payload, or a network layer message, whose data is kept raw in
+// MessageData; the network lists of the router discovery and flow control
+// messages are also decoded into Networks.
+type BACnetNPDU struct {
+	BaseLayer
+	Version        uint8
+	NetworkMessage bool
+	HasDestination bool
+	HasSource      bool
+	ExpectingReply bool
+	Priority       BACnetPriority
+
+	// DestinationNetwork, DestinationAddress and HopCount are set if
+	// HasDestination is; an empty DestinationAddress is a broadcast on the
+	// destination network.
+	DestinationNetwork uint16
+	DestinationAddress []byte
+	HopCount           uint8
+	// SourceNetwork and SourceAddress are set if HasSource is.
+	SourceNetwork uint16
+	SourceAddress []byte
+
+	// MessageType, VendorID and MessageData are set for network layer
+	// messages, VendorID only for proprietary ones.
+	MessageType BACnetNetworkMessageType
+	VendorID    uint16
+	MessageData []byte
+	// Networks are the networks of WhoIsRouterToNetwork,
+	// IAmRouterToNetwork, RouterBusyToNetwork and RouterAvailableToNetwork
+	// messages.
+	Networks []uint16
+}
+
+const (
+	bacnetNPDUNetworkMessage = 0x80
+	bacnetNPDUDestination    = 0x20
+	bacnetNPDUSource         = 0x08
+	bacnetNPDUExpectingReply = 0x04
+	bacnetNPDUPriorityMask   = 0x03
+)
+
+// LayerType returns LayerTypeBACnetNPDU.
+func (n *BACnetNPDU) LayerType() gopacket.LayerType { return LayerTypeBACnetNPDU }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (n *BACnetNPDU) CanDecode() gopacket.LayerClass { return LayerTypeBACnetNPDU }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (n *BACnetNPDU) NextLayerType() gopacket.LayerType {
+	if n.NetworkMessage {
+		return gopacket.LayerTypeZero
+	}
+	return LayerTypeBACnetAPDU
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (n *BACnetNPDU) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("BACnet NPDU too short")
+	}
+	control := data[1]
+	*n = BACnetNPDU{
+		Version:        data[0],
+		NetworkMessage: control&bacnetNPDUNetworkMessage != 0,
+		HasDestination: control&bacnetNPDUDestination != 0,
+		HasSource:      control&bacnetNPDUSource != 0,
+		ExpectingReply: control&bacnetNPDUExpectingReply != 0,
+		Priority:       BACnetPriority(control & bacnetNPDUPriorityMask),
+	}
+	if n.Version != 1 {
+		return fmt.Errorf("BACnet NPDU version %d unsupported", n.Version)
+	}
+	off := 2
+	if n.HasDestination {
+		if len(data) < off+3 || len(data) < off+3+int(data[off+2]) {
+			df.SetTruncated()
+			return errors.New("BACnet NPDU destination truncated")
+		}
+		n.DestinationNetwork = binary.BigEndian.Uint16(data[off : off+2])
+		l := int(data[off+2])
+		n.DestinationAddress = data[off+3 : off+3+l]
+		off += 3 + l
+	}
+	if n.HasSource {
+		if len(data) < off+3 || len(data) < off+3+int(data[off+2]) {
+			df.SetTruncated()
+			return errors.New("BACnet NPDU source truncated")
+		}
+		n.SourceNetwork = binary.BigEndian.Uint16(data[off : off+2])
+		l := int(data[off+2])
+		if l == 0 {
+			return errors.New("BACnet NPDU source address empty")
+		}
+		n.SourceAddress = data[off+3 : off+3+l]
+		off += 3 + l
+	}
+	if n.HasDestination {
+		if len(data) < off+1 {
+			df.SetTruncated()
+			return errors.New("BACnet NPDU hop count truncated")
+		}
+		n.HopCount = data[off]
+		off++
+	}
+	if !n.NetworkMessage {
+		n.BaseLayer = BaseLayer{Contents: data[:off], Payload: data[off:]}
+		return nil
+	}
+	if len(data) < off+1 {
+		df.SetTruncated()
+		return errors.New("BACnet NPDU message type truncated")
+	}
+	n.MessageType = BACnetNetworkMessageType(data[off])
+	off++
+	if n.MessageType >= 0x80 {
+		if len(data) < off+2 {
+			df.SetTruncated()
+			return errors.New("BACnet NPDU vendor ID truncated")
+		}
+		n.VendorID = binary.BigEndian.Uint16(data[off : off+2])
+		off += 2
+	}
+	n.MessageData = data[off:]
+	switch n.MessageType {
+	case BACnetWhoIsRouterToNetwork, BACnetIAmRouterToNetwork,
+		BACnetRouterBusyToNetwork, BACnetRouterAvailableToNetwork:
+		if len(n.MessageData)%2 != 0 {
+			return fmt.Errorf("BACnet %v network list length %d invalid", n.MessageType, len(n.MessageData))
+		}
+		for i := 0; i < len(n.MessageData); i += 2 {
+			n.Networks = append(n.Networks, binary.BigEndian.Uint16(n.MessageData[i:i+2]))
+		}
+	}
+	n.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (n *BACnetNPDU) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(n.DestinationAddress) > 0xff || len(n.SourceAddress) > 0xff {
+		return errors.New("BACnet NPDU address too long")
+	}
+	if n.Priority > bacnetNPDUPriorityMask {
+		return fmt.Errorf("BACnet NPDU priority %d invalid", n.Priority)
+	}
+	length := 2
+	if n.HasDestination {
+		length += 4 + len(n.DestinationAddress)
+	}
+	if n.HasSource {
+		length += 3 + len(n.SourceAddress)
+	}
+	if n.NetworkMessage {
+		length++
+		if n.MessageType >= 0x80 {
+			length += 2
+		}
+		length += len(n.MessageData)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = n.Version
+	control := byte(n.Priority)
+	if n.NetworkMessage {
+		control |= bacnetNPDUNetworkMessage
+	}
+	if n.HasDestination {
+		control |= bacnetNPDUDestination
+	}
+	if n.HasSource {
+		control |= bacnetNPDUSource
+	}
+	if n.ExpectingReply {
+		control |= bacnetNPDUExpectingReply
+	}
+	bytes[1] = control
+	off := 2
+	if n.HasDestination {
+		binary.BigEndian.PutUint16(bytes[off:], n.DestinationNetwork)
+		bytes[off+2] = byte(len(n.DestinationAddress))
+		off += 3 + copy(bytes[off+3:], n.DestinationAddress)
+	}
+	if n.HasSource {
+		binary.BigEndian.PutUint16(bytes[off:], n.SourceNetwork)
+		bytes[off+2] = byte(len(n.SourceAddress))
+		off += 3 + copy(bytes[off+3:], n.SourceAddress)
+	}
+	if n.HasDestination {
+		bytes[off] = n.HopCount
+		off++
+	}
+	if n.NetworkMessage {
+		bytes[off] = byte(n.MessageType)
+		off++
+		if n.MessageType >= 0x80 {
+			binary.BigEndian.PutUint16(bytes[off:], n.VendorID)
+			off += 2
+		}
+		copy(bytes[off:], n.MessageData)
+	}
+	return nil
+}
+
+func decodeBACnetNPDU(data []byte, p gopacket.PacketBuilder) error {
+	return decodingLayerDecoder(&BACnetNPDU{}, data, p)
+}
+
+// BACnetPDUType is the type of a BACnet APDU.
+type BACnetPDUType uint8
+
+// BACnetPDUType known values.
+const (
+	BACnetPDUConfirmedRequest   BACnetPDUType = 0
+	BACnetPDUUnconfirmedRequest BACnetPDUType = 1
+	BACnetPDUSimpleAck          BACnetPDUType = 2
+	BACnetPDUComplexAck         BACnetPDUType = 3
+	BACnetPDUSegmentAck         BACnetPDUType = 4
+	BACnetPDUError              BACnetPDUType = 5
+	BACnetPDUReject             BACnetPDUType = 6
+	BACnetPDUAbort              BACnetPDUType = 7
+)
+
+func (t BACnetPDUType) String() string {
+	switch t {
+	case BACnetPDUConfirmedRequest:
+		return "ConfirmedRequest"
+	case BACnetPDUUnconfirmedRequest:
+		return "UnconfirmedRequest"
+	case BACnetPDUSimpleAck:
+		return "SimpleAck"
+	case BACnetPDUComplexAck:
+		return "ComplexAck"
+	case BACnetPDUSegmentAck:
+		return "SegmentAck"
+	case BACnetPDUError:
+		return "Error"
+	case BACnetPDUReject:
+		return "Reject"
+	case BACnetPDUAbort:
+		return "Abort"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// BACnetConfirmedService is the service choice of confirmed request, ack and
+// error APDUs.
+type BACnetConfirmedService uint8
+
+// BACnetConfirmedService known values.
+const (
+	BACnetAcknowledgeAlarm           BACnetConfirmedService = 0
+	BACnetConfirmedCOVNotification   BACnetConfirmedService = 1
+	BACnetConfirmedEventNotification BACnetConfirmedService = 2
+	BACnetGetAlarmSummary            BACnetConfirmedService = 3
+	BACnetGetEnrollmentSummary       BACnetConfirmedService = 4
+	BACnetSubscribeCOV               BACnetConfirmedService = 5
+	BACnetAtomicReadFile             BACnetConfirmedService = 6
+	BACnetAtomicWriteFile            BACnetConfirmedService = 7
+	BACnetAddListElement             BACnetConfirmedService = 8
+	BACnetRemoveListElement          BACnetConfirmedService = 9
+	BACnetCreateObject               BACnetConfirmedService = 10
+	BACnetDeleteObject               BACnetConfirmedService = 11
+	BACnetReadProperty               BACnetConfirmedService = 12
+	BACnetReadPropertyMultiple       BACnetConfirmedService = 14
+	BACnetWriteProperty              BACnetConfirmedService = 15
+	BACnetWritePropertyMultiple      BACnetConfirmedService = 16
+	BACnetDeviceCommunicationControl BACnetConfirmedService = 17
+	BACnetConfirmedPrivateTransfer   BACnetConfirmedService = 18
+	BACnetConfirmedTextMessage       BACnetConfirmedService = 19
+	BACnetReinitializeDevice         BACnetConfirmedService = 20
+	BACnetReadRange                  BACnetConfirmedService = 26
+	BACnetGetEventInformation        BACnetConfirmedService = 29
+)
+
+func (s BACnetConfirmedService) String() string {
+	switch s {
+	case BACnetAcknowledgeAlarm:
+		return "AcknowledgeAlarm"
+	case BACnetConfirmedCOVNotification:
+		return "ConfirmedCOVNotification"
+	case BACnetConfirmedEventNotification:
+		return "ConfirmedEventNotification"
+	case BACnetGetAlarmSummary:
+		return "GetAlarmSummary"
+	case BACnetGetEnrollmentSummary:
+		return "GetEnrollmentSummary"
+	case BACnetSubscribeCOV:
+		return "SubscribeCOV"
+	case BACnetAtomicReadFile:
+		return "AtomicReadFile"
+	case BACnetAtomicWriteFile:
+		return "AtomicWriteFile"
+	case BACnetAddListElement:
+		return "AddListElement"
+	case BACnetRemoveListElement:
+		return "RemoveListElement"
+	case BACnetCreateObject:
+		return "CreateObject"
+	case BACnetDeleteObject:
+		return "DeleteObject"
+	case BACnetReadProperty:
+		return "ReadProperty"
+	case BACnetReadPropertyMultiple:
+		return "ReadPropertyMultiple"
+	case BACnetWriteProperty:
+		return "WriteProperty"
+	case BACnetWritePropertyMultiple:
+		return "WritePropertyMultiple"
+	case BACnetDeviceCommunicationControl:
+		return "DeviceCommunicationControl"
+	case BACnetConfirmedPrivateTransfer:
+		return "ConfirmedPrivateTransfer"
+	case BACnetConfirmedTextMessage:
+		return "ConfirmedTextMessage"
+	case BACnetReinitializeDevice:
+		return "ReinitializeDevice"
+	case BACnetReadRange:
+		return "ReadRange"
+	case BACnetGetEventInformation:
+		return "GetEventInformation"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// BACnetUnconfirmedService is the service choice of unconfirmed request
+// APDUs.
+type BACnetUnconfirmedService uint8
+
+// BACnetUnconfirmedService known values.
+const (
+	BACnetIAm                          BACnetUnconfirmedService = 0
+	BACnetIHave                        BACnetUnconfirmedService = 1
+	BACnetUnconfirmedCOVNotification   BACnetUnconfirmedService = 2
+	BACnetUnconfirmedEventNotification BACnetUnconfirmedService = 3
+	BACnetUnconfirmedPrivateTransfer   BACnetUnconfirmedService = 4
+	BACnetUnconfirmedTextMessage       BACnetUnconfirmedService = 5
+	BACnetTimeSynchronization          BACnetUnconfirmedService = 6
+	BACnetWhoHas                       BACnetUnconfirmedService = 7
+	BACnetWhoIs                        BACnetUnconfirmedService = 8
+	BACnetUTCTimeSynchronization       BACnetUnconfirmedService = 9
+	BACnetWriteGroup                   BACnetUnconfirmedService = 10
+)
+
+func (s BACnetUnconfirmedService) String() string {
+	switch s {
+	case BACnetIAm:
+		return "IAm"
+	case BACnetIHave:
+		return "IHave"
+	case BACnetUnconfirmedCOVNotification:
+		return "UnconfirmedCOVNotification"
+	case BACnetUnconfirmedEventNotification:
+		return "UnconfirmedEventNotification"
+	case BACnetUnconfirmedPrivateTransfer:
+		return "UnconfirmedPrivateTransfer"
+	case BACnetUnconfirmedTextMessage:
+		return "UnconfirmedTextMessage"
+	case BACnetTimeSynchronization:
+		return "TimeSynchronization"
+	case BACnetWhoHas:
+		return "WhoHas"
+	case BACnetWhoIs:
+		return "WhoIs"
+	case BACnetUTCTimeSynchronization:
+		return "UTCTimeSynchronization"
+	case BACnetWriteGroup:
+		return "WriteGroup"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// BACnet APDU flags, the low bits of the first octet.  Which are meaningful
+// depends on the PDU type.
+const (
+	BACnetAPDUSegmented                 = 0x08
+	BACnetAPDUMoreFollows               = 0x04
+	BACnetAPDUSegmentedResponseAccepted = 0x02
+	BACnetAPDUNegativeAck               = 0x02
+	BACnetAPDUServer                    = 0x01
+)
+
+// BACnetAPDU is the application layer header of a BACnet message, as defined
+// in clause 20.1 of ASHRAE 135.  The service parameters following the
+// header are not decoded, and kept raw in ServiceData.
+type BACnetAPDU struct {
+	BaseLayer
+	PDUType BACnetPDUType
+	// Flags holds the BACnetAPDU* flags.
+	Flags uint8
+	// MaxSegments and MaxAPDU are set for confirmed requests, and are the
+	// encoded maximum segments and APDU length accepted by the requester.
+	MaxSegments uint8
+	MaxAPDU     uint8
+	InvokeID    uint8
+	// SequenceNumber and WindowSize are set for segmented messages and
+	// segment acks.
+	SequenceNumber uint8
+	WindowSize     uint8
+	// ServiceChoice is a BACnetUnconfirmedService for unconfirmed requests,
+	// and a BACnetConfirmedService for confirmed requests, acks and errors.
+	ServiceChoice uint8
+	// Reason is set for rejects and aborts.
+	Reason      uint8
+	ServiceData []byte
+}
+
+// LayerType returns LayerTypeBACnetAPDU.
+func (a *BACnetAPDU) LayerType() gopacket.LayerType { return LayerTypeBACnetAPDU }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (a *BACnetAPDU) CanDecode() gopacket.LayerClass { return LayerTypeBACnetAPDU }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (a *BACnetAPDU) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns the service parameters of the APDU.
+func (a *BACnetAPDU) Payload() []byte { return a.ServiceData }
+
+// headerLength returns the length of the APDU header for its type and
+// flags, or 0 if the type is unknown.
+func (a *BACnetAPDU) headerLength() int {
+	seg := 0
+	if a.Flags&BACnetAPDUSegmented != 0 {
+		seg = 2
+	}
+	switch a.PDUType {
+	case BACnetPDUConfirmedRequest:
+		return 4 + seg
+	case BACnetPDUComplexAck:
+		return 3 + seg
+	case BACnetPDUUnconfirmedRequest:
+		return 2
+	case BACnetPDUSimpleAck, BACnetPDUError, BACnetPDUReject, BACnetPDUAbort:
+		return 3
+	case BACnetPDUSegmentAck:
+		return 4
+	}
+	return 0
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (a *BACnetAPDU) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 1 {
+		df.SetTruncated()
+		return errors.New("BACnet APDU too short")
+	}
+	*a = BACnetAPDU{
+		BaseLayer: BaseLayer{Contents: data},
+		PDUType:   BACnetPDUType(data[0] >> 4),
+		Flags:     data[0] & 0x0f,
+	}
+	n := a.headerLength()
+	if n == 0 {
+		return fmt.Errorf("BACnet APDU type %d unknown", a.PDUType)
+	}
+	if len(data) < n {
+		df.SetTruncated()
+		return fmt.Errorf("BACnet %v APDU truncated", a.PDUType)
+	}
+	segmented := a.Flags&BACnetAPDUSegmented != 0
+	switch a.PDUType {
+	case BACnetPDUConfirmedRequest:
+		a.MaxSegments = data[1] >> 4 & 0x07
+		a.MaxAPDU = data[1] & 0x0f
+		a.InvokeID = data[2]
+		if segmented {
+			a.SequenceNumber, a.WindowSize = data[3], data[4]
+		}
+		a.ServiceChoice = data[n-1]
+	case BACnetPDUUnconfirmedRequest:
+		a.ServiceChoice = data[1]
+	case BACnetPDUSimpleAck, BACnetPDUError:
+		a.InvokeID, a.ServiceChoice = data[1], data[2]
+	case BACnetPDUComplexAck:
+		a.InvokeID = data[1]
+		if segmented {
+			a.SequenceNumber, a.WindowSize = data[2], data[3]
+		}
+		a.ServiceChoice = data[n-1]
+	case BACnetPDUSegmentAck:
+		a.InvokeID, a.SequenceNumber, a.WindowSize = data[1], data[2], data[3]
+	case BACnetPDUReject, BACnetPDUAbort:
+		a.InvokeID, a.Reason = data[1], data[2]
+	}
+	a.ServiceData = data[n:]
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (a *BACnetAPDU) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	n := a.headerLength()
+	if n == 0 {
+		return fmt.Errorf("BACnet APDU type %d unknown", a.PDUType)
+	}
+	bytes, err := b.PrependBytes(n + len(a.ServiceData))
+	if err != nil {
+		return err
+	}
+	bytes[0] = byte(a.PDUType)<<4 | a.Flags&0x0f
+	segmented := a.Flags&BACnetAPDUSegmented != 0
+	switch a.PDUType {
+	case BACnetPDUConfirmedRequest:
+		bytes[1] = (a.MaxSegments&0x07)<<4 | a.MaxAPDU&0x0f
+		bytes[2] = a.InvokeID
+		if segmented {
+			bytes[3], bytes[4] = a.SequenceNumber, a.WindowSize
+		}
+		bytes[n-1] = a.ServiceChoice
+	case BACnetPDUUnconfirmedRequest:
+		bytes[1] = a.ServiceChoice
+	case BACnetPDUSimpleAck, BACnetPDUError:
+		bytes[1], bytes[2] = a.InvokeID, a.ServiceChoice
+	case BACnetPDUComplexAck:
+		bytes[1] = a.InvokeID
+		if segmented {
+			bytes[2], bytes[3] = a.SequenceNumber, a.WindowSize
+		}
+		bytes[n-1] = a.ServiceChoice
+	case BACnetPDUSegmentAck:
+		bytes[1], bytes[2], bytes[3] = a.InvokeID, a.SequenceNumber, a.WindowSize
+	case BACnetPDUReject, BACnetPDUAbort:
+		bytes[1], bytes[2] = a.InvokeID, a.Reason
+	}
+	copy(bytes[n:], a.ServiceData)
+	return nil
+}
+
+func decodeBACnetAPDU(data []byte, p gopacket.PacketBuilder) error {
+	a := &BACnetAPDU{}
+	if err := a.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(a)
+	p.SetApplicationLayer(a)
+	return nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketBACnetWhoIs is a global broadcast Who-Is from 192.168.1.10.
+var testPacketBACnetWhoIs = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02, 0x42, 0xc0, 0xa8, 0x01, 0x0a, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x28, 0x01, 0x01, 0x40, 0x00, 0x40, 0x11, 0xb5, 0x6a, 0xc0, 0xa8, 0x01, 0x0a, 0xc0, 0xa8,
+	0x01, 0xff, 0xba, 0xc0, 0xba, 0xc0, 0x00, 0x14, 0x72, 0xac, 0x81, 0x0b, 0x00, 0x0c, 0x01, 0x20,
+	0xff, 0xff, 0x00, 0xff, 0x10, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// testPacketBACnetIAm is the I-Am of device 123 on network 5, sent from
+// 10.0.2.5 and forwarded by the BBMD at 192.168.1.1.
+var testPacketBACnetIAm = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02, 0x42, 0xc0, 0xa8, 0x01, 0x01, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x3a, 0x02, 0x02, 0x40, 0x00, 0x40, 0x11, 0xb4, 0x60, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8,
+	0x01, 0xff, 0xba, 0xc0, 0xba, 0xc0, 0x00, 0x26, 0xb8, 0xbe, 0x81, 0x04, 0x00, 0x1e, 0x0a, 0x00,
+	0x02, 0x05, 0xba, 0xc0, 0x01, 0x08, 0x00, 0x05, 0x01, 0x07, 0x10, 0x00, 0xc4, 0x02, 0x00, 0x00,
+	0x7b, 0x22, 0x01, 0xe0, 0x91, 0x00, 0x21, 0x0f,
+}
+
+// testPacketBACnetReadProperty is a ReadProperty request for the name of
+// device 123, routed to network 5.
+var testPacketBACnetReadProperty = []byte{
+	0x02, 0x42, 0xc0, 0xa8, 0x01, 0x01, 0x02, 0x42, 0xc0, 0xa8, 0x01, 0x0a, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x32, 0x03, 0x03, 0x40, 0x00, 0x40, 0x11, 0xb4, 0x5c, 0xc0, 0xa8, 0x01, 0x0a, 0xc0, 0xa8,
+	0x01, 0x01, 0xba, 0xc0, 0xba, 0xc0, 0x00, 0x1e, 0x57, 0x83, 0x81, 0x0a, 0x00, 0x16, 0x01, 0x24,
+	0x00, 0x05, 0x01, 0x07, 0xff, 0x02, 0x05, 0x2a, 0x0c, 0x0c, 0x02, 0x00, 0x00, 0x7b, 0x19, 0x4d,
+}
+
+// testPacketBACnetIAmRouter is an I-Am-Router-To-Network for networks 5
+// and 6.
+var testPacketBACnetIAmRouter = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02, 0x42, 0xc0, 0xa8, 0x01, 0x01, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x27, 0x04, 0x04, 0x40, 0x00, 0x40, 0x11, 0xb2, 0x71, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8,
+	0x01, 0xff, 0xba, 0xc0, 0xba, 0xc0, 0x00, 0x13, 0x77, 0x5f, 0x81, 0x0b, 0x00, 0x0b, 0x01, 0x80,
+	0x01, 0x00, 0x05, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// testPacketBACnetRegister is a foreign device registration with a TTL of
+// 60 seconds, and testPacketBACnetResult its rejection.
+var testPacketBACnetRegister = []byte{
+	0x02, 0x42, 0xc0, 0xa8, 0x01, 0x01, 0x02, 0x42, 0xc0, 0xa8, 0x01, 0x0a, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x22, 0x05, 0x05, 0x40, 0x00, 0x40, 0x11, 0xb2, 0x6a, 0xc0, 0xa8, 0x01, 0x0a, 0xc0, 0xa8,
+	0x01, 0x01, 0xba, 0xc0, 0xba, 0xc0, 0x00, 0x0e, 0x85, 0xad, 0x81, 0x05, 0x00, 0x06, 0x00, 0x3c,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+var testPacketBACnetResult = []byte{
+	0x02, 0x42, 0xc0, 0xa8, 0x01, 0x0a, 0x02, 0x42, 0xc0, 0xa8, 0x01, 0x01, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x22, 0x06, 0x06, 0x40, 0x00, 0x40, 0x11, 0xb1, 0x69, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8,
+	0x01, 0x0a, 0xba, 0xc0, 0xba, 0xc0, 0x00, 0x0e, 0x85, 0xbe, 0x81, 0x00, 0x00, 0x06, 0x00, 0x30,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// testPacketBACnetBDT and testPacketBACnetFDT are the broadcast distribution
+// and foreign device tables of the BBMD at 192.168.1.1.
+var testPacketBACnetBDT = []byte{
+	0x02, 0x42, 0xc0, 0xa8, 0x01, 0x0a, 0x02, 0x42, 0xc0, 0xa8, 0x01, 0x01, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x34, 0x07, 0x07, 0x40, 0x00, 0x40, 0x11, 0xb0, 0x56, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8,
+	0x01, 0x0a, 0xba, 0xc0, 0xba, 0xc0, 0x00, 0x20, 0x43, 0x88, 0x81, 0x03, 0x00, 0x18, 0xc0, 0xa8,
+	0x01, 0x01, 0xba, 0xc0, 0xff, 0xff, 0xff, 0xff, 0x0a, 0x00, 0x02, 0x01, 0xba, 0xc0, 0xff, 0xff,
+	0xff, 0x00,
+}
+
+var testPacketBACnetFDT = []byte{
+	0x02, 0x42, 0xc0, 0xa8, 0x01, 0x0a, 0x02, 0x42, 0xc0, 0xa8, 0x01, 0x01, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x2a, 0x08, 0x08, 0x40, 0x00, 0x40, 0x11, 0xaf, 0x5f, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8,
+	0x01, 0x0a, 0xba, 0xc0, 0xba, 0xc0, 0x00, 0x16, 0x1e, 0x8f, 0x81, 0x07, 0x00, 0x0e, 0xac, 0x10,
+	0x00, 0x09, 0xba, 0xc0, 0x00, 0x3c, 0x00, 0x2a, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestBACnetWhoIs(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBACnetWhoIs, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeBVLC, LayerTypeBACnetNPDU, LayerTypeBACnetAPDU}, t)
+	b := p.Layer(LayerTypeBVLC).(*BVLC)
+	if b.Type != BVLCTypeBACnetIP || b.Function != BVLCFunctionOriginalBroadcastNPDU || b.Length != 12 {
+		t.Errorf("Got BVLC %+v", b)
+	}
+	n := p.Layer(LayerTypeBACnetNPDU).(*BACnetNPDU)
+	if n.Version != 1 || n.NetworkMessage || !n.HasDestination || n.HasSource || n.ExpectingReply || n.Priority != BACnetPriorityNormal ||
+		n.DestinationNetwork != 0xffff || len(n.DestinationAddress) != 0 || n.HopCount != 255 {
+		t.Errorf("Got NPDU %+v", n)
+	}
+	a := p.Layer(LayerTypeBACnetAPDU).(*BACnetAPDU)
+	if a.PDUType != BACnetPDUUnconfirmedRequest || BACnetUnconfirmedService(a.ServiceChoice) != BACnetWhoIs || len(a.ServiceData) != 0 {
+		t.Errorf("Got APDU %+v", a)
+	}
+	testSerialization(t, p, testPacketBACnetWhoIs)
+}
+
+func TestBACnetIAm(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBACnetIAm, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeBVLC, LayerTypeBACnetNPDU, LayerTypeBACnetAPDU}, t)
+	b := p.Layer(LayerTypeBVLC).(*BVLC)
+	if b.Function != BVLCFunctionForwardedNPDU || !b.IP.Equal(net.IP{10, 0, 2, 5}) || b.Port != 47808 {
+		t.Errorf("Got BVLC %+v", b)
+	}
+	n := p.Layer(LayerTypeBACnetNPDU).(*BACnetNPDU)
+	if n.HasDestination || !n.HasSource || n.SourceNetwork != 5 || !bytes.Equal(n.SourceAddress, []byte{7}) {
+		t.Errorf("Got NPDU %+v", n)
+	}
+	a := p.Layer(LayerTypeBACnetAPDU).(*BACnetAPDU)
+	if a.PDUType != BACnetPDUUnconfirmedRequest || BACnetUnconfirmedService(a.ServiceChoice).String() != "IAm" ||
+		!bytes.Equal(a.ServiceData, testPacketBACnetIAm[len(testPacketBACnetIAm)-12:]) {
+		t.Errorf("Got APDU %+v", a)
+	}
+	if app := p.ApplicationLayer(); app == nil || !bytes.Equal(app.Payload(), a.ServiceData) {
+		t.Errorf("Got application layer %v", app)
+	}
+	testSerialization(t, p, testPacketBACnetIAm)
+}
+
+func TestBACnetReadProperty(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBACnetReadProperty, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	n := p.Layer(LayerTypeBACnetNPDU).(*BACnetNPDU)
+	if !n.HasDestination || !n.ExpectingReply || n.DestinationNetwork != 5 || !bytes.Equal(n.DestinationAddress, []byte{7}) || n.HopCount != 255 {
+		t.Errorf("Got NPDU %+v", n)
+	}
+	a := p.Layer(LayerTypeBACnetAPDU).(*BACnetAPDU)
+	if a.PDUType != BACnetPDUConfirmedRequest || a.Flags != BACnetAPDUSegmentedResponseAccepted || a.MaxSegments != 0 || a.MaxAPDU != 5 ||
+		a.InvokeID != 42 || BACnetConfirmedService(a.ServiceChoice) != BACnetReadProperty || len(a.ServiceData) != 7 {
+		t.Errorf("Got APDU %+v", a)
+	}
+	testSerialization(t, p, testPacketBACnetReadProperty)
+}
+
+func TestBACnetSegmentedComplexAck(t *testing.T) {
+	data := []byte{
+		0x81, 0x0a, 0x00, 0x13, 0x01, 0x00, 0x3c, 0x2a, 0x00, 0x04, 0x0e, 0x0c, 0x02, 0x00, 0x00, 0x7b,
+		0x1e, 0x29, 0x4d,
+	}
+	p := gopacket.NewPacket(data, LayerTypeBVLC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBVLC, LayerTypeBACnetNPDU, LayerTypeBACnetAPDU}, t)
+	a := p.Layer(LayerTypeBACnetAPDU).(*BACnetAPDU)
+	if a.PDUType != BACnetPDUComplexAck || a.Flags != BACnetAPDUSegmented|BACnetAPDUMoreFollows || a.InvokeID != 42 ||
+		a.SequenceNumber != 0 || a.WindowSize != 4 || BACnetConfirmedService(a.ServiceChoice) != BACnetReadPropertyMultiple || len(a.ServiceData) != 8 {
+		t.Errorf("Got APDU %+v", a)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	var layers []gopacket.SerializableLayer
+	for _, l := range p.Layers() {
+		layers = append(layers, l.(gopacket.SerializableLayer))
+	}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, layers...); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("Serialized %v, want %v", buf.Bytes(), data)
+	}
+}
+
+func TestBACnetIAmRouter(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBACnetIAmRouter, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeBVLC, LayerTypeBACnetNPDU}, t)
+	n := p.Layer(LayerTypeBACnetNPDU).(*BACnetNPDU)
+	if !n.NetworkMessage || n.MessageType != BACnetIAmRouterToNetwork || len(n.Networks) != 2 || n.Networks[0] != 5 || n.Networks[1] != 6 {
+		t.Errorf("Got NPDU %+v", n)
+	}
+	testSerialization(t, p, testPacketBACnetIAmRouter)
+}
+
+func TestBACnetForeignDevice(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBACnetRegister, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeBVLC}, t)
+	if b := p.Layer(LayerTypeBVLC).(*BVLC); b.Function != BVLCFunctionRegisterForeignDevice || b.TTL != 60 {
+		t.Errorf("Got BVLC %+v", b)
+	}
+	testSerialization(t, p, testPacketBACnetRegister)
+
+	p = gopacket.NewPacket(testPacketBACnetResult, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	if b := p.Layer(LayerTypeBVLC).(*BVLC); b.Function != BVLCFunctionResult || b.ResultCode.String() != "RegisterForeignDeviceNAK" {
+		t.Errorf("Got BVLC %+v", b)
+	}
+	testSerialization(t, p, testPacketBACnetResult)
+
+	p = gopacket.NewPacket(testPacketBACnetBDT, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	b := p.Layer(LayerTypeBVLC).(*BVLC)
+	if b.Function != BVLCFunctionReadBroadcastDistributionTableAck || len(b.BDT) != 2 {
+		t.Fatalf("Got BVLC %+v", b)
+	}
+	if e := b.BDT[1]; !e.IP.Equal(net.IP{10, 0, 2, 1}) || e.Port != 47808 || e.Mask.String() != "ffffff00" {
+		t.Errorf("Got BDT entry %+v", e)
+	}
+	testSerialization(t, p, testPacketBACnetBDT)
+
+	p = gopacket.NewPacket(testPacketBACnetFDT, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	b = p.Layer(LayerTypeBVLC).(*BVLC)
+	if len(b.FDT) != 1 || !b.FDT[0].IP.Equal(net.IP{172, 16, 0, 9}) || b.FDT[0].TTL != 60 || b.FDT[0].Remaining != 42 {
+		t.Errorf("Got BVLC %+v", b)
+	}
+	testSerialization(t, p, testPacketBACnetFDT)
+}
+
+func TestBACnetMalformed(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x81, 0x0a, 0x00},
+		// BACnet/IPv6
+		{0x82, 0x0a, 0x00, 0x04},
+		// Length longer than the message
+		{0x81, 0x0a, 0x00, 0x08, 0x01, 0x00},
+		// Length shorter than the header
+		{0x81, 0x0a, 0x00, 0x02},
+		// Result of the wrong length
+		{0x81, 0x00, 0x00, 0x05, 0x00},
+		// Partial BDT entry
+		{0x81, 0x03, 0x00, 0x08, 0xc0, 0xa8, 0x01, 0x01},
+		// Forwarded NPDU without its address
+		{0x81, 0x04, 0x00, 0x08, 0x0a, 0x00, 0x02, 0x05},
+		// NPDU version 2
+		{0x81, 0x0a, 0x00, 0x08, 0x02, 0x00, 0x10, 0x08},
+		// Destination address truncated
+		{0x81, 0x0a, 0x00, 0x09, 0x01, 0x20, 0x00, 0x05, 0x06},
+		// Empty source address
+		{0x81, 0x0a, 0x00, 0x0b, 0x01, 0x08, 0x00, 0x05, 0x00, 0x10, 0x08},
+		// Odd network list
+		{0x81, 0x0b, 0x00, 0x0a, 0x01, 0x80, 0x01, 0x00, 0x05, 0x06},
+		// Unknown PDU type
+		{0x81, 0x0a, 0x00, 0x07, 0x01, 0x00, 0x80},
+		// Segmented confirmed request truncated
+		{0x81, 0x0a, 0x00, 0x0a, 0x01, 0x00, 0x08, 0x05, 0x01, 0x00},
+	} {
+		p := gopacket.NewPacket(data, LayerTypeBVLC, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("Decoded malformed message %v", data)
+		}
+	}
+}
@@ -0,0 +1,822 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+const bgpHeaderLength = 19
+
+// ErrBGPNeedMoreData is returned when data ends partway through a BGP
+// message.  When decoding a reassembled TCP stream, keep the unconsumed bytes
+// and decode again once more data arrives.
+var ErrBGPNeedMoreData = errors.New("BGP message truncated, need more data")
+
+var bgpMarker = bytes.Repeat([]byte{0xff}, 16)
+
+// BGPMessageType is the type of a BGP message.
+type BGPMessageType uint8
+
+// BGPMessageType known values.
+const (
+	BGPMessageTypeOpen         BGPMessageType = 1
+	BGPMessageTypeUpdate       BGPMessageType = 2
+	BGPMessageTypeNotification BGPMessageType = 3
+	BGPMessageTypeKeepalive    BGPMessageType = 4
+	BGPMessageTypeRouteRefresh BGPMessageType = 5
+)
+
+func (t BGPMessageType) String() string {
+	switch t {
+	case BGPMessageTypeOpen:
+		return "Open"
+	case BGPMessageTypeUpdate:
+		return "Update"
+	case BGPMessageTypeNotification:
+		return "Notification"
+	case BGPMessageTypeKeepalive:
+		return "Keepalive"
+	case BGPMessageTypeRouteRefresh:
+		return "RouteRefresh"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// BGPAFI is a BGP address family identifier.
+type BGPAFI uint16
+
+// BGPAFI known values.
+const (
+	BGPAFIIPv4  BGPAFI = 1
+	BGPAFIIPv6  BGPAFI = 2
+	BGPAFIL2VPN BGPAFI = 25
+)
+
+func (a BGPAFI) String() string {
+	switch a {
+	case BGPAFIIPv4:
+		return "IPv4"
+	case BGPAFIIPv6:
+		return "IPv6"
+	case BGPAFIL2VPN:
+		return "L2VPN"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(a))
+	}
+}
+
+// addrLen returns the length of an address in the family, or 0 if the family
+// isn't one whose prefixes can be decoded.
+func (a BGPAFI) addrLen() int {
+	switch a {
+	case BGPAFIIPv4:
+		return 4
+	case BGPAFIIPv6:
+		return 16
+	default:
+		return 0
+	}
+}
+
+// BGPSAFI is a BGP subsequent address family identifier.
+type BGPSAFI uint8
+
+// BGPSAFI known values.
+const (
+	BGPSAFIUnicast   BGPSAFI = 1
+	BGPSAFIMulticast BGPSAFI = 2
+	BGPSAFIMPLSLabel BGPSAFI = 4
+	BGPSAFIEVPN      BGPSAFI = 70
+	BGPSAFIMPLSVPN   BGPSAFI = 128
+	BGPSAFIFlowSpec  BGPSAFI = 133
+)
+
+func (s BGPSAFI) String() string {
+	switch s {
+	case BGPSAFIUnicast:
+		return "Unicast"
+	case BGPSAFIMulticast:
+		return "Multicast"
+	case BGPSAFIMPLSLabel:
+		return "MPLSLabel"
+	case BGPSAFIEVPN:
+		return "EVPN"
+	case BGPSAFIMPLSVPN:
+		return "MPLSVPN"
+	case BGPSAFIFlowSpec:
+		return "FlowSpec"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// BGPAddressFamily is an AFI/SAFI pair.
+type BGPAddressFamily struct {
+	AFI  BGPAFI
+	SAFI BGPSAFI
+}
+
+// plainPrefixes returns true if NLRI for the family are plain IP prefixes.
+func (f BGPAddressFamily) plainPrefixes() bool {
+	return f.AFI.addrLen() != 0 && (f.SAFI == BGPSAFIUnicast || f.SAFI == BGPSAFIMulticast)
+}
+
+// BGPCapabilityCode identifies a capability advertised in an OPEN message.
+type BGPCapabilityCode uint8
+
+// BGPCapabilityCode known values.
+const (
+	BGPCapabilityMultiprotocol     BGPCapabilityCode = 1
+	BGPCapabilityRouteRefresh      BGPCapabilityCode = 2
+	BGPCapabilityExtendedMessage   BGPCapabilityCode = 6
+	BGPCapabilityGracefulRestart   BGPCapabilityCode = 64
+	BGPCapabilityFourOctetAS       BGPCapabilityCode = 65
+	BGPCapabilityAddPath           BGPCapabilityCode = 69
+	BGPCapabilityEnhancedRefresh   BGPCapabilityCode = 70
+	BGPCapabilityFQDN              BGPCapabilityCode = 73
+	BGPCapabilityCiscoRouteRefresh BGPCapabilityCode = 128
+)
+
+func (c BGPCapabilityCode) String() string {
+	switch c {
+	case BGPCapabilityMultiprotocol:
+		return "Multiprotocol"
+	case BGPCapabilityRouteRefresh, BGPCapabilityCiscoRouteRefresh:
+		return "RouteRefresh"
+	case BGPCapabilityExtendedMessage:
+		return "ExtendedMessage"
+	case BGPCapabilityGracefulRestart:
+		return "GracefulRestart"
+	case BGPCapabilityFourOctetAS:
+		return "FourOctetAS"
+	case BGPCapabilityAddPath:
+		return "AddPath"
+	case BGPCapabilityEnhancedRefresh:
+		return "EnhancedRouteRefresh"
+	case BGPCapabilityFQDN:
+		return "FQDN"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// BGPCapability is a capability advertised in an OPEN message.
+type BGPCapability struct {
+	Code  BGPCapabilityCode
+	Value []byte
+}
+
+// BGPOptionalParameter is an OPEN message optional parameter other than
+// capabilities, which are decoded into BGPOpen.Capabilities.
+type BGPOptionalParameter struct {
+	Type  uint8
+	Value []byte
+}
+
+// BGPAddPath is an address family listed in an ADD-PATH capability.
+type BGPAddPath struct {
+	BGPAddressFamily
+	// SendReceive is 1 if the speaker can receive multiple paths, 2 if it
+	// can send them and 3 for both.
+	SendReceive uint8
+}
+
+// BGPOpen is the body of an OPEN message.
+type BGPOpen struct {
+	Version       uint8
+	MyAS          uint16
+	HoldTime      uint16
+	BGPIdentifier net.IP
+	Capabilities  []BGPCapability
+	Parameters    []BGPOptionalParameter
+	// The following are decoded from Capabilities.
+	FourOctetAS   uint32 // Zero if the capability wasn't advertised.
+	Multiprotocol []BGPAddressFamily
+	AddPath       []BGPAddPath
+}
+
+// AS returns the speaker's AS number, taking it from the four octet AS
+// capability if present.
+func (o *BGPOpen) AS() uint32 {
+	if o.FourOctetAS != 0 {
+		return o.FourOctetAS
+	}
+	return uint32(o.MyAS)
+}
+
+func (o *BGPOpen) decode(data []byte) error {
+	if len(data) < 10 {
+		return errors.New("BGP OPEN message too short")
+	}
+	o.Version = data[0]
+	o.MyAS = binary.BigEndian.Uint16(data[1:3])
+	o.HoldTime = binary.BigEndian.Uint16(data[3:5])
+	o.BGPIdentifier = net.IP(data[5:9])
+	params := data[10:]
+	if int(data[9]) != len(params) {
+		return fmt.Errorf("BGP OPEN optional parameters length %d, %d available", data[9], len(params))
+	}
+	for len(params) > 0 {
+		if len(params) < 2 || len(params) < 2+int(params[1]) {
+			return errors.New("BGP OPEN optional parameter truncated")
+		}
+		typ, value := params[0], params[2:2+int(params[1])]
+		params = params[2+int(params[1]):]
+		if typ != 2 {
+			o.Parameters = append(o.Parameters, BGPOptionalParameter{Type: typ, Value: value})
+			continue
+		}
+		for len(value) > 0 {
+			if len(value) < 2 || len(value) < 2+int(value[1]) {
+				return errors.New("BGP OPEN capability truncated")
+			}
+			c := BGPCapability{Code: BGPCapabilityCode(value[0]), Value: value[2 : 2+int(value[1])]}
+			value = value[2+int(value[1]):]
+			if err := o.decodeCapability(c); err != nil {
+				return err
+			}
+			o.Capabilities = append(o.Capabilities, c)
+		}
+	}
+	return nil
+}
+
+func (o *BGPOpen) decodeCapability(c BGPCapability) error {
+	switch c.Code {
+	case BGPCapabilityMultiprotocol:
+		if len(c.Value) != 4 {
+			return fmt.Errorf("BGP multiprotocol capability length %d, 4 required", len(c.Value))
+		}
+		o.Multiprotocol = append(o.Multiprotocol, BGPAddressFamily{
+			AFI:  BGPAFI(binary.BigEndian.Uint16(c.Value[0:2])),
+			SAFI: BGPSAFI(c.Value[3]),
+		})
+	case BGPCapabilityFourOctetAS:
+		if len(c.Value) != 4 {
+			return fmt.Errorf("BGP four octet AS capability length %d, 4 required", len(c.Value))
+		}
+		o.FourOctetAS = binary.BigEndian.Uint32(c.Value)
+	case BGPCapabilityAddPath:
+		if len(c.Value)%4 != 0 {
+			return fmt.Errorf("BGP ADD-PATH capability length %d not a multiple of 4", len(c.Value))
+		}
+		for v := c.Value; len(v) > 0; v = v[4:] {
+			o.AddPath = append(o.AddPath, BGPAddPath{
+				BGPAddressFamily: BGPAddressFamily{
+					AFI:  BGPAFI(binary.BigEndian.Uint16(v[0:2])),
+					SAFI: BGPSAFI(v[2]),
+				},
+				SendReceive: v[3],
+			})
+		}
+	}
+	return nil
+}
+
+// BGPAttributeFlags are the flags of a path attribute.
+type BGPAttributeFlags uint8
+
+// BGPAttributeFlags known values.
+const (
+	BGPAttributeFlagOptional       BGPAttributeFlags = 0x80
+	BGPAttributeFlagTransitive     BGPAttributeFlags = 0x40
+	BGPAttributeFlagPartial        BGPAttributeFlags = 0x20
+	BGPAttributeFlagExtendedLength BGPAttributeFlags = 0x10
+)
+
+// BGPAttributeType is the type of a path attribute.
+type BGPAttributeType uint8
+
+// BGPAttributeType known values.
+const (
+	BGPAttributeTypeOrigin              BGPAttributeType = 1
+	BGPAttributeTypeASPath              BGPAttributeType = 2
+	BGPAttributeTypeNextHop             BGPAttributeType = 3
+	BGPAttributeTypeMED                 BGPAttributeType = 4
+	BGPAttributeTypeLocalPref           BGPAttributeType = 5
+	BGPAttributeTypeAtomicAggregate     BGPAttributeType = 6
+	BGPAttributeTypeAggregator          BGPAttributeType = 7
+	BGPAttributeTypeCommunities         BGPAttributeType = 8
+	BGPAttributeTypeOriginatorID        BGPAttributeType = 9
+	BGPAttributeTypeClusterList         BGPAttributeType = 10
+	BGPAttributeTypeMPReachNLRI         BGPAttributeType = 14
+	BGPAttributeTypeMPUnreachNLRI       BGPAttributeType = 15
+	BGPAttributeTypeExtendedCommunities BGPAttributeType = 16
+	BGPAttributeTypeAS4Path             BGPAttributeType = 17
+	BGPAttributeTypeAS4Aggregator       BGPAttributeType = 18
+	BGPAttributeTypeLargeCommunities    BGPAttributeType = 32
+)
+
+func (t BGPAttributeType) String() string {
+	switch t {
+	case BGPAttributeTypeOrigin:
+		return "Origin"
+	case BGPAttributeTypeASPath:
+		return "ASPath"
+	case BGPAttributeTypeNextHop:
+		return "NextHop"
+	case BGPAttributeTypeMED:
+		return "MED"
+	case BGPAttributeTypeLocalPref:
+		return "LocalPref"
+	case BGPAttributeTypeAtomicAggregate:
+		return "AtomicAggregate"
+	case BGPAttributeTypeAggregator:
+		return "Aggregator"
+	case BGPAttributeTypeCommunities:
+		return "Communities"
+	case BGPAttributeTypeOriginatorID:
+		return "OriginatorID"
+	case BGPAttributeTypeClusterList:
+		return "ClusterList"
+	case BGPAttributeTypeMPReachNLRI:
+		return "MPReachNLRI"
+	case BGPAttributeTypeMPUnreachNLRI:
+		return "MPUnreachNLRI"
+	case BGPAttributeTypeExtendedCommunities:
+		return "ExtendedCommunities"
+	case BGPAttributeTypeAS4Path:
+		return "AS4Path"
+	case BGPAttributeTypeAS4Aggregator:
+		return "AS4Aggregator"
+	case BGPAttributeTypeLargeCommunities:
+		return "LargeCommunities"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// BGPOrigin is the value of an ORIGIN attribute.
+type BGPOrigin uint8
+
+// BGPOrigin known values.
+const (
+	BGPOriginIGP        BGPOrigin = 0
+	BGPOriginEGP        BGPOrigin = 1
+	BGPOriginIncomplete BGPOrigin = 2
+)
+
+func (o BGPOrigin) String() string {
+	switch o {
+	case BGPOriginIGP:
+		return "IGP"
+	case BGPOriginEGP:
+		return "EGP"
+	case BGPOriginIncomplete:
+		return "Incomplete"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(o))
+	}
+}
+
+// BGPASPathSegmentType is the type of an AS_PATH segment.
+type BGPASPathSegmentType uint8
+
+// BGPASPathSegmentType known values.
+const (
+	BGPASPathSegmentSet            BGPASPathSegmentType = 1
+	BGPASPathSegmentSequence       BGPASPathSegmentType = 2
+	BGPASPathSegmentConfedSequence BGPASPathSegmentType = 3
+	BGPASPathSegmentConfedSet      BGPASPathSegmentType = 4
+)
+
+// BGPASPathSegment is one segment of an AS_PATH or AS4_PATH attribute.
+type BGPASPathSegment struct {
+	Type BGPASPathSegmentType
+	ASNs []uint32
+}
+
+// BGPPrefix is a prefix in withdrawn routes or NLRI.
+type BGPPrefix struct {
+	// PathID is only set for sessions using ADD-PATH; see BGP.AddPath.
+	PathID uint32
+	Prefix net.IPNet
+}
+
+func (p BGPPrefix) String() string {
+	return p.Prefix.String()
+}
+
+// BGPMultiprotocolNLRI is the value of an MP_REACH_NLRI or MP_UNREACH_NLRI
+// attribute.  Prefixes are only decoded for IPv4 and IPv6 unicast and
+// multicast; for other families NextHop and Prefixes are empty and Data
+// holds the undecoded remainder of the attribute.
+type BGPMultiprotocolNLRI struct {
+	BGPAddressFamily
+	NextHop  []net.IP // Only for MP_REACH_NLRI.
+	Prefixes []BGPPrefix
+	Data     []byte
+}
+
+// BGPPathAttribute is a path attribute of an UPDATE message.  Type determines
+// which of the decoded fields is set; Value always holds the raw attribute.
+type BGPPathAttribute struct {
+	Flags BGPAttributeFlags
+	Type  BGPAttributeType
+	Value []byte
+
+	Origin      BGPOrigin
+	ASPath      []BGPASPathSegment // AS_PATH and AS4_PATH.
+	NextHop     net.IP
+	MED         uint32
+	LocalPref   uint32
+	Communities []uint32
+	MPReach     *BGPMultiprotocolNLRI
+	MPUnreach   *BGPMultiprotocolNLRI
+}
+
+// BGPUpdate is the body of an UPDATE message.
+type BGPUpdate struct {
+	WithdrawnRoutes []BGPPrefix
+	Attributes      []BGPPathAttribute
+	NLRI            []BGPPrefix
+}
+
+// Attribute returns the first path attribute of the given type.
+func (u *BGPUpdate) Attribute(t BGPAttributeType) (*BGPPathAttribute, bool) {
+	for i := range u.Attributes {
+		if u.Attributes[i].Type == t {
+			return &u.Attributes[i], true
+		}
+	}
+	return nil, false
+}
+
+func (u *BGPUpdate) decode(data []byte, b *BGP) error {
+	if len(data) < 4 {
+		return errors.New("BGP UPDATE message too short")
+	}
+	wl := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 4+wl {
+		return fmt.Errorf("BGP UPDATE withdrawn routes length %d too long", wl)
+	}
+	var err error
+	if u.WithdrawnRoutes, err = decodeBGPPrefixes(data[2:2+wl], BGPAFIIPv4, b.AddPath); err != nil {
+		return err
+	}
+	data = data[2+wl:]
+	al := int(binary.BigEndian.Uint16(data[0:2]))
+	if len(data) < 2+al {
+		return fmt.Errorf("BGP UPDATE path attributes length %d too long", al)
+	}
+	for attrs := data[2 : 2+al]; len(attrs) > 0; {
+		var a BGPPathAttribute
+		n, err := a.decode(attrs, b)
+		if err != nil {
+			return err
+		}
+		u.Attributes = append(u.Attributes, a)
+		attrs = attrs[n:]
+	}
+	u.NLRI, err = decodeBGPPrefixes(data[2+al:], BGPAFIIPv4, b.AddPath)
+	return err
+}
+
+// decode decodes the attribute at the start of data, returning its length.
+func (a *BGPPathAttribute) decode(data []byte, b *BGP) (int, error) {
+	if len(data) < 3 {
+		return 0, errors.New("BGP path attribute truncated")
+	}
+	a.Flags = BGPAttributeFlags(data[0])
+	a.Type = BGPAttributeType(data[1])
+	hl, vl := 3, int(data[2])
+	if a.Flags&BGPAttributeFlagExtendedLength != 0 {
+		if len(data) < 4 {
+			return 0, errors.New("BGP path attribute truncated")
+		}
+		hl, vl = 4, int(binary.BigEndian.Uint16(data[2:4]))
+	}
+	if len(data) < hl+vl {
+		return 0, fmt.Errorf("BGP %v attribute length %d, %d available", a.Type, vl, len(data)-hl)
+	}
+	a.Value = data[hl : hl+vl]
+	v := a.Value
+	bad := func() (int, error) {
+		return 0, fmt.Errorf("BGP %v attribute has bad length %d", a.Type, len(v))
+	}
+	switch a.Type {
+	case BGPAttributeTypeOrigin:
+		if len(v) != 1 {
+			return bad()
+		}
+		a.Origin = BGPOrigin(v[0])
+	case BGPAttributeTypeASPath, BGPAttributeTypeAS4Path:
+		asLen := 4
+		if a.Type == BGPAttributeTypeASPath && b.TwoOctetAS {
+			asLen = 2
+		}
+		segs, err := decodeBGPASPath(v, asLen)
+		if err != nil {
+			return 0, err
+		}
+		a.ASPath = segs
+	case BGPAttributeTypeNextHop:
+		if len(v) != 4 {
+			return bad()
+		}
+		a.NextHop = net.IP(v)
+	case BGPAttributeTypeMED:
+		if len(v) != 4 {
+			return bad()
+		}
+		a.MED = binary.BigEndian.Uint32(v)
+	case BGPAttributeTypeLocalPref:
+		if len(v) != 4 {
+			return bad()
+		}
+		a.LocalPref = binary.BigEndian.Uint32(v)
+	case BGPAttributeTypeCommunities:
+		if len(v)%4 != 0 {
+			return bad()
+		}
+		for i := 0; i < len(v); i += 4 {
+			a.Communities = append(a.Communities, binary.BigEndian.Uint32(v[i:i+4]))
+		}
+	case BGPAttributeTypeMPReachNLRI:
+		mp, err := decodeBGPMPReach(v, b.AddPath)
+		if err != nil {
+			return 0, err
+		}
+		a.MPReach = mp
+	case BGPAttributeTypeMPUnreachNLRI:
+		if len(v) < 3 {
+			return bad()
+		}
+		mp := &BGPMultiprotocolNLRI{BGPAddressFamily: BGPAddressFamily{
+			AFI:  BGPAFI(binary.BigEndian.Uint16(v[0:2])),
+			SAFI: BGPSAFI(v[2]),
+		}}
+		if !mp.plainPrefixes() {
+			mp.Data = v[3:]
+		} else {
+			prefixes, err := decodeBGPPrefixes(v[3:], mp.AFI, b.AddPath)
+			if err != nil {
+				return 0, err
+			}
+			mp.Prefixes = prefixes
+		}
+		a.MPUnreach = mp
+	}
+	return hl + vl, nil
+}
+
+func decodeBGPMPReach(v []byte, addPath bool) (*BGPMultiprotocolNLRI, error) {
+	if len(v) < 5 || len(v) < 5+int(v[3]) {
+		return nil, errors.New("BGP MP_REACH_NLRI attribute truncated")
+	}
+	mp := &BGPMultiprotocolNLRI{BGPAddressFamily: BGPAddressFamily{
+		AFI:  BGPAFI(binary.BigEndian.Uint16(v[0:2])),
+		SAFI: BGPSAFI(v[2]),
+	}}
+	if !mp.plainPrefixes() {
+		mp.Data = v[3:]
+		return mp, nil
+	}
+	nh := v[4 : 4+int(v[3])]
+	addrLen := mp.AFI.addrLen()
+	if len(nh)%addrLen != 0 {
+		return nil, fmt.Errorf("BGP MP_REACH_NLRI next hop length %d for %v", len(nh), mp.AFI)
+	}
+	for ; len(nh) > 0; nh = nh[addrLen:] {
+		mp.NextHop = append(mp.NextHop, net.IP(nh[:addrLen]))
+	}
+	// Skip the reserved byte after the next hop.
+	var err error
+	mp.Prefixes, err = decodeBGPPrefixes(v[5+int(v[3]):], mp.AFI, addPath)
+	return mp, err
+}
+
+func decodeBGPASPath(v []byte, asLen int) ([]BGPASPathSegment, error) {
+	var segs []BGPASPathSegment
+	for len(v) > 0 {
+		if len(v) < 2 || len(v) < 2+int(v[1])*asLen {
+			return nil, errors.New("BGP AS path segment truncated")
+		}
+		seg := BGPASPathSegment{Type: BGPASPathSegmentType(v[0])}
+		n := int(v[1])
+		v = v[2:]
+		for i := 0; i < n; i++ {
+			if asLen == 2 {
+				seg.ASNs = append(seg.ASNs, uint32(binary.BigEndian.Uint16(v)))
+			} else {
+				seg.ASNs = append(seg.ASNs, binary.BigEndian.Uint32(v))
+			}
+			v = v[asLen:]
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+func decodeBGPPrefixes(data []byte, afi BGPAFI, addPath bool) ([]BGPPrefix, error) {
+	addrLen := afi.addrLen()
+	var prefixes []BGPPrefix
+	for len(data) > 0 {
+		var p BGPPrefix
+		if addPath {
+			if len(data) < 4 {
+				return nil, errors.New("BGP prefix path identifier truncated")
+			}
+			p.PathID = binary.BigEndian.Uint32(data[0:4])
+			data = data[4:]
+		}
+		if len(data) < 1 {
+			return nil, errors.New("BGP prefix truncated")
+		}
+		bits := int(data[0])
+		if bits > addrLen*8 {
+			return nil, fmt.Errorf("BGP %v prefix length %d too long", afi, bits)
+		}
+		n := (bits + 7) / 8
+		if len(data) < 1+n {
+			return nil, errors.New("BGP prefix truncated")
+		}
+		ip := make(net.IP, addrLen)
+		copy(ip, data[1:1+n])
+		p.Prefix = net.IPNet{IP: ip, Mask: net.CIDRMask(bits, addrLen*8)}
+		prefixes = append(prefixes, p)
+		data = data[1+n:]
+	}
+	return prefixes, nil
+}
+
+// BGPErrorCode is the error code of a NOTIFICATION message.
+type BGPErrorCode uint8
+
+// BGPErrorCode known values.
+const (
+	BGPErrorMessageHeader BGPErrorCode = 1
+	BGPErrorOpenMessage   BGPErrorCode = 2
+	BGPErrorUpdateMessage BGPErrorCode = 3
+	BGPErrorHoldTimer     BGPErrorCode = 4
+	BGPErrorFSM           BGPErrorCode = 5
+	BGPErrorCease         BGPErrorCode = 6
+	BGPErrorRouteRefresh  BGPErrorCode = 7
+)
+
+func (c BGPErrorCode) String() string {
+	switch c {
+	case BGPErrorMessageHeader:
+		return "Message Header Error"
+	case BGPErrorOpenMessage:
+		return "OPEN Message Error"
+	case BGPErrorUpdateMessage:
+		return "UPDATE Message Error"
+	case BGPErrorHoldTimer:
+		return "Hold Timer Expired"
+	case BGPErrorFSM:
+		return "Finite State Machine Error"
+	case BGPErrorCease:
+		return "Cease"
+	case BGPErrorRouteRefresh:
+		return "ROUTE-REFRESH Message Error"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// BGPNotification is the body of a NOTIFICATION message.
+type BGPNotification struct {
+	Code    BGPErrorCode
+	Subcode uint8
+	Data    []byte
+}
+
+// BGPMessage is a single BGP message.  Type determines which of Open, Update
+// and Notification is set; KEEPALIVE messages have no body, and Body holds
+// the undecoded body of every message.
+type BGPMessage struct {
+	Type         BGPMessageType
+	Length       uint16
+	Body         []byte
+	Open         *BGPOpen
+	Update       *BGPUpdate
+	Notification *BGPNotification
+}
+
+// BGP holds the BGP messages in a TCP segment or a chunk of a reassembled
+// stream, as described in RFC 4271.
+type BGP struct {
+	BaseLayer
+	Messages []BGPMessage
+
+	// TwoOctetAS must be set before decoding UPDATE messages from a session
+	// where the four octet AS capability wasn't negotiated, whose AS_PATH
+	// attributes hold two octet AS numbers.
+	TwoOctetAS bool
+	// AddPath must be set before decoding UPDATE messages from a session
+	// that negotiated ADD-PATH, whose prefixes carry path identifiers.
+	AddPath bool
+}
+
+// LayerType returns LayerTypeBGP.
+func (b *BGP) LayerType() gopacket.LayerType { return LayerTypeBGP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (b *BGP) CanDecode() gopacket.LayerClass { return LayerTypeBGP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (b *BGP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since BGP messages don't carry other layers.
+func (b *BGP) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.  data must hold
+// only complete messages; if the last one is cut off, ErrBGPNeedMoreData is
+// returned.
+func (b *BGP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	_, err := b.DecodeMessages(data, df)
+	return err
+}
+
+// DecodeMessages decodes as many complete messages as data holds, and
+// returns the number of bytes they take up.  If data ends partway through a
+// message, the messages before it are decoded and ErrBGPNeedMoreData is
+// returned along with their length, so that the caller can keep the
+// remaining bytes until more of the stream arrives.
+func (b *BGP) DecodeMessages(data []byte, df gopacket.DecodeFeedback) (int, error) {
+	b.Messages = b.Messages[:0]
+	n := 0
+	var err error
+	for n < len(data) {
+		var m BGPMessage
+		var ml int
+		if ml, err = m.decode(data[n:], b); err != nil {
+			break
+		}
+		b.Messages = append(b.Messages, m)
+		n += ml
+	}
+	if err == ErrBGPNeedMoreData {
+		df.SetTruncated()
+	}
+	b.BaseLayer = BaseLayer{Contents: data[:n]}
+	return n, err
+}
+
+// decode decodes the message at the start of data, returning its length.
+func (m *BGPMessage) decode(data []byte, b *BGP) (int, error) {
+	if len(data) < bgpHeaderLength {
+		return 0, ErrBGPNeedMoreData
+	}
+	if !bytes.Equal(data[:16], bgpMarker) {
+		return 0, errors.New("BGP message marker is not all ones")
+	}
+	m.Length = binary.BigEndian.Uint16(data[16:18])
+	m.Type = BGPMessageType(data[18])
+	if m.Length < bgpHeaderLength {
+		return 0, fmt.Errorf("BGP message length %d too short", m.Length)
+	}
+	if len(data) < int(m.Length) {
+		return 0, ErrBGPNeedMoreData
+	}
+	m.Body = data[bgpHeaderLength:m.Length]
+	switch m.Type {
+	case BGPMessageTypeOpen:
+		m.Open = &BGPOpen{}
+		if err := m.Open.decode(m.Body); err != nil {
+			return 0, err
+		}
+	case BGPMessageTypeUpdate:
+		m.Update = &BGPUpdate{}
+		if err := m.Update.decode(m.Body, b); err != nil {
+			return 0, err
+		}
+	case BGPMessageTypeNotification:
+		if len(m.Body) < 2 {
+			return 0, errors.New("BGP NOTIFICATION message too short")
+		}
+		m.Notification = &BGPNotification{
+			Code:    BGPErrorCode(m.Body[0]),
+			Subcode: m.Body[1],
+			Data:    m.Body[2:],
+		}
+	case BGPMessageTypeKeepalive:
+		if len(m.Body) != 0 {
+			return 0, fmt.Errorf("BGP KEEPALIVE message length %d", m.Length)
+		}
+	}
+	return int(m.Length), nil
+}
+
+func decodeBGP(data []byte, p gopacket.PacketBuilder) error {
+	b := &BGP{}
+	if err := b.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(b)
+	p.SetApplicationLayer(b)
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testBGPStream is one direction of a BGP session: an OPEN advertising IPv4
+// and IPv6 unicast, a four octet AS and ADD-PATH, a KEEPALIVE, an UPDATE with
+// IPv4 and IPv6 routes, and a NOTIFICATION closing the session.
+var testBGPStream = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0x00, 0x37, 0x01, 0x04, 0x5b, 0xa0, 0x00, 0x5a, 0xc0, 0x00, 0x02, 0x01, 0x1a, 0x02, 0x18, 0x01,
+	0x04, 0x00, 0x01, 0x00, 0x01, 0x01, 0x04, 0x00, 0x02, 0x00, 0x01, 0x41, 0x04, 0x00, 0x01, 0x00,
+	0x0e, 0x45, 0x04, 0x00, 0x01, 0x01, 0x03, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x13, 0x04, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x63, 0x02, 0x00, 0x02, 0x08,
+	0x0a, 0x00, 0x46, 0x40, 0x01, 0x01, 0x00, 0x40, 0x02, 0x0a, 0x02, 0x02, 0x00, 0x01, 0x00, 0x0e,
+	0x00, 0x01, 0x00, 0x0f, 0x40, 0x03, 0x04, 0xc0, 0x00, 0x02, 0x01, 0x80, 0x04, 0x04, 0x00, 0x00,
+	0x00, 0x64, 0xc0, 0x08, 0x04, 0xfd, 0xe8, 0x00, 0x01, 0x90, 0x0e, 0x00, 0x1c, 0x00, 0x02, 0x01,
+	0x10, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x30, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01, 0x18, 0xc6, 0x33, 0x64, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x15, 0x03,
+	0x06, 0x02,
+}
+
+func bgpTestPrefix(s string) BGPPrefix {
+	ip, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		n.IP = ip4
+	}
+	return BGPPrefix{Prefix: *n}
+}
+
+func TestBGPMessages(t *testing.T) {
+	p := gopacket.NewPacket(testBGPStream, LayerTypeBGP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBGP}, t)
+	b := p.Layer(LayerTypeBGP).(*BGP)
+	if len(b.Messages) != 4 {
+		t.Fatalf("got %d messages, want 4", len(b.Messages))
+	}
+	for i, typ := range []BGPMessageType{BGPMessageTypeOpen, BGPMessageTypeKeepalive, BGPMessageTypeUpdate, BGPMessageTypeNotification} {
+		if b.Messages[i].Type != typ {
+			t.Errorf("message %d: got type %v, want %v", i, b.Messages[i].Type, typ)
+		}
+	}
+
+	open := b.Messages[0].Open
+	if open.Version != 4 || open.HoldTime != 90 || !open.BGPIdentifier.Equal(net.IPv4(192, 0, 2, 1)) || open.AS() != 65550 {
+		t.Errorf("OPEN: got %+v", open)
+	}
+	wantMP := []BGPAddressFamily{{BGPAFIIPv4, BGPSAFIUnicast}, {BGPAFIIPv6, BGPSAFIUnicast}}
+	if !reflect.DeepEqual(open.Multiprotocol, wantMP) {
+		t.Errorf("OPEN multiprotocol: got %v", open.Multiprotocol)
+	}
+	wantAddPath := []BGPAddPath{{BGPAddressFamily{BGPAFIIPv4, BGPSAFIUnicast}, 3}}
+	if !reflect.DeepEqual(open.AddPath, wantAddPath) {
+		t.Errorf("OPEN ADD-PATH: got %v", open.AddPath)
+	}
+	if len(open.Capabilities) != 4 {
+		t.Errorf("OPEN: got %d capabilities, want 4", len(open.Capabilities))
+	}
+
+	u := b.Messages[2].Update
+	if want := []BGPPrefix{bgpTestPrefix("10.0.0.0/8")}; !reflect.DeepEqual(u.WithdrawnRoutes, want) {
+		t.Errorf("withdrawn routes: got %v", u.WithdrawnRoutes)
+	}
+	if want := []BGPPrefix{bgpTestPrefix("198.51.100.0/24")}; !reflect.DeepEqual(u.NLRI, want) {
+		t.Errorf("NLRI: got %v", u.NLRI)
+	}
+	if len(u.Attributes) != 6 {
+		t.Fatalf("got %d attributes, want 6", len(u.Attributes))
+	}
+	if a, ok := u.Attribute(BGPAttributeTypeOrigin); !ok || a.Origin != BGPOriginIGP {
+		t.Errorf("ORIGIN: got %+v", a)
+	}
+	wantPath := []BGPASPathSegment{{BGPASPathSegmentSequence, []uint32{65550, 65551}}}
+	if a, ok := u.Attribute(BGPAttributeTypeASPath); !ok || !reflect.DeepEqual(a.ASPath, wantPath) {
+		t.Errorf("AS_PATH: got %+v", a)
+	}
+	if a, ok := u.Attribute(BGPAttributeTypeNextHop); !ok || !a.NextHop.Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Errorf("NEXT_HOP: got %+v", a)
+	}
+	if a, ok := u.Attribute(BGPAttributeTypeMED); !ok || a.MED != 100 {
+		t.Errorf("MED: got %+v", a)
+	}
+	if a, ok := u.Attribute(BGPAttributeTypeCommunities); !ok || !reflect.DeepEqual(a.Communities, []uint32{65000<<16 | 1}) {
+		t.Errorf("COMMUNITIES: got %+v", a)
+	}
+	a, ok := u.Attribute(BGPAttributeTypeMPReachNLRI)
+	if !ok || a.MPReach == nil {
+		t.Fatal("no MP_REACH_NLRI attribute")
+	}
+	wantReach := &BGPMultiprotocolNLRI{
+		BGPAddressFamily: BGPAddressFamily{BGPAFIIPv6, BGPSAFIUnicast},
+		NextHop:          []net.IP{net.ParseIP("2001:db8::1")},
+		Prefixes:         []BGPPrefix{bgpTestPrefix("2001:db8:1::/48")},
+	}
+	if !reflect.DeepEqual(a.MPReach, wantReach) {
+		t.Errorf("MP_REACH_NLRI mismatch\nwant %+v\ngot  %+v", wantReach, a.MPReach)
+	}
+
+	n := b.Messages[3].Notification
+	if n.Code != BGPErrorCease || n.Subcode != 2 || len(n.Data) != 0 {
+		t.Errorf("NOTIFICATION: got %+v", n)
+	}
+}
+
+func TestBGPDecodeMessagesPartial(t *testing.T) {
+	b := &BGP{}
+	// Cut the stream partway through the UPDATE.
+	n, err := b.DecodeMessages(testBGPStream[:100], gopacket.NilDecodeFeedback)
+	if err != ErrBGPNeedMoreData {
+		t.Fatalf("got error %v, want ErrBGPNeedMoreData", err)
+	}
+	if n != 74 || len(b.Messages) != 2 {
+		t.Fatalf("got %d bytes and %d messages, want 74 and 2", n, len(b.Messages))
+	}
+	n, err = b.DecodeMessages(testBGPStream[n:], gopacket.NilDecodeFeedback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(testBGPStream)-74 || len(b.Messages) != 2 || b.Messages[0].Type != BGPMessageTypeUpdate {
+		t.Errorf("got %d bytes and messages %+v", n, b.Messages)
+	}
+
+	// Less than a header is also not enough.
+	if n, err := b.DecodeMessages(testBGPStream[:10], gopacket.NilDecodeFeedback); n != 0 || err != ErrBGPNeedMoreData {
+		t.Errorf("got %d, %v decoding partial header", n, err)
+	}
+	data := append([]byte(nil), testBGPStream...)
+	data[0] = 0
+	if _, err := b.DecodeMessages(data, gopacket.NilDecodeFeedback); err == nil || err == ErrBGPNeedMoreData {
+		t.Errorf("got error %v decoding bad marker", err)
+	}
+}
+
+func TestBGPSessionOptions(t *testing.T) {
+	// An UPDATE with a two octet AS_PATH and an ADD-PATH prefix.
+	data := []byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x00, 0x28, 0x02, 0x00, 0x00, 0x00, 0x09, 0x40, 0x02, 0x06, 0x02, 0x02, 0xfd, 0xe8, 0xfd, 0xe9,
+		0x00, 0x00, 0x00, 0x07, 0x18, 0xc6, 0x33, 0x64,
+	}
+	b := &BGP{TwoOctetAS: true, AddPath: true}
+	if err := b.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	u := b.Messages[0].Update
+	wantPath := []BGPASPathSegment{{BGPASPathSegmentSequence, []uint32{65000, 65001}}}
+	if !reflect.DeepEqual(u.Attributes[0].ASPath, wantPath) {
+		t.Errorf("AS_PATH: got %+v", u.Attributes[0].ASPath)
+	}
+	want := bgpTestPrefix("198.51.100.0/24")
+	want.PathID = 7
+	if !reflect.DeepEqual(u.NLRI, []BGPPrefix{want}) {
+		t.Errorf("NLRI: got %+v", u.NLRI)
+	}
+}
+
+func TestBGPOverTCP(t *testing.T) {
+	ip := &IPv4{
+		Version:  4,
+		TTL:      1,
+		Protocol: IPProtocolTCP,
+		SrcIP:    net.IP{192, 0, 2, 1},
+		DstIP:    net.IP{192, 0, 2, 2},
+	}
+	tcp := &TCP{SrcPort: 179, DstPort: 40000, ACK: true, PSH: true, Window: 16384}
+	tcp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, tcp, gopacket.Payload(testBGPStream[55:74])); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv4, gopacket.DecodeOptions{DecodeStreamsAsDatagrams: true})
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeTCP, LayerTypeBGP}, t)
+}
@@ -0,0 +1,645 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// BluetoothHCIPacketType is the packet indicator of the HCI UART transport
+// (H4), telling what kind of HCI packet follows.
+type BluetoothHCIPacketType uint8
+
+const (
+	BluetoothHCIPacketTypeCommand BluetoothHCIPacketType = 1
+	BluetoothHCIPacketTypeACL     BluetoothHCIPacketType = 2
+	BluetoothHCIPacketTypeSCO     BluetoothHCIPacketType = 3
+	BluetoothHCIPacketTypeEvent   BluetoothHCIPacketType = 4
+	BluetoothHCIPacketTypeISO     BluetoothHCIPacketType = 5
+)
+
+func (t BluetoothHCIPacketType) String() string {
+	switch t {
+	case BluetoothHCIPacketTypeCommand:
+		return "Command"
+	case BluetoothHCIPacketTypeACL:
+		return "ACL"
+	case BluetoothHCIPacketTypeSCO:
+		return "SCO"
+	case BluetoothHCIPacketTypeEvent:
+		return "Event"
+	case BluetoothHCIPacketTypeISO:
+		return "ISO"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// LayerType returns the layer type of HCI packets of this type.  SCO and
+// ISO packets are left as gopacket.LayerTypePayload.
+func (t BluetoothHCIPacketType) LayerType() gopacket.LayerType {
+	switch t {
+	case BluetoothHCIPacketTypeCommand:
+		return LayerTypeBluetoothHCICommand
+	case BluetoothHCIPacketTypeACL:
+		return LayerTypeBluetoothHCIACL
+	case BluetoothHCIPacketTypeEvent:
+		return LayerTypeBluetoothHCIEvent
+	case BluetoothHCIPacketTypeSCO, BluetoothHCIPacketTypeISO:
+		return gopacket.LayerTypePayload
+	default:
+		return gopacket.LayerTypeZero
+	}
+}
+
+// BluetoothHCIDirection is the direction of the pseudo-header of
+// LINKTYPE_BLUETOOTH_HCI_H4_WITH_PHDR captures.
+type BluetoothHCIDirection uint32
+
+const (
+	// BluetoothHCIDirectionSent is the direction of packets sent by the
+	// host to the controller.
+	BluetoothHCIDirectionSent BluetoothHCIDirection = 0
+	// BluetoothHCIDirectionReceived is the direction of packets received
+	// by the host from the controller.
+	BluetoothHCIDirectionReceived BluetoothHCIDirection = 1
+)
+
+func (d BluetoothHCIDirection) String() string {
+	switch d {
+	case BluetoothHCIDirectionSent:
+		return "Sent"
+	case BluetoothHCIDirectionReceived:
+		return "Received"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint32(d))
+	}
+}
+
+// BluetoothHCIH4 is the packet indicator of the HCI UART transport,
+// preceded by the 4 byte direction pseudo-header in
+// LINKTYPE_BLUETOOTH_HCI_H4_WITH_PHDR captures.
+type BluetoothHCIH4 struct {
+	BaseLayer
+	// PseudoHeader must be set before decoding if the pseudo-header is
+	// present.  Packets of LinkTypeBluetoothHCIH4WithPHDR are decoded
+	// with it set.
+	PseudoHeader bool
+	// Direction is only set if PseudoHeader is.
+	Direction  BluetoothHCIDirection
+	PacketType BluetoothHCIPacketType
+}
+
+// LayerType returns LayerTypeBluetoothHCIH4.
+func (h *BluetoothHCIH4) LayerType() gopacket.LayerType { return LayerTypeBluetoothHCIH4 }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (h *BluetoothHCIH4) CanDecode() gopacket.LayerClass { return LayerTypeBluetoothHCIH4 }
+
+// NextLayerType returns the layer type given by PacketType.
+func (h *BluetoothHCIH4) NextLayerType() gopacket.LayerType { return h.PacketType.LayerType() }
+
+// Received returns true for packets received from the controller.
+func (h *BluetoothHCIH4) Received() bool { return h.Direction == BluetoothHCIDirectionReceived }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (h *BluetoothHCIH4) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	length := 1
+	if h.PseudoHeader {
+		length = 5
+	}
+	if len(data) < length {
+		df.SetTruncated()
+		return errors.New("Bluetooth HCI H4 header too short")
+	}
+	h.Direction = 0
+	if h.PseudoHeader {
+		h.Direction = BluetoothHCIDirection(binary.BigEndian.Uint32(data[0:4]))
+	}
+	h.PacketType = BluetoothHCIPacketType(data[length-1])
+	h.BaseLayer = BaseLayer{data[:length], data[length:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (h *BluetoothHCIH4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 1
+	if h.PseudoHeader {
+		length = 5
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if h.PseudoHeader {
+		binary.BigEndian.PutUint32(bytes[0:4], uint32(h.Direction))
+	}
+	bytes[length-1] = uint8(h.PacketType)
+	return nil
+}
+
+func decodeBluetoothHCIH4(data []byte, p gopacket.PacketBuilder) error {
+	h := &BluetoothHCIH4{}
+	return decodingLayerDecoder(h, data, p)
+}
+
+func decodeBluetoothHCIH4WithPHDR(data []byte, p gopacket.PacketBuilder) error {
+	h := &BluetoothHCIH4{PseudoHeader: true}
+	return decodingLayerDecoder(h, data, p)
+}
+
+// BluetoothHCIOpcode is the opcode of an HCI command, made of the opcode
+// group field (OGF) and the opcode command field (OCF).
+type BluetoothHCIOpcode uint16
+
+// OGF returns the 6 bit opcode group field.
+func (o BluetoothHCIOpcode) OGF() uint8 { return uint8(o >> 10) }
+
+// OCF returns the 10 bit opcode command field.
+func (o BluetoothHCIOpcode) OCF() uint16 { return uint16(o) & 0x3ff }
+
+func (o BluetoothHCIOpcode) String() string {
+	return fmt.Sprintf("OGF %#02x OCF %#04x", o.OGF(), o.OCF())
+}
+
+// BluetoothHCICommand is an HCI command packet.
+type BluetoothHCICommand struct {
+	BaseLayer
+	Opcode          BluetoothHCIOpcode
+	ParameterLength uint8
+	Parameters      []byte
+}
+
+// LayerType returns LayerTypeBluetoothHCICommand.
+func (c *BluetoothHCICommand) LayerType() gopacket.LayerType { return LayerTypeBluetoothHCICommand }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *BluetoothHCICommand) CanDecode() gopacket.LayerClass { return LayerTypeBluetoothHCICommand }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (c *BluetoothHCICommand) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *BluetoothHCICommand) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 3 {
+		df.SetTruncated()
+		return errors.New("Bluetooth HCI command too short")
+	}
+	c.Opcode = BluetoothHCIOpcode(binary.LittleEndian.Uint16(data[0:2]))
+	c.ParameterLength = data[2]
+	end := 3 + int(c.ParameterLength)
+	if end > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("Bluetooth HCI command parameter length %d exceeds %d bytes", c.ParameterLength, len(data)-3)
+	}
+	c.Parameters = data[3:end]
+	c.BaseLayer = BaseLayer{data[:end], data[end:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *BluetoothHCICommand) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(c.Parameters) > 0xff {
+		return fmt.Errorf("Bluetooth HCI command parameters of %d bytes too long", len(c.Parameters))
+	}
+	bytes, err := b.PrependBytes(3 + len(c.Parameters))
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		c.ParameterLength = uint8(len(c.Parameters))
+	}
+	binary.LittleEndian.PutUint16(bytes[0:2], uint16(c.Opcode))
+	bytes[2] = c.ParameterLength
+	copy(bytes[3:], c.Parameters)
+	return nil
+}
+
+func decodeBluetoothHCICommand(data []byte, p gopacket.PacketBuilder) error {
+	c := &BluetoothHCICommand{}
+	return decodingLayerDecoder(c, data, p)
+}
+
+// BluetoothHCIEventCode is the event code of an HCI event.
+type BluetoothHCIEventCode uint8
+
+const (
+	BluetoothHCIEventConnectionComplete       BluetoothHCIEventCode = 0x03
+	BluetoothHCIEventDisconnectionComplete    BluetoothHCIEventCode = 0x05
+	BluetoothHCIEventEncryptionChange         BluetoothHCIEventCode = 0x08
+	BluetoothHCIEventCommandComplete          BluetoothHCIEventCode = 0x0e
+	BluetoothHCIEventCommandStatus            BluetoothHCIEventCode = 0x0f
+	BluetoothHCIEventHardwareError            BluetoothHCIEventCode = 0x10
+	BluetoothHCIEventNumberOfCompletedPackets BluetoothHCIEventCode = 0x13
+	BluetoothHCIEventLEMeta                   BluetoothHCIEventCode = 0x3e
+	BluetoothHCIEventVendor                   BluetoothHCIEventCode = 0xff
+)
+
+func (c BluetoothHCIEventCode) String() string {
+	switch c {
+	case BluetoothHCIEventConnectionComplete:
+		return "ConnectionComplete"
+	case BluetoothHCIEventDisconnectionComplete:
+		return "DisconnectionComplete"
+	case BluetoothHCIEventEncryptionChange:
+		return "EncryptionChange"
+	case BluetoothHCIEventCommandComplete:
+		return "CommandComplete"
+	case BluetoothHCIEventCommandStatus:
+		return "CommandStatus"
+	case BluetoothHCIEventHardwareError:
+		return "HardwareError"
+	case BluetoothHCIEventNumberOfCompletedPackets:
+		return "NumberOfCompletedPackets"
+	case BluetoothHCIEventLEMeta:
+		return "LEMeta"
+	case BluetoothHCIEventVendor:
+		return "Vendor"
+	default:
+		return fmt.Sprintf("Unknown(%#02x)", uint8(c))
+	}
+}
+
+// BluetoothHCIEvent is an HCI event packet.  LE meta events have their
+// subevent code decoded into LESubeventCode; advertising reports are
+// further decoded into AdvertisingReports.
+type BluetoothHCIEvent struct {
+	BaseLayer
+	EventCode       BluetoothHCIEventCode
+	ParameterLength uint8
+	Parameters      []byte
+
+	LESubeventCode     BluetoothLESubeventCode
+	AdvertisingReports []BluetoothLEAdvertisingReport
+}
+
+// LayerType returns LayerTypeBluetoothHCIEvent.
+func (e *BluetoothHCIEvent) LayerType() gopacket.LayerType { return LayerTypeBluetoothHCIEvent }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (e *BluetoothHCIEvent) CanDecode() gopacket.LayerClass { return LayerTypeBluetoothHCIEvent }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (e *BluetoothHCIEvent) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (e *BluetoothHCIEvent) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("Bluetooth HCI event too short")
+	}
+	e.EventCode = BluetoothHCIEventCode(data[0])
+	e.ParameterLength = data[1]
+	end := 2 + int(e.ParameterLength)
+	if end > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("Bluetooth HCI event parameter length %d exceeds %d bytes", e.ParameterLength, len(data)-2)
+	}
+	e.Parameters = data[2:end]
+	e.LESubeventCode = 0
+	e.AdvertisingReports = e.AdvertisingReports[:0]
+	if e.EventCode == BluetoothHCIEventLEMeta {
+		if err := e.decodeLEMeta(); err != nil {
+			return err
+		}
+	}
+	e.BaseLayer = BaseLayer{data[:end], data[end:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// The event is written from Parameters; the decoded LE meta event fields
+// are not used.
+func (e *BluetoothHCIEvent) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(e.Parameters) > 0xff {
+		return fmt.Errorf("Bluetooth HCI event parameters of %d bytes too long", len(e.Parameters))
+	}
+	bytes, err := b.PrependBytes(2 + len(e.Parameters))
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		e.ParameterLength = uint8(len(e.Parameters))
+	}
+	bytes[0] = uint8(e.EventCode)
+	bytes[1] = e.ParameterLength
+	copy(bytes[2:], e.Parameters)
+	return nil
+}
+
+func decodeBluetoothHCIEvent(data []byte, p gopacket.PacketBuilder) error {
+	e := &BluetoothHCIEvent{}
+	return decodingLayerDecoder(e, data, p)
+}
+
+// BluetoothHCIACL is an HCI ACL data packet.  Packets starting an L2CAP
+// PDU are followed by an L2CAP layer; continuing fragments are left as a
+// gopacket.Payload.
+type BluetoothHCIACL struct {
+	BaseLayer
+	// Handle is the 12 bit connection handle.
+	Handle uint16
+	// PacketBoundary is 0 or 2 for the first fragment of an L2CAP PDU, and
+	// 1 for continuing fragments.
+	PacketBoundary uint8
+	Broadcast      uint8
+	Length         uint16
+}
+
+// LayerType returns LayerTypeBluetoothHCIACL.
+func (a *BluetoothHCIACL) LayerType() gopacket.LayerType { return LayerTypeBluetoothHCIACL }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (a *BluetoothHCIACL) CanDecode() gopacket.LayerClass { return LayerTypeBluetoothHCIACL }
+
+// NextLayerType returns LayerTypeBluetoothL2CAP for first fragments, and
+// gopacket.LayerTypePayload for continuing ones.
+func (a *BluetoothHCIACL) NextLayerType() gopacket.LayerType {
+	if a.PacketBoundary == 1 {
+		return gopacket.LayerTypePayload
+	}
+	return LayerTypeBluetoothL2CAP
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (a *BluetoothHCIACL) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("Bluetooth HCI ACL packet too short")
+	}
+	handle := binary.LittleEndian.Uint16(data[0:2])
+	a.Handle = handle & 0x0fff
+	a.PacketBoundary = uint8(handle>>12) & 0x03
+	a.Broadcast = uint8(handle >> 14)
+	a.Length = binary.LittleEndian.Uint16(data[2:4])
+	end := 4 + int(a.Length)
+	if end > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("Bluetooth HCI ACL length %d exceeds %d bytes", a.Length, len(data)-4)
+	}
+	a.BaseLayer = BaseLayer{data[:4], data[4:end]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (a *BluetoothHCIACL) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := len(b.Bytes())
+	if length > 0xffff {
+		return fmt.Errorf("Bluetooth HCI ACL payload of %d bytes too long", length)
+	}
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		a.Length = uint16(length)
+	}
+	binary.LittleEndian.PutUint16(bytes[0:2], a.Handle&0x0fff|uint16(a.PacketBoundary&0x03)<<12|uint16(a.Broadcast&0x03)<<14)
+	binary.LittleEndian.PutUint16(bytes[2:4], a.Length)
+	return nil
+}
+
+func decodeBluetoothHCIACL(data []byte, p gopacket.PacketBuilder) error {
+	a := &BluetoothHCIACL{}
+	return decodingLayerDecoder(a, data, p)
+}
+
+const (
+	// BluetoothL2CAPCIDSignaling is the channel of L2CAP signaling on
+	// BR/EDR links.
+	BluetoothL2CAPCIDSignaling = 0x0001
+	// BluetoothL2CAPCIDATT is the channel of the attribute protocol.
+	BluetoothL2CAPCIDATT = 0x0004
+	// BluetoothL2CAPCIDLESignaling is the channel of L2CAP signaling on LE
+	// links.
+	BluetoothL2CAPCIDLESignaling = 0x0005
+	// BluetoothL2CAPCIDSMP is the channel of the security manager protocol
+	// on LE links.
+	BluetoothL2CAPCIDSMP = 0x0006
+)
+
+// BluetoothL2CAP is the basic L2CAP header.  If the PDU is fragmented
+// across ACL packets, the payload is only the first fragment, and isn't
+// decoded further.
+type BluetoothL2CAP struct {
+	BaseLayer
+	// Length is the length of the whole PDU payload.
+	Length uint16
+	// CID is the channel identifier.
+	CID uint16
+}
+
+// LayerType returns LayerTypeBluetoothL2CAP.
+func (l *BluetoothL2CAP) LayerType() gopacket.LayerType { return LayerTypeBluetoothL2CAP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (l *BluetoothL2CAP) CanDecode() gopacket.LayerClass { return LayerTypeBluetoothL2CAP }
+
+// Fragmented returns true if only the first fragment of the PDU is there.
+func (l *BluetoothL2CAP) Fragmented() bool { return int(l.Length) > len(l.Payload) }
+
+// NextLayerType returns LayerTypeBluetoothATT for unfragmented PDUs of the
+// ATT channel, and gopacket.LayerTypePayload otherwise.
+func (l *BluetoothL2CAP) NextLayerType() gopacket.LayerType {
+	if l.CID == BluetoothL2CAPCIDATT && !l.Fragmented() {
+		return LayerTypeBluetoothATT
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (l *BluetoothL2CAP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("Bluetooth L2CAP header too short")
+	}
+	l.Length = binary.LittleEndian.Uint16(data[0:2])
+	l.CID = binary.LittleEndian.Uint16(data[2:4])
+	end := 4 + int(l.Length)
+	if end > len(data) {
+		end = len(data)
+	}
+	l.BaseLayer = BaseLayer{data[:4], data[4:end]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (l *BluetoothL2CAP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := len(b.Bytes())
+	if length > 0xffff {
+		return fmt.Errorf("Bluetooth L2CAP payload of %d bytes too long", length)
+	}
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		l.Length = uint16(length)
+	}
+	binary.LittleEndian.PutUint16(bytes[0:2], l.Length)
+	binary.LittleEndian.PutUint16(bytes[2:4], l.CID)
+	return nil
+}
+
+func decodeBluetoothL2CAP(data []byte, p gopacket.PacketBuilder) error {
+	l := &BluetoothL2CAP{}
+	return decodingLayerDecoder(l, data, p)
+}
+
+// BluetoothATTOpcode is the opcode of an attribute protocol PDU.  Its top
+// two bits are the authentication signature and command flags.
+type BluetoothATTOpcode uint8
+
+const (
+	BluetoothATTErrorResponse           BluetoothATTOpcode = 0x01
+	BluetoothATTExchangeMTURequest      BluetoothATTOpcode = 0x02
+	BluetoothATTExchangeMTUResponse     BluetoothATTOpcode = 0x03
+	BluetoothATTFindInformationRequest  BluetoothATTOpcode = 0x04
+	BluetoothATTFindInformationResponse BluetoothATTOpcode = 0x05
+	BluetoothATTFindByTypeValueRequest  BluetoothATTOpcode = 0x06
+	BluetoothATTFindByTypeValueResponse BluetoothATTOpcode = 0x07
+	BluetoothATTReadByTypeRequest       BluetoothATTOpcode = 0x08
+	BluetoothATTReadByTypeResponse      BluetoothATTOpcode = 0x09
+	BluetoothATTReadRequest             BluetoothATTOpcode = 0x0a
+	BluetoothATTReadResponse            BluetoothATTOpcode = 0x0b
+	BluetoothATTReadBlobRequest         BluetoothATTOpcode = 0x0c
+	BluetoothATTReadBlobResponse        BluetoothATTOpcode = 0x0d
+	BluetoothATTReadMultipleRequest     BluetoothATTOpcode = 0x0e
+	BluetoothATTReadMultipleResponse    BluetoothATTOpcode = 0x0f
+	BluetoothATTReadByGroupTypeRequest  BluetoothATTOpcode = 0x10
+	BluetoothATTReadByGroupTypeResponse BluetoothATTOpcode = 0x11
+	BluetoothATTWriteRequest            BluetoothATTOpcode = 0x12
+	BluetoothATTWriteResponse           BluetoothATTOpcode = 0x13
+	BluetoothATTPrepareWriteRequest     BluetoothATTOpcode = 0x16
+	BluetoothATTPrepareWriteResponse    BluetoothATTOpcode = 0x17
+	BluetoothATTExecuteWriteRequest     BluetoothATTOpcode = 0x18
+	BluetoothATTExecuteWriteResponse    BluetoothATTOpcode = 0x19
+	BluetoothATTHandleValueNotification BluetoothATTOpcode = 0x1b
+	BluetoothATTHandleValueIndication   BluetoothATTOpcode = 0x1d
+	BluetoothATTHandleValueConfirmation BluetoothATTOpcode = 0x1e
+	BluetoothATTWriteCommand            BluetoothATTOpcode = 0x52
+	BluetoothATTSignedWriteCommand      BluetoothATTOpcode = 0xd2
+)
+
+var bluetoothATTOpcodeNames = map[BluetoothATTOpcode]string{
+	BluetoothATTErrorResponse:           "ErrorResponse",
+	BluetoothATTExchangeMTURequest:      "ExchangeMTURequest",
+	BluetoothATTExchangeMTUResponse:     "ExchangeMTUResponse",
+	BluetoothATTFindInformationRequest:  "FindInformationRequest",
+	BluetoothATTFindInformationResponse: "FindInformationResponse",
+	BluetoothATTFindByTypeValueRequest:  "FindByTypeValueRequest",
+	BluetoothATTFindByTypeValueResponse: "FindByTypeValueResponse",
+	BluetoothATTReadByTypeRequest:       "ReadByTypeRequest",
+	BluetoothATTReadByTypeResponse:      "ReadByTypeResponse",
+	BluetoothATTReadRequest:             "ReadRequest",
+	BluetoothATTReadResponse:            "ReadResponse",
+	BluetoothATTReadBlobRequest:         "ReadBlobRequest",
+	BluetoothATTReadBlobResponse:        "ReadBlobResponse",
+	BluetoothATTReadMultipleRequest:     "ReadMultipleRequest",
+	BluetoothATTReadMultipleResponse:    "ReadMultipleResponse",
+	BluetoothATTReadByGroupTypeRequest:  "ReadByGroupTypeRequest",
+	BluetoothATTReadByGroupTypeResponse: "ReadByGroupTypeResponse",
+	BluetoothATTWriteRequest:            "WriteRequest",
+	BluetoothATTWriteResponse:           "WriteResponse",
+	BluetoothATTPrepareWriteRequest:     "PrepareWriteRequest",
+	BluetoothATTPrepareWriteResponse:    "PrepareWriteResponse",
+	BluetoothATTExecuteWriteRequest:     "ExecuteWriteRequest",
+	BluetoothATTExecuteWriteResponse:    "ExecuteWriteResponse",
+	BluetoothATTHandleValueNotification: "HandleValueNotification",
+	BluetoothATTHandleValueIndication:   "HandleValueIndication",
+	BluetoothATTHandleValueConfirmation: "HandleValueConfirmation",
+	BluetoothATTWriteCommand:            "WriteCommand",
+	BluetoothATTSignedWriteCommand:      "SignedWriteCommand",
+}
+
+func (o BluetoothATTOpcode) String() string {
+	if name, ok := bluetoothATTOpcodeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%#02x)", uint8(o))
+}
+
+// Method returns the opcode without the command and authentication
+// signature flags.
+func (o BluetoothATTOpcode) Method() BluetoothATTOpcode { return o & 0x3f }
+
+// Command returns true for commands, which have no response.
+func (o BluetoothATTOpcode) Command() bool { return o&0x40 != 0 }
+
+// Signed returns true if the PDU ends with a 12 byte authentication
+// signature.
+func (o BluetoothATTOpcode) Signed() bool { return o&0x80 != 0 }
+
+// BluetoothATT is an attribute protocol PDU.  Only the opcode is decoded;
+// Parameters holds the rest.
+type BluetoothATT struct {
+	BaseLayer
+	Opcode     BluetoothATTOpcode
+	Parameters []byte
+}
+
+// LayerType returns LayerTypeBluetoothATT.
+func (a *BluetoothATT) LayerType() gopacket.LayerType { return LayerTypeBluetoothATT }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (a *BluetoothATT) CanDecode() gopacket.LayerClass { return LayerTypeBluetoothATT }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (a *BluetoothATT) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, as ATT PDUs carry no further layers.
+func (a *BluetoothATT) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (a *BluetoothATT) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 1 {
+		df.SetTruncated()
+		return errors.New("Bluetooth ATT PDU empty")
+	}
+	a.Opcode = BluetoothATTOpcode(data[0])
+	a.Parameters = data[1:]
+	a.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (a *BluetoothATT) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(1 + len(a.Parameters))
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(a.Opcode)
+	copy(bytes[1:], a.Parameters)
+	return nil
+}
+
+func decodeBluetoothATT(data []byte, p gopacket.PacketBuilder) error {
+	a := &BluetoothATT{}
+	if err := a.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(a)
+	p.SetApplicationLayer(a)
+	return nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// BluetoothLESubeventCode is the subevent code of an LE meta event.
+type BluetoothLESubeventCode uint8
+
+const (
+	BluetoothLESubeventConnectionComplete         BluetoothLESubeventCode = 0x01
+	BluetoothLESubeventAdvertisingReport          BluetoothLESubeventCode = 0x02
+	BluetoothLESubeventConnectionUpdateComplete   BluetoothLESubeventCode = 0x03
+	BluetoothLESubeventEnhancedConnectionComplete BluetoothLESubeventCode = 0x0a
+	BluetoothLESubeventDirectedAdvertisingReport  BluetoothLESubeventCode = 0x0b
+	BluetoothLESubeventExtendedAdvertisingReport  BluetoothLESubeventCode = 0x0d
+)
+
+func (c BluetoothLESubeventCode) String() string {
+	switch c {
+	case BluetoothLESubeventConnectionComplete:
+		return "ConnectionComplete"
+	case BluetoothLESubeventAdvertisingReport:
+		return "AdvertisingReport"
+	case BluetoothLESubeventConnectionUpdateComplete:
+		return "ConnectionUpdateComplete"
+	case BluetoothLESubeventEnhancedConnectionComplete:
+		return "EnhancedConnectionComplete"
+	case BluetoothLESubeventDirectedAdvertisingReport:
+		return "DirectedAdvertisingReport"
+	case BluetoothLESubeventExtendedAdvertisingReport:
+		return "ExtendedAdvertisingReport"
+	default:
+		return fmt.Sprintf("Unknown(%#02x)", uint8(c))
+	}
+}
+
+// BluetoothADType is the type of an advertising data structure, as
+// assigned by the Bluetooth SIG.
+type BluetoothADType uint8
+
+const (
+	BluetoothADFlags                BluetoothADType = 0x01
+	BluetoothADIncompleteUUID16     BluetoothADType = 0x02
+	BluetoothADCompleteUUID16       BluetoothADType = 0x03
+	BluetoothADIncompleteUUID32     BluetoothADType = 0x04
+	BluetoothADCompleteUUID32       BluetoothADType = 0x05
+	BluetoothADIncompleteUUID128    BluetoothADType = 0x06
+	BluetoothADCompleteUUID128      BluetoothADType = 0x07
+	BluetoothADShortenedLocalName   BluetoothADType = 0x08
+	BluetoothADCompleteLocalName    BluetoothADType = 0x09
+	BluetoothADTxPowerLevel         BluetoothADType = 0x0a
+	BluetoothADServiceData16        BluetoothADType = 0x16
+	BluetoothADAppearance           BluetoothADType = 0x19
+	BluetoothADServiceData32        BluetoothADType = 0x20
+	BluetoothADServiceData128       BluetoothADType = 0x21
+	BluetoothADManufacturerSpecific BluetoothADType = 0xff
+)
+
+func (t BluetoothADType) String() string {
+	switch t {
+	case BluetoothADFlags:
+		return "Flags"
+	case BluetoothADIncompleteUUID16:
+		return "IncompleteUUID16"
+	case BluetoothADCompleteUUID16:
+		return "CompleteUUID16"
+	case BluetoothADIncompleteUUID32:
+		return "IncompleteUUID32"
+	case BluetoothADCompleteUUID32:
+		return "CompleteUUID32"
+	case BluetoothADIncompleteUUID128:
+		return "IncompleteUUID128"
+	case BluetoothADCompleteUUID128:
+		return "CompleteUUID128"
+	case BluetoothADShortenedLocalName:
+		return "ShortenedLocalName"
+	case BluetoothADCompleteLocalName:
+		return "CompleteLocalName"
+	case BluetoothADTxPowerLevel:
+		return "TxPowerLevel"
+	case BluetoothADServiceData16:
+		return "ServiceData16"
+	case BluetoothADAppearance:
+		return "Appearance"
+	case BluetoothADServiceData32:
+		return "ServiceData32"
+	case BluetoothADServiceData128:
+		return "ServiceData128"
+	case BluetoothADManufacturerSpecific:
+		return "ManufacturerSpecific"
+	default:
+		return fmt.Sprintf("Unknown(%#02x)", uint8(t))
+	}
+}
+
+// BluetoothADStructure is an advertising data structure, one of the
+// length-type-value elements of advertising and scan response data.
+type BluetoothADStructure struct {
+	Type BluetoothADType
+	Data []byte
+}
+
+// decodeBluetoothAD decodes advertising data.  A zero length ends it early,
+// the rest being padding.
+func decodeBluetoothAD(data []byte) ([]BluetoothADStructure, error) {
+	var ad []BluetoothADStructure
+	for len(data) > 0 && data[0] != 0 {
+		length := int(data[0])
+		if 1+length > len(data) {
+			return nil, fmt.Errorf("Bluetooth AD structure length %d exceeds %d bytes", length, len(data)-1)
+		}
+		ad = append(ad, BluetoothADStructure{Type: BluetoothADType(data[1]), Data: data[2 : 1+length]})
+		data = data[1+length:]
+	}
+	return ad, nil
+}
+
+// BluetoothLEAdvertisingReport is a report of an LE advertising report or
+// extended advertising report event.  The fields following Extended are
+// only set for extended reports.
+type BluetoothLEAdvertisingReport struct {
+	// EventType is the 8 bit event type of legacy reports, ADV_IND to
+	// SCAN_RSP, or the 16 bit event type bit field of extended reports.
+	EventType   uint16
+	AddressType uint8
+	// Address is the device address, in the usual most significant byte
+	// first order.
+	Address net.HardwareAddr
+	// Data is the advertising or scan response data, and AD the structures
+	// decoded from it.
+	Data []byte
+	AD   []BluetoothADStructure
+	// RSSI is in dBm, 127 if unavailable.
+	RSSI int8
+
+	Extended                    bool
+	PrimaryPHY                  uint8
+	SecondaryPHY                uint8
+	AdvertisingSID              uint8
+	TxPower                     int8
+	PeriodicAdvertisingInterval uint16
+	DirectAddressType           uint8
+	DirectAddress               net.HardwareAddr
+}
+
+// FindAD returns the data of the first AD structure of the given type, or
+// nil if there's none.
+func (r *BluetoothLEAdvertisingReport) FindAD(t BluetoothADType) []byte {
+	for _, ad := range r.AD {
+		if ad.Type == t {
+			return ad.Data
+		}
+	}
+	return nil
+}
+
+// LocalName returns the complete or shortened local name of the device, or
+// "" if there's none.
+func (r *BluetoothLEAdvertisingReport) LocalName() string {
+	if name := r.FindAD(BluetoothADCompleteLocalName); name != nil {
+		return string(name)
+	}
+	return string(r.FindAD(BluetoothADShortenedLocalName))
+}
+
+// bluetoothAddress returns the little-endian device address in b as a
+// net.HardwareAddr.
+func bluetoothAddress(b []byte) net.HardwareAddr {
+	a := make(net.HardwareAddr, 6)
+	for i := range a {
+		a[i] = b[5-i]
+	}
+	return a
+}
+
+// decodeLEMeta decodes the subevent of an LE meta event, and its reports if
+// it's an advertising report.
+func (e *BluetoothHCIEvent) decodeLEMeta() error {
+	if len(e.Parameters) < 1 {
+		return errors.New("Bluetooth LE meta event too short")
+	}
+	e.LESubeventCode = BluetoothLESubeventCode(e.Parameters[0])
+	switch e.LESubeventCode {
+	case BluetoothLESubeventAdvertisingReport, BluetoothLESubeventExtendedAdvertisingReport:
+	default:
+		return nil
+	}
+	if len(e.Parameters) < 2 {
+		return fmt.Errorf("Bluetooth LE %v too short", e.LESubeventCode)
+	}
+	count := int(e.Parameters[1])
+	data := e.Parameters[2:]
+	for i := 0; i < count; i++ {
+		var r BluetoothLEAdvertisingReport
+		var err error
+		if e.LESubeventCode == BluetoothLESubeventAdvertisingReport {
+			data, err = r.decodeLegacy(data)
+		} else {
+			data, err = r.decodeExtended(data)
+		}
+		if err != nil {
+			return err
+		}
+		e.AdvertisingReports = append(e.AdvertisingReports, r)
+	}
+	return nil
+}
+
+// decodeLegacy decodes a report of an advertising report event, returning
+// the data following it.  Reports are laid out one after the other, as
+// controllers send a single one per event.
+func (r *BluetoothLEAdvertisingReport) decodeLegacy(data []byte) ([]byte, error) {
+	if len(data) < 9 {
+		return nil, errors.New("Bluetooth LE advertising report truncated")
+	}
+	r.EventType = uint16(data[0])
+	r.AddressType = data[1]
+	r.Address = bluetoothAddress(data[2:8])
+	length := int(data[8])
+	if 10+length > len(data) {
+		return nil, errors.New("Bluetooth LE advertising report truncated")
+	}
+	r.Data = data[9 : 9+length]
+	r.RSSI = int8(data[9+length])
+	var err error
+	r.AD, err = decodeBluetoothAD(r.Data)
+	return data[10+length:], err
+}
+
+// decodeExtended decodes a report of an extended advertising report event,
+// returning the data following it.
+func (r *BluetoothLEAdvertisingReport) decodeExtended(data []byte) ([]byte, error) {
+	if len(data) < 24 {
+		return nil, errors.New("Bluetooth LE extended advertising report truncated")
+	}
+	r.Extended = true
+	r.EventType = binary.LittleEndian.Uint16(data[0:2])
+	r.AddressType = data[2]
+	r.Address = bluetoothAddress(data[3:9])
+	r.PrimaryPHY = data[9]
+	r.SecondaryPHY = data[10]
+	r.AdvertisingSID = data[11]
+	r.TxPower = int8(data[12])
+	r.RSSI = int8(data[13])
+	r.PeriodicAdvertisingInterval = binary.LittleEndian.Uint16(data[14:16])
+	r.DirectAddressType = data[16]
+	r.DirectAddress = bluetoothAddress(data[17:23])
+	length := int(data[23])
+	if 24+length > len(data) {
+		return nil, errors.New("Bluetooth LE extended advertising report truncated")
+	}
+	r.Data = data[24 : 24+length]
+	var err error
+	r.AD, err = decodeBluetoothAD(r.Data)
+	return data[24+length:], err
+}
@@ -0,0 +1,203 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketBluetoothLESetScanEnable is an LE Set Scan Enable command sent
+// to the controller, as captured by btmon.
+var testPacketBluetoothLESetScanEnable = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x01, 0x0c, 0x20, 0x02, 0x01, 0x00,
+}
+
+// testPacketBluetoothLEAdvertisingReport is an LE advertising report of an
+// ADV_IND from a device with random address 11:22:33:44:55:66, named
+// "Test", at -59 dBm.
+var testPacketBluetoothLEAdvertisingReport = []byte{
+	0x00, 0x00, 0x00, 0x01, 0x04, 0x3e, 0x1b, 0x02, 0x01, 0x00, 0x01, 0x66,
+	0x55, 0x44, 0x33, 0x22, 0x11, 0x0f, 0x02, 0x01, 0x06, 0x05, 0x09, 0x54,
+	0x65, 0x73, 0x74, 0x05, 0xff, 0x4c, 0x00, 0x01, 0x02, 0xc5,
+}
+
+// testPacketBluetoothLEExtendedAdvertisingReport is an LE extended
+// advertising report of a legacy ADV_IND from public address
+// 11:22:33:44:55:66, at -64 dBm.
+var testPacketBluetoothLEExtendedAdvertisingReport = []byte{
+	0x00, 0x00, 0x00, 0x01, 0x04, 0x3e, 0x1d, 0x0d, 0x01, 0x13, 0x00, 0x00,
+	0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x01, 0x00, 0xff, 0x7f, 0xc0, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x02, 0x01, 0x06,
+}
+
+// testPacketBluetoothATTNotification is a handle value notification of
+// handle 0x002a, received on connection handle 0x0040.
+var testPacketBluetoothATTNotification = []byte{
+	0x00, 0x00, 0x00, 0x01, 0x02, 0x40, 0x20, 0x09, 0x00, 0x05, 0x00, 0x04,
+	0x00, 0x1b, 0x2a, 0x00, 0x64, 0x00,
+}
+
+func TestPacketBluetoothHCICommand(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBluetoothLESetScanEnable, LinkTypeBluetoothHCIH4WithPHDR, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothHCIH4, LayerTypeBluetoothHCICommand}, t)
+	h := p.Layer(LayerTypeBluetoothHCIH4).(*BluetoothHCIH4)
+	if h.Received() || h.Direction != BluetoothHCIDirectionSent || h.PacketType != BluetoothHCIPacketTypeCommand {
+		t.Errorf("bad H4 header %+v", h)
+	}
+	c := p.Layer(LayerTypeBluetoothHCICommand).(*BluetoothHCICommand)
+	if c.Opcode.OGF() != 0x08 || c.Opcode.OCF() != 0x000c || !bytes.Equal(c.Parameters, []byte{1, 0}) {
+		t.Errorf("bad command %+v", c)
+	}
+	testSerialization(t, p, testPacketBluetoothLESetScanEnable)
+}
+
+func TestPacketBluetoothLEAdvertisingReport(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBluetoothLEAdvertisingReport, LinkTypeBluetoothHCIH4WithPHDR, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothHCIH4, LayerTypeBluetoothHCIEvent}, t)
+	if !p.Layer(LayerTypeBluetoothHCIH4).(*BluetoothHCIH4).Received() {
+		t.Error("event not received")
+	}
+	e := p.Layer(LayerTypeBluetoothHCIEvent).(*BluetoothHCIEvent)
+	if e.EventCode != BluetoothHCIEventLEMeta || e.LESubeventCode != BluetoothLESubeventAdvertisingReport {
+		t.Errorf("bad event %+v", e)
+	}
+	want := []BluetoothLEAdvertisingReport{{
+		EventType:   0,
+		AddressType: 1,
+		Address:     net.HardwareAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66},
+		Data:        testPacketBluetoothLEAdvertisingReport[18:33],
+		AD: []BluetoothADStructure{
+			{Type: BluetoothADFlags, Data: []byte{0x06}},
+			{Type: BluetoothADCompleteLocalName, Data: []byte("Test")},
+			{Type: BluetoothADManufacturerSpecific, Data: []byte{0x4c, 0x00, 0x01, 0x02}},
+		},
+		RSSI: -59,
+	}}
+	if !reflect.DeepEqual(e.AdvertisingReports, want) {
+		t.Errorf("reports mismatch:\ngot  %+v\nwant %+v", e.AdvertisingReports, want)
+	}
+	if name := e.AdvertisingReports[0].LocalName(); name != "Test" {
+		t.Errorf("local name %q, want Test", name)
+	}
+	testSerialization(t, p, testPacketBluetoothLEAdvertisingReport)
+}
+
+func TestPacketBluetoothLEExtendedAdvertisingReport(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBluetoothLEExtendedAdvertisingReport, LinkTypeBluetoothHCIH4WithPHDR, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	e := p.Layer(LayerTypeBluetoothHCIEvent).(*BluetoothHCIEvent)
+	if e.LESubeventCode != BluetoothLESubeventExtendedAdvertisingReport || len(e.AdvertisingReports) != 1 {
+		t.Fatalf("bad event %+v", e)
+	}
+	r := e.AdvertisingReports[0]
+	if !r.Extended || r.EventType != 0x13 || r.RSSI != -64 || r.TxPower != 127 || r.PrimaryPHY != 1 || r.AdvertisingSID != 0xff {
+		t.Errorf("bad report %+v", r)
+	}
+	if r.Address.String() != "11:22:33:44:55:66" || len(r.AD) != 1 || r.AD[0].Type != BluetoothADFlags {
+		t.Errorf("bad report address or data %+v", r)
+	}
+	testSerialization(t, p, testPacketBluetoothLEExtendedAdvertisingReport)
+}
+
+func TestPacketBluetoothATT(t *testing.T) {
+	p := gopacket.NewPacket(testPacketBluetoothATTNotification, LinkTypeBluetoothHCIH4WithPHDR, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothHCIH4, LayerTypeBluetoothHCIACL, LayerTypeBluetoothL2CAP, LayerTypeBluetoothATT}, t)
+	acl := p.Layer(LayerTypeBluetoothHCIACL).(*BluetoothHCIACL)
+	if acl.Handle != 0x0040 || acl.PacketBoundary != 2 || acl.Length != 9 {
+		t.Errorf("bad ACL header %+v", acl)
+	}
+	l2cap := p.Layer(LayerTypeBluetoothL2CAP).(*BluetoothL2CAP)
+	if l2cap.CID != BluetoothL2CAPCIDATT || l2cap.Length != 5 || l2cap.Fragmented() {
+		t.Errorf("bad L2CAP header %+v", l2cap)
+	}
+	att := p.ApplicationLayer().(*BluetoothATT)
+	if att.Opcode != BluetoothATTHandleValueNotification || att.Opcode.Command() || !bytes.Equal(att.Parameters, []byte{0x2a, 0x00, 0x64, 0x00}) {
+		t.Errorf("bad ATT PDU %+v", att)
+	}
+	testSerialization(t, p, testPacketBluetoothATTNotification)
+}
+
+func TestPacketBluetoothACLFragments(t *testing.T) {
+	// The first fragment of a 7 byte ATT PDU, then the rest.
+	first := []byte{0x02, 0x40, 0x20, 0x08, 0x00, 0x07, 0x00, 0x04, 0x00, 0x52, 0x2a, 0x00, 0x01}
+	p := gopacket.NewPacket(first, LinkTypeBluetoothHCIH4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothHCIH4, LayerTypeBluetoothHCIACL, LayerTypeBluetoothL2CAP, gopacket.LayerTypePayload}, t)
+	if !p.Layer(LayerTypeBluetoothL2CAP).(*BluetoothL2CAP).Fragmented() {
+		t.Error("L2CAP PDU not fragmented")
+	}
+
+	rest := []byte{0x02, 0x40, 0x10, 0x03, 0x00, 0x02, 0x03, 0x04}
+	p = gopacket.NewPacket(rest, LinkTypeBluetoothHCIH4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeBluetoothHCIH4, LayerTypeBluetoothHCIACL, gopacket.LayerTypePayload}, t)
+	testSerialization(t, p, rest)
+}
+
+func TestBluetoothMalformed(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		l    gopacket.DecodingLayer
+		data []byte
+	}{
+		{"short pseudo-header", &BluetoothHCIH4{PseudoHeader: true}, []byte{0, 0, 0, 1}},
+		{"short command", &BluetoothHCICommand{}, []byte{0x0c, 0x20}},
+		{"command parameters truncated", &BluetoothHCICommand{}, []byte{0x0c, 0x20, 0x02, 0x01}},
+		{"event parameters truncated", &BluetoothHCIEvent{}, testPacketBluetoothLEAdvertisingReport[5:33]},
+		{"advertising report truncated", &BluetoothHCIEvent{}, []byte{0x3e, 0x05, 0x02, 0x01, 0x00, 0x01, 0x66}},
+		{"AD structure truncated", &BluetoothHCIEvent{}, []byte{0x3e, 0x0d, 0x02, 0x01, 0x00, 0x01, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x02, 0x05, 0x09, 0xc5}},
+		{"extended report truncated", &BluetoothHCIEvent{}, testPacketBluetoothLEExtendedAdvertisingReport[5:20]},
+		{"ACL length too long", &BluetoothHCIACL{}, []byte{0x40, 0x20, 0x09, 0x00, 0x05, 0x00}},
+		{"short L2CAP header", &BluetoothL2CAP{}, []byte{0x05, 0x00, 0x04}},
+	} {
+		if err := c.l.DecodeFromBytes(c.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
+
+func TestBluetoothLongParameters(t *testing.T) {
+	params := bytes.Repeat([]byte{0xaa}, 0xff)
+	c := &BluetoothHCICommand{}
+	if err := c.DecodeFromBytes(append([]byte{0x0c, 0x20, 0xff}, params...), gopacket.NilDecodeFeedback); err != nil {
+		t.Error("Failed to decode command:", err)
+	} else if c.ParameterLength != 0xff || !bytes.Equal(c.Parameters, params) || len(c.LayerPayload()) != 0 {
+		t.Errorf("Got command %+v", c)
+	}
+	if err := c.DecodeFromBytes(append([]byte{0x0c, 0x20, 0xff}, params[:0xfe]...), gopacket.NilDecodeFeedback); err == nil {
+		t.Error("Decoded truncated command")
+	}
+	e := &BluetoothHCIEvent{}
+	if err := e.DecodeFromBytes(append([]byte{0x0e, 0xff}, params...), gopacket.NilDecodeFeedback); err != nil {
+		t.Error("Failed to decode event:", err)
+	} else if e.ParameterLength != 0xff || !bytes.Equal(e.Parameters, params) || len(e.LayerPayload()) != 0 {
+		t.Errorf("Got event %+v", e)
+	}
+	if err := e.DecodeFromBytes(append([]byte{0x0e, 0xff}, params[:0xfe]...), gopacket.NilDecodeFeedback); err == nil {
+		t.Error("Decoded truncated event")
+	}
+}
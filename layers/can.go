@@ -0,0 +1,230 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/gopacket"
+)
+
+// CANIDByteOrder is the byte order of the CAN ID of CAN frames.  Frames of
+// LINKTYPE_CAN_SOCKETCAN captures have it in network byte order, but
+// SocketCAN raw sockets use host byte order: set CANIDByteOrder to
+// binary.LittleEndian to read or write those on little-endian hosts.
+var CANIDByteOrder binary.ByteOrder = binary.BigEndian
+
+const (
+	canFlagEFF = 0x80000000
+	canFlagRTR = 0x40000000
+	canFlagERR = 0x20000000
+
+	canSFFMask = 0x000007ff
+	canEFFMask = 0x1fffffff
+
+	canMaxDataLength   = 8
+	canFDMaxDataLength = 64
+)
+
+// CANFDFlags is the flags byte of CAN FD frames.
+type CANFDFlags uint8
+
+const (
+	// CANFDFlagBRS is set if the data phase used a higher bit rate.
+	CANFDFlagBRS CANFDFlags = 0x01
+	// CANFDFlagESI is set if the sender was error passive.
+	CANFDFlagESI CANFDFlags = 0x02
+	// CANFDFlagFDF is set by newer kernels on all CAN FD frames.
+	CANFDFlagFDF CANFDFlags = 0x04
+)
+
+func (f CANFDFlags) BRS() bool { return f&CANFDFlagBRS != 0 }
+func (f CANFDFlags) ESI() bool { return f&CANFDFlagESI != 0 }
+func (f CANFDFlags) FDF() bool { return f&CANFDFlagFDF != 0 }
+
+// CANErrorClass is the error class of an error frame, a set of bits held
+// in place of the identifier.  The data bytes give the details of some
+// classes, see linux/can/error.h.
+type CANErrorClass uint32
+
+const (
+	CANErrorTxTimeout   CANErrorClass = 0x001
+	CANErrorLostArb     CANErrorClass = 0x002
+	CANErrorController  CANErrorClass = 0x004
+	CANErrorProtocol    CANErrorClass = 0x008
+	CANErrorTransceiver CANErrorClass = 0x010
+	CANErrorNoAck       CANErrorClass = 0x020
+	CANErrorBusOff      CANErrorClass = 0x040
+	CANErrorBusError    CANErrorClass = 0x080
+	CANErrorRestarted   CANErrorClass = 0x100
+	CANErrorCounter     CANErrorClass = 0x200
+)
+
+var canErrorClassNames = []struct {
+	class CANErrorClass
+	name  string
+}{
+	{CANErrorTxTimeout, "TxTimeout"},
+	{CANErrorLostArb, "LostArbitration"},
+	{CANErrorController, "Controller"},
+	{CANErrorProtocol, "Protocol"},
+	{CANErrorTransceiver, "Transceiver"},
+	{CANErrorNoAck, "NoAck"},
+	{CANErrorBusOff, "BusOff"},
+	{CANErrorBusError, "BusError"},
+	{CANErrorRestarted, "Restarted"},
+	{CANErrorCounter, "Counter"},
+}
+
+func (c CANErrorClass) String() string {
+	var names []string
+	for _, n := range canErrorClassNames {
+		if c&n.class != 0 {
+			names = append(names, n.name)
+			c &^= n.class
+		}
+	}
+	if c != 0 {
+		names = append(names, fmt.Sprintf("Unknown(%#x)", uint32(c)))
+	}
+	return strings.Join(names, "|")
+}
+
+// CAN is a SocketCAN frame, as struct can_frame or, for CAN FD, struct
+// canfd_frame of linux/can.h.  The data bytes are the payload; the padding
+// up to the 8 or 64 bytes of the data field isn't.
+type CAN struct {
+	BaseLayer
+	// ID is the 11 bit identifier, or the 29 bit one of extended frames.
+	// For error frames, it holds the error class instead, see ErrorClass.
+	ID            uint32
+	Extended      bool
+	RemoteRequest bool
+	Error         bool
+	// Length is the number of data bytes, the DLC of classic frames.
+	Length uint8
+	// FD is set for CAN FD frames, which are told apart by their size, by
+	// a length over 8 or by CANFDFlagFDF.
+	FD      bool
+	FDFlags CANFDFlags
+	// Len8DLC is the DLC of classic frames with 8 data bytes and a DLC of 9
+	// to 15, and 0 otherwise.
+	Len8DLC uint8
+}
+
+// LayerType returns LayerTypeCAN.
+func (c *CAN) LayerType() gopacket.LayerType { return LayerTypeCAN }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *CAN) CanDecode() gopacket.LayerClass { return LayerTypeCAN }
+
+// NextLayerType returns gopacket.LayerTypePayload.
+func (c *CAN) NextLayerType() gopacket.LayerType { return gopacket.LayerTypePayload }
+
+// ErrorClass returns the error class of error frames, and 0 for others.
+func (c *CAN) ErrorClass() CANErrorClass {
+	if !c.Error {
+		return 0
+	}
+	return CANErrorClass(c.ID)
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *CAN) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		df.SetTruncated()
+		return errors.New("CAN frame too short")
+	}
+	id := CANIDByteOrder.Uint32(data[0:4])
+	c.Extended = id&canFlagEFF != 0
+	c.RemoteRequest = id&canFlagRTR != 0
+	c.Error = id&canFlagERR != 0
+	if c.Extended || c.Error {
+		c.ID = id & canEFFMask
+	} else {
+		c.ID = id & canSFFMask
+	}
+	c.Length = data[4]
+	c.FD = len(data) == 8+canFDMaxDataLength || c.Length > canMaxDataLength || CANFDFlags(data[5])&CANFDFlagFDF != 0
+	c.FDFlags, c.Len8DLC = 0, 0
+	if c.FD {
+		c.FDFlags = CANFDFlags(data[5])
+		if c.Length > canFDMaxDataLength {
+			return fmt.Errorf("invalid CAN FD length %d", c.Length)
+		}
+	} else {
+		c.Len8DLC = data[7]
+	}
+	if 8+int(c.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("CAN length %d exceeds %d bytes", c.Length, len(data)-8)
+	}
+	c.BaseLayer = BaseLayer{data[:8], data[8 : 8+c.Length]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// The payload is padded to the 8 or, for CAN FD, 64 bytes of the data
+// field, making frames ready to be written to a SocketCAN raw socket.
+func (c *CAN) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := len(b.Bytes())
+	max := canMaxDataLength
+	if c.FD {
+		max = canFDMaxDataLength
+	}
+	if length > max {
+		return fmt.Errorf("CAN payload of %d bytes too long", length)
+	}
+	if opts.FixLengths {
+		c.Length = uint8(length)
+	}
+	padding, err := b.AppendBytes(max - length)
+	if err != nil {
+		return err
+	}
+	zero(padding)
+
+	bytes, err := b.PrependBytes(8)
+	if err != nil {
+		return err
+	}
+	id := c.ID & canSFFMask
+	if c.Extended || c.Error {
+		id = c.ID & canEFFMask
+	}
+	if c.Extended {
+		id |= canFlagEFF
+	}
+	if c.RemoteRequest {
+		id |= canFlagRTR
+	}
+	if c.Error {
+		id |= canFlagERR
+	}
+	CANIDByteOrder.PutUint32(bytes[0:4], id)
+	bytes[4] = c.Length
+	bytes[5] = 0
+	bytes[6] = 0
+	bytes[7] = 0
+	if c.FD {
+		bytes[5] = uint8(c.FDFlags)
+	} else {
+		bytes[7] = c.Len8DLC
+	}
+	return nil
+}
+
+func decodeCAN(data []byte, p gopacket.PacketBuilder) error {
+	c := &CAN{}
+	return decodingLayerDecoder(c, data, p)
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketCAN is a classic frame with identifier 0x123 and 8 data bytes,
+// as captured on can0.
+var testPacketCAN = []byte{
+	0x00, 0x00, 0x01, 0x23, 0x08, 0x00, 0x00, 0x00,
+	0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88,
+}
+
+// testPacketCANRemote is a remote request with the extended identifier
+// 0x18daf110.
+var testPacketCANRemote = []byte{
+	0xd8, 0xda, 0xf1, 0x10, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// testPacketCANFD is a CAN FD frame with identifier 0x456, a switched bit
+// rate and 12 data bytes, padded to 64.
+var testPacketCANFD = []byte{
+	0x00, 0x00, 0x04, 0x56, 0x0c, 0x05, 0x00, 0x00,
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// testPacketCANError is an error frame reporting controller problems, a
+// receive error warning, and bus off, with error counters 0 and 96.
+var testPacketCANError = []byte{
+	0x20, 0x00, 0x00, 0x44, 0x08, 0x00, 0x00, 0x00,
+	0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x60,
+}
+
+func TestPacketCAN(t *testing.T) {
+	p := gopacket.NewPacket(testPacketCAN, LinkTypeCANSocketCAN, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeCAN, gopacket.LayerTypePayload}, t)
+	c := p.Layer(LayerTypeCAN).(*CAN)
+	if c.ID != 0x123 || c.Extended || c.RemoteRequest || c.Error || c.FD || c.Length != 8 {
+		t.Errorf("bad frame %+v", c)
+	}
+	if want := testPacketCAN[8:]; !bytes.Equal(c.Payload, want) {
+		t.Errorf("data %x, want %x", c.Payload, want)
+	}
+	testSerialization(t, p, testPacketCAN)
+}
+
+func TestPacketCANRemote(t *testing.T) {
+	p := gopacket.NewPacket(testPacketCANRemote, LinkTypeCANSocketCAN, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeCAN}, t)
+	c := p.Layer(LayerTypeCAN).(*CAN)
+	if c.ID != 0x18daf110 || !c.Extended || !c.RemoteRequest || c.Error || c.Length != 0 {
+		t.Errorf("bad frame %+v", c)
+	}
+	testSerialization(t, p, testPacketCANRemote)
+}
+
+func TestPacketCANFD(t *testing.T) {
+	p := gopacket.NewPacket(testPacketCANFD, LinkTypeCANSocketCAN, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeCAN, gopacket.LayerTypePayload}, t)
+	c := p.Layer(LayerTypeCAN).(*CAN)
+	if c.ID != 0x456 || !c.FD || !c.FDFlags.BRS() || c.FDFlags.ESI() || c.Length != 12 {
+		t.Errorf("bad frame %+v", c)
+	}
+	if want := testPacketCANFD[8:20]; !bytes.Equal(c.Payload, want) {
+		t.Errorf("data %x, want %x", c.Payload, want)
+	}
+	testSerialization(t, p, testPacketCANFD)
+}
+
+func TestPacketCANError(t *testing.T) {
+	p := gopacket.NewPacket(testPacketCANError, LinkTypeCANSocketCAN, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	c := p.Layer(LayerTypeCAN).(*CAN)
+	if !c.Error || c.ErrorClass() != CANErrorController|CANErrorBusOff {
+		t.Errorf("bad error frame %+v", c)
+	}
+	if got, want := c.ErrorClass().String(), "Controller|BusOff"; got != want {
+		t.Errorf("error class %q, want %q", got, want)
+	}
+	testSerialization(t, p, testPacketCANError)
+}
+
+func TestCANHostByteOrder(t *testing.T) {
+	defer func(o binary.ByteOrder) { CANIDByteOrder = o }(CANIDByteOrder)
+	CANIDByteOrder = binary.LittleEndian
+
+	buf := gopacket.NewSerializeBuffer()
+	c := &CAN{ID: 0x18daf110, Extended: true}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, c, gopacket.Payload{0x02, 0x10, 0x03}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x10, 0xf1, 0xda, 0x98, 0x03, 0x00, 0x00, 0x00,
+		0x02, 0x10, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got %x, want %x", buf.Bytes(), want)
+	}
+	var d CAN
+	if err := d.DecodeFromBytes(want, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if d.ID != c.ID || !d.Extended || d.Length != 3 {
+		t.Errorf("bad frame %+v", d)
+	}
+}
+
+func TestCANMalformed(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"short header", testPacketCAN[:7]},
+		{"data truncated", testPacketCAN[:15]},
+		{"FD length too long", append([]byte{0, 0, 1, 0x23, 65, 0x04, 0, 0}, make([]byte, 64)...)},
+	} {
+		var can CAN
+		if err := can.DecodeFromBytes(c.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
@@ -13,8 +13,10 @@ package layers
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
+	"sort"
 
 	"github.com/google/gopacket"
 )
@@ -99,6 +101,32 @@ type CDPCapabilities struct {
 	RemotelyManaged bool
 }
 
+// Encode returns the value of a Capabilities TLV for c.
+func (c CDPCapabilities) Encode() []byte {
+	var caps CDPCapability
+	for _, b := range []struct {
+		set bool
+		cap CDPCapability
+	}{
+		{c.L3Router, CDPCapMaskRouter},
+		{c.TBBridge, CDPCapMaskTBBridge},
+		{c.SPBridge, CDPCapMaskSPBridge},
+		{c.L2Switch, CDPCapMaskSwitch},
+		{c.IsHost, CDPCapMaskHost},
+		{c.IGMPFilter, CDPCapMaskIGMPFilter},
+		{c.L1Repeater, CDPCapMaskRepeater},
+		{c.IsPhone, CDPCapMaskPhone},
+		{c.RemotelyManaged, CDPCapMaskRemote},
+	} {
+		if b.set {
+			caps |= b.cap
+		}
+	}
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(caps))
+	return value
+}
+
 // CDP Power-over-Ethernet values.
 const (
 	CDPPoEFourWire  byte = 0x01
@@ -115,12 +143,38 @@ type CDPSparePairPoE struct {
 	PSEOn        bool // On / Off
 }
 
+// Encode returns the value of a Spare Pair PoE TLV for p.
+func (p CDPSparePairPoE) Encode() byte {
+	var v byte
+	if p.PSEFourWire {
+		v |= CDPPoEFourWire
+	}
+	if p.PDArchShared {
+		v |= CDPPoEPDArch
+	}
+	if p.PDRequestOn {
+		v |= CDPPoEPDRequest
+	}
+	if p.PSEOn {
+		v |= CDPPoEPSE
+	}
+	return v
+}
+
 // CDPVLANDialogue encapsulates a VLAN Query/Reply
 type CDPVLANDialogue struct {
 	ID   uint8
 	VLAN uint16
 }
 
+// Encode returns the value of a VLAN Query or Reply TLV for d.
+func (d CDPVLANDialogue) Encode() []byte {
+	value := make([]byte, 3)
+	value[0] = d.ID
+	binary.BigEndian.PutUint16(value[1:3], d.VLAN)
+	return value
+}
+
 // CDPPowerDialogue encapsulates a Power Query/Reply
 type CDPPowerDialogue struct {
 	ID     uint16
@@ -128,6 +182,17 @@ type CDPPowerDialogue struct {
 	Values []uint32
 }
 
+// Encode returns the value of a Power Requested or Available TLV for d.
+func (d CDPPowerDialogue) Encode() []byte {
+	value := make([]byte, 4+4*len(d.Values))
+	binary.BigEndian.PutUint16(value[0:2], d.ID)
+	binary.BigEndian.PutUint16(value[2:4], d.MgmtID)
+	for i, v := range d.Values {
+		binary.BigEndian.PutUint32(value[4+4*i:], v)
+	}
+	return value
+}
+
 // CDPLocation provides location information for a CDP device.
 type CDPLocation struct {
 	Type     uint8 // Undocumented
@@ -218,6 +283,10 @@ func (c *CiscoDiscovery) LayerType() gopacket.LayerType {
 }
 
 func decodeCiscoDiscovery(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) < 4 {
+		p.SetTruncated()
+		return errors.New("CiscoDiscovery packet too short")
+	}
 	c := &CiscoDiscovery{
 		Version:  data[0],
 		TTL:      data[1],
@@ -237,6 +306,198 @@ func decodeCiscoDiscovery(data []byte, p gopacket.PacketBuilder) error {
 	return p.NextDecoder(gopacket.DecodeFunc(decodeCiscoDiscoveryInfo))
 }
 
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// The header is followed by Values, so a CiscoDiscoveryInfo layer isn't
+// serialized after it.
+func (c *CiscoDiscovery) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 4
+	for i := range c.Values {
+		l := 4 + len(c.Values[i].Value)
+		if l > 0xffff {
+			return fmt.Errorf("CiscoDiscovery %v value of %d bytes too long", c.Values[i].Type, len(c.Values[i].Value))
+		}
+		if opts.FixLengths {
+			c.Values[i].Length = uint16(l)
+		}
+		length += l
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = c.Version
+	bytes[1] = c.TTL
+	bytes[2] = 0
+	bytes[3] = 0
+	v := bytes[4:]
+	for _, val := range c.Values {
+		binary.BigEndian.PutUint16(v[0:2], uint16(val.Type))
+		binary.BigEndian.PutUint16(v[2:4], val.Length)
+		copy(v[4:], val.Value)
+		v = v[4+len(val.Value):]
+	}
+	if opts.ComputeChecksums {
+		c.Checksum = cdpChecksum(bytes)
+	}
+	binary.BigEndian.PutUint16(bytes[2:4], c.Checksum)
+	return nil
+}
+
+// cdpChecksum returns the checksum of a CDP packet, which is the IP checksum
+// but for packets of odd length: Cisco devices add the last byte as a
+// signed value, rather than padded with a zero byte.
+func cdpChecksum(data []byte) uint16 {
+	var csum uint32
+	length := len(data) - 1
+	for i := 0; i < length; i += 2 {
+		csum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		csum += uint32(int32(int8(data[length])))
+	}
+	for csum > 0xffff {
+		csum = (csum >> 16) + (csum & 0xffff)
+	}
+	return ^uint16(csum)
+}
+
+// ChecksumValid returns true if the checksum of the CiscoDiscovery packet
+// is correct.
+func (c *CiscoDiscovery) ChecksumValid() bool {
+	data := append(append([]byte{}, c.Contents...), c.Payload...)
+	return cdpChecksum(data) == 0
+}
+
+// CDPOption adds an optional TLV to a CiscoDiscovery created with
+// NewCiscoDiscovery.
+type CDPOption func(*CiscoDiscovery)
+
+// NewCiscoDiscovery returns a version 2 CiscoDiscovery announcing the given
+// device ID, port ID and TTL, with the TLVs added by opts in Values.  The
+// TLVs are ordered by type, as sent by IOS, and options of the same type
+// keep the order they're given in.
+func NewCiscoDiscovery(deviceID, portID string, ttl byte, opts ...CDPOption) *CiscoDiscovery {
+	c := &CiscoDiscovery{Version: 2, TTL: ttl}
+	CDPWithValue(CDPTLVDevID, []byte(deviceID))(c)
+	CDPWithValue(CDPTLVPortID, []byte(portID))(c)
+	for _, opt := range opts {
+		opt(c)
+	}
+	sort.Stable(cdpValuesByType(c.Values))
+	return c
+}
+
+type cdpValuesByType []CiscoDiscoveryValue
+
+func (v cdpValuesByType) Len() int           { return len(v) }
+func (v cdpValuesByType) Less(i, j int) bool { return v[i].Type < v[j].Type }
+func (v cdpValuesByType) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+
+// CDPWithValue adds a TLV of any type.
+func CDPWithValue(t CDPTLVType, value []byte) CDPOption {
+	return func(c *CiscoDiscovery) {
+		c.Values = append(c.Values, CiscoDiscoveryValue{Type: t, Length: uint16(4 + len(value)), Value: value})
+	}
+}
+
+// CDPWithAddresses adds an Addresses TLV of IPv4 and IPv6 addresses.
+func CDPWithAddresses(addrs ...net.IP) CDPOption {
+	return CDPWithValue(CDPTLVAddress, encodeCDPAddresses(addrs))
+}
+
+// CDPWithCapabilities adds a Capabilities TLV.
+func CDPWithCapabilities(caps CDPCapabilities) CDPOption {
+	return CDPWithValue(CDPTLVCapabilities, caps.Encode())
+}
+
+// CDPWithVersion adds a Software Version TLV.
+func CDPWithVersion(version string) CDPOption {
+	return CDPWithValue(CDPTLVVersion, []byte(version))
+}
+
+// CDPWithPlatform adds a Platform TLV.
+func CDPWithPlatform(platform string) CDPOption {
+	return CDPWithValue(CDPTLVPlatform, []byte(platform))
+}
+
+// CDPWithVTPDomain adds a VTP Management Domain TLV.
+func CDPWithVTPDomain(domain string) CDPOption {
+	return CDPWithValue(CDPTLVVTPDomain, []byte(domain))
+}
+
+// CDPWithNativeVLAN adds a Native VLAN TLV.
+func CDPWithNativeVLAN(vlan uint16) CDPOption {
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, vlan)
+	return CDPWithValue(CDPTLVNativeVLAN, value)
+}
+
+// CDPWithDuplex adds a Duplex TLV.
+func CDPWithDuplex(full bool) CDPOption {
+	value := []byte{0}
+	if full {
+		value[0] = 1
+	}
+	return CDPWithValue(CDPTLVFullDuplex, value)
+}
+
+// CDPWithVLANReply adds a VoIP VLAN Reply TLV, with which switches give
+// phones their voice VLAN.
+func CDPWithVLANReply(reply CDPVLANDialogue) CDPOption {
+	return CDPWithValue(CDPTLVVLANReply, reply.Encode())
+}
+
+// CDPWithVLANQuery adds a VoIP VLAN Query TLV.
+func CDPWithVLANQuery(query CDPVLANDialogue) CDPOption {
+	return CDPWithValue(CDPTLVVLANQuery, query.Encode())
+}
+
+// CDPWithPowerConsumption adds a Power Consumption TLV, in mW.
+func CDPWithPowerConsumption(mw uint16) CDPOption {
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, mw)
+	return CDPWithValue(CDPTLVPower, value)
+}
+
+// CDPWithExtendedTrust adds an Extended Trust Bitmap TLV.
+func CDPWithExtendedTrust(trust uint8) CDPOption {
+	return CDPWithValue(CDPTLVExtendedTrust, []byte{trust})
+}
+
+// CDPWithUntrustedCOS adds an Untrusted Port CoS TLV.
+func CDPWithUntrustedCOS(cos uint8) CDPOption {
+	return CDPWithValue(CDPTLVUntrustedCOS, []byte{cos})
+}
+
+// CDPWithSysName adds a System Name TLV.
+func CDPWithSysName(name string) CDPOption {
+	return CDPWithValue(CDPTLVSysName, []byte(name))
+}
+
+// CDPWithMgmtAddresses adds a Management Addresses TLV of IPv4 and IPv6
+// addresses.
+func CDPWithMgmtAddresses(addrs ...net.IP) CDPOption {
+	return CDPWithValue(CDPTLVMgmtAddresses, encodeCDPAddresses(addrs))
+}
+
+// CDPWithPowerRequest adds a Power Requested TLV.
+func CDPWithPowerRequest(req CDPPowerDialogue) CDPOption {
+	return CDPWithValue(CDPTLVPowerRequested, req.Encode())
+}
+
+// CDPWithPowerAvailable adds a Power Available TLV.
+func CDPWithPowerAvailable(avail CDPPowerDialogue) CDPOption {
+	return CDPWithValue(CDPTLVPowerAvailable, avail.Encode())
+}
+
+// CDPWithSparePairPoE adds a Spare Pair PoE TLV.
+func CDPWithSparePairPoE(poe CDPSparePairPoE) CDPOption {
+	return CDPWithValue(CDPTLVSparePairPOE, []byte{poe.Encode()})
+}
+
 // LayerType returns gopacket.LayerTypeCiscoDiscoveryInfo.
 func (c *CiscoDiscoveryInfo) LayerType() gopacket.LayerType {
 	return LayerTypeCiscoDiscoveryInfo
@@ -244,11 +505,15 @@ func (c *CiscoDiscoveryInfo) LayerType() gopacket.LayerType {
 
 func decodeCiscoDiscoveryTLVs(data []byte) (values []CiscoDiscoveryValue, err error) {
 	for len(data) > 0 {
+		if len(data) < 4 {
+			err = fmt.Errorf("Invalid CiscoDiscovery value of %d bytes", len(data))
+			break
+		}
 		val := CiscoDiscoveryValue{
 			Type:   CDPTLVType(binary.BigEndian.Uint16(data[:2])),
 			Length: binary.BigEndian.Uint16(data[2:4]),
 		}
-		if val.Length < 4 {
+		if val.Length < 4 || int(val.Length) > len(data) {
 			err = fmt.Errorf("Invalid CiscoDiscovery value length %d", val.Length)
 			break
 		}
@@ -518,11 +783,17 @@ func decodeAddresses(v []byte) (addresses []net.IP, err error) {
 			(prottype == CDPProtocolType802_2 && protlen != 3 && protlen != 8) { // invalid length
 			return nil, fmt.Errorf("Invalid Address Protocol length %d", protlen)
 		}
+		if len(v) < 4+protlen {
+			return nil, fmt.Errorf("Invalid Address TLV length %d", len(v))
+		}
 		plen := make([]byte, 8)
 		copy(plen[8-protlen:], v[2:2+protlen])
 		protocol := CDPAddressType(binary.BigEndian.Uint64(plen))
 		v = v[2+protlen:]
-		addrlen := binary.BigEndian.Uint16(v[0:2])
+		addrlen := int(binary.BigEndian.Uint16(v[0:2]))
+		if len(v) < 2+addrlen {
+			return nil, fmt.Errorf("Invalid Address length %d", addrlen)
+		}
 		ab := v[2 : 2+addrlen]
 		if protocol == CDPAddressTypeIPV4 && addrlen == 4 {
 			addresses = append(addresses, net.IPv4(ab[0], ab[1], ab[2], ab[3]))
@@ -539,6 +810,27 @@ func decodeAddresses(v []byte) (addresses []net.IP, err error) {
 	return
 }
 
+// encodeCDPAddresses returns the value of an Addresses or Management
+// Addresses TLV for addrs.
+func encodeCDPAddresses(addrs []net.IP) []byte {
+	value := make([]byte, 4, 4+len(addrs)*26)
+	binary.BigEndian.PutUint32(value, uint32(len(addrs)))
+	for _, a := range addrs {
+		if ip4 := a.To4(); ip4 != nil {
+			value = append(value, CDPProtocolTypeNLPID, 1, byte(CDPAddressTypeIPV4), 0, 4)
+			value = append(value, ip4...)
+		} else {
+			p := make([]byte, 8)
+			binary.BigEndian.PutUint64(p, uint64(CDPAddressTypeIPV6))
+			value = append(value, CDPProtocolType802_2, 8)
+			value = append(value, p...)
+			value = append(value, 0, 16)
+			value = append(value, a.To16()...)
+		}
+	}
+	return value
+}
+
 func (t CDPTLVType) String() (s string) {
 	switch t {
 	case CDPTLVDevID:
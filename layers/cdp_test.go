@@ -0,0 +1,156 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketCDPPhone is a CDP announcement of an IP phone asking for its
+// voice VLAN, of odd length.
+var testPacketCDPPhone = []byte{
+	0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x99, 0xaa, 0xaa,
+	0x03, 0x00, 0x00, 0x0c, 0x20, 0x00, 0x02, 0xb4, 0x9e, 0x36, 0x00, 0x01, 0x00, 0x13, 0x53, 0x45,
+	0x50, 0x30, 0x30, 0x31, 0x31, 0x32, 0x32, 0x33, 0x33, 0x34, 0x34, 0x35, 0x35, 0x00, 0x02, 0x00,
+	0x11, 0x00, 0x00, 0x00, 0x01, 0x01, 0x01, 0xcc, 0x00, 0x04, 0x0a, 0x00, 0x00, 0x05, 0x00, 0x03,
+	0x00, 0x0a, 0x50, 0x6f, 0x72, 0x74, 0x20, 0x31, 0x00, 0x04, 0x00, 0x08, 0x00, 0x00, 0x00, 0x90,
+	0x00, 0x05, 0x00, 0x14, 0x53, 0x43, 0x43, 0x50, 0x34, 0x31, 0x2e, 0x39, 0x2d, 0x34, 0x2d, 0x32,
+	0x53, 0x52, 0x31, 0x53, 0x00, 0x06, 0x00, 0x17, 0x43, 0x69, 0x73, 0x63, 0x6f, 0x20, 0x49, 0x50,
+	0x20, 0x50, 0x68, 0x6f, 0x6e, 0x65, 0x20, 0x37, 0x39, 0x36, 0x30, 0x00, 0x0b, 0x00, 0x05, 0x01,
+	0x00, 0x0f, 0x00, 0x07, 0x20, 0x00, 0x00, 0x00, 0x10, 0x00, 0x06, 0x18, 0x9c, 0x00, 0x12, 0x00,
+	0x05, 0x00, 0x00, 0x13, 0x00, 0x05, 0x00, 0x00, 0x19, 0x00, 0x10, 0x00, 0x01, 0x00, 0x00, 0x00,
+	0x00, 0x18, 0x9c, 0x00, 0x00, 0x17, 0x70,
+}
+
+// testCDPSerialization reserializes the layers of p up to CiscoDiscovery,
+// which serializes the values CiscoDiscoveryInfo is decoded from.
+func testCDPSerialization(t *testing.T, p gopacket.Packet, data []byte) {
+	var slayers []gopacket.SerializableLayer
+	for _, l := range p.Layers() {
+		if l.LayerType() == LayerTypeCiscoDiscoveryInfo {
+			break
+		}
+		slayers = append(slayers, l.(gopacket.SerializableLayer))
+	}
+	for _, opts := range []gopacket.SerializeOptions{
+		{},
+		{FixLengths: true, ComputeChecksums: true},
+	} {
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, opts, slayers...); err != nil {
+			t.Errorf("unable to reserialize layers with opts %#v: %v", opts, err)
+		} else if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("serialization failure with opts %#v:\n---want---\n%v\n---got---\n%v", opts, hex.Dump(data), hex.Dump(buf.Bytes()))
+		}
+	}
+}
+
+func TestPacketCDPPhone(t *testing.T) {
+	p := gopacket.NewPacket(testPacketCDPPhone, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLLC, LayerTypeSNAP, LayerTypeCiscoDiscovery, LayerTypeCiscoDiscoveryInfo}, t)
+	if !p.Layer(LayerTypeCiscoDiscovery).(*CiscoDiscovery).ChecksumValid() {
+		t.Error("bad checksum")
+	}
+	info := p.Layer(LayerTypeCiscoDiscoveryInfo).(*CiscoDiscoveryInfo)
+	want := &CiscoDiscoveryInfo{
+		BaseLayer:        BaseLayer{Contents: testPacketCDPPhone[26:]},
+		DeviceID:         "SEP001122334455",
+		Addresses:        []net.IP{net.IPv4(10, 0, 0, 5)},
+		PortID:           "Port 1",
+		Capabilities:     CDPCapabilities{IsHost: true, IsPhone: true},
+		Version:          "SCCP41.9-4-2SR1S",
+		Platform:         "Cisco IP Phone 7960",
+		FullDuplex:       true,
+		VLANQuery:        CDPVLANDialogue{ID: 0x20},
+		PowerConsumption: 6300,
+		PowerRequest:     CDPPowerDialogue{ID: 1, Values: []uint32{6300, 6000}},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Values mismatch, \ngot  %#v\nwant %#v\n", info, want)
+	}
+	testCDPSerialization(t, p, testPacketCDPPhone)
+}
+
+func TestNewCiscoDiscovery(t *testing.T) {
+	cdp := NewCiscoDiscovery("SEP001122334455", "Port 1", 180,
+		CDPWithPowerRequest(CDPPowerDialogue{ID: 1, Values: []uint32{6300, 6000}}),
+		CDPWithPowerConsumption(6300),
+		CDPWithVLANQuery(CDPVLANDialogue{ID: 0x20}),
+		CDPWithDuplex(true),
+		CDPWithExtendedTrust(0),
+		CDPWithUntrustedCOS(0),
+		CDPWithPlatform("Cisco IP Phone 7960"),
+		CDPWithVersion("SCCP41.9-4-2SR1S"),
+		CDPWithCapabilities(CDPCapabilities{IsHost: true, IsPhone: true}),
+		CDPWithAddresses(net.IPv4(10, 0, 0, 5)),
+	)
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		&Ethernet{SrcMAC: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, DstMAC: net.HardwareAddr{0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc}, EthernetType: EthernetTypeLLC},
+		&LLC{DSAP: 0xaa, SSAP: 0xaa, Control: 3},
+		&SNAP{OrganizationalCode: []byte{0, 0, 0x0c}, Type: EthernetTypeCiscoDiscovery},
+		cdp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketCDPPhone) {
+		t.Errorf("got\n%v\nwant\n%v", hex.Dump(buf.Bytes()), hex.Dump(testPacketCDPPhone))
+	}
+}
+
+func TestCDPAddressesRoundTrip(t *testing.T) {
+	addrs := []net.IP{net.IPv4(192, 168, 0, 253), net.ParseIP("2001:db8::1")}
+	got, err := decodeAddresses(encodeCDPAddresses(addrs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].Equal(addrs[0]) || !got[1].Equal(addrs[1]) {
+		t.Errorf("got %v, want %v", got, addrs)
+	}
+}
+
+func TestCDPChecksum(t *testing.T) {
+	// Odd lengths add the last byte as a signed value.
+	for _, c := range []struct {
+		data []byte
+		want uint16
+	}{
+		{[]byte{0x02, 0xb4, 0x00, 0x00}, ^uint16(0x02b4)},
+		{[]byte{0x02, 0xb4, 0x00, 0x00, 0x70}, ^uint16(0x0324)},
+		{[]byte{0x02, 0xb4, 0x00, 0x00, 0xfd}, ^uint16(0x02b1)},
+	} {
+		if got := cdpChecksum(c.data); got != c.want {
+			t.Errorf("checksum of %x is %#04x, want %#04x", c.data, got, c.want)
+		}
+	}
+}
+
+func TestCDPMalformed(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"short header", []byte{0x02, 0xb4}},
+		{"short value", []byte{0x02, 0xb4, 0x00, 0x00, 0x00, 0x01, 0x00}},
+		{"value length too long", []byte{0x02, 0xb4, 0x00, 0x00, 0x00, 0x01, 0x00, 0x10, 0x41}},
+		{"address truncated", []byte{0x02, 0xb4, 0x00, 0x00, 0x00, 0x02, 0x00, 0x10, 0x00, 0x00, 0x00, 0x01, 0x01, 0x01, 0xcc, 0x00, 0x08, 0x0a, 0x00, 0x00}},
+	} {
+		p := gopacket.NewPacket(c.data, LayerTypeCiscoDiscovery, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// CFMOpcode is the opcode of an IEEE 802.1ag Connectivity Fault Management
+// or ITU-T Y.1731 OAM PDU.
+type CFMOpcode uint8
+
+const (
+	CFMOpcodeCCM  CFMOpcode = 1
+	CFMOpcodeLBR  CFMOpcode = 2
+	CFMOpcodeLBM  CFMOpcode = 3
+	CFMOpcodeLTR  CFMOpcode = 4
+	CFMOpcodeLTM  CFMOpcode = 5
+	CFMOpcodeAIS  CFMOpcode = 33
+	CFMOpcodeLCK  CFMOpcode = 35
+	CFMOpcodeTST  CFMOpcode = 37
+	CFMOpcodeAPS  CFMOpcode = 39
+	CFMOpcodeRAPS CFMOpcode = 40
+	CFMOpcodeMCC  CFMOpcode = 41
+	CFMOpcodeLMR  CFMOpcode = 42
+	CFMOpcodeLMM  CFMOpcode = 43
+	CFMOpcode1DM  CFMOpcode = 45
+	CFMOpcodeDMR  CFMOpcode = 46
+	CFMOpcodeDMM  CFMOpcode = 47
+	CFMOpcodeEXR  CFMOpcode = 48
+	CFMOpcodeEXM  CFMOpcode = 49
+	CFMOpcodeVSR  CFMOpcode = 50
+	CFMOpcodeVSM  CFMOpcode = 51
+	CFMOpcodeCSF  CFMOpcode = 52
+	CFMOpcode1SL  CFMOpcode = 53
+	CFMOpcodeSLR  CFMOpcode = 54
+	CFMOpcodeSLM  CFMOpcode = 55
+)
+
+var cfmOpcodeNames = map[CFMOpcode]string{
+	CFMOpcodeCCM:  "CCM",
+	CFMOpcodeLBR:  "LBR",
+	CFMOpcodeLBM:  "LBM",
+	CFMOpcodeLTR:  "LTR",
+	CFMOpcodeLTM:  "LTM",
+	CFMOpcodeAIS:  "AIS",
+	CFMOpcodeLCK:  "LCK",
+	CFMOpcodeTST:  "TST",
+	CFMOpcodeAPS:  "APS",
+	CFMOpcodeRAPS: "R-APS",
+	CFMOpcodeMCC:  "MCC",
+	CFMOpcodeLMR:  "LMR",
+	CFMOpcodeLMM:  "LMM",
+	CFMOpcode1DM:  "1DM",
+	CFMOpcodeDMR:  "DMR",
+	CFMOpcodeDMM:  "DMM",
+	CFMOpcodeEXR:  "EXR",
+	CFMOpcodeEXM:  "EXM",
+	CFMOpcodeVSR:  "VSR",
+	CFMOpcodeVSM:  "VSM",
+	CFMOpcodeCSF:  "CSF",
+	CFMOpcode1SL:  "1SL",
+	CFMOpcodeSLR:  "SLR",
+	CFMOpcodeSLM:  "SLM",
+}
+
+func (o CFMOpcode) String() string {
+	if name, ok := cfmOpcodeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(o))
+}
+
+// CFMTLVType is the type of a CFM TLV.
+type CFMTLVType uint8
+
+const (
+	CFMTLVTypeEnd                  CFMTLVType = 0
+	CFMTLVTypeSenderID             CFMTLVType = 1
+	CFMTLVTypePortStatus           CFMTLVType = 2
+	CFMTLVTypeData                 CFMTLVType = 3
+	CFMTLVTypeInterfaceStatus      CFMTLVType = 4
+	CFMTLVTypeReplyIngress         CFMTLVType = 5
+	CFMTLVTypeReplyEgress          CFMTLVType = 6
+	CFMTLVTypeLTMEgressIdentifier  CFMTLVType = 7
+	CFMTLVTypeLTREgressIdentifier  CFMTLVType = 8
+	CFMTLVTypeOrganizationSpecific CFMTLVType = 31
+	CFMTLVTypeTest                 CFMTLVType = 32
+)
+
+// CFMTLV is a single TLV following the opcode specific fields of a CFM PDU.
+type CFMTLV struct {
+	Type  CFMTLVType
+	Value []byte
+}
+
+// CFMPortStatus is the value of a Port Status TLV.
+type CFMPortStatus uint8
+
+const (
+	CFMPortStatusBlocked CFMPortStatus = 1
+	CFMPortStatusUp      CFMPortStatus = 2
+)
+
+func (s CFMPortStatus) String() string {
+	switch s {
+	case CFMPortStatusBlocked:
+		return "Blocked"
+	case CFMPortStatusUp:
+		return "Up"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// CFMInterfaceStatus is the value of an Interface Status TLV, following the
+// ifOperStatus values of RFC 2863.
+type CFMInterfaceStatus uint8
+
+const (
+	CFMInterfaceStatusUp             CFMInterfaceStatus = 1
+	CFMInterfaceStatusDown           CFMInterfaceStatus = 2
+	CFMInterfaceStatusTesting        CFMInterfaceStatus = 3
+	CFMInterfaceStatusUnknown        CFMInterfaceStatus = 4
+	CFMInterfaceStatusDormant        CFMInterfaceStatus = 5
+	CFMInterfaceStatusNotPresent     CFMInterfaceStatus = 6
+	CFMInterfaceStatusLowerLayerDown CFMInterfaceStatus = 7
+)
+
+func (s CFMInterfaceStatus) String() string {
+	switch s {
+	case CFMInterfaceStatusUp:
+		return "Up"
+	case CFMInterfaceStatusDown:
+		return "Down"
+	case CFMInterfaceStatusTesting:
+		return "Testing"
+	case CFMInterfaceStatusUnknown:
+		return "Unknown"
+	case CFMInterfaceStatusDormant:
+		return "Dormant"
+	case CFMInterfaceStatusNotPresent:
+		return "NotPresent"
+	case CFMInterfaceStatusLowerLayerDown:
+		return "LowerLayerDown"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// Name formats of the maintenance domain and short MA names within a MAID.
+const (
+	CFMMDNameFormatNone       = 1
+	CFMMDNameFormatDNS        = 2
+	CFMMDNameFormatMACAndUint = 3
+	CFMMDNameFormatString     = 4
+
+	CFMMANameFormatPrimaryVID = 1
+	CFMMANameFormatString     = 2
+	CFMMANameFormatUint16     = 3
+	CFMMANameFormatVPNID      = 4
+	CFMMANameFormatICC        = 32
+)
+
+// CFMMAID is the 48 byte maintenance association identifier of a CCM, or
+// the MEG ID of Y.1731.
+type CFMMAID struct {
+	MDNameFormat uint8
+	// MDName is empty if MDNameFormat is CFMMDNameFormatNone.
+	MDName            []byte
+	ShortMANameFormat uint8
+	ShortMAName       []byte
+}
+
+func cfmNameString(format uint8, name []byte, md bool) string {
+	switch {
+	case md && format == CFMMDNameFormatMACAndUint && len(name) == 8:
+		return fmt.Sprintf("%v:%d", net.HardwareAddr(name[:6]), binary.BigEndian.Uint16(name[6:]))
+	case !md && (format == CFMMANameFormatPrimaryVID || format == CFMMANameFormatUint16) && len(name) == 2:
+		return strconv.Itoa(int(binary.BigEndian.Uint16(name)))
+	case !md && format == CFMMANameFormatVPNID:
+		return fmt.Sprintf("%x", name)
+	}
+	return string(name)
+}
+
+// String returns the MAID as "MDName/ShortMAName", or just the short MA name
+// if there's no MD name, as is always the case for Y.1731 ICC based MEG IDs.
+func (m *CFMMAID) String() string {
+	ma := cfmNameString(m.ShortMANameFormat, m.ShortMAName, false)
+	if m.MDNameFormat == CFMMDNameFormatNone {
+		return ma
+	}
+	return cfmNameString(m.MDNameFormat, m.MDName, true) + "/" + ma
+}
+
+func (m *CFMMAID) decodeFromBytes(data []byte) error {
+	m.MDNameFormat = data[0]
+	m.MDName = nil
+	off := 1
+	if m.MDNameFormat != CFMMDNameFormatNone {
+		if off+int(data[1]) >= len(data) {
+			return fmt.Errorf("CFM MD name length %d exceeds MAID", data[1])
+		}
+		m.MDName = data[2 : 2+int(data[1])]
+		off = 2 + int(data[1])
+	}
+	if off+2 > len(data) || off+2+int(data[off+1]) > len(data) {
+		return errors.New("CFM short MA name exceeds MAID")
+	}
+	m.ShortMANameFormat = data[off]
+	m.ShortMAName = data[off+2 : off+2+int(data[off+1])]
+	if m.ShortMANameFormat == CFMMANameFormatICC || m.ShortMANameFormat == CFMMANameFormatString {
+		m.ShortMAName = bytes.TrimRight(m.ShortMAName, "\x00")
+	}
+	return nil
+}
+
+// CFMCCM holds the fields of a continuity check message.
+type CFMCCM struct {
+	SequenceNumber uint32
+	MEPID          uint16
+	MAID           CFMMAID
+	// Y.1731 loss measurement counters.
+	TxFCf uint32
+	RxFCb uint32
+	TxFCb uint32
+}
+
+// CFMLinkTrace holds the fields of a linktrace message or reply.  OriginalMAC
+// and TargetMAC are only present in messages, and RelayAction only in
+// replies, whose TTL is the reply TTL.
+type CFMLinkTrace struct {
+	TransactionID uint32
+	TTL           uint8
+	OriginalMAC   net.HardwareAddr
+	TargetMAC     net.HardwareAddr
+	RelayAction   uint8
+}
+
+// CFMDelayMeasurement holds the timestamps of Y.1731 DMM, DMR and 1DM PDUs.
+// The backward timestamps are not present in 1DM.  Timestamps use the PTP
+// epoch; unset timestamps are the zero time of that epoch.
+type CFMDelayMeasurement struct {
+	TxTimestampf time.Time
+	RxTimestampf time.Time
+	TxTimestampb time.Time
+	RxTimestampb time.Time
+}
+
+// FrameDelay returns the two-way frame delay of a DMR received at rx, which is
+// typically RxTimestampb or the capture timestamp.  The time spent in the
+// responder is excluded if it filled in its timestamps.
+func (d *CFMDelayMeasurement) FrameDelay(rx time.Time) time.Duration {
+	delay := rx.Sub(d.TxTimestampf)
+	if d.TxTimestampb.After(d.RxTimestampf) {
+		delay -= d.TxTimestampb.Sub(d.RxTimestampf)
+	}
+	return delay
+}
+
+// CFMLossMeasurement holds the frame counters of Y.1731 LMM and LMR PDUs.
+type CFMLossMeasurement struct {
+	TxFCf uint32
+	RxFCf uint32
+	TxFCb uint32
+}
+
+// CFM is an IEEE 802.1ag Connectivity Fault Management or ITU-T Y.1731 OAM
+// PDU.  The opcode specific fields of CCM, loopback, linktrace, delay and loss
+// measurement PDUs are decoded into the matching field; the fields of other
+// opcodes are left undecoded in OpcodeData.
+type CFM struct {
+	BaseLayer
+	MDLevel        uint8
+	Version        uint8
+	Opcode         CFMOpcode
+	Flags          uint8
+	FirstTLVOffset uint8
+	OpcodeData     []byte
+
+	CCM *CFMCCM
+	// TransactionID is set for LBM and LBR PDUs.
+	TransactionID    uint32
+	LinkTrace        *CFMLinkTrace
+	DelayMeasurement *CFMDelayMeasurement
+	LossMeasurement  *CFMLossMeasurement
+	TLVs             []CFMTLV
+	PortStatus       CFMPortStatus
+	InterfaceStatus  CFMInterfaceStatus
+}
+
+// RDI returns the remote defect indication flag of a CCM.
+func (c *CFM) RDI() bool { return c.Flags&0x80 != 0 }
+
+// CCMInterval returns the transmission interval encoded in the flags of a
+// CCM, or 0 if it's invalid.
+func (c *CFM) CCMInterval() time.Duration {
+	switch c.Flags & 0x07 {
+	case 1:
+		return 3333 * time.Microsecond
+	case 2:
+		return 10 * time.Millisecond
+	case 3:
+		return 100 * time.Millisecond
+	case 4:
+		return time.Second
+	case 5:
+		return 10 * time.Second
+	case 6:
+		return time.Minute
+	case 7:
+		return 10 * time.Minute
+	}
+	return 0
+}
+
+// LayerType returns LayerTypeCFM.
+func (c *CFM) LayerType() gopacket.LayerType { return LayerTypeCFM }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *CFM) CanDecode() gopacket.LayerClass { return LayerTypeCFM }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *CFM) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// cfmMinOpcodeLength is the minimum length of the opcode specific fields of
+// the opcodes decoded by CFM.
+var cfmMinOpcodeLength = map[CFMOpcode]int{
+	CFMOpcodeCCM: 70,
+	CFMOpcodeLBM: 4,
+	CFMOpcodeLBR: 4,
+	CFMOpcodeLTM: 17,
+	CFMOpcodeLTR: 6,
+	CFMOpcodeDMM: 32,
+	CFMOpcodeDMR: 32,
+	CFMOpcode1DM: 16,
+	CFMOpcodeLMM: 12,
+	CFMOpcodeLMR: 12,
+}
+
+func cfmTimestamp(data []byte) time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint32(data[0:4])), int64(binary.BigEndian.Uint32(data[4:8]))).UTC()
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *CFM) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("CFM header less than 4 bytes")
+	}
+	c.MDLevel = data[0] >> 5
+	c.Version = data[0] & 0x1f
+	c.Opcode = CFMOpcode(data[1])
+	c.Flags = data[2]
+	c.FirstTLVOffset = data[3]
+	end := 4 + int(c.FirstTLVOffset)
+	if end > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("CFM first TLV offset %d exceeds PDU", c.FirstTLVOffset)
+	}
+	if min := cfmMinOpcodeLength[c.Opcode]; int(c.FirstTLVOffset) < min {
+		return fmt.Errorf("CFM %v first TLV offset %d, %d required", c.Opcode, c.FirstTLVOffset, min)
+	}
+	c.OpcodeData = data[4:end]
+	c.CCM, c.LinkTrace, c.DelayMeasurement, c.LossMeasurement = nil, nil, nil, nil
+	c.TransactionID = 0
+	c.PortStatus, c.InterfaceStatus = 0, 0
+	c.TLVs = c.TLVs[:0]
+
+	d := c.OpcodeData
+	switch c.Opcode {
+	case CFMOpcodeCCM:
+		c.CCM = &CFMCCM{
+			SequenceNumber: binary.BigEndian.Uint32(d[0:4]),
+			MEPID:          binary.BigEndian.Uint16(d[4:6]) & 0x1fff,
+			TxFCf:          binary.BigEndian.Uint32(d[54:58]),
+			RxFCb:          binary.BigEndian.Uint32(d[58:62]),
+			TxFCb:          binary.BigEndian.Uint32(d[62:66]),
+		}
+		if err := c.CCM.MAID.decodeFromBytes(d[6:54]); err != nil {
+			return err
+		}
+	case CFMOpcodeLBM, CFMOpcodeLBR:
+		c.TransactionID = binary.BigEndian.Uint32(d[0:4])
+	case CFMOpcodeLTM:
+		c.LinkTrace = &CFMLinkTrace{
+			TransactionID: binary.BigEndian.Uint32(d[0:4]),
+			TTL:           d[4],
+			OriginalMAC:   net.HardwareAddr(d[5:11]),
+			TargetMAC:     net.HardwareAddr(d[11:17]),
+		}
+	case CFMOpcodeLTR:
+		c.LinkTrace = &CFMLinkTrace{
+			TransactionID: binary.BigEndian.Uint32(d[0:4]),
+			TTL:           d[4],
+			RelayAction:   d[5],
+		}
+	case CFMOpcodeDMM, CFMOpcodeDMR:
+		c.DelayMeasurement = &CFMDelayMeasurement{
+			TxTimestampf: cfmTimestamp(d[0:8]),
+			RxTimestampf: cfmTimestamp(d[8:16]),
+			TxTimestampb: cfmTimestamp(d[16:24]),
+			RxTimestampb: cfmTimestamp(d[24:32]),
+		}
+	case CFMOpcode1DM:
+		c.DelayMeasurement = &CFMDelayMeasurement{
+			TxTimestampf: cfmTimestamp(d[0:8]),
+			RxTimestampf: cfmTimestamp(d[8:16]),
+		}
+	case CFMOpcodeLMM, CFMOpcodeLMR:
+		c.LossMeasurement = &CFMLossMeasurement{
+			TxFCf: binary.BigEndian.Uint32(d[0:4]),
+			RxFCf: binary.BigEndian.Uint32(d[4:8]),
+			TxFCb: binary.BigEndian.Uint32(d[8:12]),
+		}
+	}
+
+	tlvs := data[end:]
+	for len(tlvs) > 0 && CFMTLVType(tlvs[0]) != CFMTLVTypeEnd {
+		if len(tlvs) < 3 {
+			df.SetTruncated()
+			return errors.New("CFM TLV header truncated")
+		}
+		length := int(binary.BigEndian.Uint16(tlvs[1:3]))
+		if 3+length > len(tlvs) {
+			df.SetTruncated()
+			return fmt.Errorf("CFM TLV length %d exceeds PDU", length)
+		}
+		tlv := CFMTLV{Type: CFMTLVType(tlvs[0]), Value: tlvs[3 : 3+length]}
+		switch {
+		case tlv.Type == CFMTLVTypePortStatus && length == 1:
+			c.PortStatus = CFMPortStatus(tlv.Value[0])
+		case tlv.Type == CFMTLVTypeInterfaceStatus && length == 1:
+			c.InterfaceStatus = CFMInterfaceStatus(tlv.Value[0])
+		}
+		c.TLVs = append(c.TLVs, tlv)
+		tlvs = tlvs[3+length:]
+	}
+	c.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+func decodeCFM(data []byte, p gopacket.PacketBuilder) error {
+	c := &CFM{}
+	return decodingLayerDecoder(c, data, p)
+}
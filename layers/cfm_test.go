@@ -0,0 +1,127 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+var cfmTestEthernetHeader = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x35, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x89, 0x02,
+}
+
+// cfmTestCCM is a Y.1731 CCM at MEG level 5 with a 1 second interval, the RDI
+// flag set, an ICC based MEG ID, and port and interface status TLVs.
+func cfmTestCCM() []byte {
+	data := append([]byte{}, cfmTestEthernetHeader...)
+	data = append(data, 0xa0, 0x01, 0x84, 0x46) // level 5, version 0, CCM, RDI|1s, offset 70
+	data = append(data, 0x00, 0x00, 0x01, 0x00) // sequence number
+	data = append(data, 0x00, 0x2a)             // MEP ID
+	maid := make([]byte, 48)
+	copy(maid, []byte{0x01, 0x20, 0x0d, 'I', 'C', 'C', 'A', 'B', '1', '2', '3', '4', '5', '6', '7', '8'})
+	data = append(data, maid...)
+	data = append(data, make([]byte, 16)...)    // counters
+	data = append(data, 0x02, 0x00, 0x01, 0x02) // port status: up
+	data = append(data, 0x04, 0x00, 0x01, 0x07) // interface status: lower layer down
+	data = append(data, 0x00)                   // end
+	return data
+}
+
+func TestPacketCFMCCM(t *testing.T) {
+	p := gopacket.NewPacket(cfmTestCCM(), LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeCFM}, t)
+	cfm, ok := p.Layer(LayerTypeCFM).(*CFM)
+	if !ok {
+		t.Fatal("No CFM layer")
+	}
+	if cfm.MDLevel != 5 || cfm.Version != 0 || cfm.Opcode != CFMOpcodeCCM || !cfm.RDI() || cfm.CCMInterval() != time.Second {
+		t.Errorf("Unexpected CFM header %#v", cfm)
+	}
+	want := &CFMCCM{
+		SequenceNumber: 256,
+		MEPID:          42,
+		MAID: CFMMAID{
+			MDNameFormat:      CFMMDNameFormatNone,
+			ShortMANameFormat: CFMMANameFormatICC,
+			ShortMAName:       []byte("ICCAB12345678"),
+		},
+	}
+	if !reflect.DeepEqual(want, cfm.CCM) {
+		t.Errorf("CCM mismatch, \nwant %#v\ngot  %#v\n", want, cfm.CCM)
+	}
+	if s := cfm.CCM.MAID.String(); s != "ICCAB12345678" {
+		t.Errorf("Unexpected MAID string %q", s)
+	}
+	if cfm.PortStatus != CFMPortStatusUp || cfm.InterfaceStatus != CFMInterfaceStatusLowerLayerDown || len(cfm.TLVs) != 2 {
+		t.Errorf("Unexpected TLVs %v, %v, %#v", cfm.PortStatus, cfm.InterfaceStatus, cfm.TLVs)
+	}
+}
+
+func TestPacketCFMLinkTrace(t *testing.T) {
+	data := append([]byte{}, cfmTestEthernetHeader...)
+	data = append(data, 0x40, 0x05, 0x80, 0x11) // level 2, LTM, UseFDBonly, offset 17
+	data = append(data, 0x00, 0x00, 0x00, 0x09, 0x40)
+	data = append(data, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x66, 0x77, 0x88, 0x99, 0xaa)
+	data = append(data, 0x07, 0x00, 0x08, 0x00, 0x00, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00)
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	cfm := p.Layer(LayerTypeCFM).(*CFM)
+	want := &CFMLinkTrace{
+		TransactionID: 9,
+		TTL:           64,
+		OriginalMAC:   net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		TargetMAC:     net.HardwareAddr{0x00, 0x66, 0x77, 0x88, 0x99, 0xaa},
+	}
+	if cfm.MDLevel != 2 || !reflect.DeepEqual(want, cfm.LinkTrace) {
+		t.Errorf("Linktrace mismatch, \nwant %#v\ngot  %#v\n", want, cfm.LinkTrace)
+	}
+	if len(cfm.TLVs) != 1 || cfm.TLVs[0].Type != CFMTLVTypeLTMEgressIdentifier {
+		t.Errorf("Unexpected TLVs %#v", cfm.TLVs)
+	}
+}
+
+func TestPacketCFMDelayMeasurement(t *testing.T) {
+	data := append([]byte{}, cfmTestEthernetHeader...)
+	data = append(data, 0xa0, 0x2e, 0x00, 0x20)                         // DMR
+	data = append(data, 0x00, 0x00, 0x00, 0x64, 0x00, 0x00, 0x00, 0x00) // TxTimestampf 100s
+	data = append(data, 0x00, 0x00, 0x00, 0x64, 0x00, 0x0f, 0x42, 0x40) // RxTimestampf 100.001s
+	data = append(data, 0x00, 0x00, 0x00, 0x64, 0x00, 0x1e, 0x84, 0x80) // TxTimestampb 100.002s
+	data = append(data, 0x00, 0x00, 0x00, 0x64, 0x00, 0x2d, 0xc6, 0xc0) // RxTimestampb 100.003s
+	data = append(data, 0x00)
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	cfm := p.Layer(LayerTypeCFM).(*CFM)
+	dm := cfm.DelayMeasurement
+	if dm == nil {
+		t.Fatal("No delay measurement")
+	}
+	if want := time.Unix(100, 2000000).UTC(); !dm.TxTimestampb.Equal(want) {
+		t.Errorf("TxTimestampb mismatch, want %v got %v", want, dm.TxTimestampb)
+	}
+	if d := dm.FrameDelay(dm.RxTimestampb); d != 2*time.Millisecond {
+		t.Errorf("Unexpected frame delay %v", d)
+	}
+}
+
+func TestPacketCFMTruncated(t *testing.T) {
+	p := gopacket.NewPacket(cfmTestCCM()[:60], LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("Expected an error decoding a truncated CCM")
+	}
+}
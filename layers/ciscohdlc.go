@@ -0,0 +1,188 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// Cisco HDLC address field values.
+const (
+	CiscoHDLCAddressUnicast   = 0x0f
+	CiscoHDLCAddressBroadcast = 0x8f
+)
+
+// CiscoHDLCProtocolSLARP is the Cisco HDLC protocol code of SLARP.  It
+// collides with the RARP EtherType, so it's handled by CiscoHDLC itself
+// rather than through EthernetTypeMetadata.
+const CiscoHDLCProtocolSLARP EthernetType = 0x8035
+
+// CiscoHDLC is the header of Cisco HDLC framing, used on serial links
+// (DLT_C_HDLC).  Its protocol code uses EtherType values.
+type CiscoHDLC struct {
+	BaseLayer
+	Address  uint8
+	Control  uint8
+	Protocol EthernetType
+}
+
+// LayerType returns LayerTypeCiscoHDLC.
+func (c *CiscoHDLC) LayerType() gopacket.LayerType { return LayerTypeCiscoHDLC }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *CiscoHDLC) CanDecode() gopacket.LayerClass { return LayerTypeCiscoHDLC }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *CiscoHDLC) NextLayerType() gopacket.LayerType {
+	if c.Protocol == CiscoHDLCProtocolSLARP {
+		return LayerTypeSLARP
+	}
+	return c.Protocol.LayerType()
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *CiscoHDLC) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("Cisco HDLC header less than 4 bytes")
+	}
+	c.Address = data[0]
+	c.Control = data[1]
+	c.Protocol = EthernetType(binary.BigEndian.Uint16(data[2:4]))
+	c.BaseLayer = BaseLayer{data[:4], data[4:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *CiscoHDLC) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	bytes[0] = c.Address
+	bytes[1] = c.Control
+	binary.BigEndian.PutUint16(bytes[2:], uint16(c.Protocol))
+	return nil
+}
+
+func decodeCiscoHDLC(data []byte, p gopacket.PacketBuilder) error {
+	c := &CiscoHDLC{}
+	if err := c.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(c)
+	if c.Protocol == CiscoHDLCProtocolSLARP {
+		return p.NextDecoder(LayerTypeSLARP)
+	}
+	return p.NextDecoder(c.Protocol)
+}
+
+// SLARPCode is the message code of a SLARP packet.
+type SLARPCode uint32
+
+const (
+	SLARPCodeAddressRequest SLARPCode = 0
+	SLARPCodeAddressReply   SLARPCode = 1
+	SLARPCodeKeepalive      SLARPCode = 2
+)
+
+func (c SLARPCode) String() string {
+	switch c {
+	case SLARPCodeAddressRequest:
+		return "AddressRequest"
+	case SLARPCodeAddressReply:
+		return "AddressReply"
+	case SLARPCodeKeepalive:
+		return "Keepalive"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint32(c))
+	}
+}
+
+// SLARP is a Cisco Serial Line Address Resolution Protocol packet, carried
+// over Cisco HDLC.  Address and Mask are set for address requests and
+// replies, and the sequence numbers and Reliability for keepalives.
+type SLARP struct {
+	BaseLayer
+	Code         SLARPCode
+	Address      net.IP
+	Mask         net.IPMask
+	MySequence   uint32
+	YourSequence uint32
+	Reliability  uint16
+}
+
+// LayerType returns LayerTypeSLARP.
+func (s *SLARP) LayerType() gopacket.LayerType { return LayerTypeSLARP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (s *SLARP) CanDecode() gopacket.LayerClass { return LayerTypeSLARP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (s *SLARP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (s *SLARP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 14 {
+		df.SetTruncated()
+		return errors.New("SLARP packet less than 14 bytes")
+	}
+	s.Code = SLARPCode(binary.BigEndian.Uint32(data[0:4]))
+	s.Address, s.Mask = nil, nil
+	s.MySequence, s.YourSequence, s.Reliability = 0, 0, 0
+	switch s.Code {
+	case SLARPCodeAddressRequest, SLARPCodeAddressReply:
+		s.Address = net.IP(data[4:8])
+		s.Mask = net.IPMask(data[8:12])
+	case SLARPCodeKeepalive:
+		s.MySequence = binary.BigEndian.Uint32(data[4:8])
+		s.YourSequence = binary.BigEndian.Uint32(data[8:12])
+		s.Reliability = binary.BigEndian.Uint16(data[12:14])
+	default:
+		return fmt.Errorf("unknown SLARP code %d", uint32(s.Code))
+	}
+	s.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (s *SLARP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(14)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(bytes, uint32(s.Code))
+	switch s.Code {
+	case SLARPCodeAddressRequest, SLARPCodeAddressReply:
+		addr := s.Address.To4()
+		if addr == nil && s.Address != nil {
+			return fmt.Errorf("SLARP address %v is not IPv4", s.Address)
+		}
+		copy(bytes[4:8], addr)
+		copy(bytes[8:12], s.Mask)
+		bytes[12], bytes[13] = 0, 0
+	default:
+		binary.BigEndian.PutUint32(bytes[4:], s.MySequence)
+		binary.BigEndian.PutUint32(bytes[8:], s.YourSequence)
+		binary.BigEndian.PutUint16(bytes[12:], s.Reliability)
+	}
+	return nil
+}
+
+func decodeSLARP(data []byte, p gopacket.PacketBuilder) error {
+	s := &SLARP{}
+	return decodingLayerDecoder(s, data, p)
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketCiscoHDLCIPv4 is an ICMP echo request over Cisco HDLC.
+var testPacketCiscoHDLCIPv4 = []byte{
+	0x0f, 0x00, 0x08, 0x00, 0x45, 0x00, 0x00, 0x1c, 0x00, 0x01, 0x00, 0x00, 0x40, 0x01, 0xf8, 0xdd,
+	0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02, 0x08, 0x00, 0xf7, 0xfe, 0x00, 0x01, 0x00, 0x00,
+}
+
+func TestPacketCiscoHDLCIPv4(t *testing.T) {
+	p := gopacket.NewPacket(testPacketCiscoHDLCIPv4, LinkTypeC_HDLC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeCiscoHDLC, LayerTypeIPv4, LayerTypeICMPv4}, t)
+	hdlc := p.Layer(LayerTypeCiscoHDLC).(*CiscoHDLC)
+	if hdlc.Address != CiscoHDLCAddressUnicast || hdlc.Protocol != EthernetTypeIPv4 {
+		t.Errorf("Unexpected Cisco HDLC header %#v", hdlc)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	var layers []gopacket.SerializableLayer
+	for _, l := range p.Layers() {
+		layers = append(layers, l.(gopacket.SerializableLayer))
+	}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, layers...); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(testPacketCiscoHDLCIPv4, buf.Bytes()) {
+		t.Errorf("Serialization mismatch, \nwant %x\ngot  %x", testPacketCiscoHDLCIPv4, buf.Bytes())
+	}
+}
+
+func TestPacketCiscoHDLCSLARP(t *testing.T) {
+	keepalive := []byte{
+		0x8f, 0x00, 0x80, 0x35, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x04,
+		0xff, 0xff,
+	}
+	p := gopacket.NewPacket(keepalive, LinkTypeC_HDLC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeCiscoHDLC, LayerTypeSLARP}, t)
+	want := &SLARP{
+		BaseLayer:    BaseLayer{Contents: keepalive[4:]},
+		Code:         SLARPCodeKeepalive,
+		MySequence:   5,
+		YourSequence: 4,
+		Reliability:  0xffff,
+	}
+	if got := p.Layer(LayerTypeSLARP); !reflect.DeepEqual(want, got) {
+		t.Errorf("SLARP mismatch, \nwant %#v\ngot  %#v\n", want, got)
+	}
+
+	reply := []byte{
+		0x8f, 0x00, 0x80, 0x35, 0x00, 0x00, 0x00, 0x01, 0xc0, 0xa8, 0x01, 0x01, 0xff, 0xff, 0xff, 0xfc,
+		0x00, 0x00,
+	}
+	p = gopacket.NewPacket(reply, LinkTypeC_HDLC, gopacket.Default)
+	slarp, ok := p.Layer(LayerTypeSLARP).(*SLARP)
+	if !ok {
+		t.Fatal("No SLARP layer")
+	}
+	if slarp.Code != SLARPCodeAddressReply || !slarp.Address.Equal(net.IP{192, 168, 1, 1}) || slarp.Mask.String() != "fffffffc" {
+		t.Errorf("Unexpected SLARP reply %#v", slarp)
+	}
+}
@@ -690,6 +690,10 @@ func TestDecodeCiscoDiscovery(t *testing.T) {
 	if !reflect.DeepEqual(info, want) {
 		t.Errorf("Values mismatch, \ngot  %#v\nwant %#v\n", info, want)
 	}
+	if !p.Layer(LayerTypeCiscoDiscovery).(*CiscoDiscovery).ChecksumValid() {
+		t.Error("bad checksum")
+	}
+	testCDPSerialization(t, p, data)
 }
 
 func TestDecodeLinkLayerDiscovery(t *testing.T) {
@@ -729,6 +733,9 @@ func TestDecodeLinkLayerDiscovery(t *testing.T) {
 		PortID:    LLDPPortID{LLDPPortIDSubtypeIfaceName, []byte("1/1")},
 		TTL:       120,
 		BaseLayer: BaseLayer{Contents: data[14:]},
+
+		PortIDOffset: 9,
+		TTLOffset:    15,
 	}
 	lldp.Values = nil // test these in next stage
 	if !reflect.DeepEqual(lldp, want) {
@@ -784,7 +791,7 @@ func TestDecodeLinkLayerDiscovery(t *testing.T) {
 	want8023 := LLDPInfo8023{
 		LinkAggregation:    LLDPLinkAggregation{true, false, 0},
 		MACPHYConfigStatus: LLDPMACPHYConfigStatus{true, true, 0x6c00, 0x0010},
-		PowerViaMDI:        LLDPPowerViaMDI8023{true, true, true, false, 1, 0, 0, 0, 0, 0, 0},
+		PowerViaMDI:        LLDPPowerViaMDI8023{PortClassPSE: true, PSESupported: true, PSEEnabled: true, PSEPowerPair: 1},
 		MTU:                1522,
 	}
 
@@ -829,6 +836,9 @@ func TestDecodeLinkLayerDiscovery(t *testing.T) {
 		PortID:    LLDPPortID{LLDPPortIDSubtypeLocal, []byte("1")},
 		TTL:       120,
 		BaseLayer: BaseLayer{Contents: data[14:]},
+
+		PortIDOffset: 9,
+		TTLOffset:    13,
 	}
 	lldp.Values = nil // test these in next stage
 	if !reflect.DeepEqual(lldp, want) {
@@ -888,7 +898,8 @@ func TestDecodeLinkLayerDiscovery(t *testing.T) {
 				LLDPLocationAddressLine{LLDPLocationAddressTypeUnit, "R3L"},
 			},
 		}},
-		PowerViaMDI: LLDPPowerViaMDI{0, 0, LLDPPowerPriorityLow, 6500},
+		PowerViaMDI:     LLDPPowerViaMDI{0, 0, LLDPPowerPriorityLow, 6500},
+		NetworkPolicies: []LLDPNetworkPolicy{{LLDPAppTypeVoice, true, true, 50, 6, 46}},
 	}
 
 	if !reflect.DeepEqual(infoMedia, wantMedia) {
@@ -88,12 +88,21 @@ func (d *DHCPv6) LayerType() gopacket.LayerType { return LayerTypeDHCPv6 }
 
 // DecodeFromBytes decodes the given bytes into this layer.
 func (d *DHCPv6) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("DHCPv6 message too short")
+	}
 	d.BaseLayer = BaseLayer{Contents: data}
-	d.Options = d.Options[:0]
 	d.MsgType = DHCPv6MsgType(data[0])
+	d.HopCount = 0
+	d.LinkAddr, d.PeerAddr, d.TransactionID = nil, nil, nil
 
 	offset := 0
-	if d.MsgType == DHCPv6MsgTypeRelayForward || d.MsgType == DHCPv6MsgTypeRelayReply {
+	if d.isRelay() {
+		if len(data) < 34 {
+			df.SetTruncated()
+			return errors.New("DHCPv6 relay message too short")
+		}
 		d.HopCount = data[1]
 		d.LinkAddr = net.IP(data[2:18])
 		d.PeerAddr = net.IP(data[18:34])
@@ -103,30 +112,56 @@ func (d *DHCPv6) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 		offset = 4
 	}
 
-	stop := len(data)
-	for offset < stop {
-		o := DHCPv6Option{}
-		if err := o.decode(data[offset:]); err != nil {
-			return err
+	var err error
+	d.Options, err = appendDHCPv6Options(d.Options[:0], data[offset:])
+	return err
+}
+
+func (d *DHCPv6) isRelay() bool {
+	return d.MsgType == DHCPv6MsgTypeRelayForward || d.MsgType == DHCPv6MsgTypeRelayReply
+}
+
+// RelayMessage decodes the message carried in the Relay Message option of a
+// Relay-Forward or Relay-Reply message.  When a message went through several
+// relay agents the result is itself a relay message, and calling RelayMessage
+// on it returns the next one in.
+func (d *DHCPv6) RelayMessage() (*DHCPv6, error) {
+	if !d.isRelay() {
+		return nil, fmt.Errorf("DHCPv6 %s message has no relay message", d.MsgType)
+	}
+	for _, o := range d.Options {
+		if o.Code == DHCPv6OptRelayMessage {
+			inner := &DHCPv6{}
+			if err := inner.DecodeFromBytes(o.Data, gopacket.NilDecodeFeedback); err != nil {
+				return nil, err
+			}
+			return inner, nil
 		}
-		d.Options = append(d.Options, o)
-		offset += int(o.Length) + 4 // 2 from option code, 2 from option length
 	}
+	return nil, errors.New("DHCPv6 relay message option not found")
+}
 
-	return nil
+// NewDHCPv6RelayMessageOption serializes msg into a Relay Message option, to
+// be carried in a Relay-Forward or Relay-Reply message.
+func NewDHCPv6RelayMessageOption(msg *DHCPv6) (DHCPv6Option, error) {
+	buf := gopacket.NewSerializeBuffer()
+	if err := msg.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		return DHCPv6Option{}, err
+	}
+	return NewDHCPv6Option(DHCPv6OptRelayMessage, buf.Bytes()), nil
 }
 
 // Len returns the length of a DHCPv6 packet.
 func (d *DHCPv6) Len() int {
 	n := 1
-	if d.MsgType == DHCPv6MsgTypeRelayForward || d.MsgType == DHCPv6MsgTypeRelayReply {
+	if d.isRelay() {
 		n += 33
 	} else {
 		n += 3
 	}
 
 	for _, o := range d.Options {
-		n += int(o.Length) + 4 // 2 from option code, 2 from option length
+		n += len(o.Data) + 4 // 2 from option code, 2 from option length
 	}
 
 	return n
@@ -145,7 +180,7 @@ func (d *DHCPv6) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serialize
 
 	offset := 0
 	data[0] = byte(d.MsgType)
-	if d.MsgType == DHCPv6MsgTypeRelayForward || d.MsgType == DHCPv6MsgTypeRelayReply {
+	if d.isRelay() {
 		data[1] = byte(d.HopCount)
 		copy(data[2:18], d.LinkAddr.To16())
 		copy(data[18:34], d.PeerAddr.To16())
@@ -160,7 +195,7 @@ func (d *DHCPv6) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serialize
 			if err := o.encode(data[offset:], opts); err != nil {
 				return err
 			}
-			offset += int(o.Length) + 4 // 2 from option code, 2 from option length
+			offset += len(o.Data) + 4 // 2 from option code, 2 from option length
 		}
 	}
 	return nil
@@ -261,22 +296,34 @@ type DHCPv6DUID struct {
 // DecodeFromBytes decodes the given bytes into a DHCPv6DUID
 func (d *DHCPv6DUID) DecodeFromBytes(data []byte) error {
 	if len(data) < 2 {
-		return errors.New("Not enough bytes to decode: " + string(len(data)))
+		return fmt.Errorf("Not enough bytes to decode: %d", len(data))
 	}
 
 	d.Type = DHCPv6DUIDType(binary.BigEndian.Uint16(data[:2]))
-	if d.Type == DHCPv6DUIDTypeLLT || d.Type == DHCPv6DUIDTypeLL {
+	d.HardwareType, d.EnterpriseNumber, d.Time = nil, nil, nil
+	d.LinkLayerAddress, d.Identifier = nil, nil
+	switch d.Type {
+	case DHCPv6DUIDTypeLLT:
+		if len(data) < 8 {
+			return fmt.Errorf("DUID-LLT too short: %d bytes", len(data))
+		}
 		d.HardwareType = data[2:4]
-	}
-
-	if d.Type == DHCPv6DUIDTypeLLT {
 		d.Time = data[4:8]
 		d.LinkLayerAddress = net.HardwareAddr(data[8:])
-	} else if d.Type == DHCPv6DUIDTypeEN {
+	case DHCPv6DUIDTypeEN:
+		if len(data) < 6 {
+			return fmt.Errorf("DUID-EN too short: %d bytes", len(data))
+		}
 		d.EnterpriseNumber = data[2:6]
 		d.Identifier = data[6:]
-	} else { // DHCPv6DUIDTypeLL
+	case DHCPv6DUIDTypeLL:
+		if len(data) < 4 {
+			return fmt.Errorf("DUID-LL too short: %d bytes", len(data))
+		}
+		d.HardwareType = data[2:4]
 		d.LinkLayerAddress = net.HardwareAddr(data[4:])
+	default:
+		d.Identifier = data[2:]
 	}
 
 	return nil
@@ -288,18 +335,19 @@ func (d *DHCPv6DUID) Encode() []byte {
 	data := make([]byte, length)
 	binary.BigEndian.PutUint16(data[0:2], uint16(d.Type))
 
-	if d.Type == DHCPv6DUIDTypeLLT || d.Type == DHCPv6DUIDTypeLL {
+	switch d.Type {
+	case DHCPv6DUIDTypeLLT:
 		copy(data[2:4], d.HardwareType)
-	}
-
-	if d.Type == DHCPv6DUIDTypeLLT {
 		copy(data[4:8], d.Time)
 		copy(data[8:], d.LinkLayerAddress)
-	} else if d.Type == DHCPv6DUIDTypeEN {
+	case DHCPv6DUIDTypeEN:
 		copy(data[2:6], d.EnterpriseNumber)
 		copy(data[6:], d.Identifier)
-	} else {
+	case DHCPv6DUIDTypeLL:
+		copy(data[2:4], d.HardwareType)
 		copy(data[4:], d.LinkLayerAddress)
+	default:
+		copy(data[2:], d.Identifier)
 	}
 
 	return data
@@ -308,12 +356,15 @@ func (d *DHCPv6DUID) Encode() []byte {
 // Len returns the length of the DHCPv6DUID, respecting the type
 func (d *DHCPv6DUID) Len() int {
 	length := 2 // d.Type
-	if d.Type == DHCPv6DUIDTypeLLT {
+	switch d.Type {
+	case DHCPv6DUIDTypeLLT:
 		length += 2 /*HardwareType*/ + 4 /*d.Time*/ + len(d.LinkLayerAddress)
-	} else if d.Type == DHCPv6DUIDTypeEN {
+	case DHCPv6DUIDTypeEN:
 		length += 4 /*d.EnterpriseNumber*/ + len(d.Identifier)
-	} else { // LL
+	case DHCPv6DUIDTypeLL:
 		length += 2 /*d.HardwareType*/ + len(d.LinkLayerAddress)
+	default:
+		length += len(d.Identifier)
 	}
 
 	return length
@@ -325,8 +376,10 @@ func (d *DHCPv6DUID) String() string {
 		duid += fmt.Sprintf("HardwareType: %v, Time: %v, LinkLayerAddress: %v", d.HardwareType, d.Time, d.LinkLayerAddress)
 	} else if d.Type == DHCPv6DUIDTypeEN {
 		duid += fmt.Sprintf("EnterpriseNumber: %v, Identifier: %v", d.EnterpriseNumber, d.Identifier)
-	} else { // DHCPv6DUIDTypeLL
+	} else if d.Type == DHCPv6DUIDTypeLL {
 		duid += fmt.Sprintf("HardwareType: %v, LinkLayerAddress: %v", d.HardwareType, d.LinkLayerAddress)
+	} else {
+		duid += fmt.Sprintf("Identifier: %v", d.Identifier)
 	}
 	return duid
 }
@@ -12,6 +12,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/gopacket"
+	"net"
+	"time"
 )
 
 // DHCPv6Opt represents a DHCP option or parameter from RFC-3315
@@ -570,15 +572,48 @@ func (o DHCPv6Option) String() string {
 		}
 		return fmt.Sprintf("Option(%s:[%s])", o.Code, duid.String())
 	case DHCPv6OptOro:
+		codes, err := o.RequestedOptions()
+		if err != nil {
+			return fmt.Sprintf("Option(%s:INVALID)", o.Code)
+		}
 		options := ""
-		for i := 0; i < int(o.Length); i += 2 {
+		for _, option := range codes {
 			if options != "" {
 				options += ","
 			}
-			option := DHCPv6Opt(binary.BigEndian.Uint16(o.Data[i : i+2]))
 			options += option.String()
 		}
 		return fmt.Sprintf("Option(%s:[%s])", o.Code, options)
+	case DHCPv6OptElapsedTime:
+		d, err := o.ElapsedTime()
+		if err != nil {
+			return fmt.Sprintf("Option(%s:INVALID)", o.Code)
+		}
+		return fmt.Sprintf("Option(%s:%v)", o.Code, d)
+	case DHCPv6OptIANA, DHCPv6OptIAPD, DHCPv6OptIATA, DHCPv6OptIAAddr, DHCPv6OptIAPrefix,
+		DHCPv6OptStatusCode, DHCPv6OptVendorClass:
+		var v interface {
+			DecodeFromBytes([]byte) error
+			String() string
+		}
+		switch o.Code {
+		case DHCPv6OptIANA, DHCPv6OptIAPD:
+			v = &DHCPv6IA{}
+		case DHCPv6OptIATA:
+			v = &DHCPv6IATA{}
+		case DHCPv6OptIAAddr:
+			v = &DHCPv6IAAddr{}
+		case DHCPv6OptIAPrefix:
+			v = &DHCPv6IAPrefix{}
+		case DHCPv6OptStatusCode:
+			v = &DHCPv6Status{}
+		case DHCPv6OptVendorClass:
+			v = &DHCPv6VendorClass{}
+		}
+		if err := v.DecodeFromBytes(o.Data); err != nil {
+			return fmt.Sprintf("Option(%s:INVALID)", o.Code)
+		}
+		return fmt.Sprintf("Option(%s:[%s])", o.Code, v.String())
 	default:
 		return fmt.Sprintf("Option(%s:%v)", o.Code, o.Data)
 	}
@@ -608,14 +643,320 @@ func (o *DHCPv6Option) encode(b []byte, opts gopacket.SerializeOptions) error {
 }
 
 func (o *DHCPv6Option) decode(data []byte) error {
-	if len(data) < 2 {
+	if len(data) < 4 {
 		return errors.New("not enough data to decode")
 	}
 	o.Code = DHCPv6Opt(binary.BigEndian.Uint16(data[0:2]))
-	if len(data) < 3 {
-		return errors.New("not enough data to decode")
-	}
 	o.Length = binary.BigEndian.Uint16(data[2:4])
+	if len(data) < 4+int(o.Length) {
+		return fmt.Errorf("DHCPv6 option %s length %d exceeds %d remaining bytes", o.Code, o.Length, len(data)-4)
+	}
 	o.Data = data[4 : 4+o.Length]
 	return nil
 }
+
+// appendDHCPv6Options decodes a sequence of options, such as the options of a
+// message or those nested inside an IA_NA option, and appends them to opts.
+func appendDHCPv6Options(opts DHCPv6Options, data []byte) (DHCPv6Options, error) {
+	for offset := 0; offset < len(data); {
+		o := DHCPv6Option{}
+		if err := o.decode(data[offset:]); err != nil {
+			return opts, err
+		}
+		opts = append(opts, o)
+		offset += int(o.Length) + 4 // 2 from option code, 2 from option length
+	}
+	return opts, nil
+}
+
+// encodedLen returns the length of the options once encoded.
+func (o DHCPv6Options) encodedLen() int {
+	n := 0
+	for _, opt := range o {
+		n += len(opt.Data) + 4
+	}
+	return n
+}
+
+// encodeTo encodes the options into b, which must be at least encodedLen()
+// bytes long.  Option lengths are always taken from their data.
+func (o DHCPv6Options) encodeTo(b []byte) {
+	for _, opt := range o {
+		opt.encode(b, gopacket.SerializeOptions{FixLengths: true})
+		b = b[len(opt.Data)+4:]
+	}
+}
+
+// RequestedOptions decodes an Option Request option into the list of option
+// codes it requests.
+func (o DHCPv6Option) RequestedOptions() ([]DHCPv6Opt, error) {
+	if o.Code != DHCPv6OptOro {
+		return nil, fmt.Errorf("cannot decode %s option as %s", o.Code, DHCPv6OptOro)
+	}
+	if len(o.Data)%2 != 0 {
+		return nil, fmt.Errorf("invalid %s option length %d", o.Code, len(o.Data))
+	}
+	codes := make([]DHCPv6Opt, len(o.Data)/2)
+	for i := range codes {
+		codes[i] = DHCPv6Opt(binary.BigEndian.Uint16(o.Data[i*2:]))
+	}
+	return codes, nil
+}
+
+// NewDHCPv6OptionRequest constructs an Option Request option asking for the
+// given options.
+func NewDHCPv6OptionRequest(codes ...DHCPv6Opt) DHCPv6Option {
+	data := make([]byte, len(codes)*2)
+	for i, c := range codes {
+		binary.BigEndian.PutUint16(data[i*2:], uint16(c))
+	}
+	return NewDHCPv6Option(DHCPv6OptOro, data)
+}
+
+// ElapsedTime decodes an Elapsed Time option, the time since the client
+// began the current exchange.  It's carried in hundredths of a second, and
+// 0xffff means any value larger than that.
+func (o DHCPv6Option) ElapsedTime() (time.Duration, error) {
+	if o.Code != DHCPv6OptElapsedTime {
+		return 0, fmt.Errorf("cannot decode %s option as %s", o.Code, DHCPv6OptElapsedTime)
+	}
+	if len(o.Data) != 2 {
+		return 0, fmt.Errorf("invalid %s option length %d", o.Code, len(o.Data))
+	}
+	return time.Duration(binary.BigEndian.Uint16(o.Data)) * 10 * time.Millisecond, nil
+}
+
+// NewDHCPv6ElapsedTime constructs an Elapsed Time option, rounding d down to
+// hundredths of a second and capping it at 0xffff.
+func NewDHCPv6ElapsedTime(d time.Duration) DHCPv6Option {
+	hundredths := d / (10 * time.Millisecond)
+	if hundredths > 0xffff {
+		hundredths = 0xffff
+	} else if hundredths < 0 {
+		hundredths = 0
+	}
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, uint16(hundredths))
+	return NewDHCPv6Option(DHCPv6OptElapsedTime, data)
+}
+
+// DHCPv6IA is the content of an IA_NA option (RFC 3315 section 22.4) or an
+// IA_PD option (RFC 3633 section 9), which share a layout.  Addresses or
+// prefixes assigned to the identity association are carried as IAAddr or
+// IAPrefix options in Options.
+type DHCPv6IA struct {
+	IAID    uint32
+	T1      uint32
+	T2      uint32
+	Options DHCPv6Options
+}
+
+// DecodeFromBytes decodes the given bytes into a DHCPv6IA
+func (ia *DHCPv6IA) DecodeFromBytes(data []byte) error {
+	if len(data) < 12 {
+		return fmt.Errorf("IA option too short: %d bytes", len(data))
+	}
+	ia.IAID = binary.BigEndian.Uint32(data[0:4])
+	ia.T1 = binary.BigEndian.Uint32(data[4:8])
+	ia.T2 = binary.BigEndian.Uint32(data[8:12])
+	var err error
+	ia.Options, err = appendDHCPv6Options(ia.Options[:0], data[12:])
+	return err
+}
+
+// Encode encodes the DHCPv6IA in a slice of bytes
+func (ia *DHCPv6IA) Encode() []byte {
+	data := make([]byte, 12+ia.Options.encodedLen())
+	binary.BigEndian.PutUint32(data[0:4], ia.IAID)
+	binary.BigEndian.PutUint32(data[4:8], ia.T1)
+	binary.BigEndian.PutUint32(data[8:12], ia.T2)
+	ia.Options.encodeTo(data[12:])
+	return data
+}
+
+func (ia *DHCPv6IA) String() string {
+	return fmt.Sprintf("IAID: %d, T1: %d, T2: %d, Options: %s", ia.IAID, ia.T1, ia.T2, ia.Options)
+}
+
+// DHCPv6IATA is the content of an IA_TA option (RFC 3315 section 22.5), the
+// identity association for temporary addresses.
+type DHCPv6IATA struct {
+	IAID    uint32
+	Options DHCPv6Options
+}
+
+// DecodeFromBytes decodes the given bytes into a DHCPv6IATA
+func (ia *DHCPv6IATA) DecodeFromBytes(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("IA_TA option too short: %d bytes", len(data))
+	}
+	ia.IAID = binary.BigEndian.Uint32(data[0:4])
+	var err error
+	ia.Options, err = appendDHCPv6Options(ia.Options[:0], data[4:])
+	return err
+}
+
+// Encode encodes the DHCPv6IATA in a slice of bytes
+func (ia *DHCPv6IATA) Encode() []byte {
+	data := make([]byte, 4+ia.Options.encodedLen())
+	binary.BigEndian.PutUint32(data[0:4], ia.IAID)
+	ia.Options.encodeTo(data[4:])
+	return data
+}
+
+func (ia *DHCPv6IATA) String() string {
+	return fmt.Sprintf("IAID: %d, Options: %s", ia.IAID, ia.Options)
+}
+
+// DHCPv6IAAddr is the content of an IAAddr option (RFC 3315 section 22.6),
+// an address nested inside an IA_NA or IA_TA option.
+type DHCPv6IAAddr struct {
+	Address           net.IP
+	PreferredLifetime uint32
+	ValidLifetime     uint32
+	Options           DHCPv6Options
+}
+
+// DecodeFromBytes decodes the given bytes into a DHCPv6IAAddr
+func (a *DHCPv6IAAddr) DecodeFromBytes(data []byte) error {
+	if len(data) < 24 {
+		return fmt.Errorf("IAAddr option too short: %d bytes", len(data))
+	}
+	a.Address = net.IP(data[0:16])
+	a.PreferredLifetime = binary.BigEndian.Uint32(data[16:20])
+	a.ValidLifetime = binary.BigEndian.Uint32(data[20:24])
+	var err error
+	a.Options, err = appendDHCPv6Options(a.Options[:0], data[24:])
+	return err
+}
+
+// Encode encodes the DHCPv6IAAddr in a slice of bytes
+func (a *DHCPv6IAAddr) Encode() []byte {
+	data := make([]byte, 24+a.Options.encodedLen())
+	copy(data[0:16], a.Address.To16())
+	binary.BigEndian.PutUint32(data[16:20], a.PreferredLifetime)
+	binary.BigEndian.PutUint32(data[20:24], a.ValidLifetime)
+	a.Options.encodeTo(data[24:])
+	return data
+}
+
+func (a *DHCPv6IAAddr) String() string {
+	return fmt.Sprintf("Address: %v, PreferredLifetime: %d, ValidLifetime: %d, Options: %s", a.Address, a.PreferredLifetime, a.ValidLifetime, a.Options)
+}
+
+// DHCPv6IAPrefix is the content of an IAPrefix option (RFC 3633 section
+// 10), a delegated prefix nested inside an IA_PD option.
+type DHCPv6IAPrefix struct {
+	PreferredLifetime uint32
+	ValidLifetime     uint32
+	PrefixLength      uint8
+	Prefix            net.IP
+	Options           DHCPv6Options
+}
+
+// DecodeFromBytes decodes the given bytes into a DHCPv6IAPrefix
+func (p *DHCPv6IAPrefix) DecodeFromBytes(data []byte) error {
+	if len(data) < 25 {
+		return fmt.Errorf("IAPrefix option too short: %d bytes", len(data))
+	}
+	p.PreferredLifetime = binary.BigEndian.Uint32(data[0:4])
+	p.ValidLifetime = binary.BigEndian.Uint32(data[4:8])
+	p.PrefixLength = data[8]
+	p.Prefix = net.IP(data[9:25])
+	var err error
+	p.Options, err = appendDHCPv6Options(p.Options[:0], data[25:])
+	return err
+}
+
+// Encode encodes the DHCPv6IAPrefix in a slice of bytes
+func (p *DHCPv6IAPrefix) Encode() []byte {
+	data := make([]byte, 25+p.Options.encodedLen())
+	binary.BigEndian.PutUint32(data[0:4], p.PreferredLifetime)
+	binary.BigEndian.PutUint32(data[4:8], p.ValidLifetime)
+	data[8] = p.PrefixLength
+	copy(data[9:25], p.Prefix.To16())
+	p.Options.encodeTo(data[25:])
+	return data
+}
+
+func (p *DHCPv6IAPrefix) String() string {
+	return fmt.Sprintf("Prefix: %v/%d, PreferredLifetime: %d, ValidLifetime: %d, Options: %s", p.Prefix, p.PrefixLength, p.PreferredLifetime, p.ValidLifetime, p.Options)
+}
+
+// DHCPv6Status is the content of a Status Code option (RFC 3315 section
+// 22.13).
+type DHCPv6Status struct {
+	Code    DHCPv6StatusCode
+	Message string
+}
+
+// DecodeFromBytes decodes the given bytes into a DHCPv6Status
+func (s *DHCPv6Status) DecodeFromBytes(data []byte) error {
+	if len(data) < 2 {
+		return fmt.Errorf("Status Code option too short: %d bytes", len(data))
+	}
+	s.Code = DHCPv6StatusCode(binary.BigEndian.Uint16(data[0:2]))
+	s.Message = string(data[2:])
+	return nil
+}
+
+// Encode encodes the DHCPv6Status in a slice of bytes
+func (s *DHCPv6Status) Encode() []byte {
+	data := make([]byte, 2+len(s.Message))
+	binary.BigEndian.PutUint16(data[0:2], uint16(s.Code))
+	copy(data[2:], s.Message)
+	return data
+}
+
+func (s *DHCPv6Status) String() string {
+	return fmt.Sprintf("Code: %s, Message: %q", s.Code, s.Message)
+}
+
+// DHCPv6VendorClass is the content of a Vendor Class option (RFC 3315
+// section 22.16): an enterprise number and opaque vendor class data.
+type DHCPv6VendorClass struct {
+	EnterpriseNumber uint32
+	Data             [][]byte
+}
+
+// DecodeFromBytes decodes the given bytes into a DHCPv6VendorClass
+func (v *DHCPv6VendorClass) DecodeFromBytes(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("Vendor Class option too short: %d bytes", len(data))
+	}
+	v.EnterpriseNumber = binary.BigEndian.Uint32(data[0:4])
+	v.Data = v.Data[:0]
+	for data = data[4:]; len(data) > 0; {
+		if len(data) < 2 {
+			return errors.New("Vendor Class data truncated")
+		}
+		n := int(binary.BigEndian.Uint16(data[0:2]))
+		if len(data) < 2+n {
+			return fmt.Errorf("Vendor Class data length %d exceeds %d remaining bytes", n, len(data)-2)
+		}
+		v.Data = append(v.Data, data[2:2+n])
+		data = data[2+n:]
+	}
+	return nil
+}
+
+// Encode encodes the DHCPv6VendorClass in a slice of bytes
+func (v *DHCPv6VendorClass) Encode() []byte {
+	length := 4
+	for _, d := range v.Data {
+		length += 2 + len(d)
+	}
+	data := make([]byte, length)
+	binary.BigEndian.PutUint32(data[0:4], v.EnterpriseNumber)
+	offset := 4
+	for _, d := range v.Data {
+		binary.BigEndian.PutUint16(data[offset:], uint16(len(d)))
+		copy(data[offset+2:], d)
+		offset += 2 + len(d)
+	}
+	return data
+}
+
+func (v *DHCPv6VendorClass) String() string {
+	return fmt.Sprintf("EnterpriseNumber: %d, Data: %q", v.EnterpriseNumber, v.Data)
+}
@@ -8,7 +8,10 @@ package layers
 
 import (
 	"bytes"
+	"net"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/gopacket"
 )
@@ -55,6 +58,202 @@ func TestDHCPv6EncodeReply(t *testing.T) {
 	testDHCPv6Equal(t, dhcpv6, dhcpv62)
 }
 
+func TestDHCPv6SolicitAdvertise(t *testing.T) {
+	client := &DHCPv6DUID{Type: DHCPv6DUIDTypeLL, HardwareType: []byte{0, 1}, LinkLayerAddress: []byte{2, 0, 0, 0, 0, 1}}
+	server := &DHCPv6DUID{Type: DHCPv6DUIDTypeEN, EnterpriseNumber: []byte{0, 0, 0x30, 0x39}, Identifier: []byte("srv1")}
+
+	solicit := &DHCPv6{MsgType: DHCPv6MsgTypeSolicit, TransactionID: []byte{1, 2, 3}}
+	solicit.Options = DHCPv6Options{
+		NewDHCPv6Option(DHCPv6OptClientID, client.Encode()),
+		NewDHCPv6ElapsedTime(1500 * time.Millisecond),
+		NewDHCPv6OptionRequest(DHCPv6OptDNSServers, DHCPv6OptDomainList),
+		NewDHCPv6Option(DHCPv6OptIANA, (&DHCPv6IA{IAID: 1}).Encode()),
+		NewDHCPv6Option(DHCPv6OptIATA, (&DHCPv6IATA{IAID: 2}).Encode()),
+		NewDHCPv6Option(DHCPv6OptVendorClass, (&DHCPv6VendorClass{EnterpriseNumber: 12345, Data: [][]byte{[]byte("router"), []byte("v2")}}).Encode()),
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, solicit); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeDHCPv6, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeDHCPv6).(*DHCPv6)
+	testDHCPv6Equal(t, solicit, got)
+
+	duid := &DHCPv6DUID{}
+	if err := duid.DecodeFromBytes(got.Options[0].Data); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(duid, client) {
+		t.Errorf("client ID: got %v, want %v", duid, client)
+	}
+	if d, err := got.Options[1].ElapsedTime(); err != nil {
+		t.Error(err)
+	} else if d != 1500*time.Millisecond {
+		t.Errorf("elapsed time: got %v", d)
+	}
+	if codes, err := got.Options[2].RequestedOptions(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(codes, []DHCPv6Opt{DHCPv6OptDNSServers, DHCPv6OptDomainList}) {
+		t.Errorf("requested options: got %v", codes)
+	}
+	if _, err := got.Options[2].ElapsedTime(); err == nil {
+		t.Error("expected error decoding Option Request as Elapsed Time")
+	}
+	vc := &DHCPv6VendorClass{}
+	if err := vc.DecodeFromBytes(got.Options[5].Data); err != nil {
+		t.Error(err)
+	} else if vc.EnterpriseNumber != 12345 || len(vc.Data) != 2 || string(vc.Data[0]) != "router" || string(vc.Data[1]) != "v2" {
+		t.Errorf("vendor class: got %v", vc)
+	}
+
+	addr := &DHCPv6IAAddr{
+		Address:           net.ParseIP("2001:db8::100"),
+		PreferredLifetime: 3600,
+		ValidLifetime:     7200,
+		Options:           DHCPv6Options{NewDHCPv6Option(DHCPv6OptStatusCode, (&DHCPv6Status{Code: DHCPv6StatusCodeSuccess, Message: "ok"}).Encode())},
+	}
+	prefix := &DHCPv6IAPrefix{
+		PreferredLifetime: 3600,
+		ValidLifetime:     7200,
+		PrefixLength:      56,
+		Prefix:            net.ParseIP("2001:db8:100::"),
+	}
+	advertise := &DHCPv6{MsgType: DHCPv6MsgTypeAdverstise, TransactionID: []byte{1, 2, 3}}
+	advertise.Options = DHCPv6Options{
+		NewDHCPv6Option(DHCPv6OptServerID, server.Encode()),
+		NewDHCPv6Option(DHCPv6OptClientID, client.Encode()),
+		NewDHCPv6Option(DHCPv6OptIANA, (&DHCPv6IA{IAID: 1, T1: 1800, T2: 2880, Options: DHCPv6Options{NewDHCPv6Option(DHCPv6OptIAAddr, addr.Encode())}}).Encode()),
+		NewDHCPv6Option(DHCPv6OptIAPD, (&DHCPv6IA{IAID: 3, T1: 1800, T2: 2880, Options: DHCPv6Options{NewDHCPv6Option(DHCPv6OptIAPrefix, prefix.Encode())}}).Encode()),
+	}
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, advertise); err != nil {
+		t.Fatal(err)
+	}
+	p = gopacket.NewPacket(buf.Bytes(), LayerTypeDHCPv6, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got = p.Layer(LayerTypeDHCPv6).(*DHCPv6)
+	testDHCPv6Equal(t, advertise, got)
+
+	duid = &DHCPv6DUID{}
+	if err := duid.DecodeFromBytes(got.Options[0].Data); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(duid, server) {
+		t.Errorf("server ID: got %v, want %v", duid, server)
+	}
+
+	ia := &DHCPv6IA{}
+	if err := ia.DecodeFromBytes(got.Options[2].Data); err != nil {
+		t.Fatal(err)
+	}
+	if ia.IAID != 1 || ia.T1 != 1800 || ia.T2 != 2880 || len(ia.Options) != 1 {
+		t.Fatalf("IA_NA: got %v", ia)
+	}
+	gotAddr := &DHCPv6IAAddr{}
+	if err := gotAddr.DecodeFromBytes(ia.Options[0].Data); err != nil {
+		t.Fatal(err)
+	}
+	if !gotAddr.Address.Equal(addr.Address) || gotAddr.PreferredLifetime != 3600 || gotAddr.ValidLifetime != 7200 || len(gotAddr.Options) != 1 {
+		t.Errorf("IAAddr: got %v", gotAddr)
+	}
+	status := &DHCPv6Status{}
+	if err := status.DecodeFromBytes(gotAddr.Options[0].Data); err != nil {
+		t.Error(err)
+	} else if status.Code != DHCPv6StatusCodeSuccess || status.Message != "ok" {
+		t.Errorf("status: got %v", status)
+	}
+
+	ia = &DHCPv6IA{}
+	if err := ia.DecodeFromBytes(got.Options[3].Data); err != nil {
+		t.Fatal(err)
+	}
+	if ia.IAID != 3 || len(ia.Options) != 1 {
+		t.Fatalf("IA_PD: got %v", ia)
+	}
+	gotPrefix := &DHCPv6IAPrefix{}
+	if err := gotPrefix.DecodeFromBytes(ia.Options[0].Data); err != nil {
+		t.Fatal(err)
+	}
+	if !gotPrefix.Prefix.Equal(prefix.Prefix) || gotPrefix.PrefixLength != 56 || gotPrefix.ValidLifetime != 7200 || len(gotPrefix.Options) != 0 {
+		t.Errorf("IAPrefix: got %v", gotPrefix)
+	}
+}
+
+func TestDHCPv6RelayMessage(t *testing.T) {
+	inner := &DHCPv6{MsgType: DHCPv6MsgTypeSolicit, TransactionID: []byte{4, 5, 6}}
+	inner.Options = DHCPv6Options{NewDHCPv6ElapsedTime(0)}
+	innerOpt, err := NewDHCPv6RelayMessageOption(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay1 := &DHCPv6{
+		MsgType:  DHCPv6MsgTypeRelayForward,
+		LinkAddr: net.ParseIP("2001:db8:1::1"),
+		PeerAddr: net.ParseIP("fe80::1"),
+		Options:  DHCPv6Options{NewDHCPv6Option(DHCPv6OptInterfaceID, []byte("eth0")), innerOpt},
+	}
+	relay1Opt, err := NewDHCPv6RelayMessageOption(relay1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay2 := &DHCPv6{
+		MsgType:  DHCPv6MsgTypeRelayForward,
+		HopCount: 1,
+		LinkAddr: net.ParseIP("2001:db8:2::1"),
+		PeerAddr: net.ParseIP("2001:db8:1::1"),
+		Options:  DHCPv6Options{relay1Opt},
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, relay2); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeDHCPv6, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeDHCPv6).(*DHCPv6)
+	testDHCPv6Equal(t, relay2, got)
+
+	gotRelay1, err := got.RelayMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDHCPv6Equal(t, relay1, gotRelay1)
+	gotInner, err := gotRelay1.RelayMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDHCPv6Equal(t, inner, gotInner)
+	if _, err := gotInner.RelayMessage(); err == nil {
+		t.Error("expected error getting relay message of a Solicit")
+	}
+}
+
+func TestDHCPv6Malformed(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x01, 0x00},                                           // truncated header
+		{0x0c, 0x00, 0x20, 0x01},                               // truncated relay header
+		{0x01, 0x01, 0x02, 0x03, 0x00, 0x01},                   // truncated option header
+		{0x01, 0x01, 0x02, 0x03, 0x00, 0x01, 0x00, 0x08, 0x00}, // option overruns message
+	} {
+		d := &DHCPv6{}
+		if err := d.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("expected error decoding %x", data)
+		}
+	}
+	for _, data := range [][]byte{{0x00}, {0x00, 0x01, 0x00}, {0x00, 0x02, 0x00, 0x00}} {
+		duid := &DHCPv6DUID{}
+		if err := duid.DecodeFromBytes(data); err == nil {
+			t.Errorf("expected error decoding DUID %x", data)
+		}
+	}
+}
+
 func testDHCPv6Equal(t *testing.T, d1, d2 *DHCPv6) {
 	if d1.MsgType != d2.MsgType {
 		t.Errorf("expected MsgType=%s, got %s", d1.MsgType, d2.MsgType)
@@ -7,6 +7,7 @@
 package layers
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -51,24 +52,27 @@ type DNSType uint16
 
 // DNSType known values.
 const (
-	DNSTypeA     DNSType = 1  // a host address
-	DNSTypeNS    DNSType = 2  // an authoritative name server
-	DNSTypeMD    DNSType = 3  // a mail destination (Obsolete - use MX)
-	DNSTypeMF    DNSType = 4  // a mail forwarder (Obsolete - use MX)
-	DNSTypeCNAME DNSType = 5  // the canonical name for an alias
-	DNSTypeSOA   DNSType = 6  // marks the start of a zone of authority
-	DNSTypeMB    DNSType = 7  // a mailbox domain name (EXPERIMENTAL)
-	DNSTypeMG    DNSType = 8  // a mail group member (EXPERIMENTAL)
-	DNSTypeMR    DNSType = 9  // a mail rename domain name (EXPERIMENTAL)
-	DNSTypeNULL  DNSType = 10 // a null RR (EXPERIMENTAL)
-	DNSTypeWKS   DNSType = 11 // a well known service description
-	DNSTypePTR   DNSType = 12 // a domain name pointer
-	DNSTypeHINFO DNSType = 13 // host information
-	DNSTypeMINFO DNSType = 14 // mailbox or mail list information
-	DNSTypeMX    DNSType = 15 // mail exchange
-	DNSTypeTXT   DNSType = 16 // text strings
-	DNSTypeAAAA  DNSType = 28 // a IPv6 host address [RFC3596]
-	DNSTypeSRV   DNSType = 33 // server discovery [RFC2782] [RFC6195]
+	DNSTypeA     DNSType = 1   // a host address
+	DNSTypeNS    DNSType = 2   // an authoritative name server
+	DNSTypeMD    DNSType = 3   // a mail destination (Obsolete - use MX)
+	DNSTypeMF    DNSType = 4   // a mail forwarder (Obsolete - use MX)
+	DNSTypeCNAME DNSType = 5   // the canonical name for an alias
+	DNSTypeSOA   DNSType = 6   // marks the start of a zone of authority
+	DNSTypeMB    DNSType = 7   // a mailbox domain name (EXPERIMENTAL)
+	DNSTypeMG    DNSType = 8   // a mail group member (EXPERIMENTAL)
+	DNSTypeMR    DNSType = 9   // a mail rename domain name (EXPERIMENTAL)
+	DNSTypeNULL  DNSType = 10  // a null RR (EXPERIMENTAL)
+	DNSTypeWKS   DNSType = 11  // a well known service description
+	DNSTypePTR   DNSType = 12  // a domain name pointer
+	DNSTypeHINFO DNSType = 13  // host information
+	DNSTypeMINFO DNSType = 14  // mailbox or mail list information
+	DNSTypeMX    DNSType = 15  // mail exchange
+	DNSTypeTXT   DNSType = 16  // text strings
+	DNSTypeAAAA  DNSType = 28  // a IPv6 host address [RFC3596]
+	DNSTypeSRV   DNSType = 33  // server discovery [RFC2782] [RFC6195]
+	DNSTypeOPT   DNSType = 41  // OPT Pseudo-RR [RFC6891]
+	DNSTypeIXFR  DNSType = 251 // incremental zone transfer [RFC1995]
+	DNSTypeAXFR  DNSType = 252 // zone transfer
 )
 
 func (dt DNSType) String() string {
@@ -111,6 +115,12 @@ func (dt DNSType) String() string {
 		return "AAAA"
 	case DNSTypeSRV:
 		return "SRV"
+	case DNSTypeOPT:
+		return "OPT"
+	case DNSTypeIXFR:
+		return "IXFR"
+	case DNSTypeAXFR:
+		return "AXFR"
 	}
 }
 
@@ -268,6 +278,10 @@ type DNS struct {
 	Authorities []DNSResourceRecord
 	Additionals []DNSResourceRecord
 
+	// CompressNames makes SerializeTo compress names, pointing back to
+	// names and suffixes written earlier in the message [RFC1035 4.1.4].
+	CompressNames bool
+
 	// buffer for doing name decoding.  We use a single reusable buffer to avoid
 	// name decoding on a single object via multiple DecodeFromBytes calls
 	// requiring constant allocation of small byte slices.
@@ -392,6 +406,27 @@ func (d *DNS) Payload() []byte {
 	return nil
 }
 
+// OPT returns the OPT pseudo-record of the additional section, or nil if
+// there's none.
+func (d *DNS) OPT() *DNSResourceRecord {
+	for i := range d.Additionals {
+		if d.Additionals[i].Type == DNSTypeOPT {
+			return &d.Additionals[i]
+		}
+	}
+	return nil
+}
+
+// ExtendedResponseCode returns the 12 bit response code, made of the upper 8
+// bits carried by the OPT pseudo-record, if any, and ResponseCode.
+func (d *DNS) ExtendedResponseCode() uint16 {
+	rcode := uint16(d.ResponseCode)
+	if opt := d.OPT(); opt != nil {
+		rcode |= uint16(opt.EDNS.ExtendedRCode) << 4
+	}
+	return rcode
+}
+
 func b2i(b bool) int {
 	if b {
 		return 1
@@ -423,6 +458,12 @@ func recSize(rr *DNSResourceRecord) int {
 		return l
 	case DNSTypeSRV:
 		return 6 + len(rr.SRV.Name) + 2
+	case DNSTypeOPT:
+		l := len(rr.OPT) * 4
+		for _, opt := range rr.OPT {
+			l += len(opt.Data)
+		}
+		return l
 	}
 
 	return 0
@@ -431,7 +472,7 @@ func recSize(rr *DNSResourceRecord) int {
 func computeSize(recs []DNSResourceRecord) int {
 	sz := 0
 	for _, rr := range recs {
-		sz += len(rr.Name) + 12
+		sz += encodedNameLength(rr.Name) + 10
 		sz += recSize(&rr)
 	}
 	return sz
@@ -442,16 +483,38 @@ func computeSize(recs []DNSResourceRecord) int {
 func (d *DNS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
 	dsz := 0
 	for _, q := range d.Questions {
-		dsz += len(q.Name) + 6
+		dsz += encodedNameLength(q.Name) + 4
 	}
 	dsz += computeSize(d.Answers)
 	dsz += computeSize(d.Authorities)
 	dsz += computeSize(d.Additionals)
 
-	bytes, err := b.PrependBytes(12 + dsz)
+	if !d.CompressNames {
+		bytes, err := b.PrependBytes(12 + dsz)
+		if err != nil {
+			return err
+		}
+		_, err = d.encode(bytes, opts, nil)
+		return err
+	}
+	// Names compress to an unknown extent, so encode into a buffer of the
+	// uncompressed size and copy what was actually used.
+	data := make([]byte, 12+dsz)
+	n, err := d.encode(data, opts, dnsNameCompressor{})
+	if err != nil {
+		return err
+	}
+	bytes, err := b.PrependBytes(n)
 	if err != nil {
 		return err
 	}
+	copy(bytes, data[:n])
+	return nil
+}
+
+// encode writes the message to data, compressing names if c isn't nil, and
+// returns its length.
+func (d *DNS) encode(bytes []byte, opts gopacket.SerializeOptions, c dnsNameCompressor) (int, error) {
 	binary.BigEndian.PutUint16(bytes, d.ID)
 	bytes[2] = byte((b2i(d.QR) << 7) | (int(d.OpCode) << 3) | (b2i(d.AA) << 2) | (b2i(d.TC) << 1) | b2i(d.RD))
 	bytes[3] = byte((b2i(d.RA) << 7) | (int(d.Z) << 4) | int(d.ResponseCode))
@@ -469,7 +532,7 @@ func (d *DNS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 
 	off := 12
 	for _, qd := range d.Questions {
-		n := qd.encode(bytes, off)
+		n := qd.encode(bytes, off, c)
 		off += n
 	}
 
@@ -477,42 +540,56 @@ func (d *DNS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 		// done this way so we can modify DNSResourceRecord to fix
 		// lengths if requested
 		qa := &d.Answers[i]
-		n, err := qa.encode(bytes, off, opts)
+		n, err := qa.encode(bytes, off, opts, c)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		off += n
 	}
 
 	for i := range d.Authorities {
 		qa := &d.Authorities[i]
-		n, err := qa.encode(bytes, off, opts)
+		n, err := qa.encode(bytes, off, opts, c)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		off += n
 	}
 	for i := range d.Additionals {
 		qa := &d.Additionals[i]
-		n, err := qa.encode(bytes, off, opts)
+		n, err := qa.encode(bytes, off, opts, c)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		off += n
 	}
 
-	return nil
+	return off, nil
 }
 
 const maxRecursionLevel = 255
 
+// maxNameLength is the longest a name may be on the wire, length octets and
+// terminating root label included, once decompressed [RFC1035 3.1].
+const maxNameLength = 255
+
 func decodeName(data []byte, offset int, buffer *[]byte, level int) ([]byte, int, error) {
+	return decodeNameFrom(data, offset, len(data), buffer, level, len(*buffer))
+}
+
+// decodeNameFrom decodes the labels at offset, which must be below limit,
+// appending them to the name starting at nameStart in buffer.  Compression
+// pointers are only followed backwards, to before the labels they end, which
+// guarantees decoding ends.
+func decodeNameFrom(data []byte, offset, limit int, buffer *[]byte, level int, nameStart int) ([]byte, int, error) {
 	if level > maxRecursionLevel {
 		return nil, 0, errMaxRecursion
 	} else if offset >= len(data) {
 		return nil, 0, errDNSNameOffsetTooHigh
 	} else if offset < 0 {
 		return nil, 0, errDNSNameOffsetNegative
+	} else if offset >= limit {
+		return nil, 0, errDNSPointerNotBackward
 	}
 	start := len(*buffer)
 	index := offset
@@ -539,6 +616,9 @@ loop:
 			}
 			*buffer = append(*buffer, '.')
 			*buffer = append(*buffer, data[index+1:index2]...)
+			if len(*buffer)-nameStart+1 > maxNameLength {
+				return nil, 0, errDNSNameTooLong
+			}
 			index = index2
 
 		case 0xc0:
@@ -572,7 +652,7 @@ loop:
 			// decodeName is written, calling it appends the decoded name to the
 			// current buffer.  We already have the start of the buffer, then, so
 			// once this call is done buffer[start:] will contain our full name.
-			_, _, err := decodeName(data, offsetp, buffer, level+1)
+			_, _, err := decodeNameFrom(data, offsetp, offset, buffer, level+1, nameStart)
 			if err != nil {
 				return nil, 0, err
 			}
@@ -617,11 +697,11 @@ func (q *DNSQuestion) decode(data []byte, offset int, df gopacket.DecodeFeedback
 	return endq + 4, nil
 }
 
-func (q *DNSQuestion) encode(data []byte, offset int) int {
-	noff := encodeName(q.Name, data, offset)
+func (q *DNSQuestion) encode(data []byte, offset int, c dnsNameCompressor) int {
+	noff := c.encodeName(q.Name, data, offset)
 	binary.BigEndian.PutUint16(data[noff:], uint16(q.Type))
 	binary.BigEndian.PutUint16(data[noff+2:], uint16(q.Class))
-	return len(q.Name) + 6
+	return noff + 4 - offset
 }
 
 //  DNSResourceRecord
@@ -668,6 +748,12 @@ type DNSResourceRecord struct {
 
 	// Undecoded TXT for backward compatibility
 	TXT []byte
+
+	// OPT and EDNS are set for OPT pseudo-records, which carry the EDNS
+	// fields in place of the class and TTL.  These are serialized from
+	// EDNS.
+	OPT  []DNSOPT
+	EDNS DNSEDNS
 }
 
 // decode decodes the resource record, returning the total length of the record.
@@ -695,7 +781,64 @@ func (rr *DNSResourceRecord) decode(data []byte, offset int, df gopacket.DecodeF
 	return endq + 10 + int(rr.DataLength), nil
 }
 
+// encodedNameLength returns the length of name once encoded.
+func encodedNameLength(name []byte) int {
+	if len(name) == 0 {
+		return 1 // the root
+	}
+	return len(name) + 2
+}
+
+// dnsNameCompressor holds the offsets of the names and suffixes of names
+// written so far in a message, for later names to point to them.
+type dnsNameCompressor map[string]int
+
+// encodeName writes name at offset in data, ending it with a pointer to
+// the longest suffix of it written earlier, and returns the offset following
+// it.  A nil c writes it uncompressed.
+func (c dnsNameCompressor) encodeName(name []byte, data []byte, offset int) int {
+	if c == nil || len(name) == 0 {
+		return encodeName(name, data, offset)
+	}
+	for i := 0; i < len(name); {
+		suffix := string(name[i:])
+		if p, ok := c[suffix]; ok {
+			offset = encodeNameLabels(name[:i], data, offset)
+			binary.BigEndian.PutUint16(data[offset:], uint16(0xc000|p))
+			return offset + 2
+		}
+		// Pointers hold 14 bit offsets.
+		if offset+i < 0x4000 {
+			c[suffix] = offset + i
+		}
+		j := bytes.IndexByte(name[i:], '.')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	return encodeName(name, data, offset)
+}
+
+// encodeNameLabels writes the labels of prefix, the start of a name up to
+// and including a dot, at offset in data and returns the offset following
+// them.
+func encodeNameLabels(prefix []byte, data []byte, offset int) int {
+	for len(prefix) > 0 {
+		j := bytes.IndexByte(prefix, '.')
+		data[offset] = byte(j)
+		copy(data[offset+1:], prefix[:j])
+		offset += 1 + j
+		prefix = prefix[j+1:]
+	}
+	return offset
+}
+
 func encodeName(name []byte, data []byte, offset int) int {
+	if len(name) == 0 {
+		data[offset] = 0x00
+		return offset + 1
+	}
 	l := 0
 	for i := range name {
 		if name[i] == '.' {
@@ -713,28 +856,36 @@ func encodeName(name []byte, data []byte, offset int) int {
 	return offset + len(name) + 2
 }
 
-func (rr *DNSResourceRecord) encode(data []byte, offset int, opts gopacket.SerializeOptions) (int, error) {
+func (rr *DNSResourceRecord) encode(data []byte, offset int, opts gopacket.SerializeOptions, c dnsNameCompressor) (int, error) {
+
+	noff := c.encodeName(rr.Name, data, offset)
 
-	noff := encodeName(rr.Name, data, offset)
+	if rr.Type == DNSTypeOPT {
+		rr.Class, rr.TTL = rr.EDNS.classTTL()
+	}
 
 	binary.BigEndian.PutUint16(data[noff:], uint16(rr.Type))
 	binary.BigEndian.PutUint16(data[noff+2:], uint16(rr.Class))
 	binary.BigEndian.PutUint32(data[noff+4:], uint32(rr.TTL))
 
+	// Only the names of the record types of RFC 1035 may be compressed
+	// [RFC3597 4].
+	dSz := recSize(rr)
 	switch rr.Type {
 	case DNSTypeA:
 		copy(data[noff+10:], rr.IP.To4())
 	case DNSTypeAAAA:
 		copy(data[noff+10:], rr.IP)
 	case DNSTypeNS:
-		encodeName(rr.NS, data, noff+10)
+		dSz = c.encodeName(rr.NS, data, noff+10) - noff - 10
 	case DNSTypeCNAME:
-		encodeName(rr.CNAME, data, noff+10)
+		dSz = c.encodeName(rr.CNAME, data, noff+10) - noff - 10
 	case DNSTypePTR:
-		encodeName(rr.PTR, data, noff+10)
+		dSz = c.encodeName(rr.PTR, data, noff+10) - noff - 10
 	case DNSTypeSOA:
-		noff2 := encodeName(rr.SOA.MName, data, noff+10)
-		noff2 = encodeName(rr.SOA.RName, data, noff2)
+		noff2 := c.encodeName(rr.SOA.MName, data, noff+10)
+		noff2 = c.encodeName(rr.SOA.RName, data, noff2)
+		dSz = noff2 + 20 - noff - 10
 		binary.BigEndian.PutUint32(data[noff2:], rr.SOA.Serial)
 		binary.BigEndian.PutUint32(data[noff2+4:], rr.SOA.Refresh)
 		binary.BigEndian.PutUint32(data[noff2+8:], rr.SOA.Retry)
@@ -742,7 +893,7 @@ func (rr *DNSResourceRecord) encode(data []byte, offset int, opts gopacket.Seria
 		binary.BigEndian.PutUint32(data[noff2+16:], rr.SOA.Minimum)
 	case DNSTypeMX:
 		binary.BigEndian.PutUint16(data[noff+10:], rr.MX.Preference)
-		encodeName(rr.MX.Name, data, noff+12)
+		dSz = c.encodeName(rr.MX.Name, data, noff+12) - noff - 10
 	case DNSTypeTXT:
 		noff2 := noff + 10
 		for _, txt := range rr.TXTs {
@@ -755,23 +906,34 @@ func (rr *DNSResourceRecord) encode(data []byte, offset int, opts gopacket.Seria
 		binary.BigEndian.PutUint16(data[noff+12:], rr.SRV.Weight)
 		binary.BigEndian.PutUint16(data[noff+14:], rr.SRV.Port)
 		encodeName(rr.SRV.Name, data, noff+16)
+	case DNSTypeOPT:
+		noff2 := noff + 10
+		for _, opt := range rr.OPT {
+			binary.BigEndian.PutUint16(data[noff2:], uint16(opt.Code))
+			binary.BigEndian.PutUint16(data[noff2+2:], uint16(len(opt.Data)))
+			copy(data[noff2+4:], opt.Data)
+			noff2 += 4 + len(opt.Data)
+		}
 	default:
 		return 0, fmt.Errorf("serializing resource record of type %v not supported", rr.Type)
 	}
 
 	// DataLength
-	dSz := recSize(rr)
 	binary.BigEndian.PutUint16(data[noff+8:], uint16(dSz))
 
 	if opts.FixLengths {
 		rr.DataLength = uint16(dSz)
 	}
 
-	return len(rr.Name) + 1 + 11 + dSz, nil
+	return noff + 10 + dSz - offset, nil
 }
 
 func (rr *DNSResourceRecord) String() string {
 
+	if rr.Type == DNSTypeOPT {
+		return fmt.Sprintf("OPT udp=%d version=%d do=%v options=%v", rr.EDNS.UDPPayloadSize, rr.EDNS.Version, rr.EDNS.DO, rr.OPT)
+	}
+
 	if rr.Class == DNSClassIN {
 		switch rr.Type {
 		case DNSTypeA, DNSTypeAAAA:
@@ -866,6 +1028,13 @@ func (rr *DNSResourceRecord) decodeRData(data []byte, offset int, buffer *[]byte
 			return err
 		}
 		rr.SRV.Name = name
+	case DNSTypeOPT:
+		rr.EDNS = decodeDNSEDNS(rr.Class, rr.TTL)
+		opts, err := decodeDNSOPTs(rr.Data)
+		if err != nil {
+			return err
+		}
+		rr.OPT = opts
 	}
 	return nil
 }
@@ -891,6 +1060,177 @@ type DNSMX struct {
 	Name       []byte
 }
 
+// DNSOptionCode is the code of an EDNS option.
+type DNSOptionCode uint16
+
+// DNSOptionCode known values.
+const (
+	DNSOptionCodeNSID             DNSOptionCode = 3  // Name Server Identifier [RFC5001]
+	DNSOptionCodeDAU              DNSOptionCode = 5  // DNSSEC Algorithm Understood [RFC6975]
+	DNSOptionCodeDHU              DNSOptionCode = 6  // DS Hash Understood [RFC6975]
+	DNSOptionCodeN3U              DNSOptionCode = 7  // NSEC3 Hash Understood [RFC6975]
+	DNSOptionCodeEDNSClientSubnet DNSOptionCode = 8  // Client Subnet [RFC7871]
+	DNSOptionCodeEDNSExpire       DNSOptionCode = 9  // Expire [RFC7314]
+	DNSOptionCodeCookie           DNSOptionCode = 10 // Cookie [RFC7873]
+	DNSOptionCodeEDNSKeepAlive    DNSOptionCode = 11 // TCP Keepalive [RFC7828]
+	DNSOptionCodePadding          DNSOptionCode = 12 // Padding [RFC7830]
+	DNSOptionCodeChain            DNSOptionCode = 13 // CHAIN [RFC7901]
+	DNSOptionCodeEDNSKeyTag       DNSOptionCode = 14 // Key Tag [RFC8145]
+	DNSOptionCodeExtendedError    DNSOptionCode = 15 // Extended DNS Error [RFC8914]
+)
+
+func (doc DNSOptionCode) String() string {
+	switch doc {
+	default:
+		return "Unknown"
+	case DNSOptionCodeNSID:
+		return "NSID"
+	case DNSOptionCodeDAU:
+		return "DAU"
+	case DNSOptionCodeDHU:
+		return "DHU"
+	case DNSOptionCodeN3U:
+		return "N3U"
+	case DNSOptionCodeEDNSClientSubnet:
+		return "EDNSClientSubnet"
+	case DNSOptionCodeEDNSExpire:
+		return "EDNSExpire"
+	case DNSOptionCodeCookie:
+		return "Cookie"
+	case DNSOptionCodeEDNSKeepAlive:
+		return "EDNSKeepAlive"
+	case DNSOptionCodePadding:
+		return "Padding"
+	case DNSOptionCodeChain:
+		return "Chain"
+	case DNSOptionCodeEDNSKeyTag:
+		return "EDNSKeyTag"
+	case DNSOptionCodeExtendedError:
+		return "ExtendedError"
+	}
+}
+
+// DNSOPT is an EDNS option of an OPT pseudo-record.
+type DNSOPT struct {
+	Code DNSOptionCode
+	Data []byte
+}
+
+func (opt DNSOPT) String() string {
+	return fmt.Sprintf("%s=%x", opt.Code, opt.Data)
+}
+
+func decodeDNSOPTs(data []byte) ([]DNSOPT, error) {
+	var opts []DNSOPT
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errDNSOPTTooShort
+		}
+		l := int(binary.BigEndian.Uint16(data[2:4]))
+		if 4+l > len(data) {
+			return nil, errDNSOPTTooShort
+		}
+		opts = append(opts, DNSOPT{
+			Code: DNSOptionCode(binary.BigEndian.Uint16(data[0:2])),
+			Data: data[4 : 4+l],
+		})
+		data = data[4+l:]
+	}
+	return opts, nil
+}
+
+// DNSEDNS holds the EDNS fields of an OPT pseudo-record [RFC6891].
+type DNSEDNS struct {
+	// UDPPayloadSize is the largest UDP payload the sender can reassemble,
+	// carried by the class.
+	UDPPayloadSize uint16
+	// ExtendedRCode, Version, DO and Z are carried by the TTL.
+	// ExtendedRCode is the upper 8 bits of the 12 bit response code.
+	ExtendedRCode uint8
+	Version       uint8
+	// DO is set to ask for DNSSEC records [RFC3225].
+	DO bool
+	// Z is the other, reserved, flags.
+	Z uint16
+}
+
+func decodeDNSEDNS(class DNSClass, ttl uint32) DNSEDNS {
+	return DNSEDNS{
+		UDPPayloadSize: uint16(class),
+		ExtendedRCode:  uint8(ttl >> 24),
+		Version:        uint8(ttl >> 16),
+		DO:             ttl&0x8000 != 0,
+		Z:              uint16(ttl) & 0x7fff,
+	}
+}
+
+func (e DNSEDNS) classTTL() (DNSClass, uint32) {
+	ttl := uint32(e.ExtendedRCode)<<24 | uint32(e.Version)<<16 | uint32(e.Z&0x7fff)
+	if e.DO {
+		ttl |= 0x8000
+	}
+	return DNSClass(e.UDPPayloadSize), ttl
+}
+
+// DNSClientSubnet is the data of an EDNS Client Subnet option [RFC7871].
+type DNSClientSubnet struct {
+	// Family is the address family, 1 for IPv4 and 2 for IPv6.
+	Family             uint16
+	SourcePrefixLength uint8
+	ScopePrefixLength  uint8
+	// Address is padded to the full length of the family's addresses.
+	Address net.IP
+}
+
+// ClientSubnet decodes the data of an EDNS Client Subnet option.
+func (opt DNSOPT) ClientSubnet() (DNSClientSubnet, error) {
+	var ecs DNSClientSubnet
+	if opt.Code != DNSOptionCodeEDNSClientSubnet {
+		return ecs, fmt.Errorf("EDNS option %v isn't a client subnet", opt.Code)
+	}
+	if len(opt.Data) < 4 {
+		return ecs, errDNSOPTTooShort
+	}
+	ecs.Family = binary.BigEndian.Uint16(opt.Data[0:2])
+	ecs.SourcePrefixLength = opt.Data[2]
+	ecs.ScopePrefixLength = opt.Data[3]
+	addr := opt.Data[4:]
+	switch ecs.Family {
+	case 1:
+		ecs.Address = make(net.IP, net.IPv4len)
+	case 2:
+		ecs.Address = make(net.IP, net.IPv6len)
+	default:
+		return ecs, fmt.Errorf("unknown client subnet address family %d", ecs.Family)
+	}
+	if len(addr) != (int(ecs.SourcePrefixLength)+7)/8 || len(addr) > len(ecs.Address) {
+		return ecs, fmt.Errorf("client subnet address of %d bytes for prefix length %d", len(addr), ecs.SourcePrefixLength)
+	}
+	copy(ecs.Address, addr)
+	return ecs, nil
+}
+
+// Encode returns the data of an EDNS Client Subnet option for ecs, the
+// address truncated to the bytes covered by the source prefix.
+func (ecs DNSClientSubnet) Encode() []byte {
+	addr := ecs.Address
+	if ecs.Family == 1 {
+		addr = addr.To4()
+	} else {
+		addr = addr.To16()
+	}
+	n := (int(ecs.SourcePrefixLength) + 7) / 8
+	if n > len(addr) {
+		n = len(addr)
+	}
+	data := make([]byte, 4+n)
+	binary.BigEndian.PutUint16(data[0:2], ecs.Family)
+	data[2] = ecs.SourcePrefixLength
+	data[3] = ecs.ScopePrefixLength
+	copy(data[4:], addr[:n])
+	return data
+}
+
 var (
 	errMaxRecursion = errors.New("max DNS recursion level hit")
 
@@ -900,6 +1240,7 @@ var (
 	errDNSNameTooLong          = errors.New("dns name is too long")
 	errDNSNameInvalidIndex     = errors.New("dns name uncomputable: invalid index")
 	errDNSPointerOffsetTooHigh = errors.New("dns offset pointer too high")
+	errDNSPointerNotBackward   = errors.New("dns offset pointer doesn't point backward")
 	errDNSIndexOutOfRange      = errors.New("dns index walked out of range")
 	errDNSNameHasNoData        = errors.New("no dns data found for name")
 
@@ -907,6 +1248,8 @@ var (
 
 	errDecodeRecordLength = errors.New("resource record length exceeds data")
 
+	errDNSOPTTooShort = errors.New("EDNS option too short")
+
 	errDecodeQueryBadQDCount = errors.New("Invalid query decoding, not the right number of questions")
 	errDecodeQueryBadANCount = errors.New("Invalid query decoding, not the right number of answers")
 	errDecodeQueryBadNSCount = errors.New("Invalid query decoding, not the right number of authorities")
@@ -0,0 +1,120 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// DNSNeedMoreDataError is returned when data ends partway through a DNS
+// message sent over TCP.  When decoding a reassembled TCP stream, keep the
+// unconsumed bytes and decode again once at least Needed more bytes arrived.
+type DNSNeedMoreDataError struct {
+	Needed int
+}
+
+func (e *DNSNeedMoreDataError) Error() string {
+	return fmt.Sprintf("DNS message truncated, need %d more bytes", e.Needed)
+}
+
+// DNSOverTCP is the DNS messages of a TCP segment or stream, each prefixed
+// by its 2 byte length [RFC7766].  Zone transfers, for one, span many of
+// them.
+type DNSOverTCP struct {
+	BaseLayer
+	Messages []DNS
+}
+
+// LayerType returns LayerTypeDNSOverTCP.
+func (d *DNSOverTCP) LayerType() gopacket.LayerType { return LayerTypeDNSOverTCP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (d *DNSOverTCP) CanDecode() gopacket.LayerClass { return LayerTypeDNSOverTCP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (d *DNSOverTCP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since DNS messages don't carry other layers.
+func (d *DNSOverTCP) Payload() []byte { return nil }
+
+func decodeDNSOverTCP(data []byte, p gopacket.PacketBuilder) error {
+	d := &DNSOverTCP{}
+	return decodingLayerDecoder(d, data, p)
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.  data must hold
+// only complete messages; if the last one is cut off, a
+// *DNSNeedMoreDataError is returned.
+func (d *DNSOverTCP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	_, err := d.DecodeMessages(data, df)
+	return err
+}
+
+// DecodeMessages decodes as many complete messages as data holds, and
+// returns the number of bytes they take up, length prefixes included.  If
+// data ends partway through a message, the messages before it are decoded
+// and a *DNSNeedMoreDataError is returned along with their length, so that
+// the caller can keep the remaining bytes until more of the stream arrives.
+func (d *DNSOverTCP) DecodeMessages(data []byte, df gopacket.DecodeFeedback) (int, error) {
+	d.Messages = d.Messages[:0]
+	n := 0
+	var err error
+	for n < len(data) {
+		if len(data)-n < 2 {
+			err = &DNSNeedMoreDataError{Needed: 2 - (len(data) - n)}
+			break
+		}
+		ml := int(binary.BigEndian.Uint16(data[n : n+2]))
+		if len(data)-n-2 < ml {
+			err = &DNSNeedMoreDataError{Needed: ml - (len(data) - n - 2)}
+			break
+		}
+		// Grow in place so that messages keep their buffers from one
+		// call to the next.
+		if len(d.Messages) < cap(d.Messages) {
+			d.Messages = d.Messages[:len(d.Messages)+1]
+		} else {
+			d.Messages = append(d.Messages, DNS{})
+		}
+		if err = d.Messages[len(d.Messages)-1].DecodeFromBytes(data[n+2:n+2+ml], df); err != nil {
+			d.Messages = d.Messages[:len(d.Messages)-1]
+			break
+		}
+		n += 2 + ml
+	}
+	if _, ok := err.(*DNSNeedMoreDataError); ok {
+		df.SetTruncated()
+	}
+	d.BaseLayer = BaseLayer{Contents: data[:n]}
+	return n, err
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (d *DNSOverTCP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	// Messages are prepended, so go through them backwards.
+	for i := len(d.Messages) - 1; i >= 0; i-- {
+		before := len(b.Bytes())
+		if err := d.Messages[i].SerializeTo(b, opts); err != nil {
+			return err
+		}
+		ml := len(b.Bytes()) - before
+		if ml > 0xffff {
+			return fmt.Errorf("DNS message of %d bytes too long for TCP", ml)
+		}
+		bytes, err := b.PrependBytes(2)
+		if err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint16(bytes, uint16(ml))
+	}
+	return nil
+}
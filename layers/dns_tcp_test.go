@@ -0,0 +1,174 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketDNSAXFR is a segment of the zone transfer of example.com,
+// holding its two messages: the first one with the question, the SOA, NS
+// and A records, the second one with an A record and the closing SOA.
+var testPacketDNSAXFR = []byte{
+	0x02, 0x42, 0xc0, 0x00, 0x02, 0xc8, 0x02, 0x42, 0xc0, 0x00, 0x02, 0x01, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0xf8, 0x1c, 0x46, 0x40, 0x00, 0x40, 0x06, 0x98, 0xf0, 0xc0, 0x00, 0x02, 0x01, 0xc0, 0x00,
+	0x02, 0xc8, 0x00, 0x35, 0xc0, 0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e, 0x6f, 0x70, 0x81, 0x50, 0x18,
+	0xfe, 0x88, 0x44, 0xe4, 0x00, 0x00, 0x00, 0x6e, 0x4a, 0x3c, 0x84, 0x00, 0x00, 0x01, 0x00, 0x03,
+	0x00, 0x00, 0x00, 0x00, 0x07, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x03, 0x63, 0x6f, 0x6d,
+	0x00, 0x00, 0xfc, 0x00, 0x01, 0xc0, 0x0c, 0x00, 0x06, 0x00, 0x01, 0x00, 0x00, 0x0e, 0x10, 0x00,
+	0x27, 0x03, 0x6e, 0x73, 0x31, 0xc0, 0x0c, 0x0a, 0x68, 0x6f, 0x73, 0x74, 0x6d, 0x61, 0x73, 0x74,
+	0x65, 0x72, 0xc0, 0x0c, 0x78, 0x48, 0x63, 0xf5, 0x00, 0x00, 0x1c, 0x20, 0x00, 0x00, 0x0e, 0x10,
+	0x00, 0x12, 0x75, 0x00, 0x00, 0x00, 0x0e, 0x10, 0xc0, 0x0c, 0x00, 0x02, 0x00, 0x01, 0x00, 0x00,
+	0x0e, 0x10, 0x00, 0x02, 0xc0, 0x29, 0xc0, 0x29, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x0e, 0x10,
+	0x00, 0x04, 0xc0, 0x00, 0x02, 0x01, 0x00, 0x5e, 0x4a, 0x3c, 0x84, 0x00, 0x00, 0x00, 0x00, 0x02,
+	0x00, 0x00, 0x00, 0x00, 0x03, 0x77, 0x77, 0x77, 0x07, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65,
+	0x03, 0x63, 0x6f, 0x6d, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x0e, 0x10, 0x00, 0x04, 0xc0,
+	0x00, 0x02, 0x50, 0xc0, 0x10, 0x00, 0x06, 0x00, 0x01, 0x00, 0x00, 0x0e, 0x10, 0x00, 0x27, 0x03,
+	0x6e, 0x73, 0x31, 0xc0, 0x10, 0x0a, 0x68, 0x6f, 0x73, 0x74, 0x6d, 0x61, 0x73, 0x74, 0x65, 0x72,
+	0xc0, 0x10, 0x78, 0x48, 0x63, 0xf5, 0x00, 0x00, 0x1c, 0x20, 0x00, 0x00, 0x0e, 0x10, 0x00, 0x12,
+	0x75, 0x00, 0x00, 0x00, 0x0e, 0x10,
+}
+
+var testDNSOverTCPDecodeOptions = gopacket.DecodeOptions{
+	SkipDecodeRecovery:       true,
+	DecodeStreamsAsDatagrams: true,
+}
+
+func TestDNSOverTCPAXFR(t *testing.T) {
+	p := gopacket.NewPacket(testPacketDNSAXFR, LinkTypeEthernet, testDNSOverTCPDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeTCP, LayerTypeDNSOverTCP}, t)
+	d := p.Layer(LayerTypeDNSOverTCP).(*DNSOverTCP)
+	if len(d.Messages) != 2 {
+		t.Fatalf("messages, want 2 got %d", len(d.Messages))
+	}
+	first, last := &d.Messages[0], &d.Messages[1]
+	if len(first.Questions) != 1 || first.Questions[0].Type != DNSTypeAXFR || string(first.Questions[0].Name) != "example.com" {
+		t.Errorf("unexpected question %+v", first.Questions)
+	}
+	var types []DNSType
+	for _, m := range d.Messages {
+		for _, rr := range m.Answers {
+			types = append(types, rr.Type)
+		}
+	}
+	if want := []DNSType{DNSTypeSOA, DNSTypeNS, DNSTypeA, DNSTypeA, DNSTypeSOA}; !reflect.DeepEqual(types, want) {
+		t.Errorf("record types, want %v got %v", want, types)
+	}
+	if got := string(first.Answers[1].NS); got != "ns1.example.com" {
+		t.Errorf("NS, want ns1.example.com got %s", got)
+	}
+	if got := last.Answers[0]; string(got.Name) != "www.example.com" || !got.IP.Equal(net.IPv4(192, 0, 2, 80)) {
+		t.Errorf("unexpected A record %v", &got)
+	}
+	if got := last.Answers[1].SOA; got.Serial != 2018010101 || string(got.RName) != "hostmaster.example.com" {
+		t.Errorf("unexpected SOA %+v", got)
+	}
+}
+
+func TestDNSOverTCPStream(t *testing.T) {
+	stream := testPacketDNSAXFR[54:]
+	var d DNSOverTCP
+	var pending []byte
+	records := 0
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		pending = append(pending, stream[i:end]...)
+		n, err := d.DecodeMessages(pending, gopacket.NilDecodeFeedback)
+		if err != nil {
+			e, ok := err.(*DNSNeedMoreDataError)
+			if !ok {
+				t.Fatalf("at %d: %v", end, err)
+			}
+			if e.Needed <= 0 {
+				t.Fatalf("at %d: need %d more bytes", end, e.Needed)
+			}
+		}
+		for _, m := range d.Messages {
+			records += len(m.Answers)
+		}
+		pending = pending[n:]
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d bytes left undecoded", len(pending))
+	}
+	if records != 5 {
+		t.Errorf("records, want 5 got %d", records)
+	}
+
+	// The first message is 110 bytes long.
+	n, err := d.DecodeMessages(stream[:100], gopacket.NilDecodeFeedback)
+	if e, ok := err.(*DNSNeedMoreDataError); !ok || e.Needed != 12 || n != 0 {
+		t.Errorf("want 0 bytes decoded, 12 needed, got %d, %v", n, err)
+	}
+	n, err = d.DecodeMessages(stream[:113], gopacket.NilDecodeFeedback)
+	if e, ok := err.(*DNSNeedMoreDataError); !ok || e.Needed != 1 || n != 112 || len(d.Messages) != 1 {
+		t.Errorf("want 112 bytes decoded, 1 needed, got %d, %v", n, err)
+	}
+}
+
+func TestDNSOverTCPSerialize(t *testing.T) {
+	p := gopacket.NewPacket(testPacketDNSAXFR, LinkTypeEthernet, testDNSOverTCPDecodeOptions)
+	d := p.Layer(LayerTypeDNSOverTCP).(*DNSOverTCP)
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, d); err != nil {
+		t.Fatal(err)
+	}
+	var d2 DNSOverTCP
+	if n, err := d2.DecodeMessages(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil || n != len(buf.Bytes()) {
+		t.Fatalf("decoded %d of %d bytes: %v", n, len(buf.Bytes()), err)
+	}
+	if len(d2.Messages) != len(d.Messages) {
+		t.Fatalf("messages, want %d got %d", len(d.Messages), len(d2.Messages))
+	}
+	for i := range d.Messages {
+		testDNSEqual(t, &d.Messages[i], &d2.Messages[i])
+	}
+}
+
+func TestDNSOverTCPSerializeCompressed(t *testing.T) {
+	p := gopacket.NewPacket(testPacketDNSAXFR, LinkTypeEthernet, testDNSOverTCPDecodeOptions)
+	d := p.Layer(LayerTypeDNSOverTCP).(*DNSOverTCP)
+	for i := range d.Messages {
+		d.Messages[i].CompressNames = true
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, d); err != nil {
+		t.Fatal(err)
+	}
+	if want := testPacketDNSAXFR[54:]; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("compressed encoding, want\n%x\ngot\n%x", want, buf.Bytes())
+	}
+}
+
+func TestDNSOverTCPMalformed(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"message too short", []byte{0x00, 0x02, 0x12, 0x34}},
+		{"bad question", []byte{0x00, 0x0e, 0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3f, 0x61}},
+	} {
+		var d DNSOverTCP
+		_, err := d.DecodeMessages(test.data, gopacket.NilDecodeFeedback)
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+		} else if _, ok := err.(*DNSNeedMoreDataError); ok {
+			t.Errorf("%s: unexpected %v", test.name, err)
+		}
+	}
+}
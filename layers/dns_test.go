@@ -868,3 +868,263 @@ func TestDNSPacketWriteAnswer(t *testing.T) {
 		t.Fatalf("Encoded size, want %d got %d", want, got)
 	}
 }
+
+func TestPacketDNSRegressionEDNS(t *testing.T) {
+	p := gopacket.NewPacket(testPacketDNSRegression, LinkTypeEthernet, testDecodeOptions)
+	dns := p.Layer(LayerTypeDNS).(*DNS)
+	opt := dns.OPT()
+	if opt == nil {
+		t.Fatal("no OPT record")
+	}
+	want := DNSEDNS{UDPPayloadSize: 4096, DO: true}
+	if opt.EDNS != want {
+		t.Errorf("EDNS, want %+v got %+v", want, opt.EDNS)
+	}
+	if len(opt.OPT) != 0 {
+		t.Errorf("unexpected EDNS options %v", opt.OPT)
+	}
+	testSerialization(t, p, testPacketDNSRegression)
+}
+
+// testPacketDNSEDNSClientSubnet is a response for example.com carrying a
+// client subnet of 192.0.2.0/24 and a cookie.
+var testPacketDNSEDNSClientSubnet = []byte{
+	0xbe, 0xef, 0x81, 0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x07, 0x65, 0x78, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x03, 0x63, 0x6f, 0x6d, 0x00, 0x00, 0x01, 0x00, 0x01, 0x07, 0x65, 0x78,
+	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x03, 0x63, 0x6f, 0x6d, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00,
+	0x01, 0x2c, 0x00, 0x04, 0x5d, 0xb8, 0xd8, 0x22, 0x00, 0x00, 0x29, 0x04, 0xd0, 0x00, 0x00, 0x80,
+	0x00, 0x00, 0x27, 0x00, 0x08, 0x00, 0x07, 0x00, 0x01, 0x18, 0x18, 0xc0, 0x00, 0x02, 0x00, 0x0a,
+	0x00, 0x18, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15,
+	0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+}
+
+func TestDNSEDNSClientSubnet(t *testing.T) {
+	p := gopacket.NewPacket(testPacketDNSEDNSClientSubnet, LayerTypeDNS, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	dns := p.Layer(LayerTypeDNS).(*DNS)
+	opt := dns.OPT()
+	if opt == nil {
+		t.Fatal("no OPT record")
+	}
+	if want := (DNSEDNS{UDPPayloadSize: 1232, DO: true}); opt.EDNS != want {
+		t.Errorf("EDNS, want %+v got %+v", want, opt.EDNS)
+	}
+	if len(opt.OPT) != 2 {
+		t.Fatalf("EDNS options, want 2 got %d", len(opt.OPT))
+	}
+	if opt.OPT[1].Code != DNSOptionCodeCookie || len(opt.OPT[1].Data) != 24 {
+		t.Errorf("unexpected cookie option %v", opt.OPT[1])
+	}
+	ecs, err := opt.OPT[0].ClientSubnet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ecs.Family != 1 || ecs.SourcePrefixLength != 24 || ecs.ScopePrefixLength != 24 {
+		t.Errorf("unexpected client subnet %+v", ecs)
+	}
+	if !ecs.Address.Equal(net.IPv4(192, 0, 2, 0)) {
+		t.Errorf("client subnet address, want 192.0.2.0 got %v", ecs.Address)
+	}
+	if got := ecs.Encode(); !bytes.Equal(got, opt.OPT[0].Data) {
+		t.Errorf("client subnet encoding, want %x got %x", opt.OPT[0].Data, got)
+	}
+	if _, err := opt.OPT[1].ClientSubnet(); err == nil {
+		t.Error("cookie decoded as a client subnet")
+	}
+	testSerialization(t, p, testPacketDNSEDNSClientSubnet)
+}
+
+func TestDNSEncodeEDNSExtendedResponseCode(t *testing.T) {
+	ecs := DNSClientSubnet{Family: 2, SourcePrefixLength: 56, Address: net.ParseIP("2001:db8:1234:5600::")}
+	dns := &DNS{ID: 1234, QR: true, OpCode: DNSOpCodeQuery, ResponseCode: DNSResponseCodeNoErr}
+	dns.Additionals = append(dns.Additionals, DNSResourceRecord{
+		Type: DNSTypeOPT,
+		EDNS: DNSEDNS{UDPPayloadSize: 512, ExtendedRCode: 1},
+		OPT: []DNSOPT{
+			{Code: DNSOptionCodeEDNSClientSubnet, Data: ecs.Encode()},
+			{Code: DNSOptionCodePadding, Data: make([]byte, 5)},
+		},
+	})
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, dns); err != nil {
+		t.Fatal(err)
+	}
+	// header, root name, fixed fields, client subnet and padding options
+	if want, got := 12+1+10+(4+11)+(4+5), len(buf.Bytes()); want != got {
+		t.Fatalf("encoded size, want %d got %d", want, got)
+	}
+
+	dns2 := &DNS{}
+	if err := dns2.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := uint16(DNSResponseCodeBadVers), dns2.ExtendedResponseCode(); want != got {
+		t.Errorf("extended response code, want %d got %d", want, got)
+	}
+	opt := dns2.OPT()
+	if opt == nil {
+		t.Fatal("no OPT record")
+	}
+	if opt.EDNS != dns.Additionals[0].EDNS {
+		t.Errorf("EDNS, want %+v got %+v", dns.Additionals[0].EDNS, opt.EDNS)
+	}
+	if len(opt.OPT) != 2 {
+		t.Fatalf("EDNS options, want 2 got %d", len(opt.OPT))
+	}
+	ecs2, err := opt.OPT[0].ClientSubnet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecs2.Address.Equal(ecs.Address) || ecs2.SourcePrefixLength != 56 {
+		t.Errorf("client subnet, want %+v got %+v", ecs, ecs2)
+	}
+}
+
+func TestDNSMalformedEDNS(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts []byte
+	}{
+		{"option header truncated", []byte{0x00, 0x08, 0x00}},
+		{"option data truncated", []byte{0x00, 0x0a, 0x00, 0x08, 0x01, 0x02}},
+	} {
+		data := []byte{
+			0x12, 0x34, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x29, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(len(test.opts)),
+		}
+		data = append(data, test.opts...)
+		dns := &DNS{}
+		if err := dns.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"too short", []byte{0x00, 0x01, 0x18}},
+		{"unknown family", []byte{0x00, 0x03, 0x08, 0x00, 0x0a}},
+		{"prefix mismatch", []byte{0x00, 0x01, 0x18, 0x00, 0xc0, 0x00}},
+		{"address too long", []byte{0x00, 0x01, 0x28, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05}},
+	} {
+		opt := DNSOPT{Code: DNSOptionCodeEDNSClientSubnet, Data: test.data}
+		if _, err := opt.ClientSubnet(); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestDNSNameDecompressionLimits(t *testing.T) {
+	label := append([]byte{60}, bytes.Repeat([]byte{'x'}, 60)...)
+	// Five names of 60 byte labels, each pointing back to the one before,
+	// the last one decompressing to more than 255 bytes.
+	chain := append(append([]byte{}, label...), 0x00)
+	offsets := []int{0}
+	for i := 1; i < 5; i++ {
+		offsets = append(offsets, len(chain))
+		chain = append(chain, label...)
+		chain = append(chain, 0xc0, byte(offsets[i-1]))
+	}
+	for _, test := range []struct {
+		name   string
+		data   []byte
+		offset int
+		err    error
+	}{
+		{"pointer to itself", []byte{0xc0, 0x00}, 0, errDNSPointerNotBackward},
+		{"pointer to its labels", []byte{0x01, 'a', 0xc0, 0x00}, 0, errDNSPointerNotBackward},
+		{"forward pointer", []byte{0xc0, 0x02, 0x01, 'a', 0x00}, 0, errDNSPointerNotBackward},
+		{"pointer loop", []byte{0x01, 'a', 0xc0, 0x04, 0x01, 'b', 0xc0, 0x00}, 4, errDNSPointerNotBackward},
+		{"name too long", chain, offsets[4], errDNSNameTooLong},
+	} {
+		var buf []byte
+		if _, _, err := decodeName(test.data, test.offset, &buf, 1); err != test.err {
+			t.Errorf("%s: want error %v, got %v", test.name, test.err, err)
+		}
+	}
+	var buf []byte
+	name, _, err := decodeName(chain, offsets[3], &buf, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(name) != 4*61-1 {
+		t.Errorf("name length, want %d got %d", 4*61-1, len(name))
+	}
+}
+
+func TestDNSNamePointersTerminate(t *testing.T) {
+	// Point the question name everywhere in a body made of pointers,
+	// which must all fail quickly.
+	data := []byte{0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	for i := 0; i < 64; i++ {
+		data = append(data, 0xc0, byte(i))
+	}
+	for p := 12; p < len(data); p++ {
+		data[12], data[13] = 0xc0|byte(p>>8), byte(p)
+		dns := &DNS{}
+		if err := dns.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("pointer to %d: no error", p)
+		}
+	}
+}
+
+func TestDNSEncodeCompressed(t *testing.T) {
+	dns := &DNS{ID: 1234, QR: true, OpCode: DNSOpCodeQuery, AA: true, RD: true, RA: true, CompressNames: true}
+	dns.Questions = append(dns.Questions, DNSQuestion{
+		Name:  []byte("example.com"),
+		Type:  DNSTypeMX,
+		Class: DNSClassIN,
+	})
+	dns.Answers = append(dns.Answers,
+		DNSResourceRecord{
+			Name:  []byte("example.com"),
+			Type:  DNSTypeMX,
+			Class: DNSClassIN,
+			TTL:   1024,
+			MX:    DNSMX{Preference: 10, Name: []byte("mail.example.com")},
+		},
+		DNSResourceRecord{
+			Name:  []byte("example.com"),
+			Type:  DNSTypeMX,
+			Class: DNSClassIN,
+			TTL:   1024,
+			MX:    DNSMX{Preference: 20, Name: []byte("mail.example.org")},
+		})
+	dns.Additionals = append(dns.Additionals, DNSResourceRecord{
+		Name:  []byte("mail.example.com"),
+		Type:  DNSTypeA,
+		Class: DNSClassIN,
+		TTL:   1024,
+		IP:    net.IPv4(192, 0, 2, 25),
+	})
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, dns); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x04, 0xd2, 0x85, 0x80, 0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01,
+		// example.com MX IN
+		0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00, 0x00, 0x0f, 0x00, 0x01,
+		// example.com MX 10 mail.example.com
+		0xc0, 0x0c, 0x00, 0x0f, 0x00, 0x01, 0x00, 0x00, 0x04, 0x00, 0x00, 0x09,
+		0x00, 0x0a, 0x04, 'm', 'a', 'i', 'l', 0xc0, 0x0c,
+		// example.com MX 20 mail.example.org
+		0xc0, 0x0c, 0x00, 0x0f, 0x00, 0x01, 0x00, 0x00, 0x04, 0x00, 0x00, 0x14,
+		0x00, 0x14, 0x04, 'm', 'a', 'i', 'l', 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'o', 'r', 'g', 0x00,
+		// mail.example.com A 192.0.2.25
+		0xc0, 0x2b, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x04, 0x00, 0x00, 0x04, 0xc0, 0x00, 0x02, 0x19,
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("compressed encoding, want\n%x\ngot\n%x", want, buf.Bytes())
+	}
+
+	dns2 := &DNS{}
+	if err := dns2.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	testDNSEqual(t, dns, dns2)
+}
@@ -0,0 +1,524 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+// See https://standards.ieee.org/standard/802_15_4-2015.html for info on
+// all of the layers in this file.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// Dot15d4FrameType is the frame type from the IEEE 802.15.4 frame control
+// field.
+type Dot15d4FrameType uint8
+
+const (
+	Dot15d4FrameTypeBeacon       Dot15d4FrameType = 0
+	Dot15d4FrameTypeData         Dot15d4FrameType = 1
+	Dot15d4FrameTypeAck          Dot15d4FrameType = 2
+	Dot15d4FrameTypeMACCommand   Dot15d4FrameType = 3
+	Dot15d4FrameTypeMultipurpose Dot15d4FrameType = 5
+	Dot15d4FrameTypeFragment     Dot15d4FrameType = 6
+	Dot15d4FrameTypeExtended     Dot15d4FrameType = 7
+)
+
+func (t Dot15d4FrameType) String() string {
+	switch t {
+	case Dot15d4FrameTypeBeacon:
+		return "Beacon"
+	case Dot15d4FrameTypeData:
+		return "Data"
+	case Dot15d4FrameTypeAck:
+		return "Ack"
+	case Dot15d4FrameTypeMACCommand:
+		return "MACCommand"
+	case Dot15d4FrameTypeMultipurpose:
+		return "Multipurpose"
+	case Dot15d4FrameTypeFragment:
+		return "Fragment"
+	case Dot15d4FrameTypeExtended:
+		return "Extended"
+	default:
+		return "Unknown"
+	}
+}
+
+// Dot15d4AddressingMode is the addressing mode of the source or destination
+// address of an IEEE 802.15.4 frame.
+type Dot15d4AddressingMode uint8
+
+const (
+	Dot15d4AddressingModeNone     Dot15d4AddressingMode = 0
+	Dot15d4AddressingModeShort    Dot15d4AddressingMode = 2
+	Dot15d4AddressingModeExtended Dot15d4AddressingMode = 3
+)
+
+func (m Dot15d4AddressingMode) String() string {
+	switch m {
+	case Dot15d4AddressingModeNone:
+		return "None"
+	case Dot15d4AddressingModeShort:
+		return "Short"
+	case Dot15d4AddressingModeExtended:
+		return "Extended"
+	default:
+		return "Reserved"
+	}
+}
+
+// length returns the number of bytes an address in this mode takes up.
+func (m Dot15d4AddressingMode) length() int {
+	switch m {
+	case Dot15d4AddressingModeShort:
+		return 2
+	case Dot15d4AddressingModeExtended:
+		return 8
+	}
+	return 0
+}
+
+// Dot15d4FrameVersion is the frame version from the IEEE 802.15.4 frame
+// control field.
+type Dot15d4FrameVersion uint8
+
+const (
+	Dot15d4FrameVersion2003 Dot15d4FrameVersion = 0
+	Dot15d4FrameVersion2006 Dot15d4FrameVersion = 1
+	Dot15d4FrameVersion2015 Dot15d4FrameVersion = 2
+)
+
+// Dot15d4SecurityLevel is the security level from the auxiliary security
+// header.  Levels 4 and above encrypt the payload, and all levels except 0
+// and 4 append a MIC of MICLength bytes to it.
+type Dot15d4SecurityLevel uint8
+
+const (
+	Dot15d4SecurityLevelNone      Dot15d4SecurityLevel = 0
+	Dot15d4SecurityLevelMIC32     Dot15d4SecurityLevel = 1
+	Dot15d4SecurityLevelMIC64     Dot15d4SecurityLevel = 2
+	Dot15d4SecurityLevelMIC128    Dot15d4SecurityLevel = 3
+	Dot15d4SecurityLevelENC       Dot15d4SecurityLevel = 4
+	Dot15d4SecurityLevelENCMIC32  Dot15d4SecurityLevel = 5
+	Dot15d4SecurityLevelENCMIC64  Dot15d4SecurityLevel = 6
+	Dot15d4SecurityLevelENCMIC128 Dot15d4SecurityLevel = 7
+)
+
+// Encrypted returns true if frames using this security level have an
+// encrypted payload.
+func (l Dot15d4SecurityLevel) Encrypted() bool {
+	return l >= Dot15d4SecurityLevelENC
+}
+
+// MICLength returns the length of the message integrity code that's appended
+// to the payload of frames using this security level.
+func (l Dot15d4SecurityLevel) MICLength() int {
+	switch l & 0x3 {
+	case 1:
+		return 4
+	case 2:
+		return 8
+	case 3:
+		return 16
+	}
+	return 0
+}
+
+// Dot15d4KeyIdentifierMode determines the format of the key identifier in
+// the auxiliary security header.
+type Dot15d4KeyIdentifierMode uint8
+
+const (
+	// Dot15d4KeyIdentifierModeImplicit means the key is determined implicitly
+	// from the originator and recipient of the frame.
+	Dot15d4KeyIdentifierModeImplicit Dot15d4KeyIdentifierMode = 0
+	// Dot15d4KeyIdentifierModeIndex means the key is determined from the key
+	// index and the macDefaultKeySource.
+	Dot15d4KeyIdentifierModeIndex Dot15d4KeyIdentifierMode = 1
+	// Dot15d4KeyIdentifierModeSource4 means the key is determined from the key
+	// index and a 4 byte key source.
+	Dot15d4KeyIdentifierModeSource4 Dot15d4KeyIdentifierMode = 2
+	// Dot15d4KeyIdentifierModeSource8 means the key is determined from the key
+	// index and an 8 byte key source.
+	Dot15d4KeyIdentifierModeSource8 Dot15d4KeyIdentifierMode = 3
+)
+
+// Dot15d4AuxSecurityHeader is the auxiliary security header present in IEEE
+// 802.15.4 frames that have the security enabled bit set.
+type Dot15d4AuxSecurityHeader struct {
+	SecurityLevel          Dot15d4SecurityLevel
+	KeyIdentifierMode      Dot15d4KeyIdentifierMode
+	FrameCounterSuppressed bool
+	ASNInNonce             bool
+	FrameCounter           uint32
+	// KeySource is 0, 4 or 8 bytes long depending on KeyIdentifierMode.
+	KeySource []byte
+	KeyIndex  uint8
+}
+
+// Dot15d4HeaderIE is an information element from the header of an IEEE
+// 802.15.4-2015 frame.
+type Dot15d4HeaderIE struct {
+	ElementID uint8
+	Content   []byte
+}
+
+const (
+	// Header termination IEs.  HT1 is followed by payload IEs, HT2 by the
+	// frame payload.
+	dot15d4HeaderIEHT1 = 0x7e
+	dot15d4HeaderIEHT2 = 0x7f
+)
+
+// Dot15d4 is the IEEE 802.15.4 MAC frame header, as found in captures using
+// LinkTypeIEEE802_15_4 or LinkTypeIEEE802_15_4NoFCS.  The MAC payload is left
+// for higher layers.
+type Dot15d4 struct {
+	BaseLayer
+	FrameType          Dot15d4FrameType
+	SecurityEnabled    bool
+	FramePending       bool
+	AckRequest         bool
+	PANIDCompression   bool
+	SeqNumSuppression  bool
+	IEPresent          bool
+	DstAddressingMode  Dot15d4AddressingMode
+	FrameVersion       Dot15d4FrameVersion
+	SrcAddressingMode  Dot15d4AddressingMode
+	SequenceNumber     uint8
+	DstPANID, SrcPANID uint16
+	// DstAddress and SrcAddress hold a short address in their low 16 bits, or
+	// a full extended address, depending on the relevant addressing mode.
+	DstAddress, SrcAddress uint64
+	AuxSecurityHeader      *Dot15d4AuxSecurityHeader
+	HeaderIEs              []Dot15d4HeaderIE
+	// MIC is the message integrity code trailing the payload of secured
+	// frames.
+	MIC []byte
+	// HasFCS is true if FCS was present in the captured frame.
+	HasFCS bool
+	FCS    uint16
+}
+
+// LayerType returns LayerTypeDot15d4.
+func (m *Dot15d4) LayerType() gopacket.LayerType { return LayerTypeDot15d4 }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *Dot15d4) CanDecode() gopacket.LayerClass { return LayerTypeDot15d4 }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+// Unencrypted data frames whose payload starts with a 6LoWPAN dispatch value
+// are decoded as LayerTypeLoWPAN, and those that look like Zigbee network
+// frames as LayerTypeZigbeeNWK.
+func (m *Dot15d4) NextLayerType() gopacket.LayerType {
+	if m.FrameType != Dot15d4FrameTypeData || len(m.Payload) == 0 {
+		return gopacket.LayerTypePayload
+	}
+	if m.AuxSecurityHeader != nil && m.AuxSecurityHeader.SecurityLevel.Encrypted() {
+		return gopacket.LayerTypePayload
+	}
+	// Dispatch values 00xxxxxx are reserved for protocols other than 6LoWPAN.
+	if m.Payload[0]&0xc0 != 0 {
+		return LayerTypeLoWPAN
+	}
+	if isZigbeeNWK(m.Payload) {
+		return LayerTypeZigbeeNWK
+	}
+	return gopacket.LayerTypePayload
+}
+
+// linkAddr returns an address in the given mode as a big-endian byte slice.
+func (m *Dot15d4) linkAddr(addr uint64, mode Dot15d4AddressingMode) net.HardwareAddr {
+	switch mode {
+	case Dot15d4AddressingModeShort:
+		a := make(net.HardwareAddr, 2)
+		binary.BigEndian.PutUint16(a, uint16(addr))
+		return a
+	case Dot15d4AddressingModeExtended:
+		a := make(net.HardwareAddr, 8)
+		binary.BigEndian.PutUint64(a, addr)
+		return a
+	}
+	return nil
+}
+
+// SrcLinkAddr returns the source address as a 2 or 8 byte net.HardwareAddr,
+// or nil if the frame has no source address.
+func (m *Dot15d4) SrcLinkAddr() net.HardwareAddr {
+	return m.linkAddr(m.SrcAddress, m.SrcAddressingMode)
+}
+
+// DstLinkAddr returns the destination address as a 2 or 8 byte
+// net.HardwareAddr, or nil if the frame has no destination address.
+func (m *Dot15d4) DstLinkAddr() net.HardwareAddr {
+	return m.linkAddr(m.DstAddress, m.DstAddressingMode)
+}
+
+// nextDecoder returns the decoder for this frame's payload.  6LoWPAN needs the
+// frame's addresses to decompress IPv6 headers.
+func (m *Dot15d4) nextDecoder() gopacket.Decoder {
+	next := m.NextLayerType()
+	if next == LayerTypeLoWPAN {
+		return lowpanDecoder{src: m.SrcLinkAddr(), dst: m.DstLinkAddr()}
+	}
+	return next
+}
+
+// panIDsPresent returns whether the destination and source PAN ID fields are
+// present, based on the addressing modes and PAN ID compression bit.
+func (m *Dot15d4) panIDsPresent() (dst, src bool) {
+	dstMode, srcMode := m.DstAddressingMode, m.SrcAddressingMode
+	if m.FrameVersion < Dot15d4FrameVersion2015 {
+		dst = dstMode != Dot15d4AddressingModeNone
+		src = srcMode != Dot15d4AddressingModeNone && !(dst && m.PANIDCompression)
+		return
+	}
+	// IEEE 802.15.4-2015 table 7-2.
+	switch {
+	case dstMode == Dot15d4AddressingModeNone && srcMode == Dot15d4AddressingModeNone:
+		return m.PANIDCompression, false
+	case srcMode == Dot15d4AddressingModeNone:
+		return !m.PANIDCompression, false
+	case dstMode == Dot15d4AddressingModeNone:
+		return false, !m.PANIDCompression
+	case dstMode == Dot15d4AddressingModeExtended && srcMode == Dot15d4AddressingModeExtended:
+		return !m.PANIDCompression, false
+	}
+	return true, !m.PANIDCompression
+}
+
+func (m *Dot15d4) decodeAddress(data []byte, mode Dot15d4AddressingMode) uint64 {
+	switch mode {
+	case Dot15d4AddressingModeShort:
+		return uint64(binary.LittleEndian.Uint16(data))
+	case Dot15d4AddressingModeExtended:
+		return binary.LittleEndian.Uint64(data)
+	}
+	return 0
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.  The frame is
+// assumed not to end in an FCS; use decodeDot15d4FCS for frames that do.
+func (m *Dot15d4) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	return m.decodeFromBytes(data, df, false)
+}
+
+func (m *Dot15d4) decodeFromBytes(data []byte, df gopacket.DecodeFeedback, fcs bool) error {
+	if fcs {
+		if len(data) < 2 {
+			df.SetTruncated()
+			return fmt.Errorf("Dot15d4 length %v too short, %v required", len(data), 2)
+		}
+		m.HasFCS = true
+		m.FCS = binary.LittleEndian.Uint16(data[len(data)-2:])
+		data = data[:len(data)-2]
+	} else {
+		m.HasFCS = false
+		m.FCS = 0
+	}
+	if len(data) < 2 {
+		df.SetTruncated()
+		return fmt.Errorf("Dot15d4 length %v too short, %v required", len(data), 2)
+	}
+	fc := binary.LittleEndian.Uint16(data[0:2])
+	m.FrameType = Dot15d4FrameType(fc & 0x7)
+	m.SecurityEnabled = fc&0x0008 != 0
+	m.FramePending = fc&0x0010 != 0
+	m.AckRequest = fc&0x0020 != 0
+	m.PANIDCompression = fc&0x0040 != 0
+	m.SeqNumSuppression = fc&0x0100 != 0
+	m.IEPresent = fc&0x0200 != 0
+	m.DstAddressingMode = Dot15d4AddressingMode(fc >> 10 & 0x3)
+	m.FrameVersion = Dot15d4FrameVersion(fc >> 12 & 0x3)
+	m.SrcAddressingMode = Dot15d4AddressingMode(fc >> 14 & 0x3)
+	if m.FrameVersion < Dot15d4FrameVersion2015 {
+		// These bits were reserved before 802.15.4-2015.
+		m.SeqNumSuppression = false
+		m.IEPresent = false
+	}
+	offset := 2
+
+	m.SequenceNumber = 0
+	if !m.SeqNumSuppression {
+		if len(data) < offset+1 {
+			df.SetTruncated()
+			return fmt.Errorf("Dot15d4 length %v too short, %v required", len(data), offset+1)
+		}
+		m.SequenceNumber = data[offset]
+		offset++
+	}
+
+	dstPAN, srcPAN := m.panIDsPresent()
+	need := offset + m.DstAddressingMode.length() + m.SrcAddressingMode.length()
+	if dstPAN {
+		need += 2
+	}
+	if srcPAN {
+		need += 2
+	}
+	if len(data) < need {
+		df.SetTruncated()
+		return fmt.Errorf("Dot15d4 length %v too short, %v required", len(data), need)
+	}
+	m.DstPANID, m.SrcPANID = 0, 0
+	if dstPAN {
+		m.DstPANID = binary.LittleEndian.Uint16(data[offset : offset+2])
+		offset += 2
+	}
+	m.DstAddress = m.decodeAddress(data[offset:], m.DstAddressingMode)
+	offset += m.DstAddressingMode.length()
+	if srcPAN {
+		m.SrcPANID = binary.LittleEndian.Uint16(data[offset : offset+2])
+		offset += 2
+	} else if m.SrcAddressingMode != Dot15d4AddressingModeNone {
+		// The source PAN ID was elided, so it's the same as the destination's.
+		m.SrcPANID = m.DstPANID
+	}
+	m.SrcAddress = m.decodeAddress(data[offset:], m.SrcAddressingMode)
+	offset += m.SrcAddressingMode.length()
+
+	m.AuxSecurityHeader = nil
+	m.MIC = nil
+	if m.SecurityEnabled {
+		n, err := m.decodeAuxSecurityHeader(data[offset:], df)
+		if err != nil {
+			return err
+		}
+		offset += n
+	}
+
+	m.HeaderIEs = m.HeaderIEs[:0]
+	if m.IEPresent {
+		for {
+			if len(data) < offset+2 {
+				df.SetTruncated()
+				return fmt.Errorf("Dot15d4 length %v too short, %v required", len(data), offset+2)
+			}
+			desc := binary.LittleEndian.Uint16(data[offset : offset+2])
+			length := int(desc & 0x7f)
+			id := uint8(desc >> 7)
+			offset += 2
+			if len(data) < offset+length {
+				df.SetTruncated()
+				return fmt.Errorf("Dot15d4 length %v too short, %v required", len(data), offset+length)
+			}
+			m.HeaderIEs = append(m.HeaderIEs, Dot15d4HeaderIE{ElementID: id, Content: data[offset : offset+length]})
+			offset += length
+			if id == dot15d4HeaderIEHT1 || id == dot15d4HeaderIEHT2 || offset == len(data) {
+				break
+			}
+		}
+	}
+
+	end := len(data)
+	if m.AuxSecurityHeader != nil {
+		micLen := m.AuxSecurityHeader.SecurityLevel.MICLength()
+		if end-offset < micLen {
+			df.SetTruncated()
+			return fmt.Errorf("Dot15d4 length %v too short, %v required", len(data), offset+micLen)
+		}
+		end -= micLen
+		m.MIC = data[end:]
+	}
+	m.BaseLayer = BaseLayer{Contents: data[:offset], Payload: data[offset:end]}
+	return nil
+}
+
+func (m *Dot15d4) decodeAuxSecurityHeader(data []byte, df gopacket.DecodeFeedback) (int, error) {
+	if len(data) < 1 {
+		df.SetTruncated()
+		return 0, fmt.Errorf("Dot15d4 auxiliary security header length %v too short, %v required", len(data), 1)
+	}
+	h := &Dot15d4AuxSecurityHeader{
+		SecurityLevel:     Dot15d4SecurityLevel(data[0] & 0x7),
+		KeyIdentifierMode: Dot15d4KeyIdentifierMode(data[0] >> 3 & 0x3),
+	}
+	if m.FrameVersion >= Dot15d4FrameVersion2015 {
+		h.FrameCounterSuppressed = data[0]&0x20 != 0
+		h.ASNInNonce = data[0]&0x40 != 0
+	}
+	need := 1
+	if !h.FrameCounterSuppressed {
+		need += 4
+	}
+	keySourceLen := 0
+	switch h.KeyIdentifierMode {
+	case Dot15d4KeyIdentifierModeSource4:
+		keySourceLen = 4
+	case Dot15d4KeyIdentifierModeSource8:
+		keySourceLen = 8
+	}
+	if h.KeyIdentifierMode != Dot15d4KeyIdentifierModeImplicit {
+		need += keySourceLen + 1
+	}
+	if len(data) < need {
+		df.SetTruncated()
+		return 0, fmt.Errorf("Dot15d4 auxiliary security header length %v too short, %v required", len(data), need)
+	}
+	offset := 1
+	if !h.FrameCounterSuppressed {
+		h.FrameCounter = binary.LittleEndian.Uint32(data[offset : offset+4])
+		offset += 4
+	}
+	if h.KeyIdentifierMode != Dot15d4KeyIdentifierModeImplicit {
+		h.KeySource = data[offset : offset+keySourceLen]
+		offset += keySourceLen
+		h.KeyIndex = data[offset]
+		offset++
+	}
+	m.AuxSecurityHeader = h
+	return offset, nil
+}
+
+// ChecksumValid returns true if the frame's FCS matches its contents.  It
+// returns false if the frame was captured without an FCS.
+func (m *Dot15d4) ChecksumValid() bool {
+	if !m.HasFCS {
+		return false
+	}
+	crc := dot15d4CRC(0, m.Contents)
+	crc = dot15d4CRC(crc, m.Payload)
+	crc = dot15d4CRC(crc, m.MIC)
+	return crc == m.FCS
+}
+
+// dot15d4CRC computes the ITU-T CRC-16 used as the IEEE 802.15.4 FCS.
+func dot15d4CRC(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0x8408
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+func decodeDot15d4(data []byte, p gopacket.PacketBuilder) error {
+	d := &Dot15d4{}
+	if err := d.decodeFromBytes(data, p, false); err != nil {
+		return err
+	}
+	p.AddLayer(d)
+	return p.NextDecoder(d.nextDecoder())
+}
+
+func decodeDot15d4FCS(data []byte, p gopacket.PacketBuilder) error {
+	d := &Dot15d4{}
+	if err := d.decodeFromBytes(data, p, true); err != nil {
+		return err
+	}
+	p.AddLayer(d)
+	return p.NextDecoder(d.nextDecoder())
+}
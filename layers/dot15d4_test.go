@@ -0,0 +1,131 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketDot15d4Data is a broadcast data frame with short addresses and
+// PAN ID compression, captured with its FCS.
+var testPacketDot15d4Data = []byte{
+	0x41, 0x88, 0x2a, 0xcd, 0xab, 0xff, 0xff, 0x01, 0x00, 0x3f, 0x11, 0x22, 0x33, 0x44, 0x48, 0x90,
+}
+
+func TestPacketDot15d4Data(t *testing.T) {
+	p := gopacket.NewPacket(testPacketDot15d4Data, LinkTypeIEEE802_15_4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeDot15d4, gopacket.LayerTypePayload}, t)
+	got, ok := p.Layer(LayerTypeDot15d4).(*Dot15d4)
+	if !ok {
+		t.Fatal("No Dot15d4 layer")
+	}
+	want := &Dot15d4{
+		BaseLayer:         BaseLayer{testPacketDot15d4Data[:9], testPacketDot15d4Data[9:14]},
+		FrameType:         Dot15d4FrameTypeData,
+		PANIDCompression:  true,
+		DstAddressingMode: Dot15d4AddressingModeShort,
+		SrcAddressingMode: Dot15d4AddressingModeShort,
+		FrameVersion:      Dot15d4FrameVersion2003,
+		SequenceNumber:    0x2a,
+		DstPANID:          0xabcd,
+		SrcPANID:          0xabcd,
+		DstAddress:        0xffff,
+		SrcAddress:        0x0001,
+		HasFCS:            true,
+		FCS:               0x9048,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Dot15d4 layer mismatch, \nwant %#v\ngot  %#v\n", want, got)
+	}
+	if !got.ChecksumValid() {
+		t.Error("Dot15d4 FCS should be valid")
+	}
+}
+
+// testPacketDot15d4Secured is a 2006 data frame from an extended source
+// address to a short destination address, secured with ENC-MIC-32 and a key
+// index.
+var testPacketDot15d4Secured = []byte{
+	0x69, 0xd8, 0x7b, 0x34, 0x12, 0x00, 0x00, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, 0x0d,
+	0x78, 0x56, 0x34, 0x12, 0x01, 0xde, 0xad, 0xbe, 0xef, 0x00, 0x11, 0xa1, 0xa2, 0xa3, 0xa4, 0x11,
+	0x55,
+}
+
+func TestPacketDot15d4Secured(t *testing.T) {
+	p := gopacket.NewPacket(testPacketDot15d4Secured, LinkTypeIEEE802_15_4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got, ok := p.Layer(LayerTypeDot15d4).(*Dot15d4)
+	if !ok {
+		t.Fatal("No Dot15d4 layer")
+	}
+	if got.FrameVersion != Dot15d4FrameVersion2006 || !got.SecurityEnabled || !got.AckRequest {
+		t.Errorf("Unexpected frame control %#v", got)
+	}
+	if got.DstPANID != 0x1234 || got.SrcPANID != 0x1234 {
+		t.Errorf("Unexpected PAN IDs %#x, %#x", got.DstPANID, got.SrcPANID)
+	}
+	if got.DstAddress != 0x0000 || got.SrcAddress != 0x0102030405060708 {
+		t.Errorf("Unexpected addresses %#x, %#x", got.DstAddress, got.SrcAddress)
+	}
+	wantSec := &Dot15d4AuxSecurityHeader{
+		SecurityLevel:     Dot15d4SecurityLevelENCMIC32,
+		KeyIdentifierMode: Dot15d4KeyIdentifierModeIndex,
+		FrameCounter:      0x12345678,
+		KeySource:         []byte{},
+		KeyIndex:          1,
+	}
+	if !reflect.DeepEqual(wantSec, got.AuxSecurityHeader) {
+		t.Errorf("Dot15d4 security header mismatch, \nwant %#v\ngot  %#v\n", wantSec, got.AuxSecurityHeader)
+	}
+	if want := []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x11}; !reflect.DeepEqual(want, got.Payload) {
+		t.Errorf("Dot15d4 payload mismatch, want %x got %x", want, got.Payload)
+	}
+	if want := []byte{0xa1, 0xa2, 0xa3, 0xa4}; !reflect.DeepEqual(want, got.MIC) {
+		t.Errorf("Dot15d4 MIC mismatch, want %x got %x", want, got.MIC)
+	}
+	if !got.ChecksumValid() {
+		t.Error("Dot15d4 FCS should be valid")
+	}
+}
+
+func TestPacketDot15d4AckNoFCS(t *testing.T) {
+	p := gopacket.NewPacket([]byte{0x02, 0x00, 0x2a}, LinkTypeIEEE802_15_4NoFCS, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got, ok := p.Layer(LayerTypeDot15d4).(*Dot15d4)
+	if !ok {
+		t.Fatal("No Dot15d4 layer")
+	}
+	if got.FrameType != Dot15d4FrameTypeAck || got.SequenceNumber != 0x2a || got.HasFCS {
+		t.Errorf("Unexpected ack frame %#v", got)
+	}
+}
+
+func TestPacketDot15d4Truncated(t *testing.T) {
+	p := gopacket.NewPacket(testPacketDot15d4Secured[:12], LinkTypeIEEE802_15_4NoFCS, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("Expected an error decoding a truncated frame")
+	}
+	if !p.Metadata().Truncated {
+		t.Error("Expected the packet to be marked truncated")
+	}
+}
+
+func BenchmarkDecodePacketDot15d4(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		gopacket.NewPacket(testPacketDot15d4Secured, LinkTypeIEEE802_15_4, gopacket.NoCopy)
+	}
+}
@@ -8,6 +8,7 @@ package layers
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/google/gopacket"
 )
@@ -28,10 +29,100 @@ const (
 	EAPTypeIdentity     EAPType = 1
 	EAPTypeNotification EAPType = 2
 	EAPTypeNACK         EAPType = 3
-	EAPTypeOTP          EAPType = 4
-	EAPTypeTokenCard    EAPType = 5
+	EAPTypeMD5Challenge EAPType = 4
+	EAPTypeOTP          EAPType = 5
+	EAPTypeTokenCard    EAPType = 6
+	EAPTypeTLS          EAPType = 13
+	EAPTypeLEAP         EAPType = 17
+	EAPTypeSIM          EAPType = 18
+	EAPTypeTTLS         EAPType = 21
+	EAPTypeAKA          EAPType = 23
+	EAPTypePEAP         EAPType = 25
+	EAPTypeMSCHAPv2     EAPType = 26
+	EAPTypeFAST         EAPType = 43
+	EAPTypePSK          EAPType = 47
+	EAPTypeAKAPrime     EAPType = 50
+	EAPTypePWD          EAPType = 52
+	EAPTypeTEAP         EAPType = 55
+	EAPTypeExpanded     EAPType = 254
+	EAPTypeExperimental EAPType = 255
 )
 
+func (c EAPCode) String() string {
+	switch c {
+	case EAPCodeRequest:
+		return "Request"
+	case EAPCodeResponse:
+		return "Response"
+	case EAPCodeSuccess:
+		return "Success"
+	case EAPCodeFailure:
+		return "Failure"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+var eapTypeNames = map[EAPType]string{
+	EAPTypeNone:         "None",
+	EAPTypeIdentity:     "Identity",
+	EAPTypeNotification: "Notification",
+	EAPTypeNACK:         "NAK",
+	EAPTypeMD5Challenge: "MD5-Challenge",
+	EAPTypeOTP:          "OTP",
+	EAPTypeTokenCard:    "GTC",
+	EAPTypeTLS:          "TLS",
+	EAPTypeLEAP:         "LEAP",
+	EAPTypeSIM:          "SIM",
+	EAPTypeTTLS:         "TTLS",
+	EAPTypeAKA:          "AKA",
+	EAPTypePEAP:         "PEAP",
+	EAPTypeMSCHAPv2:     "MSCHAPv2",
+	EAPTypeFAST:         "FAST",
+	EAPTypePSK:          "PSK",
+	EAPTypeAKAPrime:     "AKA'",
+	EAPTypePWD:          "pwd",
+	EAPTypeTEAP:         "TEAP",
+	EAPTypeExpanded:     "Expanded",
+	EAPTypeExperimental: "Experimental",
+}
+
+func (t EAPType) String() string {
+	if name, ok := eapTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// TLSBased returns true for methods which tunnel TLS records in EAP-TLS
+// style fragments (RFC 5216 section 3.1): TLS, TTLS, PEAP, FAST and TEAP.
+func (t EAPType) TLSBased() bool {
+	switch t {
+	case EAPTypeTLS, EAPTypeTTLS, EAPTypePEAP, EAPTypeFAST, EAPTypeTEAP:
+		return true
+	}
+	return false
+}
+
+// EAPTLSFlags is the flags byte starting the type data of TLS based EAP
+// methods.  Its low 3 bits hold the method version for TTLS, PEAP, FAST and
+// TEAP.
+type EAPTLSFlags uint8
+
+const (
+	// EAPTLSFlagLengthIncluded is set if the total length of the TLS
+	// message follows the flags.
+	EAPTLSFlagLengthIncluded EAPTLSFlags = 0x80
+	// EAPTLSFlagMoreFragments is set on all but the last fragment of a TLS
+	// message.
+	EAPTLSFlagMoreFragments EAPTLSFlags = 0x40
+	// EAPTLSFlagStart is set by the server to start the method.
+	EAPTLSFlagStart EAPTLSFlags = 0x20
+)
+
+// Version returns the method version held by the low 3 bits.
+func (f EAPTLSFlags) Version() uint8 { return uint8(f) & 0x07 }
+
 // EAP defines an Extensible Authentication Protocol (rfc 3748) layer.
 type EAP struct {
 	BaseLayer
@@ -40,6 +131,13 @@ type EAP struct {
 	Length   uint16
 	Type     EAPType
 	TypeData []byte
+	// TLSFlags, TLSMessageLength and TLSData are decoded from TypeData for
+	// TLS based methods, see EAPType.TLSBased.  TLSMessageLength is the
+	// length of the whole, possibly fragmented, TLS message and is only
+	// sent with EAPTLSFlagLengthIncluded.  TLSData is this fragment.
+	TLSFlags         EAPTLSFlags
+	TLSMessageLength uint32
+	TLSData          []byte
 }
 
 // LayerType returns LayerTypeEAP.
@@ -47,19 +145,39 @@ func (e *EAP) LayerType() gopacket.LayerType { return LayerTypeEAP }
 
 // DecodeFromBytes decodes the given bytes into this layer.
 func (e *EAP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("EAP packet too short")
+	}
 	e.Code = EAPCode(data[0])
 	e.Id = data[1]
 	e.Length = binary.BigEndian.Uint16(data[2:4])
+	e.TLSFlags, e.TLSMessageLength, e.TLSData = 0, 0, nil
+	if int(e.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("EAP length %d exceeds %d bytes", e.Length, len(data))
+	}
 	switch {
 	case e.Length > 4:
 		e.Type = EAPType(data[4])
-		e.TypeData = data[5:]
+		e.TypeData = data[5:e.Length]
 	case e.Length == 4:
 		e.Type = 0
 		e.TypeData = nil
 	default:
 		return fmt.Errorf("invalid EAP length %d", e.Length)
 	}
+	if e.Type.TLSBased() && len(e.TypeData) > 0 {
+		e.TLSFlags = EAPTLSFlags(e.TypeData[0])
+		e.TLSData = e.TypeData[1:]
+		if e.TLSFlags&EAPTLSFlagLengthIncluded != 0 {
+			if len(e.TLSData) < 4 {
+				return errors.New("EAP TLS message length truncated")
+			}
+			e.TLSMessageLength = binary.BigEndian.Uint32(e.TLSData[0:4])
+			e.TLSData = e.TLSData[4:]
+		}
+	}
 	e.BaseLayer.Contents = data[:e.Length]
 	e.BaseLayer.Payload = data[e.Length:] // Should be 0 bytes
 	return nil
@@ -69,13 +187,16 @@ func (e *EAP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
 func (e *EAP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	if opts.FixLengths {
-		e.Length = uint16(len(e.TypeData) + 1)
-	}
 	size := len(e.TypeData) + 4
-	if size > 4 {
+	if e.Type != EAPTypeNone {
 		size++
 	}
+	if size > 0xffff {
+		return fmt.Errorf("EAP packet of %d bytes too long", size)
+	}
+	if opts.FixLengths {
+		e.Length = uint16(size)
+	}
 	bytes, err := b.PrependBytes(size)
 	if err != nil {
 		return err
@@ -83,9 +204,11 @@ func (e *EAP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 	bytes[0] = byte(e.Code)
 	bytes[1] = e.Id
 	binary.BigEndian.PutUint16(bytes[2:], e.Length)
-	if size > 4 {
+	if e.Type != EAPTypeNone {
 		bytes[4] = byte(e.Type)
 		copy(bytes[5:], e.TypeData)
+	} else {
+		copy(bytes[4:], e.TypeData)
 	}
 	return nil
 }
@@ -8,6 +8,7 @@ package layers
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/google/gopacket"
 )
@@ -23,19 +24,35 @@ type EAPOL struct {
 // LayerType returns LayerTypeEAPOL.
 func (e *EAPOL) LayerType() gopacket.LayerType { return LayerTypeEAPOL }
 
-// DecodeFromBytes decodes the given bytes into this layer.
+// DecodeFromBytes decodes the given bytes into this layer.  The payload is
+// cut to Length, dropping any Ethernet padding.
 func (e *EAPOL) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("EAPOL packet too short")
+	}
 	e.Version = data[0]
 	e.Type = EAPOLType(data[1])
 	e.Length = binary.BigEndian.Uint16(data[2:4])
-	e.BaseLayer = BaseLayer{data[:4], data[4:]}
+	end := 4 + int(e.Length)
+	if end > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("EAPOL length %d exceeds %d bytes", e.Length, len(data)-4)
+	}
+	e.BaseLayer = BaseLayer{data[:4], data[4:end]}
 	return nil
 }
 
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer
 func (e *EAPOL) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	bytes, _ := b.PrependBytes(4)
+	if opts.FixLengths {
+		e.Length = uint16(len(b.Bytes()))
+	}
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
 	bytes[0] = e.Version
 	bytes[1] = byte(e.Type)
 	binary.BigEndian.PutUint16(bytes[2:], e.Length)
@@ -130,7 +147,12 @@ func (kt EAPOLKeyType) String() string {
 	}
 }
 
-// EAPOLKey defines an EAPOL-Key frame for 802.1x authentication
+// EAPOLKey defines an EAPOL-Key frame for 802.1x authentication.
+//
+// RC4 key descriptors (802.1X-2004 section 7.6) only use KeyLength,
+// ReplayCounter, IV, KeyIndex, KeyType (Pairwise for unicast keys), MIC,
+// which holds the key signature, and EncryptedKeyData, which holds the key
+// if one is sent.  Other descriptors have the 802.11 layout.
 type EAPOLKey struct {
 	BaseLayer
 	KeyDescriptorType    EAPOLKeyDescriptorType
@@ -161,19 +183,33 @@ func (ek *EAPOLKey) LayerType() gopacket.LayerType {
 	return LayerTypeEAPOLKey
 }
 
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (ek *EAPOLKey) CanDecode() gopacket.LayerClass {
+	return LayerTypeEAPOLKey
+}
+
 // NextLayerType returns layers.LayerTypeDot11InformationElement if the key
 // data exists and is unencrypted, otherwise it does not expect a next layer.
 func (ek *EAPOLKey) NextLayerType() gopacket.LayerType {
+	if ek.KeyDescriptorType == EAPOLKeyDescriptorTypeRC4 {
+		return gopacket.LayerTypeZero
+	}
 	if !ek.HasEncryptedKeyData && ek.KeyDataLength > 0 {
 		return LayerTypeDot11InformationElement
 	}
 	return gopacket.LayerTypePayload
 }
 
-const eapolKeyFrameLen = 95
+const (
+	eapolKeyFrameLen    = 95
+	eapolKeyRC4FrameLen = 44
+)
 
 // DecodeFromBytes decodes the given bytes into this layer.
 func (ek *EAPOLKey) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) > 0 && EAPOLKeyDescriptorType(data[0]) == EAPOLKeyDescriptorTypeRC4 {
+		return ek.decodeRC4(data, df)
+	}
 	if len(data) < eapolKeyFrameLen {
 		df.SetTruncated()
 		return fmt.Errorf("EAPOLKey length %v too short, %v required",
@@ -229,10 +265,53 @@ func (ek *EAPOLKey) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) err
 	return nil
 }
 
+// decodeRC4 decodes an RC4 key descriptor, whose key runs to the end of
+// the EAPOL packet.
+func (ek *EAPOLKey) decodeRC4(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < eapolKeyRC4FrameLen {
+		df.SetTruncated()
+		return fmt.Errorf("EAPOLKey RC4 descriptor length %d too short, %d required",
+			len(data), eapolKeyRC4FrameLen)
+	}
+	*ek = EAPOLKey{
+		KeyDescriptorType: EAPOLKeyDescriptorTypeRC4,
+		KeyLength:         binary.BigEndian.Uint16(data[1:3]),
+		ReplayCounter:     binary.BigEndian.Uint64(data[3:11]),
+		IV:                data[11:27],
+		KeyIndex:          data[27] & 0x7f,
+		MIC:               data[28:44],
+	}
+	if data[27]&0x80 != 0 {
+		ek.KeyType = EAPOLKeyTypePairwise
+	}
+	if len(data) > eapolKeyRC4FrameLen {
+		ek.EncryptedKeyData = data[eapolKeyRC4FrameLen:]
+	}
+	ek.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
 func (ek *EAPOLKey) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if ek.KeyDescriptorType == EAPOLKeyDescriptorTypeRC4 {
+		buf, err := b.PrependBytes(eapolKeyRC4FrameLen + len(ek.EncryptedKeyData))
+		if err != nil {
+			return err
+		}
+		buf[0] = byte(ek.KeyDescriptorType)
+		binary.BigEndian.PutUint16(buf[1:3], ek.KeyLength)
+		binary.BigEndian.PutUint64(buf[3:11], ek.ReplayCounter)
+		copy(buf[11:27], ek.IV)
+		buf[27] = ek.KeyIndex & 0x7f
+		if ek.KeyType == EAPOLKeyTypePairwise {
+			buf[27] |= 0x80
+		}
+		copy(buf[28:44], ek.MIC)
+		copy(buf[44:], ek.EncryptedKeyData)
+		return nil
+	}
 	buf, err := b.PrependBytes(eapolKeyFrameLen + len(ek.EncryptedKeyData))
 	if err != nil {
 		return err
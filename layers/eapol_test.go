@@ -121,3 +121,216 @@ func BenchmarkDecodePacketEAPOLKey(b *testing.B) {
 		gopacket.NewPacket(testPacketEAPOLKey, nil, gopacket.NoCopy)
 	}
 }
+
+// testPacketEAPTLS is the first fragment of an EAP-TLS request, with the
+// length of the whole TLS message.
+var testPacketEAPTLS = []byte{
+	0x01, 0x00, 0x00, 0x12, 0x01, 0x05, 0x00, 0x12,
+	0x0d, 0xc0, 0x00, 0x00, 0x00, 0x10, 0x16, 0x03,
+	0x01, 0x00, 0x0b, 0x01, 0x00, 0x00,
+}
+
+func TestPacketEAPTLS(t *testing.T) {
+	p := gopacket.NewPacket(testPacketEAPTLS, LayerTypeEAPOL, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEAPOL, LayerTypeEAP}, t)
+	got := p.Layer(LayerTypeEAP).(*EAP)
+	want := &EAP{
+		BaseLayer: BaseLayer{
+			Contents: testPacketEAPTLS[4:],
+			Payload:  []byte{},
+		},
+		Code:             EAPCodeRequest,
+		Id:               5,
+		Length:           18,
+		Type:             EAPTypeTLS,
+		TypeData:         testPacketEAPTLS[9:],
+		TLSFlags:         EAPTLSFlagLengthIncluded | EAPTLSFlagMoreFragments,
+		TLSMessageLength: 16,
+		TLSData:          testPacketEAPTLS[14:],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(eapolErrFmt, "EAP", got, want)
+	}
+	if got.Type.String() != "TLS" || EAPTypePEAP.String() != "PEAP" || EAPTypeMSCHAPv2.String() != "MSCHAPv2" {
+		t.Errorf("bad type names %v", got.Type)
+	}
+	testSerialization(t, p, testPacketEAPTLS)
+}
+
+// testPacketEAPIdentity is an EAP identity response, padded to the minimum
+// Ethernet frame size.
+var testPacketEAPIdentity = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x03, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x88, 0x8e, 0x01, 0x00, 0x00, 0x0b, 0x02, 0x01, 0x00, 0x0b, 0x01, 0x62,
+	0x6f, 0x62, 0x40, 0x65, 0x78, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestPacketEAPIdentity(t *testing.T) {
+	p := gopacket.NewPacket(testPacketEAPIdentity, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeEAPOL, LayerTypeEAP}, t)
+	if got := p.Layer(LayerTypeEAPOL).(*EAPOL); len(got.Payload) != 11 {
+		t.Errorf("EAPOL payload of %d bytes, want 11", len(got.Payload))
+	}
+	got := p.Layer(LayerTypeEAP).(*EAP)
+	if got.Code != EAPCodeResponse || got.Type != EAPTypeIdentity || string(got.TypeData) != "bob@ex" || got.TLSData != nil {
+		t.Errorf("bad identity response %+v", got)
+	}
+	testSerialization(t, p, testPacketEAPIdentity)
+}
+
+// testPacketEAPOLKeyRC4 is an 802.1X-2004 RC4 key descriptor carrying a
+// unicast key.
+var testPacketEAPOLKeyRC4 = []byte{
+	0x01, 0x03, 0x00, 0x39, 0x01, 0x00, 0x0d, 0x00,
+	0x00, 0x00, 0x00, 0x5b, 0x8d, 0x80, 0x01, 0x10,
+	0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18,
+	0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x83,
+	0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47,
+	0x48, 0x49, 0x4a, 0x4b, 0x4c, 0x4d, 0x4e, 0x4f,
+	0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67,
+	0x68, 0x69, 0x6a, 0x6b, 0x6c,
+}
+
+func TestPacketEAPOLKeyRC4(t *testing.T) {
+	p := gopacket.NewPacket(testPacketEAPOLKeyRC4, LayerTypeEAPOL, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEAPOL, LayerTypeEAPOLKey}, t)
+	got := p.Layer(LayerTypeEAPOLKey).(*EAPOLKey)
+	want := &EAPOLKey{
+		BaseLayer:         BaseLayer{Contents: testPacketEAPOLKeyRC4[4:]},
+		KeyDescriptorType: EAPOLKeyDescriptorTypeRC4,
+		KeyType:           EAPOLKeyTypePairwise,
+		KeyIndex:          3,
+		KeyLength:         13,
+		ReplayCounter:     0x5b8d8001,
+		IV:                testPacketEAPOLKeyRC4[15:31],
+		MIC:               testPacketEAPOLKeyRC4[32:48],
+		EncryptedKeyData:  testPacketEAPOLKeyRC4[48:],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf(eapolErrFmt, "EAPOLKey", got, want)
+	}
+	testSerialization(t, p, testPacketEAPOLKeyRC4)
+}
+
+// testPacketEAPOLMKA is an MKPDU from a key server distributing a SAK,
+// with live peer list, SAK use and distributed SAK parameter sets.
+var testPacketEAPOLMKA = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x03, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x88, 0x8e, 0x03, 0x05, 0x00, 0x98, 0x01, 0x10, 0xe0, 0x22, 0x00, 0x0c,
+	0x29, 0x11, 0x22, 0x33, 0x00, 0x01, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6,
+	0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0x00, 0x00, 0x00, 0x05, 0x00, 0x80,
+	0xc2, 0x01, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x00, 0x00, 0x01, 0x00,
+	0x00, 0x10, 0xb1, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba,
+	0xbb, 0xbc, 0x00, 0x00, 0x00, 0x07, 0x03, 0x70, 0x00, 0x28, 0xa1, 0xa2,
+	0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x04, 0x40, 0x00, 0x1c, 0x00, 0x00, 0x00, 0x01, 0xc0, 0xc1,
+	0xc2, 0xc3, 0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xcb, 0xcc, 0xcd,
+	0xce, 0xcf, 0xd0, 0xd1, 0xd2, 0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xe0, 0xe1,
+	0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea, 0xeb, 0xec, 0xed,
+	0xee, 0xef,
+}
+
+func TestPacketEAPOLMKA(t *testing.T) {
+	p := gopacket.NewPacket(testPacketEAPOLMKA, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeEAPOL, LayerTypeEAPOLMKA}, t)
+	m := p.Layer(LayerTypeEAPOLMKA).(*EAPOLMKA)
+	memberID := [12]byte{0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac}
+	if m.Version != 1 || m.KeyServerPriority != 0x10 || !m.KeyServer || !m.MACsecDesired || m.MACsecCapability != 2 || m.Length != 34 {
+		t.Errorf("bad basic parameter set header %+v", m)
+	}
+	if m.SCI != 0x000c291122330001 || m.MemberID != memberID || m.MessageNumber != 5 || m.AlgorithmAgility != 0x0080c201 {
+		t.Errorf("bad basic parameter set %+v", m)
+	}
+	if !reflect.DeepEqual(m.CAKName, []byte{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("bad CAK name %x", m.CAKName)
+	}
+	if len(m.ParameterSets) != 3 {
+		t.Fatalf("got %d parameter sets, want 3", len(m.ParameterSets))
+	}
+	live := m.FindParameterSet(EAPOLMKAParameterSetLivePeerList)
+	wantPeers := []EAPOLMKAPeer{{
+		MemberID:      [12]byte{0xb1, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xbb, 0xbc},
+		MessageNumber: 7,
+	}}
+	if live == nil || !reflect.DeepEqual(live.Peers, wantPeers) {
+		t.Errorf("bad live peer list %+v", live)
+	}
+	use := m.FindParameterSet(EAPOLMKAParameterSetSAKUse)
+	wantUse := &EAPOLMKASAKUse{
+		LatestAN:                1,
+		LatestTx:                true,
+		LatestRx:                true,
+		HasKeys:                 true,
+		LatestKeyServerMemberID: memberID,
+		LatestKeyNumber:         1,
+		LatestLowestPN:          1,
+	}
+	if use == nil || !reflect.DeepEqual(use.SAKUse, wantUse) {
+		t.Errorf("bad SAK use %+v", use)
+	}
+	sak := m.FindParameterSet(EAPOLMKAParameterSetDistributedSAK)
+	if sak == nil || sak.DistributedSAK.AN != 1 || sak.DistributedSAK.KeyNumber != 1 || sak.DistributedSAK.CipherSuite != 0 || len(sak.DistributedSAK.WrappedSAK) != 24 {
+		t.Errorf("bad distributed SAK %+v", sak)
+	}
+	if m.FindParameterSet(EAPOLMKAParameterSetXPN) != nil {
+		t.Error("found an XPN parameter set")
+	}
+	if len(m.ICV) != 16 || m.ICV[0] != 0xe0 || m.ICVIndicator {
+		t.Errorf("bad ICV %x", m.ICV)
+	}
+	testSerialization(t, p, testPacketEAPOLMKA)
+}
+
+func TestEAPOLMalformed(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		l    gopacket.DecodingLayer
+		data []byte
+	}{
+		{"EAPOL short", &EAPOL{}, testPacketEAPTLS[:3]},
+		{"EAPOL length too long", &EAPOL{}, testPacketEAPTLS[:len(testPacketEAPTLS)-1]},
+		{"EAP short", &EAP{}, testPacketEAPTLS[4:7]},
+		{"EAP length too long", &EAP{}, testPacketEAPTLS[4 : len(testPacketEAPTLS)-1]},
+		{"EAP TLS length truncated", &EAP{}, []byte{1, 5, 0, 8, 13, 0x80, 0, 0}},
+		{"RC4 descriptor short", &EAPOLKey{}, testPacketEAPOLKeyRC4[4:47]},
+		{"MKA short", &EAPOLMKA{}, testPacketEAPOLMKA[18:49]},
+		{"MKA no ICV", &EAPOLMKA{}, testPacketEAPOLMKA[18 : len(testPacketEAPOLMKA)-16]},
+		{"MKA bad peer list", &EAPOLMKA{}, append(append([]byte{}, testPacketEAPOLMKA[18:58]...), 1, 0, 0, 4, 0, 0, 0, 0)},
+	} {
+		if err := c.l.DecodeFromBytes(c.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
+
+func TestEAPOLOversizedInput(t *testing.T) {
+	// A length of 0xffff must not wrap around when the input is larger.
+	data := make([]byte, 70000)
+	data[0], data[1], data[2], data[3] = 2, byte(EAPOLTypeEAP), 0xff, 0xff
+	e := &EAPOL{}
+	if err := e.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Payload) != 0xffff {
+		t.Errorf("got payload of %d bytes, want %d", len(e.Payload), 0xffff)
+	}
+	if err := e.DecodeFromBytes(data[:0xffff+3], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated payload")
+	}
+}
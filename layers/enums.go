@@ -57,8 +57,17 @@ const (
 	EthernetTypeMPLSMulticast               EthernetType = 0x8848
 	EthernetTypeEAPOL                       EthernetType = 0x888e
 	EthernetTypeQinQ                        EthernetType = 0x88a8
+	EthernetTypeSlowProtocols               EthernetType = 0x8809
 	EthernetTypeLinkLayerDiscovery          EthernetType = 0x88cc
+	EthernetTypeMVRP                        EthernetType = 0x88f5
+	EthernetTypeMMRP                        EthernetType = 0x88f6
+	EthernetTypeCFM                         EthernetType = 0x8902
 	EthernetTypeEthernetCTP                 EthernetType = 0x9000
+	EthernetTypeERSPANII                    EthernetType = 0x88be
+	EthernetTypeERSPANIII                   EthernetType = 0x22eb
+	EthernetTypeNSH                         EthernetType = 0x894f
+	EthernetTypeMACsec                      EthernetType = 0x88e5
+	EthernetTypeEtherCAT                    EthernetType = 0x88a4
 )
 
 // IPProtocol is an enumeration of IP protocol values, and acts as a decoder
@@ -85,6 +94,7 @@ const (
 	IPProtocolOSPF            IPProtocol = 89
 	IPProtocolIPIP            IPProtocol = 94
 	IPProtocolEtherIP         IPProtocol = 97
+	IPProtocolPIM             IPProtocol = 103
 	IPProtocolVRRP            IPProtocol = 112
 	IPProtocolSCTP            IPProtocol = 132
 	IPProtocolUDPLite         IPProtocol = 136
@@ -93,45 +103,55 @@ const (
 
 // LinkType is an enumeration of link types, and acts as a decoder for any
 // link type it supports.
-type LinkType uint8
+type LinkType uint16
 
 const (
 	// According to pcap-linktype(7) and http://www.tcpdump.org/linktypes.html
-	LinkTypeNull           LinkType = 0
-	LinkTypeEthernet       LinkType = 1
-	LinkTypeAX25           LinkType = 3
-	LinkTypeTokenRing      LinkType = 6
-	LinkTypeArcNet         LinkType = 7
-	LinkTypeSLIP           LinkType = 8
-	LinkTypePPP            LinkType = 9
-	LinkTypeFDDI           LinkType = 10
-	LinkTypePPP_HDLC       LinkType = 50
-	LinkTypePPPEthernet    LinkType = 51
-	LinkTypeATM_RFC1483    LinkType = 100
-	LinkTypeRaw            LinkType = 101
-	LinkTypeC_HDLC         LinkType = 104
-	LinkTypeIEEE802_11     LinkType = 105
-	LinkTypeFRelay         LinkType = 107
-	LinkTypeLoop           LinkType = 108
-	LinkTypeLinuxSLL       LinkType = 113
-	LinkTypeLTalk          LinkType = 114
-	LinkTypePFLog          LinkType = 117
-	LinkTypePrismHeader    LinkType = 119
-	LinkTypeIPOverFC       LinkType = 122
-	LinkTypeSunATM         LinkType = 123
-	LinkTypeIEEE80211Radio LinkType = 127
-	LinkTypeARCNetLinux    LinkType = 129
-	LinkTypeIPOver1394     LinkType = 138
-	LinkTypeMTP2Phdr       LinkType = 139
-	LinkTypeMTP2           LinkType = 140
-	LinkTypeMTP3           LinkType = 141
-	LinkTypeSCCP           LinkType = 142
-	LinkTypeDOCSIS         LinkType = 143
-	LinkTypeLinuxIRDA      LinkType = 144
-	LinkTypeLinuxLAPD      LinkType = 177
-	LinkTypeLinuxUSB       LinkType = 220
-	LinkTypeIPv4           LinkType = 228
-	LinkTypeIPv6           LinkType = 229
+	LinkTypeNull              LinkType = 0
+	LinkTypeEthernet          LinkType = 1
+	LinkTypeAX25              LinkType = 3
+	LinkTypeTokenRing         LinkType = 6
+	LinkTypeArcNet            LinkType = 7
+	LinkTypeSLIP              LinkType = 8
+	LinkTypePPP               LinkType = 9
+	LinkTypeFDDI              LinkType = 10
+	LinkTypePPP_HDLC          LinkType = 50
+	LinkTypePPPEthernet       LinkType = 51
+	LinkTypeATM_RFC1483       LinkType = 100
+	LinkTypeRaw               LinkType = 101
+	LinkTypeC_HDLC            LinkType = 104
+	LinkTypeIEEE802_11        LinkType = 105
+	LinkTypeFRelay            LinkType = 107
+	LinkTypeLoop              LinkType = 108
+	LinkTypeLinuxSLL          LinkType = 113
+	LinkTypeLTalk             LinkType = 114
+	LinkTypePFLog             LinkType = 117
+	LinkTypePrismHeader       LinkType = 119
+	LinkTypeIPOverFC          LinkType = 122
+	LinkTypeSunATM            LinkType = 123
+	LinkTypeIEEE80211Radio    LinkType = 127
+	LinkTypeARCNetLinux       LinkType = 129
+	LinkTypeIPOver1394        LinkType = 138
+	LinkTypeMTP2Phdr          LinkType = 139
+	LinkTypeMTP2              LinkType = 140
+	LinkTypeMTP3              LinkType = 141
+	LinkTypeSCCP              LinkType = 142
+	LinkTypeDOCSIS            LinkType = 143
+	LinkTypeLinuxIRDA         LinkType = 144
+	LinkTypeLinuxLAPD         LinkType = 177
+	LinkTypeBluetoothHCIH4    LinkType = 187
+	LinkTypeLinuxUSBLegacy    LinkType = 189
+	LinkTypeIEEE802_15_4      LinkType = 195
+	LinkTypeLinuxUSB          LinkType = 220
+	LinkTypeCANSocketCAN      LinkType = 227
+	LinkTypeIEEE802_15_4NoFCS LinkType = 230
+	LinkTypeIPv4              LinkType = 228
+	LinkTypeIPv6              LinkType = 229
+	LinkTypeLinuxSLL2         LinkType = 276
+
+	// LinkTypeBluetoothHCIH4WithPHDR is LinkTypeBluetoothHCIH4 preceded by
+	// a 4 byte direction pseudo-header.
+	LinkTypeBluetoothHCIH4WithPHDR LinkType = 201
 )
 
 // PPPoECode is the PPPoE code enum, taken from http://tools.ietf.org/html/rfc2516
@@ -155,6 +175,11 @@ const (
 	PPPTypeIPv6          PPPType = 0x0057
 	PPPTypeMPLSUnicast   PPPType = 0x0281
 	PPPTypeMPLSMulticast PPPType = 0x0283
+	PPPTypeIPCP          PPPType = 0x8021
+	PPPTypeIPv6CP        PPPType = 0x8057
+	PPPTypeLCP           PPPType = 0xc021
+	PPPTypePAP           PPPType = 0xc023
+	PPPTypeCHAP          PPPType = 0xc223
 )
 
 // SCTPChunkType is an enumeration of chunk types inside SCTP packets.
@@ -192,6 +217,12 @@ const (
 	EAPOLTypeLogOff   EAPOLType = 2
 	EAPOLTypeKey      EAPOLType = 3
 	EAPOLTypeASFAlert EAPOLType = 4
+	EAPOLTypeMKA      EAPOLType = 5
+	// EAPOL-Announcements are sent for network discovery, see 802.1X-2010
+	// section 11.12.
+	EAPOLTypeAnnouncementGeneric  EAPOLType = 6
+	EAPOLTypeAnnouncementSpecific EAPOLType = 7
+	EAPOLTypeAnnouncementRequest  EAPOLType = 8
 )
 
 // ProtocolFamily is the set of values defined as PF_* in sys/socket.h
@@ -205,6 +236,8 @@ const (
 	ProtocolFamilyIPv6FreeBSD ProtocolFamily = 28
 	ProtocolFamilyIPv6Darwin  ProtocolFamily = 30
 	ProtocolFamilyIPv6Linux   ProtocolFamily = 10
+	// Windows' AF_INET6, as written by some Npcap loopback captures.
+	ProtocolFamilyIPv6Windows ProtocolFamily = 23
 )
 
 // Dot11Type is a combination of IEEE 802.11 frame's Type and Subtype fields.
@@ -323,7 +356,16 @@ func initActualTypeData() {
 	EthernetTypeMetadata[EthernetTypeMPLSMulticast] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMPLS), Name: "MPLSMulticast", LayerType: LayerTypeMPLS}
 	EthernetTypeMetadata[EthernetTypeEAPOL] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEAPOL), Name: "EAPOL", LayerType: LayerTypeEAPOL}
 	EthernetTypeMetadata[EthernetTypeQinQ] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeDot1Q), Name: "Dot1Q", LayerType: LayerTypeDot1Q}
+	EthernetTypeMetadata[EthernetTypeSlowProtocols] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSlowProtocol), Name: "SlowProtocols"}
+	EthernetTypeMetadata[EthernetTypeCFM] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeCFM), Name: "CFM", LayerType: LayerTypeCFM}
+	EthernetTypeMetadata[EthernetTypeMVRP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMRP), Name: "MVRP", LayerType: LayerTypeMRP}
+	EthernetTypeMetadata[EthernetTypeMMRP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMRP), Name: "MMRP", LayerType: LayerTypeMRP}
 	EthernetTypeMetadata[EthernetTypeTransparentEthernetBridging] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEthernet), Name: "TransparentEthernetBridging", LayerType: LayerTypeEthernet}
+	EthernetTypeMetadata[EthernetTypeERSPANII] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeERSPANII), Name: "ERSPANII", LayerType: LayerTypeERSPANII}
+	EthernetTypeMetadata[EthernetTypeERSPANIII] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeERSPANIII), Name: "ERSPANIII", LayerType: LayerTypeERSPANIII}
+	EthernetTypeMetadata[EthernetTypeNSH] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeNSH), Name: "NSH", LayerType: LayerTypeNSH}
+	EthernetTypeMetadata[EthernetTypeMACsec] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMACsec), Name: "MACsec", LayerType: LayerTypeMACsec}
+	EthernetTypeMetadata[EthernetTypeEtherCAT] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEtherCAT), Name: "EtherCAT", LayerType: LayerTypeEtherCAT}
 
 	IPProtocolMetadata[IPProtocolIPv4] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv4), Name: "IPv4", LayerType: LayerTypeIPv4}
 	IPProtocolMetadata[IPProtocolTCP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeTCP), Name: "TCP", LayerType: LayerTypeTCP}
@@ -347,6 +389,7 @@ func initActualTypeData() {
 	IPProtocolMetadata[IPProtocolMPLSInIP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMPLS), Name: "MPLS", LayerType: LayerTypeMPLS}
 	IPProtocolMetadata[IPProtocolNoNextHeader] = EnumMetadata{DecodeWith: gopacket.DecodePayload, Name: "NoNextHeader", LayerType: gopacket.LayerTypePayload}
 	IPProtocolMetadata[IPProtocolIGMP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIGMP), Name: "IGMP", LayerType: LayerTypeIGMP}
+	IPProtocolMetadata[IPProtocolPIM] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePIM), Name: "PIM", LayerType: LayerTypePIM}
 	IPProtocolMetadata[IPProtocolVRRP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeVRRP), Name: "VRRP", LayerType: LayerTypeVRRP}
 
 	SCTPChunkTypeMetadata[SCTPChunkTypeData] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPData), Name: "Data"}
@@ -363,15 +406,21 @@ func initActualTypeData() {
 	SCTPChunkTypeMetadata[SCTPChunkTypeCookieAck] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPEmptyLayer), Name: "CookieAck"}
 	SCTPChunkTypeMetadata[SCTPChunkTypeShutdownComplete] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeSCTPEmptyLayer), Name: "ShutdownComplete"}
 
-	PPPTypeMetadata[PPPTypeIPv4] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv4), Name: "IPv4"}
-	PPPTypeMetadata[PPPTypeIPv6] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6), Name: "IPv6"}
-	PPPTypeMetadata[PPPTypeMPLSUnicast] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMPLS), Name: "MPLSUnicast"}
-	PPPTypeMetadata[PPPTypeMPLSMulticast] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMPLS), Name: "MPLSMulticast"}
+	PPPTypeMetadata[PPPTypeIPv4] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv4), Name: "IPv4", LayerType: LayerTypeIPv4}
+	PPPTypeMetadata[PPPTypeIPv6] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6), Name: "IPv6", LayerType: LayerTypeIPv6}
+	PPPTypeMetadata[PPPTypeMPLSUnicast] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMPLS), Name: "MPLSUnicast", LayerType: LayerTypeMPLS}
+	PPPTypeMetadata[PPPTypeMPLSMulticast] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeMPLS), Name: "MPLSMulticast", LayerType: LayerTypeMPLS}
+	PPPTypeMetadata[PPPTypeIPCP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPCP), Name: "IPCP", LayerType: LayerTypeIPCP}
+	PPPTypeMetadata[PPPTypeLCP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLCP), Name: "LCP", LayerType: LayerTypeLCP}
+	PPPTypeMetadata[PPPTypeIPv6CP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6CP), Name: "IPv6CP", LayerType: LayerTypeIPv6CP}
+	PPPTypeMetadata[PPPTypePAP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePPPPAP), Name: "PAP", LayerType: LayerTypePPPPAP}
+	PPPTypeMetadata[PPPTypeCHAP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePPPCHAP), Name: "CHAP", LayerType: LayerTypePPPCHAP}
 
 	PPPoECodeMetadata[PPPoECodeSession] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePPP), Name: "PPP"}
 
 	LinkTypeMetadata[LinkTypeEthernet] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEthernet), Name: "Ethernet"}
 	LinkTypeMetadata[LinkTypePPP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePPP), Name: "PPP"}
+	LinkTypeMetadata[LinkTypePPP_HDLC] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePPPSerial), Name: "PPP_HDLC"}
 	LinkTypeMetadata[LinkTypeFDDI] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeFDDI), Name: "FDDI"}
 	LinkTypeMetadata[LinkTypeNull] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLoopback), Name: "Null"}
 	LinkTypeMetadata[LinkTypeIEEE802_11] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeDot11), Name: "Dot11"}
@@ -388,19 +437,30 @@ func initActualTypeData() {
 	LinkTypeMetadata[LinkTypePFLog] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePFLog), Name: "PFLog"}
 	LinkTypeMetadata[LinkTypeIEEE80211Radio] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeRadioTap), Name: "RadioTap"}
 	LinkTypeMetadata[LinkTypeLinuxUSB] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeUSB), Name: "USB"}
+	LinkTypeMetadata[LinkTypeLinuxUSBLegacy] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeUSBLegacy), Name: "USB legacy", LayerType: LayerTypeUSB}
 	LinkTypeMetadata[LinkTypeLinuxSLL] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLinuxSLL), Name: "Linux SLL"}
+	LinkTypeMetadata[LinkTypeBluetoothHCIH4] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeBluetoothHCIH4), Name: "Bluetooth HCI H4", LayerType: LayerTypeBluetoothHCIH4}
+	LinkTypeMetadata[LinkTypeBluetoothHCIH4WithPHDR] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeBluetoothHCIH4WithPHDR), Name: "Bluetooth HCI H4 with pseudo-header", LayerType: LayerTypeBluetoothHCIH4}
+	LinkTypeMetadata[LinkTypeCANSocketCAN] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeCAN), Name: "CAN SocketCAN", LayerType: LayerTypeCAN}
+	LinkTypeMetadata[LinkTypeLinuxSLL2] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLinuxSLL2), Name: "Linux SLL2", LayerType: LayerTypeLinuxSLL2}
 	LinkTypeMetadata[LinkTypePrismHeader] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodePrismHeader), Name: "Prism"}
+	LinkTypeMetadata[LinkTypeC_HDLC] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeCiscoHDLC), Name: "C_HDLC"}
+	LinkTypeMetadata[LinkTypeFRelay] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeFrameRelay), Name: "FRelay"}
+	LinkTypeMetadata[LinkTypeIEEE802_15_4] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeDot15d4FCS), Name: "802.15.4"}
+	LinkTypeMetadata[LinkTypeIEEE802_15_4NoFCS] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeDot15d4), Name: "802.15.4 no FCS"}
 
 	FDDIFrameControlMetadata[FDDIFrameControlLLC] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeLLC), Name: "LLC"}
 
 	EAPOLTypeMetadata[EAPOLTypeEAP] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEAP), Name: "EAP", LayerType: LayerTypeEAP}
 	EAPOLTypeMetadata[EAPOLTypeKey] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEAPOLKey), Name: "EAPOLKey", LayerType: LayerTypeEAPOLKey}
+	EAPOLTypeMetadata[EAPOLTypeMKA] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeEAPOLMKA), Name: "EAPOLMKA", LayerType: LayerTypeEAPOLMKA}
 
 	ProtocolFamilyMetadata[ProtocolFamilyIPv4] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv4), Name: "IPv4", LayerType: LayerTypeIPv4}
 	ProtocolFamilyMetadata[ProtocolFamilyIPv6BSD] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6), Name: "IPv6", LayerType: LayerTypeIPv6}
 	ProtocolFamilyMetadata[ProtocolFamilyIPv6FreeBSD] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6), Name: "IPv6", LayerType: LayerTypeIPv6}
 	ProtocolFamilyMetadata[ProtocolFamilyIPv6Darwin] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6), Name: "IPv6", LayerType: LayerTypeIPv6}
 	ProtocolFamilyMetadata[ProtocolFamilyIPv6Linux] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6), Name: "IPv6", LayerType: LayerTypeIPv6}
+	ProtocolFamilyMetadata[ProtocolFamilyIPv6Windows] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeIPv6), Name: "IPv6", LayerType: LayerTypeIPv6}
 
 	Dot11TypeMetadata[Dot11TypeMgmtAssociationReq] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeDot11MgmtAssociationReq), Name: "MgmtAssociationReq", LayerType: LayerTypeDot11MgmtAssociationReq}
 	Dot11TypeMetadata[Dot11TypeMgmtAssociationResp] = EnumMetadata{DecodeWith: gopacket.DecodeFunc(decodeDot11MgmtAssociationResp), Name: "MgmtAssociationResp", LayerType: LayerTypeDot11MgmtAssociationResp}
@@ -0,0 +1,262 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// ERSPAN (Encapsulated Remote SPAN) carries mirrored frames inside GRE.  It is
+// described in https://tools.ietf.org/html/draft-foschiano-erspan-03.
+//
+// Type I sessions use GRE protocol 0x88BE without a sequence number and have
+// no ERSPAN header at all; the mirrored frame directly follows GRE.  Type II
+// sessions use the same protocol type with a GRE sequence number, followed by
+// an 8 byte header:
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |  Ver  |          VLAN         | COS | En|T|    Session ID     |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |      Reserved         |                  Index                |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// Type III sessions use GRE protocol 0x22EB and a 12 byte header, optionally
+// followed by an 8 byte platform specific subheader:
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |  Ver  |          VLAN         | COS |BSO|T|     Session ID    |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                          Timestamp                            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |             SGT               |P|    FT   |   Hw ID   |D|Gra|O|
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |  Platf ID |               Platform Specific Info              |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                  Platform Specific Info                       |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+const (
+	erspanIILength         = 8
+	erspanIIILength        = 12
+	erspanIIISubheaderSize = 8
+)
+
+// ERSPANEncapType describes how the mirrored frame was tagged on the source
+// port, carried in the En field of an ERSPAN Type II header.
+type ERSPANEncapType uint8
+
+const (
+	ERSPANEncapTypeUntagged    ERSPANEncapType = 0
+	ERSPANEncapTypeISL         ERSPANEncapType = 1
+	ERSPANEncapType8021Q       ERSPANEncapType = 2
+	ERSPANEncapTypePreserveTag ERSPANEncapType = 3
+)
+
+func (e ERSPANEncapType) String() string {
+	switch e {
+	case ERSPANEncapTypeUntagged:
+		return "Untagged"
+	case ERSPANEncapTypeISL:
+		return "ISL"
+	case ERSPANEncapType8021Q:
+		return "802.1Q"
+	case ERSPANEncapTypePreserveTag:
+		return "PreserveTag"
+	default:
+		return "Unknown"
+	}
+}
+
+// ERSPANII is an ERSPAN Type II header.
+type ERSPANII struct {
+	BaseLayer
+	Version   uint8
+	VLAN      uint16
+	COS       uint8
+	Encap     ERSPANEncapType
+	Truncated bool
+	SessionID uint16
+	Index     uint32
+}
+
+// LayerType returns LayerTypeERSPANII.
+func (e *ERSPANII) LayerType() gopacket.LayerType { return LayerTypeERSPANII }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (e *ERSPANII) CanDecode() gopacket.LayerClass { return LayerTypeERSPANII }
+
+// NextLayerType returns LayerTypeEthernet, the mirrored frame.
+func (e *ERSPANII) NextLayerType() gopacket.LayerType { return LayerTypeEthernet }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (e *ERSPANII) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < erspanIILength {
+		df.SetTruncated()
+		return errors.New("ERSPAN Type II header too short")
+	}
+	e.Version = data[0] >> 4
+	if e.Version != 1 {
+		return fmt.Errorf("ERSPAN Type II header with version %d", e.Version)
+	}
+	e.VLAN = binary.BigEndian.Uint16(data[0:2]) & 0x0fff
+	e.COS = data[2] >> 5
+	e.Encap = ERSPANEncapType(data[2]>>3) & 0x3
+	e.Truncated = data[2]&0x04 != 0
+	e.SessionID = binary.BigEndian.Uint16(data[2:4]) & 0x03ff
+	e.Index = binary.BigEndian.Uint32(data[4:8]) & 0x000fffff
+	e.BaseLayer = BaseLayer{data[:erspanIILength], data[erspanIILength:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (e *ERSPANII) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(erspanIILength)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], uint16(e.Version)<<12|e.VLAN&0x0fff)
+	binary.BigEndian.PutUint16(bytes[2:4], uint16(e.COS&0x7)<<13|uint16(e.Encap&0x3)<<11|e.SessionID&0x03ff)
+	if e.Truncated {
+		bytes[2] |= 0x04
+	}
+	binary.BigEndian.PutUint32(bytes[4:8], e.Index&0x000fffff)
+	return nil
+}
+
+func decodeERSPANII(data []byte, p gopacket.PacketBuilder) error {
+	e := &ERSPANII{}
+	return decodingLayerDecoder(e, data, p)
+}
+
+// ERSPANIII is an ERSPAN Type III header.
+type ERSPANIII struct {
+	BaseLayer
+	Version   uint8
+	VLAN      uint16
+	COS       uint8
+	BSO       uint8 // Bad/short/oversized frame indication.
+	Truncated bool
+	SessionID uint16
+	// Timestamp is in units given by Granularity.
+	Timestamp uint32
+	// SGT is the security group tag of the mirrored frame.
+	SGT         uint16
+	PDU         bool  // Set if the mirrored frame is a PDU rather than a frame.
+	FrameType   uint8 // 0 for Ethernet, 2 for IP.
+	HardwareID  uint8
+	Egress      bool // Set if the frame was mirrored as it left the port.
+	Granularity uint8
+	// Subheader is set if a platform specific subheader is present, in which
+	// case PlatformID and PlatformInfo hold its contents.
+	Subheader    bool
+	PlatformID   uint8
+	PlatformInfo uint64 // Low 58 bits of the subheader.
+}
+
+// LayerType returns LayerTypeERSPANIII.
+func (e *ERSPANIII) LayerType() gopacket.LayerType { return LayerTypeERSPANIII }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (e *ERSPANIII) CanDecode() gopacket.LayerClass { return LayerTypeERSPANIII }
+
+// NextLayerType returns LayerTypeEthernet for mirrored Ethernet frames, and
+// the payload otherwise.
+func (e *ERSPANIII) NextLayerType() gopacket.LayerType {
+	if e.FrameType == 0 {
+		return LayerTypeEthernet
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (e *ERSPANIII) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < erspanIIILength {
+		df.SetTruncated()
+		return errors.New("ERSPAN Type III header too short")
+	}
+	e.Version = data[0] >> 4
+	if e.Version != 2 {
+		return fmt.Errorf("ERSPAN Type III header with version %d", e.Version)
+	}
+	e.VLAN = binary.BigEndian.Uint16(data[0:2]) & 0x0fff
+	e.COS = data[2] >> 5
+	e.BSO = (data[2] >> 3) & 0x3
+	e.Truncated = data[2]&0x04 != 0
+	e.SessionID = binary.BigEndian.Uint16(data[2:4]) & 0x03ff
+	e.Timestamp = binary.BigEndian.Uint32(data[4:8])
+	e.SGT = binary.BigEndian.Uint16(data[8:10])
+	e.PDU = data[10]&0x80 != 0
+	e.FrameType = (data[10] >> 2) & 0x1f
+	e.HardwareID = byte(binary.BigEndian.Uint16(data[10:12])>>4) & 0x3f
+	e.Egress = data[11]&0x08 != 0
+	e.Granularity = (data[11] >> 1) & 0x3
+	e.Subheader = data[11]&0x01 != 0
+	length := erspanIIILength
+	if e.Subheader {
+		length += erspanIIISubheaderSize
+		if len(data) < length {
+			df.SetTruncated()
+			return errors.New("ERSPAN Type III platform specific subheader too short")
+		}
+		sub := binary.BigEndian.Uint64(data[erspanIIILength:length])
+		e.PlatformID = uint8(sub >> 58)
+		e.PlatformInfo = sub & (1<<58 - 1)
+	} else {
+		e.PlatformID, e.PlatformInfo = 0, 0
+	}
+	e.BaseLayer = BaseLayer{data[:length], data[length:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (e *ERSPANIII) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := erspanIIILength
+	if e.Subheader {
+		length += erspanIIISubheaderSize
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], uint16(e.Version)<<12|e.VLAN&0x0fff)
+	binary.BigEndian.PutUint16(bytes[2:4], uint16(e.COS&0x7)<<13|uint16(e.BSO&0x3)<<11|e.SessionID&0x03ff)
+	if e.Truncated {
+		bytes[2] |= 0x04
+	}
+	binary.BigEndian.PutUint32(bytes[4:8], e.Timestamp)
+	binary.BigEndian.PutUint16(bytes[8:10], e.SGT)
+	flags := uint16(e.FrameType&0x1f)<<10 | uint16(e.HardwareID&0x3f)<<4 | uint16(e.Granularity&0x3)<<1
+	if e.PDU {
+		flags |= 0x8000
+	}
+	if e.Egress {
+		flags |= 0x0008
+	}
+	if e.Subheader {
+		flags |= 0x0001
+		binary.BigEndian.PutUint64(bytes[erspanIIILength:], uint64(e.PlatformID&0x3f)<<58|e.PlatformInfo&(1<<58-1))
+	}
+	binary.BigEndian.PutUint16(bytes[10:12], flags)
+	return nil
+}
+
+func decodeERSPANIII(data []byte, p gopacket.PacketBuilder) error {
+	e := &ERSPANIII{}
+	return decodingLayerDecoder(e, data, p)
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketERSPANII is an ERSPAN Type II session as sent by a Nexus switch:
+// GRE with a sequence number, then the Type II header for session 10 on
+// VLAN 100, then the mirrored ICMP echo request.
+var testPacketERSPANII = []byte{
+	0x00, 0x00, 0x5e, 0x00, 0x01, 0x01, 0x00, 0x50, 0x56, 0x8a, 0x0b, 0x0c,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x52, 0x01, 0x00, 0x40, 0x00, 0x40, 0x2f,
+	0x25, 0x7b, 0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02, 0x10, 0x00,
+	0x88, 0xbe, 0x00, 0x00, 0x00, 0x05, 0x10, 0x64, 0x00, 0x0a, 0x00, 0x00,
+	0x00, 0x2a, 0x00, 0x0c, 0x29, 0xaa, 0xbb, 0xcc, 0x00, 0x0c, 0x29, 0xdd,
+	0xee, 0xff, 0x08, 0x00, 0x45, 0x00, 0x00, 0x20, 0x00, 0x01, 0x40, 0x00,
+	0x40, 0x01, 0xa5, 0x88, 0xc0, 0xa8, 0x0a, 0x01, 0xc0, 0xa8, 0x0a, 0x02,
+	0x08, 0x00, 0x06, 0xfa, 0x12, 0x34, 0x00, 0x01, 0x70, 0x69, 0x6e, 0x67,
+}
+
+// testPacketERSPANI is the same mirrored frame as sent by a Catalyst switch
+// using ERSPAN Type I: GRE protocol 0x88BE with no sequence number and no
+// ERSPAN header.
+var testPacketERSPANI = []byte{
+	0x00, 0x00, 0x5e, 0x00, 0x01, 0x01, 0x00, 0x50, 0x56, 0x8a, 0x0b, 0x0c,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x46, 0x01, 0x00, 0x40, 0x00, 0x40, 0x2f,
+	0x25, 0x87, 0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02, 0x00, 0x00,
+	0x88, 0xbe, 0x00, 0x0c, 0x29, 0xaa, 0xbb, 0xcc, 0x00, 0x0c, 0x29, 0xdd,
+	0xee, 0xff, 0x08, 0x00, 0x45, 0x00, 0x00, 0x20, 0x00, 0x01, 0x40, 0x00,
+	0x40, 0x01, 0xa5, 0x88, 0xc0, 0xa8, 0x0a, 0x01, 0xc0, 0xa8, 0x0a, 0x02,
+	0x08, 0x00, 0x06, 0xfa, 0x12, 0x34, 0x00, 0x01, 0x70, 0x69, 0x6e, 0x67,
+}
+
+// testPacketERSPANIII is an ERSPAN Type III session with a platform specific
+// subheader.
+var testPacketERSPANIII = []byte{
+	0x00, 0x00, 0x5e, 0x00, 0x01, 0x01, 0x00, 0x50, 0x56, 0x8a, 0x0b, 0x0c,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x5e, 0x01, 0x00, 0x40, 0x00, 0x40, 0x2f,
+	0x25, 0x6f, 0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02, 0x10, 0x00,
+	0x22, 0xeb, 0x00, 0x00, 0x00, 0x07, 0x20, 0xc8, 0xa5, 0x2c, 0x12, 0x34,
+	0x56, 0x78, 0x00, 0x64, 0x00, 0x5f, 0x0c, 0x00, 0x00, 0x00, 0x00, 0xab,
+	0xcd, 0xef, 0x00, 0x0c, 0x29, 0xaa, 0xbb, 0xcc, 0x00, 0x0c, 0x29, 0xdd,
+	0xee, 0xff, 0x08, 0x00, 0x45, 0x00, 0x00, 0x20, 0x00, 0x01, 0x40, 0x00,
+	0x40, 0x01, 0xa5, 0x88, 0xc0, 0xa8, 0x0a, 0x01, 0xc0, 0xa8, 0x0a, 0x02,
+	0x08, 0x00, 0x06, 0xfa, 0x12, 0x34, 0x00, 0x01, 0x70, 0x69, 0x6e, 0x67,
+}
+
+func TestDecodeERSPANII(t *testing.T) {
+	p := gopacket.NewPacket(testPacketERSPANII, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{
+		LayerTypeEthernet, LayerTypeIPv4, LayerTypeGRE, LayerTypeERSPANII,
+		LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload,
+	}, t)
+	got, ok := p.Layer(LayerTypeERSPANII).(*ERSPANII)
+	if !ok {
+		t.Fatal("No ERSPANII layer found")
+	}
+	want := &ERSPANII{
+		BaseLayer: BaseLayer{
+			Contents: testPacketERSPANII[42:50],
+			Payload:  testPacketERSPANII[50:],
+		},
+		Version:   1,
+		VLAN:      100,
+		Encap:     ERSPANEncapTypeUntagged,
+		SessionID: 10,
+		Index:     0x2a,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("ERSPANII layer mismatch, \nwant %#v\ngot  %#v\n", want, got)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := got.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(buf.Bytes(), got.Contents) {
+		t.Errorf("ERSPANII serialization mismatch\nwant %x\ngot  %x", got.Contents, buf.Bytes())
+	}
+}
+
+func TestDecodeERSPANI(t *testing.T) {
+	p := gopacket.NewPacket(testPacketERSPANI, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{
+		LayerTypeEthernet, LayerTypeIPv4, LayerTypeGRE,
+		LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload,
+	}, t)
+}
+
+func TestDecodeERSPANIII(t *testing.T) {
+	p := gopacket.NewPacket(testPacketERSPANIII, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{
+		LayerTypeEthernet, LayerTypeIPv4, LayerTypeGRE, LayerTypeERSPANIII,
+		LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload,
+	}, t)
+	got, ok := p.Layer(LayerTypeERSPANIII).(*ERSPANIII)
+	if !ok {
+		t.Fatal("No ERSPANIII layer found")
+	}
+	want := &ERSPANIII{
+		BaseLayer: BaseLayer{
+			Contents: testPacketERSPANIII[42:62],
+			Payload:  testPacketERSPANIII[62:],
+		},
+		Version:      2,
+		VLAN:         200,
+		COS:          5,
+		Truncated:    true,
+		SessionID:    300,
+		Timestamp:    0x12345678,
+		SGT:          100,
+		HardwareID:   5,
+		Egress:       true,
+		Granularity:  3,
+		Subheader:    true,
+		PlatformID:   3,
+		PlatformInfo: 0xabcdef,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("ERSPANIII layer mismatch, \nwant %#v\ngot  %#v\n", want, got)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := got.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(buf.Bytes(), got.Contents) {
+		t.Errorf("ERSPANIII serialization mismatch\nwant %x\ngot  %x", got.Contents, buf.Bytes())
+	}
+}
+
+func TestDecodeERSPANTruncated(t *testing.T) {
+	for _, data := range [][]byte{
+		testPacketERSPANII[42:48],
+		testPacketERSPANIII[42:58],
+	} {
+		var e gopacket.DecodingLayer = &ERSPANII{}
+		if data[0]>>4 == 2 {
+			e = &ERSPANIII{}
+		}
+		if err := e.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%x: expected decode error", data)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// ESMCITUSubtype is the ITU-T subtype of an ESMC PDU within the OSSP slow
+// protocol.
+const ESMCITUSubtype = 0x0001
+
+// esmcOUI is the ITU-T organizationally unique identifier.
+var esmcOUI = [3]byte{0x00, 0x19, 0xa7}
+
+// ESMCSSMCode is a G.781 synchronization status message code.  Its meaning
+// depends on the synchronization network option in use.
+type ESMCSSMCode uint8
+
+var esmcOptionINames = map[ESMCSSMCode]string{
+	0x2: "PRC",
+	0x4: "SSU-A",
+	0x8: "SSU-B",
+	0xb: "SEC",
+	0xf: "DNU",
+}
+
+var esmcOptionIINames = map[ESMCSSMCode]string{
+	0x0: "STU",
+	0x1: "PRS",
+	0x4: "TNC",
+	0x7: "ST2",
+	0xa: "ST3",
+	0xc: "SMC",
+	0xd: "ST3E",
+	0xe: "PROV",
+	0xf: "DUS",
+}
+
+// String returns the option I (SDH) quality level name of the code.
+func (c ESMCSSMCode) String() string {
+	if name, ok := esmcOptionINames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%#x)", uint8(c))
+}
+
+// OptionIIName returns the option II (SONET) quality level name of the code.
+func (c ESMCSSMCode) OptionIIName() string {
+	if name, ok := esmcOptionIINames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%#x)", uint8(c))
+}
+
+// ESMCEnhancedSSMCode is the enhanced SSM code of an extended QL TLV.
+type ESMCEnhancedSSMCode uint8
+
+const (
+	ESMCEnhancedSSMCodePRTC  ESMCEnhancedSSMCode = 0x20
+	ESMCEnhancedSSMCodeEPRTC ESMCEnhancedSSMCode = 0x21
+	ESMCEnhancedSSMCodeEEEC  ESMCEnhancedSSMCode = 0x22
+	ESMCEnhancedSSMCodeEPRC  ESMCEnhancedSSMCode = 0x23
+	// ESMCEnhancedSSMCodeNone means the SSM code alone gives the quality
+	// level.
+	ESMCEnhancedSSMCodeNone ESMCEnhancedSSMCode = 0xff
+)
+
+func (c ESMCEnhancedSSMCode) String() string {
+	switch c {
+	case ESMCEnhancedSSMCodePRTC:
+		return "PRTC"
+	case ESMCEnhancedSSMCodeEPRTC:
+		return "ePRTC"
+	case ESMCEnhancedSSMCodeEEEC:
+		return "eEEC"
+	case ESMCEnhancedSSMCodeEPRC:
+		return "ePRC"
+	case ESMCEnhancedSSMCodeNone:
+		return "None"
+	default:
+		return fmt.Sprintf("Unknown(%#x)", uint8(c))
+	}
+}
+
+// ESMC TLV types.
+const (
+	ESMCTLVTypeQL         = 0x01
+	ESMCTLVTypeExtendedQL = 0x02
+)
+
+// ESMCExtendedQL is the extended QL TLV added by G.8264 amendment 1.
+type ESMCExtendedQL struct {
+	EnhancedSSMCode ESMCEnhancedSSMCode
+	ClockIdentity   uint64
+	// MixedEEC is set if not all clocks in the chain are eEECs, and
+	// PartialChain if some clock in the chain didn't add an extended TLV.
+	MixedEEC      bool
+	PartialChain  bool
+	CascadedEEECs uint8
+	CascadedEECs  uint8
+}
+
+// ESMC is an ITU-T G.8264 Ethernet Synchronization Messaging Channel PDU,
+// carried as an OSSP slow protocol.
+type ESMC struct {
+	BaseLayer
+	Version uint8
+	// Event is set for event PDUs, sent immediately on a QL change, and
+	// clear for the periodic information PDUs.
+	Event   bool
+	SSMCode ESMCSSMCode
+	// ExtendedQL is nil if the PDU has no extended QL TLV.
+	ExtendedQL *ESMCExtendedQL
+}
+
+// QualityLevel returns the option I quality level name advertised by the
+// PDU, taking the enhanced SSM code into account.
+func (e *ESMC) QualityLevel() string {
+	if e.ExtendedQL != nil && e.ExtendedQL.EnhancedSSMCode != ESMCEnhancedSSMCodeNone {
+		return e.ExtendedQL.EnhancedSSMCode.String()
+	}
+	return e.SSMCode.String()
+}
+
+// LayerType returns LayerTypeESMC.
+func (e *ESMC) LayerType() gopacket.LayerType { return LayerTypeESMC }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (e *ESMC) CanDecode() gopacket.LayerClass { return LayerTypeESMC }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (e *ESMC) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (e *ESMC) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 14 {
+		df.SetTruncated()
+		return errors.New("ESMC PDU less than 14 bytes")
+	}
+	if !isESMC(data) {
+		return errors.New("not an ESMC PDU")
+	}
+	e.Version = data[6] >> 4
+	e.Event = data[6]&0x08 != 0
+	e.ExtendedQL = nil
+	seenQL := false
+	tlvs := data[10:]
+	for len(tlvs) >= 3 {
+		length := int(binary.BigEndian.Uint16(tlvs[1:3]))
+		if length < 3 {
+			// Padding up to the minimum frame size.
+			break
+		}
+		if length > len(tlvs) {
+			df.SetTruncated()
+			return fmt.Errorf("ESMC TLV length %d exceeds PDU", length)
+		}
+		switch tlvs[0] {
+		case ESMCTLVTypeQL:
+			if length < 4 {
+				return fmt.Errorf("ESMC QL TLV length %d, 4 required", length)
+			}
+			e.SSMCode = ESMCSSMCode(tlvs[3] & 0x0f)
+			seenQL = true
+		case ESMCTLVTypeExtendedQL:
+			if length < 15 {
+				return fmt.Errorf("ESMC extended QL TLV length %d, 15 required", length)
+			}
+			e.ExtendedQL = &ESMCExtendedQL{
+				EnhancedSSMCode: ESMCEnhancedSSMCode(tlvs[3]),
+				ClockIdentity:   binary.BigEndian.Uint64(tlvs[4:12]),
+				MixedEEC:        tlvs[12]&0x01 != 0,
+				PartialChain:    tlvs[12]&0x02 != 0,
+				CascadedEEECs:   tlvs[13],
+				CascadedEECs:    tlvs[14],
+			}
+		}
+		tlvs = tlvs[length:]
+	}
+	if !seenQL {
+		return errors.New("ESMC PDU without QL TLV")
+	}
+	e.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// isESMC reports whether an OSSP slow protocol frame is an ESMC PDU.
+func isESMC(data []byte) bool {
+	return len(data) >= 6 && data[1] == esmcOUI[0] && data[2] == esmcOUI[1] && data[3] == esmcOUI[2] &&
+		binary.BigEndian.Uint16(data[4:6]) == ESMCITUSubtype
+}
+
+func decodeESMC(data []byte, p gopacket.PacketBuilder) error {
+	e := &ESMC{}
+	return decodingLayerDecoder(e, data, p)
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketESMC is an ESMC event PDU advertising QL-PRTC through an extended
+// QL TLV, padded to the minimum frame size.
+var testPacketESMC = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x02, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x88, 0x09, 0x0a, 0x00,
+	0x19, 0xa7, 0x00, 0x01, 0x18, 0x00, 0x00, 0x00, 0x01, 0x00, 0x04, 0x02, 0x02, 0x00, 0x14, 0x20,
+	0x00, 0x11, 0x22, 0xff, 0xfe, 0x33, 0x44, 0x55, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestPacketESMC(t *testing.T) {
+	p := gopacket.NewPacket(testPacketESMC, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeESMC}, t)
+	esmc, ok := p.Layer(LayerTypeESMC).(*ESMC)
+	if !ok {
+		t.Fatal("No ESMC layer")
+	}
+	if esmc.Version != 1 || !esmc.Event || esmc.SSMCode != 0x2 || esmc.SSMCode.String() != "PRC" {
+		t.Errorf("Unexpected ESMC header %#v", esmc)
+	}
+	want := &ESMCExtendedQL{
+		EnhancedSSMCode: ESMCEnhancedSSMCodePRTC,
+		ClockIdentity:   0x001122fffe334455,
+		CascadedEEECs:   1,
+	}
+	if !reflect.DeepEqual(want, esmc.ExtendedQL) {
+		t.Errorf("Extended QL mismatch, \nwant %#v\ngot  %#v\n", want, esmc.ExtendedQL)
+	}
+	if ql := esmc.QualityLevel(); ql != "PRTC" {
+		t.Errorf("Unexpected quality level %q", ql)
+	}
+}
+
+func TestESMCSSMCodeNames(t *testing.T) {
+	for _, test := range []struct {
+		code        ESMCSSMCode
+		optI, optII string
+	}{
+		{0x2, "PRC", "Unknown(0x2)"},
+		{0x4, "SSU-A", "TNC"},
+		{0xb, "SEC", "Unknown(0xb)"},
+		{0xf, "DNU", "DUS"},
+	} {
+		if got := test.code.String(); got != test.optI {
+			t.Errorf("%#x: option I name %q, want %q", uint8(test.code), got, test.optI)
+		}
+		if got := test.code.OptionIIName(); got != test.optII {
+			t.Errorf("%#x: option II name %q, want %q", uint8(test.code), got, test.optII)
+		}
+	}
+}
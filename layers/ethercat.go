@@ -0,0 +1,350 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// EtherCATType is the type of the content of an EtherCAT frame.
+type EtherCATType uint8
+
+// EtherCATType known values.
+const (
+	EtherCATTypeDatagrams        EtherCATType = 1
+	EtherCATTypeNetworkVariables EtherCATType = 4
+	EtherCATTypeMailbox          EtherCATType = 5
+)
+
+func (t EtherCATType) String() string {
+	switch t {
+	case EtherCATTypeDatagrams:
+		return "Datagrams"
+	case EtherCATTypeNetworkVariables:
+		return "NetworkVariables"
+	case EtherCATTypeMailbox:
+		return "Mailbox"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// EtherCATAddressing is the way the address of an EtherCAT datagram selects
+// the slaves it's processed by.
+type EtherCATAddressing uint8
+
+// EtherCATAddressing values.
+const (
+	// EtherCATAddressingNone is the addressing of NOP datagrams, processed
+	// by no slave.
+	EtherCATAddressingNone EtherCATAddressing = iota
+	// EtherCATAddressingAutoIncrement selects a slave by its position on
+	// the ring, and a register by its offset.
+	EtherCATAddressingAutoIncrement
+	// EtherCATAddressingConfigured selects a slave by its configured
+	// station address, and a register by its offset.
+	EtherCATAddressingConfigured
+	// EtherCATAddressingBroadcast selects a register of every slave by its
+	// offset.
+	EtherCATAddressingBroadcast
+	// EtherCATAddressingLogical selects a range of the logical process
+	// image, mapped to slaves by their FMMUs.
+	EtherCATAddressingLogical
+)
+
+func (a EtherCATAddressing) String() string {
+	switch a {
+	case EtherCATAddressingNone:
+		return "None"
+	case EtherCATAddressingAutoIncrement:
+		return "AutoIncrement"
+	case EtherCATAddressingConfigured:
+		return "Configured"
+	case EtherCATAddressingBroadcast:
+		return "Broadcast"
+	case EtherCATAddressingLogical:
+		return "Logical"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(a))
+	}
+}
+
+// EtherCATCommand is the command of an EtherCAT datagram.
+type EtherCATCommand uint8
+
+// EtherCATCommand known values.
+const (
+	EtherCATCommandNOP  EtherCATCommand = 0
+	EtherCATCommandAPRD EtherCATCommand = 1
+	EtherCATCommandAPWR EtherCATCommand = 2
+	EtherCATCommandAPRW EtherCATCommand = 3
+	EtherCATCommandFPRD EtherCATCommand = 4
+	EtherCATCommandFPWR EtherCATCommand = 5
+	EtherCATCommandFPRW EtherCATCommand = 6
+	EtherCATCommandBRD  EtherCATCommand = 7
+	EtherCATCommandBWR  EtherCATCommand = 8
+	EtherCATCommandBRW  EtherCATCommand = 9
+	EtherCATCommandLRD  EtherCATCommand = 10
+	EtherCATCommandLWR  EtherCATCommand = 11
+	EtherCATCommandLRW  EtherCATCommand = 12
+	EtherCATCommandARMW EtherCATCommand = 13
+	EtherCATCommandFRMW EtherCATCommand = 14
+)
+
+func (c EtherCATCommand) String() string {
+	switch c {
+	case EtherCATCommandNOP:
+		return "NOP"
+	case EtherCATCommandAPRD:
+		return "APRD"
+	case EtherCATCommandAPWR:
+		return "APWR"
+	case EtherCATCommandAPRW:
+		return "APRW"
+	case EtherCATCommandFPRD:
+		return "FPRD"
+	case EtherCATCommandFPWR:
+		return "FPWR"
+	case EtherCATCommandFPRW:
+		return "FPRW"
+	case EtherCATCommandBRD:
+		return "BRD"
+	case EtherCATCommandBWR:
+		return "BWR"
+	case EtherCATCommandBRW:
+		return "BRW"
+	case EtherCATCommandLRD:
+		return "LRD"
+	case EtherCATCommandLWR:
+		return "LWR"
+	case EtherCATCommandLRW:
+		return "LRW"
+	case EtherCATCommandARMW:
+		return "ARMW"
+	case EtherCATCommandFRMW:
+		return "FRMW"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// Addressing returns the way datagrams with this command are addressed.
+func (c EtherCATCommand) Addressing() EtherCATAddressing {
+	switch c {
+	case EtherCATCommandAPRD, EtherCATCommandAPWR, EtherCATCommandAPRW, EtherCATCommandARMW:
+		return EtherCATAddressingAutoIncrement
+	case EtherCATCommandFPRD, EtherCATCommandFPWR, EtherCATCommandFPRW, EtherCATCommandFRMW:
+		return EtherCATAddressingConfigured
+	case EtherCATCommandBRD, EtherCATCommandBWR, EtherCATCommandBRW:
+		return EtherCATAddressingBroadcast
+	case EtherCATCommandLRD, EtherCATCommandLWR, EtherCATCommandLRW:
+		return EtherCATAddressingLogical
+	}
+	return EtherCATAddressingNone
+}
+
+// EtherCATDatagram is a datagram of an EtherCAT frame.  Address is the raw
+// 32 bit address field, to be interpreted according to the addressing of
+// Command with the Position, StationAddress, Offset and LogicalAddress
+// methods.
+type EtherCATDatagram struct {
+	Command EtherCATCommand
+	Index   uint8
+	Address uint32
+	// Circulating is set by the slave closing the ring, so a datagram
+	// coming back to it is not processed twice.
+	Circulating bool
+	// More is set on all datagrams of a frame but the last.
+	More           bool
+	IRQ            uint16
+	Data           []byte
+	WorkingCounter uint16
+}
+
+// Position returns the position on the ring addressed by an
+// auto-increment datagram, which is 0 for the slave receiving it first,
+// -1 for the next one, and so on; slaves increment it as they pass the
+// datagram on.
+func (d *EtherCATDatagram) Position() int16 { return int16(d.Address) }
+
+// StationAddress returns the configured station address of the slave
+// addressed by a configured address datagram.
+func (d *EtherCATDatagram) StationAddress() uint16 { return uint16(d.Address) }
+
+// Offset returns the register offset addressed by an auto-increment,
+// configured address or broadcast datagram.
+func (d *EtherCATDatagram) Offset() uint16 { return uint16(d.Address >> 16) }
+
+// LogicalAddress returns the logical address of a logical datagram.
+func (d *EtherCATDatagram) LogicalAddress() uint32 { return d.Address }
+
+const (
+	etherCATHeaderLength         = 2
+	etherCATDatagramHeaderLength = 10
+	etherCATLengthMask           = 0x07ff
+	etherCATCirculating          = 0x4000
+	etherCATMore                 = 0x8000
+)
+
+// EtherCAT is the header of an EtherCAT frame, carried directly over
+// Ethernet.  Frames of type EtherCATTypeDatagrams are decoded into
+// Datagrams; other frame types leave their content in the payload.
+// EtherCAT fields are little endian.
+type EtherCAT struct {
+	BaseLayer
+	// Length is the length of the frame following its header.
+	Length    uint16
+	Type      EtherCATType
+	Datagrams []EtherCATDatagram
+}
+
+// LayerType returns LayerTypeEtherCAT.
+func (e *EtherCAT) LayerType() gopacket.LayerType { return LayerTypeEtherCAT }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (e *EtherCAT) CanDecode() gopacket.LayerClass { return LayerTypeEtherCAT }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (e *EtherCAT) NextLayerType() gopacket.LayerType {
+	if len(e.Payload) > 0 {
+		return gopacket.LayerTypePayload
+	}
+	return gopacket.LayerTypeZero
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.  Bytes past the
+// frame length, such as Ethernet padding, are ignored.
+func (e *EtherCAT) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < etherCATHeaderLength {
+		df.SetTruncated()
+		return errors.New("EtherCAT header too short")
+	}
+	h := binary.LittleEndian.Uint16(data[0:2])
+	*e = EtherCAT{
+		Length: h & etherCATLengthMask,
+		Type:   EtherCATType(h >> 12),
+	}
+	end := etherCATHeaderLength + int(e.Length)
+	if len(data) < end {
+		df.SetTruncated()
+		return fmt.Errorf("EtherCAT frame length %d longer than the %d bytes available", e.Length, len(data)-etherCATHeaderLength)
+	}
+	data = data[:end]
+	if e.Type != EtherCATTypeDatagrams {
+		e.BaseLayer = BaseLayer{Contents: data[:etherCATHeaderLength], Payload: data[etherCATHeaderLength:]}
+		return nil
+	}
+	e.BaseLayer = BaseLayer{Contents: data}
+	for off := etherCATHeaderLength; ; {
+		if len(data) < off+etherCATDatagramHeaderLength {
+			return fmt.Errorf("EtherCAT datagram %d header truncated", len(e.Datagrams))
+		}
+		dh := data[off : off+etherCATDatagramHeaderLength]
+		l := binary.LittleEndian.Uint16(dh[6:8])
+		n := int(l & etherCATLengthMask)
+		if len(data) < off+etherCATDatagramHeaderLength+n+2 {
+			return fmt.Errorf("EtherCAT datagram %d of %d bytes truncated", len(e.Datagrams), n)
+		}
+		off += etherCATDatagramHeaderLength
+		d := EtherCATDatagram{
+			Command:        EtherCATCommand(dh[0]),
+			Index:          dh[1],
+			Address:        binary.LittleEndian.Uint32(dh[2:6]),
+			Circulating:    l&etherCATCirculating != 0,
+			More:           l&etherCATMore != 0,
+			IRQ:            binary.LittleEndian.Uint16(dh[8:10]),
+			Data:           data[off : off+n],
+			WorkingCounter: binary.LittleEndian.Uint16(data[off+n : off+n+2]),
+		}
+		off += n + 2
+		e.Datagrams = append(e.Datagrams, d)
+		if !d.More {
+			if off != len(data) {
+				return fmt.Errorf("EtherCAT frame length %d longer than its %d datagrams", e.Length, len(e.Datagrams))
+			}
+			return nil
+		}
+	}
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.  FixLengths
+// also sets the More flag of all datagrams but the last.
+func (e *EtherCAT) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if e.Type != EtherCATTypeDatagrams {
+		payload := b.Bytes()
+		bytes, err := b.PrependBytes(etherCATHeaderLength)
+		if err != nil {
+			return err
+		}
+		if opts.FixLengths {
+			e.Length = uint16(len(payload))
+		}
+		return e.putHeader(bytes)
+	}
+	length := 0
+	for _, d := range e.Datagrams {
+		if len(d.Data) > etherCATLengthMask {
+			return fmt.Errorf("EtherCAT datagram of %d bytes too long", len(d.Data))
+		}
+		length += etherCATDatagramHeaderLength + len(d.Data) + 2
+	}
+	bytes, err := b.PrependBytes(etherCATHeaderLength + length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		e.Length = uint16(length)
+	}
+	if err := e.putHeader(bytes); err != nil {
+		return err
+	}
+	off := etherCATHeaderLength
+	for i := range e.Datagrams {
+		d := &e.Datagrams[i]
+		if opts.FixLengths {
+			d.More = i < len(e.Datagrams)-1
+		}
+		l := uint16(len(d.Data))
+		if d.Circulating {
+			l |= etherCATCirculating
+		}
+		if d.More {
+			l |= etherCATMore
+		}
+		bytes[off] = byte(d.Command)
+		bytes[off+1] = d.Index
+		binary.LittleEndian.PutUint32(bytes[off+2:], d.Address)
+		binary.LittleEndian.PutUint16(bytes[off+6:], l)
+		binary.LittleEndian.PutUint16(bytes[off+8:], d.IRQ)
+		off += etherCATDatagramHeaderLength
+		off += copy(bytes[off:], d.Data)
+		binary.LittleEndian.PutUint16(bytes[off:], d.WorkingCounter)
+		off += 2
+	}
+	return nil
+}
+
+func (e *EtherCAT) putHeader(bytes []byte) error {
+	if e.Length > etherCATLengthMask {
+		return fmt.Errorf("EtherCAT frame length %d too long", e.Length)
+	}
+	if e.Type > 0x0f {
+		return fmt.Errorf("EtherCAT type %d invalid", e.Type)
+	}
+	binary.LittleEndian.PutUint16(bytes, e.Length|uint16(e.Type)<<12)
+	return nil
+}
+
+func decodeEtherCAT(data []byte, p gopacket.PacketBuilder) error {
+	return decodingLayerDecoder(&EtherCAT{}, data, p)
+}
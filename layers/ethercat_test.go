@@ -0,0 +1,99 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketEtherCATCyclic is a cyclic frame exchanging 4 bytes of process
+// data with a logical read/write, then checking the AL status of the slave
+// at station address 0x1001, of all slaves, and of the second slave on the
+// ring.
+var testPacketEtherCATCyclic = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x01, 0x05, 0x11, 0x22, 0x33, 0x88, 0xa4, 0x3a, 0x10,
+	0x0c, 0x80, 0x00, 0x00, 0x01, 0x00, 0x04, 0x80, 0x00, 0x00, 0x01, 0x00, 0x3f, 0x00, 0x03, 0x00,
+	0x04, 0x81, 0x01, 0x10, 0x30, 0x01, 0x02, 0x80, 0x00, 0x00, 0x08, 0x00, 0x01, 0x00, 0x07, 0x82,
+	0x00, 0x00, 0x30, 0x01, 0x02, 0x80, 0x00, 0x00, 0x08, 0x00, 0x05, 0x00, 0x01, 0x83, 0xff, 0xff,
+	0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x11, 0x22, 0x01, 0x00,
+}
+
+// testPacketEtherCATBroadcast is a single broadcast read of the slave
+// types, padded to the minimum Ethernet frame size.
+var testPacketEtherCATBroadcast = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x01, 0x05, 0x11, 0x22, 0x33, 0x88, 0xa4, 0x0e, 0x10,
+	0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestEtherCAT(t *testing.T) {
+	p := gopacket.NewPacket(testPacketEtherCATCyclic, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeEtherCAT}, t)
+	e := p.Layer(LayerTypeEtherCAT).(*EtherCAT)
+	if e.Type != EtherCATTypeDatagrams || e.Length != 58 || len(e.Datagrams) != 4 {
+		t.Fatalf("Got EtherCAT %+v", e)
+	}
+	d := e.Datagrams[0]
+	if d.Command != EtherCATCommandLRW || d.Command.Addressing() != EtherCATAddressingLogical || d.Index != 0x80 ||
+		d.LogicalAddress() != 0x10000 || !d.More || d.Circulating || !bytes.Equal(d.Data, []byte{0x01, 0x00, 0x3f, 0x00}) || d.WorkingCounter != 3 {
+		t.Errorf("Got datagram %+v", d)
+	}
+	d = e.Datagrams[1]
+	if d.Command.Addressing() != EtherCATAddressingConfigured || d.StationAddress() != 0x1001 || d.Offset() != 0x130 || d.WorkingCounter != 1 {
+		t.Errorf("Got datagram %+v", d)
+	}
+	d = e.Datagrams[2]
+	if d.Command.String() != "BRD" || d.Command.Addressing() != EtherCATAddressingBroadcast || d.Offset() != 0x130 || d.WorkingCounter != 5 {
+		t.Errorf("Got datagram %+v", d)
+	}
+	d = e.Datagrams[3]
+	if d.Command.Addressing() != EtherCATAddressingAutoIncrement || d.Position() != -1 || d.Offset() != 0 || d.More || d.WorkingCounter != 1 {
+		t.Errorf("Got datagram %+v", d)
+	}
+	testSerialization(t, p, testPacketEtherCATCyclic)
+
+	p = gopacket.NewPacket(testPacketEtherCATBroadcast, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	e = p.Layer(LayerTypeEtherCAT).(*EtherCAT)
+	if len(e.LayerContents()) != 16 || len(e.Datagrams) != 1 || e.Datagrams[0].WorkingCounter != 4 {
+		t.Errorf("Got EtherCAT %+v", e)
+	}
+	testSerialization(t, p, testPacketEtherCATBroadcast)
+}
+
+func TestEtherCATMalformed(t *testing.T) {
+	frame := testPacketEtherCATBroadcast[14:30]
+	withMore := append([]byte{}, frame...)
+	withMore[9] |= 0x80
+	for _, data := range [][]byte{
+		frame[:1],
+		// Frame longer than the buffer
+		frame[:15],
+		// Frame longer than its datagrams
+		append([]byte{0x10, 0x10}, append(append([]byte{}, frame[2:]...), 0x00, 0x00)...),
+		// More datagrams announced than present
+		withMore,
+		// Datagram longer than the frame
+		append([]byte{0x0e, 0x10, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04}, frame[9:]...),
+		// Datagram header truncated
+		{0x04, 0x10, 0x07, 0x00, 0x00, 0x00},
+	} {
+		p := gopacket.NewPacket(data, LayerTypeEtherCAT, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("Decoded malformed frame %v", data)
+		}
+	}
+}
@@ -8,9 +8,14 @@ package layers
 
 import (
 	"encoding/binary"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
+// EtherIPVersion is the only EtherIP header version defined by RFC 3378.
+const EtherIPVersion = 3
+
 // EtherIP is the struct for storing RFC 3378 EtherIP packet headers.
 type EtherIP struct {
 	BaseLayer
@@ -23,12 +28,31 @@ func (e *EtherIP) LayerType() gopacket.LayerType { return LayerTypeEtherIP }
 
 // DecodeFromBytes decodes the given bytes into this layer.
 func (e *EtherIP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return fmt.Errorf("Invalid EtherIP header. Length %d less than 2", len(data))
+	}
 	e.Version = data[0] >> 4
 	e.Reserved = binary.BigEndian.Uint16(data[:2]) & 0x0fff
+	if e.Version != EtherIPVersion {
+		return fmt.Errorf("Invalid EtherIP version %d", e.Version)
+	}
 	e.BaseLayer = BaseLayer{data[:2], data[2:]}
 	return nil
 }
 
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (e *EtherIP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(2)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes, uint16(e.Version)<<12|e.Reserved&0x0fff)
+	return nil
+}
+
 // CanDecode returns the set of layer types that this DecodingLayer can decode.
 func (e *EtherIP) CanDecode() gopacket.LayerClass {
 	return LayerTypeEtherIP
@@ -0,0 +1,139 @@
+// Copyright 2012 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketEtherIP is the packet:
+//   11:01:38.124768 IP 192.168.1.1 > 192.168.1.2: ip-proto-97 120: IP 172.16.1.1 > 172.16.1.2: ICMP echo request, id 3842, seq 1, length 64
+//      0x0000:  ea6b 4cd3 5513 d6b9 d880 56ef 0800 4500  .kL.U.....V...E.
+//      0x0010:  0078 0acd 4000 4061 ac04 c0a8 0101 c0a8  .x..@.@a........
+//      0x0020:  0102 3000 aa6a 36e6 c630 6e32 3ec7 9def  ..0..j6..0n2>...
+//      0x0030:  0800 4500 0054 d970 4000 4001 0715 ac10  ..E..T.p@.@.....
+//      0x0040:  0101 ac10 0102 0800 3f15 0f02 0001 82d9  ........?.......
+//      0x0050:  b154 0000 0000 b5e6 0100 0000 0000 1011  .T..............
+//      0x0060:  1213 1415 1617 1819 1a1b 1c1d 1e1f 2021  .............. !
+//      0x0070:  2223 2425 2627 2829 2a2b 2c2d 2e2f 3031  "#$%&'()*+,-./01
+//      0x0080:  3233 3435 3637                           234567
+var testPacketEtherIP = []byte{
+	0xea, 0x6b, 0x4c, 0xd3, 0x55, 0x13, 0xd6, 0xb9, 0xd8, 0x80, 0x56, 0xef, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x78, 0x0a, 0xcd, 0x40, 0x00, 0x40, 0x61, 0xac, 0x04, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8,
+	0x01, 0x02, 0x30, 0x00, 0xaa, 0x6a, 0x36, 0xe6, 0xc6, 0x30, 0x6e, 0x32, 0x3e, 0xc7, 0x9d, 0xef,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x54, 0xd9, 0x70, 0x40, 0x00, 0x40, 0x01, 0x07, 0x15, 0xac, 0x10,
+	0x01, 0x01, 0xac, 0x10, 0x01, 0x02, 0x08, 0x00, 0x3f, 0x15, 0x0f, 0x02, 0x00, 0x01, 0x82, 0xd9,
+	0xb1, 0x54, 0x00, 0x00, 0x00, 0x00, 0xb5, 0xe6, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x11,
+	0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20, 0x21,
+	0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d, 0x2e, 0x2f, 0x30, 0x31,
+	0x32, 0x33, 0x34, 0x35, 0x36, 0x37,
+}
+
+func TestPacketEtherIP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketEtherIP, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeEtherIP, LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+	if got, ok := p.Layer(LayerTypeEtherIP).(*EtherIP); ok {
+		want := &EtherIP{
+			BaseLayer: BaseLayer{testPacketEtherIP[34:36], testPacketEtherIP[36:]},
+			Version:   EtherIPVersion,
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("EtherIP layer mismatch, \nwant %#v\ngot  %#v\n", want, got)
+		}
+	}
+}
+
+func TestPacketEtherIPBadVersion(t *testing.T) {
+	data := append([]byte(nil), testPacketEtherIP...)
+	data[34] = 0x40
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("Expected an error decoding EtherIP version 4")
+	}
+	if p.Layer(LayerTypeEtherIP) != nil {
+		t.Error("Unexpected EtherIP layer for EtherIP version 4")
+	}
+}
+
+func BenchmarkDecodePacketEtherIP(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		gopacket.NewPacket(testPacketEtherIP, LinkTypeEthernet, gopacket.NoCopy)
+	}
+}
+
+var testEthernetOverEtherIP = []gopacket.SerializableLayer{
+	&Ethernet{
+		SrcMAC:       net.HardwareAddr{0xd6, 0xb9, 0xd8, 0x80, 0x56, 0xef},
+		DstMAC:       net.HardwareAddr{0xea, 0x6b, 0x4c, 0xd3, 0x55, 0x13},
+		EthernetType: EthernetTypeIPv4,
+	},
+	&IPv4{
+		Version:  4,
+		SrcIP:    net.IP{192, 168, 1, 1},
+		DstIP:    net.IP{192, 168, 1, 2},
+		Protocol: IPProtocolEtherIP,
+		Flags:    IPv4DontFragment,
+		TTL:      64,
+		Id:       2765,
+		IHL:      5,
+	},
+	&EtherIP{
+		Version: EtherIPVersion,
+	},
+	&Ethernet{
+		SrcMAC:       net.HardwareAddr{0x6e, 0x32, 0x3e, 0xc7, 0x9d, 0xef},
+		DstMAC:       net.HardwareAddr{0xaa, 0x6a, 0x36, 0xe6, 0xc6, 0x30},
+		EthernetType: EthernetTypeIPv4,
+	},
+	&IPv4{
+		Version:  4,
+		SrcIP:    net.IP{172, 16, 1, 1},
+		DstIP:    net.IP{172, 16, 1, 2},
+		Protocol: IPProtocolICMPv4,
+		Flags:    IPv4DontFragment,
+		TTL:      64,
+		IHL:      5,
+		Id:       55664,
+	},
+	&ICMPv4{
+		TypeCode: CreateICMPv4TypeCode(ICMPv4TypeEchoRequest, 0),
+		Id:       3842,
+		Seq:      1,
+	},
+	gopacket.Payload{
+		0x82, 0xd9, 0xb1, 0x54, 0x00, 0x00, 0x00, 0x00, 0xb5, 0xe6, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+		0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d, 0x2e, 0x2f,
+		0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37,
+	},
+}
+
+func TestEthernetOverEtherIPEncode(t *testing.T) {
+	b := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		ComputeChecksums: true,
+		FixLengths:       true,
+	}
+	if err := gopacket.SerializeLayers(b, opts, testEthernetOverEtherIP...); err != nil {
+		t.Errorf("Unable to serialize: %v", err)
+	}
+	p := gopacket.NewPacket(b.Bytes(), LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeEtherIP, LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+	if got, want := b.Bytes(), testPacketEtherIP; !reflect.DeepEqual(want, got) {
+		t.Errorf("Encoding mismatch, \nwant: %v\ngot %v\n", want, got)
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// SlowProtocolSubtype is the first byte of a frame with EtherType
+// EthernetTypeSlowProtocols, identifying which slow protocol it carries.
+type SlowProtocolSubtype uint8
+
+const (
+	SlowProtocolSubtypeLACP   SlowProtocolSubtype = 1
+	SlowProtocolSubtypeMarker SlowProtocolSubtype = 2
+	SlowProtocolSubtypeOAM    SlowProtocolSubtype = 3
+	SlowProtocolSubtypeOSSP   SlowProtocolSubtype = 10
+)
+
+func (s SlowProtocolSubtype) String() string {
+	switch s {
+	case SlowProtocolSubtypeLACP:
+		return "LACP"
+	case SlowProtocolSubtypeMarker:
+		return "Marker"
+	case SlowProtocolSubtypeOAM:
+		return "OAM"
+	case SlowProtocolSubtypeOSSP:
+		return "OSSP"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// decodeSlowProtocol dispatches a slow protocol frame on its subtype.
+func decodeSlowProtocol(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) < 1 {
+		p.SetTruncated()
+		return errors.New("slow protocol frame too short")
+	}
+	switch SlowProtocolSubtype(data[0]) {
+	case SlowProtocolSubtypeLACP:
+		return decodeLACP(data, p)
+	case SlowProtocolSubtypeMarker:
+		return decodeLACPMarker(data, p)
+	case SlowProtocolSubtypeOAM:
+		return decodeEthernetOAM(data, p)
+	case SlowProtocolSubtypeOSSP:
+		if isESMC(data) {
+			return decodeESMC(data, p)
+		}
+	}
+	return p.NextDecoder(gopacket.LayerTypePayload)
+}
+
+// EthernetOAMCode is the code of an IEEE 802.3 clause 57 OAMPDU.
+type EthernetOAMCode uint8
+
+const (
+	EthernetOAMCodeInformation          EthernetOAMCode = 0x00
+	EthernetOAMCodeEventNotification    EthernetOAMCode = 0x01
+	EthernetOAMCodeVariableRequest      EthernetOAMCode = 0x02
+	EthernetOAMCodeVariableResponse     EthernetOAMCode = 0x03
+	EthernetOAMCodeLoopbackControl      EthernetOAMCode = 0x04
+	EthernetOAMCodeOrganizationSpecific EthernetOAMCode = 0xfe
+)
+
+func (c EthernetOAMCode) String() string {
+	switch c {
+	case EthernetOAMCodeInformation:
+		return "Information"
+	case EthernetOAMCodeEventNotification:
+		return "EventNotification"
+	case EthernetOAMCodeVariableRequest:
+		return "VariableRequest"
+	case EthernetOAMCodeVariableResponse:
+		return "VariableResponse"
+	case EthernetOAMCodeLoopbackControl:
+		return "LoopbackControl"
+	case EthernetOAMCodeOrganizationSpecific:
+		return "OrganizationSpecific"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// EthernetOAMInfoType is the type of a TLV in an Information OAMPDU.
+type EthernetOAMInfoType uint8
+
+const (
+	EthernetOAMInfoTypeEnd                  EthernetOAMInfoType = 0x00
+	EthernetOAMInfoTypeLocal                EthernetOAMInfoType = 0x01
+	EthernetOAMInfoTypeRemote               EthernetOAMInfoType = 0x02
+	EthernetOAMInfoTypeOrganizationSpecific EthernetOAMInfoType = 0xfe
+)
+
+// EthernetOAMEventType is the type of a TLV in an Event Notification
+// OAMPDU.
+type EthernetOAMEventType uint8
+
+const (
+	EthernetOAMEventTypeEnd                        EthernetOAMEventType = 0x00
+	EthernetOAMEventTypeErroredSymbolPeriod        EthernetOAMEventType = 0x01
+	EthernetOAMEventTypeErroredFrame               EthernetOAMEventType = 0x02
+	EthernetOAMEventTypeErroredFramePeriod         EthernetOAMEventType = 0x03
+	EthernetOAMEventTypeErroredFrameSecondsSummary EthernetOAMEventType = 0x04
+	EthernetOAMEventTypeOrganizationSpecific       EthernetOAMEventType = 0xfe
+)
+
+func (t EthernetOAMEventType) String() string {
+	switch t {
+	case EthernetOAMEventTypeEnd:
+		return "End"
+	case EthernetOAMEventTypeErroredSymbolPeriod:
+		return "ErroredSymbolPeriod"
+	case EthernetOAMEventTypeErroredFrame:
+		return "ErroredFrame"
+	case EthernetOAMEventTypeErroredFramePeriod:
+		return "ErroredFramePeriod"
+	case EthernetOAMEventTypeErroredFrameSecondsSummary:
+		return "ErroredFrameSecondsSummary"
+	case EthernetOAMEventTypeOrganizationSpecific:
+		return "OrganizationSpecific"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// EthernetOAMInformation is the Local or Remote Information TLV of an
+// Information OAMPDU.
+type EthernetOAMInformation struct {
+	Version  uint8
+	Revision uint16
+	// ParserAction is 0 (forward), 1 (loopback) or 2 (discard).
+	ParserAction uint8
+	// MultiplexerDiscard is set if the multiplexer discards non-OAMPDUs.
+	MultiplexerDiscard bool
+	// OAM configuration.
+	ActiveMode               bool
+	UnidirectionalSupport    bool
+	RemoteLoopbackSupport    bool
+	LinkEventsSupport        bool
+	VariableRetrievalSupport bool
+	MaxPDUSize               uint16
+	OUI                      [3]byte
+	VendorSpecific           uint32
+}
+
+// EthernetOAMEvent is a TLV of an Event Notification OAMPDU.  The counters of
+// the standard link events have different widths on the wire and are widened
+// to 64 bits here; for organization specific and unknown events only Value is
+// set.
+type EthernetOAMEvent struct {
+	Type EthernetOAMEventType
+	// Timestamp is in units of 100 milliseconds.
+	Timestamp         uint16
+	Window            uint64
+	Threshold         uint64
+	Errors            uint64
+	ErrorRunningTotal uint64
+	EventRunningTotal uint32
+	Value             []byte
+}
+
+// EthernetOAM is an IEEE 802.3 clause 57 link OAM PDU, carried as slow
+// protocol subtype 3.
+type EthernetOAM struct {
+	BaseLayer
+	Subtype SlowProtocolSubtype
+	// Flags.
+	LinkFault        bool
+	DyingGasp        bool
+	CriticalEvent    bool
+	LocalEvaluating  bool
+	LocalStable      bool
+	RemoteEvaluating bool
+	RemoteStable     bool
+	Code             EthernetOAMCode
+	// LocalInfo and RemoteInfo are set for Information OAMPDUs carrying
+	// the respective TLV.
+	LocalInfo  *EthernetOAMInformation
+	RemoteInfo *EthernetOAMInformation
+	// SequenceNumber and Events are set for Event Notification OAMPDUs.
+	SequenceNumber uint16
+	Events         []EthernetOAMEvent
+	// LoopbackCommand is set for Loopback Control OAMPDUs: 1 enables and 2
+	// disables remote loopback.
+	LoopbackCommand uint8
+}
+
+// LayerType returns LayerTypeEthernetOAM.
+func (o *EthernetOAM) LayerType() gopacket.LayerType { return LayerTypeEthernetOAM }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (o *EthernetOAM) CanDecode() gopacket.LayerClass { return LayerTypeEthernetOAM }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (o *EthernetOAM) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (o *EthernetOAM) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("OAMPDU less than 4 bytes")
+	}
+	o.Subtype = SlowProtocolSubtype(data[0])
+	if o.Subtype != SlowProtocolSubtypeOAM {
+		return fmt.Errorf("slow protocol subtype %v is not OAM", o.Subtype)
+	}
+	flags := binary.BigEndian.Uint16(data[1:3])
+	o.LinkFault = flags&0x01 != 0
+	o.DyingGasp = flags&0x02 != 0
+	o.CriticalEvent = flags&0x04 != 0
+	o.LocalEvaluating = flags&0x08 != 0
+	o.LocalStable = flags&0x10 != 0
+	o.RemoteEvaluating = flags&0x20 != 0
+	o.RemoteStable = flags&0x40 != 0
+	o.Code = EthernetOAMCode(data[3])
+	o.LocalInfo, o.RemoteInfo = nil, nil
+	o.SequenceNumber = 0
+	o.Events = o.Events[:0]
+	o.LoopbackCommand = 0
+	o.BaseLayer = BaseLayer{Contents: data}
+
+	body := data[4:]
+	switch o.Code {
+	case EthernetOAMCodeInformation:
+		return o.decodeInformation(body, df)
+	case EthernetOAMCodeEventNotification:
+		if len(body) < 2 {
+			df.SetTruncated()
+			return errors.New("OAM event notification missing sequence number")
+		}
+		o.SequenceNumber = binary.BigEndian.Uint16(body[0:2])
+		return o.decodeEvents(body[2:], df)
+	case EthernetOAMCodeLoopbackControl:
+		if len(body) < 1 {
+			df.SetTruncated()
+			return errors.New("OAM loopback control missing command")
+		}
+		o.LoopbackCommand = body[0]
+	}
+	return nil
+}
+
+// nextOAMTLV splits the next type/length/value off data.  It returns a nil
+// TLV at the end TLV or end of data.  The length includes the type and length
+// bytes.
+func nextOAMTLV(data []byte, df gopacket.DecodeFeedback) (tlv, rest []byte, err error) {
+	if len(data) == 0 || data[0] == 0 {
+		return nil, nil, nil
+	}
+	if len(data) < 2 {
+		df.SetTruncated()
+		return nil, nil, errors.New("OAM TLV header truncated")
+	}
+	length := int(data[1])
+	if length < 2 {
+		return nil, nil, fmt.Errorf("invalid OAM TLV length %d", length)
+	}
+	if length > len(data) {
+		df.SetTruncated()
+		return nil, nil, fmt.Errorf("OAM TLV length %d exceeds remaining %d bytes", length, len(data))
+	}
+	return data[:length], data[length:], nil
+}
+
+func (o *EthernetOAM) decodeInformation(data []byte, df gopacket.DecodeFeedback) error {
+	for {
+		tlv, rest, err := nextOAMTLV(data, df)
+		if err != nil || tlv == nil {
+			return err
+		}
+		data = rest
+		switch EthernetOAMInfoType(tlv[0]) {
+		case EthernetOAMInfoTypeLocal, EthernetOAMInfoTypeRemote:
+			if len(tlv) < 16 {
+				return fmt.Errorf("OAM information TLV length %d, 16 required", len(tlv))
+			}
+			info := &EthernetOAMInformation{
+				Version:                  tlv[2],
+				Revision:                 binary.BigEndian.Uint16(tlv[3:5]),
+				ParserAction:             tlv[5] & 0x03,
+				MultiplexerDiscard:       tlv[5]&0x04 != 0,
+				ActiveMode:               tlv[6]&0x01 != 0,
+				UnidirectionalSupport:    tlv[6]&0x02 != 0,
+				RemoteLoopbackSupport:    tlv[6]&0x04 != 0,
+				LinkEventsSupport:        tlv[6]&0x08 != 0,
+				VariableRetrievalSupport: tlv[6]&0x10 != 0,
+				MaxPDUSize:               binary.BigEndian.Uint16(tlv[7:9]) & 0x07ff,
+				VendorSpecific:           binary.BigEndian.Uint32(tlv[12:16]),
+			}
+			copy(info.OUI[:], tlv[9:12])
+			if EthernetOAMInfoType(tlv[0]) == EthernetOAMInfoTypeLocal {
+				o.LocalInfo = info
+			} else {
+				o.RemoteInfo = info
+			}
+		}
+	}
+}
+
+func (o *EthernetOAM) decodeEvents(data []byte, df gopacket.DecodeFeedback) error {
+	for {
+		tlv, rest, err := nextOAMTLV(data, df)
+		if err != nil || tlv == nil {
+			return err
+		}
+		data = rest
+		e := EthernetOAMEvent{Type: EthernetOAMEventType(tlv[0]), Value: tlv[2:]}
+		var need int
+		switch e.Type {
+		case EthernetOAMEventTypeErroredSymbolPeriod:
+			need = 40
+		case EthernetOAMEventTypeErroredFrame:
+			need = 26
+		case EthernetOAMEventTypeErroredFramePeriod:
+			need = 28
+		case EthernetOAMEventTypeErroredFrameSecondsSummary:
+			need = 18
+		}
+		if len(tlv) < need {
+			return fmt.Errorf("OAM %v event length %d, %d required", e.Type, len(tlv), need)
+		}
+		v := tlv[2:]
+		switch e.Type {
+		case EthernetOAMEventTypeErroredSymbolPeriod:
+			e.Timestamp = binary.BigEndian.Uint16(v[0:2])
+			e.Window = binary.BigEndian.Uint64(v[2:10])
+			e.Threshold = binary.BigEndian.Uint64(v[10:18])
+			e.Errors = binary.BigEndian.Uint64(v[18:26])
+			e.ErrorRunningTotal = binary.BigEndian.Uint64(v[26:34])
+			e.EventRunningTotal = binary.BigEndian.Uint32(v[34:38])
+		case EthernetOAMEventTypeErroredFrame:
+			e.Timestamp = binary.BigEndian.Uint16(v[0:2])
+			e.Window = uint64(binary.BigEndian.Uint16(v[2:4]))
+			e.Threshold = uint64(binary.BigEndian.Uint32(v[4:8]))
+			e.Errors = uint64(binary.BigEndian.Uint32(v[8:12]))
+			e.ErrorRunningTotal = binary.BigEndian.Uint64(v[12:20])
+			e.EventRunningTotal = binary.BigEndian.Uint32(v[20:24])
+		case EthernetOAMEventTypeErroredFramePeriod:
+			e.Timestamp = binary.BigEndian.Uint16(v[0:2])
+			e.Window = uint64(binary.BigEndian.Uint32(v[2:6]))
+			e.Threshold = uint64(binary.BigEndian.Uint32(v[6:10]))
+			e.Errors = uint64(binary.BigEndian.Uint32(v[10:14]))
+			e.ErrorRunningTotal = binary.BigEndian.Uint64(v[14:22])
+			e.EventRunningTotal = binary.BigEndian.Uint32(v[22:26])
+		case EthernetOAMEventTypeErroredFrameSecondsSummary:
+			e.Timestamp = binary.BigEndian.Uint16(v[0:2])
+			e.Window = uint64(binary.BigEndian.Uint16(v[2:4]))
+			e.Threshold = uint64(binary.BigEndian.Uint16(v[4:6]))
+			e.Errors = uint64(binary.BigEndian.Uint16(v[6:8]))
+			e.ErrorRunningTotal = uint64(binary.BigEndian.Uint32(v[8:12]))
+			e.EventRunningTotal = binary.BigEndian.Uint32(v[12:16])
+		}
+		if need > 0 {
+			e.Value = nil
+		}
+		o.Events = append(o.Events, e)
+	}
+}
+
+func decodeEthernetOAM(data []byte, p gopacket.PacketBuilder) error {
+	o := &EthernetOAM{}
+	return decodingLayerDecoder(o, data, p)
+}
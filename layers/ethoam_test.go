@@ -0,0 +1,119 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketEthernetOAMDyingGasp is an Information OAMPDU with the dying gasp
+// flag set, carrying local and remote information TLVs.
+var testPacketEthernetOAMDyingGasp = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x02, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x88, 0x09, 0x03, 0x00,
+	0x52, 0x00, 0x01, 0x10, 0x01, 0x00, 0x01, 0x00, 0x15, 0x05, 0xee, 0x00, 0x10, 0x18, 0x00, 0x00,
+	0x00, 0x00, 0x02, 0x10, 0x01, 0x00, 0x02, 0x00, 0x08, 0x05, 0xee, 0x00, 0x00, 0x0c, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestPacketEthernetOAMDyingGasp(t *testing.T) {
+	p := gopacket.NewPacket(testPacketEthernetOAMDyingGasp, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeEthernetOAM}, t)
+	oam, ok := p.Layer(LayerTypeEthernetOAM).(*EthernetOAM)
+	if !ok {
+		t.Fatal("No EthernetOAM layer")
+	}
+	if !oam.DyingGasp || oam.LinkFault || oam.CriticalEvent || !oam.LocalStable || !oam.RemoteStable || oam.LocalEvaluating {
+		t.Errorf("Unexpected OAM flags %#v", oam)
+	}
+	if oam.Code != EthernetOAMCodeInformation {
+		t.Errorf("Unexpected code %v", oam.Code)
+	}
+	wantLocal := &EthernetOAMInformation{
+		Version:                  1,
+		Revision:                 1,
+		ActiveMode:               true,
+		RemoteLoopbackSupport:    true,
+		VariableRetrievalSupport: true,
+		MaxPDUSize:               1518,
+		OUI:                      [3]byte{0x00, 0x10, 0x18},
+	}
+	if !reflect.DeepEqual(wantLocal, oam.LocalInfo) {
+		t.Errorf("Local information mismatch, \nwant %#v\ngot  %#v\n", wantLocal, oam.LocalInfo)
+	}
+	wantRemote := &EthernetOAMInformation{
+		Version:           1,
+		Revision:          2,
+		LinkEventsSupport: true,
+		MaxPDUSize:        1518,
+		OUI:               [3]byte{0x00, 0x00, 0x0c},
+		VendorSpecific:    1,
+	}
+	if !reflect.DeepEqual(wantRemote, oam.RemoteInfo) {
+		t.Errorf("Remote information mismatch, \nwant %#v\ngot  %#v\n", wantRemote, oam.RemoteInfo)
+	}
+}
+
+// testPacketEthernetOAMEvent is an Event Notification OAMPDU with errored
+// symbol period and errored frame events.
+var testPacketEthernetOAMEvent = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x02, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x88, 0x09, 0x03, 0x00,
+	0x50, 0x01, 0x00, 0x07, 0x01, 0x28, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x05, 0xf5, 0xe1, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x02, 0x02, 0x1a, 0x00, 0x0a,
+	0x00, 0x0a, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00,
+}
+
+func TestPacketEthernetOAMEvent(t *testing.T) {
+	p := gopacket.NewPacket(testPacketEthernetOAMEvent, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	oam, ok := p.Layer(LayerTypeEthernetOAM).(*EthernetOAM)
+	if !ok {
+		t.Fatal("No EthernetOAM layer")
+	}
+	if oam.Code != EthernetOAMCodeEventNotification || oam.SequenceNumber != 7 || oam.DyingGasp {
+		t.Errorf("Unexpected OAM header %#v", oam)
+	}
+	want := []EthernetOAMEvent{
+		{
+			Type:              EthernetOAMEventTypeErroredSymbolPeriod,
+			Timestamp:         10,
+			Window:            100000000,
+			Threshold:         1,
+			Errors:            5,
+			ErrorRunningTotal: 16,
+			EventRunningTotal: 2,
+		},
+		{
+			Type:              EthernetOAMEventTypeErroredFrame,
+			Timestamp:         10,
+			Window:            10,
+			Threshold:         1,
+			Errors:            3,
+			ErrorRunningTotal: 3,
+			EventRunningTotal: 1,
+		},
+	}
+	if !reflect.DeepEqual(want, oam.Events) {
+		t.Errorf("OAM events mismatch, \nwant %#v\ngot  %#v\n", want, oam.Events)
+	}
+}
+
+func TestPacketEthernetOAMTruncatedEvent(t *testing.T) {
+	p := gopacket.NewPacket(testPacketEthernetOAMEvent[:40], LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("Expected an error decoding a truncated event")
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// NLPID is an ISO/IEC TR 9577 network layer protocol identifier, as used by
+// RFC 2427 multiprotocol encapsulation over Frame Relay.
+type NLPID uint8
+
+const (
+	NLPIDQ933     NLPID = 0x08
+	NLPIDCiscoLMI NLPID = 0x09
+	NLPIDSNAP     NLPID = 0x80
+	NLPIDCLNP     NLPID = 0x81
+	NLPIDESIS     NLPID = 0x82
+	NLPIDISIS     NLPID = 0x83
+	NLPIDIPv6     NLPID = 0x8e
+	NLPIDIPv4     NLPID = 0xcc
+	NLPIDPPP      NLPID = 0xcf
+)
+
+func (n NLPID) String() string {
+	switch n {
+	case NLPIDQ933:
+		return "Q.933"
+	case NLPIDCiscoLMI:
+		return "CiscoLMI"
+	case NLPIDSNAP:
+		return "SNAP"
+	case NLPIDCLNP:
+		return "CLNP"
+	case NLPIDESIS:
+		return "ES-IS"
+	case NLPIDISIS:
+		return "IS-IS"
+	case NLPIDIPv6:
+		return "IPv6"
+	case NLPIDIPv4:
+		return "IPv4"
+	case NLPIDPPP:
+		return "PPP"
+	default:
+		return fmt.Sprintf("Unknown(%#02x)", uint8(n))
+	}
+}
+
+// FrameRelayLMIType identifies the local management interface variant of an
+// LMI frame.
+type FrameRelayLMIType uint8
+
+const (
+	FrameRelayLMINone FrameRelayLMIType = iota
+	// FrameRelayLMIAnnexA is ITU-T Q.933 Annex A on DLCI 0.
+	FrameRelayLMIAnnexA
+	// FrameRelayLMIAnnexD is ANSI T1.617 Annex D on DLCI 0.
+	FrameRelayLMIAnnexD
+	// FrameRelayLMICisco is the original "gang of four" LMI on DLCI 1023.
+	FrameRelayLMICisco
+)
+
+func (t FrameRelayLMIType) String() string {
+	switch t {
+	case FrameRelayLMINone:
+		return "None"
+	case FrameRelayLMIAnnexA:
+		return "AnnexA"
+	case FrameRelayLMIAnnexD:
+		return "AnnexD"
+	case FrameRelayLMICisco:
+		return "Cisco"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// LMI message types.
+const (
+	FrameRelayLMIStatusEnquiry = 0x75
+	FrameRelayLMIStatus        = 0x7d
+)
+
+// FrameRelay is a Frame Relay frame (DLT_FRELAY): a Q.922 address followed by
+// either RFC 2427 multiprotocol encapsulation or, for frames whose control
+// field isn't UI, the Cisco encapsulation with a 2 byte EtherType.
+type FrameRelay struct {
+	BaseLayer
+	// AddressLength is the length of the Q.922 address, 2 to 4 bytes.
+	AddressLength int
+	DLCI          uint32
+	CR            bool
+	FECN          bool
+	BECN          bool
+	DE            bool
+	// DC is the D/C bit of 3 and 4 byte addresses.
+	DC bool
+
+	// Control and NLPID are set for RFC 2427 encapsulation.
+	Control uint8
+	NLPID   NLPID
+	// EthernetType is set for Cisco encapsulation.
+	EthernetType EthernetType
+	// LMIType and LMIMessageType are set for LMI frames, whose information
+	// elements are left in the payload.
+	LMIType        FrameRelayLMIType
+	LMIMessageType uint8
+}
+
+// LayerType returns LayerTypeFrameRelay.
+func (f *FrameRelay) LayerType() gopacket.LayerType { return LayerTypeFrameRelay }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (f *FrameRelay) CanDecode() gopacket.LayerClass { return LayerTypeFrameRelay }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (f *FrameRelay) NextLayerType() gopacket.LayerType {
+	if f.LMIType != FrameRelayLMINone {
+		return gopacket.LayerTypePayload
+	}
+	if f.EthernetType != 0 {
+		return f.EthernetType.LayerType()
+	}
+	switch f.NLPID {
+	case NLPIDIPv4:
+		return LayerTypeIPv4
+	case NLPIDIPv6:
+		return LayerTypeIPv6
+	case NLPIDSNAP:
+		return LayerTypeSNAP
+	case NLPIDPPP:
+		return LayerTypePPP
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (f *FrameRelay) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("Frame Relay address less than 2 bytes")
+	}
+	// The address ends with the first byte whose EA bit is set.
+	n := 0
+	for n < len(data) && n < 4 && data[n]&0x01 == 0 {
+		n++
+	}
+	if n == len(data) {
+		df.SetTruncated()
+		return errors.New("Frame Relay address truncated")
+	}
+	if n == 0 || n == 4 {
+		return fmt.Errorf("invalid Frame Relay address of %d bytes", n+1)
+	}
+	f.AddressLength = n + 1
+	f.CR = data[0]&0x02 != 0
+	f.FECN = data[1]&0x08 != 0
+	f.BECN = data[1]&0x04 != 0
+	f.DE = data[1]&0x02 != 0
+	f.DLCI = uint32(data[0]>>2)<<4 | uint32(data[1]>>4)
+	f.DC = false
+	switch f.AddressLength {
+	case 3:
+		f.DLCI = f.DLCI<<6 | uint32(data[2]>>2)
+		f.DC = data[2]&0x02 != 0
+	case 4:
+		f.DLCI = f.DLCI<<7 | uint32(data[2]>>1)
+		f.DLCI = f.DLCI<<6 | uint32(data[3]>>2)
+		f.DC = data[3]&0x02 != 0
+	}
+
+	f.Control, f.NLPID, f.EthernetType = 0, 0, 0
+	f.LMIType, f.LMIMessageType = FrameRelayLMINone, 0
+	off := f.AddressLength
+	if len(data) < off+2 {
+		df.SetTruncated()
+		return errors.New("Frame Relay encapsulation truncated")
+	}
+	if data[off] != 0x03 {
+		f.EthernetType = EthernetType(binary.BigEndian.Uint16(data[off : off+2]))
+		f.BaseLayer = BaseLayer{data[:off+2], data[off+2:]}
+		return nil
+	}
+	f.Control = data[off]
+	off++
+	if data[off] == 0x00 {
+		// Optional padding to align the rest of the frame.
+		off++
+		if off >= len(data) {
+			df.SetTruncated()
+			return errors.New("Frame Relay NLPID missing")
+		}
+	}
+	f.NLPID = NLPID(data[off])
+	off++
+
+	if f.DLCI == 0 || f.DLCI == 1023 {
+		if len(data) < off+2 {
+			df.SetTruncated()
+			return errors.New("Frame Relay LMI header truncated")
+		}
+		// Skip the call reference.
+		f.LMIMessageType = data[off+1]
+		switch {
+		case f.DLCI == 1023:
+			f.LMIType = FrameRelayLMICisco
+		case off+2 < len(data) && data[off+2] == 0x95:
+			// Annex D starts with a locking shift to codeset 5.
+			f.LMIType = FrameRelayLMIAnnexD
+		default:
+			f.LMIType = FrameRelayLMIAnnexA
+		}
+		off += 2
+	}
+	f.BaseLayer = BaseLayer{data[:off], data[off:]}
+	return nil
+}
+
+func decodeFrameRelay(data []byte, p gopacket.PacketBuilder) error {
+	f := &FrameRelay{}
+	return decodingLayerDecoder(f, data, p)
+}
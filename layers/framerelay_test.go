@@ -0,0 +1,91 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketFrameRelayIPv4 is an RFC 2427 encapsulated IPv4/UDP packet on
+// DLCI 100 with BECN set.
+var testPacketFrameRelayIPv4 = []byte{
+	0x18, 0x45, 0x03, 0xcc, 0x45, 0x00, 0x00, 0x1c, 0x00, 0x01, 0x00, 0x00, 0x40, 0x11, 0xf9, 0x6c,
+	0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02, 0x04, 0xd2, 0x16, 0x2e, 0x00, 0x08, 0x00, 0x00,
+}
+
+func TestPacketFrameRelayIPv4(t *testing.T) {
+	p := gopacket.NewPacket(testPacketFrameRelayIPv4, LinkTypeFRelay, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeFrameRelay, LayerTypeIPv4, LayerTypeUDP}, t)
+	fr, ok := p.Layer(LayerTypeFrameRelay).(*FrameRelay)
+	if !ok {
+		t.Fatal("No FrameRelay layer")
+	}
+	if fr.DLCI != 100 || fr.AddressLength != 2 || !fr.BECN || fr.FECN || fr.DE || fr.CR || fr.NLPID != NLPIDIPv4 {
+		t.Errorf("Unexpected Frame Relay header %#v", fr)
+	}
+}
+
+func TestPacketFrameRelaySNAP(t *testing.T) {
+	// Padded SNAP encapsulation of ARP on DLCI 16.
+	data := []byte{0x04, 0x01, 0x03, 0x00, 0x80, 0x00, 0x00, 0x00, 0x08, 0x06}
+	data = append(data, 0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x01)
+	data = append(data, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x0a, 0x00, 0x00, 0x01)
+	data = append(data, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x02)
+	p := gopacket.NewPacket(data, LinkTypeFRelay, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeFrameRelay, LayerTypeSNAP, LayerTypeARP}, t)
+	fr := p.Layer(LayerTypeFrameRelay).(*FrameRelay)
+	if fr.DLCI != 16 || fr.NLPID != NLPIDSNAP || len(fr.Contents) != 5 {
+		t.Errorf("Unexpected Frame Relay header %#v", fr)
+	}
+}
+
+func TestFrameRelayAddress(t *testing.T) {
+	for _, test := range []struct {
+		data   []byte
+		length int
+		dlci   uint32
+		dc     bool
+	}{
+		{[]byte{0xfc, 0xf1, 0x08, 0x00}, 2, 1023, false},
+		{[]byte{0x18, 0x40, 0x0b, 0x08, 0x00}, 3, 100<<6 | 2, true},
+		{[]byte{0x00, 0x10, 0x02, 0x05, 0x08, 0x00}, 4, 1<<13 | 1<<6 | 1, false},
+	} {
+		var fr FrameRelay
+		if err := fr.DecodeFromBytes(test.data, gopacket.NilDecodeFeedback); err != nil {
+			t.Errorf("%x: %v", test.data, err)
+			continue
+		}
+		if fr.AddressLength != test.length || fr.DLCI != test.dlci || fr.DC != test.dc {
+			t.Errorf("%x: got length %d DLCI %d D/C %v", test.data, fr.AddressLength, fr.DLCI, fr.DC)
+		}
+		if fr.EthernetType != EthernetTypeIPv4 {
+			t.Errorf("%x: Cisco encapsulation %v, want IPv4", test.data, fr.EthernetType)
+		}
+	}
+}
+
+func TestPacketFrameRelayLMI(t *testing.T) {
+	// ANSI Annex D status enquiry on DLCI 0.
+	data := []byte{0x00, 0x01, 0x03, 0x08, 0x00, 0x75, 0x95, 0x01, 0x01, 0x01, 0x03, 0x02, 0x01, 0x00}
+	p := gopacket.NewPacket(data, LinkTypeFRelay, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeFrameRelay, gopacket.LayerTypePayload}, t)
+	fr := p.Layer(LayerTypeFrameRelay).(*FrameRelay)
+	if fr.DLCI != 0 || fr.LMIType != FrameRelayLMIAnnexD || fr.LMIMessageType != FrameRelayLMIStatusEnquiry {
+		t.Errorf("Unexpected LMI frame %#v", fr)
+	}
+}
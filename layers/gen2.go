@@ -77,7 +77,7 @@ func main() {
 		Name string
 		Num  int
 	}{
-		{"LinkType", 256},
+		{"LinkType", 65536},
 		{"EthernetType", 65536},
 		{"PPPType", 65536},
 		{"IPProtocol", 256},
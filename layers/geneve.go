@@ -9,6 +9,7 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/google/gopacket"
 )
@@ -50,27 +51,33 @@ type GeneveOption struct {
 // LayerType returns LayerTypeGeneve
 func (gn *Geneve) LayerType() gopacket.LayerType { return LayerTypeGeneve }
 
-func decodeGeneveOption(data []byte, gn *Geneve) (*GeneveOption, uint8) {
+func decodeGeneveOption(data []byte, gn *Geneve) (*GeneveOption, uint8, error) {
+	if len(data) < 4 {
+		return nil, 0, errors.New("geneve option too short")
+	}
 	opt := &GeneveOption{}
 
 	opt.Class = binary.BigEndian.Uint16(data[0:2])
 	opt.Type = data[2]
-	opt.Flags = data[3] >> 4
-	opt.Length = (data[3]&0xf)*4 + 4
+	opt.Flags = data[3] >> 5
+	opt.Length = (data[3]&0x1f)*4 + 4
+	if len(data) < int(opt.Length) {
+		return nil, 0, errors.New("geneve option length exceeds options")
+	}
 
 	opt.Data = make([]byte, opt.Length-4)
 	copy(opt.Data, data[4:opt.Length])
 
-	return opt, opt.Length
+	return opt, opt.Length, nil
 }
 
 func (gn *Geneve) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-	if len(data) < 7 {
+	if len(data) < 8 {
 		df.SetTruncated()
 		return errors.New("geneve packet too short")
 	}
 
-	gn.Version = data[0] >> 7
+	gn.Version = data[0] >> 6
 	gn.OptionsLength = (data[0] & 0x3f) * 4
 
 	gn.OAMPacket = data[1]&0x80 > 0
@@ -81,21 +88,23 @@ func (gn *Geneve) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 	copy(buf[1:], data[4:7])
 	gn.VNI = binary.BigEndian.Uint32(buf[:])
 
-	offset, length := uint8(8), int32(gn.OptionsLength)
-	if len(data) < int(length+7) {
+	end := 8 + int(gn.OptionsLength)
+	if len(data) < end {
 		df.SetTruncated()
 		return errors.New("geneve packet too short")
 	}
 
-	for length > 0 {
-		opt, len := decodeGeneveOption(data[offset:], gn)
+	gn.Options = gn.Options[:0]
+	for offset := 8; offset < end; {
+		opt, n, err := decodeGeneveOption(data[offset:end], gn)
+		if err != nil {
+			return err
+		}
 		gn.Options = append(gn.Options, opt)
-
-		length -= int32(len)
-		offset += len
+		offset += int(n)
 	}
 
-	gn.BaseLayer = BaseLayer{data[:offset], data[offset:]}
+	gn.BaseLayer = BaseLayer{data[:end], data[end:]}
 
 	return nil
 }
@@ -104,6 +113,67 @@ func (gn *Geneve) NextLayerType() gopacket.LayerType {
 	return gn.Protocol.LayerType()
 }
 
+// validGeneve reports whether data looks like a version 0 Geneve header
+// whose options fit in data.
+func validGeneve(data []byte) bool {
+	return len(data) >= 8 && data[0]>>6 == 0 && len(data) >= 8+int(data[0]&0x3f)*4
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (gn *Geneve) CanDecode() gopacket.LayerClass {
+	return LayerTypeGeneve
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (gn *Geneve) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	optionsLength := 0
+	for _, opt := range gn.Options {
+		if len(opt.Data)%4 != 0 || len(opt.Data) > 124 {
+			return fmt.Errorf("invalid geneve option data length %d", len(opt.Data))
+		}
+		if opts.FixLengths {
+			opt.Length = uint8(len(opt.Data) + 4)
+		}
+		optionsLength += len(opt.Data) + 4
+	}
+	if optionsLength > 252 {
+		return fmt.Errorf("geneve options too long: %d bytes", optionsLength)
+	}
+	if opts.FixLengths {
+		gn.OptionsLength = uint8(optionsLength)
+	}
+
+	bytes, err := b.PrependBytes(8 + optionsLength)
+	if err != nil {
+		return err
+	}
+	bytes[0] = gn.Version<<6 | (gn.OptionsLength/4)&0x3f
+	bytes[1] = 0
+	if gn.OAMPacket {
+		bytes[1] |= 0x80
+	}
+	if gn.CriticalOption {
+		bytes[1] |= 0x40
+	}
+	binary.BigEndian.PutUint16(bytes[2:4], uint16(gn.Protocol))
+	if gn.VNI >= 1<<24 {
+		return fmt.Errorf("Virtual Network Identifier = %x exceeds max for 24-bit uint", gn.VNI)
+	}
+	binary.BigEndian.PutUint32(bytes[4:8], gn.VNI<<8)
+
+	offset := 8
+	for _, opt := range gn.Options {
+		binary.BigEndian.PutUint16(bytes[offset:], opt.Class)
+		bytes[offset+2] = opt.Type
+		bytes[offset+3] = opt.Flags<<5 | ((opt.Length-4)/4)&0x1f
+		copy(bytes[offset+4:], opt.Data)
+		offset += len(opt.Data) + 4
+	}
+	return nil
+}
+
 func decodeGeneve(data []byte, p gopacket.PacketBuilder) error {
 	gn := &Geneve{}
 	return decodingLayerDecoder(gn, data, p)
@@ -150,6 +150,51 @@ func TestDecodeGeneve3(t *testing.T) {
 	}
 }
 
+func TestSerializeGeneve(t *testing.T) {
+	p := gopacket.NewPacket(testPacketGeneve3, LinkTypeEthernet, gopacket.Default)
+	gn, ok := p.Layer(LayerTypeGeneve).(*Geneve)
+	if !ok {
+		t.Fatal("No Geneve layer found")
+	}
+	gn.OptionsLength = 0
+	gn.Options[0].Length = 0
+	buf := gopacket.NewSerializeBuffer()
+	if err := gn.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if want := testPacketGeneve3[42:58]; !reflect.DeepEqual(buf.Bytes(), want) {
+		t.Errorf("Geneve serialization mismatch\nwant %x\ngot  %x", want, buf.Bytes())
+	}
+	if gn.OptionsLength != 8 || gn.Options[0].Length != 8 {
+		t.Errorf("lengths not fixed: OptionsLength %d, option Length %d", gn.OptionsLength, gn.Options[0].Length)
+	}
+
+	gn.Options[0].Data = []byte{1, 2, 3}
+	if err := gn.SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{FixLengths: true}); err == nil {
+		t.Error("expected error for unaligned option data")
+	}
+}
+
+func TestDecodeGeneveBadOptionLength(t *testing.T) {
+	for _, data := range [][]byte{
+		// Option claims 8 bytes but only 4 bytes of options are present.
+		{0x01, 0x00, 0x65, 0x58, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x80, 0x01},
+		// Second option runs past the end of the options area.
+		{0x02, 0x00, 0x65, 0x58, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x80, 0x00, 0x00, 0x00, 0x80, 0x01},
+		// Options length runs past the end of the packet.
+		{0x02, 0x00, 0x65, 0x58, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x80, 0x00},
+	} {
+		gn := &Geneve{}
+		if err := gn.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%x: expected decode error", data)
+		}
+		p := gopacket.NewPacket(data, LayerTypeGeneve, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("%x: expected error layer", data)
+		}
+	}
+}
+
 func BenchmarkDecodeGeneve1(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		gopacket.NewPacket(testPacketGeneve1, LinkTypeEthernet, gopacket.NoCopy)
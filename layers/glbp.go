@@ -0,0 +1,319 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// GLBPTLVType is the type of a GLBP TLV.
+type GLBPTLVType uint8
+
+// GLBPTLVType known values.
+const (
+	GLBPTLVHello           GLBPTLVType = 1
+	GLBPTLVRequestResponse GLBPTLVType = 2
+	GLBPTLVAuth            GLBPTLVType = 3
+)
+
+func (t GLBPTLVType) String() string {
+	switch t {
+	case GLBPTLVHello:
+		return "Hello"
+	case GLBPTLVRequestResponse:
+		return "RequestResponse"
+	case GLBPTLVAuth:
+		return "Auth"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// GLBPVGState is the state of the virtual gateway of the router sending a
+// GLBP hello.
+type GLBPVGState uint8
+
+// GLBPVGState known values.
+const (
+	GLBPVGStateDisabled GLBPVGState = 0x01
+	GLBPVGStateInitial  GLBPVGState = 0x02
+	GLBPVGStateListen   GLBPVGState = 0x04
+	GLBPVGStateSpeak    GLBPVGState = 0x08
+	GLBPVGStateStandby  GLBPVGState = 0x10
+	GLBPVGStateActive   GLBPVGState = 0x20
+)
+
+func (s GLBPVGState) String() string {
+	switch s {
+	case GLBPVGStateDisabled:
+		return "Disabled"
+	case GLBPVGStateInitial:
+		return "Initial"
+	case GLBPVGStateListen:
+		return "Listen"
+	case GLBPVGStateSpeak:
+		return "Speak"
+	case GLBPVGStateStandby:
+		return "Standby"
+	case GLBPVGStateActive:
+		return "Active"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// GLBPVFState is the state of a virtual forwarder.
+type GLBPVFState uint8
+
+// GLBPVFState known values.
+const (
+	GLBPVFStateDisabled GLBPVFState = 0x01
+	GLBPVFStateInitial  GLBPVFState = 0x02
+	GLBPVFStateListen   GLBPVFState = 0x04
+	GLBPVFStateActive   GLBPVFState = 0x20
+)
+
+func (s GLBPVFState) String() string {
+	switch s {
+	case GLBPVFStateDisabled:
+		return "Disabled"
+	case GLBPVFStateInitial:
+		return "Initial"
+	case GLBPVFStateListen:
+		return "Listen"
+	case GLBPVFStateActive:
+		return "Active"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// GLBPAuthType is the type of GLBP authentication.
+type GLBPAuthType uint8
+
+// GLBPAuthType known values.
+const (
+	GLBPAuthTypeNone      GLBPAuthType = 0
+	GLBPAuthTypePlainText GLBPAuthType = 1
+	GLBPAuthTypeMD5String GLBPAuthType = 2
+	GLBPAuthTypeMD5Chain  GLBPAuthType = 3
+)
+
+func (t GLBPAuthType) String() string {
+	switch t {
+	case GLBPAuthTypeNone:
+		return "None"
+	case GLBPAuthTypePlainText:
+		return "PlainText"
+	case GLBPAuthTypeMD5String:
+		return "MD5String"
+	case GLBPAuthTypeMD5Chain:
+		return "MD5Chain"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// GLBPTLV is a TLV of a GLBP message.
+type GLBPTLV struct {
+	Type  GLBPTLVType
+	Value []byte
+}
+
+// GLBPHello is the hello TLV of a GLBP message, advertising the sender's
+// virtual gateway.  HelloTime and HoldTime are in milliseconds, Redirect
+// and Timeout in seconds.
+type GLBPHello struct {
+	VGState   GLBPVGState
+	Priority  uint8
+	HelloTime uint32
+	HoldTime  uint32
+	Redirect  uint16
+	Timeout   uint16
+	VirtualIP net.IP
+}
+
+// GLBPForwarder is a request/response TLV of a GLBP message, describing one
+// of the sender's virtual forwarders.
+type GLBPForwarder struct {
+	Forwarder  uint8
+	VFState    GLBPVFState
+	Priority   uint8
+	Weight     uint8
+	VirtualMAC net.HardwareAddr
+}
+
+// GLBPAuth is the authentication TLV of a GLBP message.
+type GLBPAuth struct {
+	Type GLBPAuthType
+	Data []byte
+}
+
+// GLBP is a message of Cisco's Gateway Load Balancing Protocol, whose format
+// is as reverse engineered by Wireshark.  The hello, request/response and
+// authentication TLVs are decoded into Hello, Forwarders and Auth; TLVs
+// holds all of them, and is all that's serialized past the header.
+type GLBP struct {
+	BaseLayer
+	Version uint8
+	// Unknown1 and Unknown2 are header fields of unknown meaning.
+	Unknown1 uint8
+	Group    uint16
+	Unknown2 uint16
+	OwnerID  net.HardwareAddr
+
+	TLVs       []GLBPTLV
+	Hello      *GLBPHello
+	Forwarders []GLBPForwarder
+	Auth       *GLBPAuth
+}
+
+// glbpHeaderLength is the length of the header of GLBP messages.
+const glbpHeaderLength = 12
+
+// LayerType returns LayerTypeGLBP.
+func (g *GLBP) LayerType() gopacket.LayerType { return LayerTypeGLBP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (g *GLBP) CanDecode() gopacket.LayerClass { return LayerTypeGLBP }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (g *GLBP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since GLBP messages carry no payload.
+func (g *GLBP) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (g *GLBP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < glbpHeaderLength {
+		df.SetTruncated()
+		return errors.New("GLBP message too short")
+	}
+	*g = GLBP{
+		BaseLayer: BaseLayer{Contents: data},
+		Version:   data[0],
+		Unknown1:  data[1],
+		Group:     binary.BigEndian.Uint16(data[2:4]),
+		Unknown2:  binary.BigEndian.Uint16(data[4:6]),
+		OwnerID:   net.HardwareAddr(data[6:12]),
+	}
+	if g.Version != 1 {
+		return fmt.Errorf("GLBP version %d unsupported", g.Version)
+	}
+	// TLV lengths include their header.
+	for tlvs := data[glbpHeaderLength:]; len(tlvs) > 0; {
+		if len(tlvs) < 2 {
+			df.SetTruncated()
+			return errors.New("GLBP TLV truncated")
+		}
+		l := int(tlvs[1])
+		if l < 2 {
+			return fmt.Errorf("GLBP TLV length %d invalid", l)
+		}
+		if len(tlvs) < l {
+			df.SetTruncated()
+			return errors.New("GLBP TLV truncated")
+		}
+		tlv := GLBPTLV{Type: GLBPTLVType(tlvs[0]), Value: tlvs[2:l]}
+		if err := g.decodeTLV(tlv); err != nil {
+			return err
+		}
+		g.TLVs = append(g.TLVs, tlv)
+		tlvs = tlvs[l:]
+	}
+	return nil
+}
+
+func (g *GLBP) decodeTLV(tlv GLBPTLV) error {
+	v := tlv.Value
+	switch tlv.Type {
+	case GLBPTLVHello:
+		if len(v) < 22 {
+			return fmt.Errorf("GLBP %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		h := &GLBPHello{
+			VGState:   GLBPVGState(v[1]),
+			Priority:  v[3],
+			HelloTime: binary.BigEndian.Uint32(v[6:10]),
+			HoldTime:  binary.BigEndian.Uint32(v[10:14]),
+			Redirect:  binary.BigEndian.Uint16(v[14:16]),
+			Timeout:   binary.BigEndian.Uint16(v[16:18]),
+		}
+		// The address is preceded by its type and length.
+		if l := int(v[21]); len(v) < 22+l {
+			return fmt.Errorf("GLBP %v TLV address truncated", tlv.Type)
+		} else if l > 0 {
+			h.VirtualIP = net.IP(v[22 : 22+l])
+		}
+		g.Hello = h
+	case GLBPTLVRequestResponse:
+		if len(v) != 18 {
+			return fmt.Errorf("GLBP %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		g.Forwarders = append(g.Forwarders, GLBPForwarder{
+			Forwarder:  v[0],
+			VFState:    GLBPVFState(v[1]),
+			Priority:   v[3],
+			Weight:     v[4],
+			VirtualMAC: net.HardwareAddr(v[12:18]),
+		})
+	case GLBPTLVAuth:
+		if len(v) < 2 || len(v) < 2+int(v[1]) {
+			return fmt.Errorf("GLBP %v TLV truncated", tlv.Type)
+		}
+		g.Auth = &GLBPAuth{Type: GLBPAuthType(v[0]), Data: v[2 : 2+v[1]]}
+	}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (g *GLBP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(g.OwnerID) != 6 {
+		return fmt.Errorf("GLBP owner ID %v invalid", g.OwnerID)
+	}
+	length := glbpHeaderLength
+	for _, tlv := range g.TLVs {
+		if len(tlv.Value) > 0xff-2 {
+			return fmt.Errorf("GLBP %v TLV value of %d bytes too long", tlv.Type, len(tlv.Value))
+		}
+		length += 2 + len(tlv.Value)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = g.Version
+	bytes[1] = g.Unknown1
+	binary.BigEndian.PutUint16(bytes[2:4], g.Group)
+	binary.BigEndian.PutUint16(bytes[4:6], g.Unknown2)
+	copy(bytes[6:12], g.OwnerID)
+	off := glbpHeaderLength
+	for _, tlv := range g.TLVs {
+		bytes[off] = byte(tlv.Type)
+		bytes[off+1] = byte(2 + len(tlv.Value))
+		copy(bytes[off+2:], tlv.Value)
+		off += 2 + len(tlv.Value)
+	}
+	return nil
+}
+
+func decodeGLBP(data []byte, p gopacket.PacketBuilder) error {
+	g := &GLBP{}
+	if err := g.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(g)
+	p.SetApplicationLayer(g)
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketGLBPActive is a GLBP hello of the active virtual gateway of
+// group 1, 192.0.2.2, with its two forwarders and plain text
+// authentication.
+var testPacketGLBPActive = []byte{
+	0x01, 0x00, 0x5e, 0x00, 0x00, 0x66, 0x00, 0x1a, 0x2b, 0x00, 0x00, 0x02, 0x08, 0x00, 0x45, 0xc0,
+	0x00, 0x78, 0x00, 0x40, 0x00, 0x00, 0x01, 0x11, 0x16, 0x0d, 0xc0, 0x00, 0x02, 0x02, 0xe0, 0x00,
+	0x00, 0x66, 0x0c, 0x96, 0x0c, 0x96, 0x00, 0x64, 0xf8, 0x7f, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00,
+	0x00, 0x1a, 0x2b, 0x00, 0x00, 0x02, 0x01, 0x1c, 0x00, 0x20, 0x00, 0x6e, 0x00, 0x00, 0x00, 0x00,
+	0x0b, 0xb8, 0x00, 0x00, 0x27, 0x10, 0x02, 0x58, 0x38, 0x40, 0x00, 0x00, 0x01, 0x04, 0xc0, 0x00,
+	0x02, 0x01, 0x02, 0x14, 0x01, 0x20, 0x00, 0xa7, 0x64, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x07, 0xb4, 0x00, 0x01, 0x01, 0x02, 0x14, 0x02, 0x04, 0x00, 0x87, 0x64, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x07, 0xb4, 0x00, 0x01, 0x02, 0x03, 0x0c, 0x01, 0x08, 0x67, 0x6c,
+	0x62, 0x70, 0x6b, 0x65, 0x79, 0x00,
+}
+
+// testPacketGLBPStandby is a GLBP hello of the standby virtual gateway,
+// 192.0.2.3, active for forwarder 2.
+var testPacketGLBPStandby = []byte{
+	0x01, 0x00, 0x5e, 0x00, 0x00, 0x66, 0x00, 0x1a, 0x2b, 0x00, 0x00, 0x03, 0x08, 0x00, 0x45, 0xc0,
+	0x00, 0x58, 0x00, 0x50, 0x00, 0x00, 0x01, 0x11, 0x16, 0x1c, 0xc0, 0x00, 0x02, 0x03, 0xe0, 0x00,
+	0x00, 0x66, 0x0c, 0x96, 0x0c, 0x96, 0x00, 0x44, 0xc7, 0xd6, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00,
+	0x00, 0x1a, 0x2b, 0x00, 0x00, 0x03, 0x01, 0x1c, 0x00, 0x10, 0x00, 0x64, 0x00, 0x00, 0x00, 0x00,
+	0x0b, 0xb8, 0x00, 0x00, 0x27, 0x10, 0x02, 0x58, 0x38, 0x40, 0x00, 0x00, 0x01, 0x04, 0xc0, 0x00,
+	0x02, 0x01, 0x02, 0x14, 0x02, 0x20, 0x00, 0xa7, 0x64, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x07, 0xb4, 0x00, 0x01, 0x02,
+}
+
+func TestGLBP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketGLBPActive, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeGLBP}, t)
+	g := p.Layer(LayerTypeGLBP).(*GLBP)
+	if g.Version != 1 || g.Group != 1 || g.OwnerID.String() != "00:1a:2b:00:00:02" || len(g.TLVs) != 4 {
+		t.Fatalf("Got GLBP %+v", g)
+	}
+	h := g.Hello
+	if h == nil || h.VGState != GLBPVGStateActive || h.Priority != 110 || h.HelloTime != 3000 || h.HoldTime != 10000 ||
+		h.Redirect != 600 || h.Timeout != 14400 || !h.VirtualIP.Equal(net.IP{192, 0, 2, 1}) {
+		t.Errorf("Got hello %+v", h)
+	}
+	if len(g.Forwarders) != 2 {
+		t.Fatalf("Got forwarders %+v", g.Forwarders)
+	}
+	if f := g.Forwarders[0]; f.Forwarder != 1 || f.VFState != GLBPVFStateActive || f.Priority != 167 || f.Weight != 100 || f.VirtualMAC.String() != "00:07:b4:00:01:01" {
+		t.Errorf("Got forwarder %+v", f)
+	}
+	if f := g.Forwarders[1]; f.Forwarder != 2 || f.VFState.String() != "Listen" {
+		t.Errorf("Got forwarder %+v", f)
+	}
+	if a := g.Auth; a == nil || a.Type != GLBPAuthTypePlainText || string(a.Data) != "glbpkey\x00" {
+		t.Errorf("Got authentication %+v", a)
+	}
+	testSerialization(t, p, testPacketGLBPActive)
+
+	p = gopacket.NewPacket(testPacketGLBPStandby, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	g = p.Layer(LayerTypeGLBP).(*GLBP)
+	if g.Hello == nil || g.Hello.VGState.String() != "Standby" || len(g.Forwarders) != 1 || g.Forwarders[0].VFState != GLBPVFStateActive || g.Auth != nil {
+		t.Errorf("Got GLBP %+v", g)
+	}
+	testSerialization(t, p, testPacketGLBPStandby)
+}
+
+func TestGLBPMalformed(t *testing.T) {
+	msg := testPacketGLBPStandby[42:]
+	for _, data := range [][]byte{
+		msg[:11],
+		// Version 2
+		append([]byte{0x02}, msg[1:]...),
+		// TLV shorter than its header
+		append(append([]byte{}, msg[:12]...), 0x01, 0x01),
+		// TLV longer than the message
+		msg[:len(msg)-1],
+		// Request/response of the wrong length
+		append(append([]byte{}, msg[:12]...), 0x02, 0x04, 0x01, 0x20),
+	} {
+		p := gopacket.NewPacket(data, LayerTypeGLBP, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("Decoded malformed message %v", data)
+		}
+	}
+}
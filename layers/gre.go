@@ -8,6 +8,7 @@ package layers
 
 import (
 	"encoding/binary"
+	"errors"
 
 	"github.com/google/gopacket"
 )
@@ -35,8 +36,33 @@ type GRERouting struct {
 // LayerType returns gopacket.LayerTypeGRE.
 func (g *GRE) LayerType() gopacket.LayerType { return LayerTypeGRE }
 
+// VSID returns the NVGRE Virtual Subnet ID, the upper 24 bits of the key
+// (RFC 7637).
+func (g *GRE) VSID() uint32 { return g.Key >> 8 }
+
+// FlowID returns the NVGRE flow ID, the lower 8 bits of the key (RFC 7637).
+func (g *GRE) FlowID() uint8 { return uint8(g.Key) }
+
+// SetNVGRE sets the key to the given NVGRE Virtual Subnet ID and flow ID.
+func (g *GRE) SetNVGRE(vsid uint32, flowID uint8) {
+	g.KeyPresent = true
+	g.Key = vsid<<8 | uint32(flowID)
+}
+
+// PayloadLength returns the payload length carried in the upper half of the
+// key by the enhanced GRE header of PPTP (RFC 2637, version 1).
+func (g *GRE) PayloadLength() uint16 { return uint16(g.Key >> 16) }
+
+// CallID returns the PPTP call ID carried in the lower half of the key by
+// the enhanced GRE header of PPTP (RFC 2637, version 1).
+func (g *GRE) CallID() uint16 { return uint16(g.Key) }
+
 // DecodeFromBytes decodes the given bytes into this layer.
 func (g *GRE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("GRE header too short")
+	}
 	g.ChecksumPresent = data[0]&0x80 != 0
 	g.RoutingPresent = data[0]&0x40 != 0
 	g.KeyPresent = data[0]&0x20 != 0
@@ -47,28 +73,52 @@ func (g *GRE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	g.Flags = data[1] >> 3
 	g.Version = data[1] & 0x7
 	g.Protocol = EthernetType(binary.BigEndian.Uint16(data[2:4]))
+	g.Checksum, g.Offset, g.Key, g.Seq, g.Ack = 0, 0, 0, 0, 0
+	g.GRERouting = nil
 	offset := 4
+	truncated := func(n int) bool {
+		if offset+n > len(data) {
+			df.SetTruncated()
+			return true
+		}
+		return false
+	}
 	if g.ChecksumPresent || g.RoutingPresent {
+		if truncated(4) {
+			return errors.New("GRE checksum truncated")
+		}
 		g.Checksum = binary.BigEndian.Uint16(data[offset : offset+2])
 		g.Offset = binary.BigEndian.Uint16(data[offset+2 : offset+4])
 		offset += 4
 	}
 	if g.KeyPresent {
+		if truncated(4) {
+			return errors.New("GRE key truncated")
+		}
 		g.Key = binary.BigEndian.Uint32(data[offset : offset+4])
 		offset += 4
 	}
 	if g.SeqPresent {
+		if truncated(4) {
+			return errors.New("GRE sequence number truncated")
+		}
 		g.Seq = binary.BigEndian.Uint32(data[offset : offset+4])
 		offset += 4
 	}
 	if g.RoutingPresent {
 		tail := &g.GRERouting
 		for {
+			if truncated(4) {
+				return errors.New("GRE routing truncated")
+			}
 			sre := &GRERouting{
 				AddressFamily: binary.BigEndian.Uint16(data[offset : offset+2]),
 				SREOffset:     data[offset+2],
 				SRELength:     data[offset+3],
 			}
+			if truncated(4 + int(sre.SRELength)) {
+				return errors.New("GRE routing truncated")
+			}
 			sre.RoutingInformation = data[offset+4 : offset+4+int(sre.SRELength)]
 			offset += 4 + int(sre.SRELength)
 			if sre.AddressFamily == 0 && sre.SRELength == 0 {
@@ -79,6 +129,9 @@ func (g *GRE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 		}
 	}
 	if g.AckPresent {
+		if truncated(4) {
+			return errors.New("GRE acknowledgment number truncated")
+		}
 		g.Ack = binary.BigEndian.Uint32(data[offset : offset+4])
 		offset += 4
 	}
@@ -169,6 +222,7 @@ func (g *GRE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 		}
 		// Terminate routing field with a "NULL" SRE.
 		binary.BigEndian.PutUint32(buf[offset:offset+4], 0)
+		offset += 4
 	}
 	if g.AckPresent {
 		binary.BigEndian.PutUint32(buf[offset:offset+4], g.Ack)
@@ -191,6 +245,11 @@ func (g *GRE) CanDecode() gopacket.LayerClass {
 
 // NextLayerType returns the layer type contained by this DecodingLayer.
 func (g *GRE) NextLayerType() gopacket.LayerType {
+	// ERSPAN Type I shares its protocol type with Type II, but has no
+	// sequence number and no ERSPAN header before the mirrored frame.
+	if g.Protocol == EthernetTypeERSPANII && !g.SeqPresent {
+		return LayerTypeEthernet
+	}
 	return g.Protocol.LayerType()
 }
 
@@ -387,3 +387,82 @@ func setNetworkLayer(layers []gopacket.SerializableLayer) error {
 	}
 	return nil
 }
+
+func TestNVGRERoundTrip(t *testing.T) {
+	gre := &GRE{Protocol: EthernetTypeTransparentEthernetBridging}
+	gre.SetNVGRE(0x123456, 0x7f)
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolGRE, SrcIP: net.IP{192, 0, 2, 1}, DstIP: net.IP{192, 0, 2, 2}}
+	inner := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolICMPv4, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, gre,
+		&Ethernet{SrcMAC: net.HardwareAddr{0x00, 0x1d, 0xd8, 0x01, 0x02, 0x03}, DstMAC: net.HardwareAddr{0x00, 0x1d, 0xd8, 0x04, 0x05, 0x06}, EthernetType: EthernetTypeIPv4},
+		inner,
+		&ICMPv4{TypeCode: CreateICMPv4TypeCode(ICMPv4TypeEchoRequest, 0), Id: 1, Seq: 1},
+		gopacket.Payload("nvgre nvgre nvgre nvgre nvgre"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if want := []byte{0x20, 0x00, 0x65, 0x58, 0x12, 0x34, 0x56, 0x7f}; !reflect.DeepEqual(data[20:28], want) {
+		t.Errorf("NVGRE header: got %x, want %x", data[20:28], want)
+	}
+
+	p := gopacket.NewPacket(data, LayerTypeIPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeGRE, LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+	got := p.Layer(LayerTypeGRE).(*GRE)
+	if got.VSID() != 0x123456 || got.FlowID() != 0x7f {
+		t.Errorf("got VSID %x and flow ID %x", got.VSID(), got.FlowID())
+	}
+	testSerialization(t, p, data)
+}
+
+func TestPPTPGRERoundTrip(t *testing.T) {
+	ppp := &PPP{PPPType: PPPTypeIPv4, HasPPTPHeader: true}
+	gre := &GRE{
+		KeyPresent: true,
+		SeqPresent: true,
+		AckPresent: true,
+		Version:    1,
+		Protocol:   EthernetTypePPP,
+		Key:        uint32(4+len(testPPPIPv4))<<16 | 0x4001,
+		Seq:        17,
+		Ack:        12,
+	}
+	ip := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolGRE, SrcIP: net.IP{192, 0, 2, 1}, DstIP: net.IP{192, 0, 2, 2}}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, gre, ppp, gopacket.Payload(testPPPIPv4)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	p := gopacket.NewPacket(data, LayerTypeIPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeGRE, LayerTypePPP, LayerTypeIPv4, LayerTypeUDP}, t)
+	got := p.Layer(LayerTypeGRE).(*GRE)
+	if got.Version != 1 || got.Seq != 17 || got.Ack != 12 || got.CallID() != 0x4001 || int(got.PayloadLength()) != len(got.Payload) {
+		t.Errorf("unexpected GRE header %#v", got)
+	}
+
+	// An acknowledgment-only packet carries no sequence number or payload.
+	buf.Clear()
+	gre.SeqPresent = false
+	gre.Key = 0x4001
+	if err := gopacket.SerializeLayers(buf, opts, gre); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x20, 0x81, 0x88, 0x0b, 0x00, 0x00, 0x40, 0x01, 0x00, 0x00, 0x00, 0x0c}; !reflect.DeepEqual(buf.Bytes(), want) {
+		t.Errorf("ack packet: got %x, want %x", buf.Bytes(), want)
+	}
+	for i := 1; i < len(buf.Bytes()); i++ {
+		if err := got.DecodeFromBytes(buf.Bytes()[:i], gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("expected error decoding %d bytes", i)
+		}
+	}
+}
@@ -15,12 +15,79 @@ import (
 
 const gtpMinimumSizeInBytes int = 8
 
+// GTPv1U message types, from 3GPP TS 29.281 section 6.1.
+const (
+	GTPMessageTypeEchoRequest      uint8 = 1
+	GTPMessageTypeEchoResponse     uint8 = 2
+	GTPMessageTypeErrorIndication  uint8 = 26
+	GTPMessageTypeSupportedExtHdrs uint8 = 31
+	GTPMessageTypeEndMarker        uint8 = 254
+	GTPMessageTypeGPDU             uint8 = 255
+)
+
+// GTPv1U extension header types, from 3GPP TS 29.281 section 5.2.1.
+const (
+	GTPExtensionHeaderTypeUDPPort             uint8 = 0x40
+	GTPExtensionHeaderTypeRANContainer        uint8 = 0x81
+	GTPExtensionHeaderTypeLongPDCPPDUNumber   uint8 = 0x82
+	GTPExtensionHeaderTypeNRRANContainer      uint8 = 0x84
+	GTPExtensionHeaderTypePDUSessionContainer uint8 = 0x85
+	GTPExtensionHeaderTypePDCPPDUNumber       uint8 = 0xc0
+)
+
 // GTPExtensionHeader is used to carry extra data and enable future extensions of the GTP  without the need to use another version number.
 type GTPExtensionHeader struct {
 	Type    uint8
 	Content []byte
 }
 
+// UDPPort returns the source UDP port carried by a UDP Port extension
+// header, which accompanies Error Indication messages.
+func (eh GTPExtensionHeader) UDPPort() (uint16, error) {
+	if eh.Type != GTPExtensionHeaderTypeUDPPort {
+		return 0, fmt.Errorf("GTP extension header type %#x is not UDP Port", eh.Type)
+	}
+	if len(eh.Content) < 2 {
+		return 0, fmt.Errorf("GTP UDP Port extension header too short: %d bytes", len(eh.Content))
+	}
+	return binary.BigEndian.Uint16(eh.Content[:2]), nil
+}
+
+// GTPPDUSessionContainer is the content of a PDU Session Container
+// extension header, used on the N3 and N9 interfaces to carry the QoS flow
+// of a packet.  Defined in 3GPP TS 38.415.
+type GTPPDUSessionContainer struct {
+	// PDUType is 0 for downlink and 1 for uplink PDU session information.
+	PDUType uint8
+	QFI     uint8
+	// The following are only present in downlink PDU session information.
+	RQI bool
+	PPP bool
+	PPI uint8
+}
+
+// PDUSessionContainer decodes the content of a PDU Session Container
+// extension header.
+func (eh GTPExtensionHeader) PDUSessionContainer() (GTPPDUSessionContainer, error) {
+	var c GTPPDUSessionContainer
+	if eh.Type != GTPExtensionHeaderTypePDUSessionContainer {
+		return c, fmt.Errorf("GTP extension header type %#x is not PDU Session Container", eh.Type)
+	}
+	if len(eh.Content) < 2 {
+		return c, fmt.Errorf("GTP PDU Session Container too short: %d bytes", len(eh.Content))
+	}
+	c.PDUType = eh.Content[0] >> 4
+	c.QFI = eh.Content[1] & 0x3f
+	if c.PDUType == 0 {
+		c.PPP = eh.Content[1]&0x80 != 0
+		c.RQI = eh.Content[1]&0x40 != 0
+		if c.PPP && len(eh.Content) > 2 {
+			c.PPI = eh.Content[2] >> 5
+		}
+	}
+	return c, nil
+}
+
 // GTPv1U protocol is used to exchange user data over GTP tunnels across the Sx interfaces.
 // Defined in https://portal.3gpp.org/desktopmodules/Specifications/SpecificationDetails.aspx?specificationId=1595
 type GTPv1U struct {
@@ -57,13 +124,17 @@ func (g *GTPv1U) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 	g.ExtensionHeaderFlag = ((data[0] >> 2) & 0x01) == 1
 	g.MessageType = data[1]
 	g.MessageLength = binary.BigEndian.Uint16(data[2:4])
-	pLen := 8 + g.MessageLength
-	if uint16(dLen) < pLen {
+	pLen := 8 + int(g.MessageLength)
+	if dLen < pLen {
+		df.SetTruncated()
 		return fmt.Errorf("GTP packet too small: %d bytes", dLen)
 	}
 	//  Field used to multiplex different connections in the same GTP tunnel.
 	g.TEID = binary.BigEndian.Uint32(data[4:8])
-	cIndex := uint16(hLen)
+	g.SequenceNumber = 0
+	g.NPDU = 0
+	g.GTPExtensionHeaders = g.GTPExtensionHeaders[:0]
+	cIndex := hLen
 	if g.SequenceNumberFlag || g.NPDUFlag || g.ExtensionHeaderFlag {
 		hLen += 4
 		cIndex += 4
@@ -77,26 +148,23 @@ func (g *GTPv1U) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 			g.NPDU = data[10]
 		}
 		if g.ExtensionHeaderFlag {
-			extensionFlag := true
-			for extensionFlag {
-				extensionType := uint8(data[cIndex-1])
-				extensionLength := uint(data[cIndex])
+			for extensionType := data[cIndex-1]; extensionType != 0; extensionType = data[cIndex-1] {
+				if cIndex >= dLen {
+					return fmt.Errorf("GTP packet with truncated extension header")
+				}
+				extensionLength := int(data[cIndex])
 				if extensionLength == 0 {
 					return fmt.Errorf("GTP packet with invalid extension header")
 				}
 				// extensionLength is in 4-octet units
-				lIndex := cIndex + (uint16(extensionLength) * 4)
-				if uint16(dLen) < lIndex {
-					fmt.Println(dLen, lIndex)
+				lIndex := cIndex + extensionLength*4
+				if dLen < lIndex {
 					return fmt.Errorf("GTP packet with small extension header: %d bytes", dLen)
 				}
 				content := data[cIndex+1 : lIndex-1]
 				eh := GTPExtensionHeader{Type: extensionType, Content: content}
 				g.GTPExtensionHeaders = append(g.GTPExtensionHeaders, eh)
 				cIndex = lIndex
-				// Check if coming bytes are from an extension header
-				extensionFlag = data[cIndex-1] != 0
-
 			}
 		}
 	}
@@ -109,15 +177,32 @@ func (g *GTPv1U) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
 func (g *GTPv1U) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	data, err := b.PrependBytes(gtpMinimumSizeInBytes)
+	if len(g.GTPExtensionHeaders) > 0 {
+		g.ExtensionHeaderFlag = true
+	}
+	hLen := gtpMinimumSizeInBytes
+	if g.ExtensionHeaderFlag || g.SequenceNumberFlag || g.NPDUFlag {
+		hLen += 4
+	}
+	for _, eh := range g.GTPExtensionHeaders {
+		// Content plus the length and next type octets must fill a
+		// whole number of 4-octet units.
+		if (len(eh.Content)+2)%4 != 0 || len(eh.Content)+2 > 255*4 {
+			return fmt.Errorf("GTP extension header content has invalid length %d", len(eh.Content))
+		}
+		hLen += len(eh.Content) + 2
+	}
+	payloadLen := len(b.Bytes())
+	data, err := b.PrependBytes(hLen)
 	if err != nil {
 		return err
 	}
-	data[0] |= (g.Version << 5)
-	data[0] |= (1 << 4)
-	if len(g.GTPExtensionHeaders) > 0 {
+	if opts.FixLengths {
+		g.MessageLength = uint16(hLen - gtpMinimumSizeInBytes + payloadLen)
+	}
+	data[0] = (g.Version << 5) | (1 << 4)
+	if g.ExtensionHeaderFlag {
 		data[0] |= 0x04
-		g.ExtensionHeaderFlag = true
 	}
 	if g.SequenceNumberFlag {
 		data[0] |= 0x02
@@ -128,26 +213,21 @@ func (g *GTPv1U) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Serialize
 	data[1] = g.MessageType
 	binary.BigEndian.PutUint16(data[2:4], g.MessageLength)
 	binary.BigEndian.PutUint32(data[4:8], g.TEID)
-	if g.ExtensionHeaderFlag || g.SequenceNumberFlag || g.NPDUFlag {
-		data, err := b.AppendBytes(4)
-		if err != nil {
-			return err
-		}
-		binary.BigEndian.PutUint16(data[:2], g.SequenceNumber)
-		data[2] = g.NPDU
-		for _, eh := range g.GTPExtensionHeaders {
-			data[len(data)-1] = eh.Type
-			lContent := len(eh.Content)
-			// extensionLength is in 4-octet units
-			extensionLength := (lContent + 2) / 4
-			// Get two extra byte for the next extension header type and length
-			data, err = b.AppendBytes(lContent + 2)
-			if err != nil {
-				return err
-			}
-			data[0] = byte(extensionLength)
-			copy(data[1:lContent+1], eh.Content)
-		}
+	if hLen == gtpMinimumSizeInBytes {
+		return nil
+	}
+	binary.BigEndian.PutUint16(data[8:10], g.SequenceNumber)
+	data[10] = g.NPDU
+	data[11] = 0
+	cIndex := 12
+	for _, eh := range g.GTPExtensionHeaders {
+		data[cIndex-1] = eh.Type
+		lContent := len(eh.Content)
+		// extensionLength is in 4-octet units
+		data[cIndex] = byte((lContent + 2) / 4)
+		copy(data[cIndex+1:], eh.Content)
+		cIndex += lContent + 2
+		data[cIndex-1] = 0
 	}
 	return nil
 
@@ -159,7 +239,16 @@ func (g *GTPv1U) CanDecode() gopacket.LayerClass {
 }
 
 // NextLayerType specifies the next layer that GoPacket should attempt to
+// decode.  Only G-PDU messages carry user data, whose IP version is taken
+// from the first nibble of the payload; other messages such as echo
+// requests and responses may carry information elements, or nothing at all.
 func (g *GTPv1U) NextLayerType() gopacket.LayerType {
+	if len(g.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	if g.MessageType != GTPMessageTypeGPDU {
+		return gopacket.LayerTypePayload
+	}
 	version := uint8(g.LayerPayload()[0]) >> 4
 	if version == 4 {
 		return LayerTypeIPv4
@@ -170,12 +259,13 @@ func (g *GTPv1U) NextLayerType() gopacket.LayerType {
 	}
 }
 
+// validGTPv1U reports whether data looks like a GTPv1 header with the
+// protocol type bit set, as opposed to GTP' or some other protocol.
+func validGTPv1U(data []byte) bool {
+	return len(data) >= gtpMinimumSizeInBytes && data[0]>>5 == 1 && data[0]&0x10 != 0
+}
+
 func decodeGTPv1u(data []byte, p gopacket.PacketBuilder) error {
 	gtp := &GTPv1U{}
-	err := gtp.DecodeFromBytes(data, p)
-	if err != nil {
-		return err
-	}
-	p.AddLayer(gtp)
-	return p.NextDecoder(gtp.NextLayerType())
+	return decodingLayerDecoder(gtp, data, p)
 }
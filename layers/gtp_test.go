@@ -9,6 +9,7 @@ package layers
 
 import (
 	"github.com/google/gopacket"
+	"net"
 	"reflect"
 	"testing"
 )
@@ -156,3 +157,91 @@ func TestGTPPacketWithEH(t *testing.T) {
 	}
 
 }
+
+func TestGTPEcho(t *testing.T) {
+	// Echo request with sequence number 42 and no information elements.
+	req := []byte{0x32, 0x01, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a, 0x00, 0x00}
+	p := gopacket.NewPacket(req, LayerTypeGTPv1U, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeGTPv1U}, t)
+	gtp := p.Layer(LayerTypeGTPv1U).(*GTPv1U)
+	if gtp.MessageType != GTPMessageTypeEchoRequest || gtp.SequenceNumber != 42 {
+		t.Errorf("unexpected echo request %#v", gtp)
+	}
+
+	// Echo response carrying a Recovery information element.
+	resp := []byte{0x32, 0x02, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a, 0x00, 0x00, 0x0e, 0x00}
+	p = gopacket.NewPacket(resp, LayerTypeGTPv1U, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeGTPv1U, gopacket.LayerTypePayload}, t)
+}
+
+func TestGTPExtensionHeaders(t *testing.T) {
+	// G-PDU with a PDU Session Container (QFI 9) followed by a UDP Port
+	// extension header (port 2152), and an IPv6 payload.
+	data := []byte{
+		0x34, 0xff, 0x00, 0x38, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x85,
+		0x01, 0x00, 0x09, 0x40,
+		0x01, 0x08, 0x68, 0x00,
+	}
+	ip6 := &IPv6{Version: 6, NextHeader: IPProtocolNoNextHeader, HopLimit: 64, SrcIP: net.ParseIP("2001:db8::1"), DstIP: net.ParseIP("2001:db8::2")}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip6, gopacket.Payload{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, buf.Bytes()...)
+	p := gopacket.NewPacket(data, LayerTypeGTPv1U, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeGTPv1U, LayerTypeIPv6, gopacket.LayerTypePayload}, t)
+	gtp := p.Layer(LayerTypeGTPv1U).(*GTPv1U)
+	if len(gtp.GTPExtensionHeaders) != 2 {
+		t.Fatalf("got %d extension headers, want 2", len(gtp.GTPExtensionHeaders))
+	}
+	c, err := gtp.GTPExtensionHeaders[0].PDUSessionContainer()
+	if err != nil {
+		t.Error(err)
+	} else if want := (GTPPDUSessionContainer{PDUType: 0, QFI: 9}); c != want {
+		t.Errorf("PDU session container: got %+v, want %+v", c, want)
+	}
+	if port, err := gtp.GTPExtensionHeaders[1].UDPPort(); err != nil {
+		t.Error(err)
+	} else if port != 2152 {
+		t.Errorf("UDP port: got %d, want 2152", port)
+	}
+	if _, err := gtp.GTPExtensionHeaders[1].PDUSessionContainer(); err == nil {
+		t.Error("expected error decoding UDP Port header as PDU Session Container")
+	}
+
+	// Serializing with FixLengths should reproduce the original packet.
+	gtp.MessageLength = 0
+	buf = gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, gtp, gopacket.Payload(gtp.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(buf.Bytes(), data) {
+		t.Errorf("GTP serialization mismatch:\nwant %x\ngot  %x", data, buf.Bytes())
+	}
+}
+
+func TestGTPMalformed(t *testing.T) {
+	for _, data := range [][]byte{
+		// Message length runs past the end of the packet.
+		{0x30, 0xff, 0x00, 0x10, 0x00, 0x00, 0x00, 0x01},
+		// Extension header runs past the end of the packet.
+		{0x34, 0xff, 0x00, 0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x85, 0x02, 0x00, 0x09, 0x00},
+		// Extension header chain continues past the end of the packet.
+		{0x34, 0xff, 0x00, 0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x85, 0x01, 0x00, 0x09, 0x40},
+	} {
+		p := gopacket.NewPacket(data, LayerTypeGTPv1U, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("%x: expected decode error", data)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// HSRPOpCode is the op code of an HSRP message.
+type HSRPOpCode uint8
+
+// HSRPOpCode known values.
+const (
+	HSRPOpCodeHello     HSRPOpCode = 0
+	HSRPOpCodeCoup      HSRPOpCode = 1
+	HSRPOpCodeResign    HSRPOpCode = 2
+	HSRPOpCodeAdvertise HSRPOpCode = 3
+)
+
+func (o HSRPOpCode) String() string {
+	switch o {
+	case HSRPOpCodeHello:
+		return "Hello"
+	case HSRPOpCodeCoup:
+		return "Coup"
+	case HSRPOpCodeResign:
+		return "Resign"
+	case HSRPOpCodeAdvertise:
+		return "Advertise"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(o))
+	}
+}
+
+// HSRPState is the state of the router sending an HSRPv1 message.
+type HSRPState uint8
+
+// HSRPState known values.
+const (
+	HSRPStateInitial HSRPState = 0
+	HSRPStateLearn   HSRPState = 1
+	HSRPStateListen  HSRPState = 2
+	HSRPStateSpeak   HSRPState = 4
+	HSRPStateStandby HSRPState = 8
+	HSRPStateActive  HSRPState = 16
+)
+
+func (s HSRPState) String() string {
+	switch s {
+	case HSRPStateInitial:
+		return "Initial"
+	case HSRPStateLearn:
+		return "Learn"
+	case HSRPStateListen:
+		return "Listen"
+	case HSRPStateSpeak:
+		return "Speak"
+	case HSRPStateStandby:
+		return "Standby"
+	case HSRPStateActive:
+		return "Active"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// HSRPv2State is the state of the router sending an HSRPv2 group state,
+// which version 2 numbers differently.
+type HSRPv2State uint8
+
+// HSRPv2State known values.
+const (
+	HSRPv2StateDisabled HSRPv2State = 0
+	HSRPv2StateInitial  HSRPv2State = 1
+	HSRPv2StateLearn    HSRPv2State = 2
+	HSRPv2StateListen   HSRPv2State = 3
+	HSRPv2StateSpeak    HSRPv2State = 4
+	HSRPv2StateStandby  HSRPv2State = 5
+	HSRPv2StateActive   HSRPv2State = 6
+)
+
+func (s HSRPv2State) String() string {
+	switch s {
+	case HSRPv2StateDisabled:
+		return "Disabled"
+	case HSRPv2StateInitial:
+		return "Initial"
+	case HSRPv2StateLearn:
+		return "Learn"
+	case HSRPv2StateListen:
+		return "Listen"
+	case HSRPv2StateSpeak:
+		return "Speak"
+	case HSRPv2StateStandby:
+		return "Standby"
+	case HSRPv2StateActive:
+		return "Active"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// HSRPTLVType is the type of an HSRP TLV.
+type HSRPTLVType uint8
+
+// HSRPTLVType known values.
+const (
+	HSRPTLVGroupState     HSRPTLVType = 1
+	HSRPTLVInterfaceState HSRPTLVType = 2
+	HSRPTLVTextAuth       HSRPTLVType = 3
+	HSRPTLVMD5Auth        HSRPTLVType = 4
+)
+
+func (t HSRPTLVType) String() string {
+	switch t {
+	case HSRPTLVGroupState:
+		return "GroupState"
+	case HSRPTLVInterfaceState:
+		return "InterfaceState"
+	case HSRPTLVTextAuth:
+		return "TextAuth"
+	case HSRPTLVMD5Auth:
+		return "MD5Auth"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// HSRPTLV is a TLV of an HSRPv2 message, or one following an HSRPv1
+// message, such as MD5 authentication.
+type HSRPTLV struct {
+	Type  HSRPTLVType
+	Value []byte
+}
+
+// HSRPGroupState is the group state TLV of an HSRPv2 message.  HelloTime
+// and HoldTime are in milliseconds.
+type HSRPGroupState struct {
+	Version    uint8
+	OpCode     HSRPOpCode
+	State      HSRPv2State
+	IPVersion  uint8
+	Group      uint16
+	Identifier net.HardwareAddr
+	Priority   uint32
+	HelloTime  uint32
+	HoldTime   uint32
+	VirtualIP  net.IP
+}
+
+// HSRPInterfaceState is the interface state TLV of an HSRPv2 message.
+type HSRPInterfaceState struct {
+	ActiveGroups  uint16
+	PassiveGroups uint16
+}
+
+// HSRPMD5Auth is the MD5 authentication TLV of an HSRP message.
+type HSRPMD5Auth struct {
+	Algorithm uint8
+	Flags     uint16
+	IPAddress net.IP
+	KeyID     uint32
+	Digest    []byte
+}
+
+// HSRP is an HSRP message [RFC2281], of either version.  HSRPv1 messages
+// have a fixed format, decoded into the fields from OpCode to VirtualIP.
+// HSRPv2 messages are TLVs; their group state, interface state and
+// authentication are decoded into GroupState, InterfaceState and AuthData or
+// MD5Auth.
+//
+// TLVs holds all TLVs, including the MD5 authentication that may follow an
+// HSRPv1 message, and is all that's serialized of HSRPv2 messages.
+type HSRP struct {
+	BaseLayer
+	// Version is 0 for HSRPv1 messages, and 2 for HSRPv2 ones.
+	Version uint8
+
+	OpCode HSRPOpCode
+	State  HSRPState
+	// HelloTime and HoldTime are in seconds.
+	HelloTime uint8
+	HoldTime  uint8
+	Priority  uint8
+	Group     uint8
+	Reserved  uint8
+	// AuthData is the cleartext authentication data of HSRPv1 messages and
+	// text authentication TLVs.
+	AuthData  []byte
+	VirtualIP net.IP
+
+	TLVs           []HSRPTLV
+	GroupState     *HSRPGroupState
+	InterfaceState *HSRPInterfaceState
+	MD5Auth        *HSRPMD5Auth
+}
+
+// hsrpV1Length is the length of HSRPv1 messages, without TLVs.
+const hsrpV1Length = 20
+
+// LayerType returns LayerTypeHSRP.
+func (h *HSRP) LayerType() gopacket.LayerType { return LayerTypeHSRP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (h *HSRP) CanDecode() gopacket.LayerClass { return LayerTypeHSRP }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (h *HSRP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since HSRP messages carry no payload.
+func (h *HSRP) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (h *HSRP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 1 {
+		df.SetTruncated()
+		return errors.New("HSRP message empty")
+	}
+	*h = HSRP{BaseLayer: BaseLayer{Contents: data}}
+	tlvs := data
+	// HSRPv1 messages start with their version, 0, and HSRPv2 ones with the
+	// type of their first TLV.
+	if data[0] == 0 {
+		if len(data) < hsrpV1Length {
+			df.SetTruncated()
+			return errors.New("HSRP message too short")
+		}
+		h.OpCode = HSRPOpCode(data[1])
+		h.State = HSRPState(data[2])
+		h.HelloTime = data[3]
+		h.HoldTime = data[4]
+		h.Priority = data[5]
+		h.Group = data[6]
+		h.Reserved = data[7]
+		h.AuthData = data[8:16]
+		h.VirtualIP = net.IP(data[16:20])
+		tlvs = data[hsrpV1Length:]
+	} else {
+		h.Version = 2
+	}
+	for len(tlvs) > 0 {
+		if len(tlvs) < 2 {
+			df.SetTruncated()
+			return errors.New("HSRP TLV truncated")
+		}
+		n := 2 + int(tlvs[1])
+		if len(tlvs) < n {
+			df.SetTruncated()
+			return errors.New("HSRP TLV truncated")
+		}
+		tlv := HSRPTLV{Type: HSRPTLVType(tlvs[0]), Value: tlvs[2:n]}
+		if err := h.decodeTLV(tlv); err != nil {
+			return err
+		}
+		h.TLVs = append(h.TLVs, tlv)
+		tlvs = tlvs[n:]
+	}
+	if h.Version == 2 && h.GroupState == nil {
+		return errors.New("HSRPv2 message without group state")
+	}
+	return nil
+}
+
+func (h *HSRP) decodeTLV(tlv HSRPTLV) error {
+	v := tlv.Value
+	switch tlv.Type {
+	case HSRPTLVGroupState:
+		if len(v) != 40 {
+			return fmt.Errorf("HSRP %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		if h.GroupState != nil {
+			return nil
+		}
+		g := &HSRPGroupState{
+			Version:    v[0],
+			OpCode:     HSRPOpCode(v[1]),
+			State:      HSRPv2State(v[2]),
+			IPVersion:  v[3],
+			Group:      binary.BigEndian.Uint16(v[4:6]),
+			Identifier: net.HardwareAddr(v[6:12]),
+			Priority:   binary.BigEndian.Uint32(v[12:16]),
+			HelloTime:  binary.BigEndian.Uint32(v[16:20]),
+			HoldTime:   binary.BigEndian.Uint32(v[20:24]),
+		}
+		switch g.IPVersion {
+		case 4:
+			g.VirtualIP = net.IP(v[24:28])
+		case 6:
+			g.VirtualIP = net.IP(v[24:40])
+		default:
+			return fmt.Errorf("HSRP group state IP version %d invalid", g.IPVersion)
+		}
+		h.GroupState = g
+	case HSRPTLVInterfaceState:
+		if len(v) != 4 {
+			return fmt.Errorf("HSRP %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		h.InterfaceState = &HSRPInterfaceState{
+			ActiveGroups:  binary.BigEndian.Uint16(v[0:2]),
+			PassiveGroups: binary.BigEndian.Uint16(v[2:4]),
+		}
+	case HSRPTLVTextAuth:
+		h.AuthData = v
+	case HSRPTLVMD5Auth:
+		if len(v) != 28 {
+			return fmt.Errorf("HSRP %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		h.MD5Auth = &HSRPMD5Auth{
+			Algorithm: v[0],
+			Flags:     binary.BigEndian.Uint16(v[2:4]),
+			IPAddress: net.IP(v[4:8]),
+			KeyID:     binary.BigEndian.Uint32(v[8:12]),
+			Digest:    v[12:28],
+		}
+	}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (h *HSRP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 0
+	if h.Version != 2 {
+		length = hsrpV1Length
+	}
+	for _, tlv := range h.TLVs {
+		if len(tlv.Value) > 0xff {
+			return fmt.Errorf("HSRP %v TLV value of %d bytes too long", tlv.Type, len(tlv.Value))
+		}
+		length += 2 + len(tlv.Value)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	off := 0
+	if h.Version != 2 {
+		if len(h.AuthData) > 8 {
+			return errors.New("HSRP authentication data longer than 8 bytes")
+		}
+		ip := h.VirtualIP.To4()
+		if ip == nil && len(h.VirtualIP) != 0 {
+			return fmt.Errorf("HSRP virtual IP %v not an IPv4 address", h.VirtualIP)
+		}
+		bytes[0] = h.Version
+		bytes[1] = uint8(h.OpCode)
+		bytes[2] = uint8(h.State)
+		bytes[3] = h.HelloTime
+		bytes[4] = h.HoldTime
+		bytes[5] = h.Priority
+		bytes[6] = h.Group
+		bytes[7] = h.Reserved
+		for i := 8; i < hsrpV1Length; i++ {
+			bytes[i] = 0
+		}
+		copy(bytes[8:16], h.AuthData)
+		copy(bytes[16:20], ip)
+		off = hsrpV1Length
+	}
+	for _, tlv := range h.TLVs {
+		bytes[off] = byte(tlv.Type)
+		bytes[off+1] = byte(len(tlv.Value))
+		copy(bytes[off+2:], tlv.Value)
+		off += 2 + len(tlv.Value)
+	}
+	return nil
+}
+
+func decodeHSRP(data []byte, p gopacket.PacketBuilder) error {
+	h := &HSRP{}
+	if err := h.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(h)
+	p.SetApplicationLayer(h)
+	return nil
+}
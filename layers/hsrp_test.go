@@ -0,0 +1,188 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// The testPacketHSRPFailover packets are a failover of HSRPv1 group 1,
+// whose virtual IP is 192.0.2.1: 192.0.2.2 is active and 192.0.2.3 standby,
+// until 192.0.2.2 resigns and 192.0.2.3 takes over.
+var testPacketHSRPFailover = [][]byte{
+	{
+		0x01, 0x00, 0x5e, 0x00, 0x00, 0x02, 0x00, 0x00, 0x0c, 0x07, 0xac, 0x01, 0x08, 0x00, 0x45, 0xc0,
+		0x00, 0x30, 0x00, 0x10, 0x00, 0x00, 0x01, 0x11, 0x16, 0xe9, 0xc0, 0x00, 0x02, 0x02, 0xe0, 0x00,
+		0x00, 0x02, 0x07, 0xc1, 0x07, 0xc1, 0x00, 0x1c, 0x2a, 0xef, 0x00, 0x00, 0x10, 0x03, 0x0a, 0x6e,
+		0x01, 0x00, 0x63, 0x69, 0x73, 0x63, 0x6f, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x02, 0x01,
+	},
+	{
+		0x01, 0x00, 0x5e, 0x00, 0x00, 0x02, 0x00, 0x1a, 0x2b, 0x00, 0x00, 0x03, 0x08, 0x00, 0x45, 0xc0,
+		0x00, 0x30, 0x00, 0x20, 0x00, 0x00, 0x01, 0x11, 0x16, 0xd8, 0xc0, 0x00, 0x02, 0x03, 0xe0, 0x00,
+		0x00, 0x02, 0x07, 0xc1, 0x07, 0xc1, 0x00, 0x1c, 0x32, 0xf8, 0x00, 0x00, 0x08, 0x03, 0x0a, 0x64,
+		0x01, 0x00, 0x63, 0x69, 0x73, 0x63, 0x6f, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x02, 0x01,
+	},
+	{
+		0x01, 0x00, 0x5e, 0x00, 0x00, 0x02, 0x00, 0x00, 0x0c, 0x07, 0xac, 0x01, 0x08, 0x00, 0x45, 0xc0,
+		0x00, 0x30, 0x00, 0x11, 0x00, 0x00, 0x01, 0x11, 0x16, 0xe8, 0xc0, 0x00, 0x02, 0x02, 0xe0, 0x00,
+		0x00, 0x02, 0x07, 0xc1, 0x07, 0xc1, 0x00, 0x1c, 0x2a, 0xed, 0x00, 0x02, 0x10, 0x03, 0x0a, 0x6e,
+		0x01, 0x00, 0x63, 0x69, 0x73, 0x63, 0x6f, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x02, 0x01,
+	},
+	{
+		0x01, 0x00, 0x5e, 0x00, 0x00, 0x02, 0x00, 0x00, 0x0c, 0x07, 0xac, 0x01, 0x08, 0x00, 0x45, 0xc0,
+		0x00, 0x30, 0x00, 0x21, 0x00, 0x00, 0x01, 0x11, 0x16, 0xd7, 0xc0, 0x00, 0x02, 0x03, 0xe0, 0x00,
+		0x00, 0x02, 0x07, 0xc1, 0x07, 0xc1, 0x00, 0x1c, 0x2a, 0xf8, 0x00, 0x00, 0x10, 0x03, 0x0a, 0x64,
+		0x01, 0x00, 0x63, 0x69, 0x73, 0x63, 0x6f, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x02, 0x01,
+	},
+}
+
+// testPacketHSRPv2MD5 is an HSRPv2 hello of the active router of group
+// 10, authenticated with MD5.
+var testPacketHSRPv2MD5 = []byte{
+	0x01, 0x00, 0x5e, 0x00, 0x00, 0x66, 0x00, 0x00, 0x0c, 0x9f, 0xf0, 0x0a, 0x08, 0x00, 0x45, 0xc0,
+	0x00, 0x64, 0x00, 0x30, 0x00, 0x00, 0x01, 0x11, 0x16, 0x31, 0xc0, 0x00, 0x02, 0x02, 0xe0, 0x00,
+	0x00, 0x66, 0x07, 0xc1, 0x07, 0xc1, 0x00, 0x50, 0x1f, 0x6e, 0x01, 0x28, 0x02, 0x00, 0x06, 0x04,
+	0x00, 0x0a, 0x00, 0x1a, 0x2b, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x6e, 0x00, 0x00, 0x0b, 0xb8,
+	0x00, 0x00, 0x27, 0x10, 0xc0, 0x00, 0x02, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x04, 0x1c, 0x01, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x02, 0x02, 0x00, 0x00,
+	0x00, 0x07, 0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0xad,
+	0xae, 0xaf,
+}
+
+// testPacketHSRPv2IPv6 is an HSRPv2 hello of the standby router of IPv6
+// group 20.
+var testPacketHSRPv2IPv6 = []byte{
+	0x33, 0x33, 0x00, 0x00, 0x00, 0x66, 0x00, 0x1a, 0x2b, 0x00, 0x00, 0x03, 0x86, 0xdd, 0x60, 0x00,
+	0x00, 0x00, 0x00, 0x32, 0x11, 0xff, 0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x1a,
+	0x2b, 0xff, 0xfe, 0x00, 0x00, 0x03, 0xff, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x66, 0x07, 0xed, 0x07, 0xed, 0x00, 0x32, 0xed, 0xe3, 0x01, 0x28,
+	0x02, 0x00, 0x05, 0x06, 0x00, 0x14, 0x00, 0x1a, 0x2b, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x64,
+	0x00, 0x00, 0x0b, 0xb8, 0x00, 0x00, 0x27, 0x10, 0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x05, 0x73, 0xff, 0xfe, 0xa0, 0x00, 0x14,
+}
+
+func TestHSRPFailover(t *testing.T) {
+	for i, want := range []struct {
+		op       HSRPOpCode
+		state    HSRPState
+		priority uint8
+		src      net.IP
+	}{
+		{HSRPOpCodeHello, HSRPStateActive, 110, net.IP{192, 0, 2, 2}},
+		{HSRPOpCodeHello, HSRPStateStandby, 100, net.IP{192, 0, 2, 3}},
+		{HSRPOpCodeResign, HSRPStateActive, 110, net.IP{192, 0, 2, 2}},
+		{HSRPOpCodeHello, HSRPStateActive, 100, net.IP{192, 0, 2, 3}},
+	} {
+		data := testPacketHSRPFailover[i]
+		p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatalf("Failed to decode packet %d: %v", i, p.ErrorLayer().Error())
+		}
+		checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeHSRP}, t)
+		h := p.Layer(LayerTypeHSRP).(*HSRP)
+		if src := p.NetworkLayer().(*IPv4).SrcIP; h.OpCode != want.op || h.State != want.state || h.Priority != want.priority || !src.Equal(want.src) {
+			t.Errorf("Packet %d: got %v %v priority %d from %v, want %v %v priority %d from %v", i, h.OpCode, h.State, h.Priority, src, want.op, want.state, want.priority, want.src)
+		}
+		if h.Version != 0 || h.Group != 1 || h.HelloTime != 3 || h.HoldTime != 10 || string(h.AuthData) != "cisco\x00\x00\x00" || !h.VirtualIP.Equal(net.IP{192, 0, 2, 1}) {
+			t.Errorf("Packet %d: got %+v", i, h)
+		}
+		testSerialization(t, p, data)
+	}
+	if s := HSRPStateStandby.String(); s != "Standby" {
+		t.Errorf("Got state %q, want Standby", s)
+	}
+}
+
+func TestHSRPv2(t *testing.T) {
+	p := gopacket.NewPacket(testPacketHSRPv2MD5, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeHSRP}, t)
+	h := p.Layer(LayerTypeHSRP).(*HSRP)
+	g := h.GroupState
+	if h.Version != 2 || g == nil || len(h.TLVs) != 2 {
+		t.Fatalf("Got HSRP %+v", h)
+	}
+	if g.Version != 2 || g.OpCode != HSRPOpCodeHello || g.State != HSRPv2StateActive || g.Group != 10 || g.Priority != 110 ||
+		g.HelloTime != 3000 || g.HoldTime != 10000 || g.Identifier.String() != "00:1a:2b:00:00:02" || !g.VirtualIP.Equal(net.IP{192, 0, 2, 1}) {
+		t.Errorf("Got group state %+v", g)
+	}
+	if g.State.String() != "Active" {
+		t.Errorf("Got state %q, want Active", g.State)
+	}
+	a := h.MD5Auth
+	if a == nil || a.Algorithm != 1 || !a.IPAddress.Equal(net.IP{192, 0, 2, 2}) || a.KeyID != 7 || len(a.Digest) != 16 {
+		t.Errorf("Got MD5 authentication %+v", a)
+	}
+	testSerialization(t, p, testPacketHSRPv2MD5)
+
+	p = gopacket.NewPacket(testPacketHSRPv2IPv6, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv6, LayerTypeUDP, LayerTypeHSRP}, t)
+	g = p.Layer(LayerTypeHSRP).(*HSRP).GroupState
+	if g == nil || g.IPVersion != 6 || g.State != HSRPv2StateStandby || g.Group != 20 || !g.VirtualIP.Equal(net.ParseIP("fe80::5:73ff:fea0:14")) {
+		t.Errorf("Got group state %+v", g)
+	}
+	testSerialization(t, p, testPacketHSRPv2IPv6)
+}
+
+func TestHSRPMalformed(t *testing.T) {
+	v1 := testPacketHSRPFailover[0][42:]
+	v2 := testPacketHSRPv2MD5[42:]
+	for _, data := range [][]byte{
+		{},
+		v1[:19],
+		// TLV following HSRPv1 longer than the message
+		append(append([]byte{}, v1...), 0x04, 0x1c, 0x01),
+		v2[:41],
+		// Group state of unknown IP version
+		append(append(append([]byte{}, v2[:5]...), 0x05), v2[6:42]...),
+		// No group state
+		v2[42:],
+	} {
+		p := gopacket.NewPacket(data, LayerTypeHSRP, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("Decoded malformed message %v", data)
+		}
+	}
+
+	// Serializing takes the fields of HSRPv1 messages.
+	h := &HSRP{OpCode: HSRPOpCodeCoup, State: HSRPStateSpeak, HelloTime: 3, HoldTime: 10, Priority: 120, Group: 1, AuthData: []byte("cisco"), VirtualIP: net.ParseIP("192.0.2.1")}
+	buf := gopacket.NewSerializeBuffer()
+	if err := h.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x00, 0x01, 0x04, 0x03, 0x0a, 0x78, 0x01, 0x00, 'c', 'i', 's', 'c', 'o', 0x00, 0x00, 0x00, 0xc0, 0x00, 0x02, 0x01}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Serialized %v, want %v", buf.Bytes(), want)
+	}
+}
+
+func TestHSRPLongTLV(t *testing.T) {
+	v2 := testPacketHSRPv2MD5[42:]
+	value := bytes.Repeat([]byte{0xaa}, 0xff)
+	data := append(append(append([]byte{}, v2...), 0x7f, 0xff), value...)
+	p := gopacket.NewPacket(data, LayerTypeHSRP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	h := p.Layer(LayerTypeHSRP).(*HSRP)
+	if tlv := h.TLVs[len(h.TLVs)-1]; tlv.Type != 0x7f || !bytes.Equal(tlv.Value, value) {
+		t.Errorf("Got TLV %+v", tlv)
+	}
+	p = gopacket.NewPacket(data[:len(data)-1], LayerTypeHSRP, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("Decoded truncated TLV")
+	}
+}
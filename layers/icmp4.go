@@ -32,6 +32,8 @@ const (
 	ICMPv4TypeInfoReply              = 16
 	ICMPv4TypeAddressMaskRequest     = 17
 	ICMPv4TypeAddressMaskReply       = 18
+	ICMPv4TypeExtendedEchoRequest    = 42
+	ICMPv4TypeExtendedEchoReply      = 43
 )
 
 const (
@@ -67,6 +69,13 @@ const (
 	// ICMPv4CodeHost = same as for DestinationUnreachable
 	ICMPv4CodeTOSNet  = 2
 	ICMPv4CodeTOSHost = 3
+
+	// ExtendedEchoReply
+	ICMPv4CodeNoError                   = 0
+	ICMPv4CodeMalformedQuery            = 1
+	ICMPv4CodeNoSuchInterface           = 2
+	ICMPv4CodeNoSuchTableEntry          = 3
+	ICMPv4CodeMultipleInterfacesSatisfy = 4
 )
 
 type icmpv4TypeCodeInfoStruct struct {
@@ -150,6 +159,18 @@ var (
 		ICMPv4TypeAddressMaskReply: icmpv4TypeCodeInfoStruct{
 			"AddressMaskReply", nil,
 		},
+		ICMPv4TypeExtendedEchoRequest: icmpv4TypeCodeInfoStruct{
+			"ExtendedEchoRequest", nil,
+		},
+		ICMPv4TypeExtendedEchoReply: icmpv4TypeCodeInfoStruct{
+			"ExtendedEchoReply", &map[uint8]string{
+				ICMPv4CodeNoError:                   "NoError",
+				ICMPv4CodeMalformedQuery:            "MalformedQuery",
+				ICMPv4CodeNoSuchInterface:           "NoSuchInterface",
+				ICMPv4CodeNoSuchTableEntry:          "NoSuchTableEntry",
+				ICMPv4CodeMultipleInterfacesSatisfy: "MultipleInterfacesSatisfy",
+			},
+		},
 	}
 )
 
@@ -206,6 +227,12 @@ func CreateICMPv4TypeCode(typ uint8, code uint8) ICMPv4TypeCode {
 }
 
 // ICMPv4 is the layer for IPv4 ICMP packet data.
+//
+// Extended echo messages (RFC 8335) don't share the identifier and sequence
+// number layout of other messages, so for those only the 4 byte type, code
+// and checksum header belongs to this layer, and Id and Seq are left unset.
+// The rest is decoded by the ICMPExtendedEchoRequest and
+// ICMPExtendedEchoReply layers, exactly as for ICMPv6.
 type ICMPv4 struct {
 	BaseLayer
 	TypeCode ICMPv4TypeCode
@@ -225,6 +252,11 @@ func (i *ICMPv4) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 	}
 	i.TypeCode = CreateICMPv4TypeCode(data[0], data[1])
 	i.Checksum = binary.BigEndian.Uint16(data[2:4])
+	if i.isExtendedEcho() {
+		i.Id, i.Seq = 0, 0
+		i.BaseLayer = BaseLayer{data[:4], data[4:]}
+		return nil
+	}
 	i.Id = binary.BigEndian.Uint16(data[4:6])
 	i.Seq = binary.BigEndian.Uint16(data[6:8])
 	i.BaseLayer = BaseLayer{data[:8], data[8:]}
@@ -235,13 +267,19 @@ func (i *ICMPv4) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
 func (i *ICMPv4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	bytes, err := b.PrependBytes(8)
+	headerLen := 8
+	if i.isExtendedEcho() {
+		headerLen = 4
+	}
+	bytes, err := b.PrependBytes(headerLen)
 	if err != nil {
 		return err
 	}
 	i.TypeCode.SerializeTo(bytes)
-	binary.BigEndian.PutUint16(bytes[4:], i.Id)
-	binary.BigEndian.PutUint16(bytes[6:], i.Seq)
+	if headerLen == 8 {
+		binary.BigEndian.PutUint16(bytes[4:], i.Id)
+		binary.BigEndian.PutUint16(bytes[6:], i.Seq)
+	}
 	if opts.ComputeChecksums {
 		bytes[2] = 0
 		bytes[3] = 0
@@ -258,9 +296,20 @@ func (i *ICMPv4) CanDecode() gopacket.LayerClass {
 
 // NextLayerType returns the layer type contained by this DecodingLayer.
 func (i *ICMPv4) NextLayerType() gopacket.LayerType {
+	switch i.TypeCode.Type() {
+	case ICMPv4TypeExtendedEchoRequest:
+		return LayerTypeICMPExtendedEchoRequest
+	case ICMPv4TypeExtendedEchoReply:
+		return LayerTypeICMPExtendedEchoReply
+	}
 	return gopacket.LayerTypePayload
 }
 
+func (i *ICMPv4) isExtendedEcho() bool {
+	t := i.TypeCode.Type()
+	return t == ICMPv4TypeExtendedEchoRequest || t == ICMPv4TypeExtendedEchoReply
+}
+
 func decodeICMPv4(data []byte, p gopacket.PacketBuilder) error {
 	i := &ICMPv4{}
 	return decodingLayerDecoder(i, data, p)
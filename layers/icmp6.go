@@ -39,6 +39,10 @@ const (
 
 	// The following are from RFC 3810
 	ICMPv6TypeMLDv2MulticastListenerReportMessageV2 = 143
+
+	// The following are from RFC 8335
+	ICMPv6TypeExtendedEchoRequest = 160
+	ICMPv6TypeExtendedEchoReply   = 161
 )
 
 const (
@@ -59,6 +63,13 @@ const (
 	ICMPv6CodeErroneousHeaderField   = 0
 	ICMPv6CodeUnrecognizedNextHeader = 1
 	ICMPv6CodeUnrecognizedIPv6Option = 2
+
+	// ExtendedEchoReply
+	ICMPv6CodeNoError                   = 0
+	ICMPv6CodeMalformedQuery            = 1
+	ICMPv6CodeNoSuchInterface           = 2
+	ICMPv6CodeNoSuchTableEntry          = 3
+	ICMPv6CodeMultipleInterfacesSatisfy = 4
 )
 
 type icmpv6TypeCodeInfoStruct struct {
@@ -116,6 +127,18 @@ var (
 		ICMPv6TypeRedirect: icmpv6TypeCodeInfoStruct{
 			"Redirect", nil,
 		},
+		ICMPv6TypeExtendedEchoRequest: icmpv6TypeCodeInfoStruct{
+			"ExtendedEchoRequest", nil,
+		},
+		ICMPv6TypeExtendedEchoReply: icmpv6TypeCodeInfoStruct{
+			"ExtendedEchoReply", &map[uint8]string{
+				ICMPv6CodeNoError:                   "NoError",
+				ICMPv6CodeMalformedQuery:            "MalformedQuery",
+				ICMPv6CodeNoSuchInterface:           "NoSuchInterface",
+				ICMPv6CodeNoSuchTableEntry:          "NoSuchTableEntry",
+				ICMPv6CodeMultipleInterfacesSatisfy: "MultipleInterfacesSatisfy",
+			},
+		},
 	}
 )
 
@@ -255,6 +278,10 @@ func (i *ICMPv6) NextLayerType() gopacket.LayerType {
 		return LayerTypeMLDv1MulticastListenerReport
 	case ICMPv6TypeMLDv2MulticastListenerReportMessageV2:
 		return LayerTypeMLDv2MulticastListenerReport
+	case ICMPv6TypeExtendedEchoRequest:
+		return LayerTypeICMPExtendedEchoRequest
+	case ICMPv6TypeExtendedEchoReply:
+		return LayerTypeICMPExtendedEchoReply
 	}
 
 	return gopacket.LayerTypePayload
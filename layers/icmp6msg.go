@@ -13,6 +13,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/google/gopacket"
@@ -52,6 +53,14 @@ const (
 	// is not well known. This option MUST be silently ignored for other
 	// Neighbor Discovery messages.
 	ICMPv6OptMTU
+
+	// ICMPv6OptRDNSS contains the addresses of recursive DNS servers, and is
+	// used in Router Advertisement messages (RFC 8106).
+	ICMPv6OptRDNSS ICMPv6Opt = 25
+
+	// ICMPv6OptDNSSL contains the DNS search list, and is used in Router
+	// Advertisement messages (RFC 8106).
+	ICMPv6OptDNSSL ICMPv6Opt = 31
 )
 
 // ICMPv6Echo represents the structure of a ping.
@@ -124,6 +133,10 @@ func (i ICMPv6Opt) String() string {
 		return "RedirectedHeader"
 	case ICMPv6OptMTU:
 		return "MTU"
+	case ICMPv6OptRDNSS:
+		return "RDNSS"
+	case ICMPv6OptDNSSL:
+		return "DNSSL"
 	default:
 		return fmt.Sprintf("Unknown(%d)", i)
 	}
@@ -488,6 +501,169 @@ func (i ICMPv6Option) String() string {
 	return fmt.Sprintf("ICMPv6Option(%s:%s)", i.Type, hd)
 }
 
+// ICMPv6PrefixInfo is the content of a Prefix Information option.
+// Lifetimes are in seconds, with 0xffffffff meaning infinity.
+type ICMPv6PrefixInfo struct {
+	PrefixLength      uint8
+	OnLink            bool // L flag
+	Autonomous        bool // A flag
+	ValidLifetime     uint32
+	PreferredLifetime uint32
+	Prefix            net.IP
+}
+
+// ICMPv6RDNSS is the content of a Recursive DNS Server option.  Lifetime is
+// in seconds, with 0xffffffff meaning infinity.
+type ICMPv6RDNSS struct {
+	Lifetime uint32
+	Servers  []net.IP
+}
+
+// ICMPv6DNSSL is the content of a DNS Search List option.  Lifetime is in
+// seconds, with 0xffffffff meaning infinity.
+type ICMPv6DNSSL struct {
+	Lifetime uint32
+	Domains  []string
+}
+
+func (i ICMPv6Option) checkType(t ICMPv6Opt, minLength int) error {
+	if i.Type != t {
+		return fmt.Errorf("ICMPv6 option %s is not %s", i.Type, t)
+	}
+	if len(i.Data) < minLength {
+		return fmt.Errorf("ICMPv6 option %s has %d bytes of data, want at least %d", i.Type, len(i.Data), minLength)
+	}
+	return nil
+}
+
+// LinkLayerAddress returns the address in a SourceAddress or TargetAddress
+// option.
+func (i ICMPv6Option) LinkLayerAddress() (net.HardwareAddr, error) {
+	if i.Type != ICMPv6OptSourceAddress && i.Type != ICMPv6OptTargetAddress {
+		return nil, fmt.Errorf("ICMPv6 option %s is not a link-layer address", i.Type)
+	}
+	return net.HardwareAddr(i.Data), nil
+}
+
+// PrefixInfo decodes a PrefixInfo option.
+func (i ICMPv6Option) PrefixInfo() (ICMPv6PrefixInfo, error) {
+	var p ICMPv6PrefixInfo
+	if err := i.checkType(ICMPv6OptPrefixInfo, 30); err != nil {
+		return p, err
+	}
+	p.PrefixLength = i.Data[0]
+	p.OnLink = i.Data[1]&0x80 != 0
+	p.Autonomous = i.Data[1]&0x40 != 0
+	p.ValidLifetime = binary.BigEndian.Uint32(i.Data[2:6])
+	p.PreferredLifetime = binary.BigEndian.Uint32(i.Data[6:10])
+	p.Prefix = net.IP(i.Data[14:30])
+	return p, nil
+}
+
+// MTU decodes an MTU option.
+func (i ICMPv6Option) MTU() (uint32, error) {
+	if err := i.checkType(ICMPv6OptMTU, 6); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(i.Data[2:6]), nil
+}
+
+// RDNSS decodes an RDNSS option.
+func (i ICMPv6Option) RDNSS() (ICMPv6RDNSS, error) {
+	var r ICMPv6RDNSS
+	if err := i.checkType(ICMPv6OptRDNSS, 22); err != nil {
+		return r, err
+	}
+	if (len(i.Data)-6)%16 != 0 {
+		return r, fmt.Errorf("ICMPv6 option RDNSS has %d bytes of addresses", len(i.Data)-6)
+	}
+	r.Lifetime = binary.BigEndian.Uint32(i.Data[2:6])
+	for data := i.Data[6:]; len(data) > 0; data = data[16:] {
+		r.Servers = append(r.Servers, net.IP(data[:16]))
+	}
+	return r, nil
+}
+
+// DNSSL decodes a DNSSL option.
+func (i ICMPv6Option) DNSSL() (ICMPv6DNSSL, error) {
+	var d ICMPv6DNSSL
+	if err := i.checkType(ICMPv6OptDNSSL, 14); err != nil {
+		return d, err
+	}
+	d.Lifetime = binary.BigEndian.Uint32(i.Data[2:6])
+	data := i.Data[6:]
+	for len(data) > 0 && data[0] != 0 {
+		var labels []string
+		for {
+			if len(data) == 0 {
+				return d, errors.New("ICMPv6 option DNSSL has an unterminated domain name")
+			}
+			l := int(data[0])
+			if l == 0 {
+				data = data[1:]
+				break
+			}
+			if l > 63 || len(data) < 1+l {
+				return d, errors.New("ICMPv6 option DNSSL has an invalid domain name label")
+			}
+			labels = append(labels, string(data[1:1+l]))
+			data = data[1+l:]
+		}
+		d.Domains = append(d.Domains, strings.Join(labels, "."))
+	}
+	// Anything left is padding, which must be zero.
+	for _, b := range data {
+		if b != 0 {
+			return d, errors.New("ICMPv6 option DNSSL has non-zero padding")
+		}
+	}
+	return d, nil
+}
+
+// Option returns p as a PrefixInfo option.
+func (p ICMPv6PrefixInfo) Option() ICMPv6Option {
+	data := make([]byte, 30)
+	data[0] = p.PrefixLength
+	if p.OnLink {
+		data[1] |= 0x80
+	}
+	if p.Autonomous {
+		data[1] |= 0x40
+	}
+	binary.BigEndian.PutUint32(data[2:6], p.ValidLifetime)
+	binary.BigEndian.PutUint32(data[6:10], p.PreferredLifetime)
+	copy(data[14:], p.Prefix.To16())
+	return ICMPv6Option{Type: ICMPv6OptPrefixInfo, Data: data}
+}
+
+// Option returns r as an RDNSS option.
+func (r ICMPv6RDNSS) Option() ICMPv6Option {
+	data := make([]byte, 6+16*len(r.Servers))
+	binary.BigEndian.PutUint32(data[2:6], r.Lifetime)
+	for n, ip := range r.Servers {
+		copy(data[6+16*n:], ip.To16())
+	}
+	return ICMPv6Option{Type: ICMPv6OptRDNSS, Data: data}
+}
+
+// Option returns d as a DNSSL option, padded with zeros to a multiple of 8
+// bytes.
+func (d ICMPv6DNSSL) Option() ICMPv6Option {
+	data := make([]byte, 6)
+	binary.BigEndian.PutUint32(data[2:6], d.Lifetime)
+	for _, domain := range d.Domains {
+		for _, label := range strings.Split(strings.TrimSuffix(domain, "."), ".") {
+			data = append(data, byte(len(label)))
+			data = append(data, label...)
+		}
+		data = append(data, 0)
+	}
+	for (len(data)+2)%8 != 0 {
+		data = append(data, 0)
+	}
+	return ICMPv6Option{Type: ICMPv6OptDNSSL, Data: data}
+}
+
 // DecodeFromBytes decodes the given bytes into this layer.
 func (i *ICMPv6Options) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	for len(data) > 0 {
@@ -527,8 +703,14 @@ func (i *ICMPv6Options) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback)
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
 func (i *ICMPv6Options) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	for _, opt := range []ICMPv6Option(*i) {
-		length := len(opt.Data) + 2
+	// Options are prepended, so go backwards to keep them in order.
+	for n := len(*i) - 1; n >= 0; n-- {
+		opt := (*i)[n]
+		// Option lengths are in units of 8 octets, pad with zeros.
+		length := (len(opt.Data) + 2 + 7) / 8 * 8
+		if length > 255*8 {
+			return fmt.Errorf("ICMPv6 option %s too long: %d bytes", opt.Type, len(opt.Data))
+		}
 		buf, err := b.PrependBytes(length)
 		if err != nil {
 			return err
@@ -537,6 +719,7 @@ func (i *ICMPv6Options) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Se
 		buf[0] = byte(opt.Type)
 		buf[1] = byte(length / 8)
 		copy(buf[2:], opt.Data)
+		copy(buf[2+len(opt.Data):], lotsOfZeros[:length-2-len(opt.Data)])
 	}
 
 	return nil
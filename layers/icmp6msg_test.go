@@ -9,6 +9,8 @@ package layers
 
 import (
 	"github.com/google/gopacket"
+	"net"
+	"reflect"
 	"testing"
 )
 
@@ -47,6 +49,106 @@ func TestPacketICMPv6RouterAdvertisement(t *testing.T) {
 		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
 	}
 	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv6, LayerTypeICMPv6, LayerTypeICMPv6RouterAdvertisement}, t)
+
+	ra := p.Layer(LayerTypeICMPv6RouterAdvertisement).(*ICMPv6RouterAdvertisement)
+	if len(ra.Options) != 3 {
+		t.Fatalf("got %d options, want 3", len(ra.Options))
+	}
+	if addr, err := ra.Options[0].LinkLayerAddress(); err != nil {
+		t.Error(err)
+	} else if addr.String() != "c2:00:54:f5:00:00" {
+		t.Errorf("source link-layer address: got %v", addr)
+	}
+	if mtu, err := ra.Options[1].MTU(); err != nil {
+		t.Error(err)
+	} else if mtu != 1500 {
+		t.Errorf("MTU: got %d, want 1500", mtu)
+	}
+	pi, err := ra.Options[2].PrefixInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ICMPv6PrefixInfo{
+		PrefixLength:      64,
+		OnLink:            true,
+		Autonomous:        true,
+		ValidLifetime:     2592000,
+		PreferredLifetime: 604800,
+		Prefix:            net.ParseIP("2001:db8:0:1::"),
+	}
+	if !reflect.DeepEqual(pi, want) {
+		t.Errorf("prefix info mismatch\nwant %+v\ngot  %+v", want, pi)
+	}
+	if _, err := ra.Options[2].MTU(); err == nil {
+		t.Error("expected error decoding PrefixInfo option as MTU")
+	}
+
+	// Re-serializing the layer should keep the options in order.
+	buf := gopacket.NewSerializeBuffer()
+	if err := ra.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(buf.Bytes(), ra.Contents) {
+		t.Errorf("router advertisement serialization mismatch\nwant %x\ngot  %x", ra.Contents, buf.Bytes())
+	}
+}
+
+func TestICMPv6RouterAdvertisementDNSOptions(t *testing.T) {
+	rdnss := ICMPv6RDNSS{
+		Lifetime: 600,
+		Servers:  []net.IP{net.ParseIP("2001:db8::53"), net.ParseIP("2001:db8::54")},
+	}
+	dnssl := ICMPv6DNSSL{
+		Lifetime: 600,
+		Domains:  []string{"example.com", "corp.example.net"},
+	}
+	ip6 := &IPv6{
+		Version:    6,
+		NextHeader: IPProtocolICMPv6,
+		HopLimit:   255,
+		SrcIP:      net.ParseIP("fe80::1"),
+		DstIP:      net.ParseIP("ff02::1"),
+	}
+	icmp := &ICMPv6{TypeCode: CreateICMPv6TypeCode(ICMPv6TypeRouterAdvertisement, 0)}
+	ra := &ICMPv6RouterAdvertisement{
+		HopLimit:       64,
+		RouterLifetime: 1800,
+		Options: ICMPv6Options{
+			{Type: ICMPv6OptSourceAddress, Data: []byte{0x02, 0, 0, 0, 0, 1}},
+			rdnss.Option(),
+			dnssl.Option(),
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip6, icmp, ra); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv6, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv6, LayerTypeICMPv6, LayerTypeICMPv6RouterAdvertisement}, t)
+	got := p.Layer(LayerTypeICMPv6RouterAdvertisement).(*ICMPv6RouterAdvertisement)
+	if len(got.Options) != 3 {
+		t.Fatalf("got %d options, want 3", len(got.Options))
+	}
+	for n, typ := range []ICMPv6Opt{ICMPv6OptSourceAddress, ICMPv6OptRDNSS, ICMPv6OptDNSSL} {
+		if got.Options[n].Type != typ {
+			t.Errorf("option %d: got %v, want %v", n, got.Options[n].Type, typ)
+		}
+	}
+	if r, err := got.Options[1].RDNSS(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(r, rdnss) {
+		t.Errorf("RDNSS mismatch\nwant %+v\ngot  %+v", rdnss, r)
+	}
+	if d, err := got.Options[2].DNSSL(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(d, dnssl) {
+		t.Errorf("DNSSL mismatch\nwant %+v\ngot  %+v", dnssl, d)
+	}
 }
 
 // testPacketICMPv6NeighborSolicitation is the packet:
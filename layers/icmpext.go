@@ -0,0 +1,459 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// ICMPExtensionVersion is the version of the ICMP extension structure
+// defined by RFC 4884.
+const ICMPExtensionVersion = 2
+
+// ICMPExtensionClass is the Class-Num of an ICMP extension object.
+type ICMPExtensionClass uint8
+
+const (
+	ICMPExtensionClassMPLSLabelStack          ICMPExtensionClass = 1 // RFC 4950
+	ICMPExtensionClassInterfaceInformation    ICMPExtensionClass = 2 // RFC 5837
+	ICMPExtensionClassInterfaceIdentification ICMPExtensionClass = 3 // RFC 8335
+	ICMPExtensionClassExtendedInformation     ICMPExtensionClass = 4 // RFC 8883
+)
+
+func (c ICMPExtensionClass) String() string {
+	switch c {
+	case ICMPExtensionClassMPLSLabelStack:
+		return "MPLSLabelStack"
+	case ICMPExtensionClassInterfaceInformation:
+		return "InterfaceInformation"
+	case ICMPExtensionClassInterfaceIdentification:
+		return "InterfaceIdentification"
+	case ICMPExtensionClassExtendedInformation:
+		return "ExtendedInformation"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// ICMPExtensionObject is a single object of an ICMP extension structure.
+type ICMPExtensionObject struct {
+	ClassNum ICMPExtensionClass
+	CType    uint8
+	Payload  []byte
+}
+
+// ICMPExtension is the multi-part message extension structure defined by RFC
+// 4884, shared by extended echo messages and by error messages carrying, for
+// example, MPLS label stacks.
+type ICMPExtension struct {
+	Version  uint8
+	Checksum uint16
+	Objects  []ICMPExtensionObject
+}
+
+// DecodeFromBytes decodes an extension structure, including its header and
+// all of its objects, from data.
+func (e *ICMPExtension) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("ICMP extension header less than 4 bytes")
+	}
+	e.Version = data[0] >> 4
+	if e.Version != ICMPExtensionVersion {
+		return fmt.Errorf("unsupported ICMP extension version %d", e.Version)
+	}
+	e.Checksum = binary.BigEndian.Uint16(data[2:4])
+	e.Objects = e.Objects[:0]
+	for data = data[4:]; len(data) > 0; {
+		if len(data) < 4 {
+			df.SetTruncated()
+			return errors.New("ICMP extension object header less than 4 bytes")
+		}
+		length := int(binary.BigEndian.Uint16(data[0:2]))
+		if length < 4 {
+			return fmt.Errorf("invalid ICMP extension object length %d", length)
+		}
+		if length > len(data) {
+			df.SetTruncated()
+			return fmt.Errorf("ICMP extension object length %d too large, %d bytes remain", length, len(data))
+		}
+		e.Objects = append(e.Objects, ICMPExtensionObject{
+			ClassNum: ICMPExtensionClass(data[2]),
+			CType:    data[3],
+			Payload:  data[4:length],
+		})
+		data = data[length:]
+	}
+	return nil
+}
+
+// SerializeTo prepends the extension structure to b.  Object payloads are
+// written as given, so callers must pad them to a multiple of 4 bytes.  The
+// Version field is ignored and ICMPExtensionVersion is always written.
+func (e *ICMPExtension) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 4
+	for _, o := range e.Objects {
+		length += 4 + len(o.Payload)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = ICMPExtensionVersion << 4
+	bytes[1] = 0
+	off := 4
+	for _, o := range e.Objects {
+		if 4+len(o.Payload) > 0xffff {
+			return fmt.Errorf("ICMP extension object payload of %d bytes too large", len(o.Payload))
+		}
+		binary.BigEndian.PutUint16(bytes[off:], uint16(4+len(o.Payload)))
+		bytes[off+2] = uint8(o.ClassNum)
+		bytes[off+3] = o.CType
+		copy(bytes[off+4:], o.Payload)
+		off += 4 + len(o.Payload)
+	}
+	if opts.ComputeChecksums {
+		bytes[2], bytes[3] = 0, 0
+		e.Checksum = tcpipChecksum(bytes, 0)
+	}
+	binary.BigEndian.PutUint16(bytes[2:], e.Checksum)
+	return nil
+}
+
+// ChecksumValid reports whether the checksum of the extension structure in
+// data, which must span the whole structure, is valid.  A zero checksum means
+// none was computed and is considered valid.
+func (e *ICMPExtension) ChecksumValid(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	if binary.BigEndian.Uint16(data[2:4]) == 0 {
+		return true
+	}
+	return tcpipChecksum(data, 0) == 0
+}
+
+// Object returns the first object of the given class, or nil if there is
+// none.
+func (e *ICMPExtension) Object(class ICMPExtensionClass) *ICMPExtensionObject {
+	for i := range e.Objects {
+		if e.Objects[i].ClassNum == class {
+			return &e.Objects[i]
+		}
+	}
+	return nil
+}
+
+// ICMPInterfaceIdentificationType is the C-Type of an interface
+// identification object, which selects how the interface is identified.
+type ICMPInterfaceIdentificationType uint8
+
+const (
+	ICMPInterfaceIdentificationByName    ICMPInterfaceIdentificationType = 1
+	ICMPInterfaceIdentificationByIndex   ICMPInterfaceIdentificationType = 2
+	ICMPInterfaceIdentificationByAddress ICMPInterfaceIdentificationType = 3
+)
+
+func (t ICMPInterfaceIdentificationType) String() string {
+	switch t {
+	case ICMPInterfaceIdentificationByName:
+		return "Name"
+	case ICMPInterfaceIdentificationByIndex:
+		return "Index"
+	case ICMPInterfaceIdentificationByAddress:
+		return "Address"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// Address family numbers used by interface identification objects.
+const (
+	ICMPAddressFamilyIPv4 uint16 = 1
+	ICMPAddressFamilyIPv6 uint16 = 2
+)
+
+// ICMPInterfaceIdentification is the interface identification object of an
+// extended echo request (RFC 8335), identifying the probed interface by name,
+// ifIndex or address.  Only the field matching Type is meaningful.
+type ICMPInterfaceIdentification struct {
+	Type  ICMPInterfaceIdentificationType
+	Name  string
+	Index uint32
+	// AddressFamily is an IANA address family number.
+	AddressFamily uint16
+	Address       net.IP
+}
+
+// DecodeFromObject decodes an interface identification object.
+func (i *ICMPInterfaceIdentification) DecodeFromObject(o *ICMPExtensionObject) error {
+	if o.ClassNum != ICMPExtensionClassInterfaceIdentification {
+		return fmt.Errorf("ICMP extension object class %v is not an interface identification", o.ClassNum)
+	}
+	*i = ICMPInterfaceIdentification{Type: ICMPInterfaceIdentificationType(o.CType)}
+	data := o.Payload
+	switch i.Type {
+	case ICMPInterfaceIdentificationByName:
+		i.Name = string(bytes.TrimRight(data, "\x00"))
+	case ICMPInterfaceIdentificationByIndex:
+		if len(data) < 4 {
+			return errors.New("ICMP interface index object less than 4 bytes")
+		}
+		i.Index = binary.BigEndian.Uint32(data)
+	case ICMPInterfaceIdentificationByAddress:
+		if len(data) < 4 {
+			return errors.New("ICMP interface address object less than 4 bytes")
+		}
+		i.AddressFamily = binary.BigEndian.Uint16(data[0:2])
+		addrLen := int(data[2])
+		if 4+addrLen > len(data) {
+			return fmt.Errorf("ICMP interface address length %d exceeds object", addrLen)
+		}
+		i.Address = net.IP(data[4 : 4+addrLen])
+	default:
+		return fmt.Errorf("unknown ICMP interface identification type %d", o.CType)
+	}
+	return nil
+}
+
+// ExtensionObject returns the interface identification as an extension
+// object, padded as RFC 8335 requires.
+func (i *ICMPInterfaceIdentification) ExtensionObject() (ICMPExtensionObject, error) {
+	o := ICMPExtensionObject{
+		ClassNum: ICMPExtensionClassInterfaceIdentification,
+		CType:    uint8(i.Type),
+	}
+	switch i.Type {
+	case ICMPInterfaceIdentificationByName:
+		o.Payload = make([]byte, (len(i.Name)+3)&^3)
+		copy(o.Payload, i.Name)
+	case ICMPInterfaceIdentificationByIndex:
+		o.Payload = make([]byte, 4)
+		binary.BigEndian.PutUint32(o.Payload, i.Index)
+	case ICMPInterfaceIdentificationByAddress:
+		addr := i.Address
+		family := i.AddressFamily
+		if ip4 := addr.To4(); ip4 != nil && family != ICMPAddressFamilyIPv6 {
+			addr = ip4
+			if family == 0 {
+				family = ICMPAddressFamilyIPv4
+			}
+		} else if family == 0 && len(addr) == net.IPv6len {
+			family = ICMPAddressFamilyIPv6
+		}
+		if len(addr) > 0xff {
+			return o, fmt.Errorf("ICMP interface address of %d bytes too long", len(addr))
+		}
+		o.Payload = make([]byte, 4+(len(addr)+3)&^3)
+		binary.BigEndian.PutUint16(o.Payload, family)
+		o.Payload[2] = uint8(len(addr))
+		copy(o.Payload[4:], addr)
+	default:
+		return o, fmt.Errorf("unknown ICMP interface identification type %d", uint8(i.Type))
+	}
+	return o, nil
+}
+
+// ICMPExtendedEchoState is the neighbor state reported in an extended echo
+// reply for a probed interface that was identified by an IP address.
+type ICMPExtendedEchoState uint8
+
+const (
+	ICMPExtendedEchoStateReserved   ICMPExtendedEchoState = 0
+	ICMPExtendedEchoStateIncomplete ICMPExtendedEchoState = 1
+	ICMPExtendedEchoStateReachable  ICMPExtendedEchoState = 2
+	ICMPExtendedEchoStateStale      ICMPExtendedEchoState = 3
+	ICMPExtendedEchoStateDelay      ICMPExtendedEchoState = 4
+	ICMPExtendedEchoStateProbe      ICMPExtendedEchoState = 5
+	ICMPExtendedEchoStateFailed     ICMPExtendedEchoState = 6
+)
+
+func (s ICMPExtendedEchoState) String() string {
+	switch s {
+	case ICMPExtendedEchoStateReserved:
+		return "Reserved"
+	case ICMPExtendedEchoStateIncomplete:
+		return "Incomplete"
+	case ICMPExtendedEchoStateReachable:
+		return "Reachable"
+	case ICMPExtendedEchoStateStale:
+		return "Stale"
+	case ICMPExtendedEchoStateDelay:
+		return "Delay"
+	case ICMPExtendedEchoStateProbe:
+		return "Probe"
+	case ICMPExtendedEchoStateFailed:
+		return "Failed"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(s))
+	}
+}
+
+// ICMPExtendedEchoRequest is a PROBE request (RFC 8335), carried by both
+// ICMPv4 and ICMPv6.  It follows the 4 byte type, code and checksum header.
+type ICMPExtendedEchoRequest struct {
+	BaseLayer
+	Identifier     uint16
+	SequenceNumber uint8
+	// Local is set if the probed interface resides on the proxy node itself.
+	Local     bool
+	Extension ICMPExtension
+	// Interface is the decoded interface identification object of the
+	// extension, or nil if there is none.  When serializing, a non-nil
+	// Interface replaces the objects of Extension.
+	Interface *ICMPInterfaceIdentification
+}
+
+// LayerType returns LayerTypeICMPExtendedEchoRequest.
+func (i *ICMPExtendedEchoRequest) LayerType() gopacket.LayerType {
+	return LayerTypeICMPExtendedEchoRequest
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (i *ICMPExtendedEchoRequest) CanDecode() gopacket.LayerClass {
+	return LayerTypeICMPExtendedEchoRequest
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (i *ICMPExtendedEchoRequest) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (i *ICMPExtendedEchoRequest) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("ICMP extended echo request less than 4 bytes")
+	}
+	i.Identifier = binary.BigEndian.Uint16(data[0:2])
+	i.SequenceNumber = data[2]
+	i.Local = data[3]&0x01 != 0
+	i.Interface = nil
+	if err := i.Extension.DecodeFromBytes(data[4:], df); err != nil {
+		return err
+	}
+	if o := i.Extension.Object(ICMPExtensionClassInterfaceIdentification); o != nil {
+		i.Interface = &ICMPInterfaceIdentification{}
+		if err := i.Interface.DecodeFromObject(o); err != nil {
+			return err
+		}
+	}
+	i.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (i *ICMPExtendedEchoRequest) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if i.Interface != nil {
+		o, err := i.Interface.ExtensionObject()
+		if err != nil {
+			return err
+		}
+		i.Extension.Objects = []ICMPExtensionObject{o}
+	}
+	if err := i.Extension.SerializeTo(b, opts); err != nil {
+		return err
+	}
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes, i.Identifier)
+	bytes[2] = i.SequenceNumber
+	bytes[3] = 0
+	if i.Local {
+		bytes[3] = 0x01
+	}
+	return nil
+}
+
+// ICMPExtendedEchoReply is a PROBE reply (RFC 8335), carried by both ICMPv4
+// and ICMPv6.  It follows the 4 byte type, code and checksum header.
+type ICMPExtendedEchoReply struct {
+	BaseLayer
+	Identifier     uint16
+	SequenceNumber uint8
+	State          ICMPExtendedEchoState
+	// Active is set if the probed interface is active.
+	Active bool
+	// IPv4 and IPv6 are set if the probed interface runs the respective
+	// protocol.
+	IPv4 bool
+	IPv6 bool
+}
+
+// LayerType returns LayerTypeICMPExtendedEchoReply.
+func (i *ICMPExtendedEchoReply) LayerType() gopacket.LayerType {
+	return LayerTypeICMPExtendedEchoReply
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (i *ICMPExtendedEchoReply) CanDecode() gopacket.LayerClass {
+	return LayerTypeICMPExtendedEchoReply
+}
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (i *ICMPExtendedEchoReply) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (i *ICMPExtendedEchoReply) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("ICMP extended echo reply less than 4 bytes")
+	}
+	i.Identifier = binary.BigEndian.Uint16(data[0:2])
+	i.SequenceNumber = data[2]
+	i.State = ICMPExtendedEchoState(data[3] >> 5)
+	i.Active = data[3]&0x04 != 0
+	i.IPv4 = data[3]&0x02 != 0
+	i.IPv6 = data[3]&0x01 != 0
+	i.BaseLayer = BaseLayer{data[:4], data[4:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (i *ICMPExtendedEchoReply) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes, i.Identifier)
+	bytes[2] = i.SequenceNumber
+	bytes[3] = uint8(i.State&0x7) << 5
+	if i.Active {
+		bytes[3] |= 0x04
+	}
+	if i.IPv4 {
+		bytes[3] |= 0x02
+	}
+	if i.IPv6 {
+		bytes[3] |= 0x01
+	}
+	return nil
+}
+
+func decodeICMPExtendedEchoRequest(data []byte, p gopacket.PacketBuilder) error {
+	i := &ICMPExtendedEchoRequest{}
+	return decodingLayerDecoder(i, data, p)
+}
+
+func decodeICMPExtendedEchoReply(data []byte, p gopacket.PacketBuilder) error {
+	i := &ICMPExtendedEchoReply{}
+	return decodingLayerDecoder(i, data, p)
+}
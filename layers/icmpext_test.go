@@ -0,0 +1,141 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestICMPv4ExtendedEchoRequestSerialization(t *testing.T) {
+	ip := &IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: IPProtocolICMPv4,
+		SrcIP:    net.IP{192, 0, 2, 1},
+		DstIP:    net.IP{192, 0, 2, 2},
+	}
+	icmp := &ICMPv4{TypeCode: CreateICMPv4TypeCode(ICMPv4TypeExtendedEchoRequest, 0)}
+	req := &ICMPExtendedEchoRequest{
+		Identifier:     0x1234,
+		SequenceNumber: 7,
+		Local:          true,
+		Interface: &ICMPInterfaceIdentification{
+			Type: ICMPInterfaceIdentificationByName,
+			Name: "eth0.100",
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, icmp, req); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x2a, 0x00, 0x00, 0x00, // type, code, checksum
+		0x12, 0x34, 0x07, 0x01, // identifier, sequence, L bit
+		0x20, 0x00, 0x00, 0x00, // extension header
+		0x00, 0x0c, 0x03, 0x01, 'e', 't', 'h', '0', '.', '1', '0', '0',
+	}
+	got := buf.Bytes()[20:]
+	copy(want[2:4], got[2:4])
+	copy(want[10:12], got[10:12])
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("Serialization mismatch, \nwant %x\ngot  %x", want, got)
+	}
+	if tcpipChecksum(got, 0) != 0 {
+		t.Error("Invalid ICMP checksum")
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeICMPv4, LayerTypeICMPExtendedEchoRequest}, t)
+	decoded, ok := p.Layer(LayerTypeICMPExtendedEchoRequest).(*ICMPExtendedEchoRequest)
+	if !ok {
+		t.Fatal("No ICMPExtendedEchoRequest layer")
+	}
+	if decoded.Identifier != 0x1234 || decoded.SequenceNumber != 7 || !decoded.Local {
+		t.Errorf("Unexpected extended echo request %#v", decoded)
+	}
+	if !reflect.DeepEqual(req.Interface, decoded.Interface) {
+		t.Errorf("Interface mismatch, \nwant %#v\ngot  %#v\n", req.Interface, decoded.Interface)
+	}
+	if !decoded.Extension.ChecksumValid(got[8:]) {
+		t.Error("Invalid extension checksum")
+	}
+}
+
+func TestICMPv6ExtendedEcho(t *testing.T) {
+	request := []byte{
+		0xa0, 0x00, 0x00, 0x00, 0xbe, 0xef, 0x01, 0x00,
+		0x20, 0x00, 0x00, 0x00,
+		0x00, 0x18, 0x03, 0x03, 0x00, 0x02, 0x10, 0x00, // by address, IPv6, 16 bytes
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+	}
+	p := gopacket.NewPacket(request, LayerTypeICMPv6, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeICMPv6, LayerTypeICMPExtendedEchoRequest}, t)
+	req, ok := p.Layer(LayerTypeICMPExtendedEchoRequest).(*ICMPExtendedEchoRequest)
+	if !ok {
+		t.Fatal("No ICMPExtendedEchoRequest layer")
+	}
+	wantIf := &ICMPInterfaceIdentification{
+		Type:          ICMPInterfaceIdentificationByAddress,
+		AddressFamily: ICMPAddressFamilyIPv6,
+		Address:       net.ParseIP("2001:db8::1"),
+	}
+	if req.Local || !reflect.DeepEqual(wantIf, req.Interface) {
+		t.Errorf("Interface mismatch, \nwant %#v\ngot  %#v\n", wantIf, req.Interface)
+	}
+
+	reply := []byte{0xa1, 0x00, 0x00, 0x00, 0xbe, 0xef, 0x01, 0x45}
+	p = gopacket.NewPacket(reply, LayerTypeICMPv6, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeICMPv6, LayerTypeICMPExtendedEchoReply}, t)
+	want := &ICMPExtendedEchoReply{
+		BaseLayer:      BaseLayer{reply[4:], []byte{}},
+		Identifier:     0xbeef,
+		SequenceNumber: 1,
+		State:          ICMPExtendedEchoStateReachable,
+		Active:         true,
+		IPv6:           true,
+	}
+	if got := p.Layer(LayerTypeICMPExtendedEchoReply); !reflect.DeepEqual(want, got) {
+		t.Errorf("Reply mismatch, \nwant %#v\ngot  %#v\n", want, got)
+	}
+	if got := p.Layer(LayerTypeICMPv6).(*ICMPv6).TypeCode.String(); got != "ExtendedEchoReply(NoError)" {
+		t.Errorf("Unexpected type code %q", got)
+	}
+}
+
+func TestICMPExtensionObjects(t *testing.T) {
+	// An MPLS label stack object followed by a truncated object.
+	data := []byte{
+		0x20, 0x00, 0x00, 0x00,
+		0x00, 0x08, 0x01, 0x01, 0x00, 0x01, 0x01, 0x01,
+		0x00, 0x08, 0x02,
+	}
+	var e ICMPExtension
+	if err := e.DecodeFromBytes(data[:12], gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode extension:", err)
+	}
+	want := []ICMPExtensionObject{{ClassNum: ICMPExtensionClassMPLSLabelStack, CType: 1, Payload: data[8:12]}}
+	if !reflect.DeepEqual(want, e.Objects) {
+		t.Errorf("Objects mismatch, \nwant %#v\ngot  %#v\n", want, e.Objects)
+	}
+	if err := e.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("Expected an error decoding a truncated object")
+	}
+}
@@ -77,18 +77,20 @@ func (i IGMPv3GroupRecordType) String() string {
 // IGMP represents an IGMPv3 message.
 type IGMP struct {
 	BaseLayer
-	Type                    IGMPType
-	MaxResponseTime         time.Duration
-	Checksum                uint16
+	Type            IGMPType
+	MaxResponseTime time.Duration
+	Checksum        uint16
+	// The following are only set for membership queries.
 	GroupAddress            net.IP
-	SupressRouterProcessing bool
-	RobustnessValue         uint8
-	IntervalTime            time.Duration
-	SourceAddresses         []net.IP
-	NumberOfGroupRecords    uint16
+	SupressRouterProcessing bool          // The S flag.
+	RobustnessValue         uint8         // The querier's robustness variable (QRV).
+	IntervalTime            time.Duration // The querier's query interval (QQIC).
 	NumberOfSources         uint16
-	GroupRecords            []IGMPv3GroupRecord
-	Version                 uint8 // IGMP protocol version
+	SourceAddresses         []net.IP
+	// The following are only set for membership reports.
+	NumberOfGroupRecords uint16
+	GroupRecords         []IGMPv3GroupRecord
+	Version              uint8 // IGMP protocol version
 }
 
 // IGMPv1or2 stores header details for an IGMPv1 or IGMPv2 packet.
@@ -168,39 +170,67 @@ type IGMPv3GroupRecord struct {
 	AuxData          uint32 // NOT USED
 }
 
-func (i *IGMP) decodeIGMPv3MembershipReport(data []byte) error {
+func (i *IGMP) decodeIGMPv3MembershipReport(data []byte, df gopacket.DecodeFeedback) error {
 	if len(data) < 8 {
+		df.SetTruncated()
 		return errors.New("IGMPv3 Membership Report too small #1")
 	}
 
+	i.MaxResponseTime = 0
 	i.Checksum = binary.BigEndian.Uint16(data[2:4])
+	i.GroupAddress = nil
+	i.SupressRouterProcessing = false
+	i.RobustnessValue = 0
+	i.IntervalTime = 0
+	i.NumberOfSources = 0
+	i.SourceAddresses = i.SourceAddresses[:0]
 	i.NumberOfGroupRecords = binary.BigEndian.Uint16(data[6:8])
 
-	recordOffset := 8
+	// Check the records' lengths and count their sources first, so that the
+	// records and all of their source addresses take an allocation each
+	// however many records there are.
+	recordOffset, numSources := 8, 0
 	for j := 0; j < int(i.NumberOfGroupRecords); j++ {
 		if len(data) < recordOffset+8 {
+			df.SetTruncated()
 			return errors.New("IGMPv3 Membership Report too small #2")
 		}
+		n := int(binary.BigEndian.Uint16(data[recordOffset+2 : recordOffset+4]))
+		recordEnd := recordOffset + 8 + 4*n + 4*int(data[recordOffset+1])
+		if len(data) < recordEnd {
+			df.SetTruncated()
+			return errors.New("IGMPv3 Membership Report too small #3")
+		}
+		numSources += n
+		recordOffset = recordEnd
+	}
+
+	if cap(i.GroupRecords) < int(i.NumberOfGroupRecords) {
+		i.GroupRecords = make([]IGMPv3GroupRecord, i.NumberOfGroupRecords)
+	} else {
+		i.GroupRecords = i.GroupRecords[:i.NumberOfGroupRecords]
+	}
+	sources := make([]net.IP, numSources)
 
-		var gr IGMPv3GroupRecord
+	recordOffset = 8
+	for j := range i.GroupRecords {
+		gr := &i.GroupRecords[j]
 		gr.Type = IGMPv3GroupRecordType(data[recordOffset])
 		gr.AuxDataLen = data[recordOffset+1]
 		gr.NumberOfSources = binary.BigEndian.Uint16(data[recordOffset+2 : recordOffset+4])
 		gr.MulticastAddress = net.IP(data[recordOffset+4 : recordOffset+8])
 
-		if len(data) < recordOffset+8+int(gr.NumberOfSources)*4 {
-			return errors.New("IGMPv3 Membership Report too small #3")
-		}
-
-		// append source address records.
-		for i := 0; i < int(gr.NumberOfSources); i++ {
-			sourceAddr := net.IP(data[recordOffset+8+i*4 : recordOffset+12+i*4])
-			gr.SourceAddresses = append(gr.SourceAddresses, sourceAddr)
+		n := int(gr.NumberOfSources)
+		gr.SourceAddresses = sources[:n:n]
+		sources = sources[n:]
+		for k := range gr.SourceAddresses {
+			gr.SourceAddresses[k] = net.IP(data[recordOffset+8+k*4 : recordOffset+12+k*4])
 		}
 
-		i.GroupRecords = append(i.GroupRecords, gr)
-		recordOffset += 8 + 4*int(gr.NumberOfSources)
+		// Auxiliary data is skipped, as no use for it has been defined.
+		recordOffset += 8 + 4*n + 4*int(gr.AuxDataLen)
 	}
+	i.BaseLayer = BaseLayer{Contents: data[:recordOffset], Payload: data[recordOffset:]}
 	return nil
 }
 
@@ -221,8 +251,9 @@ func (i *IGMP) decodeIGMPv3MembershipReport(data []byte) error {
 // +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
 //
 // decodeIGMPv3MembershipQuery parses the IGMPv3 message of type 0x11
-func (i *IGMP) decodeIGMPv3MembershipQuery(data []byte) error {
+func (i *IGMP) decodeIGMPv3MembershipQuery(data []byte, df gopacket.DecodeFeedback) error {
 	if len(data) < 12 {
+		df.SetTruncated()
 		return errors.New("IGMPv3 Membership Query too small #1")
 	}
 
@@ -231,29 +262,45 @@ func (i *IGMP) decodeIGMPv3MembershipQuery(data []byte) error {
 	i.SupressRouterProcessing = data[8]&0x8 != 0
 	i.GroupAddress = net.IP(data[4:8])
 	i.RobustnessValue = data[8] & 0x7
-	i.IntervalTime = igmpTimeDecode(data[9])
+	i.IntervalTime = time.Second * time.Duration(igmpCodeDecode(data[9]))
 	i.NumberOfSources = binary.BigEndian.Uint16(data[10:12])
+	i.NumberOfGroupRecords = 0
+	i.GroupRecords = i.GroupRecords[:0]
 
-	if len(data) < 12+int(i.NumberOfSources)*4 {
+	length := 12 + int(i.NumberOfSources)*4
+	if len(data) < length {
+		df.SetTruncated()
 		return errors.New("IGMPv3 Membership Query too small #2")
 	}
 
-	for j := 0; j < int(i.NumberOfSources); j++ {
-		i.SourceAddresses = append(i.SourceAddresses, net.IP(data[12+j*4:16+j*4]))
+	if cap(i.SourceAddresses) < int(i.NumberOfSources) {
+		i.SourceAddresses = make([]net.IP, i.NumberOfSources)
+	} else {
+		i.SourceAddresses = i.SourceAddresses[:i.NumberOfSources]
 	}
-
+	for j := range i.SourceAddresses {
+		i.SourceAddresses[j] = net.IP(data[12+j*4 : 16+j*4])
+	}
+	i.BaseLayer = BaseLayer{Contents: data[:length], Payload: data[length:]}
 	return nil
 }
 
 // igmpTimeDecode decodes the duration created by the given byte, using the
 // algorithm in http://www.rfc-base.org/txt/rfc-3376.txt section 4.1.1.
 func igmpTimeDecode(t uint8) time.Duration {
+	return time.Millisecond * 100 * time.Duration(igmpCodeDecode(t))
+}
+
+// igmpCodeDecode decodes a Max Resp Code or QQIC field, which hold values
+// of 128 and up in a floating point format described in RFC 3376 sections
+// 4.1.1 and 4.1.7.
+func igmpCodeDecode(t uint8) int {
 	if t&0x80 == 0 {
-		return time.Millisecond * 100 * time.Duration(t)
+		return int(t)
 	}
-	mant := (t & 0x70) >> 4
-	exp := t & 0x0F
-	return time.Millisecond * 100 * time.Duration((mant|0x10)<<(exp+3))
+	mant := int(t&0x0F) | 0x10
+	exp := uint(t&0x70) >> 4
+	return mant << (exp + 3)
 }
 
 // LayerType returns LayerTypeIGMP for the V1,2,3 message protocol formats.
@@ -289,17 +336,16 @@ func (i *IGMP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 
 	// common IGMP header values between versions 1..3 of IGMP specification..
 	i.Type = IGMPType(data[0])
+	i.Version = 3
 
 	switch i.Type {
 	case IGMPMembershipQuery:
-		i.decodeIGMPv3MembershipQuery(data)
+		return i.decodeIGMPv3MembershipQuery(data, df)
 	case IGMPMembershipReportV3:
-		i.decodeIGMPv3MembershipReport(data)
+		return i.decodeIGMPv3MembershipReport(data, df)
 	default:
 		return errors.New("unsupported IGMP type")
 	}
-
-	return nil
 }
 
 // CanDecode returns the set of layer types that this DecodingLayer can decode.
@@ -7,7 +7,11 @@
 package layers
 
 import (
+	"encoding/binary"
+	"net"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/gopacket"
 )
@@ -130,6 +134,10 @@ func TestIGMPv3MembershipQuery(t *testing.T) {
 	if igmp.Type != IGMPMembershipQuery {
 		t.Fatal("Invalid IGMP type")
 	}
+	if igmp.MaxResponseTime != 2400*time.Millisecond || igmp.SupressRouterProcessing ||
+		igmp.RobustnessValue != 2 || igmp.IntervalTime != 20*time.Second || len(igmp.SourceAddresses) != 0 {
+		t.Errorf("Unexpected query %+v", igmp)
+	}
 }
 
 func BenchmarkDecodeigmp3v3MembershipQueryPacket(b *testing.B) {
@@ -169,3 +177,79 @@ func BenchmarkDecodeigmpv3MembershipReport2Records(b *testing.B) {
 		gopacket.NewPacket(igmpv3MembershipReport2Records, LinkTypeEthernet, gopacket.NoCopy)
 	}
 }
+
+func TestIGMPv3GroupAndSourceSpecific(t *testing.T) {
+	// A group and source specific query with the S flag set and a QQIC
+	// using the floating point format, and a report with auxiliary data.
+	query := []byte{
+		0x11, 0x8a, 0x00, 0x00, 0xe8, 0x01, 0x01, 0x01, 0x0a, 0x82, 0x00, 0x02,
+		0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02,
+	}
+	igmp := &IGMP{}
+	if err := igmp.DecodeFromBytes(query, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	wantSources := []net.IP{{10, 0, 0, 1}, {10, 0, 0, 2}}
+	if igmp.MaxResponseTime != 20800*time.Millisecond || !igmp.SupressRouterProcessing || igmp.RobustnessValue != 2 ||
+		igmp.IntervalTime != 144*time.Second || !reflect.DeepEqual(igmp.SourceAddresses, wantSources) {
+		t.Errorf("Unexpected query %+v", igmp)
+	}
+
+	report := []byte{
+		0x22, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+		0x01, 0x01, 0x00, 0x02, 0xe8, 0x01, 0x01, 0x01, 0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02,
+		0xde, 0xad, 0xbe, 0xef,
+		0x06, 0x00, 0x00, 0x01, 0xe8, 0x01, 0x01, 0x02, 0x0a, 0x00, 0x00, 0x03,
+	}
+	if err := igmp.DecodeFromBytes(report, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	want := []IGMPv3GroupRecord{
+		{
+			Type:             IGMPIsIn,
+			AuxDataLen:       1,
+			NumberOfSources:  2,
+			MulticastAddress: net.IP{232, 1, 1, 1},
+			SourceAddresses:  wantSources,
+		},
+		{
+			Type:             IGMPBlock,
+			NumberOfSources:  1,
+			MulticastAddress: net.IP{232, 1, 1, 2},
+			SourceAddresses:  []net.IP{{10, 0, 0, 3}},
+		},
+	}
+	if !reflect.DeepEqual(igmp.GroupRecords, want) {
+		t.Errorf("Group records mismatch\nwant %+v\ngot  %+v", want, igmp.GroupRecords)
+	}
+	if len(igmp.SourceAddresses) != 0 || igmp.GroupAddress != nil {
+		t.Errorf("Query fields left over from previous decode: %+v", igmp)
+	}
+
+	if err := igmp.DecodeFromBytes(report[:30], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("Expected error decoding truncated report")
+	}
+}
+
+func TestIGMPv3ReportAllocations(t *testing.T) {
+	const records = 300
+	report := make([]byte, 8, 8+records*12)
+	report[0] = byte(IGMPMembershipReportV3)
+	binary.BigEndian.PutUint16(report[6:8], records)
+	for i := 0; i < records; i++ {
+		report = append(report, byte(IGMPAllow), 0, 0, 1, 232, 1, byte(i>>8), byte(i), 10, 0, byte(i>>8), byte(i))
+	}
+	igmp := &IGMP{}
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := igmp.DecodeFromBytes(report, gopacket.NilDecodeFeedback); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// Only the shared source address slice; the records slice is reused.
+	if allocs > 1 {
+		t.Errorf("Decoding %d records took %v allocations", records, allocs)
+	}
+	if len(igmp.GroupRecords) != records || !igmp.GroupRecords[records-1].SourceAddresses[0].Equal(net.IP{10, 0, 1, 43}) {
+		t.Errorf("Unexpected records %v", igmp.GroupRecords[records-1])
+	}
+}
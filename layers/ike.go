@@ -0,0 +1,1003 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// IKEExchangeType is the exchange type of an IKE message.
+type IKEExchangeType uint8
+
+const (
+	IKEExchangeTypeSAInit        IKEExchangeType = 34
+	IKEExchangeTypeAuth          IKEExchangeType = 35
+	IKEExchangeTypeCreateChildSA IKEExchangeType = 36
+	IKEExchangeTypeInformational IKEExchangeType = 37
+)
+
+func (t IKEExchangeType) String() string {
+	switch t {
+	case IKEExchangeTypeSAInit:
+		return "IKE_SA_INIT"
+	case IKEExchangeTypeAuth:
+		return "IKE_AUTH"
+	case IKEExchangeTypeCreateChildSA:
+		return "CREATE_CHILD_SA"
+	case IKEExchangeTypeInformational:
+		return "INFORMATIONAL"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// IKEPayloadType is the type of an IKEv2 payload, as found in the next
+// payload field of the header and of each payload.
+type IKEPayloadType uint8
+
+const (
+	IKEPayloadTypeNone              IKEPayloadType = 0
+	IKEPayloadTypeSA                IKEPayloadType = 33
+	IKEPayloadTypeKE                IKEPayloadType = 34
+	IKEPayloadTypeIDi               IKEPayloadType = 35
+	IKEPayloadTypeIDr               IKEPayloadType = 36
+	IKEPayloadTypeCert              IKEPayloadType = 37
+	IKEPayloadTypeCertReq           IKEPayloadType = 38
+	IKEPayloadTypeAuth              IKEPayloadType = 39
+	IKEPayloadTypeNonce             IKEPayloadType = 40
+	IKEPayloadTypeNotify            IKEPayloadType = 41
+	IKEPayloadTypeDelete            IKEPayloadType = 42
+	IKEPayloadTypeVendorID          IKEPayloadType = 43
+	IKEPayloadTypeTSi               IKEPayloadType = 44
+	IKEPayloadTypeTSr               IKEPayloadType = 45
+	IKEPayloadTypeEncrypted         IKEPayloadType = 46
+	IKEPayloadTypeConfiguration     IKEPayloadType = 47
+	IKEPayloadTypeEAP               IKEPayloadType = 48
+	IKEPayloadTypeEncryptedFragment IKEPayloadType = 53
+)
+
+var ikePayloadTypeNames = map[IKEPayloadType]string{
+	IKEPayloadTypeNone:              "None",
+	IKEPayloadTypeSA:                "SA",
+	IKEPayloadTypeKE:                "KE",
+	IKEPayloadTypeIDi:               "IDi",
+	IKEPayloadTypeIDr:               "IDr",
+	IKEPayloadTypeCert:              "CERT",
+	IKEPayloadTypeCertReq:           "CERTREQ",
+	IKEPayloadTypeAuth:              "AUTH",
+	IKEPayloadTypeNonce:             "Nonce",
+	IKEPayloadTypeNotify:            "Notify",
+	IKEPayloadTypeDelete:            "Delete",
+	IKEPayloadTypeVendorID:          "VendorID",
+	IKEPayloadTypeTSi:               "TSi",
+	IKEPayloadTypeTSr:               "TSr",
+	IKEPayloadTypeEncrypted:         "Encrypted",
+	IKEPayloadTypeConfiguration:     "Configuration",
+	IKEPayloadTypeEAP:               "EAP",
+	IKEPayloadTypeEncryptedFragment: "EncryptedFragment",
+}
+
+func (t IKEPayloadType) String() string {
+	if name, ok := ikePayloadTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// IKEProtocolID identifies the protocol an SA, notification or deletion
+// applies to.
+type IKEProtocolID uint8
+
+const (
+	IKEProtocolIDIKE IKEProtocolID = 1
+	IKEProtocolIDAH  IKEProtocolID = 2
+	IKEProtocolIDESP IKEProtocolID = 3
+)
+
+func (p IKEProtocolID) String() string {
+	switch p {
+	case IKEProtocolIDIKE:
+		return "IKE"
+	case IKEProtocolIDAH:
+		return "AH"
+	case IKEProtocolIDESP:
+		return "ESP"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(p))
+	}
+}
+
+// IKETransformType is the type of a transform within a proposal.
+type IKETransformType uint8
+
+const (
+	IKETransformTypeEncryption IKETransformType = 1
+	IKETransformTypePRF        IKETransformType = 2
+	IKETransformTypeIntegrity  IKETransformType = 3
+	IKETransformTypeDH         IKETransformType = 4
+	IKETransformTypeESN        IKETransformType = 5
+)
+
+func (t IKETransformType) String() string {
+	switch t {
+	case IKETransformTypeEncryption:
+		return "Encryption"
+	case IKETransformTypePRF:
+		return "PRF"
+	case IKETransformTypeIntegrity:
+		return "Integrity"
+	case IKETransformTypeDH:
+		return "DH"
+	case IKETransformTypeESN:
+		return "ESN"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// IKEEncryptionAlgorithm is the transform ID of an encryption transform.
+type IKEEncryptionAlgorithm uint16
+
+const (
+	IKEEncryptionAlgorithmDESIV64             IKEEncryptionAlgorithm = 1
+	IKEEncryptionAlgorithmDES                 IKEEncryptionAlgorithm = 2
+	IKEEncryptionAlgorithm3DES                IKEEncryptionAlgorithm = 3
+	IKEEncryptionAlgorithmRC5                 IKEEncryptionAlgorithm = 4
+	IKEEncryptionAlgorithmIDEA                IKEEncryptionAlgorithm = 5
+	IKEEncryptionAlgorithmCAST                IKEEncryptionAlgorithm = 6
+	IKEEncryptionAlgorithmBlowfish            IKEEncryptionAlgorithm = 7
+	IKEEncryptionAlgorithm3IDEA               IKEEncryptionAlgorithm = 8
+	IKEEncryptionAlgorithmDESIV32             IKEEncryptionAlgorithm = 9
+	IKEEncryptionAlgorithmNull                IKEEncryptionAlgorithm = 11
+	IKEEncryptionAlgorithmAESCBC              IKEEncryptionAlgorithm = 12
+	IKEEncryptionAlgorithmAESCTR              IKEEncryptionAlgorithm = 13
+	IKEEncryptionAlgorithmAESCCM8             IKEEncryptionAlgorithm = 14
+	IKEEncryptionAlgorithmAESCCM12            IKEEncryptionAlgorithm = 15
+	IKEEncryptionAlgorithmAESCCM16            IKEEncryptionAlgorithm = 16
+	IKEEncryptionAlgorithmAESGCM8             IKEEncryptionAlgorithm = 18
+	IKEEncryptionAlgorithmAESGCM12            IKEEncryptionAlgorithm = 19
+	IKEEncryptionAlgorithmAESGCM16            IKEEncryptionAlgorithm = 20
+	IKEEncryptionAlgorithmNullAuthAESGMAC     IKEEncryptionAlgorithm = 21
+	IKEEncryptionAlgorithmCamelliaCBC         IKEEncryptionAlgorithm = 23
+	IKEEncryptionAlgorithmCamelliaCTR         IKEEncryptionAlgorithm = 24
+	IKEEncryptionAlgorithmCamelliaCCM8        IKEEncryptionAlgorithm = 25
+	IKEEncryptionAlgorithmCamelliaCCM12       IKEEncryptionAlgorithm = 26
+	IKEEncryptionAlgorithmCamelliaCCM16       IKEEncryptionAlgorithm = 27
+	IKEEncryptionAlgorithmChaCha20Poly1305    IKEEncryptionAlgorithm = 28
+	IKEEncryptionAlgorithmAESCCM8IIV          IKEEncryptionAlgorithm = 29
+	IKEEncryptionAlgorithmAESGCM16IIV         IKEEncryptionAlgorithm = 30
+	IKEEncryptionAlgorithmChaCha20Poly1305IIV IKEEncryptionAlgorithm = 31
+)
+
+var ikeEncryptionAlgorithmNames = map[IKEEncryptionAlgorithm]string{
+	IKEEncryptionAlgorithmDESIV64:             "DES_IV64",
+	IKEEncryptionAlgorithmDES:                 "DES",
+	IKEEncryptionAlgorithm3DES:                "3DES",
+	IKEEncryptionAlgorithmRC5:                 "RC5",
+	IKEEncryptionAlgorithmIDEA:                "IDEA",
+	IKEEncryptionAlgorithmCAST:                "CAST",
+	IKEEncryptionAlgorithmBlowfish:            "BLOWFISH",
+	IKEEncryptionAlgorithm3IDEA:               "3IDEA",
+	IKEEncryptionAlgorithmDESIV32:             "DES_IV32",
+	IKEEncryptionAlgorithmNull:                "NULL",
+	IKEEncryptionAlgorithmAESCBC:              "AES_CBC",
+	IKEEncryptionAlgorithmAESCTR:              "AES_CTR",
+	IKEEncryptionAlgorithmAESCCM8:             "AES_CCM_8",
+	IKEEncryptionAlgorithmAESCCM12:            "AES_CCM_12",
+	IKEEncryptionAlgorithmAESCCM16:            "AES_CCM_16",
+	IKEEncryptionAlgorithmAESGCM8:             "AES_GCM_8",
+	IKEEncryptionAlgorithmAESGCM12:            "AES_GCM_12",
+	IKEEncryptionAlgorithmAESGCM16:            "AES_GCM_16",
+	IKEEncryptionAlgorithmNullAuthAESGMAC:     "NULL_AUTH_AES_GMAC",
+	IKEEncryptionAlgorithmCamelliaCBC:         "CAMELLIA_CBC",
+	IKEEncryptionAlgorithmCamelliaCTR:         "CAMELLIA_CTR",
+	IKEEncryptionAlgorithmCamelliaCCM8:        "CAMELLIA_CCM_8",
+	IKEEncryptionAlgorithmCamelliaCCM12:       "CAMELLIA_CCM_12",
+	IKEEncryptionAlgorithmCamelliaCCM16:       "CAMELLIA_CCM_16",
+	IKEEncryptionAlgorithmChaCha20Poly1305:    "CHACHA20_POLY1305",
+	IKEEncryptionAlgorithmAESCCM8IIV:          "AES_CCM_8_IIV",
+	IKEEncryptionAlgorithmAESGCM16IIV:         "AES_GCM_16_IIV",
+	IKEEncryptionAlgorithmChaCha20Poly1305IIV: "CHACHA20_POLY1305_IIV",
+}
+
+func (a IKEEncryptionAlgorithm) String() string {
+	if name, ok := ikeEncryptionAlgorithmNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(a))
+}
+
+// IKEPRFAlgorithm is the transform ID of a pseudorandom function transform.
+type IKEPRFAlgorithm uint16
+
+const (
+	IKEPRFAlgorithmHMACMD5     IKEPRFAlgorithm = 1
+	IKEPRFAlgorithmHMACSHA1    IKEPRFAlgorithm = 2
+	IKEPRFAlgorithmHMACTiger   IKEPRFAlgorithm = 3
+	IKEPRFAlgorithmAES128XCBC  IKEPRFAlgorithm = 4
+	IKEPRFAlgorithmHMACSHA2256 IKEPRFAlgorithm = 5
+	IKEPRFAlgorithmHMACSHA2384 IKEPRFAlgorithm = 6
+	IKEPRFAlgorithmHMACSHA2512 IKEPRFAlgorithm = 7
+	IKEPRFAlgorithmAES128CMAC  IKEPRFAlgorithm = 8
+)
+
+var ikePRFAlgorithmNames = map[IKEPRFAlgorithm]string{
+	IKEPRFAlgorithmHMACMD5:     "PRF_HMAC_MD5",
+	IKEPRFAlgorithmHMACSHA1:    "PRF_HMAC_SHA1",
+	IKEPRFAlgorithmHMACTiger:   "PRF_HMAC_TIGER",
+	IKEPRFAlgorithmAES128XCBC:  "PRF_AES128_XCBC",
+	IKEPRFAlgorithmHMACSHA2256: "PRF_HMAC_SHA2_256",
+	IKEPRFAlgorithmHMACSHA2384: "PRF_HMAC_SHA2_384",
+	IKEPRFAlgorithmHMACSHA2512: "PRF_HMAC_SHA2_512",
+	IKEPRFAlgorithmAES128CMAC:  "PRF_AES128_CMAC",
+}
+
+func (a IKEPRFAlgorithm) String() string {
+	if name, ok := ikePRFAlgorithmNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(a))
+}
+
+// IKEIntegrityAlgorithm is the transform ID of an integrity transform.
+type IKEIntegrityAlgorithm uint16
+
+const (
+	IKEIntegrityAlgorithmNone           IKEIntegrityAlgorithm = 0
+	IKEIntegrityAlgorithmHMACMD596      IKEIntegrityAlgorithm = 1
+	IKEIntegrityAlgorithmHMACSHA196     IKEIntegrityAlgorithm = 2
+	IKEIntegrityAlgorithmDESMAC         IKEIntegrityAlgorithm = 3
+	IKEIntegrityAlgorithmKPDKMD5        IKEIntegrityAlgorithm = 4
+	IKEIntegrityAlgorithmAESXCBC96      IKEIntegrityAlgorithm = 5
+	IKEIntegrityAlgorithmHMACMD5128     IKEIntegrityAlgorithm = 6
+	IKEIntegrityAlgorithmHMACSHA1160    IKEIntegrityAlgorithm = 7
+	IKEIntegrityAlgorithmAESCMAC96      IKEIntegrityAlgorithm = 8
+	IKEIntegrityAlgorithmAES128GMAC     IKEIntegrityAlgorithm = 9
+	IKEIntegrityAlgorithmAES192GMAC     IKEIntegrityAlgorithm = 10
+	IKEIntegrityAlgorithmAES256GMAC     IKEIntegrityAlgorithm = 11
+	IKEIntegrityAlgorithmHMACSHA2256128 IKEIntegrityAlgorithm = 12
+	IKEIntegrityAlgorithmHMACSHA2384192 IKEIntegrityAlgorithm = 13
+	IKEIntegrityAlgorithmHMACSHA2512256 IKEIntegrityAlgorithm = 14
+)
+
+var ikeIntegrityAlgorithmNames = map[IKEIntegrityAlgorithm]string{
+	IKEIntegrityAlgorithmNone:           "NONE",
+	IKEIntegrityAlgorithmHMACMD596:      "AUTH_HMAC_MD5_96",
+	IKEIntegrityAlgorithmHMACSHA196:     "AUTH_HMAC_SHA1_96",
+	IKEIntegrityAlgorithmDESMAC:         "AUTH_DES_MAC",
+	IKEIntegrityAlgorithmKPDKMD5:        "AUTH_KPDK_MD5",
+	IKEIntegrityAlgorithmAESXCBC96:      "AUTH_AES_XCBC_96",
+	IKEIntegrityAlgorithmHMACMD5128:     "AUTH_HMAC_MD5_128",
+	IKEIntegrityAlgorithmHMACSHA1160:    "AUTH_HMAC_SHA1_160",
+	IKEIntegrityAlgorithmAESCMAC96:      "AUTH_AES_CMAC_96",
+	IKEIntegrityAlgorithmAES128GMAC:     "AUTH_AES_128_GMAC",
+	IKEIntegrityAlgorithmAES192GMAC:     "AUTH_AES_192_GMAC",
+	IKEIntegrityAlgorithmAES256GMAC:     "AUTH_AES_256_GMAC",
+	IKEIntegrityAlgorithmHMACSHA2256128: "AUTH_HMAC_SHA2_256_128",
+	IKEIntegrityAlgorithmHMACSHA2384192: "AUTH_HMAC_SHA2_384_192",
+	IKEIntegrityAlgorithmHMACSHA2512256: "AUTH_HMAC_SHA2_512_256",
+}
+
+func (a IKEIntegrityAlgorithm) String() string {
+	if name, ok := ikeIntegrityAlgorithmNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(a))
+}
+
+// IKEDHGroup is the transform ID of a Diffie-Hellman group transform, also
+// used by KE payloads.
+type IKEDHGroup uint16
+
+const (
+	IKEDHGroupNone            IKEDHGroup = 0
+	IKEDHGroupMODP768         IKEDHGroup = 1
+	IKEDHGroupMODP1024        IKEDHGroup = 2
+	IKEDHGroupMODP1536        IKEDHGroup = 5
+	IKEDHGroupMODP2048        IKEDHGroup = 14
+	IKEDHGroupMODP3072        IKEDHGroup = 15
+	IKEDHGroupMODP4096        IKEDHGroup = 16
+	IKEDHGroupMODP6144        IKEDHGroup = 17
+	IKEDHGroupMODP8192        IKEDHGroup = 18
+	IKEDHGroupECP256          IKEDHGroup = 19
+	IKEDHGroupECP384          IKEDHGroup = 20
+	IKEDHGroupECP521          IKEDHGroup = 21
+	IKEDHGroupMODP1024S160    IKEDHGroup = 22
+	IKEDHGroupMODP2048S224    IKEDHGroup = 23
+	IKEDHGroupMODP2048S256    IKEDHGroup = 24
+	IKEDHGroupECP192          IKEDHGroup = 25
+	IKEDHGroupECP224          IKEDHGroup = 26
+	IKEDHGroupBrainpoolP224r1 IKEDHGroup = 27
+	IKEDHGroupBrainpoolP256r1 IKEDHGroup = 28
+	IKEDHGroupBrainpoolP384r1 IKEDHGroup = 29
+	IKEDHGroupBrainpoolP512r1 IKEDHGroup = 30
+	IKEDHGroupCurve25519      IKEDHGroup = 31
+	IKEDHGroupCurve448        IKEDHGroup = 32
+)
+
+var ikeDHGroupNames = map[IKEDHGroup]string{
+	IKEDHGroupNone:            "NONE",
+	IKEDHGroupMODP768:         "MODP768",
+	IKEDHGroupMODP1024:        "MODP1024",
+	IKEDHGroupMODP1536:        "MODP1536",
+	IKEDHGroupMODP2048:        "MODP2048",
+	IKEDHGroupMODP3072:        "MODP3072",
+	IKEDHGroupMODP4096:        "MODP4096",
+	IKEDHGroupMODP6144:        "MODP6144",
+	IKEDHGroupMODP8192:        "MODP8192",
+	IKEDHGroupECP256:          "ECP256",
+	IKEDHGroupECP384:          "ECP384",
+	IKEDHGroupECP521:          "ECP521",
+	IKEDHGroupMODP1024S160:    "MODP1024S160",
+	IKEDHGroupMODP2048S224:    "MODP2048S224",
+	IKEDHGroupMODP2048S256:    "MODP2048S256",
+	IKEDHGroupECP192:          "ECP192",
+	IKEDHGroupECP224:          "ECP224",
+	IKEDHGroupBrainpoolP224r1: "BRAINPOOLP224R1",
+	IKEDHGroupBrainpoolP256r1: "BRAINPOOLP256R1",
+	IKEDHGroupBrainpoolP384r1: "BRAINPOOLP384R1",
+	IKEDHGroupBrainpoolP512r1: "BRAINPOOLP512R1",
+	IKEDHGroupCurve25519:      "CURVE25519",
+	IKEDHGroupCurve448:        "CURVE448",
+}
+
+func (g IKEDHGroup) String() string {
+	if name, ok := ikeDHGroupNames[g]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(g))
+}
+
+// IKEESNMode is the transform ID of an extended sequence numbers transform.
+type IKEESNMode uint16
+
+const (
+	IKEESNModeNone IKEESNMode = 0
+	IKEESNModeESN  IKEESNMode = 1
+)
+
+func (m IKEESNMode) String() string {
+	switch m {
+	case IKEESNModeNone:
+		return "NoESN"
+	case IKEESNModeESN:
+		return "ESN"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(m))
+	}
+}
+
+// IKEIDType is the type of an identification payload.
+type IKEIDType uint8
+
+const (
+	IKEIDTypeIPv4      IKEIDType = 1
+	IKEIDTypeFQDN      IKEIDType = 2
+	IKEIDTypeRFC822    IKEIDType = 3
+	IKEIDTypeIPv6      IKEIDType = 5
+	IKEIDTypeDERASN1DN IKEIDType = 9
+	IKEIDTypeDERASN1GN IKEIDType = 10
+	IKEIDTypeKeyID     IKEIDType = 11
+)
+
+func (t IKEIDType) String() string {
+	switch t {
+	case IKEIDTypeIPv4:
+		return "ID_IPV4_ADDR"
+	case IKEIDTypeFQDN:
+		return "ID_FQDN"
+	case IKEIDTypeRFC822:
+		return "ID_RFC822_ADDR"
+	case IKEIDTypeIPv6:
+		return "ID_IPV6_ADDR"
+	case IKEIDTypeDERASN1DN:
+		return "ID_DER_ASN1_DN"
+	case IKEIDTypeDERASN1GN:
+		return "ID_DER_ASN1_GN"
+	case IKEIDTypeKeyID:
+		return "ID_KEY_ID"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// IKENotifyType is the type of a notify payload.  Types below 16384 report
+// errors, the others status.
+type IKENotifyType uint16
+
+const (
+	IKENotifyTypeUnsupportedCriticalPayload IKENotifyType = 1
+	IKENotifyTypeInvalidIKESPI              IKENotifyType = 4
+	IKENotifyTypeInvalidMajorVersion        IKENotifyType = 5
+	IKENotifyTypeInvalidSyntax              IKENotifyType = 7
+	IKENotifyTypeInvalidMessageID           IKENotifyType = 9
+	IKENotifyTypeInvalidSPI                 IKENotifyType = 11
+	IKENotifyTypeNoProposalChosen           IKENotifyType = 14
+	IKENotifyTypeInvalidKEPayload           IKENotifyType = 17
+	IKENotifyTypeAuthenticationFailed       IKENotifyType = 24
+	IKENotifyTypeSinglePairRequired         IKENotifyType = 34
+	IKENotifyTypeNoAdditionalSAs            IKENotifyType = 35
+	IKENotifyTypeInternalAddressFailure     IKENotifyType = 36
+	IKENotifyTypeFailedCPRequired           IKENotifyType = 37
+	IKENotifyTypeTSUnacceptable             IKENotifyType = 38
+	IKENotifyTypeInvalidSelectors           IKENotifyType = 39
+	IKENotifyTypeTemporaryFailure           IKENotifyType = 43
+	IKENotifyTypeChildSANotFound            IKENotifyType = 44
+	IKENotifyTypeInitialContact             IKENotifyType = 16384
+	IKENotifyTypeSetWindowSize              IKENotifyType = 16385
+	IKENotifyTypeAdditionalTSPossible       IKENotifyType = 16386
+	IKENotifyTypeIPCompSupported            IKENotifyType = 16387
+	IKENotifyTypeNATDetectionSourceIP       IKENotifyType = 16388
+	IKENotifyTypeNATDetectionDestinationIP  IKENotifyType = 16389
+	IKENotifyTypeCookie                     IKENotifyType = 16390
+	IKENotifyTypeUseTransportMode           IKENotifyType = 16391
+	IKENotifyTypeHTTPCertLookupSupported    IKENotifyType = 16392
+	IKENotifyTypeRekeySA                    IKENotifyType = 16393
+	IKENotifyTypeESPTFCPaddingNotSupported  IKENotifyType = 16394
+	IKENotifyTypeNonFirstFragmentsAlso      IKENotifyType = 16395
+	IKENotifyTypeMOBIKESupported            IKENotifyType = 16396
+	IKENotifyTypeAdditionalIP4Address       IKENotifyType = 16397
+	IKENotifyTypeAdditionalIP6Address       IKENotifyType = 16398
+	IKENotifyTypeNoAdditionalAddresses      IKENotifyType = 16399
+	IKENotifyTypeUpdateSAAddresses          IKENotifyType = 16400
+	IKENotifyTypeCookie2                    IKENotifyType = 16401
+	IKENotifyTypeNoNATsAllowed              IKENotifyType = 16402
+	IKENotifyTypeAuthLifetime               IKENotifyType = 16403
+	IKENotifyTypeMultipleAuthSupported      IKENotifyType = 16404
+	IKENotifyTypeAnotherAuthFollows         IKENotifyType = 16405
+	IKENotifyTypeRedirectSupported          IKENotifyType = 16406
+	IKENotifyTypeRedirect                   IKENotifyType = 16407
+	IKENotifyTypeRedirectedFrom             IKENotifyType = 16408
+	IKENotifyTypeEAPOnlyAuthentication      IKENotifyType = 16417
+	IKENotifyTypeChildlessIKEv2Supported    IKENotifyType = 16418
+	IKENotifyTypeFragmentationSupported     IKENotifyType = 16430
+	IKENotifyTypeSignatureHashAlgorithms    IKENotifyType = 16431
+)
+
+var ikeNotifyTypeNames = map[IKENotifyType]string{
+	IKENotifyTypeUnsupportedCriticalPayload: "UNSUPPORTED_CRITICAL_PAYLOAD",
+	IKENotifyTypeInvalidIKESPI:              "INVALID_IKE_SPI",
+	IKENotifyTypeInvalidMajorVersion:        "INVALID_MAJOR_VERSION",
+	IKENotifyTypeInvalidSyntax:              "INVALID_SYNTAX",
+	IKENotifyTypeInvalidMessageID:           "INVALID_MESSAGE_ID",
+	IKENotifyTypeInvalidSPI:                 "INVALID_SPI",
+	IKENotifyTypeNoProposalChosen:           "NO_PROPOSAL_CHOSEN",
+	IKENotifyTypeInvalidKEPayload:           "INVALID_KE_PAYLOAD",
+	IKENotifyTypeAuthenticationFailed:       "AUTHENTICATION_FAILED",
+	IKENotifyTypeSinglePairRequired:         "SINGLE_PAIR_REQUIRED",
+	IKENotifyTypeNoAdditionalSAs:            "NO_ADDITIONAL_SAS",
+	IKENotifyTypeInternalAddressFailure:     "INTERNAL_ADDRESS_FAILURE",
+	IKENotifyTypeFailedCPRequired:           "FAILED_CP_REQUIRED",
+	IKENotifyTypeTSUnacceptable:             "TS_UNACCEPTABLE",
+	IKENotifyTypeInvalidSelectors:           "INVALID_SELECTORS",
+	IKENotifyTypeTemporaryFailure:           "TEMPORARY_FAILURE",
+	IKENotifyTypeChildSANotFound:            "CHILD_SA_NOT_FOUND",
+	IKENotifyTypeInitialContact:             "INITIAL_CONTACT",
+	IKENotifyTypeSetWindowSize:              "SET_WINDOW_SIZE",
+	IKENotifyTypeAdditionalTSPossible:       "ADDITIONAL_TS_POSSIBLE",
+	IKENotifyTypeIPCompSupported:            "IPCOMP_SUPPORTED",
+	IKENotifyTypeNATDetectionSourceIP:       "NAT_DETECTION_SOURCE_IP",
+	IKENotifyTypeNATDetectionDestinationIP:  "NAT_DETECTION_DESTINATION_IP",
+	IKENotifyTypeCookie:                     "COOKIE",
+	IKENotifyTypeUseTransportMode:           "USE_TRANSPORT_MODE",
+	IKENotifyTypeHTTPCertLookupSupported:    "HTTP_CERT_LOOKUP_SUPPORTED",
+	IKENotifyTypeRekeySA:                    "REKEY_SA",
+	IKENotifyTypeESPTFCPaddingNotSupported:  "ESP_TFC_PADDING_NOT_SUPPORTED",
+	IKENotifyTypeNonFirstFragmentsAlso:      "NON_FIRST_FRAGMENTS_ALSO",
+	IKENotifyTypeMOBIKESupported:            "MOBIKE_SUPPORTED",
+	IKENotifyTypeAdditionalIP4Address:       "ADDITIONAL_IP4_ADDRESS",
+	IKENotifyTypeAdditionalIP6Address:       "ADDITIONAL_IP6_ADDRESS",
+	IKENotifyTypeNoAdditionalAddresses:      "NO_ADDITIONAL_ADDRESSES",
+	IKENotifyTypeUpdateSAAddresses:          "UPDATE_SA_ADDRESSES",
+	IKENotifyTypeCookie2:                    "COOKIE2",
+	IKENotifyTypeNoNATsAllowed:              "NO_NATS_ALLOWED",
+	IKENotifyTypeAuthLifetime:               "AUTH_LIFETIME",
+	IKENotifyTypeMultipleAuthSupported:      "MULTIPLE_AUTH_SUPPORTED",
+	IKENotifyTypeAnotherAuthFollows:         "ANOTHER_AUTH_FOLLOWS",
+	IKENotifyTypeRedirectSupported:          "REDIRECT_SUPPORTED",
+	IKENotifyTypeRedirect:                   "REDIRECT",
+	IKENotifyTypeRedirectedFrom:             "REDIRECTED_FROM",
+	IKENotifyTypeEAPOnlyAuthentication:      "EAP_ONLY_AUTHENTICATION",
+	IKENotifyTypeChildlessIKEv2Supported:    "CHILDLESS_IKEV2_SUPPORTED",
+	IKENotifyTypeFragmentationSupported:     "IKEV2_FRAGMENTATION_SUPPORTED",
+	IKENotifyTypeSignatureHashAlgorithms:    "SIGNATURE_HASH_ALGORITHMS",
+}
+
+func (t IKENotifyType) String() string {
+	if name, ok := ikeNotifyTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(t))
+}
+
+// IKETransformAttribute is an attribute of a transform.  Value holds two
+// bytes for attributes in the short TV format.
+type IKETransformAttribute struct {
+	Type  uint16
+	Value []byte
+}
+
+// ikeAttributeKeyLength is the key length transform attribute type.
+const ikeAttributeKeyLength = 14
+
+// IKETransform is a transform of an SA proposal.  ID is to be interpreted
+// according to Type, see the Encryption, PRF, Integrity, DHGroup and ESN
+// methods.
+type IKETransform struct {
+	Type       IKETransformType
+	ID         uint16
+	Attributes []IKETransformAttribute
+}
+
+// Encryption returns ID as an encryption algorithm.
+func (t IKETransform) Encryption() IKEEncryptionAlgorithm { return IKEEncryptionAlgorithm(t.ID) }
+
+// PRF returns ID as a pseudorandom function.
+func (t IKETransform) PRF() IKEPRFAlgorithm { return IKEPRFAlgorithm(t.ID) }
+
+// Integrity returns ID as an integrity algorithm.
+func (t IKETransform) Integrity() IKEIntegrityAlgorithm { return IKEIntegrityAlgorithm(t.ID) }
+
+// DHGroup returns ID as a Diffie-Hellman group.
+func (t IKETransform) DHGroup() IKEDHGroup { return IKEDHGroup(t.ID) }
+
+// ESN returns ID as an extended sequence numbers mode.
+func (t IKETransform) ESN() IKEESNMode { return IKEESNMode(t.ID) }
+
+// KeyLength returns the value of the key length attribute, or 0 if there's
+// none.
+func (t IKETransform) KeyLength() uint16 {
+	for _, a := range t.Attributes {
+		if a.Type == ikeAttributeKeyLength && len(a.Value) == 2 {
+			return binary.BigEndian.Uint16(a.Value)
+		}
+	}
+	return 0
+}
+
+// String returns the transform type and the name of its ID, along with the
+// key length if there is one.
+func (t IKETransform) String() string {
+	var id fmt.Stringer
+	switch t.Type {
+	case IKETransformTypeEncryption:
+		id = t.Encryption()
+	case IKETransformTypePRF:
+		id = t.PRF()
+	case IKETransformTypeIntegrity:
+		id = t.Integrity()
+	case IKETransformTypeDH:
+		id = t.DHGroup()
+	case IKETransformTypeESN:
+		id = t.ESN()
+	default:
+		return fmt.Sprintf("%v=%d", t.Type, t.ID)
+	}
+	if kl := t.KeyLength(); kl != 0 {
+		return fmt.Sprintf("%v=%v_%d", t.Type, id, kl)
+	}
+	return fmt.Sprintf("%v=%v", t.Type, id)
+}
+
+// IKEProposal is a proposal of an SA payload.
+type IKEProposal struct {
+	Number     uint8
+	ProtocolID IKEProtocolID
+	SPI        []byte
+	Transforms []IKETransform
+}
+
+// IKEKeyExchange is the body of a KE payload.
+type IKEKeyExchange struct {
+	Group IKEDHGroup
+	Data  []byte
+}
+
+// IKEIdentification is the body of an IDi or IDr payload.
+type IKEIdentification struct {
+	Type IKEIDType
+	Data []byte
+}
+
+// IKENotify is the body of a notify payload.
+type IKENotify struct {
+	ProtocolID IKEProtocolID
+	SPI        []byte
+	Type       IKENotifyType
+	Data       []byte
+}
+
+// IKEDelete is the body of a delete payload.
+type IKEDelete struct {
+	ProtocolID IKEProtocolID
+	SPIs       [][]byte
+}
+
+// IKEPayload is a payload of an IKE message.  Body holds everything after
+// the generic payload header, and for IKEv2 messages one of the other
+// fields is set according to Type.  Nonce, vendor ID and encrypted payloads
+// are left in Body.  For an encrypted payload, NextPayload gives the type of
+// the first payload inside it.
+type IKEPayload struct {
+	Type        IKEPayloadType
+	NextPayload IKEPayloadType
+	Critical    bool
+	Length      uint16
+	Body        []byte
+
+	Proposals      []IKEProposal
+	KeyExchange    *IKEKeyExchange
+	Identification *IKEIdentification
+	Notify         *IKENotify
+	Delete         *IKEDelete
+}
+
+// IKE is an Internet Key Exchange message, as described by RFC 7296 for
+// IKEv2.  IKEv1 (RFC 2409) messages have their header decoded and their
+// payloads split up, but the payload bodies are not interpreted.
+type IKE struct {
+	BaseLayer
+	InitiatorSPI uint64
+	ResponderSPI uint64
+	NextPayload  IKEPayloadType
+	MajorVersion uint8
+	MinorVersion uint8
+	ExchangeType IKEExchangeType
+	// Response, Version and Initiator are the R, V and I flags.
+	Response  bool
+	Version   bool
+	Initiator bool
+	MessageID uint32
+	Length    uint32
+	Payloads  []IKEPayload
+}
+
+// LayerType returns LayerTypeIKE.
+func (i *IKE) LayerType() gopacket.LayerType { return LayerTypeIKE }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (i *IKE) CanDecode() gopacket.LayerClass { return LayerTypeIKE }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (i *IKE) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, as IKE messages carry no further layers.
+func (i *IKE) Payload() []byte { return nil }
+
+// FindPayload returns the first payload of the given type.
+func (i *IKE) FindPayload(t IKEPayloadType) (*IKEPayload, bool) {
+	for j := range i.Payloads {
+		if i.Payloads[j].Type == t {
+			return &i.Payloads[j], true
+		}
+	}
+	return nil, false
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (i *IKE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 28 {
+		df.SetTruncated()
+		return errors.New("IKE header too short")
+	}
+	i.InitiatorSPI = binary.BigEndian.Uint64(data[0:8])
+	i.ResponderSPI = binary.BigEndian.Uint64(data[8:16])
+	i.NextPayload = IKEPayloadType(data[16])
+	i.MajorVersion = data[17] >> 4
+	i.MinorVersion = data[17] & 0x0f
+	i.ExchangeType = IKEExchangeType(data[18])
+	i.Response = data[19]&0x20 != 0
+	i.Version = data[19]&0x10 != 0
+	i.Initiator = data[19]&0x08 != 0
+	i.MessageID = binary.BigEndian.Uint32(data[20:24])
+	i.Length = binary.BigEndian.Uint32(data[24:28])
+	i.Payloads = i.Payloads[:0]
+	if i.Length < 28 {
+		return fmt.Errorf("IKE length %d too short", i.Length)
+	}
+	if int64(i.Length) > int64(len(data)) {
+		df.SetTruncated()
+		return fmt.Errorf("IKE length %d exceeds %d bytes", i.Length, len(data))
+	}
+	i.BaseLayer = BaseLayer{Contents: data[:i.Length]}
+
+	next := i.NextPayload
+	for rest := data[28:i.Length]; next != IKEPayloadTypeNone; {
+		if len(rest) < 4 {
+			return fmt.Errorf("IKE %v payload truncated", next)
+		}
+		p := IKEPayload{
+			Type:        next,
+			NextPayload: IKEPayloadType(rest[0]),
+			Critical:    rest[1]&0x80 != 0,
+			Length:      binary.BigEndian.Uint16(rest[2:4]),
+		}
+		if p.Length < 4 || int(p.Length) > len(rest) {
+			return fmt.Errorf("IKE %v payload length %d invalid", p.Type, p.Length)
+		}
+		p.Body = rest[4:p.Length]
+		if i.MajorVersion == 2 {
+			if err := p.decodeBody(); err != nil {
+				return err
+			}
+		}
+		i.Payloads = append(i.Payloads, p)
+		rest = rest[p.Length:]
+		// Whatever follows the encrypted payload's header is inside it.
+		if p.Type == IKEPayloadTypeEncrypted || p.Type == IKEPayloadTypeEncryptedFragment {
+			break
+		}
+		next = p.NextPayload
+	}
+	return nil
+}
+
+func (p *IKEPayload) decodeBody() error {
+	b := p.Body
+	switch p.Type {
+	case IKEPayloadTypeSA:
+		for len(b) > 0 {
+			prop, n, err := decodeIKEProposal(b)
+			if err != nil {
+				return err
+			}
+			p.Proposals = append(p.Proposals, prop)
+			last := b[0] == 0
+			b = b[n:]
+			if last {
+				break
+			}
+		}
+	case IKEPayloadTypeKE:
+		if len(b) < 4 {
+			return errors.New("IKE KE payload too short")
+		}
+		p.KeyExchange = &IKEKeyExchange{Group: IKEDHGroup(binary.BigEndian.Uint16(b[0:2])), Data: b[4:]}
+	case IKEPayloadTypeIDi, IKEPayloadTypeIDr:
+		if len(b) < 4 {
+			return errors.New("IKE identification payload too short")
+		}
+		p.Identification = &IKEIdentification{Type: IKEIDType(b[0]), Data: b[4:]}
+	case IKEPayloadTypeNotify:
+		if len(b) < 4 {
+			return errors.New("IKE notify payload too short")
+		}
+		n := 4 + int(b[1])
+		if len(b) < n {
+			return errors.New("IKE notify payload too short")
+		}
+		p.Notify = &IKENotify{
+			ProtocolID: IKEProtocolID(b[0]),
+			SPI:        b[4:n],
+			Type:       IKENotifyType(binary.BigEndian.Uint16(b[2:4])),
+			Data:       b[n:],
+		}
+	case IKEPayloadTypeDelete:
+		if len(b) < 4 {
+			return errors.New("IKE delete payload too short")
+		}
+		spiSize, count := int(b[1]), int(binary.BigEndian.Uint16(b[2:4]))
+		if len(b) < 4+spiSize*count {
+			return errors.New("IKE delete payload too short")
+		}
+		p.Delete = &IKEDelete{ProtocolID: IKEProtocolID(b[0])}
+		for j := 0; j < count; j++ {
+			p.Delete.SPIs = append(p.Delete.SPIs, b[4+j*spiSize:4+(j+1)*spiSize])
+		}
+	}
+	return nil
+}
+
+// decodeIKEProposal decodes a proposal substructure, returning it along
+// with its length.
+func decodeIKEProposal(data []byte) (IKEProposal, int, error) {
+	var prop IKEProposal
+	if len(data) < 8 {
+		return prop, 0, errors.New("IKE proposal too short")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	spiSize := int(data[6])
+	if length < 8+spiSize || length > len(data) {
+		return prop, 0, fmt.Errorf("IKE proposal length %d invalid", length)
+	}
+	prop.Number = data[4]
+	prop.ProtocolID = IKEProtocolID(data[5])
+	prop.SPI = data[8 : 8+spiSize]
+	count := int(data[7])
+	b := data[8+spiSize : length]
+	for j := 0; j < count; j++ {
+		if len(b) < 8 {
+			return prop, 0, errors.New("IKE transform too short")
+		}
+		tlen := int(binary.BigEndian.Uint16(b[2:4]))
+		if tlen < 8 || tlen > len(b) {
+			return prop, 0, fmt.Errorf("IKE transform length %d invalid", tlen)
+		}
+		t := IKETransform{
+			Type: IKETransformType(b[4]),
+			ID:   binary.BigEndian.Uint16(b[6:8]),
+		}
+		for attrs := b[8:tlen]; len(attrs) > 0; {
+			if len(attrs) < 4 {
+				return prop, 0, errors.New("IKE transform attribute too short")
+			}
+			typ := binary.BigEndian.Uint16(attrs[0:2])
+			if typ&0x8000 != 0 {
+				t.Attributes = append(t.Attributes, IKETransformAttribute{Type: typ & 0x7fff, Value: attrs[2:4]})
+				attrs = attrs[4:]
+				continue
+			}
+			alen := int(binary.BigEndian.Uint16(attrs[2:4]))
+			if 4+alen > len(attrs) {
+				return prop, 0, errors.New("IKE transform attribute truncated")
+			}
+			t.Attributes = append(t.Attributes, IKETransformAttribute{Type: typ, Value: attrs[4 : 4+alen]})
+			attrs = attrs[4+alen:]
+		}
+		prop.Transforms = append(prop.Transforms, t)
+		b = b[tlen:]
+	}
+	return prop, length, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// Payloads are written from their Type, Critical and Body fields, and are
+// chained in the order given; the decoded structures are not used.  With
+// FixLengths set, the header's NextPayload and each payload's NextPayload
+// and Length are recomputed, except for the NextPayload of an encrypted
+// payload which names the first payload inside it.
+func (i *IKE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 28
+	for _, p := range i.Payloads {
+		if len(p.Body) > 0xffff-4 {
+			return fmt.Errorf("IKE %v payload too long", p.Type)
+		}
+		length += 4 + len(p.Body)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		i.Length = uint32(length)
+		i.NextPayload = IKEPayloadTypeNone
+		if len(i.Payloads) > 0 {
+			i.NextPayload = i.Payloads[0].Type
+		}
+		for j := range i.Payloads {
+			p := &i.Payloads[j]
+			p.Length = uint16(4 + len(p.Body))
+			if p.Type == IKEPayloadTypeEncrypted || p.Type == IKEPayloadTypeEncryptedFragment {
+				continue
+			}
+			p.NextPayload = IKEPayloadTypeNone
+			if j+1 < len(i.Payloads) {
+				p.NextPayload = i.Payloads[j+1].Type
+			}
+		}
+	}
+	binary.BigEndian.PutUint64(bytes[0:8], i.InitiatorSPI)
+	binary.BigEndian.PutUint64(bytes[8:16], i.ResponderSPI)
+	bytes[16] = uint8(i.NextPayload)
+	bytes[17] = i.MajorVersion<<4 | i.MinorVersion&0x0f
+	bytes[18] = uint8(i.ExchangeType)
+	bytes[19] = 0
+	if i.Response {
+		bytes[19] |= 0x20
+	}
+	if i.Version {
+		bytes[19] |= 0x10
+	}
+	if i.Initiator {
+		bytes[19] |= 0x08
+	}
+	binary.BigEndian.PutUint32(bytes[20:24], i.MessageID)
+	binary.BigEndian.PutUint32(bytes[24:28], i.Length)
+	off := 28
+	for _, p := range i.Payloads {
+		bytes[off] = uint8(p.NextPayload)
+		bytes[off+1] = 0
+		if p.Critical {
+			bytes[off+1] = 0x80
+		}
+		binary.BigEndian.PutUint16(bytes[off+2:off+4], p.Length)
+		copy(bytes[off+4:], p.Body)
+		off += 4 + len(p.Body)
+	}
+	return nil
+}
+
+func decodeIKE(data []byte, p gopacket.PacketBuilder) error {
+	i := &IKE{}
+	if err := i.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(i)
+	p.SetApplicationLayer(i)
+	return nil
+}
+
+// IPSecNATT is the UDP encapsulation of IPSec used for NAT traversal on
+// port 4500, as described in RFC 3948.  IKE messages are preceded by a four
+// byte non-ESP marker of zeros, which is the Contents of this layer; ESP
+// packets follow the UDP header directly, as their SPI is never zero.  A
+// single 0xFF byte is a NAT keepalive.
+type IPSecNATT struct {
+	BaseLayer
+	NonESPMarker bool
+	Keepalive    bool
+}
+
+// LayerType returns LayerTypeIPSecNATT.
+func (n *IPSecNATT) LayerType() gopacket.LayerType { return LayerTypeIPSecNATT }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (n *IPSecNATT) CanDecode() gopacket.LayerClass { return LayerTypeIPSecNATT }
+
+// NextLayerType returns LayerTypeIKE after a non-ESP marker,
+// gopacket.LayerTypeZero for keepalives and LayerTypeIPSecESP otherwise.
+func (n *IPSecNATT) NextLayerType() gopacket.LayerType {
+	switch {
+	case n.NonESPMarker:
+		return LayerTypeIKE
+	case n.Keepalive:
+		return gopacket.LayerTypeZero
+	}
+	return LayerTypeIPSecESP
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (n *IPSecNATT) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	n.NonESPMarker, n.Keepalive = false, false
+	switch {
+	case len(data) == 1 && data[0] == 0xff:
+		n.Keepalive = true
+		n.BaseLayer = BaseLayer{Contents: data}
+	case len(data) < 4:
+		df.SetTruncated()
+		return errors.New("IPSec NAT-T payload too short")
+	case binary.BigEndian.Uint32(data[0:4]) == 0:
+		n.NonESPMarker = true
+		n.BaseLayer = BaseLayer{data[:4], data[4:]}
+	default:
+		n.BaseLayer = BaseLayer{data[:0], data}
+	}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (n *IPSecNATT) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	switch {
+	case n.NonESPMarker:
+		bytes, err := b.PrependBytes(4)
+		if err != nil {
+			return err
+		}
+		zero(bytes)
+	case n.Keepalive:
+		bytes, err := b.PrependBytes(1)
+		if err != nil {
+			return err
+		}
+		bytes[0] = 0xff
+	}
+	return nil
+}
+
+func decodeIPSecNATT(data []byte, p gopacket.PacketBuilder) error {
+	n := &IPSecNATT{}
+	return decodingLayerDecoder(n, data, p)
+}
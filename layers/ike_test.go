@@ -0,0 +1,234 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketIKESAInit is an IKE_SA_INIT request offering
+// AES_CBC_256/PRF_HMAC_SHA2_256/AUTH_HMAC_SHA2_256_128/CURVE25519, with KE,
+// nonce, NAT_DETECTION_SOURCE_IP and vendor ID payloads.
+var testPacketIKESAInit = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0xce, 0x12, 0x34, 0x40, 0x00, 0x40, 0x11,
+	0xa4, 0x7c, 0xc0, 0xa8, 0x01, 0x0a, 0xc0, 0xa8, 0x01, 0x14, 0x01, 0xf4,
+	0x01, 0xf4, 0x00, 0xba, 0xcd, 0x2c, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab,
+	0xcd, 0xef, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x21, 0x20,
+	0x22, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xb2, 0x22, 0x00,
+	0x00, 0x30, 0x00, 0x00, 0x00, 0x2c, 0x01, 0x01, 0x00, 0x04, 0x03, 0x00,
+	0x00, 0x0c, 0x01, 0x00, 0x00, 0x0c, 0x80, 0x0e, 0x01, 0x00, 0x03, 0x00,
+	0x00, 0x08, 0x02, 0x00, 0x00, 0x05, 0x03, 0x00, 0x00, 0x08, 0x03, 0x00,
+	0x00, 0x0c, 0x00, 0x00, 0x00, 0x08, 0x04, 0x00, 0x00, 0x1f, 0x28, 0x00,
+	0x00, 0x28, 0x00, 0x1f, 0x00, 0x00, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15,
+	0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20, 0x21,
+	0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d,
+	0x2e, 0x2f, 0x29, 0x00, 0x00, 0x14, 0x40, 0x41, 0x42, 0x43, 0x44, 0x45,
+	0x46, 0x47, 0x48, 0x49, 0x4a, 0x4b, 0x4c, 0x4d, 0x4e, 0x4f, 0x2b, 0x00,
+	0x00, 0x1c, 0x00, 0x00, 0x40, 0x04, 0x60, 0x61, 0x62, 0x63, 0x64, 0x65,
+	0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f, 0x70, 0x71,
+	0x72, 0x73, 0x00, 0x00, 0x00, 0x0e, 0x73, 0x74, 0x72, 0x6f, 0x6e, 0x67,
+	0x53, 0x77, 0x61, 0x6e,
+}
+
+// testPacketIKENATT is an IKE_AUTH request sent over UDP 4500 after NAT
+// detection, made of a single encrypted payload.
+var testPacketIKENATT = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x60, 0x12, 0x35, 0x40, 0x00, 0x40, 0x11,
+	0xa4, 0xe9, 0xc0, 0xa8, 0x01, 0x0a, 0xc0, 0xa8, 0x01, 0x14, 0x11, 0x94,
+	0x11, 0x94, 0x00, 0x4c, 0xeb, 0x28, 0x00, 0x00, 0x00, 0x00, 0x01, 0x23,
+	0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54,
+	0x32, 0x10, 0x2e, 0x20, 0x23, 0x08, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+	0x00, 0x40, 0x23, 0x00, 0x00, 0x24, 0x80, 0x81, 0x82, 0x83, 0x84, 0x85,
+	0x86, 0x87, 0x88, 0x89, 0x8a, 0x8b, 0x8c, 0x8d, 0x8e, 0x8f, 0x90, 0x91,
+	0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98, 0x99, 0x9a, 0x9b, 0x9c, 0x9d,
+	0x9e, 0x9f,
+}
+
+// testPacketESPInUDP is an ESP packet of the resulting child SA, on the
+// same port.
+var testPacketESPInUDP = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x3c, 0x12, 0x36, 0x40, 0x00, 0x40, 0x11,
+	0xa5, 0x0c, 0xc0, 0xa8, 0x01, 0x0a, 0xc0, 0xa8, 0x01, 0x14, 0x11, 0x94,
+	0x11, 0x94, 0x00, 0x28, 0x9d, 0xec, 0xc0, 0xff, 0xee, 0x01, 0x00, 0x00,
+	0x00, 0x01, 0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9,
+	0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf, 0xb0, 0xb1, 0xb2, 0xb3, 0xb4, 0xb5,
+	0xb6, 0xb7,
+}
+
+// testPacketNATKeepalive is a NAT keepalive, padded to the minimum frame
+// size.
+var testPacketNATKeepalive = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x1d, 0x12, 0x37, 0x40, 0x00, 0x40, 0x11,
+	0xa5, 0x2a, 0xc0, 0xa8, 0x01, 0x0a, 0xc0, 0xa8, 0x01, 0x14, 0x11, 0x94,
+	0x11, 0x94, 0x00, 0x09, 0x5a, 0x44, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestPacketIKESAInit(t *testing.T) {
+	p := gopacket.NewPacket(testPacketIKESAInit, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeIKE}, t)
+	ike := p.ApplicationLayer().(*IKE)
+	if ike.InitiatorSPI != 0x0123456789abcdef || ike.ResponderSPI != 0 || ike.MajorVersion != 2 ||
+		ike.ExchangeType != IKEExchangeTypeSAInit || !ike.Initiator || ike.Response || ike.Length != 178 {
+		t.Errorf("IKE header mismatch: %+v", ike)
+	}
+	var types []IKEPayloadType
+	for _, pl := range ike.Payloads {
+		types = append(types, pl.Type)
+	}
+	wantTypes := []IKEPayloadType{IKEPayloadTypeSA, IKEPayloadTypeKE, IKEPayloadTypeNonce, IKEPayloadTypeNotify, IKEPayloadTypeVendorID}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Fatalf("payload types: want %v, got %v", wantTypes, types)
+	}
+
+	sa := ike.Payloads[0]
+	if len(sa.Proposals) != 1 || sa.Proposals[0].ProtocolID != IKEProtocolIDIKE || len(sa.Proposals[0].Transforms) != 4 {
+		t.Fatalf("SA mismatch: %+v", sa.Proposals)
+	}
+	var names []string
+	for _, tr := range sa.Proposals[0].Transforms {
+		names = append(names, tr.String())
+	}
+	wantNames := []string{"Encryption=AES_CBC_256", "PRF=PRF_HMAC_SHA2_256", "Integrity=AUTH_HMAC_SHA2_256_128", "DH=CURVE25519"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("transforms: want %v, got %v", wantNames, names)
+	}
+	if ke := ike.Payloads[1].KeyExchange; ke == nil || ke.Group != IKEDHGroupCurve25519 || len(ke.Data) != 32 {
+		t.Errorf("KE mismatch: %+v", ke)
+	}
+	if nonce := ike.Payloads[2].Body; len(nonce) != 16 || nonce[0] != 0x40 {
+		t.Errorf("nonce mismatch: %x", nonce)
+	}
+	n := ike.Payloads[3].Notify
+	if n == nil || n.Type != IKENotifyTypeNATDetectionSourceIP || len(n.SPI) != 0 || len(n.Data) != 20 {
+		t.Errorf("notify mismatch: %+v", n)
+	} else if n.Type.String() != "NAT_DETECTION_SOURCE_IP" {
+		t.Errorf("notify type name %q", n.Type)
+	}
+	if vid, ok := ike.FindPayload(IKEPayloadTypeVendorID); !ok || string(vid.Body) != "strongSwan" {
+		t.Errorf("vendor ID mismatch: %+v", vid)
+	}
+	testSerialization(t, p, testPacketIKESAInit)
+}
+
+func TestPacketIKENATT(t *testing.T) {
+	p := gopacket.NewPacket(testPacketIKENATT, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeIPSecNATT, LayerTypeIKE}, t)
+	natt := p.Layer(LayerTypeIPSecNATT).(*IPSecNATT)
+	if !natt.NonESPMarker || natt.Keepalive || len(natt.Contents) != 4 {
+		t.Errorf("NAT-T mismatch: %+v", natt)
+	}
+	ike := p.Layer(LayerTypeIKE).(*IKE)
+	if ike.ExchangeType != IKEExchangeTypeAuth || ike.MessageID != 1 || len(ike.Payloads) != 1 {
+		t.Fatalf("IKE mismatch: %+v", ike)
+	}
+	enc := ike.Payloads[0]
+	if enc.Type != IKEPayloadTypeEncrypted || enc.NextPayload != IKEPayloadTypeIDi || len(enc.Body) != 32 {
+		t.Errorf("encrypted payload mismatch: %+v", enc)
+	}
+	testSerialization(t, p, testPacketIKENATT)
+}
+
+func TestPacketESPInUDP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketESPInUDP, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeIPSecNATT, LayerTypeIPSecESP}, t)
+	if natt := p.Layer(LayerTypeIPSecNATT).(*IPSecNATT); natt.NonESPMarker || natt.Keepalive {
+		t.Errorf("NAT-T mismatch: %+v", natt)
+	}
+	if esp := p.Layer(LayerTypeIPSecESP).(*IPSecESP); esp.SPI != 0xc0ffee01 || esp.Seq != 1 {
+		t.Errorf("ESP mismatch: %+v", esp)
+	}
+	testSerialization(t, p, testPacketESPInUDP)
+}
+
+func TestPacketNATKeepalive(t *testing.T) {
+	p := gopacket.NewPacket(testPacketNATKeepalive, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeIPSecNATT}, t)
+	if natt := p.Layer(LayerTypeIPSecNATT).(*IPSecNATT); !natt.Keepalive {
+		t.Errorf("NAT-T mismatch: %+v", natt)
+	}
+	testSerialization(t, p, testPacketNATKeepalive)
+}
+
+func TestIKESerializeFixLengths(t *testing.T) {
+	p := gopacket.NewPacket(testPacketIKESAInit, LinkTypeEthernet, gopacket.Default)
+	ike := p.Layer(LayerTypeIKE).(*IKE)
+	ike.NextPayload, ike.Length = 0, 0
+	for i := range ike.Payloads {
+		ike.Payloads[i].NextPayload, ike.Payloads[i].Length = 0, 0
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := ike.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketIKESAInit[42:]) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", testPacketIKESAInit[42:], buf.Bytes())
+	}
+}
+
+func TestIKEMalformed(t *testing.T) {
+	i := &IKE{}
+	msg := testPacketIKESAInit[42:]
+	if err := i.DecodeFromBytes(msg[:20], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated header")
+	}
+	if err := i.DecodeFromBytes(msg[:100], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated message")
+	}
+	data := append([]byte(nil), msg...)
+	data[31] = 0xff // SA payload length
+	if err := i.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding overlong payload")
+	}
+	data = append([]byte(nil), msg...)
+	data[43] = 0x05 // fifth transform
+	if err := i.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding missing transform")
+	}
+	n := &IPSecNATT{}
+	if err := n.DecodeFromBytes([]byte{0, 0}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated NAT-T payload")
+	}
+}
+
+func TestIKENotifyLongSPI(t *testing.T) {
+	spi := bytes.Repeat([]byte{0xaa}, 252)
+	data := bytes.Repeat([]byte{0xbb}, 44)
+	body := append(append([]byte{byte(IKEProtocolIDESP), 252, 0x40, 0x04}, spi...), data...)
+	p := &IKEPayload{Type: IKEPayloadTypeNotify, Body: body}
+	if err := p.decodeBody(); err != nil {
+		t.Fatal("Failed to decode notify:", err)
+	}
+	if n := p.Notify; n == nil || !bytes.Equal(n.SPI, spi) || !bytes.Equal(n.Data, data) {
+		t.Errorf("Got notify %+v", n)
+	}
+	body[1] = 0xff
+	p = &IKEPayload{Type: IKEPayloadTypeNotify, Body: body[:258]}
+	if err := p.decodeBody(); err == nil {
+		t.Error("expected error decoding truncated SPI")
+	}
+}
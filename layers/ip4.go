@@ -64,6 +64,23 @@ func (i *IPv4) NetworkFlow() gopacket.Flow {
 	return gopacket.NewFlow(EndpointIPv4, i.SrcIP, i.DstIP)
 }
 
+// IPv4 option types, from http://www.iana.org/assignments/ip-parameters
+const (
+	IPv4OptionEndOfList         uint8 = 0
+	IPv4OptionNoOperation       uint8 = 1
+	IPv4OptionRecordRoute       uint8 = 7
+	IPv4OptionTimestamp         uint8 = 68
+	IPv4OptionLooseSourceRoute  uint8 = 131
+	IPv4OptionStrictSourceRoute uint8 = 137
+	IPv4OptionRouterAlert       uint8 = 148
+)
+
+// IPv4Option is a single option in the IPv4 header.  Options with a known
+// format can be interpreted with RouteOption, TimestampOption and
+// RouterAlertOption.
+//
+// When serializing, an OptionLength of zero is taken to mean 2 plus the
+// length of OptionData.
 type IPv4Option struct {
 	OptionType   uint8
 	OptionLength uint8
@@ -74,22 +91,201 @@ func (i IPv4Option) String() string {
 	return fmt.Sprintf("IPv4Option(%v:%v)", i.OptionType, i.OptionData)
 }
 
+// serializedLength returns the number of bytes this option takes up in the
+// header.
+func (i IPv4Option) serializedLength() int {
+	switch i.OptionType {
+	case IPv4OptionEndOfList, IPv4OptionNoOperation:
+		return 1
+	}
+	if i.OptionLength == 0 {
+		return 2 + len(i.OptionData)
+	}
+	return int(i.OptionLength)
+}
+
+// IPv4RouteOption is the content of a Record Route, Loose Source Route or
+// Strict Source Route option.  Pointer is the offset within the option,
+// counting from 1, of the next address to be filled in or visited.
+type IPv4RouteOption struct {
+	Type    uint8
+	Pointer uint8
+	Route   []net.IP
+}
+
+// RouteOption interprets a Record Route, Loose Source Route or Strict Source
+// Route option.
+func (i IPv4Option) RouteOption() (IPv4RouteOption, error) {
+	switch i.OptionType {
+	case IPv4OptionRecordRoute, IPv4OptionLooseSourceRoute, IPv4OptionStrictSourceRoute:
+	default:
+		return IPv4RouteOption{}, fmt.Errorf("IPv4 option type %d is not a route option", i.OptionType)
+	}
+	if len(i.OptionData) < 1 || (len(i.OptionData)-1)%4 != 0 {
+		return IPv4RouteOption{}, fmt.Errorf("invalid IPv4 route option data length %d", len(i.OptionData))
+	}
+	r := IPv4RouteOption{
+		Type:    i.OptionType,
+		Pointer: i.OptionData[0],
+		Route:   make([]net.IP, (len(i.OptionData)-1)/4),
+	}
+	for j := range r.Route {
+		r.Route[j] = net.IP(i.OptionData[1+j*4 : 5+j*4])
+	}
+	return r, nil
+}
+
+// IPv4Option encodes r as a generic option.  A zero Pointer is set to point
+// at the first address.
+func (r IPv4RouteOption) IPv4Option() (IPv4Option, error) {
+	data := make([]byte, 1+4*len(r.Route))
+	data[0] = r.Pointer
+	if data[0] == 0 {
+		data[0] = 4
+	}
+	for j, addr := range r.Route {
+		a, err := checkIPv4Address(addr)
+		if err != nil {
+			return IPv4Option{}, fmt.Errorf("invalid IPv4 route address (%s)", err)
+		}
+		copy(data[1+j*4:], a)
+	}
+	return IPv4Option{OptionType: r.Type, OptionLength: uint8(2 + len(data)), OptionData: data}, nil
+}
+
+// IPv4TimestampFlag is the Flag field of an IPv4 Timestamp option, which
+// says what each entry holds.
+type IPv4TimestampFlag uint8
+
+const (
+	IPv4TimestampOnly         IPv4TimestampFlag = 0
+	IPv4TimestampWithAddress  IPv4TimestampFlag = 1
+	IPv4TimestampPrespecified IPv4TimestampFlag = 3
+)
+
+// IPv4TimestampEntry is one slot of an IPv4 Timestamp option.  Address is
+// nil when the option only carries timestamps.
+type IPv4TimestampEntry struct {
+	Address   net.IP
+	Timestamp uint32
+}
+
+// IPv4TimestampOption is the content of an Internet Timestamp option, as
+// described in RFC 791.  Pointer is the offset within the option, counting
+// from 1, of the next free entry.
+type IPv4TimestampOption struct {
+	Pointer  uint8
+	Overflow uint8
+	Flag     IPv4TimestampFlag
+	Entries  []IPv4TimestampEntry
+}
+
+// TimestampOption interprets an Internet Timestamp option.
+func (i IPv4Option) TimestampOption() (IPv4TimestampOption, error) {
+	if i.OptionType != IPv4OptionTimestamp {
+		return IPv4TimestampOption{}, fmt.Errorf("IPv4 option type %d is not a timestamp option", i.OptionType)
+	}
+	if len(i.OptionData) < 2 {
+		return IPv4TimestampOption{}, fmt.Errorf("invalid IPv4 timestamp option data length %d", len(i.OptionData))
+	}
+	t := IPv4TimestampOption{
+		Pointer:  i.OptionData[0],
+		Overflow: i.OptionData[1] >> 4,
+		Flag:     IPv4TimestampFlag(i.OptionData[1] & 0xf),
+	}
+	data := i.OptionData[2:]
+	size := 8
+	switch t.Flag {
+	case IPv4TimestampOnly:
+		size = 4
+	case IPv4TimestampWithAddress, IPv4TimestampPrespecified:
+	default:
+		return IPv4TimestampOption{}, fmt.Errorf("unknown IPv4 timestamp option flag %d", t.Flag)
+	}
+	if len(data)%size != 0 {
+		return IPv4TimestampOption{}, fmt.Errorf("invalid IPv4 timestamp option data length %d", len(i.OptionData))
+	}
+	t.Entries = make([]IPv4TimestampEntry, len(data)/size)
+	for j := range t.Entries {
+		entry := data[j*size : (j+1)*size]
+		if size == 8 {
+			t.Entries[j].Address = net.IP(entry[:4])
+			entry = entry[4:]
+		}
+		t.Entries[j].Timestamp = binary.BigEndian.Uint32(entry)
+	}
+	return t, nil
+}
+
+// IPv4Option encodes t as a generic option.  A zero Pointer is set to point
+// at the first entry.
+func (t IPv4TimestampOption) IPv4Option() (IPv4Option, error) {
+	size := 8
+	if t.Flag == IPv4TimestampOnly {
+		size = 4
+	}
+	data := make([]byte, 2+size*len(t.Entries))
+	data[0] = t.Pointer
+	if data[0] == 0 {
+		data[0] = 5
+	}
+	data[1] = t.Overflow<<4 | uint8(t.Flag)&0xf
+	for j, e := range t.Entries {
+		entry := data[2+j*size:]
+		if size == 8 {
+			a, err := checkIPv4Address(e.Address)
+			if err != nil {
+				return IPv4Option{}, fmt.Errorf("invalid IPv4 timestamp address (%s)", err)
+			}
+			copy(entry, a)
+			entry = entry[4:]
+		}
+		binary.BigEndian.PutUint32(entry, e.Timestamp)
+	}
+	return IPv4Option{OptionType: IPv4OptionTimestamp, OptionLength: uint8(2 + len(data)), OptionData: data}, nil
+}
+
+// IPv4RouterAlertOption is the content of a Router Alert option, as
+// described in RFC 2113.  A value of zero asks routers to examine the packet.
+type IPv4RouterAlertOption struct {
+	Value uint16
+}
+
+// RouterAlertOption interprets a Router Alert option.
+func (i IPv4Option) RouterAlertOption() (IPv4RouterAlertOption, error) {
+	if i.OptionType != IPv4OptionRouterAlert {
+		return IPv4RouterAlertOption{}, fmt.Errorf("IPv4 option type %d is not a router alert option", i.OptionType)
+	}
+	if len(i.OptionData) != 2 {
+		return IPv4RouterAlertOption{}, fmt.Errorf("invalid IPv4 router alert option data length %d", len(i.OptionData))
+	}
+	return IPv4RouterAlertOption{Value: binary.BigEndian.Uint16(i.OptionData)}, nil
+}
+
+// IPv4Option encodes r as a generic option.
+func (r IPv4RouterAlertOption) IPv4Option() IPv4Option {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, r.Value)
+	return IPv4Option{OptionType: IPv4OptionRouterAlert, OptionLength: 4, OptionData: data}
+}
+
+// Option returns the first option of the given type, and whether one was
+// found.
+func (ip *IPv4) Option(optionType uint8) (IPv4Option, bool) {
+	for _, opt := range ip.Options {
+		if opt.OptionType == optionType {
+			return opt, true
+		}
+	}
+	return IPv4Option{}, false
+}
+
 // for the current ipv4 options, return the number of bytes (including
 // padding that the options used)
-func (ip *IPv4) getIPv4OptionSize() uint8 {
-	optionSize := uint8(0)
+func (ip *IPv4) getIPv4OptionSize() int {
+	optionSize := 0
 	for _, opt := range ip.Options {
-		switch opt.OptionType {
-		case 0:
-			// this is the end of option lists
-			optionSize++
-		case 1:
-			// this is the padding
-			optionSize++
-		default:
-			optionSize += opt.OptionLength
-
-		}
+		optionSize += opt.serializedLength()
 	}
 	// make sure the options are aligned to 32 bit boundary
 	if (optionSize % 4) != 0 {
@@ -99,15 +295,19 @@ func (ip *IPv4) getIPv4OptionSize() uint8 {
 }
 
 // SerializeTo writes the serialized form of this layer into the
-// SerializationBuffer, implementing gopacket.SerializableLayer.
+// SerializationBuffer, implementing gopacket.SerializableLayer.  The options
+// are padded out to a 4 byte boundary, and IHL is always set to match.
 func (ip *IPv4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
 	optionLength := ip.getIPv4OptionSize()
-	bytes, err := b.PrependBytes(20 + int(optionLength))
+	if optionLength > 40 {
+		return fmt.Errorf("IPv4 options take %d bytes, more than the allowed 40", optionLength)
+	}
+	bytes, err := b.PrependBytes(20 + optionLength)
 	if err != nil {
 		return err
 	}
+	ip.IHL = 5 + uint8(optionLength/4)
 	if opts.FixLengths {
-		ip.IHL = 5 + (optionLength / 4)
 		ip.Length = uint16(len(b.Bytes()))
 	}
 	bytes[0] = (ip.Version << 4) | ip.IHL
@@ -136,17 +336,23 @@ func (ip *IPv4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeO
 			bytes[curLocation] = 1
 			curLocation++
 		default:
-			bytes[curLocation] = opt.OptionType
-			bytes[curLocation+1] = opt.OptionLength
-
+			length := opt.serializedLength()
 			// sanity checking to protect us from buffer overrun
-			if len(opt.OptionData) > int(opt.OptionLength-2) {
+			if length < 2 {
+				return fmt.Errorf("invalid length %d for IPv4 option type %d", length, opt.OptionType)
+			}
+			if len(opt.OptionData) > length-2 {
 				return errors.New("option length is smaller than length of option data")
 			}
-			copy(bytes[curLocation+2:curLocation+int(opt.OptionLength)], opt.OptionData)
-			curLocation += int(opt.OptionLength)
+			bytes[curLocation] = opt.OptionType
+			bytes[curLocation+1] = uint8(length)
+			n := copy(bytes[curLocation+2:curLocation+length], opt.OptionData)
+			zero(bytes[curLocation+2+n : curLocation+length])
+			curLocation += length
 		}
 	}
+	// Pad the rest of the options area with end of option list.
+	zero(bytes[curLocation:])
 
 	if opts.ComputeChecksums {
 		ip.Checksum = checksum(bytes)
@@ -155,6 +361,12 @@ func (ip *IPv4) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeO
 	return nil
 }
 
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
 func checksum(bytes []byte) uint16 {
 	// Clear checksum bytes
 	bytes[10] = 0
@@ -222,3 +222,79 @@ func TestIPv4Options(t *testing.T) {
 		}
 	}
 }
+
+func TestIPv4TypedOptions(t *testing.T) {
+	rr, err := IPv4RouteOption{
+		Type:    IPv4OptionRecordRoute,
+		Pointer: 8,
+		Route:   []net.IP{net.IPv4(192, 0, 2, 1), net.IPv4zero},
+	}.IPv4Option()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := IPv4TimestampOption{
+		Pointer: 13,
+		Flag:    IPv4TimestampWithAddress,
+		Entries: []IPv4TimestampEntry{{Address: net.IP{192, 0, 2, 1}, Timestamp: 100}},
+	}.IPv4Option()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip := &IPv4{
+		Version:  4,
+		TTL:      1,
+		Protocol: IPProtocolIGMP,
+		SrcIP:    net.IP{192, 0, 2, 1},
+		DstIP:    net.IP{224, 0, 0, 22},
+		Options:  []IPv4Option{IPv4RouterAlertOption{}.IPv4Option(), rr, ts},
+	}
+	// Leave garbage in the buffer to check the padding is cleared.
+	buf := gopacket.NewSerializeBuffer()
+	garbage, _ := buf.PrependBytes(48)
+	for i := range garbage {
+		garbage[i] = 0xff
+	}
+	buf.Clear()
+	if err := ip.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := hex.DecodeString("4c0000000000000001020000c0000201e0000016" +
+		"94040000" + "070b08c000020100000000" + "440c0d01c000020100000064" + "00")
+	if got := buf.Bytes(); !bytes.Equal(got, want) {
+		t.Fatalf("serialization mismatch\nwant %x\ngot  %x", want, got)
+	}
+
+	data := append([]byte(nil), want...)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+	var ip4 IPv4
+	if err := ip4.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	opt, ok := ip4.Option(IPv4OptionRouterAlert)
+	if ra, err := opt.RouterAlertOption(); !ok || err != nil || ra.Value != 0 {
+		t.Errorf("router alert: got %+v, %v", ra, err)
+	}
+	opt, _ = ip4.Option(IPv4OptionRecordRoute)
+	route, err := opt.RouteOption()
+	if err != nil || route.Pointer != 8 || len(route.Route) != 2 || !route.Route[0].Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Errorf("record route: got %+v, %v", route, err)
+	}
+	opt, _ = ip4.Option(IPv4OptionTimestamp)
+	stamps, err := opt.TimestampOption()
+	wantStamps := []IPv4TimestampEntry{{Address: net.IP{192, 0, 2, 1}, Timestamp: 100}}
+	if err != nil || stamps.Pointer != 13 || stamps.Flag != IPv4TimestampWithAddress || !reflect.DeepEqual(stamps.Entries, wantStamps) {
+		t.Errorf("timestamp: got %+v, %v", stamps, err)
+	}
+	if _, err := opt.RouteOption(); err == nil {
+		t.Error("expected error interpreting a timestamp as a route")
+	}
+	if _, ok := ip4.Option(IPv4OptionStrictSourceRoute); ok {
+		t.Error("unexpected strict source route option")
+	}
+
+	// Options which do not fit in the header are rejected.
+	ip.Options = append(ip.Options, ts, ts)
+	if err := ip.SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{}); err == nil {
+		t.Error("expected error serializing more than 40 bytes of options")
+	}
+}
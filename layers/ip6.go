@@ -17,10 +17,65 @@ import (
 )
 
 const (
+	// IPv6OptionPad1 and IPv6OptionPadN are the padding options, which may
+	// appear in both hop-by-hop and destination options headers.
+	IPv6OptionPad1 = 0x00
+	IPv6OptionPadN = 0x01
+	// IPv6HopByHopOptionRouterAlert code as defined in RFC 2711
+	IPv6HopByHopOptionRouterAlert = 0x05
 	// IPv6HopByHopOptionJumbogram code as defined in RFC 2675
 	IPv6HopByHopOptionJumbogram = 0xC2
 )
 
+// IPv6OptionAction is what a node which does not recognize a hop-by-hop or
+// destination option must do with the packet.  It is encoded in the two high
+// bits of the option type, see RFC 8200 section 4.2.
+type IPv6OptionAction uint8
+
+const (
+	IPv6OptionActionSkip               IPv6OptionAction = 0
+	IPv6OptionActionDiscard            IPv6OptionAction = 1
+	IPv6OptionActionDiscardICMP        IPv6OptionAction = 2
+	IPv6OptionActionDiscardICMPUnicast IPv6OptionAction = 3
+)
+
+func (a IPv6OptionAction) String() string {
+	switch a {
+	case IPv6OptionActionSkip:
+		return "Skip"
+	case IPv6OptionActionDiscard:
+		return "Discard"
+	case IPv6OptionActionDiscardICMP:
+		return "DiscardICMP"
+	case IPv6OptionActionDiscardICMPUnicast:
+		return "DiscardICMPUnicast"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(a))
+}
+
+// IPv6RouterAlert is the value of a Router Alert hop-by-hop option, which
+// says what sort of message the packet carries.
+type IPv6RouterAlert uint16
+
+// IPv6RouterAlert values from RFC 2711 and RFC 2710.
+const (
+	IPv6RouterAlertMLD            IPv6RouterAlert = 0
+	IPv6RouterAlertRSVP           IPv6RouterAlert = 1
+	IPv6RouterAlertActiveNetworks IPv6RouterAlert = 2
+)
+
+func (a IPv6RouterAlert) String() string {
+	switch a {
+	case IPv6RouterAlertMLD:
+		return "MLD"
+	case IPv6RouterAlertRSVP:
+		return "RSVP"
+	case IPv6RouterAlertActiveNetworks:
+		return "ActiveNetworks"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(a))
+}
+
 const (
 	ipv6MaxPayloadLength = 65535
 )
@@ -242,25 +297,30 @@ func (ipv6 *IPv6) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 			return err
 		}
 		ipv6.HopByHop = &ipv6.hbh
-		pEnd, jumbo, err := getIPv6HopByHopJumboLength(ipv6.HopByHop)
+		jumboLength, jumbo, err := getIPv6HopByHopJumboLength(ipv6.HopByHop)
 		if err != nil {
 			return err
 		}
-		if jumbo && ipv6.Length == 0 {
-			pEnd := int(pEnd)
-			if pEnd > len(ipv6.Payload) {
-				df.SetTruncated()
-				pEnd = len(ipv6.Payload)
-			}
-			ipv6.Payload = ipv6.Payload[:pEnd]
-			return nil
-		} else if jumbo && ipv6.Length != 0 {
+		pEnd := int(ipv6.Length)
+		if jumbo && ipv6.Length != 0 {
 			return errors.New("IPv6 has jumbo length and IPv6 length is not 0")
 		} else if !jumbo && ipv6.Length == 0 {
 			return errors.New("IPv6 length 0, but HopByHop header does not have jumbogram option")
-		} else {
-			ipv6.Payload = ipv6.Payload[ipv6.hbh.ActualLength:]
+		} else if jumbo {
+			pEnd = int(jumboLength)
+		}
+		// The payload length, jumbo or not, covers the hop-by-hop header too.
+		if pEnd < ipv6.hbh.ActualLength {
+			return fmt.Errorf("IPv6 payload length %d less than HopByHop length %d", pEnd, ipv6.hbh.ActualLength)
+		}
+		if pEnd > len(ipv6.Payload) {
+			df.SetTruncated()
+			pEnd = len(ipv6.Payload)
 		}
+		// The next header is decoded from the HopByHop payload, so limit both.
+		ipv6.Payload = ipv6.Payload[ipv6.hbh.ActualLength:pEnd]
+		ipv6.hbh.Payload = ipv6.Payload
+		return nil
 	}
 
 	if ipv6.Length == 0 {
@@ -324,19 +384,37 @@ func (h *ipv6HeaderTLVOption) serializeTo(data []byte, fixLengths bool, dryrun b
 	return length
 }
 
-func decodeIPv6HeaderTLVOption(data []byte) (h *ipv6HeaderTLVOption) {
+func decodeIPv6HeaderTLVOption(data []byte) (h *ipv6HeaderTLVOption, err error) {
 	h = &ipv6HeaderTLVOption{}
-	if data[0] == 0 {
+	if data[0] == IPv6OptionPad1 {
 		h.ActualLength = 1
 		return
 	}
+	if len(data) < 2 {
+		return nil, fmt.Errorf("IPv6 option type %d truncated", data[0])
+	}
 	h.OptionType = data[0]
 	h.OptionLength = data[1]
 	h.ActualLength = int(h.OptionLength) + 2
+	if h.ActualLength > len(data) {
+		return nil, fmt.Errorf("IPv6 option type %d length %d exceeds header", h.OptionType, h.OptionLength)
+	}
 	h.OptionData = data[2:h.ActualLength]
 	return
 }
 
+func decodeIPv6HeaderTLVOptions(data []byte, f func(*ipv6HeaderTLVOption)) error {
+	for offset := 0; offset < len(data); {
+		opt, err := decodeIPv6HeaderTLVOption(data[offset:])
+		if err != nil {
+			return err
+		}
+		f(opt)
+		offset += opt.ActualLength
+	}
+	return nil
+}
+
 func serializeTLVOptionPadding(data []byte, padLength int) {
 	if padLength <= 0 {
 		return
@@ -388,14 +466,13 @@ func serializeIPv6HeaderTLVOptions(buf []byte, options []*ipv6HeaderTLVOption, f
 		}
 		length += l
 	}
-	if fixLengths {
-		pad := length % 8
-		if pad != 0 {
-			if !dryrun {
-				serializeTLVOptionPadding(buf[length-2:], pad)
-			}
-			length += pad
+	// Always pad out to a multiple of 8 octets, which the header needs.
+	if length%8 != 0 {
+		pad := 8 - length%8
+		if !dryrun {
+			serializeTLVOptionPadding(buf[length-2:], pad)
 		}
+		length += pad
 	}
 	return length - 2
 }
@@ -455,6 +532,16 @@ func (i *IPv6ExtensionSkipper) NextLayerType() gopacket.LayerType {
 // IPv6HopByHopOption is a TLV option present in an IPv6 hop-by-hop extension.
 type IPv6HopByHopOption ipv6HeaderTLVOption
 
+// Action returns what a node which does not recognize this option must do.
+func (o *IPv6HopByHopOption) Action() IPv6OptionAction {
+	return IPv6OptionAction(o.OptionType >> 6)
+}
+
+// MayChange returns whether the option data may change en route.
+func (o *IPv6HopByHopOption) MayChange() bool {
+	return o.OptionType&0x20 != 0
+}
+
 // IPv6HopByHop is the IPv6 hop-by-hop extension.
 type IPv6HopByHop struct {
 	ipv6ExtensionBase
@@ -504,15 +591,39 @@ func (i *IPv6HopByHop) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback)
 	if err != nil {
 		return err
 	}
-	offset := 2
-	for offset < i.ActualLength {
-		opt := decodeIPv6HeaderTLVOption(data[offset:])
+	i.Options = i.Options[:0]
+	return decodeIPv6HeaderTLVOptions(data[2:i.ActualLength], func(opt *ipv6HeaderTLVOption) {
 		i.Options = append(i.Options, (*IPv6HopByHopOption)(opt))
-		offset += opt.ActualLength
+	})
+}
+
+// Option returns the first option of the given type, or nil if there is none.
+func (i *IPv6HopByHop) Option(optionType uint8) *IPv6HopByHopOption {
+	for _, opt := range i.Options {
+		if opt.OptionType == optionType {
+			return opt
+		}
 	}
 	return nil
 }
 
+// RouterAlert returns the value of the Router Alert option, and whether a
+// well formed one was found.
+func (i *IPv6HopByHop) RouterAlert() (IPv6RouterAlert, bool) {
+	opt := i.Option(IPv6HopByHopOptionRouterAlert)
+	if opt == nil || len(opt.OptionData) != 2 {
+		return 0, false
+	}
+	return IPv6RouterAlert(binary.BigEndian.Uint16(opt.OptionData)), true
+}
+
+// JumboLength returns the length from the Jumbo Payload option, and whether
+// a well formed one was found.
+func (i *IPv6HopByHop) JumboLength() (uint32, bool) {
+	length, ok, err := getIPv6HopByHopJumboLength(i)
+	return length, ok && err == nil
+}
+
 func decodeIPv6HopByHop(data []byte, p gopacket.PacketBuilder) error {
 	i := &IPv6HopByHop{}
 	err := i.DecodeFromBytes(data, p)
@@ -535,6 +646,41 @@ func (o *IPv6HopByHopOption) SetJumboLength(len uint32) {
 	o.OptionAlignment = [2]uint8{4, 2}
 }
 
+// SetRouterAlert makes this a Router Alert option with the given value.
+func (o *IPv6HopByHopOption) SetRouterAlert(value IPv6RouterAlert) {
+	o.OptionType = IPv6HopByHopOptionRouterAlert
+	o.OptionLength = 2
+	o.ActualLength = 4
+	o.OptionData = make([]byte, 2)
+	binary.BigEndian.PutUint16(o.OptionData, uint16(value))
+	o.OptionAlignment = [2]uint8{2, 0}
+}
+
+// IPv6 routing header types.
+const (
+	IPv6RoutingTypeSourceRoute    uint8 = 0
+	IPv6RoutingTypeSegmentRouting uint8 = 4
+)
+
+// IPv6SegmentRoutingTLVType is the type of a TLV in an IPv6 segment routing
+// header.
+type IPv6SegmentRoutingTLVType uint8
+
+// IPv6SegmentRoutingTLVType values from RFC 8754.
+const (
+	IPv6SegmentRoutingTLVPad1 IPv6SegmentRoutingTLVType = 0
+	IPv6SegmentRoutingTLVPadN IPv6SegmentRoutingTLVType = 4
+	IPv6SegmentRoutingTLVHMAC IPv6SegmentRoutingTLVType = 5
+)
+
+// IPv6SegmentRoutingTLV is a TLV following the segment list of an IPv6
+// segment routing header.  Padding TLVs are not kept when decoding, and are
+// added as needed when serializing.
+type IPv6SegmentRoutingTLV struct {
+	Type  IPv6SegmentRoutingTLVType
+	Value []byte
+}
+
 // IPv6Routing is the IPv6 routing extension.
 type IPv6Routing struct {
 	ipv6ExtensionBase
@@ -546,37 +692,181 @@ type IPv6Routing struct {
 	// SourceRoutingIPs is the set of IPv6 addresses requested for source routing,
 	// set only if RoutingType == 0.
 	SourceRoutingIPs []net.IP
+	// The remaining fields are the segment routing header from RFC 8754, set
+	// only if RoutingType == IPv6RoutingTypeSegmentRouting.  Their bytes are
+	// also in Reserved.
+	LastEntry uint8
+	Flags     uint8
+	Tag       uint16
+	// Segments is the segment list in header order, so the final segment of
+	// the path comes first and Segments[SegmentsLeft] is the active one.
+	Segments []net.IP
+	TLVs     []IPv6SegmentRoutingTLV
 }
 
 // LayerType returns LayerTypeIPv6Routing.
 func (i *IPv6Routing) LayerType() gopacket.LayerType { return LayerTypeIPv6Routing }
 
-func decodeIPv6Routing(data []byte, p gopacket.PacketBuilder) error {
-	base, err := decodeIPv6ExtensionBase(data, p)
+// ActiveSegment returns the segment Segments Left points at in a segment
+// routing header, or nil if there is none.
+func (i *IPv6Routing) ActiveSegment() net.IP {
+	if i.RoutingType != IPv6RoutingTypeSegmentRouting || int(i.SegmentsLeft) >= len(i.Segments) {
+		return nil
+	}
+	return i.Segments[i.SegmentsLeft]
+}
+
+// DecodeFromBytes implementation according to gopacket.DecodingLayer
+func (i *IPv6Routing) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	base, err := decodeIPv6ExtensionBase(data, df)
 	if err != nil {
 		return err
 	}
-	i := &IPv6Routing{
+	*i = IPv6Routing{
 		ipv6ExtensionBase: base,
 		RoutingType:       data[2],
 		SegmentsLeft:      data[3],
 		Reserved:          data[4:8],
 	}
 	switch i.RoutingType {
-	case 0: // Source routing
+	case IPv6RoutingTypeSourceRoute:
 		if (i.ActualLength-8)%16 != 0 {
 			return fmt.Errorf("Invalid IPv6 source routing, length of type 0 packet %d", i.ActualLength)
 		}
 		for d := i.Contents[8:]; len(d) >= 16; d = d[16:] {
 			i.SourceRoutingIPs = append(i.SourceRoutingIPs, net.IP(d[:16]))
 		}
+	case IPv6RoutingTypeSegmentRouting:
+		return i.decodeSegmentRouting()
 	default:
 		return fmt.Errorf("Unknown IPv6 routing header type %d", i.RoutingType)
 	}
+	return nil
+}
+
+func (i *IPv6Routing) decodeSegmentRouting() error {
+	i.LastEntry = i.Contents[4]
+	i.Flags = i.Contents[5]
+	i.Tag = binary.BigEndian.Uint16(i.Contents[6:8])
+	segments := int(i.LastEntry) + 1
+	if 8+16*segments > i.ActualLength {
+		return fmt.Errorf("IPv6 segment routing header last entry %d needs %d bytes, header is %d", i.LastEntry, 8+16*segments, i.ActualLength)
+	}
+	if int(i.SegmentsLeft) > segments {
+		return fmt.Errorf("IPv6 segment routing header segments left %d exceeds last entry %d", i.SegmentsLeft, i.LastEntry)
+	}
+	i.Segments = make([]net.IP, segments)
+	for j := range i.Segments {
+		i.Segments[j] = net.IP(i.Contents[8+16*j : 24+16*j])
+	}
+	for d := i.Contents[8+16*segments:]; len(d) > 0; {
+		t := IPv6SegmentRoutingTLVType(d[0])
+		if t == IPv6SegmentRoutingTLVPad1 {
+			d = d[1:]
+			continue
+		}
+		if len(d) < 2 {
+			return fmt.Errorf("IPv6 segment routing TLV type %d truncated", t)
+		}
+		n := 2 + int(d[1])
+		if len(d) < n {
+			return fmt.Errorf("IPv6 segment routing TLV type %d truncated", t)
+		}
+		if t != IPv6SegmentRoutingTLVPadN {
+			i.TLVs = append(i.TLVs, IPv6SegmentRoutingTLV{Type: t, Value: d[2:n]})
+		}
+		d = d[n:]
+	}
+	return nil
+}
+
+func decodeIPv6Routing(data []byte, p gopacket.PacketBuilder) error {
+	i := &IPv6Routing{}
+	if err := i.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
 	p.AddLayer(i)
 	return p.NextDecoder(i.NextHeader)
 }
 
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.  With
+// FixLengths, HeaderLength and for segment routing LastEntry are set from the
+// addresses given.
+func (i *IPv6Routing) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	var addrs []net.IP
+	length := 8
+	switch i.RoutingType {
+	case IPv6RoutingTypeSourceRoute:
+		addrs = i.SourceRoutingIPs
+	case IPv6RoutingTypeSegmentRouting:
+		addrs = i.Segments
+		if len(addrs) == 0 || len(addrs) > 256 {
+			return fmt.Errorf("IPv6 segment routing header must have 1 to 256 segments, not %d", len(addrs))
+		}
+		for _, tlv := range i.TLVs {
+			if len(tlv.Value) > 255 {
+				return fmt.Errorf("IPv6 segment routing TLV type %d value too long", tlv.Type)
+			}
+			length += 2 + len(tlv.Value)
+		}
+	default:
+		return fmt.Errorf("Unable to serialize IPv6 routing header type %d", i.RoutingType)
+	}
+	length += 16 * len(addrs)
+	padding := (8 - length%8) % 8
+	length += padding
+	if length > 2048 {
+		return fmt.Errorf("IPv6 routing header length %d too long", length)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		i.HeaderLength = uint8(length/8 - 1)
+		if i.RoutingType == IPv6RoutingTypeSegmentRouting {
+			i.LastEntry = uint8(len(addrs) - 1)
+		}
+	}
+	bytes[0] = uint8(i.NextHeader)
+	bytes[1] = i.HeaderLength
+	bytes[2] = i.RoutingType
+	bytes[3] = i.SegmentsLeft
+	if i.RoutingType == IPv6RoutingTypeSegmentRouting {
+		bytes[4] = i.LastEntry
+		bytes[5] = i.Flags
+		binary.BigEndian.PutUint16(bytes[6:8], i.Tag)
+	} else {
+		zero(bytes[4:8])
+		copy(bytes[4:8], i.Reserved)
+	}
+	for j, addr := range addrs {
+		if err := checkIPv6Address(addr); err != nil {
+			return fmt.Errorf("Invalid IPv6 routing address (%s)", err)
+		}
+		copy(bytes[8+16*j:], addr)
+	}
+	d := bytes[8+16*len(addrs):]
+	for _, tlv := range i.TLVs {
+		d[0] = uint8(tlv.Type)
+		d[1] = uint8(len(tlv.Value))
+		copy(d[2:], tlv.Value)
+		d = d[2+len(tlv.Value):]
+	}
+	switch padding {
+	case 0:
+	case 1:
+		d[0] = uint8(IPv6SegmentRoutingTLVPad1)
+	default:
+		zero(d)
+		d[0] = uint8(IPv6SegmentRoutingTLVPadN)
+		d[1] = uint8(padding - 2)
+	}
+	return nil
+}
+
 // IPv6Fragment is the IPv6 fragment header, used for packet
 // fragmentation/defragmentation.
 type IPv6Fragment struct {
@@ -615,6 +905,16 @@ func decodeIPv6Fragment(data []byte, p gopacket.PacketBuilder) error {
 // IPv6DestinationOption is a TLV option present in an IPv6 destination options extension.
 type IPv6DestinationOption ipv6HeaderTLVOption
 
+// Action returns what a node which does not recognize this option must do.
+func (o *IPv6DestinationOption) Action() IPv6OptionAction {
+	return IPv6OptionAction(o.OptionType >> 6)
+}
+
+// MayChange returns whether the option data may change en route.
+func (o *IPv6DestinationOption) MayChange() bool {
+	return o.OptionType&0x20 != 0
+}
+
 // IPv6Destination is the IPv6 destination options header.
 type IPv6Destination struct {
 	ipv6ExtensionBase
@@ -631,11 +931,18 @@ func (i *IPv6Destination) DecodeFromBytes(data []byte, df gopacket.DecodeFeedbac
 	if err != nil {
 		return err
 	}
-	offset := 2
-	for offset < i.ActualLength {
-		opt := decodeIPv6HeaderTLVOption(data[offset:])
+	i.Options = i.Options[:0]
+	return decodeIPv6HeaderTLVOptions(data[2:i.ActualLength], func(opt *ipv6HeaderTLVOption) {
 		i.Options = append(i.Options, (*IPv6DestinationOption)(opt))
-		offset += opt.ActualLength
+	})
+}
+
+// Option returns the first option of the given type, or nil if there is none.
+func (i *IPv6Destination) Option(optionType uint8) *IPv6DestinationOption {
+	for _, opt := range i.Options {
+		if opt.OptionType == optionType {
+			return opt
+		}
 	}
 	return nil
 }
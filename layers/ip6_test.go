@@ -368,10 +368,9 @@ func TestIPv6JumbogramDecode(t *testing.T) {
 		DstIP: net.IP{0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00,
 			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02},
 	}
-	buf := new(bytes.Buffer)
-	buf.Write([]byte{0x3b, 0x00, 0xc2, 0x04, 0x00, 0x01, 0x00, 0x08})
-	buf.Write(payload)
-	ip6.Payload = buf.Bytes()
+	// Like the non-jumbo case, the IPv6 payload starts after the hop-by-hop
+	// header.
+	ip6.Payload = payload
 
 	hop := &ip6.hbh
 	hop.Contents = []byte{0x3b, 0x00, 0xc2, 0x04, 0x00, 0x01, 0x00, 0x08}
@@ -428,3 +427,175 @@ func TestIPv6JumbogramDecode(t *testing.T) {
 		t.Error("No Payload layer type found in packet")
 	}
 }
+
+// testPacketMLDv2ReportRouterAlert is an MLDv2 report joining ff02::1:ff00:1,
+// with the router alert hop-by-hop header the Linux kernel puts on it.
+var testPacketMLDv2ReportRouterAlert = []byte{
+	0x33, 0x33, 0x00, 0x00, 0x00, 0x16, 0x00, 0x00, 0x5e, 0x00, 0x02, 0x33, 0x86, 0xdd, 0x60, 0x00,
+	0x00, 0x00, 0x00, 0x24, 0x00, 0x01, 0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00,
+	0x5e, 0xff, 0xfe, 0x00, 0x02, 0x33, 0xff, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x16, 0x3a, 0x00, 0x05, 0x02, 0x00, 0x00, 0x01, 0x00, 0x8f, 0x00,
+	0x0f, 0xd5, 0x00, 0x00, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0xff, 0x02, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0xff, 0x00, 0x00, 0x01,
+}
+
+func TestPacketMLDv2ReportRouterAlert(t *testing.T) {
+	// Add some ethernet padding, which must not reach the ICMPv6 layer.
+	data := append(append([]byte(nil), testPacketMLDv2ReportRouterAlert...), 0, 0, 0, 0)
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv6, LayerTypeIPv6HopByHop, LayerTypeICMPv6, LayerTypeMLDv2MulticastListenerReport}, t)
+
+	hop := p.Layer(LayerTypeIPv6HopByHop).(*IPv6HopByHop)
+	if alert, ok := hop.RouterAlert(); !ok || alert != IPv6RouterAlertMLD {
+		t.Errorf("got router alert %v, %v", alert, ok)
+	}
+	if _, ok := hop.JumboLength(); ok {
+		t.Error("unexpected jumbo length")
+	}
+	if opt := hop.Option(IPv6HopByHopOptionRouterAlert); opt == nil || opt.Action() != IPv6OptionActionSkip || opt.MayChange() {
+		t.Errorf("got router alert option %+v", opt)
+	}
+	if icmp := p.Layer(LayerTypeICMPv6).(*ICMPv6); len(icmp.Contents)+len(icmp.Payload) != 28 {
+		t.Errorf("ICMPv6 layer has %d bytes, want 28", len(icmp.Contents)+len(icmp.Payload))
+	}
+
+	// Serializing just the router alert gets the PadN added back.
+	ip6 := &IPv6{
+		Version:    6,
+		NextHeader: IPProtocolIPv6HopByHop,
+		HopLimit:   1,
+		SrcIP:      net.ParseIP("fe80::200:5eff:fe00:233"),
+		DstIP:      net.ParseIP("ff02::16"),
+		HopByHop:   &IPv6HopByHop{},
+	}
+	ip6.HopByHop.NextHeader = IPProtocolICMPv6
+	alert := &IPv6HopByHopOption{}
+	alert.SetRouterAlert(IPv6RouterAlertMLD)
+	ip6.HopByHop.Options = append(ip6.HopByHop.Options, alert)
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, ip6, gopacket.Payload(testPacketMLDv2ReportRouterAlert[62:])); err != nil {
+		t.Fatal(err)
+	}
+	if want := testPacketMLDv2ReportRouterAlert[14:]; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", want, buf.Bytes())
+	}
+}
+
+func TestIPv6OptionAction(t *testing.T) {
+	dst := &IPv6Destination{}
+	// An unknown option which must be discarded with an ICMP error, and
+	// which may change en route.
+	data := []byte{0x3b, 0x00, 0xbe, 0x02, 0x00, 0x00, 0x01, 0x00}
+	if err := dst.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	opt := dst.Option(0xbe)
+	if opt == nil || opt.Action() != IPv6OptionActionDiscardICMP || !opt.MayChange() {
+		t.Errorf("got option %+v", opt)
+	}
+	// Decoding again must not keep the old options.
+	if err := dst.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil || len(dst.Options) != 2 {
+		t.Errorf("got %d options, %v", len(dst.Options), err)
+	}
+	// An option running past the end of the header is an error.
+	data[3] = 6
+	if err := dst.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding overlong option")
+	}
+}
+
+func TestIPv6SegmentRouting(t *testing.T) {
+	segments := []net.IP{
+		net.ParseIP("2001:db8:0:3::"),
+		net.ParseIP("2001:db8:0:2::"),
+		net.ParseIP("2001:db8:0:1::"),
+	}
+	ip6 := &IPv6{
+		Version:    6,
+		NextHeader: IPProtocolIPv6Routing,
+		HopLimit:   64,
+		SrcIP:      net.ParseIP("2001:db8::1"),
+		DstIP:      segments[2],
+	}
+	srh := &IPv6Routing{
+		RoutingType:  IPv6RoutingTypeSegmentRouting,
+		SegmentsLeft: 2,
+		Tag:          0x1234,
+		Segments:     segments,
+		TLVs:         []IPv6SegmentRoutingTLV{{Type: 0x80, Value: []byte{1, 2, 3, 4}}},
+	}
+	srh.NextHeader = IPProtocolUDP
+	udp := &UDP{SrcPort: 5000, DstPort: 5001}
+	udp.SetNetworkLayerForChecksum(ip6)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip6, srh, udp, gopacket.Payload("srv6")); err != nil {
+		t.Fatal(err)
+	}
+	wantSRH := []byte{
+		0x11, 0x07, 0x04, 0x02, 0x02, 0x00, 0x12, 0x34,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x80, 0x04, 0x01, 0x02, 0x03, 0x04, 0x04, 0x00,
+	}
+	if got := buf.Bytes()[40:104]; !bytes.Equal(got, wantSRH) {
+		t.Errorf("SRH serialization mismatch\nwant %x\ngot  %x", wantSRH, got)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv6, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv6, LayerTypeIPv6Routing, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	got := p.Layer(LayerTypeIPv6Routing).(*IPv6Routing)
+	if got.LastEntry != 2 || got.SegmentsLeft != 2 || got.Tag != 0x1234 || !reflect.DeepEqual(got.Segments, segments) {
+		t.Errorf("got SRH %+v", got)
+	}
+	if !reflect.DeepEqual(got.TLVs, srh.TLVs) {
+		t.Errorf("got TLVs %+v, want %+v", got.TLVs, srh.TLVs)
+	}
+	if !got.ActiveSegment().Equal(ip6.DstIP) {
+		t.Errorf("got active segment %v, want %v", got.ActiveSegment(), ip6.DstIP)
+	}
+
+	// A last entry pointing past the end of the header is an error.
+	bad := append([]byte(nil), wantSRH...)
+	bad[4] = 3
+	if err := got.DecodeFromBytes(bad, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding SRH with last entry past the header")
+	}
+	bad[4] = 2
+	bad[3] = 4
+	if err := got.DecodeFromBytes(bad, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding SRH with segments left past last entry")
+	}
+}
+
+func TestIPv6SegmentRoutingLongTLV(t *testing.T) {
+	srh := &IPv6Routing{
+		RoutingType: IPv6RoutingTypeSegmentRouting,
+		Segments:    []net.IP{net.ParseIP("2001:db8:0:1::")},
+		TLVs:        []IPv6SegmentRoutingTLV{{Type: 0x80, Value: bytes.Repeat([]byte{0xaa}, 254)}},
+	}
+	srh.NextHeader = IPProtocolNoNextHeader
+	buf := gopacket.NewSerializeBuffer()
+	if err := srh.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	got := &IPv6Routing{}
+	if err := got.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode SRH:", err)
+	}
+	if !reflect.DeepEqual(got.TLVs, srh.TLVs) {
+		t.Errorf("got TLVs %+v, want %+v", got.TLVs, srh.TLVs)
+	}
+	data[25] = 0xff // TLV length past the header
+	if err := got.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding overlong TLV")
+	}
+}
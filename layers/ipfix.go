@@ -0,0 +1,280 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+const (
+	// IPFIXSetIDTemplate is the ID of template sets.
+	IPFIXSetIDTemplate = 2
+	// IPFIXSetIDOptionsTemplate is the ID of options template sets.
+	IPFIXSetIDOptionsTemplate = 3
+)
+
+// IPFIXVariableLength is the field length of variable-length information
+// elements, whose actual length precedes each value in data records.
+const IPFIXVariableLength = 0xffff
+
+// IPFIXSet is a set of an IPFIX message.  Body holds everything after the
+// set header, including padding.  Template and options template sets have
+// their templates decoded into Templates; a template with no fields
+// withdraws the template of that ID.  Data sets, whose ID is that of their
+// template, have their records decoded into Records if the template is
+// known; Template is then set too.
+type IPFIXSet struct {
+	ID        uint16
+	Length    uint16
+	Body      []byte
+	Templates []NetFlowV9Template
+	Template  *NetFlowV9Template
+	Records   []NetFlowV9Record
+}
+
+// IPFIX is an IPFIX message, as described by RFC 7011.  IPFIX is NetFlow
+// version 10, and shares its template and record types and its template
+// cache with NetFlow version 9.  Information element IDs up to 127 are
+// those of NetFlow version 9 field types.
+type IPFIX struct {
+	BaseLayer
+	Version uint16
+	// Length is the length of the message, header included.
+	Length              uint16
+	ExportTime          uint32
+	SequenceNumber      uint32
+	ObservationDomainID uint32
+	Sets                []IPFIXSet
+	// Templates is the cache templates are stored in and looked up from
+	// while decoding, keyed by ObservationDomainID.  If nil,
+	// NetFlowV9Templates is used.
+	Templates NetFlowV9TemplateCache
+}
+
+// LayerType returns LayerTypeIPFIX.
+func (i *IPFIX) LayerType() gopacket.LayerType { return LayerTypeIPFIX }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (i *IPFIX) CanDecode() gopacket.LayerClass { return LayerTypeIPFIX }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (i *IPFIX) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, as IPFIX messages carry no further layers.
+func (i *IPFIX) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.  Templates found
+// are stored in the template cache before the following sets are decoded,
+// so data sets may use templates from the same message.
+func (i *IPFIX) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 16 {
+		df.SetTruncated()
+		return errors.New("IPFIX header too short")
+	}
+	i.Version = binary.BigEndian.Uint16(data[0:2])
+	if i.Version != 10 {
+		return fmt.Errorf("invalid IPFIX version %d", i.Version)
+	}
+	i.Length = binary.BigEndian.Uint16(data[2:4])
+	i.ExportTime = binary.BigEndian.Uint32(data[4:8])
+	i.SequenceNumber = binary.BigEndian.Uint32(data[8:12])
+	i.ObservationDomainID = binary.BigEndian.Uint32(data[12:16])
+	if i.Length < 16 {
+		return fmt.Errorf("invalid IPFIX length %d", i.Length)
+	}
+	if int(i.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("IPFIX length %d exceeds %d bytes", i.Length, len(data))
+	}
+	cache := i.Templates
+	if cache == nil {
+		cache = NetFlowV9Templates
+	}
+
+	i.Sets = i.Sets[:0]
+	for rest := data[16:i.Length]; len(rest) > 0; {
+		if len(rest) < 4 {
+			return errors.New("IPFIX set header too short")
+		}
+		s := IPFIXSet{
+			ID:     binary.BigEndian.Uint16(rest[0:2]),
+			Length: binary.BigEndian.Uint16(rest[2:4]),
+		}
+		if s.Length < 4 || int(s.Length) > len(rest) {
+			return fmt.Errorf("invalid IPFIX set length %d", s.Length)
+		}
+		s.Body = rest[4:s.Length]
+		switch {
+		case s.ID == IPFIXSetIDTemplate, s.ID == IPFIXSetIDOptionsTemplate:
+			if err := s.decodeTemplates(); err != nil {
+				return err
+			}
+			for j := range s.Templates {
+				cache.SetTemplate(i.ObservationDomainID, &s.Templates[j])
+			}
+		case s.ID >= 256:
+			if s.Template = cache.Template(i.ObservationDomainID, s.ID); s.Template != nil {
+				if err := s.decodeRecords(); err != nil {
+					return err
+				}
+			}
+		}
+		i.Sets = append(i.Sets, s)
+		rest = rest[s.Length:]
+	}
+	i.BaseLayer = BaseLayer{Contents: data[:i.Length]}
+	return nil
+}
+
+// decodeTemplates decodes the template records of a template or options
+// template set.
+func (s *IPFIXSet) decodeTemplates() error {
+	headerLength := 4
+	if s.ID == IPFIXSetIDOptionsTemplate {
+		headerLength = 6
+	}
+	// Padding is made of zeros and shorter than any record, and template
+	// IDs are at least 256, so it can't be mistaken for a template.
+	for b := s.Body; len(b) >= 4 && binary.BigEndian.Uint16(b[0:2]) != 0; {
+		t := NetFlowV9Template{ID: binary.BigEndian.Uint16(b[0:2])}
+		count := int(binary.BigEndian.Uint16(b[2:4]))
+		off := 4
+		if count == 0 {
+			// Withdrawals have no scope field count, even in options
+			// template sets.
+			s.Templates = append(s.Templates, t)
+			b = b[off:]
+			continue
+		}
+		if len(b) < headerLength {
+			return fmt.Errorf("IPFIX template %d truncated", t.ID)
+		}
+		if s.ID == IPFIXSetIDOptionsTemplate {
+			t.ScopeFieldCount = binary.BigEndian.Uint16(b[4:6])
+			if t.ScopeFieldCount == 0 || int(t.ScopeFieldCount) > count {
+				return fmt.Errorf("invalid IPFIX options template %d scope field count %d", t.ID, t.ScopeFieldCount)
+			}
+			off = 6
+		}
+		for j := 0; j < count; j++ {
+			if off+4 > len(b) {
+				return fmt.Errorf("IPFIX template %d truncated", t.ID)
+			}
+			f := NetFlowV9Field{
+				Type:   NetFlowV9FieldType(binary.BigEndian.Uint16(b[off:off+2]) & 0x7fff),
+				Length: binary.BigEndian.Uint16(b[off+2 : off+4]),
+			}
+			enterprise := b[off]&0x80 != 0
+			off += 4
+			if enterprise {
+				if off+4 > len(b) {
+					return fmt.Errorf("IPFIX template %d truncated", t.ID)
+				}
+				f.EnterpriseID = binary.BigEndian.Uint32(b[off : off+4])
+				off += 4
+			}
+			t.Fields = append(t.Fields, f)
+		}
+		s.Templates = append(s.Templates, t)
+		b = b[off:]
+	}
+	return nil
+}
+
+// decodeRecords decodes the records of a data set against its template.
+// Anything shorter than the shortest possible record at the end is padding.
+func (s *IPFIXSet) decodeRecords() error {
+	min := 0
+	for _, f := range s.Template.Fields {
+		if f.Length == IPFIXVariableLength {
+			min++
+		} else {
+			min += int(f.Length)
+		}
+	}
+	if min == 0 {
+		return nil
+	}
+	for b := s.Body; len(b) >= min; {
+		var r NetFlowV9Record
+		off := 0
+		for _, f := range s.Template.Fields {
+			length := int(f.Length)
+			if f.Length == IPFIXVariableLength {
+				// One byte of length, or 255 followed by two bytes.
+				if off+1 > len(b) {
+					return fmt.Errorf("IPFIX record of template %d truncated", s.ID)
+				}
+				length = int(b[off])
+				off++
+				if length == 255 {
+					if off+2 > len(b) {
+						return fmt.Errorf("IPFIX record of template %d truncated", s.ID)
+					}
+					length = int(binary.BigEndian.Uint16(b[off : off+2]))
+					off += 2
+				}
+			}
+			if off+length > len(b) {
+				return fmt.Errorf("IPFIX record of template %d truncated", s.ID)
+			}
+			r.Values = append(r.Values, NetFlowV9Value{Type: f.Type, EnterpriseID: f.EnterpriseID, Value: b[off : off+length]})
+			off += length
+		}
+		s.Records = append(s.Records, r)
+		b = b[off:]
+	}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// Sets are written from their ID and Body fields; the decoded templates and
+// records are not used.  With FixLengths set, the message's and each set's
+// Length are recomputed.
+func (i *IPFIX) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 16
+	for _, s := range i.Sets {
+		if len(s.Body) > 0xffff-4 {
+			return fmt.Errorf("IPFIX set %d too long", s.ID)
+		}
+		length += 4 + len(s.Body)
+	}
+	if length > 0xffff {
+		return fmt.Errorf("IPFIX message of %d bytes too long", length)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		i.Length = uint16(length)
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], i.Version)
+	binary.BigEndian.PutUint16(bytes[2:4], i.Length)
+	binary.BigEndian.PutUint32(bytes[4:8], i.ExportTime)
+	binary.BigEndian.PutUint32(bytes[8:12], i.SequenceNumber)
+	binary.BigEndian.PutUint32(bytes[12:16], i.ObservationDomainID)
+	off := 16
+	for j := range i.Sets {
+		s := &i.Sets[j]
+		if opts.FixLengths {
+			s.Length = uint16(4 + len(s.Body))
+		}
+		binary.BigEndian.PutUint16(bytes[off:off+2], s.ID)
+		binary.BigEndian.PutUint16(bytes[off+2:off+4], s.Length)
+		copy(bytes[off+4:], s.Body)
+		off += 4 + len(s.Body)
+	}
+	return nil
+}
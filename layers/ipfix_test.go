@@ -0,0 +1,202 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketIPFIXTemplate is an IPFIX message with a template set defining
+// template 256, which has an enterprise-specific and a variable-length
+// field, and an options template set defining template 257, padded to a
+// multiple of 4 bytes.
+var testPacketIPFIXTemplate = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x68, 0x10, 0x00, 0x40, 0x00, 0x40, 0x11,
+	0xa6, 0xcf, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8, 0x01, 0x64, 0xc3, 0x50,
+	0x12, 0x83, 0x00, 0x54, 0xd3, 0x9b, 0x00, 0x0a, 0x00, 0x4c, 0x5b, 0x8d,
+	0x80, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x01, 0x00, 0x02,
+	0x00, 0x24, 0x01, 0x00, 0x00, 0x06, 0x00, 0x08, 0x00, 0x04, 0x00, 0x0c,
+	0x00, 0x04, 0x00, 0x04, 0x00, 0x01, 0x00, 0x01, 0x00, 0x08, 0x80, 0x01,
+	0x00, 0x04, 0x00, 0x00, 0x72, 0x79, 0x00, 0x60, 0xff, 0xff, 0x00, 0x03,
+	0x00, 0x18, 0x01, 0x01, 0x00, 0x03, 0x00, 0x01, 0x00, 0x8f, 0x00, 0x04,
+	0x00, 0x22, 0x00, 0x04, 0x00, 0x23, 0x00, 0x01, 0x00, 0x00,
+}
+
+// testPacketIPFIXData is the following message from the same exporter,
+// with two records of template 256, the second using the 3 byte form of
+// variable-length encoding, and one of template 257.
+var testPacketIPFIXData = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x78, 0x10, 0x00, 0x40, 0x00, 0x40, 0x11,
+	0xa6, 0xbf, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8, 0x01, 0x64, 0xc3, 0x50,
+	0x12, 0x83, 0x00, 0x64, 0x07, 0xd3, 0x00, 0x0a, 0x00, 0x5c, 0x5b, 0x8d,
+	0x80, 0x05, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x01, 0x01, 0x00,
+	0x00, 0x3c, 0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02, 0x06, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0xdc, 0xde, 0xad, 0xbe, 0xef, 0x04,
+	0x68, 0x74, 0x74, 0x70, 0x0a, 0x00, 0x00, 0x03, 0x08, 0x08, 0x08, 0x08,
+	0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x48, 0x00, 0x00, 0x00,
+	0x01, 0xff, 0x00, 0x03, 0x64, 0x6e, 0x73, 0x00, 0x00, 0x00, 0x01, 0x01,
+	0x00, 0x10, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x64, 0x02, 0x00,
+	0x00, 0x00,
+}
+
+func TestPacketIPFIX(t *testing.T) {
+	defer func(c NetFlowV9TemplateCache) { NetFlowV9Templates = c }(NetFlowV9Templates)
+	NetFlowV9Templates = NewNetFlowV9TemplateCache()
+
+	p := gopacket.NewPacket(testPacketIPFIXTemplate, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeIPFIX}, t)
+	i := p.Layer(LayerTypeIPFIX).(*IPFIX)
+	if i.Version != 10 || i.Length != 76 || i.ExportTime != 0x5b8d8000 || i.SequenceNumber != 10 || i.ObservationDomainID != 1 {
+		t.Errorf("bad header %+v", i)
+	}
+	if len(i.Sets) != 2 {
+		t.Fatalf("got %d sets, want 2", len(i.Sets))
+	}
+	want := []NetFlowV9Template{{
+		ID: 256,
+		Fields: []NetFlowV9Field{
+			{Type: NetFlowV9FieldTypeIPv4SrcAddr, Length: 4},
+			{Type: NetFlowV9FieldTypeIPv4DstAddr, Length: 4},
+			{Type: NetFlowV9FieldTypeProtocol, Length: 1},
+			{Type: NetFlowV9FieldTypeInBytes, Length: 8},
+			{Type: 1, Length: 4, EnterpriseID: 29305},
+			{Type: NetFlowV9FieldTypeApplicationName, Length: IPFIXVariableLength},
+		},
+	}}
+	if !reflect.DeepEqual(i.Sets[0].Templates, want) {
+		t.Errorf("template mismatch:\ngot  %+v\nwant %+v", i.Sets[0].Templates, want)
+	}
+	wantOptions := []NetFlowV9Template{{
+		ID:              257,
+		ScopeFieldCount: 1,
+		Fields: []NetFlowV9Field{
+			{Type: 143, Length: 4},
+			{Type: NetFlowV9FieldTypeSamplingInterval, Length: 4},
+			{Type: NetFlowV9FieldTypeSamplingAlgorithm, Length: 1},
+		},
+	}}
+	if !reflect.DeepEqual(i.Sets[1].Templates, wantOptions) {
+		t.Errorf("options template mismatch:\ngot  %+v\nwant %+v", i.Sets[1].Templates, wantOptions)
+	}
+	testSerialization(t, p, testPacketIPFIXTemplate)
+
+	p = gopacket.NewPacket(testPacketIPFIXData, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	i = p.Layer(LayerTypeIPFIX).(*IPFIX)
+	if len(i.Sets) != 2 {
+		t.Fatalf("got %d sets, want 2", len(i.Sets))
+	}
+	data := i.Sets[0]
+	if data.ID != 256 || data.Template == nil || len(data.Records) != 2 {
+		t.Fatalf("bad data set %+v", data)
+	}
+	for j, w := range []struct {
+		src        net.IP
+		bytes      uint64
+		enterprise uint64
+		app        string
+	}{
+		{net.IP{10, 0, 0, 1}, 1500, 0xdeadbeef, "http"},
+		{net.IP{10, 0, 0, 3}, 72, 1, "dns"},
+	} {
+		r := data.Records[j]
+		if v, _ := r.Value(NetFlowV9FieldTypeIPv4SrcAddr); !net.IP(v.Value).Equal(w.src) {
+			t.Errorf("record %d: source %v, want %v", j, net.IP(v.Value), w.src)
+		}
+		if v, _ := r.Value(NetFlowV9FieldTypeInBytes); v.Uint() != w.bytes {
+			t.Errorf("record %d: bytes %d, want %d", j, v.Uint(), w.bytes)
+		}
+		if v := r.Values[4]; v.EnterpriseID != 29305 || v.Uint() != w.enterprise {
+			t.Errorf("record %d: bad enterprise value %+v", j, v)
+		}
+		if v, _ := r.Value(NetFlowV9FieldTypeApplicationName); string(v.Value) != w.app {
+			t.Errorf("record %d: application %q, want %q", j, v.Value, w.app)
+		}
+	}
+	options := i.Sets[1]
+	if len(options.Records) != 1 {
+		t.Fatalf("got %d options records, want 1", len(options.Records))
+	}
+	if v, _ := options.Records[0].Value(NetFlowV9FieldTypeSamplingInterval); v.Uint() != 100 {
+		t.Errorf("sampling interval %d, want 100", v.Uint())
+	}
+	testSerialization(t, p, testPacketIPFIXData)
+}
+
+func TestIPFIXSharedCache(t *testing.T) {
+	cache := NewNetFlowV9TemplateCache()
+	i := &IPFIX{Templates: cache}
+	if err := i.DecodeFromBytes(testPacketIPFIXData[42:], gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if i.Sets[0].Template != nil || i.Sets[0].Records != nil {
+		t.Errorf("data decoded without templates: %+v", i.Sets[0])
+	}
+	if err := i.DecodeFromBytes(testPacketIPFIXTemplate[42:], gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Template(1, 256) == nil || cache.Template(1, 257) == nil {
+		t.Error("templates not cached")
+	}
+
+	// Withdrawing template 256 leaves its data undecoded.
+	withdrawal := []byte{
+		0x00, 0x0a, 0x00, 0x18, 0x5b, 0x8d, 0x80, 0x0a, 0x00, 0x00, 0x00, 0x0c,
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00, 0x08, 0x01, 0x00, 0x00, 0x00,
+	}
+	if err := i.DecodeFromBytes(withdrawal, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.DecodeFromBytes(testPacketIPFIXData[42:], gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if len(i.Sets[0].Records) != 0 || len(i.Sets[1].Records) != 1 {
+		t.Errorf("bad records after withdrawal: %+v", i.Sets)
+	}
+}
+
+func TestIPFIXMalformed(t *testing.T) {
+	msg := func(set ...byte) []byte {
+		return append([]byte{0x00, 0x0a, 0x00, byte(16 + len(set)), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, set...)
+	}
+	cache := NewNetFlowV9TemplateCache()
+	cache.SetTemplate(1, &NetFlowV9Template{ID: 256, Fields: []NetFlowV9Field{
+		{Type: NetFlowV9FieldTypeProtocol, Length: 1},
+		{Type: NetFlowV9FieldTypeApplicationName, Length: IPFIXVariableLength},
+	}})
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"short header", testPacketIPFIXData[42:57]},
+		{"wrong version", testPacketNetFlowV9Template[42:]},
+		{"length too long", testPacketIPFIXData[42 : len(testPacketIPFIXData)-1]},
+		{"short set header", msg(0, 2)},
+		{"set too long", msg(0, 2, 0, 8, 1, 0)},
+		{"template truncated", msg(0, 2, 0, 8, 1, 0, 0, 2)},
+		{"enterprise number truncated", msg(0, 2, 0, 12, 1, 0, 0, 1, 0x80, 1, 0, 4)},
+		{"no scope fields", msg(0, 3, 0, 16, 1, 1, 0, 1, 0, 0, 0, 4, 0, 4, 0, 0)},
+		{"variable length truncated", msg(1, 0, 0, 8, 6, 5, 'a', 'b')},
+		{"long variable length truncated", msg(1, 0, 0, 8, 6, 255, 0, 5)},
+	} {
+		i := &IPFIX{Templates: cache}
+		if err := i.DecodeFromBytes(c.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
@@ -8,6 +8,9 @@ package layers
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
@@ -26,24 +29,85 @@ type IPSecAH struct {
 // LayerType returns LayerTypeIPSecAH.
 func (i *IPSecAH) LayerType() gopacket.LayerType { return LayerTypeIPSecAH }
 
-func decodeIPSecAH(data []byte, p gopacket.PacketBuilder) error {
-	i := &IPSecAH{
-		ipv6ExtensionBase: ipv6ExtensionBase{
-			NextHeader:   IPProtocol(data[0]),
-			HeaderLength: data[1],
-		},
-		Reserved: binary.BigEndian.Uint16(data[2:4]),
-		SPI:      binary.BigEndian.Uint32(data[4:8]),
-		Seq:      binary.BigEndian.Uint32(data[8:12]),
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (i *IPSecAH) CanDecode() gopacket.LayerClass { return LayerTypeIPSecAH }
+
+// NextLayerType returns the layer type given by NextHeader.
+func (i *IPSecAH) NextLayerType() gopacket.LayerType { return i.NextHeader.LayerType() }
+
+// ExtendedSeq returns the 64 bit extended sequence number (RFC 4302 section
+// 2.5.1) made of the given high order bits, which are not transmitted, and
+// Seq.
+func (i *IPSecAH) ExtendedSeq(high uint32) uint64 {
+	return uint64(high)<<32 | uint64(i.Seq)
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (i *IPSecAH) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 12 {
+		df.SetTruncated()
+		return errors.New("IPSec AH header too short")
 	}
+	i.NextHeader = IPProtocol(data[0])
+	i.HeaderLength = data[1]
+	i.Reserved = binary.BigEndian.Uint16(data[2:4])
+	i.SPI = binary.BigEndian.Uint32(data[4:8])
+	i.Seq = binary.BigEndian.Uint32(data[8:12])
 	i.ActualLength = (int(i.HeaderLength) + 2) * 4
+	if i.ActualLength < 12 {
+		return fmt.Errorf("IPSec AH header length %d too short", i.HeaderLength)
+	}
+	if i.ActualLength > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("IPSec AH header length %d too long", i.HeaderLength)
+	}
 	i.AuthenticationData = data[12:i.ActualLength]
 	i.Contents = data[:i.ActualLength]
 	i.Payload = data[i.ActualLength:]
-	p.AddLayer(i)
-	return p.NextDecoder(i.NextHeader)
+	return nil
 }
 
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (i *IPSecAH) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 12 + len(i.AuthenticationData)
+	if length%4 != 0 || length/4-2 > 0xff {
+		return fmt.Errorf("invalid IPSec AH authentication data length %d", len(i.AuthenticationData))
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		i.HeaderLength = uint8(length/4 - 2)
+		i.ActualLength = length
+	}
+	bytes[0] = uint8(i.NextHeader)
+	bytes[1] = i.HeaderLength
+	binary.BigEndian.PutUint16(bytes[2:4], i.Reserved)
+	binary.BigEndian.PutUint32(bytes[4:8], i.SPI)
+	binary.BigEndian.PutUint32(bytes[8:12], i.Seq)
+	copy(bytes[12:], i.AuthenticationData)
+	return nil
+}
+
+func decodeIPSecAH(data []byte, p gopacket.PacketBuilder) error {
+	i := &IPSecAH{}
+	return decodingLayerDecoder(i, data, p)
+}
+
+// IPSecESPDetectNull makes ESP decoding look for a valid ESP trailer at the
+// end of the packet, as left by null encryption (RFC 2410), and decode the
+// protected packet if it finds one.  It's off by default, since encrypted
+// packets may occasionally look valid too.
+var IPSecESPDetectNull = false
+
+// IPSecESPNullICVLengths lists the ICV lengths tried, in order, when looking
+// for the trailer of a null encrypted ESP packet.  12 is HMAC-SHA1-96, 16 is
+// HMAC-SHA-256-128 or AES-XCBC-MAC-96 and so on.
+var IPSecESPNullICVLengths = []int{12, 16, 0, 24, 32}
+
 // IPSecESP is the encapsulating security payload defined in
 // http://tools.ietf.org/html/rfc2406
 type IPSecESP struct {
@@ -51,18 +115,146 @@ type IPSecESP struct {
 	SPI, Seq uint32
 	// Encrypted contains the encrypted set of bytes sent in an ESP
 	Encrypted []byte
+	// NullEncrypted is set if the packet was found to be null encrypted, see
+	// IPSecESPDetectNull.  The protected packet is then in Payload, and the
+	// trailer fields below are set.  When serializing with NullEncrypted
+	// set, Encrypted is ignored and the trailer is appended to the payload.
+	NullEncrypted bool
+	PadLength     uint8
+	NextHeader    IPProtocol
+	ICV           []byte
 }
 
 // LayerType returns LayerTypeIPSecESP.
 func (i *IPSecESP) LayerType() gopacket.LayerType { return LayerTypeIPSecESP }
 
-func decodeIPSecESP(data []byte, p gopacket.PacketBuilder) error {
-	i := &IPSecESP{
-		BaseLayer: BaseLayer{data, nil},
-		SPI:       binary.BigEndian.Uint32(data[:4]),
-		Seq:       binary.BigEndian.Uint32(data[4:8]),
-		Encrypted: data[8:],
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (i *IPSecESP) CanDecode() gopacket.LayerClass { return LayerTypeIPSecESP }
+
+// NextLayerType returns the layer type given by NextHeader for null
+// encrypted packets, and gopacket.LayerTypeZero otherwise.
+func (i *IPSecESP) NextLayerType() gopacket.LayerType {
+	if i.NullEncrypted {
+		return i.NextHeader.LayerType()
+	}
+	return gopacket.LayerTypeZero
+}
+
+// ExtendedSeq returns the 64 bit extended sequence number (RFC 4303 section
+// 2.2.1) made of the given high order bits, which are not transmitted, and
+// Seq.
+func (i *IPSecESP) ExtendedSeq(high uint32) uint64 {
+	return uint64(high)<<32 | uint64(i.Seq)
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (i *IPSecESP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		df.SetTruncated()
+		return errors.New("IPSec ESP header too short")
+	}
+	i.SPI = binary.BigEndian.Uint32(data[:4])
+	i.Seq = binary.BigEndian.Uint32(data[4:8])
+	i.Encrypted = data[8:]
+	i.NullEncrypted, i.PadLength, i.NextHeader, i.ICV = false, 0, 0, nil
+	i.BaseLayer = BaseLayer{data, nil}
+	if IPSecESPDetectNull {
+		for _, icv := range IPSecESPNullICVLengths {
+			if i.decodeNullTrailer(data, icv) {
+				break
+			}
+		}
 	}
-	p.AddLayer(i)
 	return nil
 }
+
+// decodeNullTrailer checks whether data ends in an ESP trailer followed by
+// icvLength bytes of ICV, and whether the payload it delimits looks like the
+// protocol given in the trailer.
+func (i *IPSecESP) decodeNullTrailer(data []byte, icvLength int) bool {
+	end := len(data) - icvLength - 2
+	if end < 8 {
+		return false
+	}
+	padLength := int(data[end])
+	next := IPProtocol(data[end+1])
+	if end-padLength < 8 || (end-8+2)%4 != 0 {
+		return false
+	}
+	payload := data[8 : end-padLength]
+	// RFC 4303 section 2.4: default padding is 1, 2, 3, ...
+	for j, b := range data[end-padLength : end] {
+		if int(b) != j+1 {
+			return false
+		}
+	}
+	switch next {
+	case IPProtocolIPv4:
+		if len(payload) < 20 || payload[0]>>4 != 4 || int(binary.BigEndian.Uint16(payload[2:4])) != len(payload) {
+			return false
+		}
+	case IPProtocolIPv6:
+		if len(payload) < 40 || payload[0]>>4 != 6 || 40+int(binary.BigEndian.Uint16(payload[4:6])) != len(payload) {
+			return false
+		}
+	case IPProtocolUDP:
+		if len(payload) < 8 || int(binary.BigEndian.Uint16(payload[4:6])) != len(payload) {
+			return false
+		}
+	case IPProtocolTCP:
+		if len(payload) < 20 || payload[12]>>4 < 5 || int(payload[12]>>4)*4 > len(payload) {
+			return false
+		}
+	case IPProtocolICMPv4, IPProtocolICMPv6:
+		if len(payload) < 8 {
+			return false
+		}
+	default:
+		return false
+	}
+	i.NullEncrypted = true
+	i.PadLength = uint8(padLength)
+	i.NextHeader = next
+	i.ICV = data[len(data)-icvLength:]
+	i.BaseLayer = BaseLayer{data[:8], payload}
+	return true
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (i *IPSecESP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if i.NullEncrypted {
+		if opts.FixLengths {
+			i.PadLength = uint8((4 - (len(b.Bytes())+2)%4) % 4)
+		}
+		trailer, err := b.AppendBytes(int(i.PadLength) + 2 + len(i.ICV))
+		if err != nil {
+			return err
+		}
+		for j := 0; j < int(i.PadLength); j++ {
+			trailer[j] = uint8(j + 1)
+		}
+		trailer[int(i.PadLength)] = i.PadLength
+		trailer[int(i.PadLength)+1] = uint8(i.NextHeader)
+		copy(trailer[int(i.PadLength)+2:], i.ICV)
+	} else {
+		bytes, err := b.PrependBytes(len(i.Encrypted))
+		if err != nil {
+			return err
+		}
+		copy(bytes, i.Encrypted)
+	}
+	bytes, err := b.PrependBytes(8)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(bytes[:4], i.SPI)
+	binary.BigEndian.PutUint32(bytes[4:8], i.Seq)
+	return nil
+}
+
+func decodeIPSecESP(data []byte, p gopacket.PacketBuilder) error {
+	i := &IPSecESP{}
+	return decodingLayerDecoder(i, data, p)
+}
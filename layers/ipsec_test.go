@@ -7,9 +7,12 @@
 package layers
 
 import (
-	"github.com/google/gopacket"
+	"bytes"
+	"net"
 	"reflect"
 	"testing"
+
+	"github.com/google/gopacket"
 )
 
 // testPacketIPSecAHTransport is the packet:
@@ -152,3 +155,111 @@ func BenchmarkDecodePacketIPSecESP(b *testing.B) {
 		gopacket.NewPacket(testPacketIPSecESP, LinkTypeEthernet, gopacket.NoCopy)
 	}
 }
+
+func TestIPSecAHSerialize(t *testing.T) {
+	p := gopacket.NewPacket(testPacketIPSecAHTunnel, LinkTypeEthernet, gopacket.Default)
+	ah := p.Layer(LayerTypeIPSecAH).(*IPSecAH)
+	buf := gopacket.NewSerializeBuffer()
+	ah.HeaderLength = 0
+	if err := ah.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketIPSecAHTunnel[34:58]) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", testPacketIPSecAHTunnel[34:58], buf.Bytes())
+	}
+	if got := ah.ExtendedSeq(2); got != 2<<32|1 {
+		t.Errorf("got extended sequence number %x", got)
+	}
+	if err := ah.DecodeFromBytes(testPacketIPSecAHTunnel[34:50], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated AH")
+	}
+}
+
+func TestIPSecESPNull(t *testing.T) {
+	outer := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolESP, SrcIP: net.IP{192, 0, 2, 1}, DstIP: net.IP{192, 0, 2, 2}}
+	esp := &IPSecESP{
+		SPI:           0x1001,
+		Seq:           7,
+		NullEncrypted: true,
+		NextHeader:    IPProtocolIPv4,
+		ICV:           []byte{0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab},
+	}
+	inner := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &UDP{SrcPort: 1234, DstPort: 5678}
+	udp.SetNetworkLayerForChecksum(inner)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, outer, esp, inner, udp, gopacket.Payload("null")); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// 20 + 8 + 20 + 8 + 4 bytes, then 2 bytes of padding for alignment.
+	if len(data) != 20+8+32+2+2+12 || esp.PadLength != 2 {
+		t.Fatalf("got %d bytes with %d bytes of padding", len(data), esp.PadLength)
+	}
+
+	p := gopacket.NewPacket(data, LayerTypeIPv4, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeIPSecESP}, t)
+
+	IPSecESPDetectNull = true
+	defer func() { IPSecESPDetectNull = false }()
+	p = gopacket.NewPacket(data, LayerTypeIPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeIPSecESP, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	got := p.Layer(LayerTypeIPSecESP).(*IPSecESP)
+	if !got.NullEncrypted || got.PadLength != 2 || got.NextHeader != IPProtocolIPv4 || !bytes.Equal(got.ICV, esp.ICV) || len(got.Payload) != 32 {
+		t.Errorf("unexpected ESP layer %#v", got)
+	}
+	if got.ExtendedSeq(1) != 1<<32|7 {
+		t.Errorf("got extended sequence number %x", got.ExtendedSeq(1))
+	}
+
+	// Encrypted packets are left alone.
+	p = gopacket.NewPacket(testPacketIPSecESP, LinkTypeEthernet, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeIPSecESP}, t)
+}
+
+func TestIPSecESPNullMaxPadding(t *testing.T) {
+	outer := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolESP, SrcIP: net.IP{192, 0, 2, 1}, DstIP: net.IP{192, 0, 2, 2}}
+	esp := &IPSecESP{SPI: 0x1001, Seq: 8, NullEncrypted: true, PadLength: 255, NextHeader: IPProtocolIPv4}
+	inner := &IPv4{Version: 4, TTL: 64, Protocol: IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	udp := &UDP{SrcPort: 1234, DstPort: 5678}
+	udp.SetNetworkLayerForChecksum(inner)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	// 31 bytes of inner packet keep the 255 bytes of padding aligned.
+	if err := gopacket.SerializeLayers(buf, opts, inner, udp, gopacket.Payload("nul")); err != nil {
+		t.Fatal(err)
+	}
+	// Serialize the ESP layer without FixLengths, which would recompute the
+	// padding.
+	if err := esp.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := outer.SerializeTo(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	data := append([]byte(nil), buf.Bytes()...)
+	if len(data) != 20+8+31+255+2 || data[len(data)-1] != byte(IPProtocolIPv4) || data[len(data)-2] != 255 {
+		t.Fatalf("bad ESP trailer in %x", data)
+	}
+
+	IPSecESPDetectNull = true
+	defer func() { IPSecESPDetectNull = false }()
+	p := gopacket.NewPacket(data, LayerTypeIPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeIPSecESP).(*IPSecESP)
+	if !got.NullEncrypted || got.PadLength != 255 || got.NextHeader != IPProtocolIPv4 {
+		t.Fatalf("unexpected ESP layer %#v", got)
+	}
+	if err := gopacket.SerializePacket(buf, gopacket.SerializeOptions{}, p); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("round trip mismatch\nwant %x\ngot  %x", data, buf.Bytes())
+	}
+}
@@ -0,0 +1,631 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// isisIRPD is the network layer protocol identifier of IS-IS, its first byte.
+const isisIRPD = 0x83
+
+// ISISPDUType is the type of an IS-IS PDU.
+type ISISPDUType uint8
+
+// ISISPDUType known values.
+const (
+	ISISPDUTypeL1LANHello ISISPDUType = 15
+	ISISPDUTypeL2LANHello ISISPDUType = 16
+	ISISPDUTypeP2PHello   ISISPDUType = 17
+	ISISPDUTypeL1LSP      ISISPDUType = 18
+	ISISPDUTypeL2LSP      ISISPDUType = 20
+	ISISPDUTypeL1CSNP     ISISPDUType = 24
+	ISISPDUTypeL2CSNP     ISISPDUType = 25
+	ISISPDUTypeL1PSNP     ISISPDUType = 26
+	ISISPDUTypeL2PSNP     ISISPDUType = 27
+)
+
+func (t ISISPDUType) String() string {
+	switch t {
+	case ISISPDUTypeL1LANHello:
+		return "L1 LAN Hello"
+	case ISISPDUTypeL2LANHello:
+		return "L2 LAN Hello"
+	case ISISPDUTypeP2PHello:
+		return "P2P Hello"
+	case ISISPDUTypeL1LSP:
+		return "L1 LSP"
+	case ISISPDUTypeL2LSP:
+		return "L2 LSP"
+	case ISISPDUTypeL1CSNP:
+		return "L1 CSNP"
+	case ISISPDUTypeL2CSNP:
+		return "L2 CSNP"
+	case ISISPDUTypeL1PSNP:
+		return "L1 PSNP"
+	case ISISPDUTypeL2PSNP:
+		return "L2 PSNP"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// ISISTLVType is the type of an IS-IS TLV.
+type ISISTLVType uint8
+
+// ISISTLVType known values.
+const (
+	ISISTLVAreaAddresses          ISISTLVType = 1
+	ISISTLVISReachability         ISISTLVType = 2
+	ISISTLVISNeighbors            ISISTLVType = 6
+	ISISTLVPadding                ISISTLVType = 8
+	ISISTLVLSPEntries             ISISTLVType = 9
+	ISISTLVAuthentication         ISISTLVType = 10
+	ISISTLVExtendedISReachability ISISTLVType = 22
+	ISISTLVIPInternalReachability ISISTLVType = 128
+	ISISTLVProtocolsSupported     ISISTLVType = 129
+	ISISTLVIPExternalReachability ISISTLVType = 130
+	ISISTLVIPInterfaceAddresses   ISISTLVType = 132
+	ISISTLVTERouterID             ISISTLVType = 134
+	ISISTLVExtendedIPReachability ISISTLVType = 135
+	ISISTLVHostname               ISISTLVType = 137
+	ISISTLVIPv6TERouterID         ISISTLVType = 140
+	ISISTLVIPv6InterfaceAddresses ISISTLVType = 232
+	ISISTLVIPv6Reachability       ISISTLVType = 236
+	ISISTLVP2PAdjacencyState      ISISTLVType = 240
+)
+
+func (t ISISTLVType) String() string {
+	switch t {
+	case ISISTLVAreaAddresses:
+		return "AreaAddresses"
+	case ISISTLVISReachability:
+		return "ISReachability"
+	case ISISTLVISNeighbors:
+		return "ISNeighbors"
+	case ISISTLVPadding:
+		return "Padding"
+	case ISISTLVLSPEntries:
+		return "LSPEntries"
+	case ISISTLVAuthentication:
+		return "Authentication"
+	case ISISTLVExtendedISReachability:
+		return "ExtendedISReachability"
+	case ISISTLVIPInternalReachability:
+		return "IPInternalReachability"
+	case ISISTLVProtocolsSupported:
+		return "ProtocolsSupported"
+	case ISISTLVIPExternalReachability:
+		return "IPExternalReachability"
+	case ISISTLVIPInterfaceAddresses:
+		return "IPInterfaceAddresses"
+	case ISISTLVTERouterID:
+		return "TERouterID"
+	case ISISTLVExtendedIPReachability:
+		return "ExtendedIPReachability"
+	case ISISTLVHostname:
+		return "Hostname"
+	case ISISTLVIPv6TERouterID:
+		return "IPv6TERouterID"
+	case ISISTLVIPv6InterfaceAddresses:
+		return "IPv6InterfaceAddresses"
+	case ISISTLVIPv6Reachability:
+		return "IPv6Reachability"
+	case ISISTLVP2PAdjacencyState:
+		return "P2PAdjacencyState"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// ISISTLV is a TLV of an IS-IS PDU, as found in the PDU.
+type ISISTLV struct {
+	Type  ISISTLVType
+	Value []byte
+}
+
+// ISISSubTLV is a sub-TLV of an extended reachability entry.  Its type
+// depends on the TLV holding it.
+type ISISSubTLV struct {
+	Type  uint8
+	Value []byte
+}
+
+// ISISISReachability is a neighbor of an IS Reachability or Extended IS
+// Reachability TLV.  For the former, Metric is the 6 bit default metric
+// and there are no sub-TLVs.
+type ISISISReachability struct {
+	NeighborID []byte
+	Metric     uint32
+	SubTLVs    []ISISSubTLV
+}
+
+// ISISIPReachability is a prefix of an IP Internal or External
+// Reachability, Extended IP Reachability or IPv6 Reachability TLV.
+type ISISIPReachability struct {
+	Prefix net.IPNet
+	Metric uint32
+	// UpDown is set for prefixes leaked down from level 2.
+	UpDown bool
+	// External is set for prefixes of IP External Reachability TLVs, and
+	// IPv6 prefixes with the X bit.
+	External bool
+	SubTLVs  []ISISSubTLV
+}
+
+// ISISLSPEntry is an LSP summarized in the LSP Entries TLV of an SNP.
+type ISISLSPEntry struct {
+	RemainingLifetime uint16
+	LSPID             []byte
+	SequenceNumber    uint32
+	Checksum          uint16
+}
+
+// ISISHello is the part of LAN and point-to-point Hellos past the common
+// header.  Priority and LANID are only set in LAN Hellos, LocalCircuitID in
+// point-to-point ones.
+type ISISHello struct {
+	CircuitType    uint8
+	SourceID       []byte
+	HoldingTime    uint16
+	Priority       uint8
+	LANID          []byte
+	LocalCircuitID uint8
+}
+
+// Flags of LSPs.
+const (
+	ISISLSPPartitionRepair = 0x80
+	ISISLSPAttachedMask    = 0x78
+	ISISLSPOverload        = 0x04
+	ISISLSPISTypeMask      = 0x03
+)
+
+// ISISLSP is the part of an LSP past the common header.
+type ISISLSP struct {
+	RemainingLifetime uint16
+	LSPID             []byte
+	SequenceNumber    uint32
+	Checksum          uint16
+	Flags             uint8
+}
+
+// ISISSNP is the part of a CSNP or PSNP past the common header.  StartLSPID
+// and EndLSPID are only set in CSNPs.
+type ISISSNP struct {
+	SourceID   []byte
+	StartLSPID []byte
+	EndLSPID   []byte
+}
+
+// ISIS is an IS-IS PDU [ISO 10589, RFC1195], carried over LLC.  PDUType
+// determines which of Hello, LSP and SNP is set.
+//
+// All TLVs are kept in TLVs, unknown ones included.  The contents of the
+// known ones, of all TLVs of the type, are also decoded into the fields
+// following TLVs; those fields are ignored when serializing.
+type ISIS struct {
+	BaseLayer
+	IRPD         uint8
+	HeaderLength uint8
+	// ProtocolIDExtension and Version are always 1.
+	ProtocolIDExtension uint8
+	// IDLength is the length of system IDs, as found in the PDU: 0 means 6
+	// bytes, 255 none.
+	IDLength         uint8
+	PDUType          ISISPDUType
+	Version          uint8
+	Reserved         uint8
+	MaxAreaAddresses uint8
+	PDULength        uint16
+
+	Hello *ISISHello
+	LSP   *ISISLSP
+	SNP   *ISISSNP
+
+	TLVs []ISISTLV
+
+	AreaAddresses      [][]byte
+	ProtocolsSupported []byte
+	Hostname           string
+	RouterID           net.IP
+	IPv6RouterID       net.IP
+	InterfaceAddresses []net.IP
+	LANNeighbors       []net.HardwareAddr
+	ISReachability     []ISISISReachability
+	IPReachability     []ISISIPReachability
+	LSPEntries         []ISISLSPEntry
+}
+
+// LayerType returns LayerTypeISIS.
+func (i *ISIS) LayerType() gopacket.LayerType { return LayerTypeISIS }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (i *ISIS) CanDecode() gopacket.LayerClass { return LayerTypeISIS }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (i *ISIS) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// idLength returns the length of system IDs.
+func (i *ISIS) idLength() int {
+	switch i.IDLength {
+	case 0:
+		return 6
+	case 255:
+		return 0
+	}
+	return int(i.IDLength)
+}
+
+var errISISTruncated = errors.New("IS-IS PDU truncated")
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (i *ISIS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		df.SetTruncated()
+		return errISISTruncated
+	}
+	*i = ISIS{
+		IRPD:                data[0],
+		HeaderLength:        data[1],
+		ProtocolIDExtension: data[2],
+		IDLength:            data[3],
+		PDUType:             ISISPDUType(data[4] & 0x1f),
+		Reserved:            data[4] >> 5,
+		Version:             data[5],
+		MaxAreaAddresses:    data[7],
+	}
+	if i.IRPD != isisIRPD {
+		return fmt.Errorf("IS-IS IRPD %#x invalid", i.IRPD)
+	}
+	if i.IDLength > 8 && i.IDLength != 255 {
+		return fmt.Errorf("IS-IS ID length %d invalid", i.IDLength)
+	}
+	id := i.idLength()
+
+	var n int
+	switch i.PDUType {
+	case ISISPDUTypeL1LANHello, ISISPDUTypeL2LANHello:
+		n = 8 + 1 + id + 2 + 2 + 1 + id + 1
+	case ISISPDUTypeP2PHello:
+		n = 8 + 1 + id + 2 + 2 + 1
+	case ISISPDUTypeL1LSP, ISISPDUTypeL2LSP:
+		n = 8 + 2 + 2 + id + 2 + 4 + 2 + 1
+	case ISISPDUTypeL1CSNP, ISISPDUTypeL2CSNP:
+		n = 8 + 2 + id + 1 + 2*(id+2)
+	case ISISPDUTypeL1PSNP, ISISPDUTypeL2PSNP:
+		n = 8 + 2 + id + 1
+	default:
+		return fmt.Errorf("IS-IS PDU type %v unsupported", i.PDUType)
+	}
+	if int(i.HeaderLength) != n {
+		return fmt.Errorf("IS-IS %v header length %d, want %d", i.PDUType, i.HeaderLength, n)
+	}
+	if len(data) < n {
+		df.SetTruncated()
+		return errISISTruncated
+	}
+
+	b := data[8:n]
+	switch i.PDUType {
+	case ISISPDUTypeL1LANHello, ISISPDUTypeL2LANHello, ISISPDUTypeP2PHello:
+		h := &ISISHello{
+			CircuitType: b[0] & 0x03,
+			SourceID:    b[1 : 1+id],
+			HoldingTime: binary.BigEndian.Uint16(b[1+id:]),
+		}
+		i.PDULength = binary.BigEndian.Uint16(b[3+id:])
+		b = b[5+id:]
+		if i.PDUType == ISISPDUTypeP2PHello {
+			h.LocalCircuitID = b[0]
+		} else {
+			h.Priority = b[0] & 0x7f
+			h.LANID = b[1 : 2+id]
+		}
+		i.Hello = h
+	case ISISPDUTypeL1LSP, ISISPDUTypeL2LSP:
+		i.PDULength = binary.BigEndian.Uint16(b[0:2])
+		i.LSP = &ISISLSP{
+			RemainingLifetime: binary.BigEndian.Uint16(b[2:4]),
+			LSPID:             b[4 : 6+id],
+			SequenceNumber:    binary.BigEndian.Uint32(b[6+id:]),
+			Checksum:          binary.BigEndian.Uint16(b[10+id:]),
+			Flags:             b[12+id],
+		}
+	default:
+		i.PDULength = binary.BigEndian.Uint16(b[0:2])
+		s := &ISISSNP{SourceID: b[2 : 3+id]}
+		if b = b[3+id:]; len(b) > 0 {
+			s.StartLSPID = b[:id+2]
+			s.EndLSPID = b[id+2:]
+		}
+		i.SNP = s
+	}
+
+	if int(i.PDULength) < n {
+		return fmt.Errorf("IS-IS PDU length %d shorter than its header", i.PDULength)
+	}
+	if int(i.PDULength) > len(data) {
+		df.SetTruncated()
+		return errISISTruncated
+	}
+	i.BaseLayer = BaseLayer{Contents: data[:i.PDULength]}
+	for b = data[n:i.PDULength]; len(b) > 0; {
+		if len(b) < 2 {
+			return errors.New("IS-IS TLV truncated")
+		}
+		l := 2 + int(b[1])
+		if len(b) < l {
+			return errors.New("IS-IS TLV truncated")
+		}
+		tlv := ISISTLV{Type: ISISTLVType(b[0]), Value: b[2:l]}
+		if err := i.decodeTLV(tlv, id); err != nil {
+			return err
+		}
+		i.TLVs = append(i.TLVs, tlv)
+		b = b[l:]
+	}
+	return nil
+}
+
+// decodeTLV decodes the contents of the known TLVs into the fields holding
+// them.
+func (i *ISIS) decodeTLV(tlv ISISTLV, id int) error {
+	v := tlv.Value
+	switch tlv.Type {
+	case ISISTLVAreaAddresses:
+		for len(v) > 0 {
+			l := 1 + int(v[0])
+			if len(v) < l {
+				return fmt.Errorf("IS-IS %v TLV truncated", tlv.Type)
+			}
+			i.AreaAddresses = append(i.AreaAddresses, v[1:l])
+			v = v[l:]
+		}
+	case ISISTLVISNeighbors:
+		if len(v)%6 != 0 {
+			return fmt.Errorf("IS-IS %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		for ; len(v) > 0; v = v[6:] {
+			i.LANNeighbors = append(i.LANNeighbors, net.HardwareAddr(v[:6]))
+		}
+	case ISISTLVISReachability:
+		// A virtual flag precedes the neighbors, each of which has four
+		// metrics.
+		n := 4 + id + 1
+		if len(v) < 1 || (len(v)-1)%n != 0 {
+			return fmt.Errorf("IS-IS %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		for v = v[1:]; len(v) > 0; v = v[n:] {
+			i.ISReachability = append(i.ISReachability, ISISISReachability{
+				NeighborID: v[4:n],
+				Metric:     uint32(v[0] & 0x3f),
+			})
+		}
+	case ISISTLVExtendedISReachability:
+		for len(v) > 0 {
+			n := id + 1
+			if len(v) < n+4 {
+				return fmt.Errorf("IS-IS %v TLV truncated", tlv.Type)
+			}
+			r := ISISISReachability{
+				NeighborID: v[:n],
+				Metric:     uint32(v[n])<<16 | uint32(v[n+1])<<8 | uint32(v[n+2]),
+			}
+			var err error
+			if r.SubTLVs, v, err = decodeISISSubTLVs(v[n+3:], tlv.Type); err != nil {
+				return err
+			}
+			i.ISReachability = append(i.ISReachability, r)
+		}
+	case ISISTLVIPInternalReachability, ISISTLVIPExternalReachability:
+		if len(v)%12 != 0 {
+			return fmt.Errorf("IS-IS %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		for ; len(v) > 0; v = v[12:] {
+			i.IPReachability = append(i.IPReachability, ISISIPReachability{
+				Prefix:   net.IPNet{IP: net.IP(v[4:8]), Mask: net.IPMask(v[8:12])},
+				Metric:   uint32(v[0] & 0x3f),
+				UpDown:   v[0]&0x80 != 0,
+				External: tlv.Type == ISISTLVIPExternalReachability,
+			})
+		}
+	case ISISTLVExtendedIPReachability, ISISTLVIPv6Reachability:
+		for len(v) > 0 {
+			var r ISISIPReachability
+			var subTLVs bool
+			var bits, n int
+			if len(v) < 5 {
+				return fmt.Errorf("IS-IS %v TLV truncated", tlv.Type)
+			}
+			r.Metric = binary.BigEndian.Uint32(v[0:4])
+			r.UpDown = v[4]&0x80 != 0
+			if tlv.Type == ISISTLVExtendedIPReachability {
+				subTLVs = v[4]&0x40 != 0
+				bits, n = int(v[4]&0x3f), 4
+				v = v[5:]
+			} else {
+				if len(v) < 6 {
+					return fmt.Errorf("IS-IS %v TLV truncated", tlv.Type)
+				}
+				r.External = v[4]&0x40 != 0
+				subTLVs = v[4]&0x20 != 0
+				bits, n = int(v[5]), 16
+				v = v[6:]
+			}
+			if bits > 8*n {
+				return fmt.Errorf("IS-IS %v TLV prefix length %d invalid", tlv.Type, bits)
+			}
+			l := (bits + 7) / 8
+			if len(v) < l {
+				return fmt.Errorf("IS-IS %v TLV truncated", tlv.Type)
+			}
+			ip := make(net.IP, n)
+			copy(ip, v[:l])
+			r.Prefix = net.IPNet{IP: ip, Mask: net.CIDRMask(bits, 8*n)}
+			v = v[l:]
+			if subTLVs {
+				var err error
+				if r.SubTLVs, v, err = decodeISISSubTLVs(v, tlv.Type); err != nil {
+					return err
+				}
+			}
+			i.IPReachability = append(i.IPReachability, r)
+		}
+	case ISISTLVLSPEntries:
+		n := 2 + id + 2 + 4 + 2
+		if len(v)%n != 0 {
+			return fmt.Errorf("IS-IS %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		for ; len(v) > 0; v = v[n:] {
+			i.LSPEntries = append(i.LSPEntries, ISISLSPEntry{
+				RemainingLifetime: binary.BigEndian.Uint16(v[0:2]),
+				LSPID:             v[2 : 4+id],
+				SequenceNumber:    binary.BigEndian.Uint32(v[4+id:]),
+				Checksum:          binary.BigEndian.Uint16(v[8+id:]),
+			})
+		}
+	case ISISTLVProtocolsSupported:
+		i.ProtocolsSupported = v
+	case ISISTLVHostname:
+		i.Hostname = string(v)
+	case ISISTLVTERouterID:
+		if len(v) != 4 {
+			return fmt.Errorf("IS-IS %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		i.RouterID = net.IP(v)
+	case ISISTLVIPv6TERouterID:
+		if len(v) != 16 {
+			return fmt.Errorf("IS-IS %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		i.IPv6RouterID = net.IP(v)
+	case ISISTLVIPInterfaceAddresses, ISISTLVIPv6InterfaceAddresses:
+		n := 4
+		if tlv.Type == ISISTLVIPv6InterfaceAddresses {
+			n = 16
+		}
+		if len(v)%n != 0 {
+			return fmt.Errorf("IS-IS %v TLV length %d invalid", tlv.Type, len(v))
+		}
+		for ; len(v) > 0; v = v[n:] {
+			i.InterfaceAddresses = append(i.InterfaceAddresses, net.IP(v[:n]))
+		}
+	}
+	return nil
+}
+
+// decodeISISSubTLVs decodes the sub-TLVs of an extended reachability entry,
+// led by their length, at the start of data, returning what follows them.
+func decodeISISSubTLVs(data []byte, t ISISTLVType) ([]ISISSubTLV, []byte, error) {
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return nil, nil, fmt.Errorf("IS-IS %v TLV sub-TLVs truncated", t)
+	}
+	n := 1 + int(data[0])
+	b, rest := data[1:n], data[n:]
+	var subTLVs []ISISSubTLV
+	for len(b) > 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, nil, fmt.Errorf("IS-IS %v TLV sub-TLV truncated", t)
+		}
+		l := 2 + int(b[1])
+		subTLVs = append(subTLVs, ISISSubTLV{Type: b[0], Value: b[2:l]})
+		b = b[l:]
+	}
+	return subTLVs, rest, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// The PDU-specific fields are written from Hello, LSP or SNP, whichever
+// PDUType selects, and the TLVs from TLVs alone.  LSP checksums are written
+// as they are.
+func (i *ISIS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	h := []byte{i.IRPD, i.HeaderLength, i.ProtocolIDExtension, i.IDLength, i.Reserved<<5 | uint8(i.PDUType)&0x1f, i.Version, 0, i.MaxAreaAddresses}
+	var pduLength int
+	switch i.PDUType {
+	case ISISPDUTypeL1LANHello, ISISPDUTypeL2LANHello, ISISPDUTypeP2PHello:
+		if i.Hello == nil {
+			return fmt.Errorf("IS-IS %v without Hello", i.PDUType)
+		}
+		h = append(h, i.Hello.CircuitType)
+		h = append(h, i.Hello.SourceID...)
+		h = append(h, byte(i.Hello.HoldingTime>>8), byte(i.Hello.HoldingTime))
+		pduLength = len(h)
+		h = append(h, 0, 0)
+		if i.PDUType == ISISPDUTypeP2PHello {
+			h = append(h, i.Hello.LocalCircuitID)
+		} else {
+			h = append(h, i.Hello.Priority&0x7f)
+			h = append(h, i.Hello.LANID...)
+		}
+	case ISISPDUTypeL1LSP, ISISPDUTypeL2LSP:
+		if i.LSP == nil {
+			return fmt.Errorf("IS-IS %v without LSP", i.PDUType)
+		}
+		pduLength = len(h)
+		h = append(h, 0, 0, byte(i.LSP.RemainingLifetime>>8), byte(i.LSP.RemainingLifetime))
+		h = append(h, i.LSP.LSPID...)
+		var b [7]byte
+		binary.BigEndian.PutUint32(b[0:4], i.LSP.SequenceNumber)
+		binary.BigEndian.PutUint16(b[4:6], i.LSP.Checksum)
+		b[6] = i.LSP.Flags
+		h = append(h, b[:]...)
+	case ISISPDUTypeL1CSNP, ISISPDUTypeL2CSNP, ISISPDUTypeL1PSNP, ISISPDUTypeL2PSNP:
+		if i.SNP == nil {
+			return fmt.Errorf("IS-IS %v without SNP", i.PDUType)
+		}
+		pduLength = len(h)
+		h = append(h, 0, 0)
+		h = append(h, i.SNP.SourceID...)
+		h = append(h, i.SNP.StartLSPID...)
+		h = append(h, i.SNP.EndLSPID...)
+	default:
+		return fmt.Errorf("IS-IS PDU type %v unsupported", i.PDUType)
+	}
+
+	length := len(h)
+	for _, tlv := range i.TLVs {
+		if len(tlv.Value) > 0xff {
+			return fmt.Errorf("IS-IS %v TLV value of %d bytes too long", tlv.Type, len(tlv.Value))
+		}
+		length += 2 + len(tlv.Value)
+	}
+	if length > 0xffff {
+		return fmt.Errorf("IS-IS PDU of %d bytes too long", length)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		i.HeaderLength = uint8(len(h))
+		i.PDULength = uint16(length)
+		h[1] = i.HeaderLength
+	}
+	binary.BigEndian.PutUint16(h[pduLength:], i.PDULength)
+	copy(bytes, h)
+	off := len(h)
+	for _, tlv := range i.TLVs {
+		bytes[off] = byte(tlv.Type)
+		bytes[off+1] = byte(len(tlv.Value))
+		copy(bytes[off+2:], tlv.Value)
+		off += 2 + len(tlv.Value)
+	}
+	return nil
+}
+
+func decodeISIS(data []byte, p gopacket.PacketBuilder) error {
+	i := &ISIS{}
+	return decodingLayerDecoder(i, data, p)
+}
@@ -0,0 +1,256 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketISISLANHello is an L2 LAN Hello from 1921.6800.0001 in area
+// 49.0001, electing itself DR with priority 64.
+var testPacketISISLANHello = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x15, 0x02, 0x42, 0xc0, 0xa8, 0x00, 0x01, 0x00, 0x40, 0xfe, 0xfe,
+	0x03, 0x83, 0x1b, 0x01, 0x00, 0x10, 0x01, 0x00, 0x00, 0x02, 0x19, 0x21, 0x68, 0x00, 0x00, 0x01,
+	0x00, 0x1e, 0x00, 0x3d, 0x40, 0x19, 0x21, 0x68, 0x00, 0x00, 0x01, 0x01, 0x81, 0x02, 0xcc, 0x8e,
+	0x01, 0x04, 0x03, 0x49, 0x00, 0x01, 0x06, 0x06, 0x02, 0x42, 0xc0, 0xa8, 0x00, 0x02, 0x84, 0x04,
+	0x0a, 0x00, 0x00, 0x01, 0x08, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// testPacketISISLSP is an L2 LSP of 1921.6800.0001, named rtr-a, with old
+// and extended IS and IP reachability, IPv6 reachability and an unknown
+// TLV.
+var testPacketISISLSP = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x15, 0x02, 0x42, 0xc0, 0xa8, 0x00, 0x01, 0x00, 0xab, 0xfe, 0xfe,
+	0x03, 0x83, 0x1b, 0x01, 0x00, 0x14, 0x01, 0x00, 0x00, 0x00, 0xa8, 0x04, 0xaf, 0x19, 0x21, 0x68,
+	0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a, 0x12, 0x34, 0x03, 0x01, 0x04, 0x03, 0x49,
+	0x00, 0x01, 0x81, 0x02, 0xcc, 0x8e, 0x89, 0x05, 0x72, 0x74, 0x72, 0x2d, 0x61, 0x86, 0x04, 0xc0,
+	0x00, 0x02, 0x01, 0x8c, 0x10, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x01, 0x16, 0x17, 0x19, 0x21, 0x68, 0x00, 0x00, 0x02, 0x01, 0x00, 0x00,
+	0x0a, 0x0c, 0x06, 0x04, 0x0a, 0x00, 0x00, 0x01, 0x08, 0x04, 0x0a, 0x00, 0x00, 0x02, 0x87, 0x16,
+	0x00, 0x00, 0x00, 0x0a, 0x18, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x14, 0xe0, 0xc0, 0x00, 0x02,
+	0x01, 0x04, 0x01, 0x02, 0x00, 0x00, 0xec, 0x0e, 0x00, 0x00, 0x00, 0x0a, 0x40, 0x40, 0x20, 0x01,
+	0x0d, 0xb8, 0x00, 0x00, 0x00, 0x01, 0x80, 0x0c, 0x0a, 0x80, 0x80, 0x80, 0xac, 0x10, 0x00, 0x00,
+	0xff, 0xff, 0x00, 0x00, 0x02, 0x0c, 0x00, 0x0a, 0x80, 0x80, 0x80, 0x19, 0x21, 0x68, 0x00, 0x00,
+	0x02, 0x00, 0xf2, 0x05, 0x04, 0x00, 0xc0, 0x00, 0x02,
+}
+
+// testPacketISISCSNP is an L2 CSNP from 1921.6800.0001 describing two LSPs.
+var testPacketISISCSNP = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x15, 0x02, 0x42, 0xc0, 0xa8, 0x00, 0x01, 0x00, 0x46, 0xfe, 0xfe,
+	0x03, 0x83, 0x21, 0x01, 0x00, 0x19, 0x01, 0x00, 0x00, 0x00, 0x43, 0x19, 0x21, 0x68, 0x00, 0x00,
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0x09, 0x20, 0x04, 0xaf, 0x19, 0x21, 0x68, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x2a, 0x12, 0x34, 0x04, 0x9c, 0x19, 0x21, 0x68, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x11, 0xbe, 0xef,
+}
+
+func TestISISLANHello(t *testing.T) {
+	p := gopacket.NewPacket(testPacketISISLANHello, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLLC, LayerTypeISIS}, t)
+	isis := p.Layer(LayerTypeISIS).(*ISIS)
+	if isis.PDUType != ISISPDUTypeL2LANHello || isis.HeaderLength != 27 || isis.PDULength != 61 {
+		t.Errorf("Got %v header length %d PDU length %d", isis.PDUType, isis.HeaderLength, isis.PDULength)
+	}
+	h := isis.Hello
+	if h == nil {
+		t.Fatal("Hello not decoded")
+	}
+	want := &ISISHello{
+		CircuitType: 2,
+		SourceID:    []byte{0x19, 0x21, 0x68, 0x00, 0x00, 0x01},
+		HoldingTime: 30,
+		Priority:    64,
+		LANID:       []byte{0x19, 0x21, 0x68, 0x00, 0x00, 0x01, 0x01},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("Got Hello %+v, want %+v", h, want)
+	}
+	if len(isis.TLVs) != 5 || isis.TLVs[4].Type != ISISTLVPadding {
+		t.Errorf("Got TLVs %v", isis.TLVs)
+	}
+	if len(isis.AreaAddresses) != 1 || !bytes.Equal(isis.AreaAddresses[0], []byte{0x49, 0x00, 0x01}) {
+		t.Errorf("Got area addresses %v", isis.AreaAddresses)
+	}
+	if len(isis.LANNeighbors) != 1 || isis.LANNeighbors[0].String() != "02:42:c0:a8:00:02" {
+		t.Errorf("Got LAN neighbors %v", isis.LANNeighbors)
+	}
+	if !bytes.Equal(isis.ProtocolsSupported, []byte{0xcc, 0x8e}) || len(isis.InterfaceAddresses) != 1 {
+		t.Errorf("Got protocols %v interface addresses %v", isis.ProtocolsSupported, isis.InterfaceAddresses)
+	}
+	testSerialization(t, p, testPacketISISLANHello)
+}
+
+func TestISISLSP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketISISLSP, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLLC, LayerTypeISIS}, t)
+	isis := p.Layer(LayerTypeISIS).(*ISIS)
+	l := isis.LSP
+	if l == nil {
+		t.Fatal("LSP not decoded")
+	}
+	if l.RemainingLifetime != 1199 || !bytes.Equal(l.LSPID, []byte{0x19, 0x21, 0x68, 0x00, 0x00, 0x01, 0x00, 0x00}) ||
+		l.SequenceNumber != 0x2a || l.Checksum != 0x1234 || l.Flags&ISISLSPISTypeMask != 3 {
+		t.Errorf("Got LSP %+v", l)
+	}
+	if isis.Hostname != "rtr-a" {
+		t.Errorf("Got hostname %q", isis.Hostname)
+	}
+	if !isis.RouterID.Equal(net.IP{192, 0, 2, 1}) || !isis.IPv6RouterID.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("Got router IDs %v %v", isis.RouterID, isis.IPv6RouterID)
+	}
+	if last := isis.TLVs[len(isis.TLVs)-1]; last.Type != 242 || len(last.Value) != 5 {
+		t.Errorf("Got unknown TLV %v", last)
+	}
+
+	if len(isis.ISReachability) != 2 {
+		t.Fatalf("Got IS reachability %+v", isis.ISReachability)
+	}
+	ext := isis.ISReachability[0]
+	if !bytes.Equal(ext.NeighborID, []byte{0x19, 0x21, 0x68, 0x00, 0x00, 0x02, 0x01}) || ext.Metric != 10 || len(ext.SubTLVs) != 2 {
+		t.Errorf("Got extended IS reachability %+v", ext)
+	} else if s := ext.SubTLVs[1]; s.Type != 8 || !net.IP(s.Value).Equal(net.IP{10, 0, 0, 2}) {
+		t.Errorf("Got neighbor address sub-TLV %+v", s)
+	}
+	if old := isis.ISReachability[1]; old.Metric != 10 || len(old.NeighborID) != 7 || old.SubTLVs != nil {
+		t.Errorf("Got IS reachability %+v", old)
+	}
+
+	if len(isis.IPReachability) != 4 {
+		t.Fatalf("Got IP reachability %+v", isis.IPReachability)
+	}
+	for i, want := range []struct {
+		prefix           string
+		metric           uint32
+		upDown, external bool
+		subTLVs          int
+	}{
+		{"10.0.0.0/24", 10, false, false, 0},
+		{"192.0.2.1/32", 20, true, false, 1},
+		{"2001:db8:0:1::/64", 10, false, true, 0},
+		{"172.16.0.0/16", 10, false, false, 0},
+	} {
+		r := isis.IPReachability[i]
+		if r.Prefix.String() != want.prefix || r.Metric != want.metric || r.UpDown != want.upDown || r.External != want.external || len(r.SubTLVs) != want.subTLVs {
+			t.Errorf("Got IP reachability %d %v metric %d up/down %v external %v sub-TLVs %v", i, r.Prefix.String(), r.Metric, r.UpDown, r.External, r.SubTLVs)
+		}
+	}
+	testSerialization(t, p, testPacketISISLSP)
+}
+
+func TestISISCSNP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketISISCSNP, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLLC, LayerTypeISIS}, t)
+	isis := p.Layer(LayerTypeISIS).(*ISIS)
+	s := isis.SNP
+	if s == nil || len(s.SourceID) != 7 || !bytes.Equal(s.StartLSPID, make([]byte, 8)) || !bytes.Equal(s.EndLSPID, bytes.Repeat([]byte{0xff}, 8)) {
+		t.Fatalf("Got CSNP %+v", s)
+	}
+	want := []ISISLSPEntry{
+		{RemainingLifetime: 1199, LSPID: []byte{0x19, 0x21, 0x68, 0x00, 0x00, 0x01, 0x00, 0x00}, SequenceNumber: 0x2a, Checksum: 0x1234},
+		{RemainingLifetime: 1180, LSPID: []byte{0x19, 0x21, 0x68, 0x00, 0x00, 0x02, 0x00, 0x00}, SequenceNumber: 0x11, Checksum: 0xbeef},
+	}
+	if !reflect.DeepEqual(isis.LSPEntries, want) {
+		t.Errorf("Got LSP entries %+v, want %+v", isis.LSPEntries, want)
+	}
+	testSerialization(t, p, testPacketISISCSNP)
+}
+
+func TestISISPDUs(t *testing.T) {
+	psnp := []byte{
+		0x83, 0x11, 0x01, 0x00, 0x1a, 0x01, 0x00, 0x00, 0x00, 0x23, 0x19, 0x21, 0x68, 0x00, 0x00, 0x02,
+		0x00, 0x09, 0x10, 0x04, 0x9c, 0x19, 0x21, 0x68, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x11, 0xbe, 0xef,
+	}
+	p := gopacket.NewPacket(psnp, LayerTypeISIS, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode PSNP:", p.ErrorLayer().Error())
+	}
+	isis := p.Layer(LayerTypeISIS).(*ISIS)
+	if isis.PDUType != ISISPDUTypeL1PSNP || isis.SNP == nil || isis.SNP.StartLSPID != nil || len(isis.LSPEntries) != 1 {
+		t.Errorf("Got %v SNP %+v entries %+v", isis.PDUType, isis.SNP, isis.LSPEntries)
+	}
+
+	p2p := []byte{
+		0x83, 0x14, 0x01, 0x00, 0x11, 0x01, 0x00, 0x00, 0x03, 0x19, 0x21, 0x68, 0x00, 0x00, 0x02, 0x00,
+		0x1e, 0x00, 0x17, 0x05, 0xf0, 0x01, 0x00,
+	}
+	p = gopacket.NewPacket(p2p, LayerTypeISIS, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode P2P Hello:", p.ErrorLayer().Error())
+	}
+	isis = p.Layer(LayerTypeISIS).(*ISIS)
+	if h := isis.Hello; h == nil || h.CircuitType != 3 || h.LocalCircuitID != 5 || h.LANID != nil {
+		t.Errorf("Got P2P Hello %+v", h)
+	}
+	if len(isis.TLVs) != 1 || isis.TLVs[0].Type != ISISTLVP2PAdjacencyState {
+		t.Errorf("Got TLVs %v", isis.TLVs)
+	}
+
+	// Serializing with fixed lengths ignores the lengths set.
+	isis.HeaderLength, isis.PDULength = 0, 0
+	buf := gopacket.NewSerializeBuffer()
+	if err := isis.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), p2p) {
+		t.Errorf("Serialized P2P Hello\n%v\nwant\n%v", buf.Bytes(), p2p)
+	}
+}
+
+func TestISISMalformed(t *testing.T) {
+	lsp := testPacketISISLSP[17:]
+	for _, data := range [][]byte{
+		lsp[:7],
+		// Wrong IRPD
+		append([]byte{0x82}, lsp[1:]...),
+		// Header length not matching the PDU type
+		append([]byte{0x83, 0x1c}, lsp[2:]...),
+		// PDU length beyond the data
+		lsp[:len(lsp)-1],
+		// TLV longer than the PDU
+		append(append([]byte{}, lsp[:len(lsp)-7]...), 0xf2, 0x06, 0x04, 0x00, 0xc0, 0x00, 0x02),
+	} {
+		p := gopacket.NewPacket(data, LayerTypeISIS, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("Decoded malformed PDU %v", data)
+		}
+	}
+}
+
+func TestISISLongTLV(t *testing.T) {
+	value := bytes.Repeat([]byte{0xaa}, 0xff)
+	data := append(append(append([]byte{}, testPacketISISLSP[17:]...), 0xfa, 0xff), value...)
+	binary.BigEndian.PutUint16(data[8:10], uint16(len(data)))
+	p := gopacket.NewPacket(data, LayerTypeISIS, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	i := p.Layer(LayerTypeISIS).(*ISIS)
+	if tlv := i.TLVs[len(i.TLVs)-1]; tlv.Type != 0xfa || !bytes.Equal(tlv.Value, value) {
+		t.Errorf("Got TLV %+v", tlv)
+	}
+	binary.BigEndian.PutUint16(data[8:10], uint16(len(data)-1))
+	p = gopacket.NewPacket(data, LayerTypeISIS, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("Decoded truncated TLV")
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// LACPDUs and Marker PDUs (IEEE 802.1AX, formerly 802.3ad) are fixed size
+// slow protocol frames: the subtype and version, a fixed sequence of TLVs,
+// then reserved bytes up to 110 bytes.
+const (
+	lacpPDULength = 110
+	// lacpMinLength covers the actor, partner, collector and terminator
+	// TLVs, which is as far as decoding needs to go.
+	lacpMinLength = 2 + 20 + 20 + 16 + 2
+	// lacpMarkerMinLength covers the marker information and terminator
+	// TLVs.
+	lacpMarkerMinLength = 2 + 16 + 2
+)
+
+// LACP TLV types.
+const (
+	lacpTLVTerminator = 0
+	lacpTLVActor      = 1
+	lacpTLVPartner    = 2
+	lacpTLVCollector  = 3
+
+	lacpTLVMarkerInformation = 1
+	lacpTLVMarkerResponse    = 2
+)
+
+// LACPState is the state byte of an actor or partner, expanded into its
+// eight flags.
+type LACPState struct {
+	// Activity is set for active LACP, clear for passive.
+	Activity bool
+	// Timeout is set for short (fast) timeouts, clear for long ones.
+	Timeout         bool
+	Aggregation     bool
+	Synchronization bool
+	Collecting      bool
+	Distributing    bool
+	Defaulted       bool
+	Expired         bool
+}
+
+func decodeLACPState(b byte) LACPState {
+	return LACPState{
+		Activity:        b&0x01 != 0,
+		Timeout:         b&0x02 != 0,
+		Aggregation:     b&0x04 != 0,
+		Synchronization: b&0x08 != 0,
+		Collecting:      b&0x10 != 0,
+		Distributing:    b&0x20 != 0,
+		Defaulted:       b&0x40 != 0,
+		Expired:         b&0x80 != 0,
+	}
+}
+
+// Byte returns the state packed into its wire format.
+func (s LACPState) Byte() byte {
+	var b byte
+	for i, set := range []bool{s.Activity, s.Timeout, s.Aggregation, s.Synchronization,
+		s.Collecting, s.Distributing, s.Defaulted, s.Expired} {
+		if set {
+			b |= 1 << uint(i)
+		}
+	}
+	return b
+}
+
+// LACPInfo is the actor or partner information of an LACPDU.
+type LACPInfo struct {
+	SystemPriority uint16
+	System         net.HardwareAddr
+	Key            uint16
+	PortPriority   uint16
+	Port           uint16
+	State          LACPState
+}
+
+func (i *LACPInfo) decode(tlv []byte) {
+	i.SystemPriority = binary.BigEndian.Uint16(tlv[2:4])
+	i.System = net.HardwareAddr(tlv[4:10])
+	i.Key = binary.BigEndian.Uint16(tlv[10:12])
+	i.PortPriority = binary.BigEndian.Uint16(tlv[12:14])
+	i.Port = binary.BigEndian.Uint16(tlv[14:16])
+	i.State = decodeLACPState(tlv[16])
+}
+
+func (i *LACPInfo) encode(tlv []byte, typ byte) {
+	tlv[0] = typ
+	tlv[1] = 20
+	binary.BigEndian.PutUint16(tlv[2:4], i.SystemPriority)
+	copy(tlv[4:10], i.System)
+	binary.BigEndian.PutUint16(tlv[10:12], i.Key)
+	binary.BigEndian.PutUint16(tlv[12:14], i.PortPriority)
+	binary.BigEndian.PutUint16(tlv[14:16], i.Port)
+	tlv[16] = i.State.Byte()
+}
+
+// checkLACPTLV checks that data starts with a TLV of the given type and
+// length.
+func checkLACPTLV(data []byte, typ, length byte, name string) error {
+	if data[0] != typ {
+		return fmt.Errorf("expected %s TLV type %d, got %d", name, typ, data[0])
+	}
+	if data[1] != length {
+		return fmt.Errorf("%s TLV length %d, %d required", name, data[1], length)
+	}
+	return nil
+}
+
+// LACP is an IEEE 802.1AX Link Aggregation Control Protocol PDU, carried as
+// slow protocol subtype 1.
+type LACP struct {
+	BaseLayer
+	Version           uint8
+	Actor             LACPInfo
+	Partner           LACPInfo
+	CollectorMaxDelay uint16 // In tens of microseconds.
+}
+
+// LayerType returns LayerTypeLACP.
+func (l *LACP) LayerType() gopacket.LayerType { return LayerTypeLACP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (l *LACP) CanDecode() gopacket.LayerClass { return LayerTypeLACP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (l *LACP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (l *LACP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < lacpMinLength {
+		df.SetTruncated()
+		return fmt.Errorf("LACPDU less than %d bytes", lacpMinLength)
+	}
+	if SlowProtocolSubtype(data[0]) != SlowProtocolSubtypeLACP {
+		return fmt.Errorf("slow protocol subtype %v is not LACP", SlowProtocolSubtype(data[0]))
+	}
+	l.Version = data[1]
+	if err := checkLACPTLV(data[2:], lacpTLVActor, 20, "LACP actor"); err != nil {
+		return err
+	}
+	if err := checkLACPTLV(data[22:], lacpTLVPartner, 20, "LACP partner"); err != nil {
+		return err
+	}
+	if err := checkLACPTLV(data[42:], lacpTLVCollector, 16, "LACP collector"); err != nil {
+		return err
+	}
+	if err := checkLACPTLV(data[58:], lacpTLVTerminator, 0, "LACP terminator"); err != nil {
+		return err
+	}
+	l.Actor.decode(data[2:22])
+	l.Partner.decode(data[22:42])
+	l.CollectorMaxDelay = binary.BigEndian.Uint16(data[44:46])
+	l.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (l *LACP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(lacpPDULength)
+	if err != nil {
+		return err
+	}
+	for i := range bytes {
+		bytes[i] = 0
+	}
+	bytes[0] = byte(SlowProtocolSubtypeLACP)
+	bytes[1] = l.Version
+	l.Actor.encode(bytes[2:22], lacpTLVActor)
+	l.Partner.encode(bytes[22:42], lacpTLVPartner)
+	bytes[42] = lacpTLVCollector
+	bytes[43] = 16
+	binary.BigEndian.PutUint16(bytes[44:46], l.CollectorMaxDelay)
+	return nil
+}
+
+func decodeLACP(data []byte, p gopacket.PacketBuilder) error {
+	l := &LACP{}
+	return decodingLayerDecoder(l, data, p)
+}
+
+// LACPMarker is an IEEE 802.1AX Marker PDU, carried as slow protocol subtype
+// 2.  It's used to flush a link before moving conversations off it.
+type LACPMarker struct {
+	BaseLayer
+	Version uint8
+	// Response is set for Marker Response PDUs, clear for Marker
+	// Information PDUs.
+	Response               bool
+	RequesterPort          uint16
+	RequesterSystem        net.HardwareAddr
+	RequesterTransactionID uint32
+}
+
+// LayerType returns LayerTypeLACPMarker.
+func (m *LACPMarker) LayerType() gopacket.LayerType { return LayerTypeLACPMarker }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *LACPMarker) CanDecode() gopacket.LayerClass { return LayerTypeLACPMarker }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (m *LACPMarker) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (m *LACPMarker) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < lacpMarkerMinLength {
+		df.SetTruncated()
+		return fmt.Errorf("Marker PDU less than %d bytes", lacpMarkerMinLength)
+	}
+	if SlowProtocolSubtype(data[0]) != SlowProtocolSubtypeMarker {
+		return fmt.Errorf("slow protocol subtype %v is not Marker", SlowProtocolSubtype(data[0]))
+	}
+	m.Version = data[1]
+	switch data[2] {
+	case lacpTLVMarkerInformation:
+		m.Response = false
+	case lacpTLVMarkerResponse:
+		m.Response = true
+	default:
+		return fmt.Errorf("unknown Marker TLV type %d", data[2])
+	}
+	if data[3] != 16 {
+		return fmt.Errorf("Marker TLV length %d, 16 required", data[3])
+	}
+	if err := checkLACPTLV(data[18:], lacpTLVTerminator, 0, "Marker terminator"); err != nil {
+		return err
+	}
+	m.RequesterPort = binary.BigEndian.Uint16(data[4:6])
+	m.RequesterSystem = net.HardwareAddr(data[6:12])
+	m.RequesterTransactionID = binary.BigEndian.Uint32(data[12:16])
+	m.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (m *LACPMarker) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(lacpPDULength)
+	if err != nil {
+		return err
+	}
+	for i := range bytes {
+		bytes[i] = 0
+	}
+	bytes[0] = byte(SlowProtocolSubtypeMarker)
+	bytes[1] = m.Version
+	bytes[2] = lacpTLVMarkerInformation
+	if m.Response {
+		bytes[2] = lacpTLVMarkerResponse
+	}
+	bytes[3] = 16
+	binary.BigEndian.PutUint16(bytes[4:6], m.RequesterPort)
+	copy(bytes[6:12], m.RequesterSystem)
+	binary.BigEndian.PutUint32(bytes[12:16], m.RequesterTransactionID)
+	return nil
+}
+
+func decodeLACPMarker(data []byte, p gopacket.PacketBuilder) error {
+	m := &LACPMarker{}
+	return decodingLayerDecoder(m, data, p)
+}
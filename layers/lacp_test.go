@@ -0,0 +1,152 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketLACPDU is an LACPDU from an active, short timeout actor whose
+// partner has expired.
+var testPacketLACPDU = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x02, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x88, 0x09, 0x01, 0x01,
+	0x01, 0x14, 0x80, 0x00, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x0d, 0x00, 0xff, 0x00, 0x05,
+	0x3d, 0x00, 0x00, 0x00, 0x02, 0x14, 0x80, 0x00, 0x00, 0x66, 0x77, 0x88, 0x99, 0xaa, 0x00, 0x21,
+	0x00, 0xff, 0x00, 0x02, 0x8f, 0x00, 0x00, 0x00, 0x03, 0x10, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestPacketLACPDU(t *testing.T) {
+	p := gopacket.NewPacket(testPacketLACPDU, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLACP}, t)
+	lacp, ok := p.Layer(LayerTypeLACP).(*LACP)
+	if !ok {
+		t.Fatal("No LACP layer")
+	}
+	want := &LACP{
+		BaseLayer: BaseLayer{Contents: testPacketLACPDU[14:]},
+		Version:   1,
+		Actor: LACPInfo{
+			SystemPriority: 0x8000,
+			System:         net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+			Key:            13,
+			PortPriority:   255,
+			Port:           5,
+			State: LACPState{
+				Activity:        true,
+				Aggregation:     true,
+				Synchronization: true,
+				Collecting:      true,
+				Distributing:    true,
+			},
+		},
+		Partner: LACPInfo{
+			SystemPriority: 0x8000,
+			System:         net.HardwareAddr{0x00, 0x66, 0x77, 0x88, 0x99, 0xaa},
+			Key:            33,
+			PortPriority:   255,
+			Port:           2,
+			State: LACPState{
+				Activity:        true,
+				Timeout:         true,
+				Aggregation:     true,
+				Synchronization: true,
+				Expired:         true,
+			},
+		},
+		CollectorMaxDelay: 5,
+	}
+	if !reflect.DeepEqual(want, lacp) {
+		t.Errorf("LACP mismatch, \nwant %#v\ngot  %#v\n", want, lacp)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := lacp.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketLACPDU[14:]) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", testPacketLACPDU[14:], buf.Bytes())
+	}
+}
+
+// testPacketLACPMarker is a Marker Information PDU.
+var testPacketLACPMarker = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x02, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x88, 0x09, 0x02, 0x01,
+	0x01, 0x10, 0x00, 0x05, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x00, 0x12, 0x34, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestPacketLACPMarker(t *testing.T) {
+	p := gopacket.NewPacket(testPacketLACPMarker, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLACPMarker}, t)
+	m, ok := p.Layer(LayerTypeLACPMarker).(*LACPMarker)
+	if !ok {
+		t.Fatal("No LACPMarker layer")
+	}
+	want := &LACPMarker{
+		BaseLayer:              BaseLayer{Contents: testPacketLACPMarker[14:]},
+		Version:                1,
+		RequesterPort:          5,
+		RequesterSystem:        net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		RequesterTransactionID: 0x1234,
+	}
+	if !reflect.DeepEqual(want, m) {
+		t.Errorf("Marker mismatch, \nwant %#v\ngot  %#v\n", want, m)
+	}
+
+	// Answer it, which only changes the TLV type.
+	m.Response = true
+	buf := gopacket.NewSerializeBuffer()
+	if err := m.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	wantBytes := append([]byte(nil), testPacketLACPMarker[14:]...)
+	wantBytes[2] = 2
+	if !bytes.Equal(buf.Bytes(), wantBytes) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", wantBytes, buf.Bytes())
+	}
+}
+
+func TestLACPMalformed(t *testing.T) {
+	pdu := testPacketLACPDU[14:]
+	l := &LACP{}
+	if err := l.DecodeFromBytes(pdu[:50], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated LACPDU")
+	}
+	for _, offset := range []int{2, 3, 22, 43, 58} {
+		data := append([]byte(nil), pdu...)
+		data[offset]++
+		if err := l.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("expected error with byte %d corrupted", offset)
+		}
+	}
+	m := &LACPMarker{}
+	data := append([]byte(nil), testPacketLACPMarker[14:]...)
+	data[2] = 3
+	if err := m.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding unknown Marker TLV")
+	}
+}
@@ -145,6 +145,64 @@ var (
 	LayerTypeModbusTCP                    = gopacket.RegisterLayerType(141, gopacket.LayerTypeMetadata{Name: "ModbusTCP", Decoder: gopacket.DecodeFunc(decodeModbusTCP)})
   LayerTypeENIP                         = gopacket.RegisterLayerType(142, gopacket.LayerTypeMetadata{Name: "Ethernet/IP", Decoder: gopacket.DecodeFunc(decodeENIP)})
 	LayerTypeCIP                          = gopacket.RegisterLayerType(143, gopacket.LayerTypeMetadata{Name: "CIP", Decoder: gopacket.DecodeFunc(decodeCIP)})
+	LayerTypeDot15d4                      = gopacket.RegisterLayerType(144, gopacket.LayerTypeMetadata{Name: "Dot15d4", Decoder: gopacket.DecodeFunc(decodeDot15d4)})
+	LayerTypeLoWPAN                       = gopacket.RegisterLayerType(145, gopacket.LayerTypeMetadata{Name: "6LoWPAN", Decoder: gopacket.DecodeFunc(decodeLoWPAN)})
+	LayerTypeZigbeeNWK                    = gopacket.RegisterLayerType(146, gopacket.LayerTypeMetadata{Name: "ZigbeeNWK", Decoder: gopacket.DecodeFunc(decodeZigbeeNWK)})
+	LayerTypeICMPExtendedEchoRequest      = gopacket.RegisterLayerType(147, gopacket.LayerTypeMetadata{Name: "ICMPExtendedEchoRequest", Decoder: gopacket.DecodeFunc(decodeICMPExtendedEchoRequest)})
+	LayerTypeICMPExtendedEchoReply        = gopacket.RegisterLayerType(148, gopacket.LayerTypeMetadata{Name: "ICMPExtendedEchoReply", Decoder: gopacket.DecodeFunc(decodeICMPExtendedEchoReply)})
+	LayerTypeMRP                          = gopacket.RegisterLayerType(149, gopacket.LayerTypeMetadata{Name: "MRP", Decoder: gopacket.DecodeFunc(decodeMRP)})
+	LayerTypeGVRP                         = gopacket.RegisterLayerType(150, gopacket.LayerTypeMetadata{Name: "GVRP", Decoder: gopacket.DecodeFunc(decodeGVRP)})
+	LayerTypeEthernetOAM                  = gopacket.RegisterLayerType(151, gopacket.LayerTypeMetadata{Name: "EthernetOAM", Decoder: gopacket.DecodeFunc(decodeEthernetOAM)})
+	LayerTypeCFM                          = gopacket.RegisterLayerType(152, gopacket.LayerTypeMetadata{Name: "CFM", Decoder: gopacket.DecodeFunc(decodeCFM)})
+	LayerTypeESMC                         = gopacket.RegisterLayerType(153, gopacket.LayerTypeMetadata{Name: "ESMC", Decoder: gopacket.DecodeFunc(decodeESMC)})
+	LayerTypeCiscoHDLC                    = gopacket.RegisterLayerType(154, gopacket.LayerTypeMetadata{Name: "CiscoHDLC", Decoder: gopacket.DecodeFunc(decodeCiscoHDLC)})
+	LayerTypeSLARP                        = gopacket.RegisterLayerType(155, gopacket.LayerTypeMetadata{Name: "SLARP", Decoder: gopacket.DecodeFunc(decodeSLARP)})
+	LayerTypeFrameRelay                   = gopacket.RegisterLayerType(156, gopacket.LayerTypeMetadata{Name: "FrameRelay", Decoder: gopacket.DecodeFunc(decodeFrameRelay)})
+	LayerTypeLCP                          = gopacket.RegisterLayerType(157, gopacket.LayerTypeMetadata{Name: "LCP", Decoder: gopacket.DecodeFunc(decodeLCP)})
+	LayerTypeIPCP                         = gopacket.RegisterLayerType(158, gopacket.LayerTypeMetadata{Name: "IPCP", Decoder: gopacket.DecodeFunc(decodeIPCP)})
+	LayerTypeERSPANII                     = gopacket.RegisterLayerType(159, gopacket.LayerTypeMetadata{Name: "ERSPANII", Decoder: gopacket.DecodeFunc(decodeERSPANII)})
+	LayerTypeERSPANIII                    = gopacket.RegisterLayerType(160, gopacket.LayerTypeMetadata{Name: "ERSPANIII", Decoder: gopacket.DecodeFunc(decodeERSPANIII)})
+	LayerTypeRADIUS                       = gopacket.RegisterLayerType(161, gopacket.LayerTypeMetadata{Name: "RADIUS", Decoder: gopacket.DecodeFunc(decodeRADIUS)})
+	LayerTypeLACP                         = gopacket.RegisterLayerType(162, gopacket.LayerTypeMetadata{Name: "LACP", Decoder: gopacket.DecodeFunc(decodeLACP)})
+	LayerTypeLACPMarker                   = gopacket.RegisterLayerType(163, gopacket.LayerTypeMetadata{Name: "LACPMarker", Decoder: gopacket.DecodeFunc(decodeLACPMarker)})
+	LayerTypeBGP                          = gopacket.RegisterLayerType(164, gopacket.LayerTypeMetadata{Name: "BGP", Decoder: gopacket.DecodeFunc(decodeBGP)})
+	LayerTypeNSH                          = gopacket.RegisterLayerType(165, gopacket.LayerTypeMetadata{Name: "NSH", Decoder: gopacket.DecodeFunc(decodeNSH)})
+	LayerTypeMPLSStack                    = gopacket.RegisterLayerType(166, gopacket.LayerTypeMetadata{Name: "MPLSStack", Decoder: gopacket.DecodeFunc(decodeMPLSStack)})
+	LayerTypeIPv6CP                       = gopacket.RegisterLayerType(167, gopacket.LayerTypeMetadata{Name: "IPv6CP", Decoder: gopacket.DecodeFunc(decodeIPv6CP)})
+	LayerTypePPPPAP                       = gopacket.RegisterLayerType(168, gopacket.LayerTypeMetadata{Name: "PAP", Decoder: gopacket.DecodeFunc(decodePPPPAP)})
+	LayerTypePPPCHAP                      = gopacket.RegisterLayerType(169, gopacket.LayerTypeMetadata{Name: "CHAP", Decoder: gopacket.DecodeFunc(decodePPPCHAP)})
+	LayerTypeIKE                          = gopacket.RegisterLayerType(170, gopacket.LayerTypeMetadata{Name: "IKE", Decoder: gopacket.DecodeFunc(decodeIKE)})
+	LayerTypeIPSecNATT                    = gopacket.RegisterLayerType(171, gopacket.LayerTypeMetadata{Name: "IPSecNATT", Decoder: gopacket.DecodeFunc(decodeIPSecNATT)})
+	LayerTypeRTP                          = gopacket.RegisterLayerType(172, gopacket.LayerTypeMetadata{Name: "RTP", Decoder: gopacket.DecodeFunc(decodeRTP)})
+	LayerTypeRTCP                         = gopacket.RegisterLayerType(173, gopacket.LayerTypeMetadata{Name: "RTCP", Decoder: gopacket.DecodeFunc(decodeRTCP)})
+	LayerTypeNetFlowV5                    = gopacket.RegisterLayerType(174, gopacket.LayerTypeMetadata{Name: "NetFlowV5", Decoder: gopacket.DecodeFunc(decodeNetFlow)})
+	LayerTypeNetFlowV9                    = gopacket.RegisterLayerType(175, gopacket.LayerTypeMetadata{Name: "NetFlowV9", Decoder: gopacket.DecodeFunc(decodeNetFlow)})
+	LayerTypeIPFIX                        = gopacket.RegisterLayerType(176, gopacket.LayerTypeMetadata{Name: "IPFIX", Decoder: gopacket.DecodeFunc(decodeNetFlow)})
+	LayerTypeMACsec                       = gopacket.RegisterLayerType(177, gopacket.LayerTypeMetadata{Name: "MACsec", Decoder: gopacket.DecodeFunc(decodeMACsec)})
+	LayerTypeEAPOLMKA                     = gopacket.RegisterLayerType(178, gopacket.LayerTypeMetadata{Name: "EAPOLMKA", Decoder: gopacket.DecodeFunc(decodeEAPOLMKA)})
+	LayerTypeLinuxSLL2                    = gopacket.RegisterLayerType(179, gopacket.LayerTypeMetadata{Name: "Linux SLL2", Decoder: gopacket.DecodeFunc(decodeLinuxSLL2)})
+	LayerTypeCAN                          = gopacket.RegisterLayerType(180, gopacket.LayerTypeMetadata{Name: "CAN", Decoder: gopacket.DecodeFunc(decodeCAN)})
+	LayerTypeBluetoothHCIH4               = gopacket.RegisterLayerType(181, gopacket.LayerTypeMetadata{Name: "BluetoothHCIH4", Decoder: gopacket.DecodeFunc(decodeBluetoothHCIH4)})
+	LayerTypeBluetoothHCICommand          = gopacket.RegisterLayerType(182, gopacket.LayerTypeMetadata{Name: "BluetoothHCICommand", Decoder: gopacket.DecodeFunc(decodeBluetoothHCICommand)})
+	LayerTypeBluetoothHCIEvent            = gopacket.RegisterLayerType(183, gopacket.LayerTypeMetadata{Name: "BluetoothHCIEvent", Decoder: gopacket.DecodeFunc(decodeBluetoothHCIEvent)})
+	LayerTypeBluetoothHCIACL              = gopacket.RegisterLayerType(184, gopacket.LayerTypeMetadata{Name: "BluetoothHCIACL", Decoder: gopacket.DecodeFunc(decodeBluetoothHCIACL)})
+	LayerTypeBluetoothL2CAP               = gopacket.RegisterLayerType(185, gopacket.LayerTypeMetadata{Name: "BluetoothL2CAP", Decoder: gopacket.DecodeFunc(decodeBluetoothL2CAP)})
+	LayerTypeBluetoothATT                 = gopacket.RegisterLayerType(186, gopacket.LayerTypeMetadata{Name: "BluetoothATT", Decoder: gopacket.DecodeFunc(decodeBluetoothATT)})
+	LayerTypeDNSOverTCP                   = gopacket.RegisterLayerType(187, gopacket.LayerTypeMetadata{Name: "DNSOverTCP", Decoder: gopacket.DecodeFunc(decodeDNSOverTCP)})
+	LayerTypeSNMP                         = gopacket.RegisterLayerType(188, gopacket.LayerTypeMetadata{Name: "SNMP", Decoder: gopacket.DecodeFunc(decodeSNMP)})
+	LayerTypeSyslog                       = gopacket.RegisterLayerType(189, gopacket.LayerTypeMetadata{Name: "Syslog", Decoder: gopacket.DecodeFunc(decodeSyslog)})
+	LayerTypeQUIC                         = gopacket.RegisterLayerType(190, gopacket.LayerTypeMetadata{Name: "QUIC", Decoder: gopacket.DecodeFunc(decodeQUIC)})
+	LayerTypeOpenFlow                     = gopacket.RegisterLayerType(191, gopacket.LayerTypeMetadata{Name: "OpenFlow", Decoder: gopacket.DecodeFunc(decodeOpenFlow)})
+	LayerTypePIM                          = gopacket.RegisterLayerType(192, gopacket.LayerTypeMetadata{Name: "PIM", Decoder: gopacket.DecodeFunc(decodePIM)})
+	LayerTypeISIS                         = gopacket.RegisterLayerType(193, gopacket.LayerTypeMetadata{Name: "ISIS", Decoder: gopacket.DecodeFunc(decodeISIS)})
+	LayerTypeRIP                          = gopacket.RegisterLayerType(194, gopacket.LayerTypeMetadata{Name: "RIP", Decoder: gopacket.DecodeFunc(decodeRIP)})
+	LayerTypeRIPng                        = gopacket.RegisterLayerType(195, gopacket.LayerTypeMetadata{Name: "RIPng", Decoder: gopacket.DecodeFunc(decodeRIPng)})
+	LayerTypeHSRP                         = gopacket.RegisterLayerType(196, gopacket.LayerTypeMetadata{Name: "HSRP", Decoder: gopacket.DecodeFunc(decodeHSRP)})
+	LayerTypeGLBP                         = gopacket.RegisterLayerType(197, gopacket.LayerTypeMetadata{Name: "GLBP", Decoder: gopacket.DecodeFunc(decodeGLBP)})
+	LayerTypeBVLC                         = gopacket.RegisterLayerType(198, gopacket.LayerTypeMetadata{Name: "BVLC", Decoder: gopacket.DecodeFunc(decodeBVLC)})
+	LayerTypeBACnetNPDU                   = gopacket.RegisterLayerType(199, gopacket.LayerTypeMetadata{Name: "BACnetNPDU", Decoder: gopacket.DecodeFunc(decodeBACnetNPDU)})
+	LayerTypeBACnetAPDU                   = gopacket.RegisterLayerType(200, gopacket.LayerTypeMetadata{Name: "BACnetAPDU", Decoder: gopacket.DecodeFunc(decodeBACnetAPDU)})
+	LayerTypeEtherCAT                     = gopacket.RegisterLayerType(201, gopacket.LayerTypeMetadata{Name: "EtherCAT", Decoder: gopacket.DecodeFunc(decodeEtherCAT)})
 )
 
 var (
@@ -0,0 +1,109 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// LinuxSLL2 is the header of the Linux cooked capture v2 link type, used by
+// libpcap for captures on the "any" device.  Unlike LinuxSLL, it records
+// the interface a packet was seen on.
+type LinuxSLL2 struct {
+	BaseLayer
+	ProtocolType EthernetType
+	// Reserved must be zero.
+	Reserved       uint16
+	InterfaceIndex uint32
+	// ARPHRDType is the Linux ARPHRD_ hardware type of the interface, for
+	// example 1 for Ethernet or 772 for loopback.
+	ARPHRDType uint16
+	PacketType LinuxSLLPacketType
+	AddrLen    uint8
+	// Addr is the link-layer source address, AddrLen bytes long but at most
+	// 8 bytes.
+	Addr net.HardwareAddr
+}
+
+// LayerType returns LayerTypeLinuxSLL2.
+func (sll *LinuxSLL2) LayerType() gopacket.LayerType { return LayerTypeLinuxSLL2 }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (sll *LinuxSLL2) CanDecode() gopacket.LayerClass {
+	return LayerTypeLinuxSLL2
+}
+
+func (sll *LinuxSLL2) LinkFlow() gopacket.Flow {
+	return gopacket.NewFlow(EndpointMAC, sll.Addr, nil)
+}
+
+// NextLayerType returns the layer type given by ProtocolType.
+func (sll *LinuxSLL2) NextLayerType() gopacket.LayerType {
+	return sll.ProtocolType.LayerType()
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (sll *LinuxSLL2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 20 {
+		df.SetTruncated()
+		return errors.New("Linux SLL2 packet too small")
+	}
+	sll.ProtocolType = EthernetType(binary.BigEndian.Uint16(data[0:2]))
+	sll.Reserved = binary.BigEndian.Uint16(data[2:4])
+	sll.InterfaceIndex = binary.BigEndian.Uint32(data[4:8])
+	sll.ARPHRDType = binary.BigEndian.Uint16(data[8:10])
+	sll.PacketType = LinuxSLLPacketType(data[10])
+	sll.AddrLen = data[11]
+	if sll.AddrLen > 8 {
+		return fmt.Errorf("invalid Linux SLL2 address length %d", sll.AddrLen)
+	}
+	sll.Addr = net.HardwareAddr(data[12 : 12+sll.AddrLen])
+	sll.BaseLayer = BaseLayer{data[:20], data[20:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (sll *LinuxSLL2) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(sll.Addr) > 8 {
+		return fmt.Errorf("Linux SLL2 address of %d bytes too long", len(sll.Addr))
+	}
+	bytes, err := b.PrependBytes(20)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		sll.AddrLen = uint8(len(sll.Addr))
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], uint16(sll.ProtocolType))
+	binary.BigEndian.PutUint16(bytes[2:4], sll.Reserved)
+	binary.BigEndian.PutUint32(bytes[4:8], sll.InterfaceIndex)
+	binary.BigEndian.PutUint16(bytes[8:10], sll.ARPHRDType)
+	bytes[10] = uint8(sll.PacketType)
+	bytes[11] = sll.AddrLen
+	copy(bytes[12:20], sll.Addr)
+	for i := 12 + len(sll.Addr); i < 20; i++ {
+		bytes[i] = 0
+	}
+	return nil
+}
+
+func decodeLinuxSLL2(data []byte, p gopacket.PacketBuilder) error {
+	sll := &LinuxSLL2{}
+	if err := sll.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(sll)
+	p.SetLinkLayer(sll)
+	return p.NextDecoder(sll.ProtocolType)
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketLinuxSLL2 is an ICMP echo request from 127.0.0.1 to itself, id
+// 7211 and seq 1, captured on the way out of the loopback interface by
+// tcpdump -i any.
+var testPacketLinuxSLL2 = []byte{
+	0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x03, 0x04, 0x04, 0x06,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x45, 0x00, 0x00, 0x2c,
+	0x6b, 0x5e, 0x40, 0x00, 0x40, 0x01, 0xd1, 0x70, 0x7f, 0x00, 0x00, 0x01,
+	0x7f, 0x00, 0x00, 0x01, 0x08, 0x00, 0x23, 0x13, 0x1c, 0x2b, 0x00, 0x01,
+	0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+	0x1c, 0x1d, 0x1e, 0x1f,
+}
+
+func TestPacketLinuxSLL2(t *testing.T) {
+	p := gopacket.NewPacket(testPacketLinuxSLL2, LinkTypeLinuxSLL2, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeLinuxSLL2, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+	sll := p.LinkLayer().(*LinuxSLL2)
+	if sll.ProtocolType != EthernetTypeIPv4 || sll.InterfaceIndex != 1 || sll.ARPHRDType != 772 {
+		t.Errorf("bad header %+v", sll)
+	}
+	if sll.PacketType != LinuxSLLPacketTypeOutgoing || sll.AddrLen != 6 || len(sll.Addr) != 6 {
+		t.Errorf("bad packet type or address %+v", sll)
+	}
+	if LinkTypeLinuxSLL2.LayerType() != LayerTypeLinuxSLL2 {
+		t.Errorf("link type %v has layer type %v", LinkTypeLinuxSLL2, LinkTypeLinuxSLL2.LayerType())
+	}
+	testSerialization(t, p, testPacketLinuxSLL2)
+}
+
+func TestLinuxSLL2Malformed(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"short header", testPacketLinuxSLL2[:19]},
+		{"address too long", append([]byte{0x08, 0x00, 0, 0, 0, 0, 0, 1, 0, 1, 0, 9}, make([]byte, 16)...)},
+	} {
+		var sll LinuxSLL2
+		if err := sll.DecodeFromBytes(c.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
@@ -63,7 +63,15 @@ func (l *LLC) NextLayerType() gopacket.LayerType {
 	case l.DSAP == 0xAA && l.SSAP == 0xAA:
 		return LayerTypeSNAP
 	case l.DSAP == 0x42 && l.SSAP == 0x42:
+		if isGARP(l.Payload) {
+			return LayerTypeGVRP
+		}
 		return LayerTypeSTP
+	case l.DSAP == 0xFE && l.SSAP == 0xFE:
+		// OSI network layer PDUs, led by their protocol identifier.
+		if len(l.Payload) > 0 && l.Payload[0] == isisIRPD {
+			return LayerTypeISIS
+		}
 	}
 	return gopacket.LayerTypeZero // Not implemented
 }
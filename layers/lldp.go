@@ -7,9 +7,15 @@
 package layers
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/google/gopacket"
 )
@@ -35,10 +41,32 @@ type LinkLayerDiscoveryValue struct {
 	Type   LLDPTLVType
 	Length uint16
 	Value  []byte
+	Offset int // Offset of the TLV's header in the layer's contents, when decoded
 }
 
-func (c *LinkLayerDiscoveryValue) len() int {
-	return 0
+// lldpMaxTLVLength is the longest TLV value, as its length takes 9 bits.
+const lldpMaxTLVLength = 511
+
+// len returns the length of the TLV's value: Length, or the length of Value
+// if Length is zero.
+func (c *LinkLayerDiscoveryValue) len() (int, error) {
+	length := int(c.Length)
+	if length == 0 {
+		length = len(c.Value)
+	}
+	if length != len(c.Value) {
+		return 0, fmt.Errorf("LLDP TLV %v length %d doesn't match its %d byte value", c.Type, length, len(c.Value))
+	}
+	if length > lldpMaxTLVLength {
+		return 0, fmt.Errorf("LLDP TLV %v value of %d bytes exceeds %d", c.Type, length, lldpMaxTLVLength)
+	}
+	return length, nil
+}
+
+// serialize writes the TLV to buf, which must hold its value and header.
+func (c *LinkLayerDiscoveryValue) serialize(buf []byte, length int) {
+	binary.BigEndian.PutUint16(buf, uint16(c.Type)<<9|uint16(length))
+	copy(buf[2:], c.Value)
 }
 
 // LLDPChassisIDSubType specifies the value type for a single LLDPChassisID.ID
@@ -75,6 +103,24 @@ func (c *LLDPChassisID) serializedLen() int {
 	return len(c.ID) + 3 // +2 for id and length, +1 for subtype
 }
 
+// NetworkAddress returns the address family and IP address of a chassis ID
+// with the network address subtype.
+func (c LLDPChassisID) NetworkAddress() (IANAAddressFamily, net.IP, error) {
+	if c.Subtype != LLDPChassisIDSubTypeNetworkAddr {
+		return 0, nil, fmt.Errorf("LLDP chassis ID subtype %v is not a network address", c.Subtype)
+	}
+	return lldpNetworkAddress(c.ID)
+}
+
+// HardwareAddr returns the MAC address of a chassis ID with the MAC address
+// subtype.
+func (c LLDPChassisID) HardwareAddr() (net.HardwareAddr, error) {
+	if c.Subtype != LLDPChassisIDSubTypeMACAddr {
+		return nil, fmt.Errorf("LLDP chassis ID subtype %v is not a MAC address", c.Subtype)
+	}
+	return lldpHardwareAddr(c.ID)
+}
+
 // LLDPPortIDSubType specifies the value type for a single LLDPPortID.ID
 type LLDPPortIDSubType byte
 
@@ -109,6 +155,53 @@ func (c *LLDPPortID) serializedLen() int {
 	return len(c.ID) + 3 // +2 for id and length, +1 for subtype
 }
 
+// NetworkAddress returns the address family and IP address of a port ID with
+// the network address subtype.
+func (c LLDPPortID) NetworkAddress() (IANAAddressFamily, net.IP, error) {
+	if c.Subtype != LLDPPortIDSubtypeNetworkAddr {
+		return 0, nil, fmt.Errorf("LLDP port ID subtype %v is not a network address", c.Subtype)
+	}
+	return lldpNetworkAddress(c.ID)
+}
+
+// HardwareAddr returns the MAC address of a port ID with the MAC address
+// subtype.
+func (c LLDPPortID) HardwareAddr() (net.HardwareAddr, error) {
+	if c.Subtype != LLDPPortIDSubtypeMACAddr {
+		return nil, fmt.Errorf("LLDP port ID subtype %v is not a MAC address", c.Subtype)
+	}
+	return lldpHardwareAddr(c.ID)
+}
+
+// lldpNetworkAddress decodes a network address ID: an IANA address family
+// followed by the address.
+func lldpNetworkAddress(id []byte) (IANAAddressFamily, net.IP, error) {
+	if len(id) == 0 {
+		return 0, nil, errors.New("Empty LLDP network address")
+	}
+	family, addr := IANAAddressFamily(id[0]), id[1:]
+	var want int
+	switch family {
+	case IANAAddressFamilyIPV4:
+		want = net.IPv4len
+	case IANAAddressFamilyIPV6:
+		want = net.IPv6len
+	default:
+		return family, nil, fmt.Errorf("Unsupported LLDP network address family %v", family)
+	}
+	if len(addr) != want {
+		return family, nil, fmt.Errorf("Invalid LLDP %v address length %d", family, len(addr))
+	}
+	return family, net.IP(addr), nil
+}
+
+func lldpHardwareAddr(id []byte) (net.HardwareAddr, error) {
+	if len(id) != 6 {
+		return nil, fmt.Errorf("Invalid LLDP MAC address length %d", len(id))
+	}
+	return net.HardwareAddr(id), nil
+}
+
 // LinkLayerDiscovery is a packet layer containing the LinkLayer Discovery Protocol.
 // See http:http://standards.ieee.org/getieee802/download/802.1AB-2009.pdf
 // ChassisID, PortID and TTL are mandatory TLV's. Other values can be decoded
@@ -119,6 +212,15 @@ type LinkLayerDiscovery struct {
 	PortID    LLDPPortID
 	TTL       uint16
 	Values    []LinkLayerDiscoveryValue
+
+	// Offsets of the headers of the mandatory TLVs in Contents, when decoded.
+	ChassisIDOffset int
+	PortIDOffset    int
+	TTLOffset       int
+
+	// MissingEnd is set if the LLDPDU ended without an End TLV, which is
+	// tolerated when decoding but reported by Validate.
+	MissingEnd bool
 }
 
 type IEEEOUI uint32
@@ -177,6 +279,14 @@ type LLDPSysCapabilities struct {
 	EnabledCap LLDPCapabilities
 }
 
+// Encode returns the value of a System Capabilities TLV for c.
+func (c LLDPSysCapabilities) Encode() []byte {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint16(value[0:2], c.SystemCap.ToUint16())
+	binary.BigEndian.PutUint16(value[2:4], c.EnabledCap.ToUint16())
+	return value
+}
+
 type IANAAddressFamily byte
 
 // LLDP Management Address Subtypes
@@ -224,7 +334,22 @@ type LLDPMgmtAddress struct {
 	Address          []byte
 	InterfaceSubtype LLDPInterfaceSubtype
 	InterfaceNumber  uint32
-	OID              string
+	OID              string // BER encoded contents of the object identifier, if any
+}
+
+// Encode returns the value of a Management Address TLV for m.  802.1AB limits
+// the address to 31 bytes and the OID to 128 bytes.
+func (m LLDPMgmtAddress) Encode() []byte {
+	mlen := len(m.Address) + 1
+	value := make([]byte, mlen+7+len(m.OID))
+	value[0] = byte(mlen)
+	value[1] = byte(m.Subtype)
+	copy(value[2:], m.Address)
+	value[mlen+1] = byte(m.InterfaceSubtype)
+	binary.BigEndian.PutUint32(value[mlen+2:mlen+6], m.InterfaceNumber)
+	value[mlen+6] = byte(len(m.OID))
+	copy(value[mlen+7:], m.OID)
+	return value
 }
 
 // LinkLayerDiscoveryInfo represents the decoded details for a set of LinkLayerDiscoveryValues
@@ -238,6 +363,8 @@ type LinkLayerDiscoveryInfo struct {
 	MgmtAddress     LLDPMgmtAddress
 	OrgTLVs         []LLDPOrgSpecificTLV      // Private TLVs
 	Unknown         []LinkLayerDiscoveryValue // undecoded TLVs
+
+	lldp LinkLayerDiscovery // Used by DecodeFromBytes
 }
 
 /// IEEE 802.1 TLV Subtypes
@@ -249,6 +376,13 @@ const (
 	LLDP8021SubtypeVDIUsageDigest   uint8 = 5
 	LLDP8021SubtypeManagementVID    uint8 = 6
 	LLDP8021SubtypeLinkAggregation  uint8 = 7
+	LLDP8021SubtypeCN               uint8 = 8
+	LLDP8021SubtypeETSConfig        uint8 = 9
+	LLDP8021SubtypeETSRecommend     uint8 = 0xa
+	LLDP8021SubtypePFCConfig        uint8 = 0xb
+	LLDP8021SubtypeAppPriority      uint8 = 0xc
+	LLDP8021SubtypeCDCP             uint8 = 0xe
+	LLDP8021SubtypePortExtension    uint8 = 0xf
 )
 
 // VLAN Port Protocol ID options
@@ -284,6 +418,57 @@ type LLDPLinkAggregation struct {
 }
 
 // LLDPInfo8021 represents the information carried in 802.1 Org-specific TLVs
+// LLDPETSTables are the tables of ETS Configuration and Recommendation TLVs.
+// Each is indexed by priority or traffic class.
+type LLDPETSTables struct {
+	PriorityAssignment [8]uint8 // Traffic class of each priority
+	TCBandwidth        [8]uint8 // Percentage of bandwidth of each traffic class
+	TSAAssignment      [8]uint8 // Transmission selection algorithm of each traffic class
+}
+
+// LLDPETSConfiguration represents an 802.1Qaz ETS Configuration TLV.
+type LLDPETSConfiguration struct {
+	Willing bool
+	CBS     bool  // Credit-based shaper supported
+	MaxTCs  uint8 // 0 means 8
+	LLDPETSTables
+}
+
+// LLDPETSRecommendation represents an 802.1Qaz ETS Recommendation TLV.
+type LLDPETSRecommendation struct {
+	LLDPETSTables
+}
+
+// LLDPPFCConfiguration represents an 802.1Qaz Priority-based Flow Control
+// Configuration TLV.
+type LLDPPFCConfiguration struct {
+	Willing    bool
+	MBC        bool  // MACsec bypass capability
+	Capability uint8 // Number of traffic classes that may enable PFC
+	Enabled    uint8 // Bitmap of priorities with PFC enabled
+}
+
+// LLDPApplicationPriority is an entry of an 802.1Qaz Application Priority
+// TLV: the priority to use for a protocol.
+type LLDPApplicationPriority struct {
+	Priority uint8
+	Selector uint8 // How Protocol is interpreted, e.g. 1 for an EtherType
+	Protocol uint16
+}
+
+// LLDPCongestionNotification represents an 802.1Qau Congestion Notification
+// TLV.  Both fields are bitmaps indexed by priority.
+type LLDPCongestionNotification struct {
+	CNPV  uint8 // Priorities that are congestion notification priority values
+	Ready uint8 // Priorities ready for congestion notification
+}
+
+// LLDPPortExtension represents an 802.1BR Port Extension TLV.
+type LLDPPortExtension struct {
+	PortExtender bool   // Set if sent by a Port Extender, clear for a Controlling Bridge
+	ECID         uint16 // E-channel ID of the sending port
+}
+
 type LLDPInfo8021 struct {
 	PVID               uint16
 	PPVIDs             []PortProtocolVLANID
@@ -292,6 +477,19 @@ type LLDPInfo8021 struct {
 	VIDUsageDigest     uint32
 	ManagementVID      uint16
 	LinkAggregation    LLDPLinkAggregation
+
+	// 802.1Qaz Data Center Bridging
+	ETSConfiguration      LLDPETSConfiguration
+	ETSRecommendation     LLDPETSRecommendation
+	PFCConfiguration      LLDPPFCConfiguration
+	ApplicationPriorities []LLDPApplicationPriority
+
+	// 802.1Qau Congestion Notification
+	CongestionNotification LLDPCongestionNotification
+
+	// 802.1BR Port Extension and 802.1Qbg S-channels
+	PortExtension LLDPPortExtension
+	CDCP          LLDPCDCP
 }
 
 // IEEE 802.3 TLV Subtypes
@@ -308,78 +506,81 @@ const (
 	LLDPMACPHYStatus     byte = 1 << 1
 )
 
+// LLDPMAUType is the dot3MauType of the MAC/PHY Configuration/Status TLV.
+type LLDPMAUType uint16
+
 // From IANA-MAU-MIB (introduced by RFC 4836) - dot3MauType
 const (
-	LLDPMAUTypeUnknown         uint16 = 0
-	LLDPMAUTypeAUI             uint16 = 1
-	LLDPMAUType10Base5         uint16 = 2
-	LLDPMAUTypeFOIRL           uint16 = 3
-	LLDPMAUType10Base2         uint16 = 4
-	LLDPMAUType10BaseT         uint16 = 5
-	LLDPMAUType10BaseFP        uint16 = 6
-	LLDPMAUType10BaseFB        uint16 = 7
-	LLDPMAUType10BaseFL        uint16 = 8
-	LLDPMAUType10BROAD36       uint16 = 9
-	LLDPMAUType10BaseT_HD      uint16 = 10
-	LLDPMAUType10BaseT_FD      uint16 = 11
-	LLDPMAUType10BaseFL_HD     uint16 = 12
-	LLDPMAUType10BaseFL_FD     uint16 = 13
-	LLDPMAUType100BaseT4       uint16 = 14
-	LLDPMAUType100BaseTX_HD    uint16 = 15
-	LLDPMAUType100BaseTX_FD    uint16 = 16
-	LLDPMAUType100BaseFX_HD    uint16 = 17
-	LLDPMAUType100BaseFX_FD    uint16 = 18
-	LLDPMAUType100BaseT2_HD    uint16 = 19
-	LLDPMAUType100BaseT2_FD    uint16 = 20
-	LLDPMAUType1000BaseX_HD    uint16 = 21
-	LLDPMAUType1000BaseX_FD    uint16 = 22
-	LLDPMAUType1000BaseLX_HD   uint16 = 23
-	LLDPMAUType1000BaseLX_FD   uint16 = 24
-	LLDPMAUType1000BaseSX_HD   uint16 = 25
-	LLDPMAUType1000BaseSX_FD   uint16 = 26
-	LLDPMAUType1000BaseCX_HD   uint16 = 27
-	LLDPMAUType1000BaseCX_FD   uint16 = 28
-	LLDPMAUType1000BaseT_HD    uint16 = 29
-	LLDPMAUType1000BaseT_FD    uint16 = 30
-	LLDPMAUType10GBaseX        uint16 = 31
-	LLDPMAUType10GBaseLX4      uint16 = 32
-	LLDPMAUType10GBaseR        uint16 = 33
-	LLDPMAUType10GBaseER       uint16 = 34
-	LLDPMAUType10GBaseLR       uint16 = 35
-	LLDPMAUType10GBaseSR       uint16 = 36
-	LLDPMAUType10GBaseW        uint16 = 37
-	LLDPMAUType10GBaseEW       uint16 = 38
-	LLDPMAUType10GBaseLW       uint16 = 39
-	LLDPMAUType10GBaseSW       uint16 = 40
-	LLDPMAUType10GBaseCX4      uint16 = 41
-	LLDPMAUType2BaseTL         uint16 = 42
-	LLDPMAUType10PASS_TS       uint16 = 43
-	LLDPMAUType100BaseBX10D    uint16 = 44
-	LLDPMAUType100BaseBX10U    uint16 = 45
-	LLDPMAUType100BaseLX10     uint16 = 46
-	LLDPMAUType1000BaseBX10D   uint16 = 47
-	LLDPMAUType1000BaseBX10U   uint16 = 48
-	LLDPMAUType1000BaseLX10    uint16 = 49
-	LLDPMAUType1000BasePX10D   uint16 = 50
-	LLDPMAUType1000BasePX10U   uint16 = 51
-	LLDPMAUType1000BasePX20D   uint16 = 52
-	LLDPMAUType1000BasePX20U   uint16 = 53
-	LLDPMAUType10GBaseT        uint16 = 54
-	LLDPMAUType10GBaseLRM      uint16 = 55
-	LLDPMAUType1000BaseKX      uint16 = 56
-	LLDPMAUType10GBaseKX4      uint16 = 57
-	LLDPMAUType10GBaseKR       uint16 = 58
-	LLDPMAUType10_1GBasePRX_D1 uint16 = 59
-	LLDPMAUType10_1GBasePRX_D2 uint16 = 60
-	LLDPMAUType10_1GBasePRX_D3 uint16 = 61
-	LLDPMAUType10_1GBasePRX_U1 uint16 = 62
-	LLDPMAUType10_1GBasePRX_U2 uint16 = 63
-	LLDPMAUType10_1GBasePRX_U3 uint16 = 64
-	LLDPMAUType10GBasePR_D1    uint16 = 65
-	LLDPMAUType10GBasePR_D2    uint16 = 66
-	LLDPMAUType10GBasePR_D3    uint16 = 67
-	LLDPMAUType10GBasePR_U1    uint16 = 68
-	LLDPMAUType10GBasePR_U3    uint16 = 69
+	LLDPMAUTypeUnknown         LLDPMAUType = 0
+	LLDPMAUTypeAUI             LLDPMAUType = 1
+	LLDPMAUType10Base5         LLDPMAUType = 2
+	LLDPMAUTypeFOIRL           LLDPMAUType = 3
+	LLDPMAUType10Base2         LLDPMAUType = 4
+	LLDPMAUType10BaseT         LLDPMAUType = 5
+	LLDPMAUType10BaseFP        LLDPMAUType = 6
+	LLDPMAUType10BaseFB        LLDPMAUType = 7
+	LLDPMAUType10BaseFL        LLDPMAUType = 8
+	LLDPMAUType10BROAD36       LLDPMAUType = 9
+	LLDPMAUType10BaseT_HD      LLDPMAUType = 10
+	LLDPMAUType10BaseT_FD      LLDPMAUType = 11
+	LLDPMAUType10BaseFL_HD     LLDPMAUType = 12
+	LLDPMAUType10BaseFL_FD     LLDPMAUType = 13
+	LLDPMAUType100BaseT4       LLDPMAUType = 14
+	LLDPMAUType100BaseTX_HD    LLDPMAUType = 15
+	LLDPMAUType100BaseTX_FD    LLDPMAUType = 16
+	LLDPMAUType100BaseFX_HD    LLDPMAUType = 17
+	LLDPMAUType100BaseFX_FD    LLDPMAUType = 18
+	LLDPMAUType100BaseT2_HD    LLDPMAUType = 19
+	LLDPMAUType100BaseT2_FD    LLDPMAUType = 20
+	LLDPMAUType1000BaseX_HD    LLDPMAUType = 21
+	LLDPMAUType1000BaseX_FD    LLDPMAUType = 22
+	LLDPMAUType1000BaseLX_HD   LLDPMAUType = 23
+	LLDPMAUType1000BaseLX_FD   LLDPMAUType = 24
+	LLDPMAUType1000BaseSX_HD   LLDPMAUType = 25
+	LLDPMAUType1000BaseSX_FD   LLDPMAUType = 26
+	LLDPMAUType1000BaseCX_HD   LLDPMAUType = 27
+	LLDPMAUType1000BaseCX_FD   LLDPMAUType = 28
+	LLDPMAUType1000BaseT_HD    LLDPMAUType = 29
+	LLDPMAUType1000BaseT_FD    LLDPMAUType = 30
+	LLDPMAUType10GBaseX        LLDPMAUType = 31
+	LLDPMAUType10GBaseLX4      LLDPMAUType = 32
+	LLDPMAUType10GBaseR        LLDPMAUType = 33
+	LLDPMAUType10GBaseER       LLDPMAUType = 34
+	LLDPMAUType10GBaseLR       LLDPMAUType = 35
+	LLDPMAUType10GBaseSR       LLDPMAUType = 36
+	LLDPMAUType10GBaseW        LLDPMAUType = 37
+	LLDPMAUType10GBaseEW       LLDPMAUType = 38
+	LLDPMAUType10GBaseLW       LLDPMAUType = 39
+	LLDPMAUType10GBaseSW       LLDPMAUType = 40
+	LLDPMAUType10GBaseCX4      LLDPMAUType = 41
+	LLDPMAUType2BaseTL         LLDPMAUType = 42
+	LLDPMAUType10PASS_TS       LLDPMAUType = 43
+	LLDPMAUType100BaseBX10D    LLDPMAUType = 44
+	LLDPMAUType100BaseBX10U    LLDPMAUType = 45
+	LLDPMAUType100BaseLX10     LLDPMAUType = 46
+	LLDPMAUType1000BaseBX10D   LLDPMAUType = 47
+	LLDPMAUType1000BaseBX10U   LLDPMAUType = 48
+	LLDPMAUType1000BaseLX10    LLDPMAUType = 49
+	LLDPMAUType1000BasePX10D   LLDPMAUType = 50
+	LLDPMAUType1000BasePX10U   LLDPMAUType = 51
+	LLDPMAUType1000BasePX20D   LLDPMAUType = 52
+	LLDPMAUType1000BasePX20U   LLDPMAUType = 53
+	LLDPMAUType10GBaseT        LLDPMAUType = 54
+	LLDPMAUType10GBaseLRM      LLDPMAUType = 55
+	LLDPMAUType1000BaseKX      LLDPMAUType = 56
+	LLDPMAUType10GBaseKX4      LLDPMAUType = 57
+	LLDPMAUType10GBaseKR       LLDPMAUType = 58
+	LLDPMAUType10_1GBasePRX_D1 LLDPMAUType = 59
+	LLDPMAUType10_1GBasePRX_D2 LLDPMAUType = 60
+	LLDPMAUType10_1GBasePRX_D3 LLDPMAUType = 61
+	LLDPMAUType10_1GBasePRX_U1 LLDPMAUType = 62
+	LLDPMAUType10_1GBasePRX_U2 LLDPMAUType = 63
+	LLDPMAUType10_1GBasePRX_U3 LLDPMAUType = 64
+	LLDPMAUType10GBasePR_D1    LLDPMAUType = 65
+	LLDPMAUType10GBasePR_D2    LLDPMAUType = 66
+	LLDPMAUType10GBasePR_D3    LLDPMAUType = 67
+	LLDPMAUType10GBasePR_U1    LLDPMAUType = 68
+	LLDPMAUType10GBasePR_U3    LLDPMAUType = 69
 )
 
 // From RFC 3636 - ifMauAutoNegCapAdvertisedBits
@@ -428,7 +629,46 @@ type LLDPMACPHYConfigStatus struct {
 	AutoNegSupported  bool
 	AutoNegEnabled    bool
 	AutoNegCapability uint16
-	MAUType           uint16
+	MAUType           LLDPMAUType
+}
+
+var lldpMAUPMDNames = []struct {
+	bit, inv uint16
+	name     string
+}{
+	{LLDPMAUPMDOther, LLDPMAUPMDOtherInv, "Other"},
+	{LLDPMAUPMD10BaseT, LLDPMAUPMD10BaseTInv, "10BaseT"},
+	{LLDPMAUPMD10BaseT_FD, LLDPMAUPMD10BaseT_FDInv, "10BaseT(FD)"},
+	{LLDPMAUPMD100BaseT4, LLDPMAUPMD100BaseT4Inv, "100BaseT4"},
+	{LLDPMAUPMD100BaseTX, LLDPMAUPMD100BaseTXInv, "100BaseTX"},
+	{LLDPMAUPMD100BaseTX_FD, LLDPMAUPMD100BaseTX_FDInv, "100BaseTX(FD)"},
+	{LLDPMAUPMD100BaseT2, LLDPMAUPMD100BaseT2Inv, "100BaseT2"},
+	{LLDPMAUPMD100BaseT2_FD, LLDPMAUPMD100BaseT2_FDInv, "100BaseT2(FD)"},
+	{LLDPMAUPMDFDXPAUSE, LLDPMAUPMDFDXPAUSEInv, "FDX PAUSE"},
+	{LLDPMAUPMDFDXAPAUSE, LLDPMAUPMDFDXAPAUSEInv, "FDX Asymmetric PAUSE"},
+	{LLDPMAUPMDFDXSPAUSE, LLDPMAUPMDFDXSPAUSEInv, "FDX Symmetric PAUSE"},
+	{LLDPMAUPMDFDXBPAUSE, LLDPMAUPMDFDXBPAUSEInv, "FDX Asymmetric and Symmetric PAUSE"},
+	{LLDPMAUPMD1000BaseX, LLDPMAUPMD1000BaseXInv, "1000BaseX"},
+	{LLDPMAUPMD1000BaseX_FD, LLDPMAUPMD1000BaseX_FDInv, "1000BaseX(FD)"},
+	{LLDPMAUPMD1000BaseT, LLDPMAUPMD1000BaseTInv, "1000BaseT"},
+	{LLDPMAUPMD1000BaseT_FD, LLDPMAUPMD1000BaseT_FDInv, "1000BaseT(FD)"},
+}
+
+// AutoNegCapabilities returns the names of the PMD types advertised in
+// AutoNegCapability.  If inverted is true, the bits are interpreted in the
+// reversed order some manufacturers use (see the LLDPMAUPMD*Inv constants).
+func (c LLDPMACPHYConfigStatus) AutoNegCapabilities(inverted bool) []string {
+	var names []string
+	for _, pmd := range lldpMAUPMDNames {
+		bit := pmd.bit
+		if inverted {
+			bit = pmd.inv
+		}
+		if c.AutoNegCapability&bit != 0 {
+			names = append(names, pmd.name)
+		}
+	}
+	return names
 }
 
 // MDI Power options
@@ -462,8 +702,22 @@ type LLDPPowerViaMDI8023 struct {
 	Type            LLDPPowerType
 	Source          LLDPPowerSource
 	Priority        LLDPPowerPriority
-	Requested       uint16 // 1-510 Watts
-	Allocated       uint16 // 1-510 Watts
+	Requested       uint16 // In units of 0.1 Watts
+	Allocated       uint16 // In units of 0.1 Watts
+
+	// The 802.3bt extension, sent by type 3 and 4 devices.  The fields are
+	// zero unless Has8023bt is set.
+	Has8023bt        bool
+	RequestedModeA   uint16 // In units of 0.1 Watts
+	RequestedModeB   uint16 // In units of 0.1 Watts
+	AllocatedAltA    uint16 // In units of 0.1 Watts
+	AllocatedAltB    uint16 // In units of 0.1 Watts
+	PowerStatus      uint16
+	SystemSetup      uint8
+	MaxAvailable     uint16 // In units of 0.1 Watts
+	Autoclass        uint8
+	PowerDownRequest uint8
+	PowerDownTime    uint32 // In seconds
 }
 
 // LLDPInfo8023 represents the information carried in 802.3 Org-specific TLVs
@@ -508,9 +762,25 @@ type LLDPEVBSettings struct {
 	RTEExponent    uint8
 }
 
+// LLDPCDCPChannel is an S-channel in a CDCP TLV: its S-channel ID and the
+// S-VLAN ID assigned to it.
+type LLDPCDCPChannel struct {
+	SCID uint16
+	SVID uint16
+}
+
+// LLDPCDCP represents the S-channel Discovery and Configuration Protocol TLV.
+type LLDPCDCP struct {
+	Role       uint8 // 0 for a bridge, 1 for a station
+	SComp      bool  // Set if an S-VLAN component is present
+	ChannelCap uint16
+	Channels   []LLDPCDCPChannel
+}
+
 // LLDPInfo8021Qbg represents the information carried in 802.1Qbg Org-specific TLVs
 type LLDPInfo8021Qbg struct {
 	EVBSettings LLDPEVBSettings
+	CDCP        LLDPCDCP
 }
 
 type LLDPMediaSubtype uint8
@@ -684,6 +954,10 @@ type LLDPInfoMedia struct {
 	Manufacturer      string
 	Model             string
 	AssetID           string
+
+	// NetworkPolicies holds every Network Policy TLV, as endpoints send one
+	// per application type; NetworkPolicy is the last of them.
+	NetworkPolicies []LLDPNetworkPolicy
 }
 
 type LLDPCisco2Subtype uint8
@@ -766,10 +1040,29 @@ func (c *LinkLayerDiscovery) LayerType() gopacket.LayerType {
 }
 
 // SerializeTo serializes LLDP packet to bytes and writes on SerializeBuffer.
+// Values are written in order after the ChassisID, PortID and TTL TLVs, and
+// followed by the End TLV.  The Length of each value is computed from its
+// Value if zero.
 func (c *LinkLayerDiscovery) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(c.ChassisID.ID)+1 > lldpMaxTLVLength || len(c.PortID.ID)+1 > lldpMaxTLVLength {
+		return errors.New("LLDP ChassisID or PortID too long")
+	}
 	chassIDLen := c.ChassisID.serializedLen()
 	portIDLen := c.PortID.serializedLen()
-	vb, err := b.AppendBytes(chassIDLen + portIDLen + 4) // +4 for TTL
+	valueLens := make([]int, len(c.Values))
+	valuesLen := 0
+	for i := range c.Values {
+		if c.Values[i].Type == LLDPTLVEnd {
+			return errors.New("LLDP End TLV in Values")
+		}
+		length, err := c.Values[i].len()
+		if err != nil {
+			return err
+		}
+		valueLens[i] = length
+		valuesLen += length + 2
+	}
+	vb, err := b.AppendBytes(chassIDLen + portIDLen + 4 + valuesLen) // +4 for TTL
 	if err != nil {
 		return err
 	}
@@ -778,6 +1071,11 @@ func (c *LinkLayerDiscovery) SerializeTo(b gopacket.SerializeBuffer, opts gopack
 	ttlIDLen := uint16(LLDPTLVTTL)<<9 | uint16(2)
 	binary.BigEndian.PutUint16(vb[chassIDLen+portIDLen:], ttlIDLen)
 	binary.BigEndian.PutUint16(vb[chassIDLen+portIDLen+2:], c.TTL)
+	vb = vb[chassIDLen+portIDLen+4:]
+	for i := range c.Values {
+		c.Values[i].serialize(vb, valueLens[i])
+		vb = vb[valueLens[i]+2:]
+	}
 
 	vb, err = b.AppendBytes(2) // End Tlv, 2 bytes
 	if err != nil {
@@ -788,63 +1086,252 @@ func (c *LinkLayerDiscovery) SerializeTo(b gopacket.SerializeBuffer, opts gopack
 
 }
 
-func decodeLinkLayerDiscovery(data []byte, p gopacket.PacketBuilder) error {
-	var vals []LinkLayerDiscoveryValue
+// LLDPOption adds an optional TLV to a LinkLayerDiscovery created with
+// NewLinkLayerDiscovery.
+type LLDPOption func(*LinkLayerDiscovery)
+
+// NewLinkLayerDiscovery returns a LinkLayerDiscovery announcing the given
+// chassis ID, port ID and TTL, with the TLVs added by opts in Values.  The
+// TLVs are ordered by type, as in the 802.1AB examples, and options of the
+// same type keep the order they're given in.
+func NewLinkLayerDiscovery(chassis LLDPChassisID, port LLDPPortID, ttl uint16, opts ...LLDPOption) *LinkLayerDiscovery {
+	c := &LinkLayerDiscovery{ChassisID: chassis, PortID: port, TTL: ttl}
+	for _, opt := range opts {
+		opt(c)
+	}
+	sort.Stable(lldpValuesByType(c.Values))
+	return c
+}
+
+type lldpValuesByType []LinkLayerDiscoveryValue
+
+func (v lldpValuesByType) Len() int           { return len(v) }
+func (v lldpValuesByType) Less(i, j int) bool { return v[i].Type < v[j].Type }
+func (v lldpValuesByType) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+
+func lldpValueOption(t LLDPTLVType, value []byte) LLDPOption {
+	return func(c *LinkLayerDiscovery) {
+		c.Values = append(c.Values, LinkLayerDiscoveryValue{Type: t, Length: uint16(len(value)), Value: value})
+	}
+}
+
+// LLDPWithPortDescription adds a Port Description TLV.
+func LLDPWithPortDescription(desc string) LLDPOption {
+	return lldpValueOption(LLDPTLVPortDescription, []byte(desc))
+}
+
+// LLDPWithSysName adds a System Name TLV.
+func LLDPWithSysName(name string) LLDPOption {
+	return lldpValueOption(LLDPTLVSysName, []byte(name))
+}
+
+// LLDPWithSysDescription adds a System Description TLV.
+func LLDPWithSysDescription(desc string) LLDPOption {
+	return lldpValueOption(LLDPTLVSysDescription, []byte(desc))
+}
+
+// LLDPWithCapabilities adds a System Capabilities TLV.
+func LLDPWithCapabilities(caps LLDPSysCapabilities) LLDPOption {
+	return lldpValueOption(LLDPTLVSysCapabilities, caps.Encode())
+}
+
+// LLDPWithMgmtAddress adds a Management Address TLV.
+func LLDPWithMgmtAddress(addr LLDPMgmtAddress) LLDPOption {
+	return lldpValueOption(LLDPTLVMgmtAddress, addr.Encode())
+}
+
+// LLDPWithOrgSpecific adds an Organisation Specific TLV.
+func LLDPWithOrgSpecific(tlv LLDPOrgSpecificTLV) LLDPOption {
+	value := make([]byte, 4+len(tlv.Info))
+	value[0] = byte(tlv.OUI >> 16)
+	value[1] = byte(tlv.OUI >> 8)
+	value[2] = byte(tlv.OUI)
+	value[3] = tlv.SubType
+	copy(value[4:], tlv.Info)
+	return lldpValueOption(LLDPTLVOrgSpecific, value)
+}
+
+// IsShutdown returns true if the LLDPDU is a shutdown LLDPDU, announcing that
+// the sender's information should be discarded: one with a TTL of 0.
+func (c *LinkLayerDiscovery) IsShutdown() bool {
+	return c.TTL == 0
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *LinkLayerDiscovery) CanDecode() gopacket.LayerClass {
+	return LayerTypeLinkLayerDiscovery
+}
+
+// NextLayerType returns gopacket.LayerTypeZero.  The details of Values are
+// decoded with DecodeInfo.
+func (c *LinkLayerDiscovery) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.  Values is reused
+// between calls.
+func (c *LinkLayerDiscovery) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	c.ChassisID = LLDPChassisID{}
+	c.PortID = LLDPPortID{}
+	c.TTL = 0
+	if n := lldpCountTLVs(data) - 3; cap(c.Values) < n {
+		c.Values = make([]LinkLayerDiscoveryValue, 0, n)
+	} else {
+		c.Values = c.Values[:0]
+	}
+	c.ChassisIDOffset, c.PortIDOffset, c.TTLOffset = 0, 0, 0
+	c.MissingEnd = false
+	gotTTL := false
+	gotEnd := false
 	vData := data[0:]
-	for len(vData) > 0 {
+	for len(vData) > 0 && !gotEnd {
+		if len(vData) < 2 {
+			df.SetTruncated()
+			return errors.New("Malformed LinkLayerDiscovery Header")
+		}
 		nbit := vData[0] & 0x01
 		t := LLDPTLVType(vData[0] >> 1)
-		val := LinkLayerDiscoveryValue{Type: t, Length: uint16(nbit)<<8 + uint16(vData[1])}
-		if val.Length > 0 {
-			val.Value = vData[2 : val.Length+2]
-		}
-		vals = append(vals, val)
-		if t == LLDPTLVEnd {
-			break
-		}
+		val := LinkLayerDiscoveryValue{Type: t, Length: uint16(nbit)<<8 + uint16(vData[1]), Offset: len(data) - len(vData)}
 		if len(vData) < int(2+val.Length) {
+			df.SetTruncated()
 			return errors.New("Malformed LinkLayerDiscovery Header")
 		}
-		vData = vData[2+val.Length:]
-	}
-	if len(vals) < 4 {
-		return errors.New("Missing mandatory LinkLayerDiscovery TLV")
-	}
-	c := &LinkLayerDiscovery{}
-	gotEnd := false
-	for _, v := range vals {
-		switch v.Type {
+		if val.Length > 0 {
+			val.Value = vData[2 : val.Length+2]
+		}
+		switch t {
 		case LLDPTLVEnd:
 			gotEnd = true
+			continue
 		case LLDPTLVChassisID:
-			if len(v.Value) < 2 {
+			if len(val.Value) < 2 {
 				return errors.New("Malformed LinkLayerDiscovery ChassisID TLV")
 			}
-			c.ChassisID.Subtype = LLDPChassisIDSubType(v.Value[0])
-			c.ChassisID.ID = v.Value[1:]
+			c.ChassisID.Subtype = LLDPChassisIDSubType(val.Value[0])
+			c.ChassisID.ID = val.Value[1:]
+			c.ChassisIDOffset = val.Offset
 		case LLDPTLVPortID:
-			if len(v.Value) < 2 {
+			if len(val.Value) < 2 {
 				return errors.New("Malformed LinkLayerDiscovery PortID TLV")
 			}
-			c.PortID.Subtype = LLDPPortIDSubType(v.Value[0])
-			c.PortID.ID = v.Value[1:]
+			c.PortID.Subtype = LLDPPortIDSubType(val.Value[0])
+			c.PortID.ID = val.Value[1:]
+			c.PortIDOffset = val.Offset
 		case LLDPTLVTTL:
-			if len(v.Value) < 2 {
+			if len(val.Value) < 2 {
 				return errors.New("Malformed LinkLayerDiscovery TTL TLV")
 			}
-			c.TTL = binary.BigEndian.Uint16(v.Value[0:2])
+			c.TTL = binary.BigEndian.Uint16(val.Value[0:2])
+			c.TTLOffset = val.Offset
+			gotTTL = true
 		default:
-			c.Values = append(c.Values, v)
+			c.Values = append(c.Values, val)
 		}
+		vData = vData[2+val.Length:]
 	}
-	if c.ChassisID.Subtype == 0 || c.PortID.Subtype == 0 || !gotEnd {
+	if c.ChassisID.Subtype == 0 || c.PortID.Subtype == 0 || !gotTTL {
 		return errors.New("Missing mandatory LinkLayerDiscovery TLV")
 	}
-	c.Contents = data
+	// Some devices leave out the End TLV, which is otherwise mandatory.
+	c.MissingEnd = !gotEnd
+	c.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// Validate checks the TLVs the layer was decoded from against the stricter
+// rules of 802.1AB that decoding doesn't enforce: Chassis ID, Port ID and TTL
+// must be the first three TLVs, in that order; they and the Port Description,
+// System Name, System Description and System Capabilities TLVs may appear only
+// once; the TTL must be 2 bytes long; and no TLV may follow the End TLV.  All
+// violations are returned as an *LLDPDecodeErrors.
+func (c *LinkLayerDiscovery) Validate() error {
+	var errs []error
+	mandatory := [...]LLDPTLVType{LLDPTLVChassisID, LLDPTLVPortID, LLDPTLVTTL}
+	seen := make(map[LLDPTLVType]bool)
+	gotEnd := false
+	data := c.Contents
+	for i := 0; len(data) > 0; i++ {
+		if len(data) < 2 {
+			errs = append(errs, errors.New("Truncated LinkLayerDiscovery TLV"))
+			break
+		}
+		t := LLDPTLVType(data[0] >> 1)
+		length := int(data[0]&0x01)<<8 | int(data[1])
+		if len(data) < 2+length {
+			errs = append(errs, errors.New("Truncated LinkLayerDiscovery TLV"))
+			break
+		}
+		data = data[2+length:]
+		if gotEnd {
+			// Padding after the End TLV looks like more End TLVs.
+			if t != LLDPTLVEnd || length != 0 {
+				errs = append(errs, fmt.Errorf("LLDP %v TLV after End TLV", t))
+			}
+			continue
+		}
+		if i < len(mandatory) && t != mandatory[i] {
+			errs = append(errs, fmt.Errorf("LLDP TLV %d is %v, want %v", i, t, mandatory[i]))
+		}
+		switch t {
+		case LLDPTLVEnd:
+			gotEnd = true
+		case LLDPTLVChassisID, LLDPTLVPortID, LLDPTLVTTL, LLDPTLVPortDescription,
+			LLDPTLVSysName, LLDPTLVSysDescription, LLDPTLVSysCapabilities:
+			if seen[t] {
+				errs = append(errs, fmt.Errorf("Duplicate LLDP %v TLV", t))
+			}
+			seen[t] = true
+		}
+		if t == LLDPTLVTTL && length != 2 {
+			errs = append(errs, fmt.Errorf("Invalid LLDP TTL TLV length %d", length))
+		}
+	}
+	if !gotEnd {
+		errs = append(errs, errors.New("Missing LLDP End TLV"))
+	}
+	return lldpDecodeErrors(errs)
+}
+
+// lldpCountTLVs returns the number of TLVs in data before the End TLV.
+func lldpCountTLVs(data []byte) (n int) {
+	for len(data) >= 2 {
+		length := int(data[0]&0x01)<<8 | int(data[1])
+		if LLDPTLVType(data[0]>>1) == LLDPTLVEnd || len(data) < 2+length {
+			break
+		}
+		n++
+		data = data[2+length:]
+	}
+	return
+}
+
+func decodeLinkLayerDiscovery(data []byte, p gopacket.PacketBuilder) error {
+	c := &LinkLayerDiscovery{}
+	if err := c.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
 	p.AddLayer(c)
 
 	info := &LinkLayerDiscoveryInfo{}
 	p.AddLayer(info)
+	return c.DecodeInfo(info)
+}
+
+// DecodeInfo decodes the details of the layer's Values into info, reusing
+// its OrgTLVs.  It's the counterpart of the LinkLayerDiscoveryInfo layer for
+// users of DecodeFromBytes.
+func (c *LinkLayerDiscovery) DecodeInfo(info *LinkLayerDiscoveryInfo) error {
+	var errs []error
+	*info = LinkLayerDiscoveryInfo{OrgTLVs: info.OrgTLVs[:0], lldp: info.lldp}
+	numOrg := 0
+	for _, v := range c.Values {
+		if v.Type == LLDPTLVOrgSpecific {
+			numOrg++
+		}
+	}
+	if cap(info.OrgTLVs) < numOrg {
+		info.OrgTLVs = make([]LLDPOrgSpecificTLV, 0, numOrg)
+	}
 	for _, v := range c.Values {
 		switch v.Type {
 		case LLDPTLVPortDescription:
@@ -855,110 +1342,234 @@ func decodeLinkLayerDiscovery(data []byte, p gopacket.PacketBuilder) error {
 			info.SysDescription = string(v.Value)
 		case LLDPTLVSysCapabilities:
 			if err := checkLLDPTLVLen(v, 4); err != nil {
-				return err
+				errs = append(errs, err)
+				continue
 			}
 			info.SysCapabilities.SystemCap = getCapabilities(binary.BigEndian.Uint16(v.Value[0:2]))
 			info.SysCapabilities.EnabledCap = getCapabilities(binary.BigEndian.Uint16(v.Value[2:4]))
 		case LLDPTLVMgmtAddress:
 			if err := checkLLDPTLVLen(v, 9); err != nil {
-				return err
+				errs = append(errs, err)
+				continue
+			}
+			// The address string length counts the subtype and address.
+			mlen := int(v.Value[0])
+			if mlen < 1 {
+				errs = append(errs, fmt.Errorf("Invalid LLDP management address length %d", mlen))
+				continue
+			}
+			if err := checkLLDPTLVLen(v, mlen+7); err != nil {
+				errs = append(errs, err)
+				continue
 			}
-			mlen := v.Value[0]
-			if err := checkLLDPTLVLen(v, int(mlen+7)); err != nil {
-				return err
+			olen := int(v.Value[mlen+6])
+			if err := checkLLDPTLVLen(v, mlen+7+olen); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.MgmtAddress.Subtype = IANAAddressFamily(v.Value[1])
 			info.MgmtAddress.Address = v.Value[2 : mlen+1]
 			info.MgmtAddress.InterfaceSubtype = LLDPInterfaceSubtype(v.Value[mlen+1])
 			info.MgmtAddress.InterfaceNumber = binary.BigEndian.Uint32(v.Value[mlen+2 : mlen+6])
-			olen := v.Value[mlen+6]
-			if err := checkLLDPTLVLen(v, int(mlen+6+olen)); err != nil {
-				return err
-			}
-			info.MgmtAddress.OID = string(v.Value[mlen+9 : mlen+9+olen])
+			info.MgmtAddress.OID = string(v.Value[mlen+7 : mlen+7+olen])
 		case LLDPTLVOrgSpecific:
 			if err := checkLLDPTLVLen(v, 4); err != nil {
-				return err
+				errs = append(errs, err)
+				continue
 			}
-			info.OrgTLVs = append(info.OrgTLVs, LLDPOrgSpecificTLV{IEEEOUI(binary.BigEndian.Uint32(append([]byte{byte(0)}, v.Value[0:3]...))), uint8(v.Value[3]), v.Value[4:]})
+			oui := IEEEOUI(v.Value[0])<<16 | IEEEOUI(v.Value[1])<<8 | IEEEOUI(v.Value[2])
+			info.OrgTLVs = append(info.OrgTLVs, LLDPOrgSpecificTLV{oui, uint8(v.Value[3]), v.Value[4:]})
 		}
 	}
-	return nil
+	return lldpDecodeErrors(errs)
 }
 
 func (l *LinkLayerDiscoveryInfo) Decode8021() (info LLDPInfo8021, err error) {
+	var errs []error
 	for _, o := range l.OrgTLVs {
 		if o.OUI != IEEEOUI8021 {
 			continue
 		}
 		switch o.SubType {
 		case LLDP8021SubtypePortVLANID:
-			if err = checkLLDPOrgSpecificLen(o, 2); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 2); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.PVID = binary.BigEndian.Uint16(o.Info[0:2])
 		case LLDP8021SubtypeProtocolVLANID:
-			if err = checkLLDPOrgSpecificLen(o, 3); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 3); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			sup := (o.Info[0]&LLDPProtocolVLANIDCapability > 0)
 			en := (o.Info[0]&LLDPProtocolVLANIDStatus > 0)
 			id := binary.BigEndian.Uint16(o.Info[1:3])
 			info.PPVIDs = append(info.PPVIDs, PortProtocolVLANID{sup, en, id})
 		case LLDP8021SubtypeVLANName:
-			if err = checkLLDPOrgSpecificLen(o, 2); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 3); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			id := binary.BigEndian.Uint16(o.Info[0:2])
-			info.VLANNames = append(info.VLANNames, VLANName{id, string(o.Info[3:])})
+			l := int(o.Info[2])
+			if err := checkLLDPOrgSpecificLen(o, 3+l); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			info.VLANNames = append(info.VLANNames, VLANName{id, string(o.Info[3 : 3+l])})
 		case LLDP8021SubtypeProtocolIdentity:
-			if err = checkLLDPOrgSpecificLen(o, 1); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 1); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			l := int(o.Info[0])
+			if err := checkLLDPOrgSpecificLen(o, 1+l); err != nil {
+				errs = append(errs, err)
+				continue
+			}
 			if l > 0 {
 				info.ProtocolIdentities = append(info.ProtocolIdentities, o.Info[1:1+l])
 			}
 		case LLDP8021SubtypeVDIUsageDigest:
-			if err = checkLLDPOrgSpecificLen(o, 4); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 4); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.VIDUsageDigest = binary.BigEndian.Uint32(o.Info[0:4])
 		case LLDP8021SubtypeManagementVID:
-			if err = checkLLDPOrgSpecificLen(o, 2); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 2); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.ManagementVID = binary.BigEndian.Uint16(o.Info[0:2])
 		case LLDP8021SubtypeLinkAggregation:
-			if err = checkLLDPOrgSpecificLen(o, 5); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 5); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			sup := (o.Info[0]&LLDPAggregationCapability > 0)
 			en := (o.Info[0]&LLDPAggregationStatus > 0)
 			info.LinkAggregation = LLDPLinkAggregation{sup, en, binary.BigEndian.Uint32(o.Info[1:5])}
+		case LLDP8021SubtypeETSConfig:
+			if err := checkLLDPOrgSpecificLen(o, 21); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			info.ETSConfiguration.Willing = (o.Info[0] & 0x80) > 0
+			info.ETSConfiguration.CBS = (o.Info[0] & 0x40) > 0
+			info.ETSConfiguration.MaxTCs = o.Info[0] & 0x07
+			info.ETSConfiguration.LLDPETSTables = decodeLLDPETSTables(o.Info[1:21])
+		case LLDP8021SubtypeETSRecommend:
+			if err := checkLLDPOrgSpecificLen(o, 21); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			info.ETSRecommendation.LLDPETSTables = decodeLLDPETSTables(o.Info[1:21])
+		case LLDP8021SubtypePFCConfig:
+			if err := checkLLDPOrgSpecificLen(o, 2); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			info.PFCConfiguration.Willing = (o.Info[0] & 0x80) > 0
+			info.PFCConfiguration.MBC = (o.Info[0] & 0x40) > 0
+			info.PFCConfiguration.Capability = o.Info[0] & 0x0f
+			info.PFCConfiguration.Enabled = o.Info[1]
+		case LLDP8021SubtypeAppPriority:
+			// A reserved byte, then 3 bytes per entry.
+			n := (len(o.Info) - 1 + 2) / 3
+			if err := checkLLDPOrgSpecificLen(o, 1+n*3); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			for i := 0; i < n; i++ {
+				e := o.Info[1+i*3 : 4+i*3]
+				info.ApplicationPriorities = append(info.ApplicationPriorities, LLDPApplicationPriority{
+					Priority: e[0] >> 5,
+					Selector: e[0] & 0x07,
+					Protocol: binary.BigEndian.Uint16(e[1:3]),
+				})
+			}
+		case LLDP8021SubtypeCN:
+			if err := checkLLDPOrgSpecificLen(o, 2); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			info.CongestionNotification.CNPV = o.Info[0]
+			info.CongestionNotification.Ready = o.Info[1]
+		case LLDP8021SubtypeCDCP:
+			if err := decodeLLDPCDCP(o, &info.CDCP); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		case LLDP8021SubtypePortExtension:
+			if err := checkLLDPOrgSpecificLen(o, 3); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			info.PortExtension.PortExtender = (o.Info[0] & 0x80) > 0
+			info.PortExtension.ECID = binary.BigEndian.Uint16(o.Info[1:3]) & 0x3fff
 		}
 	}
+	err = lldpDecodeErrors(errs)
 	return
 }
 
+// decodeLLDPETSTables decodes the 20 bytes of tables of an ETS TLV.
+func decodeLLDPETSTables(b []byte) (t LLDPETSTables) {
+	for i := 0; i < 4; i++ {
+		t.PriorityAssignment[i*2] = b[i] >> 4
+		t.PriorityAssignment[i*2+1] = b[i] & 0x0f
+	}
+	copy(t.TCBandwidth[:], b[4:12])
+	copy(t.TSAAssignment[:], b[12:20])
+	return
+}
+
+// decodeLLDPCDCP decodes a CDCP TLV, sent with the 802.1 OUI or the
+// pre-standard 802.1Qbg one.
+func decodeLLDPCDCP(o LLDPOrgSpecificTLV, cdcp *LLDPCDCP) error {
+	if err := checkLLDPOrgSpecificLen(o, 4); err != nil {
+		return err
+	}
+	// The channels are packed in 3 bytes each, and must fill the TLV.
+	n := (len(o.Info) - 4 + 2) / 3
+	if err := checkLLDPOrgSpecificLen(o, 4+n*3); err != nil {
+		return err
+	}
+	cdcp.Role = o.Info[0] >> 7
+	cdcp.SComp = (o.Info[0] & 0x08) > 0
+	cdcp.ChannelCap = binary.BigEndian.Uint16(o.Info[2:4]) & 0x0fff
+	cdcp.Channels = make([]LLDPCDCPChannel, n)
+	for i := range cdcp.Channels {
+		c := o.Info[4+i*3 : 7+i*3]
+		cdcp.Channels[i].SCID = uint16(c[0])<<4 | uint16(c[1])>>4
+		cdcp.Channels[i].SVID = uint16(c[1]&0x0f)<<8 | uint16(c[2])
+	}
+	return nil
+}
+
 func (l *LinkLayerDiscoveryInfo) Decode8023() (info LLDPInfo8023, err error) {
+	var errs []error
 	for _, o := range l.OrgTLVs {
 		if o.OUI != IEEEOUI8023 {
 			continue
 		}
 		switch o.SubType {
 		case LLDP8023SubtypeMACPHY:
-			if err = checkLLDPOrgSpecificLen(o, 5); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 5); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			sup := (o.Info[0]&LLDPMACPHYCapability > 0)
 			en := (o.Info[0]&LLDPMACPHYStatus > 0)
 			ca := binary.BigEndian.Uint16(o.Info[1:3])
-			mau := binary.BigEndian.Uint16(o.Info[3:5])
+			mau := LLDPMAUType(binary.BigEndian.Uint16(o.Info[3:5]))
 			info.MACPHYConfigStatus = LLDPMACPHYConfigStatus{sup, en, ca, mau}
 		case LLDP8023SubtypeMDIPower:
-			if err = checkLLDPOrgSpecificLen(o, 3); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 3); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.PowerViaMDI.PortClassPSE = (o.Info[0]&LLDPMDIPowerPortClass > 0)
 			info.PowerViaMDI.PSESupported = (o.Info[0]&LLDPMDIPowerCapability > 0)
@@ -966,7 +1577,9 @@ func (l *LinkLayerDiscoveryInfo) Decode8023() (info LLDPInfo8023, err error) {
 			info.PowerViaMDI.PSEPairsAbility = (o.Info[0]&LLDPMDIPowerPairsAbility > 0)
 			info.PowerViaMDI.PSEPowerPair = uint8(o.Info[1])
 			info.PowerViaMDI.PSEClass = uint8(o.Info[2])
-			if len(o.Info) >= 7 {
+			// The 802.3at extension adds 5 bytes, ending with the
+			// requested and allocated power.
+			if len(o.Info) >= 8 {
 				info.PowerViaMDI.Type = LLDPPowerType((o.Info[3] & 0xc0) >> 6)
 				info.PowerViaMDI.Source = LLDPPowerSource((o.Info[3] & 0x30) >> 4)
 				if info.PowerViaMDI.Type == 1 || info.PowerViaMDI.Type == 3 {
@@ -976,52 +1589,79 @@ func (l *LinkLayerDiscoveryInfo) Decode8023() (info LLDPInfo8023, err error) {
 				info.PowerViaMDI.Requested = binary.BigEndian.Uint16(o.Info[4:6])
 				info.PowerViaMDI.Allocated = binary.BigEndian.Uint16(o.Info[6:8])
 			}
+			// The 802.3bt extension adds another 17 bytes.
+			if len(o.Info) >= 25 {
+				info.PowerViaMDI.Has8023bt = true
+				info.PowerViaMDI.RequestedModeA = binary.BigEndian.Uint16(o.Info[8:10])
+				info.PowerViaMDI.RequestedModeB = binary.BigEndian.Uint16(o.Info[10:12])
+				info.PowerViaMDI.AllocatedAltA = binary.BigEndian.Uint16(o.Info[12:14])
+				info.PowerViaMDI.AllocatedAltB = binary.BigEndian.Uint16(o.Info[14:16])
+				info.PowerViaMDI.PowerStatus = binary.BigEndian.Uint16(o.Info[16:18])
+				info.PowerViaMDI.SystemSetup = o.Info[18]
+				info.PowerViaMDI.MaxAvailable = binary.BigEndian.Uint16(o.Info[19:21])
+				info.PowerViaMDI.Autoclass = o.Info[21]
+				info.PowerViaMDI.PowerDownRequest = o.Info[22] >> 2
+				info.PowerViaMDI.PowerDownTime = uint32(o.Info[22]&0x03)<<16 | uint32(binary.BigEndian.Uint16(o.Info[23:25]))
+			}
 		case LLDP8023SubtypeLinkAggregation:
-			if err = checkLLDPOrgSpecificLen(o, 5); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 5); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			sup := (o.Info[0]&LLDPAggregationCapability > 0)
 			en := (o.Info[0]&LLDPAggregationStatus > 0)
 			info.LinkAggregation = LLDPLinkAggregation{sup, en, binary.BigEndian.Uint32(o.Info[1:5])}
 		case LLDP8023SubtypeMTU:
-			if err = checkLLDPOrgSpecificLen(o, 2); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 2); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.MTU = binary.BigEndian.Uint16(o.Info[0:2])
 		}
 	}
+	err = lldpDecodeErrors(errs)
 	return
 }
 
 func (l *LinkLayerDiscoveryInfo) Decode8021Qbg() (info LLDPInfo8021Qbg, err error) {
+	var errs []error
 	for _, o := range l.OrgTLVs {
 		if o.OUI != IEEEOUI8021Qbg {
 			continue
 		}
 		switch o.SubType {
 		case LLDP8021QbgEVB:
-			if err = checkLLDPOrgSpecificLen(o, 9); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 9); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.EVBSettings.Supported = getEVBCapabilities(binary.BigEndian.Uint16(o.Info[0:2]))
 			info.EVBSettings.Enabled = getEVBCapabilities(binary.BigEndian.Uint16(o.Info[2:4]))
 			info.EVBSettings.SupportedVSIs = binary.BigEndian.Uint16(o.Info[4:6])
 			info.EVBSettings.ConfiguredVSIs = binary.BigEndian.Uint16(o.Info[6:8])
 			info.EVBSettings.RTEExponent = uint8(o.Info[8])
+		case LLDP8021QbgCDCP:
+			if err := decodeLLDPCDCP(o, &info.CDCP); err != nil {
+				errs = append(errs, err)
+				continue
+			}
 		}
 	}
+	err = lldpDecodeErrors(errs)
 	return
 }
 
 func (l *LinkLayerDiscoveryInfo) DecodeMedia() (info LLDPInfoMedia, err error) {
+	var errs []error
 	for _, o := range l.OrgTLVs {
 		if o.OUI != IEEEOUIMedia {
 			continue
 		}
 		switch LLDPMediaSubtype(o.SubType) {
 		case LLDPMediaTypeCapabilities:
-			if err = checkLLDPOrgSpecificLen(o, 3); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 3); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			b := binary.BigEndian.Uint16(o.Info[0:2])
 			info.MediaCapabilities.Capabilities = (b & LLDPMediaCapsLLDP) > 0
@@ -1032,8 +1672,9 @@ func (l *LinkLayerDiscoveryInfo) DecodeMedia() (info LLDPInfoMedia, err error) {
 			info.MediaCapabilities.Inventory = (b & LLDPMediaCapsInventory) > 0
 			info.MediaCapabilities.Class = LLDPMediaClass(o.Info[2])
 		case LLDPMediaTypeNetwork:
-			if err = checkLLDPOrgSpecificLen(o, 4); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 4); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.NetworkPolicy.ApplicationType = LLDPApplicationType(o.Info[0])
 			b := binary.BigEndian.Uint16(o.Info[1:3])
@@ -1043,16 +1684,19 @@ func (l *LinkLayerDiscoveryInfo) DecodeMedia() (info LLDPInfoMedia, err error) {
 			b = binary.BigEndian.Uint16(o.Info[2:4])
 			info.NetworkPolicy.L2Priority = (b & 0x01c0) >> 6
 			info.NetworkPolicy.DSCPValue = uint8(o.Info[3] & 0x3f)
+			info.NetworkPolicies = append(info.NetworkPolicies, info.NetworkPolicy)
 		case LLDPMediaTypeLocation:
-			if err = checkLLDPOrgSpecificLen(o, 1); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 1); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.Location.Format = LLDPLocationFormat(o.Info[0])
 			o.Info = o.Info[1:]
 			switch info.Location.Format {
 			case LLDPLocationFormatCoordinate:
-				if err = checkLLDPOrgSpecificLen(o, 16); err != nil {
-					return
+				if err := checkLLDPOrgSpecificLen(o, 16); err != nil {
+					errs = append(errs, err)
+					continue
 				}
 				info.Location.Coordinate.LatitudeResolution = uint8(o.Info[0]&0xfc) >> 2
 				b := binary.BigEndian.Uint64(o.Info[0:8])
@@ -1060,17 +1704,24 @@ func (l *LinkLayerDiscoveryInfo) DecodeMedia() (info LLDPInfoMedia, err error) {
 				info.Location.Coordinate.LongitudeResolution = uint8(o.Info[5]&0xfc) >> 2
 				b = binary.BigEndian.Uint64(o.Info[5:13])
 				info.Location.Coordinate.Longitude = (b & 0x03ffffffff000000) >> 24
-				info.Location.Coordinate.AltitudeType = uint8((o.Info[10] & 0x30) >> 4)
+				info.Location.Coordinate.AltitudeType = uint8(o.Info[10] >> 4)
 				b1 := binary.BigEndian.Uint16(o.Info[10:12])
 				info.Location.Coordinate.AltitudeResolution = (b1 & 0xfc0) >> 6
 				b2 := binary.BigEndian.Uint32(o.Info[11:15])
 				info.Location.Coordinate.Altitude = b2 & 0x3fffffff
 				info.Location.Coordinate.Datum = uint8(o.Info[15])
 			case LLDPLocationFormatAddress:
-				if err = checkLLDPOrgSpecificLen(o, 3); err != nil {
-					return
+				if err := checkLLDPOrgSpecificLen(o, 4); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				// The LCI length counts the bytes after itself.
+				ll := int(o.Info[0])
+				if ll < 3 || ll+1 > len(o.Info) {
+					errs = append(errs, fmt.Errorf("Invalid LLDP civic address LCI length %d", ll))
+					continue
 				}
-				//ll := uint8(o.Info[0])
+				o.Info = o.Info[:ll+1]
 				info.Location.Address.What = LLDPLocationAddressWhat(o.Info[1])
 				info.Location.Address.CountryCode = string(o.Info[2:4])
 				data := o.Info[4:]
@@ -1088,8 +1739,9 @@ func (l *LinkLayerDiscoveryInfo) DecodeMedia() (info LLDPInfoMedia, err error) {
 				info.Location.ECS.ELIN = string(o.Info)
 			}
 		case LLDPMediaTypePower:
-			if err = checkLLDPOrgSpecificLen(o, 3); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 3); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.PowerViaMDI.Type = LLDPPowerType((o.Info[0] & 0xc0) >> 6)
 			info.PowerViaMDI.Source = LLDPPowerSource((o.Info[0] & 0x30) >> 4)
@@ -1114,18 +1766,21 @@ func (l *LinkLayerDiscoveryInfo) DecodeMedia() (info LLDPInfoMedia, err error) {
 			info.AssetID = string(o.Info)
 		}
 	}
+	err = lldpDecodeErrors(errs)
 	return
 }
 
 func (l *LinkLayerDiscoveryInfo) DecodeCisco2() (info LLDPInfoCisco2, err error) {
+	var errs []error
 	for _, o := range l.OrgTLVs {
 		if o.OUI != IEEEOUICisco2 {
 			continue
 		}
 		switch LLDPCisco2Subtype(o.SubType) {
 		case LLDPCisco2PowerViaMDI:
-			if err = checkLLDPOrgSpecificLen(o, 1); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 1); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.PSEFourWirePoESupported = (o.Info[0] & LLDPCiscoPSESupport) > 0
 			info.PDSparePairArchitectureShared = (o.Info[0] & LLDPCiscoArchShared) > 0
@@ -1133,18 +1788,21 @@ func (l *LinkLayerDiscoveryInfo) DecodeCisco2() (info LLDPInfoCisco2, err error)
 			info.PSESparePairPoEOn = (o.Info[0] & LLDPCiscoPSESparePair) > 0
 		}
 	}
+	err = lldpDecodeErrors(errs)
 	return
 }
 
 func (l *LinkLayerDiscoveryInfo) DecodeProfinet() (info LLDPInfoProfinet, err error) {
+	var errs []error
 	for _, o := range l.OrgTLVs {
 		if o.OUI != IEEEOUIProfinet {
 			continue
 		}
 		switch LLDPProfinetSubtype(o.SubType) {
 		case LLDPProfinetPNIODelay:
-			if err = checkLLDPOrgSpecificLen(o, 20); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 20); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.PNIODelay.RXLocal = binary.BigEndian.Uint32(o.Info[0:4])
 			info.PNIODelay.RXRemote = binary.BigEndian.Uint32(o.Info[4:8])
@@ -1152,25 +1810,29 @@ func (l *LinkLayerDiscoveryInfo) DecodeProfinet() (info LLDPInfoProfinet, err er
 			info.PNIODelay.TXRemote = binary.BigEndian.Uint32(o.Info[12:16])
 			info.PNIODelay.CableLocal = binary.BigEndian.Uint32(o.Info[16:20])
 		case LLDPProfinetPNIOPortStatus:
-			if err = checkLLDPOrgSpecificLen(o, 4); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 4); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.PNIOPortStatus.Class2 = binary.BigEndian.Uint16(o.Info[0:2])
 			info.PNIOPortStatus.Class3 = binary.BigEndian.Uint16(o.Info[2:4])
 		case LLDPProfinetPNIOMRPPortStatus:
-			if err = checkLLDPOrgSpecificLen(o, 18); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 18); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.PNIOMRPPortStatus.UUID = o.Info[0:16]
 			info.PNIOMRPPortStatus.Status = binary.BigEndian.Uint16(o.Info[16:18])
 		case LLDPProfinetPNIOChassisMAC:
-			if err = checkLLDPOrgSpecificLen(o, 6); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 6); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.ChassisMAC = o.Info[0:6]
 		case LLDPProfinetPNIOPTCPStatus:
-			if err = checkLLDPOrgSpecificLen(o, 54); err != nil {
-				return
+			if err := checkLLDPOrgSpecificLen(o, 54); err != nil {
+				errs = append(errs, err)
+				continue
 			}
 			info.PNIOPTCPStatus.MasterAddress = o.Info[0:6]
 			info.PNIOPTCPStatus.SubdomainUUID = o.Info[6:22]
@@ -1189,6 +1851,56 @@ func (l *LinkLayerDiscoveryInfo) DecodeProfinet() (info LLDPInfoProfinet, err er
 			info.PNIOPTCPStatus.GreenPeriodBegin = b & 0x7fffffff
 		}
 	}
+	err = lldpDecodeErrors(errs)
+	return
+}
+
+// LLDPOrgDecoder decodes the information string of an Organisation Specific
+// TLV with the given subtype.
+type LLDPOrgDecoder func(subtype uint8, info []byte) (interface{}, error)
+
+var (
+	lldpOrgDecodersMu sync.RWMutex
+	lldpOrgDecoders   = map[IEEEOUI]LLDPOrgDecoder{}
+)
+
+// RegisterLLDPOrgDecoder registers fn to decode the Organisation Specific TLVs
+// of the given OUI with DecodeOrg.  It's safe to call concurrently, e.g. from
+// init functions, and returns an error if a decoder is already registered for
+// the OUI.
+func RegisterLLDPOrgDecoder(oui IEEEOUI, fn LLDPOrgDecoder) error {
+	lldpOrgDecodersMu.Lock()
+	defer lldpOrgDecodersMu.Unlock()
+	if _, ok := lldpOrgDecoders[oui]; ok {
+		return fmt.Errorf("LLDP decoder for OUI %v already registered", oui)
+	}
+	lldpOrgDecoders[oui] = fn
+	return nil
+}
+
+// DecodeOrg decodes the Organisation Specific TLVs of the given OUI with the
+// decoder registered for it by RegisterLLDPOrgDecoder, returning a value for
+// each TLV that was decoded.
+func (l *LinkLayerDiscoveryInfo) DecodeOrg(oui IEEEOUI) (values []interface{}, err error) {
+	lldpOrgDecodersMu.RLock()
+	fn := lldpOrgDecoders[oui]
+	lldpOrgDecodersMu.RUnlock()
+	if fn == nil {
+		return nil, fmt.Errorf("No LLDP decoder registered for OUI %v", oui)
+	}
+	var errs []error
+	for _, o := range l.OrgTLVs {
+		if o.OUI != oui {
+			continue
+		}
+		v, err := fn(o.SubType, o.Info)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values = append(values, v)
+	}
+	err = lldpDecodeErrors(errs)
 	return
 }
 
@@ -1197,6 +1909,27 @@ func (c *LinkLayerDiscoveryInfo) LayerType() gopacket.LayerType {
 	return LayerTypeLinkLayerDiscoveryInfo
 }
 
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *LinkLayerDiscoveryInfo) CanDecode() gopacket.LayerClass {
+	return LayerTypeLinkLayerDiscoveryInfo
+}
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (c *LinkLayerDiscoveryInfo) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+// DecodeFromBytes decodes the details of the LLDPDU in data, as the
+// LinkLayerDiscovery layer and its DecodeInfo method would.
+func (c *LinkLayerDiscoveryInfo) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if err := c.lldp.DecodeFromBytes(data, df); err != nil {
+		return err
+	}
+	err := c.lldp.DecodeInfo(c)
+	c.BaseLayer = BaseLayer{Contents: data}
+	return err
+}
+
 func getCapabilities(v uint16) (c LLDPCapabilities) {
 	c.Other = (v&LLDPCapsOther > 0)
 	c.Repeater = (v&LLDPCapsRepeater > 0)
@@ -1212,8 +1945,53 @@ func getCapabilities(v uint16) (c LLDPCapabilities) {
 	return
 }
 
+// ToUint16 returns the capabilities as the bitmap of LLDPCaps* values used on
+// the wire.  It's the inverse of their decoding.
+func (c LLDPCapabilities) ToUint16() (v uint16) {
+	for _, b := range []struct {
+		set bool
+		bit uint16
+	}{
+		{c.Other, LLDPCapsOther},
+		{c.Repeater, LLDPCapsRepeater},
+		{c.Bridge, LLDPCapsBridge},
+		{c.WLANAP, LLDPCapsWLANAP},
+		{c.Router, LLDPCapsRouter},
+		{c.Phone, LLDPCapsPhone},
+		{c.DocSis, LLDPCapsDocSis},
+		{c.StationOnly, LLDPCapsStationOnly},
+		{c.CVLAN, LLDPCapsCVLAN},
+		{c.SVLAN, LLDPCapsSVLAN},
+		{c.TMPR, LLDPCapsTmpr},
+	} {
+		if b.set {
+			v |= b.bit
+		}
+	}
+	return
+}
+
+// ToUint16 returns the capabilities as the bitmap of LLDPEVBCaps* values used
+// on the wire.
+func (c LLDPEVBCapabilities) ToUint16() (v uint16) {
+	for _, b := range []struct {
+		set bool
+		bit uint16
+	}{
+		{c.StandardBridging, LLDPEVBCapsSTD},
+		{c.ReflectiveRelay, LLDPEVBCapsRR},
+		{c.RetransmissionTimerExponent, LLDPEVBCapsRTE},
+		{c.EdgeControlProtocol, LLDPEVBCapsECP},
+		{c.VSIDiscoveryProtocol, LLDPEVBCapsVDP},
+	} {
+		if b.set {
+			v |= b.bit
+		}
+	}
+	return
+}
+
 func getEVBCapabilities(v uint16) (c LLDPEVBCapabilities) {
-	c.StandardBridging = (v & LLDPEVBCapsSTD) > 0
 	c.StandardBridging = (v & LLDPEVBCapsSTD) > 0
 	c.ReflectiveRelay = (v & LLDPEVBCapsRR) > 0
 	c.RetransmissionTimerExponent = (v & LLDPEVBCapsRTE) > 0
@@ -1372,6 +2150,154 @@ func (t LLDPInterfaceSubtype) String() (s string) {
 	return
 }
 
+func (t LLDPMAUType) String() (s string) {
+	switch t {
+	case LLDPMAUTypeUnknown:
+		s = "Unknown"
+	case LLDPMAUTypeAUI:
+		s = "AUI"
+	case LLDPMAUType10Base5:
+		s = "10Base5"
+	case LLDPMAUTypeFOIRL:
+		s = "FOIRL"
+	case LLDPMAUType10Base2:
+		s = "10Base2"
+	case LLDPMAUType10BaseT:
+		s = "10BaseT"
+	case LLDPMAUType10BaseFP:
+		s = "10BaseFP"
+	case LLDPMAUType10BaseFB:
+		s = "10BaseFB"
+	case LLDPMAUType10BaseFL:
+		s = "10BaseFL"
+	case LLDPMAUType10BROAD36:
+		s = "10BROAD36"
+	case LLDPMAUType10BaseT_HD:
+		s = "10BaseT(HD)"
+	case LLDPMAUType10BaseT_FD:
+		s = "10BaseT(FD)"
+	case LLDPMAUType10BaseFL_HD:
+		s = "10BaseFL(HD)"
+	case LLDPMAUType10BaseFL_FD:
+		s = "10BaseFL(FD)"
+	case LLDPMAUType100BaseT4:
+		s = "100BaseT4"
+	case LLDPMAUType100BaseTX_HD:
+		s = "100BaseTX(HD)"
+	case LLDPMAUType100BaseTX_FD:
+		s = "100BaseTX(FD)"
+	case LLDPMAUType100BaseFX_HD:
+		s = "100BaseFX(HD)"
+	case LLDPMAUType100BaseFX_FD:
+		s = "100BaseFX(FD)"
+	case LLDPMAUType100BaseT2_HD:
+		s = "100BaseT2(HD)"
+	case LLDPMAUType100BaseT2_FD:
+		s = "100BaseT2(FD)"
+	case LLDPMAUType1000BaseX_HD:
+		s = "1000BaseX(HD)"
+	case LLDPMAUType1000BaseX_FD:
+		s = "1000BaseX(FD)"
+	case LLDPMAUType1000BaseLX_HD:
+		s = "1000BaseLX(HD)"
+	case LLDPMAUType1000BaseLX_FD:
+		s = "1000BaseLX(FD)"
+	case LLDPMAUType1000BaseSX_HD:
+		s = "1000BaseSX(HD)"
+	case LLDPMAUType1000BaseSX_FD:
+		s = "1000BaseSX(FD)"
+	case LLDPMAUType1000BaseCX_HD:
+		s = "1000BaseCX(HD)"
+	case LLDPMAUType1000BaseCX_FD:
+		s = "1000BaseCX(FD)"
+	case LLDPMAUType1000BaseT_HD:
+		s = "1000BaseT(HD)"
+	case LLDPMAUType1000BaseT_FD:
+		s = "1000BaseT(FD)"
+	case LLDPMAUType10GBaseX:
+		s = "10GBaseX"
+	case LLDPMAUType10GBaseLX4:
+		s = "10GBaseLX4"
+	case LLDPMAUType10GBaseR:
+		s = "10GBaseR"
+	case LLDPMAUType10GBaseER:
+		s = "10GBaseER"
+	case LLDPMAUType10GBaseLR:
+		s = "10GBaseLR"
+	case LLDPMAUType10GBaseSR:
+		s = "10GBaseSR"
+	case LLDPMAUType10GBaseW:
+		s = "10GBaseW"
+	case LLDPMAUType10GBaseEW:
+		s = "10GBaseEW"
+	case LLDPMAUType10GBaseLW:
+		s = "10GBaseLW"
+	case LLDPMAUType10GBaseSW:
+		s = "10GBaseSW"
+	case LLDPMAUType10GBaseCX4:
+		s = "10GBaseCX4"
+	case LLDPMAUType2BaseTL:
+		s = "2BaseTL"
+	case LLDPMAUType10PASS_TS:
+		s = "10PASS-TS"
+	case LLDPMAUType100BaseBX10D:
+		s = "100BaseBX10D"
+	case LLDPMAUType100BaseBX10U:
+		s = "100BaseBX10U"
+	case LLDPMAUType100BaseLX10:
+		s = "100BaseLX10"
+	case LLDPMAUType1000BaseBX10D:
+		s = "1000BaseBX10D"
+	case LLDPMAUType1000BaseBX10U:
+		s = "1000BaseBX10U"
+	case LLDPMAUType1000BaseLX10:
+		s = "1000BaseLX10"
+	case LLDPMAUType1000BasePX10D:
+		s = "1000BasePX10D"
+	case LLDPMAUType1000BasePX10U:
+		s = "1000BasePX10U"
+	case LLDPMAUType1000BasePX20D:
+		s = "1000BasePX20D"
+	case LLDPMAUType1000BasePX20U:
+		s = "1000BasePX20U"
+	case LLDPMAUType10GBaseT:
+		s = "10GBaseT"
+	case LLDPMAUType10GBaseLRM:
+		s = "10GBaseLRM"
+	case LLDPMAUType1000BaseKX:
+		s = "1000BaseKX"
+	case LLDPMAUType10GBaseKX4:
+		s = "10GBaseKX4"
+	case LLDPMAUType10GBaseKR:
+		s = "10GBaseKR"
+	case LLDPMAUType10_1GBasePRX_D1:
+		s = "10/1GBasePRX-D1"
+	case LLDPMAUType10_1GBasePRX_D2:
+		s = "10/1GBasePRX-D2"
+	case LLDPMAUType10_1GBasePRX_D3:
+		s = "10/1GBasePRX-D3"
+	case LLDPMAUType10_1GBasePRX_U1:
+		s = "10/1GBasePRX-U1"
+	case LLDPMAUType10_1GBasePRX_U2:
+		s = "10/1GBasePRX-U2"
+	case LLDPMAUType10_1GBasePRX_U3:
+		s = "10/1GBasePRX-U3"
+	case LLDPMAUType10GBasePR_D1:
+		s = "10GBasePR-D1"
+	case LLDPMAUType10GBasePR_D2:
+		s = "10GBasePR-D2"
+	case LLDPMAUType10GBasePR_D3:
+		s = "10GBasePR-D3"
+	case LLDPMAUType10GBasePR_U1:
+		s = "10GBasePR-U1"
+	case LLDPMAUType10GBasePR_U3:
+		s = "10GBasePR-U3"
+	default:
+		s = "Unknown"
+	}
+	return
+}
+
 func (t LLDPPowerType) String() (s string) {
 	switch t {
 	case 0:
@@ -1570,6 +2496,177 @@ func (t LLDPLocationAddressType) String() (s string) {
 	return
 }
 
+func (t IEEEOUI) String() (s string) {
+	switch t {
+	case IEEEOUI8021:
+		s = "IEEE 802.1"
+	case IEEEOUI8023:
+		s = "IEEE 802.3"
+	case IEEEOUI80211:
+		s = "IEEE 802.11"
+	case IEEEOUI8021Qbg:
+		s = "IEEE 802.1Qbg"
+	case IEEEOUICisco2:
+		s = "Cisco"
+	case IEEEOUIMedia:
+		s = "TIA TR-41"
+	case IEEEOUIProfinet:
+		s = "Profinet"
+	case IEEEOUIDCBX:
+		s = "DCBX"
+	default:
+		s = fmt.Sprintf("%06x", uint32(t))
+	}
+	return
+}
+
+// lldpAddressString formats an address of the given family as an IP or MAC
+// address where possible.
+func lldpAddressString(family IANAAddressFamily, addr []byte) string {
+	switch {
+	case family == IANAAddressFamilyIPV4 && len(addr) == net.IPv4len,
+		family == IANAAddressFamilyIPV6 && len(addr) == net.IPv6len:
+		return net.IP(addr).String()
+	case family == IANAAddressFamily802 && len(addr) == 6:
+		return net.HardwareAddr(addr).String()
+	}
+	return fmt.Sprintf("%v %x", family, addr)
+}
+
+// lldpIDString formats a chassis or port ID that's a name as text if it's
+// printable, and in hex otherwise.
+func lldpIDString(id []byte) string {
+	for _, r := range string(id) {
+		if !unicode.IsPrint(r) {
+			return fmt.Sprintf("%x", id)
+		}
+	}
+	return string(id)
+}
+
+// String returns the subtype and the ID, formatted according to the subtype:
+// MAC and network addresses in their usual notation, names as text, and
+// anything else in hex.
+func (c LLDPChassisID) String() string {
+	switch {
+	case c.Subtype == LLDPChassisIDSubTypeMACAddr && len(c.ID) == 6:
+		return fmt.Sprintf("%v: %v", c.Subtype, net.HardwareAddr(c.ID))
+	case c.Subtype == LLDPChassisIDSubTypeNetworkAddr && len(c.ID) > 0:
+		return fmt.Sprintf("%v: %v", c.Subtype, lldpAddressString(IANAAddressFamily(c.ID[0]), c.ID[1:]))
+	case c.Subtype == LLDPChassisIDSubtypeIfaceAlias, c.Subtype == LLDPChassisIDSubtypeIfaceName,
+		c.Subtype == LLDPChassisIDSubTypeLocal:
+		return fmt.Sprintf("%v: %v", c.Subtype, lldpIDString(c.ID))
+	}
+	return fmt.Sprintf("%v: %x", c.Subtype, c.ID)
+}
+
+// String returns the subtype and the ID, formatted according to the subtype:
+// MAC and network addresses in their usual notation, names as text, and
+// anything else in hex.
+func (c LLDPPortID) String() string {
+	switch {
+	case c.Subtype == LLDPPortIDSubtypeMACAddr && len(c.ID) == 6:
+		return fmt.Sprintf("%v: %v", c.Subtype, net.HardwareAddr(c.ID))
+	case c.Subtype == LLDPPortIDSubtypeNetworkAddr && len(c.ID) > 0:
+		return fmt.Sprintf("%v: %v", c.Subtype, lldpAddressString(IANAAddressFamily(c.ID[0]), c.ID[1:]))
+	case c.Subtype == LLDPPortIDSubtypeIfaceAlias, c.Subtype == LLDPPortIDSubtypeIfaceName,
+		c.Subtype == LLDPPortIDSubtypeLocal:
+		return fmt.Sprintf("%v: %v", c.Subtype, lldpIDString(c.ID))
+	}
+	return fmt.Sprintf("%v: %x", c.Subtype, c.ID)
+}
+
+// String returns the set capabilities as a comma-separated list.
+func (c LLDPCapabilities) String() string {
+	var caps []string
+	for _, c := range []struct {
+		set  bool
+		name string
+	}{
+		{c.Other, "Other"},
+		{c.Repeater, "Repeater"},
+		{c.Bridge, "Bridge"},
+		{c.WLANAP, "WLAN AP"},
+		{c.Router, "Router"},
+		{c.Phone, "Phone"},
+		{c.DocSis, "DOCSIS"},
+		{c.StationOnly, "Station Only"},
+		{c.CVLAN, "C-VLAN"},
+		{c.SVLAN, "S-VLAN"},
+		{c.TMPR, "TPMR"},
+	} {
+		if c.set {
+			caps = append(caps, c.name)
+		}
+	}
+	return strings.Join(caps, ", ")
+}
+
+func (m LLDPMgmtAddress) String() string {
+	if len(m.Address) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v (%v %d)", lldpAddressString(m.Subtype, m.Address), m.InterfaceSubtype, m.InterfaceNumber)
+}
+
+func (o LLDPOrgSpecificTLV) String() string {
+	return fmt.Sprintf("%v subtype %d (%d bytes)", o.OUI, o.SubType, len(o.Info))
+}
+
+func (c *LinkLayerDiscovery) String() string {
+	return fmt.Sprintf("ChassisID=%v PortID=%v TTL=%d Values=%d", c.ChassisID, c.PortID, c.TTL, len(c.Values))
+}
+
+func (l *LinkLayerDiscoveryInfo) String() string {
+	var b bytes.Buffer
+	// Some devices NUL-terminate their strings.
+	fmt.Fprintf(&b, "SysName=%q SysDescription=%q PortDescription=%q",
+		strings.TrimRight(l.SysName, "\x00"), strings.TrimRight(l.SysDescription, "\x00"), strings.TrimRight(l.PortDescription, "\x00"))
+	fmt.Fprintf(&b, " SystemCap=[%v] EnabledCap=[%v]", l.SysCapabilities.SystemCap, l.SysCapabilities.EnabledCap)
+	if mgmt := l.MgmtAddress.String(); mgmt != "" {
+		fmt.Fprintf(&b, " MgmtAddress=%s", mgmt)
+	}
+	if len(l.OrgTLVs) > 0 {
+		orgs := make([]string, len(l.OrgTLVs))
+		for i, o := range l.OrgTLVs {
+			orgs[i] = o.String()
+		}
+		fmt.Fprintf(&b, " OrgTLVs=[%s]", strings.Join(orgs, ", "))
+	}
+	if len(l.Unknown) > 0 {
+		fmt.Fprintf(&b, " Unknown=%d", len(l.Unknown))
+	}
+	return b.String()
+}
+
+// LLDPDecodeErrors is returned when some TLVs of a LinkLayerDiscovery can't
+// be decoded.  The TLVs that could be are still decoded.
+type LLDPDecodeErrors struct {
+	errs []error
+}
+
+// Errors returns the errors for each malformed TLV, in the order they were
+// found.
+func (e *LLDPDecodeErrors) Errors() []error {
+	return e.errs
+}
+
+func (e *LLDPDecodeErrors) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// lldpDecodeErrors returns errs as an *LLDPDecodeErrors, or nil if it's empty.
+func lldpDecodeErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &LLDPDecodeErrors{errs}
+}
+
 func checkLLDPTLVLen(v LinkLayerDiscoveryValue, l int) (err error) {
 	if len(v.Value) < l {
 		err = fmt.Errorf("Invalid TLV %v length %d (wanted mimimum %v", v.Type, len(v.Value), l)
@@ -0,0 +1,1016 @@
+// Copyright 2012 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testLLDPDetailed is the LLDPDU of the first frame of lldp.detailed.pcap,
+// from http://wiki.wireshark.org/SampleCaptures.
+var testLLDPDetailed = []byte{
+	0x02, 0x07, 0x04, 0x00, 0x01, 0x30, 0xf9, 0xad, 0xa0, 0x04, 0x04, 0x05,
+	0x31, 0x2f, 0x31, 0x06, 0x02, 0x00, 0x78, 0x08, 0x17, 0x53, 0x75, 0x6d,
+	0x6d, 0x69, 0x74, 0x33, 0x30, 0x30, 0x2d, 0x34, 0x38, 0x2d, 0x50, 0x6f,
+	0x72, 0x74, 0x20, 0x31, 0x30, 0x30, 0x31, 0x00, 0x0a, 0x0d, 0x53, 0x75,
+	0x6d, 0x6d, 0x69, 0x74, 0x33, 0x30, 0x30, 0x2d, 0x34, 0x38, 0x00, 0x0c,
+	0x4c, 0x53, 0x75, 0x6d, 0x6d, 0x69, 0x74, 0x33, 0x30, 0x30, 0x2d, 0x34,
+	0x38, 0x20, 0x2d, 0x20, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x20,
+	0x37, 0x2e, 0x34, 0x65, 0x2e, 0x31, 0x20, 0x28, 0x42, 0x75, 0x69, 0x6c,
+	0x64, 0x20, 0x35, 0x29, 0x20, 0x62, 0x79, 0x20, 0x52, 0x65, 0x6c, 0x65,
+	0x61, 0x73, 0x65, 0x5f, 0x4d, 0x61, 0x73, 0x74, 0x65, 0x72, 0x20, 0x30,
+	0x35, 0x2f, 0x32, 0x37, 0x2f, 0x30, 0x35, 0x20, 0x30, 0x34, 0x3a, 0x35,
+	0x33, 0x3a, 0x31, 0x31, 0x00, 0x0e, 0x04, 0x00, 0x14, 0x00, 0x14, 0x10,
+	0x0e, 0x07, 0x06, 0x00, 0x01, 0x30, 0xf9, 0xad, 0xa0, 0x02, 0x00, 0x00,
+	0x03, 0xe9, 0x00, 0xfe, 0x07, 0x00, 0x12, 0x0f, 0x02, 0x07, 0x01, 0x00,
+	0xfe, 0x09, 0x00, 0x12, 0x0f, 0x01, 0x03, 0x6c, 0x00, 0x00, 0x10, 0xfe,
+	0x09, 0x00, 0x12, 0x0f, 0x03, 0x01, 0x00, 0x00, 0x00, 0x00, 0xfe, 0x06,
+	0x00, 0x12, 0x0f, 0x04, 0x05, 0xf2, 0xfe, 0x06, 0x00, 0x80, 0xc2, 0x01,
+	0x01, 0xe8, 0xfe, 0x07, 0x00, 0x80, 0xc2, 0x02, 0x01, 0x00, 0x00, 0xfe,
+	0x17, 0x00, 0x80, 0xc2, 0x03, 0x01, 0xe8, 0x10, 0x76, 0x32, 0x2d, 0x30,
+	0x34, 0x38, 0x38, 0x2d, 0x30, 0x33, 0x2d, 0x30, 0x35, 0x30, 0x35, 0x00,
+	0xfe, 0x05, 0x00, 0x80, 0xc2, 0x04, 0x00, 0x00, 0x00,
+}
+
+func TestLLDPSerializeRoundTrip(t *testing.T) {
+	p := gopacket.NewPacket(testLLDPDetailed, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	lldp := p.Layer(LayerTypeLinkLayerDiscovery).(*LinkLayerDiscovery)
+	buf := gopacket.NewSerializeBuffer()
+	if err := lldp.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testLLDPDetailed) {
+		t.Errorf("LLDP round trip mismatch:\ngot  %x\nwant %x", buf.Bytes(), testLLDPDetailed)
+	}
+}
+
+func TestLLDPSerializeValues(t *testing.T) {
+	long := make([]byte, 300)
+	lldp := &LinkLayerDiscovery{
+		ChassisID: LLDPChassisID{LLDPChassisIDSubTypeLocal, []byte("c")},
+		PortID:    LLDPPortID{LLDPPortIDSubtypeLocal, []byte("p")},
+		TTL:       120,
+		Values: []LinkLayerDiscoveryValue{
+			{Type: LLDPTLVSysName, Value: []byte("switch")},
+			{Type: LLDPTLVSysDescription, Value: long},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := lldp.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x02, 0x02, 0x07, 'c', 0x04, 0x02, 0x07, 'p', 0x06, 0x02, 0x00, 0x78}
+	want = append(want, 0x0a, 0x06, 's', 'w', 'i', 't', 'c', 'h', 0x0d, 0x2c)
+	want = append(append(want, long...), 0x00, 0x00)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got  %x\nwant %x", buf.Bytes(), want)
+	}
+
+	for _, v := range []LinkLayerDiscoveryValue{
+		{Type: LLDPTLVSysDescription, Value: make([]byte, 512)},
+		{Type: LLDPTLVSysName, Length: 3, Value: []byte("ab")},
+		{Type: LLDPTLVEnd},
+	} {
+		lldp.Values = []LinkLayerDiscoveryValue{v}
+		if err := lldp.SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{}); err == nil {
+			t.Errorf("%v: expected error", v.Type)
+		}
+	}
+}
+
+func TestLLDPDecodeMedia(t *testing.T) {
+	info := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{
+		{OUI: IEEEOUIMedia, SubType: uint8(LLDPMediaTypeNetwork), Info: []byte{0x01, 0x40, 0x64, 0x2e}},
+		{OUI: IEEEOUIMedia, SubType: uint8(LLDPMediaTypeNetwork), Info: []byte{0x02, 0x40, 0x64, 0x18}},
+		{OUI: IEEEOUIMedia, SubType: uint8(LLDPMediaTypeLocation), Info: []byte{0x01,
+			0x89, 0x23, 0x45, 0x67, 0x89, 0x8a, 0xab, 0xcd, 0xef, 0x01, 0x17, 0x81, 0x23, 0x45, 0x67, 0x01}},
+		{OUI: IEEEOUIMedia, SubType: uint8(LLDPMediaTypeSerial), Info: []byte("SN1")},
+	}}
+	media, err := info.DecodeMedia()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPolicies := []LLDPNetworkPolicy{
+		{LLDPAppTypeVoice, true, true, 50, 0, 46},
+		{LLDPappTypeVoiceSignaling, true, true, 50, 0, 24},
+	}
+	if !reflect.DeepEqual(media.NetworkPolicies, wantPolicies) || media.NetworkPolicy != wantPolicies[1] {
+		t.Errorf("network policies %+v, want %+v", media.NetworkPolicies, wantPolicies)
+	}
+	wantCoord := LLDPLocationCoordinate{
+		LatitudeResolution:  34,
+		Latitude:            0x123456789,
+		LongitudeResolution: 34,
+		Longitude:           0x2abcdef01,
+		AltitudeType:        1,
+		AltitudeResolution:  30,
+		Altitude:            0x1234567,
+		Datum:               1,
+	}
+	if media.Location.Format != LLDPLocationFormatCoordinate || media.Location.Coordinate != wantCoord {
+		t.Errorf("coordinate %+v, want %+v", media.Location.Coordinate, wantCoord)
+	}
+	if media.SerialNumber != "SN1" {
+		t.Errorf("serial number %q", media.SerialNumber)
+	}
+
+	for _, loc := range [][]byte{
+		{0x02, 0x02, 0x02, 'U', 'S'},
+		{0x02, 0x09, 0x02, 'U', 'S', 0x03, 0x01, 'X'},
+		{0x02, 0x03},
+	} {
+		info.OrgTLVs = []LLDPOrgSpecificTLV{{OUI: IEEEOUIMedia, SubType: uint8(LLDPMediaTypeLocation), Info: loc}}
+		if _, err := info.DecodeMedia(); err == nil {
+			t.Errorf("%x: expected error", loc)
+		}
+	}
+	info.OrgTLVs[0].Info = []byte{0x03, 'E', 'L', 'I', 'N'}
+	if media, err = info.DecodeMedia(); err != nil || media.Location.ECS.ELIN != "ELIN" {
+		t.Errorf("ELIN %q, error %v", media.Location.ECS.ELIN, err)
+	}
+}
+
+func TestLLDPDecode8023PowerViaMDI(t *testing.T) {
+	// An 802.3at power via MDI TLV from a type 2 PSE, granting the 25.5W a
+	// class 4 PD requested.
+	tlv := []byte{0xfe, 0x0c, 0x00, 0x12, 0x0f, 0x02, 0x0f, 0x01, 0x05, 0x11, 0x00, 0xff, 0x00, 0xff}
+	data := []byte{0x02, 0x02, 0x07, 'c', 0x04, 0x02, 0x07, 'p', 0x06, 0x02, 0x00, 0x78}
+	data = append(append(data, tlv...), 0x00, 0x00)
+	p := gopacket.NewPacket(data, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	info := p.Layer(LayerTypeLinkLayerDiscoveryInfo).(*LinkLayerDiscoveryInfo)
+	info8023, err := info.Decode8023()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LLDPPowerViaMDI8023{
+		PortClassPSE:    true,
+		PSESupported:    true,
+		PSEEnabled:      true,
+		PSEPairsAbility: true,
+		PSEPowerPair:    1,
+		PSEClass:        5,
+		Type:            0,
+		Source:          1,
+		Priority:        LLDPPowerPriorityMedium,
+		Requested:       255,
+		Allocated:       255,
+	}
+	if info8023.PowerViaMDI != want {
+		t.Errorf("power via MDI %+v, want %+v", info8023.PowerViaMDI, want)
+	}
+
+	// A TLV cut short in the 802.3at extension keeps only the basic fields.
+	info.OrgTLVs[0].Info = info.OrgTLVs[0].Info[:7]
+	if info8023, err = info.Decode8023(); err != nil || info8023.PowerViaMDI.PSEClass != 5 || info8023.PowerViaMDI.Allocated != 0 {
+		t.Errorf("short TLV decoded as %+v, error %v", info8023.PowerViaMDI, err)
+	}
+}
+
+func TestLLDPDecodeProfinet(t *testing.T) {
+	// Profinet TLVs laid out as an S7 PLC port advertises them.
+	data := []byte{0x02, 0x07, 0x04, 0x00, 0x1b, 0x1b, 0x01, 0x02, 0x03, 0x04, 0x09, 0x05, 'p', 'o', 'r', 't', '-', '0', '0', '1', 0x06, 0x02, 0x00, 0x14}
+	data = append(data, 0xfe, 0x18, 0x00, 0x0e, 0xcf, 0x01, // Delay
+		0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05)
+	data = append(data, 0xfe, 0x08, 0x00, 0x0e, 0xcf, 0x02, 0x00, 0x00, 0x10, 0x02) // Port status
+	data = append(data, 0xfe, 0x16, 0x00, 0x0e, 0xcf, 0x04,                         // MRP port status
+		0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00, 0x00, 0x01)
+	data = append(data, 0xfe, 0x0a, 0x00, 0x0e, 0xcf, 0x05, 0x00, 0x1b, 0x1b, 0x01, 0x02, 0x00) // Chassis MAC
+	data = append(data, 0x00, 0x00)
+	p := gopacket.NewPacket(data, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	info := p.Layer(LayerTypeLinkLayerDiscoveryInfo).(*LinkLayerDiscoveryInfo)
+	pn, err := info.DecodeProfinet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LLDPInfoProfinet{
+		PNIODelay:      LLDPPNIODelay{RXLocal: 0x10, TXLocal: 0x20, CableLocal: 5},
+		PNIOPortStatus: LLDPPNIOPortStatus{Class2: 0, Class3: 0x1002},
+		PNIOMRPPortStatus: LLDPPNIOMRPPortStatus{
+			UUID:   []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00},
+			Status: 1,
+		},
+		ChassisMAC: []byte{0x00, 0x1b, 0x1b, 0x01, 0x02, 0x00},
+	}
+	if !reflect.DeepEqual(pn, want) {
+		t.Errorf("Profinet mismatch, \ngot  %#v\nwant %#v\n", pn, want)
+	}
+
+	info.OrgTLVs[0].Info = info.OrgTLVs[0].Info[:19]
+	if _, err := info.DecodeProfinet(); err == nil {
+		t.Error("expected error decoding short delay TLV")
+	}
+}
+
+func TestLLDPDecodeCisco2(t *testing.T) {
+	for _, test := range []struct {
+		info []byte
+		want LLDPInfoCisco2
+	}{
+		{[]byte{0x01}, LLDPInfoCisco2{PSEFourWirePoESupported: true}},
+		{[]byte{0x0e}, LLDPInfoCisco2{PDSparePairArchitectureShared: true, PDRequestSparePairPoEOn: true, PSESparePairPoEOn: true}},
+		// Newer switches append UPOE negotiation data, which is ignored.
+		{[]byte{0x0d, 0x00, 0x3c, 0x00, 0x3c}, LLDPInfoCisco2{PSEFourWirePoESupported: true, PDRequestSparePairPoEOn: true, PSESparePairPoEOn: true}},
+	} {
+		info := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{
+			{OUI: IEEEOUICisco2, SubType: uint8(LLDPCisco2PowerViaMDI), Info: test.info},
+		}}
+		got, err := info.DecodeCisco2()
+		if err != nil || got != test.want {
+			t.Errorf("%x: got %+v, error %v, want %+v", test.info, got, err, test.want)
+		}
+	}
+	info := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{{OUI: IEEEOUICisco2, SubType: uint8(LLDPCisco2PowerViaMDI)}}}
+	if _, err := info.DecodeCisco2(); err == nil {
+		t.Error("expected error decoding empty Cisco power TLV")
+	}
+}
+
+func TestLLDPString(t *testing.T) {
+	p := gopacket.NewPacket(testLLDPDetailed, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	lldp := p.Layer(LayerTypeLinkLayerDiscovery)
+	if got, want := gopacket.LayerString(lldp), "LinkLayerDiscovery\tChassisID=MAC Address: 00:01:30:f9:ad:a0 PortID=Interface Name: 1/1 TTL=120 Values=13"; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	info := p.Layer(LayerTypeLinkLayerDiscoveryInfo)
+	want := `LinkLayerDiscoveryInfo	SysName="Summit300-48" ` +
+		`SysDescription="Summit300-48 - Version 7.4e.1 (Build 5) by Release_Master 05/27/05 04:53:11" ` +
+		`PortDescription="Summit300-48-Port 1001" SystemCap=[Bridge, Router] EnabledCap=[Bridge, Router] ` +
+		`MgmtAddress=00:01:30:f9:ad:a0 (IfIndex 1001) ` +
+		`OrgTLVs=[IEEE 802.3 subtype 2 (3 bytes), IEEE 802.3 subtype 1 (5 bytes), IEEE 802.3 subtype 3 (5 bytes), ` +
+		`IEEE 802.3 subtype 4 (2 bytes), IEEE 802.1 subtype 1 (2 bytes), IEEE 802.1 subtype 2 (3 bytes), ` +
+		`IEEE 802.1 subtype 3 (19 bytes), IEEE 802.1 subtype 4 (1 bytes)]`
+	if got := gopacket.LayerString(info); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	for _, test := range []struct {
+		id   LLDPPortID
+		want string
+	}{
+		{LLDPPortID{LLDPPortIDSubtypeReserved, []byte{0, 1}}, "Reserved: 0001"},
+		{LLDPPortID{LLDPPortIDSubtypeIfaceAlias, []byte("uplink")}, "Interface Alias: uplink"},
+		{LLDPPortID{LLDPPortIDSubtypePortComp, []byte("pc")}, "Port Component: 7063"},
+		{LLDPPortID{LLDPPortIDSubtypeMACAddr, []byte{0, 1, 2, 3, 4, 5}}, "MAC Address: 00:01:02:03:04:05"},
+		{LLDPPortID{LLDPPortIDSubtypeMACAddr, []byte{0, 1}}, "MAC Address: 0001"},
+		{LLDPPortID{LLDPPortIDSubtypeNetworkAddr, []byte{1, 10, 0, 0, 1}}, "Network Address: 10.0.0.1"},
+		{LLDPPortID{LLDPPortIDSubtypeIfaceName, []byte("Gi1/0/24")}, "Interface Name: Gi1/0/24"},
+		{LLDPPortID{LLDPPortIDSubtypeAgentCircuitID, []byte{0x12}}, "Agent Circuit ID: 12"},
+		{LLDPPortID{LLDPPortIDSubtypeLocal, []byte("Gi1/0/24")}, "Local: Gi1/0/24"},
+		{LLDPPortID{LLDPPortIDSubtypeLocal, []byte{0, 1}}, "Local: 0001"},
+	} {
+		if got := test.id.String(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		id   LLDPChassisID
+		want string
+	}{
+		{LLDPChassisID{LLDPChassisIDSubTypeReserved, []byte{0, 1}}, "Reserved: 0001"},
+		{LLDPChassisID{LLDPChassisIDSubTypeChassisComp, []byte("cc")}, "Chassis Component: 6363"},
+		{LLDPChassisID{LLDPChassisIDSubtypeIfaceAlias, []byte("uplink")}, "Interface Alias: uplink"},
+		{LLDPChassisID{LLDPChassisIDSubTypePortComp, []byte("pc")}, "Port Component: 7063"},
+		{LLDPChassisID{LLDPChassisIDSubTypeMACAddr, []byte{0, 1, 2, 3, 4, 5}}, "MAC Address: 00:01:02:03:04:05"},
+		{LLDPChassisID{LLDPChassisIDSubTypeNetworkAddr, []byte{2, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}}, "Network Address: 2001:db8::1"},
+		{LLDPChassisID{LLDPChassisIDSubtypeIfaceName, []byte("eth0")}, "Interface Name: eth0"},
+		{LLDPChassisID{LLDPChassisIDSubTypeLocal, []byte("switch1")}, "Local: switch1"},
+	} {
+		if got := test.id.String(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestLLDPDecodingLayer(t *testing.T) {
+	var lldp LinkLayerDiscovery
+	parser := gopacket.NewDecodingLayerParser(LayerTypeLinkLayerDiscovery, &lldp)
+	decoded := []gopacket.LayerType{}
+	if err := parser.DecodeLayers(testLLDPDetailed, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(testLLDPDetailed, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if want := p.Layer(LayerTypeLinkLayerDiscovery).(*LinkLayerDiscovery); !reflect.DeepEqual(&lldp, want) {
+		t.Errorf("DecodeFromBytes mismatch, \ngot  %#v\nwant %#v\n", &lldp, want)
+	}
+	var info LinkLayerDiscoveryInfo
+	if err := lldp.DecodeInfo(&info); err != nil {
+		t.Fatal(err)
+	}
+	if want := p.Layer(LayerTypeLinkLayerDiscoveryInfo).(*LinkLayerDiscoveryInfo); !reflect.DeepEqual(&info, want) {
+		t.Errorf("DecodeInfo mismatch, \ngot  %#v\nwant %#v\n", &info, want)
+	}
+
+	if allocs := testing.AllocsPerRun(10, func() {
+		lldp.DecodeFromBytes(testLLDPDetailed, gopacket.NilDecodeFeedback)
+	}); allocs != 0 {
+		t.Errorf("DecodeFromBytes allocated %v times", allocs)
+	}
+}
+
+func TestLLDPTruncated(t *testing.T) {
+	// Cutting the LLDPDU between TLVs after the TTL only loses the End TLV.
+	var full LinkLayerDiscovery
+	if err := full.DecodeFromBytes(testLLDPDetailed, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	boundaries := map[int]bool{len(testLLDPDetailed) - 2: true}
+	for _, v := range full.Values {
+		boundaries[v.Offset] = true
+	}
+	for i := 0; i < len(testLLDPDetailed); i++ {
+		data := testLLDPDetailed[:i]
+		var lldp LinkLayerDiscovery
+		err := lldp.DecodeFromBytes(data, gopacket.NilDecodeFeedback)
+		if boundaries[i] {
+			if err != nil || !lldp.MissingEnd {
+				t.Errorf("%d bytes: got error %v, missing End %v", i, err, lldp.MissingEnd)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%d bytes: expected error", i)
+		}
+		p := gopacket.NewPacket(data, LayerTypeLinkLayerDiscovery, gopacket.DecodeOptions{SkipDecodeRecovery: true})
+		if p.ErrorLayer() == nil {
+			t.Errorf("%d bytes: expected decode error", i)
+		}
+	}
+}
+
+func TestLLDPMissingEnd(t *testing.T) {
+	data := []byte{
+		0x02, 0x07, 0x04, 0x00, 0x40, 0x8c, 0x12, 0x34, 0x56,
+		0x04, 0x07, 0x03, 0x00, 0x40, 0x8c, 0x12, 0x34, 0x56,
+		0x06, 0x02, 0x00, 0x78,
+		0x0a, 0x06, 'c', 'a', 'm', 'e', 'r', 'a',
+	}
+	p := gopacket.NewPacket(data, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	lldp := p.Layer(LayerTypeLinkLayerDiscovery).(*LinkLayerDiscovery)
+	if !lldp.MissingEnd {
+		t.Error("MissingEnd not set")
+	}
+	if err := lldp.Validate(); err == nil {
+		t.Error("expected validation error for missing End TLV")
+	}
+	info := p.Layer(LayerTypeLinkLayerDiscoveryInfo).(*LinkLayerDiscoveryInfo)
+	if info.SysName != "camera" {
+		t.Errorf("got system name %q", info.SysName)
+	}
+
+	// Without the TTL, it's still an error.
+	data = data[:18]
+	p = gopacket.NewPacket(data, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("expected error for missing TTL")
+	}
+}
+
+func TestLLDPDecode8021VLANName(t *testing.T) {
+	// A switch padding VLAN names to 32 bytes.
+	padded := append([]byte{0x00, 0x64, 0x07}, "default"...)
+	padded = append(padded, make([]byte, 32-7)...)
+	info := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{
+		{OUI: IEEEOUI8021, SubType: LLDP8021SubtypeVLANName, Info: padded},
+		{OUI: IEEEOUI8021, SubType: LLDP8021SubtypeVLANName, Info: []byte{0x00, 0xc8, 0x00}},
+	}}
+	info8021, err := info.Decode8021()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VLANName{{100, "default"}, {200, ""}}
+	if !reflect.DeepEqual(info8021.VLANNames, want) {
+		t.Errorf("VLAN names %+v, want %+v", info8021.VLANNames, want)
+	}
+
+	for _, data := range [][]byte{{0x00, 0x64}, {0x00, 0x64, 0x08, 'd', 'e', 'f', 'a', 'u', 'l', 't'}} {
+		info.OrgTLVs = []LLDPOrgSpecificTLV{{OUI: IEEEOUI8021, SubType: LLDP8021SubtypeVLANName, Info: data}}
+		if _, err := info.Decode8021(); err == nil {
+			t.Errorf("%x: expected error", data)
+		}
+	}
+}
+
+func TestLLDPMACPHYConfigStatus(t *testing.T) {
+	c := LLDPMACPHYConfigStatus{true, true, 0x6c00, LLDPMAUType100BaseTX_FD}
+	if got, want := c.MAUType.String(), "100BaseTX(FD)"; got != want {
+		t.Errorf("MAUType: got %q, want %q", got, want)
+	}
+	if got, want := LLDPMAUType(200).String(), "Unknown"; got != want {
+		t.Errorf("MAUType 200: got %q, want %q", got, want)
+	}
+	want := []string{"10BaseT", "10BaseT(FD)", "100BaseTX", "100BaseTX(FD)"}
+	if got := c.AutoNegCapabilities(false); !reflect.DeepEqual(got, want) {
+		t.Errorf("AutoNegCapabilities: got %q, want %q", got, want)
+	}
+	want = []string{"FDX Symmetric PAUSE", "FDX Asymmetric and Symmetric PAUSE", "1000BaseX(FD)", "1000BaseT"}
+	if got := c.AutoNegCapabilities(true); !reflect.DeepEqual(got, want) {
+		t.Errorf("AutoNegCapabilities inverted: got %q, want %q", got, want)
+	}
+}
+
+func TestNewLinkLayerDiscovery(t *testing.T) {
+	caps := LLDPSysCapabilities{
+		SystemCap:  LLDPCapabilities{Bridge: true, Router: true},
+		EnabledCap: LLDPCapabilities{Bridge: true},
+	}
+	mgmt := LLDPMgmtAddress{
+		Subtype:          IANAAddressFamilyIPV4,
+		Address:          []byte{192, 168, 0, 1},
+		InterfaceSubtype: LLDPInterfaceSubtypeifIndex,
+		InterfaceNumber:  3,
+	}
+	org := LLDPOrgSpecificTLV{OUI: IEEEOUI8021, SubType: LLDP8021SubtypePortVLANID, Info: []byte{0x00, 0x64}}
+	want := NewLinkLayerDiscovery(
+		LLDPChassisID{LLDPChassisIDSubTypeMACAddr, []byte{0x00, 0x01, 0x30, 0xf9, 0xad, 0xa0}},
+		LLDPPortID{LLDPPortIDSubtypeIfaceName, []byte("ge-0/0/1")},
+		120,
+		LLDPWithOrgSpecific(org),
+		LLDPWithMgmtAddress(mgmt),
+		LLDPWithCapabilities(caps),
+		LLDPWithSysDescription("gopacket"),
+		LLDPWithSysName("switch1"),
+		LLDPWithPortDescription("uplink"),
+	)
+	for i, typ := range []LLDPTLVType{LLDPTLVPortDescription, LLDPTLVSysName, LLDPTLVSysDescription, LLDPTLVSysCapabilities, LLDPTLVMgmtAddress, LLDPTLVOrgSpecific} {
+		if want.Values[i].Type != typ {
+			t.Errorf("value %d: got type %v, want %v", i, want.Values[i].Type, typ)
+		}
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := want.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeLinkLayerDiscovery).(*LinkLayerDiscovery)
+	want.BaseLayer = got.BaseLayer
+	// Offsets are only set by decoding.
+	got.ChassisIDOffset, got.PortIDOffset, got.TTLOffset = 0, 0, 0
+	for i := range got.Values {
+		got.Values[i].Offset = 0
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded LLDP mismatch:\ngot  %#v\nwant %#v", got, want)
+	}
+
+	info := p.Layer(LayerTypeLinkLayerDiscoveryInfo).(*LinkLayerDiscoveryInfo)
+	if info.PortDescription != "uplink" || info.SysName != "switch1" || info.SysDescription != "gopacket" {
+		t.Errorf("got descriptions %q, %q, %q", info.PortDescription, info.SysName, info.SysDescription)
+	}
+	if info.SysCapabilities != caps {
+		t.Errorf("capabilities: got %+v, want %+v", info.SysCapabilities, caps)
+	}
+	if !bytes.Equal(info.MgmtAddress.Address, mgmt.Address) || info.MgmtAddress.InterfaceNumber != mgmt.InterfaceNumber {
+		t.Errorf("management address: got %+v, want %+v", info.MgmtAddress, mgmt)
+	}
+	if !reflect.DeepEqual(info.OrgTLVs, []LLDPOrgSpecificTLV{org}) {
+		t.Errorf("org TLVs: got %+v, want %+v", info.OrgTLVs, org)
+	}
+}
+
+func TestLLDPDecodeErrors(t *testing.T) {
+	l := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{
+		{IEEEOUI8021, LLDP8021SubtypeProtocolVLANID, []byte{0x02}},
+		{IEEEOUI8021, LLDP8021SubtypePortVLANID, []byte{0x00, 0x64}},
+		{IEEEOUI8021, LLDP8021SubtypeManagementVID, []byte{0x00}},
+	}}
+	info, err := l.Decode8021()
+	errs, ok := err.(*LLDPDecodeErrors)
+	if !ok {
+		t.Fatalf("got error %v, want *LLDPDecodeErrors", err)
+	}
+	if len(errs.Errors()) != 2 {
+		t.Errorf("got %d errors, want 2: %v", len(errs.Errors()), err)
+	}
+	if info.PVID != 100 {
+		t.Errorf("PVID: got %d, want 100", info.PVID)
+	}
+
+	l.OrgTLVs = l.OrgTLVs[1:2]
+	if _, err := l.Decode8021(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLLDPDecodeCDCP(t *testing.T) {
+	l := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{
+		{IEEEOUI8021Qbg, LLDP8021QbgCDCP, []byte{0x88, 0x00, 0x01, 0x67, 0x00, 0x10, 0x01, 0x00, 0x20, 0x64}},
+	}}
+	info, err := l.Decode8021Qbg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LLDPCDCP{
+		Role:       1,
+		SComp:      true,
+		ChannelCap: 0x167,
+		Channels:   []LLDPCDCPChannel{{1, 1}, {2, 100}},
+	}
+	if !reflect.DeepEqual(info.CDCP, want) {
+		t.Errorf("got %+v, want %+v", info.CDCP, want)
+	}
+
+	// A partial channel entry.
+	l.OrgTLVs[0].Info = l.OrgTLVs[0].Info[:9]
+	if _, err := l.Decode8021Qbg(); err == nil {
+		t.Error("expected error for truncated channel entry")
+	}
+}
+
+func TestLLDPDecodeDCBX(t *testing.T) {
+	tables := []byte{
+		0x01, 0x22, 0x33, 0x44, // priority assignment
+		10, 20, 30, 40, 0, 0, 0, 0, // bandwidth
+		2, 2, 2, 2, 0, 0, 0, 0, // TSA
+	}
+	l := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{
+		{IEEEOUI8021, LLDP8021SubtypeETSConfig, append([]byte{0xc4}, tables...)},
+		{IEEEOUI8021, LLDP8021SubtypeETSRecommend, append([]byte{0x00}, tables...)},
+		{IEEEOUI8021, LLDP8021SubtypePFCConfig, []byte{0x48, 0x08}},
+		{IEEEOUI8021, LLDP8021SubtypeAppPriority, []byte{0x00, 0x61, 0x89, 0x15, 0x62, 0x12, 0xb7}},
+	}}
+	info, err := l.Decode8021()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTables := LLDPETSTables{
+		PriorityAssignment: [8]uint8{0, 1, 2, 2, 3, 3, 4, 4},
+		TCBandwidth:        [8]uint8{10, 20, 30, 40},
+		TSAAssignment:      [8]uint8{2, 2, 2, 2},
+	}
+	if want := (LLDPETSConfiguration{true, true, 4, wantTables}); info.ETSConfiguration != want {
+		t.Errorf("ETS configuration: got %+v, want %+v", info.ETSConfiguration, want)
+	}
+	if want := (LLDPETSRecommendation{wantTables}); info.ETSRecommendation != want {
+		t.Errorf("ETS recommendation: got %+v, want %+v", info.ETSRecommendation, want)
+	}
+	if want := (LLDPPFCConfiguration{false, true, 8, 0x08}); info.PFCConfiguration != want {
+		t.Errorf("PFC configuration: got %+v, want %+v", info.PFCConfiguration, want)
+	}
+	wantApps := []LLDPApplicationPriority{{3, 1, 0x8915}, {3, 2, 4791}}
+	if !reflect.DeepEqual(info.ApplicationPriorities, wantApps) {
+		t.Errorf("application priorities: got %+v, want %+v", info.ApplicationPriorities, wantApps)
+	}
+
+	l.OrgTLVs = []LLDPOrgSpecificTLV{{IEEEOUI8021, LLDP8021SubtypeAppPriority, []byte{0x00, 0x61, 0x89}}}
+	if _, err := l.Decode8021(); err == nil {
+		t.Error("expected error for truncated application priority entry")
+	}
+}
+
+func TestLLDPDecodeOrg(t *testing.T) {
+	const oui IEEEOUI = 0x123456
+	decode := func(subtype uint8, info []byte) (interface{}, error) {
+		if subtype != 1 {
+			return nil, errors.New("bad subtype")
+		}
+		return string(info), nil
+	}
+	if err := RegisterLLDPOrgDecoder(oui, decode); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterLLDPOrgDecoder(oui, decode); err == nil {
+		t.Error("expected error for duplicate registration")
+	}
+
+	l := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{
+		{oui, 1, []byte("one")},
+		{IEEEOUI8021, 1, []byte{0x00, 0x01}},
+		{oui, 2, nil},
+		{oui, 1, []byte("two")},
+	}}
+	values, err := l.DecodeOrg(oui)
+	if errs, ok := err.(*LLDPDecodeErrors); !ok || len(errs.Errors()) != 1 {
+		t.Errorf("got error %v, want one decode error", err)
+	}
+	if want := []interface{}{"one", "two"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+	if _, err := l.DecodeOrg(0x654321); err == nil {
+		t.Error("expected error for unregistered OUI")
+	}
+}
+
+func TestLLDPIDAddresses(t *testing.T) {
+	c := LLDPChassisID{LLDPChassisIDSubTypeNetworkAddr, []byte{byte(IANAAddressFamilyIPV4), 10, 0, 0, 1}}
+	family, ip, err := c.NetworkAddress()
+	if err != nil || family != IANAAddressFamilyIPV4 || !ip.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("chassis network address: got %v, %v, %v", family, ip, err)
+	}
+	if got, want := c.String(), "Network Address: 10.0.0.1"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := c.HardwareAddr(); err == nil {
+		t.Error("expected error for MAC address of network address subtype")
+	}
+
+	p := LLDPPortID{LLDPPortIDSubtypeNetworkAddr, []byte{byte(IANAAddressFamilyIPV6), 0x20, 0x01}}
+	if _, _, err := p.NetworkAddress(); err == nil {
+		t.Error("expected error for short IPv6 address")
+	}
+	p.ID = []byte{byte(IANAAddressFamilyDNS), 'a'}
+	if _, _, err := p.NetworkAddress(); err == nil {
+		t.Error("expected error for unsupported address family")
+	}
+
+	p = LLDPPortID{LLDPPortIDSubtypeMACAddr, []byte{0x00, 0x01, 0x30, 0xf9, 0xad, 0xa0}}
+	mac, err := p.HardwareAddr()
+	if err != nil || mac.String() != "00:01:30:f9:ad:a0" {
+		t.Errorf("port MAC address: got %v, %v", mac, err)
+	}
+}
+
+func TestLLDPValidate(t *testing.T) {
+	var lldp LinkLayerDiscovery
+	if err := lldp.DecodeFromBytes(testLLDPDetailed, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if err := lldp.Validate(); err != nil {
+		t.Errorf("valid LLDPDU: %v", err)
+	}
+
+	chassis := []byte{0x02, 0x07, 0x04, 0x00, 0x01, 0x30, 0xf9, 0xad, 0xa0}
+	port := []byte{0x04, 0x04, 0x05, 0x31, 0x2f, 0x31}
+	ttl := []byte{0x06, 0x03, 0x00, 0x78, 0x00}
+	sysName := []byte{0x0a, 0x01, 'a'}
+	var data []byte
+	for _, tlv := range [][]byte{port, chassis, ttl, sysName, sysName, {0x00, 0x00}, sysName} {
+		data = append(data, tlv...)
+	}
+	if err := lldp.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	err := lldp.Validate()
+	errs, ok := err.(*LLDPDecodeErrors)
+	if !ok {
+		t.Fatalf("got error %v, want *LLDPDecodeErrors", err)
+	}
+	// Port ID and Chassis ID out of order, TTL length, duplicate System Name
+	// and System Name after End.
+	if len(errs.Errors()) != 5 {
+		t.Errorf("got %d errors, want 5: %v", len(errs.Errors()), err)
+	}
+}
+
+func TestLLDPDecode8023btPowerViaMDI(t *testing.T) {
+	// An 802.3bt power via MDI TLV from a type 4 PSE allocating 90W.
+	l := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{{IEEEOUI8023, LLDP8023SubtypeMDIPower, []byte{
+		0x0f, 0x01, 0x09, 0x51, 0x03, 0x84, 0x03, 0x84, // 802.3at
+		0x01, 0xc2, 0x01, 0xc2, 0x01, 0xc2, 0x01, 0xc2, // modes and alternatives
+		0x45, 0x77, 0x0a, 0x03, 0xb6, 0x00, 0x24, 0x00, 0x3c, // status to power down
+	}}}}
+	info, err := l.Decode8023()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := info.PowerViaMDI
+	if !p.Has8023bt || p.Allocated != 900 || p.RequestedModeA != 450 || p.AllocatedAltB != 450 ||
+		p.PowerStatus != 0x4577 || p.SystemSetup != 0x0a || p.MaxAvailable != 950 ||
+		p.Autoclass != 0 || p.PowerDownRequest != 9 || p.PowerDownTime != 60 {
+		t.Errorf("got %+v", p)
+	}
+
+	l.OrgTLVs[0].Info = l.OrgTLVs[0].Info[:8]
+	if info, err = l.Decode8023(); err != nil || info.PowerViaMDI.Has8023bt || info.PowerViaMDI.Allocated != 900 {
+		t.Errorf("802.3at TLV decoded as %+v, error %v", info.PowerViaMDI, err)
+	}
+}
+
+func TestLLDPOffsets(t *testing.T) {
+	var lldp LinkLayerDiscovery
+	if err := lldp.DecodeFromBytes(testLLDPDetailed, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if lldp.ChassisIDOffset != 0 || lldp.PortIDOffset != 9 || lldp.TTLOffset != 15 {
+		t.Errorf("got offsets %d, %d, %d", lldp.ChassisIDOffset, lldp.PortIDOffset, lldp.TTLOffset)
+	}
+
+	var data []byte
+	for _, v := range lldp.Values {
+		if v.Type == LLDPTLVSysDescription {
+			data = append(data, testLLDPDetailed[:v.Offset]...)
+			data = append(data, testLLDPDetailed[v.Offset+2+int(v.Length):]...)
+		}
+	}
+	if data == nil {
+		t.Fatal("no System Description TLV")
+	}
+	p := gopacket.NewPacket(data, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	info := p.Layer(LayerTypeLinkLayerDiscoveryInfo).(*LinkLayerDiscoveryInfo)
+	if info.SysDescription != "" || info.SysName != "Summit300-48\x00" {
+		t.Errorf("got system description %q, name %q", info.SysDescription, info.SysName)
+	}
+
+	// The 9th length bit.
+	long := NewLinkLayerDiscovery(lldp.ChassisID, lldp.PortID, 120,
+		LLDPWithPortDescription(string(make([]byte, 300))), LLDPWithSysName("a"))
+	buf := gopacket.NewSerializeBuffer()
+	if err := long.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lldp.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if off := lldp.Values[1].Offset; off != 19+302 || buf.Bytes()[off] != byte(LLDPTLVSysName)<<1 {
+		t.Errorf("System Name offset %d", off)
+	}
+}
+
+func TestLLDPDecode8021Subtypes(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		subtype uint8
+		info    []byte
+		want    LLDPInfo8021
+		wantErr bool
+	}{
+		{"port VLAN ID", LLDP8021SubtypePortVLANID, []byte{0x01, 0xe8}, LLDPInfo8021{PVID: 488}, false},
+		{"short port VLAN ID", LLDP8021SubtypePortVLANID, []byte{0x01}, LLDPInfo8021{}, true},
+		{"protocol VLAN ID supported", LLDP8021SubtypeProtocolVLANID, []byte{0x02, 0x00, 0x0a},
+			LLDPInfo8021{PPVIDs: []PortProtocolVLANID{{true, false, 10}}}, false},
+		{"protocol VLAN ID enabled", LLDP8021SubtypeProtocolVLANID, []byte{0x04, 0x00, 0x0a},
+			LLDPInfo8021{PPVIDs: []PortProtocolVLANID{{false, true, 10}}}, false},
+		{"protocol VLAN ID aggregation bits", LLDP8021SubtypeProtocolVLANID, []byte{0x01, 0x00, 0x0a},
+			LLDPInfo8021{PPVIDs: []PortProtocolVLANID{{false, false, 10}}}, false},
+		{"VLAN name", LLDP8021SubtypeVLANName, []byte{0x00, 0x0a, 0x02, 'v', '1'},
+			LLDPInfo8021{VLANNames: []VLANName{{10, "v1"}}}, false},
+		{"short VLAN name", LLDP8021SubtypeVLANName, []byte{0x00, 0x0a, 0x03, 'v', '1'}, LLDPInfo8021{}, true},
+		{"protocol identity", LLDP8021SubtypeProtocolIdentity, []byte{0x02, 0x88, 0xcc},
+			LLDPInfo8021{ProtocolIdentities: []ProtocolIdentity{{0x88, 0xcc}}}, false},
+		{"short protocol identity", LLDP8021SubtypeProtocolIdentity, []byte{0x03, 0x88, 0xcc}, LLDPInfo8021{}, true},
+		{"VID usage digest", LLDP8021SubtypeVDIUsageDigest, []byte{0x01, 0x02, 0x03, 0x04}, LLDPInfo8021{VIDUsageDigest: 0x01020304}, false},
+		{"management VID", LLDP8021SubtypeManagementVID, []byte{0x00, 0x64}, LLDPInfo8021{ManagementVID: 100}, false},
+		{"link aggregation supported", LLDP8021SubtypeLinkAggregation, []byte{0x01, 0x00, 0x00, 0x00, 0x07},
+			LLDPInfo8021{LinkAggregation: LLDPLinkAggregation{true, false, 7}}, false},
+		{"link aggregation enabled", LLDP8021SubtypeLinkAggregation, []byte{0x02, 0x00, 0x00, 0x00, 0x07},
+			LLDPInfo8021{LinkAggregation: LLDPLinkAggregation{false, true, 7}}, false},
+		{"link aggregation protocol VLAN bits", LLDP8021SubtypeLinkAggregation, []byte{0x04, 0x00, 0x00, 0x00, 0x07},
+			LLDPInfo8021{LinkAggregation: LLDPLinkAggregation{false, false, 7}}, false},
+	} {
+		l := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{{IEEEOUI8021, test.subtype, test.info}}}
+		got, err := l.Decode8021()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.wantErr)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLLDPDecodeEVBCapabilities(t *testing.T) {
+	for _, test := range []struct {
+		bits uint16
+		want LLDPEVBCapabilities
+	}{
+		{LLDPEVBCapsSTD, LLDPEVBCapabilities{StandardBridging: true}},
+		{LLDPEVBCapsRR, LLDPEVBCapabilities{ReflectiveRelay: true}},
+		{LLDPEVBCapsRTE, LLDPEVBCapabilities{RetransmissionTimerExponent: true}},
+		{LLDPEVBCapsECP, LLDPEVBCapabilities{EdgeControlProtocol: true}},
+		{LLDPEVBCapsVDP, LLDPEVBCapabilities{VSIDiscoveryProtocol: true}},
+	} {
+		info := []byte{byte(test.bits >> 8), byte(test.bits), 0x00, 0x00, 0x00, 0x01, 0x00, 0x02, 0x03}
+		l := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{{IEEEOUI8021Qbg, LLDP8021QbgEVB, info}}}
+		got, err := l.Decode8021Qbg()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.EVBSettings.Supported != test.want || got.EVBSettings.Enabled != (LLDPEVBCapabilities{}) {
+			t.Errorf("%#04x: got %+v", test.bits, got.EVBSettings)
+		}
+	}
+}
+
+func TestLLDPCapabilitiesRoundTrip(t *testing.T) {
+	for _, bits := range []uint16{0, LLDPCapsBridge, LLDPCapsBridge | LLDPCapsRouter, LLDPCapsPhone | LLDPCapsStationOnly, 0x07ff} {
+		if got := getCapabilities(bits).ToUint16(); got != bits {
+			t.Errorf("capabilities %#04x round trip: got %#04x", bits, got)
+		}
+	}
+	for _, bits := range []uint16{0, LLDPEVBCapsSTD, LLDPEVBCapsRR | LLDPEVBCapsVDP, 0x00c7} {
+		if got := getEVBCapabilities(bits).ToUint16(); got != bits {
+			t.Errorf("EVB capabilities %#04x round trip: got %#04x", bits, got)
+		}
+	}
+	caps := LLDPSysCapabilities{
+		SystemCap:  LLDPCapabilities{Bridge: true, Router: true, TMPR: true},
+		EnabledCap: LLDPCapabilities{Router: true},
+	}
+	if got, want := caps.Encode(), []byte{0x04, 0x14, 0x00, 0x10}; !bytes.Equal(got, want) {
+		t.Errorf("system capabilities: got %x, want %x", got, want)
+	}
+}
+
+func TestLLDPMgmtAddressEncode(t *testing.T) {
+	for _, m := range []LLDPMgmtAddress{
+		{IANAAddressFamilyIPV4, []byte{10, 0, 0, 1}, LLDPInterfaceSubtypeifIndex, 1001, ""},
+		{IANAAddressFamilyIPV6, net.ParseIP("2001:db8::1"), LLDPInterfaceSubtypeSysPort, 7, ""},
+		{IANAAddressFamily802, []byte{0x00, 0x01, 0x30, 0xf9, 0xad, 0xa0}, LLDPInterfaceSubtypeUnknown, 0, ""},
+	} {
+		lldp := NewLinkLayerDiscovery(
+			LLDPChassisID{LLDPChassisIDSubTypeLocal, []byte("c")},
+			LLDPPortID{LLDPPortIDSubtypeLocal, []byte("p")},
+			120, LLDPWithMgmtAddress(m))
+		buf := gopacket.NewSerializeBuffer()
+		if err := lldp.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		p := gopacket.NewPacket(buf.Bytes(), LayerTypeLinkLayerDiscovery, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+		}
+		info := p.Layer(LayerTypeLinkLayerDiscoveryInfo).(*LinkLayerDiscoveryInfo)
+		if !reflect.DeepEqual(info.MgmtAddress, m) {
+			t.Errorf("got %+v, want %+v", info.MgmtAddress, m)
+		}
+	}
+}
+
+func TestLLDPDecodeMgmtAddressOID(t *testing.T) {
+	// A management address TLV carrying the ifIndex OID 1.3.6.1.2.1.2.2.1.1.
+	data := []byte{
+		0x02, 0x02, 0x07, 'c', 0x04, 0x02, 0x07, 'p', 0x06, 0x02, 0x00, 0x78,
+		0x10, 0x15, 0x05, 0x01, 0x0a, 0x00, 0x00, 0x01, 0x02, 0x00, 0x00, 0x03, 0xe9,
+		0x09, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x01,
+		0x00, 0x00,
+	}
+	p := gopacket.NewPacket(data, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	info := p.Layer(LayerTypeLinkLayerDiscoveryInfo).(*LinkLayerDiscoveryInfo)
+	want := LLDPMgmtAddress{
+		Subtype:          IANAAddressFamilyIPV4,
+		Address:          []byte{10, 0, 0, 1},
+		InterfaceSubtype: LLDPInterfaceSubtypeifIndex,
+		InterfaceNumber:  1001,
+		OID:              "\x2b\x06\x01\x02\x01\x02\x02\x01\x01",
+	}
+	if !reflect.DeepEqual(info.MgmtAddress, want) {
+		t.Errorf("got %+v, want %+v", info.MgmtAddress, want)
+	}
+	if got := want.Encode(); !bytes.Equal(got, data[14:35]) {
+		t.Errorf("encoded %x, want %x", got, data[14:35])
+	}
+
+	// An OID length running past the end of the TLV.
+	data[25] = 0x0a
+	p = gopacket.NewPacket(data, LayerTypeLinkLayerDiscovery, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("expected error for truncated OID")
+	}
+}
+
+func TestLLDPShutdown(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"lldpd", []byte{
+			0x02, 0x07, 0x04, 0x52, 0x54, 0x00, 0x12, 0x34, 0x56,
+			0x04, 0x05, 0x05, 'e', 't', 'h', '0',
+			0x06, 0x02, 0x00, 0x00,
+			0x00, 0x00,
+		}},
+		{"Juniper EX", []byte{
+			0x02, 0x07, 0x04, 0x28, 0x8a, 0x1c, 0xe0, 0x51, 0x00,
+			0x04, 0x04, 0x07, '5', '1', '3',
+			0x06, 0x02, 0x00, 0x00,
+			0x00, 0x00,
+			// Ethernet padding
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		}},
+	} {
+		p := gopacket.NewPacket(test.data, LayerTypeLinkLayerDiscovery, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: failed to decode packet: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		lldp := p.Layer(LayerTypeLinkLayerDiscovery).(*LinkLayerDiscovery)
+		if !lldp.IsShutdown() || len(lldp.Values) != 0 {
+			t.Errorf("%s: got shutdown %v, values %v", test.name, lldp.IsShutdown(), lldp.Values)
+		}
+		if err := lldp.Validate(); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+
+	var lldp LinkLayerDiscovery
+	if err := lldp.DecodeFromBytes(testLLDPDetailed, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if lldp.IsShutdown() {
+		t.Error("LLDPDU with TTL 120 is a shutdown")
+	}
+}
+
+func BenchmarkDecodeLinkLayerDiscovery(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		gopacket.NewPacket(testLLDPDetailed, LayerTypeLinkLayerDiscovery, gopacket.NoCopy)
+	}
+}
+
+func TestLLDPDecodeCongestionNotificationAndPortExtension(t *testing.T) {
+	l := &LinkLayerDiscoveryInfo{OrgTLVs: []LLDPOrgSpecificTLV{
+		{IEEEOUI8021, LLDP8021SubtypeCN, []byte{0x08, 0x08}},
+		{IEEEOUI8021, LLDP8021SubtypePortExtension, []byte{0x80, 0x01, 0x02}},
+		{IEEEOUI8021, LLDP8021SubtypeCDCP, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x10, 0x01}},
+	}}
+	info, err := l.Decode8021()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (LLDPCongestionNotification{0x08, 0x08}); info.CongestionNotification != want {
+		t.Errorf("congestion notification: got %+v, want %+v", info.CongestionNotification, want)
+	}
+	if want := (LLDPPortExtension{true, 0x102}); info.PortExtension != want {
+		t.Errorf("port extension: got %+v, want %+v", info.PortExtension, want)
+	}
+	if want := (LLDPCDCP{ChannelCap: 2, Channels: []LLDPCDCPChannel{{1, 1}}}); !reflect.DeepEqual(info.CDCP, want) {
+		t.Errorf("CDCP: got %+v, want %+v", info.CDCP, want)
+	}
+
+	l.OrgTLVs = []LLDPOrgSpecificTLV{{IEEEOUI8021, LLDP8021SubtypeCN, []byte{0x08}}}
+	if _, err := l.Decode8021(); err == nil {
+		t.Error("expected error for short congestion notification TLV")
+	}
+}
+
+func TestLLDPInfoDecodingLayer(t *testing.T) {
+	lldpFrame := append([]byte{
+		0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e, 0x00, 0x01, 0x30, 0xf9, 0xad, 0xa0, 0x88, 0xcc,
+	}, testLLDPDetailed...)
+	var eth Ethernet
+	var ip4 IPv4
+	var tcp TCP
+	var lldp LinkLayerDiscovery
+	var payload gopacket.Payload
+	var info LinkLayerDiscoveryInfo
+	parser := gopacket.NewDecodingLayerParser(LayerTypeEthernet, &eth, &ip4, &tcp, &lldp, &payload)
+	var decoded []gopacket.LayerType
+	lldps := 0
+	for _, data := range [][]byte{testSimpleTCPPacket, lldpFrame, testSimpleTCPPacket, lldpFrame} {
+		if err := parser.DecodeLayers(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded[len(decoded)-1] != LayerTypeLinkLayerDiscovery {
+			continue
+		}
+		lldps++
+		if err := info.DecodeFromBytes(lldp.Contents, gopacket.NilDecodeFeedback); err != nil {
+			t.Fatal(err)
+		}
+		p := gopacket.NewPacket(data, LayerTypeEthernet, gopacket.Default)
+		want := p.Layer(LayerTypeLinkLayerDiscoveryInfo).(*LinkLayerDiscoveryInfo)
+		want.BaseLayer = BaseLayer{Contents: lldp.Contents}
+		got := info
+		got.lldp = LinkLayerDiscovery{}
+		if !reflect.DeepEqual(&got, want) {
+			t.Errorf("got  %+v\nwant %+v", &got, want)
+		}
+	}
+	if lldps != 2 {
+		t.Errorf("decoded %d LLDPDUs, want 2", lldps)
+	}
+
+	layers := gopacket.NewDecodingLayerParser(LayerTypeLinkLayerDiscoveryInfo, &info)
+	if err := layers.DecodeLayers(testLLDPDetailed, &decoded); err != nil || len(decoded) != 1 {
+		t.Errorf("got layers %v, error %v", decoded, err)
+	}
+	if info.SysName != "Summit300-48\x00" {
+		t.Errorf("got system name %q", info.SysName)
+	}
+}
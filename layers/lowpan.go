@@ -0,0 +1,479 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// 6LoWPAN dispatch values from RFC 4944 and RFC 6282.
+const (
+	lowpanDispatchIPv6     = 0x41
+	lowpanDispatchBC0      = 0x50
+	lowpanDispatchIPHC     = 0x60 // 011xxxxx
+	lowpanDispatchIPHCMask = 0xe0
+	lowpanDispatchMesh     = 0x80 // 10xxxxxx
+	lowpanDispatchMeshMask = 0xc0
+	lowpanDispatchFrag1    = 0xc0 // 11000xxx
+	lowpanDispatchFragN    = 0xe0 // 11100xxx
+	lowpanDispatchFragMask = 0xf8
+	lowpanNHCUDP           = 0xf0 // 11110CPP
+	lowpanNHCUDPMask       = 0xf8
+)
+
+// LoWPANMeshHeader is the RFC 4944 mesh addressing header.
+type LoWPANMeshHeader struct {
+	HopsLeft   uint8
+	Originator net.HardwareAddr
+	Final      net.HardwareAddr
+}
+
+// LoWPANFragmentHeader is an RFC 4944 FRAG1 or FRAGN header.  Reassemblers
+// should place each fragment's LoWPAN payload at DatagramOffset*8 of a
+// buffer of DatagramSize bytes; for first fragments the payload already has
+// its IPv6 header decompressed, so offsets line up.
+type LoWPANFragmentHeader struct {
+	// First is true for FRAG1 headers, which carry the start of the
+	// datagram and have no offset.
+	First          bool
+	DatagramSize   uint16
+	DatagramTag    uint16
+	DatagramOffset uint8
+}
+
+// LoWPANIPHC contains the raw fields of an RFC 6282 IPHC header.  The
+// decompressed values are found in the synthesized IPv6 layer that follows.
+type LoWPANIPHC struct {
+	TrafficFlow          uint8 // TF, 2 bits
+	NextHeaderCompressed bool  // NH
+	HopLimit             uint8 // HLIM, 2 bits
+	SrcContext           bool  // SAC
+	SrcMode              uint8 // SAM, 2 bits
+	Multicast            bool  // M
+	DstContext           bool  // DAC
+	DstMode              uint8 // DAM, 2 bits
+	SrcContextID         uint8
+	DstContextID         uint8
+}
+
+// LoWPANNHCUDP contains the raw fields of an RFC 6282 UDP next header
+// compression header.
+type LoWPANNHCUDP struct {
+	ChecksumElided bool  // C
+	Ports          uint8 // P, 2 bits
+}
+
+// LoWPAN is the 6LoWPAN adaptation layer (RFC 4944, RFC 6282) carried in IEEE
+// 802.15.4 frames.  Compressed IPv6 and UDP headers are decompressed, and the
+// layer's payload holds the synthesized headers followed by the rest of the
+// datagram, so that decoding continues with a regular IPv6 layer.
+//
+// Decompression of addresses elided in favour of link-layer addresses uses
+// SrcLinkAddr and DstLinkAddr, which are filled in from the preceding
+// Dot15d4 layer when decoding a packet.  Users of DecodingLayerParser should
+// set them from Dot15d4.SrcLinkAddr and Dot15d4.DstLinkAddr before decoding.
+// Context-based address compression isn't supported.
+type LoWPAN struct {
+	BaseLayer
+	SrcLinkAddr, DstLinkAddr net.HardwareAddr
+	Mesh                     *LoWPANMeshHeader
+	// BroadcastSequence is only valid if HasBroadcast is set.
+	HasBroadcast      bool
+	BroadcastSequence uint8
+	Fragment          *LoWPANFragmentHeader
+	IPHC              *LoWPANIPHC
+	NHCUDP            *LoWPANNHCUDP
+	nextLayerType     gopacket.LayerType
+}
+
+// LayerType returns LayerTypeLoWPAN.
+func (l *LoWPAN) LayerType() gopacket.LayerType { return LayerTypeLoWPAN }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (l *LoWPAN) CanDecode() gopacket.LayerClass { return LayerTypeLoWPAN }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (l *LoWPAN) NextLayerType() gopacket.LayerType { return l.nextLayerType }
+
+func lowpanAddr(data []byte, short bool) net.HardwareAddr {
+	if short {
+		return net.HardwareAddr(data[:2])
+	}
+	return net.HardwareAddr(data[:8])
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (l *LoWPAN) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	l.Mesh, l.Fragment, l.IPHC, l.NHCUDP = nil, nil, nil, nil
+	l.HasBroadcast, l.BroadcastSequence = false, 0
+	srcLL, dstLL := l.SrcLinkAddr, l.DstLinkAddr
+	offset := 0
+	truncated := func(need int) error {
+		df.SetTruncated()
+		return fmt.Errorf("6LoWPAN length %d too short, %d required", len(data), need)
+	}
+
+	if len(data) < 1 {
+		return truncated(1)
+	}
+	if data[0]&lowpanDispatchMeshMask == lowpanDispatchMesh {
+		shortOrig, shortFinal := data[0]&0x20 != 0, data[0]&0x10 != 0
+		m := &LoWPANMeshHeader{HopsLeft: data[0] & 0xf}
+		offset = 1
+		if m.HopsLeft == 0xf {
+			// Deep hops left, RFC 8138 section 6.1.
+			if len(data) < 2 {
+				return truncated(2)
+			}
+			m.HopsLeft = data[1]
+			offset++
+		}
+		need := offset + 16
+		if shortOrig {
+			need -= 6
+		}
+		if shortFinal {
+			need -= 6
+		}
+		if len(data) < need {
+			return truncated(need)
+		}
+		m.Originator = lowpanAddr(data[offset:], shortOrig)
+		offset += len(m.Originator)
+		m.Final = lowpanAddr(data[offset:], shortFinal)
+		offset += len(m.Final)
+		l.Mesh = m
+		// Addresses are derived from the mesh endpoints, not the last hop.
+		srcLL, dstLL = m.Originator, m.Final
+	}
+	if len(data) > offset && data[offset] == lowpanDispatchBC0 {
+		if len(data) < offset+2 {
+			return truncated(offset + 2)
+		}
+		l.HasBroadcast = true
+		l.BroadcastSequence = data[offset+1]
+		offset += 2
+	}
+	if len(data) > offset {
+		switch data[offset] & lowpanDispatchFragMask {
+		case lowpanDispatchFrag1, lowpanDispatchFragN:
+			f := &LoWPANFragmentHeader{First: data[offset]&lowpanDispatchFragMask == lowpanDispatchFrag1}
+			need := offset + 5
+			if f.First {
+				need--
+			}
+			if len(data) < need {
+				return truncated(need)
+			}
+			f.DatagramSize = binary.BigEndian.Uint16(data[offset:offset+2]) & 0x7ff
+			f.DatagramTag = binary.BigEndian.Uint16(data[offset+2 : offset+4])
+			if !f.First {
+				f.DatagramOffset = data[offset+4]
+			}
+			offset = need
+			l.Fragment = f
+		}
+	}
+	if l.Fragment != nil && !l.Fragment.First {
+		l.BaseLayer = BaseLayer{Contents: data[:offset], Payload: data[offset:]}
+		l.nextLayerType = gopacket.LayerTypeFragment
+		return nil
+	}
+
+	if len(data) < offset+1 {
+		return truncated(offset + 1)
+	}
+	switch {
+	case data[offset] == lowpanDispatchIPv6:
+		offset++
+		l.BaseLayer = BaseLayer{Contents: data[:offset], Payload: data[offset:]}
+	case data[offset]&lowpanDispatchIPHCMask == lowpanDispatchIPHC:
+		hdr, n, err := l.decompressIPHC(data[offset:], srcLL, dstLL, df)
+		if err != nil {
+			return err
+		}
+		offset += n
+		l.BaseLayer = BaseLayer{Contents: data[:offset], Payload: append(hdr, data[offset:]...)}
+		if l.Fragment == nil && l.NHCUDP != nil && l.NHCUDP.ChecksumElided {
+			l.fixUDPChecksum()
+		}
+	default:
+		return fmt.Errorf("unsupported 6LoWPAN dispatch %#x", data[offset])
+	}
+	if l.Fragment != nil {
+		l.nextLayerType = gopacket.LayerTypeFragment
+	} else {
+		l.nextLayerType = LayerTypeIPv6
+	}
+	return nil
+}
+
+// decompressIPHC decompresses an IPHC header, plus the NHC UDP header that
+// may follow it, returning the uncompressed headers and the number of bytes
+// of data they were compressed into.
+func (l *LoWPAN) decompressIPHC(data []byte, srcLL, dstLL net.HardwareAddr, df gopacket.DecodeFeedback) ([]byte, int, error) {
+	truncated := func(need int) error {
+		df.SetTruncated()
+		return fmt.Errorf("6LoWPAN IPHC length %d too short, %d required", len(data), need)
+	}
+	if len(data) < 2 {
+		return nil, 0, truncated(2)
+	}
+	h := &LoWPANIPHC{
+		TrafficFlow:          data[0] >> 3 & 0x3,
+		NextHeaderCompressed: data[0]&0x04 != 0,
+		HopLimit:             data[0] & 0x3,
+		SrcContext:           data[1]&0x40 != 0,
+		SrcMode:              data[1] >> 4 & 0x3,
+		Multicast:            data[1]&0x08 != 0,
+		DstContext:           data[1]&0x04 != 0,
+		DstMode:              data[1] & 0x3,
+	}
+	l.IPHC = h
+	offset := 2
+	if data[1]&0x80 != 0 {
+		if len(data) < offset+1 {
+			return nil, 0, truncated(offset + 1)
+		}
+		h.SrcContextID = data[offset] >> 4
+		h.DstContextID = data[offset] & 0xf
+		offset++
+	}
+
+	// Reserve room for a UDP header so appending the payload rarely
+	// reallocates.
+	hdr := make([]byte, 40, 48)
+	var ecn, dscp uint8
+	var flow uint32
+	need := offset + [4]int{4, 3, 1, 0}[h.TrafficFlow]
+	if len(data) < need {
+		return nil, 0, truncated(need)
+	}
+	switch h.TrafficFlow {
+	case 0:
+		ecn, dscp = data[offset]>>6, data[offset]&0x3f
+		flow = binary.BigEndian.Uint32(data[offset:offset+4]) & 0xfffff
+	case 1:
+		ecn = data[offset] >> 6
+		flow = uint32(data[offset]&0xf)<<16 | uint32(data[offset+1])<<8 | uint32(data[offset+2])
+	case 2:
+		ecn, dscp = data[offset]>>6, data[offset]&0x3f
+	}
+	offset = need
+	tc := dscp<<2 | ecn
+	binary.BigEndian.PutUint32(hdr[0:4], 6<<28|uint32(tc)<<20|flow)
+
+	if !h.NextHeaderCompressed {
+		if len(data) < offset+1 {
+			return nil, 0, truncated(offset + 1)
+		}
+		hdr[6] = data[offset]
+		offset++
+	}
+	switch h.HopLimit {
+	case 0:
+		if len(data) < offset+1 {
+			return nil, 0, truncated(offset + 1)
+		}
+		hdr[7] = data[offset]
+		offset++
+	case 1:
+		hdr[7] = 1
+	case 2:
+		hdr[7] = 64
+	case 3:
+		hdr[7] = 255
+	}
+
+	n, err := lowpanDecompressUnicast(hdr[8:24], data[offset:], h.SrcContext, h.SrcMode, srcLL)
+	if err == errLoWPANTruncated {
+		df.SetTruncated()
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += n
+	if h.Multicast {
+		n, err = lowpanDecompressMulticast(hdr[24:40], data[offset:], h.DstContext, h.DstMode)
+	} else {
+		n, err = lowpanDecompressUnicast(hdr[24:40], data[offset:], h.DstContext, h.DstMode, dstLL)
+	}
+	if err == errLoWPANTruncated {
+		df.SetTruncated()
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += n
+
+	udpLen := 0
+	if h.NextHeaderCompressed {
+		if len(data) < offset+1 {
+			return nil, 0, truncated(offset + 1)
+		}
+		if data[offset]&lowpanNHCUDPMask != lowpanNHCUDP {
+			return nil, 0, fmt.Errorf("unsupported 6LoWPAN next header compression %#x", data[offset])
+		}
+		u := &LoWPANNHCUDP{
+			ChecksumElided: data[offset]&0x04 != 0,
+			Ports:          data[offset] & 0x3,
+		}
+		offset++
+		need := offset + [4]int{4, 3, 3, 1}[u.Ports]
+		if !u.ChecksumElided {
+			need += 2
+		}
+		if len(data) < need {
+			return nil, 0, truncated(need)
+		}
+		hdr = hdr[:48]
+		udp := hdr[40:48]
+		var src, dst uint16
+		switch u.Ports {
+		case 0:
+			src = binary.BigEndian.Uint16(data[offset:])
+			dst = binary.BigEndian.Uint16(data[offset+2:])
+		case 1:
+			src = binary.BigEndian.Uint16(data[offset:])
+			dst = 0xf000 | uint16(data[offset+2])
+		case 2:
+			src = 0xf000 | uint16(data[offset])
+			dst = binary.BigEndian.Uint16(data[offset+1:])
+		case 3:
+			src = 0xf0b0 | uint16(data[offset]>>4)
+			dst = 0xf0b0 | uint16(data[offset]&0xf)
+		}
+		binary.BigEndian.PutUint16(udp[0:2], src)
+		binary.BigEndian.PutUint16(udp[2:4], dst)
+		offset = need
+		if !u.ChecksumElided {
+			copy(udp[6:8], data[offset-2:offset])
+		}
+		hdr[6] = byte(IPProtocolUDP)
+		l.NHCUDP = u
+		udpLen = 8
+	}
+
+	payloadLen := len(data) - offset + udpLen
+	if l.Fragment != nil {
+		payloadLen = int(l.Fragment.DatagramSize) - 40
+	}
+	binary.BigEndian.PutUint16(hdr[4:6], uint16(payloadLen))
+	if udpLen != 0 {
+		binary.BigEndian.PutUint16(hdr[44:46], uint16(payloadLen))
+	}
+	return hdr, offset, nil
+}
+
+// fixUDPChecksum computes the UDP checksum elided by NHC.  The payload must
+// hold the entire datagram.
+func (l *LoWPAN) fixUDPChecksum() {
+	ip := &IPv6{SrcIP: net.IP(l.Payload[8:24]), DstIP: net.IP(l.Payload[24:40])}
+	var c tcpipchecksum
+	c.pseudoheader = ip
+	csum, err := c.computeChecksum(l.Payload[40:], IPProtocolUDP)
+	if err != nil {
+		return
+	}
+	binary.BigEndian.PutUint16(l.Payload[46:48], csum)
+}
+
+var errLoWPANTruncated = errors.New("6LoWPAN IPHC address truncated")
+
+// lowpanIID derives an IPv6 interface identifier from an IEEE 802.15.4
+// extended or short address, as described in RFC 4944 section 6.
+func lowpanIID(iid []byte, ll net.HardwareAddr) error {
+	switch len(ll) {
+	case 8:
+		copy(iid, ll)
+		iid[0] ^= 0x02
+	case 2:
+		copy(iid, []byte{0, 0, 0, 0xff, 0xfe, 0, ll[0], ll[1]})
+	default:
+		return errors.New("6LoWPAN address elided but no link-layer address is available")
+	}
+	return nil
+}
+
+// lowpanDecompressUnicast decompresses an IPHC unicast address into ip,
+// returning the number of bytes of data consumed.
+func lowpanDecompressUnicast(ip, data []byte, context bool, mode uint8, ll net.HardwareAddr) (int, error) {
+	if context {
+		if mode == 0 {
+			// The unspecified address.
+			return 0, nil
+		}
+		return 0, errors.New("6LoWPAN context-based address compression is not supported")
+	}
+	inline := [4]int{16, 8, 2, 0}[mode]
+	if len(data) < inline {
+		return 0, errLoWPANTruncated
+	}
+	if mode != 0 {
+		ip[0], ip[1] = 0xfe, 0x80
+	}
+	switch mode {
+	case 0:
+		copy(ip, data[:16])
+	case 1:
+		copy(ip[8:], data[:8])
+	case 2:
+		copy(ip[8:], []byte{0, 0, 0, 0xff, 0xfe, 0, data[0], data[1]})
+	case 3:
+		if err := lowpanIID(ip[8:], ll); err != nil {
+			return 0, err
+		}
+	}
+	return inline, nil
+}
+
+// lowpanDecompressMulticast decompresses an IPHC multicast address into ip,
+// returning the number of bytes of data consumed.
+func lowpanDecompressMulticast(ip, data []byte, context bool, mode uint8) (int, error) {
+	if context {
+		return 0, errors.New("6LoWPAN context-based address compression is not supported")
+	}
+	inline := [4]int{16, 6, 4, 1}[mode]
+	if len(data) < inline {
+		return 0, errLoWPANTruncated
+	}
+	ip[0] = 0xff
+	switch mode {
+	case 0:
+		copy(ip, data[:16])
+	case 1:
+		ip[1] = data[0]
+		copy(ip[11:], data[1:6])
+	case 2:
+		ip[1] = data[0]
+		copy(ip[13:], data[1:4])
+	case 3:
+		ip[1] = 0x02
+		ip[15] = data[0]
+	}
+	return inline, nil
+}
+
+// lowpanDecoder decodes 6LoWPAN using the link-layer addresses of the
+// enclosing IEEE 802.15.4 frame.
+type lowpanDecoder struct {
+	src, dst net.HardwareAddr
+}
+
+func (d lowpanDecoder) Decode(data []byte, p gopacket.PacketBuilder) error {
+	l := &LoWPAN{SrcLinkAddr: d.src, DstLinkAddr: d.dst}
+	return decodingLayerDecoder(l, data, p)
+}
+
+func decodeLoWPAN(data []byte, p gopacket.PacketBuilder) error {
+	return lowpanDecoder{}.Decode(data, p)
+}
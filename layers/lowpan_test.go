@@ -0,0 +1,142 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketLoWPANUDP is an IEEE 802.15.4 data frame between two extended
+// addresses, carrying an IPHC compressed link-local IPv6 header with both
+// addresses derived from the MAC addresses, and an NHC compressed UDP header
+// from port 61617 to 61618 with an inline checksum.  The UDP payload is
+// "hello".
+var testPacketLoWPANUDP = []byte{
+	0x41, 0xdc, 0x01, 0xce, 0xfa, 0x01, 0x00, 0x00, 0x00, 0x00, 0x4b, 0x12, 0x00, 0x02, 0x00, 0x00,
+	0x00, 0x00, 0x4b, 0x12, 0x00, 0x7e, 0x33, 0xf3, 0x12, 0x43, 0x74, 0x68, 0x65, 0x6c, 0x6c, 0x6f,
+	0xf0, 0x31,
+}
+
+// testPacketLoWPANUDPChecksumElided is testPacketLoWPANUDP with the UDP
+// checksum elided.
+var testPacketLoWPANUDPChecksumElided = []byte{
+	0x41, 0xdc, 0x01, 0xce, 0xfa, 0x01, 0x00, 0x00, 0x00, 0x00, 0x4b, 0x12, 0x00, 0x02, 0x00, 0x00,
+	0x00, 0x00, 0x4b, 0x12, 0x00, 0x7e, 0x33, 0xf7, 0x12, 0x68, 0x65, 0x6c, 0x6c, 0x6f, 0xef, 0xc9,
+}
+
+func checkLoWPANUDP(t *testing.T, data []byte) {
+	p := gopacket.NewPacket(data, LinkTypeIEEE802_15_4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeDot15d4, LayerTypeLoWPAN, LayerTypeIPv6, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+
+	ip6, ok := p.Layer(LayerTypeIPv6).(*IPv6)
+	if !ok {
+		t.Fatal("No IPv6 layer")
+	}
+	if want := net.ParseIP("fe80::212:4b00:0:2"); !ip6.SrcIP.Equal(want) {
+		t.Errorf("IPv6 source mismatch, want %v got %v", want, ip6.SrcIP)
+	}
+	if want := net.ParseIP("fe80::212:4b00:0:1"); !ip6.DstIP.Equal(want) {
+		t.Errorf("IPv6 destination mismatch, want %v got %v", want, ip6.DstIP)
+	}
+	if ip6.HopLimit != 64 || ip6.NextHeader != IPProtocolUDP || ip6.Length != 13 {
+		t.Errorf("Unexpected IPv6 header %#v", ip6)
+	}
+
+	udp, ok := p.Layer(LayerTypeUDP).(*UDP)
+	if !ok {
+		t.Fatal("No UDP layer")
+	}
+	if udp.SrcPort != 61617 || udp.DstPort != 61618 || udp.Length != 13 || udp.Checksum != 0x4374 {
+		t.Errorf("Unexpected UDP header %#v", udp)
+	}
+	if want := []byte("hello"); !reflect.DeepEqual(want, udp.Payload) {
+		t.Errorf("UDP payload mismatch, want %q got %q", want, udp.Payload)
+	}
+}
+
+func TestPacketLoWPANUDP(t *testing.T) {
+	checkLoWPANUDP(t, testPacketLoWPANUDP)
+}
+
+func TestPacketLoWPANUDPChecksumElided(t *testing.T) {
+	checkLoWPANUDP(t, testPacketLoWPANUDPChecksumElided)
+}
+
+func TestLoWPANFragments(t *testing.T) {
+	frag1 := []byte{
+		0xc0, 0x32, 0x12, 0x34, // FRAG1, size 50, tag 0x1234
+		0x41,                                           // uncompressed IPv6
+		0x60, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x11, 0x40, // first 8 bytes of the IPv6 header
+	}
+	fragN := []byte{
+		0xe0, 0x32, 0x12, 0x34, 0x01, // FRAGN, size 50, tag 0x1234, offset 8
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	var l LoWPAN
+	if err := l.DecodeFromBytes(frag1, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode FRAG1:", err)
+	}
+	want := &LoWPANFragmentHeader{First: true, DatagramSize: 50, DatagramTag: 0x1234}
+	if !reflect.DeepEqual(want, l.Fragment) {
+		t.Errorf("FRAG1 header mismatch, \nwant %#v\ngot  %#v\n", want, l.Fragment)
+	}
+	if l.NextLayerType() != gopacket.LayerTypeFragment || !reflect.DeepEqual(frag1[5:], l.Payload) {
+		t.Errorf("Unexpected FRAG1 payload %v, %x", l.NextLayerType(), l.Payload)
+	}
+
+	if err := l.DecodeFromBytes(fragN, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode FRAGN:", err)
+	}
+	want = &LoWPANFragmentHeader{DatagramSize: 50, DatagramTag: 0x1234, DatagramOffset: 1}
+	if !reflect.DeepEqual(want, l.Fragment) {
+		t.Errorf("FRAGN header mismatch, \nwant %#v\ngot  %#v\n", want, l.Fragment)
+	}
+	if l.NextLayerType() != gopacket.LayerTypeFragment || !reflect.DeepEqual(fragN[5:], l.Payload) {
+		t.Errorf("Unexpected FRAGN payload %v, %x", l.NextLayerType(), l.Payload)
+	}
+}
+
+func TestLoWPANMulticast(t *testing.T) {
+	// IPHC with inline next header and hop limit, a source address elided
+	// to 16 bits, and an 8 bit multicast destination, followed by an ICMPv6
+	// echo request header.
+	data := []byte{
+		0x78, 0x2b, 0x3a, 0xff, 0x00, 0x01, 0x01,
+		0x80, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01,
+	}
+	p := gopacket.NewPacket(data, LayerTypeLoWPAN, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeLoWPAN, LayerTypeIPv6, LayerTypeICMPv6}, t)
+	ip6, ok := p.Layer(LayerTypeIPv6).(*IPv6)
+	if !ok {
+		t.Fatal("No IPv6 layer")
+	}
+	if want := net.ParseIP("fe80::ff:fe00:1"); !ip6.SrcIP.Equal(want) {
+		t.Errorf("IPv6 source mismatch, want %v got %v", want, ip6.SrcIP)
+	}
+	if want := net.ParseIP("ff02::1"); !ip6.DstIP.Equal(want) {
+		t.Errorf("IPv6 destination mismatch, want %v got %v", want, ip6.DstIP)
+	}
+	if ip6.HopLimit != 255 || ip6.Length != 8 {
+		t.Errorf("Unexpected IPv6 header %#v", ip6)
+	}
+}
+
+func BenchmarkDecodePacketLoWPAN(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		gopacket.NewPacket(testPacketLoWPANUDP, LinkTypeIEEE802_15_4, gopacket.NoCopy)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// MACsec frames (IEEE 802.1AE) carry a security tag after the source MAC
+// address, with EtherType 0x88E5, followed by the secure data and an
+// integrity check value:
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |V|E|S|S|E|C|AN |R R|    SL     |      Packet Number ...        |
+// | |S|C|C| | |   |   |           |                               |
+// | | | |B| | |   |   |           |                               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |      ... Packet Number        |  Secure Channel Identifier    |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+  (optional, 8 bytes) ...      |
+// |                                                               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |      ... SCI                  |  Secure data ...              ~
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// ~  ICV                                                          ~
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+// MACsecICVLength is the length of the integrity check value assumed when
+// decoding MACsec frames, since the frame doesn't say.  All the cipher
+// suites of 802.1AE use 16 bytes.
+var MACsecICVLength = 16
+
+// MACsec is the SecTAG of an IEEE 802.1AE MACsec frame.
+//
+// Frames which are neither encrypted nor changed carry the protected frame
+// in the clear, so it's decoded from EtherType on.  Otherwise the secure
+// data is left undecoded, as a gopacket.Payload layer.
+type MACsec struct {
+	BaseLayer
+	// Version is the V bit, which must be 0.
+	Version uint8
+	// EndStation (the ES bit) is set if the SCI is made of the source MAC
+	// address and port 1, when it isn't sent.
+	EndStation bool
+	// SCIPresent (the SC bit) is set if the SCI is sent.
+	SCIPresent bool
+	// SingleCopyBroadcast is the SCB bit, set for EPON single copy
+	// broadcast.
+	SingleCopyBroadcast bool
+	// Encrypted (the E bit) and Changed (the C bit) are set together for
+	// confidentiality protected frames, and both clear for integrity only
+	// ones.
+	Encrypted bool
+	Changed   bool
+	// AssociationNumber is the 2 bit number of the secure association.
+	AssociationNumber uint8
+	// ShortLength is the length of the secure data if it's less than 48
+	// bytes, and 0 otherwise.
+	ShortLength  uint8
+	PacketNumber uint32
+	// SCI is the secure channel identifier, set if SCIPresent.
+	SCI uint64
+	// EtherType is the type of the protected frame, set if the frame is
+	// neither encrypted nor changed.
+	EtherType EthernetType
+	// ICV is the integrity check value, MACsecICVLength bytes long when
+	// decoding.
+	ICV []byte
+}
+
+// LayerType returns LayerTypeMACsec.
+func (m *MACsec) LayerType() gopacket.LayerType { return LayerTypeMACsec }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *MACsec) CanDecode() gopacket.LayerClass { return LayerTypeMACsec }
+
+// NextLayerType returns the layer type given by EtherType for frames which
+// are neither encrypted nor changed, and gopacket.LayerTypePayload otherwise.
+func (m *MACsec) NextLayerType() gopacket.LayerType {
+	if m.clear() {
+		return m.EtherType.LayerType()
+	}
+	return gopacket.LayerTypePayload
+}
+
+func (m *MACsec) clear() bool {
+	return !m.Encrypted && !m.Changed
+}
+
+// SCIAddress returns the MAC address part of the SCI.
+func (m *MACsec) SCIAddress() net.HardwareAddr {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], m.SCI)
+	return net.HardwareAddr(b[:6])
+}
+
+// SCIPort returns the port identifier part of the SCI.
+func (m *MACsec) SCIPort() uint16 {
+	return uint16(m.SCI)
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (m *MACsec) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 6 {
+		df.SetTruncated()
+		return errors.New("MACsec SecTAG too short")
+	}
+	m.Version = data[0] >> 7
+	m.EndStation = data[0]&0x40 != 0
+	m.SCIPresent = data[0]&0x20 != 0
+	m.SingleCopyBroadcast = data[0]&0x10 != 0
+	m.Encrypted = data[0]&0x08 != 0
+	m.Changed = data[0]&0x04 != 0
+	m.AssociationNumber = data[0] & 0x03
+	m.ShortLength = data[1] & 0x3f
+	m.PacketNumber = binary.BigEndian.Uint32(data[2:6])
+	m.SCI, m.EtherType = 0, 0
+	if m.Version != 0 {
+		return fmt.Errorf("invalid MACsec version %d", m.Version)
+	}
+	if m.ShortLength >= 48 {
+		return fmt.Errorf("invalid MACsec short length %d", m.ShortLength)
+	}
+	tagLength := 6
+	if m.SCIPresent {
+		if len(data) < 14 {
+			df.SetTruncated()
+			return errors.New("MACsec SecTAG too short for SCI")
+		}
+		m.SCI = binary.BigEndian.Uint64(data[6:14])
+		tagLength = 14
+	}
+
+	// With a short length, anything after the ICV is Ethernet padding.
+	end := len(data) - MACsecICVLength
+	if m.ShortLength != 0 {
+		end = tagLength + int(m.ShortLength)
+	}
+	if end < tagLength || end+MACsecICVLength > len(data) {
+		df.SetTruncated()
+		return errors.New("MACsec frame too short for ICV")
+	}
+	m.ICV = data[end : end+MACsecICVLength]
+	if m.clear() {
+		if end < tagLength+2 {
+			return errors.New("MACsec frame too short for EtherType")
+		}
+		m.EtherType = EthernetType(binary.BigEndian.Uint16(data[tagLength : tagLength+2]))
+		tagLength += 2
+	}
+	m.BaseLayer = BaseLayer{data[:tagLength], data[tagLength:end]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// The payload already in the buffer is taken as the secure data, as is:
+// no encryption is done, and ICV is appended unchanged.
+func (m *MACsec) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	icv, err := b.AppendBytes(len(m.ICV))
+	if err != nil {
+		return err
+	}
+	secure := len(b.Bytes()) - len(m.ICV)
+	copy(icv, m.ICV)
+
+	length := 6
+	if m.SCIPresent {
+		length = 14
+	}
+	if m.clear() {
+		length += 2
+		secure += 2
+	}
+	if opts.FixLengths {
+		m.ShortLength = 0
+		if secure < 48 {
+			m.ShortLength = uint8(secure)
+		}
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = m.Version<<7 | m.AssociationNumber&0x03
+	if m.EndStation {
+		bytes[0] |= 0x40
+	}
+	if m.SCIPresent {
+		bytes[0] |= 0x20
+	}
+	if m.SingleCopyBroadcast {
+		bytes[0] |= 0x10
+	}
+	if m.Encrypted {
+		bytes[0] |= 0x08
+	}
+	if m.Changed {
+		bytes[0] |= 0x04
+	}
+	bytes[1] = m.ShortLength & 0x3f
+	binary.BigEndian.PutUint32(bytes[2:6], m.PacketNumber)
+	if m.SCIPresent {
+		binary.BigEndian.PutUint64(bytes[6:14], m.SCI)
+	}
+	if m.clear() {
+		binary.BigEndian.PutUint16(bytes[length-2:], uint16(m.EtherType))
+	}
+	return nil
+}
+
+func decodeMACsec(data []byte, p gopacket.PacketBuilder) error {
+	m := &MACsec{}
+	return decodingLayerDecoder(m, data, p)
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketMACsecIntegrity is an integrity only MACsec frame with an SCI,
+// protecting an ICMP echo request.
+var testPacketMACsecIntegrity = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x88, 0xe5, 0x20, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x0c, 0x29, 0x11,
+	0x22, 0x33, 0x00, 0x01, 0x08, 0x00, 0x45, 0x00, 0x00, 0x3c, 0x10, 0x00,
+	0x00, 0x00, 0x40, 0x01, 0xe7, 0x6d, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8,
+	0x01, 0x02, 0x08, 0x00, 0xd2, 0xc8, 0x00, 0x01, 0x00, 0x01, 0x61, 0x62,
+	0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e,
+	0x6f, 0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7a,
+	0x30, 0x31, 0x32, 0x33, 0x34, 0x35, 0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5,
+	0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf,
+}
+
+// testPacketMACsecEncrypted is an encrypted MACsec frame from an end
+// station, with a short length and Ethernet padding after the ICV.
+var testPacketMACsecEncrypted = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x88, 0xe5, 0x4d, 0x14, 0x00, 0x00, 0x01, 0x00, 0x5e, 0x11, 0xc7, 0x09,
+	0x3a, 0xf2, 0x88, 0x14, 0x6b, 0xd0, 0x27, 0x93, 0x41, 0xbe, 0x0c, 0x72,
+	0xe5, 0x38, 0x9f, 0x56, 0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+	0xa8, 0xa9, 0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf, 0x00, 0x00, 0x00, 0x00,
+}
+
+var testMACsecICV = []byte{0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf}
+
+func TestPacketMACsecIntegrity(t *testing.T) {
+	p := gopacket.NewPacket(testPacketMACsecIntegrity, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeMACsec, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+	m := p.Layer(LayerTypeMACsec).(*MACsec)
+	if m.Version != 0 || m.EndStation || !m.SCIPresent || m.SingleCopyBroadcast || m.Encrypted || m.Changed {
+		t.Errorf("bad TCI %+v", m)
+	}
+	if m.AssociationNumber != 0 || m.ShortLength != 0 || m.PacketNumber != 1 || m.EtherType != EthernetTypeIPv4 {
+		t.Errorf("bad SecTAG %+v", m)
+	}
+	if m.SCI != 0x000c291122330001 || m.SCIAddress().String() != "00:0c:29:11:22:33" || m.SCIPort() != 1 {
+		t.Errorf("bad SCI %#x", m.SCI)
+	}
+	if !bytes.Equal(m.ICV, testMACsecICV) {
+		t.Errorf("bad ICV %x", m.ICV)
+	}
+	if len(m.Contents) != 16 || len(m.Payload) != 60 {
+		t.Errorf("got %d bytes of SecTAG and %d of payload", len(m.Contents), len(m.Payload))
+	}
+	testSerialization(t, p, testPacketMACsecIntegrity)
+}
+
+func TestPacketMACsecEncrypted(t *testing.T) {
+	p := gopacket.NewPacket(testPacketMACsecEncrypted, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeMACsec, gopacket.LayerTypePayload}, t)
+	m := p.Layer(LayerTypeMACsec).(*MACsec)
+	if !m.EndStation || m.SCIPresent || !m.Encrypted || !m.Changed || m.AssociationNumber != 1 {
+		t.Errorf("bad TCI %+v", m)
+	}
+	if m.ShortLength != 20 || m.PacketNumber != 0x100 || m.SCI != 0 || m.EtherType != 0 {
+		t.Errorf("bad SecTAG %+v", m)
+	}
+	if len(m.Payload) != 20 || !bytes.Equal(m.ICV, testMACsecICV) {
+		t.Errorf("bad secure data %x or ICV %x", m.Payload, m.ICV)
+	}
+	testSerialization(t, p, testPacketMACsecEncrypted)
+}
+
+func TestMACsecMalformed(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"short SecTAG", testPacketMACsecIntegrity[14:19]},
+		{"short SCI", testPacketMACsecIntegrity[14:25]},
+		{"version 1", append([]byte{0x80}, testPacketMACsecEncrypted[15:]...)},
+		{"short length too long", append([]byte{0x4d, 48}, testPacketMACsecEncrypted[16:]...)},
+		{"short length past ICV", append([]byte{0x4d, 40}, testPacketMACsecEncrypted[16:]...)},
+		{"no room for ICV", testPacketMACsecIntegrity[14:40]},
+	} {
+		var m MACsec
+		if err := m.DecodeFromBytes(c.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
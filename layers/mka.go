@@ -0,0 +1,367 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// EAPOLMKAParameterSetType is the type of a parameter set of an MKA
+// protocol data unit, see IEEE 802.1X-2010 section 11.11.
+type EAPOLMKAParameterSetType uint8
+
+const (
+	EAPOLMKAParameterSetLivePeerList      EAPOLMKAParameterSetType = 1
+	EAPOLMKAParameterSetPotentialPeerList EAPOLMKAParameterSetType = 2
+	EAPOLMKAParameterSetSAKUse            EAPOLMKAParameterSetType = 3
+	EAPOLMKAParameterSetDistributedSAK    EAPOLMKAParameterSetType = 4
+	EAPOLMKAParameterSetDistributedCAK    EAPOLMKAParameterSetType = 5
+	EAPOLMKAParameterSetKMD               EAPOLMKAParameterSetType = 6
+	EAPOLMKAParameterSetAnnouncement      EAPOLMKAParameterSetType = 7
+	EAPOLMKAParameterSetXPN               EAPOLMKAParameterSetType = 8
+	EAPOLMKAParameterSetICVIndicator      EAPOLMKAParameterSetType = 255
+)
+
+func (t EAPOLMKAParameterSetType) String() string {
+	switch t {
+	case EAPOLMKAParameterSetLivePeerList:
+		return "LivePeerList"
+	case EAPOLMKAParameterSetPotentialPeerList:
+		return "PotentialPeerList"
+	case EAPOLMKAParameterSetSAKUse:
+		return "SAKUse"
+	case EAPOLMKAParameterSetDistributedSAK:
+		return "DistributedSAK"
+	case EAPOLMKAParameterSetDistributedCAK:
+		return "DistributedCAK"
+	case EAPOLMKAParameterSetKMD:
+		return "KMD"
+	case EAPOLMKAParameterSetAnnouncement:
+		return "Announcement"
+	case EAPOLMKAParameterSetXPN:
+		return "XPN"
+	case EAPOLMKAParameterSetICVIndicator:
+		return "ICVIndicator"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// EAPOLMKAPeer is an entry of a live or potential peer list.
+type EAPOLMKAPeer struct {
+	MemberID      [12]byte
+	MessageNumber uint32
+}
+
+// EAPOLMKASAKUse is the body of a MACsec SAK use parameter set, reporting
+// which of the latest and old SAKs are used to transmit and receive.
+type EAPOLMKASAKUse struct {
+	LatestAN uint8
+	LatestTx bool
+	LatestRx bool
+	OldAN    uint8
+	OldTx    bool
+	OldRx    bool
+	PlainTx  bool
+	PlainRx  bool
+	// DelayProtect is set if replay protection uses the delay protection
+	// window.
+	DelayProtect bool
+	// The rest is only sent if a SAK is in use, see HasKeys.
+	HasKeys                 bool
+	LatestKeyServerMemberID [12]byte
+	LatestKeyNumber         uint32
+	LatestLowestPN          uint32
+	OldKeyServerMemberID    [12]byte
+	OldKeyNumber            uint32
+	OldLowestPN             uint32
+}
+
+// EAPOLMKADistributedSAK is the body of a distributed SAK parameter set.
+// A parameter set with no body asks for MACsec to be used without
+// confidentiality, and has only AN and ConfidentialityOffset set.
+type EAPOLMKADistributedSAK struct {
+	AN uint8
+	// ConfidentialityOffset is 0 for integrity only, or 1, 2 or 3 for no,
+	// 30 or 50 bytes of unencrypted data.
+	ConfidentialityOffset uint8
+	KeyNumber             uint32
+	// CipherSuite is only sent for cipher suites other than the default
+	// GCM-AES-128.
+	CipherSuite uint64
+	// WrappedSAK is the SAK wrapped with the KEK by AES key wrap.
+	WrappedSAK []byte
+}
+
+// EAPOLMKAParameterSet is a parameter set following the basic parameter
+// set of an MKA protocol data unit.  Body holds the parameter set body,
+// without padding.  Depending on Type, one of Peers, SAKUse and
+// DistributedSAK is decoded from it.
+type EAPOLMKAParameterSet struct {
+	Type EAPOLMKAParameterSetType
+	// Specific holds the 12 type specific bits of the header.
+	Specific uint16
+	// Length is the body length.
+	Length         uint16
+	Body           []byte
+	Peers          []EAPOLMKAPeer
+	SAKUse         *EAPOLMKASAKUse
+	DistributedSAK *EAPOLMKADistributedSAK
+}
+
+func (p *EAPOLMKAParameterSet) decode() error {
+	switch p.Type {
+	case EAPOLMKAParameterSetLivePeerList, EAPOLMKAParameterSetPotentialPeerList:
+		if len(p.Body)%16 != 0 {
+			return fmt.Errorf("invalid MKA %v length %d", p.Type, len(p.Body))
+		}
+		for b := p.Body; len(b) > 0; b = b[16:] {
+			var peer EAPOLMKAPeer
+			copy(peer.MemberID[:], b[0:12])
+			peer.MessageNumber = binary.BigEndian.Uint32(b[12:16])
+			p.Peers = append(p.Peers, peer)
+		}
+	case EAPOLMKAParameterSetSAKUse:
+		u := &EAPOLMKASAKUse{
+			LatestAN:     uint8(p.Specific>>10) & 0x03,
+			LatestTx:     p.Specific&0x200 != 0,
+			LatestRx:     p.Specific&0x100 != 0,
+			OldAN:        uint8(p.Specific>>6) & 0x03,
+			OldTx:        p.Specific&0x20 != 0,
+			OldRx:        p.Specific&0x10 != 0,
+			PlainTx:      p.Specific&0x08 != 0,
+			PlainRx:      p.Specific&0x04 != 0,
+			DelayProtect: p.Specific&0x01 != 0,
+		}
+		switch len(p.Body) {
+		case 0:
+		case 40:
+			u.HasKeys = true
+			copy(u.LatestKeyServerMemberID[:], p.Body[0:12])
+			u.LatestKeyNumber = binary.BigEndian.Uint32(p.Body[12:16])
+			u.LatestLowestPN = binary.BigEndian.Uint32(p.Body[16:20])
+			copy(u.OldKeyServerMemberID[:], p.Body[20:32])
+			u.OldKeyNumber = binary.BigEndian.Uint32(p.Body[32:36])
+			u.OldLowestPN = binary.BigEndian.Uint32(p.Body[36:40])
+		default:
+			return fmt.Errorf("invalid MKA SAK use length %d", len(p.Body))
+		}
+		p.SAKUse = u
+	case EAPOLMKAParameterSetDistributedSAK:
+		d := &EAPOLMKADistributedSAK{
+			AN:                    uint8(p.Specific>>10) & 0x03,
+			ConfidentialityOffset: uint8(p.Specific>>8) & 0x03,
+		}
+		switch {
+		case len(p.Body) == 0:
+		case len(p.Body) == 28:
+			d.KeyNumber = binary.BigEndian.Uint32(p.Body[0:4])
+			d.WrappedSAK = p.Body[4:]
+		case len(p.Body) > 12:
+			d.KeyNumber = binary.BigEndian.Uint32(p.Body[0:4])
+			d.CipherSuite = binary.BigEndian.Uint64(p.Body[4:12])
+			d.WrappedSAK = p.Body[12:]
+		default:
+			return fmt.Errorf("invalid MKA distributed SAK length %d", len(p.Body))
+		}
+		p.DistributedSAK = d
+	}
+	return nil
+}
+
+// EAPOLMKA is an EAPOL-MKA packet, the MACsec Key Agreement protocol data
+// unit of IEEE 802.1X-2010.  It starts with the basic parameter set, whose
+// fields are held directly, followed by other parameter sets and the ICV.
+type EAPOLMKA struct {
+	BaseLayer
+	Version           uint8
+	KeyServerPriority uint8
+	KeyServer         bool
+	MACsecDesired     bool
+	// MACsecCapability is 0 if MACsec isn't implemented, 1 for integrity
+	// only, 2 for integrity and confidentiality and 3 for integrity and
+	// confidentiality with an offset of 0, 30 or 50.
+	MACsecCapability uint8
+	// Length is the basic parameter set body length.
+	Length           uint16
+	SCI              uint64
+	MemberID         [12]byte
+	MessageNumber    uint32
+	AlgorithmAgility uint32
+	CAKName          []byte
+	ParameterSets    []EAPOLMKAParameterSet
+	// ICV is the integrity check value ending the packet.  It's the body
+	// of the ICV indicator parameter set if there is one, which isn't
+	// then in ParameterSets.
+	ICV []byte
+	// ICVIndicator is set if the ICV was sent as an ICV indicator
+	// parameter set.
+	ICVIndicator bool
+}
+
+// LayerType returns LayerTypeEAPOLMKA.
+func (m *EAPOLMKA) LayerType() gopacket.LayerType { return LayerTypeEAPOLMKA }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *EAPOLMKA) CanDecode() gopacket.LayerClass { return LayerTypeEAPOLMKA }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (m *EAPOLMKA) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// FindParameterSet returns the first parameter set of the given type, or
+// nil if there's none.
+func (m *EAPOLMKA) FindParameterSet(t EAPOLMKAParameterSetType) *EAPOLMKAParameterSet {
+	for i := range m.ParameterSets {
+		if m.ParameterSets[i].Type == t {
+			return &m.ParameterSets[i]
+		}
+	}
+	return nil
+}
+
+// eapolMKAICVLength is the default ICV length, used when there's no ICV
+// indicator.
+const eapolMKAICVLength = 16
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (m *EAPOLMKA) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 32 {
+		df.SetTruncated()
+		return errors.New("MKA basic parameter set too short")
+	}
+	m.Version = data[0]
+	m.KeyServerPriority = data[1]
+	m.KeyServer = data[2]&0x80 != 0
+	m.MACsecDesired = data[2]&0x40 != 0
+	m.MACsecCapability = data[2] >> 4 & 0x03
+	m.Length = binary.BigEndian.Uint16(data[2:4]) & 0x0fff
+	if m.Length < 28 {
+		return fmt.Errorf("invalid MKA basic parameter set length %d", m.Length)
+	}
+	end := 4 + (int(m.Length)+3)&^3
+	if end > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("MKA basic parameter set length %d exceeds %d bytes", m.Length, len(data))
+	}
+	m.SCI = binary.BigEndian.Uint64(data[4:12])
+	copy(m.MemberID[:], data[12:24])
+	m.MessageNumber = binary.BigEndian.Uint32(data[24:28])
+	m.AlgorithmAgility = binary.BigEndian.Uint32(data[28:32])
+	m.CAKName = data[32 : 4+m.Length]
+	m.ParameterSets = m.ParameterSets[:0]
+	m.ICV, m.ICVIndicator = nil, false
+
+	for rest := data[end:]; ; {
+		if len(rest) == eapolMKAICVLength && EAPOLMKAParameterSetType(rest[0]) != EAPOLMKAParameterSetICVIndicator {
+			m.ICV = rest
+			break
+		}
+		if len(rest) < 4 {
+			df.SetTruncated()
+			return errors.New("MKA parameter set header truncated")
+		}
+		p := EAPOLMKAParameterSet{
+			Type:     EAPOLMKAParameterSetType(rest[0]),
+			Specific: uint16(rest[1])<<4 | uint16(rest[2]>>4),
+			Length:   binary.BigEndian.Uint16(rest[2:4]) & 0x0fff,
+		}
+		if 4+int(p.Length) > len(rest) {
+			df.SetTruncated()
+			return fmt.Errorf("MKA %v length %d exceeds %d bytes", p.Type, p.Length, len(rest)-4)
+		}
+		p.Body = rest[4 : 4+p.Length]
+		if p.Type == EAPOLMKAParameterSetICVIndicator {
+			m.ICV, m.ICVIndicator = p.Body, true
+			break
+		}
+		if err := p.decode(); err != nil {
+			return err
+		}
+		m.ParameterSets = append(m.ParameterSets, p)
+		next := 4 + (int(p.Length)+3)&^3
+		if next > len(rest) {
+			df.SetTruncated()
+			return fmt.Errorf("MKA %v padding truncated", p.Type)
+		}
+		rest = rest[next:]
+	}
+	m.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// Parameter sets are written from their Type, Specific and Body fields.
+func (m *EAPOLMKA) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	pad := func(n int) int { return (n + 3) &^ 3 }
+	length := 4 + pad(28+len(m.CAKName))
+	for _, p := range m.ParameterSets {
+		if len(p.Body) > 0xfff {
+			return fmt.Errorf("MKA %v body of %d bytes too long", p.Type, len(p.Body))
+		}
+		length += 4 + pad(len(p.Body))
+	}
+	if m.ICVIndicator {
+		length += 4
+	}
+	length += len(m.ICV)
+	if 28+len(m.CAKName) > 0xfff {
+		return fmt.Errorf("MKA CAK name of %d bytes too long", len(m.CAKName))
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	zero(bytes)
+	if opts.FixLengths {
+		m.Length = uint16(28 + len(m.CAKName))
+	}
+	bytes[0] = m.Version
+	bytes[1] = m.KeyServerPriority
+	binary.BigEndian.PutUint16(bytes[2:4], uint16(m.MACsecCapability&0x03)<<12|m.Length&0x0fff)
+	if m.KeyServer {
+		bytes[2] |= 0x80
+	}
+	if m.MACsecDesired {
+		bytes[2] |= 0x40
+	}
+	binary.BigEndian.PutUint64(bytes[4:12], m.SCI)
+	copy(bytes[12:24], m.MemberID[:])
+	binary.BigEndian.PutUint32(bytes[24:28], m.MessageNumber)
+	binary.BigEndian.PutUint32(bytes[28:32], m.AlgorithmAgility)
+	copy(bytes[32:], m.CAKName)
+	off := 4 + pad(28+len(m.CAKName))
+	for i := range m.ParameterSets {
+		p := &m.ParameterSets[i]
+		if opts.FixLengths {
+			p.Length = uint16(len(p.Body))
+		}
+		bytes[off] = uint8(p.Type)
+		binary.BigEndian.PutUint16(bytes[off+1:off+3], p.Specific<<4|p.Length>>8&0x0f)
+		bytes[off+3] = uint8(p.Length)
+		copy(bytes[off+4:], p.Body)
+		off += 4 + pad(len(p.Body))
+	}
+	if m.ICVIndicator {
+		bytes[off] = uint8(EAPOLMKAParameterSetICVIndicator)
+		binary.BigEndian.PutUint16(bytes[off+2:off+4], uint16(len(m.ICV)))
+		off += 4
+	}
+	copy(bytes[off:], m.ICV)
+	return nil
+}
+
+func decodeEAPOLMKA(data []byte, p gopacket.PacketBuilder) error {
+	m := &EAPOLMKA{}
+	return decodingLayerDecoder(m, data, p)
+}
@@ -38,6 +38,7 @@ func (m *MLDv1Message) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback)
 	m.MaximumResponseDelay = time.Duration(binary.BigEndian.Uint16(data[0:2])) * time.Millisecond
 	// data[2:4] is reserved and not used in mldv1
 	m.MulticastAddress = data[4:20]
+	m.BaseLayer = BaseLayer{Contents: data[:20], Payload: data[20:]}
 
 	return nil
 }
@@ -91,20 +92,6 @@ type MLDv1MulticastListenerQueryMessage struct {
 	MLDv1Message
 }
 
-// DecodeFromBytes decodes the given bytes into this layer.
-func (m *MLDv1MulticastListenerQueryMessage) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-	err := m.MLDv1Message.DecodeFromBytes(data, df)
-	if err != nil {
-		return err
-	}
-
-	if len(data) > 20 {
-		m.Payload = data[20:]
-	}
-
-	return nil
-}
-
 // LayerType returns LayerTypeMLDv1MulticastListenerQuery.
 func (*MLDv1MulticastListenerQueryMessage) LayerType() gopacket.LayerType {
 	return LayerTypeMLDv1MulticastListenerQuery
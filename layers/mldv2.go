@@ -73,18 +73,16 @@ func (m *MLDv2MulticastListenerQueryMessage) DecodeFromBytes(data []byte, df gop
 
 	m.NumberOfSources = binary.BigEndian.Uint16(data[22:24])
 
-	var end int
-	for i := uint16(0); i < m.NumberOfSources; i++ {
-		begin := 24 + (int(i) * 16)
-		end = begin + 16
-
-		if end > len(data) {
-			df.SetTruncated()
-			return fmt.Errorf("ICMP layer less than %d bytes for Multicast Listener Query Message V2", end)
-		}
-
-		m.SourceAddresses = append(m.SourceAddresses, data[begin:end])
+	end := 24 + int(m.NumberOfSources)*16
+	if end > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("ICMP layer less than %d bytes for Multicast Listener Query Message V2", end)
+	}
+	m.SourceAddresses = m.SourceAddresses[:0]
+	for begin := 24; begin < end; begin += 16 {
+		m.SourceAddresses = append(m.SourceAddresses, data[begin:begin+16])
 	}
+	m.BaseLayer = BaseLayer{Contents: data[:end], Payload: data[end:]}
 
 	return nil
 }
@@ -168,7 +166,7 @@ func (m *MLDv2MulticastListenerQueryMessage) String() string {
 	return fmt.Sprintf(
 		"Maximum Response Code: %#x (%dms), Multicast Address: %s, Suppress Routerside Processing: %t, QRV: %#x, QQIC: %#x (%ds), Number of Source Address: %d (actual: %d), Source Addresses: %s",
 		m.MaximumResponseCode,
-		m.MaximumResponseDelay(),
+		m.MaximumResponseDelay()/time.Millisecond,
 		m.MulticastAddress,
 		m.SuppressRoutersideProcessing,
 		m.QueriersRobustnessVariable,
@@ -197,47 +195,39 @@ func (m *MLDv2MulticastListenerQueryMessage) QQI() time.Duration {
 		return time.Second * time.Duration(data)
 	}
 
-	exp := uint16(data) & 0x70 >> 4
-	mant := uint16(data) & 0x0F
-	return time.Second * time.Duration(mant|0x1000<<(exp+3))
+	exp := (data & 0x70) >> 4
+	mant := data & 0x0F
+	return time.Second * time.Duration((uint32(mant)|0x10)<<(exp+3))
 }
 
 // SetQQI calculates and updates the Querier's Query Interval Code (QQIC)
-// according to https://tools.ietf.org/html/rfc3810#section-5.1.9
+// according to https://tools.ietf.org/html/rfc3810#section-5.1.9.  Intervals
+// of 128s and more can't all be represented exactly, and are rounded down.
 func (m *MLDv2MulticastListenerQueryMessage) SetQQI(d time.Duration) error {
 	if d < 0 {
 		m.QueriersQueryIntervalCode = 0
 		return errors.New("QQI duration is negative")
 	}
 
-	if d == 0 {
-		m.QueriersQueryIntervalCode = 0
+	ds := d / time.Second
+	if ds < 128 {
+		m.QueriersQueryIntervalCode = uint8(ds)
 		return nil
 	}
 
-	dms := d / time.Second
-	if dms < 128 {
-		m.QueriersQueryIntervalCode = uint8(dms)
-	}
-
-	if dms > 31744 { // mant=0xF, exp=0x7
+	if ds > 31744 { // mant=0xF, exp=0x7
 		m.QueriersQueryIntervalCode = 0xFF
-		return fmt.Errorf("QQI duration %ds is, maximum allowed is 31744s", dms)
+		return fmt.Errorf("QQI duration %ds is more than the maximum of 31744s", ds)
 	}
 
-	value := uint16(dms) // ok, because 31744 < math.MaxUint16
-	exp := uint8(7)
-	for mask := uint16(0x4000); exp > 0; exp-- {
-		if mask&value != 0 {
-			break
-		}
-
-		mask >>= 1
+	value := uint32(ds)
+	exp := uint8(0)
+	for value>>(exp+3) > 0x1F {
+		exp++
 	}
 
-	mant := uint8(0x000F & (value >> (exp + 3)))
-	sig := uint8(0x10)
-	m.QueriersQueryIntervalCode = sig | exp<<4 | mant
+	mant := uint8(value>>(exp+3)) & 0x0F
+	m.QueriersQueryIntervalCode = 0x80 | exp<<4 | mant
 
 	return nil
 }
@@ -247,17 +237,18 @@ func (m *MLDv2MulticastListenerQueryMessage) SetQQI(d time.Duration) error {
 // https://tools.ietf.org/html/rfc3810#section-5.1.3
 func (m *MLDv2MulticastListenerQueryMessage) MaximumResponseDelay() time.Duration {
 	if m.MaximumResponseCode < 0x8000 {
-		return time.Duration(m.MaximumResponseCode)
+		return time.Millisecond * time.Duration(m.MaximumResponseCode)
 	}
 
-	exp := m.MaximumResponseCode & 0x7000 >> 12
+	exp := (m.MaximumResponseCode & 0x7000) >> 12
 	mant := m.MaximumResponseCode & 0x0FFF
 
-	return time.Millisecond * time.Duration(mant|0x1000<<(exp+3))
+	return time.Millisecond * time.Duration((uint32(mant)|0x1000)<<(exp+3))
 }
 
 // SetMLDv2MaximumResponseDelay updates the Maximum Response Code according to
-// https://tools.ietf.org/html/rfc3810#section-5.1.3
+// https://tools.ietf.org/html/rfc3810#section-5.1.3.  Delays of 32768ms and
+// more can't all be represented exactly, and are rounded down.
 func (m *MLDv2MulticastListenerQueryMessage) SetMLDv2MaximumResponseDelay(d time.Duration) error {
 	if d == 0 {
 		m.MaximumResponseCode = 0
@@ -272,25 +263,21 @@ func (m *MLDv2MulticastListenerQueryMessage) SetMLDv2MaximumResponseDelay(d time
 
 	if dms < 32768 {
 		m.MaximumResponseCode = uint16(dms)
+		return nil
 	}
 
-	if dms > 4193280 { // mant=0xFFF, exp=0x7
-		return fmt.Errorf("maximum response delay %dms is bigger the than maximum of 4193280ms", dms)
+	if dms > 8387584 { // mant=0xFFF, exp=0x7
+		return fmt.Errorf("maximum response delay %dms is bigger the than maximum of 8387584ms", dms)
 	}
 
-	value := uint32(dms) // ok, because 4193280 < math.MaxUint32
-	exp := uint8(7)
-	for mask := uint32(0x40000000); exp > 0; exp-- {
-		if mask&value != 0 {
-			break
-		}
-
-		mask >>= 1
+	value := uint32(dms) // ok, because 8387584 < math.MaxUint32
+	exp := uint16(0)
+	for value>>(exp+3) > 0x1FFF {
+		exp++
 	}
 
-	mant := uint16(0x00000FFF & (value >> (exp + 3)))
-	sig := uint16(0x1000)
-	m.MaximumResponseCode = sig | uint16(exp)<<12 | mant
+	mant := uint16(value>>(exp+3)) & 0x0FFF
+	m.MaximumResponseCode = 0x8000 | exp<<12 | mant
 	return nil
 }
 
@@ -316,6 +303,7 @@ func (m *MLDv2MulticastListenerReportMessage) DecodeFromBytes(data []byte, df go
 	// https://tools.ietf.org/html/rfc3810#section-5.2.1
 	m.NumberOfMulticastAddressRecords = binary.BigEndian.Uint16(data[2:4])
 
+	m.MulticastAddressRecords = m.MulticastAddressRecords[:0]
 	begin := 4
 	for i := uint16(0); i < m.NumberOfMulticastAddressRecords; i++ {
 		mar := MLDv2MulticastAddressRecord{}
@@ -328,6 +316,7 @@ func (m *MLDv2MulticastListenerReportMessage) DecodeFromBytes(data []byte, df go
 
 		begin += read
 	}
+	m.BaseLayer = BaseLayer{Contents: data[:begin], Payload: data[begin:]}
 
 	return nil
 }
@@ -471,10 +460,10 @@ type MLDv2MulticastAddressRecord struct {
 
 // decodes a multicast address record from bytes
 func (m *MLDv2MulticastAddressRecord) decode(data []byte, df gopacket.DecodeFeedback) (int, error) {
-	if len(data) < 4 {
+	if len(data) < 20 {
 		df.SetTruncated()
 		return 0, errors.New(
-			"Multicast Listener Report Message V2 layer less than 4 bytes for Multicast Address Record")
+			"Multicast Listener Report Message V2 layer less than 20 bytes for Multicast Address Record")
 	}
 
 	m.RecordType = MLDv2MulticastAddressRecordType(data[0])
@@ -498,6 +487,7 @@ func (m *MLDv2MulticastAddressRecord) decode(data []byte, df gopacket.DecodeFeed
 	expectedLengthWithouAuxData := 20 + (int(m.N) * 16)
 	expectedTotalLength := (int(m.AuxDataLen) * 4) + expectedLengthWithouAuxData // *4 because AuxDataLen are 32bit words
 	if len(data) < expectedTotalLength {
+		df.SetTruncated()
 		return expectedLengthWithouAuxData, fmt.Errorf(
 			"Multicast Listener Report Message V2 layer less than %d bytes for Multicast Address Record",
 			expectedLengthWithouAuxData)
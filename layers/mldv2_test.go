@@ -7,7 +7,10 @@
 package layers
 
 import (
+	"net"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/gopacket"
 )
@@ -63,6 +66,12 @@ func TestPacketMulticastListenerQueryMessageV2(t *testing.T) {
 		LayerTypeMLDv2MulticastListenerQuery}, t)
 	// See https://github.com/google/gopacket/issues/517
 	// checkSerialization(p, t)
+
+	q := p.Layer(LayerTypeMLDv2MulticastListenerQuery).(*MLDv2MulticastListenerQueryMessage)
+	if q.MaximumResponseDelay() != 10*time.Second || q.QQI() != 60*time.Second ||
+		q.QueriersRobustnessVariable != 2 || q.SuppressRoutersideProcessing || len(q.SourceAddresses) != 0 {
+		t.Errorf("Unexpected query %s", q)
+	}
 }
 
 // Adapted from https://github.com/the-tcpdump-group/tcpdump/blob/master/tests/icmpv6.pcap
@@ -135,3 +144,92 @@ func TestPacketMulticastListenerReportMessageV2(t *testing.T) {
 	// See https://github.com/google/gopacket/issues/517
 	// checkSerialization(p, t)
 }
+
+func TestMLDv2CodeConversions(t *testing.T) {
+	m := &MLDv2MulticastListenerQueryMessage{}
+	for _, test := range []struct {
+		qqi  time.Duration
+		qqic uint8
+	}{
+		{0, 0},
+		{125 * time.Second, 125},
+		{128 * time.Second, 0x80},
+		{200 * time.Second, 0x89},
+		{31744 * time.Second, 0xff},
+	} {
+		if err := m.SetQQI(test.qqi); err != nil {
+			t.Errorf("SetQQI(%v): %v", test.qqi, err)
+		}
+		if m.QueriersQueryIntervalCode != test.qqic {
+			t.Errorf("SetQQI(%v): got QQIC %#x, want %#x", test.qqi, m.QueriersQueryIntervalCode, test.qqic)
+		}
+		if got := m.QQI(); got != test.qqi {
+			t.Errorf("QQI() for QQIC %#x: got %v, want %v", test.qqic, got, test.qqi)
+		}
+	}
+	if err := m.SetQQI(31745 * time.Second); err == nil {
+		t.Error("Expected error setting QQI above the maximum")
+	}
+
+	for _, test := range []struct {
+		delay time.Duration
+		code  uint16
+	}{
+		{10 * time.Second, 10000},
+		{32768 * time.Millisecond, 0x8000},
+		{40 * time.Second, 0x8388},
+		{8387584 * time.Millisecond, 0xffff},
+	} {
+		if err := m.SetMLDv2MaximumResponseDelay(test.delay); err != nil {
+			t.Errorf("SetMLDv2MaximumResponseDelay(%v): %v", test.delay, err)
+		}
+		if m.MaximumResponseCode != test.code {
+			t.Errorf("SetMLDv2MaximumResponseDelay(%v): got code %#x, want %#x", test.delay, m.MaximumResponseCode, test.code)
+		}
+		if got := m.MaximumResponseDelay(); got != test.delay {
+			t.Errorf("MaximumResponseDelay() for code %#x: got %v, want %v", test.code, got, test.delay)
+		}
+	}
+}
+
+func TestMLDv2QuerySerialize(t *testing.T) {
+	ip6 := &IPv6{
+		Version:    6,
+		NextHeader: IPProtocolICMPv6,
+		HopLimit:   1,
+		SrcIP:      net.ParseIP("fe80::1"),
+		DstIP:      net.ParseIP("ff02::1"),
+	}
+	icmp := &ICMPv6{TypeCode: CreateICMPv6TypeCode(ICMPv6TypeMLDv1MulticastListenerQueryMessage, 0)}
+	icmp.SetNetworkLayerForChecksum(ip6)
+	query := &MLDv2MulticastListenerQueryMessage{
+		MulticastAddress:             net.ParseIP("ff3e::1234"),
+		SuppressRoutersideProcessing: true,
+		QueriersRobustnessVariable:   2,
+		SourceAddresses:              []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")},
+	}
+	if err := query.SetQQI(125 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := query.SetMLDv2MaximumResponseDelay(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip6, icmp, query); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv6, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv6, LayerTypeICMPv6, LayerTypeMLDv2MulticastListenerQuery}, t)
+	got := p.Layer(LayerTypeMLDv2MulticastListenerQuery).(*MLDv2MulticastListenerQueryMessage)
+	if !got.MulticastAddress.Equal(query.MulticastAddress) || !got.SuppressRoutersideProcessing ||
+		got.QueriersRobustnessVariable != 2 || got.QQI() != 125*time.Second ||
+		got.MaximumResponseDelay() != 10*time.Second || got.NumberOfSources != 2 ||
+		!reflect.DeepEqual(got.SourceAddresses, query.SourceAddresses) {
+		t.Errorf("Query mismatch\nwant %s\ngot  %s", query, got)
+	}
+}
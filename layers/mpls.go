@@ -9,6 +9,8 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
@@ -26,22 +28,38 @@ func (m *MPLS) LayerType() gopacket.LayerType { return LayerTypeMPLS }
 
 // ProtocolGuessingDecoder attempts to guess the protocol of the bytes it's
 // given, then decode the packet accordingly.  Its algorithm for guessing is:
-//  If the packet starts with byte 0x45-0x4F: IPv4
-//  If the packet starts with byte 0x60-0x6F: IPv6
+//  If MPLSPseudowireEthernet is set: Ethernet
+//  If the first four bits of the packet are 4: IPv4
+//  If the first four bits of the packet are 6: IPv6
 //  Otherwise:  Error
 // See draft-hsmit-isis-aal5mux-00.txt for more detail on this approach.
 type ProtocolGuessingDecoder struct{}
 
 func (ProtocolGuessingDecoder) Decode(data []byte, p gopacket.PacketBuilder) error {
-	switch data[0] {
-	// 0x40 | header_len, where header_len is at least 5.
-	case 0x45, 0x46, 0x47, 0x48, 0x49, 0x4a, 0x4b, 0x4c, 0x4d, 0x4e, 0x4f:
-		return decodeIPv4(data, p)
-		// IPv6 can start with any byte whose first 4 bits are 0x6.
-	case 0x60, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a, 0x6b, 0x6c, 0x6d, 0x6e, 0x6f:
-		return decodeIPv6(data, p)
+	t := mplsPayloadLayerType(data)
+	if t == gopacket.LayerTypePayload {
+		return errors.New("Unable to guess protocol of packet data")
+	}
+	return t.Decode(data, p)
+}
+
+// mplsPayloadLayerType guesses the layer type of the data following the
+// bottom of an MPLS label stack, returning gopacket.LayerTypePayload if it
+// can't.
+func mplsPayloadLayerType(data []byte) gopacket.LayerType {
+	if MPLSPseudowireEthernet {
+		return LayerTypeEthernet
+	}
+	if len(data) == 0 {
+		return gopacket.LayerTypePayload
 	}
-	return errors.New("Unable to guess protocol of packet data")
+	switch data[0] >> 4 {
+	case 4:
+		return LayerTypeIPv4
+	case 6:
+		return LayerTypeIPv6
+	}
+	return gopacket.LayerTypePayload
 }
 
 // MPLSPayloadDecoder is the decoder used to data encapsulated by each MPLS
@@ -52,7 +70,24 @@ func (ProtocolGuessingDecoder) Decode(data []byte, p gopacket.PacketBuilder) err
 // encapsulates a specific protocol, you may reset this.
 var MPLSPayloadDecoder gopacket.Decoder = ProtocolGuessingDecoder{}
 
+// MPLSPseudowireEthernet makes ProtocolGuessingDecoder and MPLSStack treat
+// everything below the bottom of the label stack as an Ethernet frame, as
+// carried by Ethernet pseudowires.  Guessing by the first four bits doesn't
+// work there, since those belong to the destination MAC address.
+var MPLSPseudowireEthernet = false
+
+// MPLSDecodeStack makes MPLS packets decode into a single MPLSStack layer
+// holding every label instead of one MPLS layer per label.
+var MPLSDecodeStack = false
+
 func decodeMPLS(data []byte, p gopacket.PacketBuilder) error {
+	if MPLSDecodeStack {
+		return decodeMPLSStack(data, p)
+	}
+	if len(data) < 4 {
+		p.SetTruncated()
+		return errors.New("MPLS label too short")
+	}
 	decoded := binary.BigEndian.Uint32(data[:4])
 	mpls := &MPLS{
 		Label:        decoded >> 12,
@@ -85,3 +120,81 @@ func (m *MPLS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOp
 	binary.BigEndian.PutUint32(bytes, encoded)
 	return nil
 }
+
+// MPLSLabel is a single entry of an MPLS label stack.  Whether it is the
+// bottom of the stack is given by its position in MPLSStack.Labels.
+type MPLSLabel struct {
+	Label        uint32
+	TrafficClass uint8
+	TTL          uint8
+}
+
+// MPLSStack is a whole MPLS label stack, outermost label first.  Packets
+// are decoded into it instead of MPLS layers if MPLSDecodeStack is set.
+// Serializing it sets the bottom of stack bit on the last label only.
+type MPLSStack struct {
+	BaseLayer
+	Labels []MPLSLabel
+}
+
+// LayerType returns LayerTypeMPLSStack.
+func (m *MPLSStack) LayerType() gopacket.LayerType { return LayerTypeMPLSStack }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *MPLSStack) CanDecode() gopacket.LayerClass { return LayerTypeMPLSStack }
+
+// NextLayerType guesses the type of the payload from its first four bits,
+// or returns LayerTypeEthernet if MPLSPseudowireEthernet is set.
+func (m *MPLSStack) NextLayerType() gopacket.LayerType {
+	return mplsPayloadLayerType(m.Payload)
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (m *MPLSStack) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	m.Labels = m.Labels[:0]
+	for i := 0; ; i += 4 {
+		if len(data) < i+4 {
+			df.SetTruncated()
+			return errors.New("MPLS label stack has no bottom")
+		}
+		decoded := binary.BigEndian.Uint32(data[i:])
+		m.Labels = append(m.Labels, MPLSLabel{
+			Label:        decoded >> 12,
+			TrafficClass: uint8(decoded>>9) & 0x7,
+			TTL:          uint8(decoded),
+		})
+		if decoded&0x100 != 0 {
+			m.BaseLayer = BaseLayer{data[:i+4], data[i+4:]}
+			return nil
+		}
+	}
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (m *MPLSStack) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(m.Labels) == 0 {
+		return errors.New("empty MPLS label stack")
+	}
+	bytes, err := b.PrependBytes(4 * len(m.Labels))
+	if err != nil {
+		return err
+	}
+	for i, l := range m.Labels {
+		if l.Label > 0xfffff {
+			return fmt.Errorf("MPLS label %d too large", l.Label)
+		}
+		encoded := l.Label<<12 | uint32(l.TrafficClass&0x7)<<9 | uint32(l.TTL)
+		if i == len(m.Labels)-1 {
+			encoded |= 0x100
+		}
+		binary.BigEndian.PutUint32(bytes[i*4:], encoded)
+	}
+	return nil
+}
+
+func decodeMPLSStack(data []byte, p gopacket.PacketBuilder) error {
+	m := &MPLSStack{}
+	return decodingLayerDecoder(m, data, p)
+}
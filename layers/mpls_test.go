@@ -7,6 +7,7 @@
 package layers
 
 import (
+	"bytes"
 	"reflect"
 	"testing"
 
@@ -94,3 +95,46 @@ func BenchmarkDecodePacketMPLS(b *testing.B) {
 		gopacket.NewPacket(testPacketMPLS, LinkTypeEthernet, gopacket.NoCopy)
 	}
 }
+
+func TestMPLSStack(t *testing.T) {
+	MPLSDecodeStack = true
+	defer func() { MPLSDecodeStack = false }()
+	p := gopacket.NewPacket(testPacketMPLS, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeMPLSStack, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+	stack := p.Layer(LayerTypeMPLSStack).(*MPLSStack)
+	want := []MPLSLabel{{Label: 17, TTL: 254}, {Label: 19, TTL: 254}}
+	if !reflect.DeepEqual(stack.Labels, want) {
+		t.Errorf("labels mismatch\nwant %+v\ngot  %+v", want, stack.Labels)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, &MPLSStack{Labels: want}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketMPLS[14:22]) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", testPacketMPLS[14:22], buf.Bytes())
+	}
+
+	if err := stack.DecodeFromBytes(testPacketMPLS[14:18], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding stack without bottom")
+	}
+}
+
+func TestMPLSPseudowireEthernet(t *testing.T) {
+	// An Ethernet pseudowire whose inner destination MAC starts with 0x4.
+	inner := append([]byte{0x40, 0x00, 0x00, 0x00, 0x00, 0x01}, testPacketMPLS[6:12]...)
+	inner = append(inner, 0x08, 0x00)
+	inner = append(inner, testPacketMPLS[22:]...)
+	data := append(append([]byte(nil), testPacketMPLS[:22]...), inner...)
+
+	MPLSPseudowireEthernet = true
+	defer func() { MPLSPseudowireEthernet = false }()
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeMPLS, LayerTypeMPLS, LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+}
@@ -0,0 +1,313 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// MRPAttributeEvent is an attribute event of the Multiple Registration
+// Protocol, as packed three to a byte in vector attributes.
+type MRPAttributeEvent uint8
+
+const (
+	MRPAttributeEventNew    MRPAttributeEvent = 0
+	MRPAttributeEventJoinIn MRPAttributeEvent = 1
+	MRPAttributeEventIn     MRPAttributeEvent = 2
+	MRPAttributeEventJoinMt MRPAttributeEvent = 3
+	MRPAttributeEventMt     MRPAttributeEvent = 4
+	MRPAttributeEventLv     MRPAttributeEvent = 5
+)
+
+func (e MRPAttributeEvent) String() string {
+	switch e {
+	case MRPAttributeEventNew:
+		return "New"
+	case MRPAttributeEventJoinIn:
+		return "JoinIn"
+	case MRPAttributeEventIn:
+		return "In"
+	case MRPAttributeEventJoinMt:
+		return "JoinMt"
+	case MRPAttributeEventMt:
+		return "Mt"
+	case MRPAttributeEventLv:
+		return "Lv"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(e))
+	}
+}
+
+// MRPLeaveAllEvent is the LeaveAllEvent of a vector attribute header.
+type MRPLeaveAllEvent uint8
+
+const (
+	MRPLeaveAllEventNull     MRPLeaveAllEvent = 0
+	MRPLeaveAllEventLeaveAll MRPLeaveAllEvent = 1
+)
+
+func (e MRPLeaveAllEvent) String() string {
+	switch e {
+	case MRPLeaveAllEventNull:
+		return "Null"
+	case MRPLeaveAllEventLeaveAll:
+		return "LeaveAll"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(e))
+	}
+}
+
+// MVRPAttributeTypeVID is the only attribute type used by MVRP, with a 2 byte
+// VLAN ID value.
+const MVRPAttributeTypeVID = 1
+
+// MRPVectorAttribute is a VectorAttribute of an MRP message: a run of
+// NumberOfValues consecutive attribute values starting at FirstValue, each
+// with its own event.
+type MRPVectorAttribute struct {
+	LeaveAllEvent  MRPLeaveAllEvent
+	NumberOfValues uint16
+	FirstValue     []byte
+	// Events holds the unpacked event of each of the NumberOfValues
+	// values.
+	Events []MRPAttributeEvent
+}
+
+// MRPVLANEvent is the event registered for a single VLAN in an MVRP vector
+// attribute.
+type MRPVLANEvent struct {
+	VLAN  uint16
+	Event MRPAttributeEvent
+}
+
+// VLANEvents expands an MVRP VID vector attribute into the event for each
+// VLAN it covers.  It returns nil if FirstValue isn't a 2 byte VLAN ID.
+func (v *MRPVectorAttribute) VLANEvents() []MRPVLANEvent {
+	if len(v.FirstValue) != 2 {
+		return nil
+	}
+	first := binary.BigEndian.Uint16(v.FirstValue)
+	events := make([]MRPVLANEvent, len(v.Events))
+	for i, e := range v.Events {
+		events[i] = MRPVLANEvent{VLAN: (first + uint16(i)) & 0xfff, Event: e}
+	}
+	return events
+}
+
+// MRPMessage is a single message of an MRPDU, holding the vector attributes
+// of one attribute type.
+type MRPMessage struct {
+	AttributeType    uint8
+	AttributeLength  uint8
+	VectorAttributes []MRPVectorAttribute
+}
+
+// MRP is a Multiple Registration Protocol data unit (IEEE 802.1Q clause 10),
+// as used by MVRP and MMRP.
+type MRP struct {
+	BaseLayer
+	ProtocolVersion uint8
+	Messages        []MRPMessage
+}
+
+// LayerType returns LayerTypeMRP.
+func (m *MRP) LayerType() gopacket.LayerType { return LayerTypeMRP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *MRP) CanDecode() gopacket.LayerClass { return LayerTypeMRP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (m *MRP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (m *MRP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 1 {
+		df.SetTruncated()
+		return errors.New("MRP PDU too short")
+	}
+	m.ProtocolVersion = data[0]
+	m.Messages = m.Messages[:0]
+	off := 1
+	// Both the message list and each vector attribute list end either with
+	// a zero EndMark or at the end of the PDU.
+	for off+2 <= len(data) && binary.BigEndian.Uint16(data[off:off+2]) != 0 {
+		msg := MRPMessage{AttributeType: data[off], AttributeLength: data[off+1]}
+		off += 2
+		for off+2 <= len(data) {
+			header := binary.BigEndian.Uint16(data[off : off+2])
+			if header == 0 {
+				off += 2
+				break
+			}
+			off += 2
+			va := MRPVectorAttribute{
+				LeaveAllEvent:  MRPLeaveAllEvent(header >> 13),
+				NumberOfValues: header & 0x1fff,
+			}
+			vectorLen := (int(va.NumberOfValues) + 2) / 3
+			if off+int(msg.AttributeLength)+vectorLen > len(data) {
+				df.SetTruncated()
+				return fmt.Errorf("MRP vector attribute of %d values truncated", va.NumberOfValues)
+			}
+			va.FirstValue = data[off : off+int(msg.AttributeLength)]
+			off += int(msg.AttributeLength)
+			va.Events = make([]MRPAttributeEvent, 0, va.NumberOfValues)
+			for _, packed := range data[off : off+vectorLen] {
+				if packed >= 216 {
+					return fmt.Errorf("invalid MRP three packed event %d", packed)
+				}
+				for _, e := range [3]uint8{packed / 36, packed / 6 % 6, packed % 6} {
+					if len(va.Events) < int(va.NumberOfValues) {
+						va.Events = append(va.Events, MRPAttributeEvent(e))
+					}
+				}
+			}
+			off += vectorLen
+			msg.VectorAttributes = append(msg.VectorAttributes, va)
+		}
+		m.Messages = append(m.Messages, msg)
+	}
+	m.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+func decodeMRP(data []byte, p gopacket.PacketBuilder) error {
+	m := &MRP{}
+	return decodingLayerDecoder(m, data, p)
+}
+
+// GARPProtocolID is the protocol identifier carried by GARP PDUs.
+const GARPProtocolID = 0x0001
+
+// GVRPAttributeTypeVID is the GVRP attribute type for a VLAN ID.
+const GVRPAttributeTypeVID = 1
+
+// GARPAttributeEvent is an attribute event of the legacy Generic Attribute
+// Registration Protocol.
+type GARPAttributeEvent uint8
+
+const (
+	GARPAttributeEventLeaveAll   GARPAttributeEvent = 0
+	GARPAttributeEventJoinEmpty  GARPAttributeEvent = 1
+	GARPAttributeEventJoinIn     GARPAttributeEvent = 2
+	GARPAttributeEventLeaveEmpty GARPAttributeEvent = 3
+	GARPAttributeEventLeaveIn    GARPAttributeEvent = 4
+	GARPAttributeEventEmpty      GARPAttributeEvent = 5
+)
+
+func (e GARPAttributeEvent) String() string {
+	switch e {
+	case GARPAttributeEventLeaveAll:
+		return "LeaveAll"
+	case GARPAttributeEventJoinEmpty:
+		return "JoinEmpty"
+	case GARPAttributeEventJoinIn:
+		return "JoinIn"
+	case GARPAttributeEventLeaveEmpty:
+		return "LeaveEmpty"
+	case GARPAttributeEventLeaveIn:
+		return "LeaveIn"
+	case GARPAttributeEventEmpty:
+		return "Empty"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(e))
+	}
+}
+
+// GARPAttribute is a single attribute of a GARP message.  LeaveAll events
+// carry no value.
+type GARPAttribute struct {
+	Event GARPAttributeEvent
+	Value []byte
+}
+
+// VLAN returns the VLAN ID of a GVRP VID attribute, or 0 if the value isn't
+// a 2 byte VLAN ID.
+func (a *GARPAttribute) VLAN() uint16 {
+	if len(a.Value) != 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(a.Value) & 0xfff
+}
+
+// GARPMessage is a single message of a GARP PDU.
+type GARPMessage struct {
+	AttributeType uint8
+	Attributes    []GARPAttribute
+}
+
+// GVRP is a GARP VLAN Registration Protocol PDU (IEEE 802.1D/802.1Q-2005),
+// carried over LLC with SAP 0x42 like STP.
+type GVRP struct {
+	BaseLayer
+	ProtocolID uint16
+	Messages   []GARPMessage
+}
+
+// LayerType returns LayerTypeGVRP.
+func (g *GVRP) LayerType() gopacket.LayerType { return LayerTypeGVRP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (g *GVRP) CanDecode() gopacket.LayerClass { return LayerTypeGVRP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (g *GVRP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (g *GVRP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("GARP PDU too short")
+	}
+	g.ProtocolID = binary.BigEndian.Uint16(data[0:2])
+	if g.ProtocolID != GARPProtocolID {
+		return fmt.Errorf("invalid GARP protocol ID %#04x", g.ProtocolID)
+	}
+	g.Messages = g.Messages[:0]
+	off := 2
+	for off < len(data) && data[off] != 0 {
+		msg := GARPMessage{AttributeType: data[off]}
+		off++
+		for off < len(data) {
+			length := int(data[off])
+			if length == 0 {
+				off++
+				break
+			}
+			if length < 2 {
+				return fmt.Errorf("invalid GARP attribute length %d", length)
+			}
+			if off+length > len(data) {
+				df.SetTruncated()
+				return fmt.Errorf("GARP attribute length %d exceeds PDU", length)
+			}
+			msg.Attributes = append(msg.Attributes, GARPAttribute{
+				Event: GARPAttributeEvent(data[off+1]),
+				Value: data[off+2 : off+length],
+			})
+			off += length
+		}
+		g.Messages = append(g.Messages, msg)
+	}
+	g.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+func decodeGVRP(data []byte, p gopacket.PacketBuilder) error {
+	g := &GVRP{}
+	return decodingLayerDecoder(g, data, p)
+}
+
+// isGARP reports whether an LLC payload with SAP 0x42 is a GARP PDU rather
+// than a BPDU, whose protocol identifier is 0.
+func isGARP(data []byte) bool {
+	return len(data) >= 2 && binary.BigEndian.Uint16(data[0:2]) == GARPProtocolID
+}
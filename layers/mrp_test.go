@@ -0,0 +1,92 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketMVRP is an MVRP PDU with a LeaveAll and a single VID vector
+// attribute covering VLANs 100 to 104.
+var testPacketMVRP = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x21, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x88, 0xf5, 0x00, 0x01,
+	0x02, 0x20, 0x05, 0x00, 0x64, 0x2f, 0x18, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestPacketMVRP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketMVRP, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeMRP}, t)
+	mrp, ok := p.Layer(LayerTypeMRP).(*MRP)
+	if !ok {
+		t.Fatal("No MRP layer")
+	}
+	want := []MRPMessage{{
+		AttributeType:   MVRPAttributeTypeVID,
+		AttributeLength: 2,
+		VectorAttributes: []MRPVectorAttribute{{
+			LeaveAllEvent:  MRPLeaveAllEventLeaveAll,
+			NumberOfValues: 5,
+			FirstValue:     []byte{0x00, 0x64},
+			Events: []MRPAttributeEvent{
+				MRPAttributeEventJoinIn, MRPAttributeEventJoinIn, MRPAttributeEventLv,
+				MRPAttributeEventNew, MRPAttributeEventMt,
+			},
+		}},
+	}}
+	if !reflect.DeepEqual(want, mrp.Messages) {
+		t.Fatalf("MRP messages mismatch, \nwant %#v\ngot  %#v\n", want, mrp.Messages)
+	}
+	vlans := mrp.Messages[0].VectorAttributes[0].VLANEvents()
+	if len(vlans) != 5 || vlans[2] != (MRPVLANEvent{VLAN: 102, Event: MRPAttributeEventLv}) {
+		t.Errorf("Unexpected VLAN events %v", vlans)
+	}
+}
+
+func TestPacketMVRPTruncated(t *testing.T) {
+	p := gopacket.NewPacket(testPacketMVRP[:21], LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("Expected an error decoding a truncated vector")
+	}
+}
+
+// testPacketGVRP is a GVRP PDU carried over LLC, joining VLAN 10 and
+// signalling LeaveAll.
+var testPacketGVRP = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x21, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x0e, 0x42, 0x42,
+	0x03, 0x00, 0x01, 0x01, 0x04, 0x02, 0x00, 0x0a, 0x02, 0x00, 0x00, 0x00,
+}
+
+func TestPacketGVRP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketGVRP, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLLC, LayerTypeGVRP}, t)
+	gvrp, ok := p.Layer(LayerTypeGVRP).(*GVRP)
+	if !ok {
+		t.Fatal("No GVRP layer")
+	}
+	want := []GARPMessage{{
+		AttributeType: GVRPAttributeTypeVID,
+		Attributes: []GARPAttribute{
+			{Event: GARPAttributeEventJoinIn, Value: []byte{0x00, 0x0a}},
+			{Event: GARPAttributeEventLeaveAll, Value: []byte{}},
+		},
+	}}
+	if !reflect.DeepEqual(want, gvrp.Messages) {
+		t.Fatalf("GVRP messages mismatch, \nwant %#v\ngot  %#v\n", want, gvrp.Messages)
+	}
+	if vlan := gvrp.Messages[0].Attributes[0].VLAN(); vlan != 10 {
+		t.Errorf("Unexpected VLAN %d", vlan)
+	}
+}
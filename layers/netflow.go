@@ -0,0 +1,813 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/google/gopacket"
+)
+
+// NetFlow exporters send to whatever port the collector is configured with;
+// only Cisco's customary 2055 is mapped by default.  NetFlow v5 and v9 and
+// IPFIX can all be decoded from any of their layer types' decoders, which
+// pick by the version field, so other ports can be mapped to either:
+//
+//	layers.RegisterUDPPortLayerType(9995, layers.LayerTypeNetFlowV9)
+
+// NetFlowV5 is a NetFlow version 5 export packet.
+type NetFlowV5 struct {
+	BaseLayer
+	Version uint16
+	Count   uint16
+	// SysUptime is the exporter's uptime in milliseconds when the packet was
+	// sent, and UnixSecs and UnixNSecs the wall clock time.
+	SysUptime    uint32
+	UnixSecs     uint32
+	UnixNSecs    uint32
+	FlowSequence uint32
+	EngineType   uint8
+	EngineID     uint8
+	// SamplingMode and SamplingInterval share the last two bytes of the
+	// header, taking the top 2 and low 14 bits respectively.
+	SamplingMode     uint8
+	SamplingInterval uint16
+	Records          []NetFlowV5Record
+}
+
+// NetFlowV5Record is a flow record of a NetFlow version 5 export packet.
+type NetFlowV5Record struct {
+	SrcAddr, DstAddr, NextHop net.IP
+	// Input and Output are SNMP interface indexes.
+	Input, Output uint16
+	Packets       uint32
+	Octets        uint32
+	// First and Last are the SysUptime at the first and last packet of the
+	// flow.
+	First, Last      uint32
+	SrcPort, DstPort uint16
+	TCPFlags         uint8
+	Protocol         IPProtocol
+	TOS              uint8
+	SrcAS, DstAS     uint16
+	SrcMask, DstMask uint8
+}
+
+// LayerType returns LayerTypeNetFlowV5.
+func (n *NetFlowV5) LayerType() gopacket.LayerType { return LayerTypeNetFlowV5 }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (n *NetFlowV5) CanDecode() gopacket.LayerClass { return LayerTypeNetFlowV5 }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (n *NetFlowV5) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, as NetFlow packets carry no further layers.
+func (n *NetFlowV5) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (n *NetFlowV5) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 24 {
+		df.SetTruncated()
+		return errors.New("NetFlow v5 header too short")
+	}
+	n.Version = binary.BigEndian.Uint16(data[0:2])
+	if n.Version != 5 {
+		return fmt.Errorf("invalid NetFlow v5 version %d", n.Version)
+	}
+	n.Count = binary.BigEndian.Uint16(data[2:4])
+	n.SysUptime = binary.BigEndian.Uint32(data[4:8])
+	n.UnixSecs = binary.BigEndian.Uint32(data[8:12])
+	n.UnixNSecs = binary.BigEndian.Uint32(data[12:16])
+	n.FlowSequence = binary.BigEndian.Uint32(data[16:20])
+	n.EngineType = data[20]
+	n.EngineID = data[21]
+	n.SamplingMode = data[22] >> 6
+	n.SamplingInterval = binary.BigEndian.Uint16(data[22:24]) & 0x3fff
+
+	length := 24 + 48*int(n.Count)
+	if length > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("NetFlow v5 count %d exceeds %d bytes", n.Count, len(data))
+	}
+	n.Records = n.Records[:0]
+	for off := 24; off < length; off += 48 {
+		r := data[off : off+48]
+		n.Records = append(n.Records, NetFlowV5Record{
+			SrcAddr:  net.IP(r[0:4]),
+			DstAddr:  net.IP(r[4:8]),
+			NextHop:  net.IP(r[8:12]),
+			Input:    binary.BigEndian.Uint16(r[12:14]),
+			Output:   binary.BigEndian.Uint16(r[14:16]),
+			Packets:  binary.BigEndian.Uint32(r[16:20]),
+			Octets:   binary.BigEndian.Uint32(r[20:24]),
+			First:    binary.BigEndian.Uint32(r[24:28]),
+			Last:     binary.BigEndian.Uint32(r[28:32]),
+			SrcPort:  binary.BigEndian.Uint16(r[32:34]),
+			DstPort:  binary.BigEndian.Uint16(r[34:36]),
+			TCPFlags: r[37],
+			Protocol: IPProtocol(r[38]),
+			TOS:      r[39],
+			SrcAS:    binary.BigEndian.Uint16(r[40:42]),
+			DstAS:    binary.BigEndian.Uint16(r[42:44]),
+			SrcMask:  r[44],
+			DstMask:  r[45],
+		})
+	}
+	n.BaseLayer = BaseLayer{Contents: data[:length]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (n *NetFlowV5) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(n.Records) > 0xffff {
+		return fmt.Errorf("too many NetFlow v5 records: %d", len(n.Records))
+	}
+	bytes, err := b.PrependBytes(24 + 48*len(n.Records))
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		n.Count = uint16(len(n.Records))
+	}
+	zero(bytes)
+	binary.BigEndian.PutUint16(bytes[0:2], n.Version)
+	binary.BigEndian.PutUint16(bytes[2:4], n.Count)
+	binary.BigEndian.PutUint32(bytes[4:8], n.SysUptime)
+	binary.BigEndian.PutUint32(bytes[8:12], n.UnixSecs)
+	binary.BigEndian.PutUint32(bytes[12:16], n.UnixNSecs)
+	binary.BigEndian.PutUint32(bytes[16:20], n.FlowSequence)
+	bytes[20] = n.EngineType
+	bytes[21] = n.EngineID
+	binary.BigEndian.PutUint16(bytes[22:24], uint16(n.SamplingMode)<<14|n.SamplingInterval&0x3fff)
+	for i, rec := range n.Records {
+		r := bytes[24+48*i : 24+48*(i+1)]
+		copy(r[0:4], rec.SrcAddr.To4())
+		copy(r[4:8], rec.DstAddr.To4())
+		copy(r[8:12], rec.NextHop.To4())
+		binary.BigEndian.PutUint16(r[12:14], rec.Input)
+		binary.BigEndian.PutUint16(r[14:16], rec.Output)
+		binary.BigEndian.PutUint32(r[16:20], rec.Packets)
+		binary.BigEndian.PutUint32(r[20:24], rec.Octets)
+		binary.BigEndian.PutUint32(r[24:28], rec.First)
+		binary.BigEndian.PutUint32(r[28:32], rec.Last)
+		binary.BigEndian.PutUint16(r[32:34], rec.SrcPort)
+		binary.BigEndian.PutUint16(r[34:36], rec.DstPort)
+		r[37] = rec.TCPFlags
+		r[38] = uint8(rec.Protocol)
+		r[39] = rec.TOS
+		binary.BigEndian.PutUint16(r[40:42], rec.SrcAS)
+		binary.BigEndian.PutUint16(r[42:44], rec.DstAS)
+		r[44] = rec.SrcMask
+		r[45] = rec.DstMask
+	}
+	return nil
+}
+
+// NetFlowV9FieldType is the type of a field of a NetFlow version 9 template,
+// as listed in RFC 3954 section 8.
+type NetFlowV9FieldType uint16
+
+const (
+	NetFlowV9FieldTypeInBytes                   NetFlowV9FieldType = 1
+	NetFlowV9FieldTypeInPkts                    NetFlowV9FieldType = 2
+	NetFlowV9FieldTypeFlows                     NetFlowV9FieldType = 3
+	NetFlowV9FieldTypeProtocol                  NetFlowV9FieldType = 4
+	NetFlowV9FieldTypeSrcTOS                    NetFlowV9FieldType = 5
+	NetFlowV9FieldTypeTCPFlags                  NetFlowV9FieldType = 6
+	NetFlowV9FieldTypeL4SrcPort                 NetFlowV9FieldType = 7
+	NetFlowV9FieldTypeIPv4SrcAddr               NetFlowV9FieldType = 8
+	NetFlowV9FieldTypeSrcMask                   NetFlowV9FieldType = 9
+	NetFlowV9FieldTypeInputSNMP                 NetFlowV9FieldType = 10
+	NetFlowV9FieldTypeL4DstPort                 NetFlowV9FieldType = 11
+	NetFlowV9FieldTypeIPv4DstAddr               NetFlowV9FieldType = 12
+	NetFlowV9FieldTypeDstMask                   NetFlowV9FieldType = 13
+	NetFlowV9FieldTypeOutputSNMP                NetFlowV9FieldType = 14
+	NetFlowV9FieldTypeIPv4NextHop               NetFlowV9FieldType = 15
+	NetFlowV9FieldTypeSrcAS                     NetFlowV9FieldType = 16
+	NetFlowV9FieldTypeDstAS                     NetFlowV9FieldType = 17
+	NetFlowV9FieldTypeBGPIPv4NextHop            NetFlowV9FieldType = 18
+	NetFlowV9FieldTypeMulDstPkts                NetFlowV9FieldType = 19
+	NetFlowV9FieldTypeMulDstBytes               NetFlowV9FieldType = 20
+	NetFlowV9FieldTypeLastSwitched              NetFlowV9FieldType = 21
+	NetFlowV9FieldTypeFirstSwitched             NetFlowV9FieldType = 22
+	NetFlowV9FieldTypeOutBytes                  NetFlowV9FieldType = 23
+	NetFlowV9FieldTypeOutPkts                   NetFlowV9FieldType = 24
+	NetFlowV9FieldTypeMinPktLength              NetFlowV9FieldType = 25
+	NetFlowV9FieldTypeMaxPktLength              NetFlowV9FieldType = 26
+	NetFlowV9FieldTypeIPv6SrcAddr               NetFlowV9FieldType = 27
+	NetFlowV9FieldTypeIPv6DstAddr               NetFlowV9FieldType = 28
+	NetFlowV9FieldTypeIPv6SrcMask               NetFlowV9FieldType = 29
+	NetFlowV9FieldTypeIPv6DstMask               NetFlowV9FieldType = 30
+	NetFlowV9FieldTypeIPv6FlowLabel             NetFlowV9FieldType = 31
+	NetFlowV9FieldTypeICMPType                  NetFlowV9FieldType = 32
+	NetFlowV9FieldTypeMulIGMPType               NetFlowV9FieldType = 33
+	NetFlowV9FieldTypeSamplingInterval          NetFlowV9FieldType = 34
+	NetFlowV9FieldTypeSamplingAlgorithm         NetFlowV9FieldType = 35
+	NetFlowV9FieldTypeFlowActiveTimeout         NetFlowV9FieldType = 36
+	NetFlowV9FieldTypeFlowInactiveTimeout       NetFlowV9FieldType = 37
+	NetFlowV9FieldTypeEngineType                NetFlowV9FieldType = 38
+	NetFlowV9FieldTypeEngineID                  NetFlowV9FieldType = 39
+	NetFlowV9FieldTypeTotalBytesExp             NetFlowV9FieldType = 40
+	NetFlowV9FieldTypeTotalPktsExp              NetFlowV9FieldType = 41
+	NetFlowV9FieldTypeTotalFlowsExp             NetFlowV9FieldType = 42
+	NetFlowV9FieldTypeIPv4SrcPrefix             NetFlowV9FieldType = 44
+	NetFlowV9FieldTypeIPv4DstPrefix             NetFlowV9FieldType = 45
+	NetFlowV9FieldTypeMPLSTopLabelType          NetFlowV9FieldType = 46
+	NetFlowV9FieldTypeMPLSTopLabelIPAddr        NetFlowV9FieldType = 47
+	NetFlowV9FieldTypeFlowSamplerID             NetFlowV9FieldType = 48
+	NetFlowV9FieldTypeFlowSamplerMode           NetFlowV9FieldType = 49
+	NetFlowV9FieldTypeFlowSamplerRandomInterval NetFlowV9FieldType = 50
+	NetFlowV9FieldTypeMinTTL                    NetFlowV9FieldType = 52
+	NetFlowV9FieldTypeMaxTTL                    NetFlowV9FieldType = 53
+	NetFlowV9FieldTypeIPv4Ident                 NetFlowV9FieldType = 54
+	NetFlowV9FieldTypeDstTOS                    NetFlowV9FieldType = 55
+	NetFlowV9FieldTypeInSrcMAC                  NetFlowV9FieldType = 56
+	NetFlowV9FieldTypeOutDstMAC                 NetFlowV9FieldType = 57
+	NetFlowV9FieldTypeSrcVLAN                   NetFlowV9FieldType = 58
+	NetFlowV9FieldTypeDstVLAN                   NetFlowV9FieldType = 59
+	NetFlowV9FieldTypeIPProtocolVersion         NetFlowV9FieldType = 60
+	NetFlowV9FieldTypeDirection                 NetFlowV9FieldType = 61
+	NetFlowV9FieldTypeIPv6NextHop               NetFlowV9FieldType = 62
+	NetFlowV9FieldTypeBGPIPv6NextHop            NetFlowV9FieldType = 63
+	NetFlowV9FieldTypeIPv6OptionHeaders         NetFlowV9FieldType = 64
+	NetFlowV9FieldTypeMPLSLabel1                NetFlowV9FieldType = 70
+	NetFlowV9FieldTypeMPLSLabel2                NetFlowV9FieldType = 71
+	NetFlowV9FieldTypeMPLSLabel3                NetFlowV9FieldType = 72
+	NetFlowV9FieldTypeMPLSLabel4                NetFlowV9FieldType = 73
+	NetFlowV9FieldTypeMPLSLabel5                NetFlowV9FieldType = 74
+	NetFlowV9FieldTypeMPLSLabel6                NetFlowV9FieldType = 75
+	NetFlowV9FieldTypeMPLSLabel7                NetFlowV9FieldType = 76
+	NetFlowV9FieldTypeMPLSLabel8                NetFlowV9FieldType = 77
+	NetFlowV9FieldTypeMPLSLabel9                NetFlowV9FieldType = 78
+	NetFlowV9FieldTypeMPLSLabel10               NetFlowV9FieldType = 79
+	NetFlowV9FieldTypeInDstMAC                  NetFlowV9FieldType = 80
+	NetFlowV9FieldTypeOutSrcMAC                 NetFlowV9FieldType = 81
+	NetFlowV9FieldTypeIfName                    NetFlowV9FieldType = 82
+	NetFlowV9FieldTypeIfDesc                    NetFlowV9FieldType = 83
+	NetFlowV9FieldTypeSamplerName               NetFlowV9FieldType = 84
+	NetFlowV9FieldTypeInPermanentBytes          NetFlowV9FieldType = 85
+	NetFlowV9FieldTypeInPermanentPkts           NetFlowV9FieldType = 86
+	NetFlowV9FieldTypeFragmentOffset            NetFlowV9FieldType = 88
+	NetFlowV9FieldTypeForwardingStatus          NetFlowV9FieldType = 89
+	NetFlowV9FieldTypeMPLSPALRD                 NetFlowV9FieldType = 90
+	NetFlowV9FieldTypeMPLSPrefixLen             NetFlowV9FieldType = 91
+	NetFlowV9FieldTypeSrcTrafficIndex           NetFlowV9FieldType = 92
+	NetFlowV9FieldTypeDstTrafficIndex           NetFlowV9FieldType = 93
+	NetFlowV9FieldTypeApplicationDescription    NetFlowV9FieldType = 94
+	NetFlowV9FieldTypeApplicationTag            NetFlowV9FieldType = 95
+	NetFlowV9FieldTypeApplicationName           NetFlowV9FieldType = 96
+	NetFlowV9FieldTypePostIPDiffServCodePoint   NetFlowV9FieldType = 98
+	NetFlowV9FieldTypeReplicationFactor         NetFlowV9FieldType = 99
+	NetFlowV9FieldTypeLayer2PacketSectionOffset NetFlowV9FieldType = 102
+	NetFlowV9FieldTypeLayer2PacketSectionSize   NetFlowV9FieldType = 103
+	NetFlowV9FieldTypeLayer2PacketSectionData   NetFlowV9FieldType = 104
+)
+
+var netFlowV9FieldTypeNames = map[NetFlowV9FieldType]string{
+	NetFlowV9FieldTypeInBytes:                   "IN_BYTES",
+	NetFlowV9FieldTypeInPkts:                    "IN_PKTS",
+	NetFlowV9FieldTypeFlows:                     "FLOWS",
+	NetFlowV9FieldTypeProtocol:                  "PROTOCOL",
+	NetFlowV9FieldTypeSrcTOS:                    "SRC_TOS",
+	NetFlowV9FieldTypeTCPFlags:                  "TCP_FLAGS",
+	NetFlowV9FieldTypeL4SrcPort:                 "L4_SRC_PORT",
+	NetFlowV9FieldTypeIPv4SrcAddr:               "IPV4_SRC_ADDR",
+	NetFlowV9FieldTypeSrcMask:                   "SRC_MASK",
+	NetFlowV9FieldTypeInputSNMP:                 "INPUT_SNMP",
+	NetFlowV9FieldTypeL4DstPort:                 "L4_DST_PORT",
+	NetFlowV9FieldTypeIPv4DstAddr:               "IPV4_DST_ADDR",
+	NetFlowV9FieldTypeDstMask:                   "DST_MASK",
+	NetFlowV9FieldTypeOutputSNMP:                "OUTPUT_SNMP",
+	NetFlowV9FieldTypeIPv4NextHop:               "IPV4_NEXT_HOP",
+	NetFlowV9FieldTypeSrcAS:                     "SRC_AS",
+	NetFlowV9FieldTypeDstAS:                     "DST_AS",
+	NetFlowV9FieldTypeBGPIPv4NextHop:            "BGP_IPV4_NEXT_HOP",
+	NetFlowV9FieldTypeMulDstPkts:                "MUL_DST_PKTS",
+	NetFlowV9FieldTypeMulDstBytes:               "MUL_DST_BYTES",
+	NetFlowV9FieldTypeLastSwitched:              "LAST_SWITCHED",
+	NetFlowV9FieldTypeFirstSwitched:             "FIRST_SWITCHED",
+	NetFlowV9FieldTypeOutBytes:                  "OUT_BYTES",
+	NetFlowV9FieldTypeOutPkts:                   "OUT_PKTS",
+	NetFlowV9FieldTypeMinPktLength:              "MIN_PKT_LNGTH",
+	NetFlowV9FieldTypeMaxPktLength:              "MAX_PKT_LNGTH",
+	NetFlowV9FieldTypeIPv6SrcAddr:               "IPV6_SRC_ADDR",
+	NetFlowV9FieldTypeIPv6DstAddr:               "IPV6_DST_ADDR",
+	NetFlowV9FieldTypeIPv6SrcMask:               "IPV6_SRC_MASK",
+	NetFlowV9FieldTypeIPv6DstMask:               "IPV6_DST_MASK",
+	NetFlowV9FieldTypeIPv6FlowLabel:             "IPV6_FLOW_LABEL",
+	NetFlowV9FieldTypeICMPType:                  "ICMP_TYPE",
+	NetFlowV9FieldTypeMulIGMPType:               "MUL_IGMP_TYPE",
+	NetFlowV9FieldTypeSamplingInterval:          "SAMPLING_INTERVAL",
+	NetFlowV9FieldTypeSamplingAlgorithm:         "SAMPLING_ALGORITHM",
+	NetFlowV9FieldTypeFlowActiveTimeout:         "FLOW_ACTIVE_TIMEOUT",
+	NetFlowV9FieldTypeFlowInactiveTimeout:       "FLOW_INACTIVE_TIMEOUT",
+	NetFlowV9FieldTypeEngineType:                "ENGINE_TYPE",
+	NetFlowV9FieldTypeEngineID:                  "ENGINE_ID",
+	NetFlowV9FieldTypeTotalBytesExp:             "TOTAL_BYTES_EXP",
+	NetFlowV9FieldTypeTotalPktsExp:              "TOTAL_PKTS_EXP",
+	NetFlowV9FieldTypeTotalFlowsExp:             "TOTAL_FLOWS_EXP",
+	NetFlowV9FieldTypeIPv4SrcPrefix:             "IPV4_SRC_PREFIX",
+	NetFlowV9FieldTypeIPv4DstPrefix:             "IPV4_DST_PREFIX",
+	NetFlowV9FieldTypeMPLSTopLabelType:          "MPLS_TOP_LABEL_TYPE",
+	NetFlowV9FieldTypeMPLSTopLabelIPAddr:        "MPLS_TOP_LABEL_IP_ADDR",
+	NetFlowV9FieldTypeFlowSamplerID:             "FLOW_SAMPLER_ID",
+	NetFlowV9FieldTypeFlowSamplerMode:           "FLOW_SAMPLER_MODE",
+	NetFlowV9FieldTypeFlowSamplerRandomInterval: "FLOW_SAMPLER_RANDOM_INTERVAL",
+	NetFlowV9FieldTypeMinTTL:                    "MIN_TTL",
+	NetFlowV9FieldTypeMaxTTL:                    "MAX_TTL",
+	NetFlowV9FieldTypeIPv4Ident:                 "IPV4_IDENT",
+	NetFlowV9FieldTypeDstTOS:                    "DST_TOS",
+	NetFlowV9FieldTypeInSrcMAC:                  "IN_SRC_MAC",
+	NetFlowV9FieldTypeOutDstMAC:                 "OUT_DST_MAC",
+	NetFlowV9FieldTypeSrcVLAN:                   "SRC_VLAN",
+	NetFlowV9FieldTypeDstVLAN:                   "DST_VLAN",
+	NetFlowV9FieldTypeIPProtocolVersion:         "IP_PROTOCOL_VERSION",
+	NetFlowV9FieldTypeDirection:                 "DIRECTION",
+	NetFlowV9FieldTypeIPv6NextHop:               "IPV6_NEXT_HOP",
+	NetFlowV9FieldTypeBGPIPv6NextHop:            "BGP_IPV6_NEXT_HOP",
+	NetFlowV9FieldTypeIPv6OptionHeaders:         "IPV6_OPTION_HEADERS",
+	NetFlowV9FieldTypeMPLSLabel1:                "MPLS_LABEL_1",
+	NetFlowV9FieldTypeMPLSLabel2:                "MPLS_LABEL_2",
+	NetFlowV9FieldTypeMPLSLabel3:                "MPLS_LABEL_3",
+	NetFlowV9FieldTypeMPLSLabel4:                "MPLS_LABEL_4",
+	NetFlowV9FieldTypeMPLSLabel5:                "MPLS_LABEL_5",
+	NetFlowV9FieldTypeMPLSLabel6:                "MPLS_LABEL_6",
+	NetFlowV9FieldTypeMPLSLabel7:                "MPLS_LABEL_7",
+	NetFlowV9FieldTypeMPLSLabel8:                "MPLS_LABEL_8",
+	NetFlowV9FieldTypeMPLSLabel9:                "MPLS_LABEL_9",
+	NetFlowV9FieldTypeMPLSLabel10:               "MPLS_LABEL_10",
+	NetFlowV9FieldTypeInDstMAC:                  "IN_DST_MAC",
+	NetFlowV9FieldTypeOutSrcMAC:                 "OUT_SRC_MAC",
+	NetFlowV9FieldTypeIfName:                    "IF_NAME",
+	NetFlowV9FieldTypeIfDesc:                    "IF_DESC",
+	NetFlowV9FieldTypeSamplerName:               "SAMPLER_NAME",
+	NetFlowV9FieldTypeInPermanentBytes:          "IN_PERMANENT_BYTES",
+	NetFlowV9FieldTypeInPermanentPkts:           "IN_PERMANENT_PKTS",
+	NetFlowV9FieldTypeFragmentOffset:            "FRAGMENT_OFFSET",
+	NetFlowV9FieldTypeForwardingStatus:          "FORWARDING_STATUS",
+	NetFlowV9FieldTypeMPLSPALRD:                 "MPLS_PAL_RD",
+	NetFlowV9FieldTypeMPLSPrefixLen:             "MPLS_PREFIX_LEN",
+	NetFlowV9FieldTypeSrcTrafficIndex:           "SRC_TRAFFIC_INDEX",
+	NetFlowV9FieldTypeDstTrafficIndex:           "DST_TRAFFIC_INDEX",
+	NetFlowV9FieldTypeApplicationDescription:    "APPLICATION_DESCRIPTION",
+	NetFlowV9FieldTypeApplicationTag:            "APPLICATION_TAG",
+	NetFlowV9FieldTypeApplicationName:           "APPLICATION_NAME",
+	NetFlowV9FieldTypePostIPDiffServCodePoint:   "POST_IP_DIFF_SERV_CODE_POINT",
+	NetFlowV9FieldTypeReplicationFactor:         "REPLICATION_FACTOR",
+	NetFlowV9FieldTypeLayer2PacketSectionOffset: "LAYER2_PACKET_SECTION_OFFSET",
+	NetFlowV9FieldTypeLayer2PacketSectionSize:   "LAYER2_PACKET_SECTION_SIZE",
+	NetFlowV9FieldTypeLayer2PacketSectionData:   "LAYER2_PACKET_SECTION_DATA",
+}
+
+func (t NetFlowV9FieldType) String() string {
+	if name, ok := netFlowV9FieldTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(t))
+}
+
+// NetFlowV9ScopeType is the type of a scope field of a NetFlow version 9
+// options template.
+type NetFlowV9ScopeType uint16
+
+const (
+	NetFlowV9ScopeTypeSystem    NetFlowV9ScopeType = 1
+	NetFlowV9ScopeTypeInterface NetFlowV9ScopeType = 2
+	NetFlowV9ScopeTypeLineCard  NetFlowV9ScopeType = 3
+	NetFlowV9ScopeTypeCache     NetFlowV9ScopeType = 4
+	NetFlowV9ScopeTypeTemplate  NetFlowV9ScopeType = 5
+)
+
+func (t NetFlowV9ScopeType) String() string {
+	switch t {
+	case NetFlowV9ScopeTypeSystem:
+		return "System"
+	case NetFlowV9ScopeTypeInterface:
+		return "Interface"
+	case NetFlowV9ScopeTypeLineCard:
+		return "LineCard"
+	case NetFlowV9ScopeTypeCache:
+		return "Cache"
+	case NetFlowV9ScopeTypeTemplate:
+		return "Template"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(t))
+	}
+}
+
+// NetFlowV9Field is a field specifier of a NetFlow version 9 or IPFIX
+// template.  EnterpriseID is only set for enterprise-specific IPFIX
+// information elements, whose Type is then defined by that enterprise.
+type NetFlowV9Field struct {
+	Type         NetFlowV9FieldType
+	Length       uint16
+	EnterpriseID uint32
+}
+
+// NetFlowV9ScopeField is a scope field specifier of a NetFlow version 9
+// options template.
+type NetFlowV9ScopeField struct {
+	Type   NetFlowV9ScopeType
+	Length uint16
+}
+
+// NetFlowV9Template is a NetFlow version 9 template, describing the records
+// of the data flowsets whose ID is the template's.  Options templates have
+// scope fields, which come ahead of the other fields in records.
+//
+// IPFIX templates use the same type, so that both can share a template
+// cache.  Their options templates have no ScopeFields, as IPFIX scopes are
+// ordinary information elements; ScopeFieldCount gives the number of
+// leading Fields which are scopes instead.
+type NetFlowV9Template struct {
+	ID              uint16
+	ScopeFields     []NetFlowV9ScopeField
+	Fields          []NetFlowV9Field
+	ScopeFieldCount uint16
+}
+
+// RecordLength returns the length of the records described by the template.
+func (t *NetFlowV9Template) RecordLength() int {
+	length := 0
+	for _, f := range t.ScopeFields {
+		length += int(f.Length)
+	}
+	for _, f := range t.Fields {
+		length += int(f.Length)
+	}
+	return length
+}
+
+// NetFlowV9TemplateCache stores NetFlow version 9 and IPFIX templates
+// between export packets, since templates are sent once in a while and the
+// data flowsets of other packets are decoded against them.  Templates are
+// scoped by the source ID, or for IPFIX the observation domain ID, of the
+// packet they came in; implementations keeping templates of several
+// exporters apart should use a separate cache per exporter.
+// Implementations must be safe for concurrent use.
+type NetFlowV9TemplateCache interface {
+	// SetTemplate stores t for sourceID, replacing any template with the
+	// same ID.
+	SetTemplate(sourceID uint32, t *NetFlowV9Template)
+	// Template returns the template with the given ID for sourceID, or nil
+	// if there's none.
+	Template(sourceID uint32, id uint16) *NetFlowV9Template
+}
+
+type netFlowV9TemplateKey struct {
+	sourceID uint32
+	id       uint16
+}
+
+type netFlowV9TemplateMap struct {
+	mu        sync.RWMutex
+	templates map[netFlowV9TemplateKey]*NetFlowV9Template
+}
+
+// NewNetFlowV9TemplateCache returns an empty in-memory template cache.
+func NewNetFlowV9TemplateCache() NetFlowV9TemplateCache {
+	return &netFlowV9TemplateMap{templates: map[netFlowV9TemplateKey]*NetFlowV9Template{}}
+}
+
+func (m *netFlowV9TemplateMap) SetTemplate(sourceID uint32, t *NetFlowV9Template) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.templates[netFlowV9TemplateKey{sourceID, t.ID}] = t
+}
+
+func (m *netFlowV9TemplateMap) Template(sourceID uint32, id uint16) *NetFlowV9Template {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.templates[netFlowV9TemplateKey{sourceID, id}]
+}
+
+// NetFlowV9Templates is the template cache used by NetFlowV9 layers which
+// have none set, as is the case when decoding through gopacket.NewPacket.
+// It's shared by all exporters, so collectors hearing from several
+// exporters using the same source IDs should decode with DecodeFromBytes
+// and a cache per exporter instead.
+var NetFlowV9Templates = NewNetFlowV9TemplateCache()
+
+// NetFlowV9Value is the value of a field of a NetFlow version 9 or IPFIX
+// data record.
+type NetFlowV9Value struct {
+	Type         NetFlowV9FieldType
+	EnterpriseID uint32
+	Value        []byte
+}
+
+// Uint returns the value as an unsigned big endian integer.  Values longer
+// than 8 bytes are truncated to their last 8.
+func (v NetFlowV9Value) Uint() uint64 {
+	return netFlowUint(v.Value)
+}
+
+// NetFlowV9ScopeValue is the value of a scope field of a NetFlow version 9
+// options data record.
+type NetFlowV9ScopeValue struct {
+	Type  NetFlowV9ScopeType
+	Value []byte
+}
+
+// Uint returns the value as an unsigned big endian integer.  Values longer
+// than 8 bytes are truncated to their last 8.
+func (v NetFlowV9ScopeValue) Uint() uint64 {
+	return netFlowUint(v.Value)
+}
+
+func netFlowUint(b []byte) uint64 {
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u
+}
+
+// NetFlowV9Record is a data record of a NetFlow version 9 data flowset,
+// decoded against its template.  ScopeValues are only set for records of
+// options templates.
+type NetFlowV9Record struct {
+	ScopeValues []NetFlowV9ScopeValue
+	Values      []NetFlowV9Value
+}
+
+// Value returns the value of the first field of the given type, ignoring
+// enterprise-specific fields.
+func (r *NetFlowV9Record) Value(t NetFlowV9FieldType) (NetFlowV9Value, bool) {
+	for _, v := range r.Values {
+		if v.Type == t && v.EnterpriseID == 0 {
+			return v, true
+		}
+	}
+	return NetFlowV9Value{}, false
+}
+
+const (
+	// NetFlowV9FlowSetIDTemplate is the ID of template flowsets.
+	NetFlowV9FlowSetIDTemplate = 0
+	// NetFlowV9FlowSetIDOptionsTemplate is the ID of options template
+	// flowsets.
+	NetFlowV9FlowSetIDOptionsTemplate = 1
+)
+
+// NetFlowV9FlowSet is a flowset of a NetFlow version 9 export packet.  Body
+// holds everything after the flowset header, including padding.  Template
+// and options template flowsets have their templates decoded into
+// Templates.  Data flowsets, whose ID is that of their template, have their
+// records decoded into Records if the template is known; Template is then
+// set too.
+type NetFlowV9FlowSet struct {
+	ID        uint16
+	Length    uint16
+	Body      []byte
+	Templates []NetFlowV9Template
+	Template  *NetFlowV9Template
+	Records   []NetFlowV9Record
+}
+
+// NetFlowV9 is a NetFlow version 9 export packet, as described by RFC 3954.
+type NetFlowV9 struct {
+	BaseLayer
+	Version uint16
+	// Count is the number of template and data records in the packet.
+	Count          uint16
+	SysUptime      uint32
+	UnixSecs       uint32
+	SequenceNumber uint32
+	SourceID       uint32
+	FlowSets       []NetFlowV9FlowSet
+	// Templates is the cache templates are stored in and looked up from
+	// while decoding.  If nil, NetFlowV9Templates is used.
+	Templates NetFlowV9TemplateCache
+}
+
+// LayerType returns LayerTypeNetFlowV9.
+func (n *NetFlowV9) LayerType() gopacket.LayerType { return LayerTypeNetFlowV9 }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (n *NetFlowV9) CanDecode() gopacket.LayerClass { return LayerTypeNetFlowV9 }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (n *NetFlowV9) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, as NetFlow packets carry no further layers.
+func (n *NetFlowV9) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.  Templates found
+// are stored in the template cache before the following flowsets are
+// decoded, so data flowsets may use templates from the same packet.
+func (n *NetFlowV9) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 20 {
+		df.SetTruncated()
+		return errors.New("NetFlow v9 header too short")
+	}
+	n.Version = binary.BigEndian.Uint16(data[0:2])
+	if n.Version != 9 {
+		return fmt.Errorf("invalid NetFlow v9 version %d", n.Version)
+	}
+	n.Count = binary.BigEndian.Uint16(data[2:4])
+	n.SysUptime = binary.BigEndian.Uint32(data[4:8])
+	n.UnixSecs = binary.BigEndian.Uint32(data[8:12])
+	n.SequenceNumber = binary.BigEndian.Uint32(data[12:16])
+	n.SourceID = binary.BigEndian.Uint32(data[16:20])
+	cache := n.Templates
+	if cache == nil {
+		cache = NetFlowV9Templates
+	}
+
+	n.FlowSets = n.FlowSets[:0]
+	for rest := data[20:]; len(rest) > 0; {
+		if len(rest) < 4 {
+			df.SetTruncated()
+			return errors.New("NetFlow v9 flowset header too short")
+		}
+		fs := NetFlowV9FlowSet{
+			ID:     binary.BigEndian.Uint16(rest[0:2]),
+			Length: binary.BigEndian.Uint16(rest[2:4]),
+		}
+		if fs.Length < 4 || int(fs.Length) > len(rest) {
+			df.SetTruncated()
+			return fmt.Errorf("invalid NetFlow v9 flowset length %d", fs.Length)
+		}
+		fs.Body = rest[4:fs.Length]
+		switch {
+		case fs.ID == NetFlowV9FlowSetIDTemplate, fs.ID == NetFlowV9FlowSetIDOptionsTemplate:
+			if err := fs.decodeTemplates(); err != nil {
+				return err
+			}
+			for i := range fs.Templates {
+				cache.SetTemplate(n.SourceID, &fs.Templates[i])
+			}
+		case fs.ID >= 256:
+			if fs.Template = cache.Template(n.SourceID, fs.ID); fs.Template != nil {
+				fs.decodeRecords()
+			}
+		}
+		n.FlowSets = append(n.FlowSets, fs)
+		rest = rest[fs.Length:]
+	}
+	n.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// decodeTemplates decodes the templates of a template or options template
+// flowset, stopping at the padding.
+func (fs *NetFlowV9FlowSet) decodeTemplates() error {
+	b := fs.Body
+	if fs.ID == NetFlowV9FlowSetIDTemplate {
+		for len(b) >= 4 {
+			t := NetFlowV9Template{ID: binary.BigEndian.Uint16(b[0:2])}
+			count := int(binary.BigEndian.Uint16(b[2:4]))
+			if 4+4*count > len(b) {
+				return fmt.Errorf("NetFlow v9 template %d truncated", t.ID)
+			}
+			for i := 0; i < count; i++ {
+				f := b[4+4*i:]
+				t.Fields = append(t.Fields, NetFlowV9Field{
+					Type:   NetFlowV9FieldType(binary.BigEndian.Uint16(f[0:2])),
+					Length: binary.BigEndian.Uint16(f[2:4]),
+				})
+			}
+			fs.Templates = append(fs.Templates, t)
+			b = b[4+4*count:]
+		}
+		return nil
+	}
+	// Options templates give the lengths of their scope and option field
+	// lists in bytes.
+	for len(b) >= 6 {
+		t := NetFlowV9Template{ID: binary.BigEndian.Uint16(b[0:2])}
+		scopeLength := int(binary.BigEndian.Uint16(b[2:4]))
+		optionLength := int(binary.BigEndian.Uint16(b[4:6]))
+		if t.ID == 0 && scopeLength == 0 && optionLength == 0 {
+			break
+		}
+		if scopeLength%4 != 0 || optionLength%4 != 0 || 6+scopeLength+optionLength > len(b) {
+			return fmt.Errorf("NetFlow v9 options template %d truncated", t.ID)
+		}
+		for i := 6; i < 6+scopeLength; i += 4 {
+			t.ScopeFields = append(t.ScopeFields, NetFlowV9ScopeField{
+				Type:   NetFlowV9ScopeType(binary.BigEndian.Uint16(b[i : i+2])),
+				Length: binary.BigEndian.Uint16(b[i+2 : i+4]),
+			})
+		}
+		for i := 6 + scopeLength; i < 6+scopeLength+optionLength; i += 4 {
+			t.Fields = append(t.Fields, NetFlowV9Field{
+				Type:   NetFlowV9FieldType(binary.BigEndian.Uint16(b[i : i+2])),
+				Length: binary.BigEndian.Uint16(b[i+2 : i+4]),
+			})
+		}
+		fs.Templates = append(fs.Templates, t)
+		b = b[6+scopeLength+optionLength:]
+	}
+	return nil
+}
+
+// decodeRecords decodes the records of a data flowset against its
+// template.  Anything shorter than a record at the end is padding.
+func (fs *NetFlowV9FlowSet) decodeRecords() {
+	length := fs.Template.RecordLength()
+	if length == 0 {
+		return
+	}
+	for b := fs.Body; len(b) >= length; b = b[length:] {
+		var r NetFlowV9Record
+		off := 0
+		for _, f := range fs.Template.ScopeFields {
+			r.ScopeValues = append(r.ScopeValues, NetFlowV9ScopeValue{Type: f.Type, Value: b[off : off+int(f.Length)]})
+			off += int(f.Length)
+		}
+		for _, f := range fs.Template.Fields {
+			r.Values = append(r.Values, NetFlowV9Value{Type: f.Type, Value: b[off : off+int(f.Length)]})
+			off += int(f.Length)
+		}
+		fs.Records = append(fs.Records, r)
+	}
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// Flowsets are written from their ID and Body fields; the decoded templates
+// and records are not used.  With FixLengths set, each flowset's Length is
+// recomputed.
+func (n *NetFlowV9) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 20
+	for _, fs := range n.FlowSets {
+		if len(fs.Body) > 0xffff-4 {
+			return fmt.Errorf("NetFlow v9 flowset %d too long", fs.ID)
+		}
+		length += 4 + len(fs.Body)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], n.Version)
+	binary.BigEndian.PutUint16(bytes[2:4], n.Count)
+	binary.BigEndian.PutUint32(bytes[4:8], n.SysUptime)
+	binary.BigEndian.PutUint32(bytes[8:12], n.UnixSecs)
+	binary.BigEndian.PutUint32(bytes[12:16], n.SequenceNumber)
+	binary.BigEndian.PutUint32(bytes[16:20], n.SourceID)
+	off := 20
+	for i := range n.FlowSets {
+		fs := &n.FlowSets[i]
+		if opts.FixLengths {
+			fs.Length = uint16(4 + len(fs.Body))
+		}
+		binary.BigEndian.PutUint16(bytes[off:off+2], fs.ID)
+		binary.BigEndian.PutUint16(bytes[off+2:off+4], fs.Length)
+		copy(bytes[off+4:], fs.Body)
+		off += 4 + len(fs.Body)
+	}
+	return nil
+}
+
+// validNetFlow is the UDP port validator for the NetFlow layer types.
+func validNetFlow(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+	v := binary.BigEndian.Uint16(data[0:2])
+	return v == 5 || v == 9 || v == 10
+}
+
+// decodeNetFlow decodes a NetFlow v5 or v9 or IPFIX export packet.
+func decodeNetFlow(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) < 2 {
+		p.SetTruncated()
+		return errors.New("NetFlow packet too short")
+	}
+	var l interface {
+		gopacket.DecodingLayer
+		gopacket.ApplicationLayer
+	}
+	switch v := binary.BigEndian.Uint16(data[0:2]); v {
+	case 5:
+		l = &NetFlowV5{}
+	case 9:
+		l = &NetFlowV9{}
+	case 10:
+		l = &IPFIX{}
+	default:
+		return fmt.Errorf("unsupported NetFlow version %d", v)
+	}
+	if err := l.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(l)
+	p.SetApplicationLayer(l)
+	return nil
+}
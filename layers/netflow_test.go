@@ -0,0 +1,272 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketNetFlowV5 is a NetFlow v5 export packet with a TCP and a UDP
+// flow record, sampled 1 in 100.
+var testPacketNetFlowV5 = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x94, 0x10, 0x00, 0x40, 0x00, 0x40, 0x11,
+	0xa6, 0xa3, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8, 0x01, 0x64, 0xc3, 0x50,
+	0x08, 0x07, 0x00, 0x80, 0x23, 0x03, 0x00, 0x05, 0x00, 0x02, 0x00, 0x01,
+	0x86, 0xa0, 0x5b, 0x8d, 0x80, 0x00, 0x00, 0x00, 0x03, 0xe8, 0x00, 0x00,
+	0x04, 0xd2, 0x01, 0x02, 0x40, 0x64, 0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00,
+	0x00, 0x02, 0xc0, 0xa8, 0x01, 0x01, 0x00, 0x01, 0x00, 0x02, 0x00, 0x00,
+	0x00, 0x0a, 0x00, 0x00, 0x05, 0xdc, 0x00, 0x01, 0x80, 0xe8, 0x00, 0x01,
+	0x86, 0x9f, 0xc3, 0x50, 0x01, 0xbb, 0x00, 0x1b, 0x06, 0x00, 0xfd, 0xe8,
+	0xfd, 0xe9, 0x18, 0x10, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x03, 0x08, 0x08,
+	0x08, 0x08, 0xc0, 0xa8, 0x01, 0x01, 0x00, 0x01, 0x00, 0x03, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x48, 0x00, 0x01, 0x84, 0xd0, 0x00, 0x01,
+	0x84, 0xd0, 0xd4, 0x31, 0x00, 0x35, 0x00, 0x00, 0x11, 0x00, 0x00, 0x00,
+	0x3b, 0x41, 0x18, 0x08, 0x00, 0x00,
+}
+
+// testPacketNetFlowV9Template is a NetFlow v9 export packet with a template
+// flowset defining template 256 and an options template flowset defining
+// template 257, scoped to the system.
+var testPacketNetFlowV9Template = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x6c, 0x10, 0x00, 0x40, 0x00, 0x40, 0x11,
+	0xa6, 0xcb, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8, 0x01, 0x64, 0xc3, 0x50,
+	0x08, 0x07, 0x00, 0x58, 0x4a, 0xe9, 0x00, 0x09, 0x00, 0x02, 0x00, 0x01,
+	0x86, 0xa0, 0x5b, 0x8d, 0x80, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00,
+	0x00, 0x2a, 0x00, 0x00, 0x00, 0x24, 0x01, 0x00, 0x00, 0x07, 0x00, 0x08,
+	0x00, 0x04, 0x00, 0x0c, 0x00, 0x04, 0x00, 0x07, 0x00, 0x02, 0x00, 0x0b,
+	0x00, 0x02, 0x00, 0x04, 0x00, 0x01, 0x00, 0x01, 0x00, 0x04, 0x00, 0x02,
+	0x00, 0x04, 0x00, 0x01, 0x00, 0x18, 0x01, 0x01, 0x00, 0x04, 0x00, 0x08,
+	0x00, 0x01, 0x00, 0x04, 0x00, 0x22, 0x00, 0x04, 0x00, 0x23, 0x00, 0x01,
+	0x00, 0x00,
+}
+
+// testPacketNetFlowV9Data is the following packet from the same exporter,
+// with two records of template 256, one of template 257 and a flowset of
+// the unknown template 300.
+var testPacketNetFlowV9Data = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x78, 0x10, 0x00, 0x40, 0x00, 0x40, 0x11,
+	0xa6, 0xbf, 0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8, 0x01, 0x64, 0xc3, 0x50,
+	0x08, 0x07, 0x00, 0x64, 0xa9, 0x29, 0x00, 0x09, 0x00, 0x03, 0x00, 0x01,
+	0x86, 0xa5, 0x5b, 0x8d, 0x80, 0x05, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00,
+	0x00, 0x2a, 0x01, 0x00, 0x00, 0x30, 0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00,
+	0x00, 0x02, 0xc3, 0x50, 0x01, 0xbb, 0x06, 0x00, 0x00, 0x05, 0xdc, 0x00,
+	0x00, 0x00, 0x0a, 0x0a, 0x00, 0x00, 0x03, 0x08, 0x08, 0x08, 0x08, 0xd4,
+	0x31, 0x00, 0x35, 0x11, 0x00, 0x00, 0x00, 0x48, 0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x01, 0x01, 0x00, 0x10, 0xc0, 0xa8, 0x01, 0x01, 0x00, 0x00,
+	0x00, 0x64, 0x02, 0x00, 0x00, 0x00, 0x01, 0x2c, 0x00, 0x08, 0xde, 0xad,
+	0xbe, 0xef,
+}
+
+func TestPacketNetFlowV5(t *testing.T) {
+	p := gopacket.NewPacket(testPacketNetFlowV5, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeNetFlowV5}, t)
+	n := p.Layer(LayerTypeNetFlowV5).(*NetFlowV5)
+	if n.Version != 5 || n.Count != 2 || n.SysUptime != 100000 || n.UnixSecs != 0x5b8d8000 || n.UnixNSecs != 1000 || n.FlowSequence != 1234 {
+		t.Errorf("bad header %+v", n)
+	}
+	if n.EngineType != 1 || n.EngineID != 2 || n.SamplingMode != 1 || n.SamplingInterval != 100 {
+		t.Errorf("bad engine and sampling %d %d %d %d", n.EngineType, n.EngineID, n.SamplingMode, n.SamplingInterval)
+	}
+	want := []NetFlowV5Record{
+		{
+			SrcAddr: net.IP{10, 0, 0, 1}, DstAddr: net.IP{10, 0, 0, 2}, NextHop: net.IP{192, 168, 1, 1},
+			Input: 1, Output: 2, Packets: 10, Octets: 1500, First: 98536, Last: 99999,
+			SrcPort: 50000, DstPort: 443, TCPFlags: 0x1b, Protocol: IPProtocolTCP,
+			SrcAS: 65000, DstAS: 65001, SrcMask: 24, DstMask: 16,
+		},
+		{
+			SrcAddr: net.IP{10, 0, 0, 3}, DstAddr: net.IP{8, 8, 8, 8}, NextHop: net.IP{192, 168, 1, 1},
+			Input: 1, Output: 3, Packets: 1, Octets: 72, First: 99536, Last: 99536,
+			SrcPort: 54321, DstPort: 53, Protocol: IPProtocolUDP,
+			DstAS: 15169, SrcMask: 24, DstMask: 8,
+		},
+	}
+	if !reflect.DeepEqual(n.Records, want) {
+		t.Errorf("records mismatch:\ngot  %+v\nwant %+v", n.Records, want)
+	}
+	testSerialization(t, p, testPacketNetFlowV5)
+}
+
+func TestPacketNetFlowV9(t *testing.T) {
+	cache := NewNetFlowV9TemplateCache()
+	var n NetFlowV9
+	n.Templates = cache
+	if err := n.DecodeFromBytes(testPacketNetFlowV9Data[42:], gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.FlowSets) != 3 || n.FlowSets[0].Template != nil || n.FlowSets[0].Records != nil {
+		t.Fatalf("data decoded without templates: %+v", n.FlowSets)
+	}
+
+	if err := n.DecodeFromBytes(testPacketNetFlowV9Template[42:], gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if n.Version != 9 || n.Count != 2 || n.SysUptime != 100000 || n.SequenceNumber != 1 || n.SourceID != 42 {
+		t.Errorf("bad header %+v", n)
+	}
+	if len(n.FlowSets) != 2 {
+		t.Fatalf("got %d flowsets, want 2", len(n.FlowSets))
+	}
+	want := []NetFlowV9Template{{
+		ID: 256,
+		Fields: []NetFlowV9Field{
+			{Type: NetFlowV9FieldTypeIPv4SrcAddr, Length: 4},
+			{Type: NetFlowV9FieldTypeIPv4DstAddr, Length: 4},
+			{Type: NetFlowV9FieldTypeL4SrcPort, Length: 2},
+			{Type: NetFlowV9FieldTypeL4DstPort, Length: 2},
+			{Type: NetFlowV9FieldTypeProtocol, Length: 1},
+			{Type: NetFlowV9FieldTypeInBytes, Length: 4},
+			{Type: NetFlowV9FieldTypeInPkts, Length: 4},
+		},
+	}}
+	if !reflect.DeepEqual(n.FlowSets[0].Templates, want) {
+		t.Errorf("template mismatch:\ngot  %+v\nwant %+v", n.FlowSets[0].Templates, want)
+	}
+	wantOptions := []NetFlowV9Template{{
+		ID:          257,
+		ScopeFields: []NetFlowV9ScopeField{{Type: NetFlowV9ScopeTypeSystem, Length: 4}},
+		Fields: []NetFlowV9Field{
+			{Type: NetFlowV9FieldTypeSamplingInterval, Length: 4},
+			{Type: NetFlowV9FieldTypeSamplingAlgorithm, Length: 1},
+		},
+	}}
+	if !reflect.DeepEqual(n.FlowSets[1].Templates, wantOptions) {
+		t.Errorf("options template mismatch:\ngot  %+v\nwant %+v", n.FlowSets[1].Templates, wantOptions)
+	}
+	if cache.Template(42, 256) == nil || cache.Template(42, 257) == nil {
+		t.Error("templates not cached")
+	}
+	if cache.Template(43, 256) != nil {
+		t.Error("template cached for the wrong source ID")
+	}
+
+	if err := n.DecodeFromBytes(testPacketNetFlowV9Data[42:], gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.FlowSets) != 3 {
+		t.Fatalf("got %d flowsets, want 3", len(n.FlowSets))
+	}
+	data := n.FlowSets[0]
+	if data.ID != 256 || data.Template == nil || len(data.Records) != 2 {
+		t.Fatalf("bad data flowset %+v", data)
+	}
+	for i, w := range []struct {
+		src, dst     net.IP
+		proto, bytes uint64
+	}{
+		{net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}, 6, 1500},
+		{net.IP{10, 0, 0, 3}, net.IP{8, 8, 8, 8}, 17, 72},
+	} {
+		r := data.Records[i]
+		if v, _ := r.Value(NetFlowV9FieldTypeIPv4SrcAddr); !net.IP(v.Value).Equal(w.src) {
+			t.Errorf("record %d: source %v, want %v", i, net.IP(v.Value), w.src)
+		}
+		if v, _ := r.Value(NetFlowV9FieldTypeIPv4DstAddr); !net.IP(v.Value).Equal(w.dst) {
+			t.Errorf("record %d: destination %v, want %v", i, net.IP(v.Value), w.dst)
+		}
+		if v, _ := r.Value(NetFlowV9FieldTypeProtocol); v.Uint() != w.proto {
+			t.Errorf("record %d: protocol %d, want %d", i, v.Uint(), w.proto)
+		}
+		if v, _ := r.Value(NetFlowV9FieldTypeInBytes); v.Uint() != w.bytes {
+			t.Errorf("record %d: bytes %d, want %d", i, v.Uint(), w.bytes)
+		}
+	}
+	if _, ok := data.Records[0].Value(NetFlowV9FieldTypeSrcAS); ok {
+		t.Error("found a field not in the template")
+	}
+
+	options := n.FlowSets[1]
+	if len(options.Records) != 1 {
+		t.Fatalf("got %d options records, want 1", len(options.Records))
+	}
+	r := options.Records[0]
+	if len(r.ScopeValues) != 1 || r.ScopeValues[0].Type != NetFlowV9ScopeTypeSystem || !net.IP(r.ScopeValues[0].Value).Equal(net.IP{192, 168, 1, 1}) {
+		t.Errorf("bad scope values %+v", r.ScopeValues)
+	}
+	if v, _ := r.Value(NetFlowV9FieldTypeSamplingInterval); v.Uint() != 100 {
+		t.Errorf("sampling interval %d, want 100", v.Uint())
+	}
+	if v, _ := r.Value(NetFlowV9FieldTypeSamplingAlgorithm); v.Uint() != 2 {
+		t.Errorf("sampling algorithm %d, want 2", v.Uint())
+	}
+
+	unknown := n.FlowSets[2]
+	if unknown.ID != 300 || unknown.Template != nil || unknown.Records != nil || len(unknown.Body) != 4 {
+		t.Errorf("bad unknown flowset %+v", unknown)
+	}
+}
+
+func TestPacketNetFlowV9Default(t *testing.T) {
+	defer func(c NetFlowV9TemplateCache) { NetFlowV9Templates = c }(NetFlowV9Templates)
+	NetFlowV9Templates = NewNetFlowV9TemplateCache()
+
+	p := gopacket.NewPacket(testPacketNetFlowV9Template, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeNetFlowV9}, t)
+	testSerialization(t, p, testPacketNetFlowV9Template)
+
+	p = gopacket.NewPacket(testPacketNetFlowV9Data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	n := p.Layer(LayerTypeNetFlowV9).(*NetFlowV9)
+	if len(n.FlowSets) != 3 || len(n.FlowSets[0].Records) != 2 || len(n.FlowSets[1].Records) != 1 {
+		t.Errorf("data not decoded against the default cache: %+v", n.FlowSets)
+	}
+	testSerialization(t, p, testPacketNetFlowV9Data)
+}
+
+func TestNetFlowV9FieldTypeString(t *testing.T) {
+	for ft, want := range map[NetFlowV9FieldType]string{
+		NetFlowV9FieldTypeInBytes:     "IN_BYTES",
+		NetFlowV9FieldTypeIPv6SrcAddr: "IPV6_SRC_ADDR",
+		NetFlowV9FieldTypeMPLSLabel10: "MPLS_LABEL_10",
+		43:                            "Unknown(43)",
+	} {
+		if got := ft.String(); got != want {
+			t.Errorf("%d: got %q, want %q", uint16(ft), got, want)
+		}
+	}
+}
+
+func TestNetFlowMalformed(t *testing.T) {
+	v9 := testPacketNetFlowV9Template[42:]
+	header := func(b ...byte) []byte { return append(append([]byte{}, v9[:20]...), b...) }
+	for _, c := range []struct {
+		name string
+		l    gopacket.DecodingLayer
+		data []byte
+	}{
+		{"v5 short header", &NetFlowV5{}, testPacketNetFlowV5[42:60]},
+		{"v5 wrong version", &NetFlowV5{}, v9},
+		{"v5 count too large", &NetFlowV5{}, testPacketNetFlowV5[42 : len(testPacketNetFlowV5)-1]},
+		{"v9 short header", &NetFlowV9{}, v9[:19]},
+		{"v9 short flowset header", &NetFlowV9{}, v9[:22]},
+		{"v9 flowset too long", &NetFlowV9{}, v9[:len(v9)-1]},
+		{"v9 zero flowset length", &NetFlowV9{}, header(0, 0, 0, 0)},
+		{"v9 template truncated", &NetFlowV9{}, header(0, 0, 0, 12, 1, 0, 0, 7, 0, 8, 0, 4)},
+	} {
+		if n, ok := c.l.(*NetFlowV9); ok {
+			n.Templates = NewNetFlowV9TemplateCache()
+		}
+		if err := c.l.DecodeFromBytes(c.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// NSH (Network Service Header) carries service function chaining metadata
+// ahead of the original packet, as described in
+// https://tools.ietf.org/html/rfc8300.  It is sent directly over Ethernet
+// with EtherType 0x894F or inside VXLAN-GPE and GRE tunnels.
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |Ver|O|C|    TTL    |   Length  |U|U|U|U|MD Type| Next Protocol |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |          Service Path Identifier (SPI)        | Service Index |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                                                               |
+// ~                Context Header(s)                              ~
+// |                                                               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// MD type 1 carries four fixed 32 bit context headers.  MD type 2 carries
+// zero or more variable length context headers:
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |          Metadata Class       |      Type     |U|    Length   |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                   Variable-Length Metadata                    |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+const (
+	nshBaseLength = 8
+	nshMD1Length  = nshBaseLength + 16
+)
+
+// NSHMDType is the metadata type of an NSH header, which decides the format
+// of the context headers.
+type NSHMDType uint8
+
+const (
+	NSHMDType1 NSHMDType = 1
+	NSHMDType2 NSHMDType = 2
+)
+
+// NSHNextProtocol is the type of the packet following an NSH header.
+type NSHNextProtocol uint8
+
+const (
+	NSHNextProtocolIPv4     NSHNextProtocol = 1
+	NSHNextProtocolIPv6     NSHNextProtocol = 2
+	NSHNextProtocolEthernet NSHNextProtocol = 3
+	NSHNextProtocolNSH      NSHNextProtocol = 4
+	NSHNextProtocolMPLS     NSHNextProtocol = 5
+)
+
+func (n NSHNextProtocol) String() string {
+	switch n {
+	case NSHNextProtocolIPv4:
+		return "IPv4"
+	case NSHNextProtocolIPv6:
+		return "IPv6"
+	case NSHNextProtocolEthernet:
+		return "Ethernet"
+	case NSHNextProtocolNSH:
+		return "NSH"
+	case NSHNextProtocolMPLS:
+		return "MPLS"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(n))
+	}
+}
+
+// LayerType returns the layer type used to decode packets of this protocol,
+// or gopacket.LayerTypePayload if it is unknown.
+func (n NSHNextProtocol) LayerType() gopacket.LayerType {
+	switch n {
+	case NSHNextProtocolIPv4:
+		return LayerTypeIPv4
+	case NSHNextProtocolIPv6:
+		return LayerTypeIPv6
+	case NSHNextProtocolEthernet:
+		return LayerTypeEthernet
+	case NSHNextProtocolNSH:
+		return LayerTypeNSH
+	case NSHNextProtocolMPLS:
+		return LayerTypeMPLS
+	default:
+		return gopacket.LayerTypePayload
+	}
+}
+
+// NSHContextHeader is a variable length context header of an MD type 2 NSH
+// header.  Value is padded to a multiple of four bytes on the wire.
+type NSHContextHeader struct {
+	Class uint16
+	Type  uint8
+	Value []byte
+}
+
+func (c NSHContextHeader) serializedLength() int {
+	return 4 + (len(c.Value)+3)&^3
+}
+
+// NSH is a Network Service Header.
+type NSH struct {
+	BaseLayer
+	Version uint8
+	// OAM is set if the packet is an operations, administration and
+	// maintenance packet.
+	OAM bool
+	// Critical is the C bit of earlier drafts, set if critical metadata
+	// is present.  RFC 8300 leaves it unassigned.
+	Critical bool
+	TTL      uint8
+	// Length is the length of the header including the context headers,
+	// in four byte words.
+	Length       uint8
+	MDType       NSHMDType
+	NextProtocol NSHNextProtocol
+	// ServicePathID is the 24 bit service path identifier.
+	ServicePathID uint32
+	ServiceIndex  uint8
+	// Context holds the fixed context headers of an MD type 1 header.
+	Context [4]uint32
+	// ContextHeaders holds the variable context headers of an MD type 2
+	// header.
+	ContextHeaders []NSHContextHeader
+}
+
+// LayerType returns LayerTypeNSH.
+func (n *NSH) LayerType() gopacket.LayerType { return LayerTypeNSH }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (n *NSH) CanDecode() gopacket.LayerClass { return LayerTypeNSH }
+
+// NextLayerType returns the layer type given by NextProtocol.
+func (n *NSH) NextLayerType() gopacket.LayerType { return n.NextProtocol.LayerType() }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (n *NSH) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < nshBaseLength {
+		df.SetTruncated()
+		return errors.New("NSH header too short")
+	}
+	n.Version = data[0] >> 6
+	n.OAM = data[0]&0x20 != 0
+	n.Critical = data[0]&0x10 != 0
+	n.TTL = (data[0]&0x0f)<<2 | data[1]>>6
+	n.Length = data[1] & 0x3f
+	n.MDType = NSHMDType(data[2] & 0x0f)
+	n.NextProtocol = NSHNextProtocol(data[3])
+	n.ServicePathID = binary.BigEndian.Uint32(data[4:8]) >> 8
+	n.ServiceIndex = data[7]
+	n.Context = [4]uint32{}
+	n.ContextHeaders = n.ContextHeaders[:0]
+
+	length := int(n.Length) * 4
+	if length < nshBaseLength {
+		return fmt.Errorf("NSH length %d too short", n.Length)
+	}
+	if len(data) < length {
+		df.SetTruncated()
+		return fmt.Errorf("NSH length %d too long", n.Length)
+	}
+	switch n.MDType {
+	case NSHMDType1:
+		if length != nshMD1Length {
+			return fmt.Errorf("NSH MD type 1 header with length %d", n.Length)
+		}
+		for i := range n.Context {
+			n.Context[i] = binary.BigEndian.Uint32(data[8+i*4:])
+		}
+	case NSHMDType2:
+		for ctx := data[nshBaseLength:length]; len(ctx) > 0; {
+			if len(ctx) < 4 {
+				return errors.New("NSH context header too short")
+			}
+			vlen := int(ctx[3] & 0x7f)
+			if 4+(vlen+3)&^3 > len(ctx) {
+				return fmt.Errorf("NSH context header length %d too long", vlen)
+			}
+			c := NSHContextHeader{
+				Class: binary.BigEndian.Uint16(ctx[0:2]),
+				Type:  ctx[2],
+				Value: ctx[4 : 4+vlen],
+			}
+			n.ContextHeaders = append(n.ContextHeaders, c)
+			ctx = ctx[c.serializedLength():]
+		}
+	}
+	n.BaseLayer = BaseLayer{data[:length], data[length:]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (n *NSH) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := nshBaseLength
+	switch n.MDType {
+	case NSHMDType1:
+		length = nshMD1Length
+	case NSHMDType2:
+		for _, c := range n.ContextHeaders {
+			if len(c.Value) > 0x7f {
+				return fmt.Errorf("NSH context header value of %d bytes too long", len(c.Value))
+			}
+			length += c.serializedLength()
+		}
+	}
+	if length > 0x3f*4 {
+		return fmt.Errorf("NSH header of %d bytes too long", length)
+	}
+	if opts.FixLengths {
+		n.Length = uint8(length / 4)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = n.Version<<6 | n.TTL>>2&0x0f
+	if n.OAM {
+		bytes[0] |= 0x20
+	}
+	if n.Critical {
+		bytes[0] |= 0x10
+	}
+	bytes[1] = n.TTL<<6 | n.Length&0x3f
+	bytes[2] = uint8(n.MDType) & 0x0f
+	bytes[3] = uint8(n.NextProtocol)
+	binary.BigEndian.PutUint32(bytes[4:8], n.ServicePathID<<8|uint32(n.ServiceIndex))
+	switch n.MDType {
+	case NSHMDType1:
+		for i, c := range n.Context {
+			binary.BigEndian.PutUint32(bytes[8+i*4:], c)
+		}
+	case NSHMDType2:
+		ctx := bytes[nshBaseLength:]
+		for _, c := range n.ContextHeaders {
+			binary.BigEndian.PutUint16(ctx[0:2], c.Class)
+			ctx[2] = c.Type
+			ctx[3] = uint8(len(c.Value))
+			zero(ctx[4:c.serializedLength()])
+			copy(ctx[4:], c.Value)
+			ctx = ctx[c.serializedLength():]
+		}
+	}
+	return nil
+}
+
+func decodeNSH(data []byte, p gopacket.PacketBuilder) error {
+	n := &NSH{}
+	return decodingLayerDecoder(n, data, p)
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketNSHMD1 is an ICMP echo request in an Ethernet frame pushed onto
+// service path 64 the way Open vSwitch's push_nsh action builds it, with MD
+// type 1 and the first context header set.
+var testPacketNSHMD1 = []byte{
+	0x00, 0x00, 0x5e, 0x00, 0x01, 0x01, 0x00, 0x50, 0x56, 0x8a, 0x0b, 0x0c,
+	0x89, 0x4f, 0x0f, 0xc6, 0x01, 0x03, 0x00, 0x00, 0x40, 0xff, 0x11, 0x22,
+	0x33, 0x44, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x0c, 0x29, 0xaa, 0xbb, 0xcc, 0x00, 0x0c, 0x29, 0xdd,
+	0xee, 0xff, 0x08, 0x00, 0x45, 0x00, 0x00, 0x20, 0x00, 0x01, 0x40, 0x00,
+	0x40, 0x01, 0xa5, 0x88, 0xc0, 0xa8, 0x0a, 0x01, 0xc0, 0xa8, 0x0a, 0x02,
+	0x08, 0x00, 0x06, 0xfa, 0x12, 0x34, 0x00, 0x01, 0x70, 0x69, 0x6e, 0x67,
+}
+
+// testPacketNSHMD2 is the same echo request without its Ethernet header on
+// service path 64, index 254, with MD type 2 and a single context header.
+var testPacketNSHMD2 = []byte{
+	0x00, 0x00, 0x5e, 0x00, 0x01, 0x01, 0x00, 0x50, 0x56, 0x8a, 0x0b, 0x0c,
+	0x89, 0x4f, 0x0f, 0xc4, 0x02, 0x01, 0x00, 0x00, 0x40, 0xfe, 0x00, 0x00,
+	0x01, 0x03, 0x0a, 0x0b, 0x0c, 0x00, 0x45, 0x00, 0x00, 0x20, 0x00, 0x01,
+	0x40, 0x00, 0x40, 0x01, 0xa5, 0x88, 0xc0, 0xa8, 0x0a, 0x01, 0xc0, 0xa8,
+	0x0a, 0x02, 0x08, 0x00, 0x06, 0xfa, 0x12, 0x34, 0x00, 0x01, 0x70, 0x69,
+	0x6e, 0x67,
+}
+
+func TestPacketNSHMD1(t *testing.T) {
+	p := gopacket.NewPacket(testPacketNSHMD1, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeNSH, LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+	nsh := p.Layer(LayerTypeNSH).(*NSH)
+	want := &NSH{
+		BaseLayer:     BaseLayer{testPacketNSHMD1[14:38], testPacketNSHMD1[38:]},
+		TTL:           63,
+		Length:        6,
+		MDType:        NSHMDType1,
+		NextProtocol:  NSHNextProtocolEthernet,
+		ServicePathID: 64,
+		ServiceIndex:  255,
+		Context:       [4]uint32{0x11223344, 0, 0, 0},
+	}
+	if !reflect.DeepEqual(want, nsh) {
+		t.Errorf("NSH mismatch\nwant %#v\ngot  %#v", want, nsh)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	nsh.Length = 0
+	if err := nsh.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketNSHMD1[14:38]) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", testPacketNSHMD1[14:38], buf.Bytes())
+	}
+}
+
+func TestPacketNSHMD2(t *testing.T) {
+	p := gopacket.NewPacket(testPacketNSHMD2, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeNSH, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+	nsh := p.Layer(LayerTypeNSH).(*NSH)
+	want := []NSHContextHeader{{Class: 0, Type: 1, Value: []byte{0x0a, 0x0b, 0x0c}}}
+	if nsh.MDType != NSHMDType2 || nsh.Length != 4 || nsh.ServiceIndex != 254 || !reflect.DeepEqual(nsh.ContextHeaders, want) {
+		t.Errorf("NSH mismatch: %+v", nsh)
+	}
+
+	// Rebuild the packet from scratch.
+	nsh = &NSH{
+		TTL:            63,
+		MDType:         NSHMDType2,
+		NextProtocol:   NSHNextProtocolIPv4,
+		ServicePathID:  64,
+		ServiceIndex:   254,
+		ContextHeaders: want,
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, nsh, gopacket.Payload(testPacketNSHMD2[30:])); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketNSHMD2[14:]) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", testPacketNSHMD2[14:], buf.Bytes())
+	}
+}
+
+func TestNSHMalformed(t *testing.T) {
+	n := &NSH{}
+	hdr := testPacketNSHMD2[14:30]
+	if err := n.DecodeFromBytes(hdr[:6], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated header")
+	}
+	if err := n.DecodeFromBytes(hdr[:12], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated context headers")
+	}
+	data := append([]byte(nil), hdr...)
+	data[11] = 8
+	if err := n.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding overlong context header")
+	}
+	data = append([]byte(nil), testPacketNSHMD1[14:38]...)
+	data[1] = 0xc5
+	if err := n.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding short MD type 1 header")
+	}
+}
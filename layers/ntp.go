@@ -11,6 +11,7 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"time"
 
 	"github.com/google/gopacket"
 )
@@ -230,6 +231,97 @@ type NTPFixed16Seconds uint32
 type NTPReferenceID uint32
 type NTPTimestamp uint64
 
+// NTP modes, from RFC 5905 section 7.3.  Control (mode 6) messages are
+// described in RFC 1305 appendix B; private (mode 7) messages are specific to
+// the reference implementation, and include the monlist responses abused for
+// traffic amplification.
+const (
+	NTPModeReserved         NTPMode = 0
+	NTPModeSymmetricActive  NTPMode = 1
+	NTPModeSymmetricPassive NTPMode = 2
+	NTPModeClient           NTPMode = 3
+	NTPModeServer           NTPMode = 4
+	NTPModeBroadcast        NTPMode = 5
+	NTPModeControl          NTPMode = 6
+	NTPModePrivate          NTPMode = 7
+)
+
+func (m NTPMode) String() string {
+	switch m {
+	case NTPModeReserved:
+		return "Reserved"
+	case NTPModeSymmetricActive:
+		return "SymmetricActive"
+	case NTPModeSymmetricPassive:
+		return "SymmetricPassive"
+	case NTPModeClient:
+		return "Client"
+	case NTPModeServer:
+		return "Server"
+	case NTPModeBroadcast:
+		return "Broadcast"
+	case NTPModeControl:
+		return "Control"
+	case NTPModePrivate:
+		return "Private"
+	default:
+		return "Unknown"
+	}
+}
+
+// ntpEpochOffset is the number of seconds between the NTP era 0 epoch,
+// 1900-01-01, and the Unix epoch.
+const ntpEpochOffset = 2208988800
+
+// Time converts an NTP timestamp to a time.Time.  NTP timestamps carry only
+// 32 bits of seconds, wrapping every 136 years, so the era is inferred: as
+// recommended by RFC 4330 section 3, seconds with the top bit clear are taken
+// to be in era 1, starting 2036-02-07.  This gives correct results for times
+// between 1968 and 2104.  Use TimeNear for other ranges.  The zero timestamp
+// means "unknown" and converts to the zero time.Time.
+func (t NTPTimestamp) Time() time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	secs := int64(t >> 32)
+	if secs&0x80000000 == 0 {
+		secs += 1 << 32
+	}
+	return t.timeInEra(secs)
+}
+
+// TimeNear converts an NTP timestamp to the time.Time closest to ref, picking
+// whichever era puts it within 68 years of ref.
+func (t NTPTimestamp) TimeNear(ref time.Time) time.Time {
+	refSecs := ref.Unix() + ntpEpochOffset
+	secs := int64(t >> 32)
+	// Choose the era so that secs is within half an era of refSecs.
+	secs += (refSecs - secs + 1<<31) &^ (1<<32 - 1)
+	return t.timeInEra(secs)
+}
+
+// timeInEra converts t using secs, the seconds since the era 0 epoch.
+func (t NTPTimestamp) timeInEra(secs int64) time.Time {
+	nsecs := (int64(t&0xffffffff)*1e9 + 1<<31) >> 32
+	return time.Unix(secs-ntpEpochOffset, nsecs).UTC()
+}
+
+// NewNTPTimestamp converts a time.Time to an NTP timestamp.  Times before
+// 1900 or after 2172 can't be distinguished once converted, since only the
+// seconds within an era are kept.
+func NewNTPTimestamp(t time.Time) NTPTimestamp {
+	secs := uint64(t.Unix()+ntpEpochOffset) & 0xffffffff
+	frac := (uint64(t.Nanosecond())<<32 + 5e8) / 1e9
+	return NTPTimestamp(secs<<32 | frac)
+}
+
+// NTPExtensionField is an NTPv4 extension field (RFC 7822).  Value holds the
+// field's contents after its 4 byte header, including any padding.
+type NTPExtensionField struct {
+	Type  uint16
+	Value []byte
+}
+
 type NTP struct {
 	BaseLayer // Stores the packet bytes and payload bytes.
 
@@ -247,8 +339,14 @@ type NTP struct {
 	ReceiveTimestamp   NTPTimestamp      // Local time (on server) that request arrived at server host.
 	TransmitTimestamp  NTPTimestamp      // Local time (on server) that request departed server host.
 
-	// FIX: This package should analyse the extension fields and represent the extension fields too.
-	ExtensionBytes []byte // Just put extensions in a byte slice.
+	// ExtensionBytes holds everything following the 48 byte header: any
+	// extension fields and the MAC.  When it parses cleanly it's also split
+	// into Extensions, KeyID and MAC.  When serializing, ExtensionBytes is
+	// written if it is set, and Extensions, KeyID and MAC otherwise.
+	ExtensionBytes []byte
+	Extensions     []NTPExtensionField
+	KeyID          uint32
+	MAC            []byte // Message digest, without the key identifier.
 }
 
 //******************************************************************************
@@ -294,6 +392,24 @@ func decodeNTP(data []byte, p gopacket.PacketBuilder) error {
 // Upon failure, it returns an error (non nil).
 func (d *NTP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 
+	if len(data) < 1 {
+		df.SetTruncated()
+		return errors.New("NTP packet too short")
+	}
+
+	// Control and private mode messages have a different layout; only
+	// identify them by mode and keep the rest of the message as payload.
+	f := data[0]
+	if mode := NTPMode(f & 0x07); mode == NTPModeControl || mode == NTPModePrivate {
+		*d = NTP{
+			BaseLayer:     BaseLayer{Contents: data[:1], Payload: data[1:]},
+			LeapIndicator: NTPLeapIndicator((f & 0xC0) >> 6),
+			Version:       NTPVersion((f & 0x38) >> 3),
+			Mode:          mode,
+		}
+		return nil
+	}
+
 	// If the data block is too short to be a NTP record, then return an error.
 	if len(data) < ntpMinimumRecordSizeInBytes {
 		df.SetTruncated()
@@ -321,7 +437,6 @@ func (d *NTP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	// above and the section on endian conventions.
 
 	// The first few fields are all packed into the first 32 bits. Unpack them.
-	f := data[0]
 	d.LeapIndicator = NTPLeapIndicator((f & 0xC0) >> 6)
 	d.Version = NTPVersion((f & 0x38) >> 3)
 	d.Mode = NTPMode(f & 0x07)
@@ -338,19 +453,65 @@ func (d *NTP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	d.ReceiveTimestamp = NTPTimestamp(binary.BigEndian.Uint64(data[32:40]))
 	d.TransmitTimestamp = NTPTimestamp(binary.BigEndian.Uint64(data[40:48]))
 
-	// This layer does not attempt to analyse the extension bytes.
-	// But if there are any, we'd like the user to know. So we just
-	// place them all in an ExtensionBytes field.
+	// Keep the extension bytes as they are, and also split them into
+	// extension fields and MAC if they can be.
 	d.ExtensionBytes = data[48:]
+	d.Extensions, d.KeyID, d.MAC = nil, 0, nil
+	d.decodeExtensions(d.ExtensionBytes)
 
 	// Return no error.
 	return nil
 }
 
+// ntpMaxMACSize is the size of the largest MAC: a key identifier and a 20
+// byte SHA-1 digest.
+const ntpMaxMACSize = 24
+
+// decodeExtensions splits the bytes following the header into extension
+// fields and a MAC, following RFC 7822 section 7.5: anything longer than the
+// largest MAC must start with an extension field.  If the bytes don't parse,
+// they're left only in ExtensionBytes.
+func (d *NTP) decodeExtensions(data []byte) {
+	var exts []NTPExtensionField
+	for len(data) > ntpMaxMACSize {
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 16 || length%4 != 0 || length > len(data) {
+			return
+		}
+		exts = append(exts, NTPExtensionField{
+			Type:  binary.BigEndian.Uint16(data[0:2]),
+			Value: data[4:length],
+		})
+		data = data[length:]
+	}
+	switch len(data) {
+	case 0:
+	case 4, 20, 24: // crypto-NAK, MD5 or SHA-1
+		d.KeyID = binary.BigEndian.Uint32(data[0:4])
+		d.MAC = data[4:]
+	default:
+		return
+	}
+	d.Extensions = exts
+}
+
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
+//
+// Control and private mode messages are written as their first byte followed
+// by Payload.
 func (d *NTP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if d.Mode == NTPModeControl || d.Mode == NTPModePrivate {
+		data, err := b.PrependBytes(1 + len(d.BaseLayer.Payload))
+		if err != nil {
+			return err
+		}
+		data[0] = (uint8(d.LeapIndicator)<<6)&0xC0 | (uint8(d.Version)<<3)&0x38 | uint8(d.Mode)&0x07
+		copy(data[1:], d.BaseLayer.Payload)
+		return nil
+	}
+
 	data, err := b.PrependBytes(ntpMinimumRecordSizeInBytes)
 	if err != nil {
 		return err
@@ -375,11 +536,36 @@ func (d *NTP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 	binary.BigEndian.PutUint64(data[32:40], uint64(d.ReceiveTimestamp))
 	binary.BigEndian.PutUint64(data[40:48], uint64(d.TransmitTimestamp))
 
-	ex, err := b.AppendBytes(len(d.ExtensionBytes))
-	if err != nil {
-		return err
+	if len(d.ExtensionBytes) > 0 {
+		ex, err := b.AppendBytes(len(d.ExtensionBytes))
+		if err != nil {
+			return err
+		}
+		copy(ex, d.ExtensionBytes)
+		return nil
+	}
+
+	for _, e := range d.Extensions {
+		length := 4 + len(e.Value)
+		if length%4 != 0 || length > 0xffff {
+			return errors.New("NTP extension field length must be a multiple of 4 and fit in 16 bits")
+		}
+		ex, err := b.AppendBytes(length)
+		if err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint16(ex[0:2], e.Type)
+		binary.BigEndian.PutUint16(ex[2:4], uint16(length))
+		copy(ex[4:], e.Value)
+	}
+	if d.MAC != nil {
+		ex, err := b.AppendBytes(4 + len(d.MAC))
+		if err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(ex[0:4], d.KeyID)
+		copy(ex[4:], d.MAC)
 	}
-	copy(ex, d.ExtensionBytes)
 
 	return nil
 }
@@ -404,11 +590,11 @@ func (d *NTP) NextLayerType() gopacket.LayerType {
 
 //******************************************************************************
 
-// NTP packets do not carry any data payload, so the empty byte slice is retured.
-// In Go, a nil slice is functionally identical to an empty slice, so we
-// return nil to avoid a heap allocation.
+// Payload returns the body of control and private mode messages, which
+// aren't decoded.  Other NTP packets do not carry any data payload, so nil is
+// returned for them.
 func (d *NTP) Payload() []byte {
-	return nil
+	return d.BaseLayer.Payload
 }
 
 //******************************************************************************
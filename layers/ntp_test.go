@@ -14,6 +14,7 @@ import (
 	"io"
 	"reflect"
 	"testing"
+	"time"
 )
 
 //******************************************************************************
@@ -256,3 +257,116 @@ func TestNTPIsomorphism(t *testing.T) {
 		t.Errorf("NTP packet is not isomorphic:\ngot  :\n%x\n\nwant :\n%x\n\n", buf.Bytes(), NTPData)
 	}
 }
+
+//******************************************************************************
+
+func TestNTPTimestampTime(t *testing.T) {
+	for _, test := range []struct {
+		ts   NTPTimestamp
+		want time.Time
+	}{
+		{0, time.Time{}},
+		{0x83aa7e80 << 32, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{0xc50204ecec42ee92, time.Date(2004, 9, 27, 3, 18, 4, 922896300, time.UTC)},
+		{0x80000000 << 32, time.Date(1968, 1, 20, 3, 14, 8, 0, time.UTC)},
+		// Seconds with the top bit clear fall in era 1, after the rollover.
+		{1 << 32, time.Date(2036, 2, 7, 6, 28, 17, 0, time.UTC)},
+	} {
+		if got := test.ts.Time(); !got.Equal(test.want) {
+			t.Errorf("%#x: got %v, want %v", uint64(test.ts), got, test.want)
+		}
+	}
+
+	ref := time.Date(1910, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got, want := NTPTimestamp(1<<32).TimeNear(ref), time.Date(1900, 1, 1, 0, 0, 1, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("TimeNear era 0: got %v, want %v", got, want)
+	}
+	ref = time.Date(2170, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got, want := NTPTimestamp(1<<32).TimeNear(ref), time.Date(2172, 3, 15, 12, 56, 33, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("TimeNear era 2: got %v, want %v", got, want)
+	}
+
+	for _, tm := range []time.Time{
+		time.Date(2004, 9, 27, 3, 18, 4, 922896300, time.UTC),
+		time.Date(2040, 6, 1, 12, 0, 0, 500000000, time.UTC),
+	} {
+		if got := NewNTPTimestamp(tm).Time(); got.Sub(tm) > time.Nanosecond || tm.Sub(got) > time.Nanosecond {
+			t.Errorf("round trip of %v: got %v", tm, got)
+		}
+	}
+}
+
+//******************************************************************************
+
+func TestNTPExtensionsAndMAC(t *testing.T) {
+	query := &NTP{
+		Version:           4,
+		Mode:              NTPModeClient,
+		TransmitTimestamp: NewNTPTimestamp(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Extensions: []NTPExtensionField{
+			{Type: 0x0104, Value: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}},
+		},
+		KeyID: 7,
+		MAC:   []byte{0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, query); err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.Bytes()) != 48+20+20 {
+		t.Fatalf("got %d bytes, want %d", len(buf.Bytes()), 48+20+20)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeNTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeNTP).(*NTP)
+	if got.Mode != NTPModeClient || got.Version != 4 || got.TransmitTimestamp != query.TransmitTimestamp {
+		t.Errorf("header mismatch: got %+v", got)
+	}
+	if !reflect.DeepEqual(got.Extensions, query.Extensions) {
+		t.Errorf("extensions: got %v, want %v", got.Extensions, query.Extensions)
+	}
+	if got.KeyID != 7 || !reflect.DeepEqual(got.MAC, query.MAC) {
+		t.Errorf("MAC: got key %d, %x", got.KeyID, got.MAC)
+	}
+	if !reflect.DeepEqual(got.ExtensionBytes, buf.Bytes()[48:]) {
+		t.Errorf("extension bytes: got %x", got.ExtensionBytes)
+	}
+
+	// Bytes that don't parse are kept only in ExtensionBytes.
+	got = &NTP{}
+	if err := got.DecodeFromBytes(append(buf.Bytes()[:48:48], 1, 2, 3, 4, 5, 6, 7), gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if got.Extensions != nil || got.MAC != nil || len(got.ExtensionBytes) != 7 {
+		t.Errorf("unparseable extensions: got %v, %x, %x", got.Extensions, got.MAC, got.ExtensionBytes)
+	}
+}
+
+//******************************************************************************
+
+func TestNTPPrivateMode(t *testing.T) {
+	// An NTPv2 mode 7 MON_GETLIST_1 request, as used for amplification.
+	monlist := []byte{0x17, 0x00, 0x03, 0x2a, 0x00, 0x00, 0x00, 0x00}
+	p := gopacket.NewPacket(monlist, LayerTypeNTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	ntp := p.Layer(LayerTypeNTP).(*NTP)
+	if ntp.Mode != NTPModePrivate || ntp.Version != 2 {
+		t.Errorf("got mode %v version %d", ntp.Mode, ntp.Version)
+	}
+	if !reflect.DeepEqual(ntp.Payload(), monlist[1:]) {
+		t.Errorf("payload: got %x", ntp.Payload())
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := ntp.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(buf.Bytes(), monlist) {
+		t.Errorf("serialization: got %x, want %x", buf.Bytes(), monlist)
+	}
+}
@@ -0,0 +1,847 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+const openFlowHeaderLength = 8
+
+// ErrOpenFlowNeedMoreData is returned when data ends partway through an
+// OpenFlow message.  When decoding a reassembled TCP stream, keep the
+// unconsumed bytes and decode again once more data arrives.
+var ErrOpenFlowNeedMoreData = errors.New("OpenFlow message truncated, need more data")
+
+// OpenFlowNoBuffer is the buffer ID of PACKET_IN, PACKET_OUT and FLOW_MOD
+// messages that don't refer to a packet buffered by the switch.
+const OpenFlowNoBuffer = 0xffffffff
+
+// OpenFlowVersion is the wire protocol version of an OpenFlow message.
+type OpenFlowVersion uint8
+
+// OpenFlowVersion known values.  Message bodies are only decoded for these
+// versions.
+const (
+	OpenFlowVersion10 OpenFlowVersion = 1
+	OpenFlowVersion13 OpenFlowVersion = 4
+)
+
+func (v OpenFlowVersion) String() string {
+	if v >= 1 && v <= 6 {
+		return fmt.Sprintf("1.%d", v-1)
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(v))
+}
+
+func (v OpenFlowVersion) decodable() bool {
+	return v == OpenFlowVersion10 || v == OpenFlowVersion13
+}
+
+// OpenFlowType is the type of an OpenFlow message.  Types past PORT_STATUS
+// differ between versions.
+type OpenFlowType uint8
+
+// OpenFlowType values common to all versions.
+const (
+	OpenFlowTypeHello            OpenFlowType = 0
+	OpenFlowTypeError            OpenFlowType = 1
+	OpenFlowTypeEchoRequest      OpenFlowType = 2
+	OpenFlowTypeEchoReply        OpenFlowType = 3
+	OpenFlowTypeExperimenter     OpenFlowType = 4
+	OpenFlowTypeFeaturesRequest  OpenFlowType = 5
+	OpenFlowTypeFeaturesReply    OpenFlowType = 6
+	OpenFlowTypeGetConfigRequest OpenFlowType = 7
+	OpenFlowTypeGetConfigReply   OpenFlowType = 8
+	OpenFlowTypeSetConfig        OpenFlowType = 9
+	OpenFlowTypePacketIn         OpenFlowType = 10
+	OpenFlowTypeFlowRemoved      OpenFlowType = 11
+	OpenFlowTypePortStatus       OpenFlowType = 12
+	OpenFlowTypePacketOut        OpenFlowType = 13
+	OpenFlowTypeFlowMod          OpenFlowType = 14
+)
+
+func (t OpenFlowType) String() string {
+	switch t {
+	case OpenFlowTypeHello:
+		return "HELLO"
+	case OpenFlowTypeError:
+		return "ERROR"
+	case OpenFlowTypeEchoRequest:
+		return "ECHO_REQUEST"
+	case OpenFlowTypeEchoReply:
+		return "ECHO_REPLY"
+	case OpenFlowTypeExperimenter:
+		return "EXPERIMENTER"
+	case OpenFlowTypeFeaturesRequest:
+		return "FEATURES_REQUEST"
+	case OpenFlowTypeFeaturesReply:
+		return "FEATURES_REPLY"
+	case OpenFlowTypeGetConfigRequest:
+		return "GET_CONFIG_REQUEST"
+	case OpenFlowTypeGetConfigReply:
+		return "GET_CONFIG_REPLY"
+	case OpenFlowTypeSetConfig:
+		return "SET_CONFIG"
+	case OpenFlowTypePacketIn:
+		return "PACKET_IN"
+	case OpenFlowTypeFlowRemoved:
+		return "FLOW_REMOVED"
+	case OpenFlowTypePortStatus:
+		return "PORT_STATUS"
+	case OpenFlowTypePacketOut:
+		return "PACKET_OUT"
+	case OpenFlowTypeFlowMod:
+		return "FLOW_MOD"
+	default:
+		return fmt.Sprintf("Type(%d)", uint8(t))
+	}
+}
+
+// OpenFlowHello is the body of a HELLO message.  VersionBitmap is taken from
+// the version bitmap element, which OpenFlow 1.3.1 introduced; other
+// elements are skipped.
+type OpenFlowHello struct {
+	VersionBitmap []uint32
+}
+
+// Versions returns the versions set in the version bitmap.
+func (h *OpenFlowHello) Versions() []OpenFlowVersion {
+	var vs []OpenFlowVersion
+	for i, word := range h.VersionBitmap {
+		for bit := uint(0); bit < 32; bit++ {
+			if word&(1<<bit) != 0 {
+				vs = append(vs, OpenFlowVersion(i*32+int(bit)))
+			}
+		}
+	}
+	return vs
+}
+
+const openFlowHelloVersionBitmap = 1
+
+func (h *OpenFlowHello) decode(data []byte) error {
+	for len(data) >= 4 {
+		t := binary.BigEndian.Uint16(data[0:2])
+		l := int(binary.BigEndian.Uint16(data[2:4]))
+		if l < 4 || l > len(data) {
+			return fmt.Errorf("OpenFlow HELLO element length %d invalid", l)
+		}
+		if t == openFlowHelloVersionBitmap {
+			for b := data[4:l]; len(b) >= 4; b = b[4:] {
+				h.VersionBitmap = append(h.VersionBitmap, binary.BigEndian.Uint32(b))
+			}
+		}
+		data = data[openFlowPadded(l, len(data)):]
+	}
+	return nil
+}
+
+func (h *OpenFlowHello) encode() []byte {
+	if len(h.VersionBitmap) == 0 {
+		return nil
+	}
+	l := 4 + 4*len(h.VersionBitmap)
+	b := make([]byte, openFlowPadded(l, -1))
+	binary.BigEndian.PutUint16(b[0:2], openFlowHelloVersionBitmap)
+	binary.BigEndian.PutUint16(b[2:4], uint16(l))
+	for i, word := range h.VersionBitmap {
+		binary.BigEndian.PutUint32(b[4+4*i:], word)
+	}
+	return b
+}
+
+// openFlowPadded returns l rounded up to a multiple of 8, the alignment of
+// most OpenFlow structures, capped at max unless max is negative.
+func openFlowPadded(l, max int) int {
+	l = (l + 7) &^ 7
+	if max >= 0 && l > max {
+		return max
+	}
+	return l
+}
+
+// OpenFlowError is the body of an ERROR message.  Data usually holds the
+// start of the message that caused the error.
+type OpenFlowError struct {
+	Type uint16
+	Code uint16
+	Data []byte
+}
+
+// OpenFlowPort describes a port of a switch.  OpenFlow 1.0 port numbers are
+// 16 bits long, and CurrSpeed and MaxSpeed are only carried by OpenFlow 1.3.
+type OpenFlowPort struct {
+	PortNo     uint32
+	HWAddr     net.HardwareAddr
+	Name       string
+	Config     uint32
+	State      uint32
+	Curr       uint32
+	Advertised uint32
+	Supported  uint32
+	Peer       uint32
+	CurrSpeed  uint32
+	MaxSpeed   uint32
+}
+
+func openFlowPortLength(v OpenFlowVersion) int {
+	if v == OpenFlowVersion10 {
+		return 48
+	}
+	return 64
+}
+
+func (p *OpenFlowPort) decode(data []byte, v OpenFlowVersion) error {
+	if len(data) < openFlowPortLength(v) {
+		return errors.New("OpenFlow port description too short")
+	}
+	if v == OpenFlowVersion10 {
+		p.PortNo = uint32(binary.BigEndian.Uint16(data[0:2]))
+		p.HWAddr = net.HardwareAddr(data[2:8])
+		data = data[8:]
+	} else {
+		p.PortNo = binary.BigEndian.Uint32(data[0:4])
+		p.HWAddr = net.HardwareAddr(data[8:14])
+		data = data[16:]
+	}
+	name := data[:16]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	p.Name = string(name)
+	data = data[16:]
+	for _, f := range []*uint32{&p.Config, &p.State, &p.Curr, &p.Advertised, &p.Supported, &p.Peer} {
+		*f = binary.BigEndian.Uint32(data[0:4])
+		data = data[4:]
+	}
+	if v != OpenFlowVersion10 {
+		p.CurrSpeed = binary.BigEndian.Uint32(data[0:4])
+		p.MaxSpeed = binary.BigEndian.Uint32(data[4:8])
+	}
+	return nil
+}
+
+func (p *OpenFlowPort) encode(v OpenFlowVersion) []byte {
+	b := make([]byte, openFlowPortLength(v))
+	rest := b
+	if v == OpenFlowVersion10 {
+		binary.BigEndian.PutUint16(rest[0:2], uint16(p.PortNo))
+		copy(rest[2:8], p.HWAddr)
+		rest = rest[8:]
+	} else {
+		binary.BigEndian.PutUint32(rest[0:4], p.PortNo)
+		copy(rest[8:14], p.HWAddr)
+		rest = rest[16:]
+	}
+	copy(rest[:15], p.Name)
+	rest = rest[16:]
+	for _, f := range []uint32{p.Config, p.State, p.Curr, p.Advertised, p.Supported, p.Peer} {
+		binary.BigEndian.PutUint32(rest[0:4], f)
+		rest = rest[4:]
+	}
+	if v != OpenFlowVersion10 {
+		binary.BigEndian.PutUint32(rest[0:4], p.CurrSpeed)
+		binary.BigEndian.PutUint32(rest[4:8], p.MaxSpeed)
+	}
+	return b
+}
+
+// OpenFlowFeaturesReply is the body of a FEATURES_REPLY message.
+// AuxiliaryID is only carried by OpenFlow 1.3, and Ports by OpenFlow 1.0,
+// where Actions is the bitmap of supported actions; OpenFlow 1.3 reserves
+// that field.
+type OpenFlowFeaturesReply struct {
+	DatapathID   uint64
+	Buffers      uint32
+	Tables       uint8
+	AuxiliaryID  uint8
+	Capabilities uint32
+	Actions      uint32
+	Ports        []OpenFlowPort
+}
+
+func (f *OpenFlowFeaturesReply) decode(data []byte, v OpenFlowVersion) error {
+	if len(data) < 24 {
+		return errors.New("OpenFlow FEATURES_REPLY too short")
+	}
+	f.DatapathID = binary.BigEndian.Uint64(data[0:8])
+	f.Buffers = binary.BigEndian.Uint32(data[8:12])
+	f.Tables = data[12]
+	if v != OpenFlowVersion10 {
+		f.AuxiliaryID = data[13]
+	}
+	f.Capabilities = binary.BigEndian.Uint32(data[16:20])
+	f.Actions = binary.BigEndian.Uint32(data[20:24])
+	if v != OpenFlowVersion10 {
+		return nil
+	}
+	for data = data[24:]; len(data) > 0; data = data[48:] {
+		var p OpenFlowPort
+		if err := p.decode(data, v); err != nil {
+			return err
+		}
+		f.Ports = append(f.Ports, p)
+	}
+	return nil
+}
+
+func (f *OpenFlowFeaturesReply) encode(v OpenFlowVersion) []byte {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint64(b[0:8], f.DatapathID)
+	binary.BigEndian.PutUint32(b[8:12], f.Buffers)
+	b[12] = f.Tables
+	if v != OpenFlowVersion10 {
+		b[13] = f.AuxiliaryID
+	}
+	binary.BigEndian.PutUint32(b[16:20], f.Capabilities)
+	binary.BigEndian.PutUint32(b[20:24], f.Actions)
+	if v == OpenFlowVersion10 {
+		for i := range f.Ports {
+			b = append(b, f.Ports[i].encode(v)...)
+		}
+	}
+	return b
+}
+
+// OpenFlowPortReason is why a PORT_STATUS message was sent.
+type OpenFlowPortReason uint8
+
+// OpenFlowPortReason known values.
+const (
+	OpenFlowPortReasonAdd    OpenFlowPortReason = 0
+	OpenFlowPortReasonDelete OpenFlowPortReason = 1
+	OpenFlowPortReasonModify OpenFlowPortReason = 2
+)
+
+func (r OpenFlowPortReason) String() string {
+	switch r {
+	case OpenFlowPortReasonAdd:
+		return "Add"
+	case OpenFlowPortReasonDelete:
+		return "Delete"
+	case OpenFlowPortReasonModify:
+		return "Modify"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(r))
+	}
+}
+
+// OpenFlowPortStatus is the body of a PORT_STATUS message.
+type OpenFlowPortStatus struct {
+	Reason OpenFlowPortReason
+	Port   OpenFlowPort
+}
+
+func (s *OpenFlowPortStatus) decode(data []byte, v OpenFlowVersion) error {
+	if len(data) < 8 {
+		return errors.New("OpenFlow PORT_STATUS too short")
+	}
+	s.Reason = OpenFlowPortReason(data[0])
+	return s.Port.decode(data[8:], v)
+}
+
+func (s *OpenFlowPortStatus) encode(v OpenFlowVersion) []byte {
+	b := make([]byte, 8)
+	b[0] = byte(s.Reason)
+	return append(b, s.Port.encode(v)...)
+}
+
+// OpenFlowPacketInReason is why a PACKET_IN message was sent.
+type OpenFlowPacketInReason uint8
+
+// OpenFlowPacketInReason known values.
+const (
+	OpenFlowPacketInNoMatch    OpenFlowPacketInReason = 0
+	OpenFlowPacketInAction     OpenFlowPacketInReason = 1
+	OpenFlowPacketInInvalidTTL OpenFlowPacketInReason = 2
+)
+
+func (r OpenFlowPacketInReason) String() string {
+	switch r {
+	case OpenFlowPacketInNoMatch:
+		return "NoMatch"
+	case OpenFlowPacketInAction:
+		return "Action"
+	case OpenFlowPacketInInvalidTTL:
+		return "InvalidTTL"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(r))
+	}
+}
+
+// OpenFlowPacketIn is the body of a PACKET_IN message.  Data holds the
+// frame sent to the controller, or its start if the switch buffered it,
+// which can be decoded with gopacket.NewPacket and LayerTypeEthernet.
+//
+// InPort is only carried by OpenFlow 1.0; OpenFlow 1.3 puts the port in the
+// match, along with the other pipeline fields, and adds TableID and Cookie.
+type OpenFlowPacketIn struct {
+	BufferID    uint32
+	TotalLength uint16
+	InPort      uint32
+	Reason      OpenFlowPacketInReason
+	TableID     uint8
+	Cookie      uint64
+	Match       OpenFlowMatch
+	Data        []byte
+}
+
+func (p *OpenFlowPacketIn) decode(data []byte, v OpenFlowVersion) error {
+	if v == OpenFlowVersion10 {
+		if len(data) < 10 {
+			return errors.New("OpenFlow PACKET_IN too short")
+		}
+		p.BufferID = binary.BigEndian.Uint32(data[0:4])
+		p.TotalLength = binary.BigEndian.Uint16(data[4:6])
+		p.InPort = uint32(binary.BigEndian.Uint16(data[6:8]))
+		p.Reason = OpenFlowPacketInReason(data[8])
+		p.Data = data[10:]
+		return nil
+	}
+	if len(data) < 16 {
+		return errors.New("OpenFlow PACKET_IN too short")
+	}
+	p.BufferID = binary.BigEndian.Uint32(data[0:4])
+	p.TotalLength = binary.BigEndian.Uint16(data[4:6])
+	p.Reason = OpenFlowPacketInReason(data[6])
+	p.TableID = data[7]
+	p.Cookie = binary.BigEndian.Uint64(data[8:16])
+	n, err := p.Match.decodeOXM(data[16:])
+	if err != nil {
+		return err
+	}
+	// Two bytes of padding align the frame's IP header.
+	if len(data) < 16+n+2 {
+		return errors.New("OpenFlow PACKET_IN too short")
+	}
+	p.Data = data[16+n+2:]
+	return nil
+}
+
+func (p *OpenFlowPacketIn) encode(v OpenFlowVersion) ([]byte, error) {
+	if v == OpenFlowVersion10 {
+		b := make([]byte, 10, 10+len(p.Data))
+		binary.BigEndian.PutUint32(b[0:4], p.BufferID)
+		binary.BigEndian.PutUint16(b[4:6], p.TotalLength)
+		binary.BigEndian.PutUint16(b[6:8], uint16(p.InPort))
+		b[8] = byte(p.Reason)
+		return append(b, p.Data...), nil
+	}
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint32(b[0:4], p.BufferID)
+	binary.BigEndian.PutUint16(b[4:6], p.TotalLength)
+	b[6] = byte(p.Reason)
+	b[7] = p.TableID
+	binary.BigEndian.PutUint64(b[8:16], p.Cookie)
+	match, err := p.Match.encodeOXM()
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, match...)
+	b = append(b, 0, 0)
+	return append(b, p.Data...), nil
+}
+
+// OpenFlowPacketOut is the body of a PACKET_OUT message.  Data holds the
+// frame to send when BufferID is OpenFlowNoBuffer.
+type OpenFlowPacketOut struct {
+	BufferID uint32
+	InPort   uint32
+	Actions  []OpenFlowAction
+	Data     []byte
+}
+
+func (p *OpenFlowPacketOut) decode(data []byte, v OpenFlowVersion) error {
+	fixed := 16
+	if v == OpenFlowVersion10 {
+		fixed = 8
+	}
+	if len(data) < fixed {
+		return errors.New("OpenFlow PACKET_OUT too short")
+	}
+	p.BufferID = binary.BigEndian.Uint32(data[0:4])
+	var al int
+	if v == OpenFlowVersion10 {
+		p.InPort = uint32(binary.BigEndian.Uint16(data[4:6]))
+		al = int(binary.BigEndian.Uint16(data[6:8]))
+	} else {
+		p.InPort = binary.BigEndian.Uint32(data[4:8])
+		al = int(binary.BigEndian.Uint16(data[8:10]))
+	}
+	if fixed+al > len(data) {
+		return fmt.Errorf("OpenFlow PACKET_OUT actions length %d too long", al)
+	}
+	var err error
+	if p.Actions, err = decodeOpenFlowActions(data[fixed:fixed+al], v); err != nil {
+		return err
+	}
+	p.Data = data[fixed+al:]
+	return nil
+}
+
+func (p *OpenFlowPacketOut) encode(v OpenFlowVersion) ([]byte, error) {
+	actions, err := encodeOpenFlowActions(p.Actions, v)
+	if err != nil {
+		return nil, err
+	}
+	var b []byte
+	if v == OpenFlowVersion10 {
+		b = make([]byte, 8)
+		binary.BigEndian.PutUint16(b[4:6], uint16(p.InPort))
+		binary.BigEndian.PutUint16(b[6:8], uint16(len(actions)))
+	} else {
+		b = make([]byte, 16)
+		binary.BigEndian.PutUint32(b[4:8], p.InPort)
+		binary.BigEndian.PutUint16(b[8:10], uint16(len(actions)))
+	}
+	binary.BigEndian.PutUint32(b[0:4], p.BufferID)
+	b = append(b, actions...)
+	return append(b, p.Data...), nil
+}
+
+// OpenFlowFlowModCommand is the command of a FLOW_MOD message.
+type OpenFlowFlowModCommand uint8
+
+// OpenFlowFlowModCommand known values.
+const (
+	OpenFlowFlowModAdd          OpenFlowFlowModCommand = 0
+	OpenFlowFlowModModify       OpenFlowFlowModCommand = 1
+	OpenFlowFlowModModifyStrict OpenFlowFlowModCommand = 2
+	OpenFlowFlowModDelete       OpenFlowFlowModCommand = 3
+	OpenFlowFlowModDeleteStrict OpenFlowFlowModCommand = 4
+)
+
+func (c OpenFlowFlowModCommand) String() string {
+	switch c {
+	case OpenFlowFlowModAdd:
+		return "Add"
+	case OpenFlowFlowModModify:
+		return "Modify"
+	case OpenFlowFlowModModifyStrict:
+		return "ModifyStrict"
+	case OpenFlowFlowModDelete:
+		return "Delete"
+	case OpenFlowFlowModDeleteStrict:
+		return "DeleteStrict"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// OpenFlowFlowMod is the body of a FLOW_MOD message.  OpenFlow 1.0 flows
+// apply Actions, OpenFlow 1.3 ones Instructions, which CookieMask, TableID
+// and OutGroup are also only carried by.  OpenFlow 1.0 port numbers are 16
+// bits long.
+type OpenFlowFlowMod struct {
+	Cookie       uint64
+	CookieMask   uint64
+	TableID      uint8
+	Command      OpenFlowFlowModCommand
+	IdleTimeout  uint16
+	HardTimeout  uint16
+	Priority     uint16
+	BufferID     uint32
+	OutPort      uint32
+	OutGroup     uint32
+	Flags        uint16
+	Match        OpenFlowMatch
+	Actions      []OpenFlowAction
+	Instructions []OpenFlowInstruction
+}
+
+func (f *OpenFlowFlowMod) decode(data []byte, v OpenFlowVersion) error {
+	var err error
+	if v == OpenFlowVersion10 {
+		if len(data) < openFlow10MatchLength+24 {
+			return errors.New("OpenFlow FLOW_MOD too short")
+		}
+		f.Match.decode10(data[:openFlow10MatchLength])
+		data = data[openFlow10MatchLength:]
+		f.Cookie = binary.BigEndian.Uint64(data[0:8])
+		f.Command = OpenFlowFlowModCommand(binary.BigEndian.Uint16(data[8:10]))
+		f.IdleTimeout = binary.BigEndian.Uint16(data[10:12])
+		f.HardTimeout = binary.BigEndian.Uint16(data[12:14])
+		f.Priority = binary.BigEndian.Uint16(data[14:16])
+		f.BufferID = binary.BigEndian.Uint32(data[16:20])
+		f.OutPort = uint32(binary.BigEndian.Uint16(data[20:22]))
+		f.Flags = binary.BigEndian.Uint16(data[22:24])
+		f.Actions, err = decodeOpenFlowActions(data[24:], v)
+		return err
+	}
+	if len(data) < 40 {
+		return errors.New("OpenFlow FLOW_MOD too short")
+	}
+	f.Cookie = binary.BigEndian.Uint64(data[0:8])
+	f.CookieMask = binary.BigEndian.Uint64(data[8:16])
+	f.TableID = data[16]
+	f.Command = OpenFlowFlowModCommand(data[17])
+	f.IdleTimeout = binary.BigEndian.Uint16(data[18:20])
+	f.HardTimeout = binary.BigEndian.Uint16(data[20:22])
+	f.Priority = binary.BigEndian.Uint16(data[22:24])
+	f.BufferID = binary.BigEndian.Uint32(data[24:28])
+	f.OutPort = binary.BigEndian.Uint32(data[28:32])
+	f.OutGroup = binary.BigEndian.Uint32(data[32:36])
+	f.Flags = binary.BigEndian.Uint16(data[36:38])
+	n, err := f.Match.decodeOXM(data[40:])
+	if err != nil {
+		return err
+	}
+	f.Instructions, err = decodeOpenFlowInstructions(data[40+n:])
+	return err
+}
+
+func (f *OpenFlowFlowMod) encode(v OpenFlowVersion) ([]byte, error) {
+	if v == OpenFlowVersion10 {
+		b := f.Match.encode10()
+		fixed := make([]byte, 24)
+		binary.BigEndian.PutUint64(fixed[0:8], f.Cookie)
+		binary.BigEndian.PutUint16(fixed[8:10], uint16(f.Command))
+		binary.BigEndian.PutUint16(fixed[10:12], f.IdleTimeout)
+		binary.BigEndian.PutUint16(fixed[12:14], f.HardTimeout)
+		binary.BigEndian.PutUint16(fixed[14:16], f.Priority)
+		binary.BigEndian.PutUint32(fixed[16:20], f.BufferID)
+		binary.BigEndian.PutUint16(fixed[20:22], uint16(f.OutPort))
+		binary.BigEndian.PutUint16(fixed[22:24], f.Flags)
+		b = append(b, fixed...)
+		actions, err := encodeOpenFlowActions(f.Actions, v)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, actions...), nil
+	}
+	b := make([]byte, 40)
+	binary.BigEndian.PutUint64(b[0:8], f.Cookie)
+	binary.BigEndian.PutUint64(b[8:16], f.CookieMask)
+	b[16] = f.TableID
+	b[17] = byte(f.Command)
+	binary.BigEndian.PutUint16(b[18:20], f.IdleTimeout)
+	binary.BigEndian.PutUint16(b[20:22], f.HardTimeout)
+	binary.BigEndian.PutUint16(b[22:24], f.Priority)
+	binary.BigEndian.PutUint32(b[24:28], f.BufferID)
+	binary.BigEndian.PutUint32(b[28:32], f.OutPort)
+	binary.BigEndian.PutUint32(b[32:36], f.OutGroup)
+	binary.BigEndian.PutUint16(b[36:38], f.Flags)
+	match, err := f.Match.encodeOXM()
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, match...)
+	instructions, err := encodeOpenFlowInstructions(f.Instructions)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, instructions...), nil
+}
+
+// OpenFlowMessage is a single OpenFlow message.  For OpenFlow 1.0 and 1.3,
+// Type determines which of the decoded bodies is set; ECHO messages carry
+// arbitrary data, and FEATURES_REQUEST messages none.  Body holds the
+// undecoded body of every message.
+//
+// When serializing, the decoded body is encoded if set, and Body is written
+// otherwise.
+type OpenFlowMessage struct {
+	Version OpenFlowVersion
+	Type    OpenFlowType
+	Length  uint16
+	XID     uint32
+	Body    []byte
+
+	Hello         *OpenFlowHello
+	Error         *OpenFlowError
+	FeaturesReply *OpenFlowFeaturesReply
+	PacketIn      *OpenFlowPacketIn
+	PacketOut     *OpenFlowPacketOut
+	FlowMod       *OpenFlowFlowMod
+	PortStatus    *OpenFlowPortStatus
+}
+
+// decode decodes the message at the start of data, returning its length.
+func (m *OpenFlowMessage) decode(data []byte) (int, error) {
+	if len(data) < openFlowHeaderLength {
+		return 0, ErrOpenFlowNeedMoreData
+	}
+	m.Version = OpenFlowVersion(data[0])
+	m.Type = OpenFlowType(data[1])
+	m.Length = binary.BigEndian.Uint16(data[2:4])
+	m.XID = binary.BigEndian.Uint32(data[4:8])
+	if m.Length < openFlowHeaderLength {
+		return 0, fmt.Errorf("OpenFlow message length %d too short", m.Length)
+	}
+	if len(data) < int(m.Length) {
+		return 0, ErrOpenFlowNeedMoreData
+	}
+	m.Body = data[openFlowHeaderLength:m.Length]
+	var err error
+	switch {
+	case m.Type == OpenFlowTypeHello:
+		m.Hello = &OpenFlowHello{}
+		err = m.Hello.decode(m.Body)
+	case m.Type == OpenFlowTypeError:
+		if len(m.Body) < 4 {
+			return 0, errors.New("OpenFlow ERROR too short")
+		}
+		m.Error = &OpenFlowError{
+			Type: binary.BigEndian.Uint16(m.Body[0:2]),
+			Code: binary.BigEndian.Uint16(m.Body[2:4]),
+			Data: m.Body[4:],
+		}
+	case !m.Version.decodable():
+	case m.Type == OpenFlowTypeFeaturesReply:
+		m.FeaturesReply = &OpenFlowFeaturesReply{}
+		err = m.FeaturesReply.decode(m.Body, m.Version)
+	case m.Type == OpenFlowTypePacketIn:
+		m.PacketIn = &OpenFlowPacketIn{}
+		err = m.PacketIn.decode(m.Body, m.Version)
+	case m.Type == OpenFlowTypePacketOut:
+		m.PacketOut = &OpenFlowPacketOut{}
+		err = m.PacketOut.decode(m.Body, m.Version)
+	case m.Type == OpenFlowTypeFlowMod:
+		m.FlowMod = &OpenFlowFlowMod{}
+		err = m.FlowMod.decode(m.Body, m.Version)
+	case m.Type == OpenFlowTypePortStatus:
+		m.PortStatus = &OpenFlowPortStatus{}
+		err = m.PortStatus.decode(m.Body, m.Version)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(m.Length), nil
+}
+
+// encode returns the serialized message, setting Length if fixLengths is
+// true.
+func (m *OpenFlowMessage) encode(fixLengths bool) ([]byte, error) {
+	body := m.Body
+	var err error
+	switch {
+	case m.Hello != nil:
+		body = m.Hello.encode()
+	case m.Error != nil:
+		body = make([]byte, 4, 4+len(m.Error.Data))
+		binary.BigEndian.PutUint16(body[0:2], m.Error.Type)
+		binary.BigEndian.PutUint16(body[2:4], m.Error.Code)
+		body = append(body, m.Error.Data...)
+	case !m.Version.decodable():
+	case m.FeaturesReply != nil:
+		body = m.FeaturesReply.encode(m.Version)
+	case m.PacketIn != nil:
+		body, err = m.PacketIn.encode(m.Version)
+	case m.PacketOut != nil:
+		body, err = m.PacketOut.encode(m.Version)
+	case m.FlowMod != nil:
+		body, err = m.FlowMod.encode(m.Version)
+	case m.PortStatus != nil:
+		body = m.PortStatus.encode(m.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if openFlowHeaderLength+len(body) > 0xffff {
+		return nil, fmt.Errorf("OpenFlow message body of %d bytes too long", len(body))
+	}
+	if fixLengths {
+		m.Length = uint16(openFlowHeaderLength + len(body))
+	}
+	b := make([]byte, openFlowHeaderLength, openFlowHeaderLength+len(body))
+	b[0] = byte(m.Version)
+	b[1] = byte(m.Type)
+	binary.BigEndian.PutUint16(b[2:4], m.Length)
+	binary.BigEndian.PutUint32(b[4:8], m.XID)
+	return append(b, body...), nil
+}
+
+// OpenFlow holds the OpenFlow messages in a TCP segment or a chunk of a
+// reassembled stream, between a controller and a switch.  Messages of
+// OpenFlow 1.0 and 1.3 are decoded; others only have their header decoded.
+type OpenFlow struct {
+	BaseLayer
+	Messages []OpenFlowMessage
+}
+
+// LayerType returns LayerTypeOpenFlow.
+func (o *OpenFlow) LayerType() gopacket.LayerType { return LayerTypeOpenFlow }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (o *OpenFlow) CanDecode() gopacket.LayerClass { return LayerTypeOpenFlow }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (o *OpenFlow) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil.  The frames of PACKET_IN and PACKET_OUT messages are
+// in their Data fields.
+func (o *OpenFlow) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.  data must hold
+// only complete messages; if the last one is cut off,
+// ErrOpenFlowNeedMoreData is returned.
+func (o *OpenFlow) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	_, err := o.DecodeMessages(data, df)
+	return err
+}
+
+// DecodeMessages decodes as many complete messages as data holds, and
+// returns the number of bytes they take up.  If data ends partway through a
+// message, the messages before it are decoded and ErrOpenFlowNeedMoreData
+// is returned along with their length, so that the caller can keep the
+// remaining bytes until more of the stream arrives.
+func (o *OpenFlow) DecodeMessages(data []byte, df gopacket.DecodeFeedback) (int, error) {
+	o.Messages = o.Messages[:0]
+	n := 0
+	var err error
+	for n < len(data) {
+		var m OpenFlowMessage
+		var ml int
+		if ml, err = m.decode(data[n:]); err != nil {
+			break
+		}
+		o.Messages = append(o.Messages, m)
+		n += ml
+	}
+	if err == ErrOpenFlowNeedMoreData {
+		df.SetTruncated()
+	}
+	o.BaseLayer = BaseLayer{Contents: data[:n]}
+	return n, err
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (o *OpenFlow) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	var data []byte
+	for i := range o.Messages {
+		m, err := o.Messages[i].encode(opts.FixLengths)
+		if err != nil {
+			return err
+		}
+		data = append(data, m...)
+	}
+	bytes, err := b.PrependBytes(len(data))
+	if err != nil {
+		return err
+	}
+	copy(bytes, data)
+	return nil
+}
+
+func decodeOpenFlow(data []byte, p gopacket.PacketBuilder) error {
+	o := &OpenFlow{}
+	if err := o.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(o)
+	p.SetApplicationLayer(o)
+	return nil
+}
@@ -0,0 +1,501 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+const openFlow10MatchLength = 40
+
+// OpenFlowMatch is the match of a FLOW_MOD or PACKET_IN message.
+//
+// OpenFlow 1.0 matches have fixed fields, which are ignored when the
+// corresponding bits of Wildcards are set, and whose IP addresses may be
+// partially wildcarded.  OpenFlow 1.3 matches are a list of OXM TLVs
+// instead, kept in OXM.
+type OpenFlowMatch struct {
+	Wildcards  uint32
+	InPort     uint16
+	EthSrc     net.HardwareAddr
+	EthDst     net.HardwareAddr
+	VLAN       uint16
+	VLANPCP    uint8
+	EthType    EthernetType
+	IPToS      uint8
+	IPProtocol IPProtocol
+	IPSrc      net.IP
+	IPDst      net.IP
+	TPSrc      uint16
+	TPDst      uint16
+
+	OXM []OpenFlowOXM
+}
+
+func (m *OpenFlowMatch) decode10(data []byte) {
+	m.Wildcards = binary.BigEndian.Uint32(data[0:4])
+	m.InPort = binary.BigEndian.Uint16(data[4:6])
+	m.EthSrc = net.HardwareAddr(data[6:12])
+	m.EthDst = net.HardwareAddr(data[12:18])
+	m.VLAN = binary.BigEndian.Uint16(data[18:20])
+	m.VLANPCP = data[20]
+	m.EthType = EthernetType(binary.BigEndian.Uint16(data[22:24]))
+	m.IPToS = data[24]
+	m.IPProtocol = IPProtocol(data[25])
+	m.IPSrc = net.IP(data[28:32])
+	m.IPDst = net.IP(data[32:36])
+	m.TPSrc = binary.BigEndian.Uint16(data[36:38])
+	m.TPDst = binary.BigEndian.Uint16(data[38:40])
+}
+
+func (m *OpenFlowMatch) encode10() []byte {
+	b := make([]byte, openFlow10MatchLength)
+	binary.BigEndian.PutUint32(b[0:4], m.Wildcards)
+	binary.BigEndian.PutUint16(b[4:6], m.InPort)
+	copy(b[6:12], m.EthSrc)
+	copy(b[12:18], m.EthDst)
+	binary.BigEndian.PutUint16(b[18:20], m.VLAN)
+	b[20] = m.VLANPCP
+	binary.BigEndian.PutUint16(b[22:24], uint16(m.EthType))
+	b[24] = m.IPToS
+	b[25] = byte(m.IPProtocol)
+	copy(b[28:32], m.IPSrc.To4())
+	copy(b[32:36], m.IPDst.To4())
+	binary.BigEndian.PutUint16(b[36:38], m.TPSrc)
+	binary.BigEndian.PutUint16(b[38:40], m.TPDst)
+	return b
+}
+
+const openFlowMatchTypeOXM = 1
+
+// decodeOXM decodes the OpenFlow 1.3 match at the start of data, returning
+// its length, padding included.
+func (m *OpenFlowMatch) decodeOXM(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, errors.New("OpenFlow match too short")
+	}
+	if t := binary.BigEndian.Uint16(data[0:2]); t != openFlowMatchTypeOXM {
+		return 0, fmt.Errorf("OpenFlow match type %d not OXM", t)
+	}
+	l := int(binary.BigEndian.Uint16(data[2:4]))
+	padded := openFlowPadded(l, -1)
+	if l < 4 || padded > len(data) {
+		return 0, fmt.Errorf("OpenFlow match length %d invalid", l)
+	}
+	for fields := data[4:l]; len(fields) > 0; {
+		var f OpenFlowOXM
+		n, err := f.decode(fields)
+		if err != nil {
+			return 0, err
+		}
+		m.OXM = append(m.OXM, f)
+		fields = fields[n:]
+	}
+	return padded, nil
+}
+
+func (m *OpenFlowMatch) encodeOXM() ([]byte, error) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b[0:2], openFlowMatchTypeOXM)
+	for i := range m.OXM {
+		f, err := m.OXM[i].encode()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, f...)
+	}
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	return append(b, make([]byte, openFlowPadded(len(b), -1)-len(b))...), nil
+}
+
+// OpenFlowOXMClass is the class of an OXM TLV.
+type OpenFlowOXMClass uint16
+
+// OpenFlowOXMClass known values.
+const (
+	OpenFlowOXMClassNXM0          OpenFlowOXMClass = 0x0000
+	OpenFlowOXMClassNXM1          OpenFlowOXMClass = 0x0001
+	OpenFlowOXMClassOpenFlowBasic OpenFlowOXMClass = 0x8000
+	OpenFlowOXMClassExperimenter  OpenFlowOXMClass = 0xffff
+)
+
+// OpenFlowOXMField is the field of an OXM TLV of the OpenFlowBasic class.
+type OpenFlowOXMField uint8
+
+// OpenFlowOXMField known values.
+const (
+	OpenFlowOXMInPort       OpenFlowOXMField = 0
+	OpenFlowOXMInPhyPort    OpenFlowOXMField = 1
+	OpenFlowOXMMetadata     OpenFlowOXMField = 2
+	OpenFlowOXMEthDst       OpenFlowOXMField = 3
+	OpenFlowOXMEthSrc       OpenFlowOXMField = 4
+	OpenFlowOXMEthType      OpenFlowOXMField = 5
+	OpenFlowOXMVLANVID      OpenFlowOXMField = 6
+	OpenFlowOXMVLANPCP      OpenFlowOXMField = 7
+	OpenFlowOXMIPDSCP       OpenFlowOXMField = 8
+	OpenFlowOXMIPECN        OpenFlowOXMField = 9
+	OpenFlowOXMIPProto      OpenFlowOXMField = 10
+	OpenFlowOXMIPv4Src      OpenFlowOXMField = 11
+	OpenFlowOXMIPv4Dst      OpenFlowOXMField = 12
+	OpenFlowOXMTCPSrc       OpenFlowOXMField = 13
+	OpenFlowOXMTCPDst       OpenFlowOXMField = 14
+	OpenFlowOXMUDPSrc       OpenFlowOXMField = 15
+	OpenFlowOXMUDPDst       OpenFlowOXMField = 16
+	OpenFlowOXMSCTPSrc      OpenFlowOXMField = 17
+	OpenFlowOXMSCTPDst      OpenFlowOXMField = 18
+	OpenFlowOXMICMPv4Type   OpenFlowOXMField = 19
+	OpenFlowOXMICMPv4Code   OpenFlowOXMField = 20
+	OpenFlowOXMARPOp        OpenFlowOXMField = 21
+	OpenFlowOXMARPSPA       OpenFlowOXMField = 22
+	OpenFlowOXMARPTPA       OpenFlowOXMField = 23
+	OpenFlowOXMARPSHA       OpenFlowOXMField = 24
+	OpenFlowOXMARPTHA       OpenFlowOXMField = 25
+	OpenFlowOXMIPv6Src      OpenFlowOXMField = 26
+	OpenFlowOXMIPv6Dst      OpenFlowOXMField = 27
+	OpenFlowOXMIPv6FLabel   OpenFlowOXMField = 28
+	OpenFlowOXMICMPv6Type   OpenFlowOXMField = 29
+	OpenFlowOXMICMPv6Code   OpenFlowOXMField = 30
+	OpenFlowOXMIPv6NDTarget OpenFlowOXMField = 31
+	OpenFlowOXMIPv6NDSLL    OpenFlowOXMField = 32
+	OpenFlowOXMIPv6NDTLL    OpenFlowOXMField = 33
+	OpenFlowOXMMPLSLabel    OpenFlowOXMField = 34
+	OpenFlowOXMMPLSTC       OpenFlowOXMField = 35
+	OpenFlowOXMMPLSBoS      OpenFlowOXMField = 36
+	OpenFlowOXMPBBISID      OpenFlowOXMField = 37
+	OpenFlowOXMTunnelID     OpenFlowOXMField = 38
+	OpenFlowOXMIPv6ExtHdr   OpenFlowOXMField = 39
+)
+
+var openFlowOXMFieldNames = []string{
+	"IN_PORT", "IN_PHY_PORT", "METADATA", "ETH_DST", "ETH_SRC", "ETH_TYPE",
+	"VLAN_VID", "VLAN_PCP", "IP_DSCP", "IP_ECN", "IP_PROTO", "IPV4_SRC",
+	"IPV4_DST", "TCP_SRC", "TCP_DST", "UDP_SRC", "UDP_DST", "SCTP_SRC",
+	"SCTP_DST", "ICMPV4_TYPE", "ICMPV4_CODE", "ARP_OP", "ARP_SPA", "ARP_TPA",
+	"ARP_SHA", "ARP_THA", "IPV6_SRC", "IPV6_DST", "IPV6_FLABEL", "ICMPV6_TYPE",
+	"ICMPV6_CODE", "IPV6_ND_TARGET", "IPV6_ND_SLL", "IPV6_ND_TLL", "MPLS_LABEL",
+	"MPLS_TC", "MPLS_BOS", "PBB_ISID", "TUNNEL_ID", "IPV6_EXTHDR",
+}
+
+func (f OpenFlowOXMField) String() string {
+	if int(f) < len(openFlowOXMFieldNames) {
+		return openFlowOXMFieldNames[f]
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(f))
+}
+
+// OpenFlowOXM is an OXM TLV, a field to match or to set.  Value is in
+// network byte order, and Mask, of the same length, is set for masked
+// matches.  The values of experimenter fields start with the experimenter
+// ID.
+type OpenFlowOXM struct {
+	Class OpenFlowOXMClass
+	Field OpenFlowOXMField
+	Value []byte
+	Mask  []byte
+}
+
+func (f *OpenFlowOXM) decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, errors.New("OpenFlow OXM header truncated")
+	}
+	f.Class = OpenFlowOXMClass(binary.BigEndian.Uint16(data[0:2]))
+	f.Field = OpenFlowOXMField(data[2] >> 1)
+	hasMask := data[2]&1 != 0
+	l := int(data[3])
+	if 4+l > len(data) {
+		return 0, fmt.Errorf("OpenFlow OXM length %d too long", l)
+	}
+	value := data[4 : 4+l]
+	if hasMask {
+		if l%2 != 0 {
+			return 0, fmt.Errorf("OpenFlow masked OXM length %d odd", l)
+		}
+		f.Value, f.Mask = value[:l/2], value[l/2:]
+	} else {
+		f.Value = value
+	}
+	return 4 + l, nil
+}
+
+func (f *OpenFlowOXM) encode() ([]byte, error) {
+	l := len(f.Value)
+	if f.Mask != nil {
+		if len(f.Mask) != l {
+			return nil, fmt.Errorf("OpenFlow OXM %v mask length %d differs from value length %d", f.Field, len(f.Mask), l)
+		}
+		l *= 2
+	}
+	if l > 0xff {
+		return nil, fmt.Errorf("OpenFlow OXM %v too long", f.Field)
+	}
+	b := make([]byte, 4, 4+l)
+	binary.BigEndian.PutUint16(b[0:2], uint16(f.Class))
+	b[2] = byte(f.Field) << 1
+	if f.Mask != nil {
+		b[2] |= 1
+	}
+	b[3] = byte(l)
+	b = append(b, f.Value...)
+	return append(b, f.Mask...), nil
+}
+
+// OpenFlowActionType is the type of an action.  Types other than OUTPUT and
+// EXPERIMENTER differ between OpenFlow 1.0 and 1.3.
+type OpenFlowActionType uint16
+
+// OpenFlowActionType values of OpenFlow 1.0 and 1.3.
+const (
+	OpenFlowActionOutput       OpenFlowActionType = 0
+	OpenFlowActionExperimenter OpenFlowActionType = 0xffff
+)
+
+// OpenFlowActionType values of OpenFlow 1.0.
+const (
+	OpenFlow10ActionSetVLANVID OpenFlowActionType = 1
+	OpenFlow10ActionSetVLANPCP OpenFlowActionType = 2
+	OpenFlow10ActionStripVLAN  OpenFlowActionType = 3
+	OpenFlow10ActionSetDLSrc   OpenFlowActionType = 4
+	OpenFlow10ActionSetDLDst   OpenFlowActionType = 5
+	OpenFlow10ActionSetNWSrc   OpenFlowActionType = 6
+	OpenFlow10ActionSetNWDst   OpenFlowActionType = 7
+	OpenFlow10ActionSetNWToS   OpenFlowActionType = 8
+	OpenFlow10ActionSetTPSrc   OpenFlowActionType = 9
+	OpenFlow10ActionSetTPDst   OpenFlowActionType = 10
+	OpenFlow10ActionEnqueue    OpenFlowActionType = 11
+)
+
+// OpenFlowActionType values of OpenFlow 1.3.
+const (
+	OpenFlowActionCopyTTLOut OpenFlowActionType = 11
+	OpenFlowActionCopyTTLIn  OpenFlowActionType = 12
+	OpenFlowActionSetMPLSTTL OpenFlowActionType = 15
+	OpenFlowActionDecMPLSTTL OpenFlowActionType = 16
+	OpenFlowActionPushVLAN   OpenFlowActionType = 17
+	OpenFlowActionPopVLAN    OpenFlowActionType = 18
+	OpenFlowActionPushMPLS   OpenFlowActionType = 19
+	OpenFlowActionPopMPLS    OpenFlowActionType = 20
+	OpenFlowActionSetQueue   OpenFlowActionType = 21
+	OpenFlowActionGroup      OpenFlowActionType = 22
+	OpenFlowActionSetNWTTL   OpenFlowActionType = 23
+	OpenFlowActionDecNWTTL   OpenFlowActionType = 24
+	OpenFlowActionSetField   OpenFlowActionType = 25
+	OpenFlowActionPushPBB    OpenFlowActionType = 26
+	OpenFlowActionPopPBB     OpenFlowActionType = 27
+)
+
+// OpenFlowAction is an action of a FLOW_MOD or PACKET_OUT message, or of an
+// instruction.  OUTPUT actions set Port and MaxLength, OpenFlow 1.3 GROUP
+// actions GroupID, and OpenFlow 1.3 SET_FIELD actions Field.  Data holds
+// what follows the type and length of other actions.
+type OpenFlowAction struct {
+	Type      OpenFlowActionType
+	Port      uint32
+	MaxLength uint16
+	GroupID   uint32
+	Field     *OpenFlowOXM
+	Data      []byte
+}
+
+func decodeOpenFlowActions(data []byte, v OpenFlowVersion) ([]OpenFlowAction, error) {
+	var actions []OpenFlowAction
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("OpenFlow action truncated")
+		}
+		a := OpenFlowAction{Type: OpenFlowActionType(binary.BigEndian.Uint16(data[0:2]))}
+		l := int(binary.BigEndian.Uint16(data[2:4]))
+		if l < 8 || l%8 != 0 || l > len(data) {
+			return nil, fmt.Errorf("OpenFlow action length %d invalid", l)
+		}
+		body := data[4:l]
+		switch {
+		case a.Type == OpenFlowActionOutput && v == OpenFlowVersion10:
+			a.Port = uint32(binary.BigEndian.Uint16(body[0:2]))
+			a.MaxLength = binary.BigEndian.Uint16(body[2:4])
+		case a.Type == OpenFlowActionOutput:
+			if len(body) < 12 {
+				return nil, errors.New("OpenFlow OUTPUT action too short")
+			}
+			a.Port = binary.BigEndian.Uint32(body[0:4])
+			a.MaxLength = binary.BigEndian.Uint16(body[4:6])
+		case a.Type == OpenFlowActionGroup && v != OpenFlowVersion10:
+			a.GroupID = binary.BigEndian.Uint32(body[0:4])
+		case a.Type == OpenFlowActionSetField && v != OpenFlowVersion10:
+			a.Field = &OpenFlowOXM{}
+			if _, err := a.Field.decode(body); err != nil {
+				return nil, err
+			}
+		default:
+			a.Data = body
+		}
+		actions = append(actions, a)
+		data = data[l:]
+	}
+	return actions, nil
+}
+
+func encodeOpenFlowActions(actions []OpenFlowAction, v OpenFlowVersion) ([]byte, error) {
+	var b []byte
+	for _, a := range actions {
+		var body []byte
+		switch {
+		case a.Type == OpenFlowActionOutput && v == OpenFlowVersion10:
+			body = make([]byte, 4)
+			binary.BigEndian.PutUint16(body[0:2], uint16(a.Port))
+			binary.BigEndian.PutUint16(body[2:4], a.MaxLength)
+		case a.Type == OpenFlowActionOutput:
+			body = make([]byte, 12)
+			binary.BigEndian.PutUint32(body[0:4], a.Port)
+			binary.BigEndian.PutUint16(body[4:6], a.MaxLength)
+		case a.Type == OpenFlowActionGroup && v != OpenFlowVersion10:
+			body = make([]byte, 4)
+			binary.BigEndian.PutUint32(body[0:4], a.GroupID)
+		case a.Type == OpenFlowActionSetField && v != OpenFlowVersion10:
+			if a.Field == nil {
+				return nil, errors.New("OpenFlow SET_FIELD action without a field")
+			}
+			var err error
+			if body, err = a.Field.encode(); err != nil {
+				return nil, err
+			}
+		default:
+			body = a.Data
+		}
+		l := openFlowPadded(4+len(body), -1)
+		if l > 0xffff {
+			return nil, fmt.Errorf("OpenFlow action of %d bytes too long", l)
+		}
+		action := make([]byte, l)
+		binary.BigEndian.PutUint16(action[0:2], uint16(a.Type))
+		binary.BigEndian.PutUint16(action[2:4], uint16(l))
+		copy(action[4:], body)
+		b = append(b, action...)
+	}
+	return b, nil
+}
+
+// OpenFlowInstructionType is the type of an OpenFlow 1.3 instruction.
+type OpenFlowInstructionType uint16
+
+// OpenFlowInstructionType known values.
+const (
+	OpenFlowInstructionGotoTable     OpenFlowInstructionType = 1
+	OpenFlowInstructionWriteMetadata OpenFlowInstructionType = 2
+	OpenFlowInstructionWriteActions  OpenFlowInstructionType = 3
+	OpenFlowInstructionApplyActions  OpenFlowInstructionType = 4
+	OpenFlowInstructionClearActions  OpenFlowInstructionType = 5
+	OpenFlowInstructionMeter         OpenFlowInstructionType = 6
+	OpenFlowInstructionExperimenter  OpenFlowInstructionType = 0xffff
+)
+
+func (t OpenFlowInstructionType) String() string {
+	switch t {
+	case OpenFlowInstructionGotoTable:
+		return "GOTO_TABLE"
+	case OpenFlowInstructionWriteMetadata:
+		return "WRITE_METADATA"
+	case OpenFlowInstructionWriteActions:
+		return "WRITE_ACTIONS"
+	case OpenFlowInstructionApplyActions:
+		return "APPLY_ACTIONS"
+	case OpenFlowInstructionClearActions:
+		return "CLEAR_ACTIONS"
+	case OpenFlowInstructionMeter:
+		return "METER"
+	case OpenFlowInstructionExperimenter:
+		return "EXPERIMENTER"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(t))
+	}
+}
+
+// OpenFlowInstruction is an instruction of an OpenFlow 1.3 FLOW_MOD
+// message.  Type determines which fields are set; Data holds what follows
+// the type and length of experimenter and unknown instructions.
+type OpenFlowInstruction struct {
+	Type         OpenFlowInstructionType
+	TableID      uint8
+	Metadata     uint64
+	MetadataMask uint64
+	Actions      []OpenFlowAction
+	MeterID      uint32
+	Data         []byte
+}
+
+func decodeOpenFlowInstructions(data []byte) ([]OpenFlowInstruction, error) {
+	var instructions []OpenFlowInstruction
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("OpenFlow instruction truncated")
+		}
+		in := OpenFlowInstruction{Type: OpenFlowInstructionType(binary.BigEndian.Uint16(data[0:2]))}
+		l := int(binary.BigEndian.Uint16(data[2:4]))
+		if l < 8 || l > len(data) {
+			return nil, fmt.Errorf("OpenFlow instruction length %d invalid", l)
+		}
+		switch in.Type {
+		case OpenFlowInstructionGotoTable:
+			in.TableID = data[4]
+		case OpenFlowInstructionWriteMetadata:
+			if l < 24 {
+				return nil, errors.New("OpenFlow WRITE_METADATA instruction too short")
+			}
+			in.Metadata = binary.BigEndian.Uint64(data[8:16])
+			in.MetadataMask = binary.BigEndian.Uint64(data[16:24])
+		case OpenFlowInstructionWriteActions, OpenFlowInstructionApplyActions, OpenFlowInstructionClearActions:
+			var err error
+			if in.Actions, err = decodeOpenFlowActions(data[8:l], OpenFlowVersion13); err != nil {
+				return nil, err
+			}
+		case OpenFlowInstructionMeter:
+			in.MeterID = binary.BigEndian.Uint32(data[4:8])
+		default:
+			in.Data = data[4:l]
+		}
+		instructions = append(instructions, in)
+		data = data[l:]
+	}
+	return instructions, nil
+}
+
+func encodeOpenFlowInstructions(instructions []OpenFlowInstruction) ([]byte, error) {
+	var b []byte
+	for _, in := range instructions {
+		var ins []byte
+		switch in.Type {
+		case OpenFlowInstructionGotoTable:
+			ins = make([]byte, 8)
+			ins[4] = in.TableID
+		case OpenFlowInstructionWriteMetadata:
+			ins = make([]byte, 24)
+			binary.BigEndian.PutUint64(ins[8:16], in.Metadata)
+			binary.BigEndian.PutUint64(ins[16:24], in.MetadataMask)
+		case OpenFlowInstructionWriteActions, OpenFlowInstructionApplyActions, OpenFlowInstructionClearActions:
+			actions, err := encodeOpenFlowActions(in.Actions, OpenFlowVersion13)
+			if err != nil {
+				return nil, err
+			}
+			ins = append(make([]byte, 8), actions...)
+		case OpenFlowInstructionMeter:
+			ins = make([]byte, 8)
+			binary.BigEndian.PutUint32(ins[4:8], in.MeterID)
+		default:
+			ins = make([]byte, openFlowPadded(4+len(in.Data), -1))
+			copy(ins[4:], in.Data)
+		}
+		if len(ins) > 0xffff {
+			return nil, fmt.Errorf("OpenFlow instruction of %d bytes too long", len(ins))
+		}
+		binary.BigEndian.PutUint16(ins[0:2], uint16(in.Type))
+		binary.BigEndian.PutUint16(ins[2:4], uint16(len(ins)))
+		b = append(b, ins...)
+	}
+	return b, nil
+}
@@ -0,0 +1,420 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+var testOpenFlowDecodeOptions = gopacket.DecodeOptions{
+	DecodeStreamsAsDatagrams: true,
+}
+
+// testPacketOpenFlow13FlowMod is a segment from a controller holding an
+// OpenFlow 1.3 HELLO, and a FLOW_MOD matching in_port=1,ip,nw_dst=10/8 that
+// rewrites the destination MAC address, outputs to port 2 and goes to table 1.
+var testPacketOpenFlow13FlowMod = []byte{
+	0x02, 0x42, 0xc0, 0x00, 0x02, 0x14, 0x02, 0x42, 0xc0, 0x00, 0x02, 0x01, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0xb8, 0x1f, 0x00, 0x40, 0x00, 0x40, 0x06, 0x97, 0x2a, 0xc0, 0x00, 0x02, 0x01, 0xc0, 0x00,
+	0x02, 0x14, 0x19, 0xfd, 0xa8, 0x68, 0x9a, 0x3b, 0x1c, 0x01, 0x55, 0xd1, 0x00, 0x02, 0x50, 0x18,
+	0x01, 0xf6, 0x52, 0x60, 0x00, 0x00, 0x04, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01,
+	0x00, 0x08, 0x00, 0x00, 0x00, 0x12, 0x04, 0x0e, 0x00, 0x80, 0x00, 0x00, 0x00, 0x2a, 0x11, 0x22,
+	0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x3c, 0x00, 0x00, 0x03, 0xe8, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x1e, 0x80, 0x00, 0x00, 0x04, 0x00, 0x00,
+	0x00, 0x01, 0x80, 0x00, 0x0a, 0x02, 0x08, 0x00, 0x80, 0x00, 0x19, 0x08, 0x0a, 0x00, 0x00, 0x00,
+	0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0x00, 0x28, 0x00, 0x00, 0x00, 0x00, 0x00, 0x19,
+	0x00, 0x10, 0x80, 0x00, 0x06, 0x06, 0x02, 0x42, 0xac, 0x11, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x10, 0x00, 0x00, 0x00, 0x02, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+	0x00, 0x08, 0x01, 0x00, 0x00, 0x00,
+}
+
+func TestOpenFlow13FlowMod(t *testing.T) {
+	p := gopacket.NewPacket(testPacketOpenFlow13FlowMod, LinkTypeEthernet, testOpenFlowDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeTCP, LayerTypeOpenFlow}, t)
+	o := p.Layer(LayerTypeOpenFlow).(*OpenFlow)
+	if len(o.Messages) != 2 {
+		t.Fatalf("Got %d messages, want 2", len(o.Messages))
+	}
+
+	hello := o.Messages[0]
+	if hello.Version != OpenFlowVersion13 || hello.Type != OpenFlowTypeHello || hello.XID != 1 {
+		t.Errorf("Got version %v type %v XID %d, want HELLO 1.3 1", hello.Version, hello.Type, hello.XID)
+	}
+	if want := []OpenFlowVersion{OpenFlowVersion10, OpenFlowVersion13}; !reflect.DeepEqual(hello.Hello.Versions(), want) {
+		t.Errorf("Got HELLO versions %v, want %v", hello.Hello.Versions(), want)
+	}
+
+	m := o.Messages[1]
+	if m.Type != OpenFlowTypeFlowMod || m.XID != 0x2a || m.Length != 128 {
+		t.Errorf("Got type %v XID %#x length %d, want FLOW_MOD 0x2a 128", m.Type, m.XID, m.Length)
+	}
+	want := &OpenFlowFlowMod{
+		Cookie:      0x1122334455667788,
+		Command:     OpenFlowFlowModAdd,
+		IdleTimeout: 60,
+		Priority:    1000,
+		BufferID:    OpenFlowNoBuffer,
+		OutPort:     0xffffffff,
+		OutGroup:    0xffffffff,
+		Flags:       1,
+		Match: OpenFlowMatch{OXM: []OpenFlowOXM{
+			{Class: OpenFlowOXMClassOpenFlowBasic, Field: OpenFlowOXMInPort, Value: []byte{0, 0, 0, 1}},
+			{Class: OpenFlowOXMClassOpenFlowBasic, Field: OpenFlowOXMEthType, Value: []byte{0x08, 0x00}},
+			{Class: OpenFlowOXMClassOpenFlowBasic, Field: OpenFlowOXMIPv4Dst, Value: []byte{10, 0, 0, 0}, Mask: []byte{255, 0, 0, 0}},
+		}},
+		Instructions: []OpenFlowInstruction{
+			{Type: OpenFlowInstructionApplyActions, Actions: []OpenFlowAction{
+				{Type: OpenFlowActionSetField, Field: &OpenFlowOXM{
+					Class: OpenFlowOXMClassOpenFlowBasic,
+					Field: OpenFlowOXMEthDst,
+					Value: []byte{0x02, 0x42, 0xac, 0x11, 0x00, 0x02},
+				}},
+				{Type: OpenFlowActionOutput, Port: 2, MaxLength: 0xffff},
+			}},
+			{Type: OpenFlowInstructionGotoTable, TableID: 1},
+		},
+	}
+	if !reflect.DeepEqual(m.FlowMod, want) {
+		t.Errorf("FLOW_MOD mismatch, \nwant %#v\ngot  %#v", want, m.FlowMod)
+	}
+	testSerialization(t, p, testPacketOpenFlow13FlowMod)
+}
+
+// testPacketOpenFlow10PacketIn is a segment from a switch holding an
+// OpenFlow 1.0 PACKET_IN of an ARP request that missed the flow table, and
+// an ECHO_REQUEST.
+var testPacketOpenFlow10PacketIn = []byte{
+	0x02, 0x42, 0xc0, 0x00, 0x02, 0x01, 0x02, 0x42, 0xc0, 0x00, 0x02, 0x14, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x70, 0x2b, 0x11, 0x40, 0x00, 0x40, 0x06, 0x8b, 0x61, 0xc0, 0x00, 0x02, 0x14, 0xc0, 0x00,
+	0x02, 0x01, 0xa8, 0x69, 0x19, 0xe9, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x20, 0x00, 0x50, 0x18,
+	0x01, 0xf6, 0x89, 0x78, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x3c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x05, 0x00, 0x2a, 0x00, 0x03, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02, 0x42,
+	0xac, 0x11, 0x00, 0x02, 0x08, 0x06, 0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x01, 0x02, 0x42,
+	0xac, 0x11, 0x00, 0x02, 0xac, 0x11, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xac, 0x11,
+	0x00, 0x03, 0x01, 0x02, 0x00, 0x0c, 0x00, 0x00, 0x00, 0x77, 0x70, 0x69, 0x6e, 0x67,
+}
+
+func TestOpenFlow10PacketIn(t *testing.T) {
+	p := gopacket.NewPacket(testPacketOpenFlow10PacketIn, LinkTypeEthernet, testOpenFlowDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeTCP, LayerTypeOpenFlow}, t)
+	o := p.Layer(LayerTypeOpenFlow).(*OpenFlow)
+	if len(o.Messages) != 2 {
+		t.Fatalf("Got %d messages, want 2", len(o.Messages))
+	}
+	pi := o.Messages[0].PacketIn
+	if pi == nil {
+		t.Fatal("PACKET_IN not decoded")
+	}
+	if pi.BufferID != 0x105 || pi.TotalLength != 42 || pi.InPort != 3 || pi.Reason != OpenFlowPacketInNoMatch {
+		t.Errorf("Got PACKET_IN %#v", pi)
+	}
+	frame := gopacket.NewPacket(pi.Data, LayerTypeEthernet, gopacket.Default)
+	arp, ok := frame.Layer(LayerTypeARP).(*ARP)
+	if !ok {
+		t.Fatal("PACKET_IN frame not decoded as ARP:", frame)
+	}
+	if !net.IP(arp.DstProtAddress).Equal(net.IP{172, 17, 0, 3}) {
+		t.Errorf("Got ARP target %v, want 172.17.0.3", net.IP(arp.DstProtAddress))
+	}
+
+	echo := o.Messages[1]
+	if echo.Type != OpenFlowTypeEchoRequest || echo.XID != 0x77 || string(echo.Body) != "ping" {
+		t.Errorf("Got %v XID %#x body %q, want ECHO_REQUEST 0x77 \"ping\"", echo.Type, echo.XID, echo.Body)
+	}
+	testSerialization(t, p, testPacketOpenFlow10PacketIn)
+}
+
+// testOpenFlow10FlowMod is an OpenFlow 1.0 FLOW_MOD matching TCP to
+// 10.0.0.5:80, which outputs to port 2 and sets the VLAN to 100.
+var testOpenFlow10FlowMod = []byte{
+	0x01, 0x0e, 0x00, 0x58, 0x00, 0x00, 0x00, 0x31, 0x00, 0x38, 0x20, 0xee, 0x00, 0x01, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x08, 0x00,
+	0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x50,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x1e, 0x80, 0x00,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x01, 0x00, 0x00, 0x00, 0x08, 0x00, 0x02, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x08, 0x00, 0x64, 0x00, 0x00,
+}
+
+func TestOpenFlow10FlowMod(t *testing.T) {
+	o := &OpenFlow{}
+	if err := o.DecodeFromBytes(testOpenFlow10FlowMod, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode FLOW_MOD:", err)
+	}
+	want := &OpenFlowFlowMod{
+		Cookie:      7,
+		Command:     OpenFlowFlowModAdd,
+		IdleTimeout: 10,
+		HardTimeout: 30,
+		Priority:    0x8000,
+		BufferID:    OpenFlowNoBuffer,
+		OutPort:     0xffff,
+		Flags:       1,
+		Match: OpenFlowMatch{
+			Wildcards:  0x003820ee,
+			InPort:     1,
+			EthSrc:     net.HardwareAddr{0, 0, 0, 0, 0, 0},
+			EthDst:     net.HardwareAddr{0, 0, 0, 0, 0, 0},
+			VLAN:       0xffff,
+			EthType:    EthernetTypeIPv4,
+			IPProtocol: IPProtocolTCP,
+			IPSrc:      net.IP{0, 0, 0, 0},
+			IPDst:      net.IP{10, 0, 0, 5},
+			TPDst:      80,
+		},
+		Actions: []OpenFlowAction{
+			{Type: OpenFlowActionOutput, Port: 2},
+			{Type: OpenFlow10ActionSetVLANVID, Data: []byte{0, 100, 0, 0}},
+		},
+	}
+	if !reflect.DeepEqual(o.Messages[0].FlowMod, want) {
+		t.Errorf("FLOW_MOD mismatch, \nwant %#v\ngot  %#v", want, o.Messages[0].FlowMod)
+	}
+	testOpenFlowSerialization(t, o, testOpenFlow10FlowMod)
+}
+
+// testOpenFlow10FeaturesReply is an OpenFlow 1.0 FEATURES_REPLY listing a
+// single port.
+var testOpenFlow10FeaturesReply = []byte{
+	0x01, 0x06, 0x00, 0x50, 0x00, 0x00, 0x00, 0x41, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0a, 0xbc,
+	0x00, 0x00, 0x01, 0x00, 0xfe, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc7, 0x00, 0x00, 0x0f, 0xff,
+	0x00, 0x01, 0x02, 0x42, 0xac, 0x11, 0x00, 0x0a, 0x65, 0x74, 0x68, 0x31, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x02, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestOpenFlow10FeaturesReply(t *testing.T) {
+	o := &OpenFlow{}
+	if err := o.DecodeFromBytes(testOpenFlow10FeaturesReply, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode FEATURES_REPLY:", err)
+	}
+	want := &OpenFlowFeaturesReply{
+		DatapathID:   0xabc,
+		Buffers:      256,
+		Tables:       254,
+		Capabilities: 0xc7,
+		Actions:      0xfff,
+		Ports: []OpenFlowPort{{
+			PortNo: 1,
+			HWAddr: net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x0a},
+			Name:   "eth1",
+			Curr:   0x2c0,
+		}},
+	}
+	if !reflect.DeepEqual(o.Messages[0].FeaturesReply, want) {
+		t.Errorf("FEATURES_REPLY mismatch, \nwant %#v\ngot  %#v", want, o.Messages[0].FeaturesReply)
+	}
+	testOpenFlowSerialization(t, o, testOpenFlow10FeaturesReply)
+}
+
+// testOpenFlow13Messages are OpenFlow 1.3 FEATURES_REPLY, PORT_STATUS,
+// PACKET_IN, PACKET_OUT and ERROR messages.
+var testOpenFlow13Messages = []byte{
+	0x04, 0x06, 0x00, 0x20, 0x00, 0x00, 0x00, 0x51, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0a, 0xbc,
+	0x00, 0x00, 0x01, 0x00, 0xfe, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x4f, 0x00, 0x00, 0x00, 0x00,
+	0x04, 0x0c, 0x00, 0x50, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x00, 0x02, 0x42, 0xac, 0x11, 0x00, 0x07, 0x00, 0x00,
+	0x73, 0x31, 0x2d, 0x65, 0x74, 0x68, 0x37, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x28, 0x40, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x98, 0x96, 0x80, 0x00, 0x98, 0x96, 0x80,
+	0x04, 0x0a, 0x00, 0x4c, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x00, 0x22, 0x01, 0x00,
+	0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x00, 0x01, 0x00, 0x0c, 0x80, 0x00, 0x00, 0x04,
+	0x00, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x42, 0xac, 0x11, 0x00, 0x03,
+	0x02, 0x42, 0xac, 0x11, 0x00, 0x02, 0x08, 0x00, 0x45, 0x00, 0x00, 0x14, 0x00, 0x00, 0x40, 0x00,
+	0x40, 0xfd, 0x00, 0x00, 0xac, 0x11, 0x00, 0x02, 0xac, 0x11, 0x00, 0x03, 0x04, 0x0d, 0x00, 0x4a,
+	0x00, 0x00, 0x00, 0x52, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfd, 0x00, 0x10, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0xff, 0xff, 0xff, 0xfb, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x02, 0x42, 0xac, 0x11, 0x00, 0x03, 0x02, 0x42, 0xac, 0x11, 0x00, 0x02,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x14, 0x00, 0x00, 0x40, 0x00, 0x40, 0xfd, 0x00, 0x00, 0xac, 0x11,
+	0x00, 0x02, 0xac, 0x11, 0x00, 0x03, 0x04, 0x01, 0x00, 0x14, 0x00, 0x00, 0x00, 0x53, 0x00, 0x01,
+	0x00, 0x02, 0x04, 0x0e, 0x00, 0x08, 0x00, 0x00, 0x00, 0x2a,
+}
+
+func TestOpenFlow13Messages(t *testing.T) {
+	o := &OpenFlow{}
+	if err := o.DecodeFromBytes(testOpenFlow13Messages, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode messages:", err)
+	}
+	var types []OpenFlowType
+	for _, m := range o.Messages {
+		types = append(types, m.Type)
+	}
+	wantTypes := []OpenFlowType{OpenFlowTypeFeaturesReply, OpenFlowTypePortStatus, OpenFlowTypePacketIn, OpenFlowTypePacketOut, OpenFlowTypeError}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Fatalf("Got message types %v, want %v", types, wantTypes)
+	}
+
+	fr := o.Messages[0].FeaturesReply
+	if fr.DatapathID != 0xabc || fr.Capabilities != 0x4f || fr.Ports != nil {
+		t.Errorf("Got FEATURES_REPLY %#v", fr)
+	}
+	ps := o.Messages[1].PortStatus
+	wantPort := OpenFlowPort{
+		PortNo:    7,
+		HWAddr:    net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x07},
+		Name:      "s1-eth7",
+		State:     1,
+		Curr:      0x2840,
+		CurrSpeed: 10000000,
+		MaxSpeed:  10000000,
+	}
+	if ps.Reason != OpenFlowPortReasonModify || !reflect.DeepEqual(ps.Port, wantPort) {
+		t.Errorf("Got PORT_STATUS %v %#v", ps.Reason, ps.Port)
+	}
+
+	pi := o.Messages[2].PacketIn
+	wantMatch := []OpenFlowOXM{{Class: OpenFlowOXMClassOpenFlowBasic, Field: OpenFlowOXMInPort, Value: []byte{0, 0, 0, 7}}}
+	if pi.Reason != OpenFlowPacketInAction || pi.Cookie != 0x1122334455667788 || !reflect.DeepEqual(pi.Match.OXM, wantMatch) {
+		t.Errorf("Got PACKET_IN %#v", pi)
+	}
+	frame := gopacket.NewPacket(pi.Data, LayerTypeEthernet, gopacket.Default)
+	if ip, ok := frame.Layer(LayerTypeIPv4).(*IPv4); !ok || !ip.DstIP.Equal(net.IP{172, 17, 0, 3}) {
+		t.Error("PACKET_IN frame not decoded as IPv4 to 172.17.0.3:", frame)
+	}
+
+	po := o.Messages[3].PacketOut
+	wantActions := []OpenFlowAction{{Type: OpenFlowActionOutput, Port: 0xfffffffb}}
+	if po.InPort != 0xfffffffd || !reflect.DeepEqual(po.Actions, wantActions) || !bytes.Equal(po.Data, pi.Data) {
+		t.Errorf("Got PACKET_OUT %#v", po)
+	}
+
+	e := o.Messages[4].Error
+	if e.Type != 1 || e.Code != 2 || len(e.Data) != 8 {
+		t.Errorf("Got ERROR %#v", e)
+	}
+	testOpenFlowSerialization(t, o, testOpenFlow13Messages)
+}
+
+func testOpenFlowSerialization(t *testing.T, o *OpenFlow, data []byte) {
+	for _, opts := range []gopacket.SerializeOptions{{}, {FixLengths: true}} {
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, opts, o); err != nil {
+			t.Errorf("Failed to serialize with opts %#v: %v", opts, err)
+		} else if !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("Serialization with opts %#v mismatch, \nwant %x\ngot  %x", opts, data, buf.Bytes())
+		}
+	}
+}
+
+func TestOpenFlowSerializeFlowMod(t *testing.T) {
+	// Build the FLOW_MOD of testPacketOpenFlow13FlowMod from scratch.
+	basic := OpenFlowOXMClassOpenFlowBasic
+	o := &OpenFlow{Messages: []OpenFlowMessage{{
+		Version: OpenFlowVersion13,
+		Type:    OpenFlowTypeFlowMod,
+		XID:     0x2a,
+		FlowMod: &OpenFlowFlowMod{
+			Cookie:      0x1122334455667788,
+			IdleTimeout: 60,
+			Priority:    1000,
+			BufferID:    OpenFlowNoBuffer,
+			OutPort:     0xffffffff,
+			OutGroup:    0xffffffff,
+			Flags:       1,
+			Match: OpenFlowMatch{OXM: []OpenFlowOXM{
+				{Class: basic, Field: OpenFlowOXMInPort, Value: []byte{0, 0, 0, 1}},
+				{Class: basic, Field: OpenFlowOXMEthType, Value: []byte{0x08, 0x00}},
+				{Class: basic, Field: OpenFlowOXMIPv4Dst, Value: []byte{10, 0, 0, 0}, Mask: []byte{255, 0, 0, 0}},
+			}},
+			Instructions: []OpenFlowInstruction{
+				{Type: OpenFlowInstructionApplyActions, Actions: []OpenFlowAction{
+					{Type: OpenFlowActionSetField, Field: &OpenFlowOXM{Class: basic, Field: OpenFlowOXMEthDst, Value: []byte{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}}},
+					{Type: OpenFlowActionOutput, Port: 2, MaxLength: 0xffff},
+				}},
+				{Type: OpenFlowInstructionGotoTable, TableID: 1},
+			},
+		},
+	}}}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, o); err != nil {
+		t.Fatal("Failed to serialize FLOW_MOD:", err)
+	}
+	// The FLOW_MOD follows the 16 byte HELLO.
+	want := testPacketOpenFlow13FlowMod[54+16:]
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("FLOW_MOD mismatch, \nwant %x\ngot  %x", want, buf.Bytes())
+	}
+	if o.Messages[0].Length != 128 {
+		t.Errorf("Got length %d, want 128", o.Messages[0].Length)
+	}
+
+	o.Messages[0].FlowMod.Match.OXM[2].Mask = []byte{255}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, o); err == nil {
+		t.Error("Serializing an OXM whose mask and value lengths differ succeeded")
+	}
+}
+
+func TestOpenFlowDecodeMessages(t *testing.T) {
+	data := testPacketOpenFlow13FlowMod[54:]
+	o := &OpenFlow{}
+	for _, test := range []struct {
+		length, consumed, messages int
+	}{
+		{len(data), len(data), 2},
+		{len(data) - 1, 16, 1},
+		{20, 16, 1},
+		{4, 0, 0},
+	} {
+		n, err := o.DecodeMessages(data[:test.length], gopacket.NilDecodeFeedback)
+		if test.consumed == test.length {
+			if err != nil {
+				t.Errorf("Decoding %d bytes failed: %v", test.length, err)
+			}
+		} else if err != ErrOpenFlowNeedMoreData {
+			t.Errorf("Decoding %d bytes returned %v, want ErrOpenFlowNeedMoreData", test.length, err)
+		}
+		if n != test.consumed || len(o.Messages) != test.messages {
+			t.Errorf("Decoding %d bytes consumed %d with %d messages, want %d with %d", test.length, n, len(o.Messages), test.consumed, test.messages)
+		}
+	}
+
+	p := gopacket.NewPacket(testPacketOpenFlow13FlowMod[:len(testPacketOpenFlow13FlowMod)-1], LinkTypeEthernet, testOpenFlowDecodeOptions)
+	if !p.Metadata().Truncated {
+		t.Error("Packet with a partial message not marked truncated")
+	}
+}
+
+func TestOpenFlowMalformed(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"length too short", []byte{4, 0, 0, 4, 0, 0, 0, 1}},
+		{"error too short", []byte{4, 1, 0, 10, 0, 0, 0, 1, 0, 1}},
+		{"packet in too short", []byte{1, 10, 0, 12, 0, 0, 0, 1, 0, 0, 0, 0}},
+		{"match not OXM", append([]byte{4, 14, 0, 56, 0, 0, 0, 1}, append(make([]byte, 40), 0, 0, 0, 8, 0, 0, 0, 0)...)},
+		{"action length invalid", append([]byte{1, 13, 0, 24, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0, 8}, 0, 0, 0, 4, 0, 0, 0, 0)},
+		{"actions too long", []byte{1, 13, 0, 16, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0, 16}},
+	} {
+		o := &OpenFlow{}
+		if err := o.DecodeFromBytes(test.data, gopacket.NilDecodeFeedback); err == nil || err == ErrOpenFlowNeedMoreData {
+			t.Errorf("%s: got error %v", test.name, err)
+		}
+	}
+
+	// Bodies of other versions are left undecoded.
+	o := &OpenFlow{}
+	data := []byte{5, 14, 0, 12, 0, 0, 0, 1, 1, 2, 3, 4}
+	if err := o.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode OpenFlow 1.4 message:", err)
+	}
+	if m := o.Messages[0]; m.FlowMod != nil || !bytes.Equal(m.Body, data[8:]) {
+		t.Errorf("Got OpenFlow 1.4 message %#v", m)
+	}
+	testOpenFlowSerialization(t, o, data)
+}
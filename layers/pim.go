@@ -0,0 +1,745 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// PIMType is the type of a PIM message.
+type PIMType uint8
+
+// PIMType known values.
+const (
+	PIMTypeHello                    PIMType = 0
+	PIMTypeRegister                 PIMType = 1
+	PIMTypeRegisterStop             PIMType = 2
+	PIMTypeJoinPrune                PIMType = 3
+	PIMTypeBootstrap                PIMType = 4
+	PIMTypeAssert                   PIMType = 5
+	PIMTypeGraft                    PIMType = 6
+	PIMTypeGraftAck                 PIMType = 7
+	PIMTypeCandidateRPAdvertisement PIMType = 8
+)
+
+func (t PIMType) String() string {
+	switch t {
+	case PIMTypeHello:
+		return "Hello"
+	case PIMTypeRegister:
+		return "Register"
+	case PIMTypeRegisterStop:
+		return "RegisterStop"
+	case PIMTypeJoinPrune:
+		return "JoinPrune"
+	case PIMTypeBootstrap:
+		return "Bootstrap"
+	case PIMTypeAssert:
+		return "Assert"
+	case PIMTypeGraft:
+		return "Graft"
+	case PIMTypeGraftAck:
+		return "GraftAck"
+	case PIMTypeCandidateRPAdvertisement:
+		return "CandidateRPAdvertisement"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// PIMAddressFamily is the address family of an encoded address, from the
+// IANA address family numbers.
+type PIMAddressFamily uint8
+
+// PIMAddressFamily known values.
+const (
+	PIMAddressFamilyIPv4 PIMAddressFamily = 1
+	PIMAddressFamilyIPv6 PIMAddressFamily = 2
+)
+
+func (f PIMAddressFamily) String() string {
+	switch f {
+	case PIMAddressFamilyIPv4:
+		return "IPv4"
+	case PIMAddressFamilyIPv6:
+		return "IPv6"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(f))
+	}
+}
+
+func (f PIMAddressFamily) addrLen() int {
+	switch f {
+	case PIMAddressFamilyIPv4:
+		return 4
+	case PIMAddressFamilyIPv6:
+		return 16
+	}
+	return 0
+}
+
+// Flags of encoded group and source addresses.
+const (
+	PIMGroupBidirectional = 0x80
+	PIMGroupAdminScope    = 0x01
+	PIMSourceSparse       = 0x04
+	PIMSourceWildcard     = 0x02
+	PIMSourceRPT          = 0x01
+)
+
+// PIMEncodedAddress is an address in one of the encoded formats of RFC 7761
+// section 4.9.1.  Encoded unicast addresses are only an address; encoded
+// group and source addresses add Flags, such as PIMSourceWildcard, and a
+// MaskLength.  Only the native encoding, type 0, is supported.
+//
+// When serializing, Family is taken from Address if it's unset.
+type PIMEncodedAddress struct {
+	Family       PIMAddressFamily
+	EncodingType uint8
+	Flags        uint8
+	MaskLength   uint8
+	Address      net.IP
+}
+
+func (a PIMEncodedAddress) String() string {
+	if a.MaskLength != 0 {
+		return fmt.Sprintf("%v/%d", a.Address, a.MaskLength)
+	}
+	return a.Address.String()
+}
+
+// decodePIMEncodedAddress decodes the encoded address at the start of data,
+// returning its length.  Group and source addresses, unlike unicast ones,
+// have flags and a mask length.
+func decodePIMEncodedAddress(data []byte, unicast bool) (PIMEncodedAddress, int, error) {
+	var a PIMEncodedAddress
+	n := 4
+	if unicast {
+		n = 2
+	}
+	if len(data) < n {
+		return a, 0, errors.New("PIM encoded address truncated")
+	}
+	a.Family = PIMAddressFamily(data[0])
+	a.EncodingType = data[1]
+	if a.EncodingType != 0 {
+		return a, 0, fmt.Errorf("PIM address encoding type %d unsupported", a.EncodingType)
+	}
+	if !unicast {
+		a.Flags = data[2]
+		a.MaskLength = data[3]
+	}
+	l := a.Family.addrLen()
+	if l == 0 {
+		return a, 0, fmt.Errorf("PIM address family %v unsupported", a.Family)
+	}
+	if len(data) < n+l {
+		return a, 0, errors.New("PIM encoded address truncated")
+	}
+	a.Address = net.IP(data[n : n+l])
+	return a, n + l, nil
+}
+
+func (a *PIMEncodedAddress) encode(b []byte, unicast bool) ([]byte, error) {
+	family := a.Family
+	if family == 0 {
+		family = PIMAddressFamilyIPv6
+		if a.Address.To4() != nil {
+			family = PIMAddressFamilyIPv4
+		}
+	}
+	var addr net.IP
+	switch family {
+	case PIMAddressFamilyIPv4:
+		addr = a.Address.To4()
+	case PIMAddressFamilyIPv6:
+		if a.Address.To4() == nil {
+			addr = a.Address.To16()
+		}
+	default:
+		return nil, fmt.Errorf("PIM address family %v unsupported", family)
+	}
+	if addr == nil {
+		return nil, fmt.Errorf("PIM address %v not of family %v", a.Address, family)
+	}
+	b = append(b, byte(family), a.EncodingType)
+	if !unicast {
+		b = append(b, a.Flags, a.MaskLength)
+	}
+	return append(b, addr...), nil
+}
+
+// PIMHelloOptionType is the type of a Hello option.
+type PIMHelloOptionType uint16
+
+// PIMHelloOptionType known values.
+const (
+	PIMHelloHoldtime      PIMHelloOptionType = 1
+	PIMHelloLANPruneDelay PIMHelloOptionType = 2
+	PIMHelloDRPriority    PIMHelloOptionType = 19
+	PIMHelloGenerationID  PIMHelloOptionType = 20
+	PIMHelloAddressList   PIMHelloOptionType = 24
+)
+
+func (t PIMHelloOptionType) String() string {
+	switch t {
+	case PIMHelloHoldtime:
+		return "Holdtime"
+	case PIMHelloLANPruneDelay:
+		return "LANPruneDelay"
+	case PIMHelloDRPriority:
+		return "DRPriority"
+	case PIMHelloGenerationID:
+		return "GenerationID"
+	case PIMHelloAddressList:
+		return "AddressList"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(t))
+	}
+}
+
+// PIMHelloOption is an option of a Hello message.
+type PIMHelloOption struct {
+	Type  PIMHelloOptionType
+	Value []byte
+}
+
+// PIMHello is the body of a Hello message.  Holdtime, DRPriority,
+// GenerationID and SecondaryAddresses are taken from the corresponding
+// options, if present; Options alone are serialized.
+type PIMHello struct {
+	Options []PIMHelloOption
+
+	Holdtime           uint16
+	HasDRPriority      bool
+	DRPriority         uint32
+	GenerationID       uint32
+	SecondaryAddresses []PIMEncodedAddress
+}
+
+func (h *PIMHello) decode(data []byte) error {
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return errors.New("PIM Hello option truncated")
+		}
+		o := PIMHelloOption{Type: PIMHelloOptionType(binary.BigEndian.Uint16(data[0:2]))}
+		l := int(binary.BigEndian.Uint16(data[2:4]))
+		if 4+l > len(data) {
+			return fmt.Errorf("PIM Hello option length %d too long", l)
+		}
+		o.Value = data[4 : 4+l]
+		switch {
+		case o.Type == PIMHelloHoldtime && l == 2:
+			h.Holdtime = binary.BigEndian.Uint16(o.Value)
+		case o.Type == PIMHelloDRPriority && l == 4:
+			h.HasDRPriority = true
+			h.DRPriority = binary.BigEndian.Uint32(o.Value)
+		case o.Type == PIMHelloGenerationID && l == 4:
+			h.GenerationID = binary.BigEndian.Uint32(o.Value)
+		case o.Type == PIMHelloAddressList:
+			for v := o.Value; len(v) > 0; {
+				a, n, err := decodePIMEncodedAddress(v, true)
+				if err != nil {
+					return err
+				}
+				h.SecondaryAddresses = append(h.SecondaryAddresses, a)
+				v = v[n:]
+			}
+		}
+		h.Options = append(h.Options, o)
+		data = data[4+l:]
+	}
+	return nil
+}
+
+func (h *PIMHello) encode() ([]byte, error) {
+	var b []byte
+	for _, o := range h.Options {
+		if len(o.Value) > 0xffff {
+			return nil, fmt.Errorf("PIM Hello option %v too long", o.Type)
+		}
+		b = append(b, byte(o.Type>>8), byte(o.Type), byte(len(o.Value)>>8), byte(len(o.Value)))
+		b = append(b, o.Value...)
+	}
+	return b, nil
+}
+
+// Flags of Register messages.
+const (
+	PIMRegisterBorder = 0x80000000
+	PIMRegisterNull   = 0x40000000
+)
+
+// PIMRegister is the body of a Register message.  The encapsulated packet,
+// or the dummy header of null registers, is the layer's payload.
+type PIMRegister struct {
+	Flags uint32
+}
+
+// PIMRegisterStop is the body of a Register-Stop message.
+type PIMRegisterStop struct {
+	Group  PIMEncodedAddress
+	Source PIMEncodedAddress
+}
+
+// PIMJoinPruneGroup is a group of a Join/Prune message, with the sources
+// joined and pruned.
+type PIMJoinPruneGroup struct {
+	Group  PIMEncodedAddress
+	Joins  []PIMEncodedAddress
+	Prunes []PIMEncodedAddress
+}
+
+// PIMJoinPrune is the body of a Join/Prune message, and of the Graft and
+// Graft-Ack messages of PIM-DM, which share its format.
+type PIMJoinPrune struct {
+	UpstreamNeighbor PIMEncodedAddress
+	Holdtime         uint16
+	Groups           []PIMJoinPruneGroup
+}
+
+func (j *PIMJoinPrune) decode(data []byte) error {
+	var n int
+	var err error
+	if j.UpstreamNeighbor, n, err = decodePIMEncodedAddress(data, true); err != nil {
+		return err
+	}
+	data = data[n:]
+	if len(data) < 4 {
+		return errors.New("PIM Join/Prune truncated")
+	}
+	groups := int(data[1])
+	j.Holdtime = binary.BigEndian.Uint16(data[2:4])
+	data = data[4:]
+	for i := 0; i < groups; i++ {
+		var g PIMJoinPruneGroup
+		if g.Group, n, err = decodePIMEncodedAddress(data, false); err != nil {
+			return err
+		}
+		data = data[n:]
+		if len(data) < 4 {
+			return errors.New("PIM Join/Prune group truncated")
+		}
+		joins := int(binary.BigEndian.Uint16(data[0:2]))
+		prunes := int(binary.BigEndian.Uint16(data[2:4]))
+		data = data[4:]
+		for k := 0; k < joins+prunes; k++ {
+			a, n, err := decodePIMEncodedAddress(data, false)
+			if err != nil {
+				return err
+			}
+			if k < joins {
+				g.Joins = append(g.Joins, a)
+			} else {
+				g.Prunes = append(g.Prunes, a)
+			}
+			data = data[n:]
+		}
+		j.Groups = append(j.Groups, g)
+	}
+	return nil
+}
+
+func (j *PIMJoinPrune) encode() ([]byte, error) {
+	if len(j.Groups) > 0xff {
+		return nil, errors.New("PIM Join/Prune messages can carry at most 255 groups")
+	}
+	b, err := j.UpstreamNeighbor.encode(nil, true)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, 0, byte(len(j.Groups)), byte(j.Holdtime>>8), byte(j.Holdtime))
+	for _, g := range j.Groups {
+		if len(g.Joins) > 0xffff || len(g.Prunes) > 0xffff {
+			return nil, errors.New("PIM Join/Prune group has too many sources")
+		}
+		if b, err = g.Group.encode(b, false); err != nil {
+			return nil, err
+		}
+		b = append(b, byte(len(g.Joins)>>8), byte(len(g.Joins)), byte(len(g.Prunes)>>8), byte(len(g.Prunes)))
+		for _, sources := range [][]PIMEncodedAddress{g.Joins, g.Prunes} {
+			for i := range sources {
+				if b, err = sources[i].encode(b, false); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return b, nil
+}
+
+// PIMAssert is the body of an Assert message.
+type PIMAssert struct {
+	Group            PIMEncodedAddress
+	Source           PIMEncodedAddress
+	RPT              bool
+	MetricPreference uint32
+	Metric           uint32
+}
+
+// PIMBootstrapRP is a candidate RP of a Bootstrap message group.
+type PIMBootstrapRP struct {
+	Address  PIMEncodedAddress
+	Holdtime uint16
+	Priority uint8
+}
+
+// PIMBootstrapGroup is a group range of a Bootstrap message.  RPCount is
+// the number of candidate RPs for the range in all fragments, RPs those in
+// this one.
+type PIMBootstrapGroup struct {
+	Group   PIMEncodedAddress
+	RPCount uint8
+	RPs     []PIMBootstrapRP
+}
+
+// PIMBootstrap is the body of a Bootstrap message [RFC5059].
+type PIMBootstrap struct {
+	FragmentTag    uint16
+	HashMaskLength uint8
+	Priority       uint8
+	BSRAddress     PIMEncodedAddress
+	Groups         []PIMBootstrapGroup
+}
+
+func (s *PIMBootstrap) decode(data []byte) error {
+	if len(data) < 4 {
+		return errors.New("PIM Bootstrap truncated")
+	}
+	s.FragmentTag = binary.BigEndian.Uint16(data[0:2])
+	s.HashMaskLength = data[2]
+	s.Priority = data[3]
+	var n int
+	var err error
+	if s.BSRAddress, n, err = decodePIMEncodedAddress(data[4:], true); err != nil {
+		return err
+	}
+	for data = data[4+n:]; len(data) > 0; {
+		var g PIMBootstrapGroup
+		if g.Group, n, err = decodePIMEncodedAddress(data, false); err != nil {
+			return err
+		}
+		data = data[n:]
+		if len(data) < 4 {
+			return errors.New("PIM Bootstrap group truncated")
+		}
+		g.RPCount = data[0]
+		rps := int(data[1])
+		data = data[4:]
+		for i := 0; i < rps; i++ {
+			var rp PIMBootstrapRP
+			if rp.Address, n, err = decodePIMEncodedAddress(data, true); err != nil {
+				return err
+			}
+			data = data[n:]
+			if len(data) < 4 {
+				return errors.New("PIM Bootstrap RP truncated")
+			}
+			rp.Holdtime = binary.BigEndian.Uint16(data[0:2])
+			rp.Priority = data[2]
+			g.RPs = append(g.RPs, rp)
+			data = data[4:]
+		}
+		s.Groups = append(s.Groups, g)
+	}
+	return nil
+}
+
+func (s *PIMBootstrap) encode() ([]byte, error) {
+	b := []byte{byte(s.FragmentTag >> 8), byte(s.FragmentTag), s.HashMaskLength, s.Priority}
+	b, err := s.BSRAddress.encode(b, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range s.Groups {
+		if len(g.RPs) > 0xff {
+			return nil, errors.New("PIM Bootstrap group has too many RPs")
+		}
+		if b, err = g.Group.encode(b, false); err != nil {
+			return nil, err
+		}
+		b = append(b, g.RPCount, byte(len(g.RPs)), 0, 0)
+		for _, rp := range g.RPs {
+			if b, err = rp.Address.encode(b, true); err != nil {
+				return nil, err
+			}
+			b = append(b, byte(rp.Holdtime>>8), byte(rp.Holdtime), rp.Priority, 0)
+		}
+	}
+	return b, nil
+}
+
+// PIMCandidateRP is the body of a Candidate-RP-Advertisement message
+// [RFC5059].
+type PIMCandidateRP struct {
+	Priority  uint8
+	Holdtime  uint16
+	RPAddress PIMEncodedAddress
+	Groups    []PIMEncodedAddress
+}
+
+// PIM is a PIM version 2 message [RFC7761].  Type determines which of the
+// decoded bodies is set.  The packet encapsulated by Register messages is
+// the payload, decoded as IPv4 or IPv6, as are the bodies of unknown
+// message types.
+type PIM struct {
+	BaseLayer
+	Version  uint8
+	Type     PIMType
+	Reserved uint8
+	Checksum uint16
+
+	Hello        *PIMHello
+	Register     *PIMRegister
+	RegisterStop *PIMRegisterStop
+	JoinPrune    *PIMJoinPrune
+	Bootstrap    *PIMBootstrap
+	Assert       *PIMAssert
+	CandidateRP  *PIMCandidateRP
+
+	tcpipchecksum
+}
+
+// LayerType returns LayerTypePIM.
+func (p *PIM) LayerType() gopacket.LayerType { return LayerTypePIM }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (p *PIM) CanDecode() gopacket.LayerClass { return LayerTypePIM }
+
+// NextLayerType returns the layer type of the packet encapsulated by
+// Register messages, and LayerTypePayload for the bodies of unknown message
+// types.
+func (p *PIM) NextLayerType() gopacket.LayerType {
+	if len(p.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	if p.Type == PIMTypeRegister {
+		switch p.Payload[0] >> 4 {
+		case 4:
+			return LayerTypeIPv4
+		case 6:
+			return LayerTypeIPv6
+		}
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (p *PIM) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("PIM message too short")
+	}
+	*p = PIM{tcpipchecksum: p.tcpipchecksum}
+	p.Version = data[0] >> 4
+	p.Type = PIMType(data[0] & 0x0f)
+	p.Reserved = data[1]
+	p.Checksum = binary.BigEndian.Uint16(data[2:4])
+	if p.Version != 2 {
+		return fmt.Errorf("PIM version %d unsupported", p.Version)
+	}
+	body := data[4:]
+	p.BaseLayer = BaseLayer{Contents: data}
+	var err error
+	switch p.Type {
+	case PIMTypeHello:
+		p.Hello = &PIMHello{}
+		err = p.Hello.decode(body)
+	case PIMTypeRegister:
+		if len(body) < 4 {
+			df.SetTruncated()
+			return errors.New("PIM Register truncated")
+		}
+		p.Register = &PIMRegister{Flags: binary.BigEndian.Uint32(body[0:4])}
+		p.BaseLayer = BaseLayer{Contents: data[:8], Payload: data[8:]}
+	case PIMTypeRegisterStop:
+		p.RegisterStop = &PIMRegisterStop{}
+		var n int
+		if p.RegisterStop.Group, n, err = decodePIMEncodedAddress(body, false); err == nil {
+			p.RegisterStop.Source, _, err = decodePIMEncodedAddress(body[n:], true)
+		}
+	case PIMTypeJoinPrune, PIMTypeGraft, PIMTypeGraftAck:
+		p.JoinPrune = &PIMJoinPrune{}
+		err = p.JoinPrune.decode(body)
+	case PIMTypeBootstrap:
+		p.Bootstrap = &PIMBootstrap{}
+		err = p.Bootstrap.decode(body)
+	case PIMTypeAssert:
+		err = p.decodeAssert(body)
+	case PIMTypeCandidateRPAdvertisement:
+		err = p.decodeCandidateRP(body)
+	default:
+		p.BaseLayer = BaseLayer{Contents: data[:4], Payload: body}
+	}
+	return err
+}
+
+func (p *PIM) decodeAssert(data []byte) error {
+	a := &PIMAssert{}
+	var n int
+	var err error
+	if a.Group, n, err = decodePIMEncodedAddress(data, false); err != nil {
+		return err
+	}
+	data = data[n:]
+	if a.Source, n, err = decodePIMEncodedAddress(data, true); err != nil {
+		return err
+	}
+	data = data[n:]
+	if len(data) < 8 {
+		return errors.New("PIM Assert truncated")
+	}
+	pref := binary.BigEndian.Uint32(data[0:4])
+	a.RPT = pref&0x80000000 != 0
+	a.MetricPreference = pref &^ 0x80000000
+	a.Metric = binary.BigEndian.Uint32(data[4:8])
+	p.Assert = a
+	return nil
+}
+
+func (p *PIM) decodeCandidateRP(data []byte) error {
+	if len(data) < 4 {
+		return errors.New("PIM Candidate-RP-Advertisement truncated")
+	}
+	c := &PIMCandidateRP{Priority: data[1], Holdtime: binary.BigEndian.Uint16(data[2:4])}
+	prefixes := int(data[0])
+	var n int
+	var err error
+	if c.RPAddress, n, err = decodePIMEncodedAddress(data[4:], true); err != nil {
+		return err
+	}
+	data = data[4+n:]
+	for i := 0; i < prefixes; i++ {
+		var g PIMEncodedAddress
+		if g, n, err = decodePIMEncodedAddress(data, false); err != nil {
+			return err
+		}
+		c.Groups = append(c.Groups, g)
+		data = data[n:]
+	}
+	p.CandidateRP = c
+	return nil
+}
+
+func (p *PIM) encodeBody() ([]byte, error) {
+	switch {
+	case p.Hello != nil:
+		return p.Hello.encode()
+	case p.Register != nil:
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], p.Register.Flags)
+		return b[:], nil
+	case p.RegisterStop != nil:
+		b, err := p.RegisterStop.Group.encode(nil, false)
+		if err != nil {
+			return nil, err
+		}
+		return p.RegisterStop.Source.encode(b, true)
+	case p.JoinPrune != nil:
+		return p.JoinPrune.encode()
+	case p.Bootstrap != nil:
+		return p.Bootstrap.encode()
+	case p.Assert != nil:
+		a := p.Assert
+		b, err := a.Group.encode(nil, false)
+		if err != nil {
+			return nil, err
+		}
+		if b, err = a.Source.encode(b, true); err != nil {
+			return nil, err
+		}
+		pref := a.MetricPreference &^ 0x80000000
+		if a.RPT {
+			pref |= 0x80000000
+		}
+		var m [8]byte
+		binary.BigEndian.PutUint32(m[0:4], pref)
+		binary.BigEndian.PutUint32(m[4:8], a.Metric)
+		return append(b, m[:]...), nil
+	case p.CandidateRP != nil:
+		c := p.CandidateRP
+		if len(c.Groups) > 0xff {
+			return nil, errors.New("PIM Candidate-RP-Advertisement has too many groups")
+		}
+		b, err := c.RPAddress.encode([]byte{byte(len(c.Groups)), c.Priority, byte(c.Holdtime >> 8), byte(c.Holdtime)}, true)
+		if err != nil {
+			return nil, err
+		}
+		for i := range c.Groups {
+			if b, err = c.Groups[i].encode(b, false); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// Over IPv6, computing the checksum needs the network layer, as it does for
+// TCP.
+func (p *PIM) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	body, err := p.encodeBody()
+	if err != nil {
+		return err
+	}
+	bytes, err := b.PrependBytes(4 + len(body))
+	if err != nil {
+		return err
+	}
+	bytes[0] = p.Version<<4 | uint8(p.Type)&0x0f
+	bytes[1] = p.Reserved
+	copy(bytes[4:], body)
+	if opts.ComputeChecksums {
+		bytes[2], bytes[3] = 0, 0
+		if p.Checksum, err = p.pimChecksum(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	binary.BigEndian.PutUint16(bytes[2:4], p.Checksum)
+	return nil
+}
+
+// pimChecksum returns the checksum of the message in data, whose
+// checksum field is zero.  Only the header of Register messages is
+// covered, and over IPv6, a pseudo-header too.
+func (p *PIM) pimChecksum(data []byte) (uint16, error) {
+	if p.Type == PIMTypeRegister && len(data) > 8 {
+		data = data[:8]
+	}
+	if _, ok := p.pseudoheader.(*IPv6); ok {
+		return p.tcpipchecksum.computeChecksum(data, IPProtocolPIM)
+	}
+	return tcpipChecksum(data, 0), nil
+}
+
+// ComputeChecksum returns the checksum the decoded message should carry, to
+// compare against Checksum.  Over IPv6, this needs the network layer, which
+// must be set with SetNetworkLayerForChecksum.
+func (p *PIM) ComputeChecksum() (uint16, error) {
+	if len(p.Contents) < 4 {
+		return 0, errors.New("PIM message not decoded")
+	}
+	data := make([]byte, len(p.Contents)+len(p.Payload))
+	copy(data, p.Contents)
+	copy(data[len(p.Contents):], p.Payload)
+	data[2], data[3] = 0, 0
+	return p.pimChecksum(data)
+}
+
+func decodePIM(data []byte, p gopacket.PacketBuilder) error {
+	pim := &PIM{}
+	return decodingLayerDecoder(pim, data, p)
+}
@@ -0,0 +1,318 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketPIMHello is a PIMv2 Hello from 10.0.0.1 with holdtime 105, LAN
+// prune delay, DR priority 1, a generation ID and two secondary addresses.
+var testPacketPIMHello = []byte{
+	0x01, 0x00, 0x5e, 0x00, 0x00, 0x0d, 0x02, 0x42, 0xc0, 0xa8, 0x00, 0x01, 0x08, 0x00, 0x45, 0xc0,
+	0x00, 0x46, 0x12, 0x34, 0x00, 0x00, 0x01, 0x67, 0xbc, 0x4f, 0x0a, 0x00, 0x00, 0x01, 0xe0, 0x00,
+	0x00, 0x0d, 0x20, 0x00, 0x63, 0x1e, 0x00, 0x01, 0x00, 0x02, 0x00, 0x69, 0x00, 0x02, 0x00, 0x04,
+	0x81, 0xf4, 0x09, 0xc4, 0x00, 0x13, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x00, 0x14, 0x00, 0x04,
+	0x2e, 0x9f, 0xa8, 0xc1, 0x00, 0x18, 0x00, 0x0c, 0x01, 0x00, 0x0a, 0x00, 0x01, 0x01, 0x01, 0x00,
+	0x0a, 0x00, 0x02, 0x01,
+}
+
+// testPacketPIMJoinPrune is a PIMv2 Join/Prune to upstream neighbor
+// 10.0.0.2 joining (*,239.1.1.1) and (192.0.2.5,239.2.2.2), and pruning
+// (192.0.2.6,239.2.2.2) off the RP tree.
+var testPacketPIMJoinPrune = []byte{
+	0x01, 0x00, 0x5e, 0x00, 0x00, 0x0d, 0x02, 0x42, 0xc0, 0xa8, 0x00, 0x01, 0x08, 0x00, 0x45, 0xc0,
+	0x00, 0x52, 0x12, 0x35, 0x00, 0x00, 0x01, 0x67, 0xbc, 0x42, 0x0a, 0x00, 0x00, 0x01, 0xe0, 0x00,
+	0x00, 0x0d, 0x23, 0x00, 0x4c, 0x69, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x02, 0x00, 0x02, 0x00, 0xd2,
+	0x01, 0x00, 0x00, 0x20, 0xef, 0x01, 0x01, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x00, 0x07, 0x20,
+	0x0a, 0x00, 0x00, 0x09, 0x01, 0x00, 0x00, 0x20, 0xef, 0x02, 0x02, 0x02, 0x00, 0x01, 0x00, 0x01,
+	0x01, 0x00, 0x04, 0x20, 0xc0, 0x00, 0x02, 0x05, 0x01, 0x00, 0x05, 0x20, 0xc0, 0x00, 0x02, 0x06,
+}
+
+// testPacketPIMRegister is a PIMv2 Register from 10.0.0.1 to the RP
+// 10.0.0.2, encapsulating a UDP datagram from 192.0.2.5 to 239.1.1.1.
+var testPacketPIMRegister = []byte{
+	0x02, 0x42, 0xc0, 0xa8, 0x00, 0x02, 0x02, 0x42, 0xc0, 0xa8, 0x00, 0x01, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x3d, 0x12, 0x36, 0x00, 0x00, 0x40, 0x67, 0x54, 0x22, 0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00,
+	0x00, 0x02, 0x21, 0x00, 0xde, 0xff, 0x00, 0x00, 0x00, 0x00, 0x45, 0x00, 0x00, 0x21, 0x00, 0x42,
+	0x00, 0x00, 0x3f, 0x11, 0xc9, 0x82, 0xc0, 0x00, 0x02, 0x05, 0xef, 0x01, 0x01, 0x01, 0x13, 0x88,
+	0x13, 0x89, 0x00, 0x0d, 0xe2, 0xe8, 0x68, 0x65, 0x6c, 0x6c, 0x6f,
+}
+
+// testPacketPIMJoinPruneIPv6 is a PIMv2 Join/Prune over IPv6 to upstream
+// neighbor fe80::2 joining (2001:db8::5,ff0e::1).
+var testPacketPIMJoinPruneIPv6 = []byte{
+	0x33, 0x33, 0x00, 0x00, 0x00, 0x0d, 0x02, 0x42, 0xc0, 0xa8, 0x00, 0x01, 0x86, 0xdd, 0x60, 0x00,
+	0x00, 0x00, 0x00, 0x46, 0x67, 0x01, 0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0xff, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x0d, 0x23, 0x00, 0xa7, 0x9b, 0x02, 0x00, 0xfe, 0x80, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01, 0x00, 0xd2,
+	0x02, 0x00, 0x00, 0x80, 0xff, 0x0e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x00, 0x04, 0x80, 0x20, 0x01, 0x0d, 0xb8,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+}
+
+func TestPIMHello(t *testing.T) {
+	p := gopacket.NewPacket(testPacketPIMHello, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypePIM}, t)
+	pim := p.Layer(LayerTypePIM).(*PIM)
+	if pim.Version != 2 || pim.Type != PIMTypeHello || pim.Checksum != 0x631e {
+		t.Errorf("Got PIM version %d type %v checksum %#x", pim.Version, pim.Type, pim.Checksum)
+	}
+	h := pim.Hello
+	if h == nil {
+		t.Fatal("Hello not decoded")
+	}
+	if len(h.Options) != 5 || h.Options[1].Type != PIMHelloLANPruneDelay {
+		t.Errorf("Got options %v", h.Options)
+	}
+	if h.Holdtime != 105 || !h.HasDRPriority || h.DRPriority != 1 || h.GenerationID != 0x2e9fa8c1 {
+		t.Errorf("Got holdtime %d DR priority %v %d generation ID %#x", h.Holdtime, h.HasDRPriority, h.DRPriority, h.GenerationID)
+	}
+	if len(h.SecondaryAddresses) != 2 || !h.SecondaryAddresses[1].Address.Equal(net.IP{10, 0, 2, 1}) {
+		t.Errorf("Got secondary addresses %v", h.SecondaryAddresses)
+	}
+	if c, err := pim.ComputeChecksum(); err != nil || c != pim.Checksum {
+		t.Errorf("Computed checksum %#x, %v, want %#x", c, err, pim.Checksum)
+	}
+	testSerialization(t, p, testPacketPIMHello)
+}
+
+func TestPIMJoinPrune(t *testing.T) {
+	p := gopacket.NewPacket(testPacketPIMJoinPrune, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypePIM}, t)
+	j := p.Layer(LayerTypePIM).(*PIM).JoinPrune
+	if j == nil {
+		t.Fatal("Join/Prune not decoded")
+	}
+	if !j.UpstreamNeighbor.Address.Equal(net.IP{10, 0, 0, 2}) || j.Holdtime != 210 || len(j.Groups) != 2 {
+		t.Fatalf("Got Join/Prune %+v", j)
+	}
+	g := j.Groups[0]
+	if g.Group.String() != "239.1.1.1/32" || len(g.Joins) != 1 || len(g.Prunes) != 0 {
+		t.Errorf("Got group %+v", g)
+	} else if wc := g.Joins[0]; wc.Flags != PIMSourceSparse|PIMSourceWildcard|PIMSourceRPT || !wc.Address.Equal(net.IP{10, 0, 0, 9}) {
+		t.Errorf("Got (*,G) join of %v flags %#x, want RP 10.0.0.9 flags 0x7", wc.Address, wc.Flags)
+	}
+	g = j.Groups[1]
+	if len(g.Joins) != 1 || len(g.Prunes) != 1 {
+		t.Fatalf("Got group %+v", g)
+	}
+	if s := g.Joins[0]; s.Flags != PIMSourceSparse || s.String() != "192.0.2.5/32" {
+		t.Errorf("Got (S,G) join %v flags %#x", s, s.Flags)
+	}
+	if s := g.Prunes[0]; s.Flags != PIMSourceSparse|PIMSourceRPT || !s.Address.Equal(net.IP{192, 0, 2, 6}) {
+		t.Errorf("Got (S,G,rpt) prune %v flags %#x", s, s.Flags)
+	}
+	testSerialization(t, p, testPacketPIMJoinPrune)
+}
+
+func TestPIMRegister(t *testing.T) {
+	p := gopacket.NewPacket(testPacketPIMRegister, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypePIM, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+	pim := p.Layer(LayerTypePIM).(*PIM)
+	if pim.Register == nil || pim.Register.Flags != 0 || len(pim.Contents) != 8 {
+		t.Errorf("Got Register %+v, header %v", pim.Register, pim.Contents)
+	}
+	if c, err := pim.ComputeChecksum(); err != nil || c != pim.Checksum {
+		t.Errorf("Computed checksum %#x, %v, want %#x", c, err, pim.Checksum)
+	}
+	inner := p.Layers()[3].(*IPv4)
+	if !inner.SrcIP.Equal(net.IP{192, 0, 2, 5}) || !inner.DstIP.Equal(net.IP{239, 1, 1, 1}) {
+		t.Errorf("Got encapsulated packet %v > %v", inner.SrcIP, inner.DstIP)
+	}
+	if app := p.ApplicationLayer(); app == nil || string(app.Payload()) != "hello" {
+		t.Errorf("Got encapsulated payload %v", app)
+	}
+	// The encapsulated UDP checksum would be computed against the outer
+	// IPv4 header, so leave checksums alone.
+	testSerializationWithOpts(t, p, testPacketPIMRegister, gopacket.SerializeOptions{})
+	testSerializationWithOpts(t, p, testPacketPIMRegister, gopacket.SerializeOptions{FixLengths: true})
+
+	// Only the header of Register messages is checksummed.
+	reg := &PIM{Version: 2, Type: PIMTypeRegister, Register: &PIMRegister{}}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true}, reg, gopacket.Payload(pim.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if want := testPacketPIMRegister[34:]; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Serialized Register\n%v\nwant\n%v", buf.Bytes(), want)
+	}
+}
+
+func TestPIMJoinPruneIPv6(t *testing.T) {
+	p := gopacket.NewPacket(testPacketPIMJoinPruneIPv6, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv6, LayerTypePIM}, t)
+	pim := p.Layer(LayerTypePIM).(*PIM)
+	j := pim.JoinPrune
+	if j == nil || len(j.Groups) != 1 || len(j.Groups[0].Joins) != 1 {
+		t.Fatalf("Got Join/Prune %+v", j)
+	}
+	if j.UpstreamNeighbor.Family != PIMAddressFamilyIPv6 || !j.UpstreamNeighbor.Address.Equal(net.ParseIP("fe80::2")) {
+		t.Errorf("Got upstream neighbor %v", j.UpstreamNeighbor)
+	}
+	if g := j.Groups[0]; g.Group.String() != "ff0e::1/128" || g.Joins[0].String() != "2001:db8::5/128" {
+		t.Errorf("Got group %v join %v", g.Group, g.Joins[0])
+	}
+	pim.SetNetworkLayerForChecksum(p.NetworkLayer())
+	if c, err := pim.ComputeChecksum(); err != nil || c != pim.Checksum {
+		t.Errorf("Computed checksum %#x, %v, want %#x", c, err, pim.Checksum)
+	}
+	testSerialization(t, p, testPacketPIMJoinPruneIPv6)
+}
+
+func TestPIMMessages(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		data  []byte
+		check func(*PIM) bool
+	}{
+		{
+			name: "Assert",
+			data: []byte{
+				0x25, 0x00, 0xa6, 0x54, 0x01, 0x00, 0x00, 0x20, 0xef, 0x01, 0x01, 0x01, 0x01, 0x00, 0xc0, 0x00,
+				0x02, 0x05, 0x80, 0x00, 0x00, 0x6e, 0x00, 0x00, 0x00, 0x14,
+			},
+			check: func(p *PIM) bool {
+				a := p.Assert
+				return a != nil && a.Group.Address.Equal(net.IP{239, 1, 1, 1}) && a.Source.Address.Equal(net.IP{192, 0, 2, 5}) &&
+					a.RPT && a.MetricPreference == 110 && a.Metric == 20
+			},
+		},
+		{
+			name: "RegisterStop",
+			data: []byte{
+				0x22, 0x00, 0x29, 0xd7, 0x01, 0x00, 0x00, 0x20, 0xef, 0x01, 0x01, 0x01, 0x01, 0x00, 0xc0, 0x00,
+				0x02, 0x05,
+			},
+			check: func(p *PIM) bool {
+				s := p.RegisterStop
+				return s != nil && s.Group.MaskLength == 32 && s.Source.Address.Equal(net.IP{192, 0, 2, 5})
+			},
+		},
+		{
+			name: "Bootstrap",
+			data: []byte{
+				0x24, 0x00, 0xde, 0x56, 0x1a, 0x2b, 0x1e, 0x40, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x03, 0x01, 0x00,
+				0x00, 0x04, 0xe0, 0x00, 0x00, 0x00, 0x02, 0x02, 0x00, 0x00, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x03,
+				0x00, 0x96, 0xc0, 0x00, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x04, 0x00, 0x96, 0x00, 0x00,
+			},
+			check: func(p *PIM) bool {
+				s := p.Bootstrap
+				if s == nil || s.FragmentTag != 0x1a2b || s.HashMaskLength != 30 || s.Priority != 64 ||
+					!s.BSRAddress.Address.Equal(net.IP{10, 0, 0, 3}) || len(s.Groups) != 1 {
+					return false
+				}
+				g := s.Groups[0]
+				return g.Group.String() == "224.0.0.0/4" && g.RPCount == 2 && len(g.RPs) == 2 &&
+					g.RPs[0].Holdtime == 150 && g.RPs[0].Priority == 192 && g.RPs[1].Address.Address.Equal(net.IP{10, 0, 0, 4})
+			},
+		},
+		{
+			name: "CandidateRP",
+			data: []byte{
+				0x28, 0x00, 0x70, 0x94, 0x02, 0xc0, 0x00, 0x96, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x03, 0x01, 0x00,
+				0x00, 0x08, 0xef, 0x00, 0x00, 0x00, 0x01, 0x00, 0x80, 0x08, 0xe8, 0x00, 0x00, 0x00,
+			},
+			check: func(p *PIM) bool {
+				c := p.CandidateRP
+				return c != nil && c.Priority == 192 && c.Holdtime == 150 && c.RPAddress.Address.Equal(net.IP{10, 0, 0, 3}) &&
+					len(c.Groups) == 2 && c.Groups[0].String() == "239.0.0.0/8" && c.Groups[1].Flags == PIMGroupBidirectional
+			},
+		},
+	} {
+		p := gopacket.NewPacket(test.data, LayerTypePIM, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: failed to decode packet: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		pim := p.Layer(LayerTypePIM).(*PIM)
+		if !test.check(pim) {
+			t.Errorf("%s: got %+v", test.name, pim)
+		}
+		if c, err := pim.ComputeChecksum(); err != nil || c != pim.Checksum {
+			t.Errorf("%s: computed checksum %#x, %v, want %#x", test.name, c, err, pim.Checksum)
+		}
+		buf := gopacket.NewSerializeBuffer()
+		if err := pim.SerializeTo(buf, gopacket.SerializeOptions{ComputeChecksums: true}); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !bytes.Equal(buf.Bytes(), test.data) {
+			t.Errorf("%s: serialized\n%v\nwant\n%v", test.name, buf.Bytes(), test.data)
+		}
+	}
+}
+
+func TestPIMSerializeJoinPrune(t *testing.T) {
+	pim := &PIM{
+		Version: 2,
+		Type:    PIMTypeJoinPrune,
+		JoinPrune: &PIMJoinPrune{
+			UpstreamNeighbor: PIMEncodedAddress{Address: net.ParseIP("fe80::2")},
+			Holdtime:         210,
+			Groups: []PIMJoinPruneGroup{{
+				Group: PIMEncodedAddress{Address: net.ParseIP("ff0e::1"), MaskLength: 128},
+				Joins: []PIMEncodedAddress{{Address: net.ParseIP("2001:db8::5"), MaskLength: 128, Flags: PIMSourceSparse}},
+			}},
+		},
+	}
+	ip6 := &IPv6{SrcIP: net.ParseIP("fe80::1"), DstIP: net.ParseIP("ff02::d"), NextHeader: IPProtocolPIM}
+	pim.SetNetworkLayerForChecksum(ip6)
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true}, pim); err != nil {
+		t.Fatal(err)
+	}
+	if want := testPacketPIMJoinPruneIPv6[54:]; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Serialized Join/Prune\n%v\nwant\n%v", buf.Bytes(), want)
+	}
+
+	pim.JoinPrune.UpstreamNeighbor = PIMEncodedAddress{Family: PIMAddressFamilyIPv4, Address: net.ParseIP("fe80::2")}
+	if err := pim.SerializeTo(gopacket.NewSerializeBuffer(), gopacket.SerializeOptions{}); err == nil {
+		t.Error("Serialized IPv6 address as IPv4")
+	}
+}
+
+func TestPIMMalformed(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x20, 0x00},
+		// PIMv1
+		{0x10, 0x00, 0x00, 0x00},
+		// Hello option longer than the message
+		{0x20, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x04, 0x00, 0x69},
+		// Join/Prune with an unsupported address encoding
+		{0x23, 0x00, 0x00, 0x00, 0x01, 0x01, 0x0a, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0xd2},
+		// Join/Prune with more groups than present
+		{0x23, 0x00, 0x00, 0x00, 0x01, 0x00, 0x0a, 0x00, 0x00, 0x02, 0x00, 0x01, 0x00, 0xd2},
+		// Assert with an unknown address family
+		{0x25, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x20, 0xef, 0x01, 0x01, 0x01},
+		// Register without flags
+		{0x21, 0x00, 0x00, 0x00, 0x00, 0x00},
+	} {
+		p := gopacket.NewPacket(data, LayerTypePIM, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("Decoded malformed message %v", data)
+		}
+	}
+
+	p := gopacket.NewPacket([]byte{0x2f, 0x00, 0x00, 0x00, 0x01, 0x02}, LayerTypePIM, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypePIM, gopacket.LayerTypePayload}, t)
+}
@@ -60,7 +60,8 @@ func (a TCPPort) LayerType() gopacket.LayerType {
 }
 
 var tcpPortLayerType = [65536]gopacket.LayerType{
-	53:   LayerTypeDNS,
+	53:   LayerTypeDNSOverTCP,
+	179:  LayerTypeBGP,
 	443:  LayerTypeTLS,       // https
 	502:  LayerTypeModbusTCP, // modbustcp
 	636:  LayerTypeTLS,       // ldaps
@@ -71,6 +72,8 @@ var tcpPortLayerType = [65536]gopacket.LayerType{
 	994:  LayerTypeTLS,       // ircs
 	995:  LayerTypeTLS,       // pop3s
 	5061: LayerTypeTLS,       // ips
+	6633: LayerTypeOpenFlow,
+	6653: LayerTypeOpenFlow,
 }
 
 // RegisterTCPPortLayerType creates a new mapping between a TCPPort
@@ -79,6 +82,12 @@ func RegisterTCPPortLayerType(port TCPPort, layerType gopacket.LayerType) {
 	tcpPortLayerType[port] = layerType
 }
 
+// UnregisterTCPPortLayerType removes any mapping for the given TCPPort, so
+// that its payload is decoded as gopacket.LayerTypePayload.
+func UnregisterTCPPortLayerType(port TCPPort) {
+	tcpPortLayerType[port] = 0
+}
+
 // String returns the port as "number(name)" if there's a well-known port name,
 // or just "number" if there isn't.  Well-known names are stored in
 // UDPPortNames.
@@ -103,18 +112,34 @@ func (a UDPPort) LayerType() gopacket.LayerType {
 }
 
 var udpPortLayerType = [65536]gopacket.LayerType{
-	53:   LayerTypeDNS,
-	123:  LayerTypeNTP,
-	4789: LayerTypeVXLAN,
-	67:   LayerTypeDHCPv4,
-	68:   LayerTypeDHCPv4,
-	546:  LayerTypeDHCPv6,
-	547:  LayerTypeDHCPv6,
-	5060: LayerTypeSIP,
-	6343: LayerTypeSFlow,
-	6081: LayerTypeGeneve,
-	3784: LayerTypeBFD,
-	2152: LayerTypeGTPv1U,
+	53:    LayerTypeDNS,
+	123:   LayerTypeNTP,
+	4789:  LayerTypeVXLAN,
+	67:    LayerTypeDHCPv4,
+	68:    LayerTypeDHCPv4,
+	546:   LayerTypeDHCPv6,
+	547:   LayerTypeDHCPv6,
+	5060:  LayerTypeSIP,
+	6343:  LayerTypeSFlow,
+	6081:  LayerTypeGeneve,
+	3784:  LayerTypeBFD,
+	2152:  LayerTypeGTPv1U,
+	1812:  LayerTypeRADIUS,
+	1813:  LayerTypeRADIUS,
+	500:   LayerTypeIKE,
+	4500:  LayerTypeIPSecNATT,
+	2055:  LayerTypeNetFlowV9,
+	4739:  LayerTypeIPFIX,
+	161:   LayerTypeSNMP,
+	162:   LayerTypeSNMP,
+	514:   LayerTypeSyslog,
+	443:   LayerTypeQUIC,
+	520:   LayerTypeRIP,
+	521:   LayerTypeRIPng,
+	1985:  LayerTypeHSRP,
+	2029:  LayerTypeHSRP,
+	3222:  LayerTypeGLBP,
+	47808: LayerTypeBVLC,
 }
 
 // RegisterUDPPortLayerType creates a new mapping between a UDPPort
@@ -123,6 +148,62 @@ func RegisterUDPPortLayerType(port UDPPort, layerType gopacket.LayerType) {
 	udpPortLayerType[port] = layerType
 }
 
+// UnregisterUDPPortLayerType removes any mapping for the given UDPPort, so
+// that its payload is decoded as gopacket.LayerTypePayload.
+func UnregisterUDPPortLayerType(port UDPPort) {
+	udpPortLayerType[port] = 0
+}
+
+// UDPPortsForLayerType returns all UDPPorts currently mapped to the given
+// LayerType.
+func UDPPortsForLayerType(layerType gopacket.LayerType) (ports []UDPPort) {
+	for port, lt := range udpPortLayerType {
+		if lt == layerType {
+			ports = append(ports, UDPPort(port))
+		}
+	}
+	return
+}
+
+// PortLayerTypeValidator is a cheap sanity check run against a UDP payload
+// before it's handed to the LayerType its port is mapped to.  It should
+// return false if the payload obviously isn't of that type.
+type PortLayerTypeValidator func(payload []byte) bool
+
+var udpPortLayerTypeValidators = map[gopacket.LayerType]PortLayerTypeValidator{
+	LayerTypeVXLAN:  validVXLAN,
+	LayerTypeGeneve: validGeneve,
+	LayerTypeGTPv1U: validGTPv1U,
+	LayerTypeRTP:    validRTPPort,
+	LayerTypeRTCP:   ValidRTCP,
+	// NetFlow's port is a convention, so check the version.
+	LayerTypeNetFlowV5: validNetFlow,
+	LayerTypeNetFlowV9: validNetFlow,
+	LayerTypeIPFIX:     validNetFlow,
+}
+
+// RegisterUDPPortLayerTypeValidator sets the validator used for payloads
+// whose UDP port maps to layerType.  If the validator rejects a payload, UDP
+// falls back to decoding it as gopacket.LayerTypePayload instead of producing
+// an error layer.  Passing a nil validator removes any existing one.
+func RegisterUDPPortLayerTypeValidator(layerType gopacket.LayerType, validator PortLayerTypeValidator) {
+	if validator == nil {
+		delete(udpPortLayerTypeValidators, layerType)
+		return
+	}
+	udpPortLayerTypeValidators[layerType] = validator
+}
+
+// udpPayloadLayerType returns the LayerType mapped to port, or
+// gopacket.LayerTypePayload if there's none or its validator rejects payload.
+func udpPayloadLayerType(port UDPPort, payload []byte) gopacket.LayerType {
+	lt := port.LayerType()
+	if valid, ok := udpPortLayerTypeValidators[lt]; ok && !valid(payload) {
+		return gopacket.LayerTypePayload
+	}
+	return lt
+}
+
 // String returns the port as "number(name)" if there's a well-known port name,
 // or just "number" if there isn't.  Well-known names are stored in
 // RUDPPortNames.
@@ -35,30 +35,64 @@ func (p *PPP) LayerType() gopacket.LayerType { return LayerTypePPP }
 // LinkFlow returns PPPFlow.
 func (p *PPP) LinkFlow() gopacket.Flow { return PPPFlow }
 
-func decodePPP(data []byte, p gopacket.PacketBuilder) error {
-	ppp := &PPP{}
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (p *PPP) CanDecode() gopacket.LayerClass { return LayerTypePPP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (p *PPP) NextLayerType() gopacket.LayerType { return p.PPPType.LayerType() }
+
+// DecodeFromBytes decodes the given bytes into this layer.  It accepts frames
+// with the HDLC-like address and control fields present or compressed away
+// (ACFC), and with a 1 or 2 byte protocol field (PFC).
+func (p *PPP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	offset := 0
-	if data[0] == 0xff && data[1] == 0x03 {
+	p.HasPPTPHeader = false
+	if len(data) >= 2 && data[0] == 0xff && data[1] == 0x03 {
 		offset = 2
-		ppp.HasPPTPHeader = true
+		p.HasPPTPHeader = true
+	}
+	if offset >= len(data) {
+		df.SetTruncated()
+		return errors.New("PPP missing protocol field")
 	}
 	if data[offset]&0x1 == 0 {
+		if offset+2 > len(data) {
+			df.SetTruncated()
+			return errors.New("PPP protocol field truncated")
+		}
 		if data[offset+1]&0x1 == 0 {
 			return errors.New("PPP has invalid type")
 		}
-		ppp.PPPType = PPPType(binary.BigEndian.Uint16(data[offset : offset+2]))
-		ppp.Contents = data[offset : offset+2]
-		ppp.Payload = data[offset+2:]
+		p.PPPType = PPPType(binary.BigEndian.Uint16(data[offset : offset+2]))
+		p.Contents = data[offset : offset+2]
+		p.Payload = data[offset+2:]
 	} else {
-		ppp.PPPType = PPPType(data[offset])
-		ppp.Contents = data[offset : offset+1]
-		ppp.Payload = data[offset+1:]
+		p.PPPType = PPPType(data[offset])
+		p.Contents = data[offset : offset+1]
+		p.Payload = data[offset+1:]
+	}
+	return nil
+}
+
+func decodePPP(data []byte, p gopacket.PacketBuilder) error {
+	ppp := &PPP{}
+	if err := ppp.DecodeFromBytes(data, p); err != nil {
+		return err
 	}
 	p.AddLayer(ppp)
 	p.SetLinkLayer(ppp)
 	return p.NextDecoder(ppp.PPPType)
 }
 
+// decodePPPSerial decodes DLT_PPP_SERIAL frames, which are HDLC-like framed
+// PPP, except that some capture sources put Cisco HDLC frames in there too.
+func decodePPPSerial(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) > 0 && (data[0] == CiscoHDLCAddressUnicast || data[0] == CiscoHDLCAddressBroadcast) {
+		return decodeCiscoHDLC(data, p)
+	}
+	return decodePPP(data, p)
+}
+
 // SerializeTo writes the serialized form of this layer into the
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
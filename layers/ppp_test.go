@@ -0,0 +1,178 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPPPIPv4 is an IPv4/UDP packet, to be prefixed with PPP headers.
+var testPPPIPv4 = []byte{
+	0x45, 0x00, 0x00, 0x1c, 0x00, 0x01, 0x00, 0x00, 0x40, 0x11, 0xf9, 0x6c,
+	0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02, 0x04, 0xd2, 0x16, 0x2e, 0x00, 0x08, 0x00, 0x00,
+}
+
+func TestPacketPPPSerialCompression(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		header []byte
+		pptp   bool
+	}{
+		{"uncompressed", []byte{0xff, 0x03, 0x00, 0x21}, true},
+		{"ACFC", []byte{0x00, 0x21}, false},
+		{"PFC", []byte{0xff, 0x03, 0x21}, true},
+		{"ACFC+PFC", []byte{0x21}, false},
+	} {
+		data := append(append([]byte{}, test.header...), testPPPIPv4...)
+		p := gopacket.NewPacket(data, LinkTypePPP_HDLC, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: failed to decode packet: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypeIPv4, LayerTypeUDP}, t)
+		ppp := p.Layer(LayerTypePPP).(*PPP)
+		if ppp.PPPType != PPPTypeIPv4 || ppp.HasPPTPHeader != test.pptp {
+			t.Errorf("%s: unexpected PPP header %#v", test.name, ppp)
+		}
+	}
+}
+
+func TestPacketPPPSerialTruncated(t *testing.T) {
+	for _, data := range [][]byte{{0xff, 0x03}, {0xff, 0x03, 0x00}, {0x00}} {
+		p := gopacket.NewPacket(data, LinkTypePPP_HDLC, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("%x: expected decode error", data)
+		}
+	}
+}
+
+func TestPacketPPPSerialCiscoHDLC(t *testing.T) {
+	data := append([]byte{0x0f, 0x00, 0x08, 0x00}, testPPPIPv4...)
+	p := gopacket.NewPacket(data, LinkTypePPP_HDLC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeCiscoHDLC, LayerTypeIPv4, LayerTypeUDP}, t)
+}
+
+func TestPacketPPPLCP(t *testing.T) {
+	// LCP Configure-Request with MRU 1500 and a magic number.
+	data := []byte{
+		0xff, 0x03, 0xc0, 0x21, 0x01, 0x07, 0x00, 0x0e,
+		0x01, 0x04, 0x05, 0xdc, 0x05, 0x06, 0x12, 0x34, 0x56, 0x78,
+	}
+	p := gopacket.NewPacket(data, LinkTypePPP_HDLC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypeLCP}, t)
+	lcp := p.Layer(LayerTypeLCP).(*PPPControl)
+	want := []PPPControlOption{
+		{Type: 1, Length: 4, Data: []byte{0x05, 0xdc}},
+		{Type: 5, Length: 6, Data: []byte{0x12, 0x34, 0x56, 0x78}},
+	}
+	if lcp.Code != PPPControlCodeConfigureRequest || lcp.Identifier != 7 || !reflect.DeepEqual(want, lcp.Options) {
+		t.Errorf("LCP mismatch, \nwant %#v\ngot  %#v\n", want, lcp.Options)
+	}
+}
+
+func TestPacketPPPIPCP(t *testing.T) {
+	// Compressed IPCP Configure-Ack with an IP address option.
+	data := []byte{0x80, 0x21, 0x02, 0x01, 0x00, 0x0a, 0x03, 0x06, 0x0a, 0x00, 0x00, 0x01}
+	p := gopacket.NewPacket(data, LinkTypePPP_HDLC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypePPP, LayerTypeIPCP}, t)
+	ipcp := p.Layer(LayerTypeIPCP).(*PPPControl)
+	if ipcp.Code != PPPControlCodeConfigureAck || len(ipcp.Options) != 1 || ipcp.Options[0].Type != 3 {
+		t.Errorf("Unexpected IPCP packet %#v", ipcp)
+	}
+}
+
+func TestPacketPPPAuthentication(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data []byte
+		want gopacket.SerializableLayer
+	}{
+		{
+			"PAP request",
+			[]byte{0xc0, 0x23, 0x01, 0x02, 0x00, 0x0e, 0x04, 0x75, 0x73, 0x65, 0x72, 0x04, 0x70, 0x61, 0x73, 0x73},
+			&PPPPAP{Code: PPPPAPCodeAuthenticateRequest, Identifier: 2, Length: 14, PeerID: []byte("user"), Password: []byte("pass")},
+		},
+		{
+			"PAP ack",
+			[]byte{0xc0, 0x23, 0x02, 0x02, 0x00, 0x07, 0x02, 0x4f, 0x4b},
+			&PPPPAP{Code: PPPPAPCodeAuthenticateAck, Identifier: 2, Length: 7, Message: []byte("OK")},
+		},
+		{
+			"CHAP challenge",
+			[]byte{0xc2, 0x23, 0x01, 0x03, 0x00, 0x0d, 0x04, 0x01, 0x02, 0x03, 0x04, 0x62, 0x72, 0x61, 0x73},
+			&PPPCHAP{Code: PPPCHAPCodeChallenge, Identifier: 3, Length: 13, Value: []byte{1, 2, 3, 4}, Name: []byte("bras")},
+		},
+		{
+			"CHAP success",
+			[]byte{0xc2, 0x23, 0x03, 0x03, 0x00, 0x06, 0x4f, 0x4b},
+			&PPPCHAP{Code: PPPCHAPCodeSuccess, Identifier: 3, Length: 6, Message: []byte("OK")},
+		},
+		{
+			"IPv6CP request",
+			[]byte{0x80, 0x57, 0x01, 0x04, 0x00, 0x0e, 0x01, 0x0a, 0x02, 0x11, 0x22, 0xff, 0xfe, 0x33, 0x44, 0x55},
+			&PPPControl{Protocol: PPPTypeIPv6CP, Code: PPPControlCodeConfigureRequest, Identifier: 4, Length: 14, Options: []PPPControlOption{
+				{Type: 1, Length: 10, Data: []byte{0x02, 0x11, 0x22, 0xff, 0xfe, 0x33, 0x44, 0x55}},
+			}},
+		},
+	} {
+		p := gopacket.NewPacket(test.data, LayerTypePPP, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: failed to decode packet: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		l := p.Layers()[1]
+		if l.LayerType() != test.want.LayerType() {
+			t.Errorf("%s: got layer %v, want %v", test.name, l.LayerType(), test.want.LayerType())
+			continue
+		}
+		// Compare decoded fields only.
+		reflect.ValueOf(l).Elem().FieldByName("BaseLayer").Set(reflect.ValueOf(BaseLayer{}))
+		if !reflect.DeepEqual(l, test.want) {
+			t.Errorf("%s: mismatch\nwant %#v\ngot  %#v", test.name, test.want, l)
+		}
+
+		buf := gopacket.NewSerializeBuffer()
+		if err := test.want.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !bytes.Equal(buf.Bytes(), test.data[2:]) {
+			t.Errorf("%s: serialization mismatch\nwant %x\ngot  %x", test.name, test.data[2:], buf.Bytes())
+		}
+	}
+}
+
+func TestPPPPAPLongField(t *testing.T) {
+	want := &PPPPAP{Code: PPPPAPCodeAuthenticateRequest, Identifier: 5, PeerID: bytes.Repeat([]byte{'u'}, 255), Password: []byte("pass")}
+	buf := gopacket.NewSerializeBuffer()
+	if err := want.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	got := &PPPPAP{}
+	if err := got.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode PAP:", err)
+	}
+	if !bytes.Equal(got.PeerID, want.PeerID) || !bytes.Equal(got.Password, want.Password) {
+		t.Errorf("got PAP %+v", got)
+	}
+	data[4+256] = 0xff // password length past the packet
+	if err := got.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding overlong password")
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// PPPPAPCode is the code of a PAP packet, see RFC 1334.
+type PPPPAPCode uint8
+
+const (
+	PPPPAPCodeAuthenticateRequest PPPPAPCode = 1
+	PPPPAPCodeAuthenticateAck     PPPPAPCode = 2
+	PPPPAPCodeAuthenticateNak     PPPPAPCode = 3
+)
+
+func (c PPPPAPCode) String() string {
+	switch c {
+	case PPPPAPCodeAuthenticateRequest:
+		return "AuthenticateRequest"
+	case PPPPAPCodeAuthenticateAck:
+		return "AuthenticateAck"
+	case PPPPAPCodeAuthenticateNak:
+		return "AuthenticateNak"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// PPPPAP is a PPP Password Authentication Protocol packet.  PeerID and
+// Password are set for requests, Message for acks and naks.
+type PPPPAP struct {
+	BaseLayer
+	Code       PPPPAPCode
+	Identifier uint8
+	Length     uint16
+	PeerID     []byte
+	Password   []byte
+	Message    []byte
+}
+
+// LayerType returns LayerTypePPPPAP.
+func (a *PPPPAP) LayerType() gopacket.LayerType { return LayerTypePPPPAP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (a *PPPPAP) CanDecode() gopacket.LayerClass { return LayerTypePPPPAP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (a *PPPPAP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (a *PPPPAP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	body, err := decodePPPAuthHeader(data, df, &a.Identifier, &a.Length)
+	if err != nil {
+		return err
+	}
+	a.Code = PPPPAPCode(data[0])
+	a.PeerID, a.Password, a.Message = nil, nil, nil
+	switch a.Code {
+	case PPPPAPCodeAuthenticateRequest:
+		if a.PeerID, body, err = pppAuthField(body); err != nil {
+			return err
+		}
+		if a.Password, _, err = pppAuthField(body); err != nil {
+			return err
+		}
+	default:
+		// Some peers leave out the message entirely.
+		if len(body) == 0 {
+			break
+		}
+		if a.Message, _, err = pppAuthField(body); err != nil {
+			return err
+		}
+	}
+	a.BaseLayer = BaseLayer{Contents: data[:a.Length]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (a *PPPPAP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	var fields [][]byte
+	if a.Code == PPPPAPCodeAuthenticateRequest {
+		fields = [][]byte{a.PeerID, a.Password}
+	} else {
+		fields = [][]byte{a.Message}
+	}
+	length := 4
+	for _, f := range fields {
+		if len(f) > 0xff {
+			return fmt.Errorf("PAP field of %d bytes too long", len(f))
+		}
+		length += 1 + len(f)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(a.Code)
+	bytes[1] = a.Identifier
+	if opts.FixLengths {
+		a.Length = uint16(length)
+	}
+	binary.BigEndian.PutUint16(bytes[2:4], a.Length)
+	off := 4
+	for _, f := range fields {
+		bytes[off] = uint8(len(f))
+		copy(bytes[off+1:], f)
+		off += 1 + len(f)
+	}
+	return nil
+}
+
+// PPPCHAPCode is the code of a CHAP packet, see RFC 1994.
+type PPPCHAPCode uint8
+
+const (
+	PPPCHAPCodeChallenge PPPCHAPCode = 1
+	PPPCHAPCodeResponse  PPPCHAPCode = 2
+	PPPCHAPCodeSuccess   PPPCHAPCode = 3
+	PPPCHAPCodeFailure   PPPCHAPCode = 4
+)
+
+func (c PPPCHAPCode) String() string {
+	switch c {
+	case PPPCHAPCodeChallenge:
+		return "Challenge"
+	case PPPCHAPCodeResponse:
+		return "Response"
+	case PPPCHAPCodeSuccess:
+		return "Success"
+	case PPPCHAPCodeFailure:
+		return "Failure"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// PPPCHAP is a PPP Challenge Handshake Authentication Protocol packet.
+// Value and Name are set for challenges and responses, Message for success
+// and failure packets.
+type PPPCHAP struct {
+	BaseLayer
+	Code       PPPCHAPCode
+	Identifier uint8
+	Length     uint16
+	Value      []byte
+	Name       []byte
+	Message    []byte
+}
+
+// LayerType returns LayerTypePPPCHAP.
+func (c *PPPCHAP) LayerType() gopacket.LayerType { return LayerTypePPPCHAP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *PPPCHAP) CanDecode() gopacket.LayerClass { return LayerTypePPPCHAP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *PPPCHAP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (c *PPPCHAP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	body, err := decodePPPAuthHeader(data, df, &c.Identifier, &c.Length)
+	if err != nil {
+		return err
+	}
+	c.Code = PPPCHAPCode(data[0])
+	c.Value, c.Name, c.Message = nil, nil, nil
+	switch c.Code {
+	case PPPCHAPCodeChallenge, PPPCHAPCodeResponse:
+		if c.Value, c.Name, err = pppAuthField(body); err != nil {
+			return err
+		}
+	default:
+		c.Message = body
+	}
+	c.BaseLayer = BaseLayer{Contents: data[:c.Length]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *PPPCHAP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 4 + len(c.Message)
+	challenge := c.Code == PPPCHAPCodeChallenge || c.Code == PPPCHAPCodeResponse
+	if challenge {
+		if len(c.Value) > 0xff {
+			return fmt.Errorf("CHAP value of %d bytes too long", len(c.Value))
+		}
+		length = 5 + len(c.Value) + len(c.Name)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(c.Code)
+	bytes[1] = c.Identifier
+	if opts.FixLengths {
+		c.Length = uint16(length)
+	}
+	binary.BigEndian.PutUint16(bytes[2:4], c.Length)
+	if challenge {
+		bytes[4] = uint8(len(c.Value))
+		copy(bytes[5:], c.Value)
+		copy(bytes[5+len(c.Value):], c.Name)
+	} else {
+		copy(bytes[4:], c.Message)
+	}
+	return nil
+}
+
+// decodePPPAuthHeader decodes the code, identifier and length header shared
+// by PAP and CHAP, returning the rest of the packet up to length.
+func decodePPPAuthHeader(data []byte, df gopacket.DecodeFeedback, id *uint8, length *uint16) ([]byte, error) {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return nil, errors.New("PPP authentication packet less than 4 bytes")
+	}
+	*id = data[1]
+	*length = binary.BigEndian.Uint16(data[2:4])
+	if *length < 4 {
+		return nil, fmt.Errorf("invalid PPP authentication packet length %d", *length)
+	}
+	if int(*length) > len(data) {
+		df.SetTruncated()
+		return nil, fmt.Errorf("PPP authentication packet length %d exceeds %d bytes", *length, len(data))
+	}
+	return data[4:*length], nil
+}
+
+// pppAuthField splits a one byte length prefixed field off data.
+func pppAuthField(data []byte) (field, rest []byte, err error) {
+	if len(data) < 1 {
+		return nil, nil, errors.New("PPP authentication field truncated")
+	}
+	n := 1 + int(data[0])
+	if n > len(data) {
+		return nil, nil, errors.New("PPP authentication field truncated")
+	}
+	return data[1:n], data[n:], nil
+}
+
+func decodePPPPAP(data []byte, p gopacket.PacketBuilder) error {
+	a := &PPPPAP{}
+	return decodingLayerDecoder(a, data, p)
+}
+
+func decodePPPCHAP(data []byte, p gopacket.PacketBuilder) error {
+	c := &PPPCHAP{}
+	return decodingLayerDecoder(c, data, p)
+}
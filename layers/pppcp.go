@@ -0,0 +1,206 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// PPPControlCode is the code of a PPP control protocol packet, as shared by
+// LCP (RFC 1661) and the network control protocols such as IPCP (RFC 1332).
+type PPPControlCode uint8
+
+const (
+	PPPControlCodeConfigureRequest PPPControlCode = 1
+	PPPControlCodeConfigureAck     PPPControlCode = 2
+	PPPControlCodeConfigureNak     PPPControlCode = 3
+	PPPControlCodeConfigureReject  PPPControlCode = 4
+	PPPControlCodeTerminateRequest PPPControlCode = 5
+	PPPControlCodeTerminateAck     PPPControlCode = 6
+	PPPControlCodeCodeReject       PPPControlCode = 7
+	PPPControlCodeProtocolReject   PPPControlCode = 8
+	PPPControlCodeEchoRequest      PPPControlCode = 9
+	PPPControlCodeEchoReply        PPPControlCode = 10
+	PPPControlCodeDiscardRequest   PPPControlCode = 11
+)
+
+func (c PPPControlCode) String() string {
+	switch c {
+	case PPPControlCodeConfigureRequest:
+		return "ConfigureRequest"
+	case PPPControlCodeConfigureAck:
+		return "ConfigureAck"
+	case PPPControlCodeConfigureNak:
+		return "ConfigureNak"
+	case PPPControlCodeConfigureReject:
+		return "ConfigureReject"
+	case PPPControlCodeTerminateRequest:
+		return "TerminateRequest"
+	case PPPControlCodeTerminateAck:
+		return "TerminateAck"
+	case PPPControlCodeCodeReject:
+		return "CodeReject"
+	case PPPControlCodeProtocolReject:
+		return "ProtocolReject"
+	case PPPControlCodeEchoRequest:
+		return "EchoRequest"
+	case PPPControlCodeEchoReply:
+		return "EchoReply"
+	case PPPControlCodeDiscardRequest:
+		return "DiscardRequest"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// PPPControlOption is a configuration option of a Configure-* packet.  Its
+// type space depends on the control protocol.
+type PPPControlOption struct {
+	Type   uint8
+	Length uint8
+	Data   []byte
+}
+
+// PPPControl is a PPP control protocol packet.  It decodes LCP, IPCP and
+// IPv6CP, which share the same packet format; Protocol tells them apart.
+type PPPControl struct {
+	BaseLayer
+	Protocol   PPPType
+	Code       PPPControlCode
+	Identifier uint8
+	Length     uint16
+	// Options is set for Configure-* packets, and Data holds the rest of the
+	// packet for the other codes.
+	Options []PPPControlOption
+	Data    []byte
+}
+
+// LayerType returns LayerTypeLCP, LayerTypeIPCP or LayerTypeIPv6CP,
+// depending on Protocol.
+func (c *PPPControl) LayerType() gopacket.LayerType {
+	switch c.Protocol {
+	case PPPTypeIPCP:
+		return LayerTypeIPCP
+	case PPPTypeIPv6CP:
+		return LayerTypeIPv6CP
+	}
+	return LayerTypeLCP
+}
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (c *PPPControl) CanDecode() gopacket.LayerClass { return c.LayerType() }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (c *PPPControl) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.  Protocol must be
+// set beforehand, as the packet itself doesn't carry it.
+func (c *PPPControl) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("PPP control packet less than 4 bytes")
+	}
+	c.Code = PPPControlCode(data[0])
+	c.Identifier = data[1]
+	c.Length = binary.BigEndian.Uint16(data[2:4])
+	if c.Length < 4 {
+		return fmt.Errorf("invalid PPP control packet length %d", c.Length)
+	}
+	if int(c.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("PPP control packet length %d exceeds %d bytes", c.Length, len(data))
+	}
+	body := data[4:c.Length]
+	c.Options, c.Data = c.Options[:0], nil
+	switch c.Code {
+	case PPPControlCodeConfigureRequest, PPPControlCodeConfigureAck,
+		PPPControlCodeConfigureNak, PPPControlCodeConfigureReject:
+		for len(body) > 0 {
+			if len(body) < 2 || body[1] < 2 || int(body[1]) > len(body) {
+				return errors.New("invalid PPP control option")
+			}
+			c.Options = append(c.Options, PPPControlOption{
+				Type:   body[0],
+				Length: body[1],
+				Data:   body[2:body[1]],
+			})
+			body = body[body[1]:]
+		}
+	default:
+		c.Data = body
+	}
+	// Anything past Length is padding added by the link.
+	c.BaseLayer = BaseLayer{Contents: data[:c.Length]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (c *PPPControl) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	configure := false
+	switch c.Code {
+	case PPPControlCodeConfigureRequest, PPPControlCodeConfigureAck,
+		PPPControlCodeConfigureNak, PPPControlCodeConfigureReject:
+		configure = true
+	}
+	length := 4 + len(c.Data)
+	if configure {
+		length = 4
+		for i := range c.Options {
+			o := &c.Options[i]
+			if len(o.Data) > 0xff-2 {
+				return fmt.Errorf("PPP control option %d too long", o.Type)
+			}
+			if opts.FixLengths {
+				o.Length = uint8(2 + len(o.Data))
+			}
+			length += 2 + len(o.Data)
+		}
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(c.Code)
+	bytes[1] = c.Identifier
+	if opts.FixLengths {
+		c.Length = uint16(length)
+	}
+	binary.BigEndian.PutUint16(bytes[2:4], c.Length)
+	if !configure {
+		copy(bytes[4:], c.Data)
+		return nil
+	}
+	off := 4
+	for _, o := range c.Options {
+		bytes[off] = o.Type
+		bytes[off+1] = o.Length
+		copy(bytes[off+2:], o.Data)
+		off += 2 + len(o.Data)
+	}
+	return nil
+}
+
+func decodeLCP(data []byte, p gopacket.PacketBuilder) error {
+	c := &PPPControl{Protocol: PPPTypeLCP}
+	return decodingLayerDecoder(c, data, p)
+}
+
+func decodeIPCP(data []byte, p gopacket.PacketBuilder) error {
+	c := &PPPControl{Protocol: PPPTypeIPCP}
+	return decodingLayerDecoder(c, data, p)
+}
+
+func decodeIPv6CP(data []byte, p gopacket.PacketBuilder) error {
+	c := &PPPControl{Protocol: PPPTypeIPv6CP}
+	return decodingLayerDecoder(c, data, p)
+}
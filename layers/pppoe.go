@@ -8,9 +8,62 @@ package layers
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
+// PPPoETagType is the type of a PPPoE discovery tag, as listed in RFC 2516
+// appendix A.
+type PPPoETagType uint16
+
+const (
+	PPPoETagTypeEndOfList        PPPoETagType = 0x0000
+	PPPoETagTypeServiceName      PPPoETagType = 0x0101
+	PPPoETagTypeACName           PPPoETagType = 0x0102
+	PPPoETagTypeHostUniq         PPPoETagType = 0x0103
+	PPPoETagTypeACCookie         PPPoETagType = 0x0104
+	PPPoETagTypeVendorSpecific   PPPoETagType = 0x0105
+	PPPoETagTypeRelaySessionID   PPPoETagType = 0x0110
+	PPPoETagTypeServiceNameError PPPoETagType = 0x0201
+	PPPoETagTypeACSystemError    PPPoETagType = 0x0202
+	PPPoETagTypeGenericError     PPPoETagType = 0x0203
+)
+
+func (t PPPoETagType) String() string {
+	switch t {
+	case PPPoETagTypeEndOfList:
+		return "EndOfList"
+	case PPPoETagTypeServiceName:
+		return "ServiceName"
+	case PPPoETagTypeACName:
+		return "ACName"
+	case PPPoETagTypeHostUniq:
+		return "HostUniq"
+	case PPPoETagTypeACCookie:
+		return "ACCookie"
+	case PPPoETagTypeVendorSpecific:
+		return "VendorSpecific"
+	case PPPoETagTypeRelaySessionID:
+		return "RelaySessionID"
+	case PPPoETagTypeServiceNameError:
+		return "ServiceNameError"
+	case PPPoETagTypeACSystemError:
+		return "ACSystemError"
+	case PPPoETagTypeGenericError:
+		return "GenericError"
+	default:
+		return fmt.Sprintf("Unknown(0x%04x)", uint16(t))
+	}
+}
+
+// PPPoETag is a tag of a PPPoE discovery packet.
+type PPPoETag struct {
+	Type  PPPoETagType
+	Value []byte
+}
+
 // PPPoE is the layer for PPPoE encapsulation headers.
 type PPPoE struct {
 	BaseLayer
@@ -19,6 +72,10 @@ type PPPoE struct {
 	Code      PPPoECode
 	SessionId uint16
 	Length    uint16
+	// Tags holds the tags of discovery packets, that is every code but
+	// PPPoECodeSession.  When serializing, they are written ahead of any
+	// payload.
+	Tags []PPPoETag
 }
 
 // LayerType returns gopacket.LayerTypePPPoE.
@@ -26,17 +83,80 @@ func (p *PPPoE) LayerType() gopacket.LayerType {
 	return LayerTypePPPoE
 }
 
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (p *PPPoE) CanDecode() gopacket.LayerClass { return LayerTypePPPoE }
+
+// NextLayerType returns LayerTypePPP for session packets, and
+// gopacket.LayerTypeZero for discovery packets.
+func (p *PPPoE) NextLayerType() gopacket.LayerType {
+	if p.Code == PPPoECodeSession {
+		return LayerTypePPP
+	}
+	return gopacket.LayerTypeZero
+}
+
+// Tag returns the first tag of the given type.
+func (p *PPPoE) Tag(t PPPoETagType) (PPPoETag, bool) {
+	for _, tag := range p.Tags {
+		if tag.Type == t {
+			return tag, true
+		}
+	}
+	return PPPoETag{}, false
+}
+
+// DecodeFromBytes decodes the given bytes into this layer (see
+// http://tools.ietf.org/html/rfc2516).
+func (p *PPPoE) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 6 {
+		df.SetTruncated()
+		return errors.New("PPPoE header too short")
+	}
+	p.Version = data[0] >> 4
+	p.Type = data[0] & 0x0F
+	p.Code = PPPoECode(data[1])
+	p.SessionId = binary.BigEndian.Uint16(data[2:4])
+	p.Length = binary.BigEndian.Uint16(data[4:6])
+	if int(p.Length) > len(data)-6 {
+		df.SetTruncated()
+		return fmt.Errorf("PPPoE length %d too long", p.Length)
+	}
+	p.BaseLayer = BaseLayer{data[:6], data[6 : 6+int(p.Length)]}
+	p.Tags = p.Tags[:0]
+	if p.Code == PPPoECodeSession {
+		return nil
+	}
+	for tags := p.Payload; len(tags) > 0; {
+		if len(tags) < 4 {
+			return errors.New("PPPoE tag too short")
+		}
+		length := int(binary.BigEndian.Uint16(tags[2:4]))
+		if 4+length > len(tags) {
+			return fmt.Errorf("PPPoE tag length %d too long", length)
+		}
+		tag := PPPoETag{
+			Type:  PPPoETagType(binary.BigEndian.Uint16(tags[0:2])),
+			Value: tags[4 : 4+length],
+		}
+		p.Tags = append(p.Tags, tag)
+		if tag.Type == PPPoETagTypeEndOfList {
+			break
+		}
+		tags = tags[4+length:]
+	}
+	return nil
+}
+
 // decodePPPoE decodes the PPPoE header (see http://tools.ietf.org/html/rfc2516).
 func decodePPPoE(data []byte, p gopacket.PacketBuilder) error {
-	pppoe := &PPPoE{
-		Version:   data[0] >> 4,
-		Type:      data[0] & 0x0F,
-		Code:      PPPoECode(data[1]),
-		SessionId: binary.BigEndian.Uint16(data[2:4]),
-		Length:    binary.BigEndian.Uint16(data[4:6]),
-	}
-	pppoe.BaseLayer = BaseLayer{data[:6], data[6 : 6+pppoe.Length]}
+	pppoe := &PPPoE{}
+	if err := pppoe.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
 	p.AddLayer(pppoe)
+	if pppoe.Code != PPPoECodeSession {
+		return nil
+	}
 	return p.NextDecoder(pppoe.Code)
 }
 
@@ -44,6 +164,21 @@ func decodePPPoE(data []byte, p gopacket.PacketBuilder) error {
 // SerializationBuffer, implementing gopacket.SerializableLayer.
 // See the docs for gopacket.SerializableLayer for more info.
 func (p *PPPoE) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if p.Code != PPPoECodeSession {
+		for i := len(p.Tags) - 1; i >= 0; i-- {
+			tag := p.Tags[i]
+			if len(tag.Value) > 0xffff {
+				return fmt.Errorf("PPPoE tag %v too long", tag.Type)
+			}
+			bytes, err := b.PrependBytes(4 + len(tag.Value))
+			if err != nil {
+				return err
+			}
+			binary.BigEndian.PutUint16(bytes[0:2], uint16(tag.Type))
+			binary.BigEndian.PutUint16(bytes[2:4], uint16(len(tag.Value)))
+			copy(bytes[4:], tag.Value)
+		}
+	}
 	payload := b.Bytes()
 	bytes, err := b.PrependBytes(6)
 	if err != nil {
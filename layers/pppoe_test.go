@@ -0,0 +1,135 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketPPPoEPADI is a PPPoE Active Discovery Initiation asking for any
+// service, with a Host-Uniq tag.
+var testPacketPPPoEPADI = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+	0x88, 0x63, 0x11, 0x09, 0x00, 0x00, 0x00, 0x0c, 0x01, 0x01, 0x00, 0x00,
+	0x01, 0x03, 0x00, 0x04, 0xde, 0xad, 0xbe, 0xef,
+}
+
+func TestPacketPPPoEPADI(t *testing.T) {
+	p := gopacket.NewPacket(testPacketPPPoEPADI, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypePPPoE}, t)
+	pppoe := p.Layer(LayerTypePPPoE).(*PPPoE)
+	want := []PPPoETag{
+		{Type: PPPoETagTypeServiceName, Value: []byte{}},
+		{Type: PPPoETagTypeHostUniq, Value: []byte{0xde, 0xad, 0xbe, 0xef}},
+	}
+	if pppoe.Code != PPPoECodePADI || pppoe.Length != 12 || !reflect.DeepEqual(pppoe.Tags, want) {
+		t.Errorf("PPPoE mismatch: %+v", pppoe)
+	}
+	if tag, ok := pppoe.Tag(PPPoETagTypeHostUniq); !ok || !bytes.Equal(tag.Value, want[1].Value) {
+		t.Errorf("Host-Uniq: got %v, %v", tag, ok)
+	}
+	if _, ok := pppoe.Tag(PPPoETagTypeACName); ok {
+		t.Error("unexpected AC-Name tag")
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, &PPPoE{Version: 1, Type: 1, Code: PPPoECodePADI, Tags: want}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketPPPoEPADI[14:]) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", testPacketPPPoEPADI[14:], buf.Bytes())
+	}
+}
+
+func TestPPPoESessionBringUp(t *testing.T) {
+	client := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	ac := net.HardwareAddr{0x00, 0x66, 0x77, 0x88, 0x99, 0xaa}
+	hostUniq := PPPoETag{Type: PPPoETagTypeHostUniq, Value: []byte{0xde, 0xad, 0xbe, 0xef}}
+	build := func(layers ...gopacket.SerializableLayer) gopacket.Packet {
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true}
+		if err := gopacket.SerializeLayers(buf, opts, layers...); err != nil {
+			t.Fatal(err)
+		}
+		p := gopacket.NewPacket(buf.Bytes(), LinkTypeEthernet, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+		}
+		return p
+	}
+
+	// The access concentrator offers a session in a PADS.
+	p := build(
+		&Ethernet{SrcMAC: ac, DstMAC: client, EthernetType: EthernetTypePPPoEDiscovery},
+		&PPPoE{Version: 1, Type: 1, Code: PPPoECodePADS, SessionId: 0x11, Tags: []PPPoETag{
+			{Type: PPPoETagTypeServiceName, Value: []byte("internet")},
+			{Type: PPPoETagTypeACName, Value: []byte("bras1")},
+			hostUniq,
+		}},
+	)
+	pads := p.Layer(LayerTypePPPoE).(*PPPoE)
+	if name, ok := pads.Tag(PPPoETagTypeACName); pads.SessionId != 0x11 || !ok || string(name.Value) != "bras1" {
+		t.Errorf("PADS mismatch: %+v", pads)
+	}
+
+	// The client then authenticates over the session with CHAP.
+	p = build(
+		&Ethernet{SrcMAC: client, DstMAC: ac, EthernetType: EthernetTypePPPoESession},
+		&PPPoE{Version: 1, Type: 1, Code: PPPoECodeSession, SessionId: pads.SessionId},
+		&PPP{PPPType: PPPTypeCHAP},
+		&PPPCHAP{Code: PPPCHAPCodeResponse, Identifier: 1, Value: bytes.Repeat([]byte{0x5a}, 16), Name: []byte("user")},
+	)
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypePPPoE, LayerTypePPP, LayerTypePPPCHAP}, t)
+	if pppoe := p.Layer(LayerTypePPPoE).(*PPPoE); pppoe.Length != 2+4+1+16+4 {
+		t.Errorf("PPPoE length: got %d", pppoe.Length)
+	}
+	chap := p.Layer(LayerTypePPPCHAP).(*PPPCHAP)
+	if chap.Code != PPPCHAPCodeResponse || len(chap.Value) != 16 || string(chap.Name) != "user" {
+		t.Errorf("CHAP mismatch: %+v", chap)
+	}
+}
+
+func TestPPPoEMalformed(t *testing.T) {
+	pppoe := &PPPoE{}
+	data := testPacketPPPoEPADI[14:]
+	if err := pppoe.DecodeFromBytes(data[:4], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated header")
+	}
+	if err := pppoe.DecodeFromBytes(data[:len(data)-1], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated payload")
+	}
+	bad := append([]byte(nil), data...)
+	bad[13] = 5
+	if err := pppoe.DecodeFromBytes(bad, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding overlong tag")
+	}
+}
+
+func TestPPPoEOversizedInput(t *testing.T) {
+	// A length of 0xffff must not wrap around when the input is larger.
+	data := make([]byte, 70000)
+	data[0], data[1], data[4], data[5] = 0x11, byte(PPPoECodeSession), 0xff, 0xff
+	pppoe := &PPPoE{}
+	if err := pppoe.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if len(pppoe.Payload) != 0xffff {
+		t.Errorf("got payload of %d bytes, want %d", len(pppoe.Payload), 0xffff)
+	}
+	if err := pppoe.DecodeFromBytes(data[:0xffff+5], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated payload")
+	}
+}
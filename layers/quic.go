@@ -0,0 +1,294 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// QUICVersion is the version field of QUIC long headers.
+type QUICVersion uint32
+
+const (
+	// QUICVersionNegotiation marks Version Negotiation packets.
+	QUICVersionNegotiation QUICVersion = 0
+	QUICVersion1           QUICVersion = 1
+	QUICVersion2           QUICVersion = 0x6b3343cf
+	QUICVersionDraft29     QUICVersion = 0xff00001d
+)
+
+func (v QUICVersion) String() string {
+	switch v {
+	case QUICVersionNegotiation:
+		return "Negotiation"
+	case QUICVersion1:
+		return "1"
+	case QUICVersion2:
+		return "2"
+	case QUICVersionDraft29:
+		return "draft-29"
+	default:
+		return fmt.Sprintf("0x%08x", uint32(v))
+	}
+}
+
+// QUICPacketType is the kind of a QUIC packet.
+type QUICPacketType uint8
+
+const (
+	// QUICPacketUnknown is the type of long header packets of unknown
+	// versions, whose headers past the connection IDs can't be decoded.
+	QUICPacketUnknown QUICPacketType = iota
+	QUICPacketInitial
+	QUICPacket0RTT
+	QUICPacketHandshake
+	QUICPacketRetry
+	QUICPacketVersionNegotiation
+	QUICPacketShortHeader
+)
+
+func (t QUICPacketType) String() string {
+	switch t {
+	case QUICPacketUnknown:
+		return "Unknown"
+	case QUICPacketInitial:
+		return "Initial"
+	case QUICPacket0RTT:
+		return "0-RTT"
+	case QUICPacketHandshake:
+		return "Handshake"
+	case QUICPacketRetry:
+		return "Retry"
+	case QUICPacketVersionNegotiation:
+		return "VersionNegotiation"
+	case QUICPacketShortHeader:
+		return "ShortHeader"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// quicLongPacketTypes maps the type bits of long headers to packet types,
+// which version 2 [RFC9369] shuffled.
+var quicLongPacketTypes = map[QUICVersion][4]QUICPacketType{
+	QUICVersion1:       {QUICPacketInitial, QUICPacket0RTT, QUICPacketHandshake, QUICPacketRetry},
+	QUICVersionDraft29: {QUICPacketInitial, QUICPacket0RTT, QUICPacketHandshake, QUICPacketRetry},
+	QUICVersion2:       {QUICPacketRetry, QUICPacketInitial, QUICPacket0RTT, QUICPacketHandshake},
+}
+
+// quicMaxConnectionIDLength is the longest connection ID of the known
+// versions.
+const quicMaxConnectionIDLength = 20
+
+// QUIC is a QUIC packet [RFC9000].  A UDP datagram may carry several long
+// header packets, coalesced, each of which is decoded as its own layer.
+//
+// The packet number and payload are protected, and kept in Protected.  Only
+// Initial packets, whose keys derive from the client's first destination
+// connection ID, can be decrypted, with DecryptInitial.
+type QUIC struct {
+	BaseLayer
+	LongHeader bool
+	PacketType QUICPacketType
+	// FirstByte is as found in the packet.  For packets other than Version
+	// Negotiation and Retry ones, its low bits are header protected.
+	FirstByte byte
+	Version   QUICVersion
+	DCID      []byte
+	// SCID is only set in long headers.
+	SCID []byte
+	// Token is set for Initial and Retry packets.
+	Token []byte
+	// Length is the length of the packet number and payload of Initial,
+	// 0-RTT and Handshake packets.
+	Length uint64
+	// Protected is the protected packet number and payload.  For short
+	// header packets, it's all that follows the DCID.
+	Protected []byte
+	// SupportedVersions is set for Version Negotiation packets.
+	SupportedVersions []QUICVersion
+	// RetryIntegrityTag is set for Retry packets.
+	RetryIntegrityTag []byte
+
+	// ShortHeaderDCIDLength is the length of the connection IDs of short
+	// header packets, which these packets don't carry.  It must be set
+	// before decoding, to the length the endpoint receiving the packets
+	// chose; it defaults to 0.
+	ShortHeaderDCIDLength int
+}
+
+// LayerType returns LayerTypeQUIC.
+func (q *QUIC) LayerType() gopacket.LayerType { return LayerTypeQUIC }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (q *QUIC) CanDecode() gopacket.LayerClass { return LayerTypeQUIC }
+
+// NextLayerType returns LayerTypeQUIC if more packets are coalesced in the
+// datagram after this one.  Bytes that can't start a packet, such as the
+// zeros some endpoints pad datagrams with, are returned as payload.
+func (q *QUIC) NextLayerType() gopacket.LayerType {
+	switch next := q.BaseLayer.Payload; {
+	case len(next) == 0:
+		return gopacket.LayerTypeZero
+	case next[0]&0xc0 == 0:
+		return gopacket.LayerTypePayload
+	default:
+		return LayerTypeQUIC
+	}
+}
+
+// Payload returns the protected packet number and payload, making QUIC an
+// ApplicationLayer.
+func (q *QUIC) Payload() []byte { return q.Protected }
+
+var errQUICTruncated = errors.New("QUIC packet truncated")
+
+// quicVarint decodes the variable-length integer at the start of data,
+// returning its value and length.
+func quicVarint(data []byte) (uint64, int, error) {
+	if len(data) == 0 {
+		return 0, 0, errQUICTruncated
+	}
+	n := 1 << (data[0] >> 6)
+	if len(data) < n {
+		return 0, 0, errQUICTruncated
+	}
+	v := uint64(data[0] & 0x3f)
+	for _, b := range data[1:n] {
+		v = v<<8 | uint64(b)
+	}
+	return v, n, nil
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (q *QUIC) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	*q = QUIC{ShortHeaderDCIDLength: q.ShortHeaderDCIDLength}
+	if len(data) < 1 {
+		df.SetTruncated()
+		return errQUICTruncated
+	}
+	q.FirstByte = data[0]
+	if data[0]&0x80 == 0 {
+		n := 1 + q.ShortHeaderDCIDLength
+		if len(data) < n {
+			df.SetTruncated()
+			return errQUICTruncated
+		}
+		q.PacketType = QUICPacketShortHeader
+		q.DCID = data[1:n]
+		q.Protected = data[n:]
+		q.BaseLayer = BaseLayer{Contents: data}
+		return nil
+	}
+
+	q.LongHeader = true
+	if len(data) < 6 {
+		df.SetTruncated()
+		return errQUICTruncated
+	}
+	q.Version = QUICVersion(binary.BigEndian.Uint32(data[1:5]))
+	offset := 5
+	for _, cid := range []*[]byte{&q.DCID, &q.SCID} {
+		if offset >= len(data) {
+			df.SetTruncated()
+			return errQUICTruncated
+		}
+		l := int(data[offset])
+		offset++
+		if offset+l > len(data) {
+			df.SetTruncated()
+			return errQUICTruncated
+		}
+		*cid = data[offset : offset+l]
+		offset += l
+	}
+	rest := data[offset:]
+
+	if q.Version == QUICVersionNegotiation {
+		if len(rest)%4 != 0 {
+			return fmt.Errorf("QUIC version negotiation of %d bytes not a multiple of 4", len(rest))
+		}
+		q.PacketType = QUICPacketVersionNegotiation
+		for ; len(rest) > 0; rest = rest[4:] {
+			q.SupportedVersions = append(q.SupportedVersions, QUICVersion(binary.BigEndian.Uint32(rest)))
+		}
+		q.BaseLayer = BaseLayer{Contents: data}
+		return nil
+	}
+	types, ok := quicLongPacketTypes[q.Version]
+	if !ok {
+		// Only the invariants [RFC8999] hold for unknown versions.
+		q.PacketType = QUICPacketUnknown
+		q.Protected = rest
+		q.BaseLayer = BaseLayer{Contents: data}
+		return nil
+	}
+	if len(q.DCID) > quicMaxConnectionIDLength || len(q.SCID) > quicMaxConnectionIDLength {
+		return errors.New("QUIC connection ID too long")
+	}
+
+	q.PacketType = types[(data[0]>>4)&3]
+	switch q.PacketType {
+	case QUICPacketRetry:
+		if len(rest) < 16 {
+			df.SetTruncated()
+			return errQUICTruncated
+		}
+		q.Token = rest[:len(rest)-16]
+		q.RetryIntegrityTag = rest[len(rest)-16:]
+		q.BaseLayer = BaseLayer{Contents: data}
+		return nil
+	case QUICPacketInitial:
+		tl, n, err := quicVarint(rest)
+		if err != nil {
+			df.SetTruncated()
+			return err
+		}
+		if tl > uint64(len(rest)-n) {
+			df.SetTruncated()
+			return errQUICTruncated
+		}
+		q.Token = rest[n : n+int(tl)]
+		offset += n + int(tl)
+	}
+	var n int
+	var err error
+	if q.Length, n, err = quicVarint(data[offset:]); err != nil {
+		df.SetTruncated()
+		return err
+	}
+	offset += n
+	if q.Length > uint64(len(data)-offset) {
+		df.SetTruncated()
+		return errQUICTruncated
+	}
+	end := offset + int(q.Length)
+	q.Protected = data[offset:end]
+	q.BaseLayer = BaseLayer{Contents: data[:end], Payload: data[end:]}
+	return nil
+}
+
+func decodeQUIC(data []byte, p gopacket.PacketBuilder) error {
+	q := &QUIC{}
+	if err := q.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(q)
+	p.SetApplicationLayer(q)
+	// Calling NextLayerType through an interface keeps it from making an
+	// initialization cycle with LayerTypeQUIC.
+	var d layerDecodingLayer = q
+	next := d.NextLayerType()
+	if next == gopacket.LayerTypeZero {
+		return nil
+	}
+	return p.NextDecoder(next)
+}
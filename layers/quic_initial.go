@@ -0,0 +1,334 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// quicInitialSalts are the salts Initial secrets are derived with, per
+// version [RFC9001 section 5.2, RFC9369 section 3.3.1].
+var quicInitialSalts = map[QUICVersion][]byte{
+	QUICVersion1: {
+		0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17,
+		0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a,
+	},
+	QUICVersion2: {
+		0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93,
+		0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9,
+	},
+	QUICVersionDraft29: {
+		0xaf, 0xbf, 0xec, 0x28, 0x99, 0x93, 0xd2, 0x4c, 0x9e, 0x97,
+		0x86, 0xf1, 0x9c, 0x61, 0x11, 0xe0, 0x43, 0x90, 0xa8, 0x99,
+	},
+}
+
+// quicInitialKeys are the packet protection keys of Initial packets sent by
+// one endpoint.
+type quicInitialKeys struct {
+	key, iv, hp []byte
+}
+
+// hkdfExtract is HKDF-Extract [RFC5869] with SHA-256.
+func hkdfExtract(salt, ikm []byte) []byte {
+	h := hmac.New(sha256.New, salt)
+	h.Write(ikm)
+	return h.Sum(nil)
+}
+
+// hkdfExpandLabel is HKDF-Expand-Label of TLS 1.3 [RFC8446 section 7.1] with
+// SHA-256 and an empty context.
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+	label = "tls13 " + label
+	info := make([]byte, 0, 4+len(label))
+	info = append(info, byte(length>>8), byte(length), byte(len(label)))
+	info = append(info, label...)
+	info = append(info, 0)
+	var out, t []byte
+	for i := byte(1); len(out) < length; i++ {
+		h := hmac.New(sha256.New, secret)
+		h.Write(t)
+		h.Write(info)
+		h.Write([]byte{i})
+		t = h.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length]
+}
+
+// newQUICInitialKeys derives the keys protecting the Initial packets of a
+// connection, from the destination connection ID of the client's first
+// Initial packet.
+func newQUICInitialKeys(v QUICVersion, clientDCID []byte, fromServer bool) (quicInitialKeys, error) {
+	salt, ok := quicInitialSalts[v]
+	if !ok {
+		return quicInitialKeys{}, fmt.Errorf("QUIC version %v Initial salt unknown", v)
+	}
+	label := "client in"
+	if fromServer {
+		label = "server in"
+	}
+	secret := hkdfExpandLabel(hkdfExtract(salt, clientDCID), label, sha256.Size)
+	prefix := "quic "
+	if v == QUICVersion2 {
+		prefix = "quicv2 "
+	}
+	return quicInitialKeys{
+		key: hkdfExpandLabel(secret, prefix+"key", 16),
+		iv:  hkdfExpandLabel(secret, prefix+"iv", 12),
+		hp:  hkdfExpandLabel(secret, prefix+"hp", 16),
+	}, nil
+}
+
+// QUICInitialPacket is the decrypted content of an Initial packet.
+type QUICInitialPacket struct {
+	// PacketNumberLength and PacketNumber are as found in the header once
+	// header protection is removed.  PacketNumber is truncated to
+	// PacketNumberLength bytes.
+	PacketNumberLength int
+	PacketNumber       uint32
+	// Plaintext is the decrypted payload, which Frames are decoded from.
+	Plaintext []byte
+	Frames    []QUICFrame
+}
+
+// DecryptInitial removes the header protection of an Initial packet and
+// decrypts its payload [RFC9001 section 5].  clientDCID is the destination
+// connection ID of the first Initial packet the client sent, which is the
+// DCID of the packet itself for packets from the client until it gets a
+// Retry.  fromServer selects the keys the server protects its packets with.
+func (q *QUIC) DecryptInitial(clientDCID []byte, fromServer bool) (*QUICInitialPacket, error) {
+	if q.PacketType != QUICPacketInitial {
+		return nil, fmt.Errorf("QUIC %v packet not an Initial packet", q.PacketType)
+	}
+	keys, err := newQUICInitialKeys(q.Version, clientDCID, fromServer)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sample starts 4 bytes past the start of the packet number,
+	// whatever its length.
+	if len(q.Protected) < 4+aes.BlockSize {
+		return nil, errors.New("QUIC Initial packet too short for header protection sample")
+	}
+	hp, err := aes.NewCipher(keys.hp)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]byte, aes.BlockSize)
+	hp.Encrypt(mask, q.Protected[4:4+aes.BlockSize])
+
+	headerLength := len(q.Contents) - len(q.Protected)
+	header := make([]byte, headerLength, headerLength+4)
+	copy(header, q.Contents)
+	header[0] ^= mask[0] & 0x0f
+	p := &QUICInitialPacket{PacketNumberLength: int(header[0]&3) + 1}
+	for i := 0; i < p.PacketNumberLength; i++ {
+		b := q.Protected[i] ^ mask[1+i]
+		header = append(header, b)
+		p.PacketNumber = p.PacketNumber<<8 | uint32(b)
+	}
+
+	block, err := aes.NewCipher(keys.key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, len(keys.iv))
+	copy(nonce, keys.iv)
+	var pn [8]byte
+	binary.BigEndian.PutUint64(pn[:], uint64(p.PacketNumber))
+	for i := range pn {
+		nonce[len(nonce)-8+i] ^= pn[i]
+	}
+	if p.Plaintext, err = aead.Open(nil, nonce, q.Protected[p.PacketNumberLength:], header); err != nil {
+		return nil, fmt.Errorf("QUIC Initial packet decryption failed: %v", err)
+	}
+	p.Frames, err = decodeQUICFrames(p.Plaintext)
+	return p, err
+}
+
+// QUICFrameType is the type of a QUIC frame.
+type QUICFrameType uint64
+
+// The frame types that may be found in Initial packets.
+const (
+	QUICFramePadding         QUICFrameType = 0x00
+	QUICFramePing            QUICFrameType = 0x01
+	QUICFrameACK             QUICFrameType = 0x02
+	QUICFrameACKECN          QUICFrameType = 0x03
+	QUICFrameCrypto          QUICFrameType = 0x06
+	QUICFrameConnectionClose QUICFrameType = 0x1c
+)
+
+func (t QUICFrameType) String() string {
+	switch t {
+	case QUICFramePadding:
+		return "PADDING"
+	case QUICFramePing:
+		return "PING"
+	case QUICFrameACK:
+		return "ACK"
+	case QUICFrameACKECN:
+		return "ACK_ECN"
+	case QUICFrameCrypto:
+		return "CRYPTO"
+	case QUICFrameConnectionClose:
+		return "CONNECTION_CLOSE"
+	default:
+		return fmt.Sprintf("Unknown(0x%x)", uint64(t))
+	}
+}
+
+// QUICFrame is a frame of an Initial packet.  Runs of PADDING frames are
+// skipped.
+type QUICFrame struct {
+	Type QUICFrameType
+	// Offset and Data are set for CRYPTO frames.
+	Offset uint64
+	Data   []byte
+	// LargestAcknowledged and ACKDelay are set for ACK frames, whose
+	// ranges and ECN counts aren't kept.
+	LargestAcknowledged uint64
+	ACKDelay            uint64
+	// ErrorCode, FrameType and ReasonPhrase are set for CONNECTION_CLOSE
+	// frames.
+	ErrorCode    uint64
+	FrameType    QUICFrameType
+	ReasonPhrase []byte
+}
+
+// quicVarints decodes len(vs) consecutive variable-length integers at the
+// start of data, returning what follows them.
+func quicVarints(data []byte, vs ...*uint64) ([]byte, error) {
+	for _, v := range vs {
+		var n int
+		var err error
+		if *v, n, err = quicVarint(data); err != nil {
+			return nil, err
+		}
+		data = data[n:]
+	}
+	return data, nil
+}
+
+// quicBytes splits off the n bytes at the start of data.
+func quicBytes(data []byte, n uint64) ([]byte, []byte, error) {
+	if n > uint64(len(data)) {
+		return nil, nil, errQUICTruncated
+	}
+	return data[:n], data[n:], nil
+}
+
+// decodeQUICFrames decodes the frames allowed in Initial packets.
+func decodeQUICFrames(data []byte) ([]QUICFrame, error) {
+	var frames []QUICFrame
+	for len(data) > 0 {
+		if data[0] == byte(QUICFramePadding) {
+			data = data[1:]
+			continue
+		}
+		var t uint64
+		var err error
+		if data, err = quicVarints(data, &t); err != nil {
+			return frames, err
+		}
+		f := QUICFrame{Type: QUICFrameType(t)}
+		switch f.Type {
+		case QUICFramePing:
+		case QUICFrameACK, QUICFrameACKECN:
+			var count, first uint64
+			if data, err = quicVarints(data, &f.LargestAcknowledged, &f.ACKDelay, &count, &first); err != nil {
+				return frames, err
+			}
+			// Each range is a gap and a length.
+			if count > uint64(len(data)) {
+				return frames, errQUICTruncated
+			}
+			var gap, length uint64
+			for i := uint64(0); i < count; i++ {
+				if data, err = quicVarints(data, &gap, &length); err != nil {
+					return frames, err
+				}
+			}
+			if f.Type == QUICFrameACKECN {
+				var ect0, ect1, ce uint64
+				if data, err = quicVarints(data, &ect0, &ect1, &ce); err != nil {
+					return frames, err
+				}
+			}
+		case QUICFrameCrypto:
+			var length uint64
+			if data, err = quicVarints(data, &f.Offset, &length); err != nil {
+				return frames, err
+			}
+			if f.Data, data, err = quicBytes(data, length); err != nil {
+				return frames, err
+			}
+		case QUICFrameConnectionClose:
+			var ft, length uint64
+			if data, err = quicVarints(data, &f.ErrorCode, &ft, &length); err != nil {
+				return frames, err
+			}
+			f.FrameType = QUICFrameType(ft)
+			if f.ReasonPhrase, data, err = quicBytes(data, length); err != nil {
+				return frames, err
+			}
+		default:
+			return frames, fmt.Errorf("QUIC frame type %v not allowed in Initial packets", f.Type)
+		}
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// QUICClientHello reassembles the TLS ClientHello carried by the CRYPTO
+// frames of a client's Initial packets, and decodes it.  Large ClientHellos
+// span several packets, whose frames must all be passed, in any order.
+func QUICClientHello(frames []QUICFrame) (*TLSClientHello, error) {
+	var crypto []QUICFrame
+	for _, f := range frames {
+		if f.Type == QUICFrameCrypto {
+			crypto = append(crypto, f)
+		}
+	}
+	sort.Slice(crypto, func(i, j int) bool { return crypto[i].Offset < crypto[j].Offset })
+	var data []byte
+	for _, f := range crypto {
+		if f.Offset > uint64(len(data)) {
+			break
+		}
+		if end := f.Offset + uint64(len(f.Data)); end > uint64(len(data)) {
+			data = append(data, f.Data[uint64(len(data))-f.Offset:]...)
+		}
+	}
+	if len(data) < 4 {
+		return nil, errors.New("QUIC CRYPTO frames missing ClientHello header")
+	}
+	if TLSHandshakeType(data[0]) != TLSHandshakeClientHello {
+		return nil, fmt.Errorf("QUIC CRYPTO frames start with %v, not a ClientHello", TLSHandshakeType(data[0]))
+	}
+	length := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+	if len(data)-4 < length {
+		return nil, fmt.Errorf("QUIC ClientHello truncated, have %d of %d bytes", len(data)-4, length)
+	}
+	c := &TLSClientHello{}
+	if err := c.decodeFromBytes(data[4 : 4+length]); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,366 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketQUICInitial is a client's first QUIC v1 Initial packet, padded
+// to 1200 bytes, whose ClientHello for example.com is split in two CRYPTO
+// frames sent out of order, with a PING frame in between.
+var testPacketQUICInitial = []byte{
+	0x02, 0x42, 0xc0, 0x00, 0x02, 0x01, 0x02, 0x42, 0xc0, 0x00, 0x02, 0x0a, 0x08, 0x00, 0x45, 0x00,
+	0x04, 0xcc, 0x5c, 0x1e, 0x40, 0x00, 0x40, 0x11, 0xed, 0xbd, 0xc0, 0x00, 0x02, 0x0a, 0xc6, 0x33,
+	0x64, 0x07, 0xc8, 0x22, 0x01, 0xbb, 0x04, 0xb8, 0xcb, 0x3f, 0xc6, 0x00, 0x00, 0x00, 0x01, 0x08,
+	0x8b, 0x0c, 0x5a, 0x3d, 0x11, 0x2e, 0x9f, 0x47, 0x04, 0xc1, 0x5e, 0x0a, 0x77, 0x00, 0x44, 0x9a,
+	0xbd, 0xa5, 0xa7, 0x1d, 0x4e, 0xd8, 0x22, 0xb4, 0x3b, 0x21, 0x91, 0x65, 0xa7, 0xb1, 0xc9, 0xaf,
+	0xf0, 0xd4, 0x52, 0x00, 0xd7, 0x6b, 0x97, 0xeb, 0xed, 0xc2, 0x18, 0x22, 0x80, 0x65, 0xa8, 0x7c,
+	0x7e, 0x78, 0x44, 0xcc, 0x39, 0x8b, 0x3d, 0xfd, 0x30, 0x89, 0xdd, 0x22, 0xb6, 0xc3, 0xfe, 0x2f,
+	0xb4, 0xc2, 0x37, 0x17, 0x0e, 0x44, 0x02, 0x5e, 0x64, 0xab, 0x1e, 0x1c, 0xb4, 0xee, 0xf5, 0x6a,
+	0xf5, 0xc9, 0xb3, 0x8c, 0xe1, 0x02, 0xc1, 0x2d, 0x94, 0x97, 0x8d, 0x3b, 0x56, 0x5b, 0x39, 0x43,
+	0xcf, 0x21, 0x36, 0x1f, 0x8d, 0xf7, 0x76, 0x45, 0x6b, 0x81, 0x87, 0x8a, 0x6d, 0x7b, 0x5e, 0xf6,
+	0x19, 0xa3, 0xef, 0x7b, 0x35, 0xc2, 0x3a, 0xf5, 0x76, 0x6d, 0x41, 0xc8, 0x98, 0xd6, 0x06, 0xee,
+	0x37, 0x54, 0x7a, 0xc7, 0xbe, 0x6e, 0x82, 0x3d, 0x39, 0xc0, 0x2c, 0x56, 0x60, 0xc5, 0xb8, 0x0b,
+	0x89, 0xc3, 0x12, 0x47, 0x91, 0xdb, 0xff, 0x3a, 0x9e, 0xb0, 0xfb, 0x36, 0x97, 0xc5, 0x77, 0x51,
+	0xb2, 0x42, 0x97, 0x54, 0xca, 0x55, 0x28, 0x5c, 0xf4, 0x04, 0xe1, 0x0e, 0x86, 0x77, 0xd5, 0x5b,
+	0xe5, 0xc7, 0xf8, 0x26, 0x7b, 0xe4, 0xc9, 0x1e, 0xc6, 0xfe, 0x01, 0x6d, 0xb8, 0x8a, 0xbb, 0x31,
+	0x33, 0xe8, 0x5e, 0x85, 0xb7, 0xb2, 0xff, 0xf0, 0xe0, 0x75, 0x1d, 0xf7, 0x20, 0x11, 0x26, 0x9d,
+	0x67, 0x5d, 0x29, 0x50, 0x00, 0xbf, 0xb9, 0x75, 0x6f, 0xcd, 0x23, 0x52, 0x42, 0xa1, 0x56, 0x40,
+	0xad, 0xe8, 0x4e, 0xaf, 0xc8, 0xbd, 0x7d, 0x4f, 0x8b, 0x85, 0x8b, 0xd0, 0x46, 0xe0, 0x69, 0x89,
+	0xb7, 0x2e, 0x9d, 0x67, 0xad, 0x5d, 0x79, 0xb5, 0x6e, 0xe6, 0x6d, 0xc1, 0x26, 0xed, 0x4d, 0x60,
+	0x35, 0x44, 0x3c, 0xc8, 0xac, 0xff, 0x05, 0xa4, 0xba, 0xbc, 0x98, 0x7f, 0xc1, 0x43, 0x1f, 0xe3,
+	0xa7, 0x4d, 0xe1, 0x06, 0x49, 0xaf, 0x25, 0xc6, 0xc8, 0x5b, 0xbe, 0x86, 0xe0, 0xfd, 0x11, 0x51,
+	0xc3, 0xb8, 0x61, 0x9d, 0xc6, 0x16, 0xed, 0xbb, 0x39, 0xe0, 0xa2, 0xda, 0x27, 0x48, 0x14, 0xcd,
+	0x9e, 0x61, 0x67, 0x98, 0xbc, 0x15, 0x7d, 0xea, 0xcd, 0xc8, 0xe2, 0x78, 0xdf, 0x1f, 0x81, 0xa1,
+	0x4c, 0x67, 0xc6, 0x9e, 0xc2, 0x02, 0x9a, 0xbc, 0xcc, 0x9c, 0x51, 0xdd, 0xda, 0xe2, 0xb5, 0xc2,
+	0x07, 0x0c, 0x50, 0x5f, 0x77, 0x6f, 0x3c, 0x93, 0x94, 0x64, 0x48, 0x32, 0x12, 0xfd, 0xf2, 0x24,
+	0x73, 0xcb, 0xf4, 0xc2, 0x03, 0xb0, 0x85, 0x9e, 0x43, 0x73, 0x0f, 0x5b, 0xf8, 0x88, 0x44, 0x75,
+	0x15, 0x6f, 0x69, 0xe2, 0x04, 0x75, 0x56, 0x82, 0xf4, 0x21, 0x08, 0x8b, 0xc0, 0x4a, 0x66, 0xd5,
+	0x4c, 0xd6, 0x8a, 0xf7, 0x43, 0x30, 0x6d, 0x55, 0xe8, 0x3a, 0xc0, 0x58, 0xd0, 0x57, 0x93, 0x74,
+	0xd3, 0x13, 0xc8, 0xa2, 0x2b, 0xdd, 0xdc, 0xc7, 0x53, 0x68, 0x7e, 0x6c, 0x36, 0x01, 0x89, 0xee,
+	0xe3, 0xcd, 0x6e, 0x10, 0x61, 0xb4, 0xa9, 0xc3, 0x44, 0x73, 0x14, 0x09, 0xf9, 0x71, 0x56, 0xaf,
+	0x34, 0xa6, 0x04, 0xfa, 0x1a, 0x54, 0xb9, 0xd2, 0xc3, 0x70, 0x51, 0x27, 0xa5, 0x31, 0x67, 0x36,
+	0x0a, 0xbf, 0x6f, 0x1e, 0xee, 0x90, 0xb8, 0x38, 0xe6, 0x6e, 0x1e, 0x14, 0xd8, 0x46, 0x5c, 0x63,
+	0x6d, 0x7b, 0xbd, 0x8f, 0x31, 0x32, 0xb4, 0x5e, 0xaf, 0x55, 0x1e, 0x94, 0x20, 0x35, 0x17, 0x40,
+	0x79, 0xd4, 0x70, 0x32, 0xdf, 0x47, 0x4f, 0xf2, 0x25, 0xaf, 0x40, 0x3c, 0x56, 0xc6, 0x42, 0x21,
+	0x40, 0x4f, 0xcc, 0x62, 0x44, 0xc7, 0xdc, 0xe1, 0xd0, 0x11, 0xe4, 0xb5, 0x8a, 0x22, 0x6a, 0x9a,
+	0x1a, 0x81, 0x94, 0xfc, 0x5d, 0x86, 0xb3, 0x70, 0x64, 0x4c, 0x18, 0xeb, 0x11, 0xe6, 0x3d, 0x3a,
+	0xb3, 0x19, 0x35, 0x1d, 0x31, 0xf4, 0xbc, 0x09, 0x0a, 0x8b, 0x64, 0x15, 0xe7, 0x92, 0xc2, 0x97,
+	0x39, 0xb5, 0x8e, 0x9e, 0x0b, 0x00, 0xd0, 0x60, 0xc4, 0x71, 0x13, 0x78, 0x5b, 0x43, 0x70, 0x31,
+	0x36, 0xa7, 0x92, 0x62, 0x0e, 0xdf, 0x6d, 0xf2, 0xcf, 0x9f, 0x62, 0xf7, 0x47, 0x0a, 0xa4, 0xe2,
+	0xdd, 0xfc, 0x37, 0x6e, 0x4d, 0x8a, 0x46, 0x53, 0x6a, 0xe7, 0x09, 0x17, 0xd1, 0xc7, 0xe1, 0xd8,
+	0xae, 0x0c, 0x18, 0xd3, 0x96, 0x35, 0x16, 0x7d, 0x37, 0x74, 0x81, 0xe8, 0xb0, 0x3e, 0xcf, 0xa8,
+	0xf9, 0xa9, 0x61, 0xae, 0x06, 0x4c, 0x87, 0xed, 0xe5, 0x2c, 0x5b, 0x01, 0xd2, 0xd8, 0xdb, 0x18,
+	0x36, 0x96, 0xae, 0xc2, 0xad, 0xa3, 0x84, 0xfc, 0x90, 0x57, 0x7a, 0x0a, 0x9f, 0x70, 0xc9, 0xef,
+	0xc6, 0x2e, 0xbc, 0xb9, 0x01, 0x7a, 0xd1, 0x20, 0x97, 0xa1, 0x8e, 0x11, 0xdd, 0x0c, 0x37, 0xbd,
+	0x3a, 0x65, 0xb6, 0x3f, 0x0a, 0x47, 0x2f, 0xd1, 0xc9, 0x75, 0x9e, 0x14, 0xbb, 0x4c, 0x91, 0x41,
+	0xd4, 0xfd, 0xbe, 0x66, 0x2c, 0xa5, 0x0a, 0xa8, 0xbd, 0x8e, 0xb0, 0x2f, 0x41, 0x83, 0x62, 0xef,
+	0x33, 0x10, 0x19, 0xdb, 0x73, 0xfb, 0xa1, 0x77, 0x63, 0xf8, 0x61, 0x76, 0x51, 0x71, 0x0b, 0x9e,
+	0x7b, 0xd4, 0x8b, 0xf3, 0xa3, 0x3a, 0x0d, 0x8b, 0x2a, 0xd0, 0x50, 0xcb, 0xc1, 0x3e, 0xca, 0xae,
+	0xbd, 0xea, 0x44, 0x0e, 0x1d, 0xf7, 0xdd, 0xd2, 0xe9, 0x0b, 0xca, 0xda, 0x9e, 0x71, 0x13, 0x10,
+	0x0a, 0x19, 0x21, 0x18, 0xd3, 0x1a, 0x29, 0x47, 0x31, 0x05, 0x7e, 0x10, 0x2e, 0x81, 0xe0, 0x7a,
+	0x55, 0xd4, 0x8b, 0x15, 0x71, 0xb9, 0x9c, 0xaa, 0xa5, 0xce, 0xa0, 0x03, 0xbe, 0xe4, 0x8b, 0x5b,
+	0xa8, 0x28, 0x34, 0xfe, 0x63, 0xab, 0x71, 0x42, 0x29, 0xfa, 0x44, 0xd9, 0xa6, 0x63, 0x6f, 0x47,
+	0x17, 0x76, 0xcb, 0xb1, 0xfb, 0x2d, 0x2c, 0x2b, 0xc1, 0x94, 0x09, 0xab, 0xf6, 0x34, 0x70, 0x61,
+	0xbb, 0xea, 0x79, 0x44, 0xf7, 0x00, 0x91, 0xa0, 0x5d, 0xf9, 0xd5, 0xb9, 0x37, 0x6c, 0x84, 0x6e,
+	0x10, 0xbe, 0x92, 0x5a, 0x60, 0xc1, 0x66, 0xb6, 0x7c, 0xb3, 0x9e, 0xfe, 0x61, 0xc0, 0xfa, 0xa8,
+	0xbd, 0x6a, 0x71, 0x92, 0x6b, 0xa4, 0x9b, 0x3d, 0x43, 0x07, 0xc0, 0x04, 0x3b, 0xa9, 0xcb, 0x85,
+	0x9b, 0x01, 0x62, 0xb9, 0x6d, 0x01, 0x2f, 0x84, 0x83, 0xd4, 0x56, 0x82, 0x41, 0xaa, 0x75, 0xcf,
+	0x7e, 0x0b, 0xc5, 0x7f, 0xd7, 0x01, 0x92, 0xb6, 0x52, 0xfe, 0x6e, 0xbd, 0x6f, 0x57, 0xae, 0x35,
+	0x0c, 0x47, 0xac, 0x0d, 0x5c, 0xc6, 0x30, 0x96, 0x06, 0xb3, 0x63, 0x8b, 0x03, 0x70, 0x38, 0x2d,
+	0xe3, 0x74, 0x12, 0x4e, 0x52, 0x76, 0x34, 0x7b, 0x86, 0x9e, 0xf2, 0x3c, 0x35, 0xfd, 0xb3, 0x50,
+	0xb5, 0x8a, 0x8d, 0x24, 0xb1, 0xf7, 0x50, 0x32, 0xaf, 0x07, 0x02, 0xc5, 0xce, 0x9d, 0xe1, 0xe6,
+	0xea, 0xa2, 0x81, 0x64, 0x95, 0xd8, 0x10, 0x9c, 0x07, 0xe4, 0x9c, 0x6b, 0xc0, 0xdb, 0xca, 0xbd,
+	0x84, 0x0d, 0xb9, 0xb5, 0x02, 0x92, 0x5d, 0xed, 0x74, 0xb5, 0x9c, 0x48, 0xbe, 0x12, 0x23, 0x0f,
+	0x9c, 0x76, 0x54, 0x56, 0x32, 0xf2, 0x66, 0x4f, 0x45, 0x4f, 0xc2, 0xe3, 0x11, 0xe2, 0xa7, 0x9e,
+	0x71, 0x1d, 0x24, 0x45, 0x3f, 0xb7, 0x34, 0x55, 0xa2, 0xdf, 0x7f, 0x37, 0x2c, 0xf1, 0x3e, 0x11,
+	0x60, 0xc2, 0x4a, 0x9a, 0x12, 0xf3, 0x81, 0x6f, 0xd4, 0x30, 0xc3, 0x9a, 0xf6, 0xca, 0xfc, 0x3b,
+	0xd3, 0x6e, 0x9f, 0x79, 0xd8, 0x6e, 0xaf, 0xa7, 0xbf, 0x15, 0x90, 0x7d, 0x03, 0x40, 0x1f, 0x70,
+	0xb1, 0x9e, 0x62, 0x13, 0xc0, 0x53, 0x7d, 0xb1, 0xa6, 0xf7, 0xbc, 0x12, 0xd3, 0x93, 0xdf, 0xa3,
+	0x18, 0xd5, 0x2d, 0x81, 0xde, 0x8a, 0x30, 0xfb, 0xab, 0x14, 0x67, 0x5e, 0xf2, 0xc7, 0xaa, 0x4b,
+	0x37, 0xe9, 0xd7, 0xd5, 0xf4, 0x9d, 0x26, 0xad, 0xdb, 0x2e, 0xa2, 0x9e, 0x43, 0x5b, 0xeb, 0x42,
+	0x29, 0xe5, 0xce, 0xe1, 0x11, 0x83, 0xb5, 0x9d, 0x43, 0x70, 0x6b, 0x64, 0xa9, 0x8f, 0xc8, 0xe0,
+	0x79, 0x8b, 0x76, 0xac, 0xff, 0xb0, 0xe4, 0xbb, 0x59, 0xae, 0xd8, 0xc5, 0x3a, 0xe3, 0x66, 0x40,
+	0xd7, 0xd0, 0x8c, 0xce, 0x3a, 0xce, 0xdc, 0x9e, 0x6e, 0x67, 0x94, 0xbb, 0xb7, 0xf0, 0xf9, 0xbd,
+	0xb1, 0x27, 0x95, 0x93, 0x2d, 0x9e, 0xea, 0xe6, 0x0b, 0x4a, 0x87, 0xb6, 0x08, 0xc0, 0x9b, 0x42,
+	0xb6, 0xd7, 0xc0, 0x6e, 0x3e, 0xe7, 0x85, 0x54, 0x30, 0xf8, 0x38, 0x79, 0x1f, 0xfc, 0x2a, 0x32,
+	0x06, 0xa4, 0x43, 0x5e, 0xff, 0x56, 0xc5, 0x6c, 0x56, 0x39, 0x08, 0x73, 0x7d, 0xbf, 0x7d, 0x36,
+	0x17, 0xcc, 0xd9, 0xd7, 0x79, 0x3b, 0x9f, 0x37, 0xc1, 0x1d, 0xb3, 0xa2, 0xca, 0xf1, 0x0e, 0x5e,
+	0xc5, 0x6c, 0x66, 0x08, 0x49, 0x04, 0x27, 0xdd, 0xbf, 0xc8,
+}
+
+func TestQUICInitial(t *testing.T) {
+	p := gopacket.NewPacket(testPacketQUICInitial, LinkTypeEthernet, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeQUIC}, t)
+	q := p.Layer(LayerTypeQUIC).(*QUIC)
+	data := testPacketQUICInitial[42:]
+	want := &QUIC{
+		BaseLayer:  BaseLayer{Contents: data, Payload: []byte{}},
+		LongHeader: true,
+		PacketType: QUICPacketInitial,
+		FirstByte:  0xc6,
+		Version:    QUICVersion1,
+		DCID:       []byte{0x8b, 0x0c, 0x5a, 0x3d, 0x11, 0x2e, 0x9f, 0x47},
+		SCID:       []byte{0xc1, 0x5e, 0x0a, 0x77},
+		Token:      []byte{},
+		Length:     1178,
+		Protected:  data[22:],
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("QUIC mismatch, \nwant %#v\ngot  %#v", want, q)
+	}
+	if p.ApplicationLayer() != q {
+		t.Error("QUIC not the application layer")
+	}
+
+	if _, err := q.DecryptInitial(q.DCID, true); err == nil {
+		t.Error("Decrypting with the server's keys succeeded")
+	}
+	ip, err := q.DecryptInitial(q.DCID, false)
+	if err != nil {
+		t.Fatal("Failed to decrypt Initial packet:", err)
+	}
+	if ip.PacketNumberLength != 1 || ip.PacketNumber != 0 {
+		t.Errorf("Packet number length %d, number %d, want 1, 0", ip.PacketNumberLength, ip.PacketNumber)
+	}
+	var types []QUICFrameType
+	for _, f := range ip.Frames {
+		types = append(types, f.Type)
+	}
+	if want := []QUICFrameType{QUICFrameCrypto, QUICFramePing, QUICFrameCrypto}; !reflect.DeepEqual(types, want) {
+		t.Errorf("Frame types %v, want %v", types, want)
+	}
+	if ip.Frames[0].Offset != 60 || ip.Frames[2].Offset != 0 {
+		t.Errorf("CRYPTO frame offsets %d and %d, want 60 and 0", ip.Frames[0].Offset, ip.Frames[2].Offset)
+	}
+
+	ch, err := QUICClientHello(ip.Frames)
+	if err != nil {
+		t.Fatal("Failed to decode ClientHello:", err)
+	}
+	if ch.ServerName != "example.com" {
+		t.Errorf("Server name %q, want example.com", ch.ServerName)
+	}
+	if want := []string{"h3"}; !reflect.DeepEqual(ch.ALPNProtocols, want) {
+		t.Errorf("ALPN protocols %q, want %q", ch.ALPNProtocols, want)
+	}
+	if want := []TLSVersion{0x0304}; !reflect.DeepEqual(ch.SupportedVersions, want) {
+		t.Errorf("Supported versions %v, want %v", ch.SupportedVersions, want)
+	}
+
+	// Without the first frame, the ClientHello is incomplete.
+	if _, err := QUICClientHello(ip.Frames[:2]); err == nil {
+		t.Error("Decoding a ClientHello missing its start succeeded")
+	}
+	if _, err := QUICClientHello(ip.Frames[1:]); err == nil {
+		t.Error("Decoding a ClientHello missing its end succeeded")
+	}
+}
+
+func TestQUICInitialKeys(t *testing.T) {
+	// Test vectors of RFC 9001 appendix A.
+	dcid, _ := hex.DecodeString("8394c8f03e515708")
+	for _, test := range []struct {
+		fromServer  bool
+		key, iv, hp string
+	}{
+		{false, "1f369613dd76d5467730efcbe3b1a22d", "fa044b2f42a3fd3b46fb255c", "9f50449e04a0e810283a1e9933adedd2"},
+		{true, "cf3a5331653c364c88f0f379b6067e37", "0ac1493ca1905853b0bba03e", "c206b8d9b9f0f37644430b490eeaa314"},
+	} {
+		keys, err := newQUICInitialKeys(QUICVersion1, dcid, test.fromServer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []struct {
+			name      string
+			got, want string
+		}{
+			{"key", hex.EncodeToString(keys.key), test.key},
+			{"iv", hex.EncodeToString(keys.iv), test.iv},
+			{"hp", hex.EncodeToString(keys.hp), test.hp},
+		} {
+			if k.got != k.want {
+				t.Errorf("Server %v %s %s, want %s", test.fromServer, k.name, k.got, k.want)
+			}
+		}
+	}
+	if _, err := newQUICInitialKeys(0x1a2a3a4a, dcid, false); err == nil {
+		t.Error("Deriving keys of an unknown version succeeded")
+	}
+}
+
+func TestQUICVersionNegotiation(t *testing.T) {
+	data := []byte{
+		0xbf, 0x00, 0x00, 0x00, 0x00, 0x04, 0xc1, 0x5e, 0x0a, 0x77, 0x08, 0x8b, 0x0c, 0x5a, 0x3d, 0x11,
+		0x2e, 0x9f, 0x47, 0x00, 0x00, 0x00, 0x01, 0x6b, 0x33, 0x43, 0xcf, 0x1a, 0x2a, 0x3a, 0x4a,
+	}
+	p := gopacket.NewPacket(data, LayerTypeQUIC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	q := p.Layer(LayerTypeQUIC).(*QUIC)
+	if q.PacketType != QUICPacketVersionNegotiation {
+		t.Errorf("Packet type %v, want VersionNegotiation", q.PacketType)
+	}
+	if !bytes.Equal(q.DCID, data[6:10]) || !bytes.Equal(q.SCID, data[11:19]) {
+		t.Errorf("Connection IDs %x and %x", q.DCID, q.SCID)
+	}
+	if want := []QUICVersion{QUICVersion1, QUICVersion2, 0x1a2a3a4a}; !reflect.DeepEqual(q.SupportedVersions, want) {
+		t.Errorf("Supported versions %v, want %v", q.SupportedVersions, want)
+	}
+
+	if err := q.DecodeFromBytes(data[:len(data)-1], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("Decoding a partial version succeeded")
+	}
+}
+
+func TestQUICRetry(t *testing.T) {
+	data := []byte{
+		0xf0, 0x00, 0x00, 0x00, 0x01, 0x04, 0xc1, 0x5e, 0x0a, 0x77, 0x08, 0x33, 0x44, 0x55, 0x66, 0x77,
+		0x88, 0x99, 0xaa, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0xa2, 0x1b, 0x5e, 0x77, 0xc8, 0x2d, 0x90,
+		0x6a, 0x3f, 0xe1, 0x52, 0x80, 0x0b, 0xd4, 0x19, 0x66,
+	}
+	q := &QUIC{}
+	if err := q.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode Retry packet:", err)
+	}
+	if q.PacketType != QUICPacketRetry {
+		t.Errorf("Packet type %v, want Retry", q.PacketType)
+	}
+	if string(q.Token) != "token" {
+		t.Errorf("Token %q, want \"token\"", q.Token)
+	}
+	if !bytes.Equal(q.RetryIntegrityTag, data[24:]) {
+		t.Errorf("Retry integrity tag %x, want %x", q.RetryIntegrityTag, data[24:])
+	}
+	if _, err := q.DecryptInitial(q.DCID, false); err == nil {
+		t.Error("Decrypting a Retry packet succeeded")
+	}
+
+	// In version 2, the type bits of Retry packets are those of Initial
+	// packets in version 1.
+	v2 := append([]byte{0xc0, 0x6b, 0x33, 0x43, 0xcf}, data[5:]...)
+	if err := q.DecodeFromBytes(v2, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode version 2 Retry packet:", err)
+	}
+	if q.PacketType != QUICPacketRetry || q.Version != QUICVersion2 {
+		t.Errorf("Version %v packet type %v, want 2 Retry", q.Version, q.PacketType)
+	}
+}
+
+func TestQUICCoalesced(t *testing.T) {
+	// A server's Handshake packet following its Initial packet, and the
+	// zeros padding the datagram.
+	data := []byte{
+		0xc1, 0x00, 0x00, 0x00, 0x01, 0x04, 0xc1, 0x5e, 0x0a, 0x77, 0x02, 0x51, 0x52, 0x00, 0x05, 0x3e,
+		0x91, 0x0c, 0x7d, 0x24,
+		0xe2, 0x00, 0x00, 0x00, 0x01, 0x04, 0xc1, 0x5e, 0x0a, 0x77, 0x02, 0x51, 0x52, 0x04, 0x8a, 0x66,
+		0x0f, 0xbb,
+		0x00, 0x00, 0x00,
+	}
+	p := gopacket.NewPacket(data, LayerTypeQUIC, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeQUIC, LayerTypeQUIC, gopacket.LayerTypePayload}, t)
+	layers := p.Layers()
+	for i, want := range []struct {
+		typ       QUICPacketType
+		protected []byte
+	}{
+		{QUICPacketInitial, data[15:20]},
+		{QUICPacketHandshake, data[34:38]},
+	} {
+		q := layers[i].(*QUIC)
+		if q.PacketType != want.typ || !bytes.Equal(q.Protected, want.protected) {
+			t.Errorf("Packet %d type %v protected %x, want %v %x", i, q.PacketType, q.Protected, want.typ, want.protected)
+		}
+	}
+	if p.ApplicationLayer() != layers[0] {
+		t.Error("First QUIC packet not the application layer")
+	}
+}
+
+func TestQUICShortHeader(t *testing.T) {
+	data := []byte{0x43, 0xc1, 0x5e, 0x0a, 0x77, 0x9d, 0x0e, 0x55, 0x21, 0x8c}
+	q := &QUIC{ShortHeaderDCIDLength: 4}
+	if err := q.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode short header packet:", err)
+	}
+	if q.PacketType != QUICPacketShortHeader || q.LongHeader {
+		t.Errorf("Packet type %v, want ShortHeader", q.PacketType)
+	}
+	if !bytes.Equal(q.DCID, data[1:5]) || !bytes.Equal(q.Protected, data[5:]) {
+		t.Errorf("DCID %x protected %x", q.DCID, q.Protected)
+	}
+	if q.ShortHeaderDCIDLength != 4 {
+		t.Error("Connection ID length hint reset by decoding")
+	}
+	q.ShortHeaderDCIDLength = 20
+	if err := q.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("Decoding a packet shorter than its DCID succeeded")
+	}
+}
+
+func TestQUICMalformed(t *testing.T) {
+	initial := testPacketQUICInitial[42:]
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"version truncated", initial[:4]},
+		{"DCID truncated", initial[:10]},
+		{"SCID truncated", initial[:16]},
+		{"token length missing", initial[:18]},
+		{"length truncated", initial[:20]},
+		{"payload truncated", initial[:1000]},
+		{"token truncated", []byte{0xc0, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x05, 0x01, 0x02}},
+		{"connection ID too long", append([]byte{0xc0, 0x00, 0x00, 0x00, 0x01, 21}, make([]byte, 40)...)},
+		{"retry tag truncated", []byte{0xf0, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x01, 0x02}},
+	} {
+		q := &QUIC{}
+		if err := q.DecodeFromBytes(test.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: decoding succeeded", test.name)
+		}
+	}
+
+	// Unknown versions keep all that follows the connection IDs.
+	q := &QUIC{}
+	unknown := []byte{0xc0, 0x1a, 0x2a, 0x3a, 0x4a, 0x01, 0xaa, 0x01, 0xbb, 0xff, 0xfe}
+	if err := q.DecodeFromBytes(unknown, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode packet of unknown version:", err)
+	}
+	if q.PacketType != QUICPacketUnknown || !bytes.Equal(q.Protected, unknown[9:]) {
+		t.Errorf("Packet type %v protected %x", q.PacketType, q.Protected)
+	}
+
+	// Tampering with the payload fails authentication.
+	tampered := append([]byte(nil), initial...)
+	tampered[500] ^= 1
+	if err := q.DecodeFromBytes(tampered, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.DecryptInitial(q.DCID, false); err == nil {
+		t.Error("Decrypting a tampered packet succeeded")
+	}
+	if _, err := decodeQUICFrames([]byte{byte(QUICFrameCrypto), 0x00, 0x05, 0x01}); err == nil {
+		t.Error("Decoding a truncated CRYPTO frame succeeded")
+	}
+	if _, err := decodeQUICFrames([]byte{0x08}); err == nil {
+		t.Error("Decoding a STREAM frame succeeded")
+	}
+}
@@ -9,6 +9,7 @@ package layers
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"strings"
@@ -48,7 +49,15 @@ const (
 	RadioTapPresentMCS
 	RadioTapPresentAMPDUStatus
 	RadioTapPresentVHT
-	RadioTapPresentEXT RadioTapPresent = 1 << 31
+	RadioTapPresentTimestamp
+
+	// RadioTapPresentRadiotapNamespace and RadioTapPresentVendorNamespace
+	// switch the namespace of the next presence word, to the radiotap
+	// namespace or to a vendor namespace.  Their bit numbers are reserved
+	// in every presence word.
+	RadioTapPresentRadiotapNamespace RadioTapPresent = 1 << 29
+	RadioTapPresentVendorNamespace   RadioTapPresent = 1 << 30
+	RadioTapPresentEXT               RadioTapPresent = 1 << 31
 )
 
 func (r RadioTapPresent) TSFT() bool {
@@ -114,6 +123,15 @@ func (r RadioTapPresent) AMPDUStatus() bool {
 func (r RadioTapPresent) VHT() bool {
 	return r&RadioTapPresentVHT != 0
 }
+func (r RadioTapPresent) Timestamp() bool {
+	return r&RadioTapPresentTimestamp != 0
+}
+func (r RadioTapPresent) RadiotapNamespace() bool {
+	return r&RadioTapPresentRadiotapNamespace != 0
+}
+func (r RadioTapPresent) VendorNamespace() bool {
+	return r&RadioTapPresentVendorNamespace != 0
+}
 func (r RadioTapPresent) EXT() bool {
 	return r&RadioTapPresentEXT != 0
 }
@@ -671,6 +689,146 @@ func (self RadioTapVHTMCSNSS) String() string {
 	return fmt.Sprintf("NSS#%dMCS#%d", uint32(self&0xf), uint32(self>>4))
 }
 
+// RadioTapTimestamp is the timestamp field, the time the frame was sampled
+// at.  Unlike TSFT, it may be taken at another point of the frame and in
+// other units.
+type RadioTapTimestamp struct {
+	Timestamp uint64
+	// Accuracy is the accuracy of Timestamp in the same unit, if
+	// RadioTapTimestampFlagsAccuracy is set.
+	Accuracy uint16
+	// UnitPosition holds the time unit in its low 4 bits and the sampling
+	// position in its high 4 bits, see Unit and SamplingPosition.
+	UnitPosition uint8
+	Flags        RadioTapTimestampFlags
+}
+
+type RadioTapTimestampUnit uint8
+
+const (
+	RadioTapTimestampUnitMilliseconds RadioTapTimestampUnit = 0
+	RadioTapTimestampUnitMicroseconds RadioTapTimestampUnit = 1
+	RadioTapTimestampUnitNanoseconds  RadioTapTimestampUnit = 2
+)
+
+func (self RadioTapTimestamp) Unit() RadioTapTimestampUnit {
+	return RadioTapTimestampUnit(self.UnitPosition & 0x0f)
+}
+
+// SamplingPosition returns 0 if the frame was sampled at the first bit of
+// the MPDU, 1 at signal acquisition at the start of the PLCP, 2 at the end
+// of the PPDU, 3 at the end of the MPDU and 15 if it's unknown.
+func (self RadioTapTimestamp) SamplingPosition() uint8 {
+	return self.UnitPosition >> 4
+}
+
+type RadioTapTimestampFlags uint8
+
+const (
+	// RadioTapTimestampFlags32Bit is set if only the low 32 bits of
+	// Timestamp are counted.
+	RadioTapTimestampFlags32Bit    RadioTapTimestampFlags = 0x01
+	RadioTapTimestampFlagsAccuracy RadioTapTimestampFlags = 0x02
+)
+
+func (self RadioTapTimestampFlags) Bits32() bool   { return self&RadioTapTimestampFlags32Bit != 0 }
+func (self RadioTapTimestampFlags) Accuracy() bool { return self&RadioTapTimestampFlagsAccuracy != 0 }
+
+// RadioTapAntenna holds the per-antenna fields that drivers such as ath9k
+// and iwlwifi send in further radiotap namespaces, one per antenna, after
+// the fields of the whole frame.
+type RadioTapAntenna struct {
+	Antenna          uint8
+	DBMAntennaSignal int8
+	DBMAntennaNoise  int8
+	DBAntennaSignal  uint8
+	DBAntennaNoise   uint8
+}
+
+// RadioTapField is a field of a radiotap namespace which isn't decoded into
+// one of the typed fields of RadioTap, holding its raw bytes.  Namespace is
+// 0 for the first radiotap namespace, 1 for the next one, and so on; Bit is
+// the field's bit number in its namespace.
+//
+// Fields whose layout isn't known make it impossible to find the following
+// ones, so Data then holds the rest of the header and decoding stops there.
+type RadioTapField struct {
+	Namespace int
+	Bit       uint
+	Data      []byte
+}
+
+// RadioTapVendorNamespace is a vendor namespace, whose fields are skipped
+// rather than decoded.  Data holds the fields, and its length is the skip
+// length of the namespace.
+type RadioTapVendorNamespace struct {
+	OUI          [3]byte
+	SubNamespace uint8
+	Data         []byte
+}
+
+// radioTapFields holds the alignment and size of the fields of the radiotap
+// namespace, indexed by bit number.
+var radioTapFields = [...]struct{ align, size uint16 }{
+	{8, 8},  // TSFT
+	{1, 1},  // Flags
+	{1, 1},  // Rate
+	{2, 4},  // Channel
+	{1, 2},  // FHSS
+	{1, 1},  // DBMAntennaSignal
+	{1, 1},  // DBMAntennaNoise
+	{2, 2},  // LockQuality
+	{2, 2},  // TxAttenuation
+	{2, 2},  // DBTxAttenuation
+	{1, 1},  // DBMTxPower
+	{1, 1},  // Antenna
+	{1, 1},  // DBAntennaSignal
+	{1, 1},  // DBAntennaNoise
+	{2, 2},  // RxFlags
+	{2, 2},  // TxFlags
+	{1, 1},  // RtsRetries
+	{1, 1},  // DataRetries
+	{4, 8},  // XChannel
+	{1, 3},  // MCS
+	{4, 8},  // AMPDUStatus
+	{2, 12}, // VHT
+	{8, 12}, // Timestamp
+	{2, 12}, // HE
+	{2, 12}, // HE-MU
+	{2, 6},  // HE-MU-other-user
+	{1, 1},  // 0-length-PSDU
+	{2, 4},  // L-SIG
+}
+
+// radioTapWalk calls field for each field given by the presence words, in
+// the order they're laid out: with the index of the radiotap namespace and
+// the bit number of radiotap namespace fields, and with vendor set for each
+// vendor namespace, whose fields are all laid out together.  It stops if
+// field returns false.
+func radioTapWalk(words []RadioTapPresent, field func(ns int, bit uint, vendor bool) bool) {
+	ns, base, vendor := 0, uint(0), false
+	for _, w := range words {
+		if !vendor {
+			for bit := uint(0); bit < 29; bit++ {
+				if w&(1<<bit) != 0 && !field(ns, base+bit, false) {
+					return
+				}
+			}
+		}
+		switch {
+		case w.RadiotapNamespace():
+			ns, base, vendor = ns+1, 0, false
+		case w.VendorNamespace():
+			if !field(ns, 0, true) {
+				return
+			}
+			base, vendor = 0, true
+		default:
+			base += 32
+		}
+	}
+}
+
 func decodeRadioTap(data []byte, p gopacket.PacketBuilder) error {
 	d := &RadioTap{}
 	// TODO: Should we set LinkLayer here? And implement LinkFlow
@@ -686,6 +844,8 @@ type RadioTap struct {
 	Length uint16
 	// Present is a bitmap telling which fields are present. Set bit 31 (0x80000000) to extend the bitmap by another 32 bits. Additional extensions are made by setting bit 31.
 	Present RadioTapPresent
+	// ExtPresent holds the presence words following Present, if its bit 31 is set.
+	ExtPresent []RadioTapPresent
 	// TSFT: value in microseconds of the MAC's 64-bit 802.11 Time Synchronization Function timer when the first bit of the MPDU arrived at the MAC. For received frames, only.
 	TSFT  uint64
 	Flags RadioTapFlags
@@ -722,146 +882,93 @@ type RadioTap struct {
 	MCS         RadioTapMCS
 	AMPDUStatus RadioTapAMPDUStatus
 	VHT         RadioTapVHT
+	Timestamp   RadioTapTimestamp
+	// Antennas holds the per-antenna fields of the radiotap namespaces
+	// following the first one: Antennas[0] those of the second namespace,
+	// and so on.
+	Antennas []RadioTapAntenna
+	// Fields holds the fields which have no typed field above.
+	Fields []RadioTapField
+	// VendorNamespaces holds the vendor namespaces, in order.
+	VendorNamespaces []RadioTapVendorNamespace
 }
 
 func (m *RadioTap) LayerType() gopacket.LayerType { return LayerTypeRadioTap }
 
 func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-	m.Version = uint8(data[0])
-	m.Length = binary.LittleEndian.Uint16(data[2:4])
-	m.Present = RadioTapPresent(binary.LittleEndian.Uint32(data[4:8]))
-
-	offset := uint16(4)
-
-	for (binary.LittleEndian.Uint32(data[offset:offset+4]) & 0x80000000) != 0 {
-		// This parser only handles standard radiotap namespace,
-		// and expects all fields are packed in the first it_present.
-		// Extended bitmap will be just ignored.
-		offset += 4
-	}
-	offset += 4 // skip the bitmap
-
-	if m.Present.TSFT() {
-		offset += align(offset, 8)
-		m.TSFT = binary.LittleEndian.Uint64(data[offset : offset+8])
-		offset += 8
-	}
-	if m.Present.Flags() {
-		m.Flags = RadioTapFlags(data[offset])
-		offset++
-	}
-	if m.Present.Rate() {
-		m.Rate = RadioTapRate(data[offset])
-		offset++
-	}
-	if m.Present.Channel() {
-		offset += align(offset, 2)
-		m.ChannelFrequency = RadioTapChannelFrequency(binary.LittleEndian.Uint16(data[offset : offset+2]))
-		offset += 2
-		m.ChannelFlags = RadioTapChannelFlags(binary.LittleEndian.Uint16(data[offset : offset+2]))
-		offset += 2
+	if len(data) < 8 {
+		df.SetTruncated()
+		return errors.New("RadioTap header too short")
 	}
-	if m.Present.FHSS() {
-		m.FHSS = binary.LittleEndian.Uint16(data[offset : offset+2])
-		offset += 2
+	*m = RadioTap{
+		Version: uint8(data[0]),
+		Length:  binary.LittleEndian.Uint16(data[2:4]),
+		Present: RadioTapPresent(binary.LittleEndian.Uint32(data[4:8])),
 	}
-	if m.Present.DBMAntennaSignal() {
-		m.DBMAntennaSignal = int8(data[offset])
-		offset++
+	if m.Length < 8 {
+		return fmt.Errorf("invalid RadioTap length %d", m.Length)
 	}
-	if m.Present.DBMAntennaNoise() {
-		m.DBMAntennaNoise = int8(data[offset])
-		offset++
+	if int(m.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("RadioTap length %d exceeds %d bytes", m.Length, len(data))
 	}
-	if m.Present.LockQuality() {
-		offset += align(offset, 2)
-		m.LockQuality = binary.LittleEndian.Uint16(data[offset : offset+2])
-		offset += 2
-	}
-	if m.Present.TxAttenuation() {
-		offset += align(offset, 2)
-		m.TxAttenuation = binary.LittleEndian.Uint16(data[offset : offset+2])
-		offset += 2
-	}
-	if m.Present.DBTxAttenuation() {
-		offset += align(offset, 2)
-		m.DBTxAttenuation = binary.LittleEndian.Uint16(data[offset : offset+2])
-		offset += 2
-	}
-	if m.Present.DBMTxPower() {
-		m.DBMTxPower = int8(data[offset])
-		offset++
-	}
-	if m.Present.Antenna() {
-		m.Antenna = uint8(data[offset])
-		offset++
-	}
-	if m.Present.DBAntennaSignal() {
-		m.DBAntennaSignal = uint8(data[offset])
-		offset++
-	}
-	if m.Present.DBAntennaNoise() {
-		m.DBAntennaNoise = uint8(data[offset])
-		offset++
-	}
-	if m.Present.RxFlags() {
-		offset += align(offset, 2)
-		m.RxFlags = RadioTapRxFlags(binary.LittleEndian.Uint16(data[offset:]))
-		offset += 2
-	}
-	if m.Present.TxFlags() {
-		offset += align(offset, 2)
-		m.TxFlags = RadioTapTxFlags(binary.LittleEndian.Uint16(data[offset:]))
-		offset += 2
-	}
-	if m.Present.RtsRetries() {
-		m.RtsRetries = uint8(data[offset])
-		offset++
-	}
-	if m.Present.DataRetries() {
-		m.DataRetries = uint8(data[offset])
-		offset++
-	}
-	if m.Present.MCS() {
-		m.MCS = RadioTapMCS{
-			RadioTapMCSKnown(data[offset]),
-			RadioTapMCSFlags(data[offset+1]),
-			uint8(data[offset+2]),
+	header := data[:m.Length]
+
+	offset := 8
+	words := []RadioTapPresent{m.Present}
+	for w := m.Present; w.EXT(); {
+		if offset+4 > len(header) {
+			return errors.New("RadioTap presence bitmap truncated")
 		}
-		offset += 3
+		w = RadioTapPresent(binary.LittleEndian.Uint32(header[offset : offset+4]))
+		m.ExtPresent = append(m.ExtPresent, w)
+		words = append(words, w)
+		offset += 4
 	}
-	if m.Present.AMPDUStatus() {
-		offset += align(offset, 4)
-		m.AMPDUStatus = RadioTapAMPDUStatus{
-			Reference: binary.LittleEndian.Uint32(data[offset:]),
-			Flags:     RadioTapAMPDUStatusFlags(binary.LittleEndian.Uint16(data[offset+4:])),
-			CRC:       uint8(data[offset+6]),
+
+	var err error
+	radioTapWalk(words, func(ns int, bit uint, vendor bool) bool {
+		if vendor {
+			offset += int(align(uint16(offset), 2))
+			if offset+6 > len(header) {
+				err = errors.New("RadioTap vendor namespace truncated")
+				return false
+			}
+			v := RadioTapVendorNamespace{SubNamespace: header[offset+3]}
+			copy(v.OUI[:], header[offset:offset+3])
+			skip := int(binary.LittleEndian.Uint16(header[offset+4 : offset+6]))
+			offset += 6
+			if offset+skip > len(header) {
+				err = errors.New("RadioTap vendor namespace truncated")
+				return false
+			}
+			v.Data = header[offset : offset+skip]
+			offset += skip
+			m.VendorNamespaces = append(m.VendorNamespaces, v)
+			return true
 		}
-		offset += 8
-	}
-	if m.Present.VHT() {
-		offset += align(offset, 2)
-		m.VHT = RadioTapVHT{
-			Known:     RadioTapVHTKnown(binary.LittleEndian.Uint16(data[offset:])),
-			Flags:     RadioTapVHTFlags(data[offset+2]),
-			Bandwidth: uint8(data[offset+3]),
-			MCSNSS: [4]RadioTapVHTMCSNSS{
-				RadioTapVHTMCSNSS(data[offset+4]),
-				RadioTapVHTMCSNSS(data[offset+5]),
-				RadioTapVHTMCSNSS(data[offset+6]),
-				RadioTapVHTMCSNSS(data[offset+7]),
-			},
-			Coding:     uint8(data[offset+8]),
-			GroupId:    uint8(data[offset+9]),
-			PartialAID: binary.LittleEndian.Uint16(data[offset+10:]),
+		if bit >= uint(len(radioTapFields)) {
+			m.Fields = append(m.Fields, RadioTapField{Namespace: ns, Bit: bit, Data: header[offset:]})
+			return false
 		}
-		offset += 12
+		f := radioTapFields[bit]
+		offset += int(align(uint16(offset), f.align))
+		if offset+int(f.size) > len(header) {
+			err = fmt.Errorf("RadioTap field %d truncated", bit)
+			return false
+		}
+		m.decodeField(ns, bit, header[offset:offset+int(f.size)])
+		offset += int(f.size)
+		return true
+	})
+	if err != nil {
+		return err
 	}
 
 	payload := data[m.Length:]
 
 	// Remove non standard padding used by some Wi-Fi drivers
-	if m.Flags.Datapad() &&
+	if m.Flags.Datapad() && len(payload) > 1 &&
 		payload[0]&0xC == 0x8 { //&& // Data frame
 		headlen := 24
 		if payload[0]&0x8C == 0x88 { // QoS
@@ -870,7 +977,7 @@ func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 		if payload[1]&0x3 == 0x3 { // 4 addresses
 			headlen += 2
 		}
-		if headlen%4 == 2 {
+		if headlen%4 == 2 && len(payload) >= headlen+2 {
 			payload = append(payload[:headlen], payload[headlen+2:len(payload)]...)
 		}
 	}
@@ -890,176 +997,274 @@ func (m *RadioTap) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) erro
 		binary.LittleEndian.PutUint32(reallocPayload[len(payload):], h.Sum32())
 		payload = reallocPayload
 	}
-	m.BaseLayer = BaseLayer{Contents: data[:m.Length], Payload: payload}
+	m.BaseLayer = BaseLayer{Contents: header, Payload: payload}
 
 	return nil
 }
 
-func (m RadioTap) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	buf := make([]byte, 1024)
-
-	buf[0] = m.Version
-	buf[1] = 0
-
-	binary.LittleEndian.PutUint32(buf[4:8], uint32(m.Present))
-
-	offset := uint16(4)
-
-	for (binary.LittleEndian.Uint32(buf[offset:offset+4]) & 0x80000000) != 0 {
-		offset += 4
-	}
-
-	offset += 4
-
-	if m.Present.TSFT() {
-		offset += align(offset, 8)
-		binary.LittleEndian.PutUint64(buf[offset:offset+8], m.TSFT)
-		offset += 8
-	}
-
-	if m.Present.Flags() {
-		buf[offset] = uint8(m.Flags)
-		offset++
-	}
-
-	if m.Present.Rate() {
-		buf[offset] = uint8(m.Rate)
-		offset++
-	}
-
-	if m.Present.Channel() {
-		offset += align(offset, 2)
-		binary.LittleEndian.PutUint16(buf[offset:offset+2], uint16(m.ChannelFrequency))
-		offset += 2
-		binary.LittleEndian.PutUint16(buf[offset:offset+2], uint16(m.ChannelFlags))
-		offset += 2
-	}
-
-	if m.Present.FHSS() {
-		binary.LittleEndian.PutUint16(buf[offset:offset+2], m.FHSS)
-		offset += 2
-	}
-
-	if m.Present.DBMAntennaSignal() {
-		buf[offset] = byte(m.DBMAntennaSignal)
-		offset++
-	}
-
-	if m.Present.DBMAntennaNoise() {
-		buf[offset] = byte(m.DBMAntennaNoise)
-		offset++
-	}
-
-	if m.Present.LockQuality() {
-		offset += align(offset, 2)
-		binary.LittleEndian.PutUint16(buf[offset:offset+2], m.LockQuality)
-		offset += 2
-	}
-
-	if m.Present.TxAttenuation() {
-		offset += align(offset, 2)
-		binary.LittleEndian.PutUint16(buf[offset:offset+2], m.TxAttenuation)
-		offset += 2
-	}
-
-	if m.Present.DBTxAttenuation() {
-		offset += align(offset, 2)
-		binary.LittleEndian.PutUint16(buf[offset:offset+2], m.DBTxAttenuation)
-		offset += 2
-	}
-
-	if m.Present.DBMTxPower() {
-		buf[offset] = byte(m.DBMTxPower)
-		offset++
-	}
-
-	if m.Present.Antenna() {
-		buf[offset] = uint8(m.Antenna)
-		offset++
-	}
-
-	if m.Present.DBAntennaSignal() {
-		buf[offset] = uint8(m.DBAntennaSignal)
-		offset++
-	}
-
-	if m.Present.DBAntennaNoise() {
-		buf[offset] = uint8(m.DBAntennaNoise)
-		offset++
-	}
-
-	if m.Present.RxFlags() {
-		offset += align(offset, 2)
-		binary.LittleEndian.PutUint16(buf[offset:offset+2], uint16(m.RxFlags))
-		offset += 2
-	}
-
-	if m.Present.TxFlags() {
-		offset += align(offset, 2)
-		binary.LittleEndian.PutUint16(buf[offset:offset+2], uint16(m.TxFlags))
-		offset += 2
-	}
-
-	if m.Present.RtsRetries() {
-		buf[offset] = m.RtsRetries
-		offset++
-	}
-
-	if m.Present.DataRetries() {
-		buf[offset] = m.DataRetries
-		offset++
+// decodeField decodes the field with the given bit number of the ns'th
+// radiotap namespace from b, which holds exactly its bytes.
+func (m *RadioTap) decodeField(ns int, bit uint, b []byte) {
+	if ns > 0 {
+		for len(m.Antennas) < ns {
+			m.Antennas = append(m.Antennas, RadioTapAntenna{})
+		}
+		a := &m.Antennas[ns-1]
+		switch RadioTapPresent(1 << bit) {
+		case RadioTapPresentDBMAntennaSignal:
+			a.DBMAntennaSignal = int8(b[0])
+		case RadioTapPresentDBMAntennaNoise:
+			a.DBMAntennaNoise = int8(b[0])
+		case RadioTapPresentAntenna:
+			a.Antenna = b[0]
+		case RadioTapPresentDBAntennaSignal:
+			a.DBAntennaSignal = b[0]
+		case RadioTapPresentDBAntennaNoise:
+			a.DBAntennaNoise = b[0]
+		default:
+			m.Fields = append(m.Fields, RadioTapField{Namespace: ns, Bit: bit, Data: b})
+		}
+		return
+	}
+
+	switch RadioTapPresent(1 << bit) {
+	case RadioTapPresentTSFT:
+		m.TSFT = binary.LittleEndian.Uint64(b)
+	case RadioTapPresentFlags:
+		m.Flags = RadioTapFlags(b[0])
+	case RadioTapPresentRate:
+		m.Rate = RadioTapRate(b[0])
+	case RadioTapPresentChannel:
+		m.ChannelFrequency = RadioTapChannelFrequency(binary.LittleEndian.Uint16(b[0:2]))
+		m.ChannelFlags = RadioTapChannelFlags(binary.LittleEndian.Uint16(b[2:4]))
+	case RadioTapPresentFHSS:
+		m.FHSS = binary.LittleEndian.Uint16(b)
+	case RadioTapPresentDBMAntennaSignal:
+		m.DBMAntennaSignal = int8(b[0])
+	case RadioTapPresentDBMAntennaNoise:
+		m.DBMAntennaNoise = int8(b[0])
+	case RadioTapPresentLockQuality:
+		m.LockQuality = binary.LittleEndian.Uint16(b)
+	case RadioTapPresentTxAttenuation:
+		m.TxAttenuation = binary.LittleEndian.Uint16(b)
+	case RadioTapPresentDBTxAttenuation:
+		m.DBTxAttenuation = binary.LittleEndian.Uint16(b)
+	case RadioTapPresentDBMTxPower:
+		m.DBMTxPower = int8(b[0])
+	case RadioTapPresentAntenna:
+		m.Antenna = b[0]
+	case RadioTapPresentDBAntennaSignal:
+		m.DBAntennaSignal = b[0]
+	case RadioTapPresentDBAntennaNoise:
+		m.DBAntennaNoise = b[0]
+	case RadioTapPresentRxFlags:
+		m.RxFlags = RadioTapRxFlags(binary.LittleEndian.Uint16(b))
+	case RadioTapPresentTxFlags:
+		m.TxFlags = RadioTapTxFlags(binary.LittleEndian.Uint16(b))
+	case RadioTapPresentRtsRetries:
+		m.RtsRetries = b[0]
+	case RadioTapPresentDataRetries:
+		m.DataRetries = b[0]
+	case RadioTapPresentMCS:
+		m.MCS = RadioTapMCS{
+			RadioTapMCSKnown(b[0]),
+			RadioTapMCSFlags(b[1]),
+			b[2],
+		}
+	case RadioTapPresentAMPDUStatus:
+		m.AMPDUStatus = RadioTapAMPDUStatus{
+			Reference: binary.LittleEndian.Uint32(b[0:4]),
+			Flags:     RadioTapAMPDUStatusFlags(binary.LittleEndian.Uint16(b[4:6])),
+			CRC:       b[6],
+		}
+	case RadioTapPresentVHT:
+		m.VHT = RadioTapVHT{
+			Known:     RadioTapVHTKnown(binary.LittleEndian.Uint16(b[0:2])),
+			Flags:     RadioTapVHTFlags(b[2]),
+			Bandwidth: b[3],
+			MCSNSS: [4]RadioTapVHTMCSNSS{
+				RadioTapVHTMCSNSS(b[4]),
+				RadioTapVHTMCSNSS(b[5]),
+				RadioTapVHTMCSNSS(b[6]),
+				RadioTapVHTMCSNSS(b[7]),
+			},
+			Coding:     b[8],
+			GroupId:    b[9],
+			PartialAID: binary.LittleEndian.Uint16(b[10:12]),
+		}
+	case RadioTapPresentTimestamp:
+		m.Timestamp = RadioTapTimestamp{
+			Timestamp:    binary.LittleEndian.Uint64(b[0:8]),
+			Accuracy:     binary.LittleEndian.Uint16(b[8:10]),
+			UnitPosition: b[10],
+			Flags:        RadioTapTimestampFlags(b[11]),
+		}
+	default:
+		m.Fields = append(m.Fields, RadioTapField{Namespace: ns, Bit: bit, Data: b})
 	}
+}
 
-	if m.Present.MCS() {
-		buf[offset] = uint8(m.MCS.Known)
-		buf[offset+1] = uint8(m.MCS.Flags)
-		buf[offset+2] = uint8(m.MCS.MCS)
-
-		offset += 3
+// serializeField writes the field with the given bit number of the ns'th
+// radiotap namespace into b, which is exactly its size and zeroed.
+func (m *RadioTap) serializeField(ns int, bit uint, b []byte) {
+	raw := func() {
+		for _, f := range m.Fields {
+			if f.Namespace == ns && f.Bit == bit {
+				copy(b, f.Data)
+				return
+			}
+		}
 	}
-
-	if m.Present.AMPDUStatus() {
-		offset += align(offset, 4)
-
-		binary.LittleEndian.PutUint32(buf[offset:offset+4], m.AMPDUStatus.Reference)
-		binary.LittleEndian.PutUint16(buf[offset+4:offset+6], uint16(m.AMPDUStatus.Flags))
-
-		buf[offset+6] = m.AMPDUStatus.CRC
-
-		offset += 8
+	if ns > 0 {
+		var a RadioTapAntenna
+		if ns <= len(m.Antennas) {
+			a = m.Antennas[ns-1]
+		}
+		switch RadioTapPresent(1 << bit) {
+		case RadioTapPresentDBMAntennaSignal:
+			b[0] = byte(a.DBMAntennaSignal)
+		case RadioTapPresentDBMAntennaNoise:
+			b[0] = byte(a.DBMAntennaNoise)
+		case RadioTapPresentAntenna:
+			b[0] = a.Antenna
+		case RadioTapPresentDBAntennaSignal:
+			b[0] = a.DBAntennaSignal
+		case RadioTapPresentDBAntennaNoise:
+			b[0] = a.DBAntennaNoise
+		default:
+			raw()
+		}
+		return
+	}
+
+	switch RadioTapPresent(1 << bit) {
+	case RadioTapPresentTSFT:
+		binary.LittleEndian.PutUint64(b, m.TSFT)
+	case RadioTapPresentFlags:
+		b[0] = uint8(m.Flags)
+	case RadioTapPresentRate:
+		b[0] = uint8(m.Rate)
+	case RadioTapPresentChannel:
+		binary.LittleEndian.PutUint16(b[0:2], uint16(m.ChannelFrequency))
+		binary.LittleEndian.PutUint16(b[2:4], uint16(m.ChannelFlags))
+	case RadioTapPresentFHSS:
+		binary.LittleEndian.PutUint16(b, m.FHSS)
+	case RadioTapPresentDBMAntennaSignal:
+		b[0] = byte(m.DBMAntennaSignal)
+	case RadioTapPresentDBMAntennaNoise:
+		b[0] = byte(m.DBMAntennaNoise)
+	case RadioTapPresentLockQuality:
+		binary.LittleEndian.PutUint16(b, m.LockQuality)
+	case RadioTapPresentTxAttenuation:
+		binary.LittleEndian.PutUint16(b, m.TxAttenuation)
+	case RadioTapPresentDBTxAttenuation:
+		binary.LittleEndian.PutUint16(b, m.DBTxAttenuation)
+	case RadioTapPresentDBMTxPower:
+		b[0] = byte(m.DBMTxPower)
+	case RadioTapPresentAntenna:
+		b[0] = m.Antenna
+	case RadioTapPresentDBAntennaSignal:
+		b[0] = m.DBAntennaSignal
+	case RadioTapPresentDBAntennaNoise:
+		b[0] = m.DBAntennaNoise
+	case RadioTapPresentRxFlags:
+		binary.LittleEndian.PutUint16(b, uint16(m.RxFlags))
+	case RadioTapPresentTxFlags:
+		binary.LittleEndian.PutUint16(b, uint16(m.TxFlags))
+	case RadioTapPresentRtsRetries:
+		b[0] = m.RtsRetries
+	case RadioTapPresentDataRetries:
+		b[0] = m.DataRetries
+	case RadioTapPresentMCS:
+		b[0] = uint8(m.MCS.Known)
+		b[1] = uint8(m.MCS.Flags)
+		b[2] = m.MCS.MCS
+	case RadioTapPresentAMPDUStatus:
+		binary.LittleEndian.PutUint32(b[0:4], m.AMPDUStatus.Reference)
+		binary.LittleEndian.PutUint16(b[4:6], uint16(m.AMPDUStatus.Flags))
+		b[6] = m.AMPDUStatus.CRC
+	case RadioTapPresentVHT:
+		binary.LittleEndian.PutUint16(b[0:2], uint16(m.VHT.Known))
+		b[2] = uint8(m.VHT.Flags)
+		b[3] = m.VHT.Bandwidth
+		for i, v := range m.VHT.MCSNSS {
+			b[4+i] = uint8(v)
+		}
+		b[8] = m.VHT.Coding
+		b[9] = m.VHT.GroupId
+		binary.LittleEndian.PutUint16(b[10:12], m.VHT.PartialAID)
+	case RadioTapPresentTimestamp:
+		binary.LittleEndian.PutUint64(b[0:8], m.Timestamp.Timestamp)
+		binary.LittleEndian.PutUint16(b[8:10], m.Timestamp.Accuracy)
+		b[10] = m.Timestamp.UnitPosition
+		b[11] = uint8(m.Timestamp.Flags)
+	default:
+		raw()
+	}
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// The fields written are those given by Present and ExtPresent.  A field
+// with no typed field is written from Fields, and zeroed if it's not
+// there; the same goes for per-antenna fields missing from Antennas and
+// vendor namespaces missing from VendorNamespaces.
+func (m RadioTap) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	words := append([]RadioTapPresent{m.Present}, m.ExtPresent...)
+	buf := make([]byte, 4+4*len(words), 64)
+	for i, w := range words {
+		binary.LittleEndian.PutUint32(buf[4+4*i:], uint32(w))
 	}
 
-	if m.Present.VHT() {
-		offset += align(offset, 2)
-
-		binary.LittleEndian.PutUint16(buf[offset:], uint16(m.VHT.Known))
-
-		buf[offset+2] = uint8(m.VHT.Flags)
-		buf[offset+3] = uint8(m.VHT.Bandwidth)
-		buf[offset+4] = uint8(m.VHT.MCSNSS[0])
-		buf[offset+5] = uint8(m.VHT.MCSNSS[1])
-		buf[offset+6] = uint8(m.VHT.MCSNSS[2])
-		buf[offset+7] = uint8(m.VHT.MCSNSS[3])
-		buf[offset+8] = uint8(m.VHT.Coding)
-		buf[offset+9] = uint8(m.VHT.GroupId)
-
-		binary.LittleEndian.PutUint16(buf[offset+10:offset+12], m.VHT.PartialAID)
-
-		offset += 12
+	pad := func(width uint16) {
+		for n := align(uint16(len(buf)), width); n > 0; n-- {
+			buf = append(buf, 0)
+		}
 	}
-
-	packetBuf, err := b.PrependBytes(int(offset))
-
+	vendor := 0
+	radioTapWalk(words, func(ns int, bit uint, isVendor bool) bool {
+		if isVendor {
+			var v RadioTapVendorNamespace
+			if vendor < len(m.VendorNamespaces) {
+				v = m.VendorNamespaces[vendor]
+			}
+			vendor++
+			pad(2)
+			buf = append(buf, v.OUI[0], v.OUI[1], v.OUI[2], v.SubNamespace, byte(len(v.Data)), byte(len(v.Data)>>8))
+			buf = append(buf, v.Data...)
+			return true
+		}
+		if bit >= uint(len(radioTapFields)) {
+			for _, f := range m.Fields {
+				if f.Namespace == ns && f.Bit == bit {
+					buf = append(buf, f.Data...)
+				}
+			}
+			return false
+		}
+		f := radioTapFields[bit]
+		pad(f.align)
+		start := len(buf)
+		buf = append(buf, make([]byte, f.size)...)
+		m.serializeField(ns, bit, buf[start:])
+		return true
+	})
+	if len(buf) > 0xffff {
+		return fmt.Errorf("RadioTap header of %d bytes too long", len(buf))
+	}
+
+	packetBuf, err := b.PrependBytes(len(buf))
 	if err != nil {
 		return err
 	}
-
 	if opts.FixLengths {
-		m.Length = offset
+		m.Length = uint16(len(buf))
 	}
-
+	buf[0] = m.Version
+	buf[1] = 0
 	binary.LittleEndian.PutUint16(buf[2:4], m.Length)
-
 	copy(packetBuf, buf)
 
 	return nil
@@ -6,8 +6,11 @@
 package layers
 
 import (
-	"github.com/google/gopacket"
+	"bytes"
+	"reflect"
 	"testing"
+
+	"github.com/google/gopacket"
 )
 
 // testPacketRadiotap0 is the packet:
@@ -77,3 +80,126 @@ func BenchmarkDecodePacketRadiotap1(b *testing.B) {
 		gopacket.NewPacket(testPacketRadiotap1, LayerTypeRadioTap, gopacket.NoCopy)
 	}
 }
+
+// testPacketRadiotapAntennas is an ath9k style ACK frame, whose radiotap
+// header has a radiotap namespace for each of its two antennas after the
+// fields of the whole frame.
+var testPacketRadiotapAntennas = []byte{
+	0x00, 0x00, 0x26, 0x00, 0x2f, 0x40, 0x00, 0xa0, 0x20, 0x08, 0x00, 0xa0, 0x20, 0x08, 0x00, 0x00,
+	0x78, 0x56, 0x34, 0x12, 0x00, 0x00, 0x00, 0x00, 0x10, 0x02, 0x85, 0x09, 0xa0, 0x00, 0xd6, 0x00,
+	0x00, 0x00, 0xd5, 0x00, 0xd3, 0x01, 0xd4, 0x00, 0x00, 0x00, 0x88, 0x1f, 0xa1, 0xae, 0x9d, 0xcb,
+	0xc6, 0x30, 0x4b, 0x4b,
+}
+
+func TestPacketRadiotapAntennas(t *testing.T) {
+	p := gopacket.NewPacket(testPacketRadiotapAntennas, LayerTypeRadioTap, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeRadioTap, LayerTypeDot11}, t)
+	rt := p.Layer(LayerTypeRadioTap).(*RadioTap)
+	if len(rt.ExtPresent) != 2 || !rt.Present.RadiotapNamespace() {
+		t.Errorf("Radiotap presence words %v %v", rt.Present, rt.ExtPresent)
+	}
+	if rt.TSFT != 0x12345678 || rt.ChannelFrequency != 2437 || rt.DBMAntennaSignal != -42 || !rt.Flags.FCS() {
+		t.Errorf("Radiotap decode error %+v", rt)
+	}
+	want := []RadioTapAntenna{
+		{Antenna: 0, DBMAntennaSignal: -43},
+		{Antenna: 1, DBMAntennaSignal: -45},
+	}
+	if !reflect.DeepEqual(rt.Antennas, want) {
+		t.Errorf("Radiotap antennas %+v, want %+v", rt.Antennas, want)
+	}
+	testRadioTapSerialization(t, rt, testPacketRadiotapAntennas)
+}
+
+// testPacketRadiotapVHT is an iwlwifi style QoS null frame, with A-MPDU
+// status, VHT and timestamp fields, a radiotap namespace for its antenna
+// and a vendor namespace.
+var testPacketRadiotapVHT = []byte{
+	0x00, 0x00, 0x50, 0x00, 0x2b, 0x40, 0x70, 0xa0, 0x20, 0x08, 0x00, 0xc0, 0x01, 0x00, 0x00, 0x00,
+	0x55, 0x44, 0x33, 0x22, 0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3c, 0x14, 0x40, 0x01, 0xc4, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x2a, 0x00, 0x00, 0x00, 0x0c, 0x00, 0x00, 0x00, 0x44, 0x00, 0x04, 0x04,
+	0x92, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x89, 0x67, 0x45, 0x23, 0x01, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x01, 0x00, 0xc3, 0x01, 0x00, 0x13, 0x74, 0x00, 0x04, 0x00, 0x01, 0x02, 0x03, 0x04,
+	0xc8, 0x01, 0x00, 0x00, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb,
+	0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x10, 0x00, 0x00, 0x00,
+}
+
+func TestPacketRadiotapVHT(t *testing.T) {
+	p := gopacket.NewPacket(testPacketRadiotapVHT, LayerTypeRadioTap, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeRadioTap, LayerTypeDot11, LayerTypeDot11DataQOSNull}, t)
+	rt := p.Layer(LayerTypeRadioTap).(*RadioTap)
+	if rt.ChannelFrequency != 5180 || rt.DBMAntennaSignal != -60 {
+		t.Errorf("Radiotap decode error %+v", rt)
+	}
+	if rt.AMPDUStatus.Reference != 42 || !rt.AMPDUStatus.Flags.LastKnown() || !rt.AMPDUStatus.Flags.IsLast() {
+		t.Errorf("Radiotap A-MPDU status %+v", rt.AMPDUStatus)
+	}
+	if !rt.VHT.Known.Bandwidth() || rt.VHT.Bandwidth != 4 || !rt.VHT.Flags.SGI() {
+		t.Errorf("Radiotap VHT %+v", rt.VHT)
+	}
+	if !rt.VHT.MCSNSS[0].Present() || rt.VHT.MCSNSS[0] != 0x92 || rt.VHT.MCSNSS[1].Present() {
+		t.Errorf("Radiotap VHT MCS/NSS %v", rt.VHT.MCSNSS)
+	}
+	if rt.Timestamp.Timestamp != 0x0123456789 || rt.Timestamp.Unit() != RadioTapTimestampUnitMicroseconds || rt.Timestamp.SamplingPosition() != 0 {
+		t.Errorf("Radiotap timestamp %+v", rt.Timestamp)
+	}
+	if !reflect.DeepEqual(rt.Antennas, []RadioTapAntenna{{Antenna: 1, DBMAntennaSignal: -61}}) {
+		t.Errorf("Radiotap antennas %+v", rt.Antennas)
+	}
+	wantVendor := []RadioTapVendorNamespace{{OUI: [3]byte{0x00, 0x13, 0x74}, Data: []byte{1, 2, 3, 4}}}
+	if !reflect.DeepEqual(rt.VendorNamespaces, wantVendor) {
+		t.Errorf("Radiotap vendor namespaces %+v, want %+v", rt.VendorNamespaces, wantVendor)
+	}
+	testRadioTapSerialization(t, rt, testPacketRadiotapVHT)
+}
+
+func testRadioTapSerialization(t *testing.T, rt *RadioTap, data []byte) {
+	buf := gopacket.NewSerializeBuffer()
+	if err := rt.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if want := data[:rt.Length]; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Radiotap serialization mismatch:\ngot  %x\nwant %x", buf.Bytes(), want)
+	}
+}
+
+func TestRadiotapUnknownField(t *testing.T) {
+	// Flags, then the TLV field, whose layout isn't known.
+	data := []byte{0x00, 0x00, 0x0d, 0x00, 0x02, 0x00, 0x00, 0x10, 0x10, 0xaa, 0xbb, 0xcc, 0xdd}
+	var rt RadioTap
+	if err := rt.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	want := []RadioTapField{{Namespace: 0, Bit: 28, Data: []byte{0xaa, 0xbb, 0xcc, 0xdd}}}
+	if rt.Flags != 0x10 || !reflect.DeepEqual(rt.Fields, want) {
+		t.Errorf("Radiotap unknown field %+v", rt.Fields)
+	}
+	testRadioTapSerialization(t, &rt, data)
+}
+
+func TestRadiotapMalformed(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"short header", testPacketRadiotap0[:7]},
+		{"length too long", testPacketRadiotap0[:17]},
+		{"presence words truncated", []byte{0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x80, 0x00, 0x00}},
+		{"field truncated", []byte{0x00, 0x00, 0x0a, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"vendor namespace truncated", []byte{
+			0x00, 0x00, 0x12, 0x00, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x13, 0x74, 0x00, 0x04, 0x00,
+		}},
+	} {
+		var rt RadioTap
+		if err := rt.DecodeFromBytes(c.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+const radiusHeaderLength = 20
+
+// RADIUSCode is the type of a RADIUS packet, from RFC 2865, RFC 2866 and
+// RFC 5176.
+type RADIUSCode uint8
+
+const (
+	RADIUSCodeAccessRequest      RADIUSCode = 1
+	RADIUSCodeAccessAccept       RADIUSCode = 2
+	RADIUSCodeAccessReject       RADIUSCode = 3
+	RADIUSCodeAccountingRequest  RADIUSCode = 4
+	RADIUSCodeAccountingResponse RADIUSCode = 5
+	RADIUSCodeAccessChallenge    RADIUSCode = 11
+	RADIUSCodeStatusServer       RADIUSCode = 12
+	RADIUSCodeStatusClient       RADIUSCode = 13
+	RADIUSCodeDisconnectRequest  RADIUSCode = 40
+	RADIUSCodeDisconnectACK      RADIUSCode = 41
+	RADIUSCodeDisconnectNAK      RADIUSCode = 42
+	RADIUSCodeCoARequest         RADIUSCode = 43
+	RADIUSCodeCoAACK             RADIUSCode = 44
+	RADIUSCodeCoANAK             RADIUSCode = 45
+)
+
+func (c RADIUSCode) String() string {
+	switch c {
+	case RADIUSCodeAccessRequest:
+		return "Access-Request"
+	case RADIUSCodeAccessAccept:
+		return "Access-Accept"
+	case RADIUSCodeAccessReject:
+		return "Access-Reject"
+	case RADIUSCodeAccountingRequest:
+		return "Accounting-Request"
+	case RADIUSCodeAccountingResponse:
+		return "Accounting-Response"
+	case RADIUSCodeAccessChallenge:
+		return "Access-Challenge"
+	case RADIUSCodeStatusServer:
+		return "Status-Server"
+	case RADIUSCodeStatusClient:
+		return "Status-Client"
+	case RADIUSCodeDisconnectRequest:
+		return "Disconnect-Request"
+	case RADIUSCodeDisconnectACK:
+		return "Disconnect-ACK"
+	case RADIUSCodeDisconnectNAK:
+		return "Disconnect-NAK"
+	case RADIUSCodeCoARequest:
+		return "CoA-Request"
+	case RADIUSCodeCoAACK:
+		return "CoA-ACK"
+	case RADIUSCodeCoANAK:
+		return "CoA-NAK"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// RADIUSAttributeType is the type of a RADIUS attribute.
+type RADIUSAttributeType uint8
+
+const (
+	RADIUSAttributeTypeUserName             RADIUSAttributeType = 1
+	RADIUSAttributeTypeUserPassword         RADIUSAttributeType = 2
+	RADIUSAttributeTypeCHAPPassword         RADIUSAttributeType = 3
+	RADIUSAttributeTypeNASIPAddress         RADIUSAttributeType = 4
+	RADIUSAttributeTypeNASPort              RADIUSAttributeType = 5
+	RADIUSAttributeTypeServiceType          RADIUSAttributeType = 6
+	RADIUSAttributeTypeFramedProtocol       RADIUSAttributeType = 7
+	RADIUSAttributeTypeFramedIPAddress      RADIUSAttributeType = 8
+	RADIUSAttributeTypeFramedIPNetmask      RADIUSAttributeType = 9
+	RADIUSAttributeTypeFramedMTU            RADIUSAttributeType = 12
+	RADIUSAttributeTypeReplyMessage         RADIUSAttributeType = 18
+	RADIUSAttributeTypeState                RADIUSAttributeType = 24
+	RADIUSAttributeTypeClass                RADIUSAttributeType = 25
+	RADIUSAttributeTypeVendorSpecific       RADIUSAttributeType = 26
+	RADIUSAttributeTypeSessionTimeout       RADIUSAttributeType = 27
+	RADIUSAttributeTypeIdleTimeout          RADIUSAttributeType = 28
+	RADIUSAttributeTypeCalledStationID      RADIUSAttributeType = 30
+	RADIUSAttributeTypeCallingStationID     RADIUSAttributeType = 31
+	RADIUSAttributeTypeNASIdentifier        RADIUSAttributeType = 32
+	RADIUSAttributeTypeAcctStatusType       RADIUSAttributeType = 40
+	RADIUSAttributeTypeAcctDelayTime        RADIUSAttributeType = 41
+	RADIUSAttributeTypeAcctInputOctets      RADIUSAttributeType = 42
+	RADIUSAttributeTypeAcctOutputOctets     RADIUSAttributeType = 43
+	RADIUSAttributeTypeAcctSessionID        RADIUSAttributeType = 44
+	RADIUSAttributeTypeAcctAuthentic        RADIUSAttributeType = 45
+	RADIUSAttributeTypeAcctSessionTime      RADIUSAttributeType = 46
+	RADIUSAttributeTypeAcctInputPackets     RADIUSAttributeType = 47
+	RADIUSAttributeTypeAcctOutputPackets    RADIUSAttributeType = 48
+	RADIUSAttributeTypeAcctTerminateCause   RADIUSAttributeType = 49
+	RADIUSAttributeTypeNASPortType          RADIUSAttributeType = 61
+	RADIUSAttributeTypeEAPMessage           RADIUSAttributeType = 79
+	RADIUSAttributeTypeMessageAuthenticator RADIUSAttributeType = 80
+	RADIUSAttributeTypeNASPortID            RADIUSAttributeType = 87
+)
+
+func (t RADIUSAttributeType) String() string {
+	switch t {
+	case RADIUSAttributeTypeUserName:
+		return "User-Name"
+	case RADIUSAttributeTypeUserPassword:
+		return "User-Password"
+	case RADIUSAttributeTypeCHAPPassword:
+		return "CHAP-Password"
+	case RADIUSAttributeTypeNASIPAddress:
+		return "NAS-IP-Address"
+	case RADIUSAttributeTypeNASPort:
+		return "NAS-Port"
+	case RADIUSAttributeTypeServiceType:
+		return "Service-Type"
+	case RADIUSAttributeTypeFramedProtocol:
+		return "Framed-Protocol"
+	case RADIUSAttributeTypeFramedIPAddress:
+		return "Framed-IP-Address"
+	case RADIUSAttributeTypeFramedIPNetmask:
+		return "Framed-IP-Netmask"
+	case RADIUSAttributeTypeFramedMTU:
+		return "Framed-MTU"
+	case RADIUSAttributeTypeReplyMessage:
+		return "Reply-Message"
+	case RADIUSAttributeTypeState:
+		return "State"
+	case RADIUSAttributeTypeClass:
+		return "Class"
+	case RADIUSAttributeTypeVendorSpecific:
+		return "Vendor-Specific"
+	case RADIUSAttributeTypeSessionTimeout:
+		return "Session-Timeout"
+	case RADIUSAttributeTypeIdleTimeout:
+		return "Idle-Timeout"
+	case RADIUSAttributeTypeCalledStationID:
+		return "Called-Station-Id"
+	case RADIUSAttributeTypeCallingStationID:
+		return "Calling-Station-Id"
+	case RADIUSAttributeTypeNASIdentifier:
+		return "NAS-Identifier"
+	case RADIUSAttributeTypeAcctStatusType:
+		return "Acct-Status-Type"
+	case RADIUSAttributeTypeAcctDelayTime:
+		return "Acct-Delay-Time"
+	case RADIUSAttributeTypeAcctInputOctets:
+		return "Acct-Input-Octets"
+	case RADIUSAttributeTypeAcctOutputOctets:
+		return "Acct-Output-Octets"
+	case RADIUSAttributeTypeAcctSessionID:
+		return "Acct-Session-Id"
+	case RADIUSAttributeTypeAcctAuthentic:
+		return "Acct-Authentic"
+	case RADIUSAttributeTypeAcctSessionTime:
+		return "Acct-Session-Time"
+	case RADIUSAttributeTypeAcctInputPackets:
+		return "Acct-Input-Packets"
+	case RADIUSAttributeTypeAcctOutputPackets:
+		return "Acct-Output-Packets"
+	case RADIUSAttributeTypeAcctTerminateCause:
+		return "Acct-Terminate-Cause"
+	case RADIUSAttributeTypeNASPortType:
+		return "NAS-Port-Type"
+	case RADIUSAttributeTypeEAPMessage:
+		return "EAP-Message"
+	case RADIUSAttributeTypeMessageAuthenticator:
+		return "Message-Authenticator"
+	case RADIUSAttributeTypeNASPortID:
+		return "NAS-Port-Id"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// RADIUSAcctStatusType is the value of an Acct-Status-Type attribute (RFC
+// 2866 section 5.1).
+type RADIUSAcctStatusType uint32
+
+const (
+	RADIUSAcctStatusTypeStart         RADIUSAcctStatusType = 1
+	RADIUSAcctStatusTypeStop          RADIUSAcctStatusType = 2
+	RADIUSAcctStatusTypeInterimUpdate RADIUSAcctStatusType = 3
+	RADIUSAcctStatusTypeAccountingOn  RADIUSAcctStatusType = 7
+	RADIUSAcctStatusTypeAccountingOff RADIUSAcctStatusType = 8
+)
+
+func (s RADIUSAcctStatusType) String() string {
+	switch s {
+	case RADIUSAcctStatusTypeStart:
+		return "Start"
+	case RADIUSAcctStatusTypeStop:
+		return "Stop"
+	case RADIUSAcctStatusTypeInterimUpdate:
+		return "Interim-Update"
+	case RADIUSAcctStatusTypeAccountingOn:
+		return "Accounting-On"
+	case RADIUSAcctStatusTypeAccountingOff:
+		return "Accounting-Off"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint32(s))
+	}
+}
+
+// SMI network management private enterprise codes of common vendors, used in
+// Vendor-Specific attributes.
+const (
+	RADIUSVendorCisco     uint32 = 9
+	RADIUSVendorMicrosoft uint32 = 311
+	RADIUSVendorJuniper   uint32 = 2636
+	RADIUSVendor3GPP      uint32 = 10415
+	RADIUSVendorWISPr     uint32 = 14122
+	RADIUSVendorAruba     uint32 = 14823
+)
+
+// radiusStandardVSAVendors lists the vendors known to use the vendor type,
+// vendor length format for their sub-attributes suggested by RFC 2865.
+var radiusStandardVSAVendors = map[uint32]bool{
+	RADIUSVendorCisco:     true,
+	RADIUSVendorMicrosoft: true,
+	RADIUSVendorJuniper:   true,
+	RADIUSVendor3GPP:      true,
+	RADIUSVendorWISPr:     true,
+	RADIUSVendorAruba:     true,
+}
+
+// RADIUSAttribute is a single RADIUS attribute.  Length includes the 2 byte
+// attribute header.
+type RADIUSAttribute struct {
+	Type   RADIUSAttributeType
+	Length uint8
+	Value  []byte
+}
+
+func (a RADIUSAttribute) checkType(t RADIUSAttributeType) error {
+	if a.Type != t {
+		return fmt.Errorf("cannot decode %s attribute as %s", a.Type, t)
+	}
+	return nil
+}
+
+func (a RADIUSAttribute) ipv4(t RADIUSAttributeType) (net.IP, error) {
+	if err := a.checkType(t); err != nil {
+		return nil, err
+	}
+	if len(a.Value) != 4 {
+		return nil, fmt.Errorf("invalid %s attribute length %d", a.Type, len(a.Value))
+	}
+	return net.IP(a.Value), nil
+}
+
+// UserName returns the value of a User-Name attribute.
+func (a RADIUSAttribute) UserName() (string, error) {
+	if err := a.checkType(RADIUSAttributeTypeUserName); err != nil {
+		return "", err
+	}
+	return string(a.Value), nil
+}
+
+// NASIPAddress returns the value of a NAS-IP-Address attribute.
+func (a RADIUSAttribute) NASIPAddress() (net.IP, error) {
+	return a.ipv4(RADIUSAttributeTypeNASIPAddress)
+}
+
+// FramedIPAddress returns the value of a Framed-IP-Address attribute.
+func (a RADIUSAttribute) FramedIPAddress() (net.IP, error) {
+	return a.ipv4(RADIUSAttributeTypeFramedIPAddress)
+}
+
+// CallingStationID returns the value of a Calling-Station-Id attribute,
+// usually the MAC address of an 802.1X supplicant.
+func (a RADIUSAttribute) CallingStationID() (string, error) {
+	if err := a.checkType(RADIUSAttributeTypeCallingStationID); err != nil {
+		return "", err
+	}
+	return string(a.Value), nil
+}
+
+// AcctStatusType returns the value of an Acct-Status-Type attribute.
+func (a RADIUSAttribute) AcctStatusType() (RADIUSAcctStatusType, error) {
+	if err := a.checkType(RADIUSAttributeTypeAcctStatusType); err != nil {
+		return 0, err
+	}
+	if len(a.Value) != 4 {
+		return 0, fmt.Errorf("invalid %s attribute length %d", a.Type, len(a.Value))
+	}
+	return RADIUSAcctStatusType(binary.BigEndian.Uint32(a.Value)), nil
+}
+
+// RADIUSVendorAttribute is a sub-attribute of a Vendor-Specific attribute.
+type RADIUSVendorAttribute struct {
+	Type  uint8
+	Value []byte
+}
+
+// RADIUSVendorSpecific is the value of a Vendor-Specific attribute.  Data
+// holds everything after the vendor ID.  For vendors known to use the
+// format suggested by RFC 2865 it's also split into Attributes.
+type RADIUSVendorSpecific struct {
+	VendorID   uint32
+	Data       []byte
+	Attributes []RADIUSVendorAttribute
+}
+
+// VendorSpecific decodes a Vendor-Specific attribute.
+func (a RADIUSAttribute) VendorSpecific() (RADIUSVendorSpecific, error) {
+	var v RADIUSVendorSpecific
+	if err := a.checkType(RADIUSAttributeTypeVendorSpecific); err != nil {
+		return v, err
+	}
+	if len(a.Value) < 4 {
+		return v, fmt.Errorf("invalid %s attribute length %d", a.Type, len(a.Value))
+	}
+	v.VendorID = binary.BigEndian.Uint32(a.Value[0:4])
+	v.Data = a.Value[4:]
+	if !radiusStandardVSAVendors[v.VendorID] {
+		return v, nil
+	}
+	for data := v.Data; len(data) > 0; {
+		if len(data) < 2 || data[1] < 2 || int(data[1]) > len(data) {
+			return v, fmt.Errorf("invalid sub-attribute in vendor %d attribute", v.VendorID)
+		}
+		v.Attributes = append(v.Attributes, RADIUSVendorAttribute{Type: data[0], Value: data[2:data[1]]})
+		data = data[data[1]:]
+	}
+	return v, nil
+}
+
+// Attribute returns a Vendor-Specific attribute holding v.  Attributes are
+// encoded in the format suggested by RFC 2865 if set, otherwise Data is used.
+func (v RADIUSVendorSpecific) Attribute() (RADIUSAttribute, error) {
+	value := make([]byte, 4, 4+len(v.Data))
+	binary.BigEndian.PutUint32(value, v.VendorID)
+	if v.Attributes == nil {
+		value = append(value, v.Data...)
+	}
+	for _, sub := range v.Attributes {
+		if len(sub.Value) > 253 {
+			return RADIUSAttribute{}, fmt.Errorf("vendor sub-attribute %d too long", sub.Type)
+		}
+		value = append(value, sub.Type, byte(2+len(sub.Value)))
+		value = append(value, sub.Value...)
+	}
+	if len(value) > 253 {
+		return RADIUSAttribute{}, errors.New("Vendor-Specific attribute too long")
+	}
+	return RADIUSAttribute{Type: RADIUSAttributeTypeVendorSpecific, Length: uint8(2 + len(value)), Value: value}, nil
+}
+
+// RADIUS is a RADIUS packet, as defined in RFC 2865 (authentication) and RFC
+// 2866 (accounting).
+type RADIUS struct {
+	BaseLayer
+	Code          RADIUSCode
+	Identifier    uint8
+	Length        uint16
+	Authenticator [16]byte
+	Attributes    []RADIUSAttribute
+}
+
+// LayerType returns LayerTypeRADIUS.
+func (r *RADIUS) LayerType() gopacket.LayerType { return LayerTypeRADIUS }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (r *RADIUS) CanDecode() gopacket.LayerClass { return LayerTypeRADIUS }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (r *RADIUS) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since RADIUS packets carry no payload.
+func (r *RADIUS) Payload() []byte { return nil }
+
+// Attribute returns the first attribute of the given type.
+func (r *RADIUS) Attribute(t RADIUSAttributeType) (RADIUSAttribute, bool) {
+	for _, a := range r.Attributes {
+		if a.Type == t {
+			return a, true
+		}
+	}
+	return RADIUSAttribute{}, false
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.  Bytes past the
+// length given in the header are padding, and ignored.
+func (r *RADIUS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < radiusHeaderLength {
+		df.SetTruncated()
+		return errors.New("RADIUS packet too short")
+	}
+	r.Code = RADIUSCode(data[0])
+	r.Identifier = data[1]
+	r.Length = binary.BigEndian.Uint16(data[2:4])
+	if r.Length < radiusHeaderLength {
+		return fmt.Errorf("RADIUS length %d too short", r.Length)
+	}
+	if int(r.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("RADIUS length %d exceeds %d available bytes", r.Length, len(data))
+	}
+	copy(r.Authenticator[:], data[4:20])
+
+	r.Attributes = r.Attributes[:0]
+	for offset := radiusHeaderLength; offset < int(r.Length); {
+		if offset+2 > int(r.Length) {
+			return fmt.Errorf("RADIUS attribute at offset %d truncated", offset)
+		}
+		a := RADIUSAttribute{
+			Type:   RADIUSAttributeType(data[offset]),
+			Length: data[offset+1],
+		}
+		if a.Length < 2 {
+			return fmt.Errorf("RADIUS attribute at offset %d has invalid length %d", offset, a.Length)
+		}
+		end := offset + int(a.Length)
+		if end > int(r.Length) {
+			return fmt.Errorf("RADIUS attribute at offset %d with length %d runs past the packet", offset, a.Length)
+		}
+		a.Value = data[offset+2 : end]
+		r.Attributes = append(r.Attributes, a)
+		offset = end
+	}
+
+	r.BaseLayer = BaseLayer{Contents: data[:r.Length]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// The Authenticator is written as is; computing it is left to the caller,
+// since it depends on the shared secret.
+func (r *RADIUS) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := radiusHeaderLength
+	for _, a := range r.Attributes {
+		if len(a.Value) > 253 {
+			return fmt.Errorf("RADIUS %s attribute too long: %d bytes", a.Type, len(a.Value))
+		}
+		length += 2 + len(a.Value)
+	}
+	if length > 4096 {
+		return fmt.Errorf("RADIUS packet too long: %d bytes", length)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		r.Length = uint16(length)
+	}
+	bytes[0] = byte(r.Code)
+	bytes[1] = r.Identifier
+	binary.BigEndian.PutUint16(bytes[2:4], r.Length)
+	copy(bytes[4:20], r.Authenticator[:])
+	offset := radiusHeaderLength
+	for i := range r.Attributes {
+		a := &r.Attributes[i]
+		if opts.FixLengths {
+			a.Length = uint8(2 + len(a.Value))
+		}
+		bytes[offset] = byte(a.Type)
+		bytes[offset+1] = a.Length
+		copy(bytes[offset+2:], a.Value)
+		offset += 2 + len(a.Value)
+	}
+	return nil
+}
+
+func decodeRADIUS(data []byte, p gopacket.PacketBuilder) error {
+	r := &RADIUS{}
+	if err := r.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(r)
+	p.SetApplicationLayer(r)
+	return nil
+}
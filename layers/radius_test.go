@@ -0,0 +1,174 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketRADIUSAccountingStart is an Accounting-Request starting a
+// session, with User-Name, NAS-IP-Address, Framed-IP-Address,
+// Calling-Station-Id and a Cisco audit-session-id AV pair.
+var testPacketRADIUSAccountingStart = []byte{
+	0x04, 0x2a, 0x00, 0x5c, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+	0x1c, 0x1d, 0x1e, 0x1f, 0x28, 0x06, 0x00, 0x00, 0x00, 0x01, 0x01, 0x07, 0x61, 0x6c, 0x69, 0x63,
+	0x65, 0x04, 0x06, 0x0a, 0x00, 0x00, 0x01, 0x08, 0x06, 0xc0, 0xa8, 0x01, 0x32, 0x1f, 0x13, 0x30,
+	0x30, 0x2d, 0x31, 0x31, 0x2d, 0x32, 0x32, 0x2d, 0x33, 0x33, 0x2d, 0x34, 0x34, 0x2d, 0x35, 0x35,
+	0x1a, 0x1c, 0x00, 0x00, 0x00, 0x09, 0x01, 0x16, 0x61, 0x75, 0x64, 0x69, 0x74, 0x2d, 0x73, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x2d, 0x69, 0x64, 0x3d, 0x61, 0x62, 0x63,
+}
+
+func TestRADIUSAccountingRequest(t *testing.T) {
+	p := gopacket.NewPacket(testPacketRADIUSAccountingStart, LayerTypeRADIUS, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeRADIUS}, t)
+	r := p.Layer(LayerTypeRADIUS).(*RADIUS)
+	if r.Code != RADIUSCodeAccountingRequest || r.Identifier != 0x2a || r.Length != 92 {
+		t.Errorf("header: got code %v identifier %d length %d", r.Code, r.Identifier, r.Length)
+	}
+	if !bytes.Equal(r.Authenticator[:], testPacketRADIUSAccountingStart[4:20]) {
+		t.Errorf("authenticator: got %x", r.Authenticator)
+	}
+	if len(r.Attributes) != 6 {
+		t.Fatalf("got %d attributes, want 6", len(r.Attributes))
+	}
+
+	if s, err := r.Attributes[0].AcctStatusType(); err != nil {
+		t.Error(err)
+	} else if s != RADIUSAcctStatusTypeStart {
+		t.Errorf("Acct-Status-Type: got %v", s)
+	}
+	if name, err := r.Attributes[1].UserName(); err != nil {
+		t.Error(err)
+	} else if name != "alice" {
+		t.Errorf("User-Name: got %q", name)
+	}
+	if ip, err := r.Attributes[2].NASIPAddress(); err != nil {
+		t.Error(err)
+	} else if !ip.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("NAS-IP-Address: got %v", ip)
+	}
+	if ip, err := r.Attributes[3].FramedIPAddress(); err != nil {
+		t.Error(err)
+	} else if !ip.Equal(net.IPv4(192, 168, 1, 50)) {
+		t.Errorf("Framed-IP-Address: got %v", ip)
+	}
+	a, ok := r.Attribute(RADIUSAttributeTypeCallingStationID)
+	if !ok {
+		t.Fatal("no Calling-Station-Id attribute")
+	}
+	if id, err := a.CallingStationID(); err != nil {
+		t.Error(err)
+	} else if id != "00-11-22-33-44-55" {
+		t.Errorf("Calling-Station-Id: got %q", id)
+	}
+	vsa, err := r.Attributes[5].VendorSpecific()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RADIUSVendorAttribute{{Type: 1, Value: []byte("audit-session-id=abc")}}
+	if vsa.VendorID != RADIUSVendorCisco || !reflect.DeepEqual(vsa.Attributes, want) {
+		t.Errorf("Vendor-Specific: got %+v", vsa)
+	}
+	if _, err := r.Attributes[1].NASIPAddress(); err == nil {
+		t.Error("expected error decoding User-Name as NAS-IP-Address")
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	if err := r.SerializeTo(buf, gopacket.SerializeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketRADIUSAccountingStart) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", testPacketRADIUSAccountingStart, buf.Bytes())
+	}
+}
+
+func TestRADIUSSerialize(t *testing.T) {
+	vsa, err := RADIUSVendorSpecific{
+		VendorID:   RADIUSVendorMicrosoft,
+		Attributes: []RADIUSVendorAttribute{{Type: 11, Value: []byte{1, 2, 3}}},
+	}.Attribute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &RADIUS{
+		Code:          RADIUSCodeAccessRequest,
+		Identifier:    7,
+		Authenticator: [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		Attributes: []RADIUSAttribute{
+			{Type: RADIUSAttributeTypeUserName, Value: []byte("bob")},
+			vsa,
+		},
+	}
+	ip := &IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: IPProtocolUDP,
+		SrcIP:    net.IP{10, 0, 0, 1},
+		DstIP:    net.IP{10, 0, 0, 2},
+	}
+	udp := &UDP{SrcPort: 50000, DstPort: 1812}
+	udp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, udp, r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Length != 20+5+11 || r.Attributes[0].Length != 5 {
+		t.Errorf("lengths not fixed: packet %d, attribute %d", r.Length, r.Attributes[0].Length)
+	}
+
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeIPv4, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeIPv4, LayerTypeUDP, LayerTypeRADIUS}, t)
+	got := p.Layer(LayerTypeRADIUS).(*RADIUS)
+	if got.Authenticator != r.Authenticator || len(got.Attributes) != 2 {
+		t.Fatalf("got %+v", got)
+	}
+	v, err := got.Attributes[1].VendorSpecific()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.VendorID != RADIUSVendorMicrosoft || len(v.Attributes) != 1 || !bytes.Equal(v.Attributes[0].Value, []byte{1, 2, 3}) {
+		t.Errorf("Vendor-Specific: got %+v", v)
+	}
+}
+
+func TestRADIUSBadAttributeLength(t *testing.T) {
+	for _, test := range []struct {
+		offset int
+		length byte
+		want   string
+	}{
+		{20, 1, "offset 20"},
+		{26, 0, "offset 26"},
+		{64, 0xff, "offset 64"},
+	} {
+		data := append([]byte(nil), testPacketRADIUSAccountingStart...)
+		data[test.offset+1] = test.length
+		r := &RADIUS{}
+		err := r.DecodeFromBytes(data, gopacket.NilDecodeFeedback)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("attribute length %d at %d: got error %v", test.length, test.offset, err)
+		}
+	}
+
+	r := &RADIUS{}
+	if err := r.DecodeFromBytes(testPacketRADIUSAccountingStart[:40], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated packet")
+	}
+}
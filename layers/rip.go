@@ -0,0 +1,436 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+)
+
+// RIPCommand is the command of a RIP or RIPng message.
+type RIPCommand uint8
+
+// RIPCommand known values.
+const (
+	RIPCommandRequest  RIPCommand = 1
+	RIPCommandResponse RIPCommand = 2
+)
+
+func (c RIPCommand) String() string {
+	switch c {
+	case RIPCommandRequest:
+		return "Request"
+	case RIPCommandResponse:
+		return "Response"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// RIPMetricInfinity is the metric of unreachable routes, such as poisoned
+// ones.
+const RIPMetricInfinity = 16
+
+// RIP address families.  RIPAddressFamilyAuthentication marks the
+// authentication entry of RIPv2 messages, which is decoded into
+// RIP.Authentication instead of an entry.
+const (
+	RIPAddressFamilyUnspecified    uint16 = 0
+	RIPAddressFamilyIP             uint16 = 2
+	RIPAddressFamilyAuthentication uint16 = 0xffff
+)
+
+// RIPAuthType is the type of RIPv2 authentication.
+type RIPAuthType uint16
+
+// RIPAuthType known values.  RIPAuthTypeCryptographic covers keyed MD5
+// [RFC2082] and the HMAC-SHA variants of RFC4822.
+const (
+	RIPAuthTypePassword      RIPAuthType = 2
+	RIPAuthTypeCryptographic RIPAuthType = 3
+)
+
+func (t RIPAuthType) String() string {
+	switch t {
+	case RIPAuthTypePassword:
+		return "Password"
+	case RIPAuthTypeCryptographic:
+		return "Cryptographic"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(t))
+	}
+}
+
+// ripEntryLength is the length of RIP and RIPng entries.
+const ripEntryLength = 20
+
+// RIPAuthentication is the authentication of a RIPv2 message.
+type RIPAuthentication struct {
+	Type RIPAuthType
+	// Password is the simple password, padded with zeros to 16 bytes, of
+	// password authentication, and the raw authentication data of unknown
+	// types.
+	Password []byte
+
+	// The remaining fields are only set for cryptographic authentication.
+	// PacketLength is the offset of the trailer holding AuthData, the
+	// digest.
+	PacketLength   uint16
+	KeyID          uint8
+	AuthDataLength uint8
+	SequenceNumber uint32
+	AuthData       []byte
+}
+
+// RIPEntry is a route entry of a RIP message.  RouteTag, Mask and NextHop
+// are zero in RIPv1 messages.
+type RIPEntry struct {
+	AddressFamily uint16
+	RouteTag      uint16
+	Network       net.IP
+	Mask          net.IPMask
+	NextHop       net.IP
+	Metric        uint32
+}
+
+// Unreachable returns whether the route is unreachable, having a metric of
+// RIPMetricInfinity or more, as poisoned routes do.
+func (e *RIPEntry) Unreachable() bool { return e.Metric >= RIPMetricInfinity }
+
+// RIP is a RIPv1 [RFC1058] or RIPv2 [RFC2453] message.
+//
+// A request for a router's whole table holds a single entry with address
+// family RIPAddressFamilyUnspecified and metric RIPMetricInfinity.
+type RIP struct {
+	BaseLayer
+	Command        RIPCommand
+	Version        uint8
+	Reserved       uint16
+	Authentication *RIPAuthentication
+	Entries        []RIPEntry
+}
+
+// LayerType returns LayerTypeRIP.
+func (r *RIP) LayerType() gopacket.LayerType { return LayerTypeRIP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (r *RIP) CanDecode() gopacket.LayerClass { return LayerTypeRIP }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (r *RIP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since RIP messages carry no payload.
+func (r *RIP) Payload() []byte { return nil }
+
+// UnreachableEntries returns the entries whose routes are unreachable.
+func (r *RIP) UnreachableEntries() []RIPEntry {
+	var entries []RIPEntry
+	for i := range r.Entries {
+		if r.Entries[i].Unreachable() {
+			entries = append(entries, r.Entries[i])
+		}
+	}
+	return entries
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (r *RIP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("RIP message too short")
+	}
+	*r = RIP{
+		BaseLayer: BaseLayer{Contents: data},
+		Command:   RIPCommand(data[0]),
+		Version:   data[1],
+		Reserved:  binary.BigEndian.Uint16(data[2:4]),
+	}
+	if r.Version != 1 && r.Version != 2 {
+		return fmt.Errorf("RIP version %d unsupported", r.Version)
+	}
+	entries := data[4:]
+	if r.Version == 2 && len(entries) >= ripEntryLength && binary.BigEndian.Uint16(entries) == RIPAddressFamilyAuthentication {
+		a := &RIPAuthentication{Type: RIPAuthType(binary.BigEndian.Uint16(entries[2:4]))}
+		if a.Type == RIPAuthTypeCryptographic {
+			a.PacketLength = binary.BigEndian.Uint16(entries[4:6])
+			a.KeyID = entries[6]
+			a.AuthDataLength = entries[7]
+			a.SequenceNumber = binary.BigEndian.Uint32(entries[8:12])
+			// The trailer is led by an address family and type, 0xffff
+			// and 1.
+			end := int(a.PacketLength)
+			if end < 4+ripEntryLength || end+4 > len(data) {
+				return fmt.Errorf("RIP authentication packet length %d invalid", a.PacketLength)
+			}
+			a.AuthData = data[end+4:]
+			entries = data[4:end]
+		} else {
+			a.Password = entries[4:ripEntryLength]
+		}
+		r.Authentication = a
+		entries = entries[ripEntryLength:]
+	}
+	if len(entries)%ripEntryLength != 0 {
+		return fmt.Errorf("RIP entries of %d bytes not a multiple of %d", len(entries), ripEntryLength)
+	}
+	for ; len(entries) > 0; entries = entries[ripEntryLength:] {
+		r.Entries = append(r.Entries, RIPEntry{
+			AddressFamily: binary.BigEndian.Uint16(entries[0:2]),
+			RouteTag:      binary.BigEndian.Uint16(entries[2:4]),
+			Network:       net.IP(entries[4:8]),
+			Mask:          net.IPMask(entries[8:12]),
+			NextHop:       net.IP(entries[12:16]),
+			Metric:        binary.BigEndian.Uint32(entries[16:20]),
+		})
+	}
+	return nil
+}
+
+// putRIPAddress writes the IPv4 address ip, if any, to b.
+func putRIPAddress(b []byte, ip []byte) error {
+	if len(ip) == 0 {
+		return nil
+	}
+	if len(ip) == net.IPv6len {
+		if ip = net.IP(ip).To4(); ip == nil {
+			return errors.New("RIP entry holds an IPv6 address")
+		}
+	}
+	if len(ip) != net.IPv4len {
+		return fmt.Errorf("RIP entry address of %d bytes invalid", len(ip))
+	}
+	copy(b, ip)
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// The digest of cryptographic authentication isn't computed: AuthData is
+// written as it is.  With FixLengths, PacketLength is set.
+func (r *RIP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	a := r.Authentication
+	n := len(r.Entries)
+	if a != nil {
+		n++
+	}
+	length := 4 + n*ripEntryLength
+	if a != nil && a.Type == RIPAuthTypeCryptographic {
+		if opts.FixLengths {
+			a.PacketLength = uint16(length)
+		}
+		length += 4 + len(a.AuthData)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	for i := range bytes {
+		bytes[i] = 0
+	}
+	bytes[0] = uint8(r.Command)
+	bytes[1] = r.Version
+	binary.BigEndian.PutUint16(bytes[2:4], r.Reserved)
+	e := bytes[4:]
+	if a != nil {
+		binary.BigEndian.PutUint16(e[0:2], RIPAddressFamilyAuthentication)
+		binary.BigEndian.PutUint16(e[2:4], uint16(a.Type))
+		if a.Type == RIPAuthTypeCryptographic {
+			binary.BigEndian.PutUint16(e[4:6], a.PacketLength)
+			e[6] = a.KeyID
+			e[7] = a.AuthDataLength
+			binary.BigEndian.PutUint32(e[8:12], a.SequenceNumber)
+			t := bytes[4+n*ripEntryLength:]
+			binary.BigEndian.PutUint16(t[0:2], RIPAddressFamilyAuthentication)
+			binary.BigEndian.PutUint16(t[2:4], 1)
+			copy(t[4:], a.AuthData)
+		} else {
+			if len(a.Password) > 16 {
+				return errors.New("RIP password longer than 16 bytes")
+			}
+			copy(e[4:ripEntryLength], a.Password)
+		}
+		e = e[ripEntryLength:]
+	}
+	for i := range r.Entries {
+		entry := &r.Entries[i]
+		binary.BigEndian.PutUint16(e[0:2], entry.AddressFamily)
+		binary.BigEndian.PutUint16(e[2:4], entry.RouteTag)
+		for _, f := range []struct {
+			b  []byte
+			ip []byte
+		}{{e[4:8], entry.Network}, {e[8:12], entry.Mask}, {e[12:16], entry.NextHop}} {
+			if err := putRIPAddress(f.b, f.ip); err != nil {
+				return err
+			}
+		}
+		binary.BigEndian.PutUint32(e[16:20], entry.Metric)
+		e = e[ripEntryLength:]
+	}
+	return nil
+}
+
+func decodeRIP(data []byte, p gopacket.PacketBuilder) error {
+	r := &RIP{}
+	if err := r.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(r)
+	p.SetApplicationLayer(r)
+	return nil
+}
+
+// RIPngNextHopMetric is the metric of RIPng next hop entries.
+const RIPngNextHopMetric = 0xff
+
+// RIPngEntry is a route table entry of a RIPng message, or a next hop
+// entry, whose Metric is RIPngNextHopMetric and Prefix the next hop of the
+// entries following it.
+type RIPngEntry struct {
+	Prefix       net.IP
+	RouteTag     uint16
+	PrefixLength uint8
+	Metric       uint8
+
+	// NextHop is the next hop of route table entries, from the last next
+	// hop entry before them, and nil if there's none or it's the
+	// unspecified address.  It's ignored when serializing.
+	NextHop net.IP
+}
+
+// IsNextHop returns whether the entry is a next hop entry.
+func (e *RIPngEntry) IsNextHop() bool { return e.Metric == RIPngNextHopMetric }
+
+// Unreachable returns whether the entry is a route table entry whose route
+// is unreachable, having a metric of RIPMetricInfinity.
+func (e *RIPngEntry) Unreachable() bool {
+	return e.Metric >= RIPMetricInfinity && !e.IsNextHop()
+}
+
+// RIPng is a RIPng message [RFC2080].
+//
+// A request for a router's whole table holds a single entry with a zero
+// prefix and metric RIPMetricInfinity.
+type RIPng struct {
+	BaseLayer
+	Command  RIPCommand
+	Version  uint8
+	Reserved uint16
+	Entries  []RIPngEntry
+}
+
+// LayerType returns LayerTypeRIPng.
+func (r *RIPng) LayerType() gopacket.LayerType { return LayerTypeRIPng }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (r *RIPng) CanDecode() gopacket.LayerClass { return LayerTypeRIPng }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (r *RIPng) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since RIPng messages carry no payload.
+func (r *RIPng) Payload() []byte { return nil }
+
+// UnreachableEntries returns the route table entries whose routes are
+// unreachable.
+func (r *RIPng) UnreachableEntries() []RIPngEntry {
+	var entries []RIPngEntry
+	for i := range r.Entries {
+		if r.Entries[i].Unreachable() {
+			entries = append(entries, r.Entries[i])
+		}
+	}
+	return entries
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (r *RIPng) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		df.SetTruncated()
+		return errors.New("RIPng message too short")
+	}
+	*r = RIPng{
+		BaseLayer: BaseLayer{Contents: data},
+		Command:   RIPCommand(data[0]),
+		Version:   data[1],
+		Reserved:  binary.BigEndian.Uint16(data[2:4]),
+	}
+	if r.Version != 1 {
+		return fmt.Errorf("RIPng version %d unsupported", r.Version)
+	}
+	entries := data[4:]
+	if len(entries)%ripEntryLength != 0 {
+		return fmt.Errorf("RIPng entries of %d bytes not a multiple of %d", len(entries), ripEntryLength)
+	}
+	var nextHop net.IP
+	for ; len(entries) > 0; entries = entries[ripEntryLength:] {
+		e := RIPngEntry{
+			Prefix:       net.IP(entries[0:16]),
+			RouteTag:     binary.BigEndian.Uint16(entries[16:18]),
+			PrefixLength: entries[18],
+			Metric:       entries[19],
+		}
+		if e.IsNextHop() {
+			nextHop = e.Prefix
+			if nextHop.IsUnspecified() {
+				nextHop = nil
+			}
+		} else {
+			if e.PrefixLength > 128 {
+				return fmt.Errorf("RIPng prefix length %d invalid", e.PrefixLength)
+			}
+			e.NextHop = nextHop
+		}
+		r.Entries = append(r.Entries, e)
+	}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (r *RIPng) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(4 + len(r.Entries)*ripEntryLength)
+	if err != nil {
+		return err
+	}
+	bytes[0] = uint8(r.Command)
+	bytes[1] = r.Version
+	binary.BigEndian.PutUint16(bytes[2:4], r.Reserved)
+	e := bytes[4:]
+	for i := range r.Entries {
+		entry := &r.Entries[i]
+		prefix := entry.Prefix.To16()
+		if prefix == nil {
+			if len(entry.Prefix) != 0 {
+				return fmt.Errorf("RIPng prefix %v invalid", entry.Prefix)
+			}
+			prefix = net.IPv6unspecified
+		}
+		copy(e[0:16], prefix)
+		binary.BigEndian.PutUint16(e[16:18], entry.RouteTag)
+		e[18] = entry.PrefixLength
+		e[19] = entry.Metric
+		e = e[ripEntryLength:]
+	}
+	return nil
+}
+
+func decodeRIPng(data []byte, p gopacket.PacketBuilder) error {
+	r := &RIPng{}
+	if err := r.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(r)
+	p.SetApplicationLayer(r)
+	return nil
+}
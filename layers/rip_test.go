@@ -0,0 +1,224 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketRIPResponse is a RIPv2 response from 192.0.2.1 advertising
+// 10.1/16, 10.2.0/24 via 192.0.2.9 with route tag 7, and poisoning 10.3/16.
+var testPacketRIPResponse = []byte{
+	0x01, 0x00, 0x5e, 0x00, 0x00, 0x09, 0x02, 0x42, 0xc0, 0x00, 0x02, 0x01, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x5c, 0x01, 0x01, 0x40, 0x00, 0x40, 0x11, 0x97, 0x85, 0xc0, 0x00, 0x02, 0x01, 0xe0, 0x00,
+	0x00, 0x09, 0x02, 0x08, 0x02, 0x08, 0x00, 0x48, 0x78, 0x0f, 0x02, 0x02, 0x00, 0x00, 0x00, 0x02,
+	0x00, 0x00, 0x0a, 0x01, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x02, 0x00, 0x07, 0x0a, 0x02, 0x00, 0x00, 0xff, 0xff, 0xff, 0x00, 0xc0, 0x00,
+	0x02, 0x09, 0x00, 0x00, 0x00, 0x03, 0x00, 0x02, 0x00, 0x00, 0x0a, 0x03, 0x00, 0x00, 0xff, 0xff,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10,
+}
+
+// testPacketRIPRequest is a RIPv2 request for the whole routing table.
+var testPacketRIPRequest = []byte{
+	0x01, 0x00, 0x5e, 0x00, 0x00, 0x09, 0x02, 0x42, 0xc0, 0x00, 0x02, 0xc8, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x34, 0x00, 0x01, 0x40, 0x00, 0x40, 0x11, 0x97, 0xe6, 0xc0, 0x00, 0x02, 0xc8, 0xe0, 0x00,
+	0x00, 0x09, 0x02, 0x08, 0x02, 0x08, 0x00, 0x20, 0x57, 0xba, 0x01, 0x02, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x10,
+}
+
+// testPacketRIPngResponse is a RIPng response with two next hop entries,
+// the second of which resets the next hop to the sender.
+var testPacketRIPngResponse = []byte{
+	0x33, 0x33, 0x00, 0x00, 0x00, 0x09, 0x02, 0x42, 0xc0, 0x00, 0x02, 0x01, 0x86, 0xdd, 0x60, 0x00,
+	0x00, 0x00, 0x00, 0x84, 0x11, 0xff, 0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0xff, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x09, 0x02, 0x09, 0x02, 0x09, 0x00, 0x84, 0x53, 0xb2, 0x02, 0x01,
+	0x00, 0x00, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x40, 0x01, 0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0xff, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x02,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x30, 0x02, 0x20, 0x01,
+	0x0d, 0xb8, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x40, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40, 0x04,
+}
+
+func TestRIPResponse(t *testing.T) {
+	p := gopacket.NewPacket(testPacketRIPResponse, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeRIP}, t)
+	rip := p.Layer(LayerTypeRIP).(*RIP)
+	if rip.Command != RIPCommandResponse || rip.Version != 2 || rip.Authentication != nil || len(rip.Entries) != 3 {
+		t.Fatalf("Got RIP %v version %d authentication %v with %d entries", rip.Command, rip.Version, rip.Authentication, len(rip.Entries))
+	}
+	e := rip.Entries[1]
+	if e.AddressFamily != RIPAddressFamilyIP || e.RouteTag != 7 || !e.Network.Equal(net.IP{10, 2, 0, 0}) ||
+		e.Mask.String() != "ffffff00" || !e.NextHop.Equal(net.IP{192, 0, 2, 9}) || e.Metric != 3 || e.Unreachable() {
+		t.Errorf("Got entry %+v", e)
+	}
+	poisoned := rip.UnreachableEntries()
+	if len(poisoned) != 1 || !poisoned[0].Network.Equal(net.IP{10, 3, 0, 0}) {
+		t.Errorf("Got unreachable entries %+v", poisoned)
+	}
+	testSerialization(t, p, testPacketRIPResponse)
+}
+
+func TestRIPAuthentication(t *testing.T) {
+	md5 := []byte{
+		0x02, 0x02, 0x00, 0x00, 0xff, 0xff, 0x00, 0x03, 0x00, 0x2c, 0x01, 0x14, 0x5b, 0x8d, 0x1a, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x0a, 0x01, 0x00, 0x00,
+		0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xff, 0x00, 0x01,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+	}
+	p := gopacket.NewPacket(md5, LayerTypeRIP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	rip := p.Layer(LayerTypeRIP).(*RIP)
+	a := rip.Authentication
+	if a == nil || a.Type != RIPAuthTypeCryptographic || a.PacketLength != 44 || a.KeyID != 1 || a.AuthDataLength != 20 || a.SequenceNumber != 0x5b8d1a00 {
+		t.Fatalf("Got authentication %+v", a)
+	}
+	if !bytes.Equal(a.AuthData, md5[48:]) || len(rip.Entries) != 1 || rip.Entries[0].Metric != 2 {
+		t.Errorf("Got digest %v and entries %+v", a.AuthData, rip.Entries)
+	}
+	a.PacketLength = 0
+	buf := gopacket.NewSerializeBuffer()
+	if err := rip.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), md5) {
+		t.Errorf("Serialized\n%v\nwant\n%v", buf.Bytes(), md5)
+	}
+
+	password := []byte{
+		0x02, 0x02, 0x00, 0x00, 0xff, 0xff, 0x00, 0x02, 0x73, 0x65, 0x63, 0x72, 0x65, 0x74, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x0a, 0x01, 0x00, 0x00,
+		0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+	}
+	p = gopacket.NewPacket(password, LayerTypeRIP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	rip = p.Layer(LayerTypeRIP).(*RIP)
+	if a := rip.Authentication; a == nil || a.Type != RIPAuthTypePassword || string(bytes.TrimRight(a.Password, "\x00")) != "secret" {
+		t.Errorf("Got authentication %+v", a)
+	}
+	if len(rip.Entries) != 1 {
+		t.Errorf("Got entries %+v", rip.Entries)
+	}
+}
+
+func TestRIPv1(t *testing.T) {
+	v1 := []byte{
+		0x02, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+	}
+	p := gopacket.NewPacket(v1, LayerTypeRIP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	rip := p.Layer(LayerTypeRIP).(*RIP)
+	if rip.Version != 1 || len(rip.Entries) != 1 || !rip.Entries[0].Network.Equal(net.IP{10, 0, 0, 0}) || rip.Entries[0].Metric != 1 {
+		t.Errorf("Got RIPv1 %+v", rip)
+	}
+}
+
+func TestRIPSerializeRequest(t *testing.T) {
+	p := gopacket.NewPacket(testPacketRIPRequest, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeRIP}, t)
+
+	eth := p.Layer(LayerTypeEthernet).(*Ethernet)
+	ip := p.Layer(LayerTypeIPv4).(*IPv4)
+	udp := &UDP{SrcPort: 520, DstPort: 520}
+	udp.SetNetworkLayerForChecksum(ip)
+	rip := &RIP{
+		Command: RIPCommandRequest,
+		Version: 2,
+		Entries: []RIPEntry{{AddressFamily: RIPAddressFamilyUnspecified, Metric: RIPMetricInfinity}},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, udp, rip); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testPacketRIPRequest) {
+		t.Errorf("Serialized request\n%v\nwant\n%v", buf.Bytes(), testPacketRIPRequest)
+	}
+}
+
+func TestRIPng(t *testing.T) {
+	p := gopacket.NewPacket(testPacketRIPngResponse, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv6, LayerTypeUDP, LayerTypeRIPng}, t)
+	rip := p.Layer(LayerTypeRIPng).(*RIPng)
+	if rip.Command != RIPCommandResponse || rip.Version != 1 || len(rip.Entries) != 6 {
+		t.Fatalf("Got RIPng %v version %d with %d entries", rip.Command, rip.Version, len(rip.Entries))
+	}
+	nextHop := net.ParseIP("fe80::9")
+	for i, want := range []struct {
+		prefix  string
+		length  uint8
+		metric  uint8
+		tag     uint16
+		nextHop net.IP
+	}{
+		{"2001:db8:1::", 64, 1, 0, nil},
+		{"fe80::9", 0, RIPngNextHopMetric, 0, nil},
+		{"2001:db8:2::", 48, 2, 5, nextHop},
+		{"2001:db8:3::", 64, 16, 0, nextHop},
+		{"::", 0, RIPngNextHopMetric, 0, nil},
+		{"2001:db8:4::", 64, 4, 0, nil},
+	} {
+		e := rip.Entries[i]
+		if e.Prefix.String() != want.prefix || e.PrefixLength != want.length || e.Metric != want.metric || e.RouteTag != want.tag || !e.NextHop.Equal(want.nextHop) {
+			t.Errorf("Got entry %d %+v", i, e)
+		}
+	}
+	if !rip.Entries[1].IsNextHop() || rip.Entries[1].Unreachable() {
+		t.Error("Next hop entry not recognized")
+	}
+	unreachable := rip.UnreachableEntries()
+	if len(unreachable) != 1 || unreachable[0].Prefix.String() != "2001:db8:3::" {
+		t.Errorf("Got unreachable entries %+v", unreachable)
+	}
+	testSerialization(t, p, testPacketRIPngResponse)
+}
+
+func TestRIPMalformed(t *testing.T) {
+	for _, test := range []struct {
+		data []byte
+		lt   gopacket.LayerType
+	}{
+		{[]byte{0x02, 0x02, 0x00}, LayerTypeRIP},
+		{[]byte{0x02, 0x03, 0x00, 0x00}, LayerTypeRIP},
+		{[]byte{0x02, 0x02, 0x00, 0x00, 0x00, 0x02}, LayerTypeRIP},
+		// Cryptographic authentication trailer beyond the message
+		{append([]byte{0x02, 0x02, 0x00, 0x00, 0xff, 0xff, 0x00, 0x03, 0x00, 0x40}, make([]byte, 14)...), LayerTypeRIP},
+		{[]byte{0x02, 0x02, 0x00, 0x00}, LayerTypeRIPng},
+		{append([]byte{0x02, 0x01, 0x00, 0x00}, make([]byte, 19)...), LayerTypeRIPng},
+		// Prefix length over 128
+		{append(append([]byte{0x02, 0x01, 0x00, 0x00}, make([]byte, 18)...), 0x81, 0x01), LayerTypeRIPng},
+	} {
+		p := gopacket.NewPacket(test.data, test.lt, gopacket.Default)
+		if p.ErrorLayer() == nil {
+			t.Errorf("Decoded malformed %v message %v", test.lt, test.data)
+		}
+	}
+}
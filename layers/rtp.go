@@ -0,0 +1,708 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// RTP and RTCP have no well-known ports; sessions are set up out of band,
+// usually by SIP and SDP.  To have UDP decode them, map their ports with
+// RegisterUDPPortLayerType:
+//
+//	layers.RegisterUDPPortLayerType(16384, layers.LayerTypeRTP)
+//	layers.RegisterUDPPortLayerType(16385, layers.LayerTypeRTCP)
+//
+// Payloads failing ValidRTP or ValidRTCP then fall back to
+// gopacket.LayerTypePayload.  RTCP multiplexed on the RTP port (RFC 5761) is
+// recognized and decoded as RTCP.
+
+// RTPPayloadType is the payload type of an RTP packet.  Types 96 to 127 are
+// dynamic, and bound to an encoding by signalling.
+type RTPPayloadType uint8
+
+// Static payload types from RFC 3551.
+const (
+	RTPPayloadTypePCMU  RTPPayloadType = 0
+	RTPPayloadTypeGSM   RTPPayloadType = 3
+	RTPPayloadTypeG723  RTPPayloadType = 4
+	RTPPayloadTypeDVI4  RTPPayloadType = 5
+	RTPPayloadTypeLPC   RTPPayloadType = 7
+	RTPPayloadTypePCMA  RTPPayloadType = 8
+	RTPPayloadTypeG722  RTPPayloadType = 9
+	RTPPayloadTypeL16   RTPPayloadType = 10
+	RTPPayloadTypeQCELP RTPPayloadType = 12
+	RTPPayloadTypeCN    RTPPayloadType = 13
+	RTPPayloadTypeMPA   RTPPayloadType = 14
+	RTPPayloadTypeG728  RTPPayloadType = 15
+	RTPPayloadTypeG729  RTPPayloadType = 18
+	RTPPayloadTypeCelB  RTPPayloadType = 25
+	RTPPayloadTypeJPEG  RTPPayloadType = 26
+	RTPPayloadTypeNV    RTPPayloadType = 28
+	RTPPayloadTypeH261  RTPPayloadType = 31
+	RTPPayloadTypeMPV   RTPPayloadType = 32
+	RTPPayloadTypeMP2T  RTPPayloadType = 33
+	RTPPayloadTypeH263  RTPPayloadType = 34
+)
+
+func (t RTPPayloadType) String() string {
+	switch t {
+	case RTPPayloadTypePCMU:
+		return "PCMU"
+	case RTPPayloadTypeGSM:
+		return "GSM"
+	case RTPPayloadTypeG723:
+		return "G723"
+	case RTPPayloadTypeDVI4, 6, 16, 17:
+		return "DVI4"
+	case RTPPayloadTypeLPC:
+		return "LPC"
+	case RTPPayloadTypePCMA:
+		return "PCMA"
+	case RTPPayloadTypeG722:
+		return "G722"
+	case RTPPayloadTypeL16, 11:
+		return "L16"
+	case RTPPayloadTypeQCELP:
+		return "QCELP"
+	case RTPPayloadTypeCN:
+		return "CN"
+	case RTPPayloadTypeMPA:
+		return "MPA"
+	case RTPPayloadTypeG728:
+		return "G728"
+	case RTPPayloadTypeG729:
+		return "G729"
+	case RTPPayloadTypeCelB:
+		return "CelB"
+	case RTPPayloadTypeJPEG:
+		return "JPEG"
+	case RTPPayloadTypeNV:
+		return "nv"
+	case RTPPayloadTypeH261:
+		return "H261"
+	case RTPPayloadTypeMPV:
+		return "MPV"
+	case RTPPayloadTypeMP2T:
+		return "MP2T"
+	case RTPPayloadTypeH263:
+		return "H263"
+	}
+	if t >= 96 && t <= 127 {
+		return fmt.Sprintf("Dynamic(%d)", uint8(t))
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// RTP is a Real-time Transport Protocol packet, as described by RFC 3550.
+// Payload excludes any padding.
+type RTP struct {
+	BaseLayer
+	Version        uint8
+	Padding        bool
+	Extension      bool
+	CSRCCount      uint8
+	Marker         bool
+	PayloadType    RTPPayloadType
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	CSRCs          []uint32
+	// ExtensionProfile and ExtensionData make up the header extension, if
+	// Extension is set.  ExtensionData is a multiple of 4 bytes long.
+	ExtensionProfile uint16
+	ExtensionData    []byte
+	// PaddingLength is the number of padding bytes, including the last one
+	// which holds the count, if Padding is set.
+	PaddingLength uint8
+}
+
+// LayerType returns LayerTypeRTP.
+func (r *RTP) LayerType() gopacket.LayerType { return LayerTypeRTP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (r *RTP) CanDecode() gopacket.LayerClass { return LayerTypeRTP }
+
+// NextLayerType returns gopacket.LayerTypePayload.
+func (r *RTP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypePayload }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (r *RTP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 12 {
+		df.SetTruncated()
+		return errors.New("RTP header too short")
+	}
+	r.Version = data[0] >> 6
+	if r.Version != 2 {
+		return fmt.Errorf("invalid RTP version %d", r.Version)
+	}
+	r.Padding = data[0]&0x20 != 0
+	r.Extension = data[0]&0x10 != 0
+	r.CSRCCount = data[0] & 0x0f
+	r.Marker = data[1]&0x80 != 0
+	r.PayloadType = RTPPayloadType(data[1] & 0x7f)
+	r.SequenceNumber = binary.BigEndian.Uint16(data[2:4])
+	r.Timestamp = binary.BigEndian.Uint32(data[4:8])
+	r.SSRC = binary.BigEndian.Uint32(data[8:12])
+
+	offset := 12 + 4*int(r.CSRCCount)
+	if offset > len(data) {
+		df.SetTruncated()
+		return errors.New("RTP CSRC list truncated")
+	}
+	r.CSRCs = r.CSRCs[:0]
+	for i := 12; i < offset; i += 4 {
+		r.CSRCs = append(r.CSRCs, binary.BigEndian.Uint32(data[i:i+4]))
+	}
+
+	r.ExtensionProfile, r.ExtensionData = 0, nil
+	if r.Extension {
+		if offset+4 > len(data) {
+			df.SetTruncated()
+			return errors.New("RTP header extension truncated")
+		}
+		r.ExtensionProfile = binary.BigEndian.Uint16(data[offset : offset+2])
+		length := 4 * int(binary.BigEndian.Uint16(data[offset+2:offset+4]))
+		offset += 4
+		if offset+length > len(data) {
+			df.SetTruncated()
+			return errors.New("RTP header extension truncated")
+		}
+		r.ExtensionData = data[offset : offset+length]
+		offset += length
+	}
+
+	end := len(data)
+	r.PaddingLength = 0
+	if r.Padding {
+		r.PaddingLength = data[len(data)-1]
+		if r.PaddingLength == 0 || offset+int(r.PaddingLength) > len(data) {
+			return fmt.Errorf("invalid RTP padding length %d", r.PaddingLength)
+		}
+		end -= int(r.PaddingLength)
+	}
+	r.BaseLayer = BaseLayer{Contents: data[:offset], Payload: data[offset:end]}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// With Padding set, PaddingLength bytes of padding are appended to the
+// payload.
+func (r *RTP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if len(r.ExtensionData)%4 != 0 || len(r.ExtensionData) > 4*0xffff {
+		return fmt.Errorf("invalid RTP header extension length %d", len(r.ExtensionData))
+	}
+	if len(r.CSRCs) > 15 {
+		return fmt.Errorf("too many RTP CSRCs: %d", len(r.CSRCs))
+	}
+	if r.Padding && r.PaddingLength > 0 {
+		padding, err := b.AppendBytes(int(r.PaddingLength))
+		if err != nil {
+			return err
+		}
+		zero(padding)
+		padding[len(padding)-1] = r.PaddingLength
+	}
+	if opts.FixLengths {
+		r.CSRCCount = uint8(len(r.CSRCs))
+	}
+	length := 12 + 4*len(r.CSRCs)
+	if r.Extension {
+		length += 4 + len(r.ExtensionData)
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	bytes[0] = r.Version<<6 | r.CSRCCount&0x0f
+	if r.Padding {
+		bytes[0] |= 0x20
+	}
+	if r.Extension {
+		bytes[0] |= 0x10
+	}
+	bytes[1] = uint8(r.PayloadType) & 0x7f
+	if r.Marker {
+		bytes[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(bytes[2:4], r.SequenceNumber)
+	binary.BigEndian.PutUint32(bytes[4:8], r.Timestamp)
+	binary.BigEndian.PutUint32(bytes[8:12], r.SSRC)
+	offset := 12
+	for _, csrc := range r.CSRCs {
+		binary.BigEndian.PutUint32(bytes[offset:offset+4], csrc)
+		offset += 4
+	}
+	if r.Extension {
+		binary.BigEndian.PutUint16(bytes[offset:offset+2], r.ExtensionProfile)
+		binary.BigEndian.PutUint16(bytes[offset+2:offset+4], uint16(len(r.ExtensionData)/4))
+		copy(bytes[offset+4:], r.ExtensionData)
+	}
+	return nil
+}
+
+// ValidRTP reports whether data looks like an RTP packet: version 2, a
+// payload type which doesn't collide with RTCP, and a CSRC list, header
+// extension and padding which fit.  Being a heuristic, it's meant for
+// probing payloads of unknown type.
+func ValidRTP(data []byte) bool {
+	if len(data) < 12 || data[0]>>6 != 2 || isRTCPMux(data) {
+		return false
+	}
+	offset := 12 + 4*int(data[0]&0x0f)
+	if data[0]&0x10 != 0 {
+		if offset+4 > len(data) {
+			return false
+		}
+		offset += 4 + 4*int(binary.BigEndian.Uint16(data[offset+2:offset+4]))
+	}
+	if offset > len(data) {
+		return false
+	}
+	if data[0]&0x20 != 0 {
+		padding := int(data[len(data)-1])
+		return padding > 0 && offset+padding <= len(data)
+	}
+	return true
+}
+
+// isRTCPMux reports whether data multiplexed with RTP is RTCP, going by the
+// second byte being in the range RFC 5761 section 4 reserves for RTCP
+// packet types.
+func isRTCPMux(data []byte) bool {
+	return len(data) >= 2 && data[1] >= 192 && data[1] <= 223
+}
+
+// validRTPPort is the UDP port validator for LayerTypeRTP, which lets
+// multiplexed RTCP through as well.
+func validRTPPort(data []byte) bool {
+	return ValidRTP(data) || isRTCPMux(data) && ValidRTCP(data)
+}
+
+func decodeRTP(data []byte, p gopacket.PacketBuilder) error {
+	if isRTCPMux(data) {
+		return decodeRTCP(data, p)
+	}
+	r := &RTP{}
+	return decodingLayerDecoder(r, data, p)
+}
+
+// RTCPPacketType is the type of an RTCP packet.
+type RTCPPacketType uint8
+
+const (
+	RTCPPacketTypeSenderReport       RTCPPacketType = 200
+	RTCPPacketTypeReceiverReport     RTCPPacketType = 201
+	RTCPPacketTypeSourceDescription  RTCPPacketType = 202
+	RTCPPacketTypeGoodbye            RTCPPacketType = 203
+	RTCPPacketTypeApplicationDefined RTCPPacketType = 204
+	RTCPPacketTypeTransportFeedback  RTCPPacketType = 205
+	RTCPPacketTypePayloadFeedback    RTCPPacketType = 206
+	RTCPPacketTypeExtendedReport     RTCPPacketType = 207
+)
+
+func (t RTCPPacketType) String() string {
+	switch t {
+	case RTCPPacketTypeSenderReport:
+		return "SR"
+	case RTCPPacketTypeReceiverReport:
+		return "RR"
+	case RTCPPacketTypeSourceDescription:
+		return "SDES"
+	case RTCPPacketTypeGoodbye:
+		return "BYE"
+	case RTCPPacketTypeApplicationDefined:
+		return "APP"
+	case RTCPPacketTypeTransportFeedback:
+		return "RTPFB"
+	case RTCPPacketTypePayloadFeedback:
+		return "PSFB"
+	case RTCPPacketTypeExtendedReport:
+		return "XR"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// RTCPSDESType is the type of an SDES item.
+type RTCPSDESType uint8
+
+const (
+	RTCPSDESTypeCNAME RTCPSDESType = 1
+	RTCPSDESTypeName  RTCPSDESType = 2
+	RTCPSDESTypeEmail RTCPSDESType = 3
+	RTCPSDESTypePhone RTCPSDESType = 4
+	RTCPSDESTypeLoc   RTCPSDESType = 5
+	RTCPSDESTypeTool  RTCPSDESType = 6
+	RTCPSDESTypeNote  RTCPSDESType = 7
+	RTCPSDESTypePriv  RTCPSDESType = 8
+)
+
+func (t RTCPSDESType) String() string {
+	switch t {
+	case RTCPSDESTypeCNAME:
+		return "CNAME"
+	case RTCPSDESTypeName:
+		return "NAME"
+	case RTCPSDESTypeEmail:
+		return "EMAIL"
+	case RTCPSDESTypePhone:
+		return "PHONE"
+	case RTCPSDESTypeLoc:
+		return "LOC"
+	case RTCPSDESTypeTool:
+		return "TOOL"
+	case RTCPSDESTypeNote:
+		return "NOTE"
+	case RTCPSDESTypePriv:
+		return "PRIV"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// RTCPReportBlock is a reception report block of a sender or receiver
+// report.
+type RTCPReportBlock struct {
+	SSRC uint32
+	// FractionLost is the fraction of packets lost since the previous
+	// report, as a fixed point number with the binary point at the left.
+	FractionLost uint8
+	// CumulativeLost is the number of packets lost since the beginning of
+	// reception.  It's negative if duplicates outnumber losses.
+	CumulativeLost int32
+	// HighestSequence is the extended highest sequence number received.
+	HighestSequence uint32
+	// Jitter is the interarrival jitter, in timestamp units.
+	Jitter uint32
+	// LastSR is the middle 32 bits of the NTP timestamp of the last sender
+	// report received, and DelaySinceLastSR the delay since, in units of
+	// 1/65536 seconds.
+	LastSR           uint32
+	DelaySinceLastSR uint32
+}
+
+// FractionLostRatio returns FractionLost as a number between 0 and 1.
+func (r RTCPReportBlock) FractionLostRatio() float64 {
+	return float64(r.FractionLost) / 256
+}
+
+// RTCPSenderReport is the body of a sender report.
+type RTCPSenderReport struct {
+	SSRC         uint32
+	NTPTimestamp uint64
+	RTPTimestamp uint32
+	PacketCount  uint32
+	OctetCount   uint32
+	Reports      []RTCPReportBlock
+}
+
+// RTCPReceiverReport is the body of a receiver report.
+type RTCPReceiverReport struct {
+	SSRC    uint32
+	Reports []RTCPReportBlock
+}
+
+// RTCPSDESItem is an item of a source description chunk.
+type RTCPSDESItem struct {
+	Type RTCPSDESType
+	Text []byte
+}
+
+// RTCPSDESChunk is a chunk of a source description packet.
+type RTCPSDESChunk struct {
+	SSRC  uint32
+	Items []RTCPSDESItem
+}
+
+// RTCPGoodbye is the body of a BYE packet.
+type RTCPGoodbye struct {
+	SSRCs  []uint32
+	Reason []byte
+}
+
+// RTCPApp is the body of an APP packet.  Its subtype is in the Count field
+// of the packet.
+type RTCPApp struct {
+	SSRC uint32
+	Name [4]byte
+	Data []byte
+}
+
+// RTCPPacket is a packet of an RTCP compound packet.  Body holds everything
+// after the header, less any padding, and one of the other fields is set
+// according to Type.  Packets of other types are left in Body.
+type RTCPPacket struct {
+	Version uint8
+	Padding bool
+	// Count is the report count of SR and RR packets, the source count of
+	// SDES and BYE packets, and the subtype of APP packets.
+	Count uint8
+	Type  RTCPPacketType
+	// Length is the length of the packet in 32 bit words, minus one.
+	Length uint16
+	// PaddingLength is the number of padding bytes, including the last one
+	// which holds the count, if Padding is set.
+	PaddingLength uint8
+	Body          []byte
+
+	SenderReport      *RTCPSenderReport
+	ReceiverReport    *RTCPReceiverReport
+	SourceDescription []RTCPSDESChunk
+	Goodbye           *RTCPGoodbye
+	App               *RTCPApp
+}
+
+// RTCP is an RTP Control Protocol compound packet, as described by RFC 3550.
+type RTCP struct {
+	BaseLayer
+	Packets []RTCPPacket
+}
+
+// LayerType returns LayerTypeRTCP.
+func (r *RTCP) LayerType() gopacket.LayerType { return LayerTypeRTCP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (r *RTCP) CanDecode() gopacket.LayerClass { return LayerTypeRTCP }
+
+// NextLayerType returns gopacket.LayerTypeZero.
+func (r *RTCP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (r *RTCP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	r.Packets = r.Packets[:0]
+	for rest := data; len(rest) > 0; {
+		if len(rest) < 4 {
+			df.SetTruncated()
+			return errors.New("RTCP header too short")
+		}
+		p := RTCPPacket{
+			Version: rest[0] >> 6,
+			Padding: rest[0]&0x20 != 0,
+			Count:   rest[0] & 0x1f,
+			Type:    RTCPPacketType(rest[1]),
+			Length:  binary.BigEndian.Uint16(rest[2:4]),
+		}
+		if p.Version != 2 {
+			return fmt.Errorf("invalid RTCP version %d", p.Version)
+		}
+		length := 4 * (int(p.Length) + 1)
+		if length > len(rest) {
+			df.SetTruncated()
+			return fmt.Errorf("RTCP %v length %d exceeds %d bytes", p.Type, length, len(rest))
+		}
+		p.Body = rest[4:length]
+		if p.Padding {
+			p.PaddingLength = rest[length-1]
+			if p.PaddingLength == 0 || int(p.PaddingLength) > len(p.Body) {
+				return fmt.Errorf("invalid RTCP padding length %d", p.PaddingLength)
+			}
+			p.Body = p.Body[:len(p.Body)-int(p.PaddingLength)]
+		}
+		if err := p.decodeBody(); err != nil {
+			return err
+		}
+		r.Packets = append(r.Packets, p)
+		rest = rest[length:]
+	}
+	r.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+func (p *RTCPPacket) decodeBody() error {
+	b := p.Body
+	switch p.Type {
+	case RTCPPacketTypeSenderReport:
+		if len(b) < 24 {
+			return errors.New("RTCP sender report too short")
+		}
+		sr := &RTCPSenderReport{
+			SSRC:         binary.BigEndian.Uint32(b[0:4]),
+			NTPTimestamp: binary.BigEndian.Uint64(b[4:12]),
+			RTPTimestamp: binary.BigEndian.Uint32(b[12:16]),
+			PacketCount:  binary.BigEndian.Uint32(b[16:20]),
+			OctetCount:   binary.BigEndian.Uint32(b[20:24]),
+		}
+		var err error
+		sr.Reports, err = decodeRTCPReportBlocks(b[24:], p.Count)
+		if err != nil {
+			return err
+		}
+		p.SenderReport = sr
+	case RTCPPacketTypeReceiverReport:
+		if len(b) < 4 {
+			return errors.New("RTCP receiver report too short")
+		}
+		rr := &RTCPReceiverReport{SSRC: binary.BigEndian.Uint32(b[0:4])}
+		var err error
+		rr.Reports, err = decodeRTCPReportBlocks(b[4:], p.Count)
+		if err != nil {
+			return err
+		}
+		p.ReceiverReport = rr
+	case RTCPPacketTypeSourceDescription:
+		for i := 0; i < int(p.Count); i++ {
+			if len(b) < 4 {
+				return errors.New("RTCP SDES chunk truncated")
+			}
+			chunk := RTCPSDESChunk{SSRC: binary.BigEndian.Uint32(b[0:4])}
+			offset := 4
+			for {
+				if offset >= len(b) {
+					return errors.New("RTCP SDES chunk truncated")
+				}
+				if b[offset] == 0 {
+					break
+				}
+				if offset+2 > len(b) || offset+2+int(b[offset+1]) > len(b) {
+					return errors.New("RTCP SDES item truncated")
+				}
+				chunk.Items = append(chunk.Items, RTCPSDESItem{
+					Type: RTCPSDESType(b[offset]),
+					Text: b[offset+2 : offset+2+int(b[offset+1])],
+				})
+				offset += 2 + int(b[offset+1])
+			}
+			// The item list ends with one or more null octets, up to the
+			// next 32 bit boundary.
+			offset = (offset + 4) &^ 3
+			if offset > len(b) {
+				return errors.New("RTCP SDES chunk truncated")
+			}
+			p.SourceDescription = append(p.SourceDescription, chunk)
+			b = b[offset:]
+		}
+	case RTCPPacketTypeGoodbye:
+		if len(b) < 4*int(p.Count) {
+			return errors.New("RTCP BYE too short")
+		}
+		bye := &RTCPGoodbye{}
+		for i := 0; i < int(p.Count); i++ {
+			bye.SSRCs = append(bye.SSRCs, binary.BigEndian.Uint32(b[4*i:4*i+4]))
+		}
+		if b = b[4*p.Count:]; len(b) > 0 {
+			n := 1 + int(b[0])
+			if n > len(b) {
+				return errors.New("RTCP BYE reason truncated")
+			}
+			bye.Reason = b[1:n]
+		}
+		p.Goodbye = bye
+	case RTCPPacketTypeApplicationDefined:
+		if len(b) < 8 {
+			return errors.New("RTCP APP too short")
+		}
+		app := &RTCPApp{SSRC: binary.BigEndian.Uint32(b[0:4]), Data: b[8:]}
+		copy(app.Name[:], b[4:8])
+		p.App = app
+	}
+	return nil
+}
+
+// decodeRTCPReportBlocks decodes count report blocks from the start of data.
+func decodeRTCPReportBlocks(data []byte, count uint8) ([]RTCPReportBlock, error) {
+	if len(data) < 24*int(count) {
+		return nil, errors.New("RTCP report blocks truncated")
+	}
+	var reports []RTCPReportBlock
+	for i := 0; i < int(count); i++ {
+		b := data[24*i : 24*i+24]
+		lost := int32(binary.BigEndian.Uint32(b[4:8])<<8) >> 8
+		reports = append(reports, RTCPReportBlock{
+			SSRC:             binary.BigEndian.Uint32(b[0:4]),
+			FractionLost:     b[4],
+			CumulativeLost:   lost,
+			HighestSequence:  binary.BigEndian.Uint32(b[8:12]),
+			Jitter:           binary.BigEndian.Uint32(b[12:16]),
+			LastSR:           binary.BigEndian.Uint32(b[16:20]),
+			DelaySinceLastSR: binary.BigEndian.Uint32(b[20:24]),
+		})
+	}
+	return reports, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// Packets are written from their Version, Padding, Count, Type, Body and
+// PaddingLength fields; the decoded structures are not used.  The body and
+// padding of each packet must add up to a multiple of 4 bytes.
+func (r *RTCP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := 0
+	for _, p := range r.Packets {
+		length += 4 + len(p.Body) + p.paddingLength()
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	offset := 0
+	for i := range r.Packets {
+		p := &r.Packets[i]
+		body := len(p.Body) + p.paddingLength()
+		if body%4 != 0 || body/4 > 0xffff {
+			return fmt.Errorf("invalid RTCP %v length %d", p.Type, body)
+		}
+		if opts.FixLengths {
+			p.Length = uint16(body / 4)
+		}
+		bytes[offset] = p.Version<<6 | p.Count&0x1f
+		if p.Padding {
+			bytes[offset] |= 0x20
+		}
+		bytes[offset+1] = uint8(p.Type)
+		binary.BigEndian.PutUint16(bytes[offset+2:offset+4], p.Length)
+		copy(bytes[offset+4:], p.Body)
+		if padding := bytes[offset+4+len(p.Body) : offset+4+body]; len(padding) > 0 {
+			zero(padding)
+			padding[len(padding)-1] = p.PaddingLength
+		}
+		offset += 4 + body
+	}
+	return nil
+}
+
+func (p *RTCPPacket) paddingLength() int {
+	if p.Padding {
+		return int(p.PaddingLength)
+	}
+	return 0
+}
+
+// ValidRTCP reports whether data looks like an RTCP compound packet: every
+// packet is version 2 with a known type, and their lengths add up to the
+// length of data.  Being a heuristic, it's meant for probing payloads of
+// unknown type.
+func ValidRTCP(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	for len(data) > 0 {
+		if len(data) < 4 || data[0]>>6 != 2 || data[1] < 200 || data[1] > 207 {
+			return false
+		}
+		length := 4 * (int(binary.BigEndian.Uint16(data[2:4])) + 1)
+		if length > len(data) {
+			return false
+		}
+		data = data[length:]
+	}
+	return true
+}
+
+func decodeRTCP(data []byte, p gopacket.PacketBuilder) error {
+	r := &RTCP{}
+	return decodingLayerDecoder(r, data, p)
+}
@@ -0,0 +1,241 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketRTP is a G.711 mu-law RTP packet on UDP port 16384.
+var testPacketRTP = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0xb8, 0x00, 0x3c, 0x43, 0x21, 0x40, 0x00, 0x40, 0x11,
+	0x73, 0x69, 0xc0, 0xa8, 0x01, 0x0a, 0xc0, 0xa8, 0x01, 0x14, 0x40, 0x00,
+	0x40, 0x00, 0x00, 0x28, 0x9f, 0xe6, 0x80, 0x00, 0x1f, 0x40, 0x00, 0x00,
+	0xfa, 0x00, 0x12, 0x34, 0x56, 0x78, 0xd5, 0xd5, 0xd5, 0xd5, 0xd5, 0xd5,
+	0xd5, 0xd5, 0xd5, 0xd5, 0xd5, 0xd5, 0xd5, 0xd5, 0xd5, 0xd5, 0xd5, 0xd5,
+	0xd5, 0xd5,
+}
+
+// testPacketRTCPMux is a compound RTCP packet of the same session, made of a
+// sender report with one report block and a CNAME, multiplexed on the RTP
+// port.
+var testPacketRTCPMux = []byte{
+	0x00, 0x0c, 0x29, 0x44, 0x55, 0x66, 0x00, 0x0c, 0x29, 0x11, 0x22, 0x33,
+	0x08, 0x00, 0x45, 0xb8, 0x00, 0x68, 0x43, 0x21, 0x40, 0x00, 0x40, 0x11,
+	0x73, 0x3d, 0xc0, 0xa8, 0x01, 0x0a, 0xc0, 0xa8, 0x01, 0x14, 0x40, 0x00,
+	0x40, 0x00, 0x00, 0x54, 0xf1, 0x6d, 0x81, 0xc8, 0x00, 0x0c, 0x12, 0x34,
+	0x56, 0x78, 0xe6, 0x1c, 0x2b, 0x80, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xfa, 0x00, 0x00, 0x00, 0x00, 0x64, 0x00, 0x00, 0x3e, 0x80, 0x9a, 0xbc,
+	0xde, 0xf0, 0x19, 0x00, 0x00, 0x07, 0x00, 0x01, 0x1f, 0x8a, 0x00, 0x00,
+	0x00, 0x2a, 0x2b, 0x80, 0x80, 0x00, 0x00, 0x01, 0x00, 0x00, 0x81, 0xca,
+	0x00, 0x05, 0x12, 0x34, 0x56, 0x78, 0x01, 0x0a, 0x61, 0x6c, 0x69, 0x63,
+	0x65, 0x40, 0x68, 0x6f, 0x73, 0x74, 0x00, 0x00, 0x00, 0x00,
+}
+
+// testRTPExtension is an RTP packet with a CSRC, a one-byte header
+// extension and three bytes of padding.
+var testRTPExtension = []byte{
+	0xb1, 0xe0, 0x00, 0x01, 0x00, 0x00, 0x00, 0x64, 0xde, 0xad, 0xbe, 0xef,
+	0x00, 0x00, 0x00, 0x01, 0xbe, 0xde, 0x00, 0x01, 0x10, 0xab, 0xcd, 0x00,
+	0x01, 0x02, 0x03, 0x04, 0x00, 0x00, 0x03,
+}
+
+// testRTCPReceiverReport is a receiver report whose source has more
+// duplicates than losses, a BYE with a reason and an APP packet.
+var testRTCPReceiverReport = []byte{
+	0x81, 0xc9, 0x00, 0x07, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78,
+	0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0x1f, 0x41, 0x00, 0x00, 0x00, 0x10,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x81, 0xcb, 0x00, 0x04,
+	0x9a, 0xbc, 0xde, 0xf0, 0x08, 0x74, 0x65, 0x61, 0x72, 0x64, 0x6f, 0x77,
+	0x6e, 0x00, 0x00, 0x00, 0x80, 0xcc, 0x00, 0x03, 0x9a, 0xbc, 0xde, 0xf0,
+	0x54, 0x45, 0x53, 0x54, 0x00, 0x00, 0x00, 0x01,
+}
+
+func TestPacketRTP(t *testing.T) {
+	RegisterUDPPortLayerType(16384, LayerTypeRTP)
+	defer UnregisterUDPPortLayerType(16384)
+
+	p := gopacket.NewPacket(testPacketRTP, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeRTP, gopacket.LayerTypePayload}, t)
+	rtp := p.Layer(LayerTypeRTP).(*RTP)
+	want := &RTP{
+		BaseLayer:      BaseLayer{testPacketRTP[42:54], testPacketRTP[54:]},
+		Version:        2,
+		PayloadType:    RTPPayloadTypePCMU,
+		SequenceNumber: 8000,
+		Timestamp:      64000,
+		SSRC:           0x12345678,
+	}
+	if !reflect.DeepEqual(want, rtp) {
+		t.Errorf("RTP mismatch\nwant %#v\ngot  %#v", want, rtp)
+	}
+	testSerialization(t, p, testPacketRTP)
+}
+
+func TestPacketRTCPMux(t *testing.T) {
+	RegisterUDPPortLayerType(16384, LayerTypeRTP)
+	defer UnregisterUDPPortLayerType(16384)
+
+	p := gopacket.NewPacket(testPacketRTCPMux, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeRTCP}, t)
+	rtcp := p.Layer(LayerTypeRTCP).(*RTCP)
+	if len(rtcp.Packets) != 2 {
+		t.Fatalf("want 2 RTCP packets, got %d", len(rtcp.Packets))
+	}
+	sr := rtcp.Packets[0].SenderReport
+	wantSR := &RTCPSenderReport{
+		SSRC:         0x12345678,
+		NTPTimestamp: 0xe61c2b8080000000,
+		RTPTimestamp: 64000,
+		PacketCount:  100,
+		OctetCount:   16000,
+		Reports: []RTCPReportBlock{{
+			SSRC:             0x9abcdef0,
+			FractionLost:     25,
+			CumulativeLost:   7,
+			HighestSequence:  73610,
+			Jitter:           42,
+			LastSR:           0x2b808000,
+			DelaySinceLastSR: 65536,
+		}},
+	}
+	if !reflect.DeepEqual(sr, wantSR) {
+		t.Errorf("SR mismatch\nwant %+v\ngot  %+v", wantSR, sr)
+	}
+	if r := sr.Reports[0].FractionLostRatio(); r < 0.097 || r > 0.098 {
+		t.Errorf("fraction lost ratio %v", r)
+	}
+	sdes := rtcp.Packets[1].SourceDescription
+	wantSDES := []RTCPSDESChunk{{SSRC: 0x12345678, Items: []RTCPSDESItem{{Type: RTCPSDESTypeCNAME, Text: []byte("alice@host")}}}}
+	if !reflect.DeepEqual(sdes, wantSDES) {
+		t.Errorf("SDES mismatch\nwant %+v\ngot  %+v", wantSDES, sdes)
+	}
+	testSerialization(t, p, testPacketRTCPMux)
+}
+
+func TestRTPExtension(t *testing.T) {
+	r := &RTP{}
+	if err := r.DecodeFromBytes(testRTPExtension, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Marker || r.PayloadType != 96 || r.PayloadType.String() != "Dynamic(96)" || !reflect.DeepEqual(r.CSRCs, []uint32{1}) {
+		t.Errorf("RTP header mismatch: %+v", r)
+	}
+	if r.ExtensionProfile != 0xbede || !bytes.Equal(r.ExtensionData, []byte{0x10, 0xab, 0xcd, 0x00}) {
+		t.Errorf("RTP extension mismatch: %x %x", r.ExtensionProfile, r.ExtensionData)
+	}
+	if r.PaddingLength != 3 || !bytes.Equal(r.Payload, []byte{1, 2, 3, 4}) {
+		t.Errorf("RTP padding mismatch: %d %x", r.PaddingLength, r.Payload)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	r.CSRCCount = 0
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, r, gopacket.Payload(r.Payload)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), testRTPExtension) {
+		t.Errorf("serialization mismatch\nwant %x\ngot  %x", testRTPExtension, buf.Bytes())
+	}
+}
+
+func TestRTCPReceiverReport(t *testing.T) {
+	r := &RTCP{}
+	if err := r.DecodeFromBytes(testRTCPReceiverReport, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Packets) != 3 {
+		t.Fatalf("want 3 RTCP packets, got %d", len(r.Packets))
+	}
+	rr := r.Packets[0].ReceiverReport
+	if rr == nil || len(rr.Reports) != 1 || rr.Reports[0].CumulativeLost != -1 || rr.Reports[0].Jitter != 16 {
+		t.Errorf("RR mismatch: %+v", rr)
+	}
+	bye := r.Packets[1].Goodbye
+	if bye == nil || !reflect.DeepEqual(bye.SSRCs, []uint32{0x9abcdef0}) || string(bye.Reason) != "teardown" {
+		t.Errorf("BYE mismatch: %+v", bye)
+	}
+	app := r.Packets[2].App
+	if app == nil || string(app.Name[:]) != "TEST" || !bytes.Equal(app.Data, []byte{0, 0, 0, 1}) {
+		t.Errorf("APP mismatch: %+v", app)
+	}
+}
+
+func TestRTPValidity(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		data      []byte
+		rtp, rtcp bool
+	}{
+		{"RTP", testPacketRTP[42:], true, false},
+		{"RTP extension", testRTPExtension, true, false},
+		{"RTCP", testPacketRTCPMux[42:], false, true},
+		{"RTCP RR", testRTCPReceiverReport, false, true},
+		{"version 1", []byte{0x40, 0x00, 0x1f, 0x40, 0x00, 0x00, 0xfa, 0x00, 0x12, 0x34, 0x56, 0x78}, false, false},
+		{"short CSRC list", []byte{0x82, 0x00, 0x1f, 0x40, 0x00, 0x00, 0xfa, 0x00, 0x12, 0x34, 0x56, 0x78, 0x00, 0x00, 0x00, 0x01}, false, false},
+		{"RTCP bad length", testRTCPReceiverReport[:30], false, false},
+	} {
+		if got := ValidRTP(test.data); got != test.rtp {
+			t.Errorf("%s: ValidRTP = %v, want %v", test.name, got, test.rtp)
+		}
+		if got := ValidRTCP(test.data); got != test.rtcp {
+			t.Errorf("%s: ValidRTCP = %v, want %v", test.name, got, test.rtcp)
+		}
+	}
+}
+
+func TestRTPMalformed(t *testing.T) {
+	r := &RTP{}
+	if err := r.DecodeFromBytes(testRTPExtension[:8], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated header")
+	}
+	if err := r.DecodeFromBytes(testRTPExtension[:18], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated extension")
+	}
+	data := append([]byte(nil), testRTPExtension...)
+	data[len(data)-1] = 0x20
+	if err := r.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding overlong padding")
+	}
+	c := &RTCP{}
+	if err := c.DecodeFromBytes(testRTCPReceiverReport[:30], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated report")
+	}
+	data = append([]byte(nil), testRTCPReceiverReport...)
+	data[0] = 0x82
+	if err := c.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding missing report block")
+	}
+}
+
+func TestRTCPGoodbyeLongReason(t *testing.T) {
+	reason := bytes.Repeat([]byte{'x'}, 255)
+	data := append([]byte{0x81, 0xcb, 0x00, 0x41, 0x9a, 0xbc, 0xde, 0xf0, 0xff}, reason...)
+	c := &RTCP{}
+	if err := c.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if bye := c.Packets[0].Goodbye; bye == nil || !bytes.Equal(bye.Reason, reason) {
+		t.Errorf("BYE mismatch: %+v", bye)
+	}
+	// One word shorter, leaving 251 bytes for the reason.
+	data = data[:len(data)-4]
+	data[3] = 0x40
+	if err := c.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding truncated reason")
+	}
+}
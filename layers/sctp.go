@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"hash/crc32"
+	"net"
 
 	"github.com/google/gopacket"
 )
@@ -113,26 +114,28 @@ func roundUpToNearest4(i int) int {
 }
 
 func decodeSCTPChunk(data []byte) (SCTPChunk, error) {
+	if len(data) < 4 {
+		return SCTPChunk{}, errors.New("SCTP chunk header truncated")
+	}
 	length := binary.BigEndian.Uint16(data[2:4])
 	if length < 4 {
 		return SCTPChunk{}, errors.New("invalid SCTP chunk length")
 	}
+	if int(length) > len(data) {
+		return SCTPChunk{}, fmt.Errorf("SCTP chunk length %d exceeds %d available bytes", length, len(data))
+	}
 	actual := roundUpToNearest4(int(length))
-	ct := SCTPChunkType(data[0])
-
-	// For SCTP Data, use a separate layer for the payload
-	delta := 0
-	if ct == SCTPChunkTypeData {
-		delta = int(actual) - int(length)
-		actual = 16
+	if actual > len(data) {
+		// Tolerate a final chunk whose padding was not captured.
+		actual = len(data)
 	}
 
 	return SCTPChunk{
-		Type:         ct,
+		Type:         SCTPChunkType(data[0]),
 		Flags:        data[1],
 		Length:       length,
 		ActualLength: actual,
-		BaseLayer:    BaseLayer{data[:actual], data[actual : len(data)-delta]},
+		BaseLayer:    BaseLayer{data[:actual], data[actual:]},
 	}, nil
 }
 
@@ -144,14 +147,24 @@ type SCTPParameter struct {
 	Value        []byte
 }
 
-func decodeSCTPParameter(data []byte) SCTPParameter {
+func decodeSCTPParameter(data []byte) (SCTPParameter, error) {
+	if len(data) < 4 {
+		return SCTPParameter{}, errors.New("SCTP parameter header truncated")
+	}
 	length := binary.BigEndian.Uint16(data[2:4])
+	if length < 4 || int(length) > len(data) {
+		return SCTPParameter{}, fmt.Errorf("invalid SCTP parameter length %d", length)
+	}
+	actual := roundUpToNearest4(int(length))
+	if actual > len(data) {
+		actual = len(data)
+	}
 	return SCTPParameter{
 		Type:         binary.BigEndian.Uint16(data[0:2]),
 		Length:       length,
 		Value:        data[4:length],
-		ActualLength: roundUpToNearest4(int(length)),
-	}
+		ActualLength: actual,
+	}, nil
 }
 
 func (p SCTPParameter) Bytes() []byte {
@@ -206,7 +219,10 @@ func (s *SCTPUnknownChunkType) Error() error {
 	return fmt.Errorf("No decode method available for SCTP chunk type %s", s.Type)
 }
 
-// SCTPData is the SCTP Data chunk layer.
+// SCTPData is the SCTP Data chunk layer.  The user data is also decoded as
+// the following gopacket.Payload layer.  A user message which the sender
+// fragmented across several DATA chunks can be put back together with an
+// SCTPReassembler.
 type SCTPData struct {
 	SCTPChunk
 	Unordered, BeginFragment, EndFragment bool
@@ -214,6 +230,7 @@ type SCTPData struct {
 	StreamId                              uint16
 	StreamSequence                        uint16
 	PayloadProtocol                       SCTPPayloadProtocol
+	UserData                              []byte
 }
 
 // LayerType returns gopacket.LayerTypeSCTPData.
@@ -224,25 +241,27 @@ type SCTPPayloadProtocol uint32
 
 // SCTPPayloadProtocol constonts from http://www.iana.org/assignments/sctp-parameters/sctp-parameters.xhtml
 const (
-	SCTPProtocolReserved  SCTPPayloadProtocol = 0
-	SCTPPayloadUIA                            = 1
-	SCTPPayloadM2UA                           = 2
-	SCTPPayloadM3UA                           = 3
-	SCTPPayloadSUA                            = 4
-	SCTPPayloadM2PA                           = 5
-	SCTPPayloadV5UA                           = 6
-	SCTPPayloadH248                           = 7
-	SCTPPayloadBICC                           = 8
-	SCTPPayloadTALI                           = 9
-	SCTPPayloadDUA                            = 10
-	SCTPPayloadASAP                           = 11
-	SCTPPayloadENRP                           = 12
-	SCTPPayloadH323                           = 13
-	SCTPPayloadQIPC                           = 14
-	SCTPPayloadSIMCO                          = 15
-	SCTPPayloadDDPSegment                     = 16
-	SCTPPayloadDDPStream                      = 17
-	SCTPPayloadS1AP                           = 18
+	SCTPProtocolReserved    SCTPPayloadProtocol = 0
+	SCTPPayloadUIA                              = 1
+	SCTPPayloadM2UA                             = 2
+	SCTPPayloadM3UA                             = 3
+	SCTPPayloadSUA                              = 4
+	SCTPPayloadM2PA                             = 5
+	SCTPPayloadV5UA                             = 6
+	SCTPPayloadH248                             = 7
+	SCTPPayloadBICC                             = 8
+	SCTPPayloadTALI                             = 9
+	SCTPPayloadDUA                              = 10
+	SCTPPayloadASAP                             = 11
+	SCTPPayloadENRP                             = 12
+	SCTPPayloadH323                             = 13
+	SCTPPayloadQIPC                             = 14
+	SCTPPayloadSIMCO                            = 15
+	SCTPPayloadDDPSegment                       = 16
+	SCTPPayloadDDPStream                        = 17
+	SCTPPayloadS1AP                             = 18
+	SCTPPayloadDiameter                         = 46
+	SCTPPayloadDiameterDTLS                     = 47
 )
 
 func (p SCTPPayloadProtocol) String() string {
@@ -285,6 +304,10 @@ func (p SCTPPayloadProtocol) String() string {
 		return "DDPStream"
 	case SCTPPayloadS1AP:
 		return "S1AP"
+	case SCTPPayloadDiameter:
+		return "Diameter"
+	case SCTPPayloadDiameterDTLS:
+		return "DiameterDTLS"
 	}
 	return fmt.Sprintf("Unknown(%d)", p)
 }
@@ -294,6 +317,9 @@ func decodeSCTPData(data []byte, p gopacket.PacketBuilder) error {
 	if err != nil {
 		return err
 	}
+	if chunk.Length < 16 {
+		return fmt.Errorf("SCTP data chunk length %d too short", chunk.Length)
+	}
 	sc := &SCTPData{
 		SCTPChunk:       chunk,
 		Unordered:       data[1]&0x4 != 0,
@@ -303,10 +329,22 @@ func decodeSCTPData(data []byte, p gopacket.PacketBuilder) error {
 		StreamId:        binary.BigEndian.Uint16(data[8:10]),
 		StreamSequence:  binary.BigEndian.Uint16(data[10:12]),
 		PayloadProtocol: SCTPPayloadProtocol(binary.BigEndian.Uint32(data[12:16])),
+		// Length is the length in bytes of the data, INCLUDING the 16-byte header.
+		UserData: data[16:chunk.Length],
 	}
-	// Length is the length in bytes of the data, INCLUDING the 16-byte header.
+	// Use a separate layer for the user data, then carry on with any chunks
+	// bundled after this one.
+	sc.BaseLayer = BaseLayer{data[:16], sc.UserData}
 	p.AddLayer(sc)
-	return p.NextDecoder(gopacket.LayerTypePayload)
+	if len(sc.UserData) > 0 {
+		if err := gopacket.LayerTypePayload.Decode(sc.UserData, p); err != nil {
+			return err
+		}
+	}
+	if rest := data[sc.ActualLength:]; len(rest) > 0 {
+		return decodeWithSCTPChunkTypePrefix(rest, p)
+	}
+	return nil
 }
 
 // SerializeTo is for gopacket.SerializableLayer.
@@ -341,8 +379,93 @@ func (sc SCTPData) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Seriali
 	return nil
 }
 
+// SCTPInitParameterType is the type of an SCTPInitParameter.
+type SCTPInitParameterType uint16
+
+// SCTPInitParameterType values, from RFC 4960 section 3.3.2 and
+// http://www.iana.org/assignments/sctp-parameters/sctp-parameters.xhtml
+const (
+	SCTPInitParameterIPv4Address           SCTPInitParameterType = 5
+	SCTPInitParameterIPv6Address           SCTPInitParameterType = 6
+	SCTPInitParameterStateCookie           SCTPInitParameterType = 7
+	SCTPInitParameterUnrecognizedParameter SCTPInitParameterType = 8
+	SCTPInitParameterCookiePreservative    SCTPInitParameterType = 9
+	SCTPInitParameterHostNameAddress       SCTPInitParameterType = 11
+	SCTPInitParameterSupportedAddressTypes SCTPInitParameterType = 12
+	SCTPInitParameterECNCapable            SCTPInitParameterType = 0x8000
+	SCTPInitParameterRandom                SCTPInitParameterType = 0x8002
+	SCTPInitParameterChunkList             SCTPInitParameterType = 0x8003
+	SCTPInitParameterHMACAlgorithm         SCTPInitParameterType = 0x8004
+	SCTPInitParameterSupportedExtensions   SCTPInitParameterType = 0x8008
+	SCTPInitParameterForwardTSNSupported   SCTPInitParameterType = 0xc000
+	SCTPInitParameterAdaptationLayer       SCTPInitParameterType = 0xc006
+)
+
+func (t SCTPInitParameterType) String() string {
+	switch t {
+	case SCTPInitParameterIPv4Address:
+		return "IPv4Address"
+	case SCTPInitParameterIPv6Address:
+		return "IPv6Address"
+	case SCTPInitParameterStateCookie:
+		return "StateCookie"
+	case SCTPInitParameterUnrecognizedParameter:
+		return "UnrecognizedParameter"
+	case SCTPInitParameterCookiePreservative:
+		return "CookiePreservative"
+	case SCTPInitParameterHostNameAddress:
+		return "HostNameAddress"
+	case SCTPInitParameterSupportedAddressTypes:
+		return "SupportedAddressTypes"
+	case SCTPInitParameterECNCapable:
+		return "ECNCapable"
+	case SCTPInitParameterRandom:
+		return "Random"
+	case SCTPInitParameterChunkList:
+		return "ChunkList"
+	case SCTPInitParameterHMACAlgorithm:
+		return "HMACAlgorithm"
+	case SCTPInitParameterSupportedExtensions:
+		return "SupportedExtensions"
+	case SCTPInitParameterForwardTSNSupported:
+		return "ForwardTSNSupported"
+	case SCTPInitParameterAdaptationLayer:
+		return "AdaptationLayer"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint16(t))
+}
+
 // SCTPInitParameter is a parameter for an SCTP Init or InitAck packet.
-type SCTPInitParameter SCTPParameter
+type SCTPInitParameter struct {
+	Type         SCTPInitParameterType
+	Length       uint16
+	ActualLength int
+	Value        []byte
+}
+
+// Address returns the address carried by an IPv4Address or IPv6Address
+// parameter, or nil for any other parameter.
+func (p SCTPInitParameter) Address() net.IP {
+	switch {
+	case p.Type == SCTPInitParameterIPv4Address && len(p.Value) == 4,
+		p.Type == SCTPInitParameterIPv6Address && len(p.Value) == 16:
+		return net.IP(p.Value)
+	}
+	return nil
+}
+
+// SupportedAddressTypes returns the address parameter types listed by a
+// SupportedAddressTypes parameter, or nil for any other parameter.
+func (p SCTPInitParameter) SupportedAddressTypes() []SCTPInitParameterType {
+	if p.Type != SCTPInitParameterSupportedAddressTypes {
+		return nil
+	}
+	types := make([]SCTPInitParameterType, len(p.Value)/2)
+	for i := range types {
+		types[i] = SCTPInitParameterType(binary.BigEndian.Uint16(p.Value[i*2:]))
+	}
+	return types
+}
 
 // SCTPInit is used as the return value for both SCTPInit and SCTPInitAck
 // messages.
@@ -369,6 +492,9 @@ func decodeSCTPInit(data []byte, p gopacket.PacketBuilder) error {
 	if err != nil {
 		return err
 	}
+	if chunk.Length < 20 {
+		return fmt.Errorf("SCTP init chunk length %d too short", chunk.Length)
+	}
 	sc := &SCTPInit{
 		SCTPChunk:                      chunk,
 		InitiateTag:                    binary.BigEndian.Uint32(data[4:8]),
@@ -377,21 +503,40 @@ func decodeSCTPInit(data []byte, p gopacket.PacketBuilder) error {
 		InboundStreams:                 binary.BigEndian.Uint16(data[14:16]),
 		InitialTSN:                     binary.BigEndian.Uint32(data[16:20]),
 	}
-	paramData := data[20:sc.ActualLength]
+	paramData := data[20:sc.Length]
 	for len(paramData) > 0 {
-		p := SCTPInitParameter(decodeSCTPParameter(paramData))
-		paramData = paramData[p.ActualLength:]
-		sc.Parameters = append(sc.Parameters, p)
+		param, err := decodeSCTPParameter(paramData)
+		if err != nil {
+			return err
+		}
+		paramData = paramData[param.ActualLength:]
+		sc.Parameters = append(sc.Parameters, SCTPInitParameter{
+			Type:         SCTPInitParameterType(param.Type),
+			Length:       param.Length,
+			ActualLength: param.ActualLength,
+			Value:        param.Value,
+		})
 	}
 	p.AddLayer(sc)
 	return p.NextDecoder(gopacket.DecodeFunc(decodeWithSCTPChunkTypePrefix))
 }
 
+// Parameter returns the first parameter of the given type, and whether one
+// was found.
+func (sc *SCTPInit) Parameter(t SCTPInitParameterType) (SCTPInitParameter, bool) {
+	for _, param := range sc.Parameters {
+		if param.Type == t {
+			return param, true
+		}
+	}
+	return SCTPInitParameter{}, false
+}
+
 // SerializeTo is for gopacket.SerializableLayer.
 func (sc SCTPInit) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
 	var payload []byte
 	for _, param := range sc.Parameters {
-		payload = append(payload, SCTPParameter(param).Bytes()...)
+		payload = append(payload, SCTPParameter{Type: uint16(param.Type), Value: param.Value}.Bytes()...)
 	}
 	length := 20 + len(payload)
 	bytes, err := b.PrependBytes(roundUpToNearest4(length))
@@ -410,13 +555,19 @@ func (sc SCTPInit) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Seriali
 	return nil
 }
 
+// SCTPGapACKBlock is a range of TSNs received after a gap, given as offsets
+// from the cumulative TSN ack of the SCTPSack carrying it.
+type SCTPGapACKBlock struct {
+	Start, End uint16
+}
+
 // SCTPSack is the SCTP Selective ACK chunk layer.
 type SCTPSack struct {
 	SCTPChunk
 	CumulativeTSNAck               uint32
 	AdvertisedReceiverWindowCredit uint32
 	NumGapACKs, NumDuplicateTSNs   uint16
-	GapACKs                        []uint16
+	GapACKs                        []SCTPGapACKBlock
 	DuplicateTSNs                  []uint32
 }
 
@@ -430,6 +581,9 @@ func decodeSCTPSack(data []byte, p gopacket.PacketBuilder) error {
 	if err != nil {
 		return err
 	}
+	if chunk.Length < 16 {
+		return fmt.Errorf("SCTP sack chunk length %d too short", chunk.Length)
+	}
 	sc := &SCTPSack{
 		SCTPChunk:                      chunk,
 		CumulativeTSNAck:               binary.BigEndian.Uint32(data[4:8]),
@@ -437,29 +591,22 @@ func decodeSCTPSack(data []byte, p gopacket.PacketBuilder) error {
 		NumGapACKs:                     binary.BigEndian.Uint16(data[12:14]),
 		NumDuplicateTSNs:               binary.BigEndian.Uint16(data[14:16]),
 	}
-	// We maximize gapAcks and dupTSNs here so we're not allocating tons
-	// of memory based on a user-controlable field.  Our maximums are not exact,
-	// but should give us sane defaults... we'll still hit slice boundaries and
-	// fail if the user-supplied values are too high (in the for loops below), but
-	// the amount of memory we'll have allocated because of that should be small
-	// (< sc.ActualLength)
-	gapAcks := sc.SCTPChunk.ActualLength / 2
-	dupTSNs := (sc.SCTPChunk.ActualLength - gapAcks*2) / 4
-	if gapAcks > int(sc.NumGapACKs) {
-		gapAcks = int(sc.NumGapACKs)
-	}
-	if dupTSNs > int(sc.NumDuplicateTSNs) {
-		dupTSNs = int(sc.NumDuplicateTSNs)
-	}
-	sc.GapACKs = make([]uint16, 0, gapAcks)
-	sc.DuplicateTSNs = make([]uint32, 0, dupTSNs)
-	bytesRemaining := data[16:]
-	for i := 0; i < int(sc.NumGapACKs); i++ {
-		sc.GapACKs = append(sc.GapACKs, binary.BigEndian.Uint16(bytesRemaining[:2]))
-		bytesRemaining = bytesRemaining[2:]
-	}
-	for i := 0; i < int(sc.NumDuplicateTSNs); i++ {
-		sc.DuplicateTSNs = append(sc.DuplicateTSNs, binary.BigEndian.Uint32(bytesRemaining[:4]))
+	// Check the user-controlled counts against the chunk length before
+	// allocating anything based on them.
+	bytesRemaining := data[16:sc.Length]
+	if need := 4*int(sc.NumGapACKs) + 4*int(sc.NumDuplicateTSNs); need > len(bytesRemaining) {
+		return fmt.Errorf("SCTP sack with %d gap ack blocks and %d duplicate TSNs needs %d bytes, have %d",
+			sc.NumGapACKs, sc.NumDuplicateTSNs, need, len(bytesRemaining))
+	}
+	sc.GapACKs = make([]SCTPGapACKBlock, sc.NumGapACKs)
+	for i := range sc.GapACKs {
+		sc.GapACKs[i].Start = binary.BigEndian.Uint16(bytesRemaining[0:2])
+		sc.GapACKs[i].End = binary.BigEndian.Uint16(bytesRemaining[2:4])
+		bytesRemaining = bytesRemaining[4:]
+	}
+	sc.DuplicateTSNs = make([]uint32, sc.NumDuplicateTSNs)
+	for i := range sc.DuplicateTSNs {
+		sc.DuplicateTSNs[i] = binary.BigEndian.Uint32(bytesRemaining[:4])
 		bytesRemaining = bytesRemaining[4:]
 	}
 	p.AddLayer(sc)
@@ -468,7 +615,7 @@ func decodeSCTPSack(data []byte, p gopacket.PacketBuilder) error {
 
 // SerializeTo is for gopacket.SerializableLayer.
 func (sc SCTPSack) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
-	length := 16 + 2*len(sc.GapACKs) + 4*len(sc.DuplicateTSNs)
+	length := 16 + 4*len(sc.GapACKs) + 4*len(sc.DuplicateTSNs)
 	bytes, err := b.PrependBytes(roundUpToNearest4(length))
 	if err != nil {
 		return err
@@ -481,9 +628,10 @@ func (sc SCTPSack) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.Seriali
 	binary.BigEndian.PutUint16(bytes[12:14], uint16(len(sc.GapACKs)))
 	binary.BigEndian.PutUint16(bytes[14:16], uint16(len(sc.DuplicateTSNs)))
 	for i, v := range sc.GapACKs {
-		binary.BigEndian.PutUint16(bytes[16+i*2:], v)
+		binary.BigEndian.PutUint16(bytes[16+i*4:], v.Start)
+		binary.BigEndian.PutUint16(bytes[18+i*4:], v.End)
 	}
-	offset := 16 + 2*len(sc.GapACKs)
+	offset := 16 + 4*len(sc.GapACKs)
 	for i, v := range sc.DuplicateTSNs {
 		binary.BigEndian.PutUint32(bytes[offset+i*4:], v)
 	}
@@ -519,9 +667,12 @@ func decodeSCTPHeartbeat(data []byte, p gopacket.PacketBuilder) error {
 	}
 	paramData := data[4:sc.Length]
 	for len(paramData) > 0 {
-		p := SCTPHeartbeatParameter(decodeSCTPParameter(paramData))
-		paramData = paramData[p.ActualLength:]
-		sc.Parameters = append(sc.Parameters, p)
+		param, err := decodeSCTPParameter(paramData)
+		if err != nil {
+			return err
+		}
+		paramData = paramData[param.ActualLength:]
+		sc.Parameters = append(sc.Parameters, SCTPHeartbeatParameter(param))
 	}
 	p.AddLayer(sc)
 	return p.NextDecoder(gopacket.DecodeFunc(decodeWithSCTPChunkTypePrefix))
@@ -575,9 +726,12 @@ func decodeSCTPError(data []byte, p gopacket.PacketBuilder) error {
 	}
 	paramData := data[4:sc.Length]
 	for len(paramData) > 0 {
-		p := SCTPErrorParameter(decodeSCTPParameter(paramData))
-		paramData = paramData[p.ActualLength:]
-		sc.Parameters = append(sc.Parameters, p)
+		param, err := decodeSCTPParameter(paramData)
+		if err != nil {
+			return err
+		}
+		paramData = paramData[param.ActualLength:]
+		sc.Parameters = append(sc.Parameters, SCTPErrorParameter(param))
 	}
 	p.AddLayer(sc)
 	return p.NextDecoder(gopacket.DecodeFunc(decodeWithSCTPChunkTypePrefix))
@@ -616,6 +770,9 @@ func decodeSCTPShutdown(data []byte, p gopacket.PacketBuilder) error {
 	if err != nil {
 		return err
 	}
+	if chunk.Length < 8 {
+		return fmt.Errorf("SCTP shutdown chunk length %d too short", chunk.Length)
+	}
 	sc := &SCTPShutdown{
 		SCTPChunk:        chunk,
 		CumulativeTSNAck: binary.BigEndian.Uint32(data[4:8]),
@@ -744,3 +901,112 @@ func (sc SCTPEmptyLayer) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.S
 	binary.BigEndian.PutUint16(bytes[2:4], 4)
 	return nil
 }
+
+// SCTPMessage is a user message carried by one or more SCTP DATA chunks.
+type SCTPMessage struct {
+	StreamId        uint16
+	StreamSequence  uint16
+	PayloadProtocol SCTPPayloadProtocol
+	Unordered       bool
+	Data            []byte
+}
+
+type sctpMessageKey struct {
+	stream, ssn uint16
+	unordered   bool
+}
+
+type sctpFragment struct {
+	tsn        uint32
+	begin, end bool
+	protocol   SCTPPayloadProtocol
+	data       []byte
+}
+
+// SCTPReassembler puts back together user messages which were fragmented
+// across several SCTP DATA chunks, such as large Diameter requests.
+// Fragments are grouped by stream ID and stream sequence number, ordered by
+// TSN, and a message is returned once a contiguous run of TSNs from its
+// first to its last fragment has been seen.
+//
+// TSNs and stream sequence numbers are only meaningful within one direction
+// of one association, so use a separate SCTPReassembler for each.  The zero
+// value is ready to use.
+type SCTPReassembler struct {
+	pending map[sctpMessageKey][]sctpFragment
+}
+
+// Add adds a DATA chunk to the reassembler.  It returns the complete
+// message if this chunk finishes one, or nil otherwise.  The user data is
+// copied, so the chunk may be reused once Add returns.
+func (r *SCTPReassembler) Add(d *SCTPData) *SCTPMessage {
+	msg := &SCTPMessage{
+		StreamId:        d.StreamId,
+		StreamSequence:  d.StreamSequence,
+		PayloadProtocol: d.PayloadProtocol,
+		Unordered:       d.Unordered,
+	}
+	if d.BeginFragment && d.EndFragment {
+		msg.Data = append([]byte(nil), d.UserData...)
+		return msg
+	}
+	if r.pending == nil {
+		r.pending = make(map[sctpMessageKey][]sctpFragment)
+	}
+	key := sctpMessageKey{stream: d.StreamId, ssn: d.StreamSequence, unordered: d.Unordered}
+	if d.Unordered {
+		// The stream sequence number of unordered data is meaningless, so rely
+		// on the TSNs alone.
+		key.ssn = 0
+	}
+	frags := r.pending[key]
+
+	// Insert in TSN order, allowing for wraparound and dropping duplicates.
+	i := len(frags)
+	for i > 0 && int32(d.TSN-frags[i-1].tsn) < 0 {
+		i--
+	}
+	if i > 0 && frags[i-1].tsn == d.TSN {
+		return nil
+	}
+	frags = append(frags, sctpFragment{})
+	copy(frags[i+1:], frags[i:])
+	frags[i] = sctpFragment{
+		tsn:      d.TSN,
+		begin:    d.BeginFragment,
+		end:      d.EndFragment,
+		protocol: d.PayloadProtocol,
+		data:     append([]byte(nil), d.UserData...),
+	}
+	r.pending[key] = frags
+
+	for start := range frags {
+		if !frags[start].begin {
+			continue
+		}
+		for end := start; end < len(frags); end++ {
+			if end > start && (frags[end].tsn != frags[end-1].tsn+1 || frags[end].begin) {
+				break
+			}
+			if !frags[end].end {
+				continue
+			}
+			size := 0
+			for _, f := range frags[start : end+1] {
+				size += len(f.data)
+			}
+			msg.Data = make([]byte, 0, size)
+			for _, f := range frags[start : end+1] {
+				msg.Data = append(msg.Data, f.data...)
+			}
+			msg.PayloadProtocol = frags[start].protocol
+			if frags = append(frags[:start], frags[end+1:]...); len(frags) == 0 {
+				delete(r.pending, key)
+			} else {
+				r.pending[key] = frags
+			}
+			return msg
+		}
+	}
+	return nil
+}
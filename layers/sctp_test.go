@@ -0,0 +1,139 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+func TestSCTPInitParameters(t *testing.T) {
+	// The INIT chunk from TestDecodeSCTPPackets.
+	data := []byte{
+		0x27, 0x0f, 0x22, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x19, 0x6b, 0x0b, 0x40, 0x01, 0x00, 0x00, 0x24,
+		0xb6, 0x96, 0xb0, 0x9e, 0x00, 0x01, 0xc0, 0x00, 0x00, 0x0a, 0xff, 0xff, 0xdb, 0x85, 0x60, 0x23,
+		0x00, 0x0c, 0x00, 0x06, 0x00, 0x05, 0x00, 0x00, 0x80, 0x00, 0x00, 0x04, 0xc0, 0x00, 0x00, 0x04,
+	}
+	p := gopacket.NewPacket(data, LayerTypeSCTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	init := p.Layer(LayerTypeSCTPInit).(*SCTPInit)
+	var types []SCTPInitParameterType
+	for _, param := range init.Parameters {
+		types = append(types, param.Type)
+	}
+	want := []SCTPInitParameterType{SCTPInitParameterSupportedAddressTypes, SCTPInitParameterECNCapable, SCTPInitParameterForwardTSNSupported}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("got parameters %v, want %v", types, want)
+	}
+	param, ok := init.Parameter(SCTPInitParameterSupportedAddressTypes)
+	if got := param.SupportedAddressTypes(); !ok || !reflect.DeepEqual(got, []SCTPInitParameterType{SCTPInitParameterIPv4Address}) {
+		t.Errorf("got supported address types %v", got)
+	}
+	if _, ok := init.Parameter(SCTPInitParameterStateCookie); ok {
+		t.Error("unexpected state cookie")
+	}
+
+	addr := SCTPInitParameter{Type: SCTPInitParameterIPv6Address, Value: net.ParseIP("2001:db8::1")}
+	if !addr.Address().Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("got address %v", addr.Address())
+	}
+
+	// A parameter running past the end of the chunk is an error.
+	bad := append([]byte(nil), data...)
+	bad[35] = 0x20
+	p = gopacket.NewPacket(bad, LayerTypeSCTP, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("expected error decoding bad parameter length")
+	}
+}
+
+func TestSCTPSackGapACKBlocks(t *testing.T) {
+	sack := &SCTPSack{
+		SCTPChunk:                      SCTPChunk{Type: SCTPChunkTypeSack},
+		CumulativeTSNAck:               100,
+		AdvertisedReceiverWindowCredit: 65536,
+		GapACKs:                        []SCTPGapACKBlock{{2, 3}, {6, 6}},
+		DuplicateTSNs:                  []uint32{98},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, &SCTP{SrcPort: 1, DstPort: 2}, sack); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), LayerTypeSCTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	got := p.Layer(LayerTypeSCTPSack).(*SCTPSack)
+	if got.NumGapACKs != 2 || got.NumDuplicateTSNs != 1 || !reflect.DeepEqual(got.GapACKs, sack.GapACKs) || !reflect.DeepEqual(got.DuplicateTSNs, sack.DuplicateTSNs) {
+		t.Errorf("got %+v", got)
+	}
+
+	// Claim more gap ack blocks than there is room for.
+	data := buf.Bytes()
+	data[12+13] = 9
+	p = gopacket.NewPacket(data, LayerTypeSCTP, gopacket.Default)
+	if p.ErrorLayer() == nil {
+		t.Error("expected error decoding sack with too many gap ack blocks")
+	}
+}
+
+// testSCTPDiameterFragments holds a Diameter message split across two
+// bundled DATA chunks, with the last fragment first.
+var testSCTPDiameterFragments = []byte{
+	0x0f, 0x1c, 0x0f, 0x1c, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x01, 0x00, 0x15, 0x00, 0x00, 0x00, 0x0b, 0x00, 0x01, 0x00, 0x05, 0x00, 0x00, 0x00, 0x2e,
+	0x65, 0x74, 0x65, 0x72, 0x21, 0x00, 0x00, 0x00,
+	0x00, 0x02, 0x00, 0x14, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x01, 0x00, 0x05, 0x00, 0x00, 0x00, 0x2e,
+	0x44, 0x69, 0x61, 0x6d,
+}
+
+func TestSCTPDataReassembly(t *testing.T) {
+	p := gopacket.NewPacket(testSCTPDiameterFragments, LayerTypeSCTP, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeSCTP, LayerTypeSCTPData, gopacket.LayerTypePayload, LayerTypeSCTPData, gopacket.LayerTypePayload}, t)
+
+	var chunks []*SCTPData
+	for _, l := range p.Layers() {
+		if d, ok := l.(*SCTPData); ok {
+			chunks = append(chunks, d)
+		}
+	}
+	last := chunks[0]
+	if last.BeginFragment || !last.EndFragment || last.TSN != 11 || last.StreamId != 1 || last.StreamSequence != 5 ||
+		last.PayloadProtocol != SCTPPayloadDiameter || string(last.UserData) != "eter!" {
+		t.Errorf("got DATA chunk %+v", last)
+	}
+
+	var r SCTPReassembler
+	if msg := r.Add(chunks[0]); msg != nil {
+		t.Errorf("got message %+v from a single fragment", msg)
+	}
+	if msg := r.Add(chunks[0]); msg != nil {
+		t.Errorf("got message %+v from a duplicate fragment", msg)
+	}
+	msg := r.Add(chunks[1])
+	want := &SCTPMessage{StreamId: 1, StreamSequence: 5, PayloadProtocol: SCTPPayloadDiameter, Data: []byte("Diameter!")}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("got message %+v, want %+v", msg, want)
+	}
+	if len(r.pending) != 0 {
+		t.Errorf("%d messages still pending", len(r.pending))
+	}
+
+	// An unfragmented chunk comes straight back.
+	whole := &SCTPData{BeginFragment: true, EndFragment: true, TSN: 12, StreamId: 1, StreamSequence: 6, UserData: []byte("x")}
+	if msg := r.Add(whole); msg == nil || string(msg.Data) != "x" || msg.StreamSequence != 6 {
+		t.Errorf("got message %+v", msg)
+	}
+}
@@ -8,8 +8,8 @@ package layers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"io"
 	"strconv"
 	"strings"
 
@@ -173,6 +173,25 @@ var compactSipHeadersCorrespondance = map[string]string{
 	"via":                 "v",
 }
 
+// sipLongHeaderNames is the reverse of compactSipHeadersCorrespondance
+var sipLongHeaderNames = func() map[string]string {
+	m := make(map[string]string, len(compactSipHeadersCorrespondance))
+	for long, short := range compactSipHeadersCorrespondance {
+		m[short] = long
+	}
+	return m
+}()
+
+// canonicalSIPHeaderName lower cases a header name and
+// replaces its compact form by the long one
+func canonicalSIPHeaderName(name string) string {
+	name = strings.ToLower(name)
+	if long, ok := sipLongHeaderNames[name]; ok {
+		return long
+	}
+	return name
+}
+
 // SIP object will contains information about decoded SIP packet.
 // -> The SIP Version
 // -> The SIP Headers (in a map[string][]string because of multiple headers with the same name
@@ -185,8 +204,12 @@ type SIP struct {
 	BaseLayer
 
 	// Base information
-	Version SIPVersion
-	Method  SIPMethod
+	Version    SIPVersion
+	Method     SIPMethod
+	RequestURI string
+	// Headers is keyed by lower case header name, with compact forms
+	// replaced by their long form, so that "v" and "Via" headers both
+	// end up under "via".
 	Headers map[string][]string
 
 	// Response
@@ -231,64 +254,88 @@ func (s *SIP) Payload() []byte {
 }
 
 // DecodeFromBytes decodes the slice into the SIP struct.
+//
+// Header lines folded over several lines are joined before being parsed.
+// The body is left as payload; if there's a Content-Length header, it gives
+// the length of the body and any octets past it are ignored, otherwise the
+// body runs to the end of data.
 func (s *SIP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-
-	// Init some vars for parsing follow-up
-	var countLines int
-	var line []byte
-	var err error
-
-	// Clean leading new line
-	data = bytes.Trim(data, "\n")
-
-	// Iterate on all lines of the SIP Headers
-	// and stop when we reach the SDP (aka when the new line
-	// is at index 0 of the remaining packet)
-	buffer := bytes.NewBuffer(data)
-
-	for {
-
-		// Read next line
-		line, err = buffer.ReadBytes(byte('\n'))
-		if err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				return err
-			}
+	s.reset()
+
+	// RFC 3261 - 7.5 - CRLFs ahead of the start line should be ignored
+	rest := bytes.TrimLeft(data, "\r\n")
+
+	// Split the start line and headers into lines, stopping at the empty
+	// line separating them from the body
+	var lines [][]byte
+	for len(rest) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line, rest = rest[:i], rest[i+1:]
+		} else {
+			line, rest = rest, nil
 		}
-
-		// Trim the new line delimiters
-		line = bytes.Trim(line, "\r\n")
-
-		// Empty line, we hit Body
-		// Putting packet remain in Paypload
+		line = bytes.TrimRight(line, "\r")
 		if len(line) == 0 {
-			s.BaseLayer.Payload = buffer.Bytes()
 			break
 		}
 
-		// First line is the SIP request/response line
-		// Other lines are headers
-		if countLines == 0 {
-			err = s.ParseFirstLine(line)
-			if err != nil {
-				return err
+		// Unfold lines beginning with SP or TAB into the previous header,
+		// without touching data
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(lines) < 2 {
+				return fmt.Errorf("invalid SIP continuation line: '%s'", string(line))
 			}
+			last := lines[len(lines)-1]
+			last = append(last[:len(last):len(last)], ' ')
+			lines[len(lines)-1] = append(last, bytes.TrimSpace(line)...)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		df.SetTruncated()
+		return errors.New("empty SIP message")
+	}
 
-		} else {
-			err = s.ParseHeader(line)
-			if err != nil {
-				return err
-			}
+	// First line is the SIP request/response line
+	// Other lines are headers
+	if err := s.ParseFirstLine(lines[0]); err != nil {
+		return err
+	}
+	for _, line := range lines[1:] {
+		if err := s.ParseHeader(line); err != nil {
+			return err
 		}
+	}
 
-		countLines++
+	// Putting the body in Payload
+	body := rest
+	if len(s.Headers["content-length"]) > 0 {
+		if s.contentLength > int64(len(body)) {
+			df.SetTruncated()
+			return fmt.Errorf("SIP Content-Length %d exceeds %d bytes of body", s.contentLength, len(body))
+		}
+		body = body[:s.contentLength]
 	}
+	s.BaseLayer = BaseLayer{Contents: data[:len(data)-len(rest)], Payload: body}
 
 	return nil
 }
 
+// reset clears the fields set by decoding, so a SIP object can be reused.
+func (s *SIP) reset() {
+	if s.Headers == nil {
+		s.Headers = make(map[string][]string)
+	}
+	for name := range s.Headers {
+		delete(s.Headers, name)
+	}
+	s.Version, s.Method, s.RequestURI = 0, 0, ""
+	s.IsResponse, s.ResponseCode, s.ResponseStatus = false, 0, ""
+	s.cseq, s.contentLength, s.lastHeaderParsed = 0, 0, ""
+}
+
 // ParseFirstLine will compute the first line of a SIP packet.
 // The first line will tell us if it's a request or a response.
 //
@@ -340,6 +387,7 @@ func (s *SIP) ParseFirstLine(firstLine []byte) error {
 		if err != nil {
 			return err
 		}
+		s.RequestURI = splits[1]
 
 		// Validate SIP Version
 		s.Version, err = GetSIPVersion(splits[2])
@@ -376,17 +424,21 @@ func (s *SIP) ParseHeader(header []byte) (err error) {
 	// multiline headers must begin by SP or TAB
 	if header[0] == '\t' || header[0] == ' ' {
 
+		if s.lastHeaderParsed == "" {
+			return fmt.Errorf("invalid SIP continuation line: '%s'", string(header))
+		}
 		header = bytes.TrimSpace(header)
-		s.Headers[s.lastHeaderParsed][len(s.Headers[s.lastHeaderParsed])-1] += fmt.Sprintf(" %s", string(header))
-		return
+		values := s.Headers[s.lastHeaderParsed]
+		values[len(values)-1] += fmt.Sprintf(" %s", string(header))
+		return s.ParseSpecificHeaders(s.lastHeaderParsed, values[len(values)-1])
 	}
 
 	// Find the ':' to separate header name and value
 	index := bytes.Index(header, []byte(":"))
 	if index >= 0 {
 
-		headerName := strings.ToLower(string(bytes.Trim(header[:index], " ")))
-		headerValue := string(bytes.Trim(header[index+1:], " "))
+		headerName := canonicalSIPHeaderName(string(bytes.TrimSpace(header[:index])))
+		headerValue := string(bytes.TrimSpace(header[index+1:]))
 
 		// Add header to object
 		s.Headers[headerName] = append(s.Headers[headerName], headerValue)
@@ -406,13 +458,18 @@ func (s *SIP) ParseHeader(header []byte) (err error) {
 // specific headers like CSeq or Content-Length integer values
 func (s *SIP) ParseSpecificHeaders(headerName string, headerValue string) (err error) {
 
+	// Value still to come on a continuation line
+	if headerValue == "" {
+		return nil
+	}
+
 	switch headerName {
 	case "cseq":
 
 		// CSeq header value is formatted like that :
 		// CSeq: 123 INVITE
 		// We split the value to parse Cseq integer value, and method
-		splits := strings.Split(headerValue, " ")
+		splits := strings.Fields(headerValue)
 		if len(splits) > 1 {
 
 			// Parse Cseq
@@ -449,31 +506,20 @@ func (s *SIP) GetAllHeaders() map[string][]string {
 }
 
 // GetHeader will return all the headers with
-// the specified name.
+// the specified name, which may be in compact form.
 func (s *SIP) GetHeader(headerName string) []string {
-	headerName = strings.ToLower(headerName)
-	h := make([]string, 0)
-	if _, ok := s.Headers[headerName]; ok {
-		if len(s.Headers[headerName]) > 0 {
-			return s.Headers[headerName]
-		} else if len(s.Headers[compactSipHeadersCorrespondance[headerName]]) > 0 {
-			return s.Headers[compactSipHeadersCorrespondance[headerName]]
-		}
+	if h, ok := s.Headers[canonicalSIPHeaderName(headerName)]; ok {
+		return h
 	}
-	return h
+	return make([]string, 0)
 }
 
 // GetFirstHeader will return the first header with
 // the specified name. If the current SIP packet has multiple
 // headers with the same name, it returns the first.
 func (s *SIP) GetFirstHeader(headerName string) string {
-	headerName = strings.ToLower(headerName)
-	if _, ok := s.Headers[headerName]; ok {
-		if len(s.Headers[headerName]) > 0 {
-			return s.Headers[headerName][0]
-		} else if len(s.Headers[compactSipHeadersCorrespondance[headerName]]) > 0 {
-			return s.Headers[compactSipHeadersCorrespondance[headerName]][0]
-		}
+	if h := s.Headers[canonicalSIPHeaderName(headerName)]; len(h) > 0 {
+		return h[0]
 	}
 	return ""
 }
@@ -529,3 +575,220 @@ func (s *SIP) GetContentLength() int64 {
 func (s *SIP) GetCSeq() int64 {
 	return s.cseq
 }
+
+// GetVia will return the parsed Via headers of the
+// current SIP packet, topmost first. Headers holding
+// several comma separated values are split up.
+func (s *SIP) GetVia() ([]SIPVia, error) {
+	var vias []SIPVia
+	for _, header := range s.GetHeader("Via") {
+		for _, value := range splitSIPList(header, ',') {
+			via, err := ParseSIPVia(value)
+			if err != nil {
+				return nil, err
+			}
+			vias = append(vias, via)
+		}
+	}
+	return vias, nil
+}
+
+// GetFromAddress will return the parsed From
+// header of the current SIP packet
+func (s *SIP) GetFromAddress() (SIPAddress, error) {
+	return ParseSIPAddress(s.GetFrom())
+}
+
+// GetToAddress will return the parsed To
+// header of the current SIP packet
+func (s *SIP) GetToAddress() (SIPAddress, error) {
+	return ParseSIPAddress(s.GetTo())
+}
+
+// GetContactAddresses will return the parsed Contact
+// headers of the current SIP packet. Headers holding
+// several comma separated values are split up.
+func (s *SIP) GetContactAddresses() ([]SIPAddress, error) {
+	var addresses []SIPAddress
+	for _, header := range s.GetHeader("Contact") {
+		for _, value := range splitSIPList(header, ',') {
+			address, err := ParseSIPAddress(value)
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, nil
+}
+
+// SIPVia is the value of a Via header, as defined
+// in rfc3261 section 20.42
+//
+//	Via: SIP/2.0/UDP 192.0.2.1:5060;branch=z9hG4bK776asdhds
+type SIPVia struct {
+	// Protocol is the protocol name and version, like "SIP/2.0"
+	Protocol string
+	// Transport is the transport, like "UDP"
+	Transport string
+	// SentBy is the host and optional port
+	SentBy string
+	// Params holds the parameters keyed by lower case name,
+	// parameters without value map to an empty string
+	Params map[string]string
+}
+
+// Branch will return the branch parameter of the Via
+func (v SIPVia) Branch() string {
+	return v.Params["branch"]
+}
+
+// ParseSIPVia parses a single Via header value. Whitespace
+// is allowed around the slashes and parameter separators.
+func ParseSIPVia(value string) (SIPVia, error) {
+	var via SIPVia
+	params := splitSIPList(value, ';')
+	protocol := strings.SplitN(params[0], "/", 3)
+	if len(protocol) != 3 {
+		return via, fmt.Errorf("invalid SIP Via: '%s'", value)
+	}
+	transport := strings.Fields(protocol[2])
+	if len(transport) < 2 {
+		return via, fmt.Errorf("invalid SIP Via: '%s'", value)
+	}
+	via.Protocol = strings.TrimSpace(protocol[0]) + "/" + strings.TrimSpace(protocol[1])
+	via.Transport = transport[0]
+	via.SentBy = strings.Join(transport[1:], "")
+	via.Params = parseSIPParams(params[1:])
+	return via, nil
+}
+
+// SIPAddress is the value of a From, To or Contact header,
+// as defined in rfc3261 section 20.10
+//
+//	From: "Bob" <sip:bob@biloxi.com>;tag=a6c85cf
+//	To: sip:alice@atlanta.com
+type SIPAddress struct {
+	// DisplayName is the display name, unquoted
+	DisplayName string
+	URI         string
+	// Params holds the header parameters keyed by lower case name,
+	// parameters without value map to an empty string
+	Params map[string]string
+}
+
+// Tag will return the tag parameter of the address
+func (a SIPAddress) Tag() string {
+	return a.Params["tag"]
+}
+
+// ParseSIPAddress parses a single From, To or Contact header
+// value. When the URI isn't enclosed in angle brackets, any
+// parameters following it are header parameters.
+func ParseSIPAddress(value string) (SIPAddress, error) {
+	var a SIPAddress
+	var rest string
+	v := strings.TrimSpace(value)
+	if v == "" {
+		return a, errors.New("empty SIP address")
+	}
+
+	// Quoted display name, which may contain escaped characters
+	if v[0] == '"' {
+		name, n, err := sipUnquote(v)
+		if err != nil {
+			return a, err
+		}
+		a.DisplayName = name
+		v = strings.TrimLeft(v[n:], " \t")
+		if !strings.HasPrefix(v, "<") {
+			return a, fmt.Errorf("invalid SIP address: '%s'", value)
+		}
+	}
+
+	if start := strings.IndexByte(v, '<'); start >= 0 {
+		if start > 0 {
+			a.DisplayName = strings.TrimSpace(v[:start])
+		}
+		end := strings.IndexByte(v[start:], '>')
+		if end < 0 {
+			return a, fmt.Errorf("invalid SIP address: '%s'", value)
+		}
+		a.URI = strings.TrimSpace(v[start+1 : start+end])
+		rest = v[start+end+1:]
+	} else if i := strings.IndexByte(v, ';'); i >= 0 {
+		a.URI, rest = strings.TrimSpace(v[:i]), v[i:]
+	} else {
+		a.URI = v
+	}
+
+	params := splitSIPList(rest, ';')
+	if strings.TrimSpace(params[0]) != "" {
+		return a, fmt.Errorf("invalid SIP address: '%s'", value)
+	}
+	a.Params = parseSIPParams(params[1:])
+	return a, nil
+}
+
+// splitSIPList splits a header value on sep, ignoring
+// separators within quoted strings and angle brackets
+func splitSIPList(value string, sep byte) []string {
+	var parts []string
+	var quoted, escaped bool
+	var brackets, start int
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted:
+			escaped = c == '\\'
+			quoted = c != '"'
+		case c == '"':
+			quoted = true
+		case c == '<':
+			brackets++
+		case c == '>' && brackets > 0:
+			brackets--
+		case c == sep && brackets == 0:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// parseSIPParams parses name=value parameters, which
+// have been split on semicolons already
+func parseSIPParams(params []string) map[string]string {
+	m := make(map[string]string, len(params))
+	for _, param := range params {
+		name, value := param, ""
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			name, value = param[:i], strings.TrimSpace(param[i+1:])
+		}
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			m[name] = value
+		}
+	}
+	return m
+}
+
+// sipUnquote unescapes the quoted string at the start of value,
+// returning it along with the length of its quoted form
+func sipUnquote(value string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			if i++; i < len(value) {
+				b.WriteByte(value[i])
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated SIP quoted string: '%s'", value)
+}
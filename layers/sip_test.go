@@ -7,6 +7,8 @@
 package layers
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/gopacket"
@@ -149,3 +151,199 @@ func _TestPacketSIP(t *testing.T, packetData []byte, methodWanted SIPMethod, isR
 		}
 	}
 }
+
+// sipTorture converts a message from RFC 4475 to CRLF line endings.
+func sipTorture(msg string) []byte {
+	return []byte(strings.Replace(msg, "\n", "\r\n", -1))
+}
+
+// RFC 4475 - 3.1.1.1 - A Short Tortuous INVITE
+var testSIPTortuousInvite = sipTorture(`INVITE sip:vivekg@chair-dnrc.example.com;unknownparam SIP/2.0
+TO :
+ sip:vivekg@chair-dnrc.example.com ;   tag    = 1918181833n
+from   : "J Rosenberg \\\""       <sip:jdrosen@example.com>
+  ;
+  tag = 98asjd8
+MaX-fOrWaRdS: 0068
+Call-ID: wsinv.ndaksdj@192.0.2.1
+Content-Length   : 150
+cseq: 0009
+  INVITE
+Via  : SIP  /   2.0
+ /UDP
+    192.0.2.2;branch=390skdjuw
+s :
+NewFangledHeader:   newfangled value
+ continued newfangled value
+UnknownHeaderWithUnusualValue: ;;,,;;,;
+Content-Type: application/sdp
+Route:
+ <sip:services.example.com;lr;unknownwith=value;unknown-no-value>
+v:  SIP  / 2.0  / TCP     spindle.example.com   ;
+  branch  =   z9hG4bK9ikj8  ,
+ SIP  /    2.0   / UDP  192.168.255.111   ; branch=
+ z9hG4bK30239
+m:"Quoted string \"\"" <sip:jdrosen@example.com> ; newparam =
+      newvalue ;
+  secondparam ; q = 0.33
+
+v=0
+o=mhandley 29739 7272939 IN IP4 192.0.2.3
+s=-
+c=IN IP4 192.0.2.4
+t=0 0
+m=audio 49217 RTP/AVP 0 12
+m=video 3227 RTP/AVP 31
+a=rtpmap:31 LPC
+`)
+
+// RFC 4475 - 3.1.1.6 - Message with No LWS between Display Name and <
+var testSIPNoLWSDisplayName = sipTorture(`OPTIONS sip:user@example.com SIP/2.0
+To: sip:user@example.com
+From: caller<sip:caller@example.com>;tag=323
+Max-Forwards: 70
+Call-ID: lwsdisp.1234abcd@funky.example.com
+CSeq: 60 OPTIONS
+Via: SIP/2.0/UDP funky.example.com;branch=z9hG4bKkdjuw
+l: 0
+
+`)
+
+// RFC 4475 - 3.1.1.8 - Extra Trailing Octets in a UDP Datagram
+var testSIPTrailingOctets = sipTorture(`REGISTER sip:example.com SIP/2.0
+To: sip:j.user@example.com
+From: sip:j.user@example.com;tag=43251j3j324
+Max-Forwards: 8
+I: dblreg.0ha0isndaksdj99sdfafnl3lk233412
+Contact: sip:j.user@host.example.com
+CSeq: 8 REGISTER
+Via: SIP/2.0/UDP 192.0.2.125;branch=z9hG4bKon23nfi23jdfkj23
+Content-Length: 0
+
+INVITE sip:joe@example.com SIP/2.0
+t: sip:joe@example.com
+From: sip:caller@example.net;tag=141334
+Max-Forwards: 8
+Call-ID: dblreq.0ha0isnda977644900765@192.0.2.15
+CSeq: 8 INVITE
+Via: SIP/2.0/UDP 192.0.2.15;branch=z9hG4bKkdjuw380234
+Content-Type: application/sdp
+Content-Length: 0
+
+`)
+
+// RFC 4475 - 3.1.2.7 - Content Length Larger Than Message
+var testSIPContentLengthTooLarge = sipTorture(`INVITE sip:user@example.com SIP/2.0
+Max-Forwards: 80
+To: sip:j.user@example.com
+From: sip:caller@example.net;tag=93942939o2
+Contact: <sip:caller@hungry.example.net>
+Call-ID: clerr.0ha0isndaksdjweiafasdk3
+CSeq: 8 INVITE
+Via: SIP/2.0/UDP host5.example.com;branch=z9hG4bK-39234-23523
+Content-Type: application/sdp
+Content-Length: 9999
+
+v=0
+o=mhandley 29739 7272939 IN IP4 192.0.2.155
+s=-
+c=IN IP4 192.0.2.155
+t=0 0
+m=audio 49217 RTP/AVP 0
+`)
+
+func TestSIPTortuousInvite(t *testing.T) {
+	s := NewSIP()
+	if err := s.DecodeFromBytes(testSIPTortuousInvite, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if s.Method != SIPMethodInvite || s.RequestURI != "sip:vivekg@chair-dnrc.example.com;unknownparam" || s.IsResponse {
+		t.Errorf("start line mismatch: %v %q", s.Method, s.RequestURI)
+	}
+	if s.GetCSeq() != 9 || s.GetContentLength() != 150 || len(s.Payload()) != 150 {
+		t.Errorf("CSeq %d, Content-Length %d, body of %d bytes", s.GetCSeq(), s.GetContentLength(), len(s.Payload()))
+	}
+	if got := s.GetFirstHeader("max-forwards"); got != "0068" {
+		t.Errorf("Max-Forwards %q", got)
+	}
+	if got := s.GetFirstHeader("NewFangledHeader"); got != "newfangled value continued newfangled value" {
+		t.Errorf("folded header %q", got)
+	}
+
+	vias, err := s.GetVia()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SIPVia{
+		{Protocol: "SIP/2.0", Transport: "UDP", SentBy: "192.0.2.2", Params: map[string]string{"branch": "390skdjuw"}},
+		{Protocol: "SIP/2.0", Transport: "TCP", SentBy: "spindle.example.com", Params: map[string]string{"branch": "z9hG4bK9ikj8"}},
+		{Protocol: "SIP/2.0", Transport: "UDP", SentBy: "192.168.255.111", Params: map[string]string{"branch": "z9hG4bK30239"}},
+	}
+	if !reflect.DeepEqual(vias, want) {
+		t.Errorf("Via mismatch\nwant %+v\ngot  %+v", want, vias)
+	}
+
+	to, err := s.GetToAddress()
+	if err != nil || to.URI != "sip:vivekg@chair-dnrc.example.com" || to.Tag() != "1918181833n" {
+		t.Errorf("To mismatch: %+v, %v", to, err)
+	}
+	from, err := s.GetFromAddress()
+	if err != nil || from.DisplayName != `J Rosenberg \"` || from.URI != "sip:jdrosen@example.com" || from.Tag() != "98asjd8" {
+		t.Errorf("From mismatch: %+v, %v", from, err)
+	}
+	contacts, err := s.GetContactAddresses()
+	wantContacts := []SIPAddress{{
+		DisplayName: `Quoted string ""`,
+		URI:         "sip:jdrosen@example.com",
+		Params:      map[string]string{"newparam": "newvalue", "secondparam": "", "q": "0.33"},
+	}}
+	if err != nil || !reflect.DeepEqual(contacts, wantContacts) {
+		t.Errorf("Contact mismatch: %+v, %v", contacts, err)
+	}
+}
+
+func TestSIPNoLWSDisplayName(t *testing.T) {
+	s := NewSIP()
+	if err := s.DecodeFromBytes(testSIPNoLWSDisplayName, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	from, err := s.GetFromAddress()
+	if err != nil || from.DisplayName != "caller" || from.URI != "sip:caller@example.com" || from.Tag() != "323" {
+		t.Errorf("From mismatch: %+v, %v", from, err)
+	}
+	if s.GetFirstHeader("Content-Length") != "0" || len(s.Payload()) != 0 {
+		t.Errorf("compact Content-Length not found")
+	}
+}
+
+func TestSIPTrailingOctets(t *testing.T) {
+	s := NewSIP()
+	if err := s.DecodeFromBytes(testSIPTrailingOctets, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if s.Method != SIPMethodRegister || s.GetCallID() != "dblreg.0ha0isndaksdj99sdfafnl3lk233412" {
+		t.Errorf("REGISTER mismatch: %v %q", s.Method, s.GetCallID())
+	}
+	if len(s.Payload()) != 0 {
+		t.Errorf("trailing octets in payload: %q", s.Payload())
+	}
+	vias, err := s.GetVia()
+	if err != nil || len(vias) != 1 || vias[0].Branch() != "z9hG4bKon23nfi23jdfkj23" {
+		t.Errorf("Via mismatch: %+v, %v", vias, err)
+	}
+
+	// Decoding again must not keep anything from the previous message
+	if err := s.DecodeFromBytes(testSIPNoLWSDisplayName, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.GetHeader("Contact")) != 0 || s.Method != SIPMethodOptions {
+		t.Errorf("state left over from previous message: %+v", s.Headers)
+	}
+}
+
+func TestSIPContentLengthTooLarge(t *testing.T) {
+	s := NewSIP()
+	if err := s.DecodeFromBytes(testSIPContentLengthTooLarge, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding message shorter than Content-Length")
+	}
+}
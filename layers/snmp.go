@@ -0,0 +1,759 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+)
+
+// BER tags of the universal types SNMP uses.
+const (
+	berTagInteger          = 0x02
+	berTagOctetString      = 0x04
+	berTagNull             = 0x05
+	berTagObjectIdentifier = 0x06
+	berTagSequence         = 0x30
+)
+
+// errBERTruncated is returned when data ends partway through a BER element.
+var errBERTruncated = errors.New("BER element truncated")
+
+// berDecode decodes the BER encoded element at the start of data, returning
+// its tag and contents, and the data following it.  Only the definite
+// length form is supported, which is all SNMP allows.
+func berDecode(data []byte) (tag byte, contents, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errBERTruncated
+	}
+	tag = data[0]
+	if tag&0x1f == 0x1f {
+		return 0, nil, nil, fmt.Errorf("BER tag %#x with multiple bytes not supported", tag)
+	}
+	length, offset := int(data[1]), 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 {
+			return 0, nil, nil, errors.New("BER indefinite length not supported")
+		} else if n > 3 {
+			return 0, nil, nil, fmt.Errorf("BER length of %d bytes too long", n)
+		} else if len(data) < 2+n {
+			return 0, nil, nil, errBERTruncated
+		}
+		length = 0
+		for _, b := range data[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+	if len(data)-offset < length {
+		return 0, nil, nil, errBERTruncated
+	}
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
+
+// berExpect decodes the element at the start of data, which must have the
+// given tag.
+func berExpect(data []byte, tag byte, what string) (contents, rest []byte, err error) {
+	t, contents, rest, err := berDecode(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("SNMP %s: %v", what, err)
+	}
+	if t != tag {
+		return nil, nil, fmt.Errorf("SNMP %s has tag %#x, not %#x", what, t, tag)
+	}
+	return contents, rest, nil
+}
+
+// berInteger decodes the contents of a signed integer of at most 64 bits.
+func berInteger(contents []byte) (int64, error) {
+	if len(contents) == 0 || len(contents) > 8 {
+		return 0, fmt.Errorf("BER integer of %d bytes not supported", len(contents))
+	}
+	v := int64(int8(contents[0]))
+	for _, b := range contents[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+// berUnsigned decodes the contents of an unsigned integer of at most 64
+// bits, which may take a leading zero byte.
+func berUnsigned(contents []byte) (uint64, error) {
+	if len(contents) > 1 && contents[0] == 0 {
+		contents = contents[1:]
+	}
+	if len(contents) == 0 || len(contents) > 8 {
+		return 0, fmt.Errorf("BER unsigned integer of %d bytes not supported", len(contents))
+	}
+	var v uint64
+	for _, b := range contents {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+// berOID decodes the contents of an object identifier to its dotted form.
+func berOID(contents []byte) (string, error) {
+	if len(contents) == 0 {
+		return "", errors.New("BER object identifier empty")
+	}
+	var ids []string
+	var v uint64
+	for i, b := range contents {
+		if v > 1<<56 {
+			return "", errors.New("BER object identifier component too large")
+		}
+		v = v<<7 | uint64(b&0x7f)
+		if b&0x80 != 0 {
+			if i == len(contents)-1 {
+				return "", errors.New("BER object identifier truncated")
+			}
+			continue
+		}
+		if len(ids) == 0 {
+			// The first two components are packed together.
+			first := v / 40
+			if first > 2 {
+				first = 2
+			}
+			ids = append(ids, strconv.FormatUint(first, 10), strconv.FormatUint(v-first*40, 10))
+		} else {
+			ids = append(ids, strconv.FormatUint(v, 10))
+		}
+		v = 0
+	}
+	return strings.Join(ids, "."), nil
+}
+
+// berAppend appends the element with the given tag and contents to b.
+func berAppend(b []byte, tag byte, contents []byte) []byte {
+	b = append(b, tag)
+	switch l := len(contents); {
+	case l < 0x80:
+		b = append(b, byte(l))
+	case l <= 0xff:
+		b = append(b, 0x81, byte(l))
+	case l <= 0xffff:
+		b = append(b, 0x82, byte(l>>8), byte(l))
+	default:
+		b = append(b, 0x83, byte(l>>16), byte(l>>8), byte(l))
+	}
+	return append(b, contents...)
+}
+
+// berAppendInteger appends the signed integer v, in the fewest bytes.
+func berAppendInteger(b []byte, tag byte, v int64) []byte {
+	n := 8
+	for n > 1 && (v>>uint(8*(n-1)-1) == 0 || v>>uint(8*(n-1)-1) == -1) {
+		n--
+	}
+	contents := make([]byte, n)
+	for i := range contents {
+		contents[i] = byte(v >> uint(8*(n-1-i)))
+	}
+	return berAppend(b, tag, contents)
+}
+
+// berAppendUnsigned appends the unsigned integer v, in the fewest bytes
+// with a leading zero byte if its top bit is set.
+func berAppendUnsigned(b []byte, tag byte, v uint64) []byte {
+	contents := make([]byte, 9)
+	for i := 8; i > 0; i-- {
+		contents[i] = byte(v)
+		v >>= 8
+	}
+	i := 0
+	for i < 8 && contents[i] == 0 && contents[i+1]&0x80 == 0 {
+		i++
+	}
+	return berAppend(b, tag, contents[i:])
+}
+
+// berAppendOID appends the object identifier oid, given in dotted form.
+func berAppendOID(b []byte, oid string) ([]byte, error) {
+	parts := strings.Split(oid, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("SNMP object identifier %q has less than 2 components", oid)
+	}
+	ids := make([]uint64, len(parts))
+	for i, p := range parts {
+		var err error
+		if ids[i], err = strconv.ParseUint(p, 10, 64); err != nil {
+			return nil, fmt.Errorf("SNMP object identifier %q: %v", oid, err)
+		}
+	}
+	if ids[0] > 2 || (ids[0] < 2 && ids[1] >= 40) {
+		return nil, fmt.Errorf("SNMP object identifier %q invalid", oid)
+	}
+	ids = append([]uint64{ids[0]*40 + ids[1]}, ids[2:]...)
+	var contents []byte
+	for _, id := range ids {
+		n := 1
+		for v := id >> 7; v > 0; v >>= 7 {
+			n++
+		}
+		for i := n - 1; i >= 0; i-- {
+			c := byte(id>>uint(7*i)) & 0x7f
+			if i > 0 {
+				c |= 0x80
+			}
+			contents = append(contents, c)
+		}
+	}
+	return berAppend(b, berTagObjectIdentifier, contents), nil
+}
+
+// SNMPVersion is the version of an SNMP message, as encoded in it.
+type SNMPVersion int
+
+const (
+	SNMPVersion1  SNMPVersion = 0
+	SNMPVersion2c SNMPVersion = 1
+	SNMPVersion3  SNMPVersion = 3
+)
+
+func (v SNMPVersion) String() string {
+	switch v {
+	case SNMPVersion1:
+		return "v1"
+	case SNMPVersion2c:
+		return "v2c"
+	case SNMPVersion3:
+		return "v3"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(v))
+	}
+}
+
+// SNMPPDUType is the type of an SNMP PDU, which is its BER tag.
+type SNMPPDUType uint8
+
+const (
+	SNMPPDUGetRequest     SNMPPDUType = 0xa0
+	SNMPPDUGetNextRequest SNMPPDUType = 0xa1
+	SNMPPDUResponse       SNMPPDUType = 0xa2
+	SNMPPDUSetRequest     SNMPPDUType = 0xa3
+	SNMPPDUTrapV1         SNMPPDUType = 0xa4
+	SNMPPDUGetBulkRequest SNMPPDUType = 0xa5
+	SNMPPDUInformRequest  SNMPPDUType = 0xa6
+	SNMPPDUTrapV2         SNMPPDUType = 0xa7
+	SNMPPDUReport         SNMPPDUType = 0xa8
+)
+
+func (t SNMPPDUType) String() string {
+	switch t {
+	case SNMPPDUGetRequest:
+		return "GetRequest"
+	case SNMPPDUGetNextRequest:
+		return "GetNextRequest"
+	case SNMPPDUResponse:
+		return "Response"
+	case SNMPPDUSetRequest:
+		return "SetRequest"
+	case SNMPPDUTrapV1:
+		return "Trap"
+	case SNMPPDUGetBulkRequest:
+		return "GetBulkRequest"
+	case SNMPPDUInformRequest:
+		return "InformRequest"
+	case SNMPPDUTrapV2:
+		return "SNMPv2-Trap"
+	case SNMPPDUReport:
+		return "Report"
+	default:
+		return fmt.Sprintf("Unknown(%#x)", uint8(t))
+	}
+}
+
+// SNMPErrorStatus is the error status of an SNMP PDU.
+type SNMPErrorStatus int
+
+const (
+	SNMPErrorNoError             SNMPErrorStatus = 0
+	SNMPErrorTooBig              SNMPErrorStatus = 1
+	SNMPErrorNoSuchName          SNMPErrorStatus = 2
+	SNMPErrorBadValue            SNMPErrorStatus = 3
+	SNMPErrorReadOnly            SNMPErrorStatus = 4
+	SNMPErrorGenErr              SNMPErrorStatus = 5
+	SNMPErrorNoAccess            SNMPErrorStatus = 6
+	SNMPErrorWrongType           SNMPErrorStatus = 7
+	SNMPErrorWrongLength         SNMPErrorStatus = 8
+	SNMPErrorWrongEncoding       SNMPErrorStatus = 9
+	SNMPErrorWrongValue          SNMPErrorStatus = 10
+	SNMPErrorNoCreation          SNMPErrorStatus = 11
+	SNMPErrorInconsistentValue   SNMPErrorStatus = 12
+	SNMPErrorResourceUnavailable SNMPErrorStatus = 13
+	SNMPErrorCommitFailed        SNMPErrorStatus = 14
+	SNMPErrorUndoFailed          SNMPErrorStatus = 15
+	SNMPErrorAuthorizationError  SNMPErrorStatus = 16
+	SNMPErrorNotWritable         SNMPErrorStatus = 17
+	SNMPErrorInconsistentName    SNMPErrorStatus = 18
+)
+
+var snmpErrorStatusNames = []string{
+	"noError", "tooBig", "noSuchName", "badValue", "readOnly", "genErr",
+	"noAccess", "wrongType", "wrongLength", "wrongEncoding", "wrongValue",
+	"noCreation", "inconsistentValue", "resourceUnavailable", "commitFailed",
+	"undoFailed", "authorizationError", "notWritable", "inconsistentName",
+}
+
+func (s SNMPErrorStatus) String() string {
+	if s >= 0 && int(s) < len(snmpErrorStatusNames) {
+		return snmpErrorStatusNames[s]
+	}
+	return fmt.Sprintf("Unknown(%d)", int(s))
+}
+
+// SNMPGenericTrap is the generic trap type of an SNMPv1 trap.
+type SNMPGenericTrap int
+
+const (
+	SNMPGenericTrapColdStart             SNMPGenericTrap = 0
+	SNMPGenericTrapWarmStart             SNMPGenericTrap = 1
+	SNMPGenericTrapLinkDown              SNMPGenericTrap = 2
+	SNMPGenericTrapLinkUp                SNMPGenericTrap = 3
+	SNMPGenericTrapAuthenticationFailure SNMPGenericTrap = 4
+	SNMPGenericTrapEGPNeighborLoss       SNMPGenericTrap = 5
+	SNMPGenericTrapEnterpriseSpecific    SNMPGenericTrap = 6
+)
+
+func (g SNMPGenericTrap) String() string {
+	switch g {
+	case SNMPGenericTrapColdStart:
+		return "coldStart"
+	case SNMPGenericTrapWarmStart:
+		return "warmStart"
+	case SNMPGenericTrapLinkDown:
+		return "linkDown"
+	case SNMPGenericTrapLinkUp:
+		return "linkUp"
+	case SNMPGenericTrapAuthenticationFailure:
+		return "authenticationFailure"
+	case SNMPGenericTrapEGPNeighborLoss:
+		return "egpNeighborLoss"
+	case SNMPGenericTrapEnterpriseSpecific:
+		return "enterpriseSpecific"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(g))
+	}
+}
+
+// SNMPValueType is the type of the value of a variable binding, which is
+// its BER tag.
+type SNMPValueType uint8
+
+const (
+	SNMPValueInteger          SNMPValueType = berTagInteger
+	SNMPValueOctetString      SNMPValueType = berTagOctetString
+	SNMPValueNull             SNMPValueType = berTagNull
+	SNMPValueObjectIdentifier SNMPValueType = berTagObjectIdentifier
+	SNMPValueIPAddress        SNMPValueType = 0x40
+	SNMPValueCounter32        SNMPValueType = 0x41
+	SNMPValueGauge32          SNMPValueType = 0x42
+	SNMPValueTimeTicks        SNMPValueType = 0x43
+	SNMPValueOpaque           SNMPValueType = 0x44
+	SNMPValueCounter64        SNMPValueType = 0x46
+	SNMPValueNoSuchObject     SNMPValueType = 0x80
+	SNMPValueNoSuchInstance   SNMPValueType = 0x81
+	SNMPValueEndOfMibView     SNMPValueType = 0x82
+)
+
+func (t SNMPValueType) String() string {
+	switch t {
+	case SNMPValueInteger:
+		return "Integer"
+	case SNMPValueOctetString:
+		return "OctetString"
+	case SNMPValueNull:
+		return "Null"
+	case SNMPValueObjectIdentifier:
+		return "ObjectIdentifier"
+	case SNMPValueIPAddress:
+		return "IpAddress"
+	case SNMPValueCounter32:
+		return "Counter32"
+	case SNMPValueGauge32:
+		return "Gauge32"
+	case SNMPValueTimeTicks:
+		return "TimeTicks"
+	case SNMPValueOpaque:
+		return "Opaque"
+	case SNMPValueCounter64:
+		return "Counter64"
+	case SNMPValueNoSuchObject:
+		return "noSuchObject"
+	case SNMPValueNoSuchInstance:
+		return "noSuchInstance"
+	case SNMPValueEndOfMibView:
+		return "endOfMibView"
+	default:
+		return fmt.Sprintf("Unknown(%#x)", uint8(t))
+	}
+}
+
+// SNMPValue is the value of a variable binding.  Which of the fields
+// following Type holds it depends on Type; Null and the exceptions of
+// responses have none.
+type SNMPValue struct {
+	Type SNMPValueType
+	// Int holds Integer values.
+	Int int64
+	// Uint holds Counter32, Gauge32, TimeTicks and Counter64 values.
+	Uint uint64
+	// Bytes holds OctetString and Opaque values, and the contents of
+	// values of unknown types.
+	Bytes []byte
+	// OID holds ObjectIdentifier values, in dotted form.
+	OID string
+	// IP holds IpAddress values.
+	IP net.IP
+}
+
+func (v SNMPValue) String() string {
+	switch v.Type {
+	case SNMPValueInteger:
+		return strconv.FormatInt(v.Int, 10)
+	case SNMPValueCounter32, SNMPValueGauge32, SNMPValueTimeTicks, SNMPValueCounter64:
+		return fmt.Sprintf("%v(%d)", v.Type, v.Uint)
+	case SNMPValueOctetString:
+		return strconv.Quote(string(v.Bytes))
+	case SNMPValueObjectIdentifier:
+		return v.OID
+	case SNMPValueIPAddress:
+		return v.IP.String()
+	case SNMPValueNull, SNMPValueNoSuchObject, SNMPValueNoSuchInstance, SNMPValueEndOfMibView:
+		return v.Type.String()
+	default:
+		return fmt.Sprintf("%v(%x)", v.Type, v.Bytes)
+	}
+}
+
+func (v *SNMPValue) decode(tag byte, contents []byte) error {
+	v.Type = SNMPValueType(tag)
+	var err error
+	switch v.Type {
+	case SNMPValueInteger:
+		v.Int, err = berInteger(contents)
+	case SNMPValueCounter32, SNMPValueGauge32, SNMPValueTimeTicks:
+		if v.Uint, err = berUnsigned(contents); err == nil && v.Uint > 0xffffffff {
+			err = fmt.Errorf("SNMP %v value %d too large", v.Type, v.Uint)
+		}
+	case SNMPValueCounter64:
+		v.Uint, err = berUnsigned(contents)
+	case SNMPValueObjectIdentifier:
+		v.OID, err = berOID(contents)
+	case SNMPValueIPAddress:
+		if len(contents) != 4 {
+			return fmt.Errorf("SNMP IpAddress of %d bytes", len(contents))
+		}
+		v.IP = net.IP(contents)
+	case SNMPValueNull, SNMPValueNoSuchObject, SNMPValueNoSuchInstance, SNMPValueEndOfMibView:
+		if len(contents) != 0 {
+			return fmt.Errorf("SNMP %v value of %d bytes", v.Type, len(contents))
+		}
+	default:
+		v.Bytes = contents
+	}
+	return err
+}
+
+func (v *SNMPValue) encode(b []byte) ([]byte, error) {
+	tag := byte(v.Type)
+	switch v.Type {
+	case SNMPValueInteger:
+		return berAppendInteger(b, tag, v.Int), nil
+	case SNMPValueCounter32, SNMPValueGauge32, SNMPValueTimeTicks, SNMPValueCounter64:
+		return berAppendUnsigned(b, tag, v.Uint), nil
+	case SNMPValueObjectIdentifier:
+		return berAppendOID(b, v.OID)
+	case SNMPValueIPAddress:
+		ip := v.IP.To4()
+		if ip == nil {
+			return nil, fmt.Errorf("SNMP IpAddress %v isn't an IPv4 address", v.IP)
+		}
+		return berAppend(b, tag, ip), nil
+	case SNMPValueNull, SNMPValueNoSuchObject, SNMPValueNoSuchInstance, SNMPValueEndOfMibView:
+		return berAppend(b, tag, nil), nil
+	default:
+		return berAppend(b, tag, v.Bytes), nil
+	}
+}
+
+// SNMPVarBind is a variable binding, an object identifier in dotted form
+// with its value.  Requests bind them to Null values.
+type SNMPVarBind struct {
+	OID   string
+	Value SNMPValue
+}
+
+// SNMP is an SNMPv1 or SNMPv2c message [RFC1157] [RFC3416].  SNMPv3 messages
+// are only recognized by their version, the rest of them is left in Data.
+type SNMP struct {
+	BaseLayer
+	Version   SNMPVersion
+	Community []byte
+	PDUType   SNMPPDUType
+
+	// RequestID, ErrorStatus and ErrorIndex are set for all PDUs but
+	// SNMPv1 traps.  GetBulkRequest PDUs carry NonRepeaters and
+	// MaxRepetitions in place of ErrorStatus and ErrorIndex.
+	RequestID      int32
+	ErrorStatus    SNMPErrorStatus
+	ErrorIndex     int
+	NonRepeaters   int
+	MaxRepetitions int
+
+	// Enterprise to Timestamp are set for SNMPv1 traps.
+	Enterprise   string
+	AgentAddress net.IP
+	GenericTrap  SNMPGenericTrap
+	SpecificTrap int
+	Timestamp    uint32
+
+	VarBinds []SNMPVarBind
+
+	// Data holds what follows the version of SNMPv3 messages.
+	Data []byte
+}
+
+// LayerType returns LayerTypeSNMP.
+func (s *SNMP) LayerType() gopacket.LayerType { return LayerTypeSNMP }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (s *SNMP) CanDecode() gopacket.LayerClass { return LayerTypeSNMP }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (s *SNMP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since SNMP messages carry no payload.
+func (s *SNMP) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.  Bytes past the
+// message are ignored.
+func (s *SNMP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	tag, msg, rest, err := berDecode(data)
+	if err == errBERTruncated {
+		df.SetTruncated()
+		return errors.New("SNMP message truncated")
+	} else if err != nil {
+		return fmt.Errorf("SNMP message: %v", err)
+	} else if tag != berTagSequence {
+		return fmt.Errorf("SNMP message has tag %#x, not %#x", tag, berTagSequence)
+	}
+	s.BaseLayer = BaseLayer{Contents: data[:len(data)-len(rest)]}
+
+	contents, rest, err := berExpect(msg, berTagInteger, "version")
+	if err != nil {
+		return err
+	}
+	version, err := berInteger(contents)
+	if err != nil {
+		return err
+	}
+	s.Version = SNMPVersion(version)
+	s.Community, s.Data, s.VarBinds = nil, nil, s.VarBinds[:0]
+	switch s.Version {
+	case SNMPVersion1, SNMPVersion2c:
+	case SNMPVersion3:
+		s.Data = rest
+		return nil
+	default:
+		return fmt.Errorf("SNMP version %d not supported", version)
+	}
+
+	if s.Community, rest, err = berExpect(rest, berTagOctetString, "community"); err != nil {
+		return err
+	}
+	tag, pdu, _, err := berDecode(rest)
+	if err != nil {
+		return fmt.Errorf("SNMP PDU: %v", err)
+	}
+	s.PDUType = SNMPPDUType(tag)
+	switch s.PDUType {
+	case SNMPPDUTrapV1:
+		pdu, err = s.decodeTrapV1(pdu)
+	case SNMPPDUGetRequest, SNMPPDUGetNextRequest, SNMPPDUResponse, SNMPPDUSetRequest,
+		SNMPPDUGetBulkRequest, SNMPPDUInformRequest, SNMPPDUTrapV2, SNMPPDUReport:
+		pdu, err = s.decodePDUHeader(pdu)
+	default:
+		return fmt.Errorf("SNMP PDU type %v not supported", s.PDUType)
+	}
+	if err != nil {
+		return err
+	}
+	return s.decodeVarBinds(pdu)
+}
+
+// snmpIntegers decodes the integers at the start of data into vs.
+func snmpIntegers(data []byte, what []string, vs ...*int64) ([]byte, error) {
+	for i, v := range vs {
+		contents, rest, err := berExpect(data, berTagInteger, what[i])
+		if err != nil {
+			return nil, err
+		}
+		if *v, err = berInteger(contents); err != nil {
+			return nil, fmt.Errorf("SNMP %s: %v", what[i], err)
+		}
+		data = rest
+	}
+	return data, nil
+}
+
+func (s *SNMP) decodePDUHeader(pdu []byte) ([]byte, error) {
+	var id, status, index int64
+	rest, err := snmpIntegers(pdu, []string{"request ID", "error status", "error index"}, &id, &status, &index)
+	if err != nil {
+		return nil, err
+	}
+	s.RequestID = int32(id)
+	if s.PDUType == SNMPPDUGetBulkRequest {
+		s.ErrorStatus, s.ErrorIndex = 0, 0
+		s.NonRepeaters, s.MaxRepetitions = int(status), int(index)
+	} else {
+		s.ErrorStatus, s.ErrorIndex = SNMPErrorStatus(status), int(index)
+		s.NonRepeaters, s.MaxRepetitions = 0, 0
+	}
+	return rest, nil
+}
+
+func (s *SNMP) decodeTrapV1(pdu []byte) ([]byte, error) {
+	contents, rest, err := berExpect(pdu, berTagObjectIdentifier, "enterprise")
+	if err != nil {
+		return nil, err
+	}
+	if s.Enterprise, err = berOID(contents); err != nil {
+		return nil, err
+	}
+	if contents, rest, err = berExpect(rest, byte(SNMPValueIPAddress), "agent address"); err != nil {
+		return nil, err
+	} else if len(contents) != 4 {
+		return nil, fmt.Errorf("SNMP agent address of %d bytes", len(contents))
+	}
+	s.AgentAddress = net.IP(contents)
+	var generic, specific int64
+	if rest, err = snmpIntegers(rest, []string{"generic trap", "specific trap"}, &generic, &specific); err != nil {
+		return nil, err
+	}
+	s.GenericTrap, s.SpecificTrap = SNMPGenericTrap(generic), int(specific)
+	if contents, rest, err = berExpect(rest, byte(SNMPValueTimeTicks), "timestamp"); err != nil {
+		return nil, err
+	}
+	ts, err := berUnsigned(contents)
+	if err != nil || ts > 0xffffffff {
+		return nil, fmt.Errorf("SNMP timestamp %x invalid", contents)
+	}
+	s.Timestamp = uint32(ts)
+	return rest, nil
+}
+
+func (s *SNMP) decodeVarBinds(pdu []byte) error {
+	list, _, err := berExpect(pdu, berTagSequence, "variable bindings")
+	if err != nil {
+		return err
+	}
+	for len(list) > 0 {
+		var vb, contents []byte
+		if vb, list, err = berExpect(list, berTagSequence, "variable binding"); err != nil {
+			return err
+		}
+		var b SNMPVarBind
+		if contents, vb, err = berExpect(vb, berTagObjectIdentifier, "variable binding name"); err != nil {
+			return err
+		}
+		if b.OID, err = berOID(contents); err != nil {
+			return err
+		}
+		tag, contents, _, err := berDecode(vb)
+		if err != nil {
+			return fmt.Errorf("SNMP variable binding value: %v", err)
+		}
+		if err := b.Value.decode(tag, contents); err != nil {
+			return err
+		}
+		s.VarBinds = append(s.VarBinds, b)
+	}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (s *SNMP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	msg := berAppendInteger(nil, berTagInteger, int64(s.Version))
+	if s.Version == SNMPVersion3 {
+		msg = append(msg, s.Data...)
+	} else {
+		msg = berAppend(msg, berTagOctetString, s.Community)
+		pdu, err := s.encodePDU()
+		if err != nil {
+			return err
+		}
+		msg = berAppend(msg, byte(s.PDUType), pdu)
+	}
+	msg = berAppend(nil, berTagSequence, msg)
+	bytes, err := b.PrependBytes(len(msg))
+	if err != nil {
+		return err
+	}
+	copy(bytes, msg)
+	return nil
+}
+
+func (s *SNMP) encodePDU() ([]byte, error) {
+	var pdu []byte
+	switch s.PDUType {
+	case SNMPPDUTrapV1:
+		var err error
+		if pdu, err = berAppendOID(pdu, s.Enterprise); err != nil {
+			return nil, err
+		}
+		addr := s.AgentAddress.To4()
+		if addr == nil {
+			return nil, fmt.Errorf("SNMP agent address %v isn't an IPv4 address", s.AgentAddress)
+		}
+		pdu = berAppend(pdu, byte(SNMPValueIPAddress), addr)
+		pdu = berAppendInteger(pdu, berTagInteger, int64(s.GenericTrap))
+		pdu = berAppendInteger(pdu, berTagInteger, int64(s.SpecificTrap))
+		pdu = berAppendUnsigned(pdu, byte(SNMPValueTimeTicks), uint64(s.Timestamp))
+	case SNMPPDUGetBulkRequest:
+		pdu = berAppendInteger(pdu, berTagInteger, int64(s.RequestID))
+		pdu = berAppendInteger(pdu, berTagInteger, int64(s.NonRepeaters))
+		pdu = berAppendInteger(pdu, berTagInteger, int64(s.MaxRepetitions))
+	default:
+		pdu = berAppendInteger(pdu, berTagInteger, int64(s.RequestID))
+		pdu = berAppendInteger(pdu, berTagInteger, int64(s.ErrorStatus))
+		pdu = berAppendInteger(pdu, berTagInteger, int64(s.ErrorIndex))
+	}
+	var list []byte
+	for i := range s.VarBinds {
+		vb, err := berAppendOID(nil, s.VarBinds[i].OID)
+		if err != nil {
+			return nil, err
+		}
+		if vb, err = s.VarBinds[i].Value.encode(vb); err != nil {
+			return nil, err
+		}
+		list = berAppend(list, berTagSequence, vb)
+	}
+	return berAppend(pdu, berTagSequence, list), nil
+}
+
+func decodeSNMP(data []byte, p gopacket.PacketBuilder) error {
+	s := &SNMP{}
+	if err := s.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(s)
+	p.SetApplicationLayer(s)
+	return nil
+}
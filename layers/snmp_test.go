@@ -0,0 +1,246 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketSNMPGetRequest is an SNMPv2c GetRequest for sysDescr.0 and
+// sysUpTime.0, with community "public".
+var testPacketSNMPGetRequest = []byte{
+	0x02, 0x42, 0xc0, 0x00, 0x02, 0x01, 0x02, 0x42, 0xc0, 0x00, 0x02, 0xc8, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x55, 0x3e, 0x21, 0x40, 0x00, 0x40, 0x11, 0x77, 0xad, 0xc0, 0x00, 0x02, 0xc8, 0xc0, 0x00,
+	0x02, 0x01, 0xc0, 0x00, 0x00, 0xa1, 0x00, 0x41, 0x3a, 0x88, 0x30, 0x37, 0x02, 0x01, 0x01, 0x04,
+	0x06, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0xa0, 0x2a, 0x02, 0x04, 0x1a, 0x2b, 0x3c, 0x4d, 0x02,
+	0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x1c, 0x30, 0x0c, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01,
+	0x01, 0x01, 0x00, 0x05, 0x00, 0x30, 0x0c, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03,
+	0x00, 0x05, 0x00,
+}
+
+func TestSNMPGetRequest(t *testing.T) {
+	p := gopacket.NewPacket(testPacketSNMPGetRequest, LinkTypeEthernet, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeSNMP}, t)
+	s := p.Layer(LayerTypeSNMP).(*SNMP)
+	want := &SNMP{
+		BaseLayer: BaseLayer{Contents: testPacketSNMPGetRequest[42:]},
+		Version:   SNMPVersion2c,
+		Community: []byte("public"),
+		PDUType:   SNMPPDUGetRequest,
+		RequestID: 0x1a2b3c4d,
+		VarBinds: []SNMPVarBind{
+			{OID: "1.3.6.1.2.1.1.1.0", Value: SNMPValue{Type: SNMPValueNull}},
+			{OID: "1.3.6.1.2.1.1.3.0", Value: SNMPValue{Type: SNMPValueNull}},
+		},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("SNMP mismatch, \nwant %#v\ngot  %#v", want, s)
+	}
+	testSerialization(t, p, testPacketSNMPGetRequest)
+}
+
+func TestSNMPSerializeGetRequest(t *testing.T) {
+	s := &SNMP{
+		Version:   SNMPVersion2c,
+		Community: []byte("public"),
+		PDUType:   SNMPPDUGetRequest,
+		RequestID: 0x1a2b3c4d,
+		VarBinds: []SNMPVarBind{
+			{OID: "1.3.6.1.2.1.1.1.0", Value: SNMPValue{Type: SNMPValueNull}},
+			{OID: "1.3.6.1.2.1.1.3.0", Value: SNMPValue{Type: SNMPValueNull}},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, s); err != nil {
+		t.Fatal(err)
+	}
+	if want := testPacketSNMPGetRequest[42:]; !reflect.DeepEqual(buf.Bytes(), want) {
+		t.Errorf("serialized GetRequest, want\n%x\ngot\n%x", want, buf.Bytes())
+	}
+}
+
+// testPacketSNMPResponse is an SNMPv2c Response binding values of all
+// types, down to a noSuchObject exception.
+var testPacketSNMPResponse = []byte{
+	0x30, 0x81, 0xdd, 0x02, 0x01, 0x01, 0x04, 0x06, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0xa2, 0x81,
+	0xcf, 0x02, 0x04, 0x1a, 0x2b, 0x3c, 0x4d, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x81, 0xc0,
+	0x30, 0x1d, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, 0x04, 0x11, 0x4c, 0x69,
+	0x6e, 0x75, 0x78, 0x20, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x72, 0x20, 0x34, 0x2e, 0x31, 0x39, 0x30,
+	0x10, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x03, 0x00, 0x43, 0x04, 0x07, 0x5b, 0xcd,
+	0x15, 0x30, 0x16, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x02, 0x00, 0x06, 0x0a, 0x2b,
+	0x06, 0x01, 0x04, 0x01, 0xbf, 0x08, 0x03, 0x02, 0x0a, 0x30, 0x16, 0x06, 0x0e, 0x2b, 0x06, 0x01,
+	0x02, 0x01, 0x04, 0x14, 0x01, 0x01, 0x81, 0x40, 0x00, 0x02, 0x01, 0x40, 0x04, 0xc0, 0x00, 0x02,
+	0x01, 0x30, 0x13, 0x06, 0x0a, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x05, 0x02, 0x42,
+	0x05, 0x00, 0xff, 0xff, 0xff, 0xff, 0x30, 0x0f, 0x06, 0x0a, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x02,
+	0x02, 0x01, 0x0e, 0x02, 0x41, 0x01, 0x07, 0x30, 0x18, 0x06, 0x0b, 0x2b, 0x06, 0x01, 0x02, 0x01,
+	0x1f, 0x01, 0x01, 0x01, 0x06, 0x02, 0x46, 0x09, 0x00, 0xfe, 0xdc, 0xba, 0x98, 0x76, 0x54, 0x32,
+	0x10, 0x30, 0x0f, 0x06, 0x0a, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x07, 0x02, 0x02,
+	0x01, 0xff, 0x30, 0x0c, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x09, 0x00, 0x80, 0x00,
+}
+
+func TestSNMPResponse(t *testing.T) {
+	p := gopacket.NewPacket(testPacketSNMPResponse, LayerTypeSNMP, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	s := p.Layer(LayerTypeSNMP).(*SNMP)
+	if s.PDUType != SNMPPDUResponse || s.ErrorStatus != SNMPErrorNoError || s.RequestID != 0x1a2b3c4d {
+		t.Errorf("unexpected PDU %v, error status %v, request ID %#x", s.PDUType, s.ErrorStatus, s.RequestID)
+	}
+	want := []SNMPVarBind{
+		{OID: "1.3.6.1.2.1.1.1.0", Value: SNMPValue{Type: SNMPValueOctetString, Bytes: []byte("Linux router 4.19")}},
+		{OID: "1.3.6.1.2.1.1.3.0", Value: SNMPValue{Type: SNMPValueTimeTicks, Uint: 123456789}},
+		{OID: "1.3.6.1.2.1.1.2.0", Value: SNMPValue{Type: SNMPValueObjectIdentifier, OID: "1.3.6.1.4.1.8072.3.2.10"}},
+		{OID: "1.3.6.1.2.1.4.20.1.1.192.0.2.1", Value: SNMPValue{Type: SNMPValueIPAddress, IP: net.IP{192, 0, 2, 1}}},
+		{OID: "1.3.6.1.2.1.2.2.1.5.2", Value: SNMPValue{Type: SNMPValueGauge32, Uint: 4294967295}},
+		{OID: "1.3.6.1.2.1.2.2.1.14.2", Value: SNMPValue{Type: SNMPValueCounter32, Uint: 7}},
+		{OID: "1.3.6.1.2.1.31.1.1.1.6.2", Value: SNMPValue{Type: SNMPValueCounter64, Uint: 0xfedcba9876543210}},
+		{OID: "1.3.6.1.2.1.2.2.1.7.2", Value: SNMPValue{Type: SNMPValueInteger, Int: -1}},
+		{OID: "1.3.6.1.2.1.1.9.0", Value: SNMPValue{Type: SNMPValueNoSuchObject}},
+	}
+	if len(s.VarBinds) != len(want) {
+		t.Fatalf("variable bindings, want %d got %d", len(want), len(s.VarBinds))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(s.VarBinds[i], want[i]) {
+			t.Errorf("variable binding %d, want %v %v got %v %v", i, want[i].OID, want[i].Value, s.VarBinds[i].OID, s.VarBinds[i].Value)
+		}
+	}
+	testSerialization(t, p, testPacketSNMPResponse)
+}
+
+// testPacketSNMPTrapV1 is an SNMPv1 linkDown trap for ifIndex 3.
+var testPacketSNMPTrapV1 = []byte{
+	0x02, 0x42, 0xc0, 0x00, 0x02, 0xc8, 0x02, 0x42, 0xc0, 0x00, 0x02, 0x0a, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0x56, 0x00, 0x01, 0x40, 0x00, 0x40, 0x11, 0xb5, 0xc3, 0xc0, 0x00, 0x02, 0x0a, 0xc0, 0x00,
+	0x02, 0xc8, 0x04, 0x00, 0x00, 0xa2, 0x00, 0x42, 0x2c, 0x8f, 0x30, 0x38, 0x02, 0x01, 0x00, 0x04,
+	0x06, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0xa4, 0x2b, 0x06, 0x06, 0x2b, 0x06, 0x01, 0x04, 0x01,
+	0x09, 0x40, 0x04, 0xc0, 0x00, 0x02, 0x0a, 0x02, 0x01, 0x02, 0x02, 0x01, 0x00, 0x43, 0x02, 0x30,
+	0x39, 0x30, 0x11, 0x30, 0x0f, 0x06, 0x0a, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x01,
+	0x03, 0x02, 0x01, 0x03,
+}
+
+func TestSNMPTrapV1(t *testing.T) {
+	p := gopacket.NewPacket(testPacketSNMPTrapV1, LinkTypeEthernet, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeSNMP}, t)
+	s := p.Layer(LayerTypeSNMP).(*SNMP)
+	if s.Version != SNMPVersion1 || s.PDUType != SNMPPDUTrapV1 {
+		t.Errorf("unexpected version %v PDU %v", s.Version, s.PDUType)
+	}
+	if s.Enterprise != "1.3.6.1.4.1.9" || !s.AgentAddress.Equal(net.IPv4(192, 0, 2, 10)) ||
+		s.GenericTrap != SNMPGenericTrapLinkDown || s.SpecificTrap != 0 || s.Timestamp != 12345 {
+		t.Errorf("unexpected trap %s %v %v %d %d", s.Enterprise, s.AgentAddress, s.GenericTrap, s.SpecificTrap, s.Timestamp)
+	}
+	want := []SNMPVarBind{{OID: "1.3.6.1.2.1.2.2.1.1.3", Value: SNMPValue{Type: SNMPValueInteger, Int: 3}}}
+	if !reflect.DeepEqual(s.VarBinds, want) {
+		t.Errorf("variable bindings, want %v got %v", want, s.VarBinds)
+	}
+	testSerialization(t, p, testPacketSNMPTrapV1)
+}
+
+// testPacketSNMPGetBulkRequest is an SNMPv2c GetBulkRequest walking
+// ifDescr 10 rows at a time.
+var testPacketSNMPGetBulkRequest = []byte{
+	0x30, 0x28, 0x02, 0x01, 0x01, 0x04, 0x07, 0x70, 0x72, 0x69, 0x76, 0x61, 0x74, 0x65, 0xa5, 0x1a,
+	0x02, 0x01, 0x4d, 0x02, 0x01, 0x00, 0x02, 0x01, 0x0a, 0x30, 0x0f, 0x30, 0x0d, 0x06, 0x09, 0x2b,
+	0x06, 0x01, 0x02, 0x01, 0x02, 0x02, 0x01, 0x02, 0x05, 0x00,
+}
+
+func TestSNMPGetBulkRequest(t *testing.T) {
+	p := gopacket.NewPacket(testPacketSNMPGetBulkRequest, LayerTypeSNMP, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	s := p.Layer(LayerTypeSNMP).(*SNMP)
+	if string(s.Community) != "private" || s.PDUType != SNMPPDUGetBulkRequest || s.RequestID != 77 ||
+		s.NonRepeaters != 0 || s.MaxRepetitions != 10 || s.ErrorStatus != 0 {
+		t.Errorf("unexpected GetBulkRequest %+v", s)
+	}
+	testSerialization(t, p, testPacketSNMPGetBulkRequest)
+}
+
+// testPacketSNMPv3 is an SNMPv3 discovery GetRequest, with an empty user
+// name and no authentication or privacy.
+var testPacketSNMPv3 = []byte{
+	0x30, 0x38, 0x02, 0x01, 0x03, 0x30, 0x0e, 0x02, 0x01, 0x5b, 0x02, 0x03, 0x00, 0xff, 0xe3, 0x04,
+	0x01, 0x04, 0x02, 0x01, 0x03, 0x04, 0x10, 0x30, 0x0e, 0x04, 0x00, 0x02, 0x01, 0x00, 0x02, 0x01,
+	0x00, 0x04, 0x00, 0x04, 0x00, 0x04, 0x00, 0x30, 0x11, 0x04, 0x00, 0x04, 0x00, 0xa0, 0x0b, 0x02,
+	0x01, 0x5b, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x00,
+}
+
+func TestSNMPv3(t *testing.T) {
+	p := gopacket.NewPacket(testPacketSNMPv3, LayerTypeSNMP, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	s := p.Layer(LayerTypeSNMP).(*SNMP)
+	if s.Version != SNMPVersion3 || !reflect.DeepEqual(s.Data, testPacketSNMPv3[5:]) {
+		t.Errorf("unexpected version %v data %x", s.Version, s.Data)
+	}
+	testSerialization(t, p, testPacketSNMPv3)
+}
+
+func TestSNMPMalformed(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		data      []byte
+		truncated bool
+	}{
+		{"empty", []byte{0x30}, true},
+		{"message truncated", testPacketSNMPGetBulkRequest[:30], true},
+		{"not a sequence", []byte{0x04, 0x00}, false},
+		{"indefinite length", []byte{0x30, 0x80, 0x02, 0x01, 0x01, 0x00, 0x00}, false},
+		{"unknown version", []byte{0x30, 0x03, 0x02, 0x01, 0x02}, false},
+		{"no community", []byte{0x30, 0x03, 0x02, 0x01, 0x01}, false},
+		{"unknown PDU", []byte{0x30, 0x07, 0x02, 0x01, 0x01, 0x04, 0x00, 0xaf, 0x00}, false},
+		{"request ID too long", []byte{0x30, 0x11, 0x02, 0x01, 0x01, 0x04, 0x00, 0xa0, 0x0a,
+			0x02, 0x09, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}, false},
+		{"bad IP address", []byte{0x30, 0x1c, 0x02, 0x01, 0x01, 0x04, 0x00, 0xa2, 0x15,
+			0x02, 0x01, 0x01, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x0a,
+			0x30, 0x08, 0x06, 0x03, 0x2b, 0x06, 0x01, 0x40, 0x01, 0xc0}, false},
+		{"OID truncated", []byte{0x30, 0x1b, 0x02, 0x01, 0x01, 0x04, 0x00, 0xa2, 0x14,
+			0x02, 0x01, 0x01, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x09,
+			0x30, 0x07, 0x06, 0x03, 0x2b, 0x06, 0x81, 0x05, 0x00}, false},
+	} {
+		p := gopacket.NewPacket(test.data, LayerTypeSNMP, testDecodeOptions)
+		if p.ErrorLayer() == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+		if p.Metadata().Truncated != test.truncated {
+			t.Errorf("%s: want truncated %v, got %v", test.name, test.truncated, p.Metadata().Truncated)
+		}
+	}
+}
+
+func TestSNMPOIDEncoding(t *testing.T) {
+	for _, oid := range []string{"0.0", "1.3.6.1.4.1.2636.3.1.13.1.8", "2.999.3", "1.3.6.1.4.1.4294967295"} {
+		b, err := berAppendOID(nil, oid)
+		if err != nil {
+			t.Errorf("%s: %v", oid, err)
+			continue
+		}
+		_, contents, _, _ := berDecode(b)
+		if got, err := berOID(contents); err != nil || got != oid {
+			t.Errorf("%s: got %s, %v", oid, got, err)
+		}
+	}
+	for _, oid := range []string{"1", "3.1", "1.40", "1.3.a"} {
+		if _, err := berAppendOID(nil, oid); err == nil {
+			t.Errorf("%s: no error", oid)
+		}
+	}
+}
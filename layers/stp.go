@@ -7,21 +7,367 @@
 package layers
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
 	"github.com/google/gopacket"
 )
 
+// STPBPDUType is the type of a BPDU.
+type STPBPDUType uint8
+
+const (
+	STPBPDUTypeConfig STPBPDUType = 0x00
+	// STPBPDUTypeRST is the type of RSTP and MSTP BPDUs.
+	STPBPDUTypeRST STPBPDUType = 0x02
+	STPBPDUTypeTCN STPBPDUType = 0x80
+)
+
+func (t STPBPDUType) String() string {
+	switch t {
+	case STPBPDUTypeConfig:
+		return "Config"
+	case STPBPDUTypeRST:
+		return "RST"
+	case STPBPDUTypeTCN:
+		return "TCN"
+	default:
+		return fmt.Sprintf("Unknown(%#02x)", uint8(t))
+	}
+}
+
+// STP protocol versions.
+const (
+	STPProtocolVersionSTP  uint8 = 0
+	STPProtocolVersionRSTP uint8 = 2
+	STPProtocolVersionMSTP uint8 = 3
+)
+
+// STPPortRole is the port role carried by the flags of RSTP and MSTP BPDUs.
+type STPPortRole uint8
+
+const (
+	// STPPortRoleUnknown is the master port role of MSTI messages.
+	STPPortRoleUnknown           STPPortRole = 0
+	STPPortRoleAlternateOrBackup STPPortRole = 1
+	STPPortRoleRoot              STPPortRole = 2
+	STPPortRoleDesignated        STPPortRole = 3
+)
+
+func (r STPPortRole) String() string {
+	switch r {
+	case STPPortRoleUnknown:
+		return "Unknown"
+	case STPPortRoleAlternateOrBackup:
+		return "AlternateOrBackup"
+	case STPPortRoleRoot:
+		return "Root"
+	case STPPortRoleDesignated:
+		return "Designated"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(r))
+	}
+}
+
+// STPFlags is the flags byte of BPDUs and MSTI configuration messages.
+// Configuration BPDUs only use TopologyChange and TopologyChangeAck.
+type STPFlags struct {
+	TopologyChange bool
+	Proposal       bool
+	PortRole       STPPortRole
+	Learning       bool
+	Forwarding     bool
+	Agreement      bool
+	// TopologyChangeAck is the master flag of MSTI configuration messages.
+	TopologyChangeAck bool
+}
+
+func decodeSTPFlags(b uint8) STPFlags {
+	return STPFlags{
+		TopologyChange:    b&0x01 != 0,
+		Proposal:          b&0x02 != 0,
+		PortRole:          STPPortRole(b>>2) & 0x03,
+		Learning:          b&0x10 != 0,
+		Forwarding:        b&0x20 != 0,
+		Agreement:         b&0x40 != 0,
+		TopologyChangeAck: b&0x80 != 0,
+	}
+}
+
+func (f STPFlags) encode() uint8 {
+	b := uint8(f.PortRole&0x03) << 2
+	for i, set := range []bool{f.TopologyChange, f.Proposal, false, false, f.Learning, f.Forwarding, f.Agreement, f.TopologyChangeAck} {
+		if set {
+			b |= 1 << uint(i)
+		}
+	}
+	return b
+}
+
+// STPBridgeID is a bridge identifier.  Priority is a multiple of 4096,
+// SystemID the 12 bit extension holding the VLAN or MSTI.
+type STPBridgeID struct {
+	Priority uint16
+	SystemID uint16
+	Address  net.HardwareAddr
+}
+
+func decodeSTPBridgeID(data []byte) STPBridgeID {
+	p := binary.BigEndian.Uint16(data[0:2])
+	return STPBridgeID{
+		Priority: p & 0xf000,
+		SystemID: p & 0x0fff,
+		Address:  net.HardwareAddr(data[2:8]),
+	}
+}
+
+func (id *STPBridgeID) encode(b []byte) {
+	binary.BigEndian.PutUint16(b[0:2], id.Priority&0xf000|id.SystemID&0x0fff)
+	zero(b[2:8])
+	copy(b[2:8], id.Address)
+}
+
+// STPMSTConfigID is the MST configuration identifier of MSTP BPDUs, which
+// bridges of the same region share.
+type STPMSTConfigID struct {
+	FormatSelector uint8
+	// Name is up to 32 bytes, padded with NULs on the wire.
+	Name     string
+	Revision uint16
+	// Digest is the HMAC-MD5 of the VLAN to MSTI table.
+	Digest [16]byte
+}
+
+// STPMSTIConfig is an MSTI configuration message of an MSTP BPDU.
+type STPMSTIConfig struct {
+	Flags                STPFlags
+	RegionalRootID       STPBridgeID
+	InternalRootPathCost uint32
+	// BridgePriority and PortPriority are the high 4 bits of the priorities.
+	BridgePriority uint8
+	PortPriority   uint8
+	RemainingHops  uint8
+}
+
+const (
+	stpTCNLength     = 4
+	stpConfigLength  = 35
+	stpRSTLength     = 36
+	stpMSTLength     = 102
+	stpMSTILength    = 16
+	stpMSTNameLength = 32
+)
+
 // STP decode spanning tree protocol packets to transport BPDU (bridge protocol data unit) message.
+//
+// All BPDU types are decoded, the fields present depending on Type and
+// Version: TCN BPDUs end with Type, configuration BPDUs with ForwardDelay,
+// RST BPDUs with Version1Length and MST BPDUs, of version 3, carry the
+// rest.
 type STP struct {
 	BaseLayer
+	ProtocolID   uint16
+	Version      uint8
+	Type         STPBPDUType
+	Flags        STPFlags
+	RootID       STPBridgeID
+	RootPathCost uint32
+	BridgeID     STPBridgeID
+	PortID       uint16
+	// Times are in units of 1/256 seconds.
+	MessageAge   uint16
+	MaxAge       uint16
+	HelloTime    uint16
+	ForwardDelay uint16
+
+	Version1Length uint8
+
+	Version3Length           uint16
+	MSTConfigID              STPMSTConfigID
+	CISTInternalRootPathCost uint32
+	CISTBridgeID             STPBridgeID
+	CISTRemainingHops        uint8
+	MSTIs                    []STPMSTIConfig
 }
 
 // LayerType returns gopacket.LayerTypeSTP.
 func (s *STP) LayerType() gopacket.LayerType { return LayerTypeSTP }
 
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (s *STP) CanDecode() gopacket.LayerClass { return LayerTypeSTP }
+
+// NextLayerType returns gopacket.LayerTypeZero, BPDUs carrying no payload.
+func (s *STP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// MST returns true if this is an MST BPDU, carrying the MSTP fields.
+func (s *STP) MST() bool {
+	return s.Type == STPBPDUTypeRST && s.Version >= STPProtocolVersionMSTP
+}
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (s *STP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < stpTCNLength {
+		df.SetTruncated()
+		return errors.New("STP BPDU too short")
+	}
+	*s = STP{
+		ProtocolID: binary.BigEndian.Uint16(data[0:2]),
+		Version:    data[2],
+		Type:       STPBPDUType(data[3]),
+	}
+	length := stpTCNLength
+	switch s.Type {
+	case STPBPDUTypeTCN:
+	case STPBPDUTypeConfig, STPBPDUTypeRST:
+		length = stpConfigLength
+		if s.Type == STPBPDUTypeRST {
+			length = stpRSTLength
+		}
+		if len(data) < length {
+			df.SetTruncated()
+			return fmt.Errorf("STP %v BPDU length %d too short", s.Type, len(data))
+		}
+		s.Flags = decodeSTPFlags(data[4])
+		s.RootID = decodeSTPBridgeID(data[5:13])
+		s.RootPathCost = binary.BigEndian.Uint32(data[13:17])
+		s.BridgeID = decodeSTPBridgeID(data[17:25])
+		s.PortID = binary.BigEndian.Uint16(data[25:27])
+		s.MessageAge = binary.BigEndian.Uint16(data[27:29])
+		s.MaxAge = binary.BigEndian.Uint16(data[29:31])
+		s.HelloTime = binary.BigEndian.Uint16(data[31:33])
+		s.ForwardDelay = binary.BigEndian.Uint16(data[33:35])
+		if s.Type == STPBPDUTypeRST {
+			s.Version1Length = data[35]
+		}
+		if s.MST() {
+			var err error
+			if length, err = s.decodeMST(data, df); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown STP BPDU type %v", s.Type)
+	}
+	s.BaseLayer = BaseLayer{Contents: data[:length], Payload: data[length:]}
+	return nil
+}
+
+// decodeMST decodes the MSTP fields, returning the length of the BPDU.
+func (s *STP) decodeMST(data []byte, df gopacket.DecodeFeedback) (int, error) {
+	if len(data) < stpMSTLength {
+		df.SetTruncated()
+		return 0, fmt.Errorf("STP MST BPDU length %d too short", len(data))
+	}
+	s.Version3Length = binary.BigEndian.Uint16(data[36:38])
+	s.MSTConfigID.FormatSelector = data[38]
+	name := data[39 : 39+stpMSTNameLength]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	s.MSTConfigID.Name = string(name)
+	s.MSTConfigID.Revision = binary.BigEndian.Uint16(data[71:73])
+	copy(s.MSTConfigID.Digest[:], data[73:89])
+	s.CISTInternalRootPathCost = binary.BigEndian.Uint32(data[89:93])
+	s.CISTBridgeID = decodeSTPBridgeID(data[93:101])
+	s.CISTRemainingHops = data[101]
+
+	length := stpRSTLength + 2 + int(s.Version3Length)
+	if s.Version3Length < stpMSTLength-stpRSTLength-2 || (length-stpMSTLength)%stpMSTILength != 0 {
+		return 0, fmt.Errorf("invalid STP version 3 length %d", s.Version3Length)
+	}
+	if length > len(data) {
+		df.SetTruncated()
+		return 0, fmt.Errorf("STP version 3 length %d exceeds %d bytes", s.Version3Length, len(data)-stpRSTLength-2)
+	}
+	for b := data[stpMSTLength:length]; len(b) > 0; b = b[stpMSTILength:] {
+		s.MSTIs = append(s.MSTIs, STPMSTIConfig{
+			Flags:                decodeSTPFlags(b[0]),
+			RegionalRootID:       decodeSTPBridgeID(b[1:9]),
+			InternalRootPathCost: binary.BigEndian.Uint32(b[9:13]),
+			BridgePriority:       b[13] >> 4,
+			PortPriority:         b[14] >> 4,
+			RemainingHops:        b[15],
+		})
+	}
+	return length, nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+func (s *STP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	length := stpTCNLength
+	switch {
+	case s.Type == STPBPDUTypeTCN:
+	case s.MST():
+		length = stpMSTLength + stpMSTILength*len(s.MSTIs)
+	case s.Type == STPBPDUTypeRST:
+		length = stpRSTLength
+	case s.Type == STPBPDUTypeConfig:
+		length = stpConfigLength
+	default:
+		return fmt.Errorf("unknown STP BPDU type %v", s.Type)
+	}
+	if len(s.MSTConfigID.Name) > stpMSTNameLength {
+		return fmt.Errorf("STP MST configuration name of %d bytes too long", len(s.MSTConfigID.Name))
+	}
+	if opts.FixLengths {
+		s.Version1Length = 0
+		if s.MST() {
+			s.Version3Length = uint16(length - stpRSTLength - 2)
+		}
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], s.ProtocolID)
+	bytes[2] = s.Version
+	bytes[3] = uint8(s.Type)
+	if length == stpTCNLength {
+		return nil
+	}
+	bytes[4] = s.Flags.encode()
+	s.RootID.encode(bytes[5:13])
+	binary.BigEndian.PutUint32(bytes[13:17], s.RootPathCost)
+	s.BridgeID.encode(bytes[17:25])
+	binary.BigEndian.PutUint16(bytes[25:27], s.PortID)
+	binary.BigEndian.PutUint16(bytes[27:29], s.MessageAge)
+	binary.BigEndian.PutUint16(bytes[29:31], s.MaxAge)
+	binary.BigEndian.PutUint16(bytes[31:33], s.HelloTime)
+	binary.BigEndian.PutUint16(bytes[33:35], s.ForwardDelay)
+	if length == stpConfigLength {
+		return nil
+	}
+	bytes[35] = s.Version1Length
+	if length == stpRSTLength {
+		return nil
+	}
+	binary.BigEndian.PutUint16(bytes[36:38], s.Version3Length)
+	bytes[38] = s.MSTConfigID.FormatSelector
+	zero(bytes[39 : 39+stpMSTNameLength])
+	copy(bytes[39:39+stpMSTNameLength], s.MSTConfigID.Name)
+	binary.BigEndian.PutUint16(bytes[71:73], s.MSTConfigID.Revision)
+	copy(bytes[73:89], s.MSTConfigID.Digest[:])
+	binary.BigEndian.PutUint32(bytes[89:93], s.CISTInternalRootPathCost)
+	s.CISTBridgeID.encode(bytes[93:101])
+	bytes[101] = s.CISTRemainingHops
+	for i, m := range s.MSTIs {
+		b := bytes[stpMSTLength+i*stpMSTILength:]
+		b[0] = m.Flags.encode()
+		m.RegionalRootID.encode(b[1:9])
+		binary.BigEndian.PutUint32(b[9:13], m.InternalRootPathCost)
+		b[13] = m.BridgePriority << 4
+		b[14] = m.PortPriority << 4
+		b[15] = m.RemainingHops
+	}
+	return nil
+}
+
 func decodeSTP(data []byte, p gopacket.PacketBuilder) error {
 	stp := &STP{}
-	stp.Contents = data[:]
-	// TODO:  parse the STP protocol into actual subfields.
-	p.AddLayer(stp)
-	return nil
+	return decodingLayerDecoder(stp, data, p)
 }
@@ -0,0 +1,176 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketRSTP is an RSTP BPDU of a designated port, proposing and both
+// learning and forwarding, padded to the minimum Ethernet frame size.
+var testPacketRSTP = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x00, 0x00, 0x1c, 0x0e, 0x87, 0x85, 0x00, 0x00, 0x27, 0x42, 0x42,
+	0x03, 0x00, 0x00, 0x02, 0x02, 0x3e, 0x80, 0x01, 0x00, 0x1c, 0x0e, 0x87, 0x78, 0x00, 0x00, 0x00,
+	0x4e, 0x20, 0x80, 0x01, 0x00, 0x1c, 0x0e, 0x87, 0x85, 0x00, 0x80, 0x04, 0x01, 0x00, 0x14, 0x00,
+	0x02, 0x00, 0x0f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// testPacketMSTP is an MSTP BPDU of region "region1", revision 3, with one
+// MSTI configuration message.
+var testPacketMSTP = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x00, 0x00, 0x1c, 0x0e, 0x87, 0x85, 0x00, 0x00, 0x79, 0x42, 0x42,
+	0x03, 0x00, 0x00, 0x03, 0x02, 0x78, 0x80, 0x00, 0x00, 0x1c, 0x0e, 0x87, 0x78, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x80, 0x00, 0x00, 0x1c, 0x0e, 0x87, 0x78, 0x00, 0x80, 0x04, 0x00, 0x00, 0x14, 0x00,
+	0x02, 0x00, 0x0f, 0x00, 0x00, 0x00, 0x50, 0x00, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x31, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5,
+	0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xab, 0xac, 0xad, 0xae, 0xaf, 0x00, 0x00, 0x4e, 0x20, 0x70, 0x00,
+	0x00, 0x1c, 0x0e, 0x87, 0x85, 0x00, 0x14, 0x7c, 0x80, 0x01, 0x00, 0x1c, 0x0e, 0x87, 0x78, 0x00,
+	0x00, 0x00, 0x4e, 0x20, 0x80, 0x80, 0x13,
+}
+
+func TestPacketSTPConfig(t *testing.T) {
+	data := []byte{
+		0x01, 0x80, 0xc2, 0x00, 0x00, 0x00, 0x00, 0x1c, 0x0e, 0x87, 0x85, 0x04, 0x00, 0x26, 0x42, 0x42,
+		0x03, 0x00, 0x00, 0x00, 0x00, 0x81, 0x80, 0x64, 0x00, 0x1c, 0x0e, 0x87, 0x78, 0x00, 0x00, 0x00,
+		0x00, 0x04, 0x80, 0x64, 0x00, 0x1c, 0x0e, 0x87, 0x85, 0x00, 0x80, 0x04, 0x01, 0x00, 0x14, 0x00,
+		0x02, 0x00, 0x0f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLLC, LayerTypeSTP}, t)
+	got := p.Layer(LayerTypeSTP).(*STP)
+	want := &STP{
+		BaseLayer:    BaseLayer{Contents: data[17:52], Payload: []byte{}},
+		Type:         STPBPDUTypeConfig,
+		Flags:        STPFlags{TopologyChange: true, TopologyChangeAck: true},
+		RootID:       STPBridgeID{Priority: 0x8000, SystemID: 0x64, Address: net.HardwareAddr{0x00, 0x1c, 0x0e, 0x87, 0x78, 0x00}},
+		RootPathCost: 4,
+		BridgeID:     STPBridgeID{Priority: 0x8000, SystemID: 0x64, Address: net.HardwareAddr{0x00, 0x1c, 0x0e, 0x87, 0x85, 0x00}},
+		PortID:       0x8004,
+		MessageAge:   256,
+		MaxAge:       20 * 256,
+		HelloTime:    2 * 256,
+		ForwardDelay: 15 * 256,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("STP mismatch:\ngot  %#v\nwant %#v", got, want)
+	}
+	testSerialization(t, p, data)
+}
+
+func TestPacketSTPTCN(t *testing.T) {
+	var s STP
+	if err := s.DecodeFromBytes([]byte{0x00, 0x00, 0x00, 0x80}, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if s.Type != STPBPDUTypeTCN || len(s.Contents) != 4 {
+		t.Errorf("bad TCN BPDU %+v", s)
+	}
+}
+
+func TestPacketRSTP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketRSTP, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLLC, LayerTypeSTP}, t)
+	s := p.Layer(LayerTypeSTP).(*STP)
+	if s.Version != STPProtocolVersionRSTP || s.Type != STPBPDUTypeRST || s.MST() || len(s.Contents) != 36 {
+		t.Errorf("bad RSTP BPDU %+v", s)
+	}
+	want := STPFlags{Proposal: true, PortRole: STPPortRoleDesignated, Learning: true, Forwarding: true}
+	if s.Flags != want {
+		t.Errorf("flags are %+v, want %+v", s.Flags, want)
+	}
+	if s.RootID.Priority != 0x8000 || s.RootID.SystemID != 1 || s.RootPathCost != 20000 {
+		t.Errorf("bad root %+v cost %d", s.RootID, s.RootPathCost)
+	}
+	testSerialization(t, p, testPacketRSTP)
+}
+
+func TestPacketMSTP(t *testing.T) {
+	p := gopacket.NewPacket(testPacketMSTP, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeLLC, LayerTypeSTP}, t)
+	s := p.Layer(LayerTypeSTP).(*STP)
+	if !s.MST() || s.Version3Length != 80 || s.Flags.PortRole != STPPortRoleRoot || !s.Flags.Agreement {
+		t.Errorf("bad MSTP BPDU %+v", s)
+	}
+	wantID := STPMSTConfigID{Name: "region1", Revision: 3}
+	copy(wantID.Digest[:], testPacketMSTP[90:106])
+	if s.MSTConfigID != wantID {
+		t.Errorf("MST configuration identifier is %+v, want %+v", s.MSTConfigID, wantID)
+	}
+	if s.CISTInternalRootPathCost != 20000 || s.CISTBridgeID.Priority != 0x7000 || s.CISTRemainingHops != 20 {
+		t.Errorf("bad CIST fields %+v", s)
+	}
+	want := []STPMSTIConfig{{
+		Flags:                STPFlags{PortRole: STPPortRoleDesignated, Learning: true, Forwarding: true, Agreement: true},
+		RegionalRootID:       STPBridgeID{Priority: 0x8000, SystemID: 1, Address: net.HardwareAddr{0x00, 0x1c, 0x0e, 0x87, 0x78, 0x00}},
+		InternalRootPathCost: 20000,
+		BridgePriority:       8,
+		PortPriority:         8,
+		RemainingHops:        19,
+	}}
+	if !reflect.DeepEqual(s.MSTIs, want) {
+		t.Errorf("MSTIs are %+v, want %+v", s.MSTIs, want)
+	}
+	testSerialization(t, p, testPacketMSTP)
+}
+
+func TestSTPSerializePriority(t *testing.T) {
+	s := &STP{
+		Version:  STPProtocolVersionRSTP,
+		Type:     STPBPDUTypeRST,
+		Flags:    STPFlags{PortRole: STPPortRoleDesignated, TopologyChange: true},
+		RootID:   STPBridgeID{Priority: 4096, SystemID: 10, Address: net.HardwareAddr{1, 2, 3, 4, 5, 6}},
+		BridgeID: STPBridgeID{Priority: 61440, Address: net.HardwareAddr{1, 2, 3, 4, 5, 7}},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, s); err != nil {
+		t.Fatal(err)
+	}
+	var got STP
+	if err := got.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	got.BaseLayer = BaseLayer{}
+	if !reflect.DeepEqual(&got, s) {
+		t.Errorf("got %+v, want %+v", got, s)
+	}
+	if b := buf.Bytes(); len(b) != 36 || b[4] != 0x0d || b[5] != 0x10 || b[6] != 0x0a || b[17] != 0xf0 {
+		t.Errorf("bad serialization %x", b)
+	}
+}
+
+func TestSTPMalformed(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"short", []byte{0x00, 0x00, 0x00}},
+		{"config truncated", testPacketRSTP[17:50]},
+		{"RST truncated", testPacketRSTP[17:52]},
+		{"MST truncated", testPacketMSTP[17:110]},
+		{"MSTI truncated", testPacketMSTP[17:130]},
+		{"unknown type", []byte{0x00, 0x00, 0x00, 0x01}},
+	} {
+		var s STP
+		if err := s.DecodeFromBytes(c.data, gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// SyslogFormat is the format of a syslog message.
+type SyslogFormat uint8
+
+const (
+	// SyslogFormatRFC3164 is the legacy BSD format, which is also what
+	// messages that don't follow any format are decoded as.
+	SyslogFormatRFC3164 SyslogFormat = iota
+	SyslogFormatRFC5424
+)
+
+func (f SyslogFormat) String() string {
+	switch f {
+	case SyslogFormatRFC3164:
+		return "RFC3164"
+	case SyslogFormatRFC5424:
+		return "RFC5424"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(f))
+	}
+}
+
+// SyslogFacility is the facility of a syslog message.
+type SyslogFacility uint8
+
+var syslogFacilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+func (f SyslogFacility) String() string {
+	if int(f) < len(syslogFacilityNames) {
+		return syslogFacilityNames[f]
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(f))
+}
+
+// SyslogSeverity is the severity of a syslog message.
+type SyslogSeverity uint8
+
+const (
+	SyslogSeverityEmergency SyslogSeverity = iota
+	SyslogSeverityAlert
+	SyslogSeverityCritical
+	SyslogSeverityError
+	SyslogSeverityWarning
+	SyslogSeverityNotice
+	SyslogSeverityInformational
+	SyslogSeverityDebug
+)
+
+var syslogSeverityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+func (s SyslogSeverity) String() string {
+	if int(s) < len(syslogSeverityNames) {
+		return syslogSeverityNames[s]
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(s))
+}
+
+// Syslog is a syslog message, in the format of RFC 5424 or of RFC 3164.
+// Fields are left empty when the message doesn't carry them, or carries the
+// NILVALUE of RFC 5424.
+type Syslog struct {
+	BaseLayer
+	Format SyslogFormat
+
+	// HasPriority is false for messages lacking the PRI part, which leaves
+	// Facility and Severity unset.
+	HasPriority bool
+	Facility    SyslogFacility
+	Severity    SyslogSeverity
+
+	// Version is only set for RFC 5424 messages.
+	Version int
+	// Timestamp of RFC 3164 messages has no year, and is in the sender's
+	// local time, which is decoded as UTC.
+	Timestamp time.Time
+	Hostname  string
+	// AppName, MsgID and StructuredData are only set for RFC 5424
+	// messages, Tag only for RFC 3164 ones.  The PID following the tag of
+	// the latter goes to ProcID.
+	AppName string
+	ProcID  string
+	MsgID   string
+	Tag     string
+	// StructuredData maps the SD-IDs of the structured data elements to
+	// their parameters, unescaped.  A parameter repeated in an element
+	// keeps its last value.
+	StructuredData map[string]map[string]string
+	// Message is the free form message, the CONTENT of RFC 3164 messages
+	// and the MSG of RFC 5424 ones, without its BOM.
+	Message []byte
+}
+
+// LayerType returns LayerTypeSyslog.
+func (s *Syslog) LayerType() gopacket.LayerType { return LayerTypeSyslog }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (s *Syslog) CanDecode() gopacket.LayerClass { return LayerTypeSyslog }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (s *Syslog) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
+// Payload returns nil, since syslog messages carry no payload.
+func (s *Syslog) Payload() []byte { return nil }
+
+// DecodeFromBytes decodes the given bytes into this layer.  Anything
+// lacking a valid PRI part is decoded as the message of a RFC 3164 message,
+// as RFC 3164 asks relays to do.
+func (s *Syslog) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	*s = Syslog{BaseLayer: BaseLayer{Contents: data}}
+	rest := s.decodePriority(data)
+	if !s.HasPriority {
+		s.Message = rest
+		return nil
+	}
+	if v, after, ok := syslogVersion(rest); ok {
+		s.Format = SyslogFormatRFC5424
+		s.Version = v
+		return s.decodeRFC5424(after)
+	}
+	s.decodeRFC3164(rest)
+	return nil
+}
+
+// decodePriority decodes the PRI part at the start of data, if it's a
+// valid one, and returns what follows it.
+func (s *Syslog) decodePriority(data []byte) []byte {
+	if len(data) < 3 || data[0] != '<' {
+		return data
+	}
+	// The PRI value has 1 to 3 digits.
+	n := len(data)
+	if n > 5 {
+		n = 5
+	}
+	end := bytes.IndexByte(data[:n], '>')
+	if end < 2 || (end > 2 && data[1] == '0') {
+		return data
+	}
+	pri, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || pri > 191 {
+		return data
+	}
+	s.HasPriority = true
+	s.Facility = SyslogFacility(pri >> 3)
+	s.Severity = SyslogSeverity(pri & 7)
+	return data[end+1:]
+}
+
+// syslogVersion decodes the version starting RFC 5424 headers, a non-zero
+// number of up to 3 digits followed by a space.
+func syslogVersion(data []byte) (int, []byte, bool) {
+	i := 0
+	for i < len(data) && i < 3 && data[i] >= '0' && data[i] <= '9' {
+		i++
+	}
+	if i == 0 || data[0] == '0' || i >= len(data) || data[i] != ' ' {
+		return 0, nil, false
+	}
+	v, _ := strconv.Atoi(string(data[:i]))
+	return v, data[i+1:], true
+}
+
+// syslogField splits off the space terminated header field at the start
+// of data, returning "" for the NILVALUE.
+func syslogField(data []byte, name string) (string, []byte, error) {
+	end := bytes.IndexByte(data, ' ')
+	if end < 0 {
+		end = len(data)
+	}
+	if end == 0 {
+		return "", nil, fmt.Errorf("syslog %s empty", name)
+	}
+	field := string(data[:end])
+	if end < len(data) {
+		end++
+	}
+	if field == "-" {
+		field = ""
+	}
+	return field, data[end:], nil
+}
+
+func (s *Syslog) decodeRFC5424(data []byte) error {
+	ts, data, err := syslogField(data, "timestamp")
+	if err != nil {
+		return err
+	}
+	if ts != "" {
+		if s.Timestamp, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			return fmt.Errorf("syslog timestamp %q invalid", ts)
+		}
+	}
+	for _, f := range []struct {
+		field *string
+		name  string
+	}{
+		{&s.Hostname, "hostname"},
+		{&s.AppName, "app-name"},
+		{&s.ProcID, "procid"},
+		{&s.MsgID, "msgid"},
+	} {
+		if *f.field, data, err = syslogField(data, f.name); err != nil {
+			return err
+		}
+	}
+	if data, err = s.decodeStructuredData(data); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		if data[0] != ' ' {
+			return errors.New("syslog structured data not followed by a space")
+		}
+		s.Message = bytes.TrimPrefix(data[1:], []byte("\xef\xbb\xbf"))
+	}
+	return nil
+}
+
+// decodeStructuredData decodes the STRUCTURED-DATA at the start of data,
+// returning what follows it.
+func (s *Syslog) decodeStructuredData(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("syslog structured data missing")
+	}
+	if data[0] == '-' {
+		return data[1:], nil
+	}
+	s.StructuredData = map[string]map[string]string{}
+	for len(data) > 0 && data[0] == '[' {
+		end := bytes.IndexAny(data, " ]")
+		if end <= 1 {
+			return nil, errors.New("syslog structured data element ID invalid")
+		}
+		params := map[string]string{}
+		s.StructuredData[string(data[1:end])] = params
+		data = data[end:]
+		for len(data) > 0 && data[0] == ' ' {
+			eq := bytes.IndexByte(data, '=')
+			if eq <= 1 || eq+1 >= len(data) || data[eq+1] != '"' {
+				return nil, errors.New("syslog structured data parameter invalid")
+			}
+			name := string(data[1:eq])
+			value, rest, err := syslogParamValue(data[eq+2:])
+			if err != nil {
+				return nil, err
+			}
+			params[name] = value
+			data = rest
+		}
+		if len(data) == 0 || data[0] != ']' {
+			return nil, errors.New("syslog structured data element not terminated")
+		}
+		data = data[1:]
+	}
+	return data, nil
+}
+
+// syslogParamValue unescapes the parameter value at the start of data, up
+// to its closing quote, returning what follows the quote.  Only '"', '\'
+// and ']' are escaped; a backslash before anything else is kept.
+func syslogParamValue(data []byte) (string, []byte, error) {
+	var value []byte
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '"':
+			return string(value), data[i+1:], nil
+		case '\\':
+			if i+1 < len(data) {
+				switch next := data[i+1]; next {
+				case '"', '\\', ']':
+					value = append(value, next)
+					i++
+					continue
+				}
+			}
+			value = append(value, c)
+		default:
+			value = append(value, c)
+		}
+	}
+	return "", nil, errors.New("syslog structured data parameter value not terminated")
+}
+
+// syslogRFC3164Timestamp is the layout of RFC 3164 timestamps, whose days of
+// the month are padded with a space.
+const syslogRFC3164Timestamp = "Jan _2 15:04:05"
+
+// decodeRFC3164 decodes what follows the PRI part of RFC 3164 messages.
+// Without a timestamp, all of it is the message.  Otherwise the hostname
+// follows, then the message, starting with the tag if there's one.
+func (s *Syslog) decodeRFC3164(data []byte) {
+	s.Format = SyslogFormatRFC3164
+	s.Message = data
+	n := len(syslogRFC3164Timestamp)
+	if len(data) <= n || data[n] != ' ' {
+		return
+	}
+	ts, err := time.Parse(syslogRFC3164Timestamp, string(data[:n]))
+	if err != nil {
+		return
+	}
+	s.Timestamp = ts
+	data = data[n+1:]
+	if end := bytes.IndexByte(data, ' '); end > 0 {
+		s.Hostname = string(data[:end])
+		data = data[end+1:]
+	} else {
+		s.Hostname = string(data)
+		data = nil
+	}
+	s.Message = data
+
+	// The tag is up to 32 alphanumeric characters, though daemons use some
+	// punctuation too, optionally followed by the PID in brackets, and then
+	// by a colon.
+	end := 0
+	for end < len(data) && end <= 32 && isSyslogTagChar(data[end]) {
+		end++
+	}
+	if end == 0 || end > 32 || end == len(data) {
+		return
+	}
+	tag, rest := string(data[:end]), data[end:]
+	var procID string
+	if rest[0] == '[' {
+		rb := bytes.IndexByte(rest, ']')
+		if rb < 0 {
+			return
+		}
+		procID, rest = string(rest[1:rb]), rest[rb+1:]
+	}
+	if len(rest) == 0 || rest[0] != ':' {
+		return
+	}
+	s.Tag, s.ProcID = tag, procID
+	s.Message = bytes.TrimPrefix(rest[1:], []byte(" "))
+}
+
+func isSyslogTagChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '_' || c == '.' || c == '/'
+}
+
+func decodeSyslog(data []byte, p gopacket.PacketBuilder) error {
+	s := &Syslog{}
+	if err := s.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(s)
+	p.SetApplicationLayer(s)
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketSyslogRFC5424 is the RFC 5424 example with structured data,
+// with a parameter value escaping quotes, a bracket and a backslash added:
+//
+//	<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47
+//	[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"]
+//	[examplePriority@32473 class="high \"x\" \] \\ y"] BOMAn application
+//	event log entry...
+var testPacketSyslogRFC5424 = []byte{
+	0x02, 0x42, 0xc0, 0x00, 0x02, 0xc8, 0x02, 0x42, 0xc0, 0x00, 0x02, 0x0f, 0x08, 0x00, 0x45, 0x00,
+	0x00, 0xfd, 0x7a, 0x11, 0x40, 0x00, 0x40, 0x11, 0x3b, 0x07, 0xc0, 0x00, 0x02, 0x0f, 0xc0, 0x00,
+	0x02, 0xc8, 0xc0, 0xa8, 0x02, 0x02, 0x00, 0xe9, 0x98, 0xc4, 0x3c, 0x31, 0x36, 0x35, 0x3e, 0x31,
+	0x20, 0x32, 0x30, 0x30, 0x33, 0x2d, 0x31, 0x30, 0x2d, 0x31, 0x31, 0x54, 0x32, 0x32, 0x3a, 0x31,
+	0x34, 0x3a, 0x31, 0x35, 0x2e, 0x30, 0x30, 0x33, 0x5a, 0x20, 0x6d, 0x79, 0x6d, 0x61, 0x63, 0x68,
+	0x69, 0x6e, 0x65, 0x2e, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x2e, 0x63, 0x6f, 0x6d, 0x20,
+	0x65, 0x76, 0x6e, 0x74, 0x73, 0x6c, 0x6f, 0x67, 0x20, 0x2d, 0x20, 0x49, 0x44, 0x34, 0x37, 0x20,
+	0x5b, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x53, 0x44, 0x49, 0x44, 0x40, 0x33, 0x32, 0x34,
+	0x37, 0x33, 0x20, 0x69, 0x75, 0x74, 0x3d, 0x22, 0x33, 0x22, 0x20, 0x65, 0x76, 0x65, 0x6e, 0x74,
+	0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x3d, 0x22, 0x41, 0x70, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x22, 0x20, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x49, 0x44, 0x3d, 0x22, 0x31, 0x30,
+	0x31, 0x31, 0x22, 0x5d, 0x5b, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x50, 0x72, 0x69, 0x6f,
+	0x72, 0x69, 0x74, 0x79, 0x40, 0x33, 0x32, 0x34, 0x37, 0x33, 0x20, 0x63, 0x6c, 0x61, 0x73, 0x73,
+	0x3d, 0x22, 0x68, 0x69, 0x67, 0x68, 0x20, 0x5c, 0x22, 0x78, 0x5c, 0x22, 0x20, 0x5c, 0x5d, 0x20,
+	0x5c, 0x5c, 0x20, 0x79, 0x22, 0x5d, 0x20, 0xef, 0xbb, 0xbf, 0x41, 0x6e, 0x20, 0x61, 0x70, 0x70,
+	0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x20, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x20, 0x6c,
+	0x6f, 0x67, 0x20, 0x65, 0x6e, 0x74, 0x72, 0x79, 0x2e, 0x2e, 0x2e,
+}
+
+func TestSyslogRFC5424(t *testing.T) {
+	p := gopacket.NewPacket(testPacketSyslogRFC5424, LinkTypeEthernet, testDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeSyslog}, t)
+	s := p.Layer(LayerTypeSyslog).(*Syslog)
+	want := &Syslog{
+		BaseLayer:   BaseLayer{Contents: testPacketSyslogRFC5424[42:]},
+		Format:      SyslogFormatRFC5424,
+		HasPriority: true,
+		Facility:    20,
+		Severity:    SyslogSeverityNotice,
+		Version:     1,
+		Timestamp:   time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+		Hostname:    "mymachine.example.com",
+		AppName:     "evntslog",
+		MsgID:       "ID47",
+		StructuredData: map[string]map[string]string{
+			"exampleSDID@32473":     {"iut": "3", "eventSource": "Application", "eventID": "1011"},
+			"examplePriority@32473": {"class": `high "x" ] \ y`},
+		},
+		Message: []byte("An application event log entry..."),
+	}
+	if !s.Timestamp.Equal(want.Timestamp) {
+		t.Errorf("timestamp, want %v got %v", want.Timestamp, s.Timestamp)
+	}
+	s.Timestamp = want.Timestamp
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("Syslog mismatch, \nwant %#v\ngot  %#v", want, s)
+	}
+	if s.Facility.String() != "local4" {
+		t.Errorf("facility, want local4 got %v", s.Facility)
+	}
+}
+
+func TestSyslogMessages(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		want Syslog
+	}{
+		{
+			"<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8",
+			Syslog{
+				Format: SyslogFormatRFC3164, HasPriority: true, Facility: 4, Severity: SyslogSeverityCritical,
+				Timestamp: time.Date(0, 10, 11, 22, 14, 15, 0, time.UTC), Hostname: "mymachine",
+				Tag: "su", ProcID: "230", Message: []byte("'su root' failed for lonvick on /dev/pts/8"),
+			},
+		},
+		{
+			"<13>Feb  5 17:32:18 10.0.0.99 Use the BFG!",
+			Syslog{
+				Format: SyslogFormatRFC3164, HasPriority: true, Facility: 1, Severity: SyslogSeverityNotice,
+				Timestamp: time.Date(0, 2, 5, 17, 32, 18, 0, time.UTC), Hostname: "10.0.0.99",
+				Message: []byte("Use the BFG!"),
+			},
+		},
+		{
+			"<166>Jan 15 08:00:00 fw01 postfix/smtpd: connect from unknown",
+			Syslog{
+				Format: SyslogFormatRFC3164, HasPriority: true, Facility: 20, Severity: SyslogSeverityInformational,
+				Timestamp: time.Date(0, 1, 15, 8, 0, 0, 0, time.UTC), Hostname: "fw01",
+				Tag: "postfix/smtpd", Message: []byte("connect from unknown"),
+			},
+		},
+		{
+			"<190>%ASA-6-302013: Built outbound TCP connection",
+			Syslog{
+				Format: SyslogFormatRFC3164, HasPriority: true, Facility: 23, Severity: SyslogSeverityInformational,
+				Message: []byte("%ASA-6-302013: Built outbound TCP connection"),
+			},
+		},
+		{
+			"link up on port 3",
+			Syslog{Message: []byte("link up on port 3")},
+		},
+		{
+			"<999>not a priority",
+			Syslog{Message: []byte("<999>not a priority")},
+		},
+		{
+			"<14>1 - - - - - -",
+			Syslog{Format: SyslogFormatRFC5424, HasPriority: true, Facility: 1, Severity: SyslogSeverityInformational, Version: 1},
+		},
+		{
+			"<14>1 2018-03-01T10:00:00+01:00 host app 42 - [a b=\"c\\d\"] hello",
+			Syslog{
+				Format: SyslogFormatRFC5424, HasPriority: true, Facility: 1, Severity: SyslogSeverityInformational, Version: 1,
+				Timestamp: time.Date(2018, 3, 1, 9, 0, 0, 0, time.UTC), Hostname: "host", AppName: "app", ProcID: "42",
+				StructuredData: map[string]map[string]string{"a": {"b": `c\d`}},
+				Message:        []byte("hello"),
+			},
+		},
+	} {
+		var s Syslog
+		if err := s.DecodeFromBytes([]byte(test.data), gopacket.NilDecodeFeedback); err != nil {
+			t.Errorf("%q: %v", test.data, err)
+			continue
+		}
+		if !s.Timestamp.Equal(test.want.Timestamp) {
+			t.Errorf("%q: timestamp, want %v got %v", test.data, test.want.Timestamp, s.Timestamp)
+		}
+		s.Timestamp = test.want.Timestamp
+		test.want.Contents = []byte(test.data)
+		if !reflect.DeepEqual(s, test.want) {
+			t.Errorf("%q: \nwant %#v\ngot  %#v", test.data, test.want, s)
+		}
+	}
+}
+
+func TestSyslogMalformed(t *testing.T) {
+	for _, data := range []string{
+		"<14>1 yesterday host app - - - hello",
+		"<14>1 - host app - -",
+		"<14>1 - host app - - [a b=\"c\"",
+		"<14>1 - host app - - [a b=\"c]",
+		"<14>1 - host app - - [a b=c] hello",
+		"<14>1 - host app - - [ b=\"c\"] hello",
+		"<14>1 - host app - - [a b=\"c\"]hello",
+		"<14>1 - host app - - x",
+	} {
+		var s Syslog
+		if err := s.DecodeFromBytes([]byte(data), gopacket.NilDecodeFeedback); err == nil {
+			t.Errorf("%q: no error", data)
+		}
+	}
+}
@@ -54,6 +54,7 @@ const (
 	TCPOptionKindCCEcho                          = 13 // obsolete
 	TCPOptionKindAltChecksum                     = 14 // len = 3, obsolete
 	TCPOptionKindAltChecksumData                 = 15 // len = n, obsolete
+	TCPOptionKindMPTCP                           = 30 // len = n
 )
 
 func (k TCPOptionKind) String() string {
@@ -90,6 +91,8 @@ func (k TCPOptionKind) String() string {
 		return "AltChecksum"
 	case TCPOptionKindAltChecksumData:
 		return "AltChecksumData"
+	case TCPOptionKindMPTCP:
+		return "MPTCP"
 	default:
 		return fmt.Sprintf("Unknown(%d)", k)
 	}
@@ -125,6 +128,133 @@ func (t TCPOption) String() string {
 	return fmt.Sprintf("TCPOption(%s:%s)", t.OptionType, hd)
 }
 
+// TCPSACKBlock is a block of data acknowledged by a selective
+// acknowledgement (SACK) option, from Left up to but not including Right.
+type TCPSACKBlock struct {
+	Left, Right uint32
+}
+
+// TCPOptionsDecoded holds the commonly used TCP options in structured form,
+// as returned by TCP.DecodeOptions.  Each option has a flag saying whether
+// it was present, so that zero values aren't ambiguous.
+type TCPOptionsDecoded struct {
+	HasMSS         bool
+	MSS            uint16
+	HasWindowScale bool
+	WindowScale    uint8
+	SACKPermitted  bool
+	HasSACK        bool
+	SACKBlocks     []TCPSACKBlock
+	HasTimestamps  bool
+	TSVal, TSEcr   uint32
+	// MPTCP is set if any multipath TCP option is present.  MPTCP options
+	// aren't decoded, and aren't reproduced by Options.
+	MPTCP bool
+}
+
+// DecodeOptions decodes the MSS, window scale, SACK-permitted, SACK and
+// timestamps options from t.Options.  Options of other kinds are ignored.
+// An error naming the option kind is returned if one of the options has an
+// invalid length.
+func (t *TCP) DecodeOptions() (TCPOptionsDecoded, error) {
+	var d TCPOptionsDecoded
+	for _, o := range t.Options {
+		switch o.OptionType {
+		case TCPOptionKindEndList, TCPOptionKindNop:
+			continue
+		}
+		if o.OptionLength < 2 {
+			return d, fmt.Errorf("Invalid TCP %s option length %d < 2", o.OptionType, o.OptionLength)
+		}
+		if int(o.OptionLength) != len(o.OptionData)+2 {
+			return d, fmt.Errorf("Invalid TCP %s option length %d with %d bytes of data", o.OptionType, o.OptionLength, len(o.OptionData))
+		}
+		want := -1
+		switch o.OptionType {
+		case TCPOptionKindMSS:
+			want = 2
+		case TCPOptionKindWindowScale:
+			want = 1
+		case TCPOptionKindSACKPermitted:
+			want = 0
+		case TCPOptionKindTimestamps:
+			want = 8
+		case TCPOptionKindSACK:
+			if len(o.OptionData) == 0 || len(o.OptionData)%8 != 0 {
+				return d, fmt.Errorf("Invalid TCP %s option length %d", o.OptionType, o.OptionLength)
+			}
+		}
+		if want >= 0 && len(o.OptionData) != want {
+			return d, fmt.Errorf("Invalid TCP %s option length %d != %d", o.OptionType, o.OptionLength, want+2)
+		}
+		switch o.OptionType {
+		case TCPOptionKindMSS:
+			d.HasMSS = true
+			d.MSS = binary.BigEndian.Uint16(o.OptionData)
+		case TCPOptionKindWindowScale:
+			d.HasWindowScale = true
+			d.WindowScale = o.OptionData[0]
+		case TCPOptionKindSACKPermitted:
+			d.SACKPermitted = true
+		case TCPOptionKindSACK:
+			d.HasSACK = true
+			for b := o.OptionData; len(b) > 0; b = b[8:] {
+				d.SACKBlocks = append(d.SACKBlocks, TCPSACKBlock{
+					Left:  binary.BigEndian.Uint32(b[0:4]),
+					Right: binary.BigEndian.Uint32(b[4:8]),
+				})
+			}
+		case TCPOptionKindTimestamps:
+			d.HasTimestamps = true
+			d.TSVal = binary.BigEndian.Uint32(o.OptionData[0:4])
+			d.TSEcr = binary.BigEndian.Uint32(o.OptionData[4:8])
+		case TCPOptionKindMPTCP:
+			d.MPTCP = true
+		}
+	}
+	return d, nil
+}
+
+// Options returns the options in d as TCPOptions, suitable for use as
+// TCP.Options.  They are laid out the way Linux does, with NOPs keeping
+// each option aligned so that the result is a multiple of 4 bytes long.
+func (d TCPOptionsDecoded) Options() []TCPOption {
+	var opts []TCPOption
+	nop := TCPOption{OptionType: TCPOptionKindNop, OptionLength: 1}
+	if d.HasMSS {
+		data := make([]byte, 2)
+		binary.BigEndian.PutUint16(data, d.MSS)
+		opts = append(opts, TCPOption{OptionType: TCPOptionKindMSS, OptionLength: 4, OptionData: data})
+	}
+	if d.SACKPermitted {
+		if !d.HasTimestamps {
+			opts = append(opts, nop, nop)
+		}
+		opts = append(opts, TCPOption{OptionType: TCPOptionKindSACKPermitted, OptionLength: 2})
+	}
+	if d.HasTimestamps {
+		if !d.SACKPermitted {
+			opts = append(opts, nop, nop)
+		}
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint32(data[0:4], d.TSVal)
+		binary.BigEndian.PutUint32(data[4:8], d.TSEcr)
+		opts = append(opts, TCPOption{OptionType: TCPOptionKindTimestamps, OptionLength: 10, OptionData: data})
+	}
+	if d.HasWindowScale {
+		opts = append(opts, nop, TCPOption{OptionType: TCPOptionKindWindowScale, OptionLength: 3, OptionData: []byte{d.WindowScale}})
+	}
+	if d.HasSACK && len(d.SACKBlocks) > 0 {
+		data := make([]byte, 8*len(d.SACKBlocks))
+		for i, b := range d.SACKBlocks {
+			binary.BigEndian.PutUint32(data[i*8:], b.Left)
+			binary.BigEndian.PutUint32(data[i*8+4:], b.Right)
+		}
+		opts = append(opts, nop, nop, TCPOption{OptionType: TCPOptionKindSACK, OptionLength: uint8(len(data) + 2), OptionData: data})
+	}
+	return opts
+}
+
 // LayerType returns gopacket.LayerTypeTCP
 func (t *TCP) LayerType() gopacket.LayerType { return LayerTypeTCP }
 
@@ -285,10 +415,10 @@ func (tcp *TCP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 			}
 			opt.OptionLength = data[1]
 			if opt.OptionLength < 2 {
-				return fmt.Errorf("Invalid TCP option length %d < 2", opt.OptionLength)
+				return fmt.Errorf("Invalid TCP %s option length %d < 2", opt.OptionType, opt.OptionLength)
 			} else if int(opt.OptionLength) > len(data) {
 				df.SetTruncated()
-				return fmt.Errorf("Invalid TCP option length %d exceeds remaining %d bytes", opt.OptionLength, len(data))
+				return fmt.Errorf("Invalid TCP %s option length %d exceeds remaining %d bytes", opt.OptionType, opt.OptionLength, len(data))
 			}
 			opt.OptionData = data[2:opt.OptionLength]
 		}
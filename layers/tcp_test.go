@@ -7,6 +7,8 @@
 package layers
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/gopacket"
@@ -58,3 +60,91 @@ func TestTCPSerializePadding(t *testing.T) {
 		t.Errorf("TCP data of len %d not padding to 32 bit boundary", len(buf.Bytes()))
 	}
 }
+
+// testTCPSYNHeader is a TCP SYN header with the options Linux sends: MSS
+// 1460, SACK permitted, timestamps 1/0, NOP and window scale 7.
+var testTCPSYNHeader = []byte{
+	0xd4, 0x31, 0x00, 0x50, 0x6b, 0x1d, 0x6e, 0x1a, 0x00, 0x00, 0x00, 0x00,
+	0xa0, 0x02, 0xfa, 0xf0, 0x12, 0x34, 0x00, 0x00,
+	0x02, 0x04, 0x05, 0xb4, 0x04, 0x02, 0x08, 0x0a, 0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x00, 0x01, 0x03, 0x03, 0x07,
+}
+
+func TestTCPDecodeOptions(t *testing.T) {
+	tcp := &TCP{}
+	if err := tcp.DecodeFromBytes(testTCPSYNHeader, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tcp.DecodeOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TCPOptionsDecoded{
+		HasMSS:         true,
+		MSS:            1460,
+		HasWindowScale: true,
+		WindowScale:    7,
+		SACKPermitted:  true,
+		HasTimestamps:  true,
+		TSVal:          1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded options mismatch\nwant %+v\ngot  %+v", want, got)
+	}
+
+	tcp.Options = got.Options()
+	buf := gopacket.NewSerializeBuffer()
+	if err := tcp.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(buf.Bytes(), testTCPSYNHeader) {
+		t.Errorf("options round trip mismatch\nwant %x\ngot  %x", testTCPSYNHeader, buf.Bytes())
+	}
+}
+
+func TestTCPDecodeOptionsSACK(t *testing.T) {
+	want := TCPOptionsDecoded{
+		HasTimestamps: true,
+		TSVal:         100,
+		TSEcr:         200,
+		HasSACK:       true,
+		SACKBlocks:    []TCPSACKBlock{{1000, 2000}, {3000, 4000}},
+		MPTCP:         true,
+	}
+	tcp := &TCP{Options: append(want.Options(), TCPOption{OptionType: TCPOptionKindMPTCP, OptionLength: 4, OptionData: []byte{0x20, 0x81}})}
+	got, err := tcp.DecodeOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded options mismatch\nwant %+v\ngot  %+v", want, got)
+	}
+}
+
+func TestTCPDecodeOptionsMalformed(t *testing.T) {
+	for _, tc := range []struct {
+		opt  TCPOption
+		kind string
+	}{
+		{TCPOption{OptionType: TCPOptionKindMSS, OptionLength: 3, OptionData: []byte{5}}, "MSS"},
+		{TCPOption{OptionType: TCPOptionKindWindowScale, OptionLength: 0}, "WindowScale"},
+		{TCPOption{OptionType: TCPOptionKindTimestamps, OptionLength: 10, OptionData: []byte{1, 2, 3}}, "Timestamps"},
+		{TCPOption{OptionType: TCPOptionKindSACK, OptionLength: 6, OptionData: []byte{1, 2, 3, 4}}, "SACK"},
+	} {
+		tcp := &TCP{Options: []TCPOption{tc.opt}}
+		if _, err := tcp.DecodeOptions(); err == nil {
+			t.Errorf("%v: expected error", tc.opt)
+		} else if !strings.Contains(err.Error(), tc.kind) {
+			t.Errorf("%v: error %q doesn't name the option kind", tc.opt, err)
+		}
+	}
+
+	// A SACK-permitted option running past the end of the header.
+	data := append([]byte(nil), testTCPSYNHeader...)
+	data[25] = 0x20
+	if err := (&TCP{}).DecodeFromBytes(data, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding option running past the header")
+	} else if !strings.Contains(err.Error(), "SACKPermitted") {
+		t.Errorf("error %q doesn't name the option kind", err)
+	}
+}
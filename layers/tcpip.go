@@ -8,7 +8,6 @@
 package layers
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/google/gopacket"
@@ -74,7 +73,7 @@ func tcpipChecksum(data []byte, csum uint32) uint16 {
 // out. headerProtocol is the IP protocol number of the upper-layer header.
 func (c *tcpipchecksum) computeChecksum(headerAndPayload []byte, headerProtocol IPProtocol) (uint16, error) {
 	if c.pseudoheader == nil {
-		return 0, errors.New("TCP/IP layer 4 checksum cannot be computed without network layer... call SetNetworkLayerForChecksum to set which layer to use")
+		return 0, fmt.Errorf("%v checksum cannot be computed without a network layer; serialize it after an IPv4 or IPv6 layer, or call SetNetworkLayerForChecksum to set which layer to use", headerProtocol)
 	}
 	length := uint32(len(headerAndPayload))
 	csum, err := c.pseudoheader.pseudoheaderChecksum()
@@ -90,9 +89,11 @@ func (c *tcpipchecksum) computeChecksum(headerAndPayload []byte, headerProtocol
 // SetNetworkLayerForChecksum tells this layer which network layer is wrapping it.
 // This is needed for computing the checksum when serializing, since TCP/IP transport
 // layer checksums depends on fields in the IPv4 or IPv6 layer that contains it.
-// The passed in layer must be an *IPv4 or *IPv6.
+// The passed in layer must be an *IPv4 or *IPv6, or nil to unset it.
 func (i *tcpipchecksum) SetNetworkLayerForChecksum(l gopacket.NetworkLayer) error {
 	switch v := l.(type) {
+	case nil:
+		i.pseudoheader = nil
 	case *IPv4:
 		i.pseudoheader = v
 	case *IPv6:
@@ -102,3 +103,15 @@ func (i *tcpipchecksum) SetNetworkLayerForChecksum(l gopacket.NetworkLayer) erro
 	}
 	return nil
 }
+
+// NetworkLayerForChecksum returns the network layer set with
+// SetNetworkLayerForChecksum, or nil if there isn't one.
+func (i *tcpipchecksum) NetworkLayerForChecksum() gopacket.NetworkLayer {
+	switch v := i.pseudoheader.(type) {
+	case *IPv4:
+		return v
+	case *IPv6:
+		return v
+	}
+	return nil
+}
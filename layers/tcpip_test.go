@@ -7,6 +7,7 @@
 package layers
 
 import (
+	"encoding/binary"
 	"github.com/google/gopacket"
 	"net"
 	"testing"
@@ -183,3 +184,114 @@ func TestIPv6JumbogramUDPChecksum(t *testing.T) {
 		t.Errorf("Bad checksum:\ngot:\n%#v\n\nwant:\n%#v\n\n", got, want)
 	}
 }
+
+// checkPseudoheaderChecksum verifies that the checksum of the serialized
+// transport layer data, including the pseudo-header from ip, is correct.
+func checkPseudoheaderChecksum(t *testing.T, ip *IPv6, proto IPProtocol, data []byte) {
+	csum, err := ip.pseudoheaderChecksum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	csum += uint32(proto) + uint32(len(data))
+	if got := tcpipChecksum(data, csum); got != 0 {
+		t.Errorf("%v checksum over IPv6 doesn't verify: got %#04x, want 0", proto, got)
+	}
+}
+
+func TestIPv6AutomaticChecksums(t *testing.T) {
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	payload := gopacket.Payload("checksum me")
+
+	ip6 := createIPv6ChecksumTestLayer()
+	ip6.NextHeader = IPProtocolTCP
+	tcp := &TCP{SrcPort: 12345, DstPort: 80, SYN: true, Window: 1024}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, opts, ip6, tcp, payload); err != nil {
+		t.Fatal(err)
+	}
+	checkPseudoheaderChecksum(t, ip6, IPProtocolTCP, buf.Bytes()[40:])
+
+	ip6 = createIPv6ChecksumTestLayer()
+	ip6.NextHeader = IPProtocolIPv6Destination
+	dst := createIPv6DestinationChecksumTestLayer()
+	dst.NextHeader = IPProtocolUDP
+	if err := gopacket.SerializeLayers(buf, opts, ip6, dst, createUDPChecksumTestLayer()); err != nil {
+		t.Fatal(err)
+	}
+	if got := binary.BigEndian.Uint16(buf.Bytes()[54:]); got != ipv6UDPChecksumWithIPv6DstOpts {
+		t.Errorf("Bad UDP checksum: got %#04x, want %#04x", got, ipv6UDPChecksumWithIPv6DstOpts)
+	}
+
+	ip6 = createIPv6ChecksumTestLayer()
+	ip6.NextHeader = IPProtocolICMPv6
+	icmp := &ICMPv6{TypeCode: CreateICMPv6TypeCode(ICMPv6TypeEchoRequest, 0)}
+	if err := gopacket.SerializeLayers(buf, opts, ip6, icmp, payload); err != nil {
+		t.Fatal(err)
+	}
+	checkPseudoheaderChecksum(t, ip6, IPProtocolICMPv6, buf.Bytes()[40:])
+}
+
+func TestIPv6UDPZeroChecksum(t *testing.T) {
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	ip6 := createIPv6ChecksumTestLayer()
+	ip6.NextHeader = IPProtocolUDP
+	udp := createUDPChecksumTestLayer()
+	payload := gopacket.Payload{0, 0}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, opts, ip6, udp, payload); err != nil {
+		t.Fatal(err)
+	}
+	// Adding the checksum to the payload makes the one's complement sum all
+	// ones, so the computed checksum is zero.
+	binary.BigEndian.PutUint16(payload, udp.Checksum)
+	if err := gopacket.SerializeLayers(buf, opts, ip6, udp, payload); err != nil {
+		t.Fatal(err)
+	}
+	if udp.Checksum != 0xffff {
+		t.Errorf("Zero UDP checksum not sent as all ones: got %#04x", udp.Checksum)
+	}
+	checkPseudoheaderChecksum(t, ip6, IPProtocolUDP, buf.Bytes()[40:])
+}
+
+func TestChecksumWithoutNetworkLayer(t *testing.T) {
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	for _, l := range []gopacket.SerializableLayer{
+		&TCP{}, &UDP{}, &ICMPv6{},
+	} {
+		buf := gopacket.NewSerializeBuffer()
+		if err := gopacket.SerializeLayers(buf, opts, l); err == nil {
+			t.Errorf("%v: expected error computing checksum without a network layer", l.LayerType())
+		}
+	}
+}
+
+func TestAutomaticChecksumLayerReuse(t *testing.T) {
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	payload := gopacket.Payload("checksum me")
+	udp := &UDP{SrcPort: 12345, DstPort: 53}
+	buf := gopacket.NewSerializeBuffer()
+	for _, src := range []string{"2001:db8::1", "2001:db8::2"} {
+		ip6 := createIPv6ChecksumTestLayer()
+		ip6.SrcIP = net.ParseIP(src)
+		ip6.NextHeader = IPProtocolUDP
+		if err := gopacket.SerializeLayers(buf, opts, ip6, udp, payload); err != nil {
+			t.Fatal(err)
+		}
+		checkPseudoheaderChecksum(t, ip6, IPProtocolUDP, buf.Bytes()[40:])
+		if l := udp.NetworkLayerForChecksum(); l != nil {
+			t.Errorf("Network layer %v left set after serializing", l)
+		}
+	}
+
+	// A network layer set explicitly is kept.
+	ip6 := createIPv6ChecksumTestLayer()
+	ip6.NextHeader = IPProtocolUDP
+	udp.SetNetworkLayerForChecksum(ip6)
+	if err := gopacket.SerializeLayers(buf, opts, udp, payload); err != nil {
+		t.Fatal(err)
+	}
+	checkPseudoheaderChecksum(t, ip6, IPProtocolUDP, buf.Bytes())
+	if l := udp.NetworkLayerForChecksum(); l != ip6 {
+		t.Errorf("Got network layer %v, want %v", l, ip6)
+	}
+}
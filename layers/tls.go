@@ -89,6 +89,12 @@ type TLS struct {
 	Handshake        []TLSHandshakeRecord
 	AppData          []TLSAppDataRecord
 	Alert            []TLSAlertRecord
+	// Encrypted holds the handshake records sent after a ChangeCipherSpec,
+	// whose contents are encrypted.
+	Encrypted []TLSRecord
+
+	// changedCipherSpec is set once a ChangeCipherSpec record is seen.
+	changedCipherSpec bool
 }
 
 // TLSRecordHeader contains all the information that each TLS Record types should have
@@ -98,6 +104,12 @@ type TLSRecordHeader struct {
 	Length      uint16
 }
 
+// TLSRecord is a record whose contents aren't decoded.
+type TLSRecord struct {
+	TLSRecordHeader
+	Payload []byte
+}
+
 // LayerType returns gopacket.LayerTypeTLS.
 func (t *TLS) LayerType() gopacket.LayerType { return LayerTypeTLS }
 
@@ -114,82 +126,134 @@ func decodeTLS(data []byte, p gopacket.PacketBuilder) error {
 	return nil
 }
 
-// DecodeFromBytes decodes the slice into the TLS struct.
+// DecodeFromBytes decodes the slice into the TLS struct.  data must hold
+// only complete records.
 func (t *TLS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
-	t.BaseLayer.Contents = data
-	t.BaseLayer.Payload = nil
+	t.changedCipherSpec = false
+	if len(data) < 5 {
+		df.SetTruncated()
+		return errors.New("TLS record too short")
+	}
+	n, err := t.DecodeRecords(data, df)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		df.SetTruncated()
+		return errors.New("TLS packet length mismatch")
+	}
+	return nil
+}
 
+// DecodeRecords decodes as many complete records as data holds, and returns
+// the number of bytes they take up.  Unlike DecodeFromBytes, a record cut off
+// at the end of data isn't an error: the caller should keep the remaining
+// bytes and call again once more data arrives, as when reading a stream
+// reassembled by tcpassembly.
+//
+// DecodeRecords remembers whether a ChangeCipherSpec has been seen, so that
+// later handshake records are kept in Encrypted.  Use a separate TLS for each
+// direction of a stream.
+func (t *TLS) DecodeRecords(data []byte, df gopacket.DecodeFeedback) (int, error) {
 	t.ChangeCipherSpec = t.ChangeCipherSpec[:0]
 	t.Handshake = t.Handshake[:0]
 	t.AppData = t.AppData[:0]
 	t.Alert = t.Alert[:0]
+	t.Encrypted = t.Encrypted[:0]
 
-	return t.decodeTLSRecords(data, df)
-}
+	n := 0
+	for len(data)-n >= 5 {
+		var h TLSRecordHeader
+		h.ContentType = TLSType(data[n])
+		h.Version = TLSVersion(binary.BigEndian.Uint16(data[n+1 : n+3]))
+		h.Length = binary.BigEndian.Uint16(data[n+3 : n+5])
 
-func (t *TLS) decodeTLSRecords(data []byte, df gopacket.DecodeFeedback) error {
-	if len(data) < 5 {
-		df.SetTruncated()
-		return errors.New("TLS record too short")
+		if h.ContentType.String() == "Unknown" {
+			return n, errors.New("Unknown TLS record type")
+		}
+
+		hl := 5 // header length
+		tl := hl + int(h.Length)
+		if len(data)-n < tl {
+			break
+		}
+		if err := t.decodeTLSRecord(h, data[n+hl:n+tl], df); err != nil {
+			return n, err
+		}
+		n += tl
 	}
 
 	// since there are no further layers, the baselayer's content is
 	// pointing to this layer
-	t.BaseLayer = BaseLayer{Contents: data[:len(data)]}
-
-	var h TLSRecordHeader
-	h.ContentType = TLSType(data[0])
-	h.Version = TLSVersion(binary.BigEndian.Uint16(data[1:3]))
-	h.Length = binary.BigEndian.Uint16(data[3:5])
-
-	if h.ContentType.String() == "Unknown" {
-		return errors.New("Unknown TLS record type")
-	}
-
-	hl := 5 // header length
-	tl := hl + int(h.Length)
-	if len(data) < tl {
-		df.SetTruncated()
-		return errors.New("TLS packet length mismatch")
-	}
+	t.BaseLayer = BaseLayer{Contents: data[:n]}
+	return n, nil
+}
 
+func (t *TLS) decodeTLSRecord(h TLSRecordHeader, data []byte, df gopacket.DecodeFeedback) error {
 	switch h.ContentType {
 	default:
 		return errors.New("Unknown TLS record type")
 	case TLSChangeCipherSpec:
 		var r TLSChangeCipherSpecRecord
-		e := r.decodeFromBytes(h, data[hl:tl], df)
+		e := r.decodeFromBytes(h, data, df)
 		if e != nil {
 			return e
 		}
 		t.ChangeCipherSpec = append(t.ChangeCipherSpec, r)
+		t.changedCipherSpec = true
 	case TLSAlert:
 		var r TLSAlertRecord
-		e := r.decodeFromBytes(h, data[hl:tl], df)
+		e := r.decodeFromBytes(h, data, df)
 		if e != nil {
 			return e
 		}
 		t.Alert = append(t.Alert, r)
 	case TLSHandshake:
+		if t.changedCipherSpec {
+			t.Encrypted = append(t.Encrypted, TLSRecord{h, data})
+			break
+		}
 		var r TLSHandshakeRecord
-		e := r.decodeFromBytes(h, data[hl:tl], df)
+		e := r.decodeFromBytes(h, data, df)
 		if e != nil {
 			return e
 		}
 		t.Handshake = append(t.Handshake, r)
 	case TLSApplicationData:
 		var r TLSAppDataRecord
-		e := r.decodeFromBytes(h, data[hl:tl], df)
+		e := r.decodeFromBytes(h, data, df)
 		if e != nil {
 			return e
 		}
 		t.AppData = append(t.AppData, r)
 	}
+	return nil
+}
+
+// ClientHello returns the first ClientHello message in the decoded
+// handshake records, or nil if there is none.
+func (t *TLS) ClientHello() *TLSClientHello {
+	for _, r := range t.Handshake {
+		for _, m := range r.Messages {
+			if m.ClientHello != nil {
+				return m.ClientHello
+			}
+		}
+	}
+	return nil
+}
 
-	if len(data) == tl {
-		return nil
+// ServerHello returns the first ServerHello message in the decoded
+// handshake records, or nil if there is none.
+func (t *TLS) ServerHello() *TLSServerHello {
+	for _, r := range t.Handshake {
+		for _, m := range r.Messages {
+			if m.ServerHello != nil {
+				return m.ServerHello
+			}
+		}
 	}
-	return t.decodeTLSRecords(data[tl:len(data)], df)
+	return nil
 }
 
 // CanDecode implements gopacket.DecodingLayer.
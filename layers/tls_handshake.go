@@ -7,12 +7,181 @@
 package layers
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
+// TLSHandshakeType defines the type of a handshake message
+type TLSHandshakeType uint8
+
+const (
+	TLSHandshakeHelloRequest        TLSHandshakeType = 0
+	TLSHandshakeClientHello         TLSHandshakeType = 1
+	TLSHandshakeServerHello         TLSHandshakeType = 2
+	TLSHandshakeNewSessionTicket    TLSHandshakeType = 4
+	TLSHandshakeEncryptedExtensions TLSHandshakeType = 8
+	TLSHandshakeCertificate         TLSHandshakeType = 11
+	TLSHandshakeServerKeyExchange   TLSHandshakeType = 12
+	TLSHandshakeCertificateRequest  TLSHandshakeType = 13
+	TLSHandshakeServerHelloDone     TLSHandshakeType = 14
+	TLSHandshakeCertificateVerify   TLSHandshakeType = 15
+	TLSHandshakeClientKeyExchange   TLSHandshakeType = 16
+	TLSHandshakeFinished            TLSHandshakeType = 20
+)
+
+// String shows the handshake type nicely formatted
+func (ht TLSHandshakeType) String() string {
+	switch ht {
+	default:
+		return "Unknown"
+	case TLSHandshakeHelloRequest:
+		return "Hello Request"
+	case TLSHandshakeClientHello:
+		return "Client Hello"
+	case TLSHandshakeServerHello:
+		return "Server Hello"
+	case TLSHandshakeNewSessionTicket:
+		return "New Session Ticket"
+	case TLSHandshakeEncryptedExtensions:
+		return "Encrypted Extensions"
+	case TLSHandshakeCertificate:
+		return "Certificate"
+	case TLSHandshakeServerKeyExchange:
+		return "Server Key Exchange"
+	case TLSHandshakeCertificateRequest:
+		return "Certificate Request"
+	case TLSHandshakeServerHelloDone:
+		return "Server Hello Done"
+	case TLSHandshakeCertificateVerify:
+		return "Certificate Verify"
+	case TLSHandshakeClientKeyExchange:
+		return "Client Key Exchange"
+	case TLSHandshakeFinished:
+		return "Finished"
+	}
+}
+
+// TLSExtensionType defines the type of a hello message extension
+type TLSExtensionType uint16
+
+const (
+	TLSExtensionServerName          TLSExtensionType = 0
+	TLSExtensionSupportedGroups     TLSExtensionType = 10
+	TLSExtensionECPointFormats      TLSExtensionType = 11
+	TLSExtensionSignatureAlgorithms TLSExtensionType = 13
+	TLSExtensionALPN                TLSExtensionType = 16
+	TLSExtensionSessionTicket       TLSExtensionType = 35
+	TLSExtensionSupportedVersions   TLSExtensionType = 43
+	TLSExtensionKeyShare            TLSExtensionType = 51
+	TLSExtensionRenegotiationInfo   TLSExtensionType = 0xff01
+)
+
+// String shows the extension type nicely formatted
+func (et TLSExtensionType) String() string {
+	switch et {
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint16(et))
+	case TLSExtensionServerName:
+		return "server_name"
+	case TLSExtensionSupportedGroups:
+		return "supported_groups"
+	case TLSExtensionECPointFormats:
+		return "ec_point_formats"
+	case TLSExtensionSignatureAlgorithms:
+		return "signature_algorithms"
+	case TLSExtensionALPN:
+		return "application_layer_protocol_negotiation"
+	case TLSExtensionSessionTicket:
+		return "session_ticket"
+	case TLSExtensionSupportedVersions:
+		return "supported_versions"
+	case TLSExtensionKeyShare:
+		return "key_share"
+	case TLSExtensionRenegotiationInfo:
+		return "renegotiation_info"
+	}
+}
+
+//  TLS Handshake Message
+//  0  1  2  3  4  5  6  7  8
+//  +--+--+--+--+--+--+--+--+
+//  |     Message Type      |
+//  +--+--+--+--+--+--+--+--+
+//  |        Length         |
+//  +--+--+--+--+--+--+--+--+
+//  |        Length         |
+//  +--+--+--+--+--+--+--+--+
+//  |        Length         |
+//  +--+--+--+--+--+--+--+--+
+//  |   Body (variable)...  |
+//  +--+--+--+--+--+--+--+--+
+
 // TLSHandshakeRecord defines the structure of a Handshare Record
 type TLSHandshakeRecord struct {
 	TLSRecordHeader
+
+	Messages []TLSHandshakeMessage
+}
+
+// TLSHandshakeMessage is a single message inside a Handshake Record.  Body
+// is shorter than Length if the message continues in a later record.
+// ClientHello and ServerHello messages are also decoded into their own
+// structures.
+type TLSHandshakeMessage struct {
+	Type   TLSHandshakeType
+	Length uint32
+	Body   []byte
+
+	ClientHello *TLSClientHello
+	ServerHello *TLSServerHello
+}
+
+// TLSExtension is a ClientHello or ServerHello extension.
+type TLSExtension struct {
+	Type TLSExtensionType
+	Data []byte
+}
+
+// TLSClientHello is a decoded ClientHello message.  ServerName,
+// ALPNProtocols and SupportedVersions are taken from the corresponding
+// extensions, if present.
+type TLSClientHello struct {
+	Version            TLSVersion
+	Random             []byte
+	SessionID          []byte
+	CipherSuites       []uint16
+	CompressionMethods []uint8
+	Extensions         []TLSExtension
+
+	ServerName        string
+	ALPNProtocols     []string
+	SupportedVersions []TLSVersion
+}
+
+// TLSServerHello is a decoded ServerHello message.  ALPNProtocol and
+// SupportedVersion are taken from the corresponding extensions, if present.
+type TLSServerHello struct {
+	Version           TLSVersion
+	Random            []byte
+	SessionID         []byte
+	CipherSuite       uint16
+	CompressionMethod uint8
+	Extensions        []TLSExtension
+
+	ALPNProtocol     string
+	SupportedVersion TLSVersion
+}
+
+// NegotiatedVersion returns the version chosen by the server: the
+// supported_versions extension for TLS 1.3, or the legacy version field.
+func (s *TLSServerHello) NegotiatedVersion() TLSVersion {
+	if s.SupportedVersion != 0 {
+		return s.SupportedVersion
+	}
+	return s.Version
 }
 
 // DecodeFromBytes decodes the slice into the TLS struct.
@@ -22,7 +191,198 @@ func (t *TLSHandshakeRecord) decodeFromBytes(h TLSRecordHeader, data []byte, df
 	t.Version = h.Version
 	t.Length = h.Length
 
-	// TODO
+	for len(data) > 0 {
+		if len(data) < 4 {
+			// The message header continues in the next record.
+			break
+		}
+		m := TLSHandshakeMessage{
+			Type:   TLSHandshakeType(data[0]),
+			Length: uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3]),
+		}
+		end := 4 + int(m.Length)
+		if end > len(data) {
+			end = len(data)
+		}
+		m.Body = data[4:end]
+		data = data[end:]
+		if len(m.Body) == int(m.Length) {
+			switch m.Type {
+			case TLSHandshakeClientHello:
+				m.ClientHello = &TLSClientHello{}
+				if err := m.ClientHello.decodeFromBytes(m.Body); err != nil {
+					return err
+				}
+			case TLSHandshakeServerHello:
+				m.ServerHello = &TLSServerHello{}
+				if err := m.ServerHello.decodeFromBytes(m.Body); err != nil {
+					return err
+				}
+			}
+		}
+		t.Messages = append(t.Messages, m)
+	}
+
+	return nil
+}
+
+// tlsVector splits a vector with an n byte length prefix off the front of
+// data, returning the vector and whatever follows it.
+func tlsVector(data []byte, n int) (vec, rest []byte, err error) {
+	if len(data) < n {
+		return nil, nil, errors.New("TLS vector length truncated")
+	}
+	length := 0
+	for _, b := range data[:n] {
+		length = length<<8 | int(b)
+	}
+	if len(data) < n+length {
+		return nil, nil, errors.New("TLS vector truncated")
+	}
+	return data[n : n+length], data[n+length:], nil
+}
+
+func decodeTLSExtensions(data []byte) ([]TLSExtension, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	data, rest, err := tlsVector(data, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("TLS hello has data after extensions")
+	}
+	var exts []TLSExtension
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, errors.New("TLS extension truncated")
+		}
+		e := TLSExtension{Type: TLSExtensionType(binary.BigEndian.Uint16(data[0:2]))}
+		if e.Data, data, err = tlsVector(data[2:], 2); err != nil {
+			return nil, err
+		}
+		exts = append(exts, e)
+	}
+	return exts, nil
+}
+
+func decodeTLSALPN(data []byte) ([]string, error) {
+	list, _, err := tlsVector(data, 2)
+	if err != nil {
+		return nil, err
+	}
+	var protos []string
+	for len(list) > 0 {
+		var p []byte
+		if p, list, err = tlsVector(list, 1); err != nil {
+			return nil, err
+		}
+		protos = append(protos, string(p))
+	}
+	return protos, nil
+}
+
+func (c *TLSClientHello) decodeFromBytes(data []byte) error {
+	if len(data) < 34 {
+		return errors.New("TLS ClientHello too short")
+	}
+	c.Version = TLSVersion(binary.BigEndian.Uint16(data[0:2]))
+	c.Random = data[2:34]
+	var err error
+	if c.SessionID, data, err = tlsVector(data[34:], 1); err != nil {
+		return err
+	}
+	var ciphers, compression []byte
+	if ciphers, data, err = tlsVector(data, 2); err != nil {
+		return err
+	}
+	if len(ciphers)%2 != 0 {
+		return errors.New("TLS ClientHello cipher suites length is odd")
+	}
+	c.CipherSuites = make([]uint16, len(ciphers)/2)
+	for i := range c.CipherSuites {
+		c.CipherSuites[i] = binary.BigEndian.Uint16(ciphers[i*2:])
+	}
+	if compression, data, err = tlsVector(data, 1); err != nil {
+		return err
+	}
+	c.CompressionMethods = compression
+	if c.Extensions, err = decodeTLSExtensions(data); err != nil {
+		return err
+	}
+
+	for _, e := range c.Extensions {
+		switch e.Type {
+		case TLSExtensionServerName:
+			list, _, err := tlsVector(e.Data, 2)
+			if err != nil {
+				return err
+			}
+			for len(list) > 0 {
+				nameType := list[0]
+				var name []byte
+				if name, list, err = tlsVector(list[1:], 2); err != nil {
+					return err
+				}
+				if nameType == 0 { // host_name
+					c.ServerName = string(name)
+					break
+				}
+			}
+		case TLSExtensionALPN:
+			if c.ALPNProtocols, err = decodeTLSALPN(e.Data); err != nil {
+				return err
+			}
+		case TLSExtensionSupportedVersions:
+			versions, _, err := tlsVector(e.Data, 1)
+			if err != nil {
+				return err
+			}
+			for i := 0; i+1 < len(versions); i += 2 {
+				c.SupportedVersions = append(c.SupportedVersions, TLSVersion(binary.BigEndian.Uint16(versions[i:])))
+			}
+		}
+	}
+	return nil
+}
+
+func (s *TLSServerHello) decodeFromBytes(data []byte) error {
+	if len(data) < 34 {
+		return errors.New("TLS ServerHello too short")
+	}
+	s.Version = TLSVersion(binary.BigEndian.Uint16(data[0:2]))
+	s.Random = data[2:34]
+	var err error
+	if s.SessionID, data, err = tlsVector(data[34:], 1); err != nil {
+		return err
+	}
+	if len(data) < 3 {
+		return errors.New("TLS ServerHello too short")
+	}
+	s.CipherSuite = binary.BigEndian.Uint16(data[0:2])
+	s.CompressionMethod = data[2]
+	if s.Extensions, err = decodeTLSExtensions(data[3:]); err != nil {
+		return err
+	}
 
+	for _, e := range s.Extensions {
+		switch e.Type {
+		case TLSExtensionALPN:
+			protos, err := decodeTLSALPN(e.Data)
+			if err != nil {
+				return err
+			}
+			if len(protos) != 1 {
+				return errors.New("TLS ServerHello must select exactly one ALPN protocol")
+			}
+			s.ALPNProtocol = protos[0]
+		case TLSExtensionSupportedVersions:
+			if len(e.Data) != 2 {
+				return errors.New("TLS ServerHello supported_versions extension has wrong length")
+			}
+			s.SupportedVersion = TLSVersion(binary.BigEndian.Uint16(e.Data))
+		}
+	}
 	return nil
 }
@@ -43,6 +43,32 @@ var testClientHelloDecoded = &TLS{
 				Version:     0x0301,
 				Length:      209,
 			},
+			[]TLSHandshakeMessage{
+				{
+					Type:   TLSHandshakeClientHello,
+					Length: 205,
+					Body:   testClientHello[63:],
+					ClientHello: &TLSClientHello{
+						Version:   0x0301,
+						Random:    testClientHello[65:97],
+						SessionID: testClientHello[98:98],
+						CipherSuites: []uint16{
+							0xc014, 0xc00a, 0x0039, 0x0038, 0x0088, 0x0087, 0xc00f, 0xc005, 0x0035,
+							0x0084, 0xc013, 0xc009, 0x0033, 0x0032, 0x009a, 0x0099, 0x0045, 0x0044,
+							0xc00e, 0xc004, 0x002f, 0x0096, 0x0041, 0xc011, 0xc007, 0xc00c, 0xc002,
+							0x0005, 0x0004, 0xc012, 0xc008, 0x0016, 0x0013, 0xc00d, 0xc003, 0x000a,
+							0x0015, 0x0012, 0x0009, 0x0014, 0x0011, 0x0008, 0x0006, 0x0003, 0x00ff,
+						},
+						CompressionMethods: []uint8{0x01, 0x00},
+						Extensions: []TLSExtension{
+							{TLSExtensionECPointFormats, testClientHello[199:203]},
+							{TLSExtensionSupportedGroups, testClientHello[207:259]},
+							{TLSExtensionSessionTicket, testClientHello[263:263]},
+							{15, testClientHello[267:268]}, // heartbeat
+						},
+					},
+				},
+			},
 		},
 	},
 	AppData: nil,
@@ -97,7 +123,7 @@ var testClientKeyExchange = []byte{
 }
 var testClientKeyExchangeDecoded = &TLS{
 	BaseLayer: BaseLayer{
-		Contents: testClientKeyExchange[81:],
+		Contents: testClientKeyExchange,
 		Payload:  nil,
 	},
 	ChangeCipherSpec: []TLSChangeCipherSpecRecord{
@@ -117,17 +143,28 @@ var testClientKeyExchangeDecoded = &TLS{
 				Version:     0x0301,
 				Length:      70,
 			},
+			[]TLSHandshakeMessage{
+				{
+					Type:   TLSHandshakeClientKeyExchange,
+					Length: 66,
+					Body:   testClientKeyExchange[9:75],
+				},
+			},
 		},
+	},
+	AppData: nil,
+	Alert:   nil,
+	Encrypted: []TLSRecord{
 		{
 			TLSRecordHeader{
 				ContentType: 22,
 				Version:     0x0301,
 				Length:      48,
 			},
+			testClientKeyExchange[86:],
 		},
 	},
-	AppData: nil,
-	Alert:   nil,
+	changedCipherSpec: true,
 }
 
 // Packet 9 - New Session Ticket, Change Cipher Spec, Encryption Handshake Message
@@ -159,7 +196,7 @@ var testDoubleAppData = []byte{
 }
 var testDoubleAppDataDecoded = &TLS{
 	BaseLayer: BaseLayer{
-		Contents: testDoubleAppData[37:],
+		Contents: testDoubleAppData,
 		Payload:  nil,
 	},
 	ChangeCipherSpec: nil,
@@ -337,3 +374,143 @@ func TestParseTLSAlertEncrypted(t *testing.T) {
 		t.Error("No TLS layer type found in packet")
 	}
 }
+
+func TestParseTLSServerHello(t *testing.T) {
+	var got TLS
+	if err := got.DecodeFromBytes(testServerHello, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	var types []TLSHandshakeType
+	for _, r := range got.Handshake {
+		for _, m := range r.Messages {
+			types = append(types, m.Type)
+		}
+	}
+	want := []TLSHandshakeType{TLSHandshakeServerHello, TLSHandshakeCertificate, TLSHandshakeServerHelloDone}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("handshake messages: got %v, want %v", types, want)
+	}
+	sh := got.ServerHello()
+	if sh == nil {
+		t.Fatal("no ServerHello decoded")
+	}
+	if sh.CipherSuite != 0x002f || sh.NegotiatedVersion() != 0x0301 || sh.CompressionMethod != 1 {
+		t.Errorf("ServerHello: got cipher %#x version %v compression %d", sh.CipherSuite, sh.NegotiatedVersion(), sh.CompressionMethod)
+	}
+	if !reflect.DeepEqual(sh.Random, testServerHello[11:43]) {
+		t.Errorf("ServerHello random: got %x", sh.Random)
+	}
+	if len(sh.Extensions) != 3 || sh.Extensions[0].Type != TLSExtensionRenegotiationInfo {
+		t.Errorf("ServerHello extensions: got %v", sh.Extensions)
+	}
+	if got.ClientHello() != nil {
+		t.Error("unexpected ClientHello")
+	}
+}
+
+// tlsTestVector prefixes data with its length in n bytes.
+func tlsTestVector(n int, data ...byte) []byte {
+	out := make([]byte, n, n+len(data))
+	for i, l := 0, len(data); i < n; i, l = i+1, l>>8 {
+		out[n-1-i] = byte(l)
+	}
+	return append(out, data...)
+}
+
+func tlsTestConcat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestParseTLSClientHelloExtensions(t *testing.T) {
+	sni := tlsTestVector(2, tlsTestConcat([]byte{0}, tlsTestVector(2, []byte("example.com")...))...)
+	alpn := tlsTestVector(2, tlsTestConcat(tlsTestVector(1, []byte("h2")...), tlsTestVector(1, []byte("http/1.1")...))...)
+	versions := tlsTestVector(1, 0x03, 0x04, 0x03, 0x03)
+	exts := tlsTestConcat(
+		[]byte{0x00, 0x00}, tlsTestVector(2, sni...),
+		[]byte{0x00, 0x10}, tlsTestVector(2, alpn...),
+		[]byte{0x00, 0x2b}, tlsTestVector(2, versions...),
+	)
+	body := tlsTestConcat(
+		[]byte{0x03, 0x03}, make([]byte, 32),
+		tlsTestVector(1, 0xaa, 0xbb),
+		tlsTestVector(2, 0x13, 0x01, 0x13, 0x02),
+		tlsTestVector(1, 0x00),
+		tlsTestVector(2, exts...),
+	)
+	msg := tlsTestConcat([]byte{byte(TLSHandshakeClientHello)}, tlsTestVector(3, body...))
+	record := tlsTestConcat([]byte{0x16, 0x03, 0x01}, tlsTestVector(2, msg...))
+
+	p := gopacket.NewPacket(record, LayerTypeTLS, testTLSDecodeOptions)
+	if p.ErrorLayer() != nil {
+		t.Fatal("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	ch := p.Layer(LayerTypeTLS).(*TLS).ClientHello()
+	if ch == nil {
+		t.Fatal("no ClientHello decoded")
+	}
+	if ch.ServerName != "example.com" {
+		t.Errorf("server name: got %q", ch.ServerName)
+	}
+	if !reflect.DeepEqual(ch.ALPNProtocols, []string{"h2", "http/1.1"}) {
+		t.Errorf("ALPN: got %q", ch.ALPNProtocols)
+	}
+	if !reflect.DeepEqual(ch.SupportedVersions, []TLSVersion{0x0304, 0x0303}) {
+		t.Errorf("supported versions: got %v", ch.SupportedVersions)
+	}
+	if !reflect.DeepEqual(ch.CipherSuites, []uint16{0x1301, 0x1302}) {
+		t.Errorf("cipher suites: got %#x", ch.CipherSuites)
+	}
+	if !reflect.DeepEqual(ch.SessionID, []byte{0xaa, 0xbb}) {
+		t.Errorf("session ID: got %x", ch.SessionID)
+	}
+
+	// A cipher suite list overrunning the message is an error.
+	bad := append([]byte(nil), record...)
+	bad[9+34+3+1] = 0xff
+	var tls TLS
+	if err := tls.DecodeFromBytes(bad, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error decoding ClientHello with bad cipher suites length")
+	}
+}
+
+func TestParseTLSRecordsPartial(t *testing.T) {
+	stream := append(append([]byte(nil), testServerHello...), testClientKeyExchange...)
+	var tls TLS
+	// Cut the stream in the middle of the certificate record.
+	n, err := tls.DecodeRecords(stream[:100], gopacket.NilDecodeFeedback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 63 || len(tls.Handshake) != 1 || tls.ServerHello() == nil {
+		t.Fatalf("first chunk: consumed %d bytes, %d handshake records", n, len(tls.Handshake))
+	}
+	if err := tls.DecodeFromBytes(stream[:100], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected DecodeFromBytes to reject a partial record")
+	}
+
+	tls = TLS{}
+	n, err = tls.DecodeRecords(stream[63:], gopacket.NilDecodeFeedback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(stream)-63 {
+		t.Errorf("second chunk: consumed %d bytes, want %d", n, len(stream)-63)
+	}
+	if len(tls.Handshake) != 3 || len(tls.ChangeCipherSpec) != 1 || len(tls.Encrypted) != 1 {
+		t.Errorf("second chunk: got %d handshake, %d change cipher spec, %d encrypted records",
+			len(tls.Handshake), len(tls.ChangeCipherSpec), len(tls.Encrypted))
+	}
+
+	// Handshake records in later calls stay encrypted.
+	n, err = tls.DecodeRecords(testClientKeyExchange[81:], gopacket.NilDecodeFeedback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(testClientKeyExchange)-81 || len(tls.Handshake) != 0 || len(tls.Encrypted) != 1 {
+		t.Errorf("third chunk: consumed %d bytes, %d handshake, %d encrypted records", n, len(tls.Handshake), len(tls.Encrypted))
+	}
+}
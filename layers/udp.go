@@ -89,6 +89,11 @@ func (u *UDP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOpt
 		if err != nil {
 			return err
 		}
+		// A zero checksum means none was computed, which isn't allowed
+		// over IPv6, so a computed zero is sent as all ones (RFC 768).
+		if csum == 0 {
+			csum = 0xffff
+		}
 		u.Checksum = csum
 	}
 	binary.BigEndian.PutUint16(bytes[6:], u.Checksum)
@@ -101,12 +106,15 @@ func (u *UDP) CanDecode() gopacket.LayerClass {
 
 // NextLayerType use the destination port to select the
 // right next decoder. It tries first to decode via the
-// destination port, then the source port.
+// destination port, then the source port.  Ports whose
+// LayerType has a validator registered with
+// RegisterUDPPortLayerTypeValidator are only used if the
+// validator accepts the payload.
 func (u *UDP) NextLayerType() gopacket.LayerType {
-	if lt := u.DstPort.LayerType(); lt != gopacket.LayerTypePayload {
+	if lt := udpPayloadLayerType(u.DstPort, u.Payload); lt != gopacket.LayerTypePayload {
 		return lt
 	}
-	return u.SrcPort.LayerType()
+	return udpPayloadLayerType(u.SrcPort, u.Payload)
 }
 
 func decodeUDP(data []byte, p gopacket.PacketBuilder) error {
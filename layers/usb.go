@@ -8,6 +8,9 @@ package layers
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
+
 	"github.com/google/gopacket"
 )
 
@@ -44,6 +47,8 @@ const (
 	USBRequestBlockSetupRequestGetConfiguration USBRequestBlockSetupRequest = 0x08
 	USBRequestBlockSetupRequestSetConfiguration USBRequestBlockSetupRequest = 0x09
 	USBRequestBlockSetupRequestSetIdle          USBRequestBlockSetupRequest = 0x0a
+	USBRequestBlockSetupRequestSetInterface     USBRequestBlockSetupRequest = 0x0b
+	USBRequestBlockSetupRequestSynchFrame       USBRequestBlockSetupRequest = 0x0c
 )
 
 func (a USBRequestBlockSetupRequest) String() string {
@@ -66,6 +71,10 @@ func (a USBRequestBlockSetupRequest) String() string {
 		return "SET_CONFIGURATION"
 	case USBRequestBlockSetupRequestSetIdle:
 		return "SET_IDLE"
+	case USBRequestBlockSetupRequestSetInterface:
+		return "SET_INTERFACE"
+	case USBRequestBlockSetupRequestSynchFrame:
+		return "SYNCH_FRAME"
 	default:
 		return "UNKNOWN"
 	}
@@ -100,6 +109,159 @@ func (a USBDirectionType) String() string {
 	}
 }
 
+// USBRequestType is the bmRequestType field of a setup packet.
+type USBRequestType uint8
+
+// USBRequestTypeType is the type of a request, bits 5 and 6 of its
+// USBRequestType.
+type USBRequestTypeType uint8
+
+const (
+	USBRequestTypeStandard USBRequestTypeType = 0
+	USBRequestTypeClass    USBRequestTypeType = 1
+	USBRequestTypeVendor   USBRequestTypeType = 2
+)
+
+func (a USBRequestTypeType) String() string {
+	switch a {
+	case USBRequestTypeStandard:
+		return "Standard"
+	case USBRequestTypeClass:
+		return "Class"
+	case USBRequestTypeVendor:
+		return "Vendor"
+	default:
+		return "Reserved"
+	}
+}
+
+// USBRequestRecipient is the recipient of a request, the low 5 bits of its
+// USBRequestType.
+type USBRequestRecipient uint8
+
+const (
+	USBRequestRecipientDevice    USBRequestRecipient = 0
+	USBRequestRecipientInterface USBRequestRecipient = 1
+	USBRequestRecipientEndpoint  USBRequestRecipient = 2
+	USBRequestRecipientOther     USBRequestRecipient = 3
+)
+
+func (a USBRequestRecipient) String() string {
+	switch a {
+	case USBRequestRecipientDevice:
+		return "Device"
+	case USBRequestRecipientInterface:
+		return "Interface"
+	case USBRequestRecipientEndpoint:
+		return "Endpoint"
+	case USBRequestRecipientOther:
+		return "Other"
+	default:
+		return "Reserved"
+	}
+}
+
+// Direction returns the direction of the data stage of the request.
+func (a USBRequestType) Direction() USBDirectionType {
+	if a&0x80 != 0 {
+		return USBDirectionTypeIn
+	}
+	return USBDirectionTypeOut
+}
+
+func (a USBRequestType) Type() USBRequestTypeType       { return USBRequestTypeType(a>>5) & 0x3 }
+func (a USBRequestType) Recipient() USBRequestRecipient { return USBRequestRecipient(a) & 0x1f }
+
+// USBHIDRequest is the request of a HID class setup packet.
+type USBHIDRequest uint8
+
+const (
+	USBHIDRequestGetReport   USBHIDRequest = 0x01
+	USBHIDRequestGetIdle     USBHIDRequest = 0x02
+	USBHIDRequestGetProtocol USBHIDRequest = 0x03
+	USBHIDRequestSetReport   USBHIDRequest = 0x09
+	USBHIDRequestSetIdle     USBHIDRequest = 0x0a
+	USBHIDRequestSetProtocol USBHIDRequest = 0x0b
+)
+
+func (a USBHIDRequest) String() string {
+	switch a {
+	case USBHIDRequestGetReport:
+		return "GET_REPORT"
+	case USBHIDRequestGetIdle:
+		return "GET_IDLE"
+	case USBHIDRequestGetProtocol:
+		return "GET_PROTOCOL"
+	case USBHIDRequestSetReport:
+		return "SET_REPORT"
+	case USBHIDRequestSetIdle:
+		return "SET_IDLE"
+	case USBHIDRequestSetProtocol:
+		return "SET_PROTOCOL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// USBSetupPacket is the 8 byte setup packet starting control transfers.
+type USBSetupPacket struct {
+	RequestType USBRequestType
+	Request     USBRequestBlockSetupRequest
+	Value       uint16
+	Index       uint16
+	Length      uint16
+}
+
+// RequestName returns the name of the request.  Standard requests and the
+// requests of the HID class, sent to interfaces, are known.
+func (s *USBSetupPacket) RequestName() string {
+	switch {
+	case s.RequestType.Type() == USBRequestTypeStandard && s.Request == 0x0a:
+		// USBRequestBlockSetupRequestSetIdle is a HID class request.
+		return "GET_INTERFACE"
+	case s.RequestType.Type() == USBRequestTypeStandard:
+		return s.Request.String()
+	case s.RequestType.Type() == USBRequestTypeClass && s.RequestType.Recipient() == USBRequestRecipientInterface:
+		return USBHIDRequest(s.Request).String()
+	default:
+		return fmt.Sprintf("%v(%#02x)", s.RequestType.Type(), uint8(s.Request))
+	}
+}
+
+// DescriptorType returns the descriptor type of GET_DESCRIPTOR and
+// SET_DESCRIPTOR requests, held by the high byte of Value.
+func (s *USBSetupPacket) DescriptorType() USBDescriptorType { return USBDescriptorType(s.Value >> 8) }
+
+// DescriptorIndex returns the descriptor index of GET_DESCRIPTOR and
+// SET_DESCRIPTOR requests, held by the low byte of Value.
+func (s *USBSetupPacket) DescriptorIndex() uint8 { return uint8(s.Value) }
+
+func (s *USBSetupPacket) decodeFromBytes(data []byte) {
+	s.RequestType = USBRequestType(data[0])
+	s.Request = USBRequestBlockSetupRequest(data[1])
+	s.Value = binary.LittleEndian.Uint16(data[2:4])
+	s.Index = binary.LittleEndian.Uint16(data[4:6])
+	s.Length = binary.LittleEndian.Uint16(data[6:8])
+}
+
+// USBIsoDescriptor is the descriptor of a packet of an isochronous
+// transfer, giving the place of its data in the transfer buffer.
+type USBIsoDescriptor struct {
+	Status int32
+	Offset uint32
+	Length uint32
+}
+
+const (
+	usbHeaderLength       = 64
+	usbLegacyHeaderLength = 48
+	usbIsoDescriptorLen   = 16
+)
+
+// USB is the usbmon header preceding URBs, in the 64 byte form of
+// LINKTYPE_USB_LINUX_MMAPPED captures, or the 48 byte one of
+// LINKTYPE_USB_LINUX captures if LegacyHeader is set.
+//
 // The reference at http://www.beyondlogic.org/usbnutshell/usb1.shtml contains more information about the protocol.
 type USB struct {
 	BaseLayer
@@ -118,20 +280,35 @@ type USB struct {
 	UrbLength      uint32
 	UrbDataLength  uint32
 
+	// SetupPacket is set if Setup is, on submissions of control transfers.
+	SetupPacket USBSetupPacket
+
+	// The following are only set by the 64 byte header.
 	UrbInterval            uint32
 	UrbStartFrame          uint32
 	UrbCopyOfTransferFlags uint32
-	IsoNumDesc             uint32
+
+	// IsoErrorCount and IsoNumDesc are only set for isochronous transfers.
+	// With the 64 byte header, IsoNumDesc is the number of descriptors in
+	// IsoDescriptors, which precede the data.
+	IsoErrorCount  int32
+	IsoNumDesc     uint32
+	IsoDescriptors []USBIsoDescriptor
+
+	// LegacyHeader is set to decode the 48 byte header.  The decoder of
+	// LinkTypeLinuxUSBLegacy sets it, and it's kept by DecodeFromBytes.
+	LegacyHeader bool
 }
 
 func (u *USB) LayerType() gopacket.LayerType { return LayerTypeUSB }
 
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (m *USB) CanDecode() gopacket.LayerClass { return LayerTypeUSB }
+
 func (m *USB) NextLayerType() gopacket.LayerType {
-	if m.Setup {
-		return LayerTypeUSBRequestBlockSetup
-	} else if m.Data {
+	if m.TransferType == USBTransportTypeIsochronous {
+		return gopacket.LayerTypePayload
 	}
-
 	return m.TransferType.LayerType()
 }
 
@@ -141,7 +318,22 @@ func decodeUSB(data []byte, p gopacket.PacketBuilder) error {
 	return decodingLayerDecoder(d, data, p)
 }
 
+func decodeUSBLegacy(data []byte, p gopacket.PacketBuilder) error {
+	d := &USB{LegacyHeader: true}
+	return decodingLayerDecoder(d, data, p)
+}
+
 func (m *USB) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	length := usbHeaderLength
+	if m.LegacyHeader {
+		length = usbLegacyHeaderLength
+	}
+	if len(data) < length {
+		df.SetTruncated()
+		return fmt.Errorf("USB header length %d too short", len(data))
+	}
+	*m = USB{LegacyHeader: m.LegacyHeader}
+
 	m.ID = binary.LittleEndian.Uint64(data[0:8])
 	m.EventType = USBEventType(data[8])
 	m.TransferType = USBTransportType(data[9])
@@ -170,28 +362,252 @@ func (m *USB) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
 	m.UrbLength = binary.LittleEndian.Uint32(data[32:36])
 	m.UrbDataLength = binary.LittleEndian.Uint32(data[36:40])
 
-	m.Contents = data[:40]
-	m.Payload = data[40:]
-
+	// Bytes 40 to 48 hold either the setup packet or, for isochronous
+	// transfers, the error count and the number of descriptors.
 	if m.Setup {
-		m.Payload = data[40:]
-	} else if m.Data {
-		m.Payload = data[uint32(len(data))-m.UrbDataLength:]
+		m.SetupPacket.decodeFromBytes(data[40:48])
+	} else if m.TransferType == USBTransportTypeIsochronous {
+		m.IsoErrorCount = int32(binary.LittleEndian.Uint32(data[40:44]))
+		m.IsoNumDesc = binary.LittleEndian.Uint32(data[44:48])
+	}
+
+	if !m.LegacyHeader {
+		m.UrbInterval = binary.LittleEndian.Uint32(data[48:52])
+		m.UrbStartFrame = binary.LittleEndian.Uint32(data[52:56])
+		m.UrbCopyOfTransferFlags = binary.LittleEndian.Uint32(data[56:60])
+		ndesc := binary.LittleEndian.Uint32(data[60:64])
+		if m.TransferType == USBTransportTypeIsochronous {
+			m.IsoNumDesc = ndesc
+			if uint64(ndesc)*usbIsoDescriptorLen > uint64(len(data)-length) {
+				df.SetTruncated()
+				return fmt.Errorf("USB isochronous descriptor count %d exceeds %d bytes", ndesc, len(data)-length)
+			}
+			for i := uint32(0); i < ndesc; i++ {
+				d := data[length : length+usbIsoDescriptorLen]
+				m.IsoDescriptors = append(m.IsoDescriptors, USBIsoDescriptor{
+					Status: int32(binary.LittleEndian.Uint32(d[0:4])),
+					Offset: binary.LittleEndian.Uint32(d[4:8]),
+					Length: binary.LittleEndian.Uint32(d[8:12]),
+				})
+				length += usbIsoDescriptorLen
+			}
+		}
+	}
+
+	m.Contents = data[:length]
+	m.Payload = data[length:]
+
+	return nil
+}
+
+// USBDescriptorType is the bDescriptorType field of USB descriptors.
+type USBDescriptorType uint8
+
+const (
+	USBDescriptorTypeDevice                  USBDescriptorType = 0x01
+	USBDescriptorTypeConfiguration           USBDescriptorType = 0x02
+	USBDescriptorTypeString                  USBDescriptorType = 0x03
+	USBDescriptorTypeInterface               USBDescriptorType = 0x04
+	USBDescriptorTypeEndpoint                USBDescriptorType = 0x05
+	USBDescriptorTypeDeviceQualifier         USBDescriptorType = 0x06
+	USBDescriptorTypeOtherSpeedConfiguration USBDescriptorType = 0x07
+	USBDescriptorTypeInterfaceAssociation    USBDescriptorType = 0x0b
+	USBDescriptorTypeBOS                     USBDescriptorType = 0x0f
+	USBDescriptorTypeHID                     USBDescriptorType = 0x21
+	USBDescriptorTypeHIDReport               USBDescriptorType = 0x22
+)
+
+func (a USBDescriptorType) String() string {
+	switch a {
+	case USBDescriptorTypeDevice:
+		return "DEVICE"
+	case USBDescriptorTypeConfiguration:
+		return "CONFIGURATION"
+	case USBDescriptorTypeString:
+		return "STRING"
+	case USBDescriptorTypeInterface:
+		return "INTERFACE"
+	case USBDescriptorTypeEndpoint:
+		return "ENDPOINT"
+	case USBDescriptorTypeDeviceQualifier:
+		return "DEVICE_QUALIFIER"
+	case USBDescriptorTypeOtherSpeedConfiguration:
+		return "OTHER_SPEED_CONFIGURATION"
+	case USBDescriptorTypeInterfaceAssociation:
+		return "INTERFACE_ASSOCIATION"
+	case USBDescriptorTypeBOS:
+		return "BOS"
+	case USBDescriptorTypeHID:
+		return "HID"
+	case USBDescriptorTypeHIDReport:
+		return "HID_REPORT"
+	default:
+		return fmt.Sprintf("Unknown(%#02x)", uint8(a))
 	}
+}
+
+// USBDeviceDescriptor is a DEVICE descriptor.
+type USBDeviceDescriptor struct {
+	USBVersion        uint16
+	Class             uint8
+	SubClass          uint8
+	Protocol          uint8
+	MaxPacketSize0    uint8
+	VendorID          uint16
+	ProductID         uint16
+	DeviceVersion     uint16
+	ManufacturerIndex uint8
+	ProductIndex      uint8
+	SerialNumberIndex uint8
+	NumConfigurations uint8
+}
 
-	// if 64 bit, dissect_linux_usb_pseudo_header_ext
-	if false {
-		m.UrbInterval = binary.LittleEndian.Uint32(data[40:44])
-		m.UrbStartFrame = binary.LittleEndian.Uint32(data[44:48])
-		m.UrbDataLength = binary.LittleEndian.Uint32(data[48:52])
-		m.IsoNumDesc = binary.LittleEndian.Uint32(data[52:56])
-		m.Contents = data[:56]
-		m.Payload = data[56:]
+// USBConfigurationDescriptor is a CONFIGURATION descriptor.  TotalLength
+// covers the interface, endpoint and class descriptors following it.
+type USBConfigurationDescriptor struct {
+	TotalLength        uint16
+	NumInterfaces      uint8
+	ConfigurationValue uint8
+	ConfigurationIndex uint8
+	Attributes         uint8
+	// MaxPower is in units of 2 mA.
+	MaxPower uint8
+}
+
+// USBInterfaceDescriptor is an INTERFACE descriptor.
+type USBInterfaceDescriptor struct {
+	InterfaceNumber  uint8
+	AlternateSetting uint8
+	NumEndpoints     uint8
+	Class            uint8
+	SubClass         uint8
+	Protocol         uint8
+	InterfaceIndex   uint8
+}
+
+// USBEndpointDescriptor is an ENDPOINT descriptor.
+type USBEndpointDescriptor struct {
+	Address       uint8
+	Attributes    uint8
+	MaxPacketSize uint16
+	Interval      uint8
+}
+
+func (d *USBEndpointDescriptor) Number() uint8 { return d.Address & 0x0f }
+
+func (d *USBEndpointDescriptor) Direction() USBDirectionType {
+	if d.Address&0x80 != 0 {
+		return USBDirectionTypeIn
 	}
+	return USBDirectionTypeOut
+}
+
+// TransferType returns the transfer type of the endpoint.  Descriptors
+// number transfer types differently from usbmon.
+func (d *USBEndpointDescriptor) TransferType() USBTransportType {
+	return [...]USBTransportType{
+		USBTransportTypeControl,
+		USBTransportTypeIsochronous,
+		USBTransportTypeBulk,
+		USBTransportTypeInterrupt,
+	}[d.Attributes&0x03]
+}
+
+// USBDescriptor is a descriptor of a GET_DESCRIPTOR response.  Data is the
+// descriptor following its length and type bytes.  One of Device,
+// Configuration, Interface and Endpoint is set for descriptors of those
+// types.
+type USBDescriptor struct {
+	Length        uint8
+	Type          USBDescriptorType
+	Data          []byte
+	Device        *USBDeviceDescriptor
+	Configuration *USBConfigurationDescriptor
+	Interface     *USBInterfaceDescriptor
+	Endpoint      *USBEndpointDescriptor
+}
 
-	// crc5 or crc16
-	// eop (end of packet)
+// DecodeUSBDescriptors decodes the descriptors of the data of a
+// GET_DESCRIPTOR response, such as the payload of a USBControl layer.  A
+// CONFIGURATION response holds the descriptors of all of its interfaces
+// and endpoints.
+func DecodeUSBDescriptors(data []byte) ([]USBDescriptor, error) {
+	var descriptors []USBDescriptor
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return descriptors, errors.New("USB descriptor too short")
+		}
+		d := USBDescriptor{Length: data[0], Type: USBDescriptorType(data[1])}
+		if d.Length < 2 || int(d.Length) > len(data) {
+			return descriptors, fmt.Errorf("invalid USB descriptor length %d", d.Length)
+		}
+		d.Data = data[2:d.Length]
+		if err := d.decodeData(); err != nil {
+			return descriptors, err
+		}
+		descriptors = append(descriptors, d)
+		data = data[d.Length:]
+	}
+	return descriptors, nil
+}
 
+func (d *USBDescriptor) decodeData() error {
+	b := d.Data
+	switch d.Type {
+	case USBDescriptorTypeDevice:
+		if len(b) < 16 {
+			return fmt.Errorf("USB %v descriptor too short", d.Type)
+		}
+		d.Device = &USBDeviceDescriptor{
+			USBVersion:        binary.LittleEndian.Uint16(b[0:2]),
+			Class:             b[2],
+			SubClass:          b[3],
+			Protocol:          b[4],
+			MaxPacketSize0:    b[5],
+			VendorID:          binary.LittleEndian.Uint16(b[6:8]),
+			ProductID:         binary.LittleEndian.Uint16(b[8:10]),
+			DeviceVersion:     binary.LittleEndian.Uint16(b[10:12]),
+			ManufacturerIndex: b[12],
+			ProductIndex:      b[13],
+			SerialNumberIndex: b[14],
+			NumConfigurations: b[15],
+		}
+	case USBDescriptorTypeConfiguration, USBDescriptorTypeOtherSpeedConfiguration:
+		if len(b) < 7 {
+			return fmt.Errorf("USB %v descriptor too short", d.Type)
+		}
+		d.Configuration = &USBConfigurationDescriptor{
+			TotalLength:        binary.LittleEndian.Uint16(b[0:2]),
+			NumInterfaces:      b[2],
+			ConfigurationValue: b[3],
+			ConfigurationIndex: b[4],
+			Attributes:         b[5],
+			MaxPower:           b[6],
+		}
+	case USBDescriptorTypeInterface:
+		if len(b) < 7 {
+			return fmt.Errorf("USB %v descriptor too short", d.Type)
+		}
+		d.Interface = &USBInterfaceDescriptor{
+			InterfaceNumber:  b[0],
+			AlternateSetting: b[1],
+			NumEndpoints:     b[2],
+			Class:            b[3],
+			SubClass:         b[4],
+			Protocol:         b[5],
+			InterfaceIndex:   b[6],
+		}
+	case USBDescriptorTypeEndpoint:
+		if len(b) < 5 {
+			return fmt.Errorf("USB %v descriptor too short", d.Type)
+		}
+		d.Endpoint = &USBEndpointDescriptor{
+			Address:       b[0],
+			Attributes:    b[1],
+			MaxPacketSize: binary.LittleEndian.Uint16(b[2:4]),
+			Interval:      b[4],
+		}
+	}
 	return nil
 }
 
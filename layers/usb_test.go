@@ -7,6 +7,7 @@
 package layers
 
 import (
+	"bytes"
 	_ "fmt"
 	"github.com/google/gopacket"
 	"reflect"
@@ -41,7 +42,7 @@ func TestPacketUSB0(t *testing.T) {
 	if got, ok := p.Layer(LayerTypeUSB).(*USB); ok {
 		want := &USB{
 			BaseLayer: BaseLayer{
-				Contents: []uint8{0x0, 0x38, 0x4a, 0x3b, 0x0, 0x88, 0xff, 0xff, 0x43, 0x1, 0x81, 0x1, 0x2, 0x0, 0x2d, 0x0, 0xc0, 0xd3, 0x5b, 0x50, 0x0, 0x0, 0x0, 0x0, 0x8a, 0x85, 0xa, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0},
+				Contents: testPacketUSB0[:64],
 				Payload:  []uint8{0x4},
 			},
 			ID:             0xffff88003b4a3800,
@@ -58,6 +59,9 @@ func TestPacketUSB0(t *testing.T) {
 			Status:         0,
 			UrbLength:      0x1,
 			UrbDataLength:  0x1,
+
+			UrbInterval:            0x80,
+			UrbCopyOfTransferFlags: 0x200,
 		}
 
 		if !reflect.DeepEqual(got, want) {
@@ -71,3 +75,201 @@ func BenchmarkDecodePacketUSB0(b *testing.B) {
 		gopacket.NewPacket(testPacketUSB0, LinkTypeLinuxUSB, gopacket.NoCopy)
 	}
 }
+
+// testPacketUSBGetDescriptor is the submission of a GET_DESCRIPTOR request
+// for the device descriptor of device 1:3.
+var testPacketUSBGetDescriptor = []byte{
+	0x00, 0xcc, 0xbb, 0xaa, 0x00, 0x88, 0xff, 0xff, 0x53, 0x02, 0x80, 0x03,
+	0x01, 0x00, 0x00, 0x3c, 0x00, 0x2f, 0x68, 0x59, 0x00, 0x00, 0x00, 0x00,
+	0x40, 0xe2, 0x01, 0x00, 0x8d, 0xff, 0xff, 0xff, 0x12, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x80, 0x06, 0x00, 0x01, 0x00, 0x00, 0x12, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+}
+
+// testPacketUSBDeviceDescriptor is its completion, with the device
+// descriptor of a 046d:c52b receiver.
+var testPacketUSBDeviceDescriptor = []byte{
+	0x00, 0xcc, 0xbb, 0xaa, 0x00, 0x88, 0xff, 0xff, 0x43, 0x02, 0x80, 0x03,
+	0x01, 0x00, 0x2d, 0x00, 0x00, 0x2f, 0x68, 0x59, 0x00, 0x00, 0x00, 0x00,
+	0x40, 0xe2, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x12, 0x00, 0x00, 0x00,
+	0x12, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x12, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x40,
+	0x6d, 0x04, 0x2b, 0xc5, 0x00, 0x01, 0x01, 0x02, 0x00, 0x01,
+}
+
+// testPacketUSBIsochronous is the completion of an isochronous transfer of
+// two 3 byte packets.
+var testPacketUSBIsochronous = []byte{
+	0x00, 0xcc, 0xbb, 0xaa, 0x00, 0x88, 0xff, 0xff, 0x43, 0x00, 0x81, 0x04,
+	0x01, 0x00, 0x2d, 0x00, 0x00, 0x2f, 0x68, 0x59, 0x00, 0x00, 0x00, 0x00,
+	0x40, 0xe2, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00,
+	0x26, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x03, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
+}
+
+func TestPacketUSBSetup(t *testing.T) {
+	p := gopacket.NewPacket(testPacketUSBGetDescriptor, LinkTypeLinuxUSB, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeUSB}, t)
+	u := p.Layer(LayerTypeUSB).(*USB)
+	if !u.Setup || u.Data || u.Status != -115 || u.UrbCopyOfTransferFlags != 0x200 {
+		t.Errorf("bad USB header %+v", u)
+	}
+	want := USBSetupPacket{RequestType: 0x80, Request: USBRequestBlockSetupRequestGetDescriptor, Value: 0x0100, Length: 18}
+	if u.SetupPacket != want {
+		t.Errorf("setup packet is %+v, want %+v", u.SetupPacket, want)
+	}
+	s := u.SetupPacket
+	if s.RequestType.Direction() != USBDirectionTypeIn || s.RequestType.Type() != USBRequestTypeStandard || s.RequestType.Recipient() != USBRequestRecipientDevice {
+		t.Errorf("bad request type %#x", s.RequestType)
+	}
+	if s.RequestName() != "GET_DESCRIPTOR" || s.DescriptorType() != USBDescriptorTypeDevice || s.DescriptorIndex() != 0 {
+		t.Errorf("bad request %s of descriptor %v %d", s.RequestName(), s.DescriptorType(), s.DescriptorIndex())
+	}
+}
+
+func TestUSBSetupPacketRequestName(t *testing.T) {
+	for _, c := range []struct {
+		s    USBSetupPacket
+		want string
+	}{
+		{USBSetupPacket{RequestType: 0x81, Request: 0x0a}, "GET_INTERFACE"},
+		{USBSetupPacket{RequestType: 0x00, Request: 0x09}, "SET_CONFIGURATION"},
+		{USBSetupPacket{RequestType: 0x21, Request: 0x0a}, "SET_IDLE"},
+		{USBSetupPacket{RequestType: 0xa1, Request: 0x01}, "GET_REPORT"},
+		{USBSetupPacket{RequestType: 0xc0, Request: 0x33}, "Vendor(0x33)"},
+	} {
+		if got := c.s.RequestName(); got != c.want {
+			t.Errorf("%+v: got %s, want %s", c.s, got, c.want)
+		}
+	}
+}
+
+func TestPacketUSBDeviceDescriptor(t *testing.T) {
+	p := gopacket.NewPacket(testPacketUSBDeviceDescriptor, LinkTypeLinuxUSB, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeUSB, LayerTypeUSBControl}, t)
+	if u := p.Layer(LayerTypeUSB).(*USB); u.Setup || !u.Data || u.SetupPacket != (USBSetupPacket{}) {
+		t.Errorf("bad USB header %+v", u)
+	}
+	d, err := DecodeUSBDescriptors(p.Layer(LayerTypeUSBControl).LayerContents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []USBDescriptor{{
+		Length: 18,
+		Type:   USBDescriptorTypeDevice,
+		Data:   testPacketUSBDeviceDescriptor[66:],
+		Device: &USBDeviceDescriptor{
+			USBVersion:        0x0200,
+			MaxPacketSize0:    64,
+			VendorID:          0x046d,
+			ProductID:         0xc52b,
+			DeviceVersion:     0x0100,
+			ManufacturerIndex: 1,
+			ProductIndex:      2,
+			NumConfigurations: 1,
+		},
+	}}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("descriptors mismatch:\ngot  %+v\nwant %+v", d, want)
+	}
+}
+
+func TestDecodeUSBConfigurationDescriptors(t *testing.T) {
+	// A configuration of a boot mouse interface, its HID descriptor and
+	// interrupt IN endpoint.
+	data := []byte{
+		0x09, 0x02, 0x22, 0x00, 0x01, 0x01, 0x00, 0xa0, 0x32,
+		0x09, 0x04, 0x00, 0x00, 0x01, 0x03, 0x01, 0x02, 0x00,
+		0x09, 0x21, 0x11, 0x01, 0x00, 0x01, 0x22, 0x34, 0x00,
+		0x07, 0x05, 0x81, 0x03, 0x08, 0x00, 0x0a,
+	}
+	d, err := DecodeUSBDescriptors(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d) != 4 {
+		t.Fatalf("got %d descriptors, want 4", len(d))
+	}
+	if c := d[0].Configuration; c == nil || c.TotalLength != 34 || c.NumInterfaces != 1 || c.Attributes != 0xa0 || c.MaxPower != 50 {
+		t.Errorf("bad configuration descriptor %+v", c)
+	}
+	if i := d[1].Interface; i == nil || i.Class != 3 || i.SubClass != 1 || i.Protocol != 2 || i.NumEndpoints != 1 {
+		t.Errorf("bad interface descriptor %+v", i)
+	}
+	if d[2].Type != USBDescriptorTypeHID || !bytes.Equal(d[2].Data, data[20:27]) {
+		t.Errorf("bad HID descriptor %+v", d[2])
+	}
+	e := d[3].Endpoint
+	if e == nil || e.Number() != 1 || e.Direction() != USBDirectionTypeIn || e.TransferType() != USBTransportTypeInterrupt || e.MaxPacketSize != 8 || e.Interval != 10 {
+		t.Errorf("bad endpoint descriptor %+v", e)
+	}
+}
+
+func TestPacketUSBIsochronous(t *testing.T) {
+	p := gopacket.NewPacket(testPacketUSBIsochronous, LinkTypeLinuxUSB, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeUSB, gopacket.LayerTypePayload}, t)
+	u := p.Layer(LayerTypeUSB).(*USB)
+	want := []USBIsoDescriptor{{Offset: 0, Length: 3}, {Offset: 3, Length: 3}}
+	if u.IsoNumDesc != 2 || !reflect.DeepEqual(u.IsoDescriptors, want) {
+		t.Errorf("isochronous descriptors are %+v, want %+v", u.IsoDescriptors, want)
+	}
+	if u.UrbInterval != 1 || u.UrbStartFrame != 0x100 || len(u.Contents) != 96 {
+		t.Errorf("bad USB header %+v", u)
+	}
+	if !bytes.Equal(u.Payload, []byte{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("payload is %x", u.Payload)
+	}
+}
+
+func TestPacketUSBLegacyHeader(t *testing.T) {
+	data := append(append([]byte{}, testPacketUSB0[:48]...), 0x04)
+	p := gopacket.NewPacket(data, LinkTypeLinuxUSBLegacy, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeUSB, LayerTypeUSBInterrupt}, t)
+	u := p.Layer(LayerTypeUSB).(*USB)
+	if !u.LegacyHeader || len(u.Contents) != 48 || u.UrbInterval != 0 || !bytes.Equal(u.Payload, []byte{0x04}) {
+		t.Errorf("bad USB header %+v", u)
+	}
+}
+
+func TestUSBMalformed(t *testing.T) {
+	var u USB
+	if err := u.DecodeFromBytes(testPacketUSB0[:60], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding truncated header")
+	}
+	if err := u.DecodeFromBytes(testPacketUSBIsochronous[:90], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("no error decoding truncated isochronous descriptors")
+	}
+
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"short", []byte{0x12}},
+		{"zero length", []byte{0x00, 0x01}},
+		{"length too long", []byte{0x09, 0x02, 0x22, 0x00}},
+		{"device descriptor too short", []byte{0x04, 0x01, 0x00, 0x02}},
+		{"endpoint descriptor too short", []byte{0x04, 0x05, 0x81, 0x03}},
+	} {
+		if _, err := DecodeUSBDescriptors(c.data); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
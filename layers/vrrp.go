@@ -9,13 +9,15 @@ package layers
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"net"
 
 	"github.com/google/gopacket"
 )
 
 /*
-	This layer provides decoding for Virtual Router Redundancy Protocol (VRRP) v2.
+	This layer provides decoding for Virtual Router Redundancy Protocol (VRRP) v2
+	and v3.
 	https://tools.ietf.org/html/rfc3768#section-5
     0                   1                   2                   3
     0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
@@ -34,9 +36,28 @@ import (
    |                     Authentication Data (1)                   |
    +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
    |                     Authentication Data (2)                   |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+	Version 3 replaces the authentication fields with a 12 bit maximum
+	advertisement interval, drops the authentication data, and carries either
+	IPv4 or IPv6 addresses.  Its checksum covers an IP pseudo-header, as TCP's
+	and UDP's do.
+	https://tools.ietf.org/html/rfc5798#section-5.1
+    0                   1                   2                   3
+    0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |Version| Type  | Virtual Rtr ID|   Priority    |Count IPvX Addr|
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |(rsvd) |     Max Adver Int     |          Checksum             |
+   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+   |                       IPvX Address(es)                        |
    +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
 */
 
+// vrrpv2AuthDataLength is the length of the authentication data at the end of
+// a VRRP v2 advertisement.
+const vrrpv2AuthDataLength = 8
+
 type VRRPv2Type uint8
 type VRRPv2AuthType uint8
 
@@ -73,32 +94,81 @@ func (v VRRPv2AuthType) String() string {
 	}
 }
 
-// VRRPv2 represents an VRRP v2 message.
-type VRRPv2 struct {
+// VRRP represents a VRRP v2 or v3 message.
+type VRRP struct {
 	BaseLayer
-	Version      uint8          // The version field specifies the VRRP protocol version of this packet (v2)
-	Type         VRRPv2Type     // The type field specifies the type of this VRRP packet.  The only type defined in v2 is ADVERTISEMENT
+	Version      uint8          // The version field specifies the VRRP protocol version of this packet (2 or 3)
+	Type         VRRPv2Type     // The type field specifies the type of this VRRP packet.  The only type defined is ADVERTISEMENT
 	VirtualRtrID uint8          // identifies the virtual router this packet is reporting status for
 	Priority     uint8          // specifies the sending VRRP router's priority for the virtual router (100 = default)
 	CountIPAddr  uint8          // The number of IP addresses contained in this VRRP advertisement.
-	AuthType     VRRPv2AuthType // identifies the authentication method being utilized
-	AdverInt     uint8          // The Advertisement interval indicates the time interval (in seconds) between ADVERTISEMENTS.  The default is 1 second
+	AuthType     VRRPv2AuthType // v2 only: identifies the authentication method being utilized
+	AdverInt     uint8          // v2 only: The Advertisement interval indicates the time interval (in seconds) between ADVERTISEMENTS.  The default is 1 second
+	MaxAdverInt  uint16         // v3 only: The advertisement interval in centiseconds, 12 bits.  The default is 100 (1 second)
 	Checksum     uint16         // used to detect data corruption in the VRRP message.
 	IPAddress    []net.IP       // one or more IP addresses associated with the virtual router. Specified in the CountIPAddr field.
+
+	tcpipchecksum
 }
 
-// LayerType returns LayerTypeVRRP for VRRP v2 message.
-func (v *VRRPv2) LayerType() gopacket.LayerType { return LayerTypeVRRP }
+// VRRPv2 is the name VRRP had when only version 2 was decoded.
+type VRRPv2 = VRRP
+
+// LayerType returns LayerTypeVRRP for VRRP v2 and v3 messages.
+func (v *VRRP) LayerType() gopacket.LayerType { return LayerTypeVRRP }
 
-func (v *VRRPv2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+// addressLength returns the length of each address in a length byte VRRP
+// message.
+func (v *VRRP) addressLength(length int) (int, error) {
+	count := int(v.CountIPAddr)
+	switch v.Version {
+	case 2:
+		// Version 2 is IPv4 only, and followed by authentication data.
+		if length < 8+count*4+vrrpv2AuthDataLength {
+			return 0, errors.New("VRRPv2 packet too small for its addresses.")
+		}
+		return 4, nil
+	case 3:
+		// Use the network layer if we know it.  Otherwise, as there's
+		// nothing after the addresses, their length tells us which they are.
+		switch v.pseudoheader.(type) {
+		case *IPv4:
+			if length < 8+count*4 {
+				return 0, errors.New("VRRPv3 packet too small for its IPv4 addresses.")
+			}
+			return 4, nil
+		case *IPv6:
+			if length < 8+count*16 {
+				return 0, errors.New("VRRPv3 packet too small for its IPv6 addresses.")
+			}
+			return 16, nil
+		}
+		switch length - 8 {
+		case count * 16:
+			return 16, nil
+		case count * 4:
+			return 4, nil
+		}
+		return 0, errors.New("VRRPv3 packet length doesn't match its number of addresses.")
+	}
+	return 0, fmt.Errorf("Unsupported VRRP version %d.", v.Version)
+}
 
-	v.BaseLayer = BaseLayer{Contents: data[:len(data)]}
-	v.Version = data[0] >> 4 // high nibble == VRRP version. We're expecting v2
+// DecodeFromBytes decodes the given bytes into this layer.  A VRRP v3 message
+// may carry IPv4 or IPv6 addresses; if SetNetworkLayerForChecksum has been
+// called the enclosing network layer decides which, and otherwise the
+// message's length does.
+func (v *VRRP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		df.SetTruncated()
+		return errors.New("Not a valid VRRP packet. Packet length is too small.")
+	}
+	v.Version = data[0] >> 4 // high nibble == VRRP version.
 
 	v.Type = VRRPv2Type(data[0] & 0x0F) // low nibble == VRRP type. Expecting 1 (advertisement)
 	if v.Type != 1 {
 		// rfc3768: A packet with unknown type MUST be discarded.
-		return errors.New("Unrecognized VRRPv2 type field.")
+		return errors.New("Unrecognized VRRP type field.")
 	}
 
 	v.VirtualRtrID = data[1]
@@ -106,19 +176,33 @@ func (v *VRRPv2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 
 	v.CountIPAddr = data[3]
 	if v.CountIPAddr < 1 {
-		return errors.New("VRRPv2 number of IP addresses is not valid.")
+		return errors.New("VRRP number of IP addresses is not valid.")
 	}
 
-	v.AuthType = VRRPv2AuthType(data[4])
-	v.AdverInt = uint8(data[5])
+	if v.Version == 3 {
+		v.AuthType = 0
+		v.AdverInt = 0
+		v.MaxAdverInt = binary.BigEndian.Uint16(data[4:6]) & 0x0fff
+	} else {
+		v.AuthType = VRRPv2AuthType(data[4])
+		v.AdverInt = uint8(data[5])
+		v.MaxAdverInt = 0
+	}
 	v.Checksum = binary.BigEndian.Uint16(data[6:8])
 
+	addrLen, err := v.addressLength(len(data))
+	if err != nil {
+		df.SetTruncated()
+		return err
+	}
+
 	// populate the IPAddress field. The number of addresses is specified in the v.CountIPAddr field
 	// offset references the starting byte containing the list of ip addresses
+	v.IPAddress = v.IPAddress[:0]
 	offset := 8
 	for i := uint8(0); i < v.CountIPAddr; i++ {
-		v.IPAddress = append(v.IPAddress, data[offset:offset+4])
-		offset += 4
+		v.IPAddress = append(v.IPAddress, data[offset:offset+addrLen])
+		offset += addrLen
 	}
 
 	//	any trailing packets here may be authentication data and *should* be ignored in v2 as per RFC
@@ -128,29 +212,111 @@ func (v *VRRPv2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error
 	//			The authentication string is currently only used to maintain
 	//			backwards compatibility with RFC 2338.  It SHOULD be set to zero on
 	//	   		transmission and ignored on reception.
+	v.BaseLayer = BaseLayer{Contents: data}
+	return nil
+}
+
+// SerializeTo writes the serialized form of this layer into the
+// SerializationBuffer, implementing gopacket.SerializableLayer.
+// See the docs for gopacket.SerializableLayer for more info.
+//
+// For version 3, all addresses must be IPv4 or all IPv6, and computing the
+// checksum needs the network layer, as it does for TCP.
+func (v *VRRP) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	if v.Version != 2 && v.Version != 3 {
+		return fmt.Errorf("Unsupported VRRP version %d.", v.Version)
+	}
+	if len(v.IPAddress) > 255 {
+		return errors.New("VRRP messages can carry at most 255 addresses.")
+	}
+	addrLen := 4
+	if v.Version == 3 && len(v.IPAddress) > 0 && v.IPAddress[0].To4() == nil {
+		addrLen = 16
+	}
+	length := 8 + len(v.IPAddress)*addrLen
+	if v.Version == 2 {
+		length += vrrpv2AuthDataLength
+	}
+	bytes, err := b.PrependBytes(length)
+	if err != nil {
+		return err
+	}
+	if opts.FixLengths {
+		v.CountIPAddr = uint8(len(v.IPAddress))
+	}
+	bytes[0] = v.Version<<4 | uint8(v.Type)&0x0f
+	bytes[1] = v.VirtualRtrID
+	bytes[2] = v.Priority
+	bytes[3] = v.CountIPAddr
+	if v.Version == 3 {
+		binary.BigEndian.PutUint16(bytes[4:6], v.MaxAdverInt&0x0fff)
+	} else {
+		bytes[4] = uint8(v.AuthType)
+		bytes[5] = v.AdverInt
+	}
+	offset := 8
+	for i, ip := range v.IPAddress {
+		if addrLen == 4 {
+			ip = ip.To4()
+		} else if ip.To4() != nil {
+			ip = nil
+		}
+		if ip == nil {
+			return fmt.Errorf("invalid VRRPv%d address [%d] '%s'", v.Version, i, v.IPAddress[i])
+		}
+		copy(bytes[offset:], ip)
+		offset += addrLen
+	}
+	for i := offset; i < length; i++ {
+		bytes[i] = 0
+	}
+	if opts.ComputeChecksums {
+		bytes[6], bytes[7] = 0, 0
+		if v.Version == 3 {
+			if v.Checksum, err = v.computeChecksum(bytes, IPProtocolVRRP); err != nil {
+				return err
+			}
+		} else {
+			v.Checksum = tcpipChecksum(bytes, 0)
+		}
+	}
+	binary.BigEndian.PutUint16(bytes[6:8], v.Checksum)
 	return nil
 }
 
+// ComputeChecksum returns the checksum the decoded message should carry, to
+// compare against Checksum.  For version 3 this needs the network layer,
+// which must be set with SetNetworkLayerForChecksum.
+func (v *VRRP) ComputeChecksum() (uint16, error) {
+	if len(v.Contents) < 8 {
+		return 0, errors.New("VRRP message not decoded")
+	}
+	data := make([]byte, len(v.Contents))
+	copy(data, v.Contents)
+	data[6], data[7] = 0, 0
+	if v.Version == 3 {
+		return v.computeChecksum(data, IPProtocolVRRP)
+	}
+	return tcpipChecksum(data, 0), nil
+}
+
 // CanDecode specifies the layer type in which we are attempting to unwrap.
-func (v *VRRPv2) CanDecode() gopacket.LayerClass {
+func (v *VRRP) CanDecode() gopacket.LayerClass {
 	return LayerTypeVRRP
 }
 
 // NextLayerType specifies the next layer that should be decoded. VRRP does not contain any further payload, so we set to 0
-func (v *VRRPv2) NextLayerType() gopacket.LayerType {
+func (v *VRRP) NextLayerType() gopacket.LayerType {
 	return gopacket.LayerTypeZero
 }
 
 // The VRRP packet does not include payload data. Setting byte slice to nil
-func (v *VRRPv2) Payload() []byte {
+func (v *VRRP) Payload() []byte {
 	return nil
 }
 
-// decodeVRRP will parse VRRP v2
+// decodeVRRP will parse VRRP v2 and v3
 func decodeVRRP(data []byte, p gopacket.PacketBuilder) error {
-	if len(data) < 8 {
-		return errors.New("Not a valid VRRP packet. Packet length is too small.")
-	}
-	v := &VRRPv2{}
+	v := &VRRP{}
 	return decodingLayerDecoder(v, data, p)
 }
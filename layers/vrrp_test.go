@@ -6,8 +6,12 @@
 package layers
 
 import (
-	"github.com/google/gopacket"
+	"bytes"
+	"net"
+	"reflect"
 	"testing"
+
+	"github.com/google/gopacket"
 )
 
 // vrrpPacketPriority100 is the packet:
@@ -47,9 +51,103 @@ func TestVRRPPacketPacket0(t *testing.T) {
 	if vrrp.Checksum != 47698 {
 		t.Fatalf("Unable to decode VRRPv2 checksum. Received %d, expected %d", vrrp.Checksum, 47698)
 	}
+
+	if vrrp.AuthType != VRRPv2AuthNoAuth || vrrp.AdverInt != 1 || !reflect.DeepEqual(vrrp.IPAddress, []net.IP{{192, 168, 0, 1}}) {
+		t.Errorf("Unexpected VRRPv2 fields %+v", vrrp)
+	}
+	if csum, err := vrrp.ComputeChecksum(); err != nil || csum != vrrp.Checksum {
+		t.Errorf("VRRPv2 checksum: computed %#x, %v", csum, err)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := vrrp.SerializeTo(buf, gopacket.SerializeOptions{ComputeChecksums: true}); err != nil {
+		t.Fatal(err)
+	}
+	if want := vrrpPacketPriority100[34:54]; !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("VRRPv2 serialization mismatch\nwant %x\ngot  %x", want, buf.Bytes())
+	}
 }
 func BenchmarkDecodeVRRPPacket0(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		gopacket.NewPacket(vrrpPacketPriority100, LayerTypeEthernet, gopacket.NoCopy)
 	}
 }
+
+// vrrpv3PacketIPv4 is a VRRPv3 advertisement over IPv4, with priority 150, a
+// 1s advertisement interval and two virtual addresses.
+var vrrpv3PacketIPv4 = []byte{
+	0x01, 0x00, 0x5e, 0x00, 0x00, 0x12, 0x00, 0x00, 0x5e, 0x00, 0x01, 0x33, 0x08, 0x00, 0x45, 0xc0,
+	0x00, 0x24, 0x00, 0x00, 0x00, 0x00, 0xff, 0x70, 0x19, 0xd1, 0xc0, 0xa8, 0x00, 0x1e, 0xe0, 0x00,
+	0x00, 0x12, 0x31, 0x33, 0x96, 0x02, 0x00, 0x64, 0x15, 0xb8, 0xc0, 0xa8, 0x00, 0x01, 0xc0, 0xa8,
+	0x00, 0x02,
+}
+
+// vrrpv3PacketIPv6 is a VRRPv3 advertisement over IPv6, with priority 200, a
+// 1s advertisement interval and one virtual address.
+var vrrpv3PacketIPv6 = []byte{
+	0x33, 0x33, 0x00, 0x00, 0x00, 0x12, 0x00, 0x00, 0x5e, 0x00, 0x02, 0x33, 0x86, 0xdd, 0x60, 0x00,
+	0x00, 0x00, 0x00, 0x18, 0x70, 0xff, 0xfe, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00,
+	0x5e, 0xff, 0xfe, 0x00, 0x02, 0x33, 0xff, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x12, 0x31, 0x33, 0xc8, 0x01, 0x00, 0x64, 0x79, 0x5b, 0x20, 0x01,
+	0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+}
+
+func TestVRRPv3(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		data     []byte
+		network  gopacket.LayerType
+		priority uint8
+		addrs    []net.IP
+	}{
+		{"IPv4", vrrpv3PacketIPv4, LayerTypeIPv4, 150, []net.IP{{192, 168, 0, 1}, {192, 168, 0, 2}}},
+		{"IPv6", vrrpv3PacketIPv6, LayerTypeIPv6, 200, []net.IP{net.ParseIP("2001:db8::1")}},
+	} {
+		p := gopacket.NewPacket(test.data, LinkTypeEthernet, gopacket.Default)
+		if p.ErrorLayer() != nil {
+			t.Errorf("%s: Failed to decode packet: %v", test.name, p.ErrorLayer().Error())
+			continue
+		}
+		checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, test.network, LayerTypeVRRP}, t)
+		vrrp := p.Layer(LayerTypeVRRP).(*VRRP)
+		if vrrp.Version != 3 || vrrp.VirtualRtrID != 51 || vrrp.Priority != test.priority ||
+			vrrp.MaxAdverInt != 100 || !reflect.DeepEqual(vrrp.IPAddress, test.addrs) {
+			t.Errorf("%s: unexpected fields %+v", test.name, vrrp)
+		}
+
+		if _, err := vrrp.ComputeChecksum(); err == nil {
+			t.Errorf("%s: expected error computing checksum without a network layer", test.name)
+		}
+		vrrp.SetNetworkLayerForChecksum(p.NetworkLayer())
+		if csum, err := vrrp.ComputeChecksum(); err != nil || csum != vrrp.Checksum {
+			t.Errorf("%s: checksum %#x, computed %#x, %v", test.name, vrrp.Checksum, csum, err)
+		}
+
+		// Craft the same advertisement, and check it comes out identical.
+		crafted := &VRRP{
+			Version:      3,
+			Type:         VRRPv2Advertisement,
+			VirtualRtrID: 51,
+			Priority:     test.priority,
+			MaxAdverInt:  100,
+			IPAddress:    test.addrs,
+		}
+		crafted.SetNetworkLayerForChecksum(p.NetworkLayer())
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		if err := crafted.SerializeTo(buf, opts); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !bytes.Equal(buf.Bytes(), vrrp.Contents) {
+			t.Errorf("%s: serialization mismatch\nwant %x\ngot  %x", test.name, vrrp.Contents, buf.Bytes())
+		}
+	}
+}
+
+func TestVRRPTruncated(t *testing.T) {
+	vrrp := &VRRP{}
+	if err := vrrp.DecodeFromBytes(vrrpPacketPriority100[34:50], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("Expected error decoding VRRPv2 without authentication data")
+	}
+	if err := vrrp.DecodeFromBytes(vrrpv3PacketIPv4[34:46], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("Expected error decoding VRRPv3 missing an address")
+	}
+}
@@ -8,6 +8,7 @@ package layers
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/google/gopacket"
 )
@@ -37,30 +38,53 @@ type VXLAN struct {
 // LayerType returns LayerTypeVXLAN
 func (vx *VXLAN) LayerType() gopacket.LayerType { return LayerTypeVXLAN }
 
-func decodeVXLAN(data []byte, p gopacket.PacketBuilder) error {
-	vx := &VXLAN{}
+// validVXLAN reports whether data looks like a VXLAN header, ie. it's long
+// enough and has the 'I' flag set as RFC 7348 requires.
+func validVXLAN(data []byte) bool {
+	return len(data) >= 8 && data[0]&0x08 != 0
+}
 
-	// VNI is a 24bit number, Uint32 requires 32 bits
-	var buf [4]byte
-	copy(buf[1:], data[4:7])
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (vx *VXLAN) CanDecode() gopacket.LayerClass { return LayerTypeVXLAN }
+
+// NextLayerType returns LayerTypeEthernet, the encapsulated frame.
+func (vx *VXLAN) NextLayerType() gopacket.LayerType { return LayerTypeEthernet }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (vx *VXLAN) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	const vxlanLength = 8
+	if len(data) < vxlanLength {
+		df.SetTruncated()
+		return errors.New("VXLAN header too short")
+	}
 
 	// RFC 7348 https://tools.ietf.org/html/rfc7348
-	vx.ValidIDFlag = data[0]&0x08 > 0        // 'I' bit per RFC7348
-	vx.VNI = binary.BigEndian.Uint32(buf[:]) // VXLAN Network Identifier per RFC7348
+	vx.ValidIDFlag = data[0]&0x08 > 0 // 'I' bit per RFC7348
+	if !vx.ValidIDFlag {
+		return errors.New("VXLAN header without the I flag")
+	}
+	// VNI is a 24bit number, Uint32 requires 32 bits
+	vx.VNI = binary.BigEndian.Uint32(data[4:8]) >> 8 // VXLAN Network Identifier per RFC7348
 
 	// Group Based Policy https://tools.ietf.org/html/draft-smith-vxlan-group-policy-00
-	vx.GBPExtension = data[0]&0x80 > 0                       // 'G' bit per the group policy draft
-	vx.GBPDontLearn = data[1]&0x40 > 0                       // 'D' bit - the egress VTEP MUST NOT learn the source address of the encapsulated frame.
-	vx.GBPApplied = data[1]&0x80 > 0                         // 'A' bit - indicates that the group policy has already been applied to this packet.
-	vx.GBPGroupPolicyID = binary.BigEndian.Uint16(data[2:4]) // Policy ID as per the group policy draft
+	vx.GBPExtension = data[0]&0x80 > 0 // 'G' bit per the group policy draft
+	if vx.GBPExtension {
+		vx.GBPDontLearn = data[1]&0x40 > 0                       // 'D' bit - the egress VTEP MUST NOT learn the source address of the encapsulated frame.
+		vx.GBPApplied = data[1]&0x80 > 0                         // 'A' bit - indicates that the group policy has already been applied to this packet.
+		vx.GBPGroupPolicyID = binary.BigEndian.Uint16(data[2:4]) // Policy ID as per the group policy draft
+	} else {
+		vx.GBPDontLearn, vx.GBPApplied, vx.GBPGroupPolicyID = false, false, 0
+	}
 
 	// Layer information
-	const vxlanLength = 8
 	vx.Contents = data[:vxlanLength]
 	vx.Payload = data[vxlanLength:]
+	return nil
+}
 
-	p.AddLayer(vx)
-	return p.NextDecoder(LinkTypeEthernet)
+func decodeVXLAN(data []byte, p gopacket.PacketBuilder) error {
+	vx := &VXLAN{}
+	return decodingLayerDecoder(vx, data, p)
 }
 
 // SerializeTo writes the serialized form of this layer into the
@@ -106,3 +106,53 @@ func TestIsomorphicPacketVXLAN(t *testing.T) {
 		t.Errorf("VXLAN isomorph mismatch, \nwant %#v\ngot %#v\n", vx, vxTranslated)
 	}
 }
+
+func TestPacketVXLANInvalidFlags(t *testing.T) {
+	data := append([]byte(nil), testPacketVXLAN...)
+	data[42] &^= 0x08 // clear the 'I' flag
+	p := gopacket.NewPacket(data, LinkTypeEthernet, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+}
+
+func TestPacketVXLANPortMapping(t *testing.T) {
+	defer RegisterUDPPortLayerType(4789, LayerTypeVXLAN)
+	UnregisterUDPPortLayerType(4789)
+	p := gopacket.NewPacket(testPacketVXLAN, LinkTypeEthernet, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, gopacket.LayerTypePayload}, t)
+
+	// The source port of the test packet is 45149.
+	defer UnregisterUDPPortLayerType(45149)
+	RegisterUDPPortLayerType(45149, LayerTypeVXLAN)
+	if got := UDPPortsForLayerType(LayerTypeVXLAN); !reflect.DeepEqual(got, []UDPPort{45149}) {
+		t.Errorf("UDPPortsForLayerType(VXLAN) = %v, want [45149]", got)
+	}
+	p = gopacket.NewPacket(testPacketVXLAN, LinkTypeEthernet, gopacket.Default)
+	checkLayers(p, []gopacket.LayerType{LayerTypeEthernet, LayerTypeIPv4, LayerTypeUDP, LayerTypeVXLAN, LayerTypeEthernet, LayerTypeIPv4, LayerTypeICMPv4, gopacket.LayerTypePayload}, t)
+}
+
+func TestDecodeVXLANErrors(t *testing.T) {
+	var vx VXLAN
+	if err := vx.DecodeFromBytes([]byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error for short header")
+	}
+	if err := vx.DecodeFromBytes([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("expected error for header without the I flag")
+	}
+
+	// The group policy fields are only meaningful with the G flag.
+	if err := vx.DecodeFromBytes([]byte{0x08, 0xc0, 0x03, 0x09, 0x00, 0x00, 0x01, 0x00}, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if vx.VNI != 1 || vx.GBPExtension || vx.GBPApplied || vx.GBPDontLearn || vx.GBPGroupPolicyID != 0 {
+		t.Errorf("got %+v", vx)
+	}
+	if err := vx.DecodeFromBytes([]byte{0x88, 0xc0, 0x03, 0x09, 0x00, 0x00, 0x01, 0x00}, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal(err)
+	}
+	if !vx.GBPExtension || !vx.GBPApplied || !vx.GBPDontLearn || vx.GBPGroupPolicyID != 777 {
+		t.Errorf("got %+v", vx)
+	}
+}
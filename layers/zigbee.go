@@ -0,0 +1,288 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/gopacket"
+)
+
+// ZigbeeNWKFrameType is the frame type from the Zigbee network layer frame
+// control field.
+type ZigbeeNWKFrameType uint8
+
+const (
+	ZigbeeNWKFrameTypeData     ZigbeeNWKFrameType = 0
+	ZigbeeNWKFrameTypeCommand  ZigbeeNWKFrameType = 1
+	ZigbeeNWKFrameTypeInterPAN ZigbeeNWKFrameType = 3
+)
+
+func (t ZigbeeNWKFrameType) String() string {
+	switch t {
+	case ZigbeeNWKFrameTypeData:
+		return "Data"
+	case ZigbeeNWKFrameTypeCommand:
+		return "Command"
+	case ZigbeeNWKFrameTypeInterPAN:
+		return "InterPAN"
+	default:
+		return "Reserved"
+	}
+}
+
+// ZigbeeNWKMulticastMode is the multicast mode from the Zigbee network layer
+// multicast control field.
+type ZigbeeNWKMulticastMode uint8
+
+const (
+	ZigbeeNWKMulticastModeNonMember ZigbeeNWKMulticastMode = 0
+	ZigbeeNWKMulticastModeMember    ZigbeeNWKMulticastMode = 1
+)
+
+// ZigbeeNWKMulticastControl is the multicast control field present in Zigbee
+// network frames with the multicast flag set.
+type ZigbeeNWKMulticastControl struct {
+	Mode               ZigbeeNWKMulticastMode
+	NonMemberRadius    uint8
+	MaxNonMemberRadius uint8
+}
+
+// ZigbeeNWKSourceRoute is the source route subframe present in Zigbee
+// network frames with the source route flag set.
+type ZigbeeNWKSourceRoute struct {
+	RelayIndex uint8
+	Relays     []uint16
+}
+
+// ZigbeeSecurityHeader is the auxiliary security header of a secured Zigbee
+// network frame.
+//
+// The security level transmitted over the air is normally 0, and receivers
+// substitute the network's nwkSecurityLevel, which the Zigbee specification
+// fixes at 5 (ENC-MIC-32).  MICLength accounts for this.
+type ZigbeeSecurityHeader struct {
+	SecurityLevel uint8
+	KeyIdentifier ZigbeeKeyIdentifier
+	ExtendedNonce bool
+	FrameCounter  uint32
+	// SourceAddress is only present if ExtendedNonce is set.
+	SourceAddress uint64
+	// KeySequenceNumber is only present if KeyIdentifier is
+	// ZigbeeKeyIdentifierNetwork.
+	KeySequenceNumber uint8
+}
+
+// ZigbeeKeyIdentifier identifies the type of key used to secure a Zigbee
+// frame.
+type ZigbeeKeyIdentifier uint8
+
+const (
+	ZigbeeKeyIdentifierData         ZigbeeKeyIdentifier = 0
+	ZigbeeKeyIdentifierNetwork      ZigbeeKeyIdentifier = 1
+	ZigbeeKeyIdentifierKeyTransport ZigbeeKeyIdentifier = 2
+	ZigbeeKeyIdentifierKeyLoad      ZigbeeKeyIdentifier = 3
+)
+
+func (k ZigbeeKeyIdentifier) String() string {
+	switch k {
+	case ZigbeeKeyIdentifierData:
+		return "Data"
+	case ZigbeeKeyIdentifierNetwork:
+		return "Network"
+	case ZigbeeKeyIdentifierKeyTransport:
+		return "KeyTransport"
+	case ZigbeeKeyIdentifierKeyLoad:
+		return "KeyLoad"
+	default:
+		return "Unknown"
+	}
+}
+
+// MICLength returns the length of the message integrity code that's appended
+// to the frame's payload.
+func (h *ZigbeeSecurityHeader) MICLength() int {
+	level := h.SecurityLevel
+	if level == 0 {
+		level = 5
+	}
+	return Dot15d4SecurityLevel(level).MICLength()
+}
+
+// ZigbeeNWK is the Zigbee network layer header, carried in IEEE 802.15.4 data
+// frames.  Secured frames are not decrypted; their payload is left encrypted
+// and Encrypted is set.
+type ZigbeeNWK struct {
+	BaseLayer
+	FrameType          ZigbeeNWKFrameType
+	ProtocolVersion    uint8
+	DiscoverRoute      uint8
+	Multicast          bool
+	Security           bool
+	SourceRouteFlag    bool
+	DstIEEEAddrPresent bool
+	SrcIEEEAddrPresent bool
+	EndDeviceInitiator bool
+	DstAddr            uint16
+	SrcAddr            uint16
+	Radius             uint8
+	SequenceNumber     uint8
+	// DstIEEEAddr and SrcIEEEAddr are only valid if the corresponding
+	// present flag is set.
+	DstIEEEAddr      uint64
+	SrcIEEEAddr      uint64
+	MulticastControl *ZigbeeNWKMulticastControl
+	SourceRoute      *ZigbeeNWKSourceRoute
+	SecurityHeader   *ZigbeeSecurityHeader
+	// Encrypted is true if the payload is encrypted.
+	Encrypted bool
+	// MIC is the message integrity code trailing the payload of secured
+	// frames.
+	MIC []byte
+}
+
+// LayerType returns LayerTypeZigbeeNWK.
+func (z *ZigbeeNWK) LayerType() gopacket.LayerType { return LayerTypeZigbeeNWK }
+
+// CanDecode returns the set of layer types that this DecodingLayer can decode.
+func (z *ZigbeeNWK) CanDecode() gopacket.LayerClass { return LayerTypeZigbeeNWK }
+
+// NextLayerType returns the layer type contained by this DecodingLayer.
+func (z *ZigbeeNWK) NextLayerType() gopacket.LayerType { return gopacket.LayerTypePayload }
+
+// DecodeFromBytes decodes the given bytes into this layer.
+func (z *ZigbeeNWK) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	truncated := func(need int) error {
+		df.SetTruncated()
+		return fmt.Errorf("ZigbeeNWK length %d too short, %d required", len(data), need)
+	}
+	if len(data) < 8 {
+		return truncated(8)
+	}
+	fc := binary.LittleEndian.Uint16(data[0:2])
+	z.FrameType = ZigbeeNWKFrameType(fc & 0x3)
+	z.ProtocolVersion = uint8(fc >> 2 & 0xf)
+	z.DiscoverRoute = uint8(fc >> 6 & 0x3)
+	z.Multicast = fc&0x0100 != 0
+	z.Security = fc&0x0200 != 0
+	z.SourceRouteFlag = fc&0x0400 != 0
+	z.DstIEEEAddrPresent = fc&0x0800 != 0
+	z.SrcIEEEAddrPresent = fc&0x1000 != 0
+	z.EndDeviceInitiator = fc&0x2000 != 0
+	z.DstAddr = binary.LittleEndian.Uint16(data[2:4])
+	z.SrcAddr = binary.LittleEndian.Uint16(data[4:6])
+	z.Radius = data[6]
+	z.SequenceNumber = data[7]
+	offset := 8
+
+	z.DstIEEEAddr, z.SrcIEEEAddr = 0, 0
+	if z.DstIEEEAddrPresent {
+		if len(data) < offset+8 {
+			return truncated(offset + 8)
+		}
+		z.DstIEEEAddr = binary.LittleEndian.Uint64(data[offset : offset+8])
+		offset += 8
+	}
+	if z.SrcIEEEAddrPresent {
+		if len(data) < offset+8 {
+			return truncated(offset + 8)
+		}
+		z.SrcIEEEAddr = binary.LittleEndian.Uint64(data[offset : offset+8])
+		offset += 8
+	}
+
+	z.MulticastControl = nil
+	if z.Multicast {
+		if len(data) < offset+1 {
+			return truncated(offset + 1)
+		}
+		z.MulticastControl = &ZigbeeNWKMulticastControl{
+			Mode:               ZigbeeNWKMulticastMode(data[offset] & 0x3),
+			NonMemberRadius:    data[offset] >> 2 & 0x7,
+			MaxNonMemberRadius: data[offset] >> 5,
+		}
+		offset++
+	}
+
+	z.SourceRoute = nil
+	if z.SourceRouteFlag {
+		if len(data) < offset+2 {
+			return truncated(offset + 2)
+		}
+		count := int(data[offset])
+		sr := &ZigbeeNWKSourceRoute{RelayIndex: data[offset+1]}
+		offset += 2
+		if len(data) < offset+2*count {
+			return truncated(offset + 2*count)
+		}
+		for i := 0; i < count; i++ {
+			sr.Relays = append(sr.Relays, binary.LittleEndian.Uint16(data[offset:offset+2]))
+			offset += 2
+		}
+		z.SourceRoute = sr
+	}
+
+	z.SecurityHeader = nil
+	z.Encrypted = false
+	z.MIC = nil
+	end := len(data)
+	if z.Security {
+		if len(data) < offset+5 {
+			return truncated(offset + 5)
+		}
+		h := &ZigbeeSecurityHeader{
+			SecurityLevel: data[offset] & 0x7,
+			KeyIdentifier: ZigbeeKeyIdentifier(data[offset] >> 3 & 0x3),
+			ExtendedNonce: data[offset]&0x20 != 0,
+			FrameCounter:  binary.LittleEndian.Uint32(data[offset+1 : offset+5]),
+		}
+		offset += 5
+		if h.ExtendedNonce {
+			if len(data) < offset+8 {
+				return truncated(offset + 8)
+			}
+			h.SourceAddress = binary.LittleEndian.Uint64(data[offset : offset+8])
+			offset += 8
+		}
+		if h.KeyIdentifier == ZigbeeKeyIdentifierNetwork {
+			if len(data) < offset+1 {
+				return truncated(offset + 1)
+			}
+			h.KeySequenceNumber = data[offset]
+			offset++
+		}
+		micLen := h.MICLength()
+		if len(data) < offset+micLen {
+			return truncated(offset + micLen)
+		}
+		end -= micLen
+		z.MIC = data[end:]
+		z.SecurityHeader = h
+		// Levels 1 to 3 only authenticate the payload.
+		z.Encrypted = h.SecurityLevel == 0 || Dot15d4SecurityLevel(h.SecurityLevel).Encrypted()
+	}
+
+	z.BaseLayer = BaseLayer{Contents: data[:offset], Payload: data[offset:end]}
+	return nil
+}
+
+// isZigbeeNWK reports whether an IEEE 802.15.4 data frame's payload looks
+// like a Zigbee network frame.
+func isZigbeeNWK(data []byte) bool {
+	if len(data) < 8 {
+		return false
+	}
+	version := data[0] >> 2 & 0xf
+	frameType := ZigbeeNWKFrameType(data[0] & 0x3)
+	return (version == 1 || version == 2) && (frameType == ZigbeeNWKFrameTypeData || frameType == ZigbeeNWKFrameTypeCommand)
+}
+
+func decodeZigbeeNWK(data []byte, p gopacket.PacketBuilder) error {
+	z := &ZigbeeNWK{}
+	return decodingLayerDecoder(z, data, p)
+}
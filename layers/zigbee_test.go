@@ -0,0 +1,98 @@
+// Copyright 2018 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package layers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gopacket"
+)
+
+// testPacketZigbeeSecured is an IEEE 802.15.4 broadcast data frame, without
+// FCS, carrying a Zigbee PRO network frame secured with the network key and
+// an extended nonce.
+var testPacketZigbeeSecured = []byte{
+	0x41, 0x88, 0x01, 0xcd, 0xab, 0xff, 0xff, 0x00, 0x00, 0x08, 0x02, 0xfd, 0xff, 0x00, 0x00, 0x1e,
+	0x5a, 0x28, 0x01, 0x00, 0x00, 0x00, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, 0x00, 0xaa,
+	0xbb, 0xcc, 0x11, 0x22, 0x33, 0x44,
+}
+
+func TestPacketZigbeeSecured(t *testing.T) {
+	p := gopacket.NewPacket(testPacketZigbeeSecured, LinkTypeIEEE802_15_4NoFCS, gopacket.Default)
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	checkLayers(p, []gopacket.LayerType{LayerTypeDot15d4, LayerTypeZigbeeNWK, gopacket.LayerTypePayload}, t)
+	got, ok := p.Layer(LayerTypeZigbeeNWK).(*ZigbeeNWK)
+	if !ok {
+		t.Fatal("No ZigbeeNWK layer")
+	}
+	nwk := testPacketZigbeeSecured[9:]
+	want := &ZigbeeNWK{
+		BaseLayer:       BaseLayer{nwk[:22], nwk[22:25]},
+		FrameType:       ZigbeeNWKFrameTypeData,
+		ProtocolVersion: 2,
+		Security:        true,
+		DstAddr:         0xfffd,
+		SrcAddr:         0x0000,
+		Radius:          30,
+		SequenceNumber:  0x5a,
+		SecurityHeader: &ZigbeeSecurityHeader{
+			KeyIdentifier: ZigbeeKeyIdentifierNetwork,
+			ExtendedNonce: true,
+			FrameCounter:  1,
+			SourceAddress: 0x0102030405060708,
+		},
+		Encrypted: true,
+		MIC:       []byte{0x11, 0x22, 0x33, 0x44},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("ZigbeeNWK layer mismatch, \nwant %#v\ngot  %#v\n", want, got)
+	}
+}
+
+func TestZigbeeNWKOptionalFields(t *testing.T) {
+	data := []byte{
+		0x09, 0x1d, 0x34, 0x12, 0x78, 0x56, 0x05, 0x07,
+		0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, // destination IEEE address
+		0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, // source IEEE address
+		0xe5,                               // multicast control
+		0x02, 0x01, 0x01, 0x00, 0x02, 0x00, // source route
+		0x01,
+	}
+	var z ZigbeeNWK
+	if err := z.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatal("Failed to decode:", err)
+	}
+	if z.FrameType != ZigbeeNWKFrameTypeCommand || z.DstAddr != 0x1234 || z.SrcAddr != 0x5678 || z.Radius != 5 || z.SequenceNumber != 7 {
+		t.Errorf("Unexpected header %#v", z)
+	}
+	if z.DstIEEEAddr != 0x1817161514131211 || z.SrcIEEEAddr != 0x2827262524232221 {
+		t.Errorf("Unexpected IEEE addresses %#x, %#x", z.DstIEEEAddr, z.SrcIEEEAddr)
+	}
+	wantMC := &ZigbeeNWKMulticastControl{Mode: ZigbeeNWKMulticastModeMember, NonMemberRadius: 1, MaxNonMemberRadius: 7}
+	if !reflect.DeepEqual(wantMC, z.MulticastControl) {
+		t.Errorf("Multicast control mismatch, \nwant %#v\ngot  %#v\n", wantMC, z.MulticastControl)
+	}
+	wantSR := &ZigbeeNWKSourceRoute{RelayIndex: 1, Relays: []uint16{1, 2}}
+	if !reflect.DeepEqual(wantSR, z.SourceRoute) {
+		t.Errorf("Source route mismatch, \nwant %#v\ngot  %#v\n", wantSR, z.SourceRoute)
+	}
+	if z.Encrypted || !reflect.DeepEqual([]byte{0x01}, z.Payload) {
+		t.Errorf("Unexpected payload %x", z.Payload)
+	}
+	if err := z.DecodeFromBytes(data[:30], gopacket.NilDecodeFeedback); err == nil {
+		t.Error("Expected an error decoding a truncated source route")
+	}
+}
+
+func BenchmarkDecodePacketZigbee(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		gopacket.NewPacket(testPacketZigbeeSecured, LinkTypeIEEE802_15_4NoFCS, gopacket.NoCopy)
+	}
+}
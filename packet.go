@@ -115,6 +115,8 @@ type packet struct {
 	metadata PacketMetadata
 
 	decodeOptions DecodeOptions
+	// decoder decodes the first layer of data, and is kept for CopyPacket.
+	decoder Decoder
 
 	// Pointers to the various important layers
 	link        LinkLayer
@@ -657,7 +659,7 @@ func NewPacket(data []byte, firstLayerDecoder Decoder, options DecodeOptions) Pa
 	}
 	if options.Lazy {
 		p := &lazyPacket{
-			packet: packet{data: data, decodeOptions: options},
+			packet: packet{data: data, decodeOptions: options, decoder: firstLayerDecoder},
 			next:   firstLayerDecoder,
 		}
 		p.layers = p.initialLayers[:0]
@@ -674,13 +676,53 @@ func NewPacket(data []byte, firstLayerDecoder Decoder, options DecodeOptions) Pa
 		return p
 	}
 	p := &eagerPacket{
-		packet: packet{data: data, decodeOptions: options},
+		packet: packet{data: data, decodeOptions: options, decoder: firstLayerDecoder},
 	}
 	p.layers = p.initialLayers[:0]
 	p.initialDecode(firstLayerDecoder)
 	return p
 }
 
+// NewCapturedPacket creates a new Packet object from a set of bytes read
+// from a capture, as NewPacket does, and sets its metadata from ci.  It's
+// useful for turning packet data handled with a DecodingLayerParser into a
+// Packet, for example to keep it.
+func NewCapturedPacket(data []byte, ci CaptureInfo, firstLayerDecoder Decoder, options DecodeOptions) Packet {
+	packet := NewPacket(data, firstLayerDecoder, options)
+	m := packet.Metadata()
+	m.CaptureInfo = ci
+	m.Truncated = m.Truncated || ci.CaptureLength < ci.Length
+	return packet
+}
+
+// CopyPacket returns a deep copy of p, which owns its data and metadata and
+// so remains valid however the bytes p was decoded from change, as is
+// needed to keep packets decoded with NoCopy or from zero-copy sources.
+//
+// The copy is decoded again from its data, with the same decoder and
+// options as p (if p was created by NewPacket) except NoCopy.  Use a Lazy p
+// to only pay for decoding the layers that are accessed.
+func CopyPacket(p Packet) Packet {
+	decoder, options := Decoder(nil), Default
+	if pp, ok := p.(interface{ basePacket() *packet }); ok {
+		decoder, options = pp.basePacket().decoder, pp.basePacket().decodeOptions
+	} else if layers := p.Layers(); len(layers) > 0 {
+		decoder = layers[0].LayerType()
+	}
+	if decoder == nil {
+		decoder = LayerTypePayload
+	}
+	options.NoCopy = false
+	m := p.Metadata()
+	ci := m.CaptureInfo
+	ci.AncillaryData = append([]interface{}(nil), ci.AncillaryData...)
+	packet := NewCapturedPacket(p.Data(), ci, decoder, options)
+	packet.Metadata().Truncated = packet.Metadata().Truncated || m.Truncated
+	return packet
+}
+
+func (p *packet) basePacket() *packet { return p }
+
 // PacketDataSource is an interface for some source of packet data.  Users may
 // create their own implementations, or use the existing implementations in
 // gopacket/pcap (libpcap, allows reading from live interfaces or from
@@ -800,11 +842,7 @@ func (p *PacketSource) NextPacket() (Packet, error) {
 	if err != nil {
 		return nil, err
 	}
-	packet := NewPacket(data, p.decoder, p.DecodeOptions)
-	m := packet.Metadata()
-	m.CaptureInfo = ci
-	m.Truncated = m.Truncated || ci.CaptureLength < ci.Length
-	return packet, nil
+	return NewCapturedPacket(data, ci, p.decoder, p.DecodeOptions), nil
 }
 
 // packetsToChannel reads in all packets from the packet source and sends them
@@ -60,3 +60,24 @@ func TestConcatPacketSources(t *testing.T) {
 		t.Errorf("expected io.EOF, got %v", err)
 	}
 }
+
+func TestCopyPacket(t *testing.T) {
+	for _, opts := range []DecodeOptions{NoCopy, {NoCopy: true, Lazy: true}} {
+		data := []byte{1, 2, 3, 4}
+		p := NewCapturedPacket(data, CaptureInfo{CaptureLength: 4, Length: 10}, DecodePayload, opts)
+		c := CopyPacket(p)
+		data[0] = 9
+		if p.Data()[0] != 9 {
+			t.Fatalf("%+v: NoCopy packet doesn't share its data", opts)
+		}
+		if got := c.ApplicationLayer().Payload(); !reflect.DeepEqual(got, []byte{1, 2, 3, 4}) {
+			t.Errorf("%+v: copied payload %v", opts, got)
+		}
+		if m := c.Metadata(); m.Length != 10 || !m.Truncated {
+			t.Errorf("%+v: copied metadata %+v", opts, m)
+		}
+		if _, lazy := c.(*lazyPacket); lazy != opts.Lazy {
+			t.Errorf("%+v: copy lazy %v", opts, lazy)
+		}
+	}
+}
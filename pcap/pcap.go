@@ -19,6 +19,7 @@ package pcap
 #cgo windows,386 LDFLAGS: -L C:/WpdPack/Lib -lwpcap
 #cgo windows,amd64 LDFLAGS: -L C:/WpdPack/Lib/x64 -lwpcap
 #include <stdlib.h>
+#include <string.h>
 #include <pcap.h>
 #include <stdint.h>
 
@@ -53,6 +54,10 @@ int pcap_tstamp_type_name_to_val(const char* t) {
 #endif  // < v1.2
 #endif  // < v1.5
 
+#ifndef PCAP_ERROR_BREAK
+#define PCAP_ERROR_BREAK -2
+#endif
+
 #ifndef PCAP_ERROR_PROMISC_PERM_DENIED
 #define PCAP_ERROR_PROMISC_PERM_DENIED -11
 #endif
@@ -112,10 +117,94 @@ int pcap_next_ex_escaping(pcap_t *p, uintptr_t pkt_hdr, uintptr_t pkt_data) {
   }
   return ex;
 }
+
+// gopacket_batch holds the packets copied out of a single pcap_dispatch call,
+// so that reading a whole batch only crosses from Go into C once.
+typedef struct {
+  pcap_t *p;
+  u_char *buf;
+  int buflen;
+  int used;
+  int snaplen;
+  struct pcap_pkthdr *hdrs;
+  int *offsets;
+  int max;
+  int count;
+} gopacket_batch;
+
+gopacket_batch *gopacket_batch_alloc(int max, int buflen, int snaplen) {
+  gopacket_batch *b = calloc(1, sizeof(gopacket_batch));
+  if (b == NULL) {
+    return NULL;
+  }
+  b->buf = malloc(buflen);
+  b->hdrs = calloc(max, sizeof(struct pcap_pkthdr));
+  b->offsets = calloc(max, sizeof(int));
+  if (b->buf == NULL || b->hdrs == NULL || b->offsets == NULL) {
+    free(b->buf);
+    free(b->hdrs);
+    free(b->offsets);
+    free(b);
+    return NULL;
+  }
+  b->buflen = buflen;
+  b->snaplen = snaplen;
+  b->max = max;
+  return b;
+}
+
+void gopacket_batch_free(gopacket_batch *b) {
+  free(b->buf);
+  free(b->hdrs);
+  free(b->offsets);
+  free(b);
+}
+
+static void gopacket_batch_handler(u_char *user, const struct pcap_pkthdr *h, const u_char *bytes) {
+  gopacket_batch *b = (gopacket_batch *)user;
+  int caplen = h->caplen;
+  if (b->count >= b->max) {
+    return;
+  }
+  // Packets never exceed the snapshot length, and we stop the batch before
+  // less than that is left, so this only truncates broken savefiles.
+  if (caplen > b->buflen - b->used) {
+    caplen = b->buflen - b->used;
+  }
+  memcpy(b->buf + b->used, bytes, caplen);
+  b->hdrs[b->count] = *h;
+  b->hdrs[b->count].caplen = caplen;
+  b->offsets[b->count] = b->used;
+  b->used += caplen;
+  b->count++;
+  if (b->count < b->max && b->buflen - b->used < b->snaplen) {
+    pcap_breakloop(b->p);
+  }
+}
+
+// pcap_dispatch_batch fills b with up to b->max packets, returning how many
+// it read or a negative pcap error.
+int pcap_dispatch_batch(pcap_t *p, gopacket_batch *b) {
+  int n;
+  b->p = p;
+  b->used = 0;
+  b->count = 0;
+  n = pcap_dispatch(p, b->max, gopacket_batch_handler, (u_char *)b);
+  if (n == PCAP_ERROR_BREAK && b->count == 0) {
+    // A break requested at the very end of the previous batch may only be
+    // noticed now, so try again.
+    n = pcap_dispatch(p, b->max, gopacket_batch_handler, (u_char *)b);
+  }
+  if (n < 0 && n != PCAP_ERROR_BREAK) {
+    return n;
+  }
+  return b->count;
+}
 */
 import "C"
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -124,6 +213,7 @@ import (
 	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -166,12 +256,20 @@ type Handle struct {
 	mu          sync.Mutex
 	closeMu     sync.Mutex
 
+	// npcapLoopback is set for Npcap's loopback adapter.
+	npcapLoopback bool
+
 	// Since pointers to these objects are passed into a C function, if
 	// they're declared locally then the Go compiler thinks they may have
 	// escaped into C-land, so it allocates them on the heap.  This causes a
 	// huge memory hit, so to handle that we store them here instead.
 	pkthdr *C.struct_pcap_pkthdr
 	bufptr *C.u_char
+
+	// batch holds the packets read by ReadPacketDataBatch, and batchNext
+	// the index of the first one not yet passed to the caller.
+	batch     *C.gopacket_batch
+	batchNext int
 }
 
 // Stats contains statistics on how many packets were handled by a pcap handle,
@@ -188,6 +286,9 @@ type Interface struct {
 	Description string
 	Flags       uint32
 	Addresses   []InterfaceAddress
+	// IsLoopback is set for loopback interfaces, including Npcap's loopback
+	// adapter on Windows.
+	IsLoopback bool
 }
 
 // Datalink describes the datalink
@@ -250,7 +351,7 @@ func OpenLive(device string, snaplen int32, promisc bool, timeout time.Duration)
 	if promisc {
 		pro = 1
 	}
-	p := &Handle{timeout: timeout, device: device}
+	p := &Handle{timeout: timeout, device: device, npcapLoopback: isNpcapLoopback(device)}
 
 	ifc, err := net.InterfaceByName(device)
 	if err != nil {
@@ -467,6 +568,122 @@ func (p *Handle) ZeroCopyReadPacketData() (data []byte, ci gopacket.CaptureInfo,
 	return
 }
 
+// maxBatchBufferSize bounds the buffer ReadPacketDataBatch copies packets
+// into.  Batches of large packets that don't fit end early instead.
+const maxBatchBufferSize = 8 << 20
+
+// ReadPacketDataBatch reads up to max packets with a single pcap_dispatch call
+// and calls fn for each of them in turn, so the cost of calling into libpcap
+// is paid once per batch rather than once per packet.
+//
+// The data passed to fn points into a buffer owned by the Handle, and is only
+// valid until fn returns; copy it to keep it.  If fn returns false, the rest
+// of the batch is kept and passed to fn by the next call.  fn must not read
+// from the handle itself.
+//
+// ReadPacketDataBatch blocks and times out like ReadPacketData, returning
+// NextErrorTimeoutExpired if no packet arrived within the handle's timeout,
+// and io.EOF once a file has been read completely.
+func (p *Handle) ReadPacketDataBatch(max int, fn func(data []byte, ci gopacket.CaptureInfo) bool) (err error) {
+	if max <= 0 {
+		return errors.New("batch size must be positive")
+	}
+	p.mu.Lock()
+	if err = p.fillBatchLocked(max); err == nil {
+		p.deliverBatchLocked(fn)
+	}
+	p.mu.Unlock()
+	if err == NextErrorTimeoutExpired {
+		runtime.Gosched()
+	}
+	return
+}
+
+// fillBatchLocked reads the next batch of packets into p.batch, unless some
+// packets of the previous one are still left.
+func (p *Handle) fillBatchLocked(max int) error {
+	if p.cptr == nil {
+		return io.EOF
+	}
+	if p.batch != nil && p.batchNext < int(p.batch.count) {
+		return nil
+	}
+	if p.batch == nil || int(p.batch.max) != max {
+		if p.batch != nil {
+			C.gopacket_batch_free(p.batch)
+			p.batch = nil
+		}
+		snaplen := int(C.pcap_snapshot(p.cptr))
+		if snaplen <= 0 {
+			snaplen = 65535
+		}
+		buflen := max * snaplen
+		if buflen > maxBatchBufferSize || buflen/snaplen != max {
+			buflen = maxBatchBufferSize
+		}
+		if buflen < snaplen {
+			buflen = snaplen
+		}
+		p.batch = C.gopacket_batch_alloc(C.int(max), C.int(buflen), C.int(snaplen))
+		if p.batch == nil {
+			return errors.New("unable to allocate packet batch")
+		}
+	}
+	p.batchNext = 0
+
+	// set after we have call waitForPacket for the first time
+	var waited bool
+
+	for atomic.LoadUint64(&p.stop) == 0 {
+		n := int(C.pcap_dispatch_batch(p.cptr, p.batch))
+		switch {
+		case n > 0:
+			return nil
+		case n < 0:
+			return NextError(n)
+		case C.pcap_file(p.cptr) != nil:
+			// pcap_dispatch reads nothing only at the end of a file.
+			return io.EOF
+		case waited && p.timeout > 0:
+			return NextErrorTimeoutExpired
+		default:
+			p.waitForPacket()
+			waited = true
+		}
+	}
+
+	// stop must be set
+	return io.EOF
+}
+
+// deliverBatchLocked passes the packets left in p.batch to fn.
+func (p *Handle) deliverBatchLocked(fn func(data []byte, ci gopacket.CaptureInfo) bool) {
+	b := p.batch
+	hdrSize := unsafe.Sizeof(*b.hdrs)
+	offSize := unsafe.Sizeof(*b.offsets)
+	for p.batchNext < int(b.count) {
+		i := uintptr(p.batchNext)
+		p.batchNext++
+		hdr := (*C.struct_pcap_pkthdr)(unsafe.Pointer(uintptr(unsafe.Pointer(b.hdrs)) + i*hdrSize))
+		off := *(*C.int)(unsafe.Pointer(uintptr(unsafe.Pointer(b.offsets)) + i*offSize))
+
+		ci := gopacket.CaptureInfo{
+			Timestamp:      time.Unix(int64(hdr.ts.tv_sec), int64(hdr.ts.tv_usec)*1000),
+			CaptureLength:  int(hdr.caplen),
+			Length:         int(hdr.len),
+			InterfaceIndex: p.deviceIndex,
+		}
+		var data []byte
+		slice := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+		slice.Data = uintptr(unsafe.Pointer(b.buf)) + uintptr(off)
+		slice.Len = ci.CaptureLength
+		slice.Cap = ci.CaptureLength
+		if !fn(data, ci) {
+			return
+		}
+	}
+}
+
 // Close closes the underlying pcap handle.
 func (p *Handle) Close() {
 	p.closeMu.Lock()
@@ -484,6 +701,10 @@ func (p *Handle) Close() {
 
 	C.pcap_close(p.cptr)
 	p.cptr = nil
+	if p.batch != nil {
+		C.gopacket_batch_free(p.batch)
+		p.batch = nil
+	}
 }
 
 // Error returns the current error associated with a pcap handle (pcap_geterr).
@@ -768,9 +989,25 @@ func Version() string {
 	return C.GoString(C.pcap_lib_version())
 }
 
+// npcapLoopbackDevice is the name of Npcap's loopback adapter on Windows.
+const npcapLoopbackDevice = `\Device\NPF_Loopback`
+
+// isNpcapLoopback returns whether device names Npcap's loopback adapter.
+func isNpcapLoopback(device string) bool {
+	return strings.EqualFold(device, npcapLoopbackDevice)
+}
+
 // LinkType returns pcap_datalink, as a layers.LinkType.
+//
+// Npcap's loopback adapter frames packets with a DLT_NULL header, but older
+// Npcap versions report it as Ethernet; LinkTypeNull is returned for it
+// regardless, so packets decode as Loopback.
 func (p *Handle) LinkType() layers.LinkType {
-	return layers.LinkType(C.pcap_datalink(p.cptr))
+	lt := layers.LinkType(C.pcap_datalink(p.cptr))
+	if p.npcapLoopback && lt == layers.LinkTypeEthernet {
+		return layers.LinkTypeNull
+	}
+	return lt
 }
 
 // SetLinkType calls pcap_set_datalink on the pcap handle.
@@ -822,6 +1059,7 @@ func FindAllDevs() (ifs []Interface, err error) {
 		iface.Description = C.GoString(dev.description)
 		iface.Addresses = findalladdresses(dev.addresses)
 		iface.Flags = uint32(dev.flags)
+		iface.IsLoopback = iface.Flags&C.PCAP_IF_LOOPBACK != 0 || isNpcapLoopback(iface.Name)
 		ifs[j] = iface
 		j++
 	}
@@ -889,13 +1127,37 @@ func sockaddrToIP(rsa *syscall.RawSockaddr) (IP []byte, err error) {
 }
 
 // WritePacketData calls pcap_sendpacket, injecting the given data into the pcap handle.
+//
+// On Npcap's loopback adapter, data must start with the same 4 byte DLT_NULL
+// header as captured packets, holding the IPv4 or IPv6 protocol family,
+// rather than an Ethernet header.
 func (p *Handle) WritePacketData(data []byte) (err error) {
+	if p.npcapLoopback {
+		if err := checkNpcapLoopbackPacket(data); err != nil {
+			return err
+		}
+	}
 	if -1 == C.pcap_sendpacket(p.cptr, (*C.u_char)(&data[0]), (C.int)(len(data))) {
 		err = p.Error()
 	}
 	return
 }
 
+// checkNpcapLoopbackPacket returns an error if data can't be injected on
+// Npcap's loopback adapter, which only accepts IP packets behind a DLT_NULL
+// header.
+func checkNpcapLoopbackPacket(data []byte) error {
+	if len(data) < 4 {
+		return errors.New("Npcap loopback packets must start with a 4 byte DLT_NULL header")
+	}
+	family := binary.LittleEndian.Uint32(data)
+	switch family {
+	case uint32(layers.ProtocolFamilyIPv4), uint32(layers.ProtocolFamilyIPv6BSD), uint32(layers.ProtocolFamilyIPv6Windows):
+		return nil
+	}
+	return fmt.Errorf("Npcap loopback injection of protocol family %d is unsupported, only IPv4 and IPv6", family)
+}
+
 // Direction is used by Handle.SetDirection.
 type Direction uint8
 
@@ -981,6 +1243,7 @@ func (p *InactiveHandle) Activate() (*Handle, error) {
 		device:      p.device,
 		deviceIndex: p.deviceIndex,
 	}
+	h.npcapLoopback = isNpcapLoopback(h.device)
 	p.cptr = nil
 	return h, nil
 }
@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/google/gopacket"
@@ -117,6 +118,92 @@ func TestPcapFileRead(t *testing.T) {
 	}
 }
 
+func TestPcapReadPacketDataBatch(t *testing.T) {
+	handle, err := OpenOffline("test_loopback.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+	var want [][]byte
+	var wantCI []gopacket.CaptureInfo
+	for {
+		data, ci, err := handle.ReadPacketData()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, data)
+		wantCI = append(wantCI, ci)
+	}
+
+	batched, err := OpenOffline("test_loopback.pcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer batched.Close()
+	var got [][]byte
+	var gotCI []gopacket.CaptureInfo
+	for {
+		// Stop after every fifth packet, which leaves the rest of the batch
+		// for the next call.
+		n := 0
+		err := batched.ReadPacketDataBatch(8, func(data []byte, ci gopacket.CaptureInfo) bool {
+			got = append(got, append([]byte{}, data...))
+			gotCI = append(gotCI, ci)
+			n++
+			return n < 5
+		})
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("batched data mismatch, read %d packets, want %d", len(got), len(want))
+	}
+	if len(wantCI) != len(gotCI) {
+		t.Fatalf("batched capture info mismatch, got %d, want %d", len(gotCI), len(wantCI))
+	}
+	for i := range wantCI {
+		if !wantCI[i].Timestamp.Equal(gotCI[i].Timestamp) || wantCI[i].Length != gotCI[i].Length ||
+			wantCI[i].CaptureLength != gotCI[i].CaptureLength {
+			t.Errorf("packet %d: capture info %+v, want %+v", i, gotCI[i], wantCI[i])
+		}
+	}
+}
+
+func BenchmarkPcapReadPacketData(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		handle, err := OpenOffline("test_loopback.pcap")
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			if _, _, err := handle.ZeroCopyReadPacketData(); err != nil {
+				break
+			}
+		}
+		handle.Close()
+	}
+}
+
+func BenchmarkPcapReadPacketDataBatch(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		handle, err := OpenOffline("test_loopback.pcap")
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			if err := handle.ReadPacketDataBatch(64, func([]byte, gopacket.CaptureInfo) bool { return true }); err != nil {
+				break
+			}
+		}
+		handle.Close()
+	}
+}
+
 func TestBPF(t *testing.T) {
 	handle, err := OpenOffline("test_ethernet.pcap")
 	if err != nil {
@@ -300,3 +387,24 @@ func ExampleBPF() {
 	// SYN flag not set
 	// SYN flag not set
 }
+
+func TestNpcapLoopbackPacket(t *testing.T) {
+	if !isNpcapLoopback(`\Device\NPF_Loopback`) || isNpcapLoopback(`\Device\NPF_{0A1B}`) {
+		t.Error("Npcap loopback adapter not identified by name")
+	}
+	for _, test := range []struct {
+		data []byte
+		ok   bool
+	}{
+		{[]byte{2, 0, 0, 0, 0x45}, true},
+		{[]byte{24, 0, 0, 0, 0x60}, true},
+		{[]byte{23, 0, 0, 0, 0x60}, true},
+		{[]byte{2, 1, 0, 0, 0x45}, false},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, false},
+		{[]byte{2, 0}, false},
+	} {
+		if err := checkNpcapLoopbackPacket(test.data); (err == nil) != test.ok {
+			t.Errorf("%x: got error %v, want ok %v", test.data, err, test.ok)
+		}
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"bytes"
 	"testing"
 	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 )
 
 // test header read
@@ -239,3 +242,42 @@ func TestPacketZeroCopy(t *testing.T) {
 		t.Error("different buffers returned by subsequent ZeroCopyReadPacketData calls")
 	}
 }
+
+// A pcap file of one packet recorded by tcpdump -i any, whose link type is
+// LINKTYPE_LINUX_SLL2.
+func TestPacketSourceLinuxSLL2(t *testing.T) {
+	test := []byte{
+		0xd4, 0xc3, 0xb2, 0xa1, 0x02, 0x00, 0x04, 0x00, // magic, maj, min
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // tz, sigfigs
+		0x00, 0x00, 0x04, 0x00, 0x14, 0x01, 0x00, 0x00, // snaplen, linkType
+		0x70, 0x31, 0xa0, 0x5c, 0xb5, 0xf1, 0x01, 0x00, // sec, usec
+		0x40, 0x00, 0x00, 0x00, 0x40, 0x00, 0x00, 0x00, // cap len, full len
+		0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x03, 0x04, 0x04, 0x06, // data
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x45, 0x00, 0x00, 0x2c,
+		0x6b, 0x5e, 0x40, 0x00, 0x40, 0x01, 0xd1, 0x70, 0x7f, 0x00, 0x00, 0x01,
+		0x7f, 0x00, 0x00, 0x01, 0x08, 0x00, 0x23, 0x13, 0x1c, 0x2b, 0x00, 0x01,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+		0x1c, 0x1d, 0x1e, 0x1f,
+	}
+
+	r, err := NewReader(bytes.NewBuffer(test))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.LinkType() != layers.LinkTypeLinuxSLL2 {
+		t.Fatalf("link type %v, want %v", r.LinkType(), layers.LinkTypeLinuxSLL2)
+	}
+	p, err := gopacket.NewPacketSource(r, r.LinkType()).NextPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ErrorLayer() != nil {
+		t.Error("Failed to decode packet:", p.ErrorLayer().Error())
+	}
+	if p.LinkLayer() == nil || p.LinkLayer().LayerType() != layers.LayerTypeLinuxSLL2 {
+		t.Errorf("link layer %v, want Linux SLL2", p.LinkLayer())
+	}
+	if p.Layer(layers.LayerTypeICMPv4) == nil {
+		t.Error("no ICMPv4 layer")
+	}
+}
@@ -10,6 +10,7 @@ package pfring
 /*
 #cgo LDFLAGS: -lpfring -lpcap
 #include <stdlib.h>
+#include <string.h>
 #include <pfring.h>
 #include <linux/pf_ring.h>
 
@@ -25,6 +26,69 @@ int pfring_readpacketdatato_wrapper(
   // actually used anyway.
   return pfring_recv(ring, &buffer, buffer_len, hdr, 1);
 }
+
+// filtering_rule and hw_filtering_rule are packed, which hides most of their
+// fields from Go, so rules are passed in these plain structs and converted
+// here.
+typedef struct {
+  u_int16_t rule_id;
+  int action;
+  u_int8_t bidirectional;
+  u_int16_t vlan_id;
+  u_int8_t proto;
+  u_int8_t ipv6;
+  u_int32_t shost4, dhost4, shost_mask4, dhost_mask4;
+  u_int8_t shost6[16], dhost6[16], shost_mask6[16], dhost_mask6[16];
+  u_int16_t sport_low, sport_high, dport_low, dport_high;
+} gopacket_filtering_rule;
+
+int pfring_add_filtering_rule_wrapper(pfring* ring, gopacket_filtering_rule* r) {
+  filtering_rule rule;
+  memset(&rule, 0, sizeof(rule));
+  rule.rule_id = r->rule_id;
+  rule.rule_action = (rule_action_behaviour)r->action;
+  rule.bidirectional = r->bidirectional;
+  rule.core_fields.vlan_id = r->vlan_id;
+  rule.core_fields.proto = r->proto;
+  if (r->ipv6) {
+    memcpy(&rule.core_fields.shost.v6, r->shost6, 16);
+    memcpy(&rule.core_fields.dhost.v6, r->dhost6, 16);
+    memcpy(&rule.core_fields.shost_mask.v6, r->shost_mask6, 16);
+    memcpy(&rule.core_fields.dhost_mask.v6, r->dhost_mask6, 16);
+  } else {
+    rule.core_fields.shost.v4 = r->shost4;
+    rule.core_fields.dhost.v4 = r->dhost4;
+    rule.core_fields.shost_mask.v4 = r->shost_mask4;
+    rule.core_fields.dhost_mask.v4 = r->dhost_mask4;
+  }
+  rule.core_fields.sport_low = r->sport_low;
+  rule.core_fields.sport_high = r->sport_high;
+  rule.core_fields.dport_low = r->dport_low;
+  rule.core_fields.dport_high = r->dport_high;
+  return pfring_add_filtering_rule(ring, &rule);
+}
+
+typedef struct {
+  u_int16_t rule_id;
+  u_int8_t proto;
+  u_int32_t s_addr, d_addr;
+  u_int16_t s_port, d_port;
+  u_int16_t queue_id;
+} gopacket_hw_rule;
+
+int pfring_add_hw_rule_wrapper(pfring* ring, gopacket_hw_rule* r) {
+  hw_filtering_rule rule;
+  memset(&rule, 0, sizeof(rule));
+  rule.rule_family_type = intel_82599_five_tuple_rule;
+  rule.rule_id = r->rule_id;
+  rule.rule_family.five_tuple_rule.proto = r->proto;
+  rule.rule_family.five_tuple_rule.s_addr = r->s_addr;
+  rule.rule_family.five_tuple_rule.d_addr = r->d_addr;
+  rule.rule_family.five_tuple_rule.s_port = r->s_port;
+  rule.rule_family.five_tuple_rule.d_port = r->d_port;
+  rule.rule_family.five_tuple_rule.queue_id = r->queue_id;
+  return pfring_add_hw_rule(ring, &rule);
+}
 */
 import "C"
 
@@ -60,6 +124,9 @@ type Ring struct {
 	// huge memory hit, so to handle that we store them here instead.
 	pkthdr C.struct_pfring_pkthdr
 	bufPtr *C.u_char
+	// softwareRules and hardwareRules are the IDs of the rules added to
+	// the ring, guarded by mu.
+	softwareRules, hardwareRules ruleIDs
 }
 
 // Flag provides a set of boolean flags to use when creating a new ring.
@@ -306,6 +373,224 @@ func (r *Ring) Stats() (s Stats, err error) {
 	return
 }
 
+// ExtendedStats provides the statistics PF_RING keeps on a ring beyond
+// Stats.  The counters are for the whole ring: PF_RING doesn't count
+// packets per RX channel (NIC queue) of a ring opened on several, so
+// per-queue statistics are not provided.  To get them, open one ring per
+// channel, as "device@channel", and call ExtendedStats on each.
+type ExtendedStats struct {
+	Stats
+	// Shunted is the number of packets the ring received only the start
+	// of, due to a shunting rule.
+	Shunted uint64
+	// Channels is the number of RX channels of the ring's device.
+	Channels int
+}
+
+// ExtendedStats returns extended statistics for the ring.
+func (r *Ring) ExtendedStats() (s ExtendedStats, err error) {
+	var stats C.pfring_stat
+	if rv := C.pfring_stats(r.cptr, &stats); rv != 0 {
+		err = fmt.Errorf("Unable to get ring stats, got error code %d", rv)
+		return
+	}
+	s.Received = uint64(stats.recv)
+	s.Dropped = uint64(stats.drop)
+	s.Shunted = uint64(stats.shunt)
+	s.Channels = int(C.pfring_get_num_rx_channels(r.cptr))
+	return
+}
+
+// FilteringMode determines whether filtering rules are evaluated by the NIC,
+// in software, or both.
+type FilteringMode C.filtering_mode
+
+const (
+	// FilteringHardwareAndSoftware allows both hardware and software rules.
+	FilteringHardwareAndSoftware FilteringMode = C.hardware_and_software
+	// FilteringHardwareOnly allows only hardware rules.
+	FilteringHardwareOnly FilteringMode = C.hardware_only
+	// FilteringSoftwareOnly allows only software rules.
+	FilteringSoftwareOnly FilteringMode = C.software_only
+)
+
+// SetFilteringMode sets where the ring's filtering rules are evaluated.  It
+// must be called before rules are added.
+func (r *Ring) SetFilteringMode(mode FilteringMode) error {
+	if rv := C.pfring_set_filtering_mode(r.cptr, C.filtering_mode(mode)); rv != 0 {
+		return fmt.Errorf("Unable to set filtering mode, got error code %d", rv)
+	}
+	return nil
+}
+
+// SetDefaultFilteringPolicy sets what happens to packets matching none of
+// the ring's filtering rules: they're accepted if accept is true, and dropped
+// otherwise.
+func (r *Ring) SetDefaultFilteringPolicy(accept bool) error {
+	var policy C.u_int8_t
+	if accept {
+		policy = 1
+	}
+	if rv := C.pfring_toggle_filtering_policy(r.cptr, policy); rv != 0 {
+		return fmt.Errorf("Unable to set default filtering policy, got error code %d", rv)
+	}
+	return nil
+}
+
+// RuleAction is what a filtering rule does with the packets it matches.
+type RuleAction C.rule_action_behaviour
+
+const (
+	// RuleActionForward passes matching packets to the ring.
+	RuleActionForward RuleAction = C.forward_packet_and_stop_rule_evaluation
+	// RuleActionDrop drops matching packets.
+	RuleActionDrop RuleAction = C.dont_forward_packet_and_stop_rule_evaluation
+)
+
+// FilteringRule is a software filtering rule.  Zero fields match anything,
+// and rules are evaluated in order of their IDs.
+type FilteringRule struct {
+	// ID is the rule's ID, which must not be used by another software rule
+	// added to the ring.  If zero, AddFilteringRule picks one not in use.
+	ID     uint16
+	Action RuleAction
+	// Bidirectional rules also match packets with source and destination
+	// swapped.
+	Bidirectional bool
+	VLAN          uint16
+	Protocol      uint8
+	// SrcIP and DstIP must be of the same family, as must their masks.
+	// A nil mask matches the address exactly.
+	SrcIP, DstIP     net.IP
+	SrcMask, DstMask net.IPMask
+	// Port ranges are inclusive.
+	SrcPortLow, SrcPortHigh uint16
+	DstPortLow, DstPortHigh uint16
+}
+
+// HardwareRuleDrop is the HardwareRule queue that drops matching packets.
+const HardwareRuleDrop = -1
+
+// HardwareRule is a five-tuple filtering rule evaluated by the NIC, on
+// supported hardware.  Zero fields match anything.
+type HardwareRule struct {
+	// ID is the rule's ID, which must not be used by another hardware rule
+	// added to the ring.  If zero, AddHardwareRule picks one not in use.
+	ID       uint16
+	Protocol uint8
+	// SrcIP and DstIP must be IPv4 addresses.
+	SrcIP, DstIP     net.IP
+	SrcPort, DstPort uint16
+	// Queue is the RX queue matching packets are steered to, or
+	// HardwareRuleDrop to drop them.
+	Queue int
+}
+
+// reserveRuleID reserves id, or an unused ID if id is zero, in ids.
+func (r *Ring) reserveRuleID(ids *ruleIDs, id uint16) (uint16, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ids.reserve(id)
+}
+
+// releaseRuleID releases id in ids.
+func (r *Ring) releaseRuleID(ids *ruleIDs, id uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids.release(id)
+}
+
+// AddFilteringRule adds a software filtering rule to the ring, returning
+// its ID.
+func (r *Ring) AddFilteringRule(rule FilteringRule) (id uint16, err error) {
+	var c C.gopacket_filtering_rule
+	c.action = C.int(rule.Action)
+	if rule.Bidirectional {
+		c.bidirectional = 1
+	}
+	c.vlan_id = C.u_int16_t(rule.VLAN)
+	c.proto = C.u_int8_t(rule.Protocol)
+	c.sport_low = C.u_int16_t(rule.SrcPortLow)
+	c.sport_high = C.u_int16_t(rule.SrcPortHigh)
+	c.dport_low = C.u_int16_t(rule.DstPortLow)
+	c.dport_high = C.u_int16_t(rule.DstPortHigh)
+	a, err := rule.addresses()
+	if err != nil {
+		return 0, err
+	}
+	if a.ipv6 {
+		c.ipv6 = 1
+		for i := 0; i < 16; i++ {
+			c.shost6[i] = C.u_int8_t(a.shost6[i])
+			c.dhost6[i] = C.u_int8_t(a.dhost6[i])
+			c.shost_mask6[i] = C.u_int8_t(a.shostMask6[i])
+			c.dhost_mask6[i] = C.u_int8_t(a.dhostMask6[i])
+		}
+	} else {
+		c.shost4, c.dhost4 = C.u_int32_t(a.shost4), C.u_int32_t(a.dhost4)
+		c.shost_mask4, c.dhost_mask4 = C.u_int32_t(a.shostMask4), C.u_int32_t(a.dhostMask4)
+	}
+	if id, err = r.reserveRuleID(&r.softwareRules, rule.ID); err != nil {
+		return 0, err
+	}
+	c.rule_id = C.u_int16_t(id)
+	if rv := C.pfring_add_filtering_rule_wrapper(r.cptr, &c); rv != 0 {
+		r.releaseRuleID(&r.softwareRules, id)
+		return 0, fmt.Errorf("Unable to add filtering rule, got error code %d", rv)
+	}
+	return id, nil
+}
+
+// RemoveFilteringRule removes the software filtering rule with the given ID.
+func (r *Ring) RemoveFilteringRule(id uint16) error {
+	if rv := C.pfring_remove_filtering_rule(r.cptr, C.u_int16_t(id)); rv != 0 {
+		return fmt.Errorf("Unable to remove filtering rule, got error code %d", rv)
+	}
+	r.releaseRuleID(&r.softwareRules, id)
+	return nil
+}
+
+// AddHardwareRule adds a filtering rule to the NIC, returning its ID.  The
+// ring's filtering mode must allow hardware rules.
+func (r *Ring) AddHardwareRule(rule HardwareRule) (id uint16, err error) {
+	src, err := ipv4Rule(rule.SrcIP)
+	if err != nil {
+		return 0, err
+	}
+	dst, err := ipv4Rule(rule.DstIP)
+	if err != nil {
+		return 0, err
+	}
+	queue, err := rule.queueID()
+	if err != nil {
+		return 0, err
+	}
+	var c C.gopacket_hw_rule
+	c.proto = C.u_int8_t(rule.Protocol)
+	c.s_addr, c.d_addr = C.u_int32_t(src), C.u_int32_t(dst)
+	c.s_port = C.u_int16_t(rule.SrcPort)
+	c.d_port = C.u_int16_t(rule.DstPort)
+	c.queue_id = C.u_int16_t(queue)
+	if id, err = r.reserveRuleID(&r.hardwareRules, rule.ID); err != nil {
+		return 0, err
+	}
+	c.rule_id = C.u_int16_t(id)
+	if rv := C.pfring_add_hw_rule_wrapper(r.cptr, &c); rv != 0 {
+		r.releaseRuleID(&r.hardwareRules, id)
+		return 0, fmt.Errorf("Unable to add hardware rule, got error code %d", rv)
+	}
+	return id, nil
+}
+
+// RemoveHardwareRule removes the hardware filtering rule with the given ID.
+func (r *Ring) RemoveHardwareRule(id uint16) error {
+	if rv := C.pfring_remove_hw_rule(r.cptr, C.u_int16_t(id)); rv != 0 {
+		return fmt.Errorf("Unable to remove hardware rule, got error code %d", rv)
+	}
+	r.releaseRuleID(&r.hardwareRules, id)
+	return nil
+}
+
 // Direction is a simple enum to set which packets (TX, RX, or both) a ring
 // captures.
 type Direction C.packet_direction
@@ -0,0 +1,137 @@
+// Copyright 2012 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pfring
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ruleIDs tracks the IDs of a ring's rules of one kind, so that rules added
+// without an ID are given one that isn't in use.
+type ruleIDs struct {
+	used map[uint16]bool
+	// last is the last ID picked for a rule added without one.
+	last uint16
+}
+
+// reserve marks id as used and returns it or, if id is zero, picks the next
+// unused ID after the last one picked.
+func (r *ruleIDs) reserve(id uint16) (uint16, error) {
+	if r.used == nil {
+		r.used = make(map[uint16]bool)
+	}
+	if id != 0 {
+		if r.used[id] {
+			return 0, fmt.Errorf("rule ID %d already in use", id)
+		}
+		r.used[id] = true
+		return id, nil
+	}
+	for i := 0; i < 0xffff; i++ {
+		r.last++
+		if r.last == 0 {
+			r.last = 1
+		}
+		if !r.used[r.last] {
+			r.used[r.last] = true
+			return r.last, nil
+		}
+	}
+	return 0, errors.New("no unused rule ID")
+}
+
+// release marks id as unused.
+func (r *ruleIDs) release(id uint16) {
+	delete(r.used, id)
+}
+
+// ipv4Rule returns an IPv4 address or mask as PF_RING's rules want it, in
+// host byte order.
+func ipv4Rule(ip []byte) (uint32, error) {
+	if ip == nil {
+		return 0, nil
+	}
+	if ip4 := net.IP(ip).To4(); ip4 != nil {
+		return binary.BigEndian.Uint32(ip4), nil
+	}
+	if len(ip) == net.IPv4len {
+		// An IPv4 mask.
+		return binary.BigEndian.Uint32(ip), nil
+	}
+	return 0, fmt.Errorf("%v is not an IPv4 address", net.IP(ip))
+}
+
+// ruleAddresses are the addresses and masks of a FilteringRule, as PF_RING
+// wants them.  Only those of the rule's family are set.
+type ruleAddresses struct {
+	ipv6                                   bool
+	shost4, dhost4, shostMask4, dhostMask4 uint32
+	shost6, dhost6, shostMask6, dhostMask6 [16]byte
+}
+
+// addresses converts the addresses and masks of rule.  Nil addresses match
+// anything, and nil masks match the address exactly.
+func (rule *FilteringRule) addresses() (a ruleAddresses, err error) {
+	if rule.SrcIP.To4() == nil && rule.SrcIP != nil || rule.DstIP.To4() == nil && rule.DstIP != nil {
+		a.ipv6 = true
+		for _, f := range []struct {
+			dst  *[16]byte
+			addr []byte
+			mask bool
+		}{
+			{&a.shost6, rule.SrcIP, false},
+			{&a.dhost6, rule.DstIP, false},
+			{&a.shostMask6, rule.SrcMask, true},
+			{&a.dhostMask6, rule.DstMask, true},
+		} {
+			switch {
+			case f.mask && f.addr == nil:
+				for i := range f.dst {
+					f.dst[i] = 0xff
+				}
+			case f.addr == nil:
+			case len(f.addr) != net.IPv6len || net.IP(f.addr).To4() != nil && !f.mask:
+				return a, fmt.Errorf("%v is not an IPv6 address", net.IP(f.addr))
+			default:
+				copy(f.dst[:], f.addr)
+			}
+		}
+		return a, nil
+	}
+	if a.shost4, err = ipv4Rule(rule.SrcIP); err != nil {
+		return
+	}
+	if a.dhost4, err = ipv4Rule(rule.DstIP); err != nil {
+		return
+	}
+	a.shostMask4, a.dhostMask4 = 0xffffffff, 0xffffffff
+	if rule.SrcMask != nil {
+		if a.shostMask4, err = ipv4Rule(rule.SrcMask); err != nil {
+			return
+		}
+	}
+	if rule.DstMask != nil {
+		if a.dhostMask4, err = ipv4Rule(rule.DstMask); err != nil {
+			return
+		}
+	}
+	return a, nil
+}
+
+// queueID returns the queue of rule as PF_RING wants it.
+func (rule *HardwareRule) queueID() (uint16, error) {
+	switch {
+	case rule.Queue == HardwareRuleDrop:
+		return 0xffff, nil
+	case rule.Queue < 0 || rule.Queue >= 0xffff:
+		return 0, errors.New("invalid hardware rule queue")
+	}
+	return uint16(rule.Queue), nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2012 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package pfring
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRuleIDs(t *testing.T) {
+	var ids ruleIDs
+	for _, want := range []uint16{3, 5} {
+		if id, err := ids.reserve(want); err != nil || id != want {
+			t.Fatalf("reserve(%d) = %d, %v", want, id, err)
+		}
+	}
+	if _, err := ids.reserve(3); err == nil {
+		t.Error("reserving a used ID succeeded")
+	}
+	for _, want := range []uint16{1, 2, 4, 6} {
+		if id, err := ids.reserve(0); err != nil || id != want {
+			t.Fatalf("reserve(0) = %d, %v, want %d", id, err, want)
+		}
+	}
+	ids.release(2)
+	if id, err := ids.reserve(0); err != nil || id != 7 {
+		t.Errorf("reserve(0) = %d, %v, want 7", id, err)
+	}
+	if id, err := ids.reserve(2); err != nil || id != 2 {
+		t.Errorf("reserve(2) after release = %d, %v", id, err)
+	}
+}
+
+func TestRuleIDsWrap(t *testing.T) {
+	ids := ruleIDs{last: 0xfffe}
+	for _, want := range []uint16{0xffff, 1} {
+		if id, err := ids.reserve(0); err != nil || id != want {
+			t.Fatalf("reserve(0) = %d, %v, want %d", id, err, want)
+		}
+	}
+	for i := 2; i <= 0xfffe; i++ {
+		if _, err := ids.reserve(0); err != nil {
+			t.Fatalf("reserve(0) with %d IDs used: %v", i-1, err)
+		}
+	}
+	if id, err := ids.reserve(0); err == nil {
+		t.Errorf("reserve(0) with all IDs used = %d", id)
+	}
+	ids.release(0x1234)
+	if id, err := ids.reserve(0); err != nil || id != 0x1234 {
+		t.Errorf("reserve(0) = %d, %v, want 0x1234", id, err)
+	}
+}
+
+func TestFilteringRuleAddresses(t *testing.T) {
+	a, err := (&FilteringRule{
+		SrcIP:   net.IPv4(192, 168, 1, 2),
+		SrcMask: net.CIDRMask(24, 32),
+		DstIP:   net.IP{10, 0, 0, 1},
+	}).addresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ruleAddresses{
+		shost4:     0xc0a80102,
+		dhost4:     0x0a000001,
+		shostMask4: 0xffffff00,
+		dhostMask4: 0xffffffff,
+	}); a != want {
+		t.Errorf("IPv4 rule: got %+v, want %+v", a, want)
+	}
+
+	a, err = (&FilteringRule{
+		DstIP:   net.ParseIP("2001:db8::1"),
+		DstMask: net.CIDRMask(64, 128),
+	}).addresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ruleAddresses{ipv6: true}
+	copy(want.dhost6[:], net.ParseIP("2001:db8::1"))
+	copy(want.dhostMask6[:], net.CIDRMask(64, 128))
+	for i := range want.shostMask6 {
+		want.shostMask6[i] = 0xff
+	}
+	if a != want {
+		t.Errorf("IPv6 rule: got %+v, want %+v", a, want)
+	}
+
+	for _, rule := range []FilteringRule{
+		{SrcIP: net.IPv4(192, 168, 1, 2), DstIP: net.ParseIP("2001:db8::1")},
+		{SrcIP: net.ParseIP("2001:db8::1"), SrcMask: net.CIDRMask(24, 32)},
+		{SrcIP: net.IP{1, 2, 3}},
+		{SrcIP: net.IPv4(192, 168, 1, 2), SrcMask: net.CIDRMask(64, 128)},
+	} {
+		if a, err := rule.addresses(); err == nil {
+			t.Errorf("%+v: got %+v, want error", rule, a)
+		}
+	}
+}
+
+func TestHardwareRuleQueueID(t *testing.T) {
+	for _, test := range []struct {
+		queue int
+		want  uint16
+		ok    bool
+	}{
+		{0, 0, true},
+		{3, 3, true},
+		{0xfffe, 0xfffe, true},
+		{HardwareRuleDrop, 0xffff, true},
+		{-2, 0, false},
+		{0xffff, 0, false},
+	} {
+		got, err := (&HardwareRule{Queue: test.queue}).queueID()
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("queue %d: got %d, %v, want %d", test.queue, got, err, test.want)
+		}
+	}
+}
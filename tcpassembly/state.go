@@ -0,0 +1,168 @@
+// Copyright 2012 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package tcpassembly
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// stateVersion is the version of the format written by SaveState.  It must be
+// bumped whenever savedConnection or savedPage change incompatibly.
+const stateVersion = 1
+
+// savedPage is the saved form of a page of out-of-order data.
+type savedPage struct {
+	Seq   Sequence
+	Bytes []byte
+	End   bool
+	Seen  time.Time
+}
+
+// savedConnection is the saved form of a connection.  Its key is stored as
+// the raw endpoints of its two flows.
+type savedConnection struct {
+	NetType, TCPType gopacket.EndpointType
+	NetSrc, NetDst   []byte
+	TCPSrc, TCPDst   []byte
+	NextSeq          Sequence
+	Created          time.Time
+	LastSeen         time.Time
+	Pages            []savedPage
+}
+
+// SaveStateOptions provide options for saving assembler state.
+type SaveStateOptions struct {
+	T time.Time // If nonzero, connections that haven't seen a packet since T are skipped.
+}
+
+// StreamRestorer may be implemented by a StreamFactory to create the streams
+// of connections restored by LoadState differently from those of new
+// connections.  If it isn't, LoadState uses the factory's New method.
+type StreamRestorer interface {
+	// Restore should return a stream for the given restored TCP key.
+	Restore(netFlow, tcpFlow gopacket.Flow) Stream
+}
+
+// SaveState writes the state of all connections in the assembler's
+// StreamPool to w: their keys, the next sequence number each expects, and
+// the out-of-order data buffered for them.  Streams themselves aren't saved.
+//
+// SaveState is meant to be called when the assembler is being shut down,
+// after the last Assemble call; connections stay open and keep their data.
+func (a *Assembler) SaveState(w io.Writer, opt SaveStateOptions) error {
+	var saved []savedConnection
+	for _, conn := range a.connPool.connections() {
+		conn.mu.Lock()
+		if conn.closed || (!opt.T.IsZero() && conn.lastSeen.Before(opt.T)) {
+			conn.mu.Unlock()
+			continue
+		}
+		netSrc, netDst := conn.key[0].Endpoints()
+		tcpSrc, tcpDst := conn.key[1].Endpoints()
+		s := savedConnection{
+			NetType:  conn.key[0].EndpointType(),
+			NetSrc:   netSrc.Raw(),
+			NetDst:   netDst.Raw(),
+			TCPType:  conn.key[1].EndpointType(),
+			TCPSrc:   tcpSrc.Raw(),
+			TCPDst:   tcpDst.Raw(),
+			NextSeq:  conn.nextSeq,
+			Created:  conn.created,
+			LastSeen: conn.lastSeen,
+		}
+		for p := conn.first; p != nil; p = p.next {
+			s.Pages = append(s.Pages, savedPage{
+				Seq:   p.seq,
+				Bytes: append([]byte(nil), p.Bytes...),
+				End:   p.End,
+				Seen:  p.Seen,
+			})
+		}
+		conn.mu.Unlock()
+		saved = append(saved, s)
+	}
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(stateVersion); err != nil {
+		return err
+	}
+	return enc.Encode(saved)
+}
+
+// LoadState restores connections saved by SaveState into the assembler's
+// StreamPool, so reassembly continues where it left off once their packets
+// are seen again.  A stream is created for each restored connection with the
+// pool's StreamFactory, using its Restore method if it's a StreamRestorer.
+// Connections already in the pool are left alone.
+//
+// It returns the number of connections restored.
+func (a *Assembler) LoadState(r io.Reader) (restored int, err error) {
+	dec := gob.NewDecoder(r)
+	var version int
+	if err := dec.Decode(&version); err != nil {
+		return 0, err
+	}
+	if version != stateVersion {
+		return 0, fmt.Errorf("unsupported assembler state version %d", version)
+	}
+	var saved []savedConnection
+	if err := dec.Decode(&saved); err != nil {
+		return 0, err
+	}
+	p := a.connPool
+	restorer, _ := p.factory.(StreamRestorer)
+	for _, s := range saved {
+		k := key{
+			gopacket.NewFlow(s.NetType, s.NetSrc, s.NetDst),
+			gopacket.NewFlow(s.TCPType, s.TCPSrc, s.TCPDst),
+		}
+		p.mu.RLock()
+		exists := p.conns[k] != nil
+		p.mu.RUnlock()
+		if exists {
+			continue
+		}
+		var stream Stream
+		if restorer != nil {
+			stream = restorer.Restore(k[0], k[1])
+		} else {
+			stream = p.factory.New(k[0], k[1])
+		}
+		p.mu.Lock()
+		if p.conns[k] != nil {
+			p.mu.Unlock()
+			continue
+		}
+		conn := p.newConnection(k, stream, s.Created)
+		conn.nextSeq = s.NextSeq
+		conn.lastSeen = s.LastSeen
+		for _, sp := range s.Pages {
+			for bytes := sp.Bytes; ; {
+				page := a.pc.next(sp.Seen)
+				length := min(len(bytes), pageBytes)
+				page.Bytes = page.buf[:length]
+				copy(page.Bytes, bytes)
+				page.seq = sp.Seq.Add(len(sp.Bytes) - len(bytes))
+				bytes = bytes[length:]
+				page.End = sp.End && len(bytes) == 0
+				conn.pushBetween(conn.last, nil, page, page)
+				conn.pages++
+				if len(bytes) == 0 {
+					break
+				}
+			}
+		}
+		p.conns[k] = conn
+		p.mu.Unlock()
+		restored++
+	}
+	return restored, nil
+}
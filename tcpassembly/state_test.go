@@ -0,0 +1,69 @@
+// Copyright 2012 Google, Inc. All rights reserved.
+//
+// Use of this source code is governed by a BSD-style license
+// that can be found in the LICENSE file in the root of the source
+// tree.
+
+package tcpassembly
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+type testRestorer struct {
+	testFactory
+	restored []gopacket.Flow
+}
+
+func (t *testRestorer) Restore(netFlow, tcpFlow gopacket.Flow) Stream {
+	t.restored = append(t.restored, tcpFlow)
+	return t
+}
+
+func TestSaveLoadState(t *testing.T) {
+	start := time.Unix(1000, 0)
+	a := NewAssembler(NewStreamPool(&testFactory{}))
+	a.AssembleWithTimestamp(netFlow, &layers.TCP{SrcPort: 1, DstPort: 2, SYN: true, Seq: 999}, start)
+	a.AssembleWithTimestamp(netFlow, &layers.TCP{SrcPort: 1, DstPort: 2, Seq: 1003,
+		BaseLayer: layers.BaseLayer{Payload: []byte{4, 5, 6}}}, start)
+	// An idle connection, skipped when saving.
+	a.AssembleWithTimestamp(netFlow.Reverse(), &layers.TCP{SrcPort: 1, DstPort: 2, SYN: true, Seq: 1}, start.Add(-time.Hour))
+
+	var buf bytes.Buffer
+	if err := a.SaveState(&buf, SaveStateOptions{T: start.Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	fact := &testRestorer{}
+	b := NewAssembler(NewStreamPool(fact))
+	if n, err := b.LoadState(&buf); err != nil || n != 1 {
+		t.Fatalf("LoadState restored %d connections, error %v", n, err)
+	}
+	if len(fact.restored) != 1 {
+		t.Fatalf("unexpected restored streams %v", fact.restored)
+	}
+	b.AssembleWithTimestamp(netFlow, &layers.TCP{SrcPort: 1, DstPort: 2, Seq: 1000,
+		BaseLayer: layers.BaseLayer{Payload: []byte{1, 2, 3}}}, start.Add(time.Second))
+	want := []Reassembly{
+		{Bytes: []byte{1, 2, 3}},
+		{Bytes: []byte{4, 5, 6}},
+	}
+	if !reflect.DeepEqual(fact.reassembly, want) {
+		t.Errorf("want: %v\n got: %v", want, fact.reassembly)
+	}
+}
+
+func TestLoadStateVersion(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write([]byte{3, 4, 0, 4}) // gob encoding of int 2
+	a := NewAssembler(NewStreamPool(&testFactory{}))
+	if _, err := a.LoadState(&buf); err == nil {
+		t.Error("expected error loading unknown state version")
+	}
+}
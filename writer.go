@@ -203,8 +203,17 @@ func (w *serializeBuffer) PushLayer(l LayerType) {
 //   firstPayload := buf.Bytes()  // contains byte representation of a(b(c))
 //   gopacket.SerializeLayers(buf, opts, d, e, f)
 //   secondPayload := buf.Bytes()  // contains byte representation of d(e(f)). firstPayload is now invalidated, since the SerializeLayers call Clears buf.
+//
+// If opts.ComputeChecksums is set, layers whose checksum covers a
+// pseudo-header from the network layer (TCP, UDP and ICMPv6 in
+// gopacket/layers) and don't have one set yet are given the closest network
+// layer before them in layers, as if SetNetworkLayerForChecksum had been
+// called for the duration of the call.
 func SerializeLayers(w SerializeBuffer, opts SerializeOptions, layers ...SerializableLayer) error {
 	w.Clear()
+	if opts.ComputeChecksums {
+		defer setNetworkLayersForChecksum(layers)()
+	}
 	for i := len(layers) - 1; i >= 0; i-- {
 		layer := layers[i]
 		err := layer.SerializeTo(w, opts)
@@ -216,6 +225,39 @@ func SerializeLayers(w SerializeBuffer, opts SerializeOptions, layers ...Seriali
 	return nil
 }
 
+// checksumLayer is implemented by layers whose checksum covers a pseudo-header
+// built from the network layer that wraps them.
+type checksumLayer interface {
+	SetNetworkLayerForChecksum(NetworkLayer) error
+	NetworkLayerForChecksum() NetworkLayer
+}
+
+// setNetworkLayersForChecksum tells each checksumLayer in layers without a
+// network layer about the closest NetworkLayer preceding it.  It returns a
+// function unsetting them again, so that a layer reused with another
+// network layer doesn't keep the pseudo-header of this one.
+func setNetworkLayersForChecksum(layers []SerializableLayer) (reset func()) {
+	var network NetworkLayer
+	var set []checksumLayer
+	for _, layer := range layers {
+		if c, ok := layer.(checksumLayer); ok && network != nil && c.NetworkLayerForChecksum() == nil {
+			// A network layer that can't provide a pseudo-header is
+			// reported when the checksum is computed.
+			if c.SetNetworkLayerForChecksum(network) == nil {
+				set = append(set, c)
+			}
+		}
+		if n, ok := layer.(NetworkLayer); ok {
+			network = n
+		}
+	}
+	return func() {
+		for _, c := range set {
+			c.SetNetworkLayerForChecksum(nil)
+		}
+	}
+}
+
 // SerializePacket is a convenience function that calls SerializeLayers
 // on packet's Layers().
 // It returns an error if one of the packet layers is not a SerializebleLayer.